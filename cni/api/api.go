@@ -14,6 +14,9 @@ type PodNetworkInterfaceInfo struct {
 	PodEndpointId string
 	ContainerID   string
 	IPAddresses   []net.IPNet
+	IfName        string `json:",omitempty"`
+	HostIfName    string `json:",omitempty"`
+	HnsEndpointID string `json:",omitempty"`
 }
 
 type AzureCNIState struct {
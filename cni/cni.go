@@ -24,15 +24,20 @@ const (
 	// nonstandard CNI spec command, used to dump CNI state to stdout
 	CmdGetEndpointsState = "GET_ENDPOINT_STATE"
 
+	// nonstandard CNI spec command, used to dump the address pool usage of the IPAM plugin to stdout
+	CmdGetPoolUsage = "GET_POOL_USAGE"
+
 	// CNI errors.
 	ErrRuntime = 100
+	// ErrHostPortConflict - a host port of the pod is used by another pod.
+	ErrHostPortConflict = 101
 
 	// DefaultVersion is the CNI version used when no version is specified in a network config file.
 	defaultVersion = "0.2.0"
 )
 
 // Supported CNI versions.
-var supportedVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0"}
+var supportedVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", "1.0.0"}
 
 // CNI contract.
 type PluginApi interface {
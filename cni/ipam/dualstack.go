@@ -0,0 +1,153 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+)
+
+var ipv6DefaultRouteDstPrefix = net.IPNet{
+	IP:   net.IPv6zero,
+	Mask: net.CIDRMask(0, 128),
+}
+
+// addDualStack allocates an IPv4 and an IPv6 address for the endpoint in a single transaction, so that a failing
+// IPv6 allocation doesn't leak the IPv4 address, and returns both in the result.
+func (plugin *ipamPlugin) addDualStack(
+	nwCfg *cni.NetworkConfig,
+	args *cniSkel.CmdArgs,
+	options map[string]string,
+) (*cniTypesCurr.Result, error) {
+	var err error
+
+	// Select the requested interface.
+	options[ipam.OptInterfaceName] = nwCfg.Master
+
+	// Allocate the address pools of the network if they are not specified.
+	if nwCfg.IPAM.Subnet == "" {
+		var poolID string
+		poolID, nwCfg.IPAM.Subnet, err = plugin.am.RequestPool(nwCfg.IPAM.AddrSpace, "", "", options, false)
+		if err != nil {
+			err = plugin.Errorf("Failed to allocate pool: %v", err)
+			return nil, err
+		}
+
+		defer func() {
+			if err != nil {
+				log.Printf("[cni-ipam] Releasing pool %v.", poolID)
+				_ = plugin.am.ReleasePool(nwCfg.IPAM.AddrSpace, poolID)
+			}
+		}()
+	}
+
+	if nwCfg.IPAM.IPv6Subnet == "" {
+		var poolID string
+		poolID, nwCfg.IPAM.IPv6Subnet, err = plugin.am.RequestPool(nwCfg.IPAM.AddrSpace, "", "", options, true)
+		if err != nil {
+			err = plugin.Errorf("Failed to allocate v6 pool: %v", err)
+			return nil, err
+		}
+
+		defer func() {
+			if err != nil {
+				log.Printf("[cni-ipam] Releasing pool %v.", poolID)
+				_ = plugin.am.ReleasePool(nwCfg.IPAM.AddrSpace, poolID)
+			}
+		}()
+	}
+
+	log.Printf("[cni-ipam] Allocating dual-stack addresses from subnets %v and %v.", nwCfg.IPAM.Subnet, nwCfg.IPAM.IPv6Subnet)
+
+	// Report the usage of the pools, also when one is exhausted.
+	defer plugin.reportPoolUsage(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet)
+	defer plugin.reportPoolUsage(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.IPv6Subnet)
+
+	// Honor the static addresses or IP reservation requested for the pod.
+	requested, reservation := requestedAddress(nwCfg, args)
+	requestedV6 := runtimeConfigAddress(nwCfg, true)
+	if err = setReservationOptions(nwCfg, reservation, options); err != nil {
+		err = plugin.Errorf("Failed to allocate address: %v", err)
+		return nil, err
+	}
+
+	address, addressV6, err := plugin.am.RequestDualStackAddresses(
+		nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet, requested, nwCfg.IPAM.IPv6Subnet, requestedV6, options)
+	if err != nil {
+		err = plugin.Errorf("Failed to allocate dual-stack addresses: %v", err)
+		return nil, err
+	}
+
+	// On failure, release the addresses.
+	defer func() {
+		if err != nil {
+			log.Printf("[cni-ipam] Releasing addresses %v and %v.", address, addressV6)
+			_ = plugin.am.ReleaseAddress(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet, "", options)
+			_ = plugin.am.ReleaseAddress(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.IPv6Subnet, "", options)
+		}
+	}()
+
+	log.Printf("[cni-ipam] Allocated addresses %v and %v.", address, addressV6)
+
+	ipAddress, err := platform.ConvertStringToIPNet(address)
+	if err != nil {
+		err = plugin.Errorf("Failed to parse address: %v", err)
+		return nil, err
+	}
+
+	ipAddressV6, err := platform.ConvertStringToIPNet(addressV6)
+	if err != nil {
+		err = plugin.Errorf("Failed to parse address: %v", err)
+		return nil, err
+	}
+
+	// Query pool information for gateways and DNS servers.
+	apInfo, err := plugin.am.GetPoolInfo(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet)
+	if err != nil {
+		err = plugin.Errorf("Failed to get pool information: %v", err)
+		return nil, err
+	}
+
+	apInfoV6, err := plugin.am.GetPoolInfo(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.IPv6Subnet)
+	if err != nil {
+		err = plugin.Errorf("Failed to get v6 pool information: %v", err)
+		return nil, err
+	}
+
+	result := &cniTypesCurr.Result{
+		IPs: []*cniTypesCurr.IPConfig{
+			{
+				Address: *ipAddress,
+				Gateway: apInfo.Gateway,
+			},
+			{
+				Address: *ipAddressV6,
+				Gateway: apInfoV6.Gateway,
+			},
+		},
+		Routes: []*cniTypes.Route{
+			{
+				Dst: ipv4DefaultRouteDstPrefix,
+				GW:  apInfo.Gateway,
+			},
+			{
+				Dst: ipv6DefaultRouteDstPrefix,
+				GW:  apInfoV6.Gateway,
+			},
+		},
+	}
+
+	for _, dnsServer := range apInfo.DnsServers {
+		result.DNS.Nameservers = append(result.DNS.Nameservers, dnsServer.String())
+	}
+
+	return result, nil
+}
@@ -6,16 +6,21 @@ package ipam
 import (
 	"encoding/json"
 	"net"
+	"os"
 	"strconv"
 
+	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/util"
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/ipam"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/pkg/errors"
 )
 
 const ipamV6 = "azure-vnet-ipamv6"
@@ -151,6 +156,16 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 	options := make(map[string]string)
 	options[ipam.OptAddressID] = args.ContainerID
 
+	if nwCfg.IPAM.Mode == string(util.DualStack) {
+		result, err = plugin.addDualStack(nwCfg, args, options)
+		if err != nil {
+			return err
+		}
+
+		err = plugin.outputResult(nwCfg, args, result)
+		return err
+	}
+
 	// Check if an address pool is specified.
 	if nwCfg.IPAM.Subnet == "" {
 		var poolID string
@@ -183,10 +198,28 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 		log.Printf("[cni-ipam] Allocated address poolID %v with subnet %v.", poolID, subnet)
 	}
 
+	// Report the usage of the pool, also when it is exhausted.
+	defer plugin.reportPoolUsage(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet)
+
+	// Honor the static address or IP reservation requested for the pod.
+	requested, reservation := requestedAddress(nwCfg, args)
+	if err = setReservationOptions(nwCfg, reservation, options); err != nil {
+		err = plugin.Errorf("Failed to allocate address: %v", err)
+		return err
+	}
+
 	// Allocate an address for the endpoint.
-	address, err := plugin.am.RequestAddress(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet, nwCfg.IPAM.Address, options)
+	address, err := plugin.am.RequestAddress(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet, requested, options)
 	if err != nil {
-		err = plugin.Errorf("Failed to allocate address: %v", err)
+		switch {
+		case requested != "":
+			err = plugin.Errorf("Failed to allocate requested address %s: %v", requested, err)
+		case reservation != "":
+			err = plugin.Errorf("Failed to allocate address from reservation %s (%s): %v",
+				reservation, options[ipam.OptAddressRange], err)
+		default:
+			err = plugin.Errorf("Failed to allocate address: %v", err)
+		}
 		return err
 	}
 
@@ -235,11 +268,16 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 		result.DNS.Nameservers = append(result.DNS.Nameservers, dnsServer.String())
 	}
 
+	err = plugin.outputResult(nwCfg, args, result)
+	return err
+}
+
+// outputResult converts the result to the requested CNI version and returns it to the caller.
+func (plugin *ipamPlugin) outputResult(nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs, result *cniTypesCurr.Result) error {
 	// Convert result to the requested CNI version.
-	res, err := result.GetAsVersion(nwCfg.CNIVersion)
+	res, err := cni.ConvertResult(result, nwCfg.CNIVersion)
 	if err != nil {
-		err = plugin.Errorf("Failed to convert result: %v", err)
-		return err
+		return plugin.Errorf("Failed to convert result: %v", err)
 	}
 
 	// Output the result.
@@ -254,6 +292,63 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 	return nil
 }
 
+// PrintPoolUsage writes the address usage of the pools to stdout.
+func (plugin *ipamPlugin) PrintPoolUsage() error {
+	b, err := json.MarshalIndent(plugin.am.GetPoolUsage(), "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal pool usage")
+	}
+
+	if _, err = os.Stdout.Write(b); err != nil {
+		return errors.Wrap(err, "failed to write pool usage to stdout")
+	}
+
+	return nil
+}
+
+// reportPoolUsage logs the address usage of the pool and sends it to the telemetry service, so that pools close to
+// exhaustion can be found.
+func (plugin *ipamPlugin) reportPoolUsage(asID, subnet string) {
+	var usage *ipam.PoolUsage
+	usages := plugin.am.GetPoolUsage()
+	for i := range usages {
+		if usages[i].AddressSpace == asID && usages[i].Subnet == subnet {
+			usage = &usages[i]
+			break
+		}
+	}
+	if usage == nil || usage.Total == 0 {
+		return
+	}
+
+	log.Printf("[cni-ipam] Pool %s usage: total %d allocated %d reserved %d available %d.",
+		subnet, usage.Total, usage.Allocated, usage.Reserved, usage.Available)
+
+	tb := telemetry.NewTelemetryBuffer()
+	if err := tb.Connect(); err != nil {
+		log.Printf("[cni-ipam] Cannot connect to telemetry service: %v", err)
+		return
+	}
+	defer tb.Close()
+
+	metric := telemetry.AIMetric{
+		Metric: aitelemetry.Metric{
+			Name:       telemetry.IPAMPoolUsageMetricStr,
+			Value:      float64(usage.Total-usage.Available) * 100 / float64(usage.Total),
+			AppVersion: plugin.Version,
+			CustomDimensions: map[string]string{
+				telemetry.SubnetStr:    subnet,
+				telemetry.TotalStr:     strconv.Itoa(usage.Total),
+				telemetry.AllocatedStr: strconv.Itoa(usage.Allocated),
+				telemetry.ReservedStr:  strconv.Itoa(usage.Reserved),
+			},
+		},
+	}
+	if err := telemetry.SendCNIMetric(&metric, tb); err != nil {
+		log.Printf("[cni-ipam] Failed to send pool usage metric: %v", err)
+	}
+}
+
 // Get handles CNI Get commands.
 func (plugin *ipamPlugin) Get(args *cniSkel.CmdArgs) error {
 	return nil
@@ -278,9 +373,20 @@ func (plugin *ipamPlugin) Delete(args *cniSkel.CmdArgs) error {
 	// Select the requested interface.
 	options := make(map[string]string)
 	options[ipam.OptAddressID] = args.ContainerID
+	if nwCfg.IPAM.Quarantine != "" {
+		options[ipam.OptAddressQuarantine] = nwCfg.IPAM.Quarantine
+	}
 
 	err = plugin.am.ReleaseAddress(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet, nwCfg.IPAM.Address, options)
 
+	// Without an address, the IPv6 address of a dual-stack endpoint is released by its container too.
+	if nwCfg.IPAM.Mode == string(util.DualStack) && nwCfg.IPAM.Address == "" && nwCfg.IPAM.IPv6Subnet != "" {
+		errV6 := plugin.am.ReleaseAddress(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.IPv6Subnet, "", options)
+		if err == nil {
+			err = errV6
+		}
+	}
+
 	if err != nil {
 		err = plugin.Errorf("Failed to release address: %v", err)
 		return err
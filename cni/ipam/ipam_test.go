@@ -5,6 +5,7 @@ package ipam
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -18,7 +19,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/ipam"
 )
 
 var (
@@ -323,3 +326,53 @@ func AssertProperAddressSpace(address net.IPNet) {
 	Expect(network.Contains(address.IP)).Should(Equal(true))
 	Expect(address.Mask).Should(Equal(network.Mask))
 }
+
+var _ = Describe("Test static addresses", func() {
+	Context("When an address is requested for the pod", func() {
+		It("Should prefer the network configuration over the CNI args and runtime config", func() {
+			nwCfg := &cni.NetworkConfig{}
+			nwCfg.RuntimeConfig.IPs = []string{"fd00::5/64", "10.0.0.5/16"}
+			args := &cniSkel.CmdArgs{Args: "IgnoreUnknown=1;K8S_POD_NAME=pod-1"}
+
+			address, reservation := requestedAddress(nwCfg, args)
+			Expect(address).To(Equal("10.0.0.5"))
+			Expect(reservation).To(BeEmpty())
+
+			args.Args = "IgnoreUnknown=1;IP=10.0.0.6;IP_RESERVATION=stable"
+			address, reservation = requestedAddress(nwCfg, args)
+			Expect(address).To(Equal("10.0.0.6"))
+			Expect(reservation).To(Equal("stable"))
+
+			nwCfg.IPAM.Address = "10.0.0.7"
+			address, _ = requestedAddress(nwCfg, args)
+			Expect(address).To(Equal("10.0.0.7"))
+		})
+
+		It("Should only use the runtime config ips of the address family of the plugin", func() {
+			nwCfg := &cni.NetworkConfig{}
+			nwCfg.IPAM.Type = ipamV6
+			nwCfg.RuntimeConfig.IPs = []string{"10.0.0.5", "fd00::5"}
+
+			address, _ := requestedAddress(nwCfg, &cniSkel.CmdArgs{})
+			Expect(address).To(Equal("fd00::5"))
+		})
+	})
+
+	Context("When IP reservations are configured", func() {
+		It("Should allocate from the requested reservation only", func() {
+			nwCfg := &cni.NetworkConfig{}
+			nwCfg.IPAM.Reservations = map[string]string{"stable": "10.0.0.6/31", "db": "10.0.0.4/32"}
+
+			options := map[string]string{}
+			Expect(setReservationOptions(nwCfg, "stable", options)).To(Succeed())
+			Expect(options).To(Equal(map[string]string{ipam.OptAddressRange: "10.0.0.6/31"}))
+
+			options = map[string]string{}
+			Expect(setReservationOptions(nwCfg, "", options)).To(Succeed())
+			Expect(options).To(Equal(map[string]string{ipam.OptReservedRanges: "10.0.0.4/32,10.0.0.6/31"}))
+
+			err := setReservationOptions(nwCfg, "unknown", map[string]string{})
+			Expect(errors.Is(err, errUnknownReservation)).To(BeTrue())
+		})
+	})
+})
@@ -62,7 +62,12 @@ func main() {
 		panic("ipam plugin fatal error")
 	}
 
-	err = ipamPlugin.Execute(cni.PluginApi(ipamPlugin))
+	// used to dump the pool usage
+	if os.Getenv(cni.Cmd) == cni.CmdGetPoolUsage {
+		err = ipamPlugin.PrintPoolUsage()
+	} else {
+		err = ipamPlugin.Execute(cni.PluginApi(ipamPlugin))
+	}
 
 	ipamPlugin.Stop()
 
@@ -0,0 +1,86 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/log"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/pkg/errors"
+)
+
+var errUnknownReservation = errors.New("unknown IP reservation")
+
+// requestedAddress returns the static address and the name of the IP reservation requested for the pod. The address
+// of the network configuration takes precedence over the IP CNI argument, which takes precedence over the ips the
+// runtime sets from the pod annotations.
+func requestedAddress(nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs) (string, string) {
+	var address, reservation string
+
+	podCfg, err := cni.ParseCniArgs(args.Args)
+	if err != nil {
+		log.Printf("[cni-ipam] Ignoring CNI args %q: %v", args.Args, err)
+	} else {
+		address = string(podCfg.IP)
+		reservation = string(podCfg.IP_RESERVATION)
+	}
+
+	if nwCfg.IPAM.Address != "" {
+		address = nwCfg.IPAM.Address
+	}
+
+	if address == "" {
+		address = runtimeConfigAddress(nwCfg, nwCfg.IPAM.Type == ipamV6)
+	}
+
+	return address, reservation
+}
+
+// runtimeConfigAddress returns the first address of the family in the ips the runtime requested for the pod.
+func runtimeConfigAddress(nwCfg *cni.NetworkConfig, isIpv6 bool) string {
+	for _, ip := range nwCfg.RuntimeConfig.IPs {
+		// The ips capability may be given in CIDR notation.
+		if addr, _, err := net.ParseCIDR(ip); err == nil {
+			ip = addr.String()
+		}
+
+		if addr := net.ParseIP(ip); addr != nil && (addr.To4() == nil) == isIpv6 {
+			return addr.String()
+		}
+	}
+
+	return ""
+}
+
+// setReservationOptions restricts the allocation to the requested IP reservation, or to the addresses outside of
+// all IP reservations when none is requested.
+func setReservationOptions(nwCfg *cni.NetworkConfig, reservation string, options map[string]string) error {
+	if reservation != "" {
+		addrRange, ok := nwCfg.IPAM.Reservations[reservation]
+		if !ok {
+			return errors.Wrapf(errUnknownReservation, "reservation %s", reservation)
+		}
+
+		options[ipam.OptAddressRange] = addrRange
+		return nil
+	}
+
+	if len(nwCfg.IPAM.Reservations) == 0 {
+		return nil
+	}
+
+	ranges := make([]string, 0, len(nwCfg.IPAM.Reservations))
+	for _, addrRange := range nwCfg.IPAM.Reservations {
+		ranges = append(ranges, addrRange)
+	}
+	sort.Strings(ranges)
+	options[ipam.OptReservedRanges] = strings.Join(ranges, ",")
+
+	return nil
+}
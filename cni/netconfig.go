@@ -9,6 +9,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/network/policy"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
 )
 
 const (
@@ -31,6 +32,27 @@ type PortMapping struct {
 type RuntimeConfig struct {
 	PortMappings []PortMapping    `json:"portMappings,omitempty"`
 	DNS          RuntimeDNSConfig `json:"dns,omitempty"`
+	Bandwidth    *BandwidthConfig `json:"bandwidth,omitempty"`
+	// IPs are the static addresses requested for the pod when the plugin announces the ips capability.
+	IPs []string `json:"ips,omitempty"`
+	// Routes are the extra routes requested for the pod, which the runtime sets from the pod annotations when the
+	// plugin announces the routes capability.
+	Routes []RuntimeRoute `json:"routes,omitempty"`
+}
+
+// RuntimeRoute is a route to a destination prefix via a gateway, in the format of the routes of a CNI result.
+type RuntimeRoute struct {
+	Dst string `json:"dst"`
+	GW  string `json:"gw,omitempty"`
+}
+
+// BandwidthConfig is set by kubelet from the kubernetes.io/ingress-bandwidth and egress-bandwidth pod
+// annotations when the plugin announces the bandwidth capability. Rates are in bits per second, bursts in bits.
+type BandwidthConfig struct {
+	IngressRate  uint64 `json:"ingressRate,omitempty"`
+	IngressBurst uint64 `json:"ingressBurst,omitempty"`
+	EgressRate   uint64 `json:"egressRate,omitempty"`
+	EgressBurst  uint64 `json:"egressBurst,omitempty"`
 }
 
 // https://github.com/kubernetes/kubernetes/blob/master/pkg/kubelet/dockershim/network/cni/cni.go#L104
@@ -46,8 +68,15 @@ type IPAM struct {
 	Environment   string `json:"environment,omitempty"`
 	AddrSpace     string `json:"addressSpace,omitempty"`
 	Subnet        string `json:"subnet,omitempty"`
+	IPv6Subnet    string `json:"ipv6Subnet,omitempty"`
 	Address       string `json:"ipAddress,omitempty"`
 	QueryInterval string `json:"queryInterval,omitempty"`
+	// Reservations are named blocks of the subnet, given as CIDRs, whose addresses are only allocated to the pods
+	// requesting the reservation.
+	Reservations map[string]string `json:"reservations,omitempty"`
+	// Quarantine is set by the network plugin when it releases an address found in use by another host, for the
+	// duration the address is not allocated again.
+	Quarantine string `json:"quarantine,omitempty"`
 }
 
 // NetworkConfig represents Azure CNI plugin network configuration.
@@ -72,18 +101,45 @@ type NetworkConfig struct {
 	EnableExactMatchForPodName    bool            `json:"enableExactMatchForPodName,omitempty"`
 	DisableHairpinOnHostInterface bool            `json:"disableHairpinOnHostInterface,omitempty"`
 	DisableIPTableLock            bool            `json:"disableIPTableLock,omitempty"`
+	EnableStatelessCNI            bool            `json:"enableStatelessCni,omitempty"`
 	CNSUrl                        string          `json:"cnsurl,omitempty"`
+	EnableCNSResponseCache        bool            `json:"enableCnsResponseCache,omitempty"`
+	WaitForNCProgramming          bool            `json:"waitForNCProgramming,omitempty"`
 	ExecutionMode                 string          `json:"executionMode,omitempty"`
 	IPAM                          IPAM            `json:"ipam,omitempty"`
 	DNS                           cniTypes.DNS    `json:"dns,omitempty"`
 	RuntimeConfig                 RuntimeConfig   `json:"runtimeConfig,omitempty"`
 	WindowsSettings               WindowsSettings `json:"windowsSettings,omitempty"`
+	DuplicateAddressDetection     *DADSettings    `json:"duplicateAddressDetection,omitempty"`
 	AdditionalArgs                []KVPair        `json:"AdditionalArgs,omitempty"`
+	RawPrevResult                 json.RawMessage `json:"prevResult,omitempty"`
+	// DisableCheck is set in the configuration lists whose networks must not be checked. Runtimes don't call CHECK
+	// for them, and the plugin skips the check when one does.
+	DisableCheck bool `json:"disableCheck,omitempty"`
 }
 
 type WindowsSettings struct {
 	EnableLoopbackDSR           bool `json:"enableLoopbackDSR,omitempty"`
 	HnsTimeoutDurationInSeconds int  `json:"hnsTimeoutDurationInSeconds,omitempty"`
+	// OutboundNATExceptions are added to the exceptions of the OutBoundNAT policy of the endpoints. The endpoints
+	// of the network are updated when they change, and repaired when another agent overwrites their policy.
+	OutboundNATExceptions *OutboundNATExceptions `json:"outboundNATExceptions,omitempty"`
+}
+
+// OutboundNATExceptions are the prefixes the pods reach without SNAT to the address of the node.
+type OutboundNATExceptions struct {
+	ClusterCIDRs []string `json:"clusterCidrs,omitempty"`
+	ServiceCIDRs []string `json:"serviceCidrs,omitempty"`
+	// InfraCIDRs are the prefixes of the infrastructure the pods reach with their own address, e.g. the vnet.
+	InfraCIDRs []string `json:"infraCidrs,omitempty"`
+}
+
+// DADSettings enable the duplicate address detection of the addresses of the pods on Linux, so that an address
+// already in use on the network, e.g. after the IPAM state was lost, fails the ADD instead of silently conflicting.
+type DADSettings struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// TimeoutMs is how long other hosts are given to answer for the address, one second by default.
+	TimeoutMs int `json:"timeoutMs,omitempty"`
 }
 
 type K8SPodEnvArgs struct {
@@ -91,6 +147,10 @@ type K8SPodEnvArgs struct {
 	K8S_POD_NAMESPACE          cniTypes.UnmarshallableString `json:"K8S_POD_NAMESPACE,omitempty"`
 	K8S_POD_NAME               cniTypes.UnmarshallableString `json:"K8S_POD_NAME,omitempty"`
 	K8S_POD_INFRA_CONTAINER_ID cniTypes.UnmarshallableString `json:"K8S_POD_INFRA_CONTAINER_ID,omitempty"`
+	IP                         cniTypes.UnmarshallableString `json:"IP,omitempty"`
+	IP_RESERVATION             cniTypes.UnmarshallableString `json:"IP_RESERVATION,omitempty"`
+	// ROUTES are extra routes for the pod, separated by commas, e.g. "10.0.0.0/8 via 10.1.0.1,192.168.0.0/16 via 10.1.0.1".
+	ROUTES cniTypes.UnmarshallableString `json:"ROUTES,omitempty"`
 }
 
 // ParseCniArgs unmarshals cni arguments.
@@ -120,6 +180,16 @@ func ParseNetworkConfig(b []byte) (*NetworkConfig, error) {
 	return &nwCfg, nil
 }
 
+// PrevResult returns the result of the previous plugin in the chain passed to CHECK and DEL commands,
+// or nil if there is none.
+func (nwCfg *NetworkConfig) PrevResult() (*cniTypesCurr.Result, error) {
+	if len(nwCfg.RawPrevResult) == 0 {
+		return nil, nil
+	}
+
+	return parseResult(nwCfg.CNIVersion, nwCfg.RawPrevResult)
+}
+
 // GetPoliciesFromNwCfg returns network policies from network config.
 func GetPoliciesFromNwCfg(kvp []KVPair) []policy.Policy {
 	var policies []policy.Policy
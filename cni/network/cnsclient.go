@@ -2,8 +2,17 @@ package network
 
 import (
 	"context"
+	"net/url"
+	"time"
 
+	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/avast/retry-go/v3"
+	"github.com/pkg/errors"
 )
 
 type cnsclient interface {
@@ -11,3 +20,284 @@ type cnsclient interface {
 	ReleaseIPAddress(ctx context.Context, ipconfig cns.IPConfigRequest) error
 	GetNetworkConfiguration(ctx context.Context, orchestratorContext []byte) (*cns.GetNetworkContainerResponse, error)
 }
+
+// ncProgrammingStatusGetter is implemented by the CNS clients which can tell whether the NIC has been programmed with
+// the current version of the NCs.
+type ncProgrammingStatusGetter interface {
+	GetNCProgrammingStatus(ctx context.Context, ncIDs ...string) ([]cns.NCProgrammingStatus, error)
+}
+
+// hostPortReserver is implemented by the CNS clients which can reserve the host ports of the pods.
+type hostPortReserver interface {
+	ReserveHostPorts(ctx context.Context, request cns.ReserveHostPortsRequest) ([]cns.HostPortReservation, error)
+}
+
+const (
+	cnsClientStoreName = "azure-vnet-cns-client"
+	// the circuit breaker state and the cached responses are stored under these keys.
+	cnsCircuitStoreKey = "CNSCircuit"
+	cnsCacheStoreKey   = "NetworkConfigurations"
+
+	cnsClientAttemptTimeout   = 5 * time.Second
+	cnsClientRetryAttempts    = 3
+	cnsClientRetryDelay       = 100 * time.Millisecond
+	cnsClientRetryMaxJitter   = 100 * time.Millisecond
+	cnsCircuitFailureTreshold = 5
+	cnsCircuitCooldown        = 5 * time.Second
+	cnsCacheMaxAge            = 24 * time.Hour
+	cnsClientStoreLockTimeout = 2 * time.Second
+)
+
+var errCNSCircuitOpen = errors.New("CNS circuit breaker is open, not calling CNS")
+
+// cnsCircuit is the state of the circuit breaker, shared by the CNI processes through the store.
+type cnsCircuit struct {
+	ConsecutiveFailures int
+	OpenUntil           time.Time
+}
+
+// cachedNetworkConfiguration is the last known good network configuration of a pod.
+type cachedNetworkConfiguration struct {
+	Response *cns.GetNetworkContainerResponse
+	CachedAt time.Time
+}
+
+// resilientCNSClient calls CNS with a timeout per attempt and retries the calls which fail because CNS can't be
+// reached, with a jittered backoff. As every CNI call runs in its own process, the circuit breaker is kept in a
+// store: after cnsCircuitFailureTreshold consecutive failed calls, CNS isn't called for cnsCircuitCooldown, so that
+// a pod creation storm doesn't pile up on a restarting CNS. If enabled, the network configurations CNS returns are
+// cached in the store as well, and the last known good one of the pod is returned while CNS can't be reached.
+type resilientCNSClient struct {
+	cli            cnsclient
+	store          store.KeyValueStore
+	cacheResponses bool
+	attemptTimeout time.Duration
+	retryDelay     time.Duration
+	maxJitter      time.Duration
+	now            func() time.Time
+}
+
+// newResilientCNSClient wraps the CNS client with the circuit breaker and cache stored in the CNI runtime path.
+// If the store can't be created, the client still retries but has no circuit breaker and cache.
+func newResilientCNSClient(cli cnsclient, nwCfg *cni.NetworkConfig) *resilientCNSClient {
+	c := &resilientCNSClient{
+		cli:            cli,
+		cacheResponses: nwCfg.EnableCNSResponseCache,
+		attemptTimeout: cnsClientAttemptTimeout,
+		retryDelay:     cnsClientRetryDelay,
+		maxJitter:      cnsClientRetryMaxJitter,
+		now:            time.Now,
+	}
+
+	lockclient, err := processlock.NewFileLock(platform.CNILockPath + cnsClientStoreName + store.LockExtension)
+	if err != nil {
+		log.Printf("[cni-net] Not using the CNS circuit breaker, failed to create lock: %v", err)
+		return c
+	}
+	c.store, err = store.NewJsonFileStore(platform.CNIRuntimePath+cnsClientStoreName+".json", lockclient)
+	if err != nil {
+		log.Printf("[cni-net] Not using the CNS circuit breaker, failed to create store: %v", err)
+		c.store = nil
+	}
+	return c
+}
+
+func (c *resilientCNSClient) RequestIPAddress(ctx context.Context, ipconfig cns.IPConfigRequest) (*cns.IPConfigResponse, error) {
+	var response *cns.IPConfigResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		response, err = c.cli.RequestIPAddress(ctx, ipconfig)
+		return err //nolint:wrapcheck // returned as is by the retries
+	})
+	return response, err
+}
+
+func (c *resilientCNSClient) ReleaseIPAddress(ctx context.Context, ipconfig cns.IPConfigRequest) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		return c.cli.ReleaseIPAddress(ctx, ipconfig) //nolint:wrapcheck // returned as is by the retries
+	})
+}
+
+func (c *resilientCNSClient) GetNetworkConfiguration(ctx context.Context, orchestratorContext []byte) (*cns.GetNetworkContainerResponse, error) {
+	var response *cns.GetNetworkContainerResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		response, err = c.cli.GetNetworkConfiguration(ctx, orchestratorContext)
+		return err //nolint:wrapcheck // returned as is by the retries
+	})
+	if !c.cacheResponses || c.store == nil {
+		return response, err
+	}
+
+	key := string(orchestratorContext)
+	if err == nil {
+		c.cacheNetworkConfiguration(key, response)
+		return response, nil
+	}
+	if !isCNSUnreachableError(err) {
+		return response, err
+	}
+	if cached, ok := c.cachedNetworkConfiguration(key); ok {
+		log.Printf("[cni-net] Using the network configuration cached at %s as CNS can't be reached: %v", cached.CachedAt, err)
+		return cached.Response, nil
+	}
+	return response, err
+}
+
+// GetNCProgrammingStatus returns no status if the wrapped client can't get them.
+func (c *resilientCNSClient) GetNCProgrammingStatus(ctx context.Context, ncIDs ...string) ([]cns.NCProgrammingStatus, error) {
+	getter, ok := c.cli.(ncProgrammingStatusGetter)
+	if !ok {
+		return nil, nil
+	}
+
+	var statuses []cns.NCProgrammingStatus
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		statuses, err = getter.GetNCProgrammingStatus(ctx, ncIDs...)
+		return err //nolint:wrapcheck // returned as is by the retries
+	})
+	return statuses, err
+}
+
+// ReserveHostPorts reserves nothing if the wrapped client can't reserve host ports.
+func (c *resilientCNSClient) ReserveHostPorts(ctx context.Context, request cns.ReserveHostPortsRequest) ([]cns.HostPortReservation, error) {
+	reserver, ok := c.cli.(hostPortReserver)
+	if !ok {
+		return nil, nil
+	}
+
+	var reservations []cns.HostPortReservation
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		reservations, err = reserver.ReserveHostPorts(ctx, request)
+		return err //nolint:wrapcheck // returned as is by the retries
+	})
+	return reservations, err
+}
+
+// call calls CNS through the circuit breaker, retrying while CNS can't be reached.
+func (c *resilientCNSClient) call(ctx context.Context, request func(context.Context) error) error {
+	circuit := c.readCircuit()
+	if c.now().Before(circuit.OpenUntil) {
+		return errors.Wrapf(errCNSCircuitOpen, "until %s after %d consecutive failures", circuit.OpenUntil, circuit.ConsecutiveFailures)
+	}
+
+	err := retry.Do(func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.attemptTimeout)
+		defer cancel()
+		return request(attemptCtx)
+	},
+		retry.Context(ctx),
+		retry.Attempts(cnsClientRetryAttempts),
+		retry.Delay(c.retryDelay),
+		retry.MaxJitter(c.maxJitter),
+		retry.DelayType(retry.CombineDelay(retry.BackOffDelay, retry.RandomDelay)),
+		retry.LastErrorOnly(true),
+		retry.RetryIf(isCNSUnreachableError),
+		retry.OnRetry(func(n uint, err error) {
+			log.Printf("[cni-net] Retrying CNS call after attempt %d failed: %v", n+1, err)
+		}))
+
+	switch {
+	case err != nil && isCNSUnreachableError(err):
+		c.updateStore(func() {
+			// other CNI processes may have failed in the meantime.
+			circuit := c.readCircuit()
+			circuit.ConsecutiveFailures++
+			if circuit.ConsecutiveFailures >= cnsCircuitFailureTreshold {
+				circuit.OpenUntil = c.now().Add(cnsCircuitCooldown)
+				log.Printf("[cni-net] Opening the CNS circuit breaker until %s after %d consecutive failures",
+					circuit.OpenUntil, circuit.ConsecutiveFailures)
+			}
+			c.writeCircuit(circuit)
+		})
+	case circuit.ConsecutiveFailures > 0:
+		c.updateStore(func() {
+			c.writeCircuit(cnsCircuit{})
+		})
+	}
+	return err //nolint:wrapcheck // the last error of the request is returned
+}
+
+// isCNSUnreachableError returns whether the CNS call failed because CNS couldn't be reached or didn't answer in
+// time, rather than because CNS failed the request.
+func isCNSUnreachableError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func isStoreEmpty(err error) bool {
+	return errors.Is(err, store.ErrKeyNotFound) || errors.Is(err, store.ErrStoreEmpty)
+}
+
+// updateStore locks the store while update reads and writes it, so that the CNI processes running in parallel
+// don't lose each other's updates. The store isn't updated if it can't be locked.
+func (c *resilientCNSClient) updateStore(update func()) {
+	if c.store == nil {
+		return
+	}
+	if err := c.store.Lock(cnsClientStoreLockTimeout); err != nil {
+		log.Printf("[cni-net] Failed to lock the CNS client store: %v", err)
+		return
+	}
+	defer func() {
+		if err := c.store.Unlock(); err != nil {
+			log.Printf("[cni-net] Failed to unlock the CNS client store: %v", err)
+		}
+	}()
+	update()
+}
+
+func (c *resilientCNSClient) readCircuit() cnsCircuit {
+	var circuit cnsCircuit
+	if c.store == nil {
+		return circuit
+	}
+	if err := c.store.Read(cnsCircuitStoreKey, &circuit); err != nil && !isStoreEmpty(err) {
+		log.Printf("[cni-net] Failed to read the CNS circuit breaker: %v", err)
+	}
+	return circuit
+}
+
+func (c *resilientCNSClient) writeCircuit(circuit cnsCircuit) {
+	if c.store == nil {
+		return
+	}
+	if err := c.store.Write(cnsCircuitStoreKey, circuit); err != nil {
+		log.Printf("[cni-net] Failed to write the CNS circuit breaker: %v", err)
+	}
+}
+
+func (c *resilientCNSClient) cachedNetworkConfigurations() map[string]cachedNetworkConfiguration {
+	cache := map[string]cachedNetworkConfiguration{}
+	if err := c.store.Read(cnsCacheStoreKey, &cache); err != nil && !isStoreEmpty(err) {
+		log.Printf("[cni-net] Failed to read the cached network configurations: %v", err)
+	}
+	return cache
+}
+
+func (c *resilientCNSClient) cachedNetworkConfiguration(key string) (cachedNetworkConfiguration, bool) {
+	cached, ok := c.cachedNetworkConfigurations()[key]
+	if !ok || c.now().Sub(cached.CachedAt) > cnsCacheMaxAge {
+		return cachedNetworkConfiguration{}, false
+	}
+	return cached, true
+}
+
+func (c *resilientCNSClient) cacheNetworkConfiguration(key string, response *cns.GetNetworkContainerResponse) {
+	c.updateStore(func() {
+		now := c.now()
+		cache := c.cachedNetworkConfigurations()
+		// drop the configurations of the pods which are long gone.
+		for k := range cache {
+			if now.Sub(cache[k].CachedAt) > cnsCacheMaxAge {
+				delete(cache, k)
+			}
+		}
+		cache[key] = cachedNetworkConfiguration{Response: response, CachedAt: now}
+		if err := c.store.Write(cnsCacheStoreKey, cache); err != nil {
+			log.Printf("[cni-net] Failed to cache the network configuration: %v", err)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/stretchr/testify/require"
+)
+
+var errCNSUnreachable = &url.Error{Op: "Post", URL: "http://localhost:10090", Err: syscall.ECONNREFUSED}
+
+// networkConfigCNSClient returns the network configuration unless it is given an error to fail with.
+type networkConfigCNSClient struct {
+	flakyCNSClient
+	config *cns.GetNetworkContainerResponse
+	err    error
+}
+
+func (c *networkConfigCNSClient) GetNetworkConfiguration(context.Context, []byte) (*cns.GetNetworkContainerResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.config, nil
+}
+
+func newTestResilientCNSClient(cli cnsclient, now *time.Time) *resilientCNSClient {
+	return &resilientCNSClient{
+		cli:            cli,
+		store:          store.NewMockStore(""),
+		attemptTimeout: time.Second,
+		maxJitter:      1,
+		now:            func() time.Time { return *now },
+	}
+}
+
+func TestResilientCNSClientRetries(t *testing.T) {
+	now := time.Now()
+	client := &flakyCNSClient{errs: []error{errCNSUnreachable, errCNSUnreachable}, response: &cns.IPConfigResponse{}}
+	c := newTestResilientCNSClient(client, &now)
+
+	_, err := c.RequestIPAddress(context.Background(), cns.IPConfigRequest{})
+	require.NoError(t, err)
+	require.Len(t, client.requests, 3, "unreachable CNS is retried")
+	require.Equal(t, cnsCircuit{}, c.readCircuit())
+
+	client = &flakyCNSClient{errs: []error{&cnscli.CNSClientError{Code: types.FailedToAllocateIPConfig}}}
+	c.cli = client
+	_, err = c.RequestIPAddress(context.Background(), cns.IPConfigRequest{})
+	require.Error(t, err)
+	require.Len(t, client.requests, 1, "errors of CNS are not retried")
+	require.Equal(t, cnsCircuit{}, c.readCircuit())
+}
+
+func TestResilientCNSClientCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	client := &flakyCNSClient{}
+	for i := 0; i < 3*(cnsCircuitFailureTreshold+1); i++ {
+		client.errs = append(client.errs, errCNSUnreachable)
+	}
+	c := newTestResilientCNSClient(client, &now)
+
+	for i := 0; i < cnsCircuitFailureTreshold; i++ {
+		require.Error(t, c.ReleaseIPAddress(context.Background(), cns.IPConfigRequest{}))
+	}
+	require.Len(t, client.releases, 3*cnsCircuitFailureTreshold)
+
+	// the circuit is open, CNS isn't called.
+	err := c.ReleaseIPAddress(context.Background(), cns.IPConfigRequest{})
+	require.True(t, errors.Is(err, errCNSCircuitOpen), "unexpected error %v", err)
+	require.Len(t, client.releases, 3*cnsCircuitFailureTreshold)
+
+	// CNS is called again after the cooldown, a failure opens the circuit again.
+	now = now.Add(cnsCircuitCooldown)
+	require.Error(t, c.ReleaseIPAddress(context.Background(), cns.IPConfigRequest{}))
+	require.Len(t, client.releases, 3*(cnsCircuitFailureTreshold+1))
+	err = c.ReleaseIPAddress(context.Background(), cns.IPConfigRequest{})
+	require.True(t, errors.Is(err, errCNSCircuitOpen), "unexpected error %v", err)
+
+	// a success closes the circuit.
+	now = now.Add(cnsCircuitCooldown)
+	require.NoError(t, c.ReleaseIPAddress(context.Background(), cns.IPConfigRequest{}))
+	require.Equal(t, cnsCircuit{}, c.readCircuit())
+}
+
+func TestResilientCNSClientSharesCircuit(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	// every CNI process has its own store of the same file.
+	newProcessClient := func() *resilientCNSClient {
+		lock, err := processlock.NewFileLock(filepath.Join(dir, cnsClientStoreName+store.LockExtension))
+		require.NoError(t, err)
+		kvs, err := store.NewJsonFileStore(filepath.Join(dir, cnsClientStoreName+".json"), lock)
+		require.NoError(t, err)
+		c := newTestResilientCNSClient(&flakyCNSClient{errs: []error{errCNSUnreachable, errCNSUnreachable, errCNSUnreachable}}, &now)
+		c.store = kvs
+		return c
+	}
+
+	newProcessClient().writeCircuit(cnsCircuit{})
+	first, second := newProcessClient(), newProcessClient()
+	require.Equal(t, cnsCircuit{}, first.readCircuit())
+	require.Error(t, second.ReleaseIPAddress(context.Background(), cns.IPConfigRequest{}))
+	require.Error(t, first.ReleaseIPAddress(context.Background(), cns.IPConfigRequest{}))
+
+	// the failure of the second process isn't lost by the first one, which read the circuit before it.
+	require.Equal(t, 2, newProcessClient().readCircuit().ConsecutiveFailures)
+}
+
+func TestResilientCNSClientCache(t *testing.T) {
+	now := time.Now()
+	config := &cns.GetNetworkContainerResponse{NetworkContainerID: "nc"}
+	client := &networkConfigCNSClient{config: config}
+	c := newTestResilientCNSClient(client, &now)
+	c.cacheResponses = true
+
+	got, err := c.GetNetworkConfiguration(context.Background(), []byte("pod"))
+	require.NoError(t, err)
+	require.Equal(t, config, got)
+
+	// the last known good configuration of the pod is returned while CNS can't be reached.
+	client.err = errCNSUnreachable
+	got, err = c.GetNetworkConfiguration(context.Background(), []byte("pod"))
+	require.NoError(t, err)
+	require.Equal(t, config, got)
+
+	_, err = c.GetNetworkConfiguration(context.Background(), []byte("other pod"))
+	require.Error(t, err)
+
+	// errors of CNS are returned.
+	client.err = &cnscli.CNSClientError{Code: types.UnknownContainerID}
+	_, err = c.GetNetworkConfiguration(context.Background(), []byte("pod"))
+	require.Error(t, err)
+
+	// stale configurations aren't used.
+	client.err = errCNSUnreachable
+	now = now.Add(cnsCacheMaxAge + time.Second)
+	_, err = c.GetNetworkConfiguration(context.Background(), []byte("pod"))
+	require.Error(t, err)
+}
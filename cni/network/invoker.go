@@ -20,6 +20,13 @@ type IPAMInvoker interface {
 	Delete(address *net.IPNet, nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs, options map[string]interface{}) error
 }
 
+// addressQuarantiner is implemented by the IPAM invokers which can quarantine an address, so that it is not
+// allocated again for a while, such as an address found in use by another host.
+type addressQuarantiner interface {
+	// Quarantine releases the address and quarantines it.
+	Quarantine(address *net.IPNet, nwCfg *cni.NetworkConfig, options map[string]interface{}) error
+}
+
 type IPAMAddConfig struct {
 	nwCfg   *cni.NetworkConfig
 	args    *cniSkel.CmdArgs
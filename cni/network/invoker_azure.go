@@ -6,8 +6,10 @@ import (
 	"os"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/util"
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/ipam"
 	"github.com/Azure/azure-container-networking/log"
@@ -18,6 +20,9 @@ import (
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
 )
 
+// addressQuarantinePeriod is how long an address found in use by another host isn't allocated again.
+const addressQuarantinePeriod = time.Hour
+
 type AzureIPAMInvoker struct {
 	plugin delegatePlugin
 	nwInfo *network.NetworkInfo
@@ -51,6 +56,10 @@ func (invoker *AzureIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, er
 		addConfig.nwCfg.IPAM.Subnet = invoker.nwInfo.Subnets[0].Prefix.String()
 	}
 
+	if isDualStackIPAM(addConfig.nwCfg) {
+		return invoker.addDualStack(addConfig)
+	}
+
 	// Call into IPAM plugin to allocate an address pool for the network.
 	addResult.ipv4Result, err = invoker.plugin.DelegateAdd(addConfig.nwCfg.IPAM.Type, addConfig.nwCfg)
 
@@ -95,6 +104,63 @@ func (invoker *AzureIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, er
 	return addResult, err
 }
 
+// addDualStack allocates the IPv4 and IPv6 address of the endpoint with a single call into the IPAM plugin, which
+// either allocates both or none of them.
+func (invoker *AzureIPAMInvoker) addDualStack(addConfig IPAMAddConfig) (IPAMAddResult, error) {
+	addResult := IPAMAddResult{}
+
+	if len(invoker.nwInfo.Subnets) > 1 {
+		// ipv6 is the second subnet of the slice
+		addConfig.nwCfg.IPAM.IPv6Subnet = invoker.nwInfo.Subnets[1].Prefix.String()
+	}
+
+	result, err := invoker.plugin.DelegateAdd(addConfig.nwCfg.IPAM.Type, addConfig.nwCfg)
+	if err != nil && strings.Contains(err.Error(), ipam.ErrNoAvailableAddressPools.Error()) {
+		invoker.deleteIpamState()
+	}
+	if err != nil {
+		return addResult, invoker.plugin.Errorf("Failed to allocate dual-stack addresses: %v", err)
+	}
+
+	addResult.ipv4Result = &cniTypesCurr.Result{DNS: result.DNS}
+	addResult.ipv6Result = &cniTypesCurr.Result{}
+	for _, ipConfig := range result.IPs {
+		if ipConfig.Address.IP.To4() != nil {
+			addResult.ipv4Result.IPs = append(addResult.ipv4Result.IPs, ipConfig)
+		} else {
+			addResult.ipv6Result.IPs = append(addResult.ipv6Result.IPs, ipConfig)
+		}
+	}
+	for _, route := range result.Routes {
+		if route.Dst.IP.To4() != nil {
+			addResult.ipv4Result.Routes = append(addResult.ipv4Result.Routes, route)
+		} else {
+			addResult.ipv6Result.Routes = append(addResult.ipv6Result.Routes, route)
+		}
+	}
+
+	if len(addResult.ipv4Result.IPs) == 0 || len(addResult.ipv6Result.IPs) == 0 {
+		if er := invoker.Delete(nil, addConfig.nwCfg, nil, addConfig.options); er != nil {
+			log.Printf("[cni] Failed to release dual-stack addresses %+v: %v", result.IPs, er)
+		}
+		return IPAMAddResult{}, invoker.plugin.Errorf("IPAM plugin didn't return an address of each family: %+v", result.IPs)
+	}
+
+	addResult.hostSubnetPrefix = addResult.ipv4Result.IPs[0].Address
+
+	// Keep the IPv6 subnet to release the address from when the endpoint can't be created.
+	ipv6Subnet := addResult.ipv6Result.IPs[0].Address
+	ipv6Subnet.IP = ipv6Subnet.IP.Mask(ipv6Subnet.Mask)
+	addConfig.nwCfg.IPAM.IPv6Subnet = ipv6Subnet.String()
+
+	return addResult, nil
+}
+
+// isDualStackIPAM returns whether the IPv4 and IPv6 address of endpoints are allocated by the IPAM plugin together.
+func isDualStackIPAM(nwCfg *cni.NetworkConfig) bool {
+	return nwCfg.IPV6Mode != "" && nwCfg.IPAM.Mode == string(util.DualStack)
+}
+
 func (invoker *AzureIPAMInvoker) deleteIpamState() {
 	cniStateExists, err := platform.CheckIfFileExists(platform.CNIStateFilePath)
 	if err != nil {
@@ -122,6 +188,19 @@ func (invoker *AzureIPAMInvoker) deleteIpamState() {
 	}
 }
 
+// Quarantine releases the address and quarantines it in the IPAM plugin for addressQuarantinePeriod.
+func (invoker *AzureIPAMInvoker) Quarantine(address *net.IPNet, nwCfg *cni.NetworkConfig, options map[string]interface{}) error {
+	// the requested address is restored so that the next allocation doesn't request the quarantined address.
+	requested := nwCfg.IPAM.Address
+	nwCfg.IPAM.Quarantine = addressQuarantinePeriod.String()
+	defer func() {
+		nwCfg.IPAM.Address = requested
+		nwCfg.IPAM.Quarantine = ""
+	}()
+
+	return invoker.Delete(address, nwCfg, nil, options)
+}
+
 func (invoker *AzureIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkConfig, _ *cniSkel.CmdArgs, options map[string]interface{}) error {
 	if nwCfg == nil {
 		return invoker.plugin.Errorf("nil nwCfg passed to CNI ADD, stack: %+v", string(debug.Stack()))
@@ -131,6 +210,10 @@ func (invoker *AzureIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkCo
 		nwCfg.IPAM.Subnet = invoker.nwInfo.Subnets[0].Prefix.String()
 	}
 
+	if isDualStackIPAM(nwCfg) && len(invoker.nwInfo.Subnets) > 1 {
+		nwCfg.IPAM.IPv6Subnet = invoker.nwInfo.Subnets[1].Prefix.String()
+	}
+
 	if address == nil {
 		if err := invoker.plugin.DelegateDel(nwCfg.IPAM.Type, nwCfg); err != nil {
 			return invoker.plugin.Errorf("Attempted to release address with error:  %v", err)
@@ -145,11 +228,15 @@ func (invoker *AzureIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkCo
 		}
 	} else if len(address.IP.To16()) == 16 {
 		nwCfgIpv6 := *nwCfg
-		nwCfgIpv6.IPAM.Environment = common.OptEnvironmentIPv6NodeIpam
-		nwCfgIpv6.IPAM.Type = ipamV6
+		if !isDualStackIPAM(nwCfg) {
+			nwCfgIpv6.IPAM.Environment = common.OptEnvironmentIPv6NodeIpam
+			nwCfgIpv6.IPAM.Type = ipamV6
+		}
 		nwCfgIpv6.IPAM.Address = address.IP.String()
 		if len(invoker.nwInfo.Subnets) > 1 {
 			nwCfgIpv6.IPAM.Subnet = invoker.nwInfo.Subnets[1].Prefix.String()
+		} else if isDualStackIPAM(nwCfg) && nwCfg.IPAM.IPv6Subnet != "" {
+			nwCfgIpv6.IPAM.Subnet = nwCfg.IPAM.IPv6Subnet
 		}
 
 		log.Printf("Releasing ipv6 address :%s pool: %s",
@@ -422,3 +422,80 @@ func TestRemoveIpamState_Add(t *testing.T) {
 		})
 	}
 }
+
+// dualStackDelegatePlugin returns the result of a dual-stack IPAM plugin and records the delegated calls.
+type dualStackDelegatePlugin struct {
+	mockDelegatePlugin
+	result  *cniTypesCurr.Result
+	addCfgs []cni.NetworkConfig
+	delCfgs []cni.NetworkConfig
+}
+
+func (d *dualStackDelegatePlugin) DelegateAdd(_ string, nwCfg *cni.NetworkConfig) (*cniTypesCurr.Result, error) {
+	d.addCfgs = append(d.addCfgs, *nwCfg)
+	return d.result, nil
+}
+
+func (d *dualStackDelegatePlugin) DelegateDel(_ string, nwCfg *cni.NetworkConfig) error {
+	d.delCfgs = append(d.delCfgs, *nwCfg)
+	return nil
+}
+
+func TestAzureIPAMInvoker_AddDualStack(t *testing.T) {
+	result := &cniTypesCurr.Result{
+		IPs: []*cniTypesCurr.IPConfig{
+			{Address: *getCIDRNotationForAddress("10.0.0.4/24")},
+			{Address: *getCIDRNotationForAddress("2001:db8:abcd:12::4/64")},
+		},
+		Routes: []*cniTypes.Route{
+			{Dst: *getCIDRNotationForAddress("0.0.0.0/0")},
+			{Dst: *getCIDRNotationForAddress("::/0")},
+		},
+	}
+	plugin := &dualStackDelegatePlugin{result: result}
+	invoker := &AzureIPAMInvoker{plugin: plugin, nwInfo: getNwInfo("10.0.0.0/24", "2001:db8:abcd:12::/64")}
+
+	nwCfg := &cni.NetworkConfig{IPV6Mode: network.IPV6Nat, IPAM: cni.IPAM{Type: "azure-vnet-ipam", Mode: "dualstack"}}
+	addResult, err := invoker.Add(IPAMAddConfig{nwCfg: nwCfg})
+	require.NoError(t, err)
+
+	require.Len(t, plugin.addCfgs, 1, "both addresses are allocated by a single call")
+	require.Equal(t, "10.0.0.0/24", plugin.addCfgs[0].IPAM.Subnet)
+	require.Equal(t, "2001:db8:abcd:12::/64", plugin.addCfgs[0].IPAM.IPv6Subnet)
+	require.Equal(t, []*cniTypesCurr.IPConfig{result.IPs[0]}, addResult.ipv4Result.IPs)
+	require.Equal(t, []*cniTypes.Route{result.Routes[0]}, addResult.ipv4Result.Routes)
+	require.Equal(t, []*cniTypesCurr.IPConfig{result.IPs[1]}, addResult.ipv6Result.IPs)
+	require.Equal(t, []*cniTypes.Route{result.Routes[1]}, addResult.ipv6Result.Routes)
+
+	// The IPv6 address is released by the dual-stack IPAM plugin.
+	require.NoError(t, invoker.Delete(&result.IPs[1].Address, nwCfg, nil, nil))
+	require.Equal(t, "azure-vnet-ipam", plugin.delCfgs[0].IPAM.Type)
+	require.Equal(t, "2001:db8:abcd:12::/64", plugin.delCfgs[0].IPAM.Subnet)
+}
+
+func TestAzureIPAMInvoker_AddDualStackMissingFamily(t *testing.T) {
+	plugin := &dualStackDelegatePlugin{result: getResult("10.0.0.4/24")[0]}
+	invoker := &AzureIPAMInvoker{plugin: plugin, nwInfo: getNwInfo("10.0.0.0/24", "")}
+
+	nwCfg := &cni.NetworkConfig{IPV6Mode: network.IPV6Nat, IPAM: cni.IPAM{Type: "azure-vnet-ipam", Mode: "dualstack"}}
+	addResult, err := invoker.Add(IPAMAddConfig{nwCfg: nwCfg})
+	require.NotNil(t, err) // use NotNil since *cniTypes.Error is not of type Error
+	require.Nil(t, addResult.ipv4Result)
+	require.Len(t, plugin.delCfgs, 1, "the allocated address is released")
+	require.Empty(t, plugin.delCfgs[0].IPAM.Address)
+}
+
+func TestAzureIPAMInvoker_Quarantine(t *testing.T) {
+	plugin := &dualStackDelegatePlugin{}
+	invoker := &AzureIPAMInvoker{plugin: plugin, nwInfo: getNwInfo("10.0.0.0/24", "")}
+
+	nwCfg := &cni.NetworkConfig{IPAM: cni.IPAM{Type: "azure-vnet-ipam"}}
+	require.NoError(t, invoker.Quarantine(getCIDRNotationForAddress("10.0.0.4/24"), nwCfg, nil))
+
+	require.Len(t, plugin.delCfgs, 1)
+	require.Equal(t, "10.0.0.4", plugin.delCfgs[0].IPAM.Address)
+	require.Equal(t, addressQuarantinePeriod.String(), plugin.delCfgs[0].IPAM.Quarantine)
+	// the next allocation doesn't request the quarantined address.
+	require.Empty(t, nwCfg.IPAM.Address)
+	require.Empty(t, nwCfg.IPAM.Quarantine)
+}
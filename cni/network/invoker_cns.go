@@ -5,14 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"syscall"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cni/util"
 	"github.com/Azure/azure-container-networking/cns"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
 	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network"
 	"github.com/Azure/azure-container-networking/network/networkutils"
+	"github.com/avast/retry-go/v3"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
@@ -20,9 +24,17 @@ import (
 )
 
 var (
-	errEmptyCNIArgs  = errors.New("empty CNI cmd args not allowed")
-	errInvalidArgs   = errors.New("invalid arg(s)")
-	overlayGatewayIP = "169.254.1.1"
+	errEmptyCNIArgs         = errors.New("empty CNI cmd args not allowed")
+	errInvalidArgs          = errors.New("invalid arg(s)")
+	errNCProgrammingPending = errors.New("the NIC is not programmed with the network container yet")
+	errHostPortConflict     = errors.New("host ports are used by another pod")
+	overlayGatewayIP        = "169.254.1.1"
+)
+
+const (
+	// CNS drains and restarts within cnsDrainRetryAttempts * cnsDrainRetryDelay during a rolling upgrade.
+	cnsDrainRetryAttempts = 30
+	cnsDrainRetryDelay    = 500 * time.Millisecond
 )
 
 type CNSIPAMInvoker struct {
@@ -31,6 +43,10 @@ type CNSIPAMInvoker struct {
 	cnsClient     cnsclient
 	executionMode util.ExecutionMode
 	ipamMode      util.IpamMode
+	retryDelay    time.Duration
+	// waitForNCProgramming fails the ADD with errNCProgrammingPending while the NIC isn't programmed with the NC
+	// of the pod IP, whose traffic would be dropped until it is.
+	waitForNCProgramming bool
 }
 
 type IPv4ResultInfo struct {
@@ -50,6 +66,7 @@ func NewCNSInvoker(podName, namespace string, cnsClient cnsclient, executionMode
 		cnsClient:     cnsClient,
 		executionMode: executionMode,
 		ipamMode:      ipamMode,
+		retryDelay:    cnsDrainRetryDelay,
 	}
 }
 
@@ -75,15 +92,36 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 		OrchestratorContext: orchestratorContext,
 		PodInterfaceID:      GetEndpointID(addConfig.args),
 		InfraContainerID:    addConfig.args.ContainerID,
+		Ifname:              addConfig.args.IfName,
+	}
+
+	if err = invoker.reserveHostPorts(addConfig.nwCfg, &ipconfig); err != nil {
+		return IPAMAddResult{}, err
 	}
 
 	log.Printf("Requesting IP for pod %+v using ipconfig %+v", podInfo, ipconfig)
-	response, err := invoker.cnsClient.RequestIPAddress(context.TODO(), ipconfig)
+	var response *cns.IPConfigResponse
+	err = invoker.retryWhileDraining(func() error {
+		var requestErr error
+		response, requestErr = invoker.cnsClient.RequestIPAddress(context.TODO(), ipconfig)
+		return requestErr //nolint:wrapcheck // wrapped after the retries
+	})
 	if err != nil {
 		log.Printf("Failed to get IP address from CNS with error %v, response: %v", err, response)
 		return IPAMAddResult{}, errors.Wrap(err, "Failed to get IP address from CNS with error: %w")
 	}
 
+	if invoker.waitForNCProgramming {
+		if err = invoker.checkNCProgrammed(response.PodIpInfo.NetworkContainerID); err != nil {
+			// the IP is released rather than held by a pod which may not be created again.
+			if releaseErr := invoker.cnsClient.ReleaseIPAddress(context.TODO(), ipconfig); releaseErr != nil {
+				log.Printf("[cni-invoker-cns] Failed to release IP %s of pod %v: %v",
+					response.PodIpInfo.PodIPConfig.IPAddress, podInfo, releaseErr)
+			}
+			return IPAMAddResult{}, err
+		}
+	}
+
 	info := IPv4ResultInfo{
 		podIPAddress:       response.PodIpInfo.PodIPConfig.IPAddress,
 		ncSubnetPrefix:     response.PodIpInfo.NetworkContainerPrimaryIPConfig.IPSubnet.PrefixLength,
@@ -155,6 +193,64 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 	return addResult, nil
 }
 
+// reserveHostPorts reserves the host ports of the pod in CNS before its IP is requested, so that a host port used by
+// another pod fails the ADD with errHostPortConflict rather than when the port mappings are programmed. CNS releases
+// them with the IP of the pod. The pod isn't held back when CNS predates the reservations or fails to reserve them.
+func (invoker *CNSIPAMInvoker) reserveHostPorts(nwCfg *cni.NetworkConfig, ipconfig *cns.IPConfigRequest) error {
+	reserver, ok := invoker.cnsClient.(hostPortReserver)
+	if !ok || nwCfg == nil || len(nwCfg.RuntimeConfig.PortMappings) == 0 {
+		return nil
+	}
+
+	req := cns.ReserveHostPortsRequest{
+		PodInterfaceID:   ipconfig.PodInterfaceID,
+		InfraContainerID: ipconfig.InfraContainerID,
+	}
+	for _, mapping := range nwCfg.RuntimeConfig.PortMappings {
+		req.HostPorts = append(req.HostPorts, cns.HostPort{
+			HostIP:   mapping.HostIp,
+			Port:     mapping.HostPort,
+			Protocol: mapping.Protocol,
+		})
+	}
+
+	conflicts, err := reserver.ReserveHostPorts(context.TODO(), req)
+	switch {
+	case cnscli.IsHostPortConflict(err):
+		if len(conflicts) == 0 {
+			return errors.Wrap(errHostPortConflict, err.Error())
+		}
+		return errors.Wrapf(errHostPortConflict, "%s/%d is used by pod interface %s, infra container %s",
+			conflicts[0].Protocol, conflicts[0].Port, conflicts[0].PodInterfaceID, conflicts[0].InfraContainerID)
+	case err != nil:
+		log.Printf("[cni-invoker-cns] Not checking the host ports %+v for conflicts, failed to reserve them: %v", req.HostPorts, err)
+	}
+	return nil
+}
+
+// checkNCProgrammed returns errNCProgrammingPending if CNS reports that the NIC isn't programmed with the current
+// version of the NC. The pod isn't held back when CNS predates the programming status or can't tell it.
+func (invoker *CNSIPAMInvoker) checkNCProgrammed(ncID string) error {
+	getter, ok := invoker.cnsClient.(ncProgrammingStatusGetter)
+	if !ok || ncID == "" {
+		return nil
+	}
+
+	statuses, err := getter.GetNCProgrammingStatus(context.TODO(), ncID)
+	if err != nil {
+		log.Printf("[cni-invoker-cns] Not waiting for NC %s to be programmed, failed to get its status: %v", ncID, err)
+		return nil
+	}
+
+	for _, status := range statuses {
+		if status.State == cns.NCProgrammingPending {
+			return errors.Wrapf(errNCProgrammingPending, "NC %s version %s, programmed version %s",
+				status.NetworkContainerID, status.Version, status.ProgrammedVersion)
+		}
+	}
+	return nil
+}
+
 func setHostOptions(ncSubnetPrefix *net.IPNet, options map[string]interface{}, info *IPv4ResultInfo) error {
 	// get the host ip
 	hostIP := net.ParseIP(info.hostPrimaryIP)
@@ -217,6 +313,7 @@ func (invoker *CNSIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkConf
 		OrchestratorContext: orchestratorContext,
 		PodInterfaceID:      GetEndpointID(args),
 		InfraContainerID:    args.ContainerID,
+		Ifname:              args.IfName,
 	}
 
 	if address != nil {
@@ -225,9 +322,32 @@ func (invoker *CNSIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkConf
 		log.Printf("CNS invoker called with empty IP address")
 	}
 
-	if err := invoker.cnsClient.ReleaseIPAddress(context.TODO(), req); err != nil {
+	err = invoker.retryWhileDraining(func() error {
+		return invoker.cnsClient.ReleaseIPAddress(context.TODO(), req) //nolint:wrapcheck // wrapped after the retries
+	})
+	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("failed to release IP %v with err ", address)+"%w")
 	}
 
 	return nil
 }
+
+// retryWhileDraining sends the CNS request again as long as CNS is draining or not listening, as it does while a
+// rolling upgrade replaces it, so that the CNI call doesn't fail during the upgrade.
+func (invoker *CNSIPAMInvoker) retryWhileDraining(request func() error) error {
+	return retry.Do(request, //nolint:wrapcheck // the last error of the request is returned
+		retry.Attempts(cnsDrainRetryAttempts),
+		retry.Delay(invoker.retryDelay),
+		retry.DelayType(retry.FixedDelay),
+		retry.LastErrorOnly(true),
+		retry.RetryIf(isCNSUnavailableError),
+		retry.OnRetry(func(n uint, err error) {
+			log.Printf("[cni-invoker-cns] Retrying CNS request after attempt %d failed: %v", n+1, err)
+		}))
+}
+
+// isCNSUnavailableError returns whether CNS failed the request because it is being replaced. While the circuit
+// breaker of the client is open CNS isn't called, the request is sent again once it closes.
+func isCNSUnavailableError(err error) bool {
+	return cnscli.IsDraining(err) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, errCNSCircuitOpen)
+}
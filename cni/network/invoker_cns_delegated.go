@@ -0,0 +1,177 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cns"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/avast/retry-go/v3"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/pkg/errors"
+)
+
+const (
+	cnsRequestRetryAttempts = 5
+	cnsRequestRetryDelay    = 200 * time.Millisecond
+)
+
+// CNSDelegatedIPAMInvoker requests the addresses of pods on delegated pod subnets from CNS. Every interface of a
+// pod gets an address of the Azure subnet delegated to the pods, which the VNET routes to the node, so unlike the
+// addresses of the swift network container no SNAT or host route is set up for them.
+type CNSDelegatedIPAMInvoker struct {
+	podName      string
+	podNamespace string
+	cnsClient    cnsclient
+	retryDelay   time.Duration
+}
+
+func NewCNSDelegatedInvoker(podName, namespace string, cnsClient cnsclient) *CNSDelegatedIPAMInvoker {
+	return &CNSDelegatedIPAMInvoker{
+		podName:      podName,
+		podNamespace: namespace,
+		cnsClient:    cnsClient,
+		retryDelay:   cnsRequestRetryDelay,
+	}
+}
+
+// Add requests the address of the pod interface from CNS, retrying while CNS can't allocate it yet.
+func (invoker *CNSDelegatedIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, error) {
+	if addConfig.args == nil {
+		return IPAMAddResult{}, errEmptyCNIArgs
+	}
+
+	ipconfig, err := invoker.ipConfigRequest(addConfig.args)
+	if err != nil {
+		return IPAMAddResult{}, err
+	}
+
+	var response *cns.IPConfigResponse
+	err = invoker.retry(func() error {
+		var requestErr error
+		response, requestErr = invoker.cnsClient.RequestIPAddress(context.TODO(), ipconfig)
+		return requestErr //nolint:wrapcheck // wrapped after the retries
+	})
+	if err != nil {
+		return IPAMAddResult{}, errors.Wrap(err, "failed to get IP address of delegated pod subnet from CNS")
+	}
+
+	podIPInfo := response.PodIpInfo
+	log.Printf("[cni-invoker-cns-delegated] Received IP %s for interface %s of pod %s/%s",
+		podIPInfo.PodIPConfig.IPAddress, addConfig.args.IfName, invoker.podNamespace, invoker.podName)
+
+	gw := net.ParseIP(podIPInfo.NetworkContainerPrimaryIPConfig.GatewayIPAddress)
+	if gw == nil {
+		return IPAMAddResult{}, errors.Wrapf(errInvalidArgs, "gateway address %s from response is invalid",
+			podIPInfo.NetworkContainerPrimaryIPConfig.GatewayIPAddress)
+	}
+
+	ip, subnet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", podIPInfo.PodIPConfig.IPAddress,
+		podIPInfo.NetworkContainerPrimaryIPConfig.IPSubnet.PrefixLength))
+	if err != nil {
+		return IPAMAddResult{}, errors.Wrapf(err, "unable to parse IP %s from response", podIPInfo.PodIPConfig.IPAddress)
+	}
+
+	_, hostSubnet, err := net.ParseCIDR(podIPInfo.HostPrimaryIPInfo.Subnet)
+	if err != nil {
+		return IPAMAddResult{}, errors.Wrap(err, "unable to parse hostSubnet")
+	}
+
+	addResult := IPAMAddResult{hostSubnetPrefix: *hostSubnet}
+	addResult.ipv4Result = &cniTypesCurr.Result{
+		IPs: []*cniTypesCurr.IPConfig{
+			{
+				Address: net.IPNet{IP: ip, Mask: subnet.Mask},
+				Gateway: gw,
+			},
+		},
+		Routes: []*cniTypes.Route{
+			{
+				Dst: network.Ipv4DefaultRouteDstPrefix,
+				GW:  gw,
+			},
+		},
+	}
+
+	return addResult, nil
+}
+
+// Delete releases the address of the pod interface in CNS, retrying while CNS is unreachable.
+func (invoker *CNSDelegatedIPAMInvoker) Delete(address *net.IPNet, _ *cni.NetworkConfig, args *cniSkel.CmdArgs, _ map[string]interface{}) error {
+	if args == nil {
+		return errEmptyCNIArgs
+	}
+
+	req, err := invoker.ipConfigRequest(args)
+	if err != nil {
+		return err
+	}
+
+	if address != nil {
+		req.DesiredIPAddress = address.IP.String()
+	}
+
+	err = invoker.retry(func() error {
+		return invoker.cnsClient.ReleaseIPAddress(context.TODO(), req) //nolint:wrapcheck // wrapped after the retries
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to release IP %v of delegated pod subnet", address)
+	}
+
+	return nil
+}
+
+func (invoker *CNSDelegatedIPAMInvoker) ipConfigRequest(args *cniSkel.CmdArgs) (cns.IPConfigRequest, error) {
+	orchestratorContext, err := json.Marshal(cns.KubernetesPodInfo{
+		PodName:      invoker.podName,
+		PodNamespace: invoker.podNamespace,
+	})
+	if err != nil {
+		return cns.IPConfigRequest{}, errors.Wrap(err, "failed to marshal orchestrator context")
+	}
+
+	return cns.IPConfigRequest{
+		OrchestratorContext: orchestratorContext,
+		PodInterfaceID:      GetEndpointID(args),
+		InfraContainerID:    args.ContainerID,
+		Ifname:              args.IfName,
+	}, nil
+}
+
+// retry calls the CNS request with an exponential backoff as long as it fails with a transient error.
+func (invoker *CNSDelegatedIPAMInvoker) retry(request func() error) error {
+	return retry.Do(request, //nolint:wrapcheck // the last error of the request is returned
+		retry.Attempts(cnsRequestRetryAttempts),
+		retry.Delay(invoker.retryDelay),
+		retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true),
+		retry.RetryIf(isRetriableCNSError),
+		retry.OnRetry(func(n uint, err error) {
+			log.Printf("[cni-invoker-cns-delegated] Retrying CNS request after attempt %d failed: %v", n+1, err)
+		}))
+}
+
+// isRetriableCNSError returns whether a CNS request may succeed when sent again. Errors which don't come with a
+// CNS return code, such as CNS being unreachable while it restarts, are retried.
+func isRetriableCNSError(err error) bool {
+	var cnsErr *cnscli.CNSClientError
+	if !errors.As(err, &cnsErr) {
+		return true
+	}
+
+	switch cnsErr.Code {
+	case types.FailedToAllocateIPConfig, types.NetworkContainerVfpProgramPending, types.UnexpectedError:
+		return true
+	default:
+		return false
+	}
+}
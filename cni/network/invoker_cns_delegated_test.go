@@ -0,0 +1,134 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/util"
+	"github.com/Azure/azure-container-networking/cns"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/stretchr/testify/require"
+)
+
+var errCNSConnectionRefused = errors.New("connection refused")
+
+// flakyCNSClient fails the first requests with the given errors before succeeding.
+type flakyCNSClient struct {
+	errs      []error
+	response  *cns.IPConfigResponse
+	requests  []cns.IPConfigRequest
+	releases  []cns.IPConfigRequest
+	callCount int
+}
+
+func (c *flakyCNSClient) nextErr() error {
+	c.callCount++
+	if c.callCount <= len(c.errs) {
+		return c.errs[c.callCount-1]
+	}
+	return nil
+}
+
+func (c *flakyCNSClient) RequestIPAddress(_ context.Context, ipconfig cns.IPConfigRequest) (*cns.IPConfigResponse, error) {
+	c.requests = append(c.requests, ipconfig)
+	if err := c.nextErr(); err != nil {
+		return nil, err
+	}
+	return c.response, nil
+}
+
+func (c *flakyCNSClient) ReleaseIPAddress(_ context.Context, ipconfig cns.IPConfigRequest) error {
+	c.releases = append(c.releases, ipconfig)
+	return c.nextErr()
+}
+
+func (c *flakyCNSClient) GetNetworkConfiguration(context.Context, []byte) (*cns.GetNetworkContainerResponse, error) {
+	return nil, nil
+}
+
+func delegatedIPConfigResponse() *cns.IPConfigResponse {
+	return &cns.IPConfigResponse{
+		PodIpInfo: cns.PodIpInfo{
+			PodIPConfig: cns.IPSubnet{IPAddress: "10.1.0.10", PrefixLength: 24},
+			NetworkContainerPrimaryIPConfig: cns.IPConfiguration{
+				IPSubnet:         cns.IPSubnet{IPAddress: "10.1.0.0", PrefixLength: 24},
+				GatewayIPAddress: "10.1.0.1",
+			},
+			HostPrimaryIPInfo: cns.HostIPInfo{Gateway: "10.0.0.1", PrimaryIP: "10.0.0.4", Subnet: "10.0.0.0/24"},
+		},
+	}
+}
+
+func TestCNSDelegatedIPAMInvokerAdd(t *testing.T) {
+	client := &flakyCNSClient{
+		errs:     []error{errCNSConnectionRefused, &cnscli.CNSClientError{Code: types.FailedToAllocateIPConfig}},
+		response: delegatedIPConfigResponse(),
+	}
+	invoker := NewCNSDelegatedInvoker(testPodInfo.PodName, testPodInfo.PodNamespace, client)
+	invoker.retryDelay = 0
+
+	args := &cniSkel.CmdArgs{ContainerID: "testcontainerid", IfName: "eth1"}
+	options := map[string]interface{}{}
+	result, err := invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, args: args, options: options})
+	require.NoError(t, err)
+	require.Len(t, client.requests, 3, "transient errors are retried")
+	require.Equal(t, "eth1", client.requests[0].Ifname)
+
+	require.Equal(t, "10.1.0.10/24", result.ipv4Result.IPs[0].Address.String())
+	require.Equal(t, "10.1.0.1", result.ipv4Result.IPs[0].Gateway.String())
+	require.Equal(t, "10.1.0.1", result.ipv4Result.Routes[0].GW.String())
+	require.Equal(t, "10.0.0.0/24", result.hostSubnetPrefix.String())
+	require.Empty(t, options, "no SNAT or host routes are set up for delegated subnets")
+}
+
+func TestCNSDelegatedIPAMInvokerAddFails(t *testing.T) {
+	client := &flakyCNSClient{
+		errs:     []error{&cnscli.CNSClientError{Code: types.UnsupportedOrchestratorContext}},
+		response: delegatedIPConfigResponse(),
+	}
+	invoker := NewCNSDelegatedInvoker(testPodInfo.PodName, testPodInfo.PodNamespace, client)
+	invoker.retryDelay = 0
+
+	args := &cniSkel.CmdArgs{ContainerID: "testcontainerid", IfName: "eth0"}
+	_, err := invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, args: args, options: map[string]interface{}{}})
+	require.Error(t, err)
+	require.Len(t, client.requests, 1, "errors CNS returns for the request are not retried")
+
+	client = &flakyCNSClient{errs: make([]error, cnsRequestRetryAttempts)}
+	for i := range client.errs {
+		client.errs[i] = errCNSConnectionRefused
+	}
+	invoker.cnsClient = client
+	_, err = invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, args: args, options: map[string]interface{}{}})
+	require.ErrorIs(t, err, errCNSConnectionRefused)
+	require.Len(t, client.requests, cnsRequestRetryAttempts)
+
+	_, err = invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, options: map[string]interface{}{}})
+	require.ErrorIs(t, err, errEmptyCNIArgs)
+}
+
+func TestCNSDelegatedIPAMInvokerDelete(t *testing.T) {
+	client := &flakyCNSClient{errs: []error{errCNSConnectionRefused}}
+	invoker := NewCNSDelegatedInvoker(testPodInfo.PodName, testPodInfo.PodNamespace, client)
+	invoker.retryDelay = 0
+
+	args := &cniSkel.CmdArgs{ContainerID: "testcontainerid", IfName: "eth1"}
+	address := getCIDRNotationForAddress("10.1.0.10/24")
+	require.NoError(t, invoker.Delete(address, &cni.NetworkConfig{}, args, nil))
+	require.Len(t, client.releases, 2)
+	require.Equal(t, "10.1.0.10", client.releases[1].DesiredIPAddress)
+	require.Equal(t, "eth1", client.releases[1].Ifname)
+	require.Equal(t, GetEndpointID(args), client.releases[1].PodInterfaceID)
+}
+
+func TestNewCNSIPAMInvoker(t *testing.T) {
+	nwCfg := &cni.NetworkConfig{}
+	require.IsType(t, &CNSIPAMInvoker{}, newCNSIPAMInvoker("pod", "default", &flakyCNSClient{}, nwCfg))
+
+	nwCfg.IPAM.Mode = string(util.DelegatedPodSubnet)
+	require.IsType(t, &CNSDelegatedIPAMInvoker{}, newCNSIPAMInvoker("pod", "default", &flakyCNSClient{}, nwCfg))
+}
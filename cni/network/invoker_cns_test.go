@@ -1,12 +1,17 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"net"
+	"syscall"
 	"testing"
 
 	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/util"
 	"github.com/Azure/azure-container-networking/cns"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/network"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
@@ -22,6 +27,7 @@ func getTestIPConfigRequest() cns.IPConfigRequest {
 		PodInterfaceID:      "testcont-testifname",
 		InfraContainerID:    "testcontainerid",
 		OrchestratorContext: marshallPodInfo(testPodInfo),
+		Ifname:              "testifname",
 	}
 }
 
@@ -319,3 +325,106 @@ func Test_setHostOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestCNSIPAMInvoker_AddWhileDraining(t *testing.T) {
+	draining := &cnscli.CNSClientError{Code: types.ServiceDraining}
+	client := &flakyCNSClient{
+		errs:     []error{draining, syscall.ECONNREFUSED},
+		response: delegatedIPConfigResponse(),
+	}
+	invoker := NewCNSInvoker(testPodInfo.PodName, testPodInfo.PodNamespace, client, util.Default, "")
+	invoker.retryDelay = 0
+
+	args := &cniSkel.CmdArgs{ContainerID: "testcontainerid", IfName: "eth0"}
+	_, err := invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, args: args, options: map[string]interface{}{}})
+	require.NoError(t, err)
+	require.Len(t, client.requests, 3, "requests are retried while CNS is replaced")
+
+	client = &flakyCNSClient{errs: []error{&cnscli.CNSClientError{Code: types.FailedToAllocateIPConfig}}}
+	invoker.cnsClient = client
+	_, err = invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, args: args, options: map[string]interface{}{}})
+	require.Error(t, err)
+	require.Len(t, client.requests, 1, "other errors are not retried")
+
+	client = &flakyCNSClient{errs: []error{draining}}
+	invoker.cnsClient = client
+	require.NoError(t, invoker.Delete(nil, &cni.NetworkConfig{}, args, nil))
+	require.Len(t, client.releases, 2, "releases are retried while CNS is replaced")
+}
+
+// ncProgrammingCNSClient is a CNS client which reports the programming status of the NCs.
+type ncProgrammingCNSClient struct {
+	flakyCNSClient
+	statuses []cns.NCProgrammingStatus
+	err      error
+}
+
+func (c *ncProgrammingCNSClient) GetNCProgrammingStatus(context.Context, ...string) ([]cns.NCProgrammingStatus, error) {
+	return c.statuses, c.err
+}
+
+func TestCNSIPAMInvoker_AddWaitsForNCProgramming(t *testing.T) {
+	response := delegatedIPConfigResponse()
+	response.PodIpInfo.NetworkContainerID = "nc1"
+	client := &ncProgrammingCNSClient{
+		flakyCNSClient: flakyCNSClient{response: response},
+		statuses: []cns.NCProgrammingStatus{
+			{NetworkContainerID: "nc1", Version: "2", ProgrammedVersion: "1", State: cns.NCProgrammingPending},
+		},
+	}
+	invoker := NewCNSInvoker(testPodInfo.PodName, testPodInfo.PodNamespace, client, util.Default, "")
+	invoker.waitForNCProgramming = true
+
+	args := &cniSkel.CmdArgs{ContainerID: "testcontainerid", IfName: "eth0"}
+	_, err := invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, args: args, options: map[string]interface{}{}})
+	require.ErrorIs(t, err, errNCProgrammingPending)
+	require.Len(t, client.releases, 1, "the IP is released while the NC is pending")
+
+	client.statuses[0].State = cns.NCProgrammingSucceeded
+	_, err = invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, args: args, options: map[string]interface{}{}})
+	require.NoError(t, err)
+
+	client.err = errors.New("CNS predates the NC programming status") //nolint:goerr113 // error for ut
+	_, err = invoker.Add(IPAMAddConfig{nwCfg: &cni.NetworkConfig{}, args: args, options: map[string]interface{}{}})
+	require.NoError(t, err, "pods are not held back when the status is unknown")
+	require.Len(t, client.releases, 1)
+}
+
+// hostPortCNSClient is a CNS client which reserves the host ports of the pods.
+type hostPortCNSClient struct {
+	flakyCNSClient
+	reservations []cns.ReserveHostPortsRequest
+	conflicts    []cns.HostPortReservation
+}
+
+func (c *hostPortCNSClient) ReserveHostPorts(_ context.Context, request cns.ReserveHostPortsRequest) ([]cns.HostPortReservation, error) {
+	c.reservations = append(c.reservations, request)
+	if len(c.conflicts) > 0 {
+		return c.conflicts, &cnscli.CNSClientError{Code: types.HostPortConflict, Err: errors.New("conflict")} //nolint:goerr113 // error for ut
+	}
+	return nil, nil
+}
+
+func TestCNSIPAMInvoker_AddReservesHostPorts(t *testing.T) {
+	client := &hostPortCNSClient{flakyCNSClient: flakyCNSClient{response: delegatedIPConfigResponse()}}
+	invoker := NewCNSInvoker(testPodInfo.PodName, testPodInfo.PodNamespace, client, util.Default, "")
+
+	args := &cniSkel.CmdArgs{ContainerID: "testcontainerid", IfName: "eth0"}
+	nwCfg := &cni.NetworkConfig{}
+	_, err := invoker.Add(IPAMAddConfig{nwCfg: nwCfg, args: args, options: map[string]interface{}{}})
+	require.NoError(t, err)
+	require.Empty(t, client.reservations, "nothing is reserved without port mappings")
+
+	nwCfg.RuntimeConfig.PortMappings = []cni.PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}
+	_, err = invoker.Add(IPAMAddConfig{nwCfg: nwCfg, args: args, options: map[string]interface{}{}})
+	require.NoError(t, err)
+	require.Len(t, client.reservations, 1)
+	require.Equal(t, []cns.HostPort{{Port: 8080, Protocol: "tcp"}}, client.reservations[0].HostPorts)
+	require.Equal(t, GetEndpointID(args), client.reservations[0].PodInterfaceID)
+
+	client.conflicts = []cns.HostPortReservation{{HostPort: cns.HostPort{Port: 8080, Protocol: "TCP"}, PodInterfaceID: "other-eth0"}}
+	client.requests = nil
+	_, err = invoker.Add(IPAMAddConfig{nwCfg: nwCfg, args: args, options: map[string]interface{}{}})
+	require.ErrorIs(t, err, errHostPortConflict)
+	require.Empty(t, client.requests, "no IP is requested for a conflicting pod")
+}
@@ -0,0 +1,119 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/pkg/errors"
+)
+
+const (
+	// podLockDir is the directory of the pod locks in the lock path.
+	podLockDir = "pods"
+	// networkLockFile is the lock of the networks in the lock path.
+	networkLockFile = "azure-vnet-networks.lock"
+)
+
+// SetLockPath enables the per-pod locks with their lock files in path. Commands for different pods then run in
+// parallel, so the store must be locked only while it is accessed.
+func (plugin *NetPlugin) SetLockPath(path string) {
+	plugin.lockPath = path
+}
+
+// lockPod serializes the commands for a pod and shares the network lock with the commands for other pods.
+// The state is read again, since other commands may have saved it after it was read.
+func (plugin *NetPlugin) lockPod(podNamespace, podName, containerID string) error {
+	if plugin.lockPath == "" {
+		return nil
+	}
+
+	name := podNamespace + "_" + podName
+	if podName == "" {
+		name = containerID
+	}
+
+	podLockPath := filepath.Join(plugin.lockPath, podLockDir, name+store.LockExtension)
+	podLock, err := processlock.NewFileLock(podLockPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to create pod lock")
+	}
+
+	if err = podLock.LockTimeout(store.DefaultLockTimeout); err != nil {
+		return errors.Wrapf(err, "failed to lock pod %s", name)
+	}
+
+	plugin.podLock = podLock
+	plugin.podLockPath = podLockPath
+
+	networkLock, err := processlock.NewRWFileLock(filepath.Join(plugin.lockPath, networkLockFile))
+	if err != nil {
+		plugin.unlockPod(false)
+		return errors.Wrap(err, "failed to create network lock")
+	}
+
+	if err = networkLock.RLockTimeout(store.DefaultLockTimeout); err != nil {
+		plugin.unlockPod(false)
+		return errors.Wrap(err, "failed to lock networks")
+	}
+
+	plugin.networkLock = networkLock
+
+	if err = plugin.nm.Reload(); err != nil {
+		plugin.unlockPod(false)
+		return errors.Wrap(err, "failed to read state")
+	}
+
+	return nil
+}
+
+// lockNetworks takes the network lock exclusively to create a network. The state is read again, since another
+// command may have created the network while the lock was released.
+func (plugin *NetPlugin) lockNetworks() error {
+	if plugin.networkLock == nil {
+		return nil
+	}
+
+	if err := plugin.networkLock.Unlock(); err != nil {
+		return errors.Wrap(err, "failed to unlock networks")
+	}
+
+	if err := plugin.networkLock.LockTimeout(store.DefaultLockTimeout); err != nil {
+		plugin.networkLock = nil
+		return errors.Wrap(err, "failed to lock networks exclusively")
+	}
+
+	if err := plugin.nm.Reload(); err != nil {
+		return errors.Wrap(err, "failed to read state")
+	}
+
+	return nil
+}
+
+// unlockPod releases the locks taken by lockPod. The lock file of a deleted pod is removed while it is still
+// locked, so that commands waiting for it lock a new file.
+func (plugin *NetPlugin) unlockPod(podDeleted bool) {
+	if plugin.networkLock != nil {
+		if err := plugin.networkLock.Unlock(); err != nil {
+			log.Errorf("[cni-net] Failed to unlock networks: %v", err)
+		}
+		plugin.networkLock = nil
+	}
+
+	if plugin.podLock == nil {
+		return
+	}
+
+	if podDeleted {
+		if err := os.Remove(plugin.podLockPath); err != nil {
+			log.Errorf("[cni-net] Failed to remove pod lock %s: %v", plugin.podLockPath, err)
+		}
+	}
+
+	if err := plugin.podLock.Unlock(); err != nil {
+		log.Errorf("[cni-net] Failed to unlock pod: %v", err)
+	}
+	plugin.podLock = nil
+}
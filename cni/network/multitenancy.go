@@ -283,7 +283,7 @@ func getInfraVnetIP(
 
 		log.Printf("call ipam to allocate ip from subnet %v", nwCfg.IPAM.Subnet)
 		ipamAddOpt := IPAMAddConfig{nwCfg: nwCfg, options: make(map[string]interface{})}
-		ipamAddResult, err := plugin.ipamInvoker.Add(ipamAddOpt)
+		ipamAddResult, err := plugin.ipamAdd(ipamAddOpt)
 		if err != nil {
 			err = plugin.Errorf("Failed to allocate address: %v", err)
 			return nil, err
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/Azure/azure-container-networking/aitelemetry"
@@ -23,8 +24,10 @@ import (
 	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/network/networkutils"
 	"github.com/Azure/azure-container-networking/network/policy"
 	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/processlock"
 	nnscontracts "github.com/Azure/azure-container-networking/proto/nodenetworkservice/3.302.0.744"
 	"github.com/Azure/azure-container-networking/store"
 	"github.com/Azure/azure-container-networking/telemetry"
@@ -47,6 +50,7 @@ const (
 	CNI_ADD    = "ADD"
 	CNI_DEL    = "DEL"
 	CNI_UPDATE = "UPDATE"
+	CNI_CHECK  = "CHECK"
 )
 
 const (
@@ -73,6 +77,14 @@ type NetPlugin struct {
 	tb                 *telemetry.TelemetryBuffer
 	nnsClient          NnsClient
 	multitenancyClient MultitenancyClient
+	lockPath           string
+	podLock            processlock.Interface
+	podLockPath        string
+	networkLock        processlock.RWInterface
+	// phases times the phases of the command for its latency breakdown.
+	phases *telemetry.PhaseTimer
+	// operations records the outcome of the operations on the endpoint of the command for the operation metrics.
+	operations *telemetry.OperationRecorder
 }
 
 type PolicyArgs struct {
@@ -112,6 +124,9 @@ func NewPlugin(name string,
 		return nil, err
 	}
 
+	// rules are tagged with the release by every command, not only by ADD
+	iptables.ReleaseVersion = config.Version
+
 	nl := netlink.NewNetlink()
 	// Setup network manager.
 	nm, err := network.NewNetworkManager(nl, platform.NewExecClient(), &netio.NetIO{})
@@ -194,6 +209,9 @@ func (plugin *NetPlugin) GetAllEndpointState(networkid string) (*api.AzureCNISta
 			PodEndpointId: ep.Id,
 			ContainerID:   ep.ContainerID,
 			IPAddresses:   ep.IPAddresses,
+			IfName:        ep.IfName,
+			HostIfName:    ep.HostIfName,
+			HnsEndpointID: ep.HNSEndpointID,
 		}
 
 		st.ContainerInterfaces[id] = info
@@ -202,6 +220,29 @@ func (plugin *NetPlugin) GetAllEndpointState(networkid string) (*api.AzureCNISta
 	return &st, nil
 }
 
+// ExportState returns the network state of the node, as dumped by azure-vnet state dump for support bundles.
+func (plugin *NetPlugin) ExportState() (*network.NodeState, error) {
+	return plugin.nm.ExportState()
+}
+
+// RepairEndpoint adds the addresses and routes of an endpoint which are missing in its namespace, as done by
+// azure-vnet endpoint repair, and returns the changes made, or those it would make in a dry run.
+func (plugin *NetPlugin) RepairEndpoint(networkID, endpointID string, dryRun bool) ([]string, error) {
+	return plugin.nm.RepairEndpoint(networkID, endpointID, dryRun)
+}
+
+// DeleteEndpointByID deletes an endpoint and its interfaces, as done by azure-vnet endpoint delete. With force, the
+// endpoint is removed from the state even if deleting its interfaces fails. Its addresses are not released.
+func (plugin *NetPlugin) DeleteEndpointByID(networkID, endpointID string, force bool) error {
+	err := plugin.nm.DeleteEndpoint(networkID, endpointID)
+	if err == nil || !force {
+		return err
+	}
+
+	log.Printf("[cni-net] Failed to delete endpoint %s, removing it from the state: %v", endpointID, err)
+	return plugin.nm.RemoveEndpointState(networkID, endpointID)
+}
+
 // Stops the plugin.
 func (plugin *NetPlugin) Stop() {
 	plugin.nm.Uninitialize()
@@ -303,7 +344,8 @@ func addNatIPV6SubnetInfo(nwCfg *cni.NetworkConfig,
 	resultV6 *cniTypesCurr.Result,
 	nwInfo *network.NetworkInfo,
 ) {
-	if nwCfg.IPV6Mode == network.IPV6Nat {
+	// The IPv6 subnet of dual-stack IPAM is kept to allocate the IPv6 addresses of later endpoints from.
+	if nwCfg.IPV6Mode == network.IPV6Nat || isDualStackIPAM(nwCfg) {
 		ipv6Subnet := resultV6.IPs[0].Address
 		ipv6Subnet.IP = ipv6Subnet.IP.Mask(ipv6Subnet.Mask)
 		ipv6SubnetInfo := network.SubnetInfo{
@@ -330,6 +372,8 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		enableSnatForDNS bool
 		k8sPodName       string
 		cniMetric        telemetry.AIMetric
+		// secondaryIfs are the interfaces of the endpoint next to the primary interface.
+		secondaryIfs []network.InterfaceInfo
 	)
 
 	startTime := time.Now()
@@ -344,8 +388,21 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	if err = plugin.useStatelessState(nwCfg); err != nil {
+		err = plugin.Errorf("Failed to stop using the state file: %v", err)
+		return err
+	}
+
+	// When chained after other plugins, their result is passed on with the interfaces and IPs of this plugin added.
+	prevResult, err := nwCfg.PrevResult()
+	if err != nil {
+		err = plugin.Errorf("Failed to parse previous result: %v.", err)
+		return err
+	}
+
 	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
 	plugin.setCNIReportDetails(nwCfg, CNI_ADD, "")
+	plugin.startPhaseTimer()
 
 	defer func() {
 		operationTimeMs := time.Since(startTime).Milliseconds()
@@ -357,6 +414,7 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 		SetCustomDimensions(&cniMetric, nwCfg, err)
 		telemetry.SendCNIMetric(&cniMetric, plugin.tb)
+		plugin.sendPhaseMetrics(CNI_ADD)
 
 		// Add Interfaces to result.
 		if ipamAddResult.ipv4Result == nil {
@@ -364,7 +422,8 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 
 		iface := &cniTypesCurr.Interface{
-			Name: args.IfName,
+			Name:    args.IfName,
+			Sandbox: args.Netns,
 		}
 		ipamAddResult.ipv4Result.Interfaces = append(ipamAddResult.ipv4Result.Interfaces, iface)
 
@@ -372,9 +431,17 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 			ipamAddResult.ipv4Result.IPs = append(ipamAddResult.ipv4Result.IPs, ipamAddResult.ipv6Result.IPs...)
 		}
 
+		addSecondaryInterfaces(ipamAddResult.ipv4Result, secondaryIfs, args.Netns)
+
 		addSnatInterface(nwCfg, ipamAddResult.ipv4Result)
+
+		result := ipamAddResult.ipv4Result
+		if prevResult != nil {
+			result = mergePrevResult(prevResult, result)
+		}
+
 		// Convert result to the requested CNI version.
-		res, vererr := ipamAddResult.ipv4Result.GetAsVersion(nwCfg.CNIVersion)
+		res, vererr := cni.ConvertResult(result, nwCfg.CNIVersion)
 		if vererr != nil {
 			log.Printf("GetAsVersion failed with error %v", vererr)
 			plugin.Error(vererr)
@@ -410,6 +477,11 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		return plugin.Errorf(errMsg)
 	}
 
+	if err = plugin.lockPod(k8sNamespace, k8sPodName, k8sContainerID); err != nil {
+		return err
+	}
+	defer plugin.unlockPod(false)
+
 	platformInit(nwCfg)
 	if nwCfg.ExecutionMode == string(util.Baremetal) {
 		var res *nnscontracts.ConfigureContainerNetworkingResponse
@@ -431,6 +503,12 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 	}
 
+	if nwCfg.EnableStatelessCNI {
+		if err = validateStatelessCNIConfig(nwCfg); err != nil {
+			return plugin.Errorf("Invalid stateless CNI configuration: %v", err)
+		}
+	}
+
 	cnsClient, er := cnscli.New(nwCfg.CNSUrl, defaultRequestTimeout)
 	if er != nil {
 		return fmt.Errorf("failed to create cns client with error: %w", er)
@@ -438,7 +516,7 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 
 	if nwCfg.MultiTenancy {
 		plugin.report.Context = "AzureCNIMultitenancy"
-		plugin.multitenancyClient.Init(cnsClient, AzureNetIOShim{})
+		plugin.multitenancyClient.Init(newResilientCNSClient(cnsClient, nwCfg), AzureNetIOShim{})
 
 		// Temporary if block to determining whether we disable SNAT on host (for multi-tenant scenario only)
 		if enableSnatForDNS, nwCfg.EnableSnatOnHost, err = plugin.multitenancyClient.DetermineSnatFeatureOnHost(
@@ -469,6 +547,11 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	endpointID := GetEndpointID(args)
 	policies := cni.GetPoliciesFromNwCfg(nwCfg.AdditionalArgs)
 
+	// NICs may be attached to or detached from the VM at runtime.
+	if refreshErr := plugin.nm.RefreshExternalInterfaces(); refreshErr != nil {
+		log.Printf("[cni-net] Failed to refresh external interfaces: %v", refreshErr)
+	}
+
 	options := make(map[string]interface{})
 	// Check whether the network already exists.
 	nwInfo, nwInfoErr := plugin.nm.GetNetworkInfo(networkID)
@@ -491,6 +574,12 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 
 		if resultSecondAdd != nil {
 			ipamAddResult.ipv4Result = resultSecondAdd
+			if epInfo, epErr := plugin.nm.GetEndpointInfo(networkID, endpointID); epErr == nil {
+				secondaryIfs = epInfo.SecondaryInterfaces
+				if len(epInfo.DNS.Servers) > 0 {
+					ipamAddResult.ipv4Result.DNS = endpointResultDNS(epInfo.DNS)
+				}
+			}
 			return nil
 		}
 	}
@@ -499,7 +588,7 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	if plugin.ipamInvoker == nil {
 		switch nwCfg.IPAM.Type {
 		case network.AzureCNS:
-			plugin.ipamInvoker = NewCNSInvoker(k8sPodName, k8sNamespace, cnsClient, util.ExecutionMode(nwCfg.ExecutionMode), util.IpamMode(nwCfg.IPAM.Mode))
+			plugin.ipamInvoker = newCNSIPAMInvoker(k8sPodName, k8sNamespace, newResilientCNSClient(cnsClient, nwCfg), nwCfg)
 
 		default:
 			plugin.ipamInvoker = NewAzureIpamInvoker(plugin, &nwInfo)
@@ -509,7 +598,16 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	ipamAddConfig := IPAMAddConfig{nwCfg: nwCfg, args: args, options: options}
 	// No need to call Add if we already got IPAMAddResult in multitenancy section via GetContainerNetworkConfiguration
 	if !nwCfg.MultiTenancy {
-		ipamAddResult, err = plugin.ipamInvoker.Add(ipamAddConfig)
+		ipamAddResult, err = plugin.ipamAdd(ipamAddConfig)
+		if errors.Is(err, errNCProgrammingPending) {
+			// the runtime creates the pod again once the NC has been programmed.
+			err = plugin.RetriableError(fmt.Errorf("IPAM Invoker Add failed with error: %w", err))
+			return err
+		}
+		if errors.Is(err, errHostPortConflict) {
+			err = plugin.Error(cniTypes.NewError(cni.ErrHostPortConflict, "IPAM Invoker Add failed", err.Error()))
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("IPAM Invoker Add failed with error: %w", err)
 		}
@@ -523,13 +621,29 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 	}()
 
+	if nwInfoErr != nil {
+		// Networks are created under the exclusive lock, another pod may have created it in the meantime.
+		if err = plugin.lockNetworks(); err != nil {
+			return err
+		}
+
+		var existingNwInfo network.NetworkInfo
+		if existingNwInfo, nwInfoErr = plugin.nm.GetNetworkInfo(networkID); nwInfoErr == nil {
+			nwInfo = existingNwInfo
+			nwInfo.IPAMType = nwCfg.IPAM.Type
+			for key, value := range nwInfo.Options {
+				options[key] = value
+			}
+		}
+	}
+
 	// Create network
 	if nwInfoErr != nil {
 		// Network does not exist.
 		logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Creating network %v.", networkID))
 		// opts map needs to get passed in here
 		if nwInfo, err = plugin.createNetworkInternal(networkID, policies, ipamAddConfig, ipamAddResult); err != nil {
-			log.Errorf("Create network failed: %w", err)
+			log.Errorf("Create network failed: %v", err)
 			return err
 		}
 
@@ -555,10 +669,49 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		natInfo:          natInfo,
 	}
 	epInfo, err := plugin.createEndpointInternal(&createEndpointInternalOpt)
+	if errors.Is(err, networkutils.ErrAddressConflict) && !nwCfg.MultiTenancy {
+		// Another host uses the address, e.g. after the IPAM state was lost. The endpoint is created once more
+		// with another address while the conflicting one is quarantined.
+		if ipamAddResult, err = plugin.reallocateConflictingAddress(err, ipamAddConfig, ipamAddResult); err == nil {
+			createEndpointInternalOpt.result = ipamAddResult.ipv4Result
+			createEndpointInternalOpt.resultV6 = ipamAddResult.ipv6Result
+			createEndpointInternalOpt.policies = policies
+			epInfo, err = plugin.createEndpointInternal(&createEndpointInternalOpt)
+		}
+	}
+	if errors.Is(err, networkutils.ErrAddressConflict) {
+		err = plugin.Errorf("Failed to create endpoint: %v", err)
+	}
 	if err != nil {
-		log.Errorf("Endpoint creation failed:%w", err)
+		log.Errorf("Endpoint creation failed:%v", err)
 		return err
 	}
+	secondaryIfs = epInfo.SecondaryInterfaces
+	plugin.syncOutboundNATExceptions(networkID, epInfo.OutboundNATExceptions)
+
+	// Runtimes which write the resolv.conf of the pod from the result, rather than from the pod spec, get the DNS
+	// settings of the endpoint instead of the ones returned by IPAM.
+	if len(epInfo.DNS.Servers) > 0 {
+		ipamAddResult.ipv4Result.DNS = endpointResultDNS(epInfo.DNS)
+	}
+
+	if nwCfg.EnableStatelessCNI {
+		if err = plugin.saveEndpointState(context.TODO(), cnsClient, args, networkID, endpointID); err != nil {
+			// DEL could not find the endpoint without its state in CNS, so it is removed right away.
+			if delErr := plugin.nm.DeleteEndpoint(networkID, endpointID); delErr != nil {
+				log.Errorf("Failed to delete endpoint %s: %v", endpointID, delErr)
+			}
+			return err
+		}
+	}
+
+	if nwCfg.Mode == OpModeWireguard {
+		// The pod is reachable from this node regardless, so a failed sync only delays cross-node connectivity
+		// until the next ADD on the node.
+		if syncErr := plugin.syncWireguardPeers(context.TODO(), cnsClient, networkID); syncErr != nil {
+			logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Failed to sync wireguard peers: %v", syncErr))
+		}
+	}
 
 	sendEvent(plugin, fmt.Sprintf("CNI ADD succeeded : IP:%+v, VlanID: %v, podname %v, namespace %v numendpoints:%d",
 		ipamAddResult.ipv4Result.IPs, epInfo.Data[network.VlanIDKey], k8sPodName, k8sNamespace, plugin.nm.GetNumberOfEndpoints("", nwCfg.Name)))
@@ -566,6 +719,88 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	return nil
 }
 
+// newCNSIPAMInvoker returns the invoker of the CNS IPAM mode of the network configuration.
+func newCNSIPAMInvoker(podName, namespace string, cnsClient cnsclient, nwCfg *cni.NetworkConfig) IPAMInvoker {
+	if util.IpamMode(nwCfg.IPAM.Mode) == util.DelegatedPodSubnet {
+		return NewCNSDelegatedInvoker(podName, namespace, cnsClient)
+	}
+
+	invoker := NewCNSInvoker(podName, namespace, cnsClient, util.ExecutionMode(nwCfg.ExecutionMode), util.IpamMode(nwCfg.IPAM.Mode))
+	invoker.waitForNCProgramming = nwCfg.WaitForNCProgramming
+	return invoker
+}
+
+// ipamAdd allocates the addresses of the endpoint, timed as the IPAM phase of the command.
+func (plugin *NetPlugin) ipamAdd(config IPAMAddConfig) (IPAMAddResult, error) {
+	defer plugin.phases.Start(telemetry.PhaseIPAM)()
+	return plugin.ipamInvoker.Add(config)
+}
+
+// ipamDelete releases the addresses of the endpoint, timed as the IPAM phase of the command.
+func (plugin *NetPlugin) ipamDelete(address *net.IPNet, nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs, options map[string]interface{}) error {
+	defer plugin.phases.Start(telemetry.PhaseIPAM)()
+	return plugin.ipamInvoker.Delete(address, nwCfg, args, options)
+}
+
+// reallocateConflictingAddress quarantines the address of the endpoint found in use by another host, releases the
+// other addresses of the endpoint and allocates new ones. The conflict is returned as is if the IPAM can't
+// quarantine addresses.
+func (plugin *NetPlugin) reallocateConflictingAddress(conflictErr error, config IPAMAddConfig, result IPAMAddResult) (IPAMAddResult, error) {
+	var addrConflictErr *networkutils.AddressConflictError
+	quarantiner, ok := plugin.ipamInvoker.(addressQuarantiner)
+	if !ok || !errors.As(conflictErr, &addrConflictErr) {
+		return result, conflictErr
+	}
+
+	logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Quarantining address %v: %v", addrConflictErr.IP, conflictErr))
+	for _, r := range []*cniTypesCurr.Result{result.ipv4Result, result.ipv6Result} {
+		if r == nil || len(r.IPs) == 0 {
+			continue
+		}
+
+		address := r.IPs[0].Address
+		var err error
+		if address.IP.Equal(addrConflictErr.IP) {
+			err = quarantiner.Quarantine(&address, config.nwCfg, config.options)
+		} else {
+			err = plugin.ipamDelete(&address, config.nwCfg, config.args, config.options)
+		}
+		if err != nil {
+			log.Errorf("Failed to release address %v: %v", address.IP, err)
+		}
+	}
+
+	result, err := plugin.ipamAdd(config)
+	if err != nil {
+		return IPAMAddResult{}, fmt.Errorf("IPAM Invoker Add failed with error: %w", err)
+	}
+	sendEvent(plugin, fmt.Sprintf("Allocated IPAddress from ipam:%+v v6:%+v", result.ipv4Result, result.ipv6Result))
+
+	return result, nil
+}
+
+// startPhaseTimer starts the latency breakdown of a command and the recording of its operations.
+func (plugin *NetPlugin) startPhaseTimer() {
+	plugin.phases = telemetry.NewPhaseTimer()
+	plugin.nm.SetPhaseTimer(plugin.phases)
+	plugin.operations = telemetry.NewOperationRecorder()
+	networkutils.SetOperationObserver(plugin.operations.Observe)
+}
+
+// sendPhaseMetrics reports the time spent in each phase of the command, so that a slower command can be traced
+// to the phase which regressed, and the outcome of each operation on the endpoint.
+func (plugin *NetPlugin) sendPhaseMetrics(operation string) {
+	for _, phaseMetric := range plugin.phases.Metrics(operation, plugin.Version) {
+		phaseMetric := phaseMetric
+		telemetry.SendCNIMetric(&phaseMetric, plugin.tb)
+	}
+
+	for _, operationMetric := range plugin.operations.Metrics(operation, plugin.Version) {
+		operationMetric := operationMetric
+		telemetry.SendCNIMetric(&operationMetric, plugin.tb)
+	}
+}
+
 func (plugin *NetPlugin) cleanupAllocationOnError(
 	result, resultV6 *cniTypesCurr.Result,
 	nwCfg *cni.NetworkConfig,
@@ -573,12 +808,12 @@ func (plugin *NetPlugin) cleanupAllocationOnError(
 	options map[string]interface{},
 ) {
 	if result != nil && len(result.IPs) > 0 {
-		if er := plugin.ipamInvoker.Delete(&result.IPs[0].Address, nwCfg, args, options); er != nil {
+		if er := plugin.ipamDelete(&result.IPs[0].Address, nwCfg, args, options); er != nil {
 			log.Errorf("Failed to cleanup ip allocation on failure: %v", er)
 		}
 	}
 	if resultV6 != nil && len(resultV6.IPs) > 0 {
-		if er := plugin.ipamInvoker.Delete(&resultV6.IPs[0].Address, nwCfg, args, options); er != nil {
+		if er := plugin.ipamDelete(&resultV6.IPs[0].Address, nwCfg, args, options); er != nil {
 			log.Errorf("Failed to cleanup ipv6 allocation on failure: %v", er)
 		}
 	}
@@ -676,6 +911,18 @@ type createEndpointInternalOpt struct {
 	natInfo          []policy.NATInfo
 }
 
+// getVethName returns the key the names of the veth pair of the endpoint are generated from.
+func getVethName(nwCfg *cni.NetworkConfig, networkID string, args *cniSkel.CmdArgs, podNamespace, podName string) string {
+	if nwCfg.Mode == OpModeTransparent {
+		return fmt.Sprintf("%s.%s", podNamespace, podName)
+	}
+
+	// this mechanism of using only namespace and name is not unique for different incarnations of POD/container.
+	// IT will result in unpredictable behavior if API server decides to
+	// reorder DELETE and ADD call for new incarnation of same POD.
+	return fmt.Sprintf("%s%s%s", networkID, args.ContainerID, args.IfName)
+}
+
 func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt) (network.EndpointInfo, error) {
 	epInfo := network.EndpointInfo{}
 
@@ -697,14 +944,14 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 
 	opt.policies = append(opt.policies, endpointPolicies...)
 
-	vethName := fmt.Sprintf("%s.%s", opt.k8sNamespace, opt.k8sPodName)
-	if opt.nwCfg.Mode != OpModeTransparent {
-		// this mechanism of using only namespace and name is not unique for different incarnations of POD/container.
-		// IT will result in unpredictable behavior if API server decides to
-		// reorder DELETE and ADD call for new incarnation of same POD.
-		vethName = fmt.Sprintf("%s%s%s", opt.nwInfo.Id, opt.args.ContainerID, opt.args.IfName)
+	outboundNATExceptions, err := getOutboundNATExceptions(opt.nwCfg)
+	if err != nil {
+		err = plugin.Errorf("Failed to get outbound NAT exceptions: %v", err)
+		return epInfo, err
 	}
 
+	vethName := getVethName(opt.nwCfg, opt.nwInfo.Id, opt.args, opt.k8sNamespace, opt.k8sPodName)
+
 	epInfo = network.EndpointInfo{
 		Id:                 opt.endpointID,
 		ContainerID:        opt.args.ContainerID,
@@ -725,7 +972,11 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 		VnetCidrs:          opt.nwCfg.VnetCidrs,
 		ServiceCidrs:       opt.nwCfg.ServiceCidrs,
 		NATInfo:            opt.natInfo,
+		BandwidthLimits:    getBandwidthLimitsFromRuntimeCfg(opt.nwCfg),
+		PortMappings:       getPortMappingsFromRuntimeCfg(opt.nwCfg),
+		DADTimeout:         getDADTimeout(opt.nwCfg),
 	}
+	epInfo.OutboundNATExceptions = outboundNATExceptions
 
 	epPolicies := getPoliciesFromRuntimeCfg(opt.nwCfg)
 	epInfo.Policies = append(epInfo.Policies, epPolicies...)
@@ -746,6 +997,20 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 		epInfo.Routes = append(epInfo.Routes, network.RouteInfo{Dst: route.Dst, Gw: route.GW})
 	}
 
+	podRoutes, err := getPodRoutes(opt.nwCfg, opt.args)
+	if err == nil {
+		err = network.ValidatePodRoutes(podRoutes, epInfo.IPAddresses)
+	}
+	if err != nil {
+		err = plugin.Errorf("Failed to get pod routes: %v", err)
+		return epInfo, err
+	}
+	if len(podRoutes) > 0 {
+		log.Printf("[cni-net] Adding pod routes %+v.", podRoutes)
+		epInfo.Routes = append(epInfo.Routes, podRoutes...)
+		epInfo.Policies = append(epInfo.Policies, getPodRoutePolicies(podRoutes)...)
+	}
+
 	if opt.azIpamResult != nil && opt.azIpamResult.IPs != nil {
 		epInfo.InfraVnetIP = opt.azIpamResult.IPs[0].Address
 	}
@@ -766,47 +1031,37 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 	logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Creating endpoint %s.", epInfo.PrettyString()))
 	err = plugin.nm.CreateEndpoint(cnsclient, opt.nwInfo.Id, &epInfo)
 	if err != nil {
-		err = plugin.Errorf("Failed to create endpoint: %v", err)
+		// transient kernel conditions (e.g. EBUSY) are worth a retry by the container runtime,
+		// everything else (e.g. the netns is already gone) is reported as a permanent failure
+		switch {
+		case errors.Is(err, networkutils.ErrAddressConflict):
+			// kept as is, so that ADD can quarantine the address and allocate another one.
+			err = fmt.Errorf("Failed to create endpoint: %w", err)
+		case networkutils.IsRetriable(err):
+			err = plugin.RetriableError(fmt.Errorf("Failed to create endpoint: %w", err))
+		default:
+			err = plugin.Errorf("Failed to create endpoint: %v", err)
+		}
 	}
 
 	return epInfo, err
 }
 
-// Get handles CNI Get commands.
+// Get handles CNI CHECK commands, which the CNI library dispatches to this handler. It verifies that the interfaces,
+// addresses, routes and neighbor entries of the endpoint still match the state store record and the previous result,
+// and fails if they diverged so that the runtime recreates the sandbox.
 func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 	var (
-		result    cniTypesCurr.Result
 		err       error
 		nwCfg     *cni.NetworkConfig
 		epInfo    *network.EndpointInfo
-		iface     *cniTypesCurr.Interface
 		networkID string
 	)
 
-	log.Printf("[cni-net] Processing GET command with args {ContainerID:%v Netns:%v IfName:%v Args:%v Path:%v}.",
+	log.Printf("[cni-net] Processing CHECK command with args {ContainerID:%v Netns:%v IfName:%v Args:%v Path:%v}.",
 		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path)
 
-	defer func() {
-		// Add Interfaces to result.
-		iface = &cniTypesCurr.Interface{
-			Name: args.IfName,
-		}
-		result.Interfaces = append(result.Interfaces, iface)
-
-		// Convert result to the requested CNI version.
-		res, vererr := result.GetAsVersion(nwCfg.CNIVersion)
-		if vererr != nil {
-			log.Printf("GetAsVersion failed with error %v", vererr)
-			plugin.Error(vererr)
-		}
-
-		if err == nil && res != nil {
-			// Output the result to stdout.
-			res.Print()
-		}
-
-		log.Printf("[cni-net] GET command completed with result:%+v err:%v.", result, err)
-	}()
+	defer func() { log.Printf("[cni-net] CHECK command completed with err:%v.", err) }()
 
 	// Parse network configuration from stdin.
 	if nwCfg, err = cni.ParseNetworkConfig(args.StdinData); err != nil {
@@ -814,10 +1069,23 @@ func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	if nwCfg.DisableCheck {
+		log.Printf("[cni-net] Skipping CHECK since it is disabled for network %s.", nwCfg.Name)
+		return nil
+	}
+
+	if err = plugin.useStatelessState(nwCfg); err != nil {
+		err = plugin.Errorf("Failed to stop using the state file: %v", err)
+		return err
+	}
+
 	log.Printf("[cni-net] Read network configuration %+v.", nwCfg)
 
 	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
 
+	plugin.startPhaseTimer()
+	defer plugin.sendPhaseMetrics(CNI_CHECK)
+
 	// Initialize values from network config.
 	if networkID, err = plugin.getNetworkName(args.Netns, nil, nwCfg); err != nil {
 		// TODO: Ideally we should return from here only.
@@ -826,41 +1094,189 @@ func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 
 	endpointID := GetEndpointID(args)
 
+	if nwCfg.EnableStatelessCNI {
+		if err = plugin.restoreEndpointStateFromCNS(nwCfg, args, networkID); err != nil {
+			err = plugin.Errorf("Failed to restore endpoint state: %v", err)
+			return err
+		}
+	}
+
 	// Query the network.
 	if _, err = plugin.nm.GetNetworkInfo(networkID); err != nil {
-		plugin.Errorf("Failed to query network: %v", err)
+		err = plugin.Errorf("Failed to query network: %v", err)
 		return err
 	}
 
 	// Query the endpoint.
 	if epInfo, err = plugin.nm.GetEndpointInfo(networkID, endpointID); err != nil {
-		plugin.Errorf("Failed to query endpoint: %v", err)
+		err = plugin.Errorf("Failed to query endpoint: %v", err)
+		return err
+	}
+
+	if err = checkPrevResult(nwCfg, epInfo); err != nil {
+		err = plugin.Errorf("Endpoint does not match the previous result: %v", err)
 		return err
 	}
 
-	for _, ipAddresses := range epInfo.IPAddresses {
-		ipConfig := &cniTypesCurr.IPConfig{
-			Interface: &epInfo.IfIndex,
-			Address:   ipAddresses,
+	if err = plugin.nm.CheckEndpoint(networkID, endpointID, args.Netns, args.IfName); err != nil {
+		err = plugin.Errorf("Failed to check endpoint: %v", err)
+		return err
+	}
+
+	plugin.sendEndpointStatsMetric(networkID, endpointID, nwCfg)
+
+	return nil
+}
+
+// addSecondaryInterfaces adds the secondary interfaces of the endpoint to the result with their addresses, gateways
+// and routes. The addresses without an interface are those of the primary interface, the one added last.
+func addSecondaryInterfaces(result *cniTypesCurr.Result, secondaryIfs []network.InterfaceInfo, sandbox string) {
+	if len(secondaryIfs) == 0 {
+		return
+	}
+
+	primaryIndex := len(result.Interfaces) - 1
+	for _, ipConfig := range result.IPs {
+		if ipConfig.Interface == nil && primaryIndex >= 0 {
+			ipConfig.Interface = cniTypesCurr.Int(primaryIndex)
+		}
+	}
+
+	for _, secondaryIf := range secondaryIfs {
+		index := len(result.Interfaces)
+		result.Interfaces = append(result.Interfaces, &cniTypesCurr.Interface{
+			Name:    secondaryIf.Name,
+			Mac:     secondaryIf.MacAddress.String(),
+			Sandbox: sandbox,
+		})
+
+		for _, ipAddr := range secondaryIf.IPAddresses {
+			result.IPs = append(result.IPs, &cniTypesCurr.IPConfig{
+				Interface: cniTypesCurr.Int(index),
+				Address:   ipAddr,
+				Gateway:   gatewayForAddress(secondaryIf.Gateways, ipAddr.IP),
+			})
 		}
 
-		if epInfo.Gateways != nil {
-			ipConfig.Gateway = epInfo.Gateways[0]
+		for _, route := range secondaryIf.Routes {
+			result.Routes = append(result.Routes, &cniTypes.Route{Dst: route.Dst, GW: route.Gw})
 		}
+	}
+}
 
-		result.IPs = append(result.IPs, ipConfig)
+// gatewayForAddress returns the first gateway of the address family of ip.
+func gatewayForAddress(gateways []net.IP, ip net.IP) net.IP {
+	for _, gw := range gateways {
+		if (gw.To4() == nil) == (ip.To4() == nil) {
+			return gw
+		}
 	}
 
-	for _, route := range epInfo.Routes {
-		result.Routes = append(result.Routes, &cniTypes.Route{Dst: route.Dst, GW: route.Gw})
+	return nil
+}
+
+// mergePrevResult appends the interfaces, IPs and routes of the result of this plugin to the result of the previous
+// plugins in the chain. The DNS settings of this plugin replace the previous ones if it has any.
+func mergePrevResult(prevResult, result *cniTypesCurr.Result) *cniTypesCurr.Result {
+	merged := &cniTypesCurr.Result{
+		CNIVersion: result.CNIVersion,
+		Interfaces: append([]*cniTypesCurr.Interface{}, prevResult.Interfaces...),
+		IPs:        append([]*cniTypesCurr.IPConfig{}, prevResult.IPs...),
+		Routes:     append([]*cniTypes.Route{}, prevResult.Routes...),
+		DNS:        prevResult.DNS,
+	}
+
+	// IPs reference interfaces by their index in the result, which moves past the previous interfaces.
+	offset := len(merged.Interfaces)
+	merged.Interfaces = append(merged.Interfaces, result.Interfaces...)
+
+	for _, ipConfig := range result.IPs {
+		ipc := *ipConfig
+		if ipc.Interface != nil {
+			index := *ipc.Interface + offset
+			ipc.Interface = &index
+		}
+		merged.IPs = append(merged.IPs, &ipc)
+	}
+
+	merged.Routes = append(merged.Routes, result.Routes...)
+
+	if len(result.DNS.Nameservers) > 0 || result.DNS.Domain != "" || len(result.DNS.Search) > 0 {
+		merged.DNS = result.DNS
 	}
 
-	result.DNS.Nameservers = epInfo.DNS.Servers
-	result.DNS.Domain = epInfo.DNS.Suffix
+	return merged
+}
+
+// endpointResultDNS returns the DNS settings of the endpoint in the format of a CNI result.
+func endpointResultDNS(dns network.DNSInfo) cniTypes.DNS {
+	return cniTypes.DNS{
+		Nameservers: dns.Servers,
+		Domain:      dns.Domain,
+		Search:      dns.Searches(),
+		Options:     dns.Options,
+	}
+}
+
+// checkPrevResult verifies that the addresses of the previous result passed to CHECK are the addresses of the endpoint.
+func checkPrevResult(nwCfg *cni.NetworkConfig, epInfo *network.EndpointInfo) error {
+	prevResult, err := nwCfg.PrevResult()
+	if err != nil {
+		return fmt.Errorf("failed to parse previous result: %w", err)
+	}
+
+	if prevResult == nil {
+		return nil
+	}
+
+	ipAddrs := append([]net.IPNet{}, epInfo.IPAddresses...)
+	for _, secondaryIf := range epInfo.SecondaryInterfaces {
+		ipAddrs = append(ipAddrs, secondaryIf.IPAddresses...)
+	}
+
+	for _, ipConfig := range prevResult.IPs {
+		found := false
+		for _, ipAddr := range ipAddrs {
+			if ipAddr.IP.Equal(ipConfig.Address.IP) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("address %v is not assigned to endpoint %s", ipConfig.Address.String(), epInfo.Id)
+		}
+	}
 
 	return nil
 }
 
+// sendEndpointStatsMetric reports the packet drop and error counters of the endpoint's host interface
+// so that pods with drops at the host veth can be spotted.
+func (plugin *NetPlugin) sendEndpointStatsMetric(networkID, endpointID string, nwCfg *cni.NetworkConfig) {
+	stats, err := plugin.nm.GetEndpointStats(networkID, endpointID)
+	if err != nil {
+		log.Printf("[cni-net] Failed to get stats of endpoint %s: %v", endpointID, err)
+		return
+	}
+
+	cniMetric := telemetry.AIMetric{
+		Metric: aitelemetry.Metric{
+			Name:             telemetry.CNIEndpointDroppedStr,
+			Value:            float64(stats.Dropped()),
+			AppVersion:       plugin.Version,
+			CustomDimensions: make(map[string]string),
+		},
+	}
+	cniMetric.Metric.CustomDimensions[telemetry.EndpointIDStr] = endpointID
+	cniMetric.Metric.CustomDimensions[telemetry.RxDroppedStr] = strconv.FormatUint(stats.RxDropped, 10)
+	cniMetric.Metric.CustomDimensions[telemetry.TxDroppedStr] = strconv.FormatUint(stats.TxDropped, 10)
+	cniMetric.Metric.CustomDimensions[telemetry.RxErrorsStr] = strconv.FormatUint(stats.RxErrors, 10)
+	cniMetric.Metric.CustomDimensions[telemetry.TxErrorsStr] = strconv.FormatUint(stats.TxErrors, 10)
+	SetCustomDimensions(&cniMetric, nwCfg, nil)
+	telemetry.SendCNIMetric(&cniMetric, plugin.tb)
+}
+
 // Delete handles CNI delete commands.
 func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 	var (
@@ -889,6 +1305,11 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	if err = plugin.useStatelessState(nwCfg); err != nil {
+		err = plugin.Errorf("Failed to stop using the state file: %v", err)
+		return err
+	}
+
 	// Parse Pod arguments.
 	if k8sPodName, k8sNamespace, err = plugin.getPodInfo(args.Args); err != nil {
 		log.Printf("[cni-net] Failed to get POD info due to error: %v", err)
@@ -897,6 +1318,16 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 	plugin.setCNIReportDetails(nwCfg, CNI_DEL, "")
 	plugin.report.ContainerName = k8sPodName + ":" + k8sNamespace
 
+	plugin.startPhaseTimer()
+	defer plugin.sendPhaseMetrics(CNI_DEL)
+
+	if err = plugin.lockPod(k8sNamespace, k8sPodName, args.ContainerID); err != nil {
+		return err
+	}
+	defer func() {
+		plugin.unlockPod(err == nil)
+	}()
+
 	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
 
 	sendMetricFunc := func() {
@@ -934,7 +1365,7 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 				log.Printf("[cni-net] failed to create cns client:%v", cnsErr)
 				return errors.Wrap(cnsErr, "failed to create cns client")
 			}
-			plugin.ipamInvoker = NewCNSInvoker(k8sPodName, k8sNamespace, cnsClient, util.ExecutionMode(nwCfg.ExecutionMode), util.IpamMode(nwCfg.IPAM.Mode))
+			plugin.ipamInvoker = newCNSIPAMInvoker(k8sPodName, k8sNamespace, newResilientCNSClient(cnsClient, nwCfg), nwCfg)
 
 		default:
 			plugin.ipamInvoker = NewAzureIpamInvoker(plugin, &nwInfo)
@@ -952,6 +1383,14 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 		}
 	}
 
+	if nwCfg.EnableStatelessCNI {
+		// Without the state of the endpoint nothing but its addresses would be released, so DEL is retried.
+		if err = plugin.restoreEndpointStateFromCNS(nwCfg, args, networkID); err != nil {
+			err = plugin.RetriableError(err)
+			return err
+		}
+	}
+
 	// Query the network.
 	if nwInfo, err = plugin.nm.GetNetworkInfo(networkID); err != nil {
 		if !nwCfg.MultiTenancy {
@@ -959,6 +1398,12 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 			// Log the error but return success if the network is not found.
 			// if cni hits this, mostly state file would be missing and it can be reboot scenario where
 			// container runtime tries to delete and create pods which existed before reboot.
+			// Whatever remains of the endpoint is cleaned up on a best effort basis.
+			plugin.cleanupStaleEndpoint(args, nwCfg, networkID, k8sPodName, k8sNamespace)
+			logAndSendEvent(plugin, fmt.Sprintf("Release ip by ContainerID (network not found):%v", args.ContainerID))
+			if relErr := plugin.ipamDelete(nil, nwCfg, args, nwInfo.Options); relErr != nil {
+				log.Printf("[cni-net] Skipping release of addresses of container %s: %v", args.ContainerID, relErr)
+			}
 			err = nil
 			return err
 		}
@@ -967,13 +1412,14 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 	endpointID := GetEndpointID(args)
 	// Query the endpoint.
 	if epInfo, err = plugin.nm.GetEndpointInfo(networkID, endpointID); err != nil {
+		plugin.cleanupStaleEndpoint(args, nwCfg, networkID, k8sPodName, k8sNamespace)
 
 		if !nwCfg.MultiTenancy {
 			// attempt to release address associated with this Endpoint id
 			// This is to ensure clean up is done even in failure cases
 			log.Printf("[cni-net] Failed to query endpoint %s: %v", endpointID, err)
 			logAndSendEvent(plugin, fmt.Sprintf("Release ip by ContainerID (endpoint not found):%v", args.ContainerID))
-			if err = plugin.ipamInvoker.Delete(nil, nwCfg, args, nwInfo.Options); err != nil {
+			if err = plugin.ipamDelete(nil, nwCfg, args, nwInfo.Options); err != nil {
 				return plugin.RetriableError(fmt.Errorf("failed to release address(no endpoint): %w", err))
 			}
 		}
@@ -998,7 +1444,7 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 		// Call into IPAM plugin to release the endpoint's addresses.
 		for _, address := range epInfo.IPAddresses {
 			logAndSendEvent(plugin, fmt.Sprintf("Release ip:%s", address.IP.String()))
-			err = plugin.ipamInvoker.Delete(&address, nwCfg, args, nwInfo.Options)
+			err = plugin.ipamDelete(&address, nwCfg, args, nwInfo.Options)
 			if err != nil {
 				return plugin.RetriableError(fmt.Errorf("failed to release address: %w", err))
 			}
@@ -1006,7 +1452,7 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 	} else if epInfo.EnableInfraVnet {
 		nwCfg.IPAM.Subnet = nwInfo.Subnets[0].Prefix.String()
 		nwCfg.IPAM.Address = epInfo.InfraVnetIP.IP.String()
-		err = plugin.ipamInvoker.Delete(nil, nwCfg, args, nwInfo.Options)
+		err = plugin.ipamDelete(nil, nwCfg, args, nwInfo.Options)
 		if err != nil {
 			return plugin.RetriableError(fmt.Errorf("failed to release address: %w", err))
 		}
@@ -1017,6 +1463,23 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 	return err
 }
 
+// cleanupStaleEndpoint removes what remains of an endpoint which is not in the state, such as the interfaces of an
+// ADD which did not complete. Failures are only logged, DEL succeeds when the state of the endpoint is gone.
+func (plugin *NetPlugin) cleanupStaleEndpoint(args *cniSkel.CmdArgs, nwCfg *cni.NetworkConfig, networkID, podName, podNamespace string) {
+	epInfo := &network.EndpointInfo{
+		Id:          GetEndpointID(args),
+		ContainerID: args.ContainerID,
+		NetNsPath:   args.Netns,
+		IfName:      args.IfName,
+		Data:        make(map[string]interface{}),
+	}
+	setEndpointOptions(nil, epInfo, getVethName(nwCfg, networkID, args, podNamespace, podName))
+
+	if err := plugin.nm.CleanupEndpoint(epInfo); err != nil {
+		log.Printf("[cni-net] Failed to clean up endpoint %s: %v", epInfo.Id, err)
+	}
+}
+
 // Update handles CNI update commands.
 // Update is only supported for multitenancy and to update routes.
 func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
@@ -1042,6 +1505,11 @@ func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	if err = plugin.useStatelessState(nwCfg); err != nil {
+		err = plugin.Errorf("Failed to stop using the state file: %v", err)
+		return err
+	}
+
 	log.Printf("[cni-net] Read network configuration %+v.", nwCfg)
 
 	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
@@ -1063,7 +1531,7 @@ func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
 		}
 
 		// Convert result to the requested CNI version.
-		res, vererr := result.GetAsVersion(nwCfg.CNIVersion)
+		res, vererr := cni.ConvertResult(result, nwCfg.CNIVersion)
 		if vererr != nil {
 			log.Printf("GetAsVersion failed with error %v", vererr)
 			plugin.Error(vererr)
@@ -1097,6 +1565,11 @@ func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
 		return plugin.Errorf(errMsg)
 	}
 
+	if err = plugin.lockPod(k8sNamespace, k8sPodName, args.ContainerID); err != nil {
+		return err
+	}
+	defer plugin.unlockPod(false)
+
 	// Initialize values from network config.
 	networkID := nwCfg.Name
 
@@ -3,11 +3,14 @@ package network
 import (
 	"net"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/network/networkutils"
 	"github.com/Azure/azure-container-networking/network/policy"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
@@ -114,20 +117,107 @@ func getNetworkDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result
 	return nwDNS, nil
 }
 
+// getEndpointDNSSettings returns the DNS settings of the pod. The DNS of the runtime config, which the runtime
+// sets from the DNS config of the pod when the plugin announces the dns capability, takes precedence over the
+// dns of the network config, which takes precedence over the DNS returned by IPAM.
 func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result, _ string) (network.DNSInfo, error) {
-	return getNetworkDNSSettings(nwCfg, result)
+	if runtimeDNS := nwCfg.RuntimeConfig.DNS; len(runtimeDNS.Servers) > 0 {
+		return network.DNSInfo{
+			Servers: runtimeDNS.Servers,
+			Suffix:  strings.Join(runtimeDNS.Searches, ","),
+			Options: runtimeDNS.Options,
+		}, nil
+	}
+
+	dns := result.DNS
+	if len(nwCfg.DNS.Nameservers) > 0 {
+		dns = nwCfg.DNS
+	}
+
+	epDNS := network.DNSInfo{
+		Servers: dns.Nameservers,
+		Domain:  dns.Domain,
+		Suffix:  strings.Join(dns.Search, ","),
+		Options: dns.Options,
+	}
+	if epDNS.Suffix == "" {
+		epDNS.Suffix = dns.Domain
+	}
+	if len(nwCfg.DNS.Options) > 0 {
+		epDNS.Options = nwCfg.DNS.Options
+	}
+
+	return epDNS, nil
 }
 
 func getEndpointPolicies(PolicyArgs) ([]policy.Policy, error) {
 	return nil, nil
 }
 
+// getOutboundNATExceptions returns no exceptions, the linux endpoints are SNATed by iptables rules which exclude
+// the vnet CIDRs.
+func getOutboundNATExceptions(*cni.NetworkConfig) ([]string, error) {
+	return nil, nil
+}
+
+// syncOutboundNATExceptions does nothing, see getOutboundNATExceptions.
+func (plugin *NetPlugin) syncOutboundNATExceptions(string, []string) {}
+
 // getPoliciesFromRuntimeCfg returns network policies from network config.
 // getPoliciesFromRuntimeCfg is a dummy function for Linux platform.
 func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
 	return nil
 }
 
+// getBandwidthLimitsFromRuntimeCfg returns the limits kubelet passed from the bandwidth pod annotations.
+func getBandwidthLimitsFromRuntimeCfg(nwCfg *cni.NetworkConfig) network.BandwidthLimits {
+	bw := nwCfg.RuntimeConfig.Bandwidth
+	if bw == nil {
+		return network.BandwidthLimits{}
+	}
+
+	log.Printf("[net] Bandwidth limits: %+v", *bw)
+	return network.BandwidthLimits{
+		IngressRate:  bw.IngressRate,
+		IngressBurst: bw.IngressBurst,
+		EgressRate:   bw.EgressRate,
+		EgressBurst:  bw.EgressBurst,
+	}
+}
+
+// getPortMappingsFromRuntimeCfg returns the host ports kubelet passed for the containers of the pod.
+func getPortMappingsFromRuntimeCfg(nwCfg *cni.NetworkConfig) []network.PortMapping {
+	var mappings []network.PortMapping
+	for _, mapping := range nwCfg.RuntimeConfig.PortMappings {
+		mappings = append(mappings, network.PortMapping{
+			HostPort:      mapping.HostPort,
+			ContainerPort: mapping.ContainerPort,
+			Protocol:      mapping.Protocol,
+			HostIP:        mapping.HostIp,
+		})
+	}
+
+	if len(mappings) > 0 {
+		log.Printf("[net] Port mappings: %+v", mappings)
+	}
+
+	return mappings
+}
+
+// getDADTimeout returns how long the duplicate address detection of the addresses of the endpoint waits, zero if
+// it is disabled.
+func getDADTimeout(nwCfg *cni.NetworkConfig) time.Duration {
+	dad := nwCfg.DuplicateAddressDetection
+	switch {
+	case dad == nil || !dad.Enabled:
+		return 0
+	case dad.TimeoutMs > 0:
+		return time.Duration(dad.TimeoutMs) * time.Millisecond
+	}
+
+	return networkutils.DefaultDADTimeout
+}
+
 func addIPV6EndpointPolicy(nwInfo network.NetworkInfo) (policy.Policy, error) {
 	return policy.Policy{}, nil
 }
@@ -141,3 +231,14 @@ func getNATInfo(_ string, _ interface{}, _, _ bool) (natInfo []policy.NATInfo) {
 }
 
 func platformInit(cniConfig *cni.NetworkConfig) {}
+
+// getPodRoutePolicies returns no policies, the pod routes are programmed in the network namespace of the pod.
+func getPodRoutePolicies(_ []network.RouteInfo) []policy.Policy {
+	return nil
+}
+
+// isStatelessCNIModeSupported returns whether the network of the mode can be created again on every ADD. Only
+// transparent mode does not depend on a bridge set up by the first ADD.
+func isStatelessCNIModeSupported(mode string) bool {
+	return mode == OpModeTransparent
+}
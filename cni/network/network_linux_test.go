@@ -6,8 +6,10 @@ package network
 import (
 	"testing"
 
+	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/network"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -161,3 +163,72 @@ func TestAddSnatForDns(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEndpointDNSSettings(t *testing.T) {
+	result := &current.Result{DNS: cniTypes.DNS{Nameservers: []string{"168.63.129.16"}, Domain: "ipam.internal"}}
+
+	tests := []struct {
+		name     string
+		nwCfg    cni.NetworkConfig
+		expected network.DNSInfo
+	}{
+		{
+			name:     "dns returned by IPAM",
+			expected: network.DNSInfo{Servers: []string{"168.63.129.16"}, Domain: "ipam.internal", Suffix: "ipam.internal"},
+		},
+		{
+			name: "dns of the network config",
+			nwCfg: cni.NetworkConfig{DNS: cniTypes.DNS{
+				Nameservers: []string{"10.0.0.10"},
+				Domain:      "corp.contoso.com",
+				Search:      []string{"corp.contoso.com", "contoso.com"},
+				Options:     []string{"ndots:2"},
+			}},
+			expected: network.DNSInfo{
+				Servers: []string{"10.0.0.10"},
+				Domain:  "corp.contoso.com",
+				Suffix:  "corp.contoso.com,contoso.com",
+				Options: []string{"ndots:2"},
+			},
+		},
+		{
+			name:  "options of the network config with the dns returned by IPAM",
+			nwCfg: cni.NetworkConfig{DNS: cniTypes.DNS{Options: []string{"timeout:1"}}},
+			expected: network.DNSInfo{
+				Servers: []string{"168.63.129.16"},
+				Domain:  "ipam.internal",
+				Suffix:  "ipam.internal",
+				Options: []string{"timeout:1"},
+			},
+		},
+		{
+			name: "dns of the runtime config",
+			nwCfg: cni.NetworkConfig{
+				DNS: cniTypes.DNS{Nameservers: []string{"10.0.0.10"}},
+				RuntimeConfig: cni.RuntimeConfig{DNS: cni.RuntimeDNSConfig{
+					Servers:  []string{"10.96.0.10"},
+					Searches: []string{"default.svc.cluster.local", "svc.cluster.local"},
+					Options:  []string{"ndots:5"},
+				}},
+			},
+			expected: network.DNSInfo{
+				Servers: []string{"10.96.0.10"},
+				Suffix:  "default.svc.cluster.local,svc.cluster.local",
+				Options: []string{"ndots:5"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dns, err := getEndpointDNSSettings(&tt.nwCfg, result, "default")
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, dns)
+
+			resultDNS := endpointResultDNS(dns)
+			require.Equal(t, tt.expected.Servers, resultDNS.Nameservers)
+			require.Equal(t, tt.expected.Searches(), resultDNS.Search)
+		})
+	}
+}
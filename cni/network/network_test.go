@@ -14,6 +14,8 @@ import (
 	"github.com/Azure/azure-container-networking/nns"
 	"github.com/Azure/azure-container-networking/telemetry"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -35,15 +37,7 @@ func TestMain(m *testing.M) {
 		Mode:              "bridge",
 		Master:            eth0IfName,
 		IPsToRouteViaHost: []string{"169.254.20.10"},
-		IPAM: struct {
-			Mode          string `json:"mode,omitempty"`
-			Type          string `json:"type"`
-			Environment   string `json:"environment,omitempty"`
-			AddrSpace     string `json:"addressSpace,omitempty"`
-			Subnet        string `json:"subnet,omitempty"`
-			Address       string `json:"ipAddress,omitempty"`
-			QueryInterval string `json:"queryInterval,omitempty"`
-		}{
+		IPAM: cni.IPAM{
 			Type: "azure-cns",
 		},
 	}
@@ -165,6 +159,23 @@ func TestPluginDelete(t *testing.T) {
 	}
 }
 
+// DEL succeeds when the state of the pod is gone, even if its addresses can't be released
+func TestPluginDeleteMissingState(t *testing.T) {
+	plugin := GetTestResources()
+	plugin.ipamInvoker = NewMockIpamInvoker(false, true, false)
+
+	args := &cniSkel.CmdArgs{
+		StdinData:   nwCfg.Serialize(),
+		ContainerID: "test-container",
+		Netns:       "test-container",
+		Args:        fmt.Sprintf("K8S_POD_NAME=%v;K8S_POD_NAMESPACE=%v", "test-pod", "test-pod-ns"),
+		IfName:      eth0IfName,
+	}
+
+	require.NoError(t, plugin.Delete(args))
+	require.NoError(t, plugin.Delete(args), "DEL is idempotent")
+}
+
 // Test multiple cni add calls
 func TestPluginSecondAddDifferentPod(t *testing.T) {
 	plugin := GetTestResources()
@@ -573,6 +584,164 @@ func TestPluginGet(t *testing.T) {
 	}
 }
 
+func TestPluginGetDisableCheck(t *testing.T) {
+	plugin, _ := cni.NewPlugin("name", "0.3.0")
+	netPlugin := &NetPlugin{
+		Plugin:      plugin,
+		nm:          acnnetwork.NewMockNetworkmanager(),
+		ipamInvoker: NewMockIpamInvoker(false, false, false),
+		report:      &telemetry.CNIReport{},
+		tb:          &telemetry.TelemetryBuffer{},
+	}
+
+	checkCfg := nwCfg
+	checkCfg.DisableCheck = true
+	checkArgs := *args
+	checkArgs.StdinData = checkCfg.Serialize()
+
+	// the network doesn't exist, which only fails the check when it isn't disabled
+	require.NoError(t, netPlugin.Get(&checkArgs))
+	require.Error(t, netPlugin.Get(args))
+}
+
+func TestMergePrevResult(t *testing.T) {
+	prevIndex, index := 0, 0
+	_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+
+	prevResult := &cniTypesCurr.Result{
+		Interfaces: []*cniTypesCurr.Interface{{Name: "macvlan0", Sandbox: "/var/run/netns/ns1"}},
+		IPs: []*cniTypesCurr.IPConfig{
+			{Interface: &prevIndex, Address: net.IPNet{IP: net.ParseIP("192.168.0.4"), Mask: net.CIDRMask(24, 32)}},
+		},
+		Routes: []*cniTypes.Route{{Dst: net.IPNet{IP: net.ParseIP("192.168.1.0"), Mask: net.CIDRMask(24, 32)}}},
+		DNS:    cniTypes.DNS{Nameservers: []string{"192.168.0.1"}},
+	}
+	result := &cniTypesCurr.Result{
+		CNIVersion: "1.0.0",
+		Interfaces: []*cniTypesCurr.Interface{{Name: eth0IfName, Sandbox: "/var/run/netns/ns1"}},
+		IPs: []*cniTypesCurr.IPConfig{
+			{Interface: &index, Address: net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}},
+			{Address: net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)}},
+		},
+		Routes: []*cniTypes.Route{{Dst: *defaultDst, GW: net.ParseIP("10.0.0.1")}},
+	}
+
+	merged := mergePrevResult(prevResult, result)
+
+	require.Equal(t, "1.0.0", merged.CNIVersion)
+	require.Len(t, merged.Interfaces, 2)
+	require.Equal(t, "macvlan0", merged.Interfaces[0].Name)
+	require.Equal(t, eth0IfName, merged.Interfaces[1].Name)
+
+	require.Len(t, merged.IPs, 3)
+	require.Equal(t, 0, *merged.IPs[0].Interface)
+	require.Equal(t, 1, *merged.IPs[1].Interface)
+	require.Nil(t, merged.IPs[2].Interface)
+	require.Equal(t, 0, index, "the result of this plugin should not be modified")
+
+	require.Len(t, merged.Routes, 2)
+	require.Equal(t, []string{"192.168.0.1"}, merged.DNS.Nameservers, "previous DNS is kept when this plugin has none")
+
+	result.DNS = cniTypes.DNS{Nameservers: []string{"168.63.129.16"}}
+	merged = mergePrevResult(prevResult, result)
+	require.Equal(t, []string{"168.63.129.16"}, merged.DNS.Nameservers)
+}
+
+func TestAddSecondaryInterfaces(t *testing.T) {
+	_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+	_, secondaryDst, _ := net.ParseCIDR("10.1.0.0/16")
+	mac, _ := net.ParseMAC("00:0d:3a:01:02:03")
+
+	result := &cniTypesCurr.Result{
+		Interfaces: []*cniTypesCurr.Interface{{Name: eth0IfName, Sandbox: "/var/run/netns/ns1"}},
+		IPs: []*cniTypesCurr.IPConfig{
+			{Address: net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}},
+		},
+		Routes: []*cniTypes.Route{{Dst: *defaultDst, GW: net.ParseIP("10.0.0.1")}},
+	}
+
+	addSecondaryInterfaces(result, nil, "/var/run/netns/ns1")
+	require.Len(t, result.Interfaces, 1)
+	require.Nil(t, result.IPs[0].Interface, "the result is unchanged without secondary interfaces")
+
+	secondaryIfs := []acnnetwork.InterfaceInfo{
+		{
+			Name:       "eth1",
+			MacAddress: mac,
+			IPAddresses: []net.IPNet{
+				{IP: net.ParseIP("10.1.0.4"), Mask: net.CIDRMask(16, 32)},
+				{IP: net.ParseIP("fd00::4"), Mask: net.CIDRMask(64, 128)},
+			},
+			Gateways: []net.IP{net.ParseIP("fd00::1"), net.ParseIP("10.1.0.1")},
+			Routes:   []acnnetwork.RouteInfo{{Dst: *secondaryDst, Gw: net.ParseIP("10.1.0.1")}},
+		},
+	}
+	addSecondaryInterfaces(result, secondaryIfs, "/var/run/netns/ns1")
+
+	require.Len(t, result.Interfaces, 2)
+	require.Equal(t, "eth1", result.Interfaces[1].Name)
+	require.Equal(t, mac.String(), result.Interfaces[1].Mac)
+	require.Equal(t, "/var/run/netns/ns1", result.Interfaces[1].Sandbox)
+
+	require.Len(t, result.IPs, 3)
+	require.Equal(t, 0, *result.IPs[0].Interface)
+	require.Equal(t, 1, *result.IPs[1].Interface)
+	require.Equal(t, "10.1.0.1", result.IPs[1].Gateway.String())
+	require.Equal(t, 1, *result.IPs[2].Interface)
+	require.Equal(t, "fd00::1", result.IPs[2].Gateway.String())
+
+	require.Len(t, result.Routes, 2)
+	require.Equal(t, secondaryDst.String(), result.Routes[1].Dst.String())
+}
+
+func TestCheckPrevResult(t *testing.T) {
+	epInfo := &acnnetwork.EndpointInfo{
+		Id:          "ep1",
+		IPAddresses: []net.IPNet{{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}},
+	}
+
+	tests := []struct {
+		name       string
+		prevResult string
+		wantErr    bool
+	}{
+		{
+			name: "no previous result",
+		},
+		{
+			name:       "matching previous result",
+			prevResult: `{"cniVersion":"0.4.0","ips":[{"version":"4","address":"10.0.0.4/24"}]}`,
+		},
+		{
+			name:       "previous result with another address",
+			prevResult: `{"cniVersion":"0.4.0","ips":[{"version":"4","address":"10.0.0.5/24"}]}`,
+			wantErr:    true,
+		},
+		{
+			name:       "invalid previous result",
+			prevResult: `{"cniVersion":"0.4.0","ips":[{"version":"4","address":"10.0.0"}]}`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &cni.NetworkConfig{CNIVersion: "0.4.0"}
+			if tt.prevResult != "" {
+				cfg.RawPrevResult = []byte(tt.prevResult)
+			}
+
+			err := checkPrevResult(cfg, epInfo)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 /*
 Multitenancy scenarios
 */
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cni/util"
@@ -225,12 +226,14 @@ func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Resul
 			Servers: nwCfg.DNS.Nameservers,
 			Suffix:  namespace + "." + strings.Join(nwCfg.DNS.Search, ","),
 			Options: nwCfg.DNS.Options,
+			Domain:  nwCfg.DNS.Domain,
 		}
 	} else {
 		epDNS = network.DNSInfo{
 			Servers: result.DNS.Nameservers,
 			Suffix:  result.DNS.Domain,
 			Options: nwCfg.DNS.Options,
+			Domain:  result.DNS.Domain,
 		}
 	}
 
@@ -241,15 +244,17 @@ func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Resul
 func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
 	log.Printf("[net] RuntimeConfigs: %+v", nwCfg.RuntimeConfig)
 	var policies []policy.Policy
-	var protocol uint32
 	for _, mapping := range nwCfg.RuntimeConfig.PortMappings {
-
+		var protocol uint32
 		cfgProto := strings.ToUpper(strings.TrimSpace(mapping.Protocol))
 		switch cfgProto {
-		case "TCP":
+		case "", "TCP":
 			protocol = policy.ProtocolTcp
 		case "UDP":
 			protocol = policy.ProtocolUdp
+		default:
+			log.Printf("[net] Ignoring port mapping %+v with unsupported protocol", mapping)
+			continue
 		}
 
 		rawPolicy, _ := json.Marshal(&hnsv2.PortMappingPolicySetting{
@@ -276,6 +281,84 @@ func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
 	return policies
 }
 
+// getPodRoutePolicies returns the ROUTE endpoint policies of the routes requested for the pod, which HNS applies
+// in addition to the routes of the endpoint.
+func getPodRoutePolicies(routes []network.RouteInfo) []policy.Policy {
+	policies := make([]policy.Policy, 0, len(routes))
+	for i := range routes {
+		rawPolicy, _ := json.Marshal(&policy.KVPairRoute{
+			Type:              policy.RoutePolicy,
+			DestinationPrefix: routes[i].Dst.String(),
+			NextHop:           routes[i].Gw.String(),
+			NeedEncap:         false,
+		})
+
+		policies = append(policies, policy.Policy{
+			Type: policy.EndpointPolicy,
+			Data: rawPolicy,
+		})
+	}
+
+	return policies
+}
+
+// getOutboundNATExceptions returns the prefixes of the outbound NAT exceptions of the config, in their canonical
+// form and without duplicates.
+func getOutboundNATExceptions(nwCfg *cni.NetworkConfig) ([]string, error) {
+	cfg := nwCfg.WindowsSettings.OutboundNATExceptions
+	if cfg == nil {
+		return nil, nil
+	}
+
+	var exceptions []string
+	seen := make(map[string]bool)
+	for _, cidrs := range [][]string{cfg.ClusterCIDRs, cfg.ServiceCIDRs, cfg.InfraCIDRs} {
+		for _, cidr := range cidrs {
+			_, prefix, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid outbound NAT exception %q", cidr)
+			}
+			if !seen[prefix.String()] {
+				seen[prefix.String()] = true
+				exceptions = append(exceptions, prefix.String())
+			}
+		}
+	}
+
+	return exceptions, nil
+}
+
+// syncOutboundNATExceptions updates the outbound NAT exceptions of the endpoints of the network which were created
+// with another config. The endpoint just created is kept regardless, so failures are only logged.
+func (plugin *NetPlugin) syncOutboundNATExceptions(networkID string, exceptions []string) {
+	if err := plugin.nm.UpdateOutboundNATExceptions(networkID, exceptions); err != nil {
+		logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Failed to update outbound NAT exceptions: %v", err))
+	}
+}
+
+// getPortMappingsFromRuntimeCfg returns no mappings, the port mappings are HNS policies on windows.
+func getPortMappingsFromRuntimeCfg(_ *cni.NetworkConfig) []network.PortMapping {
+	return nil
+}
+
+// getBandwidthLimitsFromRuntimeCfg returns no limits, bandwidth shaping is not supported by HNS endpoints.
+func getBandwidthLimitsFromRuntimeCfg(nwCfg *cni.NetworkConfig) network.BandwidthLimits {
+	if nwCfg.RuntimeConfig.Bandwidth != nil {
+		log.Printf("[net] Ignoring bandwidth limits %+v, not supported on windows", *nwCfg.RuntimeConfig.Bandwidth)
+	}
+
+	return network.BandwidthLimits{}
+}
+
+// getDADTimeout returns zero, HNS detects duplicate addresses of the endpoints itself.
+func getDADTimeout(nwCfg *cni.NetworkConfig) time.Duration {
+	if nwCfg.DuplicateAddressDetection != nil && nwCfg.DuplicateAddressDetection.Enabled {
+		log.Printf("[net] Ignoring duplicate address detection, not supported on windows")
+	}
+
+	return 0
+}
+
 func getEndpointPolicies(args PolicyArgs) ([]policy.Policy, error) {
 	var policies []policy.Policy
 
@@ -400,3 +483,8 @@ func platformInit(cniConfig *cni.NetworkConfig) {
 		network.EnableHnsV2Timeout(cniConfig.WindowsSettings.HnsTimeoutDurationInSeconds)
 	}
 }
+
+// isStatelessCNIModeSupported returns true, the HNS network of every mode is found by name when it already exists.
+func isStatelessCNIModeSupported(_ string) bool {
+	return true
+}
@@ -499,3 +499,23 @@ func TestGetNetworkNameFromCNS(t *testing.T) {
 		})
 	}
 }
+
+func TestGetOutboundNATExceptions(t *testing.T) {
+	nwCfg := &cni.NetworkConfig{}
+	exceptions, err := getOutboundNATExceptions(nwCfg)
+	require.NoError(t, err)
+	require.Nil(t, exceptions)
+
+	nwCfg.WindowsSettings.OutboundNATExceptions = &cni.OutboundNATExceptions{
+		ClusterCIDRs: []string{"10.244.0.1/16"},
+		ServiceCIDRs: []string{"10.0.0.0/16"},
+		InfraCIDRs:   []string{"10.244.0.0/16", "168.63.129.16/32"},
+	}
+	exceptions, err = getOutboundNATExceptions(nwCfg)
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.244.0.0/16", "10.0.0.0/16", "168.63.129.16/32"}, exceptions)
+
+	nwCfg.WindowsSettings.OutboundNATExceptions.InfraCIDRs = []string{"168.63.129.16"}
+	_, err = getOutboundNATExceptions(nwCfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,235 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Azure/azure-container-networking/cni/network"
+	cnms "github.com/Azure/azure-container-networking/network"
+	"github.com/pkg/errors"
+)
+
+const endpointUsage = `Usage: azure-vnet endpoint <command> [flags] [endpoint-id]
+
+Commands:
+  list                          List the endpoints in the state
+  show <endpoint-id>            Show an endpoint and check it against its namespace
+  delete [flags] <endpoint-id>  Delete an endpoint and its interfaces
+      -dry-run  Print what would be deleted
+      -force    Remove the endpoint from the state even if deleting its interfaces fails
+  repair [flags] <endpoint-id>  Add the addresses and routes missing in the namespace of an endpoint
+      -dry-run  Print what would be repaired
+`
+
+var errEndpointNotFound = errors.New("endpoint not found")
+
+// endpointCommand is a command of azure-vnet endpoint, which operates on the endpoints of the state directly so
+// that node operators don't need the kubelet or to edit the state file.
+type endpointCommand struct {
+	name       string
+	endpointID string
+	dryRun     bool
+	force      bool
+}
+
+// isEndpointCommand returns true if the plugin was run as azure-vnet endpoint.
+func isEndpointCommand(cmdArgs []string) bool {
+	return len(cmdArgs) > 0 && cmdArgs[0] == "endpoint"
+}
+
+// parseEndpointCommand parses the arguments after azure-vnet endpoint. Flags may come before or after the endpoint ID.
+func parseEndpointCommand(cmdArgs []string) (*endpointCommand, error) {
+	if len(cmdArgs) == 0 {
+		return nil, errors.New("missing endpoint command")
+	}
+
+	cmd := &endpointCommand{name: cmdArgs[0]}
+	fs := flag.NewFlagSet("endpoint "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	switch cmd.name {
+	case "list", "show":
+	case "delete":
+		fs.BoolVar(&cmd.dryRun, "dry-run", false, "")
+		fs.BoolVar(&cmd.force, "force", false, "")
+	case "repair":
+		fs.BoolVar(&cmd.dryRun, "dry-run", false, "")
+	default:
+		return nil, errors.Errorf("unknown endpoint command %q", cmd.name)
+	}
+
+	var positional []string
+	rest := cmdArgs[1:]
+	for {
+		if err := fs.Parse(rest); err != nil {
+			return nil, errors.Wrapf(err, "endpoint %s", cmd.name)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+
+	switch {
+	case cmd.name == "list" && len(positional) != 0:
+		return nil, errors.New("endpoint list takes no arguments")
+	case cmd.name != "list" && len(positional) != 1:
+		return nil, errors.Errorf("endpoint %s takes one endpoint ID", cmd.name)
+	case cmd.name != "list":
+		cmd.endpointID = positional[0]
+	}
+
+	return cmd, nil
+}
+
+// runEndpointCommand runs azure-vnet endpoint with the store locked, and prints the usage if the arguments are wrong.
+func runEndpointCommand(cmdArgs []string, out io.Writer) error {
+	cmd, err := parseEndpointCommand(cmdArgs)
+	if err != nil {
+		fmt.Fprint(os.Stderr, endpointUsage)
+		return err
+	}
+
+	return withNetPlugin(func(netPlugin *network.NetPlugin) error {
+		state, err := netPlugin.ExportState()
+		if err != nil {
+			return errors.Wrap(err, "Export state error")
+		}
+
+		if cmd.name == "list" {
+			return listEndpoints(state, out)
+		}
+
+		networkID, ep, err := findEndpoint(state, cmd.endpointID)
+		if err != nil {
+			return err
+		}
+
+		switch cmd.name {
+		case "show":
+			return showEndpoint(netPlugin, networkID, ep, out)
+		case "delete":
+			return deleteEndpoint(netPlugin, networkID, ep, cmd.dryRun, cmd.force, out)
+		default:
+			return repairEndpoint(netPlugin, networkID, ep, cmd.dryRun, out)
+		}
+	})
+}
+
+// findEndpoint returns the endpoint with the ID and the ID of its network.
+func findEndpoint(state *cnms.NodeState, endpointID string) (string, *cnms.EndpointState, error) {
+	for i := range state.ExternalInterfaces {
+		for j := range state.ExternalInterfaces[i].Networks {
+			nw := &state.ExternalInterfaces[i].Networks[j]
+			for k := range nw.Endpoints {
+				if nw.Endpoints[k].Id == endpointID {
+					return nw.Id, &nw.Endpoints[k], nil
+				}
+			}
+		}
+	}
+
+	return "", nil, errors.Wrap(errEndpointNotFound, endpointID)
+}
+
+func listEndpoints(state *cnms.NodeState, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NETWORK\tENDPOINT\tCONTAINER\tPOD\tIPS\tHOST VETH")
+	for _, extIf := range state.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for i := range nw.Endpoints {
+				ep := &nw.Endpoints[i]
+				pod := ""
+				if ep.PodName != "" {
+					pod = ep.PodNamespace + "/" + ep.PodName
+				}
+				hostVeth := ""
+				if ep.HostVeth != nil {
+					hostVeth = ep.HostVeth.Name
+					if !ep.HostVeth.Exists {
+						hostVeth += " (missing)"
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					nw.Id, ep.Id, ep.ContainerID, pod, strings.Join(ep.IPAddresses, ","), hostVeth)
+			}
+		}
+	}
+
+	return errors.Wrap(w.Flush(), "Write endpoints error")
+}
+
+// showEndpoint prints the state of the endpoint and what a repair would change in its namespace.
+func showEndpoint(netPlugin *network.NetPlugin, networkID string, ep *cnms.EndpointState, out io.Writer) error {
+	b, err := json.MarshalIndent(ep, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "Marshal endpoint error")
+	}
+	fmt.Fprintf(out, "%s\nNetwork: %s\n", b, networkID)
+
+	actions, err := netPlugin.RepairEndpoint(networkID, ep.Id, true)
+	switch {
+	case cnms.IsEndpointNotRepairableError(err):
+		fmt.Fprintf(out, "Check: %v, delete it with azure-vnet endpoint delete\n", err)
+	case err != nil:
+		return errors.Wrap(err, "Check endpoint error")
+	case len(actions) == 0:
+		fmt.Fprintln(out, "Check: the namespace matches the state")
+	default:
+		fmt.Fprintln(out, "Check: the namespace diverged from the state, azure-vnet endpoint repair would")
+		for _, action := range actions {
+			fmt.Fprintf(out, "  %s\n", action)
+		}
+	}
+
+	return nil
+}
+
+func deleteEndpoint(netPlugin *network.NetPlugin, networkID string, ep *cnms.EndpointState, dryRun, force bool, out io.Writer) error {
+	if dryRun {
+		fmt.Fprintf(out, "would delete endpoint %s of container %s in network %s", ep.Id, ep.ContainerID, networkID)
+		if ep.HostVeth != nil {
+			fmt.Fprintf(out, " and host veth %s", ep.HostVeth.Name)
+		}
+		fmt.Fprintln(out)
+		return nil
+	}
+
+	if err := netPlugin.DeleteEndpointByID(networkID, ep.Id, force); err != nil {
+		return errors.Wrap(err, "Delete endpoint error")
+	}
+
+	fmt.Fprintf(out, "deleted endpoint %s, its addresses %s were not released from IPAM\n",
+		ep.Id, strings.Join(ep.IPAddresses, ","))
+	return nil
+}
+
+func repairEndpoint(netPlugin *network.NetPlugin, networkID string, ep *cnms.EndpointState, dryRun bool, out io.Writer) error {
+	actions, err := netPlugin.RepairEndpoint(networkID, ep.Id, dryRun)
+	if cnms.IsEndpointNotRepairableError(err) {
+		return errors.Wrap(err, "delete the endpoint with azure-vnet endpoint delete instead")
+	}
+	if err != nil {
+		return errors.Wrap(err, "Repair endpoint error")
+	}
+
+	if len(actions) == 0 {
+		fmt.Fprintln(out, "nothing to repair")
+	}
+	for _, action := range actions {
+		if dryRun {
+			action = "would " + action
+		}
+		fmt.Fprintln(out, action)
+	}
+
+	return nil
+}
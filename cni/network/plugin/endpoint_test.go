@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEndpointCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    *endpointCommand
+		wantErr bool
+	}{
+		{args: []string{"list"}, want: &endpointCommand{name: "list"}},
+		{args: []string{"show", "ep1"}, want: &endpointCommand{name: "show", endpointID: "ep1"}},
+		{args: []string{"delete", "-dry-run", "ep1"}, want: &endpointCommand{name: "delete", endpointID: "ep1", dryRun: true}},
+		{args: []string{"delete", "ep1", "-force"}, want: &endpointCommand{name: "delete", endpointID: "ep1", force: true}},
+		{args: []string{"repair", "ep1", "--dry-run"}, want: &endpointCommand{name: "repair", endpointID: "ep1", dryRun: true}},
+		{args: nil, wantErr: true},
+		{args: []string{"create", "ep1"}, wantErr: true},
+		{args: []string{"list", "ep1"}, wantErr: true},
+		{args: []string{"show"}, wantErr: true},
+		{args: []string{"show", "ep1", "ep2"}, wantErr: true},
+		{args: []string{"repair", "-force", "ep1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		cmd, err := parseEndpointCommand(tt.args)
+		if tt.wantErr {
+			require.Error(t, err, "args %v", tt.args)
+			continue
+		}
+		require.NoError(t, err, "args %v", tt.args)
+		require.Equal(t, tt.want, cmd)
+	}
+}
@@ -5,6 +5,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -16,6 +17,7 @@ import (
 	"github.com/Azure/azure-container-networking/cni/api"
 	"github.com/Azure/azure-container-networking/cni/network"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/nns"
 	"github.com/Azure/azure-container-networking/platform"
@@ -137,6 +139,57 @@ func printCNIError(msg string) {
 	cniErr.Print()
 }
 
+// isStateDump returns true if the plugin was run as azure-vnet state dump.
+func isStateDump(cmdArgs []string) bool {
+	return len(cmdArgs) == 2 && cmdArgs[0] == "state" && cmdArgs[1] == "dump"
+}
+
+// withNetPlugin starts the network plugin with the store locked, so that a concurrent command doesn't change the
+// state halfway, and runs fn with it.
+func withNetPlugin(fn func(*network.NetPlugin) error) error {
+	var config common.PluginConfig
+	config.Version = version
+
+	netPlugin, err := network.NewPlugin(name, &config, &nns.GrpcClient{}, &network.Multitenancy{})
+	if err != nil {
+		return errors.Wrap(err, "Create plugin error")
+	}
+
+	if err = netPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
+		return errors.Wrap(err, "lock acquire error")
+	}
+	defer func() {
+		if errUninit := netPlugin.Plugin.UninitializeKeyValueStore(); errUninit != nil {
+			log.Errorf("Failed to uninitialize key-value store of network plugin, err:%v.\n", errUninit)
+		}
+	}()
+
+	if err = netPlugin.Start(&config); err != nil {
+		return errors.Wrap(err, "Start plugin error")
+	}
+	defer netPlugin.Stop()
+
+	return fn(netPlugin)
+}
+
+// dumpState prints the network state of the node to stdout for support bundles.
+func dumpState() error {
+	return withNetPlugin(func(netPlugin *network.NetPlugin) error {
+		state, err := netPlugin.ExportState()
+		if err != nil {
+			return errors.Wrap(err, "Export state error")
+		}
+
+		b, err := json.MarshalIndent(state, "", "    ")
+		if err != nil {
+			return errors.Wrap(err, "Marshal state error")
+		}
+
+		_, err = os.Stdout.Write(append(b, '\n'))
+		return errors.Wrap(err, "Write state error")
+	})
+}
+
 func rootExecute() error {
 	var (
 		config common.PluginConfig
@@ -144,6 +197,8 @@ func rootExecute() error {
 	)
 
 	config.Version = version
+	iptables.JournalPath = iptables.DefaultJournalPath
+	iptables.RestoreLockPath = iptables.DefaultRestoreLockPath
 	reportManager := &telemetry.ReportManager{
 		HostNetAgentURL: hostNetAgentURL,
 		ContentType:     telemetry.ContentType,
@@ -183,7 +238,7 @@ func rootExecute() error {
 			cniReport.VMUptime = upTime.Format("2006-01-02 15:04:05")
 		}
 
-		// CNI Acquires lock
+		// CNI Acquires lock. In stateless mode the command releases it once it has read the network configuration.
 		if err = netPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
 			printCNIError(fmt.Sprintf("Failed to initialize key-value store of network plugin: %v", err))
 
@@ -230,6 +285,15 @@ func rootExecute() error {
 
 		netPlugin.SetCNIReport(cniReport, tb)
 
+		// Commands for different pods run in parallel under per-pod locks, the store is locked only while accessed.
+		if platform.CNILockPath != "" {
+			netPlugin.SetLockPath(platform.CNILockPath)
+			config.LockStorePerAccess = true
+			if err = netPlugin.Plugin.UnlockKeyValueStore(); err != nil {
+				return errors.Wrap(err, "lock release error")
+			}
+		}
+
 		t := time.Now()
 		cniReport.Timestamp = t.Format("2006-01-02 15:04:05")
 
@@ -296,7 +360,21 @@ func main() {
 		return
 	}
 
-	err := rootExecute()
+	var err error
+	switch {
+	case isStateDump(flag.Args()):
+		err = dumpState()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to dump state: %v\n", err)
+		}
+	case isEndpointCommand(flag.Args()):
+		err = runEndpointCommand(flag.Args()[1:], os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to run endpoint command: %v\n", err)
+		}
+	default:
+		err = rootExecute()
+	}
 
 	log.Close()
 	if err != nil {
@@ -0,0 +1,85 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/pkg/errors"
+)
+
+const (
+	// podRouteSeparator separates the routes of the ROUTES CNI argument.
+	podRouteSeparator = ","
+	// podRouteVia separates the destination and the gateway of a route of the ROUTES CNI argument.
+	podRouteVia = " via "
+)
+
+var (
+	errPodRouteFormat      = errors.New("pod route must be in the format <prefix> via <gateway>")
+	errPodRoutesNotAllowed = errors.New("pod routes are not supported in transparent mode")
+)
+
+// getPodRoutes returns the extra routes requested for the pod in the ROUTES CNI argument and in the runtime
+// config, which the runtime sets from the pod annotations. The routes are validated against the addresses of
+// the endpoint when it is created.
+func getPodRoutes(nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs) ([]network.RouteInfo, error) {
+	var routes []network.RouteInfo
+
+	podCfg, err := cni.ParseCniArgs(args.Args)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse CNI args %q", args.Args)
+	}
+
+	if arg := strings.TrimSpace(string(podCfg.ROUTES)); arg != "" {
+		for _, value := range strings.Split(arg, podRouteSeparator) {
+			parts := strings.Split(strings.TrimSpace(value), podRouteVia)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("%w: %q", errPodRouteFormat, value)
+			}
+
+			route, err := parsePodRoute(parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, route)
+		}
+	}
+
+	for _, runtimeRoute := range nwCfg.RuntimeConfig.Routes {
+		route, err := parsePodRoute(runtimeRoute.Dst, runtimeRoute.GW)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+
+	// The host routes all the traffic of the pod in transparent mode, there is no gateway in the pod subnet.
+	if len(routes) > 0 && nwCfg.Mode == OpModeTransparent {
+		return nil, errPodRoutesNotAllowed
+	}
+
+	return routes, nil
+}
+
+func parsePodRoute(dst, gw string) (network.RouteInfo, error) {
+	dstIP, dstNet, err := net.ParseCIDR(strings.TrimSpace(dst))
+	if err != nil {
+		return network.RouteInfo{}, fmt.Errorf("%w: invalid destination %q", errPodRouteFormat, dst)
+	}
+
+	// The address is kept as given rather than masked, so that a destination which is not a prefix is rejected.
+	if ipv4 := dstIP.To4(); ipv4 != nil {
+		dstIP = ipv4
+	}
+
+	gwIP := net.ParseIP(strings.TrimSpace(gw))
+	if gwIP == nil {
+		return network.RouteInfo{}, fmt.Errorf("%w: invalid gateway %q", errPodRouteFormat, gw)
+	}
+
+	return network.RouteInfo{Dst: net.IPNet{IP: dstIP, Mask: dstNet.Mask}, Gw: gwIP}, nil
+}
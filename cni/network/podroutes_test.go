@@ -0,0 +1,49 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPodRoutes(t *testing.T) {
+	nwCfg := &cni.NetworkConfig{
+		Mode: "bridge",
+		RuntimeConfig: cni.RuntimeConfig{
+			Routes: []cni.RuntimeRoute{{Dst: "172.16.0.0/12", GW: "10.1.0.2"}},
+		},
+	}
+	args := &cniSkel.CmdArgs{Args: "K8S_POD_NAME=pod;ROUTES=10.0.0.0/8 via 10.1.0.1, fd01::/48 via fd00::1"}
+
+	routes, err := getPodRoutes(nwCfg, args)
+	require.NoError(t, err)
+	require.Len(t, routes, 3)
+	require.Equal(t, "10.0.0.0/8", routes[0].Dst.String())
+	require.True(t, routes[0].Gw.Equal(net.ParseIP("10.1.0.1")))
+	require.Equal(t, "fd01::/48", routes[1].Dst.String())
+	require.True(t, routes[1].Gw.Equal(net.ParseIP("fd00::1")))
+	require.Equal(t, "172.16.0.0/12", routes[2].Dst.String())
+	require.True(t, routes[2].Gw.Equal(net.ParseIP("10.1.0.2")))
+
+	// the destination is kept as given so that validation rejects it
+	routes, err = getPodRoutes(&cni.NetworkConfig{}, &cniSkel.CmdArgs{Args: "ROUTES=10.1.2.3/8 via 10.1.0.1"})
+	require.NoError(t, err)
+	podIP := net.IPNet{IP: net.ParseIP("10.1.0.10").To4(), Mask: net.CIDRMask(24, 32)}
+	require.True(t, network.IsPodRouteInvalidError(network.ValidatePodRoutes(routes, []net.IPNet{podIP})))
+
+	for _, arg := range []string{"ROUTES=10.0.0.0/8", "ROUTES=10.0.0.0/8 via gw", "ROUTES=10.0.0.0 via 10.1.0.1"} {
+		_, err = getPodRoutes(&cni.NetworkConfig{}, &cniSkel.CmdArgs{Args: arg})
+		require.ErrorIs(t, err, errPodRouteFormat, arg)
+	}
+
+	_, err = getPodRoutes(&cni.NetworkConfig{Mode: OpModeTransparent}, args)
+	require.ErrorIs(t, err, errPodRoutesNotAllowed)
+
+	routes, err = getPodRoutes(&cni.NetworkConfig{Mode: OpModeTransparent}, &cniSkel.CmdArgs{Args: "K8S_POD_NAME=pod"})
+	require.NoError(t, err)
+	require.Empty(t, routes)
+}
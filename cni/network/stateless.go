@@ -0,0 +1,153 @@
+package network
+
+import (
+	"context"
+	"net"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/common"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/restserver"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/pkg/errors"
+)
+
+var errStatelessCNIUnsupported = errors.New("stateless CNI is not supported")
+
+// endpointStateClient reads and records the endpoint state kept by CNS in stateless mode.
+type endpointStateClient interface {
+	GetEndpoint(ctx context.Context, endpointID string) (*restserver.GetEndpointResponse, error)
+	UpdateEndpoint(ctx context.Context, endpointID string, ifnameToIPMap map[string]*restserver.IPInfo) error
+}
+
+// useStatelessState stops using the state file for the command if the configuration enables the stateless mode, in
+// which the endpoints are kept by CNS. The store is locked and read before the configuration is known, so the lock
+// is released and the state read from the store is dropped.
+func (plugin *NetPlugin) useStatelessState(nwCfg *cni.NetworkConfig) error {
+	if !nwCfg.EnableStatelessCNI || plugin.Store == nil {
+		return nil
+	}
+
+	log.Printf("[cni-net] Stateless CNI, the key-value store is not used")
+	if err := plugin.Plugin.UninitializeKeyValueStore(); err != nil {
+		return errors.Wrap(err, "failed to release the store")
+	}
+
+	if err := plugin.nm.Initialize(&common.PluginConfig{Version: plugin.Version}, false); err != nil {
+		return errors.Wrap(err, "failed to initialize the network manager without store")
+	}
+
+	return errors.Wrap(plugin.nm.Reload(), "failed to drop the state")
+}
+
+// validateStatelessCNIConfig checks that the endpoints of the configuration can be rebuilt from the state in CNS.
+// CNS only knows the endpoints whose addresses it allocated, and only the modes which create their network
+// idempotently can do without the network state.
+func validateStatelessCNIConfig(nwCfg *cni.NetworkConfig) error {
+	if nwCfg.IPAM.Type != network.AzureCNS {
+		return errors.Wrapf(errStatelessCNIUnsupported, "ipam type %s", nwCfg.IPAM.Type)
+	}
+
+	if nwCfg.MultiTenancy {
+		return errors.Wrap(errStatelessCNIUnsupported, "multitenancy")
+	}
+
+	if !isStatelessCNIModeSupported(nwCfg.Mode) {
+		return errors.Wrapf(errStatelessCNIUnsupported, "mode %s", nwCfg.Mode)
+	}
+
+	return nil
+}
+
+// saveEndpointState records the host side of a created endpoint in CNS, next to the addresses of the pod.
+func (plugin *NetPlugin) saveEndpointState(
+	ctx context.Context, client endpointStateClient, args *cniSkel.CmdArgs, networkID, endpointID string,
+) error {
+	epInfo, err := plugin.nm.GetEndpointInfo(networkID, endpointID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get endpoint")
+	}
+
+	ifnameToIPMap := map[string]*restserver.IPInfo{
+		args.IfName: {
+			HostVethName:  epInfo.HostIfName,
+			HnsEndpointID: epInfo.HNSEndpointID,
+		},
+	}
+
+	if err = client.UpdateEndpoint(ctx, args.ContainerID, ifnameToIPMap); err != nil {
+		return errors.Wrap(err, "failed to save endpoint state in CNS")
+	}
+
+	return nil
+}
+
+// restoreEndpointState adds the endpoint kept by CNS to the network manager, so that DEL and CHECK find it as if
+// it had been read from the state file. Nothing is added when CNS has no host interface for the endpoint.
+func (plugin *NetPlugin) restoreEndpointState(
+	ctx context.Context, client endpointStateClient, nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs, networkID string,
+) error {
+	resp, err := client.GetEndpoint(ctx, args.ContainerID)
+	if err != nil {
+		var cnsErr *cnscli.CNSClientError
+		if errors.As(err, &cnsErr) && cnsErr.Code == types.NotFound {
+			log.Printf("[cni-net] No endpoint state in CNS for container %s.", args.ContainerID)
+			return nil
+		}
+
+		return errors.Wrap(err, "failed to get endpoint state from CNS")
+	}
+
+	epInfo := &network.EndpointInfo{
+		Id:           GetEndpointID(args),
+		ContainerID:  args.ContainerID,
+		NetNsPath:    args.Netns,
+		IfName:       args.IfName,
+		PODName:      resp.EndpointInfo.PodName,
+		PODNameSpace: resp.EndpointInfo.PodNamespace,
+	}
+
+	for _, ipInfo := range resp.EndpointInfo.IfnameToIPMap {
+		epInfo.IPAddresses = append(epInfo.IPAddresses, ipInfo.IPv4...)
+		epInfo.IPAddresses = append(epInfo.IPAddresses, ipInfo.IPv6...)
+		if ipInfo.HostVethName != "" {
+			epInfo.HostIfName = ipInfo.HostVethName
+		}
+		if ipInfo.HnsEndpointID != "" {
+			epInfo.HNSEndpointID = ipInfo.HnsEndpointID
+		}
+	}
+
+	// The addresses were allocated but the ADD failed before the endpoint was created.
+	if epInfo.HostIfName == "" && epInfo.HNSEndpointID == "" {
+		log.Printf("[cni-net] Endpoint state in CNS for container %s has no host interface.", args.ContainerID)
+		return nil
+	}
+
+	nwInfo := &network.NetworkInfo{
+		Id:   networkID,
+		Mode: nwCfg.Mode,
+	}
+	for _, ipAddress := range epInfo.IPAddresses {
+		if ipAddress.IP.To4() != nil {
+			subnet := net.IPNet{IP: ipAddress.IP.Mask(ipAddress.Mask), Mask: ipAddress.Mask}
+			nwInfo.MasterIfName = plugin.findMasterInterface(nwCfg, &subnet)
+			break
+		}
+	}
+
+	return errors.Wrap(plugin.nm.AddEndpointState(nwInfo, epInfo), "failed to add endpoint state")
+}
+
+// restoreEndpointStateFromCNS restores the endpoint of the command from CNS in stateless mode.
+func (plugin *NetPlugin) restoreEndpointStateFromCNS(nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs, networkID string) error {
+	client, err := cnscli.New(nwCfg.CNSUrl, defaultRequestTimeout)
+	if err != nil {
+		return errors.Wrap(err, "failed to create cns client")
+	}
+
+	return plugin.restoreEndpointState(context.TODO(), client, nwCfg, args, networkID)
+}
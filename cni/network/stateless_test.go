@@ -0,0 +1,143 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/common"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/restserver"
+	"github.com/Azure/azure-container-networking/cns/types"
+	acnnetwork "github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/store"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/stretchr/testify/require"
+)
+
+var errCNSUnavailable = errors.New("cns unavailable")
+
+type fakeEndpointStateClient struct {
+	endpoints map[string]*restserver.EndpointInfo
+	getErr    error
+}
+
+func (f *fakeEndpointStateClient) GetEndpoint(_ context.Context, endpointID string) (*restserver.GetEndpointResponse, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+
+	endpointInfo, ok := f.endpoints[endpointID]
+	if !ok {
+		return nil, &cnscli.CNSClientError{Code: types.NotFound}
+	}
+
+	return &restserver.GetEndpointResponse{EndpointInfo: *endpointInfo}, nil
+}
+
+func (f *fakeEndpointStateClient) UpdateEndpoint(_ context.Context, endpointID string, ifnameToIPMap map[string]*restserver.IPInfo) error {
+	endpointInfo, ok := f.endpoints[endpointID]
+	if !ok {
+		return &cnscli.CNSClientError{Code: types.NotFound}
+	}
+
+	for ifName, ipInfo := range ifnameToIPMap {
+		if _, ok := endpointInfo.IfnameToIPMap[ifName]; !ok {
+			endpointInfo.IfnameToIPMap[ifName] = &restserver.IPInfo{}
+		}
+		endpointInfo.IfnameToIPMap[ifName].HostVethName = ipInfo.HostVethName
+		endpointInfo.IfnameToIPMap[ifName].HnsEndpointID = ipInfo.HnsEndpointID
+	}
+
+	return nil
+}
+
+func TestUseStatelessState(t *testing.T) {
+	plugin := GetTestResources()
+	plugin.Store = store.NewMockStore("")
+	require.NoError(t, plugin.Plugin.InitializeKeyValueStore(&common.PluginConfig{}))
+
+	// the store stays locked for commands which keep their state in it
+	require.NoError(t, plugin.useStatelessState(&cni.NetworkConfig{}))
+	require.NotNil(t, plugin.Store)
+
+	require.NoError(t, plugin.useStatelessState(&cni.NetworkConfig{EnableStatelessCNI: true}))
+	require.Nil(t, plugin.Store)
+}
+
+func TestValidateStatelessCNIConfig(t *testing.T) {
+	supportedMode := OpModeTransparent
+	nwCfg := &cni.NetworkConfig{Mode: supportedMode}
+	nwCfg.IPAM.Type = acnnetwork.AzureCNS
+	require.NoError(t, validateStatelessCNIConfig(nwCfg))
+
+	nwCfg.IPAM.Type = "azure-vnet-ipam"
+	require.ErrorIs(t, validateStatelessCNIConfig(nwCfg), errStatelessCNIUnsupported)
+
+	nwCfg.IPAM.Type = acnnetwork.AzureCNS
+	nwCfg.MultiTenancy = true
+	require.ErrorIs(t, validateStatelessCNIConfig(nwCfg), errStatelessCNIUnsupported)
+}
+
+func TestSaveAndRestoreEndpointState(t *testing.T) {
+	plugin := GetTestResources()
+	nm := plugin.nm.(*acnnetwork.MockNetworkManager)
+
+	args := &cniSkel.CmdArgs{ContainerID: "container-1", Netns: "/var/run/netns/ns-1", IfName: "eth0"}
+	endpointID := GetEndpointID(args)
+	_, ipNet, _ := net.ParseCIDR("10.240.0.4/16")
+	ipNet.IP = net.ParseIP("10.240.0.4")
+
+	nm.TestEndpointInfoMap[endpointID] = &acnnetwork.EndpointInfo{Id: endpointID, HostIfName: "azv1234"}
+	client := &fakeEndpointStateClient{
+		endpoints: map[string]*restserver.EndpointInfo{
+			args.ContainerID: {
+				PodName:       "pod-1",
+				PodNamespace:  "default",
+				IfnameToIPMap: map[string]*restserver.IPInfo{"eth0": {IPv4: []net.IPNet{*ipNet}}},
+			},
+		},
+	}
+	require.NoError(t, plugin.saveEndpointState(context.Background(), client, args, "net", endpointID))
+	require.Equal(t, "azv1234", client.endpoints[args.ContainerID].IfnameToIPMap["eth0"].HostVethName)
+
+	// A stateless DEL starts without the endpoint and finds it in CNS.
+	delete(nm.TestEndpointInfoMap, endpointID)
+	nwCfg := &cni.NetworkConfig{Mode: OpModeTransparent, Master: "eth0"}
+	require.NoError(t, plugin.restoreEndpointState(context.Background(), client, nwCfg, args, "net"))
+
+	epInfo, err := plugin.nm.GetEndpointInfo("net", endpointID)
+	require.NoError(t, err)
+	require.Equal(t, "azv1234", epInfo.HostIfName)
+	require.Equal(t, args.Netns, epInfo.NetNsPath)
+	require.Equal(t, "pod-1", epInfo.PODName)
+	require.Equal(t, []net.IPNet{*ipNet}, epInfo.IPAddresses)
+
+	nwInfo, err := plugin.nm.GetNetworkInfo("net")
+	require.NoError(t, err)
+	require.Equal(t, "eth0", nwInfo.MasterIfName)
+}
+
+func TestRestoreEndpointStateWithoutEndpoint(t *testing.T) {
+	plugin := GetTestResources()
+	nwCfg := &cni.NetworkConfig{Mode: OpModeTransparent, Master: "eth0"}
+	args := &cniSkel.CmdArgs{ContainerID: "container-1", IfName: "eth0"}
+
+	// Neither an unknown container nor one whose ADD failed before creating the endpoint is restored.
+	client := &fakeEndpointStateClient{
+		endpoints: map[string]*restserver.EndpointInfo{
+			"container-2": {IfnameToIPMap: map[string]*restserver.IPInfo{"eth0": {}}},
+		},
+	}
+	require.NoError(t, plugin.restoreEndpointState(context.Background(), client, nwCfg, args, "net"))
+	args.ContainerID = "container-2"
+	require.NoError(t, plugin.restoreEndpointState(context.Background(), client, nwCfg, args, "net"))
+
+	_, err := plugin.nm.GetNetworkInfo("net")
+	require.Error(t, err)
+
+	client.getErr = errCNSUnavailable
+	require.ErrorIs(t, plugin.restoreEndpointState(context.Background(), client, nwCfg, args, "net"), errCNSUnavailable)
+}
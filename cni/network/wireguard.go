@@ -0,0 +1,77 @@
+package network
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network/wireguard"
+	"github.com/pkg/errors"
+)
+
+const (
+	// OpModeWireguard encrypts pod-to-pod traffic between nodes through a wireguard overlay.
+	OpModeWireguard = "wireguard"
+	// wireguardKeyMaxAge is the age after which the key of the node is rotated on the next ADD.
+	wireguardKeyMaxAge = 7 * 24 * time.Hour
+)
+
+type wireguardPeerClient interface {
+	PublishWireguardPublicKey(ctx context.Context, publicKey string) error
+	GetWireguardPeers(ctx context.Context) ([]cns.WireguardPeer, error)
+}
+
+// syncWireguardPeers rotates the key of this node when it is due, publishes the public key through CNS
+// and programs the peers of the other nodes. Publishing on every call also distributes rotated keys; the
+// other nodes pick them up on their next ADD.
+func (plugin *NetPlugin) syncWireguardPeers(ctx context.Context, client wireguardPeerClient, networkID string) error {
+	if _, err := plugin.nm.RotateWireguardKey(networkID, wireguardKeyMaxAge); err != nil {
+		return errors.Wrap(err, "failed to rotate wireguard key")
+	}
+
+	publicKey, err := plugin.nm.GetWireguardPublicKey(networkID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get local wireguard public key")
+	}
+
+	if err = client.PublishWireguardPublicKey(ctx, publicKey); err != nil {
+		return errors.Wrap(err, "failed to publish wireguard public key")
+	}
+
+	cnsPeers, err := client.GetWireguardPeers(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get wireguard peers")
+	}
+
+	peers := make([]wireguard.Peer, 0, len(cnsPeers))
+	for _, cnsPeer := range cnsPeers {
+		if cnsPeer.PublicKey == publicKey {
+			continue
+		}
+
+		peer := wireguard.Peer{
+			PublicKey: cnsPeer.PublicKey,
+			Endpoint:  cnsPeer.Endpoint,
+		}
+		for _, cidr := range cnsPeer.AllowedIPs {
+			_, ipNet, parseErr := net.ParseCIDR(cidr)
+			if parseErr != nil {
+				log.Printf("[cni-net] Ignoring invalid allowed IP %s of wireguard peer %s.", cidr, cnsPeer.NodeName)
+				continue
+			}
+			peer.AllowedIPs = append(peer.AllowedIPs, *ipNet)
+		}
+
+		if peer.Validate() != nil {
+			log.Printf("[cni-net] Ignoring invalid wireguard peer %+v.", cnsPeer)
+			continue
+		}
+
+		peers = append(peers, peer)
+	}
+
+	log.Printf("[cni-net] Syncing %d wireguard peers for network %s.", len(peers), networkID)
+	return errors.Wrap(plugin.nm.SyncWireguardPeers(networkID, peers), "failed to sync wireguard peers")
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	acnnetwork "github.com/Azure/azure-container-networking/network"
+	"github.com/stretchr/testify/require"
+)
+
+var errPublish = errors.New("publish failed")
+
+type fakeWireguardPeerClient struct {
+	published  []string
+	peers      []cns.WireguardPeer
+	publishErr error
+}
+
+func (f *fakeWireguardPeerClient) PublishWireguardPublicKey(_ context.Context, publicKey string) error {
+	if f.publishErr != nil {
+		return f.publishErr
+	}
+
+	f.published = append(f.published, publicKey)
+	return nil
+}
+
+func (f *fakeWireguardPeerClient) GetWireguardPeers(context.Context) ([]cns.WireguardPeer, error) {
+	return f.peers, nil
+}
+
+func TestSyncWireguardPeers(t *testing.T) {
+	plugin := GetTestResources()
+	nm := plugin.nm.(*acnnetwork.MockNetworkManager)
+	nm.TestNetworkInfoMap["net"] = &acnnetwork.NetworkInfo{Id: "net"}
+
+	client := &fakeWireguardPeerClient{
+		peers: []cns.WireguardPeer{
+			{NodeName: "local", PublicKey: acnnetwork.MockWireguardPublicKey, Endpoint: "10.240.0.4:51820", AllowedIPs: []string{"10.244.0.0/24"}},
+			{NodeName: "node-1", PublicKey: "key-1", Endpoint: "10.240.0.5:51820", AllowedIPs: []string{"10.244.1.0/24", "invalid"}},
+			{NodeName: "node-2", PublicKey: "key-2", AllowedIPs: []string{"invalid"}},
+		},
+	}
+
+	require.NoError(t, plugin.syncWireguardPeers(context.Background(), client, "net"))
+	require.Equal(t, []string{acnnetwork.MockWireguardPublicKey}, client.published)
+
+	require.Len(t, nm.TestWireguardPeers, 1)
+	require.Equal(t, "key-1", nm.TestWireguardPeers[0].PublicKey)
+	require.Equal(t, "10.240.0.5:51820", nm.TestWireguardPeers[0].Endpoint)
+	require.Len(t, nm.TestWireguardPeers[0].AllowedIPs, 1)
+	require.Equal(t, "10.244.1.0/24", nm.TestWireguardPeers[0].AllowedIPs[0].String())
+
+	client.publishErr = errPublish
+	require.ErrorIs(t, plugin.syncWireguardPeers(context.Background(), client, "net"), errPublish)
+	require.Error(t, plugin.syncWireguardPeers(context.Background(), client, "missing"))
+}
@@ -27,7 +27,8 @@ var errEmptyContent = errors.New("read content is zero bytes")
 // Plugin is the parent class for CNI plugins.
 type Plugin struct {
 	*common.Plugin
-	version string
+	version     string
+	storeLocked bool
 }
 
 // NewPlugin creates a new CNI plugin.
@@ -167,7 +168,8 @@ func (plugin *Plugin) InitializeKeyValueStore(config *common.PluginConfig) error
 			return errors.Wrap(err, "error creating new filelock")
 		}
 
-		plugin.Store, err = store.NewJsonFileStore(platform.CNIRuntimePath+plugin.Name+".json", lockclient)
+		// The state is backed up on each save so that a corrupt file doesn't fail every command on the node.
+		plugin.Store, err = store.NewJsonFileStoreWithBackups(platform.CNIRuntimePath+plugin.Name+".json", lockclient, store.DefaultBackupCount)
 		if err != nil {
 			log.Printf("[cni] Failed to create store: %v.", err)
 			return err
@@ -179,20 +181,31 @@ func (plugin *Plugin) InitializeKeyValueStore(config *common.PluginConfig) error
 		log.Printf("[cni] Failed to lock store: %v.", err)
 		return err
 	}
+	plugin.storeLocked = true
 
 	config.Store = plugin.Store
 
 	return nil
 }
 
-// Uninitialize key-value store
-func (plugin *Plugin) UninitializeKeyValueStore() error {
-	if plugin.Store != nil {
-		err := plugin.Store.Unlock()
-		if err != nil {
+// UnlockKeyValueStore releases the store lock acquired by InitializeKeyValueStore, for plugins which lock the
+// store only while accessing it.
+func (plugin *Plugin) UnlockKeyValueStore() error {
+	if plugin.Store != nil && plugin.storeLocked {
+		if err := plugin.Store.Unlock(); err != nil {
 			log.Printf("[cni] Failed to unlock store: %v.", err)
 			return err
 		}
+		plugin.storeLocked = false
+	}
+
+	return nil
+}
+
+// Uninitialize key-value store
+func (plugin *Plugin) UninitializeKeyValueStore() error {
+	if err := plugin.UnlockKeyValueStore(); err != nil {
+		return err
 	}
 	plugin.Store = nil
 
@@ -13,6 +13,7 @@ import (
 	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
@@ -136,6 +137,7 @@ func main() {
 		return
 	}
 
+	log.ReopenOnSignal(context.Background())
 	log.Logf("args %+v", os.Args)
 
 	if runtime.GOOS == "linux" {
@@ -189,6 +191,22 @@ func main() {
 
 	err = telemetry.CreateAITelemetryHandle(aiConfig, config.DisableAll, config.DisableTrace, config.DisableMetric)
 	log.Printf("[Telemetry] AI Handle creation status:%v", err)
+
+	if !config.DisableAll && config.OTLP.Enabled() {
+		err = telemetry.CreateOTLPTelemetryHandle(pluginName, version, config.OTLP)
+		log.Printf("[Telemetry] OTLP Handle creation status:%v", err)
+	}
+
+	if config.MetricsAddress != "" {
+		registry := prometheus.NewRegistry()
+		if err = telemetry.RegisterMetrics(registry); err != nil {
+			log.Logf("[Telemetry] Failed to register metrics: %v", err)
+		} else {
+			log.Logf("[Telemetry] Serving metrics on %s", config.MetricsAddress)
+			go telemetry.ServeMetrics(config.MetricsAddress, registry)
+		}
+	}
+
 	log.Logf("[Telemetry] Report to host for an interval of %d seconds", config.ReportToHostIntervalInSeconds)
 	tb.PushData(context.Background())
 	telemetry.CloseAITelemetryHandle()
@@ -14,4 +14,8 @@ type IpamMode string
 // IPAM modes
 const (
 	V4Overlay IpamMode = "v4overlay"
+	// DelegatedPodSubnet gives every pod interface an address of the Azure subnet delegated to the pods.
+	DelegatedPodSubnet IpamMode = "delegatedpodsubnet"
+	// DualStack allocates the IPv4 and IPv6 address of a pod from azure-vnet-ipam in a single transaction.
+	DualStack IpamMode = "dualstack"
 )
@@ -0,0 +1,55 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package cni
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	cniVers "github.com/containernetworking/cni/pkg/version"
+)
+
+// ConvertResult converts the result of the plugin to the CNI version of the network configuration, which is the
+// version the runtime expects. Results of 0.1.0 and 0.2.0 only keep the first address of each IP family, and results
+// before 1.0.0 carry the IP version of each address.
+func ConvertResult(result *cniTypesCurr.Result, cniVersion string) (cniTypes.Result, error) {
+	res, err := result.GetAsVersion(cniVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert result to CNI version %s: %w", cniVersion, err)
+	}
+
+	return res, nil
+}
+
+// parseResult parses a result of the CNI version of the network configuration into the current result type.
+// Results before 1.0.0 may not carry their version, which is the version of the configuration then.
+func parseResult(cniVersion string, raw json.RawMessage) (*cniTypesCurr.Result, error) {
+	var versioned struct {
+		CNIVersion string `json:"cniVersion"`
+	}
+	if err := json.Unmarshal(raw, &versioned); err != nil {
+		return nil, fmt.Errorf("failed to decode result version: %w", err)
+	}
+
+	if versioned.CNIVersion == "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, fmt.Errorf("failed to decode result: %w", err)
+		}
+		fields["cniVersion"], _ = json.Marshal(cniVersion)
+		var err error
+		if raw, err = json.Marshal(fields); err != nil {
+			return nil, fmt.Errorf("failed to encode result: %w", err)
+		}
+	}
+
+	res, err := cniVers.NewResult(cniVersion, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return cniTypesCurr.NewResultFromResult(res)
+}
@@ -0,0 +1,154 @@
+package cni
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	cniVers "github.com/containernetworking/cni/pkg/version"
+	"github.com/stretchr/testify/require"
+)
+
+// resultVectors are the results of a dual stack ADD in the format of each version of the CNI spec.
+var resultVectors = map[string]string{
+	"1.0.0": `{
+		"cniVersion": "1.0.0",
+		"interfaces": [{"name": "eth0", "mac": "99:88:77:66:55:44", "sandbox": "/var/run/netns/blue"}],
+		"ips": [
+			{"address": "10.1.0.5/16", "gateway": "10.1.0.1", "interface": 0},
+			{"address": "10.1.0.6/16", "gateway": "10.1.0.1", "interface": 0},
+			{"address": "fd00::5/64", "gateway": "fd00::1", "interface": 0}
+		],
+		"routes": [{"dst": "0.0.0.0/0"}, {"dst": "::/0"}],
+		"dns": {"nameservers": ["10.1.0.1"]}
+	}`,
+	"0.4.0": `{
+		"cniVersion": "0.4.0",
+		"interfaces": [{"name": "eth0", "mac": "99:88:77:66:55:44", "sandbox": "/var/run/netns/blue"}],
+		"ips": [
+			{"version": "4", "address": "10.1.0.5/16", "gateway": "10.1.0.1", "interface": 0},
+			{"version": "4", "address": "10.1.0.6/16", "gateway": "10.1.0.1", "interface": 0},
+			{"version": "6", "address": "fd00::5/64", "gateway": "fd00::1", "interface": 0}
+		],
+		"routes": [{"dst": "0.0.0.0/0"}, {"dst": "::/0"}],
+		"dns": {"nameservers": ["10.1.0.1"]}
+	}`,
+	"0.3.1": `{
+		"cniVersion": "0.3.1",
+		"interfaces": [{"name": "eth0", "mac": "99:88:77:66:55:44", "sandbox": "/var/run/netns/blue"}],
+		"ips": [
+			{"version": "4", "address": "10.1.0.5/16", "gateway": "10.1.0.1", "interface": 0},
+			{"version": "4", "address": "10.1.0.6/16", "gateway": "10.1.0.1", "interface": 0},
+			{"version": "6", "address": "fd00::5/64", "gateway": "fd00::1", "interface": 0}
+		],
+		"routes": [{"dst": "0.0.0.0/0"}, {"dst": "::/0"}],
+		"dns": {"nameservers": ["10.1.0.1"]}
+	}`,
+	"0.3.0": `{
+		"cniVersion": "0.3.0",
+		"interfaces": [{"name": "eth0", "mac": "99:88:77:66:55:44", "sandbox": "/var/run/netns/blue"}],
+		"ips": [
+			{"version": "4", "address": "10.1.0.5/16", "gateway": "10.1.0.1", "interface": 0},
+			{"version": "4", "address": "10.1.0.6/16", "gateway": "10.1.0.1", "interface": 0},
+			{"version": "6", "address": "fd00::5/64", "gateway": "fd00::1", "interface": 0}
+		],
+		"routes": [{"dst": "0.0.0.0/0"}, {"dst": "::/0"}],
+		"dns": {"nameservers": ["10.1.0.1"]}
+	}`,
+	// 0.2.0 and 0.1.0 only have one address of each family
+	"0.2.0": `{
+		"cniVersion": "0.2.0",
+		"ip4": {"ip": "10.1.0.5/16", "gateway": "10.1.0.1", "routes": [{"dst": "0.0.0.0/0"}]},
+		"ip6": {"ip": "fd00::5/64", "gateway": "fd00::1", "routes": [{"dst": "::/0"}]},
+		"dns": {"nameservers": ["10.1.0.1"]}
+	}`,
+	"0.1.0": `{
+		"cniVersion": "0.1.0",
+		"ip4": {"ip": "10.1.0.5/16", "gateway": "10.1.0.1", "routes": [{"dst": "0.0.0.0/0"}]},
+		"ip6": {"ip": "fd00::5/64", "gateway": "fd00::1", "routes": [{"dst": "::/0"}]},
+		"dns": {"nameservers": ["10.1.0.1"]}
+	}`,
+}
+
+func vectorResult(t *testing.T) *cniTypesCurr.Result {
+	t.Helper()
+	_, ipv4Net, _ := net.ParseCIDR("10.1.0.0/16")
+	_, ipv6Net, _ := net.ParseCIDR("fd00::/64")
+	_, defaultV4, _ := net.ParseCIDR("0.0.0.0/0")
+	_, defaultV6, _ := net.ParseCIDR("::/0")
+	return &cniTypesCurr.Result{
+		CNIVersion: cniTypesCurr.ImplementedSpecVersion,
+		Interfaces: []*cniTypesCurr.Interface{{Name: "eth0", Mac: "99:88:77:66:55:44", Sandbox: "/var/run/netns/blue"}},
+		IPs: []*cniTypesCurr.IPConfig{
+			{Address: net.IPNet{IP: net.ParseIP("10.1.0.5"), Mask: ipv4Net.Mask}, Gateway: net.ParseIP("10.1.0.1"), Interface: cniTypesCurr.Int(0)},
+			{Address: net.IPNet{IP: net.ParseIP("10.1.0.6"), Mask: ipv4Net.Mask}, Gateway: net.ParseIP("10.1.0.1"), Interface: cniTypesCurr.Int(0)},
+			{Address: net.IPNet{IP: net.ParseIP("fd00::5"), Mask: ipv6Net.Mask}, Gateway: net.ParseIP("fd00::1"), Interface: cniTypesCurr.Int(0)},
+		},
+		Routes: []*cniTypes.Route{{Dst: *defaultV4}, {Dst: *defaultV6}},
+		DNS:    cniTypes.DNS{Nameservers: []string{"10.1.0.1"}},
+	}
+}
+
+func TestSupportedVersions(t *testing.T) {
+	require.ElementsMatch(t, cniVers.All.SupportedVersions(), supportedVersions)
+	for _, version := range supportedVersions {
+		require.Contains(t, resultVectors, version)
+	}
+}
+
+func TestConvertResult(t *testing.T) {
+	for version, vector := range resultVectors {
+		t.Run(version, func(t *testing.T) {
+			res, err := ConvertResult(vectorResult(t), version)
+			require.NoError(t, err)
+			require.Equal(t, version, res.Version())
+			b, err := json.Marshal(res)
+			require.NoError(t, err)
+			require.JSONEq(t, vector, string(b))
+		})
+	}
+
+	_, err := ConvertResult(vectorResult(t), "0.5.0")
+	require.Error(t, err)
+}
+
+func TestPrevResult(t *testing.T) {
+	for version, vector := range resultVectors {
+		t.Run(version, func(t *testing.T) {
+			nwCfg := &NetworkConfig{CNIVersion: version, RawPrevResult: json.RawMessage(vector)}
+			prevResult, err := nwCfg.PrevResult()
+			require.NoError(t, err)
+			require.Equal(t, cniTypesCurr.ImplementedSpecVersion, prevResult.CNIVersion)
+			require.Equal(t, "10.1.0.5/16", prevResult.IPs[0].Address.String())
+			require.Equal(t, "fd00::5/64", prevResult.IPs[len(prevResult.IPs)-1].Address.String())
+
+			// results before 1.0.0 may leave out their version, which is the version of the configuration
+			var fields map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal([]byte(vector), &fields))
+			delete(fields, "cniVersion")
+			nwCfg.RawPrevResult, err = json.Marshal(fields)
+			require.NoError(t, err)
+			prevResult, err = nwCfg.PrevResult()
+			require.NoError(t, err)
+			require.Equal(t, "10.1.0.5/16", prevResult.IPs[0].Address.String())
+		})
+	}
+
+	prevResult, err := (&NetworkConfig{CNIVersion: "1.0.0"}).PrevResult()
+	require.NoError(t, err)
+	require.Nil(t, prevResult)
+}
+
+func TestParseNetworkConfigDisableCheck(t *testing.T) {
+	nwCfg, err := ParseNetworkConfig([]byte(`{"cniVersion": "1.0.0", "name": "azure", "disableCheck": true}`))
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0", nwCfg.CNIVersion)
+	require.True(t, nwCfg.DisableCheck)
+
+	nwCfg, err = ParseNetworkConfig([]byte(`{"name": "azure"}`))
+	require.NoError(t, err)
+	require.Equal(t, defaultVersion, nwCfg.CNIVersion)
+	require.False(t, nwCfg.DisableCheck)
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -70,6 +71,17 @@ var args = common.ArgumentList{
 			common.OptLogTargetFile:   log.TargetLogfile,
 		},
 	},
+	{
+		Name:         common.OptLogFormat,
+		Shorthand:    common.OptLogFormatAlias,
+		Description:  "Set the logging format",
+		Type:         "int",
+		DefaultValue: common.OptLogFormatText,
+		ValueMap: map[string]interface{}{
+			common.OptLogFormatText: log.FormatText,
+			common.OptLogFormatJSON: log.FormatJSON,
+		},
+	},
 	{
 		Name:         common.OptLogLocation,
 		Shorthand:    common.OptLogLocationAlias,
@@ -122,6 +134,7 @@ func main() {
 	url := common.GetArg(common.OptAPIServerURL).(string)
 	logLevel := common.GetArg(common.OptLogLevel).(int)
 	logTarget := common.GetArg(common.OptLogTarget).(int)
+	logFormat := common.GetArg(common.OptLogFormat).(int)
 	ipamQueryUrl, _ := common.GetArg(common.OptIpamQueryUrl).(string)
 	ipamQueryInterval, _ := common.GetArg(common.OptIpamQueryInterval).(int)
 	vers := common.GetArg(common.OptVersion).(bool)
@@ -183,6 +196,13 @@ func main() {
 		return
 	}
 
+	err = log.SetFormat(logFormat)
+	if err != nil {
+		fmt.Printf("Failed to configure logging: %v\n", err)
+		return
+	}
+	log.ReopenOnSignal(context.Background())
+
 	// Log platform information.
 	log.Printf("Running on %v", platform.GetOSInfo())
 	common.LogNetworkInterfaces()
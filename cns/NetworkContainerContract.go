@@ -6,6 +6,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/pkg/errors"
@@ -16,6 +17,8 @@ import (
 const (
 	SetOrchestratorType                      = "/network/setorchestratortype"
 	GetHomeAz                                = "/homeaz"
+	WireguardPeers                           = "/network/wireguard/peers"
+	EndpointPath                             = "/network/endpoints/"
 	CreateOrUpdateNetworkContainer           = "/network/createorupdatenetworkcontainer"
 	DeleteNetworkContainer                   = "/network/deletenetworkcontainer"
 	PublishNetworkContainer                  = "/network/publishnetworkcontainer"
@@ -23,13 +26,21 @@ const (
 	GetInterfaceForContainer                 = "/network/getinterfaceforcontainer"
 	GetNetworkContainerByOrchestratorContext = "/network/getnetworkcontainerbyorchestratorcontext"
 	NetworkContainersURLPath                 = "/network/networkcontainers"
+	NCProgrammingStatusPath                  = "/network/ncprogrammingstatus"
 	AttachContainerToNetwork                 = "/network/attachcontainertonetwork"
 	DetachContainerFromNetwork               = "/network/detachcontainerfromnetwork"
 	RequestIPConfig                          = "/network/requestipconfig"
 	ReleaseIPConfig                          = "/network/releaseipconfig"
+	QuarantineIPs                            = "/network/quarantineips"
+	ReleaseQuarantinedIPs                    = "/network/releasequarantinedips"
+	ReserveHostPorts                         = "/network/reservehostports"
+	ReleaseHostPorts                         = "/network/releasehostports"
 	PathDebugIPAddresses                     = "/debug/ipaddresses"
 	PathDebugPodContext                      = "/debug/podcontext"
 	PathDebugRestData                        = "/debug/restdata"
+	PathDebugPodIPAssignments                = "/debug/podipassignments"
+	PathDebugQuarantinedIPs                  = "/debug/quarantinedips"
+	PathDebugHostPorts                       = "/debug/hostports"
 	NumberOfCPUCores                         = NumberOfCPUCoresPath
 	NMAgentSupportedAPIs                     = NmAgentSupportedApisPath
 )
@@ -394,6 +405,8 @@ type PodIpInfo struct {
 	PodIPConfig                     IPSubnet
 	NetworkContainerPrimaryIPConfig IPConfiguration
 	HostPrimaryIPInfo               HostIPInfo
+	// NetworkContainerID is the NC of the IP, empty when returned by a CNS that predates it.
+	NetworkContainerID string
 }
 
 type HostIPInfo struct {
@@ -445,6 +458,142 @@ type GetPodContextResponse struct {
 	Response   Response
 }
 
+// GetPodIPAssignmentsRequest is used in CNS debug mode to get the assignments of IPs to pods. Only the IPs matching
+// all the set filters are returned, ordered by IP address. When Limit is set, at most Limit IPs are returned and the
+// next page is requested by passing the Continue token of the response.
+type GetPodIPAssignmentsRequest struct {
+	PodNamespace        string
+	PodName             string
+	InfraContainerID    string
+	NCID                string
+	IPConfigStateFilter []types.IPState
+	Limit               int
+	Continue            string
+}
+
+// PodIPAssignment is the assignment of an IP to a pod. The pod fields are empty when the IP isn't assigned.
+type PodIPAssignment struct {
+	PodNamespace        string
+	PodName             string
+	InfraContainerID    string
+	PodInterfaceID      string
+	IPAddress           string
+	IPConfigID          string
+	NCID                string
+	State               types.IPState
+	LastStateTransition time.Time
+}
+
+// GetPodIPAssignmentsResponse is used in CNS debug mode as a response to get the assignments of IPs to pods.
+// Continue is empty on the last page.
+type GetPodIPAssignmentsResponse struct {
+	PodIPAssignments []PodIPAssignment
+	Continue         string
+	Response         Response
+}
+
+// NCProgrammingState is whether the NIC of the Node has been programmed with the version of a network container.
+type NCProgrammingState string
+
+const (
+	// NCProgrammingPending is the state of an NC whose version is not programmed yet, the traffic of its new IPs is
+	// dropped until it is.
+	NCProgrammingPending NCProgrammingState = "Pending"
+	// NCProgrammingSucceeded is the state of an NC whose version is programmed.
+	NCProgrammingSucceeded NCProgrammingState = "Succeeded"
+	// NCProgrammingUnknown is the state of an NC whose versions can't be compared.
+	NCProgrammingUnknown NCProgrammingState = "Unknown"
+)
+
+// GetNCProgrammingStatusRequest requests the programming status of the NCs, or of all of them if
+// NetworkContainerIDs is empty.
+type GetNCProgrammingStatusRequest struct {
+	NetworkContainerIDs []string
+}
+
+// NCProgrammingStatus is the programming status of an NC: Version is the version of the NC in CNS and
+// ProgrammedVersion the version programmed on the NIC, which is -1 until NMAgent has reported one.
+type NCProgrammingStatus struct {
+	NetworkContainerID string
+	Version            string
+	ProgrammedVersion  string
+	State              NCProgrammingState
+}
+
+// GetNCProgrammingStatusResponse is the response to a GetNCProgrammingStatusRequest.
+type GetNCProgrammingStatusResponse struct {
+	NCProgrammingStatuses []NCProgrammingStatus
+	Response              Response
+}
+
+// QuarantineIPsRequest quarantines secondary IPs, e.g. after a duplicate address was detected on them or programming
+// them failed, so that CNS doesn't assign them to Pods until they are released. An IP assigned to a Pod is quarantined
+// once the Pod releases it.
+type QuarantineIPsRequest struct {
+	IPAddresses []string
+	Reason      string
+}
+
+// ReleaseQuarantinedIPsRequest releases the quarantined IPs with the addresses, or all of them if All is set, so
+// that CNS assigns them to Pods again.
+type ReleaseQuarantinedIPsRequest struct {
+	IPAddresses []string
+	All         bool
+}
+
+// QuarantinedIP is an IP which CNS doesn't assign to Pods. State is the state of the IP, which is Quarantined unless
+// the IP is still assigned to a Pod or is pending release.
+type QuarantinedIP struct {
+	IPAddress  string
+	IPConfigID string
+	NCID       string
+	State      types.IPState
+	Reason     string
+	Since      time.Time
+}
+
+// QuarantinedIPsResponse is the response of the quarantine APIs, with the IPs quarantined, released or listed.
+type QuarantinedIPsResponse struct {
+	QuarantinedIPs []QuarantinedIP
+	Response       Response
+}
+
+// HostPort is a port of the Node mapped to a Pod by the hostPort of one of its containers. An empty HostIP, or an
+// unspecified one, is every address of the Node. An empty Protocol is TCP.
+type HostPort struct {
+	HostIP   string
+	Port     int
+	Protocol string
+}
+
+// ReserveHostPortsRequest reserves the host ports of a Pod interface, replacing those it reserved before. None is
+// reserved if any of them is reserved by another Pod interface.
+type ReserveHostPortsRequest struct {
+	PodInterfaceID   string
+	InfraContainerID string
+	HostPorts        []HostPort
+}
+
+// ReleaseHostPortsRequest releases the host ports of a Pod interface. The host ports are also released with its IP.
+type ReleaseHostPortsRequest struct {
+	PodInterfaceID string
+}
+
+// HostPortReservation is a host port reserved by a Pod interface.
+type HostPortReservation struct {
+	HostPort
+	PodInterfaceID   string
+	InfraContainerID string
+}
+
+// HostPortsResponse is the response of the host port APIs. When a reservation fails with the HostPortConflict return
+// code, HostPorts are the conflicting reservations, otherwise they are the reservations of the Pod interface, or all
+// of them when listed.
+type HostPortsResponse struct {
+	HostPorts []HostPortReservation
+	Response  Response
+}
+
 // IPAddressState Only used in the GetIPConfig API to return IPs that match a filter
 type IPAddressState struct {
 	IPAddress string
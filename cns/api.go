@@ -275,6 +275,7 @@ type IPAMPoolMonitor interface {
 	Start(ctx context.Context) error
 	Update(nnc *v1alpha.NodeNetworkConfig) error
 	GetStateSnapshot() IpamPoolMonitorStateSnapshot
+	Notify()
 }
 
 // IpamPoolMonitorStateSnapshot struct to expose state values for IPAMPoolMonitor struct
@@ -347,3 +348,25 @@ type GetHomeAzResponse struct {
 	Response       Response       `json:"response"`
 	HomeAzResponse HomeAzResponse `json:"homeAzResponse"`
 }
+
+// WireguardPeer is the wireguard identity of a node in the encrypted overlay.
+type WireguardPeer struct {
+	NodeName   string   `json:"nodeName"`
+	PublicKey  string   `json:"publicKey"`
+	Endpoint   string   `json:"endpoint"`   // host:port the node's wireguard interface listens on
+	AllowedIPs []string `json:"allowedIPs"` // pod CIDRs of the node
+}
+
+// PublishWireguardPeerRequest publishes the public key of the node CNS runs on.
+type PublishWireguardPeerRequest struct {
+	PublicKey string `json:"publicKey"`
+}
+
+type PublishWireguardPeerResponse struct {
+	Response Response `json:"response"`
+}
+
+type GetWireguardPeersResponse struct {
+	Response Response        `json:"response"`
+	Peers    []WireguardPeer `json:"peers"`
+}
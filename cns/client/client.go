@@ -33,6 +33,13 @@ var clientPaths = []string{
 	cns.PathDebugIPAddresses,
 	cns.PathDebugPodContext,
 	cns.PathDebugRestData,
+	cns.PathDebugPodIPAssignments,
+	cns.PathDebugQuarantinedIPs,
+	cns.QuarantineIPs,
+	cns.ReleaseQuarantinedIPs,
+	cns.ReserveHostPorts,
+	cns.ReleaseHostPorts,
+	cns.PathDebugHostPorts,
 	cns.UnpublishNetworkContainer,
 	cns.PublishNetworkContainer,
 	cns.CreateOrUpdateNetworkContainer,
@@ -41,7 +48,10 @@ var clientPaths = []string{
 	cns.NMAgentSupportedAPIs,
 	cns.DeleteNetworkContainer,
 	cns.NetworkContainersURLPath,
+	cns.NCProgrammingStatusPath,
 	cns.GetHomeAz,
+	cns.WireguardPeers,
+	cns.EndpointPath,
 }
 
 type do interface {
@@ -269,7 +279,11 @@ func (c *Client) RequestIPAddress(ctx context.Context, ipconfig cns.IPConfigRequ
 	}
 
 	if response.Response.ReturnCode != 0 {
-		return nil, errors.New(response.Response.Message)
+		err = &CNSClientError{
+			Code: response.Response.ReturnCode,
+			Err:  errors.New(response.Response.Message),
+		}
+		return nil, err
 	}
 
 	return &response, nil
@@ -307,7 +321,10 @@ func (c *Client) ReleaseIPAddress(ctx context.Context, ipconfig cns.IPConfigRequ
 	}
 
 	if resp.ReturnCode != 0 {
-		return errors.New(resp.Message)
+		return &CNSClientError{
+			Code: resp.ReturnCode,
+			Err:  errors.New(resp.Message),
+		}
 	}
 
 	return nil
@@ -387,6 +404,206 @@ func (c *Client) GetPodOrchestratorContext(ctx context.Context) (map[string]stri
 	return resp.PodContext, nil
 }
 
+// GetPodIPAssignments returns the page of the assignments of IPs to pods matching the request.
+func (c *Client) GetPodIPAssignments(ctx context.Context, request cns.GetPodIPAssignmentsRequest) (*cns.GetPodIPAssignmentsResponse, error) { //nolint:gocritic // ignore hugeparam
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(request); err != nil {
+		return nil, errors.Wrap(err, "failed to encode GetPodIPAssignmentsRequest")
+	}
+
+	u := c.routes[cns.PathDebugPodIPAssignments]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.GetPodIPAssignmentsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode GetPodIPAssignmentsResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return &resp, nil
+}
+
+// GetNCProgrammingStatus returns whether the NIC has been programmed with the current version of the NCs, or of all
+// of them if no NC ID is passed.
+func (c *Client) GetNCProgrammingStatus(ctx context.Context, ncIDs ...string) ([]cns.NCProgrammingStatus, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(cns.GetNCProgrammingStatusRequest{NetworkContainerIDs: ncIDs}); err != nil {
+		return nil, errors.Wrap(err, "failed to encode GetNCProgrammingStatusRequest")
+	}
+
+	u := c.routes[cns.NCProgrammingStatusPath]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.GetNCProgrammingStatusResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode GetNCProgrammingStatusResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return resp.NCProgrammingStatuses, nil
+}
+
+// QuarantineIPs quarantines the IPs so that CNS doesn't assign them to Pods until they are released.
+func (c *Client) QuarantineIPs(ctx context.Context, request cns.QuarantineIPsRequest) ([]cns.QuarantinedIP, error) {
+	return c.postQuarantine(ctx, cns.QuarantineIPs, request)
+}
+
+// ReleaseQuarantinedIPs releases the quarantined IPs so that CNS assigns them to Pods again.
+func (c *Client) ReleaseQuarantinedIPs(ctx context.Context, request cns.ReleaseQuarantinedIPsRequest) ([]cns.QuarantinedIP, error) {
+	return c.postQuarantine(ctx, cns.ReleaseQuarantinedIPs, request)
+}
+
+func (c *Client) postQuarantine(ctx context.Context, path string, request any) ([]cns.QuarantinedIP, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(request); err != nil {
+		return nil, errors.Wrap(err, "failed to encode quarantine request")
+	}
+
+	u := c.routes[path]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	return c.doQuarantine(req)
+}
+
+// GetQuarantinedIPs returns the IPs which CNS doesn't assign to Pods because they are quarantined.
+func (c *Client) GetQuarantinedIPs(ctx context.Context) ([]cns.QuarantinedIP, error) {
+	u := c.routes[cns.PathDebugQuarantinedIPs]
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	return c.doQuarantine(req)
+}
+
+func (c *Client) doQuarantine(req *http.Request) ([]cns.QuarantinedIP, error) {
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.QuarantinedIPsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode QuarantinedIPsResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return resp.QuarantinedIPs, nil
+}
+
+// ReserveHostPorts reserves the host ports of a Pod interface. When they conflict with the host ports of another Pod
+// interface, it returns the conflicting reservations with an error for which IsHostPortConflict is true.
+func (c *Client) ReserveHostPorts(ctx context.Context, request cns.ReserveHostPortsRequest) ([]cns.HostPortReservation, error) {
+	return c.postHostPorts(ctx, cns.ReserveHostPorts, request)
+}
+
+// ReleaseHostPorts releases the host ports of a Pod interface and returns them.
+func (c *Client) ReleaseHostPorts(ctx context.Context, request cns.ReleaseHostPortsRequest) ([]cns.HostPortReservation, error) {
+	return c.postHostPorts(ctx, cns.ReleaseHostPorts, request)
+}
+
+func (c *Client) postHostPorts(ctx context.Context, path string, request any) ([]cns.HostPortReservation, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(request); err != nil {
+		return nil, errors.Wrap(err, "failed to encode host ports request")
+	}
+
+	u := c.routes[path]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	return c.doHostPorts(req)
+}
+
+// GetHostPorts returns the host ports reserved by the Pod interfaces.
+func (c *Client) GetHostPorts(ctx context.Context) ([]cns.HostPortReservation, error) {
+	u := c.routes[cns.PathDebugHostPorts]
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	return c.doHostPorts(req)
+}
+
+func (c *Client) doHostPorts(req *http.Request) ([]cns.HostPortReservation, error) {
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.HostPortsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode HostPortsResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return resp.HostPorts, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return resp.HostPorts, nil
+}
+
 // GetHTTPServiceData gets all public in-memory struct details for debugging purpose
 func (c *Client) GetHTTPServiceData(ctx context.Context) (*restserver.GetHTTPServiceDataResponse, error) {
 	u := c.routes[cns.PathDebugRestData]
@@ -847,3 +1064,142 @@ func (c *Client) GetHomeAz(ctx context.Context) (*cns.GetHomeAzResponse, error)
 
 	return &getHomeAzResponse, nil
 }
+
+// PublishWireguardPublicKey publishes the wireguard public key of the local node so that the other
+// nodes of the overlay can add it as a peer. Publishing again replaces the previous key, which is how
+// rotated keys are distributed.
+func (c *Client) PublishWireguardPublicKey(ctx context.Context, publicKey string) error {
+	if publicKey == "" {
+		return errors.New("public key missing from request")
+	}
+
+	body, err := json.Marshal(cns.PublishWireguardPeerRequest{PublicKey: publicKey})
+	if err != nil {
+		return errors.Wrap(err, "encoding request body as json")
+	}
+	u := c.routes[cns.WireguardPeers]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "building HTTP request")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending HTTP request")
+	}
+	defer resp.Body.Close()
+
+	var out cns.PublishWireguardPeerResponse
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return errors.Wrap(err, "decoding JSON response")
+	}
+
+	if out.Response.ReturnCode != 0 {
+		return &CNSClientError{
+			Code: out.Response.ReturnCode,
+			Err:  errors.New(out.Response.Message),
+		}
+	}
+
+	return nil
+}
+
+// GetWireguardPeers returns the wireguard peers of all nodes of the cluster, the local node included.
+func (c *Client) GetWireguardPeers(ctx context.Context) ([]cns.WireguardPeer, error) {
+	u := c.routes[cns.WireguardPeers]
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "building http request")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "sending HTTP request")
+	}
+	defer resp.Body.Close()
+
+	var out cns.GetWireguardPeersResponse
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding response as JSON")
+	}
+
+	if out.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: out.Response.ReturnCode,
+			Err:  errors.New(out.Response.Message),
+		}
+	}
+
+	return out.Peers, nil
+}
+
+// GetEndpoint returns the state CNS keeps for the endpoint of the infra container, which is how the CNI finds its
+// endpoints in stateless mode.
+func (c *Client) GetEndpoint(ctx context.Context, endpointID string) (*restserver.GetEndpointResponse, error) {
+	u := c.routes[cns.EndpointPath]
+	u.Path += endpointID
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "building http request")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "sending HTTP request")
+	}
+	defer resp.Body.Close()
+
+	var out restserver.GetEndpointResponse
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding response as JSON")
+	}
+
+	if out.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: out.Response.ReturnCode,
+			Err:  errors.New(out.Response.Message),
+		}
+	}
+
+	return &out, nil
+}
+
+// UpdateEndpoint records the host side of the interfaces of the endpoint of the infra container, keyed by the
+// interface name in the container.
+func (c *Client) UpdateEndpoint(ctx context.Context, endpointID string, ifnameToIPMap map[string]*restserver.IPInfo) error {
+	body, err := json.Marshal(ifnameToIPMap)
+	if err != nil {
+		return errors.Wrap(err, "encoding request body as json")
+	}
+	u := c.routes[cns.EndpointPath]
+	u.Path += endpointID
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "building HTTP request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending HTTP request")
+	}
+	defer resp.Body.Close()
+
+	var out cns.Response
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return errors.Wrap(err, "decoding JSON response")
+	}
+
+	if out.ReturnCode != 0 {
+		return &CNSClientError{
+			Code: out.ReturnCode,
+			Err:  errors.New(out.Message),
+		}
+	}
+
+	return nil
+}
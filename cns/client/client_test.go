@@ -1910,6 +1910,91 @@ func TestGetPodOrchestratorContext(t *testing.T) {
 	}
 }
 
+func TestGetPodIPAssignments(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	assignments := &cns.GetPodIPAssignmentsResponse{
+		PodIPAssignments: []cns.PodIPAssignment{{PodName: "testpod", PodNamespace: "testns", IPAddress: "10.0.0.5", State: types.Assigned}},
+		Continue:         "10.0.0.5",
+	}
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		mockdo  *mockdo
+		routes  map[string]url.URL
+		want    *cns.GetPodIPAssignmentsResponse
+		wantErr bool
+	}{
+		{
+			name: "happy case",
+			ctx:  context.TODO(),
+			mockdo: &mockdo{
+				errToReturn:            nil,
+				objToReturn:            assignments,
+				httpStatusCodeToReturn: http.StatusOK,
+			},
+			routes:  emptyRoutes,
+			want:    assignments,
+			wantErr: false,
+		},
+		{
+			name: "bad request",
+			ctx:  context.TODO(),
+			mockdo: &mockdo{
+				errToReturn:            errBadRequest,
+				objToReturn:            nil,
+				httpStatusCodeToReturn: http.StatusBadRequest,
+			},
+			routes:  emptyRoutes,
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "http status not ok",
+			ctx:  context.TODO(),
+			mockdo: &mockdo{
+				errToReturn:            nil,
+				objToReturn:            nil,
+				httpStatusCodeToReturn: http.StatusInternalServerError,
+			},
+			routes:  emptyRoutes,
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "cns return code not zero",
+			ctx:  context.TODO(),
+			mockdo: &mockdo{
+				errToReturn: nil,
+				objToReturn: &cns.GetPodIPAssignmentsResponse{
+					Response: cns.Response{
+						ReturnCode: types.InvalidParameter,
+					},
+				},
+				httpStatusCodeToReturn: http.StatusOK,
+			},
+			routes:  emptyRoutes,
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{
+				client: tt.mockdo,
+				routes: tt.routes,
+			}
+			got, err := client.GetPodIPAssignments(tt.ctx, cns.GetPodIPAssignmentsRequest{Limit: 1})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestGetHTTPServiceData(t *testing.T) {
 	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
 	tests := []struct {
@@ -2323,3 +2408,162 @@ func TestGetHomeAz(t *testing.T) {
 		})
 	}
 }
+
+func TestGetWireguardPeers(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	peers := []cns.WireguardPeer{
+		{
+			NodeName:   "node-1",
+			PublicKey:  "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=",
+			Endpoint:   "10.240.0.4:51820",
+			AllowedIPs: []string{"10.244.1.0/24"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		shouldErr bool
+		resp      *cns.GetWireguardPeersResponse
+	}{
+		{
+			"happy path",
+			false,
+			&cns.GetWireguardPeersResponse{
+				Response: cns.Response{ReturnCode: 0},
+				Peers:    peers,
+			},
+		},
+		{
+			"error",
+			true,
+			&cns.GetWireguardPeersResponse{
+				Response: cns.Response{
+					ReturnCode: types.UnexpectedError,
+					Message:    "unexpected error",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &Client{
+				client: &mockdo{
+					objToReturn:            test.resp,
+					httpStatusCodeToReturn: http.StatusOK,
+				},
+				routes: emptyRoutes,
+			}
+
+			got, err := client.GetWireguardPeers(context.Background())
+			if test.shouldErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, peers, got)
+		})
+	}
+}
+
+func TestPublishWireguardPublicKey(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	client := &Client{
+		client: &mockdo{
+			objToReturn:            &cns.PublishWireguardPeerResponse{},
+			httpStatusCodeToReturn: http.StatusOK,
+		},
+		routes: emptyRoutes,
+	}
+
+	err := client.PublishWireguardPublicKey(context.Background(), "")
+	require.Error(t, err, "publishing an empty public key should fail")
+
+	err = client.PublishWireguardPublicKey(context.Background(), "key")
+	require.NoError(t, err)
+}
+
+func TestGetEndpoint(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	endpointInfo := restserver.EndpointInfo{
+		PodName:      "pod",
+		PodNamespace: "default",
+		IfnameToIPMap: map[string]*restserver.IPInfo{
+			"eth0": {
+				IPv4:         []net.IPNet{{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}},
+				HostVethName: "azv1234567",
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		shouldErr bool
+		resp      *restserver.GetEndpointResponse
+	}{
+		{
+			"happy path",
+			false,
+			&restserver.GetEndpointResponse{
+				Response:     restserver.Response{ReturnCode: types.Success},
+				EndpointInfo: endpointInfo,
+			},
+		},
+		{
+			"not found",
+			true,
+			&restserver.GetEndpointResponse{
+				Response: restserver.Response{
+					ReturnCode: types.NotFound,
+					Message:    "endpoint state not found",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &Client{
+				client: &mockdo{
+					objToReturn:            test.resp,
+					httpStatusCodeToReturn: http.StatusOK,
+				},
+				routes: emptyRoutes,
+			}
+
+			got, err := client.GetEndpoint(context.Background(), "container")
+			if test.shouldErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, endpointInfo, got.EndpointInfo)
+		})
+	}
+}
+
+func TestUpdateEndpoint(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	ifnameToIPMap := map[string]*restserver.IPInfo{"eth0": {HostVethName: "azv1234567"}}
+
+	client := &Client{
+		client: &mockdo{
+			objToReturn:            &cns.Response{ReturnCode: types.Success},
+			httpStatusCodeToReturn: http.StatusOK,
+		},
+		routes: emptyRoutes,
+	}
+	require.NoError(t, client.UpdateEndpoint(context.Background(), "container", ifnameToIPMap))
+
+	client.client = &mockdo{
+		objToReturn:            &cns.Response{ReturnCode: types.NotFound, Message: "endpoint state not found"},
+		httpStatusCodeToReturn: http.StatusOK,
+	}
+	require.Error(t, client.UpdateEndpoint(context.Background(), "container", ifnameToIPMap))
+}
@@ -28,9 +28,27 @@ func (e *CNSClientError) Error() string {
 	return fmt.Sprintf("[Azure cnsclient] Code: %d , Error: %v", e.Code, e.Err)
 }
 
+func (e *CNSClientError) Unwrap() error {
+	return e.Err
+}
+
 // IsNotFound tests if the provided error is of type CNSClientError and then
 // further tests if the error code is of type UnknowContainerID
 func IsNotFound(err error) bool {
 	e := &CNSClientError{}
 	return errors.As(err, &e) && (e.Code == types.UnknownContainerID)
 }
+
+// IsDraining tests if the provided error is of type CNSClientError and then
+// further tests if the error code is of type ServiceDraining
+func IsDraining(err error) bool {
+	e := &CNSClientError{}
+	return errors.As(err, &e) && (e.Code == types.ServiceDraining)
+}
+
+// IsHostPortConflict tests if the provided error is of type CNSClientError and then
+// further tests if the error code is of type HostPortConflict
+func IsHostPortConflict(err error) bool {
+	e := &CNSClientError{}
+	return errors.As(err, &e) && (e.Code == types.HostPortConflict)
+}
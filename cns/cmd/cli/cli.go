@@ -6,6 +6,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/client"
@@ -13,11 +14,17 @@ import (
 )
 
 const (
-	envCNSIPAddress = "CNSIpAddress"
-	envCNSPort      = "CNSPort"
-	getCmdArg       = "get"
-	getInMemoryData = "getInMemory"
-	getPodCmdArg    = "getPodContexts"
+	envCNSIPAddress          = "CNSIpAddress"
+	envCNSPort               = "CNSPort"
+	getCmdArg                = "get"
+	getInMemoryData          = "getInMemory"
+	getPodCmdArg             = "getPodContexts"
+	getPodIPsCmdArg          = "getPodIPs"
+	getQuarantinedIPsCmdArg  = "getQuarantinedIPs"
+	getHostPortsCmdArg       = "getHostPorts"
+	releaseQuarantinedCmdArg = "releaseQuarantinedIPs"
+	releaseAllArg            = "all"
+	podIPsPageSize           = 100
 )
 
 func HandleCNSClientCommands(ctx context.Context, cmd string, arg string) error {
@@ -36,6 +43,14 @@ func HandleCNSClientCommands(ctx context.Context, cmd string, arg string) error
 		return getPodCmd(ctx, cnsClient)
 	case strings.EqualFold(getInMemoryData, cmd):
 		return getInMemory(ctx, cnsClient)
+	case strings.EqualFold(getPodIPsCmdArg, cmd):
+		return getPodIPsCmd(ctx, cnsClient, arg)
+	case strings.EqualFold(getQuarantinedIPsCmdArg, cmd):
+		return getQuarantinedIPsCmd(ctx, cnsClient)
+	case strings.EqualFold(releaseQuarantinedCmdArg, cmd):
+		return releaseQuarantinedIPsCmd(ctx, cnsClient, arg)
+	case strings.EqualFold(getHostPortsCmdArg, cmd):
+		return getHostPortsCmd(ctx, cnsClient)
 	default:
 		return fmt.Errorf("No debug cmd supplied, options are: %v", getCmdArg)
 	}
@@ -53,8 +68,10 @@ func getCmd(ctx context.Context, client *client.Client, arg string) error {
 		states = append(states, types.PendingProgramming)
 	case types.PendingRelease:
 		states = append(states, types.PendingRelease)
+	case types.Quarantined:
+		states = append(states, types.Quarantined)
 	default:
-		states = append(states, types.Assigned, types.Available, types.PendingProgramming, types.PendingRelease)
+		states = append(states, types.Assigned, types.Available, types.PendingProgramming, types.PendingRelease, types.Quarantined)
 	}
 
 	addr, err := client.GetIPAddressesMatchingStates(ctx, states...)
@@ -90,6 +107,85 @@ func getPodCmd(ctx context.Context, client *client.Client) error {
 	return nil
 }
 
+// getPodIPsCmd prints the IPs assigned to pods, optionally only those of a namespace, page by page.
+func getPodIPsCmd(ctx context.Context, client *client.Client, namespace string) error {
+	req := cns.GetPodIPAssignmentsRequest{
+		PodNamespace:        namespace,
+		IPConfigStateFilter: []types.IPState{types.Assigned},
+		Limit:               podIPsPageSize,
+	}
+	for {
+		resp, err := client.GetPodIPAssignments(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		for i := range resp.PodIPAssignments {
+			a := &resp.PodIPAssignments[i]
+			fmt.Printf("%s/%s %s %s %s %s %s\n", a.PodNamespace, a.PodName, a.InfraContainerID, a.IPAddress, a.NCID, a.State,
+				a.LastStateTransition.Format(time.RFC3339))
+		}
+
+		if resp.Continue == "" {
+			return nil
+		}
+		req.Continue = resp.Continue
+	}
+}
+
+func getQuarantinedIPsCmd(ctx context.Context, client *client.Client) error {
+	ips, err := client.GetQuarantinedIPs(ctx)
+	if err != nil {
+		return err
+	}
+
+	printQuarantinedIPs(ips)
+	return nil
+}
+
+// releaseQuarantinedIPsCmd releases the quarantined IP passed, or all of them if "all" is passed.
+func releaseQuarantinedIPsCmd(ctx context.Context, client *client.Client, arg string) error {
+	if arg == "" {
+		return fmt.Errorf("%s takes the IP address to release, or %s", releaseQuarantinedCmdArg, releaseAllArg)
+	}
+
+	req := cns.ReleaseQuarantinedIPsRequest{All: strings.EqualFold(releaseAllArg, arg)}
+	if !req.All {
+		req.IPAddresses = []string{arg}
+	}
+	ips, err := client.ReleaseQuarantinedIPs(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	printQuarantinedIPs(ips)
+	return nil
+}
+
+func printQuarantinedIPs(ips []cns.QuarantinedIP) {
+	for i := range ips {
+		ip := &ips[i]
+		fmt.Printf("%s %s %s %s %q\n", ip.IPAddress, ip.NCID, ip.State, ip.Since.Format(time.RFC3339), ip.Reason)
+	}
+}
+
+func getHostPortsCmd(ctx context.Context, client *client.Client) error {
+	reservations, err := client.GetHostPorts(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range reservations {
+		r := &reservations[i]
+		hostIP := r.HostIP
+		if hostIP == "" {
+			hostIP = "*"
+		}
+		fmt.Printf("%s:%d/%s %s %s\n", hostIP, r.Port, r.Protocol, r.PodInterfaceID, r.InfraContainerID)
+	}
+	return nil
+}
+
 func getInMemory(ctx context.Context, client *client.Client) error {
 	data, err := client.GetHTTPServiceData(ctx)
 	if err != nil {
@@ -0,0 +1,92 @@
+package cnireconciler
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/Azure/azure-container-networking/cni/client"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/restserver"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/pkg/errors"
+	"k8s.io/utils/exec"
+)
+
+// MigrateCNIState adds the endpoints of the CNI statefile to the endpoint state store of CNS, so that a node which
+// ran the CNI with its own statefile can switch to the stateless CNI. Endpoints already in the store are kept as
+// they are.
+func MigrateCNIState(endpointStore store.KeyValueStore) error {
+	return migrateCNIState(exec.New(), endpointStore)
+}
+
+func migrateCNIState(exec exec.Interface, endpointStore store.KeyValueStore) error {
+	cli := client.New(exec)
+	cniState, err := cli.GetEndpointState()
+	if err != nil {
+		return errors.Wrap(err, "failed to invoke CNI client.GetEndpointState()")
+	}
+
+	var state map[string]*restserver.EndpointInfo
+	err = endpointStore.Read(restserver.EndpointStoreKey, &state)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) && !errors.Is(err, store.ErrStoreEmpty) {
+		return errors.Wrap(err, "failed to read endpoints state from store")
+	}
+	if state == nil {
+		state = make(map[string]*restserver.EndpointInfo)
+	}
+
+	migrated := cniStateToEndpointState(cniState, state)
+	if migrated == 0 {
+		logger.Printf("[cnireconciler] No endpoint to migrate from the CNI statefile")
+		return nil
+	}
+
+	if err = endpointStore.Write(restserver.EndpointStoreKey, state); err != nil {
+		return errors.Wrap(err, "failed to write endpoints state to store")
+	}
+
+	logger.Printf("[cnireconciler] Migrated %d endpoints from the CNI statefile", migrated)
+	return nil
+}
+
+// cniStateToEndpointState adds the endpoints of the CNI state which are missing from the endpoint state, keyed by
+// the infra container like the endpoints added by CNS, and returns the number of endpoints added.
+func cniStateToEndpointState(cniState *api.AzureCNIState, state map[string]*restserver.EndpointInfo) int {
+	migrated := make(map[string]*restserver.EndpointInfo)
+	for _, endpoint := range cniState.ContainerInterfaces {
+		if _, ok := state[endpoint.ContainerID]; ok {
+			continue
+		}
+
+		// The interfaces of a container are separate endpoints in the CNI state.
+		endpointInfo, ok := migrated[endpoint.ContainerID]
+		if !ok {
+			endpointInfo = &restserver.EndpointInfo{
+				PodName:       endpoint.PodName,
+				PodNamespace:  endpoint.PodNamespace,
+				IfnameToIPMap: make(map[string]*restserver.IPInfo),
+			}
+			migrated[endpoint.ContainerID] = endpointInfo
+		}
+
+		ipInfo := &restserver.IPInfo{
+			HostVethName:  endpoint.HostIfName,
+			HnsEndpointID: endpoint.HnsEndpointID,
+		}
+		for _, ipAddress := range endpoint.IPAddresses {
+			ipNet := net.IPNet{IP: ipAddress.IP, Mask: ipAddress.Mask}
+			if ipAddress.IP.To4() != nil {
+				ipInfo.IPv4 = append(ipInfo.IPv4, ipNet)
+			} else {
+				ipInfo.IPv6 = append(ipInfo.IPv6, ipNet)
+			}
+		}
+		endpointInfo.IfnameToIPMap[endpoint.IfName] = ipInfo
+	}
+
+	for containerID, endpointInfo := range migrated {
+		state[containerID] = endpointInfo
+	}
+
+	return len(migrated)
+}
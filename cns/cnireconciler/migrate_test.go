@@ -0,0 +1,58 @@
+package cnireconciler
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns/restserver"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateCNIState(t *testing.T) {
+	endpointStore := store.NewMockStore("")
+	existing := map[string]*restserver.EndpointInfo{
+		"6e688597eafb97c83c84e402cc72b299bfb8aeb02021e4c99307a037352c0bed": {
+			PodName:       "tunnelfront-5d96f9b987-65xbn",
+			PodNamespace:  "kube-system",
+			IfnameToIPMap: map[string]*restserver.IPInfo{"eth0": {IPv4: []net.IPNet{{IP: net.IPv4(10, 241, 0, 13), Mask: net.IPv4Mask(255, 255, 0, 0)}}}},
+		},
+	}
+	if err := endpointStore.Write(restserver.EndpointStoreKey, existing); err != nil {
+		t.Fatalf("Error writing to store: %v", err)
+	}
+
+	exec := newCNIStateFakeExec(
+		`{"ContainerInterfaces":{"3f813b02-eth0":{"PodName":"metrics-server-77c8679d7d-6ksdh","PodNamespace":"kube-system","PodEndpointID":"3f813b02-eth0","ContainerID":"3f813b029429b4e41a09ab33b6f6d365d2ed704017524c78d1d0dece33cdaf46","IPAddresses":[{"IP":"10.241.0.17","Mask":"//8AAA=="},{"IP":"fd00::11","Mask":"////////////////AAAAAA=="}],"IfName":"eth0","HostIfName":"azv3f813b02"},"6e688597-eth0":{"PodName":"tunnelfront-5d96f9b987-65xbn","PodNamespace":"kube-system","PodEndpointID":"6e688597-eth0","ContainerID":"6e688597eafb97c83c84e402cc72b299bfb8aeb02021e4c99307a037352c0bed","IPAddresses":[{"IP":"10.241.0.99","Mask":"//8AAA=="}],"IfName":"eth0","HostIfName":"azv6e688597"}}}`,
+	)
+	assert.NoError(t, migrateCNIState(exec, endpointStore))
+
+	var state map[string]*restserver.EndpointInfo
+	assert.NoError(t, endpointStore.Read(restserver.EndpointStoreKey, &state))
+	assert.Len(t, state, 2)
+
+	migrated := state["3f813b029429b4e41a09ab33b6f6d365d2ed704017524c78d1d0dece33cdaf46"]
+	assert.Equal(t, "metrics-server-77c8679d7d-6ksdh", migrated.PodName)
+	assert.Equal(t, "azv3f813b02", migrated.IfnameToIPMap["eth0"].HostVethName)
+	assert.Equal(t, "10.241.0.17", migrated.IfnameToIPMap["eth0"].IPv4[0].IP.String())
+	assert.Equal(t, "fd00::11", migrated.IfnameToIPMap["eth0"].IPv6[0].IP.String())
+
+	// The endpoints known to CNS are not changed.
+	kept := state["6e688597eafb97c83c84e402cc72b299bfb8aeb02021e4c99307a037352c0bed"]
+	assert.Equal(t, "10.241.0.13", kept.IfnameToIPMap["eth0"].IPv4[0].IP.String())
+	assert.Empty(t, kept.IfnameToIPMap["eth0"].HostVethName)
+}
+
+func TestMigrateCNIStateEmptyStore(t *testing.T) {
+	endpointStore := store.NewMockStore("")
+	assert.NoError(t, migrateCNIState(newCNIStateFakeExec(`{}`), endpointStore))
+
+	exec := newCNIStateFakeExec(
+		`{"ContainerInterfaces":{"3f813b02-eth0":{"PodName":"metrics-server-77c8679d7d-6ksdh","PodNamespace":"kube-system","PodEndpointID":"3f813b02-eth0","ContainerID":"3f813b029429b4e41a09ab33b6f6d365d2ed704017524c78d1d0dece33cdaf46","IPAddresses":[{"IP":"10.241.0.17","Mask":"//8AAA=="}],"IfName":"eth0"}}}`,
+	)
+	assert.NoError(t, migrateCNIState(exec, endpointStore))
+
+	var state map[string]*restserver.EndpointInfo
+	assert.NoError(t, endpointStore.Read(restserver.EndpointStoreKey, &state))
+	assert.Len(t, state, 1)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/logger"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/telemetry"
 	"github.com/pkg/errors"
 )
 
@@ -42,10 +43,31 @@ type CNSConfig struct {
 	MSISettings                 MSISettings
 	ProgramSNATIPTables         bool
 	ManageEndpointState         bool
+	MigrateCNIState             bool
 	CNIConflistScenario         string
 	EnableCNIConflistGeneration bool
 	CNIConflistFilepath         string
 	PopulateHomeAzCacheRetryIntervalSecs int
+	IPPoolScalingSettings       IPPoolScalingSettings
+	DrainSettings               DrainSettings
+	LogSettings                 LogSettings
+}
+
+type LogSettings struct {
+	// Rotate the log file when it reaches this size. The log package default is used when 0.
+	MaxFileSizeInMB int
+	// Number of log files kept, the active one included. The log package default is used when 0.
+	MaxFileCount int
+	// Compress the rotated log files with gzip.
+	CompressRotatedFiles bool
+}
+
+type IPPoolScalingSettings struct {
+	// Reconcile the IP pool when IPs are assigned or released instead of polling it.
+	EventDriven bool
+	// Override the request and release watermarks of the NodeNetworkConfig scaler, in percent of the batch size.
+	RequestThresholdPercent int64
+	ReleaseThresholdPercent int64
 }
 
 type TelemetrySettings struct {
@@ -73,6 +95,17 @@ type TelemetrySettings struct {
 	SnapshotIntervalInMins int
 	// AppInsightsInstrumentationKey allows the user to override the default appinsights ikey
 	AppInsightsInstrumentationKey string
+	// OTLP exports the telemetry to an OpenTelemetry collector as well
+	OTLP telemetry.OTLPConfig
+}
+
+type DrainSettings struct {
+	// Stop assigning IPs on SIGTERM and hand off the IPs assigned to Pods to the next CNS process.
+	Enable bool
+	// Maximum time to wait for the in-flight IP requests before handing off.
+	TimeoutInSecs int
+	// Maximum age of a handoff the next CNS process accepts.
+	HandoffMaxAgeInSecs int
 }
 
 type ManagedSettings struct {
@@ -216,6 +249,15 @@ func setManagedSettingDefaults(managedSettings *ManagedSettings) {
 	}
 }
 
+func setDrainSettingsDefaults(ds *DrainSettings) {
+	if ds.TimeoutInSecs == 0 {
+		ds.TimeoutInSecs = 10 //nolint:gomnd // default times
+	}
+	if ds.HandoffMaxAgeInSecs == 0 {
+		ds.HandoffMaxAgeInSecs = 300 //nolint:gomnd // default times
+	}
+}
+
 func setKeyVaultSettingsDefaults(kvs *KeyVaultSettings) {
 	if kvs.RefreshIntervalInHrs == 0 {
 		kvs.RefreshIntervalInHrs = 12 //nolint:gomnd // default times
@@ -227,6 +269,7 @@ func SetCNSConfigDefaults(config *CNSConfig) {
 	setTelemetrySettingDefaults(&config.TelemetrySettings)
 	setManagedSettingDefaults(&config.ManagedSettings)
 	setKeyVaultSettingsDefaults(&config.KeyVaultSettings)
+	setDrainSettingsDefaults(&config.DrainSettings)
 
 	if config.ChannelMode == "" {
 		config.ChannelMode = cns.Direct
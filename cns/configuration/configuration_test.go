@@ -208,6 +208,10 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 					RefreshIntervalInHrs: 12,
 				},
 				PopulateHomeAzCacheRetryIntervalSecs: 15,
+				DrainSettings: DrainSettings{
+					TimeoutInSecs:       10,
+					HandoffMaxAgeInSecs: 300,
+				},
 			},
 		},
 		{
@@ -231,6 +235,10 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 					RefreshIntervalInHrs: 3,
 				},
 				PopulateHomeAzCacheRetryIntervalSecs: 10,
+				DrainSettings: DrainSettings{
+					TimeoutInSecs:       1,
+					HandoffMaxAgeInSecs: 60,
+				},
 			},
 			want: CNSConfig{
 				ChannelMode: "Other",
@@ -251,6 +259,10 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 					RefreshIntervalInHrs: 3,
 				},
 				PopulateHomeAzCacheRetryIntervalSecs: 10,
+				DrainSettings: DrainSettings{
+					TimeoutInSecs:       1,
+					HandoffMaxAgeInSecs: 60,
+				},
 			},
 		},
 	}
@@ -16,6 +16,8 @@ var (
 	StatePendingProgramming = ipConfigStatePredicate(types.PendingProgramming)
 	// StatePendingRelease is a preset filter for types.PendingRelease.
 	StatePendingRelease = ipConfigStatePredicate(types.PendingRelease)
+	// StateQuarantined is a preset filter for types.Quarantined.
+	StateQuarantined = ipConfigStatePredicate(types.Quarantined)
 )
 
 var filters = map[types.IPState]IPConfigStatePredicate{
@@ -23,6 +25,7 @@ var filters = map[types.IPState]IPConfigStatePredicate{
 	types.Available:          StateAvailable,
 	types.PendingProgramming: StatePendingProgramming,
 	types.PendingRelease:     StatePendingRelease,
+	types.Quarantined:        StateQuarantined,
 }
 
 // ipConfigStatePredicate returns a predicate function that compares an IPConfigurationStatus.State to
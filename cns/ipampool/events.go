@@ -0,0 +1,96 @@
+package ipampool
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Events the Monitor emits on its Node.
+const (
+	ReasonPoolIncreased    = "IPPoolIncreased"
+	ReasonPoolDecreased    = "IPPoolDecreased"
+	ReasonPoolAtMax        = "IPPoolAtMaxIPs"
+	ReasonSubnetExhausted  = "SubnetIPsExhausted"
+	ReasonPoolUpdateFailed = "IPPoolUpdateFailed"
+)
+
+// poolEvents emits the Events of the pool on the Node. The warnings are only emitted when their condition starts, so
+// that a pool stuck at its max or a failing API server doesn't flood the Node with Events. A nil poolEvents does
+// nothing.
+type poolEvents struct {
+	recorder record.EventRecorder
+	node     *corev1.ObjectReference
+	// atMax, exhausted and updateFailing are the conditions warned about last.
+	atMax         bool
+	exhausted     bool
+	updateFailing bool
+}
+
+func newPoolEvents(recorder record.EventRecorder, nodeName string) *poolEvents {
+	if recorder == nil {
+		return nil
+	}
+	return &poolEvents{
+		recorder: recorder,
+		// Node Events are referenced by the name of the Node, as the kubelet does.
+		node: &corev1.ObjectReference{Kind: "Node", Name: nodeName, UID: k8stypes.UID(nodeName)},
+	}
+}
+
+func (e *poolEvents) increased(previous, requested int64, state ipPoolState) {
+	if e == nil {
+		return
+	}
+	e.recorder.Eventf(e.node, corev1.EventTypeNormal, ReasonPoolIncreased,
+		"Requested %d IPs, up from %d, with %d IPs allocated to Pods", requested, previous, state.allocatedToPods)
+}
+
+func (e *poolEvents) decreased(previous, requested int64, state ipPoolState) {
+	if e == nil {
+		return
+	}
+	e.recorder.Eventf(e.node, corev1.EventTypeNormal, ReasonPoolDecreased,
+		"Requested %d IPs, down from %d, with %d IPs allocated to Pods", requested, previous, state.allocatedToPods)
+}
+
+// poolAtMax warns when the pool needs more IPs than its max, after which new Pods may fail to get an IP.
+func (e *poolEvents) poolAtMax(atMax bool, state ipPoolState, meta metaState) {
+	if e == nil || atMax == e.atMax {
+		return
+	}
+	e.atMax = atMax
+	if atMax {
+		e.recorder.Eventf(e.node, corev1.EventTypeWarning, ReasonPoolAtMax,
+			"The IP pool is at its max of %d IPs with %d IPs allocated to Pods, new Pods may fail to get an IP",
+			meta.max, state.allocatedToPods)
+	}
+}
+
+// subnetExhausted warns when the subnet of the pool is exhausted, after which the pool scales one IP at a time.
+func (e *poolEvents) subnetExhausted(exhausted bool, meta metaState) {
+	if e == nil || exhausted == e.exhausted {
+		return
+	}
+	e.exhausted = exhausted
+	if exhausted {
+		e.recorder.Eventf(e.node, corev1.EventTypeWarning, ReasonSubnetExhausted,
+			"Subnet %s is exhausted, the IP pool scales one IP at a time", meta.subnet)
+	}
+}
+
+// updateFailed warns when updating the NodeNetworkConfig starts failing.
+func (e *poolEvents) updateFailed(err error) {
+	if e == nil {
+		return
+	}
+	failing := err != nil
+	if failing == e.updateFailing {
+		return
+	}
+	e.updateFailing = failing
+	if failing {
+		e.recorder.Eventf(e.node, corev1.EventTypeWarning, ReasonPoolUpdateFailed,
+			"Failed to update the NodeNetworkConfig, the IP pool can't scale: %v", err)
+	}
+}
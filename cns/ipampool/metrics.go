@@ -14,6 +14,18 @@ const (
 	subnetExhaustionStateLabel = "subnet_exhaustion_state"
 	subnetIPExhausted          = 1
 	subnetIPNotExhausted       = 0
+	triggerLabel               = "trigger"
+	triggerTick                = "tick"
+	triggerPoolEvent           = "pool_event"
+	triggerClusterSubnetState  = "clustersubnetstate"
+	triggerNodeNetworkConfig   = "nodenetworkconfig"
+	operationLabel             = "operation"
+	operationIncrease          = "increase"
+	operationDecrease          = "decrease"
+	operationRelease           = "release"
+	resultLabel                = "result"
+	resultSuccess              = "success"
+	resultFailure              = "failure"
 )
 
 var (
@@ -81,6 +93,21 @@ var (
 		},
 		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel},
 	)
+	ipamPendingRequestIPCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cx_ipam_pending_request_ips",
+			Help:        "Count of IPs CNS has requested that DNC has not allocated yet.",
+			ConstLabels: prometheus.Labels{customerMetricLabel: customerMetricLabelValue},
+		},
+		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel},
+	)
+	ipamPoolReconcileCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cx_ipam_pool_reconcile_total",
+			Help: "Count of the number of times the ipam pool monitor reconciled the pool, by trigger.",
+		},
+		[]string{triggerLabel},
+	)
 	ipamPrimaryIPCount = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cx_ipam_primary_ips",
@@ -89,6 +116,14 @@ var (
 		},
 		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel},
 	)
+	ipamQuarantinedIPCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cx_ipam_quarantined_ips",
+			Help:        "Count of IPs quarantined, which CNS doesn't assign to Pods.",
+			ConstLabels: prometheus.Labels{customerMetricLabel: customerMetricLabelValue},
+		},
+		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel},
+	)
 	ipamRequestedIPConfigCount = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cx_ipam_requested_ips",
@@ -113,6 +148,14 @@ var (
 		},
 		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel},
 	)
+	ipamPoolUpdateLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cx_ipam_pool_update_latency_seconds",
+			Help:    "Latency of the requests of the ipam pool monitor to update the NodeNetworkConfig, by operation and result.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12), //nolint:gomnd // 10 ms to ~20 seconds
+		},
+		[]string{operationLabel, resultLabel},
+	)
 	ipamSubnetExhaustionCount = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "cx_ipam_subnet_exhaustion_state_count_total",
@@ -132,7 +175,11 @@ func init() {
 		ipamMaxIPCount,
 		ipamPendingProgramIPCount,
 		ipamPendingReleaseIPCount,
+		ipamPendingRequestIPCount,
+		ipamPoolReconcileCount,
+		ipamPoolUpdateLatency,
 		ipamPrimaryIPCount,
+		ipamQuarantinedIPCount,
 		ipamRequestedIPConfigCount,
 		ipamTotalIPCount,
 		ipamSubnetExhaustionState,
@@ -150,7 +197,9 @@ func observeIPPoolState(state ipPoolState, meta metaState) {
 	ipamMaxIPCount.WithLabelValues(labels...).Set(float64(meta.max))
 	ipamPendingProgramIPCount.WithLabelValues(labels...).Set(float64(state.pendingProgramming))
 	ipamPendingReleaseIPCount.WithLabelValues(labels...).Set(float64(state.pendingRelease))
+	ipamPendingRequestIPCount.WithLabelValues(labels...).Set(float64(state.pendingRequest))
 	ipamPrimaryIPCount.WithLabelValues(labels...).Set(float64(len(meta.primaryIPAddresses)))
+	ipamQuarantinedIPCount.WithLabelValues(labels...).Set(float64(state.quarantined))
 	ipamRequestedIPConfigCount.WithLabelValues(labels...).Set(float64(state.requestedIPs))
 	ipamTotalIPCount.WithLabelValues(labels...).Set(float64(state.totalIPs))
 	if meta.exhausted {
@@ -15,6 +15,7 @@ import (
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -47,6 +48,18 @@ type metaState struct {
 type Options struct {
 	RefreshDelay time.Duration
 	MaxIPs       int64
+	// EventDriven reconciles the pool when IPs are assigned or released (through a call to Notify()) and when the
+	// NodeNetworkConfig or ClusterSubnetState changes, instead of once per RefreshDelay. A failed reconcile is
+	// retried after RefreshDelay.
+	EventDriven bool
+	// RequestThresholdPercent and ReleaseThresholdPercent, if set, override the scaler watermarks of the
+	// NodeNetworkConfig as a percent of the batch size.
+	RequestThresholdPercent int64
+	ReleaseThresholdPercent int64
+	// EventRecorder, if set, emits Events on the Node named NodeName when the pool scales, and warnings when it
+	// can't grow past its max IPs, when the subnet is exhausted and when the NodeNetworkConfig can't be updated.
+	EventRecorder record.EventRecorder
+	NodeName      string
 }
 
 type Monitor struct {
@@ -57,6 +70,8 @@ type Monitor struct {
 	httpService cns.HTTPService
 	cssSource   <-chan v1alpha1.ClusterSubnetState
 	nncSource   chan v1alpha.NodeNetworkConfig
+	poolEvents  chan struct{}
+	events      *poolEvents
 	started     chan interface{}
 	once        sync.Once
 }
@@ -74,6 +89,8 @@ func NewMonitor(httpService cns.HTTPService, nnccli nodeNetworkConfigSpecUpdater
 		nnccli:      nnccli,
 		cssSource:   cssSource,
 		nncSource:   make(chan v1alpha.NodeNetworkConfig),
+		poolEvents:  make(chan struct{}, 1),
+		events:      newPoolEvents(opts.EventRecorder, opts.NodeName),
 		started:     make(chan interface{}),
 	}
 }
@@ -81,16 +98,32 @@ func NewMonitor(httpService cns.HTTPService, nnccli nodeNetworkConfigSpecUpdater
 // Start begins the Monitor's pool reconcile loop.
 // On first run, it will block until a NodeNetworkConfig is received (through a call to Update()).
 // Subsequently, it will run run once per RefreshDelay and attempt to re-reconcile the pool.
+// If the Monitor is EventDriven, it will instead re-reconcile the pool when notified of a pool event.
 func (pm *Monitor) Start(ctx context.Context) error {
-	logger.Printf("[ipam-pool-monitor] Starting CNS IPAM Pool Monitor")
-	ticker := time.NewTicker(pm.opts.RefreshDelay)
-	defer ticker.Stop()
+	logger.Printf("[ipam-pool-monitor] Starting CNS IPAM Pool Monitor, event driven = %t", pm.opts.EventDriven)
+	var tick <-chan time.Time // a nil channel never fires, so event driven Monitors don't poll.
+	if !pm.opts.EventDriven {
+		ticker := time.NewTicker(pm.opts.RefreshDelay)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 	for {
+		var trigger string
 		// proceed when things happen:
 		select {
 		case <-ctx.Done(): // calling context has closed, we'll exit.
 			return errors.Wrap(ctx.Err(), "pool monitor context closed")
-		case <-ticker.C: // attempt to reconcile every tick.
+		case <-tick: // attempt to reconcile every tick.
+			trigger = triggerTick
+			select {
+			default:
+				// if we have NOT initialized and enter this case, we continue out of this iteration and let the for loop begin again.
+				continue
+			case <-pm.started: // this blocks until we have initialized
+				// if we have initialized and enter this case, we proceed out of the select and continue to reconcile.
+			}
+		case <-pm.poolEvents: // IPs have been assigned or released, attempt to reconcile.
+			trigger = triggerPoolEvent
 			select {
 			default:
 				// if we have NOT initialized and enter this case, we continue out of this iteration and let the for loop begin again.
@@ -99,8 +132,10 @@ func (pm *Monitor) Start(ctx context.Context) error {
 				// if we have initialized and enter this case, we proceed out of the select and continue to reconcile.
 			}
 		case css := <-pm.cssSource: // received an updated ClusterSubnetState
+			trigger = triggerClusterSubnetState
 			pm.metastate.exhausted = css.Status.Exhausted
 			logger.Printf("subnet exhausted status = %t", pm.metastate.exhausted)
+			pm.events.subnetExhausted(pm.metastate.exhausted, pm.metastate)
 			ipamSubnetExhaustionCount.With(prometheus.Labels{
 				subnetLabel: pm.metastate.subnet, subnetCIDRLabel: pm.metastate.subnetCIDR,
 				podnetARMIDLabel: pm.metastate.subnetARMID, subnetExhaustionStateLabel: strconv.FormatBool(pm.metastate.exhausted),
@@ -113,6 +148,7 @@ func (pm *Monitor) Start(ctx context.Context) error {
 				// if we have initialized and enter this case, we proceed out of the select and continue to reconcile.
 			}
 		case nnc := <-pm.nncSource: // received a new NodeNetworkConfig, extract the data from it and re-reconcile.
+			trigger = triggerNodeNetworkConfig
 			if len(nnc.Status.NetworkContainers) > 0 {
 				// Set SubnetName, SubnetAddressSpace and Pod Network ARM ID values to the global subnet, subnetCIDR and subnetARM variables.
 				pm.metastate.subnet = nnc.Status.NetworkContainers[0].SubnetName
@@ -140,27 +176,46 @@ func (pm *Monitor) Start(ctx context.Context) error {
 			})
 		}
 		// if control has flowed through the select(s) to this point, we can now reconcile.
+		ipamPoolReconcileCount.WithLabelValues(trigger).Inc()
 		err := pm.reconcile(ctx)
 		if err != nil {
 			logger.Printf("[ipam-pool-monitor] Reconcile failed with err %v", err)
+			if pm.opts.EventDriven {
+				// nothing else may happen to the pool for a while, so schedule the retry ourselves.
+				time.AfterFunc(pm.opts.RefreshDelay, pm.Notify)
+			}
 		}
 	}
 }
 
+// Notify signals the Monitor that IPs have been assigned or released, so that an EventDriven Monitor re-reconciles
+// the pool. It does not block: notifications received while a reconcile is already pending are coalesced.
+func (pm *Monitor) Notify() {
+	select {
+	case pm.poolEvents <- struct{}{}:
+	default:
+	}
+}
+
 // ipPoolState is the current actual state of the CNS IP pool.
 type ipPoolState struct {
 	// allocatedToPods are the IPs CNS gives to Pods.
 	allocatedToPods int64
 	// available are the IPs in state "Available".
 	available int64
-	// currentAvailableIPs are the current available IPs: allocated - assigned - pendingRelease.
+	// currentAvailableIPs are the current available IPs: allocated - assigned - pendingRelease - quarantined.
 	currentAvailableIPs int64
-	// expectedAvailableIPs are the "future" available IPs, if the requested IP count is honored: requested - assigned.
+	// expectedAvailableIPs are the "future" available IPs, if the requested IP count is honored:
+	// requested - assigned - quarantined.
 	expectedAvailableIPs int64
 	// pendingProgramming are the IPs in state "PendingProgramming".
 	pendingProgramming int64
 	// pendingRelease are the IPs in state "PendingRelease".
 	pendingRelease int64
+	// pendingRequest are the IPs CNS has requested that DNC has not allocated yet: requested - (total - pendingRelease).
+	pendingRequest int64
+	// quarantined are the IPs in state "Quarantined", which the pool grows to make up for.
+	quarantined int64
 	// requestedIPs are the IPs CNS has requested that it be allocated by DNC.
 	requestedIPs int64
 	// totalIPs are all the IPs given to CNS by DNC.
@@ -183,10 +238,15 @@ func buildIPPoolState(ips map[string]cns.IPConfigurationStatus, spec v1alpha.Nod
 			state.pendingProgramming++
 		case types.PendingRelease:
 			state.pendingRelease++
+		case types.Quarantined:
+			state.quarantined++
 		}
 	}
-	state.currentAvailableIPs = state.totalIPs - state.allocatedToPods - state.pendingRelease
-	state.expectedAvailableIPs = state.requestedIPs - state.allocatedToPods
+	state.currentAvailableIPs = state.totalIPs - state.allocatedToPods - state.pendingRelease - state.quarantined
+	state.expectedAvailableIPs = state.requestedIPs - state.allocatedToPods - state.quarantined
+	if pending := state.requestedIPs - (state.totalIPs - state.pendingRelease); pending > 0 {
+		state.pendingRequest = pending
+	}
 	return state
 }
 
@@ -211,6 +271,8 @@ func (pm *Monitor) reconcile(ctx context.Context) error {
 		meta.maxFreeCount = 2
 	}
 
+	pm.events.poolAtMax(state.expectedAvailableIPs < meta.minFreeCount && state.requestedIPs == meta.max, state, meta)
+
 	switch {
 	// pod count is increasing
 	case state.expectedAvailableIPs < meta.minFreeCount:
@@ -271,12 +333,13 @@ func (pm *Monitor) increasePoolSize(ctx context.Context, meta metaState, state i
 
 	logger.Printf("[ipam-pool-monitor] Increasing pool size, pool %+v, spec %+v", state, tempNNCSpec)
 
-	if _, err := pm.nnccli.UpdateSpec(ctx, &tempNNCSpec); err != nil {
+	if err := pm.updateSpec(ctx, operationIncrease, &tempNNCSpec); err != nil {
 		// caller will retry to update the CRD again
-		return errors.Wrap(err, "executing UpdateSpec with NNC CLI")
+		return err
 	}
 
 	logger.Printf("[ipam-pool-monitor] Increasing pool size: UpdateCRDSpec succeeded for spec %+v", tempNNCSpec)
+	pm.events.increased(previouslyRequestedIPCount, tempNNCSpec.RequestedIPCount, state)
 	// start an alloc timer
 	metric.StartPoolIncreaseTimer(batchSize)
 	// save the updated state to cachedSpec
@@ -334,13 +397,13 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, meta metaState, state i
 	tempNNCSpec.RequestedIPCount -= int64(len(pendingIPAddresses))
 	logger.Printf("[ipam-pool-monitor] Decreasing pool size, pool %+v, spec %+v", state, tempNNCSpec)
 
-	_, err := pm.nnccli.UpdateSpec(ctx, &tempNNCSpec)
-	if err != nil {
+	if err := pm.updateSpec(ctx, operationDecrease, &tempNNCSpec); err != nil {
 		// caller will retry to update the CRD again
-		return errors.Wrap(err, "executing UpdateSpec with NNC CLI")
+		return err
 	}
 
 	logger.Printf("[ipam-pool-monitor] Decreasing pool size: UpdateCRDSpec succeeded for spec %+v", tempNNCSpec)
+	pm.events.decreased(previouslyRequestedIPCount, tempNNCSpec.RequestedIPCount, state)
 	// start a dealloc timer
 	metric.StartPoolDecreaseTimer(batchSize)
 
@@ -359,10 +422,9 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, meta metaState, state i
 func (pm *Monitor) cleanPendingRelease(ctx context.Context) error {
 	tempNNCSpec := pm.createNNCSpecForCRD()
 
-	_, err := pm.nnccli.UpdateSpec(ctx, &tempNNCSpec)
-	if err != nil {
+	if err := pm.updateSpec(ctx, operationRelease, &tempNNCSpec); err != nil {
 		// caller will retry to update the CRD again
-		return errors.Wrap(err, "executing UpdateSpec with NNC CLI")
+		return err
 	}
 
 	logger.Printf("[ipam-pool-monitor] cleanPendingRelease: UpdateCRDSpec succeeded for spec %+v", tempNNCSpec)
@@ -372,6 +434,19 @@ func (pm *Monitor) cleanPendingRelease(ctx context.Context) error {
 	return nil
 }
 
+// updateSpec updates the NodeNetworkConfig with the spec of the operation, observing the latency of the request.
+func (pm *Monitor) updateSpec(ctx context.Context, operation string, spec *v1alpha.NodeNetworkConfigSpec) error {
+	start := time.Now()
+	_, err := pm.nnccli.UpdateSpec(ctx, spec)
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	ipamPoolUpdateLatency.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+	pm.events.updateFailed(err)
+	return errors.Wrap(err, "executing UpdateSpec with NNC CLI")
+}
+
 // createNNCSpecForCRD translates CNS's map of IPs to be released and requested IP count into an NNC Spec.
 func (pm *Monitor) createNNCSpecForCRD() v1alpha.NodeNetworkConfigSpec {
 	var spec v1alpha.NodeNetworkConfigSpec
@@ -441,6 +516,12 @@ func (pm *Monitor) Update(nnc *v1alpha.NodeNetworkConfig) error {
 // without these checks. if they are incorrectly set, there will be some weird
 // IP pool behavior for a while until the nnc reconciler corrects the state.
 func (pm *Monitor) clampScaler(scaler *v1alpha.Scaler) {
+	if pm.opts.RequestThresholdPercent > 0 {
+		scaler.RequestThresholdPercent = pm.opts.RequestThresholdPercent
+	}
+	if pm.opts.ReleaseThresholdPercent > 0 {
+		scaler.ReleaseThresholdPercent = pm.opts.ReleaseThresholdPercent
+	}
 	if scaler.MaxIPCount < 1 {
 		scaler.MaxIPCount = pm.opts.MaxIPs
 	}
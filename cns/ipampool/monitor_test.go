@@ -2,14 +2,19 @@ package ipampool
 
 import (
 	"context"
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/fakes"
 	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/record"
 )
 
 type fakeNodeNetworkConfigUpdater struct {
@@ -633,3 +638,129 @@ func TestCalculateIPs(t *testing.T) {
 		})
 	}
 }
+
+type specRecorder struct {
+	nnc   *v1alpha.NodeNetworkConfig
+	specs chan v1alpha.NodeNetworkConfigSpec
+}
+
+func (s *specRecorder) UpdateSpec(_ context.Context, spec *v1alpha.NodeNetworkConfigSpec) (*v1alpha.NodeNetworkConfig, error) {
+	s.specs <- *spec
+	return s.nnc, nil
+}
+
+func TestEventDrivenPoolIncrease(t *testing.T) {
+	initState := testState{
+		batch:                   10,
+		assigned:                2,
+		allocated:               10,
+		requestThresholdPercent: 50,
+		releaseThresholdPercent: 150,
+		max:                     30,
+	}
+	fakecns, fakerc, _ := initFakes(initState)
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	recorder := &specRecorder{nnc: fakerc.NNC, specs: make(chan v1alpha.NodeNetworkConfigSpec, 1)}
+	poolmonitor := NewMonitor(fakecns, recorder, nil, &Options{RefreshDelay: 100 * time.Second, EventDriven: true})
+
+	// notifications before the Monitor has started don't block.
+	poolmonitor.Notify()
+	poolmonitor.Notify()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = poolmonitor.Start(ctx) }()
+	assert.NoError(t, poolmonitor.Update(fakerc.NNC))
+
+	// the pool is within the watermarks, so the Monitor doesn't update the spec until it is notified.
+	assert.NoError(t, fakecns.SetNumberOfAssignedIPs(8))
+	select {
+	case spec := <-recorder.specs:
+		t.Fatalf("unexpected spec update %+v", spec)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	poolmonitor.Notify()
+	select {
+	case spec := <-recorder.specs:
+		assert.Equal(t, initState.allocated+initState.batch, spec.RequestedIPCount)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the pool to scale up")
+	}
+}
+
+func TestClampScalerWatermarkOverrides(t *testing.T) {
+	scaler := v1alpha.Scaler{
+		BatchSize:               10,
+		RequestThresholdPercent: 50,
+		ReleaseThresholdPercent: 150,
+		MaxIPCount:              250,
+	}
+
+	pm := &Monitor{opts: &Options{}}
+	pm.clampScaler(&scaler)
+	assert.Equal(t, int64(50), scaler.RequestThresholdPercent)
+	assert.Equal(t, int64(150), scaler.ReleaseThresholdPercent)
+
+	pm = &Monitor{opts: &Options{RequestThresholdPercent: 30, ReleaseThresholdPercent: 200}}
+	pm.clampScaler(&scaler)
+	assert.Equal(t, int64(30), scaler.RequestThresholdPercent)
+	assert.Equal(t, int64(200), scaler.ReleaseThresholdPercent)
+	assert.Equal(t, int64(3), CalculateMinFreeIPs(scaler))
+	assert.Equal(t, int64(20), CalculateMaxFreeIPs(scaler))
+}
+
+type failingNodeNetworkConfigUpdater struct{}
+
+func (failingNodeNetworkConfigUpdater) UpdateSpec(context.Context, *v1alpha.NodeNetworkConfigSpec) (*v1alpha.NodeNetworkConfig, error) {
+	return nil, errors.New("apiserver unavailable")
+}
+
+func TestPoolEvents(t *testing.T) {
+	initState := testState{
+		batch:                   16,
+		assigned:                9,
+		allocated:               16,
+		requestThresholdPercent: 50,
+		releaseThresholdPercent: 150,
+		max:                     30,
+	}
+
+	fakecns, fakerc, poolmonitor := initFakes(initState)
+	recorder := record.NewFakeRecorder(10)
+	poolmonitor.events = newPoolEvents(recorder, "node1")
+	require.NoError(t, fakerc.Reconcile(true))
+
+	require.NoError(t, poolmonitor.reconcile(context.Background()))
+	require.Equal(t, "Normal IPPoolIncreased Requested 30 IPs, up from 16, with 9 IPs allocated to Pods", <-recorder.Events)
+	require.NoError(t, fakerc.Reconcile(true))
+
+	// the pool can't grow past its max, which is only warned about once
+	require.NoError(t, fakecns.SetNumberOfAssignedIPs(28))
+	require.NoError(t, poolmonitor.reconcile(context.Background()))
+	require.NoError(t, poolmonitor.reconcile(context.Background()))
+	require.Equal(t, "Warning IPPoolAtMaxIPs The IP pool is at its max of 30 IPs with 28 IPs allocated to Pods, new Pods may fail to get an IP", <-recorder.Events)
+
+	poolmonitor.nnccli = failingNodeNetworkConfigUpdater{}
+	spec := poolmonitor.createNNCSpecForCRD()
+	require.Error(t, poolmonitor.updateSpec(context.Background(), operationRelease, &spec))
+	require.Error(t, poolmonitor.updateSpec(context.Background(), operationRelease, &spec))
+	require.Equal(t, "Warning IPPoolUpdateFailed Failed to update the NodeNetworkConfig, the IP pool can't scale: apiserver unavailable", <-recorder.Events)
+	require.Empty(t, recorder.Events)
+}
+
+func TestBuildIPPoolStateQuarantined(t *testing.T) {
+	ips := map[string]cns.IPConfigurationStatus{}
+	for i, state := range []types.IPState{types.Assigned, types.Available, types.Available, types.PendingRelease, types.Quarantined} {
+		ip := cns.IPConfigurationStatus{ID: strconv.Itoa(i)}
+		ip.SetState(state)
+		ips[ip.ID] = ip
+	}
+
+	// the quarantined IP is neither available now nor once the requested IPs are allocated.
+	state := buildIPPoolState(ips, v1alpha.NodeNetworkConfigSpec{RequestedIPCount: 4})
+	assert.Equal(t, int64(1), state.quarantined)
+	assert.Equal(t, int64(2), state.currentAvailableIPs)
+	assert.Equal(t, int64(2), state.expectedAvailableIPs)
+}
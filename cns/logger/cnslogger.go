@@ -1,12 +1,14 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
 	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/telemetry"
 	"github.com/pkg/errors"
 )
 
@@ -49,6 +51,21 @@ func (c *CNSLogger) InitAIWithIKey(aiConfig aitelemetry.AIConfig, instrumentatio
 	c.DisableEventLogging = disableEventLogging
 }
 
+// InitOTLP exports the telemetry to an OpenTelemetry collector, alongside AI if it was initialized.
+func (c *CNSLogger) InitOTLP(appName, appVersion string, config telemetry.OTLPConfig, disableTraceLogging, disableMetricLogging, disableEventLogging bool) {
+	th, err := telemetry.NewOTLPTelemetry(appName, appVersion, config)
+	if err != nil {
+		c.logger.Errorf("Error initializing OTLP Telemetry:%v", err)
+		return
+	}
+
+	c.th = telemetry.NewTeeTelemetryHandle(c.th, th)
+	c.logger.Printf("OTLP Telemetry Handle created")
+	c.DisableMetricLogging = disableMetricLogging
+	c.DisableTraceLogging = disableTraceLogging
+	c.DisableEventLogging = disableEventLogging
+}
+
 // wait time for closing AI telemetry session.
 const waitTimeInSecs = 10
 
@@ -59,6 +76,26 @@ func (c *CNSLogger) Close() {
 	}
 }
 
+// SetLogFileLimits sets the size and number of the local log files, non positive limits keep the defaults.
+func (c *CNSLogger) SetLogFileLimits(maxFileSize, maxFileCount int) {
+	c.logger.SetLogFileLimits(maxFileSize, maxFileCount)
+}
+
+// SetLogFileCompression sets whether the rotated local log files are compressed.
+func (c *CNSLogger) SetLogFileCompression(compress bool) {
+	c.logger.SetLogFileCompression(compress)
+}
+
+// ReopenOnSignal reopens the local log file on SIGHUP until the context is done.
+func (c *CNSLogger) ReopenOnSignal(ctx context.Context) {
+	c.logger.ReopenOnSignal(ctx)
+}
+
+// SetFormat sets the format of the local log, the telemetry is unchanged.
+func (c *CNSLogger) SetFormat(format int) error {
+	return c.logger.SetFormat(format)
+}
+
 func (c *CNSLogger) SetContextDetails(orchestrator, nodeID string) {
 	c.logger.Logf("SetContext details called with: %v orchestrator nodeID %v", orchestrator, nodeID)
 	c.m.Lock()
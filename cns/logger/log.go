@@ -2,8 +2,11 @@
 package logger
 
 import (
+	"context"
+
 	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/telemetry"
 )
 
 var (
@@ -29,6 +32,26 @@ func InitAIWithIKey(aiConfig aitelemetry.AIConfig, instrumentationKey string, di
 	Log.InitAIWithIKey(aiConfig, instrumentationKey, disableTraceLogging, disableMetricLogging, disableEventLogging)
 }
 
+func InitOTLP(appName, appVersion string, config telemetry.OTLPConfig, disableTraceLogging, disableMetricLogging, disableEventLogging bool) {
+	Log.InitOTLP(appName, appVersion, config, disableTraceLogging, disableMetricLogging, disableEventLogging)
+}
+
+func SetLogFileLimits(maxFileSize, maxFileCount int) {
+	Log.SetLogFileLimits(maxFileSize, maxFileCount)
+}
+
+func SetLogFileCompression(compress bool) {
+	Log.SetLogFileCompression(compress)
+}
+
+func ReopenOnSignal(ctx context.Context) {
+	Log.ReopenOnSignal(ctx)
+}
+
+func SetFormat(format int) error {
+	return Log.SetFormat(format)
+}
+
 func SetContextDetails(orchestrator, nodeID string) {
 	Log.SetContextDetails(orchestrator, nodeID)
 }
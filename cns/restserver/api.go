@@ -27,6 +27,7 @@ import (
 var (
 	ncRegex               = regexp.MustCompile(`NetworkManagement/interfaces/(.{0,36})/networkContainers/(.{0,36})/authenticationToken/(.{0,36})/api-version/1(/method/DELETE)?`)
 	ErrInvalidNcURLFormat = errors.New("Invalid network container url format")
+	errUnknownNC          = errors.New("unknown network container")
 )
 
 // ncURLExpectedMatches defines the size of matches expected from exercising the ncRegex
@@ -785,6 +786,83 @@ func (service *HTTPRestService) getHomeAz(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// wireguardPeers publishes the wireguard public key of this node on POST and lists the peers of the
+// cluster on GET. Keys are only accepted from the node itself: the CNI plugin reaches CNS over loopback,
+// and the key is written to this node's Node object with the credentials of CNS.
+func (service *HTTPRestService) wireguardPeers(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("[Azure CNS] wireguardPeers")
+
+	if service.WireguardPeerStore == nil {
+		returnMessage := "[Azure CNS] Error. wireguardPeers requires CNS to run in CRD mode."
+		returnCode := types.UnsupportedOrchestratorType
+		service.setResponse(w, returnCode, cns.GetWireguardPeersResponse{
+			Response: cns.Response{ReturnCode: returnCode, Message: returnMessage},
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		logger.Request(service.Name, "wireguardPeers", nil)
+		returnCode := types.Success
+		returnMessage := ""
+		peers, err := service.WireguardPeerStore.ListPeers(r.Context())
+		if err != nil {
+			returnCode = types.UnexpectedError
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. Failed to list wireguard peers: %v", err)
+		}
+
+		service.setResponse(w, returnCode, cns.GetWireguardPeersResponse{
+			Response: cns.Response{ReturnCode: returnCode, Message: returnMessage},
+			Peers:    peers,
+		})
+	case http.MethodPost:
+		var req cns.PublishWireguardPeerRequest
+		err := service.Listener.Decode(w, r, &req)
+		logger.Request(service.Name, &req, err)
+		if err != nil {
+			return
+		}
+
+		returnCode := types.Success
+		returnMessage := ""
+		switch {
+		case !isLoopbackRequest(r):
+			returnCode = types.StatusUnauthorized
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. wireguardPeers only accepts keys from the local node, not %s.", r.RemoteAddr)
+		case req.PublicKey == "":
+			returnCode = types.InvalidParameter
+			returnMessage = "[Azure CNS] Error. wireguardPeers requires a public key."
+		default:
+			if err = service.WireguardPeerStore.PublishPublicKey(r.Context(), req.PublicKey); err != nil {
+				returnCode = types.UnexpectedError
+				returnMessage = fmt.Sprintf("[Azure CNS] Error. Failed to publish wireguard public key: %v", err)
+			}
+		}
+
+		service.setResponse(w, returnCode, cns.PublishWireguardPeerResponse{
+			Response: cns.Response{ReturnCode: returnCode, Message: returnMessage},
+		})
+	default:
+		returnMessage := "[Azure CNS] Error. wireguardPeers expects a GET or POST."
+		returnCode := types.UnsupportedVerb
+		service.setResponse(w, returnCode, cns.GetWireguardPeersResponse{
+			Response: cns.Response{ReturnCode: returnCode, Message: returnMessage},
+		})
+	}
+}
+
+// isLoopbackRequest returns true if the request was sent from the node itself.
+func isLoopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (service *HTTPRestService) createOrUpdateNetworkContainer(w http.ResponseWriter, r *http.Request) {
 	logger.Printf("[Azure CNS] createOrUpdateNetworkContainer")
 
@@ -918,6 +996,30 @@ func (service *HTTPRestService) getOrRefreshNetworkContainers(w http.ResponseWri
 	}
 }
 
+// getNCProgrammingStatus returns whether the NIC has been programmed with the current version of the NCs, so that the
+// CNI plugin can hold the pods of an NC back until their traffic is let through.
+func (service *HTTPRestService) getNCProgrammingStatus(w http.ResponseWriter, r *http.Request) {
+	var req cns.GetNCProgrammingStatusRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name+"getNCProgrammingStatus", req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.GetNCProgrammingStatusResponse
+	service.RLock()
+	resp.NCProgrammingStatuses, err = service.ncProgrammingStatusesUntransacted(req.NetworkContainerIDs)
+	service.RUnlock()
+	if err != nil {
+		resp.Response = cns.Response{
+			ReturnCode: types.UnknownContainerID,
+			Message:    err.Error(),
+		}
+	}
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
 func (service *HTTPRestService) deleteNetworkContainer(w http.ResponseWriter, r *http.Request) {
 	logger.Printf("[Azure CNS] deleteNetworkContainer")
 
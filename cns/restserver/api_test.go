@@ -995,6 +995,69 @@ func TestGetHomeAz_UnsupportedHttpMethod(t *testing.T) {
 	logger.Printf("GetHomeAz Responded with %+v\n", getHomeAzResponse)
 }
 
+type fakeWireguardPeerStore struct {
+	publicKey string
+}
+
+func (f *fakeWireguardPeerStore) PublishPublicKey(_ context.Context, publicKey string) error {
+	f.publicKey = publicKey
+	return nil
+}
+
+func (f *fakeWireguardPeerStore) ListPeers(context.Context) ([]cns.WireguardPeer, error) {
+	return []cns.WireguardPeer{
+		{NodeName: "node-1", PublicKey: f.publicKey, Endpoint: "10.240.0.4:51820", AllowedIPs: []string{"10.244.1.0/24"}},
+	}, nil
+}
+
+func TestWireguardPeers(t *testing.T) {
+	publish := func(publicKey, remoteAddr string) cns.PublishWireguardPeerResponse {
+		var body bytes.Buffer
+		err := json.NewEncoder(&body).Encode(cns.PublishWireguardPeerRequest{PublicKey: publicKey})
+		assert.NoError(t, err)
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, cns.WireguardPeers, &body)
+		assert.NoError(t, err)
+		req.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		var resp cns.PublishWireguardPeerResponse
+		assert.NoError(t, decodeResponse(w, &resp))
+		return resp
+	}
+
+	resp := publish("xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", "127.0.0.1:40000")
+	assert.Equal(t, types.UnsupportedOrchestratorType, resp.Response.ReturnCode, "peer exchange requires CRD mode")
+
+	store := &fakeWireguardPeerStore{}
+	svc.WireguardPeerStore = store
+	defer func() { svc.WireguardPeerStore = nil }()
+
+	resp = publish("", "127.0.0.1:40000")
+	assert.Equal(t, types.InvalidParameter, resp.Response.ReturnCode)
+
+	resp = publish("xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", "10.240.0.5:40000")
+	assert.Equal(t, types.StatusUnauthorized, resp.Response.ReturnCode, "keys of other nodes must be rejected")
+	assert.Empty(t, store.publicKey)
+
+	resp = publish("xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", "127.0.0.1:40000")
+	assert.Equal(t, types.Success, resp.Response.ReturnCode)
+	assert.Equal(t, "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", store.publicKey)
+
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, cns.WireguardPeers, http.NoBody)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var getResp cns.GetWireguardPeersResponse
+	assert.NoError(t, decodeResponse(w, &getResp))
+	assert.Equal(t, types.Success, getResp.Response.ReturnCode)
+	assert.Equal(t, []cns.WireguardPeer{
+		{NodeName: "node-1", PublicKey: store.publicKey, Endpoint: "10.240.0.4:51820", AllowedIPs: []string{"10.244.1.0/24"}},
+	}, getResp.Peers)
+}
+
 func TestCreateHostNCApipaEndpoint(t *testing.T) {
 	fmt.Println("Test: createHostNCApipaEndpoint")
 
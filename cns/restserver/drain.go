@@ -0,0 +1,150 @@
+package restserver
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HandoffStoreKey is the key of the HandoffSnapshot in the handoff store.
+	HandoffStoreKey = "IPAMHandoff"
+	// HandoffSnapshotVersion is the version of the HandoffSnapshot format. A CNS only accepts a snapshot of its own
+	// version, so a format change must bump it.
+	HandoffSnapshotVersion = 1
+)
+
+var (
+	errServiceDraining        = errors.New("CNS is draining, retry the request after it has restarted")
+	ErrInvalidHandoffSnapshot = errors.New("invalid handoff snapshot")
+)
+
+// HandoffSnapshot is the IPAM state a draining CNS hands off to the CNS process that replaces it, so that the new
+// process can restore the IPs assigned to Pods without asking CNI or the apiserver.
+type HandoffSnapshot struct {
+	Version    int
+	CNSVersion string
+	TakenAt    time.Time
+	PodIPs     []HandoffPodIP
+}
+
+// HandoffPodIP is an IP assigned to a Pod interface.
+type HandoffPodIP struct {
+	IPAddress        string
+	PodName          string
+	PodNamespace     string
+	InfraContainerID string
+	PodInterfaceID   string
+}
+
+var _ cns.PodInfoByIPProvider = (*HandoffSnapshot)(nil)
+
+// Validate checks that the snapshot can be restored: it must be of the current version, not older than maxAge,
+// and assign every IP to at most one Pod.
+func (s *HandoffSnapshot) Validate(maxAge time.Duration, now time.Time) error {
+	if s.Version != HandoffSnapshotVersion {
+		return errors.Wrapf(ErrInvalidHandoffSnapshot, "version %d, expected %d", s.Version, HandoffSnapshotVersion)
+	}
+	if age := now.Sub(s.TakenAt); age > maxAge {
+		return errors.Wrapf(ErrInvalidHandoffSnapshot, "taken %s ago, max age is %s", age, maxAge)
+	}
+	if _, err := s.PodInfoByIP(); err != nil {
+		return errors.Wrap(ErrInvalidHandoffSnapshot, err.Error())
+	}
+	return nil
+}
+
+// PodInfoByIP implements cns.PodInfoByIPProvider on the snapshot.
+func (s *HandoffSnapshot) PodInfoByIP() (map[string]cns.PodInfo, error) {
+	podInfoByIP := make(map[string]cns.PodInfo, len(s.PodIPs))
+	for i := range s.PodIPs {
+		podIP := s.PodIPs[i]
+		if net.ParseIP(podIP.IPAddress) == nil {
+			return nil, errors.Errorf("invalid IP %q of pod %s/%s", podIP.IPAddress, podIP.PodNamespace, podIP.PodName)
+		}
+		if _, ok := podInfoByIP[podIP.IPAddress]; ok {
+			return nil, errors.Wrap(cns.ErrDuplicateIP, podIP.IPAddress)
+		}
+		podInfoByIP[podIP.IPAddress] = cns.NewPodInfo(podIP.InfraContainerID, podIP.PodInterfaceID, podIP.PodName, podIP.PodNamespace)
+	}
+	return podInfoByIP, nil
+}
+
+// beginIPAMRequest registers an IP request or release as in flight, unless the service is draining.
+// The caller must call ipamRequestsInFlight.Done() once the request is handled.
+func (service *HTTPRestService) beginIPAMRequest() error {
+	service.drainLock.RLock()
+	defer service.drainLock.RUnlock()
+	if service.draining {
+		return errServiceDraining
+	}
+	service.ipamRequestsInFlight.Add(1)
+	return nil
+}
+
+// Drain prepares the service to be replaced by a new CNS process: it stops accepting IP requests and releases,
+// which fail with the ServiceDraining return code so that the caller retries them against the new process, waits
+// for the in-flight ones to complete or the context to be done, then persists the CNS state and writes a
+// HandoffSnapshot of the IPs assigned to Pods to the HandoffStore.
+func (service *HTTPRestService) Drain(ctx context.Context) error {
+	service.drainLock.Lock()
+	service.draining = true
+	service.drainLock.Unlock()
+	logger.Printf("[Azure CNS] Draining, rejecting new IP requests")
+
+	done := make(chan struct{})
+	go func() {
+		service.ipamRequestsInFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// hand off what we have, the requests still in flight will fail and be retried by CNI.
+		logger.Errorf("[Azure CNS] Timed out waiting for in-flight IP requests: %v", ctx.Err())
+	}
+
+	service.Lock()
+	defer service.Unlock()
+	if err := service.saveState(); err != nil {
+		return errors.Wrap(err, "failed to persist state")
+	}
+
+	if service.HandoffStore == nil {
+		return nil
+	}
+	snapshot := service.handoffSnapshot()
+	if err := service.HandoffStore.Write(HandoffStoreKey, snapshot); err != nil {
+		return errors.Wrap(err, "failed to write handoff snapshot")
+	}
+	logger.Printf("[Azure CNS] Handed off %d Pod IPs", len(snapshot.PodIPs))
+	return nil
+}
+
+// handoffSnapshot builds the HandoffSnapshot of the IPs assigned to Pods, does not take a lock.
+func (service *HTTPRestService) handoffSnapshot() *HandoffSnapshot {
+	snapshot := &HandoffSnapshot{
+		Version:    HandoffSnapshotVersion,
+		CNSVersion: service.Version,
+		TakenAt:    time.Now(),
+	}
+	for i := range service.PodIPConfigState {
+		ipConfig := service.PodIPConfigState[i]
+		if ipConfig.GetState() != types.Assigned || ipConfig.PodInfo == nil {
+			continue
+		}
+		snapshot.PodIPs = append(snapshot.PodIPs, HandoffPodIP{
+			IPAddress:        ipConfig.IPAddress,
+			PodName:          ipConfig.PodInfo.Name(),
+			PodNamespace:     ipConfig.PodInfo.Namespace(),
+			InfraContainerID: ipConfig.PodInfo.InfraContainerID(),
+			PodInterfaceID:   ipConfig.PodInfo.InterfaceID(),
+		})
+	}
+	return snapshot
+}
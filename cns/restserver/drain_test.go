@@ -0,0 +1,114 @@
+package restserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(t *testing.T) {
+	svc := getTestService()
+	svc.HandoffStore = store.NewMockStore("")
+
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testPod1GUID, testNCID, types.Assigned, 24, 0, testPod1Info)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+	ipconfigs := map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+	}
+	if err := UpdatePodIpConfigState(t, svc, ipconfigs); err != nil {
+		t.Fatalf("Expected to not fail adding IPs to state: %+v", err)
+	}
+
+	// a request in flight delays the handoff until it completes.
+	assert.NoError(t, svc.beginIPAMRequest())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		svc.ipamRequestsInFlight.Done()
+	}()
+
+	assert.NoError(t, svc.Drain(context.Background()))
+	assert.True(t, errors.Is(svc.beginIPAMRequest(), errServiceDraining), "new requests are rejected")
+
+	var snapshot HandoffSnapshot
+	assert.NoError(t, svc.HandoffStore.Read(HandoffStoreKey, &snapshot))
+	assert.NoError(t, snapshot.Validate(time.Minute, time.Now()))
+	assert.Equal(t, []HandoffPodIP{
+		{
+			IPAddress:        testIP1,
+			PodName:          testPod1Info.Name(),
+			PodNamespace:     testPod1Info.Namespace(),
+			InfraContainerID: testPod1Info.InfraContainerID(),
+			PodInterfaceID:   testPod1Info.InterfaceID(),
+		},
+	}, snapshot.PodIPs)
+
+	podInfoByIP, err := snapshot.PodInfoByIP()
+	assert.NoError(t, err)
+	assert.Equal(t, testPod1Info.Key(), podInfoByIP[testIP1].Key())
+}
+
+func TestDrainTimeout(t *testing.T) {
+	svc := getTestService()
+	assert.NoError(t, svc.beginIPAMRequest())
+	defer svc.ipamRequestsInFlight.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, svc.Drain(ctx), "the state is persisted when in-flight requests don't complete in time")
+}
+
+func TestHandoffSnapshotValidate(t *testing.T) {
+	now := time.Now()
+	podIP := HandoffPodIP{IPAddress: testIP1, PodName: "testpod1", PodNamespace: "testpod1namespace"}
+	tests := []struct {
+		name     string
+		snapshot HandoffSnapshot
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			snapshot: HandoffSnapshot{Version: HandoffSnapshotVersion, TakenAt: now, PodIPs: []HandoffPodIP{podIP}},
+		},
+		{
+			name:     "other version",
+			snapshot: HandoffSnapshot{Version: HandoffSnapshotVersion + 1, TakenAt: now},
+			wantErr:  true,
+		},
+		{
+			name:     "too old",
+			snapshot: HandoffSnapshot{Version: HandoffSnapshotVersion, TakenAt: now.Add(-2 * time.Minute)},
+			wantErr:  true,
+		},
+		{
+			name:     "duplicate IP",
+			snapshot: HandoffSnapshot{Version: HandoffSnapshotVersion, TakenAt: now, PodIPs: []HandoffPodIP{podIP, podIP}},
+			wantErr:  true,
+		},
+		{
+			name: "invalid IP",
+			snapshot: HandoffSnapshot{
+				Version: HandoffSnapshotVersion, TakenAt: now,
+				PodIPs: []HandoffPodIP{{IPAddress: "10.0.0", PodName: "testpod1", PodNamespace: "testpod1namespace"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.snapshot.Validate(time.Minute, now)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidHandoffSnapshot), "unexpected error %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package restserver
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+var (
+	errHostPortConflict         = errors.New("host ports are reserved by another pod")
+	errInvalidHostPort          = errors.New("invalid host port")
+	errMissingPodInterfaceID    = errors.New("missing pod interface ID")
+	errUnsupportedHostPortProto = errors.New("unsupported host port protocol")
+)
+
+// hostPortReservations are the host ports reserved by the Pod interfaces, so that conflicting hostPorts are reported
+// by CNI ADD rather than by the iptables or HNS programming failing. They are kept in memory only: a restarted CNS
+// doesn't know the host ports of the existing Pods, whose conflicts are then left to the programming to report.
+type hostPortReservations map[string][]cns.HostPortReservation // PodInterfaceID is key
+
+func (service *HTTPRestService) reserveHostPortsHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.ReserveHostPortsRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name+"reserveHostPortsHandler", req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.HostPortsResponse
+	if resp.HostPorts, err = service.reserveHostPorts(&req); err != nil {
+		returnCode := types.InvalidParameter
+		if errors.Is(err, errHostPortConflict) {
+			returnCode = types.HostPortConflict
+		}
+		resp.Response = cns.Response{
+			ReturnCode: returnCode,
+			Message:    err.Error(),
+		}
+	}
+	w.Header().Set(cnsReturnCode, resp.Response.ReturnCode.String())
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
+func (service *HTTPRestService) releaseHostPortsHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.ReleaseHostPortsRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name+"releaseHostPortsHandler", req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.HostPortsResponse
+	if req.PodInterfaceID == "" {
+		resp.Response = cns.Response{
+			ReturnCode: types.InvalidParameter,
+			Message:    errMissingPodInterfaceID.Error(),
+		}
+	} else {
+		service.Lock()
+		resp.HostPorts = service.releaseHostPortsUntransacted(req.PodInterfaceID)
+		service.Unlock()
+	}
+	w.Header().Set(cnsReturnCode, resp.Response.ReturnCode.String())
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
+func (service *HTTPRestService) handleDebugHostPorts(w http.ResponseWriter, r *http.Request) {
+	var resp cns.HostPortsResponse
+	service.RLock()
+	for _, reservations := range service.hostPorts {
+		resp.HostPorts = append(resp.HostPorts, reservations...)
+	}
+	service.RUnlock()
+
+	sortHostPortReservations(resp.HostPorts)
+	err := service.Listener.Encode(w, &resp)
+	logger.Response(service.Name, resp, resp.Response.ReturnCode, err)
+}
+
+// reserveHostPorts reserves the host ports of the Pod interface in place of those it reserved before. If any of them
+// is reserved by another Pod interface, none is reserved and the conflicting reservations are returned with
+// errHostPortConflict.
+func (service *HTTPRestService) reserveHostPorts(req *cns.ReserveHostPortsRequest) ([]cns.HostPortReservation, error) {
+	if req.PodInterfaceID == "" {
+		return nil, errMissingPodInterfaceID
+	}
+
+	reservations := make([]cns.HostPortReservation, 0, len(req.HostPorts))
+	for _, hostPort := range req.HostPorts {
+		hostPort, err := normalizeHostPort(hostPort)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, cns.HostPortReservation{
+			HostPort:         hostPort,
+			PodInterfaceID:   req.PodInterfaceID,
+			InfraContainerID: req.InfraContainerID,
+		})
+	}
+
+	service.Lock()
+	defer service.Unlock()
+
+	var conflicts []cns.HostPortReservation
+	for podInterfaceID, reserved := range service.hostPorts {
+		if podInterfaceID == req.PodInterfaceID {
+			continue
+		}
+		for i := range reserved {
+			for j := range reservations {
+				if hostPortsConflict(reserved[i].HostPort, reservations[j].HostPort) {
+					conflicts = append(conflicts, reserved[i])
+					break
+				}
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		sortHostPortReservations(conflicts)
+		logger.Errorf("[reserveHostPorts] Host ports of pod interface %s conflict with %+v", req.PodInterfaceID, conflicts)
+		return conflicts, errors.Wrapf(errHostPortConflict, "%s/%d is reserved by pod interface %s",
+			conflicts[0].Protocol, conflicts[0].Port, conflicts[0].PodInterfaceID)
+	}
+
+	if service.hostPorts == nil {
+		service.hostPorts = make(hostPortReservations)
+	}
+	if len(reservations) == 0 {
+		delete(service.hostPorts, req.PodInterfaceID)
+	} else {
+		service.hostPorts[req.PodInterfaceID] = reservations
+		logger.Printf("[reserveHostPorts] Reserved host ports %+v for pod interface %s", reservations, req.PodInterfaceID)
+	}
+	return reservations, nil
+}
+
+// releaseHostPortsUntransacted releases the host ports of the Pod interface and returns them.
+func (service *HTTPRestService) releaseHostPortsUntransacted(podInterfaceID string) []cns.HostPortReservation {
+	released, ok := service.hostPorts[podInterfaceID]
+	if ok {
+		delete(service.hostPorts, podInterfaceID)
+		logger.Printf("[releaseHostPorts] Released host ports %+v of pod interface %s", released, podInterfaceID)
+	}
+	return released
+}
+
+// normalizeHostPort validates the host port and sets its protocol and unspecified host IP to their canonical form.
+func normalizeHostPort(hostPort cns.HostPort) (cns.HostPort, error) {
+	if hostPort.Port <= 0 || hostPort.Port > 65535 {
+		return hostPort, errors.Wrapf(errInvalidHostPort, "%d", hostPort.Port)
+	}
+
+	hostPort.Protocol = strings.ToUpper(strings.TrimSpace(hostPort.Protocol))
+	switch hostPort.Protocol {
+	case "":
+		hostPort.Protocol = "TCP"
+	case "TCP", "UDP", "SCTP":
+	default:
+		return hostPort, errors.Wrap(errUnsupportedHostPortProto, hostPort.Protocol)
+	}
+
+	if hostPort.HostIP != "" {
+		ip := net.ParseIP(hostPort.HostIP)
+		if ip == nil {
+			return hostPort, errors.Wrapf(errInvalidHostPort, "host IP %s", hostPort.HostIP)
+		}
+		if ip.IsUnspecified() {
+			hostPort.HostIP = ""
+		} else {
+			hostPort.HostIP = ip.String()
+		}
+	}
+	return hostPort, nil
+}
+
+// hostPortsConflict returns whether the normalized host ports can't both be mapped: they are the same port and
+// protocol, and either is on every address of the Node or both are on the same address.
+func hostPortsConflict(a, b cns.HostPort) bool {
+	if a.Port != b.Port || a.Protocol != b.Protocol {
+		return false
+	}
+	return a.HostIP == "" || b.HostIP == "" || a.HostIP == b.HostIP
+}
+
+func sortHostPortReservations(reservations []cns.HostPortReservation) {
+	sort.Slice(reservations, func(i, j int) bool {
+		a, b := reservations[i], reservations[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.HostIP != b.HostIP {
+			return a.HostIP < b.HostIP
+		}
+		return a.PodInterfaceID < b.PodInterfaceID
+	})
+}
@@ -0,0 +1,73 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveHostPorts(t *testing.T) {
+	svc := getTestService()
+
+	_, err := svc.reserveHostPorts(&cns.ReserveHostPortsRequest{
+		PodInterfaceID: "pod1-eth0",
+		HostPorts:      []cns.HostPort{{Port: 8080}, {HostIP: "10.0.0.4", Port: 53, Protocol: "udp"}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		hostPort cns.HostPort
+		conflict bool
+	}{
+		{name: "same port on every address", hostPort: cns.HostPort{HostIP: "0.0.0.0", Port: 8080, Protocol: "TCP"}, conflict: true},
+		{name: "same port on one address", hostPort: cns.HostPort{HostIP: "10.0.0.5", Port: 8080}, conflict: true},
+		{name: "same port other protocol", hostPort: cns.HostPort{Port: 8080, Protocol: "UDP"}},
+		{name: "same port on other address", hostPort: cns.HostPort{HostIP: "10.0.0.5", Port: 53, Protocol: "UDP"}},
+		{name: "same port on every address of other", hostPort: cns.HostPort{Port: 53, Protocol: "UDP"}, conflict: true},
+		{name: "other port", hostPort: cns.HostPort{Port: 8081}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req := &cns.ReserveHostPortsRequest{PodInterfaceID: "pod2-eth0", HostPorts: []cns.HostPort{tt.hostPort}}
+			conflicts, err := svc.reserveHostPorts(req)
+			if !tt.conflict {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, errHostPortConflict)
+			require.Len(t, conflicts, 1)
+			assert.Equal(t, "pod1-eth0", conflicts[0].PodInterfaceID)
+		})
+	}
+
+	// the pod reserves its host ports again when its ADD is retried.
+	_, err = svc.reserveHostPorts(&cns.ReserveHostPortsRequest{PodInterfaceID: "pod1-eth0", HostPorts: []cns.HostPort{{Port: 8080}}})
+	require.NoError(t, err)
+
+	_, err = svc.reserveHostPorts(&cns.ReserveHostPortsRequest{PodInterfaceID: "pod2-eth0", HostPorts: []cns.HostPort{{Port: 0}}})
+	require.ErrorIs(t, err, errInvalidHostPort)
+	_, err = svc.reserveHostPorts(&cns.ReserveHostPortsRequest{PodInterfaceID: "pod2-eth0", HostPorts: []cns.HostPort{{Port: 80, Protocol: "icmp"}}})
+	require.ErrorIs(t, err, errUnsupportedHostPortProto)
+}
+
+func TestHostPortsAreReleasedWithIP(t *testing.T) {
+	svc := getTestService()
+	state := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state.ID: state}))
+
+	_, err := svc.reserveHostPorts(&cns.ReserveHostPortsRequest{
+		PodInterfaceID: testPod1Info.InterfaceID(),
+		HostPorts:      []cns.HostPort{{Port: 8080}},
+	})
+	require.NoError(t, err)
+	_, err = svc.AssignAnyAvailableIPConfig(testPod1Info)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.releaseIPConfig(testPod1Info))
+	assert.Empty(t, svc.hostPorts)
+}
@@ -4,10 +4,13 @@
 package restserver
 
 import (
+	"bytes"
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/filter"
@@ -27,6 +30,20 @@ func (service *HTTPRestService) requestIPConfigHandler(w http.ResponseWriter, r
 		return
 	}
 
+	if err = service.beginIPAMRequest(); err != nil {
+		reserveResp := &cns.IPConfigResponse{
+			Response: cns.Response{
+				ReturnCode: types.ServiceDraining,
+				Message:    err.Error(),
+			},
+		}
+		w.Header().Set(cnsReturnCode, reserveResp.Response.ReturnCode.String())
+		err = service.Listener.Encode(w, &reserveResp)
+		logger.ResponseEx(service.Name+operationName, ipconfigRequest, reserveResp, reserveResp.Response.ReturnCode, err)
+		return
+	}
+	defer service.ipamRequestsInFlight.Done()
+
 	// retrieve ipconfig from nc
 	podInfo, returnCode, returnMessage := service.validateIPConfigRequest(ipconfigRequest)
 	if returnCode != types.Success {
@@ -46,6 +63,8 @@ func (service *HTTPRestService) requestIPConfigHandler(w http.ResponseWriter, r
 	service.podsPendingIPAssignment.Push(podInfo.Key())
 
 	podIPInfo, err := requestIPConfigHelper(service, ipconfigRequest)
+	// the pool may need to scale up, also when no IP was available for this pod.
+	service.notifyPoolMonitor()
 	if err != nil {
 		reserveResp := &cns.IPConfigResponse{
 			Response: cns.Response{
@@ -100,6 +119,7 @@ func (service *HTTPRestService) requestIPConfigHandler(w http.ResponseWriter, r
 var (
 	errStoreEmpty       = errors.New("empty endpoint state store")
 	errParsePodIPFailed = errors.New("failed to parse pod's ip")
+	errEndpointNotFound = errors.New("endpoint state not found")
 )
 
 func (service *HTTPRestService) updateEndpointState(ipconfigRequest cns.IPConfigRequest, podInfo cns.PodInfo, podIPInfo cns.PodIpInfo) error {
@@ -111,6 +131,9 @@ func (service *HTTPRestService) updateEndpointState(ipconfigRequest cns.IPConfig
 	logger.Printf("[updateEndpointState] Updating endpoint state for infra container %s", ipconfigRequest.InfraContainerID)
 	if endpointInfo, ok := service.EndpointState[ipconfigRequest.InfraContainerID]; ok {
 		logger.Warnf("[updateEndpointState] Found existing endpoint state for infra container %s", ipconfigRequest.InfraContainerID)
+		if _, ok := endpointInfo.IfnameToIPMap[ipconfigRequest.Ifname]; !ok {
+			endpointInfo.IfnameToIPMap[ipconfigRequest.Ifname] = &IPInfo{}
+		}
 		ip := net.ParseIP(podIPInfo.PodIPConfig.IPAddress)
 		if ip == nil {
 			logger.Errorf("failed to parse pod ip address %s", podIPInfo.PodIPConfig.IPAddress)
@@ -180,6 +203,18 @@ func (service *HTTPRestService) releaseIPConfigHandler(w http.ResponseWriter, r
 		return
 	}
 
+	if err = service.beginIPAMRequest(); err != nil {
+		resp := cns.Response{
+			ReturnCode: types.ServiceDraining,
+			Message:    err.Error(),
+		}
+		w.Header().Set(cnsReturnCode, resp.ReturnCode.String())
+		err = service.Listener.Encode(w, &resp)
+		logger.ResponseEx(service.Name, req, resp, resp.ReturnCode, err)
+		return
+	}
+	defer service.ipamRequestsInFlight.Done()
+
 	podInfo, returnCode, message := service.validateIPConfigRequest(req)
 
 	// Check if http rest service managed endpoint state is set
@@ -201,6 +236,8 @@ func (service *HTTPRestService) releaseIPConfigHandler(w http.ResponseWriter, r
 		returnCode = types.UnexpectedError
 		message = err.Error()
 		logger.Errorf("releaseIPConfigHandler releaseIPConfig failed because %v, release IP config info %s", message, req)
+	} else {
+		service.notifyPoolMonitor()
 	}
 	resp := cns.Response{
 		ReturnCode: returnCode,
@@ -230,39 +267,163 @@ func (service *HTTPRestService) removeEndpointState(podInfo cns.PodInfo) error {
 	return nil
 }
 
-// MarkIPAsPendingRelease will set the IPs which are in PendingProgramming or Available to PendingRelease state
-// It will try to update [totalIpsToRelease]  number of ips.
-func (service *HTTPRestService) MarkIPAsPendingRelease(totalIpsToRelease int) (map[string]cns.IPConfigurationStatus, error) {
-	pendingReleasedIps := make(map[string]cns.IPConfigurationStatus)
+// endpointHandlerAPI returns the state of an endpoint on GET and records the host side of its interfaces on PATCH.
+// The endpoint is identified by the infra container ID which follows the endpoint path.
+func (service *HTTPRestService) endpointHandlerAPI(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("[endpointHandlerAPI] Received request with http method %s", r.Method)
+
+	endpointID := strings.TrimPrefix(r.URL.Path, cns.EndpointPath)
+	switch r.Method {
+	case http.MethodGet:
+		service.getEndpointHandler(w, endpointID)
+	case http.MethodPatch:
+		service.updateEndpointHandler(w, r, endpointID)
+	default:
+		returnCode := types.UnsupportedVerb
+		service.setResponse(w, returnCode, cns.Response{
+			ReturnCode: returnCode,
+			Message:    "[Azure CNS] Error. endpointHandlerAPI expects a GET or PATCH.",
+		})
+	}
+}
+
+func (service *HTTPRestService) getEndpointHandler(w http.ResponseWriter, endpointID string) {
+	logger.Printf("[getEndpointHandler] Getting endpoint state of infra container %s", endpointID)
+
+	endpointInfo, err := service.getEndpointState(endpointID)
+	if err != nil {
+		returnCode := types.UnexpectedError
+		if errors.Is(err, errEndpointNotFound) {
+			returnCode = types.NotFound
+		}
+
+		resp := GetEndpointResponse{
+			Response: Response{ReturnCode: returnCode, Message: err.Error()},
+		}
+		w.Header().Set(cnsReturnCode, resp.Response.ReturnCode.String())
+		service.setResponse(w, returnCode, resp)
+		return
+	}
+
+	resp := GetEndpointResponse{
+		Response:     Response{ReturnCode: types.Success},
+		EndpointInfo: *endpointInfo,
+	}
+	w.Header().Set(cnsReturnCode, resp.Response.ReturnCode.String())
+	service.setResponse(w, types.Success, resp)
+}
+
+func (service *HTTPRestService) updateEndpointHandler(w http.ResponseWriter, r *http.Request, endpointID string) {
+	var req map[string]*IPInfo
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name+"updateEndpointHandler", req, err)
+	if err != nil {
+		return
+	}
+
+	returnCode := types.Success
+	message := ""
+	if err = service.updateEndpointHostInterfaces(endpointID, req); err != nil {
+		returnCode = types.UnexpectedError
+		if errors.Is(err, errEndpointNotFound) {
+			returnCode = types.NotFound
+		}
+		message = err.Error()
+	}
+
+	resp := cns.Response{ReturnCode: returnCode, Message: message}
+	w.Header().Set(cnsReturnCode, resp.ReturnCode.String())
+	service.setResponse(w, returnCode, resp)
+}
+
+// getEndpointState returns a copy of the state of the endpoint of the infra container.
+func (service *HTTPRestService) getEndpointState(endpointID string) (*EndpointInfo, error) {
+	if service.EndpointStateStore == nil {
+		return nil, errStoreEmpty
+	}
+	service.RLock()
+	defer service.RUnlock()
+
+	endpointInfo, ok := service.EndpointState[endpointID]
+	if !ok {
+		return nil, errors.Wrapf(errEndpointNotFound, "infra container %s", endpointID)
+	}
+
+	copied := &EndpointInfo{
+		PodName:       endpointInfo.PodName,
+		PodNamespace:  endpointInfo.PodNamespace,
+		IfnameToIPMap: make(map[string]*IPInfo),
+	}
+	for ifName, ipInfo := range endpointInfo.IfnameToIPMap {
+		ipInfoCopy := *ipInfo
+		copied.IfnameToIPMap[ifName] = &ipInfoCopy
+	}
+
+	return copied, nil
+}
+
+// updateEndpointHostInterfaces records the host interfaces of the endpoint of the infra container. The addresses
+// of the interfaces are only set when they are allocated, so they are not changed.
+func (service *HTTPRestService) updateEndpointHostInterfaces(endpointID string, ifnameToIPMap map[string]*IPInfo) error {
+	if service.EndpointStateStore == nil {
+		return errStoreEmpty
+	}
 	service.Lock()
 	defer service.Unlock()
 
-	for uuid, existingIpConfig := range service.PodIPConfigState {
-		if existingIpConfig.GetState() == types.PendingProgramming {
-			updatedIPConfig, err := service.updateIPConfigState(uuid, types.PendingRelease, existingIpConfig.PodInfo)
-			if err != nil {
-				return nil, err
-			}
+	endpointInfo, ok := service.EndpointState[endpointID]
+	if !ok {
+		return errors.Wrapf(errEndpointNotFound, "infra container %s", endpointID)
+	}
 
-			pendingReleasedIps[uuid] = updatedIPConfig
-			if len(pendingReleasedIps) == totalIpsToRelease {
-				return pendingReleasedIps, nil
-			}
+	logger.Printf("[updateEndpointHostInterfaces] Updating host interfaces of infra container %s", endpointID)
+	for ifName, update := range ifnameToIPMap {
+		if update == nil {
+			continue
+		}
+
+		ipInfo, ok := endpointInfo.IfnameToIPMap[ifName]
+		if !ok {
+			ipInfo = &IPInfo{}
+			endpointInfo.IfnameToIPMap[ifName] = ipInfo
+		}
+
+		if update.HostVethName != "" {
+			ipInfo.HostVethName = update.HostVethName
+		}
+
+		if update.HnsEndpointID != "" {
+			ipInfo.HnsEndpointID = update.HnsEndpointID
 		}
 	}
 
-	// if not all expected IPs are set to PendingRelease, then check the Available IPs
-	for uuid, existingIpConfig := range service.PodIPConfigState {
-		if existingIpConfig.GetState() == types.Available {
-			updatedIPConfig, err := service.updateIPConfigState(uuid, types.PendingRelease, existingIpConfig.PodInfo)
-			if err != nil {
-				return nil, err
-			}
+	if err := service.EndpointStateStore.Write(EndpointStoreKey, service.EndpointState); err != nil {
+		return fmt.Errorf("failed to write endpoint state to store: %w", err)
+	}
 
-			pendingReleasedIps[uuid] = updatedIPConfig
+	return nil
+}
 
-			if len(pendingReleasedIps) == totalIpsToRelease {
-				return pendingReleasedIps, nil
+// MarkIPAsPendingRelease will set the IPs which are in PendingProgramming, Quarantined or Available to PendingRelease
+// state, in that order, so that quarantined IPs are returned to the subnet before any healthy one.
+// It will try to update [totalIpsToRelease]  number of ips.
+func (service *HTTPRestService) MarkIPAsPendingRelease(totalIpsToRelease int) (map[string]cns.IPConfigurationStatus, error) {
+	pendingReleasedIps := make(map[string]cns.IPConfigurationStatus)
+	service.Lock()
+	defer service.Unlock()
+
+	for _, state := range []types.IPState{types.PendingProgramming, types.Quarantined, types.Available} {
+		for uuid, existingIpConfig := range service.PodIPConfigState {
+			if existingIpConfig.GetState() == state {
+				updatedIPConfig, err := service.updateIPConfigState(uuid, types.PendingRelease, existingIpConfig.PodInfo)
+				if err != nil {
+					return nil, err
+				}
+
+				pendingReleasedIps[uuid] = updatedIPConfig
+				if len(pendingReleasedIps) == totalIpsToRelease {
+					return pendingReleasedIps, nil
+				}
 			}
 		}
 	}
@@ -312,6 +473,13 @@ func (service *HTTPRestService) MarkIpsAsAvailableUntransacted(ncID string, newH
 					ncInfo.CreateNetworkContainerRequest.SecondaryIPConfigs[uuid] = secondaryIPConfigs
 					logger.Printf("Change ip %s with uuid %s from pending programming to %s, current secondary ip configs is %+v", ipConfigStatus.IPAddress, uuid, types.Available,
 						ncInfo.CreateNetworkContainerRequest.SecondaryIPConfigs[uuid])
+				} else if q, quarantined := service.quarantinedIPs[uuid]; quarantined && q.previous == types.PendingProgramming &&
+					secondaryIPConfigs.NCVersion <= newHostNCVersion {
+					// the quarantined IP has been programmed, so it is Available once released.
+					q.previous = types.Available
+					service.quarantinedIPs[uuid] = q
+					secondaryIPConfigs.NCVersion = newHostNCVersion
+					ncInfo.CreateNetworkContainerRequest.SecondaryIPConfigs[uuid] = secondaryIPConfigs
 				}
 			}
 		}
@@ -373,6 +541,107 @@ func (service *HTTPRestService) handleDebugIPAddresses(w http.ResponseWriter, r
 	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
 }
 
+func (service *HTTPRestService) handleDebugPodIPAssignments(w http.ResponseWriter, r *http.Request) {
+	var req cns.GetPodIPAssignmentsRequest
+	if err := service.Listener.Decode(w, r, &req); err != nil {
+		resp := cns.GetPodIPAssignmentsResponse{
+			Response: cns.Response{
+				ReturnCode: types.UnexpectedError,
+				Message:    err.Error(),
+			},
+		}
+		err = service.Listener.Encode(w, &resp)
+		logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+		return
+	}
+
+	service.RLock()
+	assignments, next, err := podIPAssignments(service.PodIPConfigState, &req)
+	service.RUnlock()
+
+	resp := cns.GetPodIPAssignmentsResponse{
+		PodIPAssignments: assignments,
+		Continue:         next,
+	}
+	if err != nil {
+		resp = cns.GetPodIPAssignmentsResponse{
+			Response: cns.Response{
+				ReturnCode: types.InvalidParameter,
+				Message:    err.Error(),
+			},
+		}
+	}
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
+// podIPAssignments returns the page of the IPs matching the request, ordered by IP address, and the token to request
+// the next page with. The token is the last IP address of the page when more IPs match the request.
+func podIPAssignments(ipConfigs map[string]cns.IPConfigurationStatus, req *cns.GetPodIPAssignmentsRequest) ([]cns.PodIPAssignment, string, error) {
+	if req.Limit < 0 {
+		return nil, "", errors.Errorf("invalid limit %d", req.Limit)
+	}
+
+	var after net.IP
+	if req.Continue != "" {
+		if after = net.ParseIP(req.Continue); after == nil {
+			return nil, "", errors.Errorf("invalid continue token %s", req.Continue)
+		}
+	}
+
+	states := make(map[types.IPState]bool, len(req.IPConfigStateFilter))
+	for _, state := range req.IPConfigStateFilter {
+		states[state] = true
+	}
+
+	assignments := []cns.PodIPAssignment{}
+	for _, ipConfig := range ipConfigs { //nolint:gocritic // ignore copy
+		assignment := cns.PodIPAssignment{
+			IPAddress:           ipConfig.IPAddress,
+			IPConfigID:          ipConfig.ID,
+			NCID:                ipConfig.NCID,
+			State:               ipConfig.GetState(),
+			LastStateTransition: ipConfig.LastStateTransition,
+		}
+		if ipConfig.PodInfo != nil {
+			assignment.PodNamespace = ipConfig.PodInfo.Namespace()
+			assignment.PodName = ipConfig.PodInfo.Name()
+			assignment.InfraContainerID = ipConfig.PodInfo.InfraContainerID()
+			assignment.PodInterfaceID = ipConfig.PodInfo.InterfaceID()
+		}
+
+		if (len(states) > 0 && !states[assignment.State]) ||
+			(req.PodNamespace != "" && req.PodNamespace != assignment.PodNamespace) ||
+			(req.PodName != "" && req.PodName != assignment.PodName) ||
+			(req.InfraContainerID != "" && req.InfraContainerID != assignment.InfraContainerID) ||
+			(req.NCID != "" && !strings.EqualFold(req.NCID, assignment.NCID)) {
+			continue
+		}
+
+		if after != nil && compareIPs(net.ParseIP(assignment.IPAddress), after) <= 0 {
+			continue
+		}
+
+		assignments = append(assignments, assignment)
+	}
+
+	sort.Slice(assignments, func(i, j int) bool {
+		return compareIPs(net.ParseIP(assignments[i].IPAddress), net.ParseIP(assignments[j].IPAddress)) < 0
+	})
+
+	if req.Limit == 0 || len(assignments) <= req.Limit {
+		return assignments, "", nil
+	}
+
+	assignments = assignments[:req.Limit]
+	return assignments, assignments[req.Limit-1].IPAddress, nil
+}
+
+// compareIPs orders IPs by their 16-byte representation.
+func compareIPs(a, b net.IP) int {
+	return bytes.Compare(a.To16(), b.To16())
+}
+
 // GetAssignedIPConfigs returns a filtered list of IPs which are in
 // Assigned State.
 func (service *HTTPRestService) GetAssignedIPConfigs() []cns.IPConfigurationStatus {
@@ -416,25 +685,43 @@ func (service *HTTPRestService) assignIPConfig(ipconfig cns.IPConfigurationStatu
 	return nil
 }
 
-// unassignIPConfig unassigns the ipconfig from the passed Pod, sets the state as Available, does not take a lock.
+// unassignIPConfig unassigns the ipconfig from the passed Pod, sets the state as Available, or as Quarantined if the
+// IP was quarantined while assigned, does not take a lock.
 func (service *HTTPRestService) unassignIPConfig(ipconfig cns.IPConfigurationStatus, podInfo cns.PodInfo) (cns.IPConfigurationStatus, error) { //nolint:gocritic // ignore hugeparam
-	ipconfig, err := service.updateIPConfigState(ipconfig.ID, types.Available, nil)
+	state := types.Available
+	if q, quarantined := service.quarantinedIPs[ipconfig.ID]; quarantined {
+		q.previous = types.Available
+		service.quarantinedIPs[ipconfig.ID] = q
+		state = types.Quarantined
+	}
+
+	ipconfig, err := service.updateIPConfigState(ipconfig.ID, state, nil)
 	if err != nil {
 		return cns.IPConfigurationStatus{}, err
 	}
 
 	delete(service.PodIPIDByPodInterfaceKey, podInfo.Key())
-	logger.Printf("[setIPConfigAsAvailable] Deleted outdated pod info %s from PodIPIDByOrchestratorContext since IP %s with ID %s will be released and set as Available",
-		podInfo.Key(), ipconfig.IPAddress, ipconfig.ID)
+	logger.Printf("[setIPConfigAsAvailable] Deleted outdated pod info %s from PodIPIDByOrchestratorContext since IP %s with ID %s will be released and set as %s",
+		podInfo.Key(), ipconfig.IPAddress, ipconfig.ID, state)
 	return ipconfig, nil
 }
 
+// notifyPoolMonitor tells the IPAM pool monitor, if there is one, that IPs have been assigned or released.
+func (service *HTTPRestService) notifyPoolMonitor() {
+	if service.IPAMPoolMonitor != nil {
+		service.IPAMPoolMonitor.Notify()
+	}
+}
+
 // Todo - CNI should also pass the IPAddress which needs to be released to validate if that is the right IP allcoated
 // in the first place.
 func (service *HTTPRestService) releaseIPConfig(podInfo cns.PodInfo) error {
 	service.Lock()
 	defer service.Unlock()
 
+	// the host ports of the pod are released with its IP, also when it has none.
+	service.releaseHostPortsUntransacted(podInfo.InterfaceID())
+
 	ipID := service.PodIPIDByPodInterfaceKey[podInfo.Key()]
 	if ipID != "" {
 		if ipconfig, isExist := service.PodIPConfigState[ipID]; isExist {
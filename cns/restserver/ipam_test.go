@@ -192,6 +192,42 @@ func TestEndpointStateReadAndWrite(t *testing.T) {
 	assert.Equal(t, desiredState, svc.EndpointState)
 }
 
+func TestEndpointHostInterfaces(t *testing.T) {
+	svc := getTestService()
+	_, ipnet, _ := net.ParseCIDR(testIP1 + "/24")
+	svc.EndpointState = map[string]*EndpointInfo{
+		testPod1Info.InfraContainerID(): {
+			PodName:       testPod1Info.Name(),
+			PodNamespace:  testPod1Info.Namespace(),
+			IfnameToIPMap: map[string]*IPInfo{"eth0": {IPv4: []net.IPNet{*ipnet}}},
+		},
+	}
+
+	err := svc.updateEndpointHostInterfaces(testPod1Info.InfraContainerID(), map[string]*IPInfo{
+		"eth0": {HostVethName: "azv1234567", IPv4: []net.IPNet{}},
+	})
+	if err != nil {
+		t.Fatalf("Expected to not fail updating host interfaces: %+v", err)
+	}
+
+	endpointInfo, err := svc.getEndpointState(testPod1Info.InfraContainerID())
+	if err != nil {
+		t.Fatalf("Expected to not fail getting endpoint state: %+v", err)
+	}
+	assert.Equal(t, "azv1234567", endpointInfo.IfnameToIPMap["eth0"].HostVethName)
+	assert.Equal(t, []net.IPNet{*ipnet}, endpointInfo.IfnameToIPMap["eth0"].IPv4, "the addresses are not updated")
+
+	// the returned state is a copy
+	endpointInfo.IfnameToIPMap["eth0"].HostVethName = ""
+	assert.Equal(t, "azv1234567", svc.EndpointState[testPod1Info.InfraContainerID()].IfnameToIPMap["eth0"].HostVethName)
+
+	_, err = svc.getEndpointState("unknown")
+	assert.ErrorIs(t, err, errEndpointNotFound)
+
+	err = svc.updateEndpointHostInterfaces("unknown", map[string]*IPInfo{"eth0": {HostVethName: "azv1234567"}})
+	assert.ErrorIs(t, err, errEndpointNotFound)
+}
+
 // Want first IP
 func TestIPAMGetAvailableIPConfig(t *testing.T) {
 	svc := getTestService()
@@ -782,3 +818,66 @@ func TestIPAMMarkExistingIPConfigAsPending(t *testing.T) {
 		t.Fatalf("Expected to see ID %v in pending release ipconfigs, actual %+v", testPod1GUID, assignedIPConfigs)
 	}
 }
+
+func TestPodIPAssignments(t *testing.T) {
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testPod1GUID, testNCID, types.Assigned, 24, 0, testPod1Info)
+	state2, _ := NewPodStateWithOrchestratorContext(testIP2, testPod2GUID, testNCID, types.Assigned, 24, 0, testPod2Info)
+	state3 := NewPodState("10.0.0.10", 24, testPod3GUID, testNCID, types.Available, 0)
+	ipconfigs := map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+		state3.ID: state3,
+	}
+
+	// All IPs are returned ordered by address.
+	assignments, next, err := podIPAssignments(ipconfigs, &cns.GetPodIPAssignmentsRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Len(t, assignments, 3)
+	assert.Equal(t, []string{testIP1, testIP2, "10.0.0.10"},
+		[]string{assignments[0].IPAddress, assignments[1].IPAddress, assignments[2].IPAddress})
+	assert.Equal(t, cns.PodIPAssignment{
+		PodNamespace:        "testpod1namespace",
+		PodName:             "testpod1",
+		InfraContainerID:    "898fb8-eth0",
+		PodInterfaceID:      testPod1GUID,
+		IPAddress:           testIP1,
+		IPConfigID:          testPod1GUID,
+		NCID:                testNCID,
+		State:               types.Assigned,
+		LastStateTransition: state1.LastStateTransition,
+	}, assignments[0])
+	assert.Empty(t, assignments[2].PodName)
+
+	// Filters.
+	assignments, _, err = podIPAssignments(ipconfigs, &cns.GetPodIPAssignmentsRequest{PodNamespace: "testpod2namespace"})
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 1)
+	assert.Equal(t, testIP2, assignments[0].IPAddress)
+
+	assignments, _, err = podIPAssignments(ipconfigs, &cns.GetPodIPAssignmentsRequest{IPConfigStateFilter: []types.IPState{types.Available}})
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 1)
+	assert.Equal(t, "10.0.0.10", assignments[0].IPAddress)
+
+	assignments, _, err = podIPAssignments(ipconfigs, &cns.GetPodIPAssignmentsRequest{NCID: "other-nc"})
+	assert.NoError(t, err)
+	assert.Empty(t, assignments)
+
+	// Pagination.
+	assignments, next, err = podIPAssignments(ipconfigs, &cns.GetPodIPAssignmentsRequest{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 2)
+	assert.Equal(t, testIP2, next)
+
+	assignments, next, err = podIPAssignments(ipconfigs, &cns.GetPodIPAssignmentsRequest{Limit: 2, Continue: next})
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 1)
+	assert.Equal(t, "10.0.0.10", assignments[0].IPAddress)
+	assert.Empty(t, next)
+
+	_, _, err = podIPAssignments(ipconfigs, &cns.GetPodIPAssignmentsRequest{Limit: -1})
+	assert.Error(t, err)
+	_, _, err = podIPAssignments(ipconfigs, &cns.GetPodIPAssignmentsRequest{Continue: "not-an-ip"})
+	assert.Error(t, err)
+}
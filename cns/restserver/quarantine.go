@@ -0,0 +1,217 @@
+package restserver
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+var (
+	errIPNotInPool       = errors.New("IP not found in the pool")
+	errIPNotQuarantined  = errors.New("IP is not quarantined")
+	errNoIPsToQuarantine = errors.New("no IPs to quarantine")
+	errNoIPsToRelease    = errors.New("no quarantined IPs to release")
+)
+
+// ipQuarantine is why and since when an IP is quarantined, and the state the IP returns to when it is released.
+// Quarantines are kept in memory only: a restarted CNS rebuilds the pool from the NC and assigns the IPs again.
+type ipQuarantine struct {
+	reason   string
+	since    time.Time
+	previous types.IPState
+}
+
+func (service *HTTPRestService) quarantineIPsHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.QuarantineIPsRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name+"quarantineIPsHandler", req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.QuarantinedIPsResponse
+	if resp.QuarantinedIPs, err = service.quarantineIPs(req.IPAddresses, req.Reason); err != nil {
+		resp.Response = cns.Response{
+			ReturnCode: types.InvalidParameter,
+			Message:    err.Error(),
+		}
+	} else {
+		// the pool grows to make up for the quarantined IPs.
+		service.notifyPoolMonitor()
+	}
+	w.Header().Set(cnsReturnCode, resp.Response.ReturnCode.String())
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
+func (service *HTTPRestService) releaseQuarantinedIPsHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.ReleaseQuarantinedIPsRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name+"releaseQuarantinedIPsHandler", req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.QuarantinedIPsResponse
+	if resp.QuarantinedIPs, err = service.releaseQuarantinedIPs(req.IPAddresses, req.All); err != nil {
+		resp.Response = cns.Response{
+			ReturnCode: types.InvalidParameter,
+			Message:    err.Error(),
+		}
+	} else {
+		service.notifyPoolMonitor()
+	}
+	w.Header().Set(cnsReturnCode, resp.Response.ReturnCode.String())
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
+func (service *HTTPRestService) handleDebugQuarantinedIPs(w http.ResponseWriter, r *http.Request) {
+	service.RLock()
+	resp := cns.QuarantinedIPsResponse{
+		QuarantinedIPs: make([]cns.QuarantinedIP, 0, len(service.quarantinedIPs)),
+	}
+	for ipID := range service.quarantinedIPs {
+		resp.QuarantinedIPs = append(resp.QuarantinedIPs, service.quarantinedIPUntransacted(ipID))
+	}
+	service.RUnlock()
+
+	sortQuarantinedIPs(resp.QuarantinedIPs)
+	err := service.Listener.Encode(w, &resp)
+	logger.Response(service.Name, resp, resp.Response.ReturnCode, err)
+}
+
+// quarantineIPs quarantines the IPs with the addresses, or updates the reason of those already quarantined.
+// Available and PendingProgramming IPs are set Quarantined at once, IPs assigned to Pods when the Pods release
+// them, and IPs pending release are left to be removed from the NC.
+func (service *HTTPRestService) quarantineIPs(ipAddresses []string, reason string) ([]cns.QuarantinedIP, error) {
+	if len(ipAddresses) == 0 {
+		return nil, errNoIPsToQuarantine
+	}
+
+	service.Lock()
+	defer service.Unlock()
+
+	ipIDs, err := service.ipIDsByAddressUntransacted(ipAddresses)
+	if err != nil {
+		return nil, err
+	}
+
+	if service.quarantinedIPs == nil {
+		service.quarantinedIPs = make(map[string]ipQuarantine)
+	}
+
+	quarantined := make([]cns.QuarantinedIP, 0, len(ipIDs))
+	for _, ipID := range ipIDs {
+		q, exists := service.quarantinedIPs[ipID]
+		if !exists {
+			q = ipQuarantine{since: time.Now(), previous: types.Available}
+		}
+		q.reason = reason
+
+		ipConfig := service.PodIPConfigState[ipID]
+		state := ipConfig.GetState()
+		if state == types.Available || state == types.PendingProgramming {
+			q.previous = state
+			if _, err := service.updateIPConfigState(ipID, types.Quarantined, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		service.quarantinedIPs[ipID] = q
+		logger.Printf("[quarantineIPs] Quarantined IP %s with ID %s in state %s: %s", ipConfig.IPAddress, ipID, state, reason)
+		quarantined = append(quarantined, service.quarantinedIPUntransacted(ipID))
+	}
+
+	sortQuarantinedIPs(quarantined)
+	return quarantined, nil
+}
+
+// releaseQuarantinedIPs releases the quarantined IPs with the addresses, or all of them, back to the state they were
+// quarantined in. It returns the released IPs.
+func (service *HTTPRestService) releaseQuarantinedIPs(ipAddresses []string, all bool) ([]cns.QuarantinedIP, error) {
+	service.Lock()
+	defer service.Unlock()
+
+	var ipIDs []string
+	if all {
+		for ipID := range service.quarantinedIPs {
+			ipIDs = append(ipIDs, ipID)
+		}
+	} else {
+		if len(ipAddresses) == 0 {
+			return nil, errNoIPsToRelease
+		}
+
+		var err error
+		if ipIDs, err = service.ipIDsByAddressUntransacted(ipAddresses); err != nil {
+			return nil, err
+		}
+		for i, ipID := range ipIDs {
+			if _, ok := service.quarantinedIPs[ipID]; !ok {
+				return nil, errors.Wrap(errIPNotQuarantined, ipAddresses[i])
+			}
+		}
+	}
+
+	released := make([]cns.QuarantinedIP, 0, len(ipIDs))
+	for _, ipID := range ipIDs {
+		q := service.quarantinedIPs[ipID]
+		ipConfig := service.PodIPConfigState[ipID]
+		if ipConfig.GetState() == types.Quarantined {
+			if _, err := service.updateIPConfigState(ipID, q.previous, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		released = append(released, service.quarantinedIPUntransacted(ipID))
+		delete(service.quarantinedIPs, ipID)
+		logger.Printf("[releaseQuarantinedIPs] Released IP %s with ID %s from quarantine", ipConfig.IPAddress, ipID)
+	}
+
+	sortQuarantinedIPs(released)
+	return released, nil
+}
+
+// ipIDsByAddressUntransacted returns the IDs of the IPs with the addresses, in the same order.
+func (service *HTTPRestService) ipIDsByAddressUntransacted(ipAddresses []string) ([]string, error) {
+	ipIDByAddress := make(map[string]string, len(service.PodIPConfigState))
+	for ipID := range service.PodIPConfigState {
+		ipIDByAddress[service.PodIPConfigState[ipID].IPAddress] = ipID
+	}
+
+	ipIDs := make([]string, len(ipAddresses))
+	for i, ipAddress := range ipAddresses {
+		ipID, ok := ipIDByAddress[ipAddress]
+		if !ok {
+			return nil, errors.Wrap(errIPNotInPool, ipAddress)
+		}
+		ipIDs[i] = ipID
+	}
+	return ipIDs, nil
+}
+
+func (service *HTTPRestService) quarantinedIPUntransacted(ipID string) cns.QuarantinedIP {
+	ipConfig := service.PodIPConfigState[ipID]
+	q := service.quarantinedIPs[ipID]
+	return cns.QuarantinedIP{
+		IPAddress:  ipConfig.IPAddress,
+		IPConfigID: ipID,
+		NCID:       ipConfig.NCID,
+		State:      ipConfig.GetState(),
+		Reason:     q.reason,
+		Since:      q.since,
+	}
+}
+
+func sortQuarantinedIPs(ips []cns.QuarantinedIP) {
+	sort.Slice(ips, func(i, j int) bool {
+		return compareIPs(net.ParseIP(ips[i].IPAddress), net.ParseIP(ips[j].IPAddress)) < 0
+	})
+}
@@ -0,0 +1,85 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantinedIPsAreNotAssigned(t *testing.T) {
+	svc := getTestService()
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testPod1GUID, testNCID, types.Assigned, 24, 0, testPod1Info)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+	ipconfigs := map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+	}
+	require.NoError(t, UpdatePodIpConfigState(t, svc, ipconfigs))
+
+	_, err := svc.quarantineIPs([]string{testIP2, "10.0.0.100"}, "dad")
+	require.ErrorIs(t, err, errIPNotInPool)
+
+	quarantined, err := svc.quarantineIPs([]string{testIP2, testIP1}, "duplicate address detected")
+	require.NoError(t, err)
+	require.Len(t, quarantined, 2)
+	assert.Equal(t, testIP1, quarantined[0].IPAddress)
+	assert.Equal(t, types.Assigned, quarantined[0].State)
+	assert.Equal(t, testIP2, quarantined[1].IPAddress)
+	assert.Equal(t, types.Quarantined, quarantined[1].State)
+	assert.Equal(t, "duplicate address detected", quarantined[1].Reason)
+
+	// the quarantined IP isn't assigned to a new Pod, also when desired.
+	_, err = svc.AssignAnyAvailableIPConfig(testPod3Info)
+	require.Error(t, err)
+	_, err = svc.AssignDesiredIPConfig(testPod3Info, testIP2)
+	require.Error(t, err)
+
+	// the IP assigned when quarantined is quarantined once released by its Pod.
+	require.NoError(t, svc.releaseIPConfig(testPod1Info))
+	ipConfig := svc.PodIPConfigState[testPod1GUID]
+	assert.Equal(t, types.Quarantined, ipConfig.GetState())
+
+	_, err = svc.releaseQuarantinedIPs([]string{testIP2, testIP3}, false)
+	require.ErrorIs(t, err, errIPNotInPool)
+
+	released, err := svc.releaseQuarantinedIPs([]string{testIP2}, false)
+	require.NoError(t, err)
+	require.Len(t, released, 1)
+	assert.Equal(t, types.Available, released[0].State)
+	_, err = svc.releaseQuarantinedIPs([]string{testIP2}, false)
+	require.ErrorIs(t, err, errIPNotQuarantined)
+
+	podIPInfo, err := svc.AssignAnyAvailableIPConfig(testPod3Info)
+	require.NoError(t, err)
+	assert.Equal(t, testIP2, podIPInfo.PodIPConfig.IPAddress)
+
+	released, err = svc.releaseQuarantinedIPs(nil, true)
+	require.NoError(t, err)
+	require.Len(t, released, 1)
+	assert.Equal(t, testIP1, released[0].IPAddress)
+	assert.Empty(t, svc.quarantinedIPs)
+}
+
+func TestQuarantinedIPsAreReleasedFirst(t *testing.T) {
+	svc := getTestService()
+	ipconfigs := map[string]cns.IPConfigurationStatus{}
+	for _, ip := range []struct{ id, address string }{{testPod1GUID, testIP1}, {testPod2GUID, testIP2}, {testPod3GUID, testIP3}} {
+		ipconfigs[ip.id] = NewPodState(ip.address, 24, ip.id, testNCID, types.Available, 0)
+	}
+	require.NoError(t, UpdatePodIpConfigState(t, svc, ipconfigs))
+
+	_, err := svc.quarantineIPs([]string{testIP3}, "programming failed")
+	require.NoError(t, err)
+
+	pending, err := svc.MarkIPAsPendingRelease(1)
+	require.NoError(t, err)
+	require.Contains(t, pending, testPod3GUID)
+
+	// the quarantine is cleared when the IP is removed from the NC.
+	delete(ipconfigs, testPod3GUID)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, ipconfigs))
+	assert.Empty(t, svc.quarantinedIPs)
+}
@@ -49,6 +49,12 @@ type nmagentClient interface {
 	GetHomeAz(context.Context) (nma.AzResponse, error)
 }
 
+// WireguardPeerStore exchanges the wireguard peers of the nodes of the cluster.
+type WireguardPeerStore interface {
+	PublishPublicKey(ctx context.Context, publicKey string) error
+	ListPeers(ctx context.Context) ([]cns.WireguardPeer, error)
+}
+
 // HTTPRestService represents http listener for CNS - Container Networking Service.
 type HTTPRestService struct {
 	*cns.Service
@@ -60,6 +66,8 @@ type HTTPRestService struct {
 	networkContainer         *networkcontainers.NetworkContainers
 	PodIPIDByPodInterfaceKey map[string]string                    // PodInterfaceId is key and value is Pod IP (SecondaryIP) uuid.
 	PodIPConfigState         map[string]cns.IPConfigurationStatus // Secondary IP ID(uuid) is key
+	quarantinedIPs           map[string]ipQuarantine              // Secondary IP ID(uuid) is key
+	hostPorts                hostPortReservations
 	IPAMPoolMonitor          cns.IPAMPoolMonitor
 	routingTable             *routes.RoutingTable
 	store                    store.KeyValueStore
@@ -71,6 +79,11 @@ type HTTPRestService struct {
 	EndpointStateStore      store.KeyValueStore
 	cniConflistGenerator    CNIConflistGenerator
 	generateCNIConflistOnce sync.Once
+	HandoffStore            store.KeyValueStore // the IPAM state is handed off to the next CNS process through it on Drain
+	drainLock               sync.RWMutex
+	draining                bool
+	ipamRequestsInFlight    sync.WaitGroup
+	WireguardPeerStore      WireguardPeerStore // only set in CRD mode, where CNS has access to the Node objects
 }
 
 type CNIConflistGenerator interface {
@@ -97,6 +110,14 @@ type EndpointInfo struct {
 type IPInfo struct {
 	IPv4 []net.IPNet
 	IPv6 []net.IPNet
+	// The host side of the interface is recorded by the CNI in stateless mode to delete the interface later.
+	HostVethName  string `json:",omitempty"`
+	HnsEndpointID string `json:",omitempty"`
+}
+
+type GetEndpointResponse struct {
+	Response     Response     `json:"response"`
+	EndpointInfo EndpointInfo `json:"endpointInfo"`
 }
 
 type GetHTTPServiceDataResponse struct {
@@ -255,8 +276,18 @@ func (service *HTTPRestService) Init(config *common.ServiceConfig) error {
 	listener.AddHandler(cns.PathDebugIPAddresses, service.handleDebugIPAddresses)
 	listener.AddHandler(cns.PathDebugPodContext, service.handleDebugPodContext)
 	listener.AddHandler(cns.PathDebugRestData, service.handleDebugRestData)
+	listener.AddHandler(cns.PathDebugPodIPAssignments, service.handleDebugPodIPAssignments)
+	listener.AddHandler(cns.PathDebugQuarantinedIPs, service.handleDebugQuarantinedIPs)
+	listener.AddHandler(cns.QuarantineIPs, service.quarantineIPsHandler)
+	listener.AddHandler(cns.ReleaseQuarantinedIPs, service.releaseQuarantinedIPsHandler)
+	listener.AddHandler(cns.ReserveHostPorts, service.reserveHostPortsHandler)
+	listener.AddHandler(cns.ReleaseHostPorts, service.releaseHostPortsHandler)
+	listener.AddHandler(cns.PathDebugHostPorts, service.handleDebugHostPorts)
 	listener.AddHandler(cns.NetworkContainersURLPath, service.getOrRefreshNetworkContainers)
+	listener.AddHandler(cns.NCProgrammingStatusPath, service.getNCProgrammingStatus)
 	listener.AddHandler(cns.GetHomeAz, service.getHomeAz)
+	listener.AddHandler(cns.WireguardPeers, service.wireguardPeers)
+	listener.AddHandler(cns.EndpointPath, service.endpointHandlerAPI)
 
 	// handlers for v0.2
 	listener.AddHandler(cns.V2Prefix+cns.SetEnvironmentPath, service.setEnvironment)
@@ -281,6 +312,7 @@ func (service *HTTPRestService) Init(config *common.ServiceConfig) error {
 	listener.AddHandler(cns.V2Prefix+cns.DeleteHostNCApipaEndpointPath, service.deleteHostNCApipaEndpoint)
 	listener.AddHandler(cns.V2Prefix+cns.NmAgentSupportedApisPath, service.nmAgentSupportedApisHandler)
 	listener.AddHandler(cns.V2Prefix+cns.GetHomeAz, service.getHomeAz)
+	listener.AddHandler(cns.V2Prefix+cns.WireguardPeers, service.wireguardPeers)
 
 	// Initialize HTTP client to be reused in CNS
 	connectionTimeout, _ := service.GetOption(acn.OptHttpConnectionTimeout).(int)
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
@@ -347,6 +348,7 @@ func (service *HTTPRestService) removeToBeDeletedIPStateUntransacted(
 		ipID,
 		service.PodIPConfigState[ipID])
 	delete(service.PodIPConfigState, ipID)
+	delete(service.quarantinedIPs, ipID)
 	return 0, ""
 }
 
@@ -761,6 +763,7 @@ func (service *HTTPRestService) populateIPConfigInfoUntransacted(ipConfigStatus
 	}
 
 	podIPInfo.NetworkContainerPrimaryIPConfig = primaryIPCfg
+	podIPInfo.NetworkContainerID = ipConfigStatus.NCID
 	primaryHostInterface, err := service.getPrimaryHostInterface(context.TODO())
 	if err != nil {
 		return err
@@ -832,6 +835,50 @@ func (service *HTTPRestService) isNCWaitingForUpdate(
 	return false, types.NetworkContainerVfpProgramComplete, msg
 }
 
+// ncProgrammingStatusesUntransacted returns the programming status of the NCs, or of all of them if ncIDs is empty.
+func (service *HTTPRestService) ncProgrammingStatusesUntransacted(ncIDs []string) ([]cns.NCProgrammingStatus, error) {
+	if len(ncIDs) == 0 {
+		for ncID := range service.state.ContainerStatus {
+			ncIDs = append(ncIDs, ncID)
+		}
+		sort.Strings(ncIDs)
+	}
+
+	statuses := make([]cns.NCProgrammingStatus, 0, len(ncIDs))
+	for _, ncID := range ncIDs {
+		ncStatus, ok := service.state.ContainerStatus[ncID]
+		if !ok {
+			return nil, errors.Wrap(errUnknownNC, ncID)
+		}
+		statuses = append(statuses, ncProgrammingStatus(&ncStatus))
+	}
+	return statuses, nil
+}
+
+// ncProgrammingStatus compares the version of the NC with the version NMAgent reported programmed on the NIC, which
+// SyncHostNCVersion keeps up to date. An NC NMAgent reported VFP programming complete for is programmed as well.
+func ncProgrammingStatus(ncStatus *containerstatus) cns.NCProgrammingStatus {
+	status := cns.NCProgrammingStatus{
+		NetworkContainerID: ncStatus.ID,
+		Version:            ncStatus.CreateNetworkContainerRequest.Version,
+		ProgrammedVersion:  ncStatus.HostVersion,
+		State:              cns.NCProgrammingUnknown,
+	}
+
+	ncVersion, ncErr := strconv.Atoi(status.Version)
+	hostVersion, hostErr := strconv.Atoi(status.ProgrammedVersion)
+	switch {
+	case ncStatus.VfpUpdateComplete:
+		status.State = cns.NCProgrammingSucceeded
+	case ncErr != nil || hostErr != nil:
+	case hostVersion >= ncVersion:
+		status.State = cns.NCProgrammingSucceeded
+	default:
+		status.State = cns.NCProgrammingPending
+	}
+	return status
+}
+
 // handleGetNetworkContainers returns all NCs in CNS
 func (service *HTTPRestService) handleGetNetworkContainers(w http.ResponseWriter) {
 	logger.Printf("[Azure CNS] handleGetNetworkContainers")
@@ -3,6 +3,7 @@ package restserver
 import (
 	"testing"
 
+	"github.com/Azure/azure-container-networking/cns"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -50,3 +51,38 @@ func TestAreNCsPresent(t *testing.T) {
 		})
 	}
 }
+
+func TestNCProgrammingStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		ncStatus containerstatus
+		want     cns.NCProgrammingState
+	}{
+		{
+			name:     "host version behind",
+			ncStatus: containerstatus{HostVersion: "1", CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{Version: "2"}},
+			want:     cns.NCProgrammingPending,
+		},
+		{
+			name:     "host version current",
+			ncStatus: containerstatus{HostVersion: "2", CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{Version: "2"}},
+			want:     cns.NCProgrammingSucceeded,
+		},
+		{
+			name:     "vfp programming complete",
+			ncStatus: containerstatus{HostVersion: "-1", VfpUpdateComplete: true, CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{Version: "2"}},
+			want:     cns.NCProgrammingSucceeded,
+		},
+		{
+			name:     "host version not reported",
+			ncStatus: containerstatus{CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{Version: "2"}},
+			want:     cns.NCProgrammingUnknown,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ncProgrammingStatus(&tt.ncStatus).State)
+		})
+	}
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/Azure/azure-container-networking/cns/multitenantcontroller/multitenantoperator"
 	"github.com/Azure/azure-container-networking/cns/restserver"
 	cnstypes "github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/cns/wireguardpeers"
 	"github.com/Azure/azure-container-networking/cns/wireserver"
 	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/crd"
@@ -44,6 +45,7 @@ import (
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/Azure/azure-container-networking/fs"
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/nmagent"
 	"github.com/Azure/azure-container-networking/platform"
@@ -54,15 +56,20 @@ import (
 	"github.com/avast/retry-go/v3"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	kuberuntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 const (
@@ -71,6 +78,7 @@ const (
 	pluginName                        = "azure-vnet"
 	endpointStoreName                 = "azure-endpoints"
 	endpointStoreLocation             = "/var/run/azure-cns/"
+	handoffStoreName                  = "azure-cns-handoff"
 	defaultCNINetworkConfigFileName   = "10-azure.conflist"
 	dncApiVersion                     = "?api-version=2018-03-01"
 	poolIPAMRefreshRateInMilliseconds = 1000
@@ -140,6 +148,17 @@ var args = acn.ArgumentList{
 			acn.OptLogMultiWrite:   log.TargetStdOutAndLogFile,
 		},
 	},
+	{
+		Name:         acn.OptLogFormat,
+		Shorthand:    acn.OptLogFormatAlias,
+		Description:  "Set the logging format",
+		Type:         "int",
+		DefaultValue: acn.OptLogFormatText,
+		ValueMap: map[string]interface{}{
+			acn.OptLogFormatText: log.FormatText,
+			acn.OptLogFormatJSON: log.FormatJSON,
+		},
+	},
 	{
 		Name:         acn.OptLogLocation,
 		Shorthand:    acn.OptLogLocationAlias,
@@ -394,13 +413,21 @@ func sendRegisterNodeRequest(httpc *http.Client, httpRestService cns.HTTPService
 	return nil
 }
 
-func startTelemetryService(ctx context.Context) {
+func startTelemetryService(ctx context.Context, ts configuration.TelemetrySettings) {
 	var config aitelemetry.AIConfig
 
 	err := telemetry.CreateAITelemetryHandle(config, false, false, false)
 	if err != nil {
-		log.Errorf("AI telemetry handle creation failed..:%w", err)
-		return
+		log.Errorf("AI telemetry handle creation failed..:%v", err)
+		if !ts.OTLP.Enabled() {
+			return
+		}
+	}
+
+	if ts.OTLP.Enabled() {
+		if err = telemetry.CreateOTLPTelemetryHandle(telemetry.TelemetryServiceProcessName, version, ts.OTLP); err != nil {
+			log.Errorf("OTLP telemetry handle creation failed..:%v", err)
+		}
 	}
 
 	tbtemp := telemetry.NewTelemetryBuffer()
@@ -410,7 +437,7 @@ func startTelemetryService(ctx context.Context) {
 	tb := telemetry.NewTelemetryBuffer()
 	err = tb.StartServer()
 	if err != nil {
-		log.Errorf("Telemetry service failed to start: %w", err)
+		log.Errorf("Telemetry service failed to start: %v", err)
 		return
 	}
 	tb.PushData(rootCtx)
@@ -428,6 +455,7 @@ func main() {
 	cnsURL := acn.GetArg(acn.OptCnsURL).(string)
 	logLevel := acn.GetArg(acn.OptLogLevel).(int)
 	logTarget := acn.GetArg(acn.OptLogTarget).(int)
+	logFormat := acn.GetArg(acn.OptLogFormat).(int)
 	logDirectory := acn.GetArg(acn.OptLogLocation).(string)
 	ipamQueryUrl := acn.GetArg(acn.OptIpamQueryUrl).(string)
 	ipamQueryInterval := acn.GetArg(acn.OptIpamQueryInterval).(int)
@@ -466,6 +494,12 @@ func main() {
 
 	// Create logging provider.
 	logger.InitLogger(name, logLevel, logTarget, logDirectory)
+	if err = logger.SetFormat(logFormat); err != nil {
+		fmt.Printf("Failed to set log format: %v\n", err)
+	}
+	if err = log.SetFormat(logFormat); err != nil {
+		fmt.Printf("Failed to set log format: %v\n", err)
+	}
 
 	if clientDebugCmd != "" {
 		err := cnscli.HandleCNSClientCommands(rootCtx, clientDebugCmd, clientDebugArg)
@@ -489,6 +523,10 @@ func main() {
 	configuration.SetCNSConfigDefaults(cnsconfig)
 	logger.Printf("[Azure CNS] Read config :%+v", cnsconfig)
 
+	logger.SetLogFileLimits(cnsconfig.LogSettings.MaxFileSizeInMB*1024*1024, cnsconfig.LogSettings.MaxFileCount) //nolint:gomnd // MB to bytes
+	logger.SetLogFileCompression(cnsconfig.LogSettings.CompressRotatedFiles)
+	logger.ReopenOnSignal(rootCtx)
+
 	var conflistGenerator restserver.CNIConflistGenerator
 	if cnsconfig.EnableCNIConflistGeneration {
 		writer, newWriterErr := fs.NewAtomicWriter(cnsconfig.CNIConflistFilepath)
@@ -506,6 +544,10 @@ func main() {
 		}
 	}
 
+	if err = iptables.RegisterMetrics(metrics.Registry); err != nil {
+		logger.Errorf("[Azure CNS] Failed to register iptables metrics: %v", err)
+	}
+
 	// start the health server
 	z, _ := zap.NewProduction()
 	go healthserver.Start(z, cnsconfig.MetricsBindAddress)
@@ -557,10 +599,14 @@ func main() {
 		} else {
 			logger.InitAI(aiConfig, ts.DisableTrace, ts.DisableMetric, ts.DisableEvent)
 		}
+
+		if ts.OTLP.Enabled() {
+			logger.InitOTLP(name, version, ts.OTLP, ts.DisableTrace, ts.DisableMetric, ts.DisableEvent)
+		}
 	}
 
 	if telemetryDaemonEnabled {
-		go startTelemetryService(rootCtx)
+		go startTelemetryService(rootCtx, cnsconfig.TelemetrySettings)
 	}
 
 	// Log platform information.
@@ -608,6 +654,14 @@ func main() {
 			logger.Errorf("Failed to create endpoint state store file: %s, due to error %v\n", storeFileName, err)
 			return
 		}
+
+		// The endpoints of the CNI statefile are added once to the store, for the CNI to run stateless.
+		if cnsconfig.MigrateCNIState {
+			if err = cnireconciler.MigrateCNIState(endpointStateStore); err != nil {
+				logger.Errorf("Failed to migrate CNI statefile to endpoint state store, err:%v.\n", err)
+				return
+			}
+		}
 	}
 
 	// Create CNS object.
@@ -831,6 +885,17 @@ func main() {
 	// block until process exiting
 	<-rootCtx.Done()
 
+	if cnsconfig.DrainSettings.Enable {
+		if svc, ok := httpRestService.(*restserver.HTTPRestService); ok {
+			logger.Printf("drain cns service")
+			drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cnsconfig.DrainSettings.TimeoutInSecs)*time.Second)
+			if err = svc.Drain(drainCtx); err != nil {
+				logger.Errorf("[Azure CNS] Failed to drain, err:%v", err)
+			}
+			cancel()
+		}
+	}
+
 	if len(strings.TrimSpace(createDefaultExtNetworkType)) > 0 {
 		if err := hnsclient.DeleteDefaultExtNetwork(); err == nil {
 			logger.Printf("[Azure CNS] Successfully deleted default ext network")
@@ -1011,6 +1076,39 @@ func reconcileInitialCNSState(ctx context.Context, cli nodeNetworkConfigGetter,
 	return nil
 }
 
+// loadHandoff creates the store CNS hands off its IPAM state through when it drains, and returns the snapshot the
+// previous CNS process handed off if it is valid. The snapshot is removed once read so that it is restored only once.
+func loadHandoff(httpRestServiceImplementation *restserver.HTTPRestService, maxAge time.Duration) (*restserver.HandoffSnapshot, error) {
+	handoffStoreLock, err := processlock.NewFileLock(platform.CNILockPath + handoffStoreName + store.LockExtension)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create handoff store lock")
+	}
+	if err = platform.CreateDirectory(endpointStoreLocation); err != nil {
+		return nil, errors.Wrapf(err, "failed to create directory %s", endpointStoreLocation)
+	}
+	handoffStore, err := store.NewJsonFileStore(endpointStoreLocation+handoffStoreName+".json", handoffStoreLock)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create handoff store")
+	}
+	httpRestServiceImplementation.HandoffStore = handoffStore
+
+	if !handoffStore.Exists() {
+		return nil, nil //nolint:nilnil // no handoff is not an error
+	}
+	defer handoffStore.Remove()
+
+	var snapshot restserver.HandoffSnapshot
+	if err = handoffStore.Read(restserver.HandoffStoreKey, &snapshot); err != nil {
+		logger.Errorf("[Azure CNS] Ignoring unreadable handoff, err:%v", err)
+		return nil, nil //nolint:nilnil // the state is restored from the other sources
+	}
+	if err = snapshot.Validate(maxAge, time.Now()); err != nil {
+		logger.Errorf("[Azure CNS] Ignoring handoff, err:%v", err)
+		return nil, nil //nolint:nilnil // the state is restored from the other sources
+	}
+	return &snapshot, nil
+}
+
 // InitializeCRDState builds and starts the CRD controllers.
 func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cnsconfig *configuration.CNSConfig) error {
 	// convert interface type to implementation type
@@ -1093,12 +1191,33 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 	scopedcli := nncctrl.NewScopedClient(nnccli, types.NamespacedName{Namespace: "kube-system", Name: nodeName})
 
 	clusterSubnetStateChan := make(chan v1alpha1.ClusterSubnetState)
+	if cnsconfig.DrainSettings.Enable {
+		snapshot, err := loadHandoff(httpRestServiceImplementation, time.Duration(cnsconfig.DrainSettings.HandoffMaxAgeInSecs)*time.Second) //nolint:govet // ignore err shadow
+		if err != nil {
+			return errors.Wrap(err, "failed to load handoff")
+		}
+		if snapshot != nil {
+			logger.Printf("Initializing from the handoff of CNS %s taken at %s", snapshot.CNSVersion, snapshot.TakenAt)
+			podInfoByIPProvider = snapshot
+		}
+	}
+
+	// the pool monitor emits Events on the Node when the pool scales or can't.
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+
 	// initialize the ipam pool monitor
 	poolOpts := ipampool.Options{
-		RefreshDelay: poolIPAMRefreshRateInMilliseconds * time.Millisecond,
+		RefreshDelay:            poolIPAMRefreshRateInMilliseconds * time.Millisecond,
+		EventDriven:             cnsconfig.IPPoolScalingSettings.EventDriven,
+		RequestThresholdPercent: cnsconfig.IPPoolScalingSettings.RequestThresholdPercent,
+		ReleaseThresholdPercent: cnsconfig.IPPoolScalingSettings.ReleaseThresholdPercent,
+		EventRecorder:           broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "azure-cns", Host: nodeName}),
+		NodeName:                nodeName,
 	}
 	poolMonitor := ipampool.NewMonitor(httpRestServiceImplementation, scopedcli, clusterSubnetStateChan, &poolOpts)
 	httpRestServiceImplementation.IPAMPoolMonitor = poolMonitor
+	httpRestServiceImplementation.WireguardPeerStore = wireguardpeers.NewNodeStore(clientset, nodeName)
 
 	// reconcile initial CNS state from CNI or apiserver.
 	// Only reconcile if there are any existing Pods using NC ips,
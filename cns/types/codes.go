@@ -42,6 +42,8 @@ const (
 	NilEndpointStateStore                  ResponseCode = 40
 	NmAgentInternalServerError             ResponseCode = 41
 	StatusUnauthorized                     ResponseCode = 42
+	ServiceDraining                        ResponseCode = 43
+	HostPortConflict                       ResponseCode = 44
 	UnexpectedError                        ResponseCode = 99
 )
 
@@ -122,6 +124,10 @@ func (c ResponseCode) String() string {
 		return "NmAgentInternalServerError"
 	case StatusUnauthorized:
 		return "StatusUnauthorized"
+	case ServiceDraining:
+		return "ServiceDraining"
+	case HostPortConflict:
+		return "HostPortConflict"
 	default:
 		return "UnknownError"
 	}
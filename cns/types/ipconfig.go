@@ -13,4 +13,6 @@ const (
 	PendingRelease IPState = "PendingRelease"
 	// PendingProgramming IPConfigState for allocated IPs pending programming.
 	PendingProgramming IPState = "PendingProgramming"
+	// Quarantined IPConfigState for allocated IPs that CNS doesn't assign to Pods, e.g. after a conflict was detected.
+	Quarantined IPState = "Quarantined"
 )
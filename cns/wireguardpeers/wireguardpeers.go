@@ -0,0 +1,106 @@
+// Package wireguardpeers exchanges the wireguard public keys of the nodes of the overlay through the
+// Node objects of the cluster, so every node sees the peers of every other node.
+package wireguardpeers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/network/wireguard"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PublicKeyAnnotation holds the wireguard public key of a node on its Node object.
+const PublicKeyAnnotation = "kubernetes.azure.com/wireguard-public-key"
+
+// NodeStore publishes the key of the local node as an annotation of its Node object and lists the
+// peers from the annotations of all Nodes. Only the key is taken from the annotation: the endpoint is
+// the InternalIP of the Node and the allowed IPs are its pod CIDRs, so a node can not claim the
+// addresses of another node.
+type NodeStore struct {
+	cli      kubernetes.Interface
+	nodeName string
+}
+
+// NewNodeStore returns a NodeStore publishing the key of the node nodeName.
+func NewNodeStore(cli kubernetes.Interface, nodeName string) *NodeStore {
+	return &NodeStore{
+		cli:      cli,
+		nodeName: nodeName,
+	}
+}
+
+// PublishPublicKey sets the wireguard public key of the local node.
+func (s *NodeStore) PublishPublicKey(ctx context.Context, publicKey string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{PublicKeyAnnotation: publicKey},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal node patch")
+	}
+
+	if _, err = s.cli.CoreV1().Nodes().Patch(ctx, s.nodeName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to publish wireguard public key on node %s", s.nodeName)
+	}
+
+	return nil
+}
+
+// ListPeers returns the wireguard peers of all nodes which published a key, sorted by node name.
+// Nodes without pod CIDRs or InternalIP are skipped since no traffic can be routed to them.
+func (s *NodeStore) ListPeers(ctx context.Context) ([]cns.WireguardPeer, error) {
+	nodes, err := s.cli.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+
+	peers := make([]cns.WireguardPeer, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		if peer, ok := peerFromNode(&nodes.Items[i]); ok {
+			peers = append(peers, peer)
+		}
+	}
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i].NodeName < peers[j].NodeName })
+	return peers, nil
+}
+
+func peerFromNode(node *corev1.Node) (cns.WireguardPeer, bool) {
+	publicKey := node.Annotations[PublicKeyAnnotation]
+	if publicKey == "" {
+		return cns.WireguardPeer{}, false
+	}
+
+	podCIDRs := node.Spec.PodCIDRs
+	if len(podCIDRs) == 0 && node.Spec.PodCIDR != "" {
+		podCIDRs = []string{node.Spec.PodCIDR}
+	}
+	if len(podCIDRs) == 0 {
+		return cns.WireguardPeer{}, false
+	}
+
+	for _, addr := range node.Status.Addresses {
+		if addr.Type != corev1.NodeInternalIP {
+			continue
+		}
+
+		return cns.WireguardPeer{
+			NodeName:   node.Name,
+			PublicKey:  publicKey,
+			Endpoint:   net.JoinHostPort(addr.Address, strconv.Itoa(wireguard.DefaultListenPort)),
+			AllowedIPs: podCIDRs,
+		}, true
+	}
+
+	return cns.WireguardPeer{}, false
+}
@@ -0,0 +1,57 @@
+package wireguardpeers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newNode(name, internalIP string, podCIDRs ...string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{PodCIDRs: podCIDRs},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: name},
+				{Type: corev1.NodeInternalIP, Address: internalIP},
+			},
+		},
+	}
+}
+
+func TestPublishAndListPeers(t *testing.T) {
+	cli := fake.NewSimpleClientset(
+		newNode("node-1", "10.240.0.4", "10.244.1.0/24"),
+		newNode("node-2", "10.240.0.5", "10.244.2.0/24", "fd00:2::/64"),
+		newNode("node-3", "10.240.0.6"),
+	)
+	ctx := context.Background()
+
+	require.NoError(t, NewNodeStore(cli, "node-1").PublishPublicKey(ctx, "key-1"))
+	require.NoError(t, NewNodeStore(cli, "node-2").PublishPublicKey(ctx, "key-2"))
+	// node-3 has no pod CIDR so it is not a peer even with a key
+	require.NoError(t, NewNodeStore(cli, "node-3").PublishPublicKey(ctx, "key-3"))
+
+	peers, err := NewNodeStore(cli, "node-1").ListPeers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []cns.WireguardPeer{
+		{NodeName: "node-1", PublicKey: "key-1", Endpoint: "10.240.0.4:51820", AllowedIPs: []string{"10.244.1.0/24"}},
+		{NodeName: "node-2", PublicKey: "key-2", Endpoint: "10.240.0.5:51820", AllowedIPs: []string{"10.244.2.0/24", "fd00:2::/64"}},
+	}, peers)
+
+	// publishing again replaces the key, which is how rotated keys are distributed
+	require.NoError(t, NewNodeStore(cli, "node-1").PublishPublicKey(ctx, "key-1-rotated"))
+	peers, err = NewNodeStore(cli, "node-2").ListPeers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "key-1-rotated", peers[0].PublicKey)
+}
+
+func TestPublishPublicKeyUnknownNode(t *testing.T) {
+	cli := fake.NewSimpleClientset()
+	require.Error(t, NewNodeStore(cli, "node-1").PublishPublicKey(context.Background(), "key-1"))
+}
@@ -3,24 +3,28 @@ package common
 import (
 	"testing"
 
+	"github.com/Azure/azure-container-networking/netns"
 	testutils "github.com/Azure/azure-container-networking/test/utils"
 	utilexec "k8s.io/utils/exec"
 	testingexec "k8s.io/utils/exec/testing"
 )
 
 type IOShim struct {
-	Exec utilexec.Interface
+	Exec  utilexec.Interface
+	Netns netns.Interface
 }
 
 func NewIOShim() *IOShim {
 	return &IOShim{
-		Exec: utilexec.New(),
+		Exec:  utilexec.New(),
+		Netns: netns.New(),
 	}
 }
 
 func NewMockIOShim(calls []testutils.TestCmd) *IOShim {
 	return &IOShim{
-		Exec: testutils.GetFakeExecWithScripts(calls),
+		Exec:  testutils.GetFakeExecWithScripts(calls),
+		Netns: netns.NewFake(),
 	}
 }
 
@@ -40,6 +40,9 @@ type PluginConfig struct {
 	Listener *Listener
 	ErrChan  chan error
 	Store    store.KeyValueStore
+	// LockStorePerAccess is set if the store is not locked for the whole operation of the plugin, but only
+	// while it is read or written. Changes are then merged into the state written by other processes.
+	LockStorePerAccess bool
 }
 
 // NewPlugin creates a new Plugin object.
@@ -0,0 +1,97 @@
+package conntrack
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrEmptyFilter is returned when a flush is requested with a filter that matches nothing.
+// An empty filter is rejected instead of flushing the whole table.
+var ErrEmptyFilter = errors.New("conntrack filter is empty")
+
+// ErrPortWithoutProtocol is returned when a flush is requested with a port but no protocol.
+// Ports are only meaningful for a given protocol, and ignoring the port would flush far more than asked.
+var ErrPortWithoutProtocol = errors.New("conntrack filter has a port but no protocol")
+
+// Filter selects the conntrack entries to flush. All set fields must match for an entry
+// to be flushed, unset fields match any entry.
+type Filter struct {
+	// IP matches entries with this address as source or destination in either direction.
+	IP net.IP
+	// SrcIP and DstIP match the original direction of the entry.
+	SrcIP net.IP
+	DstIP net.IP
+	// Protocol is the layer 4 protocol number, e.g. unix.IPPROTO_TCP.
+	Protocol uint8
+	// Port matches entries with this source or destination port in the original direction.
+	// Port requires Protocol to be set.
+	Port uint16
+}
+
+// tuple is the layer 3 and layer 4 part of one direction of a conntrack entry.
+type tuple struct {
+	srcIP   net.IP
+	dstIP   net.IP
+	srcPort uint16
+	dstPort uint16
+}
+
+// IsEmpty returns true if the filter does not restrict anything.
+func (f *Filter) IsEmpty() bool {
+	return f.IP == nil && f.SrcIP == nil && f.DstIP == nil && f.Protocol == 0 && f.Port == 0
+}
+
+// Validate returns an error if the filter can't be used to flush entries.
+func (f *Filter) Validate() error {
+	if f.IsEmpty() {
+		return ErrEmptyFilter
+	}
+
+	if f.Port != 0 && f.Protocol == 0 {
+		return ErrPortWithoutProtocol
+	}
+
+	return nil
+}
+
+// ips returns the addresses referenced by the filter.
+func (f *Filter) ips() []net.IP {
+	var ips []net.IP
+	for _, ip := range []net.IP{f.IP, f.SrcIP, f.DstIP} {
+		if ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// matches returns true if an entry with the given protocol and tuples is selected by the filter.
+func (f *Filter) matches(protocol uint8, orig, reply tuple) bool {
+	if f.Validate() != nil {
+		return false
+	}
+
+	if f.Protocol != 0 && f.Protocol != protocol {
+		return false
+	}
+
+	if f.SrcIP != nil && !f.SrcIP.Equal(orig.srcIP) {
+		return false
+	}
+
+	if f.DstIP != nil && !f.DstIP.Equal(orig.dstIP) {
+		return false
+	}
+
+	if f.IP != nil &&
+		!f.IP.Equal(orig.srcIP) && !f.IP.Equal(orig.dstIP) &&
+		!f.IP.Equal(reply.srcIP) && !f.IP.Equal(reply.dstIP) {
+		return false
+	}
+
+	if f.Port != 0 && f.Port != orig.srcPort && f.Port != orig.dstPort {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,70 @@
+//go:build linux
+// +build linux
+
+package conntrack
+
+import (
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+)
+
+// Client flushes conntrack entries through the netfilter netlink interface.
+type Client struct{}
+
+func New() *Client {
+	return &Client{}
+}
+
+// flowFilter adapts a Filter to the netlink.CustomConntrackFilter interface.
+type flowFilter struct {
+	filter *Filter
+}
+
+func (f flowFilter) MatchConntrackFlow(flow *netlink.ConntrackFlow) bool {
+	orig := tuple{
+		srcIP:   flow.Forward.SrcIP,
+		dstIP:   flow.Forward.DstIP,
+		srcPort: flow.Forward.SrcPort,
+		dstPort: flow.Forward.DstPort,
+	}
+	reply := tuple{
+		srcIP:   flow.Reverse.SrcIP,
+		dstIP:   flow.Reverse.DstIP,
+		srcPort: flow.Reverse.SrcPort,
+		dstPort: flow.Reverse.DstPort,
+	}
+	return f.filter.matches(flow.Forward.Protocol, orig, reply)
+}
+
+// Flush deletes all conntrack entries selected by the filter and returns the number of deleted entries.
+func (c *Client) Flush(filter Filter) (uint, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	var flushed uint
+	for _, family := range families(&filter) {
+		n, err := netlink.ConntrackDeleteFilter(netlink.ConntrackTable, family, flowFilter{filter: &filter})
+		flushed += n
+		if err != nil {
+			return flushed, errors.Wrapf(err, "failed to flush conntrack entries for family %d", family)
+		}
+	}
+
+	log.Printf("[conntrack] Flushed %d entries matching %+v", flushed, filter)
+	return flushed, nil
+}
+
+// families returns the address families which can contain entries selected by the filter.
+func families(filter *Filter) []netlink.InetFamily {
+	ips := filter.ips()
+	if len(ips) == 0 {
+		return []netlink.InetFamily{netlink.FAMILY_V4, netlink.FAMILY_V6}
+	}
+
+	if ips[0].To4() != nil {
+		return []netlink.InetFamily{netlink.FAMILY_V4}
+	}
+	return []netlink.InetFamily{netlink.FAMILY_V6}
+}
@@ -0,0 +1,114 @@
+package conntrack
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	protoTCP = 6
+	protoUDP = 17
+)
+
+func TestFilterMatches(t *testing.T) {
+	podIP := net.ParseIP("10.0.0.4")
+	remoteIP := net.ParseIP("20.0.0.1")
+	snatIP := net.ParseIP("169.254.128.10")
+
+	// pod 10.0.0.4:40000 -> 20.0.0.1:443, source natted to 169.254.128.10 on the way out
+	orig := tuple{srcIP: podIP, dstIP: remoteIP, srcPort: 40000, dstPort: 443}
+	reply := tuple{srcIP: remoteIP, dstIP: snatIP, srcPort: 443, dstPort: 40000}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		match  bool
+	}{
+		{
+			name:   "empty filter never matches",
+			filter: Filter{},
+			match:  false,
+		},
+		{
+			name:   "ip matches original source",
+			filter: Filter{IP: podIP},
+			match:  true,
+		},
+		{
+			name:   "ip matches reply destination",
+			filter: Filter{IP: snatIP},
+			match:  true,
+		},
+		{
+			name:   "unrelated ip",
+			filter: Filter{IP: net.ParseIP("10.0.0.5")},
+			match:  false,
+		},
+		{
+			name:   "source and destination",
+			filter: Filter{SrcIP: podIP, DstIP: remoteIP},
+			match:  true,
+		},
+		{
+			name:   "source and destination reversed",
+			filter: Filter{SrcIP: remoteIP, DstIP: podIP},
+			match:  false,
+		},
+		{
+			name:   "protocol and port",
+			filter: Filter{IP: podIP, Protocol: protoTCP, Port: 443},
+			match:  true,
+		},
+		{
+			name:   "protocol mismatch",
+			filter: Filter{IP: podIP, Protocol: protoUDP},
+			match:  false,
+		},
+		{
+			name:   "port without protocol never matches",
+			filter: Filter{Port: 443},
+			match:  false,
+		},
+		{
+			name:   "port mismatch",
+			filter: Filter{Protocol: protoTCP, Port: 80},
+			match:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.match, tt.filter.matches(protoTCP, orig, reply))
+		})
+	}
+}
+
+func TestMockConntrack(t *testing.T) {
+	m := NewMockConntrack(false)
+	_, err := m.Flush(Filter{})
+	require.ErrorIs(t, err, ErrEmptyFilter)
+
+	_, err = m.Flush(Filter{Port: 80})
+	require.ErrorIs(t, err, ErrPortWithoutProtocol)
+	require.Empty(t, m.Flushed)
+
+	filter := Filter{IP: net.ParseIP("10.0.0.4")}
+	n, err := m.Flush(filter)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), n)
+	require.Equal(t, []Filter{filter}, m.Flushed)
+
+	_, err = NewMockConntrack(true).Flush(filter)
+	require.ErrorIs(t, err, ErrMockConntrack)
+}
+
+func TestFilterValidate(t *testing.T) {
+	require.ErrorIs(t, (&Filter{}).Validate(), ErrEmptyFilter)
+	require.ErrorIs(t, (&Filter{Port: 80}).Validate(), ErrPortWithoutProtocol)
+	require.ErrorIs(t, (&Filter{IP: net.ParseIP("10.0.0.4"), Port: 80}).Validate(), ErrPortWithoutProtocol)
+	require.NoError(t, (&Filter{Protocol: protoTCP, Port: 80}).Validate())
+	require.NoError(t, (&Filter{IP: net.ParseIP("10.0.0.4")}).Validate())
+}
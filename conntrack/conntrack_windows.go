@@ -0,0 +1,24 @@
+package conntrack
+
+import (
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Client is the Windows conntrack client. HNS keeps flow state in VFP on the endpoint port,
+// and the flows are dropped along with the endpoint or policy which created them,
+// so there are no entries which have to be flushed separately.
+type Client struct{}
+
+func New() *Client {
+	return &Client{}
+}
+
+// Flush validates the filter and returns without deleting anything since HNS owns the flow state.
+func (c *Client) Flush(filter Filter) (uint, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	log.Printf("[conntrack] Skipping flush of entries matching %+v, flows are managed by HNS", filter)
+	return 0, nil
+}
@@ -0,0 +1,33 @@
+package conntrack
+
+import (
+	"errors"
+)
+
+// ErrMockConntrack - conntrack mock error
+var ErrMockConntrack = errors.New("mock conntrack error")
+
+// MockConntrack records the filters it was asked to flush.
+type MockConntrack struct {
+	returnError bool
+	Flushed     []Filter
+}
+
+func NewMockConntrack(returnError bool) *MockConntrack {
+	return &MockConntrack{
+		returnError: returnError,
+	}
+}
+
+func (m *MockConntrack) Flush(filter Filter) (uint, error) {
+	if m.returnError {
+		return 0, ErrMockConntrack
+	}
+
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	m.Flushed = append(m.Flushed, filter)
+	return 1, nil
+}
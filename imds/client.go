@@ -0,0 +1,137 @@
+// Package imds contains a client of the Azure Instance Metadata Service (IMDS), which CNI, CNS and NPM
+// use to make decisions based on the capabilities of the VM they run on.
+package imds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultEndpoint      = "http://169.254.169.254"
+	instanceURLFmt       = "%s/metadata/instance?api-version=2021-02-01&format=json"
+	defaultCacheTTL      = 5 * time.Minute
+	defaultRetryAttempts = 3
+	defaultRetryDelay    = 500 * time.Millisecond
+	requestTimeout       = 5 * time.Second
+)
+
+// ErrUnexpectedStatusCode is returned when IMDS answers with a status other than 200 OK.
+var ErrUnexpectedStatusCode = errors.New("unexpected status code")
+
+// Provider provides the metadata of the VM.
+type Provider interface {
+	GetVMMetadata(ctx context.Context) (*VMMetadata, error)
+}
+
+// Config is a configuration of a Client. Zero values select the defaults.
+type Config struct {
+	Endpoint      string        // the IMDS endpoint, http://169.254.169.254 by default
+	CacheTTL      time.Duration // how long the metadata is cached
+	RetryAttempts uint          // the number of attempts of a request
+	RetryDelay    time.Duration // the delay before the first retry, doubled for each attempt
+}
+
+// Client retrieves the metadata of the VM from IMDS. The metadata is cached so that it is requested
+// at most once per CacheTTL, concurrent callers wait for the same request.
+type Client struct {
+	httpClient    *http.Client
+	instanceURL   string
+	cacheTTL      time.Duration
+	retryAttempts uint
+	retryDelay    time.Duration
+
+	mu        sync.Mutex
+	metadata  *VMMetadata
+	expiresAt time.Time
+}
+
+var _ Provider = (*Client)(nil)
+
+// NewClient returns a Client using the provided configuration.
+func NewClient(c Config) *Client {
+	client := &Client{
+		// IMDS must not be reached through a proxy.
+		httpClient:    &http.Client{Transport: &http.Transport{Proxy: nil}, Timeout: requestTimeout},
+		instanceURL:   fmt.Sprintf(instanceURLFmt, defaultEndpoint),
+		cacheTTL:      defaultCacheTTL,
+		retryAttempts: defaultRetryAttempts,
+		retryDelay:    defaultRetryDelay,
+	}
+
+	if c.Endpoint != "" {
+		client.instanceURL = fmt.Sprintf(instanceURLFmt, c.Endpoint)
+	}
+	if c.CacheTTL > 0 {
+		client.cacheTTL = c.CacheTTL
+	}
+	if c.RetryAttempts > 0 {
+		client.retryAttempts = c.RetryAttempts
+	}
+	if c.RetryDelay > 0 {
+		client.retryDelay = c.RetryDelay
+	}
+
+	return client
+}
+
+// GetVMMetadata returns the metadata of the VM, from the cache if it has not expired.
+func (c *Client) GetVMMetadata(ctx context.Context) (*VMMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.metadata != nil && time.Now().Before(c.expiresAt) {
+		return c.metadata, nil
+	}
+
+	var res instanceResponse
+	err := retry.Do(func() error {
+		return c.getInstance(ctx, &res)
+	}, retry.Context(ctx), retry.Attempts(c.retryAttempts), retry.Delay(c.retryDelay),
+		retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get instance metadata from IMDS")
+	}
+
+	c.metadata = res.metadata()
+	c.expiresAt = time.Now().Add(c.cacheTTL)
+
+	return c.metadata, nil
+}
+
+func (c *Client) getInstance(ctx context.Context, res *instanceResponse) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.instanceURL, http.NoBody)
+	if err != nil {
+		return retry.Unrecoverable(errors.Wrap(err, "failed to build request"))
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = errors.Wrapf(ErrUnexpectedStatusCode, "%d", resp.StatusCode)
+		// Client errors other than throttling will not succeed on retry.
+		if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError &&
+			resp.StatusCode != http.StatusTooManyRequests {
+			return retry.Unrecoverable(err)
+		}
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return errors.Wrap(err, "failed to decode response")
+	}
+
+	return nil
+}
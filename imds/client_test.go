@@ -0,0 +1,157 @@
+package imds_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/imds"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// newTestServer returns an IMDS server answering with the statuses in order, then with the instance
+// metadata in testdata, and the number of requests it received.
+func newTestServer(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	instance, err := os.ReadFile("testdata/instance.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if r.Header.Get("Metadata") != "true" || r.URL.Path != "/metadata/instance" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = w.Write(instance)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+func TestGetVMMetadata(t *testing.T) {
+	srv, requests := newTestServer(t)
+	client := imds.NewClient(imds.Config{Endpoint: srv.URL})
+
+	got, err := client.GetVMMetadata(context.Background())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	exp := &imds.VMMetadata{
+		VMID:     "3d7b8a1e-7c3e-4e0f-9c1e-6f5a2b1c0d9e",
+		VMSize:   "Standard_D4s_v3",
+		Location: "westus2",
+		Interfaces: []imds.Interface{
+			{
+				MacAddress:    "000D3A6E1B2C",
+				IPv4Addresses: []string{"10.224.0.4", "10.224.0.5"},
+				IPv6Addresses: []string{"fd00:10:224::4"},
+			},
+			{
+				MacAddress:    "000D3A6E1B2D",
+				IPv4Addresses: []string{"10.225.0.4"},
+			},
+		},
+	}
+	if !cmp.Equal(got, exp) {
+		t.Error("unexpected metadata: diff:", cmp.Diff(got, exp))
+	}
+
+	if got.NICCount() != 2 {
+		t.Error("unexpected NIC count:", got.NICCount())
+	}
+
+	if !got.IPv6Enabled() {
+		t.Error("expected IPv6 to be enabled")
+	}
+
+	// the second call is served from the cache
+	if _, err := client.GetVMMetadata(context.Background()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Error("expected 1 request, got", n)
+	}
+}
+
+func TestGetVMMetadataCacheExpiry(t *testing.T) {
+	srv, requests := newTestServer(t)
+	client := imds.NewClient(imds.Config{Endpoint: srv.URL, CacheTTL: time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetVMMetadata(context.Background()); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Error("expected 2 requests, got", n)
+	}
+}
+
+func TestGetVMMetadataRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		shouldErr    bool
+		expRequests  int32
+		expStatusErr bool
+	}{
+		{
+			name:        "retried server errors",
+			statuses:    []int{http.StatusInternalServerError, http.StatusTooManyRequests},
+			expRequests: 3,
+		},
+		{
+			name:         "attempts exhausted",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			shouldErr:    true,
+			expRequests:  3,
+			expStatusErr: true,
+		},
+		{
+			name:         "client error not retried",
+			statuses:     []int{http.StatusNotFound},
+			shouldErr:    true,
+			expRequests:  1,
+			expStatusErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			srv, requests := newTestServer(t, test.statuses...)
+			client := imds.NewClient(imds.Config{Endpoint: srv.URL, RetryDelay: time.Millisecond})
+
+			_, err := client.GetVMMetadata(context.Background())
+			if err != nil && !test.shouldErr {
+				t.Fatal("unexpected error:", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatal("expected error but received none")
+			}
+			if test.expStatusErr && !errors.Is(err, imds.ErrUnexpectedStatusCode) {
+				t.Error("expected unexpected status code error, got", err)
+			}
+			if n := atomic.LoadInt32(requests); n != test.expRequests {
+				t.Errorf("expected %d requests, got %d", test.expRequests, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package imds
+
+// VMMetadata is the metadata of the VM hosting the node. It is shared by the callers of a Client and
+// must not be modified.
+type VMMetadata struct {
+	VMID     string
+	VMSize   string
+	Location string
+	// Interfaces are the network interfaces of the VM, the primary interface first.
+	Interfaces []Interface
+}
+
+// Interface is a network interface of the VM.
+type Interface struct {
+	MacAddress    string
+	IPv4Addresses []string
+	IPv6Addresses []string
+}
+
+// NICCount returns the number of network interfaces of the VM.
+func (m *VMMetadata) NICCount() int {
+	return len(m.Interfaces)
+}
+
+// IPv6Enabled reports whether any network interface of the VM has an IPv6 address.
+func (m *VMMetadata) IPv6Enabled() bool {
+	for i := range m.Interfaces {
+		if len(m.Interfaces[i].IPv6Addresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceResponse is the instance metadata document returned by IMDS.
+type instanceResponse struct {
+	Compute struct {
+		VMID     string `json:"vmId"`
+		VMSize   string `json:"vmSize"`
+		Location string `json:"location"`
+	} `json:"compute"`
+	Network struct {
+		Interface []struct {
+			MacAddress string        `json:"macAddress"`
+			IPv4       ipAddressList `json:"ipv4"`
+			IPv6       ipAddressList `json:"ipv6"`
+		} `json:"interface"`
+	} `json:"network"`
+}
+
+type ipAddressList struct {
+	IPAddress []struct {
+		PrivateIPAddress string `json:"privateIpAddress"`
+	} `json:"ipAddress"`
+}
+
+func (l ipAddressList) addresses() []string {
+	var addresses []string
+	for _, ip := range l.IPAddress {
+		if ip.PrivateIPAddress != "" {
+			addresses = append(addresses, ip.PrivateIPAddress)
+		}
+	}
+	return addresses
+}
+
+func (r *instanceResponse) metadata() *VMMetadata {
+	m := &VMMetadata{
+		VMID:     r.Compute.VMID,
+		VMSize:   r.Compute.VMSize,
+		Location: r.Compute.Location,
+	}
+
+	for _, nic := range r.Network.Interface {
+		m.Interfaces = append(m.Interfaces, Interface{
+			MacAddress:    nic.MacAddress,
+			IPv4Addresses: nic.IPv4.addresses(),
+			IPv6Addresses: nic.IPv6.addresses(),
+		})
+	}
+
+	return m
+}
@@ -20,12 +20,20 @@ var (
 	errAddressNotFound      = fmt.Errorf("Address not found")
 	errAddressInUse         = fmt.Errorf("Address already in use")
 	errNoAvailableAddresses = fmt.Errorf("No available addresses")
+	errAddressQuarantined   = fmt.Errorf("Address is quarantined")
 
 	// Options used by AddressManager.
 	OptInterfaceName      = "azure.interface.name"
 	OptAddressID          = "azure.address.id"
 	OptAddressType        = "azure.address.type"
 	OptAddressTypeGateway = "gateway"
+	// OptAddressRange restricts the allocation to the addresses of a CIDR.
+	OptAddressRange = "azure.address.range"
+	// OptReservedRanges are comma separated CIDRs whose addresses are only allocated when requested by range.
+	OptReservedRanges = "azure.address.reserved"
+	// OptAddressQuarantine is the duration, as parsed by time.ParseDuration, for which a released address is not
+	// allocated again, e.g. because another host was found using it.
+	OptAddressQuarantine = "azure.address.quarantine"
 )
 
 // Exportable errors returned by AddressManager
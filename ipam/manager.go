@@ -4,6 +4,7 @@
 package ipam
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -23,7 +24,7 @@ type addressManager struct {
 	Version    string
 	TimeStamp  time.Time
 	AddrSpaces map[string]*addressSpace `json:"AddressSpaces"`
-	store      store.KeyValueStore
+	store      platform.KeyValueStore
 	source     addressConfigSource
 	netApi     common.NetApi
 	sync.Mutex
@@ -42,8 +43,11 @@ type AddressManager interface {
 	RequestPool(asId, poolId, subPoolId string, options map[string]string, v6 bool) (string, string, error)
 	ReleasePool(asId, poolId string) error
 	GetPoolInfo(asId, poolId string) (*AddressPoolInfo, error)
+	GetPoolUsage() []PoolUsage
+	Defragment() (int, error)
 
 	RequestAddress(asId, poolId, address string, options map[string]string) (string, error)
+	RequestDualStackAddresses(asId, poolIdV4, addressV4, poolIdV6, addressV6 string, options map[string]string) (string, string, error)
 	ReleaseAddress(asId, poolId, address string, options map[string]string) error
 }
 
@@ -115,6 +119,10 @@ func (am *addressManager) restore(rehydrateIpamInfoOnReboot bool) error {
 		}
 	}
 
+	if rs, ok := am.store.(store.RecoverableStore); ok && rs.Recovered() {
+		log.Printf("[ipam] Restored state from a backup of the store")
+	}
+
 	// Populate pointers.
 	for _, as := range am.AddrSpaces {
 		for _, ap := range as.Pools {
@@ -341,6 +349,51 @@ func (am *addressManager) GetPoolInfo(asId string, poolId string) (*AddressPoolI
 	return ap.getInfo(), nil
 }
 
+// GetPoolUsage returns the address usage of every address pool, ordered by address space and subnet.
+func (am *addressManager) GetPoolUsage() []PoolUsage {
+	am.Lock()
+	defer am.Unlock()
+
+	var usages []PoolUsage
+	for _, as := range am.AddrSpaces {
+		for _, ap := range as.Pools {
+			usages = append(usages, ap.getUsage())
+		}
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].AddressSpace != usages[j].AddressSpace {
+			return usages[i].AddressSpace < usages[j].AddressSpace
+		}
+		return usages[i].Subnet < usages[j].Subnet
+	})
+
+	return usages
+}
+
+// Defragment compacts the free addresses of every address pool and returns the number of addresses reclaimed.
+func (am *addressManager) Defragment() (int, error) {
+	am.Lock()
+	defer am.Unlock()
+
+	var reclaimed int
+	for _, as := range am.AddrSpaces {
+		for _, ap := range as.Pools {
+			reclaimed += ap.defragment()
+		}
+	}
+
+	if reclaimed == 0 {
+		return 0, nil
+	}
+
+	if err := am.save(); err != nil {
+		return 0, err
+	}
+
+	return reclaimed, nil
+}
+
 // RequestAddress reserves a new address from the address pool.
 func (am *addressManager) RequestAddress(asId, poolId, address string, options map[string]string) (string, error) {
 	am.Lock()
@@ -358,20 +411,71 @@ func (am *addressManager) RequestAddress(asId, poolId, address string, options m
 		return "", err
 	}
 
-	addr, err := ap.requestAddress(address, options)
+	addr, err := ap.requestAddressWithDefragment(address, options)
 	if err != nil {
 		return "", err
 	}
 
 	err = am.save()
 	if err != nil {
-		ap.releaseAddress(addr, options)
+		ap.releaseAllocatedAddress(addr, options)
 		return "", err
 	}
 
 	return addr, nil
 }
 
+// RequestDualStackAddresses reserves an IPv4 and an IPv6 address in a single transaction. Either both addresses are
+// reserved, or the IPv4 address is released again and neither is.
+func (am *addressManager) RequestDualStackAddresses(
+	asId, poolIdV4, addressV4, poolIdV6, addressV6 string,
+	options map[string]string,
+) (string, string, error) {
+	am.Lock()
+	defer am.Unlock()
+
+	am.refreshSource()
+
+	as, err := am.getAddressSpace(asId)
+	if err != nil {
+		return "", "", err
+	}
+
+	ap4, err := as.getAddressPool(poolIdV4)
+	if err != nil {
+		return "", "", err
+	}
+
+	ap6, err := as.getAddressPool(poolIdV6)
+	if err != nil {
+		return "", "", err
+	}
+
+	if ap4.IsIPv6 || !ap6.IsIPv6 {
+		return "", "", errInvalidPoolID
+	}
+
+	addr4, err := ap4.requestAddressWithDefragment(addressV4, options)
+	if err != nil {
+		return "", "", err
+	}
+
+	addr6, err := ap6.requestAddressWithDefragment(addressV6, options)
+	if err != nil {
+		ap4.releaseAllocatedAddress(addr4, options)
+		return "", "", err
+	}
+
+	err = am.save()
+	if err != nil {
+		ap4.releaseAllocatedAddress(addr4, options)
+		ap6.releaseAllocatedAddress(addr6, options)
+		return "", "", err
+	}
+
+	return addr4, addr6, nil
+}
+
 // ReleaseAddress releases a previously reserved address.
 func (am *addressManager) ReleaseAddress(asId string, poolId string, address string, options map[string]string) error {
 	am.Lock()
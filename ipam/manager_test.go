@@ -386,4 +386,110 @@ var _ = Describe("Test Manager", func() {
 			})
 		})
 	})
+
+	Describe("Test RequestAddress", func() {
+		Context("When the pool only has reserved addresses", func() {
+			It("Should defragment the pool and allocate an address", func() {
+				am := &addressManager{
+					AddrSpaces: make(map[string]*addressSpace),
+				}
+				as := &addressSpace{Id: LocalDefaultAddressSpaceId, Pools: make(map[string]*addressPool)}
+				am.AddrSpaces[as.Id] = as
+				_, subnet, _ := net.ParseCIDR("10.0.0.0/16")
+				ap, err := as.newAddressPool("eth0", 0, subnet)
+				Expect(err).NotTo(HaveOccurred())
+				addr := net.ParseIP("10.0.0.4")
+				ar, err := ap.newAddressRecord(&addr)
+				Expect(err).NotTo(HaveOccurred())
+				ar.ID = "container-1"
+				ap.addrsByID[ar.ID] = ar
+
+				Expect(am.GetPoolUsage()[0].Reserved).To(Equal(1))
+
+				address, err := am.RequestAddress(as.Id, ap.Id, "", map[string]string{OptAddressID: "container-2"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(address).To(Equal("10.0.0.4/16"))
+
+				usage := am.GetPoolUsage()
+				Expect(usage).To(HaveLen(1))
+				Expect(usage[0].Allocated).To(Equal(1))
+				Expect(usage[0].Reserved).To(Equal(0))
+				Expect(usage[0].Available).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("Test RequestDualStackAddresses", func() {
+		var (
+			am       *addressManager
+			as       *addressSpace
+			ap4, ap6 *addressPool
+		)
+
+		BeforeEach(func() {
+			am = &addressManager{
+				AddrSpaces: make(map[string]*addressSpace),
+			}
+			as = &addressSpace{Id: LocalDefaultAddressSpaceId, Pools: make(map[string]*addressPool)}
+			am.AddrSpaces[as.Id] = as
+
+			var err error
+			_, subnet4, _ := net.ParseCIDR("10.0.0.0/16")
+			ap4, err = as.newAddressPool("eth0", 0, subnet4)
+			Expect(err).NotTo(HaveOccurred())
+			addr4 := net.ParseIP("10.0.0.4")
+			_, err = ap4.newAddressRecord(&addr4)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, subnet6, _ := net.ParseCIDR("fd00::/64")
+			ap6, err = as.newAddressPool("eth0", 0, subnet6)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("When both pools have an available address", func() {
+			It("Should reserve an address of each family", func() {
+				addr6 := net.ParseIP("fd00::4")
+				_, err := ap6.newAddressRecord(&addr6)
+				Expect(err).NotTo(HaveOccurred())
+
+				options := map[string]string{OptAddressID: "container-1"}
+				address4, address6, err := am.RequestDualStackAddresses(as.Id, ap4.Id, "", ap6.Id, "", options)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(address4).To(Equal("10.0.0.4/16"))
+				Expect(address6).To(Equal("fd00::4/64"))
+			})
+		})
+
+		Context("When the IPv6 pool is exhausted", func() {
+			It("Should not reserve the IPv4 address", func() {
+				options := map[string]string{OptAddressID: "container-1"}
+				_, _, err := am.RequestDualStackAddresses(as.Id, ap4.Id, "", ap6.Id, "", options)
+				Expect(err).To(Equal(errNoAvailableAddresses))
+
+				Expect(ap4.Addresses["10.0.0.4"].InUse).To(BeFalse())
+				Expect(ap4.Addresses["10.0.0.4"].ID).To(BeEmpty())
+				Expect(ap4.addrsByID).To(BeEmpty())
+			})
+		})
+
+		Context("When the pools are of the wrong families", func() {
+			It("Should raise errInvalidPoolID", func() {
+				_, _, err := am.RequestDualStackAddresses(as.Id, ap6.Id, "", ap4.Id, "", nil)
+				Expect(err).To(Equal(errInvalidPoolID))
+			})
+		})
+	})
+
+	Describe("Test Defragment", func() {
+		Context("When no address is reclaimed", func() {
+			It("Should return zero", func() {
+				am := &addressManager{
+					AddrSpaces: make(map[string]*addressSpace),
+				}
+				reclaimed, err := am.Defragment()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reclaimed).To(Equal(0))
+			})
+		})
+	})
 })
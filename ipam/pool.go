@@ -4,9 +4,11 @@
 package ipam
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
@@ -75,13 +77,36 @@ type AddressPoolInfo struct {
 	Capacity       int
 }
 
+// PoolUsage contains the address usage of an address pool.
+type PoolUsage struct {
+	AddressSpace string
+	Subnet       string
+	IfName       string
+	Total        int
+	Allocated    int
+	// Reserved addresses are not in use but still held for an address ID, so they can't be allocated until the pool
+	// is defragmented.
+	Reserved  int
+	Available int
+	Unhealthy int
+	// Quarantined addresses are not in use but can't be allocated until their quarantine ends.
+	Quarantined int
+}
+
 // Represents an IP address in a pool.
 type addressRecord struct {
-	ID        string
-	Addr      net.IP
-	InUse     bool
-	unhealthy bool
-	epoch     int
+	ID    string
+	Addr  net.IP
+	InUse bool
+	// QuarantinedUntil is when a quarantined address can be allocated again.
+	QuarantinedUntil time.Time
+	unhealthy        bool
+	epoch            int
+}
+
+// Returns if the address is quarantined.
+func (ar *addressRecord) isQuarantined() bool {
+	return time.Now().Before(ar.QuarantinedUntil)
 }
 
 //
@@ -417,6 +442,70 @@ func (ap *addressPool) getInfo() *AddressPoolInfo {
 	return info
 }
 
+// Returns the address usage of the pool.
+func (ap *addressPool) getUsage() PoolUsage {
+	usage := PoolUsage{
+		Subnet: ap.Subnet.String(),
+		IfName: ap.IfName,
+		Total:  len(ap.Addresses),
+	}
+	if ap.as != nil {
+		usage.AddressSpace = ap.as.Id
+	}
+
+	for _, ar := range ap.Addresses {
+		switch {
+		case ar.InUse:
+			usage.Allocated++
+		case ar.ID != "":
+			usage.Reserved++
+		case ar.isQuarantined():
+			usage.Quarantined++
+		}
+		if ar.unhealthy {
+			usage.Unhealthy++
+		}
+	}
+	usage.Available = usage.Total - usage.Allocated - usage.Reserved - usage.Quarantined
+
+	return usage
+}
+
+// Compacts the free addresses of the pool. Addresses held for an address ID without being in use are returned to
+// the free addresses, and those no longer provided by the address source are deleted, as they are when released.
+// Returns the number of addresses reclaimed.
+func (ap *addressPool) defragment() int {
+	var reclaimed int
+
+	for id, ar := range ap.addrsByID {
+		if ar.ID != id || ap.Addresses[ar.Addr.String()] != ar {
+			delete(ap.addrsByID, id)
+		}
+	}
+
+	for key, ar := range ap.Addresses {
+		if ar.InUse {
+			continue
+		}
+
+		if ap.as != nil && ar.epoch < ap.as.epoch {
+			delete(ap.addrsByID, ar.ID)
+			delete(ap.Addresses, key)
+			reclaimed++
+		} else if ar.ID != "" {
+			delete(ap.addrsByID, ar.ID)
+			ar.ID = ""
+			reclaimed++
+		}
+	}
+
+	if reclaimed > 0 {
+		log.Printf("[ipam] Defragmented pool %s, reclaimed %d addresses.", ap.Id, reclaimed)
+	}
+
+	return reclaimed
+}
+
 // Returns if an address pool is currently in use.
 func (ap *addressPool) isInUse() bool {
 	return ap.RefCount > 0
@@ -463,6 +552,11 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 
 	log.Printf("[ipam] Requesting address with address:%v options:%+v.", address, options)
 
+	addrRange, reserved, err := parseAddressRanges(options)
+	if err != nil {
+		return "", err
+	}
+
 	if address != "" {
 		// Return the specific address requested.
 		ar = ap.Addresses[address]
@@ -476,6 +570,9 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 				log.Printf("[ipam] Address request failed with %v", errAddressInUse)
 				return "", errAddressInUse
 			}
+		} else if ar.isQuarantined() {
+			log.Printf("[ipam] Address request failed with %v until %v", errAddressQuarantined, ar.QuarantinedUntil)
+			return "", errAddressQuarantined
 		}
 	} else if options[OptAddressType] == OptAddressTypeGateway {
 		// Return the pre-assigned gateway address.
@@ -486,12 +583,15 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 	} else if id != "" {
 		// Return the address with the matching identifier.
 		ar = ap.addrsByID[id]
+		if ar != nil && !isAllocatable(ar.Addr, addrRange, reserved) {
+			ar = nil
+		}
 	}
 
 	// If no address was found, return any available address.
 	if ar == nil {
 		for _, ar = range ap.Addresses {
-			if !ar.InUse && ar.ID == "" {
+			if !ar.InUse && ar.ID == "" && !ar.isQuarantined() && isAllocatable(ar.Addr, addrRange, reserved) {
 				break
 			}
 			ar = nil
@@ -521,6 +621,72 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 	return addr.String(), nil
 }
 
+// Requests an address, compacting the pool once if it has no address available.
+func (ap *addressPool) requestAddressWithDefragment(address string, options map[string]string) (string, error) {
+	addr, err := ap.requestAddress(address, options)
+	if errors.Is(err, errNoAvailableAddresses) && ap.defragment() > 0 {
+		addr, err = ap.requestAddress(address, options)
+	}
+
+	return addr, err
+}
+
+// Releases an address returned by requestAddress, which is in CIDR notation.
+func (ap *addressPool) releaseAllocatedAddress(addr string, options map[string]string) {
+	ip, _, err := net.ParseCIDR(addr)
+	if err != nil {
+		log.Printf("[ipam] Failed to parse allocated address %v: %v", addr, err)
+		return
+	}
+
+	if err = ap.releaseAddress(ip.String(), options); err != nil {
+		log.Printf("[ipam] Failed to release allocated address %v: %v", addr, err)
+	}
+}
+
+// Returns the range the address must be allocated from and the reserved ranges set in the options.
+func parseAddressRanges(options map[string]string) (*net.IPNet, []*net.IPNet, error) {
+	var addrRange *net.IPNet
+	var reserved []*net.IPNet
+
+	if r := options[OptAddressRange]; r != "" {
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: address range %s", errInvalidConfiguration, r)
+		}
+		addrRange = ipNet
+	}
+
+	if r := options[OptReservedRanges]; r != "" {
+		for _, s := range strings.Split(r, ",") {
+			_, ipNet, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: reserved range %s", errInvalidConfiguration, s)
+			}
+			reserved = append(reserved, ipNet)
+		}
+	}
+
+	return addrRange, reserved, nil
+}
+
+// Returns if an address can be allocated without being requested. Addresses of the requested range are allocated
+// from it, otherwise only the addresses outside of the reserved ranges are allocated. A range doesn't restrict the
+// addresses of the other address family.
+func isAllocatable(addr net.IP, addrRange *net.IPNet, reserved []*net.IPNet) bool {
+	if addrRange != nil && (addrRange.IP.To4() == nil) == (addr.To4() == nil) {
+		return addrRange.Contains(addr)
+	}
+
+	for _, r := range reserved {
+		if r.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Releases a previously requested address back to its address pool.
 func (ap *addressPool) releaseAddress(address string, options map[string]string) error {
 	var ar *addressRecord
@@ -568,6 +734,18 @@ func (ap *addressPool) releaseAddress(address string, options map[string]string)
 		ar.ID = ""
 	}
 
+	if quarantine := options[OptAddressQuarantine]; quarantine != "" {
+		var period time.Duration
+		if period, err = time.ParseDuration(quarantine); err != nil {
+			return fmt.Errorf("%w: quarantine %s", errInvalidConfiguration, quarantine)
+		}
+		// the address is not held for its ID, so that the ID is allocated another address.
+		delete(ap.addrsByID, ar.ID)
+		ar.ID = ""
+		ar.QuarantinedUntil = time.Now().Add(period)
+		log.Printf("[ipam] Quarantined address %v until %v.", address, ar.QuarantinedUntil)
+	}
+
 	// Delete address record if it is no longer available.
 	if ar.epoch < ap.as.epoch {
 		log.Printf("Deleting Address record from address pool as metadata doesn't have this address")
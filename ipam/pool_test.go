@@ -1,8 +1,10 @@
 package ipam
 
 import (
+	"errors"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -997,6 +999,67 @@ var _ = Describe("Test Pool", func() {
 			})
 		})
 
+		Context("When an address range is requested", func() {
+			It("Should return an address of the range", func() {
+				ap := &addressPool{
+					Addresses: map[string]*addressRecord{
+						addr11.String(): {Addr: addr11},
+						addr12.String(): {Addr: addr12},
+					},
+					addrsByID: map[string]*addressRecord{},
+					Subnet:    subnet1,
+				}
+				options := map[string]string{
+					OptAddressID:    "container-1",
+					OptAddressRange: "10.0.1.2/32",
+				}
+				addr, err := ap.requestAddress("", options)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(addr).To(Equal("10.0.1.2/24"))
+
+				addr, err = ap.requestAddress("", map[string]string{OptAddressRange: "10.0.1.2/32"})
+				Expect(err).To(Equal(errNoAvailableAddresses))
+				Expect(addr).To(BeEmpty())
+			})
+		})
+
+		Context("When address ranges are reserved", func() {
+			It("Should only return addresses outside of the reserved ranges", func() {
+				ap := &addressPool{
+					Addresses: map[string]*addressRecord{
+						addr11.String(): {Addr: addr11},
+						addr12.String(): {Addr: addr12},
+						addr13.String(): {Addr: addr13},
+					},
+					addrsByID: map[string]*addressRecord{},
+					Subnet:    subnet1,
+				}
+				options := map[string]string{OptReservedRanges: "10.0.1.1/32,10.0.1.2/32"}
+				addr, err := ap.requestAddress("", options)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(addr).To(Equal("10.0.1.3/24"))
+
+				addr, err = ap.requestAddress("", options)
+				Expect(err).To(Equal(errNoAvailableAddresses))
+				Expect(addr).To(BeEmpty())
+
+				// A reserved address can still be requested explicitly.
+				addr, err = ap.requestAddress(addr12.String(), options)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(addr).To(Equal("10.0.1.2/24"))
+			})
+		})
+
+		Context("When an address range is invalid", func() {
+			It("Should raise errInvalidConfiguration", func() {
+				ap := &addressPool{}
+				_, err := ap.requestAddress("", map[string]string{OptAddressRange: "10.0.1.2"})
+				Expect(errors.Is(err, errInvalidConfiguration)).To(BeTrue())
+				_, err = ap.requestAddress("", map[string]string{OptReservedRanges: "10.0.1.0/30,bad"})
+				Expect(errors.Is(err, errInvalidConfiguration)).To(BeTrue())
+			})
+		})
+
 		Context("When no available address", func() {
 			It("Should raise errNoAvailableAddresses", func() {
 				ap := &addressPool{
@@ -1109,5 +1172,106 @@ var _ = Describe("Test Pool", func() {
 				Expect(ap.Addresses["10.0.0.1/16"]).To(BeNil())
 			})
 		})
+
+		Context("When the address is quarantined", func() {
+			It("Should not allocate it again until the quarantine ends", func() {
+				_, subnet, _ := net.ParseCIDR("10.0.0.0/16")
+				ap := &addressPool{
+					as:        &addressSpace{epoch: 1},
+					Subnet:    *subnet,
+					Addresses: map[string]*addressRecord{},
+					addrsByID: map[string]*addressRecord{},
+				}
+				ap.Addresses["10.0.0.4"] = &addressRecord{Addr: net.ParseIP("10.0.0.4"), epoch: 1}
+				options := map[string]string{OptAddressID: "container-1"}
+
+				addr, err := ap.requestAddress("", options)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(addr).To(Equal("10.0.0.4/16"))
+
+				options[OptAddressQuarantine] = "1h"
+				Expect(ap.releaseAddress("10.0.0.4", options)).To(Succeed())
+				Expect(ap.addrsByID).To(BeEmpty())
+				Expect(ap.getUsage().Quarantined).To(Equal(1))
+
+				delete(options, OptAddressQuarantine)
+				_, err = ap.requestAddress("", options)
+				Expect(err).To(Equal(errNoAvailableAddresses))
+				_, err = ap.requestAddress("10.0.0.4", options)
+				Expect(err).To(Equal(errAddressQuarantined))
+
+				ap.Addresses["10.0.0.4"].QuarantinedUntil = time.Now().Add(-time.Second)
+				addr, err = ap.requestAddress("", options)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(addr).To(Equal("10.0.0.4/16"))
+			})
+		})
+
+		Context("When the quarantine is invalid", func() {
+			It("Should return an error", func() {
+				ap := &addressPool{
+					Addresses: map[string]*addressRecord{"10.0.0.4": {Addr: net.ParseIP("10.0.0.4"), InUse: true}},
+				}
+				err := ap.releaseAddress("10.0.0.4", map[string]string{OptAddressQuarantine: "forever"})
+				Expect(errors.Is(err, errInvalidConfiguration)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Test getUsage", func() {
+		Context("When the pool has allocated and reserved addresses", func() {
+			It("Should count the addresses by state", func() {
+				_, subnet, _ := net.ParseCIDR("10.0.0.0/16")
+				ap := &addressPool{
+					as:     &addressSpace{Id: LocalDefaultAddressSpaceId},
+					IfName: "eth0",
+					Subnet: *subnet,
+					Addresses: map[string]*addressRecord{
+						"10.0.0.4": {Addr: net.ParseIP("10.0.0.4"), InUse: true, ID: "container-1"},
+						"10.0.0.5": {Addr: net.ParseIP("10.0.0.5"), ID: "container-2"},
+						"10.0.0.6": {Addr: net.ParseIP("10.0.0.6"), unhealthy: true},
+						"10.0.0.7": {Addr: net.ParseIP("10.0.0.7")},
+					},
+				}
+				usage := ap.getUsage()
+				Expect(usage.AddressSpace).To(Equal(LocalDefaultAddressSpaceId))
+				Expect(usage.Subnet).To(Equal("10.0.0.0/16"))
+				Expect(usage.IfName).To(Equal("eth0"))
+				Expect(usage.Total).To(Equal(4))
+				Expect(usage.Allocated).To(Equal(1))
+				Expect(usage.Reserved).To(Equal(1))
+				Expect(usage.Unhealthy).To(Equal(1))
+				Expect(usage.Available).To(Equal(2))
+			})
+		})
+	})
+
+	Describe("Test defragment", func() {
+		Context("When addresses are held without being in use", func() {
+			It("Should return them to the free addresses", func() {
+				inUse := &addressRecord{Addr: net.ParseIP("10.0.0.4"), InUse: true, ID: "container-1", epoch: 1}
+				reserved := &addressRecord{Addr: net.ParseIP("10.0.0.5"), ID: "container-2", epoch: 1}
+				stale := &addressRecord{Addr: net.ParseIP("10.0.0.6"), epoch: 0}
+				ap := &addressPool{
+					as: &addressSpace{epoch: 1},
+					Addresses: map[string]*addressRecord{
+						"10.0.0.4": inUse,
+						"10.0.0.5": reserved,
+						"10.0.0.6": stale,
+					},
+					addrsByID: map[string]*addressRecord{
+						"container-1": inUse,
+						"container-2": reserved,
+						"container-3": {Addr: net.ParseIP("10.0.0.9"), ID: "container-3"},
+					},
+				}
+				Expect(ap.defragment()).To(Equal(2))
+				Expect(ap.Addresses).To(HaveLen(2))
+				Expect(ap.Addresses["10.0.0.5"].ID).To(BeEmpty())
+				Expect(ap.addrsByID).To(HaveLen(1))
+				Expect(ap.addrsByID["container-1"]).To(Equal(inUse))
+				Expect(ap.defragment()).To(Equal(0))
+			})
+		})
 	})
 })
@@ -0,0 +1,134 @@
+package iptables
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Backend is the kernel API the iptables binaries program rules through.
+type Backend string
+
+const (
+	// Legacy programs rules through the x_tables kernel API.
+	Legacy Backend = "legacy"
+	// Nft programs rules through nf_tables.
+	Nft Backend = "nft"
+	// Unknown means the host only ships a plain iptables binary, which is used as is.
+	Unknown Backend = "unknown"
+)
+
+const (
+	nftKernelModulePath    = "/sys/module/nf_tables"
+	legacyKernelTablesPath = "/proc/net/ip_tables_names"
+	saveCmd                = "%s-save 2>/dev/null"
+)
+
+var iptablesBackend = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "iptables_backend",
+		Help: "The iptables backend in use, 1 for the selected backend.",
+	},
+	[]string{"backend"},
+)
+
+var (
+	backendOnce     sync.Once
+	selectedBackend Backend
+
+	// hooks overridden in tests
+	lookPath   = exec.LookPath
+	countRules = countSavedRules
+	pathExists = func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+)
+
+// GetBackend returns the backend used by this package. The backend is detected on first use and kept
+// for the lifetime of the process so that all rules end up in the same backend.
+func GetBackend() Backend {
+	backendOnce.Do(func() {
+		selectedBackend = detectBackend()
+		log.Printf("[iptables] Using %s iptables backend", selectedBackend)
+		iptablesBackend.WithLabelValues(string(selectedBackend)).Set(1)
+	})
+
+	return selectedBackend
+}
+
+// detectBackend picks the backend the host already uses. When both variants are installed the backend
+// holding more rules wins, since kubelet and kube-proxy have programmed their rules by the time we run.
+// On a host without any rules the backend supported by the kernel is preferred, nft first.
+func detectBackend() Backend {
+	_, nftErr := lookPath(Nft.command(iptables))
+	_, legacyErr := lookPath(Legacy.command(iptables))
+	hasNft, hasLegacy := nftErr == nil, legacyErr == nil
+
+	switch {
+	case !hasNft && !hasLegacy:
+		return Unknown
+	case !hasLegacy:
+		return Nft
+	case !hasNft:
+		return Legacy
+	}
+
+	nftRules := countRules(Nft)
+	legacyRules := countRules(Legacy)
+	log.Printf("[iptables] Found %d nft and %d legacy rules", nftRules, legacyRules)
+
+	switch {
+	case nftRules > legacyRules:
+		return Nft
+	case legacyRules > nftRules:
+		return Legacy
+	case pathExists(nftKernelModulePath):
+		return Nft
+	case pathExists(legacyKernelTablesPath):
+		return Legacy
+	default:
+		return Nft
+	}
+}
+
+// countSavedRules returns the number of rules listed by iptables-save and ip6tables-save of the backend.
+func countSavedRules(b Backend) int {
+	p := platform.NewExecClient()
+	count := 0
+	for _, base := range []string{iptables, ip6tables} {
+		out, err := p.ExecuteCommand(fmt.Sprintf(saveCmd, b.command(base)))
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, "-A ") {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// command returns the binary of the backend for a base command such as iptables or ip6tables-restore.
+func (b Backend) command(base string) string {
+	if b == Unknown || b == "" {
+		return base
+	}
+
+	for _, prefix := range []string{ip6tables, iptables} {
+		if strings.HasPrefix(base, prefix) {
+			return prefix + "-" + string(b) + strings.TrimPrefix(base, prefix)
+		}
+	}
+
+	return base
+}
@@ -0,0 +1,106 @@
+package iptables
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errNotFound = errors.New("not found")
+
+func TestBackendCommand(t *testing.T) {
+	require.Equal(t, "iptables-nft", Nft.command(iptables))
+	require.Equal(t, "ip6tables-legacy", Legacy.command(ip6tables))
+	require.Equal(t, "iptables-nft-restore", Nft.command(iptablesRestore))
+	require.Equal(t, "ip6tables-legacy-restore", Legacy.command(ip6tablesRestore))
+	require.Equal(t, "iptables-restore", Unknown.command(iptablesRestore))
+}
+
+func TestDetectBackend(t *testing.T) {
+	origLookPath, origCountRules, origPathExists := lookPath, countRules, pathExists
+	defer func() {
+		lookPath, countRules, pathExists = origLookPath, origCountRules, origPathExists
+	}()
+
+	tests := []struct {
+		name        string
+		binaries    []string
+		nftRules    int
+		legacyRules int
+		paths       []string
+		want        Backend
+	}{
+		{
+			name: "no variant binaries",
+			want: Unknown,
+		},
+		{
+			name:     "only nft installed",
+			binaries: []string{"iptables-nft"},
+			want:     Nft,
+		},
+		{
+			name:     "only legacy installed",
+			binaries: []string{"iptables-legacy"},
+			want:     Legacy,
+		},
+		{
+			name:        "legacy holds the rules",
+			binaries:    []string{"iptables-nft", "iptables-legacy"},
+			nftRules:    2,
+			legacyRules: 40,
+			paths:       []string{nftKernelModulePath},
+			want:        Legacy,
+		},
+		{
+			name:        "nft holds the rules",
+			binaries:    []string{"iptables-nft", "iptables-legacy"},
+			nftRules:    40,
+			legacyRules: 0,
+			want:        Nft,
+		},
+		{
+			name:     "no rules, legacy kernel only",
+			binaries: []string{"iptables-nft", "iptables-legacy"},
+			paths:    []string{legacyKernelTablesPath},
+			want:     Legacy,
+		},
+		{
+			name:     "no rules, nft kernel support",
+			binaries: []string{"iptables-nft", "iptables-legacy"},
+			paths:    []string{nftKernelModulePath, legacyKernelTablesPath},
+			want:     Nft,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			lookPath = func(file string) (string, error) {
+				for _, b := range tt.binaries {
+					if b == file {
+						return "/usr/sbin/" + file, nil
+					}
+				}
+				return "", errNotFound
+			}
+			countRules = func(b Backend) int {
+				if b == Nft {
+					return tt.nftRules
+				}
+				return tt.legacyRules
+			}
+			pathExists = func(path string) bool {
+				for _, p := range tt.paths {
+					if p == path {
+						return true
+					}
+				}
+				return false
+			}
+
+			require.Equal(t, tt.want, detectBackend())
+		})
+	}
+}
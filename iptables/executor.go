@@ -0,0 +1,103 @@
+package iptables
+
+import (
+	"bytes"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// maxLockAttempts bounds the retries when another process holds the xtables lock.
+	maxLockAttempts = 3
+	// lockRetryDelay is the base delay before retrying a change, up to half of it is added as jitter so
+	// that processes contending for the lock don't retry in lockstep.
+	lockRetryDelay = 200 * time.Millisecond
+	// exitCodeResourceProblem is returned by iptables when the xtables lock can't be acquired.
+	exitCodeResourceProblem = 4
+	xtablesLockMsg          = "xtables lock"
+	resourceUnavailableMsg  = "Resource temporarily unavailable"
+)
+
+var (
+	lockWaitSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "iptables_lock_wait_seconds",
+			Help:    "Time iptables changes waited for other changes of the process and for retries of the xtables lock.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8), //nolint:gomnd // 1ms to ~16s
+		},
+	)
+	lockRetries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "iptables_lock_retries_total",
+			Help: "Number of iptables changes retried because another process held the xtables lock.",
+		},
+	)
+)
+
+// executor serializes the iptables changes of the process.
+type executor struct {
+	mu    sync.Mutex
+	sleep func(time.Duration)
+}
+
+func newExecutor() *executor {
+	return &executor{sleep: time.Sleep}
+}
+
+var changes = newExecutor()
+
+// run runs the change once the changes started before it are done, retrying with jitter while another
+// process holds the xtables lock. The change returns its output to detect lock contention.
+func (e *executor) run(name string, change func() ([]byte, error)) error {
+	start := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	wait := time.Since(start)
+
+	var err error
+	for attempt := 1; attempt <= maxLockAttempts; attempt++ {
+		var out []byte
+		if out, err = change(); err == nil || !isLockContention(err, out) {
+			break
+		}
+
+		if attempt < maxLockAttempts {
+			lockRetries.Inc()
+			log.Printf("[iptables] %s could not acquire the xtables lock, attempt %d of %d", name, attempt, maxLockAttempts)
+			delay := jitter(lockRetryDelay)
+			e.sleep(delay)
+			wait += delay
+		}
+	}
+
+	lockWaitSeconds.Observe(wait.Seconds())
+	return err
+}
+
+// jitter returns the delay with up to half of it added at random.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration(rand.Int63n(int64(d)/2+1)) //nolint:gosec // no need for crypto randomness
+}
+
+// isLockContention returns true if the command failed because another process held the xtables lock.
+func isLockContention(err error, out []byte) bool {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitCodeResourceProblem {
+		return true
+	}
+
+	for _, msg := range []string{xtablesLockMsg, resourceUnavailableMsg} {
+		if bytes.Contains(out, []byte(msg)) || strings.Contains(err.Error(), msg) {
+			return true
+		}
+	}
+
+	return false
+}
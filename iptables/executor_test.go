@@ -0,0 +1,69 @@
+package iptables
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutorRetriesLockContention(t *testing.T) {
+	e := newExecutor()
+	var delays []time.Duration
+	e.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	calls := 0
+	err := e.run("iptables", func() ([]byte, error) {
+		calls++
+		return nil, errors.New("exit status 4:Another app is currently holding the xtables lock")
+	})
+	require.Error(t, err)
+	require.Equal(t, maxLockAttempts, calls)
+	require.Len(t, delays, maxLockAttempts-1)
+	for _, d := range delays {
+		require.GreaterOrEqual(t, d, lockRetryDelay)
+		require.LessOrEqual(t, d, lockRetryDelay*3/2)
+	}
+
+	calls = 0
+	err = e.run("iptables", func() ([]byte, error) {
+		calls++
+		return nil, errors.New("exit status 1:Bad rule")
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls, "only lock contention is retried")
+}
+
+func TestExecutorSerializesChanges(t *testing.T) {
+	e := newExecutor()
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = e.run("iptables", func() ([]byte, error) {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, maxRunning)
+}
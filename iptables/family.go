@@ -0,0 +1,135 @@
+package iptables
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrFamilyMismatch is returned when a rule matching addresses of one IP family is programmed for another.
+var ErrFamilyMismatch = errors.New("rule addresses do not match the IP family")
+
+// Family is a bitmask of the IP families a rule is programmed for.
+type Family uint8
+
+const (
+	FamilyV4 Family = 1 << iota
+	FamilyV6
+	// FamilyDual programs rules with iptables and ip6tables.
+	FamilyDual = FamilyV4 | FamilyV6
+)
+
+// Versions returns the IP versions of the family, V4 first.
+func (f Family) Versions() []string {
+	var versions []string
+	if f&FamilyV4 != 0 {
+		versions = append(versions, V4)
+	}
+	if f&FamilyV6 != 0 {
+		versions = append(versions, V6)
+	}
+
+	return versions
+}
+
+// FamilyOf returns the family of the address or CIDR, zero if it can't be parsed.
+func FamilyOf(address string) Family {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(address); err != nil {
+			return 0
+		}
+	}
+
+	if ip.To4() != nil {
+		return FamilyV4
+	}
+
+	return FamilyV6
+}
+
+// family returns the families the rule can be programmed for, which is limited by the addresses it matches.
+func (r *Rule) family() Family {
+	f := FamilyDual
+	for _, m := range r.Matches {
+		for i := 0; i < len(m.Args)-1; i++ {
+			switch strings.TrimLeft(m.Args[i], "-") {
+			case "s", "d", "source", "destination", "to", "to-source", "to-destination":
+				if af := FamilyOf(m.Args[i+1]); af != 0 {
+					f &= af
+				}
+			}
+		}
+	}
+	for i := 0; i < len(r.Target.Args)-1; i++ {
+		if strings.HasPrefix(r.Target.Args[i], "--to") {
+			if af := FamilyOf(r.Target.Args[i+1]); af != 0 {
+				f &= af
+			}
+		}
+	}
+
+	return f
+}
+
+// ForFamily returns a copy of the rule for each IP version of the family. The Version of the rule is ignored.
+func (r *Rule) ForFamily(f Family) ([]*Rule, error) {
+	if f&^r.family() != 0 {
+		return nil, errors.Wrapf(ErrFamilyMismatch, "rule %s", r)
+	}
+
+	versions := f.Versions()
+	rules := make([]*Rule, 0, len(versions))
+	for _, version := range versions {
+		rule := *r
+		rule.Version = version
+		rules = append(rules, &rule)
+	}
+
+	return rules, nil
+}
+
+// EnsureChainFamily creates the chain in the table of each IP version of the family.
+func EnsureChainFamily(f Family, tableName, chainName string) error {
+	for _, version := range f.Versions() {
+		if err := EnsureChain(version, tableName, chainName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EnsureRuleFamily programs the rule for each IP version of the family, see EnsureRule.
+func EnsureRuleFamily(f Family, rule *Rule, action string) error {
+	rules, err := rule.ForFamily(f)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rules {
+		if err := EnsureRule(r, action); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteRuleFamily deletes the rule for each IP version of the family, see DeleteRule.
+func DeleteRuleFamily(f Family, rule *Rule) error {
+	rules, err := rule.ForFamily(f)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rules {
+		if err := DeleteRule(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package iptables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFamilyVersions(t *testing.T) {
+	require.Equal(t, []string{V4}, FamilyV4.Versions())
+	require.Equal(t, []string{V6}, FamilyV6.Versions())
+	require.Equal(t, []string{V4, V6}, FamilyDual.Versions())
+	require.Empty(t, Family(0).Versions())
+}
+
+func TestFamilyOf(t *testing.T) {
+	require.Equal(t, FamilyV4, FamilyOf("10.0.0.4"))
+	require.Equal(t, FamilyV4, FamilyOf("10.0.0.0/8"))
+	require.Equal(t, FamilyV6, FamilyOf("fc00::/7"))
+	require.Equal(t, FamilyV6, FamilyOf("fd00::4"))
+	require.Equal(t, Family(0), FamilyOf("azure-set"))
+}
+
+func TestRuleForFamily(t *testing.T) {
+	rule := &Rule{
+		Version: V4,
+		Table:   Filter,
+		Chain:   Forward,
+		Matches: []Match{MatchInInterface("azSnatbr"), MatchSet("azSnatbr-private-ranges", "dst")},
+		Target:  Jump(Drop),
+	}
+
+	rules, err := rule.ForFamily(FamilyDual)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, V4, rules[0].Version)
+	require.Equal(t, V6, rules[1].Version)
+	require.Equal(t, rule.Render(), rules[1].Render())
+	require.Equal(t, V4, rule.Version, "the original rule must not be modified")
+
+	v4Only := &Rule{Table: Filter, Chain: Input, Matches: []Match{MatchSource("10.0.0.4")}, Target: Jump(Accept)}
+	rules, err = v4Only.ForFamily(FamilyV4)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	_, err = v4Only.ForFamily(FamilyDual)
+	require.ErrorIs(t, err, ErrFamilyMismatch)
+
+	snat := &Rule{Table: Nat, Chain: Postrouting, Target: Jump(Snat, "--to", "fd00::1")}
+	_, err = snat.ForFamily(FamilyV4)
+	require.ErrorIs(t, err, ErrFamilyMismatch)
+	rules, err = snat.ForFamily(FamilyV6)
+	require.NoError(t, err)
+	require.Equal(t, V6, rules[0].Version)
+}
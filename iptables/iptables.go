@@ -5,7 +5,6 @@ package iptables
 import (
 	"fmt"
 
-	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
 )
 
@@ -79,6 +78,9 @@ const (
 
 var DisableIPTableLock bool
 
+// ReleaseVersion is the release of the running component, recorded on the rules tagged by a Registry.
+var ReleaseVersion = "unknown"
+
 type IPTableEntry struct {
 	Version string
 	Params  string
@@ -86,25 +88,39 @@ type IPTableEntry struct {
 
 // Run iptables command
 func RunCmd(version, params string) error {
-	var cmd string
-
 	p := platform.NewExecClient()
+	cmd := command(version, params)
+	if !isChange(params) {
+		_, err := p.ExecuteCommand(cmd)
+		return err
+	}
+
+	return changes.run(cmd, func() ([]byte, error) {
+		_, err := p.ExecuteCommand(cmd)
+		record(cmd, "", err)
+		return nil, err
+	})
+}
+
+// command returns the iptables or ip6tables command line of the selected backend running params.
+func command(version, params string) string {
 	iptCmd := iptables
 	if version == V6 {
 		iptCmd = ip6tables
 	}
+	iptCmd = GetBackend().command(iptCmd)
 
 	if DisableIPTableLock {
-		cmd = fmt.Sprintf("%s %s", iptCmd, params)
-	} else {
-		cmd = fmt.Sprintf("%s -w %d %s", iptCmd, lockTimeout, params)
+		return fmt.Sprintf("%s %s", iptCmd, params)
 	}
 
-	if _, err := p.ExecuteCommand(cmd); err != nil {
-		return err
-	}
+	return fmt.Sprintf("%s -w %d %s", iptCmd, lockTimeout, params)
+}
 
-	return nil
+// listRules returns the chains and rules of the table in iptables -S format.
+func listRules(version, tableName string) (string, error) {
+	p := platform.NewExecClient()
+	return p.ExecuteCommand(command(version, fmt.Sprintf("-t %s -S", tableName)))
 }
 
 // check if iptable chain alreay exists
@@ -125,26 +141,28 @@ func GetCreateChainCmd(version, tableName, chainName string) IPTableEntry {
 }
 
 // create new iptable chain under specified table name
+//
+// Deprecated: use EnsureChain.
 func CreateChain(version, tableName, chainName string) error {
-	var err error
+	return EnsureChain(version, tableName, chainName)
+}
 
-	if !ChainExists(version, tableName, chainName) {
-		cmd := GetCreateChainCmd(version, tableName, chainName)
-		err = RunCmd(version, cmd.Params)
-	} else {
-		log.Printf("%s Chain exists in table %s", chainName, tableName)
+// newRawRule builds a rule from a match condition and target in iptables syntax.
+func newRawRule(version, tableName, chainName, match, target string) *Rule {
+	return &Rule{
+		Version: version,
+		Table:   tableName,
+		Chain:   chainName,
+		Matches: []Match{MatchRaw(match)},
+		Target:  parseTarget(target),
 	}
-
-	return err
 }
 
 // check if iptable rule alreay exists
+//
+// Deprecated: use Rule.Exists.
 func RuleExists(version, tableName, chainName, match, target string) bool {
-	params := fmt.Sprintf("-t %s -C %s %s -j %s", tableName, chainName, match, target)
-	if err := RunCmd(version, params); err != nil {
-		return false
-	}
-	return true
+	return newRawRule(version, tableName, chainName, match, target).Exists()
 }
 
 func GetInsertIptableRuleCmd(version, tableName, chainName, match, target string) IPTableEntry {
@@ -155,14 +173,10 @@ func GetInsertIptableRuleCmd(version, tableName, chainName, match, target string
 }
 
 // Insert iptable rule at beginning of iptable chain
+//
+// Deprecated: use EnsureRule with Insert.
 func InsertIptableRule(version, tableName, chainName, match, target string) error {
-	if RuleExists(version, tableName, chainName, match, target) {
-		log.Printf("Rule already exists")
-		return nil
-	}
-
-	cmd := GetInsertIptableRuleCmd(version, tableName, chainName, match, target)
-	return RunCmd(version, cmd.Params)
+	return EnsureRule(newRawRule(version, tableName, chainName, match, target), Insert)
 }
 
 func GetAppendIptableRuleCmd(version, tableName, chainName, match, target string) IPTableEntry {
@@ -173,18 +187,16 @@ func GetAppendIptableRuleCmd(version, tableName, chainName, match, target string
 }
 
 // Append iptable rule at end of iptable chain
+//
+// Deprecated: use EnsureRule with Append.
 func AppendIptableRule(version, tableName, chainName, match, target string) error {
-	if RuleExists(version, tableName, chainName, match, target) {
-		log.Printf("Rule already exists")
-		return nil
-	}
-
-	cmd := GetAppendIptableRuleCmd(version, tableName, chainName, match, target)
-	return RunCmd(version, cmd.Params)
+	return EnsureRule(newRawRule(version, tableName, chainName, match, target), Append)
 }
 
-// Delete matched iptable rule
+// Delete matched iptable rule. Like DeleteRule it deletes every copy of the rule and returns nil if
+// the rule is not programmed, where it used to fail with the error of iptables -D.
+//
+// Deprecated: use DeleteRule.
 func DeleteIptableRule(version, tableName, chainName, match, target string) error {
-	params := fmt.Sprintf("-t %s -D %s %s -j %s", tableName, chainName, match, target)
-	return RunCmd(version, params)
+	return DeleteRule(newRawRule(version, tableName, chainName, match, target))
 }
@@ -0,0 +1,169 @@
+package iptables
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/internal/lockedfile"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultJournalPath is the file the changes made by this package are recorded in.
+	DefaultJournalPath = "/var/log/azure-vnet-iptables.journal"
+	journalBackupExt   = ".1"
+	journalLockExt     = ".lock"
+	journalFilePerm    = 0o600
+	journalResultOK    = "ok"
+	packagePath        = "github.com/Azure/azure-container-networking/iptables."
+	maxCallerDepth     = 32
+)
+
+var (
+	// JournalPath is the journal changes are recorded in, recording is disabled if empty. Components
+	// enable it at startup so that tests of packages making changes don't write to the host.
+	JournalPath string
+
+	// journalMaxSize bounds the journal file, once exceeded it is rotated to a single backup.
+	journalMaxSize int64 = 1 << 20
+
+	journalMu sync.Mutex
+)
+
+// JournalEntry is a change of the iptables rules made by this package.
+type JournalEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Caller is the process and the function outside of this package which requested the change.
+	Caller  string `json:"caller"`
+	Command string `json:"command"`
+	// Input is the input of iptables-restore commands.
+	Input  string `json:"input,omitempty"`
+	Result string `json:"result"`
+}
+
+// isChange returns true if the iptables parameters modify rules or chains, as opposed to listing or
+// checking them.
+func isChange(params string) bool {
+	for _, field := range strings.Fields(params) {
+		switch field {
+		case "-A", "-I", "-D", "-R", "-N", "-X", "-F", "-P", "-E", "-Z":
+			return true
+		}
+	}
+
+	return false
+}
+
+// record appends the command and its result to the journal. Failures to record are only logged, they
+// must not fail the change itself.
+func record(command, input string, cmdErr error) {
+	if JournalPath == "" {
+		return
+	}
+
+	entry := JournalEntry{
+		Timestamp: time.Now().UTC(),
+		Caller:    caller(),
+		Command:   command,
+		Input:     input,
+		Result:    journalResultOK,
+	}
+	if cmdErr != nil {
+		entry.Result = cmdErr.Error()
+	}
+
+	if err := appendEntry(JournalPath, &entry); err != nil {
+		log.Printf("[iptables] Failed to record %s in journal: %v", command, err)
+	}
+}
+
+// appendEntry writes the entry as a single line. Lines are appended in a single write so that
+// concurrent processes don't interleave their entries, and the journal is rotated under a file lock so
+// that concurrent processes don't rotate it twice and overwrite the backup.
+func appendEntry(path string, entry *JournalEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal journal entry")
+	}
+
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	unlock, err := lockedfile.MutexAt(path + journalLockExt).Lock()
+	if err != nil {
+		return errors.Wrap(err, "failed to lock journal")
+	}
+	defer unlock()
+
+	if info, err := os.Stat(path); err == nil && info.Size() >= journalMaxSize {
+		if err := os.Rename(path, path+journalBackupExt); err != nil {
+			return errors.Wrap(err, "failed to rotate journal")
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, journalFilePerm)
+	if err != nil {
+		return errors.Wrap(err, "failed to open journal")
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(b, '\n'))
+	return errors.Wrap(err, "failed to write journal")
+}
+
+// caller returns the process and the first function outside of this package on the stack.
+func caller() string {
+	process := fmt.Sprintf("%s[%d]", filepath.Base(os.Args[0]), os.Getpid())
+
+	pcs := make([]uintptr, maxCallerDepth)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)]) //nolint:gomnd // skip runtime.Callers and caller
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePath) {
+			return fmt.Sprintf("%s %s:%d", process, frame.Function, frame.Line)
+		}
+		if !more {
+			return process
+		}
+	}
+}
+
+// ReadJournal returns the entries of the journal at path, including its rotated backup, oldest first.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	for _, p := range []string{path + journalBackupExt, path} {
+		f, err := os.Open(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open journal")
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, int(journalMaxSize))
+		for scanner.Scan() {
+			var entry JournalEntry
+			// a line may be truncated if the node went down while it was written
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read journal %s", p)
+		}
+	}
+
+	return entries, nil
+}
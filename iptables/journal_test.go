@@ -0,0 +1,124 @@
+package iptables
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/internal/lockedfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// keep tests from waiting for lock retries
+	changes.sleep = func(time.Duration) {}
+	os.Exit(m.Run())
+}
+
+func setJournal(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "iptables.journal")
+	JournalPath = path
+	t.Cleanup(func() { JournalPath = "" })
+	return path
+}
+
+func TestIsChange(t *testing.T) {
+	require.True(t, isChange("-t filter -A INPUT -j ACCEPT"))
+	require.True(t, isChange("-t nat -I POSTROUTING 1 -j MASQUERADE"))
+	require.True(t, isChange("-t filter -X AZURECNIOLD"))
+	require.False(t, isChange("-t filter -C INPUT -j ACCEPT"))
+	require.False(t, isChange("-t filter -S"))
+	require.False(t, isChange("-t filter -L AZURECNIINPUT"))
+}
+
+func TestJournal(t *testing.T) {
+	path := setJournal(t)
+
+	record("iptables -t filter -A INPUT -j ACCEPT", "", nil)
+	record("iptables -t filter -D INPUT -j ACCEPT", "", errors.New("exit status 1"))
+
+	entries, err := ReadJournal(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "iptables -t filter -A INPUT -j ACCEPT", entries[0].Command)
+	require.Equal(t, journalResultOK, entries[0].Result)
+	require.Equal(t, "exit status 1", entries[1].Result)
+	require.False(t, entries[0].Timestamp.IsZero())
+	require.True(t, strings.HasPrefix(entries[0].Caller, "iptables.test["))
+}
+
+func TestJournalRotation(t *testing.T) {
+	path := setJournal(t)
+	orig := journalMaxSize
+	journalMaxSize = 200
+	defer func() { journalMaxSize = orig }()
+
+	for i := 0; i < 10; i++ {
+		record("iptables -t filter -A INPUT -j ACCEPT", "", nil)
+	}
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Less(t, info.Size(), 2*journalMaxSize)
+
+	entries, err := ReadJournal(path)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	require.Less(t, len(entries), 10, "entries older than the backup are dropped")
+}
+
+func TestWriterJournal(t *testing.T) {
+	path := setJournal(t)
+
+	w := NewWriter(V4)
+	w.restore = func(string, []string, []byte) ([]byte, error) { return nil, nil }
+	w.chainExists = func(string, string, string) bool { return false }
+	w.EnsureChain(Nat, Swift)
+	require.NoError(t, w.Flush())
+
+	entries, err := ReadJournal(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.True(t, strings.HasSuffix(entries[0].Command, "-restore --noflush -w 60"))
+	require.Contains(t, entries[0].Input, ":SWIFT - [0:0]")
+}
+
+func TestReadJournalMissing(t *testing.T) {
+	entries, err := ReadJournal(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestJournalRotationAcrossProcesses(t *testing.T) {
+	path := setJournal(t)
+	orig := journalMaxSize
+	journalMaxSize = 200
+	defer func() { journalMaxSize = orig }()
+
+	// another process holding the journal lock blocks the rotation until it is done
+	unlock, err := lockedfile.MutexAt(path + journalLockExt).Lock()
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		record("iptables -t filter -A INPUT -j ACCEPT", "", nil)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("entry recorded while the journal was locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-done
+
+	entries, err := ReadJournal(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
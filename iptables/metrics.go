@@ -0,0 +1,18 @@
+package iptables
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterMetrics registers the metrics of this package with the registerer of the component using it.
+// The metrics are recorded either way, they are only exposed once registered.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{iptablesBackend, lockWaitSeconds, lockRetries} {
+		if err := registerer.Register(c); err != nil {
+			return errors.Wrap(err, "failed to register iptables metrics")
+		}
+	}
+
+	return nil
+}
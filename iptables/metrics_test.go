@@ -0,0 +1,17 @@
+package iptables
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetrics(registry))
+	require.Error(t, RegisterMetrics(registry), "registering twice must fail")
+
+	// the metrics of the package are not registered with any other registry
+	require.NoError(t, RegisterMetrics(prometheus.NewRegistry()))
+}
@@ -0,0 +1,351 @@
+package iptables
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	commentModule = "comment"
+	// tagSeparator separates the owner, release and rule hash within a tag.
+	tagSeparator = "/"
+	ruleHashLen  = 8
+)
+
+// MatchComment attaches a comment to the rule. Whitespace in the comment is replaced since rules are
+// run through the shell.
+func MatchComment(comment string) Match {
+	return Match{Module: commentModule, Args: []string{"--comment", strings.Join(strings.Fields(comment), "_")}}
+}
+
+// sanitizeTag replaces the characters which can't appear in a field of a tag.
+func sanitizeTag(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), "_"), tagSeparator, "_")
+}
+
+type chainID struct {
+	version string
+	table   string
+	chain   string
+}
+
+type registeredRule struct {
+	tagged   *Rule
+	untagged *Rule
+}
+
+// Registry records the chains and rules a component owns. Rules are tagged with a comment holding the
+// owner, the release and a hash of the rule, so that rules left behind by other releases of the owner
+// can be found and removed by CleanupOrphans after an upgrade.
+type Registry struct {
+	owner    string
+	version  string
+	prefixes []string
+
+	mu     sync.Mutex
+	chains map[chainID]struct{}
+	rules  map[string]registeredRule
+
+	// hooks overridden in tests
+	list       func(version, tableName string) (string, error)
+	run        func(version, params string) error
+	ruleExists func(rule *Rule) bool
+}
+
+// NewRegistry returns a registry for the rules of owner at the release version. Chains whose name starts
+// with one of chainPrefixes are considered owned and are removed by a pruning cleanup unless registered.
+func NewRegistry(owner, version string, chainPrefixes ...string) *Registry {
+	return &Registry{
+		owner:      sanitizeTag(owner),
+		version:    sanitizeTag(version),
+		prefixes:   chainPrefixes,
+		chains:     make(map[chainID]struct{}),
+		rules:      make(map[string]registeredRule),
+		list:       listRules,
+		run:        RunCmd,
+		ruleExists: func(rule *Rule) bool { return rule.Exists() },
+	}
+}
+
+// UseExec makes the registry list tables with list, run iptables with run and look up existing rules with
+// ruleExists, for callers which run iptables through their own exec interface.
+func (r *Registry) UseExec(list func(version, tableName string) (string, error), run func(version, params string) error, ruleExists func(rule *Rule) bool) {
+	r.list = list
+	r.run = run
+	r.ruleExists = ruleExists
+}
+
+// Tag returns a copy of the rule carrying the tag of the registry and records it as desired.
+func (r *Registry) Tag(rule *Rule) *Rule {
+	hash := ruleHash(rule)
+	tagged := *rule
+	tagged.Matches = append(append([]Match{}, rule.Matches...), MatchComment(r.tag(hash)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules[hash] = registeredRule{tagged: &tagged, untagged: rule}
+	return &tagged
+}
+
+// RegisterChain records the chain as desired.
+func (r *Registry) RegisterChain(version, tableName, chainName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.chains[chainID{version: version, table: tableName, chain: chainName}] = struct{}{}
+}
+
+func (r *Registry) tag(hash string) string {
+	return strings.Join([]string{r.owner, r.version, hash}, tagSeparator)
+}
+
+// ruleHash identifies a rule independent of the release which programmed it.
+func ruleHash(rule *Rule) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s %s", rule.version(), rule.String())))
+	return hex.EncodeToString(sum[:])[:ruleHashLen]
+}
+
+// parseTag splits a comment created by a registry.
+func parseTag(comment string) (owner, version, hash string, ok bool) {
+	fields := strings.Split(comment, tagSeparator)
+	if len(fields) != 3 { //nolint:gomnd // owner, release and hash
+		return "", "", "", false
+	}
+
+	return fields[0], fields[1], fields[2], true
+}
+
+// ownsChain returns true if the chain name starts with one of the prefixes of the registry.
+func (r *Registry) ownsChain(chainName string) bool {
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(chainName, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeleteRule deletes every copy of the rule programmed by the owner, whichever release tagged it, and
+// untagged copies programmed before rules were tagged.
+func (r *Registry) DeleteRule(rule *Rule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	hash := ruleHash(rule)
+	out, err := r.list(rule.version(), rule.Table)
+	if err != nil {
+		return fmt.Errorf("failed to list rules of table %s: %w", rule.Table, err)
+	}
+
+	_, rules := parseListing(out)
+	for _, listed := range rules {
+		owner, _, h, ok := parseTag(listed.comment)
+		if !ok || owner != r.owner || h != hash || listed.chain != rule.Chain {
+			continue
+		}
+
+		if err := r.run(rule.version(), fmt.Sprintf("-t %s -D %s %s", rule.Table, listed.chain, listed.spec)); err != nil {
+			return fmt.Errorf("failed to delete rule %s: %w", rule, err)
+		}
+	}
+
+	for r.ruleExists(rule) {
+		if err := r.run(rule.version(), rule.params(Delete)); err != nil {
+			return fmt.Errorf("failed to delete rule %s: %w", rule, err)
+		}
+	}
+
+	return nil
+}
+
+// listedRule is a rule as listed by iptables -S.
+type listedRule struct {
+	chain   string
+	spec    string
+	comment string
+	target  string
+}
+
+// parseListing returns the chains and rules of a table listed by iptables -S.
+func parseListing(out string) (chains []string, rules []listedRule) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 { //nolint:gomnd // option and chain
+			continue
+		}
+
+		switch fields[0] {
+		case "-N":
+			chains = append(chains, fields[1])
+		case "-A":
+			rule := listedRule{chain: fields[1], spec: strings.Join(fields[2:], " ")}
+			for i := 2; i < len(fields)-1; i++ {
+				switch fields[i] {
+				case "--comment":
+					rule.comment = strings.Trim(fields[i+1], `"`)
+				case "-j":
+					rule.target = fields[i+1]
+				}
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	return chains, rules
+}
+
+// isOrphan returns true if the listed rule has to be removed. Rules of the owner tagged by another
+// release are removed once this release programs the same rule. When pruning, the registry holds the
+// complete desired state and every rule of the owner which is not registered is removed as well,
+// including untagged rules in owned chains which were programmed before rules were tagged.
+func (r *Registry) isOrphan(rule listedRule, prune bool) bool {
+	owner, version, hash, tagged := parseTag(rule.comment)
+	if !tagged {
+		return prune && rule.comment == "" && r.ownsChain(rule.chain)
+	}
+
+	if owner != r.owner {
+		return false
+	}
+
+	_, desired := r.rules[hash]
+	if version != r.version {
+		return desired || prune
+	}
+
+	return prune && !desired
+}
+
+// CleanupOrphans removes the rules and chains of the owner which were left behind by other releases.
+// Without pruning only rules superseded by a registered rule are removed, which is safe for callers
+// that register a subset of their rules. With pruning the registry is treated as the complete desired
+// state and all other rules of the owner, and all unregistered owned chains, are removed too.
+func (r *Registry) CleanupOrphans(prune bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Untagged copies of registered rules were programmed by releases which did not tag rules yet.
+	for _, rr := range r.rules {
+		for r.ruleExists(rr.untagged) {
+			log.Printf("[iptables] Removing untagged rule superseded by %s", rr.tagged)
+			if err := r.run(rr.untagged.version(), rr.untagged.params(Delete)); err != nil {
+				return fmt.Errorf("failed to delete rule %s: %w", rr.untagged, err)
+			}
+		}
+	}
+
+	for _, t := range r.tables(prune) {
+		isOrphan := func(rule listedRule) bool { return r.isOrphan(rule, prune) }
+		if err := r.cleanupTable(t.version, t.table, isOrphan, prune); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CleanupOrphanChains removes the owned chains which are not registered, and the rules jumping to them,
+// from the tables holding registered chains. Rules in the registered chains are left alone, which suits
+// owners such as NPM which don't tag their rules but whose chains change between releases.
+func (r *Registry) CleanupOrphanChains() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[chainID]bool)
+	for c := range r.chains {
+		t := chainID{version: c.version, table: c.table}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+
+		if err := r.cleanupTable(t.version, t.table, func(listedRule) bool { return false }, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tables returns the tables to scan. Without pruning only the tables holding registered rules can
+// contain superseded rules.
+func (r *Registry) tables(prune bool) []chainID {
+	var tables []chainID
+	if prune {
+		for _, version := range []string{V4, V6} {
+			for _, table := range []string{Filter, Nat, Mangle} {
+				tables = append(tables, chainID{version: version, table: table})
+			}
+		}
+		return tables
+	}
+
+	seen := make(map[chainID]bool)
+	for _, rr := range r.rules {
+		t := chainID{version: rr.tagged.version(), table: rr.tagged.Table}
+		if !seen[t] {
+			seen[t] = true
+			tables = append(tables, t)
+		}
+	}
+
+	return tables
+}
+
+// cleanupTable removes the rules of the table for which isOrphan returns true and, when pruneChains is
+// set, the owned chains which are not registered.
+func (r *Registry) cleanupTable(version, tableName string, isOrphan func(listedRule) bool, pruneChains bool) error {
+	out, err := r.list(version, tableName)
+	if err != nil {
+		// ip6tables or the table may not be available on the host, there is nothing to clean up.
+		log.Printf("[iptables] Skipping cleanup of table %s v%s: %v", tableName, version, err)
+		return nil
+	}
+
+	chains, rules := parseListing(out)
+
+	var orphanChains map[string]bool
+	if pruneChains {
+		orphanChains = make(map[string]bool)
+		for _, chain := range chains {
+			if _, ok := r.chains[chainID{version: version, table: tableName, chain: chain}]; !ok && r.ownsChain(chain) {
+				orphanChains[chain] = true
+			}
+		}
+	}
+
+	// Jumps to orphaned chains have to go before the chains can be deleted.
+	for _, rule := range rules {
+		if !isOrphan(rule) && !orphanChains[rule.target] {
+			continue
+		}
+
+		log.Printf("[iptables] Removing orphaned rule -t %s -A %s %s", tableName, rule.chain, rule.spec)
+		if err := r.run(version, fmt.Sprintf("-t %s -D %s %s", tableName, rule.chain, rule.spec)); err != nil {
+			return fmt.Errorf("failed to delete rule -A %s %s: %w", rule.chain, rule.spec, err)
+		}
+	}
+
+	for _, chain := range chains {
+		if !orphanChains[chain] {
+			continue
+		}
+
+		log.Printf("[iptables] Removing orphaned chain %s in table %s", chain, tableName)
+		for _, params := range []string{"-F", "-X"} {
+			if err := r.run(version, fmt.Sprintf("-t %s %s %s", tableName, params, chain)); err != nil {
+				return fmt.Errorf("failed to delete chain %s in table %s: %w", chain, tableName, err)
+			}
+		}
+	}
+
+	return nil
+}
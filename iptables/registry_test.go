@@ -0,0 +1,169 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeRegistry returns a registry listing the given IPv4 tables and recording the commands it runs.
+func newFakeRegistry(version string, listings map[string]string, existing map[string]bool, cmds *[]string) *Registry {
+	r := NewRegistry("azure-cni", version, "AZURECNI")
+	r.list = func(version, tableName string) (string, error) {
+		out, ok := listings[tableName]
+		if !ok || version != V4 {
+			return "", errNotFound
+		}
+		return out, nil
+	}
+	r.run = func(_, params string) error {
+		*cmds = append(*cmds, params)
+		return nil
+	}
+	r.ruleExists = func(rule *Rule) bool {
+		ok := existing[rule.String()]
+		existing[rule.String()] = false
+		return ok
+	}
+	return r
+}
+
+func newAcceptRule(src string) *Rule {
+	return &Rule{
+		Table:   Filter,
+		Chain:   CNIInputChain,
+		Matches: []Match{MatchSource(src)},
+		Target:  Jump(Accept),
+	}
+}
+
+func TestRegistryTag(t *testing.T) {
+	r := NewRegistry("azure cni", "v1.5/1", "AZURECNI")
+	rule := newAcceptRule("10.0.0.4")
+
+	tagged := r.Tag(rule)
+	require.Len(t, rule.Matches, 1, "the original rule must not be modified")
+	require.Equal(t, fmt.Sprintf("-s 10.0.0.4 -m comment --comment azure_cni/v1.5_1/%s -j ACCEPT", ruleHash(rule)), tagged.Render())
+
+	owner, version, hash, ok := parseTag(tagged.Matches[1].Args[1])
+	require.True(t, ok)
+	require.Equal(t, "azure_cni", owner)
+	require.Equal(t, "v1.5_1", version)
+	require.Equal(t, ruleHash(rule), hash)
+
+	// the hash is independent of the release
+	require.Equal(t, tagged.Render(), NewRegistry("azure cni", "v1.5/1").Tag(newAcceptRule("10.0.0.4")).Render())
+	require.NotEqual(t, ruleHash(rule), ruleHash(newAcceptRule("10.0.0.5")))
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	desired := newAcceptRule("10.0.0.4")
+	stale := newAcceptRule("10.0.0.5")
+	hash, staleHash := ruleHash(desired), ruleHash(stale)
+
+	listing := strings.Join([]string{
+		"-P INPUT ACCEPT",
+		"-N AZURECNIINPUT",
+		"-N AZURECNIOLD",
+		"-N KUBE-FIREWALL",
+		"-A INPUT -j AZURECNIINPUT",
+		"-A INPUT -j AZURECNIOLD",
+		"-A AZURECNIINPUT -s 10.0.0.4/32 -m comment --comment azure-cni/v2/" + hash + " -j ACCEPT",
+		"-A AZURECNIINPUT -s 10.0.0.4/32 -m comment --comment azure-cni/v1/" + hash + " -j ACCEPT",
+		"-A AZURECNIINPUT -s 10.0.0.5/32 -m comment --comment azure-cni/v1/" + staleHash + " -j ACCEPT",
+		"-A AZURECNIINPUT -s 10.0.0.6/32 -j ACCEPT",
+		"-A AZURECNIINPUT -s 10.0.0.7/32 -m comment --comment \"kubernetes firewall\" -j ACCEPT",
+		"-A KUBE-FIREWALL -m comment --comment azure-npm/v1/" + staleHash + " -j DROP",
+	}, "\n")
+
+	tests := []struct {
+		name  string
+		prune bool
+		want  []string
+	}{
+		{
+			name: "superseded rules only",
+			want: []string{
+				"-t filter -D AZURECNIINPUT -s 10.0.0.4 -j ACCEPT",
+				"-t filter -D AZURECNIINPUT -s 10.0.0.4/32 -m comment --comment azure-cni/v1/" + hash + " -j ACCEPT",
+			},
+		},
+		{
+			name:  "prune to the desired state",
+			prune: true,
+			want: []string{
+				"-t filter -D AZURECNIINPUT -s 10.0.0.4 -j ACCEPT",
+				"-t filter -D INPUT -j AZURECNIOLD",
+				"-t filter -D AZURECNIINPUT -s 10.0.0.4/32 -m comment --comment azure-cni/v1/" + hash + " -j ACCEPT",
+				"-t filter -D AZURECNIINPUT -s 10.0.0.5/32 -m comment --comment azure-cni/v1/" + staleHash + " -j ACCEPT",
+				"-t filter -D AZURECNIINPUT -s 10.0.0.6/32 -j ACCEPT",
+				"-t filter -F AZURECNIOLD",
+				"-t filter -X AZURECNIOLD",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var cmds []string
+			existing := map[string]bool{desired.String(): true}
+			r := newFakeRegistry("v2", map[string]string{Filter: listing}, existing, &cmds)
+			r.RegisterChain(V4, Filter, CNIInputChain)
+			r.Tag(desired)
+
+			require.NoError(t, r.CleanupOrphans(tt.prune))
+			require.Equal(t, tt.want, cmds)
+		})
+	}
+}
+
+func TestRegistryDeleteRule(t *testing.T) {
+	rule := newAcceptRule("10.0.0.4")
+	hash := ruleHash(rule)
+	listing := strings.Join([]string{
+		"-A AZURECNIINPUT -s 10.0.0.4/32 -m comment --comment azure-cni/v1/" + hash + " -j ACCEPT",
+		"-A AZURECNIINPUT -s 10.0.0.4/32 -m comment --comment azure-cni/v2/" + hash + " -j ACCEPT",
+		"-A AZURECNIINPUT -s 10.0.0.5/32 -m comment --comment azure-cni/v2/" + ruleHash(newAcceptRule("10.0.0.5")) + " -j ACCEPT",
+	}, "\n")
+
+	var cmds []string
+	r := newFakeRegistry("v2", map[string]string{Filter: listing}, map[string]bool{rule.String(): true}, &cmds)
+	require.NoError(t, r.DeleteRule(rule))
+	require.Equal(t, []string{
+		"-t filter -D AZURECNIINPUT -s 10.0.0.4/32 -m comment --comment azure-cni/v1/" + hash + " -j ACCEPT",
+		"-t filter -D AZURECNIINPUT -s 10.0.0.4/32 -m comment --comment azure-cni/v2/" + hash + " -j ACCEPT",
+		"-t filter -D AZURECNIINPUT -s 10.0.0.4 -j ACCEPT",
+	}, cmds)
+
+	// tables which can't be listed are skipped by the cleanup but fail the deletion
+	r = newFakeRegistry("v2", nil, map[string]bool{}, &cmds)
+	require.Error(t, r.DeleteRule(rule))
+	r.Tag(rule)
+	require.NoError(t, r.CleanupOrphans(true))
+}
+
+func TestCleanupOrphanChains(t *testing.T) {
+	listing := strings.Join([]string{
+		"-N AZURECNIINPUT",
+		"-N AZURECNIOLD",
+		"-N OTHER",
+		"-A INPUT -j AZURECNIINPUT",
+		"-A AZURECNIINPUT -s 10.0.0.4/32 -j ACCEPT",
+		"-A AZURECNIINPUT -j AZURECNIOLD",
+		"-A AZURECNIOLD -s 10.0.0.5/32 -j ACCEPT",
+	}, "\n")
+
+	var cmds []string
+	r := newFakeRegistry("v2", map[string]string{Filter: listing}, map[string]bool{}, &cmds)
+	r.RegisterChain(V4, Filter, CNIInputChain)
+	require.NoError(t, r.CleanupOrphanChains())
+	// untagged rules of registered chains stay, only the jump to the orphaned chain goes with it
+	require.Equal(t, []string{
+		"-t filter -D AZURECNIINPUT -j AZURECNIOLD",
+		"-t filter -F AZURECNIOLD",
+		"-t filter -X AZURECNIOLD",
+	}, cmds)
+}
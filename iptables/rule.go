@@ -0,0 +1,239 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidRule is returned when a rule is missing its table, chain or target.
+var ErrInvalidRule = errors.New("iptables rule requires a table, chain and target")
+
+// ruleMu serializes the check-then-modify sequences of this process. Concurrent processes are
+// serialized by the xtables lock taken for each command unless DisableIPTableLock is set.
+var ruleMu sync.Mutex
+
+// Match is a single match of a rule, e.g. "-s 10.0.0.0/8" or "-m set --match-set name dst".
+type Match struct {
+	// Module is the match extension loaded with -m, empty for builtin matches such as -s or -i.
+	Module string
+	// Args are the options of the match in order.
+	Args []string
+}
+
+// Render returns the match in iptables syntax.
+func (m Match) Render() string {
+	return strings.Join(m.args(), " ")
+}
+
+func (m Match) args() []string {
+	var parts []string
+	if m.Module != "" {
+		parts = append(parts, "-m", m.Module)
+	}
+
+	return append(parts, m.Args...)
+}
+
+// MatchSource matches packets with a source address in cidr.
+func MatchSource(cidr string) Match {
+	return Match{Args: []string{"-s", cidr}}
+}
+
+// MatchDestination matches packets with a destination address in cidr.
+func MatchDestination(cidr string) Match {
+	return Match{Args: []string{"-d", cidr}}
+}
+
+// MatchInInterface matches packets received on the interface.
+func MatchInInterface(ifName string) Match {
+	return Match{Args: []string{"-i", ifName}}
+}
+
+// MatchOutInterface matches packets sent on the interface.
+func MatchOutInterface(ifName string) Match {
+	return Match{Args: []string{"-o", ifName}}
+}
+
+// MatchProtocol matches packets of the protocol, e.g. TCP.
+func MatchProtocol(protocol string) Match {
+	return Match{Args: []string{"-p", protocol}}
+}
+
+// MatchDestinationPort matches packets of the protocol sent to the port.
+func MatchDestinationPort(protocol string, port int) Match {
+	return Match{Args: []string{"-p", protocol, "--dport", strconv.Itoa(port)}}
+}
+
+// MatchSet matches packets whose addresses are members of the ipset, flags is e.g. "dst" or "src,dst".
+func MatchSet(setName, flags string) Match {
+	return Match{Module: "set", Args: []string{"--match-set", setName, flags}}
+}
+
+// MatchState matches packets of connections in one of the states, e.g. Established.
+func MatchState(states ...string) Match {
+	return Match{Module: "state", Args: []string{"--state", strings.Join(states, ",")}}
+}
+
+// MatchRaw wraps a match condition in iptables syntax. It exists for conditions which don't have a
+// constructor yet, rules built from raw matches can't be introspected.
+func MatchRaw(condition string) Match {
+	return Match{Args: strings.Fields(condition)}
+}
+
+// Target is the jump target of a rule with its options, e.g. "SNAT --to 10.0.0.4".
+type Target struct {
+	Name string
+	Args []string
+}
+
+// Jump returns a target jumping to a chain or builtin target with the given options.
+func Jump(name string, args ...string) Target {
+	return Target{Name: name, Args: args}
+}
+
+// parseTarget converts a target in iptables syntax.
+func parseTarget(target string) Target {
+	fields := strings.Fields(target)
+	if len(fields) == 0 {
+		return Target{}
+	}
+
+	return Target{Name: fields[0], Args: fields[1:]}
+}
+
+// Render returns the target in iptables syntax.
+func (t Target) Render() string {
+	return strings.Join(append([]string{t.Name}, t.Args...), " ")
+}
+
+// Rule is an iptables rule. Two rules are the same rule iff their rendered forms are equal.
+type Rule struct {
+	Version string
+	Table   string
+	Chain   string
+	Matches []Match
+	Target  Target
+}
+
+// Render returns the rule specification, i.e. the matches and target without table and chain.
+func (r *Rule) Render() string {
+	return strings.Join(r.Args(), " ")
+}
+
+// Args returns the rule specification as command arguments, for callers which run iptables
+// themselves. Unlike Render it keeps arguments containing spaces, such as comments, intact.
+func (r *Rule) Args() []string {
+	var args []string
+	for _, m := range r.Matches {
+		args = append(args, m.args()...)
+	}
+
+	return append(append(args, "-j", r.Target.Name), r.Target.Args...)
+}
+
+// String returns the rule the way iptables-save lists it.
+func (r *Rule) String() string {
+	return fmt.Sprintf("-t %s -A %s %s", r.Table, r.Chain, r.Render())
+}
+
+func (r *Rule) validate() error {
+	if r.Table == "" || r.Chain == "" || r.Target.Name == "" {
+		return ErrInvalidRule
+	}
+
+	return nil
+}
+
+func (r *Rule) version() string {
+	if r.Version == "" {
+		return V4
+	}
+
+	return r.Version
+}
+
+// line returns the rule applying the action within its table, as accepted by iptables-restore.
+func (r *Rule) line(action string) string {
+	if action == Insert {
+		return fmt.Sprintf("-I %s 1 %s", r.Chain, r.Render())
+	}
+
+	return fmt.Sprintf("-%s %s %s", action, r.Chain, r.Render())
+}
+
+// params returns the iptables arguments applying the action to the rule.
+func (r *Rule) params(action string) string {
+	return fmt.Sprintf("-t %s %s", r.Table, r.line(action))
+}
+
+// Entry returns the command applying the action to the rule for deferred execution with RunCmd.
+func (r *Rule) Entry(action string) IPTableEntry {
+	return IPTableEntry{
+		Version: r.version(),
+		Params:  r.params(action),
+	}
+}
+
+// Exists returns true if the rule is programmed.
+func (r *Rule) Exists() bool {
+	return RunCmd(r.version(), r.params("C")) == nil
+}
+
+// EnsureChain creates the chain in the table if it does not exist.
+func EnsureChain(version, tableName, chainName string) error {
+	ruleMu.Lock()
+	defer ruleMu.Unlock()
+
+	if ChainExists(version, tableName, chainName) {
+		return nil
+	}
+
+	cmd := GetCreateChainCmd(version, tableName, chainName)
+	return errors.Wrapf(RunCmd(version, cmd.Params), "failed to create chain %s in table %s", chainName, tableName)
+}
+
+// EnsureRule programs the rule if it is not programmed yet. The action is Insert to add the rule at
+// the beginning of the chain or Append to add it at the end.
+func EnsureRule(rule *Rule, action string) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	if action != Insert && action != Append {
+		return errors.Errorf("invalid action %s for rule %s", action, rule)
+	}
+
+	ruleMu.Lock()
+	defer ruleMu.Unlock()
+
+	if rule.Exists() {
+		log.Printf("Rule already exists: %s", rule)
+		return nil
+	}
+
+	return errors.Wrapf(RunCmd(rule.version(), rule.params(action)), "failed to add rule %s", rule)
+}
+
+// DeleteRule deletes every copy of the rule. Rules which only partially match are left untouched,
+// deleting a rule which is not programmed is not an error.
+func DeleteRule(rule *Rule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	ruleMu.Lock()
+	defer ruleMu.Unlock()
+
+	for rule.Exists() {
+		if err := RunCmd(rule.version(), rule.params(Delete)); err != nil {
+			return errors.Wrapf(err, "failed to delete rule %s", rule)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package iptables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   Rule
+		spec   string
+		insert string
+		delete string
+	}{
+		{
+			name:   "jump without matches",
+			rule:   Rule{Table: Filter, Chain: Input, Target: Jump(CNIInputChain)},
+			spec:   "-j AZURECNIINPUT",
+			insert: "-t filter -I INPUT 1 -j AZURECNIINPUT",
+			delete: "-t filter -D INPUT -j AZURECNIINPUT",
+		},
+		{
+			name: "matches in order",
+			rule: Rule{
+				Table:   Filter,
+				Chain:   Forward,
+				Matches: []Match{MatchInInterface("azure0"), MatchSet("azure0-allowed-hosts", "dst")},
+				Target:  Jump(Accept),
+			},
+			spec:   "-i azure0 -m set --match-set azure0-allowed-hosts dst -j ACCEPT",
+			insert: "-t filter -I FORWARD 1 -i azure0 -m set --match-set azure0-allowed-hosts dst -j ACCEPT",
+			delete: "-t filter -D FORWARD -i azure0 -m set --match-set azure0-allowed-hosts dst -j ACCEPT",
+		},
+		{
+			name: "target options",
+			rule: Rule{
+				Version: V6,
+				Table:   Nat,
+				Chain:   Postrouting,
+				Matches: []Match{MatchSource("fd00::/64")},
+				Target:  Jump(Snat, "--to", "fd00::4"),
+			},
+			spec:   "-s fd00::/64 -j SNAT --to fd00::4",
+			insert: "-t nat -I POSTROUTING 1 -s fd00::/64 -j SNAT --to fd00::4",
+			delete: "-t nat -D POSTROUTING -s fd00::/64 -j SNAT --to fd00::4",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.spec, tt.rule.Render())
+			require.Equal(t, tt.insert, tt.rule.params(Insert))
+			require.Equal(t, tt.delete, tt.rule.params(Delete))
+		})
+	}
+}
+
+func TestRuleArgs(t *testing.T) {
+	rule := Rule{
+		Table:   Filter,
+		Chain:   Forward,
+		Matches: []Match{MatchRaw(""), {Module: "comment", Args: []string{"--comment", "allow all"}}},
+		Target:  Jump("MARK", "--set-mark", "0x0"),
+	}
+
+	require.Equal(t, []string{"-m", "comment", "--comment", "allow all", "-j", "MARK", "--set-mark", "0x0"}, rule.Args())
+}
+
+func TestRawRuleMatchesStructuredRule(t *testing.T) {
+	raw := newRawRule(V4, Filter, CNIInputChain, " -i azSnatbr -m state --state ESTABLISHED,RELATED", Accept)
+	structured := &Rule{
+		Version: V4,
+		Table:   Filter,
+		Chain:   CNIInputChain,
+		Matches: []Match{MatchInInterface("azSnatbr"), MatchState(Established, Related)},
+		Target:  Jump(Accept),
+	}
+
+	require.Equal(t, structured.String(), raw.String())
+	require.Equal(t, IPTableEntry{Version: V4, Params: "-t filter -A AZURECNIINPUT -i azSnatbr -m state --state ESTABLISHED,RELATED -j ACCEPT"},
+		structured.Entry(Append))
+}
+
+func TestInvalidRule(t *testing.T) {
+	require.ErrorIs(t, EnsureRule(&Rule{Table: Filter, Chain: Input}, Insert), ErrInvalidRule)
+	require.ErrorIs(t, DeleteRule(&Rule{Chain: Input, Target: Jump(Accept)}), ErrInvalidRule)
+	require.Error(t, EnsureRule(&Rule{Table: Filter, Chain: Input, Target: Jump(Accept)}, Delete))
+}
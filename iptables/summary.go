@@ -0,0 +1,77 @@
+package iptables
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChainSummary is the number of rules in a chain.
+type ChainSummary struct {
+	Name  string
+	Rules int
+}
+
+// TableSummary summarizes the chains of an iptables table, e.g. for support bundles which need to know which
+// components programmed rules without dumping every rule.
+type TableSummary struct {
+	Version string
+	Table   string
+	Chains  []ChainSummary
+	// Owners are the number of rules tagged by each registry owner.
+	Owners map[string]int `json:",omitempty"`
+}
+
+// Summarize lists the table and counts its rules per chain and per owner.
+func Summarize(version, tableName string) (*TableSummary, error) {
+	out, err := listRules(version, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s table: %w", tableName, err)
+	}
+
+	return summarizeListing(version, tableName, out), nil
+}
+
+// summarizeListing summarizes a table listed by iptables -S.
+func summarizeListing(version, tableName, out string) *TableSummary {
+	counts := make(map[string]int)
+	// the built-in chains are listed with -P rather than -N
+	for _, chain := range builtinChains(out) {
+		counts[chain] = 0
+	}
+
+	chains, rules := parseListing(out)
+	for _, chain := range chains {
+		counts[chain] = 0
+	}
+
+	summary := &TableSummary{Version: version, Table: tableName}
+	for _, rule := range rules {
+		counts[rule.chain]++
+		if owner, _, _, ok := parseTag(rule.comment); ok {
+			if summary.Owners == nil {
+				summary.Owners = make(map[string]int)
+			}
+			summary.Owners[owner]++
+		}
+	}
+
+	for name, count := range counts {
+		summary.Chains = append(summary.Chains, ChainSummary{Name: name, Rules: count})
+	}
+	sort.Slice(summary.Chains, func(i, j int) bool { return summary.Chains[i].Name < summary.Chains[j].Name })
+
+	return summary
+}
+
+// builtinChains returns the chains with a policy in a listing of iptables -S.
+func builtinChains(out string) []string {
+	var chains []string
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == "-P" {
+			chains = append(chains, fields[1])
+		}
+	}
+
+	return chains
+}
@@ -0,0 +1,30 @@
+package iptables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeListing(t *testing.T) {
+	out := `-P PREROUTING ACCEPT
+-P POSTROUTING ACCEPT
+-N AZURECNIPOSTROUTING
+-N KUBE-SERVICES
+-A POSTROUTING -j AZURECNIPOSTROUTING
+-A AZURECNIPOSTROUTING -s 10.240.0.0/16 -m comment --comment "azure-cni/v1.5.0/0a1b2c3d" -j MASQUERADE
+-A AZURECNIPOSTROUTING -d 169.254.20.10/32 -m comment --comment "azure-cni/v1.5.0/4e5f6a7b" -j RETURN
+-A POSTROUTING -m comment --comment "kubernetes postrouting rules" -j KUBE-POSTROUTING
+`
+
+	summary := summarizeListing(V4, Nat, out)
+	require.Equal(t, V4, summary.Version)
+	require.Equal(t, Nat, summary.Table)
+	require.Equal(t, []ChainSummary{
+		{Name: "AZURECNIPOSTROUTING", Rules: 2},
+		{Name: "KUBE-SERVICES", Rules: 0},
+		{Name: "POSTROUTING", Rules: 2},
+		{Name: "PREROUTING", Rules: 0},
+	}, summary.Chains)
+	require.Equal(t, map[string]int{"azure-cni": 2}, summary.Owners)
+}
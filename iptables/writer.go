@@ -0,0 +1,239 @@
+package iptables
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/internal/lockedfile"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	iptablesRestore  = "iptables-restore"
+	ip6tablesRestore = "ip6tables-restore"
+	// DefaultRestoreLockPath is the file lock serializing the flushes of writers across processes.
+	DefaultRestoreLockPath = "/var/run/azure-vnet-iptables-restore.lock"
+)
+
+var (
+	// ErrVersionMismatch is returned when a rule for one IP version is added to a writer for the other.
+	ErrVersionMismatch = errors.New("rule version does not match writer version")
+
+	// RestoreLockPath is the file locked while a writer looks up the existing chains and rules and
+	// restores the missing ones, disabled if empty. Without it two processes can both find a chain
+	// missing, and the restore of the second one flushes the rules the first one added to it.
+	// Components running in parallel processes enable it at startup.
+	RestoreLockPath string
+)
+
+// RestoreFunc runs the restore command name with args and the input on stdin and returns its
+// combined output.
+type RestoreFunc func(name string, args []string, input []byte) ([]byte, error)
+
+type writerRule struct {
+	rule   *Rule
+	action string
+}
+
+// Writer accumulates chains and rules and programs them with a single iptables-restore invocation.
+// Chains added with SyncChain are owned by the writer: they are flushed and refilled with the rules
+// added to them. Chains added with EnsureChain and rules in chains not owned by the writer are only
+// written if they don't exist yet, so a flush is idempotent either way.
+type Writer struct {
+	version       string
+	tables        []string
+	syncedChains  map[string][]string
+	ensuredChains map[string][]string
+	rules         map[string][]writerRule
+	restore       RestoreFunc
+	chainExists   func(version, tableName, chainName string) bool
+	ruleExists    func(rule *Rule) bool
+}
+
+// NewWriter returns a writer programming rules for the IP version, V4 or V6.
+func NewWriter(version string) *Writer {
+	return &Writer{
+		version:       version,
+		syncedChains:  make(map[string][]string),
+		ensuredChains: make(map[string][]string),
+		rules:         make(map[string][]writerRule),
+		restore:       runRestore,
+		chainExists:   ChainExists,
+		ruleExists:    (*Rule).Exists,
+	}
+}
+
+// UseExec makes the writer run the restore with restore and look up existing chains and rules with
+// chainExists and ruleExists, for callers which run iptables through their own exec interface.
+func (w *Writer) UseExec(restore RestoreFunc, chainExists func(version, tableName, chainName string) bool, ruleExists func(rule *Rule) bool) {
+	w.restore = restore
+	w.chainExists = chainExists
+	w.ruleExists = ruleExists
+}
+
+func (w *Writer) addTable(tableName string) {
+	for _, t := range w.tables {
+		if t == tableName {
+			return
+		}
+	}
+
+	w.tables = append(w.tables, tableName)
+}
+
+func (w *Writer) isSynced(tableName, chainName string) bool {
+	for _, c := range w.syncedChains[tableName] {
+		if c == chainName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SyncChain creates the chain or flushes it if it exists. The chain ends up with exactly the rules
+// added to it through the writer.
+func (w *Writer) SyncChain(tableName, chainName string) {
+	if w.isSynced(tableName, chainName) {
+		return
+	}
+
+	w.addTable(tableName)
+	w.syncedChains[tableName] = append(w.syncedChains[tableName], chainName)
+}
+
+// EnsureChain creates the chain if it does not exist, its rules are kept.
+func (w *Writer) EnsureChain(tableName, chainName string) {
+	w.addTable(tableName)
+	w.ensuredChains[tableName] = append(w.ensuredChains[tableName], chainName)
+}
+
+// EnsureRule adds the rule with the action Insert or Append. Rules are written in the order they
+// are added.
+func (w *Writer) EnsureRule(rule *Rule, action string) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	if action != Insert && action != Append {
+		return errors.Errorf("invalid action %s for rule %s", action, rule)
+	}
+
+	if rule.version() != w.version {
+		return errors.Wrapf(ErrVersionMismatch, "rule %s", rule)
+	}
+
+	w.addTable(rule.Table)
+	w.rules[rule.Table] = append(w.rules[rule.Table], writerRule{rule: rule, action: action})
+	return nil
+}
+
+// Render returns the iptables-restore input programming everything added to the writer, assuming
+// none of the ensured chains and rules exist yet.
+func (w *Writer) Render() string {
+	return w.render(func(string, string) bool { return false }, func(*Rule) bool { return false })
+}
+
+func (w *Writer) render(chainExists func(tableName, chainName string) bool, ruleExists func(*Rule) bool) string {
+	var buf strings.Builder
+	for _, table := range w.tables {
+		var lines []string
+		for _, chain := range w.syncedChains[table] {
+			lines = append(lines, fmt.Sprintf(":%s - [0:0]", chain))
+		}
+
+		for _, chain := range w.ensuredChains[table] {
+			if !w.isSynced(table, chain) && !chainExists(table, chain) {
+				lines = append(lines, fmt.Sprintf(":%s - [0:0]", chain))
+			}
+		}
+
+		for _, r := range w.rules[table] {
+			if !w.isSynced(table, r.rule.Chain) && ruleExists(r.rule) {
+				continue
+			}
+			lines = append(lines, r.rule.line(r.action))
+		}
+
+		if len(lines) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&buf, "*%s\n%s\nCOMMIT\n", table, strings.Join(lines, "\n"))
+	}
+
+	return buf.String()
+}
+
+// Flush programs the accumulated chains and rules and resets the writer. Nothing is programmed if
+// the restore fails.
+func (w *Writer) Flush() error {
+	if RestoreLockPath != "" {
+		unlock, err := lockedfile.MutexAt(RestoreLockPath).Lock()
+		if err != nil {
+			return errors.Wrap(err, "failed to lock iptables restore")
+		}
+		defer unlock()
+	}
+
+	input := w.render(
+		func(tableName, chainName string) bool { return w.chainExists(w.version, tableName, chainName) },
+		w.ruleExists,
+	)
+	if input == "" {
+		w.reset()
+		return nil
+	}
+
+	cmd := iptablesRestore
+	if w.version == V6 {
+		cmd = ip6tablesRestore
+	}
+	cmd = GetBackend().command(cmd)
+
+	args := []string{"--noflush"}
+	if !DisableIPTableLock {
+		args = append(args, "-w", strconv.Itoa(lockTimeout))
+	}
+
+	err := changes.run(cmd, func() ([]byte, error) {
+		out, err := w.restore(cmd, args, []byte(input))
+		if err != nil {
+			err = errors.Wrapf(err, "%s failed: %s", cmd, strings.TrimSpace(string(out)))
+		}
+		record(strings.Join(append([]string{cmd}, args...), " "), input, err)
+		return out, err
+	})
+	if err == nil {
+		w.reset()
+		return nil
+	}
+
+	log.Errorf("[iptables] Failed to restore rules:\n%s", input)
+	return err
+}
+
+func (w *Writer) reset() {
+	w.tables = nil
+	w.syncedChains = make(map[string][]string)
+	w.ensuredChains = make(map[string][]string)
+	w.rules = make(map[string][]writerRule)
+}
+
+// runRestore runs the restore command with the input on stdin and returns its combined output.
+func runRestore(name string, args []string, input []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*lockTimeout*time.Second)
+	defer cancel()
+
+	log.Printf("[iptables] %s %s", name, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	out, err := cmd.CombinedOutput()
+	return out, errors.Wrapf(err, "running %s", name)
+}
@@ -0,0 +1,156 @@
+package iptables
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/internal/lockedfile"
+	"github.com/stretchr/testify/require"
+)
+
+var errRestore = errors.New("restore failed")
+
+type fakeRestore struct {
+	calls  int
+	inputs []string
+	args   [][]string
+	out    []string
+	errs   []error
+}
+
+func (f *fakeRestore) restore(_ string, args []string, input []byte) ([]byte, error) {
+	f.calls++
+	f.inputs = append(f.inputs, string(input))
+	f.args = append(f.args, args)
+
+	var (
+		out string
+		err error
+	)
+	if len(f.out) > 0 {
+		out, f.out = f.out[0], f.out[1:]
+	}
+	if len(f.errs) > 0 {
+		err, f.errs = f.errs[0], f.errs[1:]
+	}
+
+	return []byte(out), err
+}
+
+func newTestWriter(existingChains []string, existingRules []*Rule) (*Writer, *fakeRestore) {
+	f := &fakeRestore{}
+	w := NewWriter(V4)
+	w.restore = f.restore
+	w.chainExists = func(_, _, chainName string) bool {
+		for _, c := range existingChains {
+			if c == chainName {
+				return true
+			}
+		}
+		return false
+	}
+	w.ruleExists = func(rule *Rule) bool {
+		for _, r := range existingRules {
+			if r.String() == rule.String() {
+				return true
+			}
+		}
+		return false
+	}
+
+	return w, f
+}
+
+func TestWriterRender(t *testing.T) {
+	w, _ := newTestWriter(nil, nil)
+	w.SyncChain(Nat, Swift)
+	w.EnsureChain(Filter, CNIInputChain)
+	require.NoError(t, w.EnsureRule(&Rule{Table: Nat, Chain: Postrouting, Target: Jump(Swift)}, Append))
+	require.NoError(t, w.EnsureRule(&Rule{
+		Table:   Nat,
+		Chain:   Swift,
+		Matches: []Match{MatchSource("10.0.1.0/24"), MatchDestinationPort(UDP, DNSPort)},
+		Target:  Jump(Snat, "--to", "10.0.1.20"),
+	}, Insert))
+	require.NoError(t, w.EnsureRule(&Rule{Table: Filter, Chain: Input, Target: Jump(CNIInputChain)}, Insert))
+
+	require.Equal(t, `*nat
+:SWIFT - [0:0]
+-A POSTROUTING -j SWIFT
+-I SWIFT 1 -s 10.0.1.0/24 -p udp --dport 53 -j SNAT --to 10.0.1.20
+COMMIT
+*filter
+:AZURECNIINPUT - [0:0]
+-I INPUT 1 -j AZURECNIINPUT
+COMMIT
+`, w.Render())
+}
+
+func TestWriterFlushSkipsExisting(t *testing.T) {
+	jump := &Rule{Table: Filter, Chain: Input, Target: Jump(CNIInputChain)}
+	accept := &Rule{Table: Filter, Chain: CNIInputChain, Matches: []Match{MatchSource("169.254.0.4")}, Target: Jump(Accept)}
+	w, f := newTestWriter([]string{CNIInputChain}, []*Rule{jump})
+
+	w.EnsureChain(Filter, CNIInputChain)
+	require.NoError(t, w.EnsureRule(jump, Insert))
+	require.NoError(t, w.EnsureRule(accept, Insert))
+	require.NoError(t, w.Flush())
+
+	require.Equal(t, 1, f.calls)
+	require.Equal(t, "*filter\n-I AZURECNIINPUT 1 -s 169.254.0.4 -j ACCEPT\nCOMMIT\n", f.inputs[0])
+	require.Equal(t, []string{"--noflush", "-w", "60"}, f.args[0])
+
+	// the writer is reset after a successful flush
+	require.NoError(t, w.Flush())
+	require.Equal(t, 1, f.calls)
+}
+
+func TestWriterFlushRetriesLockContention(t *testing.T) {
+	w, f := newTestWriter(nil, nil)
+	f.out = []string{"Another app is currently holding the xtables lock.", ""}
+	f.errs = []error{errRestore, nil}
+
+	w.SyncChain(Nat, Swift)
+	require.NoError(t, w.Flush())
+	require.Equal(t, 2, f.calls)
+
+	w, f = newTestWriter(nil, nil)
+	f.errs = []error{errRestore}
+	w.SyncChain(Nat, Swift)
+	require.ErrorIs(t, w.Flush(), errRestore)
+	require.Equal(t, 1, f.calls, "only lock contention is retried")
+}
+
+func TestWriterFlushWaitsForRestoreLock(t *testing.T) {
+	RestoreLockPath = filepath.Join(t.TempDir(), "restore.lock")
+	t.Cleanup(func() { RestoreLockPath = "" })
+
+	// another process is flushing
+	unlock, err := lockedfile.MutexAt(RestoreLockPath).Lock()
+	require.NoError(t, err)
+
+	w, f := newTestWriter(nil, nil)
+	w.EnsureChain(Nat, Swift)
+	done := make(chan error, 1)
+	go func() { done <- w.Flush() }()
+
+	select {
+	case <-done:
+		t.Fatal("flush did not wait for the restore lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	require.NoError(t, <-done)
+	require.Equal(t, 1, f.calls)
+}
+
+func TestWriterRejectsInvalidRules(t *testing.T) {
+	w, _ := newTestWriter(nil, nil)
+	require.ErrorIs(t, w.EnsureRule(&Rule{Table: Filter, Chain: Input}, Append), ErrInvalidRule)
+	require.ErrorIs(t, w.EnsureRule(&Rule{Version: V6, Table: Filter, Chain: Input, Target: Jump(Accept)}, Append), ErrVersionMismatch)
+	require.Error(t, w.EnsureRule(&Rule{Table: Filter, Chain: Input, Target: Jump(Accept)}, Delete))
+	require.Empty(t, w.Render())
+}
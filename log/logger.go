@@ -4,12 +4,16 @@
 package log
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path"
 	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // Log level
@@ -34,6 +38,7 @@ const (
 	// Log file properties.
 	logPrefix        = ""
 	logFileExtension = ".log"
+	gzipExtension    = ".gz"
 	logFilePerm      = os.FileMode(0o664)
 
 	// Log file rotation default limits, in bytes.
@@ -52,8 +57,12 @@ type Logger struct {
 	maxFileSize  int
 	maxFileCount int
 	callCount    int
+	compress     bool
 	directory    string
 	mutex        *sync.Mutex
+	format       int
+	zap          *zap.Logger
+	zapLevel     zap.AtomicLevel
 }
 
 var pid = os.Getpid()
@@ -72,7 +81,9 @@ func NewLoggerE(name string, level, target int, logDir string) (*Logger, error)
 		maxFileSize:  maxLogFileSize,
 		maxFileCount: maxLogFileCount,
 		mutex:        &sync.Mutex{},
+		zapLevel:     zap.NewAtomicLevelAt(toZapLevel(level)),
 	}
+	logger.zap = logger.newZap()
 
 	err := logger.SetTarget(target)
 	if err != nil {
@@ -106,12 +117,23 @@ func (logger *Logger) SetName(name string) {
 // SetLevel sets the log chattiness.
 func (logger *Logger) SetLevel(level int) {
 	logger.level = level
+	logger.zapLevel.SetLevel(toZapLevel(level))
 }
 
-// SetLogFileLimits sets the log file limits.
+// SetLogFileLimits sets the log file limits, non positive limits keep the current ones.
 func (logger *Logger) SetLogFileLimits(maxFileSize int, maxFileCount int) {
-	logger.maxFileSize = maxFileSize
-	logger.maxFileCount = maxFileCount
+	if maxFileSize > 0 {
+		logger.maxFileSize = maxFileSize
+	}
+
+	if maxFileCount > 0 {
+		logger.maxFileCount = maxFileCount
+	}
+}
+
+// SetLogFileCompression sets whether the rotated log files are compressed with gzip.
+func (logger *Logger) SetLogFileCompression(compress bool) {
+	logger.compress = compress
 }
 
 // Close closes the log stream.
@@ -149,8 +171,8 @@ func (logger *Logger) getLogFileName() string {
 	return logFileName
 }
 
-// todo: handle errors and use atomic file rotation
 // Rotate checks the active log file size and rotates log files if necessary.
+// The caller must hold the logger mutex.
 func (logger *Logger) rotate() {
 	// Return if target is not a log file.
 	if (logger.target != TargetLogfile && logger.target != TargetStdOutAndLogFile) || logger.out == nil {
@@ -160,31 +182,105 @@ func (logger *Logger) rotate() {
 	fileName := logger.getLogFileName()
 	fileInfo, err := os.Stat(fileName)
 	if err != nil {
-		logger.Logf("[log] Failed to query log file info %+v.", err)
+		logger.logf("[log] Failed to query log file info %+v.", err)
 		return
 	}
 
 	// Rotate if size limit is reached.
 	if fileInfo.Size() >= int64(logger.maxFileSize) {
 		logger.out.Close()
-		var fn1, fn2 string
-
-		// Rotate log files, keeping the last maxFileCount files.
-		for n := logger.maxFileCount - 1; n >= 0; n-- {
-			fn2 = fn1
-			if n == 0 {
-				fn1 = fileName
-			} else {
-				fn1 = fmt.Sprintf("%v.%v", fileName, n)
-			}
-			if fn2 != "" {
-				os.Rename(fn1, fn2)
-			}
-		}
+		rotateErr := logger.rotateFiles(fileName)
 
 		// Create a new log file.
-		logger.SetTarget(logger.target)
+		if err := logger.SetTarget(logger.target); err != nil {
+			return
+		}
+
+		if rotateErr != nil {
+			logger.logf("[log] Failed to rotate log files %+v.", rotateErr)
+		}
+	}
+}
+
+// rotateFiles moves the active log file to fileName.1, shifting the older files and
+// keeping the last maxFileCount files, the active one included.
+func (logger *Logger) rotateFiles(fileName string) error {
+	rotatedFileName := func(n int, extension string) string {
+		return fmt.Sprintf("%v.%v%v", fileName, n, extension)
+	}
+
+	if logger.maxFileCount <= 1 {
+		return os.Remove(fileName)
+	}
+
+	// Drop the oldest file, whether it was compressed or not, so it can't be left behind.
+	for _, extension := range []string{"", gzipExtension} {
+		os.Remove(rotatedFileName(logger.maxFileCount-1, extension))
 	}
+
+	for n := logger.maxFileCount - 2; n >= 1; n-- {
+		for _, extension := range []string{"", gzipExtension} {
+			os.Rename(rotatedFileName(n, extension), rotatedFileName(n+1, extension))
+		}
+	}
+
+	if err := os.Rename(fileName, rotatedFileName(1, "")); err != nil {
+		return err
+	}
+
+	if logger.compress {
+		return compressFile(rotatedFileName(1, ""))
+	}
+
+	return nil
+}
+
+// compressFile replaces the file by its gzip compressed copy.
+func compressFile(fileName string) error {
+	src, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	gzFileName := fileName + gzipExtension
+	dst, err := os.OpenFile(gzFileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, logFilePerm)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(gzFileName)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(fileName)
+}
+
+// Reopen closes and reopens the log file, e.g. after it was moved by an external log rotation.
+func (logger *Logger) Reopen() error {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	if logger.target != TargetLogfile && logger.target != TargetStdOutAndLogFile {
+		return nil
+	}
+
+	if logger.out != nil {
+		logger.out.Close()
+	}
+
+	return logger.SetTarget(logger.target)
 }
 
 // Request logs a structured request.
@@ -218,30 +314,47 @@ func (logger *Logger) ResponseEx(tag string, request interface{}, response inter
 	}
 }
 
-// logf logs a formatted string.
-func (logger *Logger) logf(format string, args ...interface{}) {
-	if logger.callCount%rotationCheckFrq == 0 {
+// checkRotation rotates the log files every rotationCheckFrq writes.
+// The count is incremented first as rotate logs its own failures.
+func (logger *Logger) checkRotation() {
+	check := logger.callCount%rotationCheckFrq == 0
+	logger.callCount++
+	if check {
 		logger.rotate()
 	}
+}
+
+// logf logs a formatted string.
+func (logger *Logger) logf(format string, args ...interface{}) {
+	logger.checkRotation()
 	format = fmt.Sprintf("[%v] %s", pid, format)
-	logger.callCount++
 	logger.l.Printf(format, args...)
 }
 
-// Logf wraps logf.
-func (logger *Logger) Logf(format string, args ...interface{}) {
+// logAt logs a formatted string, as a structured message of the given level in JSON format.
+func (logger *Logger) logAt(level zapcore.Level, format string, args ...interface{}) {
+	if logger.format == FormatJSON {
+		logger.zapf(level, format, args...)
+		return
+	}
+
 	logger.mutex.Lock()
 	logger.logf(format, args...)
 	logger.mutex.Unlock()
 }
 
+// Logf wraps logf.
+func (logger *Logger) Logf(format string, args ...interface{}) {
+	logger.logAt(zapcore.InfoLevel, format, args...)
+}
+
 // Printf logs a formatted string at info level.
 func (logger *Logger) Printf(format string, args ...interface{}) {
 	if logger.level < LevelInfo {
 		return
 	}
 
-	logger.Logf(format, args...)
+	logger.logAt(zapcore.InfoLevel, format, args...)
 }
 
 // Debugf logs a formatted string at info level.
@@ -250,12 +363,12 @@ func (logger *Logger) Debugf(format string, args ...interface{}) {
 		return
 	}
 
-	logger.Logf(format, args...)
+	logger.logAt(zapcore.DebugLevel, format, args...)
 }
 
 // Errorf logs a formatted string at info level and sends the string to TelemetryBuffer.
 func (logger *Logger) Errorf(format string, args ...interface{}) {
-	logger.Logf(format, args...)
+	logger.logAt(zapcore.ErrorLevel, format, args...)
 }
 
 // Warnf logs a formatted string at warninglevel
@@ -264,5 +377,5 @@ func (logger *Logger) Warnf(format string, args ...interface{}) {
 		return
 	}
 
-	logger.Logf(format, args...)
+	logger.logAt(zapcore.WarnLevel, format, args...)
 }
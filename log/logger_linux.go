@@ -4,11 +4,14 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"log/syslog"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 const (
@@ -52,3 +55,26 @@ func (logger *Logger) SetTarget(target int) error {
 
 	return err
 }
+
+// ReopenOnSignal reopens the log file on SIGHUP until the context is done, so the file can be rotated externally.
+func (logger *Logger) ReopenOnSignal(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sigCh)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigCh:
+				if err := logger.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to reopen log file: %v\n", err)
+					continue
+				}
+				logger.Printf("[log] Reopened log file on SIGHUP.")
+			}
+		}
+	}()
+}
@@ -4,7 +4,9 @@
 package log
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strings"
@@ -103,3 +105,84 @@ func TestPid(t *testing.T) {
 		t.Fatalf("Unexpected log: %s.", log)
 	}
 }
+
+// Tests that the rotated log files are compressed when compression is enabled.
+func TestLogFileRotationCompressesRotatedFiles(t *testing.T) {
+	logDirectory := t.TempDir() + "/"
+	l := NewLogger(logName, LevelInfo, TargetLogfile, logDirectory)
+	if l == nil {
+		t.Fatalf("Failed to create logger.")
+	}
+
+	l.SetLogFileLimits(512, 3)
+	l.SetLogFileCompression(true)
+
+	for i := 1; i <= 100; i++ {
+		l.Logf("LogText %v", i)
+	}
+
+	l.Close()
+
+	fn := logDirectory + logName + ".log"
+	if _, err := os.Stat(fn); err != nil {
+		t.Errorf("Failed to find active log file.")
+	}
+
+	for _, n := range []int{1, 2} {
+		fn := fmt.Sprintf("%s.%d", logDirectory+logName+".log", n)
+		if _, err := os.Stat(fn); err == nil {
+			t.Errorf("Found uncompressed rotated log file %s.", fn)
+		}
+
+		f, err := os.Open(fn + ".gz")
+		if err != nil {
+			t.Fatalf("Failed to find compressed rotated log file %s.gz.", fn)
+		}
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("Failed to read compressed log file %s.gz: %v", fn, err)
+		}
+
+		b, err := io.ReadAll(gz)
+		f.Close()
+		if err != nil || !strings.Contains(string(b), "LogText") {
+			t.Errorf("Unexpected content in compressed log file %s.gz: %v", fn, err)
+		}
+	}
+
+	if _, err := os.Stat(logDirectory + logName + ".log.3.gz"); err == nil {
+		t.Errorf("Found the 3rd rotated log file which should have been deleted.")
+	}
+}
+
+// Tests that Reopen writes to a new file after the log file was moved away.
+func TestReopenAfterExternalRotation(t *testing.T) {
+	logDirectory := t.TempDir() + "/"
+	l := NewLogger(logName, LevelInfo, TargetLogfile, logDirectory)
+	if l == nil {
+		t.Fatalf("Failed to create logger.")
+	}
+	defer l.Close()
+
+	fn := logDirectory + logName + ".log"
+	l.Printf("before")
+
+	if err := os.Rename(fn, fn+".old"); err != nil {
+		t.Fatalf("Failed to move log file: %v", err)
+	}
+
+	if err := l.Reopen(); err != nil {
+		t.Fatalf("Failed to reopen log file: %v", err)
+	}
+	l.Printf("after")
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to read reopened log file: %v", err)
+	}
+
+	if strings.Contains(string(b), "before") || !strings.Contains(string(b), "after") {
+		t.Errorf("Unexpected content in reopened log file: %s", b)
+	}
+}
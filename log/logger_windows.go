@@ -4,6 +4,7 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -47,3 +48,6 @@ func (logger *Logger) SetTarget(target int) error {
 
 	return err
 }
+
+// ReopenOnSignal does nothing as there is no SIGHUP on Windows, the log files are rotated by the logger.
+func (logger *Logger) ReopenOnSignal(context.Context) {}
@@ -3,6 +3,12 @@
 
 package log
 
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
 // Standard logger is a pre-defined logger for convenience.
 // Set log directory as the current location
 var stdLog = NewLogger("azure-container-networking", LevelInfo, TargetStderr, "")
@@ -24,6 +30,19 @@ func SetLogFileLimits(maxFileSize int, maxFileCount int) {
 	stdLog.SetLogFileLimits(maxFileSize, maxFileCount)
 }
 
+func SetLogFileCompression(compress bool) {
+	stdLog.SetLogFileCompression(compress)
+}
+
+func Reopen() error {
+	return stdLog.Reopen()
+}
+
+// ReopenOnSignal reopens the standard log file on SIGHUP until the context is done.
+func ReopenOnSignal(ctx context.Context) {
+	stdLog.ReopenOnSignal(ctx)
+}
+
 func Close() {
 	stdLog.Close()
 }
@@ -61,3 +80,17 @@ func Debugf(format string, args ...interface{}) {
 func Errorf(format string, args ...interface{}) {
 	stdLog.Errorf(format, args...)
 }
+
+func SetFormat(format int) error {
+	return stdLog.SetFormat(format)
+}
+
+// Zap returns the structured standard logger.
+func Zap() *zap.Logger {
+	return stdLog.Zap()
+}
+
+// With returns the structured standard logger adding the fields to every message.
+func With(fields ...zap.Field) *zap.Logger {
+	return stdLog.With(fields...)
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Microsoft. All rights reserved.
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log format
+const (
+	FormatText = iota
+	FormatJSON
+)
+
+// Structured log field names.
+const (
+	ComponentKey   = "component"
+	OperationKey   = "operation"
+	ContainerIDKey = "containerID"
+	NetworkKey     = "network"
+	pidKey         = "pid"
+)
+
+// textTimeLayout matches the timestamps of the standard log package used by the text format.
+const textTimeLayout = "2006/01/02 15:04:05"
+
+// Component is the subsystem logging, e.g. "net" or "ipam".
+func Component(name string) zap.Field {
+	return zap.String(ComponentKey, name)
+}
+
+// Operation is the operation being logged, e.g. the CNI command or the CNS API.
+func Operation(op string) zap.Field {
+	return zap.String(OperationKey, op)
+}
+
+// ContainerID is the container the operation is done for.
+func ContainerID(id string) zap.Field {
+	return zap.String(ContainerIDKey, id)
+}
+
+// Network is the network the operation is done on.
+func Network(id string) zap.Field {
+	return zap.String(NetworkKey, id)
+}
+
+// toZapLevel converts a log level to the zap level enabling the same messages.
+func toZapLevel(level int) zapcore.Level {
+	switch level {
+	case LevelAlert, LevelError:
+		return zapcore.ErrorLevel
+	case LevelWarning:
+		return zapcore.WarnLevel
+	case LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+// zapWriter writes the structured logs to the output of the logger, sharing its rotation.
+type zapWriter Logger
+
+func (w *zapWriter) Write(p []byte) (int, error) {
+	logger := (*Logger)(w)
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	logger.checkRotation()
+	return logger.l.Writer().Write(p) //nolint:wrapcheck // io.Writer
+}
+
+func (w *zapWriter) Sync() error {
+	return nil
+}
+
+// newZap builds the structured logger writing in the format of the logger.
+func (logger *Logger) newZap() *zap.Logger {
+	var encoder zapcore.Encoder
+	if logger.format == FormatJSON {
+		config := zap.NewProductionEncoderConfig()
+		config.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoder = zapcore.NewJSONEncoder(config)
+	} else {
+		config := zap.NewDevelopmentEncoderConfig()
+		config.EncodeTime = zapcore.TimeEncoderOfLayout(textTimeLayout)
+		config.ConsoleSeparator = " "
+		config.CallerKey = ""
+		encoder = zapcore.NewConsoleEncoder(config)
+	}
+
+	core := zapcore.NewCore(encoder, (*zapWriter)(logger), logger.zapLevel)
+	return zap.New(core).With(zap.Int(pidKey, pid))
+}
+
+// SetFormat sets the log format. The JSON format applies to the messages of the
+// formatted functions as well, so the components can migrate to structured logging incrementally.
+func (logger *Logger) SetFormat(format int) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("Invalid log format %d", format) //nolint:goerr113 // same as SetTarget
+	}
+
+	logger.format = format
+	logger.zap = logger.newZap()
+	return nil
+}
+
+// Zap returns the structured logger, writing to the same output at the same level.
+func (logger *Logger) Zap() *zap.Logger {
+	return logger.zap
+}
+
+// With returns a structured logger adding the fields to every message.
+func (logger *Logger) With(fields ...zap.Field) *zap.Logger {
+	return logger.zap.With(fields...)
+}
+
+// splitComponent splits the component prefix off messages such as "[net] Created network".
+func splitComponent(msg string) (component, rest string) {
+	if !strings.HasPrefix(msg, "[") {
+		return "", msg
+	}
+
+	end := strings.Index(msg, "] ")
+	if end <= 1 || strings.ContainsAny(msg[1:end], " []") {
+		return "", msg
+	}
+
+	return msg[1:end], msg[end+2:]
+}
+
+// zapf logs a formatted message through the structured logger, the component prefix becoming a field.
+func (logger *Logger) zapf(level zapcore.Level, format string, args ...interface{}) {
+	component, msg := splitComponent(fmt.Sprintf(format, args...))
+
+	var fields []zap.Field
+	if component != "" {
+		fields = append(fields, Component(component))
+	}
+
+	if ce := logger.zap.Check(level, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
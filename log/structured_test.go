@@ -0,0 +1,137 @@
+// Copyright 2022 Microsoft. All rights reserved.
+// MIT License
+
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func readLogLines(t *testing.T, l *Logger) []string {
+	t.Helper()
+
+	l.Close()
+	f, err := os.Open(path.Join(l.GetLogDirectory(), logName+logFileExtension))
+	if err != nil {
+		t.Fatalf("Failed to open log, %v", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines
+}
+
+func TestJSONFormat(t *testing.T) {
+	l, err := NewLoggerE(logName, LevelInfo, TargetLogfile, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create logger, %v", err)
+	}
+
+	if err = l.SetFormat(FormatJSON); err != nil {
+		t.Fatalf("Failed to set JSON format, %v", err)
+	}
+
+	l.Printf("[net] Created network %v.", "azure")
+	l.Debugf("[net] Not logged at info level")
+	l.Errorf("Failed to create endpoint")
+	l.With(Component("net"), ContainerID("container")).Info("Created endpoint", Network("azure"))
+
+	lines := readLogLines(t, l)
+	if len(lines) != 3 {
+		t.Fatalf("Unexpected log: %v.", lines)
+	}
+
+	expected := []map[string]interface{}{
+		{"level": "info", "msg": "Created network azure.", ComponentKey: "net"},
+		{"level": "error", "msg": "Failed to create endpoint"},
+		{"level": "info", "msg": "Created endpoint", ComponentKey: "net", ContainerIDKey: "container", NetworkKey: "azure"},
+	}
+
+	for i, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line %q isn't JSON, %v", line, err)
+		}
+
+		if entry[pidKey] != float64(pid) {
+			t.Errorf("Unexpected pid in %q.", line)
+		}
+
+		for k, v := range expected[i] {
+			if entry[k] != v {
+				t.Errorf("Unexpected %s in %q, expected %v.", k, line, v)
+			}
+		}
+
+		if _, ok := entry[ComponentKey]; ok != (expected[i][ComponentKey] != nil) {
+			t.Errorf("Unexpected component in %q.", line)
+		}
+	}
+}
+
+func TestTextFormat(t *testing.T) {
+	l, err := NewLoggerE(logName, LevelInfo, TargetLogfile, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create logger, %v", err)
+	}
+
+	l.Printf("[net] Created network %v.", "azure")
+	l.With(Component("net")).Info("Created endpoint", ContainerID("container"))
+	l.SetLevel(LevelError)
+	l.With(Component("net")).Info("Not logged at error level")
+
+	lines := readLogLines(t, l)
+	if len(lines) != 2 {
+		t.Fatalf("Unexpected log: %v.", lines)
+	}
+
+	// the formatted messages are unchanged.
+	if !strings.HasSuffix(lines[0], fmt.Sprintf("[%v] [net] Created network azure.", pid)) {
+		t.Errorf("Unexpected log line %q.", lines[0])
+	}
+
+	for _, s := range []string{"INFO", "Created endpoint", `"component": "net"`, `"containerID": "container"`} {
+		if !strings.Contains(lines[1], s) {
+			t.Errorf("Log line %q doesn't contain %q.", lines[1], s)
+		}
+	}
+}
+
+func TestSetFormatInvalid(t *testing.T) {
+	l := NewLogger(logName, LevelInfo, TargetStderr, "")
+	if err := l.SetFormat(FormatJSON + 1); err == nil {
+		t.Error("expected an error but did not receive one")
+	}
+}
+
+func TestSplitComponent(t *testing.T) {
+	tests := []struct {
+		msg       string
+		component string
+		rest      string
+	}{
+		{msg: "[net] Created network", component: "net", rest: "Created network"},
+		{msg: "[Azure CNS] Registered", component: "", rest: "[Azure CNS] Registered"},
+		{msg: "[] empty", component: "", rest: "[] empty"},
+		{msg: "[net]no space", component: "", rest: "[net]no space"},
+		{msg: "no component", component: "", rest: "no component"},
+	}
+
+	for _, tt := range tests {
+		component, rest := splitComponent(tt.msg)
+		if component != tt.component || rest != tt.rest {
+			t.Errorf("splitComponent(%q) = %q, %q, expected %q, %q", tt.msg, component, rest, tt.component, tt.rest)
+		}
+	}
+}
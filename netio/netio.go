@@ -15,13 +15,34 @@ type NetIOInterface interface {
 // ErrInterfaceNil - errors out when interface is nil
 var ErrInterfaceNil = errors.New("Interface is nil")
 
+// ErrInterfaceNotFound - errors out when the host has no interface with the name, e.g. a NIC detached from the VM
+var ErrInterfaceNotFound = errors.New("Interface not found")
+
 type NetIO struct{}
 
 func (ns *NetIO) GetNetworkInterfaceByName(name string) (*net.Interface, error) {
 	iface, err := net.InterfaceByName(name)
+	if err != nil && !interfaceExists(name) {
+		return nil, errors.Wrapf(ErrInterfaceNotFound, "GetNetworkInterfaceByName failed for %s", name)
+	}
 	return iface, errors.Wrap(err, "GetNetworkInterfaceByName failed")
 }
 
+// interfaceExists is whether the host has an interface with the name. It is true if the interfaces can't be listed,
+// so that a transient failure isn't mistaken for a missing interface.
+func interfaceExists(name string) bool {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return true
+	}
+	for i := range ifaces {
+		if ifaces[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (ns *NetIO) GetNetworkInterfaceAddrs(iface *net.Interface) ([]net.Addr, error) {
 	if iface == nil {
 		return []net.Addr{}, ErrInterfaceNil
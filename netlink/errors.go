@@ -0,0 +1,100 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package netlink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// errNoSuchInterfaceMsg is the message of the unexported error returned by net.InterfaceByName when
+// the interface does not exist.
+const errNoSuchInterfaceMsg = "no such network interface"
+
+// Sentinel errors for the errnos netlink requests commonly fail with. The errors returned by the
+// package match them with errors.Is, and still match the syscall.Errno the kernel returned.
+var (
+	// ErrExists is returned when the link, address, route or neighbor being added already exists (EEXIST).
+	ErrExists = errors.New("netlink: object already exists")
+	// ErrNoSuchDevice is returned when the link does not exist (ENODEV).
+	ErrNoSuchDevice = errors.New("netlink: no such device")
+	// ErrAddressNotAvailable is returned when the address is not assigned to the link (EADDRNOTAVAIL).
+	ErrAddressNotAvailable = errors.New("netlink: address not available")
+	// ErrNotFound is returned when the route or neighbor being deleted does not exist (ESRCH, ENOENT).
+	ErrNotFound = errors.New("netlink: object not found")
+	// ErrResourceBusy is returned for transient conditions of the kernel, the request may be retried.
+	ErrResourceBusy = errors.New("netlink: resource temporarily busy")
+	// ErrNotPermitted is returned when the process lacks the privileges for the request (EPERM, EACCES).
+	ErrNotPermitted = errors.New("netlink: operation not permitted")
+	// ErrInvalidRequest is returned when the kernel rejects the attributes of the request (EINVAL, ERANGE).
+	ErrInvalidRequest = errors.New("netlink: invalid request")
+	// ErrNotSupported is returned by requests which have no equivalent on the platform.
+	ErrNotSupported = errors.New("netlink: not supported on this platform")
+)
+
+var errnoSentinels = map[syscall.Errno]error{
+	syscall.EEXIST:        ErrExists,
+	syscall.ENODEV:        ErrNoSuchDevice,
+	syscall.ENXIO:         ErrNoSuchDevice,
+	syscall.EADDRNOTAVAIL: ErrAddressNotAvailable,
+	syscall.ESRCH:         ErrNotFound,
+	syscall.ENOENT:        ErrNotFound,
+	syscall.EBUSY:         ErrResourceBusy,
+	syscall.EAGAIN:        ErrResourceBusy,
+	syscall.EINTR:         ErrResourceBusy,
+	syscall.ENOBUFS:       ErrResourceBusy,
+	syscall.EPERM:         ErrNotPermitted,
+	syscall.EACCES:        ErrNotPermitted,
+	syscall.EINVAL:        ErrInvalidRequest,
+	syscall.ERANGE:        ErrInvalidRequest,
+}
+
+// Classify returns the sentinel error of err, nil if there is none. Besides the errors returned by this
+// package it classifies the errnos and the interface lookup errors of the net package, so that errors of
+// other libraries are told apart the same way.
+func Classify(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Err != nil && opErr.Err.Error() == errNoSuchInterfaceMsg {
+		return ErrNoSuchDevice
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errnoSentinels[errno]
+	}
+
+	return nil
+}
+
+// Error is an errno returned for a netlink request.
+type Error struct {
+	Errno syscall.Errno
+	// Link is the name of the interface the request failed for, if known.
+	Link string
+}
+
+func newError(errno syscall.Errno) error {
+	return &Error{Errno: errno}
+}
+
+func (e *Error) Error() string {
+	if e.Link != "" {
+		return fmt.Sprintf("link %s: %v", e.Link, e.Errno)
+	}
+
+	return e.Errno.Error()
+}
+
+// Unwrap returns the errno so that errors.Is can still match syscall.Errno values.
+func (e *Error) Unwrap() error {
+	return e.Errno
+}
+
+// Is reports whether target is the sentinel error of the errno.
+func (e *Error) Is(target error) bool {
+	sentinel, ok := errnoSentinels[e.Errno]
+	return ok && sentinel == target
+}
@@ -7,7 +7,10 @@
 package netlink
 
 import (
+	"math"
 	"net"
+	"strings"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
@@ -24,6 +27,11 @@ const (
 	RTPROT_KERNEL = 2
 )
 
+// RTNH_F_ONLINK is the route flag which lets the gateway of the route be off the subnets of the link.
+const (
+	RTNH_F_ONLINK = unix.RTNH_F_ONLINK
+)
+
 // setIPAddress sends an IP address set request.
 func (Netlink) setIPAddress(ifName string, ipAddress net.IP, ipNet *net.IPNet, add bool) error {
 	var msgType, flags int
@@ -33,7 +41,7 @@ func (Netlink) setIPAddress(ifName string, ipAddress net.IP, ipNet *net.IPNet, a
 		return err
 	}
 
-	iface, err := net.InterfaceByName(ifName)
+	iface, err := interfaceByName(ifName)
 	if err != nil {
 		return err
 	}
@@ -43,7 +51,8 @@ func (Netlink) setIPAddress(ifName string, ipAddress net.IP, ipNet *net.IPNet, a
 		flags = unix.NLM_F_CREATE | unix.NLM_F_EXCL | unix.NLM_F_ACK
 	} else {
 		msgType = unix.RTM_DELADDR
-		flags = unix.NLM_F_EXCL | unix.NLM_F_ACK
+		// NLM_F_EXCL shares its bit with NLM_F_BULK for delete requests, which newer kernels reject.
+		flags = unix.NLM_F_ACK
 	}
 
 	req := newRequest(msgType, flags)
@@ -79,6 +88,88 @@ func (n Netlink) DeleteIPAddress(ifName string, ipAddress net.IP, ipNet *net.IPN
 	return n.setIPAddress(ifName, ipAddress, ipNet, false)
 }
 
+// Address represents an IP address assigned to a network interface.
+type Address struct {
+	// IPNet is the address with the prefix length of its subnet.
+	IPNet     *net.IPNet
+	LinkIndex int
+	Scope     int
+	// Flags are the IFA_F_* flags of the address, e.g. IFA_F_TENTATIVE or IFA_F_DADFAILED.
+	Flags int
+	Label string
+}
+
+// deserializeAddress decodes a netlink message into an Address struct.
+func deserializeAddress(msg *message) *Address {
+	ifAddr := (*unix.IfAddrmsg)(unsafe.Pointer(&msg.data[0:unix.SizeofIfAddrmsg][0]))
+	address := Address{
+		LinkIndex: int(ifAddr.Index),
+		Scope:     int(ifAddr.Scope),
+		Flags:     int(ifAddr.Flags),
+	}
+
+	var local, peer net.IP
+	for _, attr := range msg.getAttributes(nil) {
+		switch attr.Type {
+		case unix.IFA_LOCAL:
+			local = net.IP(attr.value)
+		case unix.IFA_ADDRESS:
+			peer = net.IP(attr.value)
+		case unix.IFA_LABEL:
+			address.Label = strings.TrimRight(string(attr.value), "\x00")
+		case unix.IFA_FLAGS:
+			address.Flags = int(encoder.Uint32(attr.value[0:4]))
+		}
+	}
+
+	// IFA_ADDRESS is the peer address of point-to-point links, IFA_LOCAL the address of the interface.
+	ip := local
+	if ip == nil {
+		ip = peer
+	}
+	address.IPNet = &net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(int(ifAddr.Prefixlen), 8*len(ip)),
+	}
+
+	return &address
+}
+
+// GetIPAddresses returns the IP addresses assigned to the network interface, like "ip addr show dev".
+func (Netlink) GetIPAddresses(ifName string) ([]*Address, error) {
+	iface, err := interfaceByName(ifName)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	req := newRequest(unix.RTM_GETADDR, unix.NLM_F_DUMP)
+	req.addPayload(newIfAddrMsg(unix.AF_UNSPEC))
+
+	msgs, err := s.sendAndWaitForResponse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []*Address
+	for _, msg := range msgs {
+		if msg.Type != unix.RTM_NEWADDR || len(msg.data) < unix.SizeofIfAddrmsg {
+			continue
+		}
+
+		address := deserializeAddress(msg)
+		if address.LinkIndex == iface.Index && address.IPNet.IP != nil {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses, nil
+}
+
 // Route represents a netlink route.
 type Route struct {
 	Family     int
@@ -94,6 +185,10 @@ type Route struct {
 	Priority   int
 	LinkIndex  int
 	ILinkIndex int
+	// MTU is the path MTU of the route, zero for the MTU of the link.
+	MTU int
+	// LockMTU keeps path MTU discovery from lowering the MTU of the route.
+	LockMTU bool
 }
 
 // deserializeRoute decodes a netlink message into a Route struct.
@@ -133,12 +228,33 @@ func deserializeRoute(msg *message) (*Route, error) {
 			route.LinkIndex = int(encoder.Uint32(attr.value[0:4]))
 		case unix.RTA_IIF:
 			route.ILinkIndex = int(encoder.Uint32(attr.value[0:4]))
+		case unix.RTA_METRICS:
+			route.MTU, route.LockMTU = deserializeRouteMTU(attr.value)
 		}
 	}
 
 	return &route, nil
 }
 
+// deserializeRouteMTU returns the MTU of the route metrics and whether it is locked.
+func deserializeRouteMTU(metrics []byte) (mtu int, locked bool) {
+	var lock uint32
+	for _, attr := range parseRtAttributes(metrics) {
+		if len(attr.Value) < 4 {
+			continue
+		}
+
+		switch attr.Attr.Type {
+		case unix.RTAX_MTU:
+			mtu = int(encoder.Uint32(attr.Value[0:4]))
+		case unix.RTAX_LOCK:
+			lock = encoder.Uint32(attr.Value[0:4])
+		}
+	}
+
+	return mtu, lock&(1<<unix.RTAX_MTU) != 0
+}
+
 // GetIPRoute returns a list of IP routes matching the given filter.
 func (Netlink) GetIPRoute(filter *Route) ([]*Route, error) {
 	s, err := getSocket()
@@ -211,6 +327,79 @@ func (Netlink) GetIPRoute(filter *Route) ([]*Route, error) {
 	return routes, nil
 }
 
+// RouteGetOptions narrows a route lookup like the from, oif and mark arguments of "ip route get".
+type RouteGetOptions struct {
+	// Src is the source address of the packet, nil to let the kernel pick one.
+	Src net.IP
+	// LinkIndex is the index of the interface the packet leaves through, 0 for any.
+	LinkIndex int
+	// Mark is the firewall mark of the packet, used to select policy routing tables.
+	Mark uint32
+}
+
+// GetRouteTo returns the route the kernel resolves for packets to dst, including the table it was found
+// in, the outgoing interface and the preferred source address. This is the equivalent of "ip route get".
+func (Netlink) GetRouteTo(dst net.IP, options *RouteGetOptions) (*Route, error) {
+	s, err := getSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	family := GetIPAddressFamily(dst)
+	req := newRequest(unix.RTM_GETROUTE, 0)
+
+	msg := &rtMsg{
+		RtMsg: unix.RtMsg{
+			Family: uint8(family),
+			Flags:  unix.RTM_F_LOOKUP_TABLE,
+		},
+	}
+	msg.Dst_len = uint8(8 * len(ipAddressValue(dst, family)))
+	req.addPayload(msg)
+	req.addPayload(newAttribute(unix.RTA_DST, ipAddressValue(dst, family)))
+
+	if options != nil {
+		if options.Src != nil {
+			msg.Src_len = uint8(8 * len(ipAddressValue(options.Src, family)))
+			req.addPayload(newAttribute(unix.RTA_SRC, ipAddressValue(options.Src, family)))
+		}
+
+		if options.LinkIndex != 0 {
+			req.addPayload(newAttributeUint32(unix.RTA_OIF, uint32(options.LinkIndex)))
+		}
+
+		if options.Mark != 0 {
+			req.addPayload(newAttributeUint32(unix.RTA_MARK, options.Mark))
+		}
+	}
+
+	msgs, err := s.sendAndWaitForResponse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(msgs) == 0 {
+		return nil, unix.ENETUNREACH
+	}
+
+	return deserializeRoute(msgs[0])
+}
+
+// GetIPRoutesInTable returns the routes of the address family in the routing table, e.g. a table
+// of a policy routing rule. Table 0 returns the routes of the main table.
+func (n Netlink) GetIPRoutesInTable(family, table int) ([]*Route, error) {
+	return n.GetIPRoute(&Route{Family: family, Table: table})
+}
+
+// ipAddressValue returns the address in the length of the address family.
+func ipAddressValue(ip net.IP, family int) net.IP {
+	if family == unix.AF_INET {
+		return ip.To4()
+	}
+
+	return ip.To16()
+}
+
 // setIpRoute sends an IP route set request.
 func setIpRoute(route *Route, add bool) error {
 	var msgType, flags int
@@ -225,7 +414,7 @@ func setIpRoute(route *Route, add bool) error {
 		flags = unix.NLM_F_CREATE | unix.NLM_F_EXCL | unix.NLM_F_ACK
 	} else {
 		msgType = unix.RTM_DELROUTE
-		flags = unix.NLM_F_EXCL | unix.NLM_F_ACK
+		flags = unix.NLM_F_ACK
 	}
 
 	req := newRequest(msgType, flags)
@@ -233,6 +422,10 @@ func setIpRoute(route *Route, add bool) error {
 	msg := newRtMsg(route.Family)
 	msg.Tos = uint8(route.Tos)
 	msg.Table = uint8(route.Table)
+	if route.Table > math.MaxUint8 {
+		// the table of the message is 8 bits wide, larger tables such as the ones of VRFs need RTA_TABLE
+		msg.Table = unix.RT_TABLE_UNSPEC
+	}
 
 	if route.Protocol != 0 {
 		msg.Protocol = uint8(route.Protocol)
@@ -264,6 +457,10 @@ func setIpRoute(route *Route, add bool) error {
 		req.addPayload(newAttributeIpAddress(unix.RTA_GATEWAY, route.Gw))
 	}
 
+	if route.Table > math.MaxUint8 {
+		req.addPayload(newAttributeUint32(unix.RTA_TABLE, uint32(route.Table)))
+	}
+
 	if route.Priority != 0 {
 		req.addPayload(newAttributeUint32(unix.RTA_PRIORITY, uint32(route.Priority)))
 	}
@@ -276,9 +473,23 @@ func setIpRoute(route *Route, add bool) error {
 		req.addPayload(newAttributeUint32(unix.RTA_IIF, uint32(route.ILinkIndex)))
 	}
 
+	if add && route.MTU != 0 {
+		req.addPayload(newRouteMetricsAttribute(route))
+	}
+
 	return s.sendAndWaitForAck(req)
 }
 
+// newRouteMetricsAttribute returns the metrics of the route with its MTU, locked if requested.
+func newRouteMetricsAttribute(route *Route) *attribute {
+	metrics := newAttribute(unix.RTA_METRICS, nil)
+	if route.LockMTU {
+		metrics.addNested(newAttributeUint32(unix.RTAX_LOCK, 1<<unix.RTAX_MTU))
+	}
+	metrics.addNested(newAttributeUint32(unix.RTAX_MTU, uint32(route.MTU)))
+	return metrics
+}
+
 // AddIPRoute adds an IP route to the route table.
 func (Netlink) AddIPRoute(route *Route) error {
 	return setIpRoute(route, true)
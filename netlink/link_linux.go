@@ -7,8 +7,12 @@
 package netlink
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
+	"strings"
+	"syscall"
+	"unsafe"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/pkg/errors"
@@ -17,10 +21,16 @@ import (
 
 // Link types.
 const (
-	LINK_TYPE_BRIDGE = "bridge"
-	LINK_TYPE_VETH   = "veth"
-	LINK_TYPE_IPVLAN = "ipvlan"
-	LINK_TYPE_DUMMY  = "dummy"
+	LINK_TYPE_BRIDGE    = "bridge"
+	LINK_TYPE_VETH      = "veth"
+	LINK_TYPE_IPVLAN    = "ipvlan"
+	LINK_TYPE_DUMMY     = "dummy"
+	LINK_TYPE_VLAN      = "vlan"
+	LINK_TYPE_WIREGUARD = "wireguard"
+	LINK_TYPE_VRF       = "vrf"
+	LINK_TYPE_BOND      = "bond"
+	LINK_TYPE_IFB       = "ifb"
+	LINK_TYPE_VXLAN     = "vxlan"
 )
 
 // IPVLAN link attributes.
@@ -33,6 +43,19 @@ const (
 	IPVLAN_MODE_MAX
 )
 
+// Bond link attributes.
+type BondMode uint8
+
+const (
+	BOND_MODE_BALANCE_RR BondMode = iota
+	BOND_MODE_ACTIVE_BACKUP
+	BOND_MODE_BALANCE_XOR
+	BOND_MODE_BROADCAST
+	BOND_MODE_802_3AD
+	BOND_MODE_BALANCE_TLB
+	BOND_MODE_BALANCE_ALB
+)
+
 const (
 	ADD = iota
 	REMOVE
@@ -53,6 +76,45 @@ type LinkInfo struct {
 	ParentIndex int
 	MacAddress  net.HardwareAddr
 	IPAddr      net.IP
+	// Statistics are the traffic counters of the link, only set on links returned by GetLink.
+	Statistics *LinkStatistics
+}
+
+// LinkStatistics are the traffic counters of a link as reported in IFLA_STATS64.
+type LinkStatistics struct {
+	RxPackets uint64
+	TxPackets uint64
+	RxBytes   uint64
+	TxBytes   uint64
+	RxErrors  uint64
+	TxErrors  uint64
+	RxDropped uint64
+	TxDropped uint64
+}
+
+// sizeofLinkStatistics is the size of the leading counters of rtnl_link_stats64 decoded into LinkStatistics.
+const sizeofLinkStatistics = 8 * 8
+
+// deserializeLinkStatistics decodes the leading counters of a rtnl_link_stats64 attribute.
+func deserializeLinkStatistics(b []byte) *LinkStatistics {
+	if len(b) < sizeofLinkStatistics {
+		return nil
+	}
+
+	counter := func(i int) uint64 {
+		return encoder.Uint64(b[i*8 : (i+1)*8])
+	}
+
+	return &LinkStatistics{
+		RxPackets: counter(0),
+		TxPackets: counter(1),
+		RxBytes:   counter(2),
+		TxBytes:   counter(3),
+		RxErrors:  counter(4),
+		TxErrors:  counter(5),
+		RxDropped: counter(6),
+		TxDropped: counter(7),
+	}
 }
 
 func (linkInfo *LinkInfo) Info() *LinkInfo {
@@ -81,6 +143,64 @@ type DummyLink struct {
 	LinkInfo
 }
 
+// VlanLink represents an 802.1Q VLAN sub-interface of the link at ParentIndex.
+type VlanLink struct {
+	LinkInfo
+	VlanID uint16
+}
+
+// WireguardLink represents a wireguard tunnel interface. Keys and peers are configured separately.
+type WireguardLink struct {
+	LinkInfo
+}
+
+// VxlanLink represents a VXLAN tunnel interface. Frames to a MAC address are sent to the remote VTEP
+// of its FDB entry.
+type VxlanLink struct {
+	LinkInfo
+	VNI uint32
+	// VtepIndex is the index of the underlay interface, zero lets the kernel pick it by route.
+	VtepIndex int
+	// Local is the source address of the encapsulated packets.
+	Local net.IP
+	// Port is the UDP destination port, zero uses the kernel default.
+	Port uint16
+	// Learning enables learning the FDB entries from received packets.
+	Learning bool
+}
+
+// VRFLink represents a VRF device. Links enslaved to it with SetLinkMaster are routed with the routes
+// of its table.
+type VRFLink struct {
+	LinkInfo
+	Table uint32
+}
+
+// BondLink represents a bond of the links enslaved to it with SetLinkMaster. Links must be down
+// before they are enslaved.
+type BondLink struct {
+	LinkInfo
+	Mode BondMode
+	// Miimon is the link monitoring interval in milliseconds, zero disables link monitoring.
+	Miimon uint32
+}
+
+// interfaceByName returns the network interface with the name. The error matches ErrNoSuchDevice
+// if the interface does not exist.
+func interfaceByName(name string) (*net.Interface, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Err != nil && opErr.Err.Error() == errNoSuchInterfaceMsg {
+			return nil, &Error{Errno: unix.ENODEV, Link: name}
+		}
+
+		return nil, err
+	}
+
+	return iface, nil
+}
+
 // AddLink adds a new network interface of a specified type.
 func (Netlink) AddLink(link Link) error {
 	info := link.Info()
@@ -151,6 +271,54 @@ func (Netlink) AddLink(link Link) error {
 		attrData := newAttribute(IFLA_INFO_DATA, nil)
 		attrData.addNested(newAttributeUint16(IFLA_IPVLAN_MODE, uint16(ipvlan.Mode)))
 
+		attrLinkInfo.addNested(attrData)
+	} else if vrf, ok := link.(*VRFLink); ok {
+		// Set VRF attributes.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttributeUint32(IFLA_VRF_TABLE, vrf.Table))
+
+		attrLinkInfo.addNested(attrData)
+	} else if vlan, ok := link.(*VlanLink); ok {
+		// Set VLAN attributes.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttributeUint16(IFLA_VLAN_ID, vlan.VlanID))
+
+		attrLinkInfo.addNested(attrData)
+	} else if vxlan, ok := link.(*VxlanLink); ok {
+		// Set VXLAN attributes.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttributeUint32(unix.IFLA_VXLAN_ID, vxlan.VNI))
+		if vxlan.VtepIndex != 0 {
+			attrData.addNested(newAttributeUint32(unix.IFLA_VXLAN_LINK, uint32(vxlan.VtepIndex)))
+		}
+		if vxlan.Local != nil {
+			if local := vxlan.Local.To4(); local != nil {
+				attrData.addNested(newAttribute(unix.IFLA_VXLAN_LOCAL, local))
+			} else {
+				attrData.addNested(newAttribute(unix.IFLA_VXLAN_LOCAL6, vxlan.Local.To16()))
+			}
+		}
+		if vxlan.Port != 0 {
+			// The port is in network byte order.
+			port := make([]byte, 2)
+			binary.BigEndian.PutUint16(port, vxlan.Port)
+			attrData.addNested(newAttribute(unix.IFLA_VXLAN_PORT, port))
+		}
+		learning := []byte{0}
+		if vxlan.Learning {
+			learning[0] = 1
+		}
+		attrData.addNested(newAttribute(unix.IFLA_VXLAN_LEARNING, learning))
+
+		attrLinkInfo.addNested(attrData)
+	} else if bond, ok := link.(*BondLink); ok {
+		// Set bond attributes.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttribute(unix.IFLA_BOND_MODE, []byte{byte(bond.Mode)}))
+		if bond.Miimon > 0 {
+			attrData.addNested(newAttributeUint32(unix.IFLA_BOND_MIIMON, bond.Miimon))
+		}
+
 		attrLinkInfo.addNested(attrData)
 	}
 
@@ -159,8 +327,119 @@ func (Netlink) AddLink(link Link) error {
 	return s.sendAndWaitForAck(req)
 }
 
+// GetLink returns the network interface with the name. VLAN, VXLAN and bond links are returned as
+// *VlanLink, *VxlanLink and *BondLink, other links as *LinkInfo with the link kind as Type.
+func (Netlink) GetLink(name string) (Link, error) {
+	iface, err := interfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	req := newRequest(unix.RTM_GETLINK, 0)
+
+	ifInfo := newIfInfoMsg()
+	ifInfo.Index = int32(iface.Index)
+	req.addPayload(ifInfo)
+
+	msgs, err := s.sendAndWaitForResponse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range msgs {
+		if msg.Type == unix.RTM_NEWLINK && len(msg.data) >= unix.SizeofIfInfomsg {
+			return deserializeLink(msg), nil
+		}
+	}
+
+	return nil, &Error{Errno: unix.ENODEV, Link: name}
+}
+
+// deserializeLink decodes a link message into the Link of its kind.
+func deserializeLink(msg *message) Link {
+	ifInfo := (*unix.IfInfomsg)(unsafe.Pointer(&msg.data[0:unix.SizeofIfInfomsg][0]))
+
+	var info LinkInfo
+	if ifInfo.Flags&unix.IFF_UP != 0 {
+		info.Flags |= net.FlagUp
+	}
+
+	var data []syscall.NetlinkRouteAttr
+	for _, attr := range parseRtAttributes(msg.data[unix.SizeofIfInfomsg:]) {
+		switch attr.Attr.Type & NLA_TYPE_MASK {
+		case unix.IFLA_IFNAME:
+			info.Name = strings.TrimRight(string(attr.Value), "\x00")
+		case unix.IFLA_MTU:
+			info.MTU = uint(encoder.Uint32(attr.Value))
+		case unix.IFLA_TXQLEN:
+			info.TxQLen = uint(encoder.Uint32(attr.Value))
+		case unix.IFLA_LINK:
+			info.ParentIndex = int(encoder.Uint32(attr.Value))
+		case unix.IFLA_ADDRESS:
+			info.MacAddress = net.HardwareAddr(attr.Value)
+		case unix.IFLA_STATS64:
+			info.Statistics = deserializeLinkStatistics(attr.Value)
+		case unix.IFLA_LINKINFO:
+			for _, nested := range parseRtAttributes(attr.Value) {
+				switch nested.Attr.Type & NLA_TYPE_MASK {
+				case IFLA_INFO_KIND:
+					info.Type = strings.TrimRight(string(nested.Value), "\x00")
+				case IFLA_INFO_DATA:
+					data = parseRtAttributes(nested.Value)
+				}
+			}
+		}
+	}
+
+	switch info.Type {
+	case LINK_TYPE_VLAN:
+		vlan := &VlanLink{LinkInfo: info}
+		for _, attr := range data {
+			if attr.Attr.Type&NLA_TYPE_MASK == IFLA_VLAN_ID && len(attr.Value) >= 2 {
+				vlan.VlanID = encoder.Uint16(attr.Value)
+			}
+		}
+		return vlan
+	case LINK_TYPE_VXLAN:
+		vxlan := &VxlanLink{LinkInfo: info}
+		for _, attr := range data {
+			switch attr.Attr.Type & NLA_TYPE_MASK {
+			case unix.IFLA_VXLAN_ID:
+				vxlan.VNI = encoder.Uint32(attr.Value)
+			case unix.IFLA_VXLAN_LINK:
+				vxlan.VtepIndex = int(encoder.Uint32(attr.Value))
+			case unix.IFLA_VXLAN_LOCAL, unix.IFLA_VXLAN_LOCAL6:
+				vxlan.Local = net.IP(attr.Value)
+			case unix.IFLA_VXLAN_PORT:
+				vxlan.Port = binary.BigEndian.Uint16(attr.Value)
+			case unix.IFLA_VXLAN_LEARNING:
+				vxlan.Learning = attr.Value[0] != 0
+			}
+		}
+		return vxlan
+	case LINK_TYPE_BOND:
+		bond := &BondLink{LinkInfo: info}
+		for _, attr := range data {
+			switch attr.Attr.Type & NLA_TYPE_MASK {
+			case unix.IFLA_BOND_MODE:
+				bond.Mode = BondMode(attr.Value[0])
+			case unix.IFLA_BOND_MIIMON:
+				bond.Miimon = encoder.Uint32(attr.Value)
+			}
+		}
+		return bond
+	}
+
+	return &info
+}
+
 func (Netlink) SetLinkMTU(name string, mtu int) error {
-	iface, err := net.InterfaceByName(name)
+	iface, err := interfaceByName(name)
 	if err != nil {
 		log.Printf("[net] Interface not found. returning error")
 		return errors.Wrap(err, "SetLinkMTU:InterfaceByName failed")
@@ -189,7 +468,7 @@ func (Netlink) DeleteLink(name string) error {
 		return nil
 	}
 
-	iface, err := net.InterfaceByName(name)
+	iface, err := interfaceByName(name)
 	if err != nil {
 		log.Printf("[net] Interface not found. Not returning error")
 		return nil
@@ -216,7 +495,7 @@ func (Netlink) SetLinkName(name string, newName string) error {
 		return err
 	}
 
-	iface, err := net.InterfaceByName(name)
+	iface, err := interfaceByName(name)
 	if err != nil {
 		return err
 	}
@@ -243,7 +522,7 @@ func (Netlink) SetLinkState(name string, up bool) error {
 		return err
 	}
 
-	iface, err := net.InterfaceByName(name)
+	iface, err := interfaceByName(name)
 	if err != nil {
 		return err
 	}
@@ -274,14 +553,14 @@ func (Netlink) SetLinkMaster(name string, master string) error {
 		return err
 	}
 
-	iface, err := net.InterfaceByName(name)
+	iface, err := interfaceByName(name)
 	if err != nil {
 		return err
 	}
 
 	var masterIndex uint32
 	if master != "" {
-		masterIface, err := net.InterfaceByName(master)
+		masterIface, err := interfaceByName(master)
 		if err != nil {
 			return err
 		}
@@ -310,7 +589,7 @@ func (Netlink) SetLinkNetNs(name string, fd uintptr) error {
 		return err
 	}
 
-	iface, err := net.InterfaceByName(name)
+	iface, err := interfaceByName(name)
 	if err != nil {
 		return err
 	}
@@ -337,7 +616,7 @@ func (Netlink) SetLinkAddress(ifName string, hwAddress net.HardwareAddr) error {
 		return err
 	}
 
-	iface, err := net.InterfaceByName(ifName)
+	iface, err := interfaceByName(ifName)
 	if err != nil {
 		return err
 	}
@@ -364,7 +643,7 @@ func (Netlink) SetLinkPromisc(ifName string, on bool) error {
 		return err
 	}
 
-	iface, err := net.InterfaceByName(ifName)
+	iface, err := interfaceByName(ifName)
 	if err != nil {
 		return err
 	}
@@ -395,7 +674,7 @@ func (Netlink) SetLinkHairpin(bridgeName string, on bool) error {
 		return err
 	}
 
-	iface, err := net.InterfaceByName(bridgeName)
+	iface, err := interfaceByName(bridgeName)
 	if err != nil {
 		return err
 	}
@@ -438,7 +717,7 @@ func (Netlink) SetOrRemoveLinkAddress(linkInfo LinkInfo, mode, linkState int) er
 	}
 	state = linkState
 
-	iface, err := net.InterfaceByName(linkInfo.Name)
+	iface, err := interfaceByName(linkInfo.Name)
 	if err != nil {
 		return err
 	}
@@ -464,3 +743,183 @@ func (Netlink) SetOrRemoveLinkAddress(linkInfo LinkInfo, mode, linkState int) er
 
 	return s.sendAndWaitForAck(req)
 }
+
+// Neighbor represents an entry of the neighbor (ARP or NDP) table.
+type Neighbor struct {
+	Family       int
+	LinkIndex    int
+	IP           net.IP
+	HardwareAddr net.HardwareAddr
+	// State is the NUD_* state of the entry.
+	State int
+	Flags int
+	Type  int
+}
+
+// deserializeNeighbor decodes a netlink message into a Neighbor struct.
+func deserializeNeighbor(msg *message) *Neighbor {
+	ndMsg := (*unix.NdMsg)(unsafe.Pointer(&msg.data[0:unix.SizeofNdMsg][0]))
+	neigh := Neighbor{
+		Family:    int(ndMsg.Family),
+		LinkIndex: int(ndMsg.Ifindex),
+		State:     int(ndMsg.State),
+		Flags:     int(ndMsg.Flags),
+		Type:      int(ndMsg.Type),
+	}
+
+	// The attributes of neighbor messages are not parsed on receive since the
+	// syscall package doesn't know their header.
+	for _, attr := range parseRtAttributes(msg.data[unix.SizeofNdMsg:]) {
+		switch attr.Attr.Type {
+		case NDA_DST:
+			neigh.IP = net.IP(attr.Value)
+		case NDA_LLADDR:
+			neigh.HardwareAddr = net.HardwareAddr(attr.Value)
+		}
+	}
+
+	return &neigh
+}
+
+// ListNeighbors returns the neighbor entries of the network interface, like "ip neigh show dev".
+func (Netlink) ListNeighbors(ifName string) ([]*Neighbor, error) {
+	iface, err := interfaceByName(ifName)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	req := newRequest(unix.RTM_GETNEIGH, unix.NLM_F_DUMP)
+	req.addPayload(&neighMsg{Family: unix.AF_UNSPEC})
+
+	msgs, err := s.sendAndWaitForResponse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var neighbors []*Neighbor
+	for _, msg := range msgs {
+		if msg.Type != unix.RTM_NEWNEIGH || len(msg.data) < unix.SizeofNdMsg {
+			continue
+		}
+
+		neigh := deserializeNeighbor(msg)
+		if neigh.LinkIndex == iface.Index {
+			neighbors = append(neighbors, neigh)
+		}
+	}
+
+	return neighbors, nil
+}
+
+// setFDBEntry adds or removes the forwarding database entry which sends the frames to the MAC address
+// out of the interface to the remote VTEP, like "bridge fdb replace/del <mac> dev <ifName> dst <dst>".
+func setFDBEntry(ifName string, mac net.HardwareAddr, dst net.IP, add bool) error {
+	iface, err := interfaceByName(ifName)
+	if err != nil {
+		return err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	var req *message
+	if add {
+		req = newRequest(unix.RTM_NEWNEIGH, unix.NLM_F_CREATE|unix.NLM_F_REPLACE|unix.NLM_F_ACK)
+	} else {
+		req = newRequest(unix.RTM_DELNEIGH, unix.NLM_F_ACK)
+	}
+
+	req.addPayload(&neighMsg{
+		Family: unix.AF_BRIDGE,
+		Index:  uint32(iface.Index),
+		State:  NUD_PERMANENT,
+		Flags:  NTF_SELF,
+	})
+	req.addPayload(newRtAttr(NDA_LLADDR, []byte(mac)))
+
+	if dst != nil {
+		dstData := dst.To4()
+		if dstData == nil {
+			dstData = dst.To16()
+		}
+		req.addPayload(newRtAttr(NDA_DST, dstData))
+	}
+
+	return s.sendAndWaitForAck(req)
+}
+
+// AddFDBEntry adds or replaces the forwarding database entry of the MAC address on the interface.
+func (Netlink) AddFDBEntry(ifName string, mac net.HardwareAddr, dst net.IP) error {
+	return setFDBEntry(ifName, mac, dst, true)
+}
+
+// DeleteFDBEntry deletes the forwarding database entry of the MAC address on the interface.
+func (Netlink) DeleteFDBEntry(ifName string, mac net.HardwareAddr, dst net.IP) error {
+	return setFDBEntry(ifName, mac, dst, false)
+}
+
+// ListFDBEntries returns the forwarding database entries of the interface, like "bridge fdb show dev".
+// The IP of an entry is its remote VTEP.
+func (Netlink) ListFDBEntries(ifName string) ([]*Neighbor, error) {
+	iface, err := interfaceByName(ifName)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	req := newRequest(unix.RTM_GETNEIGH, unix.NLM_F_DUMP)
+	req.addPayload(&neighMsg{Family: unix.AF_BRIDGE})
+
+	msgs, err := s.sendAndWaitForResponse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Neighbor
+	for _, msg := range msgs {
+		if msg.Type != unix.RTM_NEWNEIGH || len(msg.data) < unix.SizeofNdMsg {
+			continue
+		}
+
+		entry := deserializeNeighbor(msg)
+		if entry.LinkIndex == iface.Index {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// parseRtAttributes parses the route attributes following the header of a message.
+func parseRtAttributes(b []byte) []syscall.NetlinkRouteAttr {
+	var attrs []syscall.NetlinkRouteAttr
+	for len(b) >= unix.SizeofRtAttr {
+		l := int(encoder.Uint16(b[0:2]))
+		if l < unix.SizeofRtAttr || l > len(b) {
+			break
+		}
+
+		attrs = append(attrs, syscall.NetlinkRouteAttr{
+			Attr:  syscall.RtAttr{Len: uint16(l), Type: encoder.Uint16(b[2:4])},
+			Value: b[unix.SizeofRtAttr:l],
+		})
+
+		if rtaAlignOf(l) >= len(b) {
+			break
+		}
+		b = b[rtaAlignOf(l):]
+	}
+
+	return attrs
+}
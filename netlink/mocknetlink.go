@@ -36,6 +36,13 @@ func (f *MockNetlink) AddLink(l Link) error {
 	return f.error()
 }
 
+func (f *MockNetlink) GetLink(name string) (Link, error) {
+	if err := f.error(); err != nil {
+		return nil, err
+	}
+	return &LinkInfo{Name: name}, nil
+}
+
 func (f *MockNetlink) SetLinkMTU(name string, mtu int) error {
 	return f.error()
 }
@@ -84,10 +91,38 @@ func (f *MockNetlink) DeleteIPAddress(string, net.IP, *net.IPNet) error {
 	return f.error()
 }
 
+func (f *MockNetlink) GetIPAddresses(string) ([]*Address, error) {
+	return nil, f.error()
+}
+
+func (f *MockNetlink) ListNeighbors(string) ([]*Neighbor, error) {
+	return nil, f.error()
+}
+
+func (f *MockNetlink) AddFDBEntry(string, net.HardwareAddr, net.IP) error {
+	return f.error()
+}
+
+func (f *MockNetlink) DeleteFDBEntry(string, net.HardwareAddr, net.IP) error {
+	return f.error()
+}
+
+func (f *MockNetlink) ListFDBEntries(string) ([]*Neighbor, error) {
+	return nil, f.error()
+}
+
 func (f *MockNetlink) GetIPRoute(*Route) ([]*Route, error) {
 	return nil, f.error()
 }
 
+func (f *MockNetlink) GetIPRoutesInTable(int, int) ([]*Route, error) {
+	return nil, f.error()
+}
+
+func (f *MockNetlink) GetRouteTo(net.IP, *RouteGetOptions) (*Route, error) {
+	return &Route{}, f.error()
+}
+
 func (f *MockNetlink) AddIPRoute(*Route) error {
 	return f.error()
 }
@@ -95,3 +130,31 @@ func (f *MockNetlink) AddIPRoute(*Route) error {
 func (f *MockNetlink) DeleteIPRoute(*Route) error {
 	return f.error()
 }
+
+func (f *MockNetlink) AddIPRule(*Rule) error {
+	return f.error()
+}
+
+func (f *MockNetlink) DeleteIPRule(*Rule) error {
+	return f.error()
+}
+
+func (f *MockNetlink) GetIPRules(int) ([]*Rule, error) {
+	return nil, f.error()
+}
+
+func (f *MockNetlink) ReplaceQdisc(Qdisc) error {
+	return f.error()
+}
+
+func (f *MockNetlink) DeleteQdisc(Qdisc) error {
+	return f.error()
+}
+
+func (f *MockNetlink) ReplaceClass(*HtbClass) error {
+	return f.error()
+}
+
+func (f *MockNetlink) ReplaceFilter(*RedirectFilter) error {
+	return f.error()
+}
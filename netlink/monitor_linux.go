@@ -0,0 +1,175 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/sys/unix"
+)
+
+// LinkEventType is the change a link event reports.
+type LinkEventType int
+
+const (
+	// LinkUp is reported when a link becomes operational. Links seen for the first time are reported
+	// with their current state.
+	LinkUp LinkEventType = iota
+	// LinkDown is reported when a link stops being operational.
+	LinkDown
+	// LinkDeleted is reported when a link is removed.
+	LinkDeleted
+)
+
+func (t LinkEventType) String() string {
+	switch t {
+	case LinkUp:
+		return "up"
+	case LinkDown:
+		return "down"
+	default:
+		return "deleted"
+	}
+}
+
+// LinkEvent is a change of the state of a network interface.
+type LinkEvent struct {
+	Type  LinkEventType
+	Index int
+	Name  string
+	// Flags are the IFF_* flags of the link.
+	Flags uint32
+}
+
+const (
+	// monitorReceiveTimeout bounds how long the monitor blocks in a receive before checking for cancellation.
+	monitorReceiveTimeout = 500 * time.Millisecond
+	// monitorBufferSize fits link messages, which exceed a page for NICs with many virtual functions.
+	monitorBufferSize = 64 * 1024
+)
+
+// SubscribeLinkEvents subscribes to the RTNLGRP_LINK multicast group and returns a channel of link
+// up, down and delete events. Only changes of the operational state are reported, the events of
+// other link attribute changes are dropped. The channel is closed once ctx is done.
+func SubscribeLinkEvents(ctx context.Context) (<-chan LinkEvent, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+
+	sa := &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: unix.RTMGRP_LINK,
+	}
+	if err = unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	tv := unix.NsecToTimeval(monitorReceiveTimeout.Nanoseconds())
+	if err = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	events := make(chan LinkEvent)
+	go monitorLinks(ctx, fd, events)
+
+	return events, nil
+}
+
+// monitorLinks receives the link messages of the socket until ctx is done.
+func monitorLinks(ctx context.Context, fd int, events chan<- LinkEvent) {
+	defer close(events)
+	defer unix.Close(fd)
+
+	up := make(map[int]bool)
+	buffer := make([]byte, monitorBufferSize)
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(fd, buffer, 0)
+		switch {
+		case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.ENOBUFS):
+			// the kernel dropped events while we were busy, the next event of a link corrects its state
+			log.Printf("[netlink] Link monitor overrun, events were lost")
+			continue
+		case err != nil:
+			log.Printf("[netlink] Link monitor failed to receive: %v", err)
+			return
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			log.Printf("[netlink] Link monitor failed to parse message: %v", err)
+			continue
+		}
+
+		for i := range msgs {
+			event, ok := parseLinkEvent(&msgs[i], up)
+			if !ok {
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// parseLinkEvent converts a link message into an event. The operational state of every link is kept
+// in up so that messages which don't change it are not reported.
+func parseLinkEvent(msg *syscall.NetlinkMessage, up map[int]bool) (LinkEvent, bool) {
+	if msg.Header.Type != unix.RTM_NEWLINK && msg.Header.Type != unix.RTM_DELLINK {
+		return LinkEvent{}, false
+	}
+
+	if len(msg.Data) < unix.SizeofIfInfomsg {
+		return LinkEvent{}, false
+	}
+
+	ifInfo := (*unix.IfInfomsg)(unsafe.Pointer(&msg.Data[0]))
+	event := LinkEvent{
+		Index: int(ifInfo.Index),
+		Flags: ifInfo.Flags,
+	}
+
+	attrs, _ := syscall.ParseNetlinkRouteAttr(msg)
+	for _, attr := range attrs {
+		if attr.Attr.Type == unix.IFLA_IFNAME {
+			event.Name = strings.TrimRight(string(attr.Value), "\x00")
+		}
+	}
+
+	if msg.Header.Type == unix.RTM_DELLINK {
+		delete(up, event.Index)
+		event.Type = LinkDeleted
+		return event, true
+	}
+
+	isUp := ifInfo.Flags&unix.IFF_UP != 0 && ifInfo.Flags&unix.IFF_RUNNING != 0
+	if wasUp, known := up[event.Index]; known && wasUp == isUp {
+		return LinkEvent{}, false
+	}
+	up[event.Index] = isUp
+
+	event.Type = LinkDown
+	if isUp {
+		event.Type = LinkUp
+	}
+
+	return event, true
+}
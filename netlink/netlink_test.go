@@ -7,10 +7,18 @@
 package netlink
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
+	"os"
+	"runtime"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -170,6 +178,34 @@ func TestAddDeleteIPVlan(t *testing.T) {
 	}
 }
 
+// TestAddDeleteVlan tests adding and deleting a VLAN sub-interface.
+func TestAddDeleteVlan(t *testing.T) {
+	dummy, err := addDummyInterface(dummyName)
+	require.NoError(t, err)
+
+	link := VlanLink{
+		LinkInfo: LinkInfo{
+			Type:        LINK_TYPE_VLAN,
+			Name:        ifName,
+			ParentIndex: dummy.Index,
+		},
+		VlanID: 100,
+	}
+	nl := NewNetlink()
+
+	err = nl.AddLink(&link)
+	require.NoError(t, err)
+
+	err = nl.DeleteLink(ifName)
+	require.NoError(t, err)
+
+	_, err = net.InterfaceByName(ifName)
+	require.Error(t, err, "Interface not deleted")
+
+	err = nl.DeleteLink(dummyName)
+	require.NoError(t, err)
+}
+
 // TestSetLinkState tests setting the operational state of a network interface.
 func TestSetLinkState(t *testing.T) {
 	_, err := addDummyInterface(ifName)
@@ -311,3 +347,591 @@ func TestAddRemoveStaticArp(t *testing.T) {
 		t.Errorf("DeleteLink failed: %+v", err)
 	}
 }
+
+func TestGetRouteTo(t *testing.T) {
+	nl := NewNetlink()
+	lo, err := net.InterfaceByName("lo")
+	require.NoError(t, err)
+
+	route, err := nl.GetRouteTo(net.ParseIP("127.0.0.1"), nil)
+	require.NoError(t, err)
+	require.Equal(t, unix.AF_INET, route.Family)
+	// the kernel merges the local into the main table unless there are policy routing rules
+	require.Contains(t, []int{unix.RT_TABLE_LOCAL, unix.RT_TABLE_MAIN}, route.Table)
+	require.Equal(t, unix.RTN_LOCAL, route.Type)
+	require.Equal(t, lo.Index, route.LinkIndex)
+	require.True(t, route.Dst.IP.Equal(net.ParseIP("127.0.0.1")))
+
+	route, err = nl.GetRouteTo(net.ParseIP("127.0.0.2"), &RouteGetOptions{LinkIndex: lo.Index})
+	require.NoError(t, err)
+	require.Equal(t, lo.Index, route.LinkIndex)
+}
+
+func TestGetIPRoutesInTable(t *testing.T) {
+	nl := NewNetlink()
+	lo, err := net.InterfaceByName("lo")
+	require.NoError(t, err)
+
+	routes, err := nl.GetIPRoutesInTable(unix.AF_INET, unix.RT_TABLE_LOCAL)
+	require.NoError(t, err)
+
+	found := false
+	for _, route := range routes {
+		require.Equal(t, unix.RT_TABLE_LOCAL, route.Table)
+		if route.LinkIndex == lo.Index && route.Dst != nil && route.Dst.IP.Equal(net.ParseIP("127.0.0.1")) {
+			found = true
+		}
+	}
+	require.True(t, found, "local table has no route for 127.0.0.1")
+}
+
+// newLinkMessage returns a link message as received from the kernel.
+func newLinkMessage(msgType uint16, index int32, flags uint32, name string) *syscall.NetlinkMessage {
+	ifInfo := newIfInfoMsg()
+	ifInfo.Index = index
+	ifInfo.Flags = flags
+	msg := newMessage(int(msgType), 0)
+	msg.addPayload(ifInfo)
+	msg.addPayload(newAttributeStringZ(unix.IFLA_IFNAME, name))
+
+	msgs, err := syscall.ParseNetlinkMessage(msg.serialize())
+	if err != nil || len(msgs) != 1 {
+		panic("failed to parse link message")
+	}
+	return &msgs[0]
+}
+
+func TestParseLinkEvent(t *testing.T) {
+	up := make(map[int]bool)
+	running := uint32(unix.IFF_UP | unix.IFF_RUNNING)
+
+	event, ok := parseLinkEvent(newLinkMessage(unix.RTM_NEWLINK, 2, running, "eth0"), up)
+	require.True(t, ok)
+	require.Equal(t, LinkEvent{Type: LinkUp, Index: 2, Name: "eth0", Flags: running}, event)
+
+	// attribute changes which keep the link up are not reported
+	_, ok = parseLinkEvent(newLinkMessage(unix.RTM_NEWLINK, 2, running, "eth0"), up)
+	require.False(t, ok)
+
+	// carrier loss keeps IFF_UP but clears IFF_RUNNING
+	event, ok = parseLinkEvent(newLinkMessage(unix.RTM_NEWLINK, 2, unix.IFF_UP, "eth0"), up)
+	require.True(t, ok)
+	require.Equal(t, LinkDown, event.Type)
+
+	event, ok = parseLinkEvent(newLinkMessage(unix.RTM_DELLINK, 2, 0, "eth0"), up)
+	require.True(t, ok)
+	require.Equal(t, LinkDeleted, event.Type)
+	require.Empty(t, up)
+
+	_, ok = parseLinkEvent(newLinkMessage(unix.RTM_NEWADDR, 2, running, "eth0"), up)
+	require.False(t, ok)
+}
+
+func TestSubscribeLinkEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := SubscribeLinkEvents(ctx)
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		for ok {
+			_, ok = <-events
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("events channel was not closed after cancellation")
+	}
+}
+
+func TestGetIPAddresses(t *testing.T) {
+	nl := NewNetlink()
+	addresses, err := nl.GetIPAddresses("lo")
+	require.NoError(t, err)
+
+	found := false
+	for _, address := range addresses {
+		if address.IPNet.String() == "127.0.0.1/8" {
+			found = true
+			require.Equal(t, unix.RT_SCOPE_HOST, address.Scope)
+			require.Equal(t, "lo", address.Label)
+		}
+	}
+	require.True(t, found, "lo has no address 127.0.0.1/8: %+v", addresses)
+
+	_, err = nl.GetIPAddresses("nonexistent0")
+	require.Error(t, err)
+}
+
+func TestListNeighbors(t *testing.T) {
+	_, err := NewNetlink().ListNeighbors("lo")
+	require.NoError(t, err)
+}
+
+func TestDeserializeNeighbor(t *testing.T) {
+	ip := net.ParseIP("10.0.0.4").To4()
+	mac, _ := net.ParseMAC("aa:b3:4d:5e:e2:4a")
+
+	var data []byte
+	data = append(data, (&neighMsg{Family: unix.AF_INET, Index: 7, State: NUD_PERMANENT}).serialize()...)
+	data = append(data, newRtAttr(NDA_DST, ip).serialize()...)
+	data = append(data, newRtAttr(NDA_LLADDR, mac).serialize()...)
+
+	neigh := deserializeNeighbor(&message{data: data})
+	require.Equal(t, unix.AF_INET, neigh.Family)
+	require.Equal(t, 7, neigh.LinkIndex)
+	require.Equal(t, NUD_PERMANENT, neigh.State)
+	require.True(t, neigh.IP.Equal(ip))
+	require.Equal(t, mac, neigh.HardwareAddr)
+}
+
+func TestTbfQdiscOptions(t *testing.T) {
+	tbf := &TbfQdisc{Rate: 8 * 1000 * 1000, Burst: 64 * 1024}
+	options, err := tbf.options()
+	require.NoError(t, err)
+
+	b := options.serialize()
+	require.Equal(t, uint16(TCA_OPTIONS), encoder.Uint16(b[2:4]))
+	parms := b[8:]
+	require.Equal(t, uint8(TC_LINKLAYER_ETHERNET), parms[1])
+	require.Equal(t, uint32(1000*1000), encoder.Uint32(parms[8:12]), "rate is in bytes per second")
+	require.Equal(t, uint32(25*1000+64*1024), encoder.Uint32(parms[24:28]), "limit queues 25ms of traffic")
+
+	_, err = (&TbfQdisc{Rate: 8}).options()
+	require.Error(t, err)
+}
+
+func TestReplaceDeleteQdisc(t *testing.T) {
+	nl := NewNetlink()
+	err := nl.AddLink(&VEthLink{
+		LinkInfo: LinkInfo{Type: LINK_TYPE_VETH, Name: ifName},
+		PeerName: ifName2,
+	})
+	require.NoError(t, err)
+
+	//nolint:errcheck // not testing deletelink here
+	defer nl.DeleteLink(ifName)
+
+	tbf := &TbfQdisc{
+		QdiscInfo: QdiscInfo{LinkName: ifName, Handle: MakeHandle(1, 0), Parent: TC_H_ROOT},
+		Rate:      10 * 1000 * 1000,
+		Burst:     64 * 1024,
+	}
+	require.NoError(t, nl.ReplaceQdisc(tbf))
+	tbf.Rate = 20 * 1000 * 1000
+	require.NoError(t, nl.ReplaceQdisc(tbf), "replacing the qdisc must succeed")
+	require.NoError(t, nl.DeleteQdisc(tbf))
+
+	htb := &HtbQdisc{
+		QdiscInfo:    QdiscInfo{LinkName: ifName, Handle: MakeHandle(1, 0), Parent: TC_H_ROOT},
+		DefaultClass: 1,
+	}
+	require.NoError(t, nl.ReplaceQdisc(htb))
+	require.NoError(t, nl.ReplaceClass(&HtbClass{
+		LinkName: ifName,
+		Handle:   MakeHandle(1, 1),
+		Parent:   MakeHandle(1, 0),
+		Rate:     10 * 1000 * 1000,
+		Burst:    64 * 1024,
+	}))
+	require.NoError(t, nl.DeleteQdisc(htb))
+
+	// redirect the traffic received by the veth to an ifb
+	require.NoError(t, nl.AddLink(&LinkInfo{Type: LINK_TYPE_IFB, Name: "ifbtest0"}))
+	//nolint:errcheck // not testing deletelink here
+	defer nl.DeleteLink("ifbtest0")
+
+	ingress := &IngressQdisc{QdiscInfo: QdiscInfo{LinkName: ifName, Handle: MakeHandle(0xffff, 0), Parent: TC_H_INGRESS}}
+	require.NoError(t, nl.ReplaceQdisc(ingress))
+	filter := &RedirectFilter{LinkName: ifName, Parent: MakeHandle(0xffff, 0), Priority: 1, TargetLinkName: "ifbtest0"}
+	require.NoError(t, nl.ReplaceFilter(filter))
+	require.NoError(t, nl.ReplaceFilter(filter), "replacing the filter must succeed")
+	require.NoError(t, nl.DeleteQdisc(ingress))
+}
+
+func TestRedirectFilterOptions(t *testing.T) {
+	b := (&RedirectFilter{}).options(7).serialize()
+
+	// TCA_OPTIONS > TCA_U32_SEL, TCA_U32_ACT > action 1 > TCA_ACT_KIND "mirred", TCA_ACT_OPTIONS > TCA_MIRRED_PARMS
+	require.Equal(t, uint16(TCA_OPTIONS), encoder.Uint16(b[2:4]))
+	require.Equal(t, uint16(TCA_U32_SEL), encoder.Uint16(b[6:8]))
+	sel := b[8:40]
+	require.Equal(t, uint8(TC_U32_TERMINAL), sel[0])
+	require.Equal(t, uint8(1), sel[2], "a single key")
+	require.Equal(t, make([]byte, 16), sel[16:], "the key matches every packet")
+	require.Equal(t, uint16(TCA_U32_ACT), encoder.Uint16(b[42:44]))
+	require.Equal(t, uint16(1), encoder.Uint16(b[46:48]))
+	require.Equal(t, uint16(TCA_ACT_KIND), encoder.Uint16(b[50:52]))
+	require.Equal(t, "mirred\x00", string(b[52:59]))
+	parms := b[len(b)-28:]
+	require.Equal(t, uint32(TC_ACT_STOLEN), encoder.Uint32(parms[8:12]))
+	require.Equal(t, uint32(TCA_EGRESS_REDIR), encoder.Uint32(parms[20:24]))
+	require.Equal(t, uint32(7), encoder.Uint32(parms[24:28]))
+}
+
+func TestErrorSentinels(t *testing.T) {
+	err := newError(unix.EEXIST)
+	require.ErrorIs(t, err, ErrExists)
+	require.ErrorIs(t, err, syscall.EEXIST, "the errno must still match")
+	require.NotErrorIs(t, err, ErrNoSuchDevice)
+	require.Equal(t, "file exists", err.Error())
+
+	require.ErrorIs(t, newError(unix.ESRCH), ErrNotFound)
+	require.ErrorIs(t, newError(unix.EADDRNOTAVAIL), ErrAddressNotAvailable)
+	require.NotErrorIs(t, newError(unix.EPERM), ErrExists)
+
+	nl := NewNetlink()
+	err = nl.SetLinkState("nonexistent0", true)
+	require.ErrorIs(t, err, ErrNoSuchDevice)
+	require.ErrorIs(t, err, syscall.ENODEV)
+}
+
+func TestClassify(t *testing.T) {
+	require.Equal(t, ErrExists, Classify(fmt.Errorf("wrapped: %w", syscall.EEXIST)))
+	require.Equal(t, ErrResourceBusy, Classify(&os.PathError{Op: "open", Err: syscall.EBUSY}))
+	require.Equal(t, ErrNoSuchDevice, Classify(&net.OpError{Op: "route", Err: errors.New(errNoSuchInterfaceMsg)}))
+	require.NoError(t, Classify(errors.New("test error")))
+	require.NoError(t, Classify(syscall.EOPNOTSUPP))
+}
+
+func TestAddIPAddressExists(t *testing.T) {
+	nl := NewNetlink()
+	err := nl.AddLink(&VEthLink{
+		LinkInfo: LinkInfo{Type: LINK_TYPE_VETH, Name: ifName},
+		PeerName: ifName2,
+	})
+	require.NoError(t, err)
+
+	//nolint:errcheck // not testing deletelink here
+	defer nl.DeleteLink(ifName)
+
+	ip, ipNet, _ := net.ParseCIDR("10.240.0.4/24")
+	require.NoError(t, nl.AddIPAddress(ifName, ip, ipNet))
+	require.ErrorIs(t, nl.AddIPAddress(ifName, ip, ipNet), ErrExists)
+	require.NoError(t, nl.DeleteIPAddress(ifName, ip, ipNet))
+	require.ErrorIs(t, nl.DeleteIPAddress(ifName, ip, ipNet), ErrAddressNotAvailable)
+}
+
+func TestDeleteIPRouteNotFound(t *testing.T) {
+	nl := NewNetlink()
+	err := nl.AddLink(&VEthLink{
+		LinkInfo: LinkInfo{Type: LINK_TYPE_VETH, Name: ifName, Flags: net.FlagUp},
+		PeerName: ifName2,
+	})
+	require.NoError(t, err)
+
+	//nolint:errcheck // not testing deletelink here
+	defer nl.DeleteLink(ifName)
+
+	iface, err := net.InterfaceByName(ifName)
+	require.NoError(t, err)
+
+	_, dst, _ := net.ParseCIDR("10.241.0.0/24")
+	route := &Route{Family: unix.AF_INET, Dst: dst, LinkIndex: iface.Index, Scope: RT_SCOPE_LINK}
+	require.NoError(t, nl.AddIPRoute(route))
+	require.ErrorIs(t, nl.AddIPRoute(route), ErrExists)
+	require.NoError(t, nl.DeleteIPRoute(route))
+	require.ErrorIs(t, nl.DeleteIPRoute(route), ErrNotFound)
+}
+
+// inNetNs runs f in a new network namespace, the namespace of the test is restored afterwards.
+func inNetNs(t *testing.T, f func()) {
+	t.Helper()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	require.NoError(t, err)
+	defer orig.Close()
+
+	require.NoError(t, unix.Unshare(unix.CLONE_NEWNET))
+	ResetSocket()
+	defer func() {
+		require.NoError(t, unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET))
+		ResetSocket()
+	}()
+
+	f()
+}
+
+// addTestVeth creates a veth pair which is up.
+func addTestVeth(t *testing.T, nl *Netlink) *net.Interface {
+	t.Helper()
+
+	err := nl.AddLink(&VEthLink{
+		LinkInfo: LinkInfo{Type: LINK_TYPE_VETH, Name: ifName, Flags: net.FlagUp},
+		PeerName: ifName2,
+	})
+	require.NoError(t, err)
+	require.NoError(t, nl.SetLinkState(ifName2, true))
+
+	iface, err := net.InterfaceByName(ifName)
+	require.NoError(t, err)
+
+	return iface
+}
+
+func TestRouteInLargeTable(t *testing.T) {
+	inNetNs(t, func() {
+		nl := NewNetlink()
+		iface := addTestVeth(t, nl)
+
+		const table = 1000
+		_, dst, _ := net.ParseCIDR("10.242.0.0/24")
+		route := &Route{Family: unix.AF_INET, Dst: dst, LinkIndex: iface.Index, Scope: RT_SCOPE_LINK, Table: table}
+		require.NoError(t, nl.AddIPRoute(route))
+
+		routes, err := nl.GetIPRoutesInTable(unix.AF_INET, table)
+		require.NoError(t, err)
+		require.Len(t, routes, 1)
+		require.Equal(t, dst.String(), routes[0].Dst.String())
+
+		main, err := nl.GetIPRoutesInTable(unix.AF_INET, unix.RT_TABLE_MAIN)
+		require.NoError(t, err)
+		for _, r := range main {
+			require.NotEqual(t, dst.String(), r.Dst.String(), "the route must not be added to the main table")
+		}
+
+		require.NoError(t, nl.DeleteIPRoute(route))
+		routes, err = nl.GetIPRoutesInTable(unix.AF_INET, table)
+		require.NoError(t, err)
+		require.Empty(t, routes)
+	})
+}
+
+func TestRouteMetrics(t *testing.T) {
+	metrics := newRouteMetricsAttribute(&Route{MTU: 1400, LockMTU: true}).serialize()
+	require.Equal(t, uint16(unix.RTA_METRICS), encoder.Uint16(metrics[2:4]))
+
+	mtu, locked := deserializeRouteMTU(metrics[unix.SizeofRtAttr:])
+	require.Equal(t, 1400, mtu)
+	require.True(t, locked)
+
+	metrics = newRouteMetricsAttribute(&Route{MTU: 1400}).serialize()
+	mtu, locked = deserializeRouteMTU(metrics[unix.SizeofRtAttr:])
+	require.Equal(t, 1400, mtu)
+	require.False(t, locked)
+}
+
+func TestRouteOnLinkWithMTU(t *testing.T) {
+	inNetNs(t, func() {
+		nl := NewNetlink()
+		iface := addTestVeth(t, nl)
+		ip, ipNet, _ := net.ParseCIDR("10.242.1.4/24")
+		require.NoError(t, nl.AddIPAddress(ifName, ip, ipNet))
+
+		// the gateway is in none of the subnets of the link.
+		_, dst, _ := net.ParseCIDR("10.243.0.0/24")
+		route := &Route{
+			Family:    unix.AF_INET,
+			Dst:       dst,
+			Gw:        net.ParseIP("10.244.0.1"),
+			LinkIndex: iface.Index,
+			Priority:  50,
+			MTU:       1400,
+			LockMTU:   true,
+		}
+		require.Error(t, nl.AddIPRoute(route), "the kernel rejects gateways which are not on link")
+
+		route.Flags = RTNH_F_ONLINK
+		require.NoError(t, nl.AddIPRoute(route))
+
+		routes, err := nl.GetIPRoutesInTable(unix.AF_INET, unix.RT_TABLE_MAIN)
+		require.NoError(t, err)
+		var added *Route
+		for _, r := range routes {
+			if r.Dst != nil && r.Dst.String() == dst.String() {
+				added = r
+			}
+		}
+		require.NotNil(t, added)
+		require.NotZero(t, added.Flags&RTNH_F_ONLINK)
+		require.Equal(t, 50, added.Priority)
+		require.Equal(t, 1400, added.MTU)
+		require.True(t, added.LockMTU)
+
+		require.NoError(t, nl.DeleteIPRoute(route))
+	})
+}
+
+func TestVRF(t *testing.T) {
+	inNetNs(t, func() {
+		nl := NewNetlink()
+
+		const vrfName, table = "vrftest", 1001
+		err := nl.AddLink(&VRFLink{
+			LinkInfo: LinkInfo{Type: LINK_TYPE_VRF, Name: vrfName, Flags: net.FlagUp},
+			Table:    table,
+		})
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("the kernel does not support VRF devices")
+		}
+		require.NoError(t, err)
+
+		iface := addTestVeth(t, nl)
+		require.NoError(t, nl.SetLinkMaster(ifName, vrfName))
+
+		ip, ipNet, _ := net.ParseCIDR("10.243.0.4/24")
+		require.NoError(t, nl.AddIPAddress(ifName, ip, ipNet))
+
+		// the kernel adds the prefix route of the address to the table of the VRF
+		routes, err := nl.GetIPRoutesInTable(unix.AF_INET, table)
+		require.NoError(t, err)
+		found := false
+		for _, r := range routes {
+			if r.Dst != nil && r.Dst.String() == "10.243.0.0/24" && r.LinkIndex == iface.Index {
+				found = true
+			}
+		}
+		require.True(t, found, "prefix route not in VRF table: %+v", routes)
+
+		_, dst, _ := net.ParseCIDR("10.244.0.0/16")
+		route := &Route{Family: unix.AF_INET, Dst: dst, Gw: net.ParseIP("10.243.0.1"), LinkIndex: iface.Index, Table: table}
+		require.NoError(t, nl.AddIPRoute(route))
+		require.NoError(t, nl.DeleteIPRoute(route))
+
+		require.NoError(t, nl.SetLinkMaster(ifName, ""))
+		require.NoError(t, nl.DeleteLink(vrfName))
+	})
+}
+
+func TestGetVlanLink(t *testing.T) {
+	inNetNs(t, func() {
+		nl := NewNetlink()
+		iface := addTestVeth(t, nl)
+
+		link, err := nl.GetLink(ifName)
+		require.NoError(t, err)
+		require.Equal(t, LINK_TYPE_VETH, link.Info().Type)
+		require.Equal(t, ifName, link.Info().Name)
+		require.Equal(t, uint(iface.MTU), link.Info().MTU)
+		require.NotZero(t, link.Info().Flags&net.FlagUp)
+
+		_, err = nl.GetLink("nltest-missing")
+		require.ErrorIs(t, err, ErrNoSuchDevice)
+
+		const vlanName = "nltest.100"
+		err = nl.AddLink(&VlanLink{
+			LinkInfo: LinkInfo{Type: LINK_TYPE_VLAN, Name: vlanName, ParentIndex: iface.Index},
+			VlanID:   100,
+		})
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("the kernel does not support VLAN devices")
+		}
+		require.NoError(t, err)
+
+		link, err = nl.GetLink(vlanName)
+		require.NoError(t, err)
+		vlan, ok := link.(*VlanLink)
+		require.True(t, ok, "unexpected link %+v", link)
+		require.Equal(t, vlanName, vlan.Name)
+		require.Equal(t, LINK_TYPE_VLAN, vlan.Type)
+		require.Equal(t, uint16(100), vlan.VlanID)
+		require.Equal(t, iface.Index, vlan.ParentIndex)
+
+		require.NoError(t, nl.DeleteLink(vlanName))
+	})
+}
+
+func TestBondLink(t *testing.T) {
+	inNetNs(t, func() {
+		nl := NewNetlink()
+
+		const bondName = "bondtest"
+		err := nl.AddLink(&BondLink{
+			LinkInfo: LinkInfo{Type: LINK_TYPE_BOND, Name: bondName},
+			Mode:     BOND_MODE_ACTIVE_BACKUP,
+			Miimon:   100,
+		})
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("the kernel does not support bond devices")
+		}
+		require.NoError(t, err)
+
+		link, err := nl.GetLink(bondName)
+		require.NoError(t, err)
+		bond, ok := link.(*BondLink)
+		require.True(t, ok, "unexpected link %+v", link)
+		require.Equal(t, BOND_MODE_ACTIVE_BACKUP, bond.Mode)
+		require.Equal(t, uint32(100), bond.Miimon)
+
+		addTestVeth(t, nl)
+		require.NoError(t, nl.SetLinkState(ifName, false))
+		require.NoError(t, nl.SetLinkMaster(ifName, bondName))
+		require.NoError(t, nl.SetLinkMaster(ifName, ""))
+
+		require.NoError(t, nl.DeleteLink(bondName))
+	})
+}
+
+func TestVxlanLink(t *testing.T) {
+	inNetNs(t, func() {
+		nl := NewNetlink()
+
+		const vxlanName = "vxlantest"
+		err := nl.AddLink(&VxlanLink{
+			LinkInfo: LinkInfo{Type: LINK_TYPE_VXLAN, Name: vxlanName},
+			VNI:      4096,
+			Local:    net.ParseIP("10.240.0.4"),
+			Port:     4789,
+		})
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("the kernel does not support VXLAN devices")
+		}
+		require.NoError(t, err)
+
+		link, err := nl.GetLink(vxlanName)
+		require.NoError(t, err)
+		vxlan, ok := link.(*VxlanLink)
+		require.True(t, ok, "unexpected link %+v", link)
+		require.Equal(t, uint32(4096), vxlan.VNI)
+		require.True(t, vxlan.Local.Equal(net.ParseIP("10.240.0.4")))
+		require.Equal(t, uint16(4789), vxlan.Port)
+		require.False(t, vxlan.Learning)
+
+		mac, _ := net.ParseMAC("02:00:00:00:00:01")
+		require.NoError(t, nl.AddFDBEntry(vxlanName, mac, net.ParseIP("10.240.0.5")))
+		// adding the entry again replaces it
+		require.NoError(t, nl.AddFDBEntry(vxlanName, mac, net.ParseIP("10.240.0.6")))
+
+		entries, err := nl.ListFDBEntries(vxlanName)
+		require.NoError(t, err)
+		var dsts []string
+		for _, entry := range entries {
+			if entry.HardwareAddr.String() == mac.String() {
+				dsts = append(dsts, entry.IP.String())
+			}
+		}
+		require.Equal(t, []string{"10.240.0.6"}, dsts)
+
+		require.NoError(t, nl.DeleteFDBEntry(vxlanName, mac, net.ParseIP("10.240.0.6")))
+		require.NoError(t, nl.DeleteLink(vxlanName))
+	})
+}
+
+func TestDeserializeLinkStatistics(t *testing.T) {
+	// rtnl_link_stats64 has more counters than the ones decoded, they are ignored
+	b := make([]byte, 24*8)
+	for i := 0; i < 24; i++ {
+		encoder.PutUint64(b[i*8:], uint64(i+1))
+	}
+
+	require.Equal(t, &LinkStatistics{
+		RxPackets: 1,
+		TxPackets: 2,
+		RxBytes:   3,
+		TxBytes:   4,
+		RxErrors:  5,
+		TxErrors:  6,
+		RxDropped: 7,
+		TxDropped: 8,
+	}, deserializeLinkStatistics(b))
+
+	require.Nil(t, deserializeLinkStatistics(b[:sizeofLinkStatistics-1]))
+}
+
+func TestGetLinkStatistics(t *testing.T) {
+	link, err := NewNetlink().GetLink("lo")
+	require.NoError(t, err)
+	require.NotNil(t, link.Info().Statistics, "the kernel reports IFLA_STATS64 for every link")
+}
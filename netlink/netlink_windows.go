@@ -12,6 +12,21 @@ type Link interface {
 
 type Route struct{}
 
+type RouteGetOptions struct{}
+
+type Rule struct{}
+
+type Address struct{}
+
+type Neighbor struct{}
+
+// Qdisc represents a queueing discipline attached to a network interface.
+type Qdisc interface{}
+
+type HtbClass struct{}
+
+type RedirectFilter struct{}
+
 // LinkInfo respresents the common properties of all network interfaces.
 type LinkInfo struct {
 	Type string
@@ -26,6 +41,10 @@ func (Netlink) AddLink(link Link) error {
 	return nil
 }
 
+func (Netlink) GetLink(name string) (Link, error) {
+	return nil, nil
+}
+
 func (Netlink) SetLinkMTU(name string, mtu int) error {
 	return nil
 }
@@ -74,10 +93,40 @@ func (Netlink) DeleteIPAddress(ifName string, ipAddress net.IP, ipNet *net.IPNet
 	return nil
 }
 
+func (Netlink) GetIPAddresses(ifName string) ([]*Address, error) {
+	return nil, nil
+}
+
+func (Netlink) ListNeighbors(ifName string) ([]*Neighbor, error) {
+	return nil, nil
+}
+
+// AddFDBEntry is not supported, there are no VXLAN interfaces on windows.
+func (Netlink) AddFDBEntry(ifName string, mac net.HardwareAddr, dst net.IP) error {
+	return ErrNotSupported
+}
+
+func (Netlink) DeleteFDBEntry(ifName string, mac net.HardwareAddr, dst net.IP) error {
+	return ErrNotSupported
+}
+
+func (Netlink) ListFDBEntries(ifName string) ([]*Neighbor, error) {
+	return nil, ErrNotSupported
+}
+
 func (Netlink) GetIPRoute(filter *Route) ([]*Route, error) {
 	return nil, nil
 }
 
+func (Netlink) GetIPRoutesInTable(family, table int) ([]*Route, error) {
+	return nil, nil
+}
+
+// GetRouteTo is not supported, there is no route lookup through the kernel on windows.
+func (Netlink) GetRouteTo(dst net.IP, options *RouteGetOptions) (*Route, error) {
+	return nil, ErrNotSupported
+}
+
 func (Netlink) AddIPRoute(route *Route) error {
 	return nil
 }
@@ -85,3 +134,32 @@ func (Netlink) AddIPRoute(route *Route) error {
 func (Netlink) DeleteIPRoute(route *Route) error {
 	return nil
 }
+
+// AddIPRule is not supported, there is no policy routing through the kernel on windows.
+func (Netlink) AddIPRule(rule *Rule) error {
+	return ErrNotSupported
+}
+
+func (Netlink) DeleteIPRule(rule *Rule) error {
+	return ErrNotSupported
+}
+
+func (Netlink) GetIPRules(family int) ([]*Rule, error) {
+	return nil, ErrNotSupported
+}
+
+func (Netlink) ReplaceQdisc(qdisc Qdisc) error {
+	return nil
+}
+
+func (Netlink) DeleteQdisc(qdisc Qdisc) error {
+	return nil
+}
+
+func (Netlink) ReplaceClass(class *HtbClass) error {
+	return nil
+}
+
+func (Netlink) ReplaceFilter(filter *RedirectFilter) error {
+	return nil
+}
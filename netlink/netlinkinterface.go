@@ -9,6 +9,7 @@ import (
 
 type NetlinkInterface interface {
 	AddLink(link Link) error
+	GetLink(name string) (Link, error)
 	DeleteLink(name string) error
 	SetLinkName(name string, newName string) error
 	SetLinkState(name string, up bool) error
@@ -21,7 +22,21 @@ type NetlinkInterface interface {
 	SetOrRemoveLinkAddress(linkInfo LinkInfo, mode, linkState int) error
 	AddIPAddress(ifName string, ipAddress net.IP, ipNet *net.IPNet) error
 	DeleteIPAddress(ifName string, ipAddress net.IP, ipNet *net.IPNet) error
+	GetIPAddresses(ifName string) ([]*Address, error)
+	ListNeighbors(ifName string) ([]*Neighbor, error)
+	AddFDBEntry(ifName string, mac net.HardwareAddr, dst net.IP) error
+	DeleteFDBEntry(ifName string, mac net.HardwareAddr, dst net.IP) error
+	ListFDBEntries(ifName string) ([]*Neighbor, error)
 	GetIPRoute(filter *Route) ([]*Route, error)
+	GetIPRoutesInTable(family, table int) ([]*Route, error)
+	GetRouteTo(dst net.IP, options *RouteGetOptions) (*Route, error)
 	AddIPRoute(route *Route) error
 	DeleteIPRoute(route *Route) error
+	AddIPRule(rule *Rule) error
+	DeleteIPRule(rule *Rule) error
+	GetIPRules(family int) ([]*Rule, error)
+	ReplaceQdisc(qdisc Qdisc) error
+	DeleteQdisc(qdisc Qdisc) error
+	ReplaceClass(class *HtbClass) error
+	ReplaceFilter(filter *RedirectFilter) error
 }
@@ -54,9 +54,12 @@ const (
 	IFLA_INFO_DATA   = 2
 	IFLA_NET_NS_FD   = 28
 	IFLA_IPVLAN_MODE = 1
+	IFLA_VLAN_ID     = 1
+	IFLA_VRF_TABLE   = 1
 	IFLA_BRPORT_MODE = 4
 	VETH_INFO_PEER   = 1
 	DEFAULT_CHANGE   = 0xFFFFFFFF
+	NLA_TYPE_MASK    = 0x3FFF
 )
 
 // Serializable types are used to construct netlink messages.
@@ -213,6 +216,13 @@ func newAttributeUint32(attrType int, value uint32) *attribute {
 	return newAttribute(attrType, buf)
 }
 
+// Creates a new attribute with a uint64 value.
+func newAttributeUint64(attrType int, value uint64) *attribute {
+	buf := make([]byte, 8)
+	encoder.PutUint64(buf, value)
+	return newAttribute(attrType, buf)
+}
+
 // Creates a new attribute with a uint16 value.
 func newAttributeUint16(attrType int, value uint16) *attribute {
 	buf := make([]byte, 2)
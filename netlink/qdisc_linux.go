@@ -0,0 +1,458 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Traffic control constants that are not already defined in unix package.
+const (
+	TCA_KIND    = 1
+	TCA_OPTIONS = 2
+
+	TCA_TBF_PARMS  = 1
+	TCA_TBF_RATE64 = 4
+	TCA_TBF_BURST  = 6
+
+	TCA_HTB_PARMS  = 1
+	TCA_HTB_INIT   = 2
+	TCA_HTB_RATE64 = 6
+	TCA_HTB_CEIL64 = 7
+
+	TCA_U32_SEL      = 5
+	TCA_U32_ACT      = 7
+	TCA_ACT_KIND     = 1
+	TCA_ACT_OPTIONS  = 2
+	TCA_MIRRED_PARMS = 2
+
+	TC_H_ROOT             = 0xFFFFFFFF
+	TC_H_INGRESS          = 0xFFFFFFF1
+	TC_LINKLAYER_ETHERNET = 1
+
+	TCA_EGRESS_REDIR = 1
+	TC_ACT_STOLEN    = 4
+	TC_U32_TERMINAL  = 1
+)
+
+// Qdisc types.
+const (
+	QDISC_TYPE_TBF     = "tbf"
+	QDISC_TYPE_HTB     = "htb"
+	QDISC_TYPE_INGRESS = "ingress"
+)
+
+const (
+	filterTypeU32    = "u32"
+	actionTypeMirred = "mirred"
+	// ethPAllBigEndian is ETH_P_ALL in network byte order, filters of this protocol see all packets.
+	ethPAllBigEndian = 0x0300
+	// u32FilterHandle is the handle of the first filter of the default hash table, 800::800.
+	u32FilterHandle = 0x80000800
+)
+
+const (
+	// htbVersion is the major version of the HTB options the kernel accepts.
+	htbVersion = 3
+	// htbRateToQuantum derives the quantum of HTB classes from their rate.
+	htbRateToQuantum = 10
+	// pschedTickShift converts nanoseconds to the scheduler ticks of qdisc options.
+	pschedTickShift = 6
+	// defaultQueueLatency is the time packets may wait for tokens before a TBF qdisc drops them.
+	defaultQueueLatency = 25 * time.Millisecond
+)
+
+// Qdisc represents a queueing discipline attached to a network interface.
+type Qdisc interface {
+	Info() *QdiscInfo
+}
+
+// QdiscInfo represents the common properties of all queueing disciplines.
+type QdiscInfo struct {
+	Type     string
+	LinkName string
+	Handle   uint32
+	Parent   uint32
+}
+
+func (qdiscInfo *QdiscInfo) Info() *QdiscInfo {
+	return qdiscInfo
+}
+
+// TbfQdisc is a token bucket filter shaping the traffic sent by the interface.
+type TbfQdisc struct {
+	QdiscInfo
+	// Rate in bits per second.
+	Rate uint64
+	// Burst is the size of the bucket in bytes.
+	Burst uint32
+	// Limit is the number of bytes queued waiting for tokens, derived from the rate if zero.
+	Limit uint32
+}
+
+// HtbQdisc is a hierarchical token bucket, the traffic is shaped by its classes.
+type HtbQdisc struct {
+	QdiscInfo
+	// DefaultClass receives the traffic not classified by a filter.
+	DefaultClass uint32
+}
+
+// IngressQdisc receives the traffic arriving on the interface so that filters can act on it.
+type IngressQdisc struct {
+	QdiscInfo
+}
+
+// RedirectFilter redirects all the traffic of the qdisc of an interface to the egress of another
+// interface, such as an ifb whose qdisc then shapes the traffic the interface received.
+type RedirectFilter struct {
+	LinkName string
+	Parent   uint32
+	Priority uint16
+	// TargetLinkName is the interface the traffic is redirected to.
+	TargetLinkName string
+}
+
+// HtbClass is a class of an HTB qdisc.
+type HtbClass struct {
+	LinkName string
+	Handle   uint32
+	Parent   uint32
+	// Rate and Ceil in bits per second.
+	Rate uint64
+	Ceil uint64
+	// Burst in bytes.
+	Burst uint32
+}
+
+// MakeHandle returns the traffic control handle of a major and minor number.
+func MakeHandle(major, minor uint16) uint32 {
+	return uint32(major)<<16 | uint32(minor)
+}
+
+// Traffic control message
+type tcMsg struct {
+	Family  uint8
+	Ifindex int32
+	Handle  uint32
+	Parent  uint32
+	Info    uint32
+}
+
+// Serializes a traffic control message.
+func (tc *tcMsg) serialize() []byte {
+	b := make([]byte, tc.length())
+	b[0] = tc.Family
+	encoder.PutUint32(b[4:8], uint32(tc.Ifindex))
+	encoder.PutUint32(b[8:12], tc.Handle)
+	encoder.PutUint32(b[12:16], tc.Parent)
+	encoder.PutUint32(b[16:20], tc.Info)
+	return b
+}
+
+// Returns the length of a traffic control message.
+func (tc *tcMsg) length() int {
+	return 20
+}
+
+// Rate specification of qdisc and class options
+type tcRateSpec struct {
+	CellLog   uint8
+	LinkLayer uint8
+	Overhead  uint16
+	CellAlign int16
+	Mpu       uint16
+	Rate      uint32
+}
+
+const sizeofTcRateSpec = 12
+
+// Creates a rate specification of a rate in bytes per second. Rates above the 32 bit field are
+// passed in a 64 bit attribute.
+func newTcRateSpec(rate uint64) tcRateSpec {
+	spec := tcRateSpec{LinkLayer: TC_LINKLAYER_ETHERNET, Rate: math.MaxUint32}
+	if rate < math.MaxUint32 {
+		spec.Rate = uint32(rate)
+	}
+
+	return spec
+}
+
+func (spec *tcRateSpec) encode(b []byte) {
+	b[0] = spec.CellLog
+	b[1] = spec.LinkLayer
+	encoder.PutUint16(b[2:4], spec.Overhead)
+	encoder.PutUint16(b[4:6], uint16(spec.CellAlign))
+	encoder.PutUint16(b[6:8], spec.Mpu)
+	encoder.PutUint32(b[8:12], spec.Rate)
+}
+
+// Returns the scheduler ticks it takes to send size bytes at a rate in bytes per second.
+func transmitTime(rate uint64, size uint32) uint32 {
+	if rate == 0 {
+		return 0
+	}
+
+	ticks := uint64(size) * 1000 * 1000 * 1000 / rate >> pschedTickShift
+	if ticks > math.MaxUint32 {
+		return math.MaxUint32
+	}
+
+	return uint32(ticks)
+}
+
+// Returns the qdisc options attribute of a TBF qdisc.
+func (tbf *TbfQdisc) options() (*attribute, error) {
+	rate := tbf.Rate / 8
+	if rate == 0 || tbf.Burst == 0 {
+		return nil, fmt.Errorf("Invalid TBF rate %v or burst %v", tbf.Rate, tbf.Burst)
+	}
+
+	limit := tbf.Limit
+	if limit == 0 {
+		limit = uint32(rate*uint64(defaultQueueLatency.Milliseconds())/1000) + tbf.Burst
+	}
+
+	// struct tc_tbf_qopt { rate, peakrate tc_ratespec; limit, buffer, mtu u32 }
+	parms := make([]byte, 2*sizeofTcRateSpec+12)
+	rateSpec := newTcRateSpec(rate)
+	rateSpec.encode(parms[0:sizeofTcRateSpec])
+	encoder.PutUint32(parms[24:28], limit)
+	encoder.PutUint32(parms[28:32], transmitTime(rate, tbf.Burst))
+
+	attrOptions := newAttribute(TCA_OPTIONS, nil)
+	attrOptions.addNested(newAttribute(TCA_TBF_PARMS, parms))
+	attrOptions.addNested(newAttributeUint32(TCA_TBF_BURST, tbf.Burst))
+	if rate >= math.MaxUint32 {
+		attrOptions.addNested(newAttributeUint64(TCA_TBF_RATE64, rate))
+	}
+
+	return attrOptions, nil
+}
+
+// Returns the qdisc options attribute of an HTB qdisc.
+func (htb *HtbQdisc) options() *attribute {
+	// struct tc_htb_glob { version, rate2quantum, defcls, debug, direct_pkts u32 }
+	glob := make([]byte, 20)
+	encoder.PutUint32(glob[0:4], htbVersion)
+	encoder.PutUint32(glob[4:8], htbRateToQuantum)
+	encoder.PutUint32(glob[8:12], htb.DefaultClass)
+
+	attrOptions := newAttribute(TCA_OPTIONS, nil)
+	attrOptions.addNested(newAttribute(TCA_HTB_INIT, glob))
+
+	return attrOptions
+}
+
+// Returns the class options attribute of an HTB class.
+func (class *HtbClass) options() (*attribute, error) {
+	rate := class.Rate / 8
+	ceil := class.Ceil / 8
+	if ceil == 0 {
+		ceil = rate
+	}
+
+	if rate == 0 || class.Burst == 0 {
+		return nil, fmt.Errorf("Invalid HTB class rate %v or burst %v", class.Rate, class.Burst)
+	}
+
+	// struct tc_htb_opt { rate, ceil tc_ratespec; buffer, cbuffer, quantum, level, prio u32 }
+	parms := make([]byte, 2*sizeofTcRateSpec+20)
+	rateSpec := newTcRateSpec(rate)
+	rateSpec.encode(parms[0:sizeofTcRateSpec])
+	ceilSpec := newTcRateSpec(ceil)
+	ceilSpec.encode(parms[sizeofTcRateSpec : 2*sizeofTcRateSpec])
+	encoder.PutUint32(parms[24:28], transmitTime(rate, class.Burst))
+	encoder.PutUint32(parms[28:32], transmitTime(ceil, class.Burst))
+
+	attrOptions := newAttribute(TCA_OPTIONS, nil)
+	attrOptions.addNested(newAttribute(TCA_HTB_PARMS, parms))
+	if rate >= math.MaxUint32 {
+		attrOptions.addNested(newAttributeUint64(TCA_HTB_RATE64, rate))
+	}
+	if ceil >= math.MaxUint32 {
+		attrOptions.addNested(newAttributeUint64(TCA_HTB_CEIL64, ceil))
+	}
+
+	return attrOptions, nil
+}
+
+// Returns the filter options attribute of a u32 filter matching all packets with a mirred action
+// redirecting to the interface of the index. u32 is used over matchall since it is available on all kernels.
+func (f *RedirectFilter) options(targetIndex int) *attribute {
+	// struct tc_u32_sel { flags, offshift, nkeys u8; offmask, off u16; offoff, hoff s16; hmask u32 } with
+	// a single struct tc_u32_key { mask, val u32; off, offmask s32 } of zeros, matching every packet
+	sel := make([]byte, 32)
+	sel[0] = TC_U32_TERMINAL
+	sel[2] = 1
+
+	// struct tc_mirred { index, capab u32; action, refcnt, bindcnt, eaction int32; ifindex u32 }
+	parms := make([]byte, 28)
+	encoder.PutUint32(parms[8:12], TC_ACT_STOLEN)
+	encoder.PutUint32(parms[20:24], TCA_EGRESS_REDIR)
+	encoder.PutUint32(parms[24:28], uint32(targetIndex))
+
+	attrMirredOptions := newAttribute(TCA_ACT_OPTIONS, nil)
+	attrMirredOptions.addNested(newAttribute(TCA_MIRRED_PARMS, parms))
+
+	// actions are nested by their order
+	attrAction := newAttribute(1, nil)
+	attrAction.addNested(newAttributeStringZ(TCA_ACT_KIND, actionTypeMirred))
+	attrAction.addNested(attrMirredOptions)
+
+	attrActions := newAttribute(TCA_U32_ACT, nil)
+	attrActions.addNested(attrAction)
+
+	attrOptions := newAttribute(TCA_OPTIONS, nil)
+	attrOptions.addNested(newAttribute(TCA_U32_SEL, sel))
+	attrOptions.addNested(attrActions)
+
+	return attrOptions
+}
+
+// ReplaceQdisc adds a qdisc to a network interface, replacing the qdisc of the same parent.
+func (Netlink) ReplaceQdisc(qdisc Qdisc) error {
+	info := qdisc.Info()
+
+	iface, err := interfaceByName(info.LinkName)
+	if err != nil {
+		return err
+	}
+
+	var attrOptions *attribute
+	switch q := qdisc.(type) {
+	case *TbfQdisc:
+		if attrOptions, err = q.options(); err != nil {
+			return err
+		}
+		info.Type = QDISC_TYPE_TBF
+	case *HtbQdisc:
+		attrOptions = q.options()
+		info.Type = QDISC_TYPE_HTB
+	case *IngressQdisc:
+		info.Type = QDISC_TYPE_INGRESS
+	default:
+		return fmt.Errorf("Unsupported qdisc type %T", qdisc)
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	req := newRequest(unix.RTM_NEWQDISC, unix.NLM_F_CREATE|unix.NLM_F_REPLACE|unix.NLM_F_ACK)
+
+	tc := &tcMsg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: int32(iface.Index),
+		Handle:  info.Handle,
+		Parent:  info.Parent,
+	}
+	req.addPayload(tc)
+	req.addPayload(newAttributeStringZ(TCA_KIND, info.Type))
+	if attrOptions != nil {
+		req.addPayload(attrOptions)
+	}
+
+	return s.sendAndWaitForAck(req)
+}
+
+// DeleteQdisc deletes a qdisc from a network interface.
+func (Netlink) DeleteQdisc(qdisc Qdisc) error {
+	info := qdisc.Info()
+
+	iface, err := interfaceByName(info.LinkName)
+	if err != nil {
+		return err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	req := newRequest(unix.RTM_DELQDISC, unix.NLM_F_ACK)
+
+	tc := &tcMsg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: int32(iface.Index),
+		Handle:  info.Handle,
+		Parent:  info.Parent,
+	}
+	req.addPayload(tc)
+
+	return s.sendAndWaitForAck(req)
+}
+
+// ReplaceClass adds a class to the HTB qdisc of a network interface, replacing the class of the same handle.
+func (Netlink) ReplaceClass(class *HtbClass) error {
+	iface, err := interfaceByName(class.LinkName)
+	if err != nil {
+		return err
+	}
+
+	attrOptions, err := class.options()
+	if err != nil {
+		return err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	req := newRequest(unix.RTM_NEWTCLASS, unix.NLM_F_CREATE|unix.NLM_F_REPLACE|unix.NLM_F_ACK)
+
+	tc := &tcMsg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: int32(iface.Index),
+		Handle:  class.Handle,
+		Parent:  class.Parent,
+	}
+	req.addPayload(tc)
+	req.addPayload(newAttributeStringZ(TCA_KIND, QDISC_TYPE_HTB))
+	req.addPayload(attrOptions)
+
+	return s.sendAndWaitForAck(req)
+}
+
+// ReplaceFilter adds a filter redirecting the traffic of a qdisc of a network interface, replacing the
+// filter of the same priority.
+func (Netlink) ReplaceFilter(filter *RedirectFilter) error {
+	iface, err := interfaceByName(filter.LinkName)
+	if err != nil {
+		return err
+	}
+
+	target, err := interfaceByName(filter.TargetLinkName)
+	if err != nil {
+		return err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	req := newRequest(unix.RTM_NEWTFILTER, unix.NLM_F_CREATE|unix.NLM_F_REPLACE|unix.NLM_F_ACK)
+
+	tc := &tcMsg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: int32(iface.Index),
+		Handle:  u32FilterHandle,
+		Parent:  filter.Parent,
+		Info:    uint32(filter.Priority)<<16 | ethPAllBigEndian,
+	}
+	req.addPayload(tc)
+	req.addPayload(newAttributeStringZ(TCA_KIND, filterTypeU32))
+	req.addPayload(filter.options(target.Index))
+
+	return s.sendAndWaitForAck(req)
+}
@@ -0,0 +1,144 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// Rule is a policy routing rule, like "ip rule add from <Src> lookup <Table> priority <Priority>".
+type Rule struct {
+	Family int
+	// Src and Dst match the source and destination prefix of the packets, nil for any.
+	Src *net.IPNet
+	Dst *net.IPNet
+	// Mark matches the firewall mark of the packets, 0 for any.
+	Mark uint32
+	// Table is the routing table the matching packets are looked up in.
+	Table int
+	// Priority orders the rules, lower first. 0 lets the kernel pick one for a new rule.
+	Priority int
+}
+
+// newRuleMsg returns the fib_rule_hdr of a rule, which has the layout of a route message.
+func newRuleMsg(rule *Rule) *rtMsg {
+	msg := &rtMsg{
+		RtMsg: unix.RtMsg{
+			Family: uint8(rule.Family),
+			Table:  unix.RT_TABLE_UNSPEC,
+			// the action of the rule is at the offset of the route type
+			Type: unix.FR_ACT_TO_TBL,
+		},
+	}
+	if rule.Table < 256 {
+		msg.Table = uint8(rule.Table)
+	}
+	return msg
+}
+
+// setIPRule sends an IP rule set request.
+func setIPRule(rule *Rule, add bool) error {
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	msgType, flags := unix.RTM_DELRULE, unix.NLM_F_ACK
+	if add {
+		msgType = unix.RTM_NEWRULE
+		flags = unix.NLM_F_CREATE | unix.NLM_F_EXCL | unix.NLM_F_ACK
+	}
+
+	req := newRequest(msgType, flags)
+	msg := newRuleMsg(rule)
+	req.addPayload(msg)
+
+	if rule.Src != nil {
+		prefixLength, _ := rule.Src.Mask.Size()
+		msg.Src_len = uint8(prefixLength)
+		req.addPayload(newAttribute(unix.FRA_SRC, ipAddressValue(rule.Src.IP, rule.Family)))
+	}
+
+	if rule.Dst != nil {
+		prefixLength, _ := rule.Dst.Mask.Size()
+		msg.Dst_len = uint8(prefixLength)
+		req.addPayload(newAttribute(unix.FRA_DST, ipAddressValue(rule.Dst.IP, rule.Family)))
+	}
+
+	if rule.Mark != 0 {
+		req.addPayload(newAttributeUint32(unix.FRA_FWMARK, rule.Mark))
+	}
+
+	req.addPayload(newAttributeUint32(unix.FRA_TABLE, uint32(rule.Table)))
+
+	if rule.Priority != 0 {
+		req.addPayload(newAttributeUint32(unix.FRA_PRIORITY, uint32(rule.Priority)))
+	}
+
+	return s.sendAndWaitForAck(req)
+}
+
+// AddIPRule adds a policy routing rule.
+func (Netlink) AddIPRule(rule *Rule) error {
+	return setIPRule(rule, true)
+}
+
+// DeleteIPRule deletes a policy routing rule. The rule is matched by its selectors, table and priority.
+func (Netlink) DeleteIPRule(rule *Rule) error {
+	return setIPRule(rule, false)
+}
+
+// deserializeRule decodes a netlink message into a Rule struct.
+func deserializeRule(msg *message) *Rule {
+	hdr := deserializeRtMsg(msg.data)
+	rule := Rule{
+		Family: int(hdr.Family),
+		Table:  int(hdr.Table),
+	}
+
+	for _, attr := range msg.getAttributes(hdr) {
+		switch attr.Type {
+		case unix.FRA_SRC:
+			rule.Src = &net.IPNet{IP: net.IP(attr.value), Mask: net.CIDRMask(int(hdr.Src_len), 8*len(attr.value))}
+		case unix.FRA_DST:
+			rule.Dst = &net.IPNet{IP: net.IP(attr.value), Mask: net.CIDRMask(int(hdr.Dst_len), 8*len(attr.value))}
+		case unix.FRA_FWMARK:
+			rule.Mark = encoder.Uint32(attr.value[0:4])
+		case unix.FRA_TABLE:
+			rule.Table = int(encoder.Uint32(attr.value[0:4]))
+		case unix.FRA_PRIORITY:
+			rule.Priority = int(encoder.Uint32(attr.value[0:4]))
+		}
+	}
+
+	return &rule
+}
+
+// GetIPRules returns the policy routing rules of the address family, like "ip rule show".
+func (Netlink) GetIPRules(family int) ([]*Rule, error) {
+	s, err := getSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	req := newRequest(unix.RTM_GETRULE, unix.NLM_F_DUMP)
+	req.addPayload(newRuleMsg(&Rule{Family: family}))
+
+	msgs, err := s.sendAndWaitForResponse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+	for _, msg := range msgs {
+		if msg.Type != unix.RTM_NEWRULE || len(msg.data) < unix.SizeofRtMsg {
+			continue
+		}
+		rules = append(rules, deserializeRule(msg))
+	}
+
+	return rules, nil
+}
@@ -45,11 +45,19 @@ func getSocket() (*socket, error) {
 	return s, err
 }
 
-// ResetSocket deletes the default netlink socket.
+// ResetSocket deletes the default netlink socket. The socket is closed so that the socket created
+// when the namespace it was bound to is entered again can reuse its port ID.
 func ResetSocket() {
 	m.Lock()
 	defer m.Unlock()
 
+	if s != nil {
+		// wait for a request in progress
+		s.Lock()
+		s.close()
+		s.Unlock()
+	}
+
 	s = nil
 }
 
@@ -170,7 +178,7 @@ func (s *socket) receiveResponse(sent *message) ([]*message, error) {
 				if errCode == 0 {
 					log.Debugf("[netlink] Received %+v, ack\n", msg)
 				} else {
-					err = syscall.Errno(-errCode)
+					err = newError(syscall.Errno(-errCode))
 					log.Printf("[netlink] Received %+v, err=%v\n", msg, err)
 				}
 				return nil, err
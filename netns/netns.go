@@ -4,12 +4,32 @@
 package netns
 
 import (
+	"runtime"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/pkg/errors"
 	"github.com/vishvananda/netns"
 )
 
+// Interface is the set of network namespace operations. Namespaces are referenced by open file
+// descriptors, which must be released with Close.
+type Interface interface {
+	Get() (fileDescriptor int, err error)
+	GetFromName(name string) (fileDescriptor int, err error)
+	GetFromPath(path string) (fileDescriptor int, err error)
+	GetFromPid(pid int) (fileDescriptor int, err error)
+	Set(fileDescriptor int) (err error)
+	NewNamed(name string) (fileDescriptor int, err error)
+	DeleteNamed(name string) (err error)
+	Close(fileDescriptor int) (err error)
+	ExecuteInNS(fileDescriptor int, f func() error) (err error)
+}
+
 type Netns struct{}
 
+var _ Interface = (*Netns)(nil)
+
 func New() *Netns {
 	return &Netns{}
 }
@@ -24,6 +44,16 @@ func (f *Netns) GetFromName(name string) (int, error) {
 	return int(nsHandle), errors.Wrap(err, "netns impl")
 }
 
+func (f *Netns) GetFromPath(path string) (int, error) {
+	nsHandle, err := netns.GetFromPath(path)
+	return int(nsHandle), errors.Wrap(err, "netns impl")
+}
+
+func (f *Netns) GetFromPid(pid int) (int, error) {
+	nsHandle, err := netns.GetFromPid(pid)
+	return int(nsHandle), errors.Wrap(err, "netns impl")
+}
+
 func (f *Netns) Set(fileDescriptor int) error {
 	return errors.Wrap(netns.Set(netns.NsHandle(fileDescriptor)), "netns impl")
 }
@@ -36,3 +66,41 @@ func (f *Netns) NewNamed(name string) (int, error) {
 func (f *Netns) DeleteNamed(name string) error {
 	return errors.Wrap(netns.DeleteNamed(name), "netns impl")
 }
+
+func (f *Netns) Close(fileDescriptor int) error {
+	nsHandle := netns.NsHandle(fileDescriptor)
+	return errors.Wrap(nsHandle.Close(), "netns impl")
+}
+
+// ExecuteInNS runs f on the calling goroutine with its thread in the namespace, and returns the thread
+// to its namespace afterwards.
+func (f *Netns) ExecuteInNS(fileDescriptor int, fn func() error) error {
+	runtime.LockOSThread()
+
+	orig, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return errors.Wrap(err, "netns impl: failed to get current namespace")
+	}
+	defer orig.Close()
+
+	if err = netns.Set(netns.NsHandle(fileDescriptor)); err != nil {
+		runtime.UnlockOSThread()
+		return errors.Wrap(err, "netns impl: failed to enter namespace")
+	}
+	// Recycle the netlink socket for the new network namespace.
+	netlink.ResetSocket()
+
+	defer func() {
+		if err := netns.Set(orig); err != nil {
+			// Leave the thread locked so that it exits with the goroutine instead of being reused in
+			// the wrong namespace.
+			log.Errorf("[netns] Failed to return to namespace %v: %v", orig, err)
+			return
+		}
+		netlink.ResetSocket()
+		runtime.UnlockOSThread()
+	}()
+
+	return fn()
+}
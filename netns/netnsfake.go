@@ -0,0 +1,177 @@
+//go:build linux
+// +build linux
+
+package netns
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrFakeNetns is returned by a Fake for invalid namespace handles.
+var ErrFakeNetns = errors.New("fake netns error")
+
+const fakeHostNamespace = "host"
+
+// Fake is an in-memory Interface for unit tests. It keeps track of the named namespaces, the namespace
+// the caller is in and the open handles, so that tests can check that every handle is closed.
+type Fake struct {
+	mu      sync.Mutex
+	current string
+	named   map[string]bool
+	handles map[int]string
+	next    int
+}
+
+var _ Interface = (*Fake)(nil)
+
+// NewFake returns a Fake with the caller in the host namespace.
+func NewFake() *Fake {
+	return &Fake{
+		current: fakeHostNamespace,
+		named:   make(map[string]bool),
+		handles: make(map[int]string),
+		next:    1,
+	}
+}
+
+// AddNamed adds a named namespace, as if it had been created by another process.
+func (f *Fake) AddNamed(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.named[name] = true
+}
+
+// HasNamed reports whether the named namespace exists.
+func (f *Fake) HasNamed(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.named[name]
+}
+
+// Current returns the namespace the caller is in, "host" or the name, path or pid it was opened by.
+func (f *Fake) Current() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.current
+}
+
+// OpenHandles returns the number of handles which have not been closed.
+func (f *Fake) OpenHandles() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.handles)
+}
+
+func (f *Fake) open(ns string) int {
+	fd := f.next
+	f.next++
+	f.handles[fd] = ns
+	return fd
+}
+
+func (f *Fake) lookup(fileDescriptor int) (string, error) {
+	ns, ok := f.handles[fileDescriptor]
+	if !ok {
+		return "", errors.Wrapf(ErrFakeNetns, "invalid handle %d", fileDescriptor)
+	}
+	return ns, nil
+}
+
+func (f *Fake) Get() (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.open(f.current), nil
+}
+
+func (f *Fake) GetFromName(name string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.named[name] {
+		return 0, errors.Wrapf(os.ErrNotExist, "namespace %s", name)
+	}
+	return f.open(name), nil
+}
+
+func (f *Fake) GetFromPath(path string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.open(path), nil
+}
+
+func (f *Fake) GetFromPid(pid int) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.open(fmt.Sprintf("pid:%d", pid)), nil
+}
+
+func (f *Fake) Set(fileDescriptor int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ns, err := f.lookup(fileDescriptor)
+	if err != nil {
+		return err
+	}
+	f.current = ns
+	return nil
+}
+
+// NewNamed creates the named namespace and enters it, like the real implementation.
+func (f *Fake) NewNamed(name string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.named[name] {
+		return 0, errors.Wrapf(os.ErrExist, "namespace %s", name)
+	}
+	f.named[name] = true
+	f.current = name
+	return f.open(name), nil
+}
+
+func (f *Fake) DeleteNamed(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.named[name] {
+		return errors.Wrapf(os.ErrNotExist, "namespace %s", name)
+	}
+	delete(f.named, name)
+	return nil
+}
+
+func (f *Fake) Close(fileDescriptor int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.lookup(fileDescriptor); err != nil {
+		return err
+	}
+	delete(f.handles, fileDescriptor)
+	return nil
+}
+
+func (f *Fake) ExecuteInNS(fileDescriptor int, fn func() error) error {
+	f.mu.Lock()
+	ns, err := f.lookup(fileDescriptor)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	prev := f.current
+	f.current = ns
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.current = prev
+		f.mu.Unlock()
+	}()
+
+	return fn()
+}
@@ -20,8 +20,31 @@ var (
 	errMultipleEndpointsFound = fmt.Errorf("Multiple endpoints found")
 	errEndpointInUse          = fmt.Errorf("Endpoint is already joined to a sandbox")
 	errEndpointNotInUse       = fmt.Errorf("Endpoint is not joined to a sandbox")
+	errInterfaceNotFound      = fmt.Errorf("External interface not found")
+	errVlanIDInvalid          = fmt.Errorf("VLAN ID is invalid")
+	errVlanInterfaceMismatch  = fmt.Errorf("Existing interface is not the expected VLAN sub-interface")
+	errEndpointDiverged       = fmt.Errorf("Endpoint does not match its state")
+	errEndpointNotRepairable  = fmt.Errorf("Endpoint can't be repaired in place")
+	errInterfaceRemoved       = &interfaceRemovedError{}
+	errSecondaryIPInvalid     = fmt.Errorf("Secondary IP configuration is invalid")
+	errSecondaryIPDiverged    = fmt.Errorf("Secondary IP configuration is not programmed")
+	errPodRouteInvalid        = fmt.Errorf("Pod route is invalid")
 )
 
+// interfaceRemovedError is returned for a network whose external interface was detached from the host.
+type interfaceRemovedError struct {
+	ifName string
+}
+
+func (e *interfaceRemovedError) Error() string {
+	return fmt.Sprintf("External interface %s was removed from the host", e.ifName)
+}
+
+func (e *interfaceRemovedError) Is(target error) bool {
+	_, ok := target.(*interfaceRemovedError)
+	return ok
+}
+
 type networkNotFoundError struct{}
 
 func (n *networkNotFoundError) Error() string {
@@ -31,3 +54,33 @@ func (n *networkNotFoundError) Error() string {
 func IsNetworkNotFoundError(err error) bool {
 	return errors.Is(err, errNetworkNotFound)
 }
+
+// IsInterfaceRemovedError returns true if the error reports a network whose external interface was detached
+// from the host, e.g. a NIC hot-removed from the VM.
+func IsInterfaceRemovedError(err error) bool {
+	return errors.Is(err, errInterfaceRemoved)
+}
+
+// IsEndpointDivergedError returns true if the error reports an endpoint whose interfaces, addresses,
+// routes or neighbor entries no longer match the state store record.
+func IsEndpointDivergedError(err error) bool {
+	return errors.Is(err, errEndpointDiverged)
+}
+
+// IsEndpointNotRepairableError returns true if the error reports an endpoint whose namespace or interfaces are
+// gone, which only deleting the endpoint and adding it again fixes.
+func IsEndpointNotRepairableError(err error) bool {
+	return errors.Is(err, errEndpointNotRepairable)
+}
+
+// IsPodRouteInvalidError returns true if the error reports a route requested for the pod which cannot be
+// programmed on its endpoint.
+func IsPodRouteInvalidError(err error) bool {
+	return errors.Is(err, errPodRouteInvalid)
+}
+
+// IsSecondaryIPDivergedError returns true if the error reports secondary IP addresses, routes or rules
+// which are missing from a delegated NIC.
+func IsSecondaryIPDivergedError(err error) bool {
+	return errors.Is(err, errSecondaryIPDiverged)
+}
@@ -0,0 +1,122 @@
+package network
+
+import (
+	"errors"
+	"math"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+)
+
+const (
+	// defaultBurstDivisor sizes the burst of limits without one to the traffic sent in 100ms.
+	defaultBurstDivisor = 10
+	// minBandwidthBurst keeps the bucket larger than a few full sized packets.
+	minBandwidthBurst = 64 * 1024
+	// bandwidthIfbPrefix names the ifb shaping the egress of an endpoint after its host interface.
+	bandwidthIfbPrefix = "ifb"
+	maxIfNameLen       = 15
+	ingressQdiscMajor  = 0xffff
+)
+
+// bandwidthIfbName returns the name of the ifb of the host interface, keeping the end of the host
+// interface name which tells endpoints apart.
+func bandwidthIfbName(hostIfName string) string {
+	if n := maxIfNameLen - len(bandwidthIfbPrefix); len(hostIfName) > n {
+		hostIfName = hostIfName[len(hostIfName)-n:]
+	}
+
+	return bandwidthIfbPrefix + hostIfName
+}
+
+// setBandwidthLimit shapes the traffic sent by the interface to a rate in bits per second with an HTB
+// qdisc at its root whose default class holds the limit. Burst is in bits, zero rates are not limited.
+func setBandwidthLimit(nl netlink.NetlinkInterface, ifName string, rate, burst uint64) error {
+	burstBytes := burst / 8
+	if burstBytes == 0 {
+		burstBytes = rate / 8 / defaultBurstDivisor
+	}
+	if burstBytes < minBandwidthBurst {
+		burstBytes = minBandwidthBurst
+	}
+	if burstBytes > math.MaxUint32 {
+		burstBytes = math.MaxUint32
+	}
+
+	log.Printf("[net] Limiting bandwidth of %v to %v bits/s, burst %v bytes.", ifName, rate, burstBytes)
+	if err := nl.ReplaceQdisc(&netlink.HtbQdisc{
+		QdiscInfo: netlink.QdiscInfo{
+			LinkName: ifName,
+			Handle:   netlink.MakeHandle(1, 0),
+			Parent:   netlink.TC_H_ROOT,
+		},
+		DefaultClass: 1,
+	}); err != nil {
+		return err
+	}
+
+	return nl.ReplaceClass(&netlink.HtbClass{
+		LinkName: ifName,
+		Handle:   netlink.MakeHandle(1, 1),
+		Parent:   netlink.MakeHandle(1, 0),
+		Rate:     rate,
+		Ceil:     rate,
+		Burst:    uint32(burstBytes),
+	})
+}
+
+// setBandwidthLimits limits the traffic of the endpoint on its host side interface, where the pod can't
+// change the qdiscs. The traffic received by the endpoint is sent by the host interface and shaped there.
+// The traffic sent by the endpoint is received by the host interface and redirected to an ifb, which
+// shapes it when sending it on. Namespace: the one of hostIfName.
+func setBandwidthLimits(nl netlink.NetlinkInterface, hostIfName string, limits BandwidthLimits) error {
+	if limits.IngressRate != 0 {
+		if err := setBandwidthLimit(nl, hostIfName, limits.IngressRate, limits.IngressBurst); err != nil {
+			return err
+		}
+	}
+
+	if limits.EgressRate == 0 {
+		return nil
+	}
+
+	ifbName := bandwidthIfbName(hostIfName)
+	log.Printf("[net] Redirecting traffic received by %v to %v.", hostIfName, ifbName)
+	err := nl.AddLink(&netlink.LinkInfo{
+		Type:  netlink.LINK_TYPE_IFB,
+		Name:  ifbName,
+		Flags: net.FlagUp,
+	})
+	if err != nil && !errors.Is(err, netlink.ErrExists) {
+		return err
+	}
+
+	ingress := netlink.MakeHandle(ingressQdiscMajor, 0)
+	if err := nl.ReplaceQdisc(&netlink.IngressQdisc{
+		QdiscInfo: netlink.QdiscInfo{
+			LinkName: hostIfName,
+			Handle:   ingress,
+			Parent:   netlink.TC_H_INGRESS,
+		},
+	}); err != nil {
+		return err
+	}
+
+	if err := nl.ReplaceFilter(&netlink.RedirectFilter{
+		LinkName:       hostIfName,
+		Parent:         ingress,
+		Priority:       1,
+		TargetLinkName: ifbName,
+	}); err != nil {
+		return err
+	}
+
+	return setBandwidthLimit(nl, ifbName, limits.EgressRate, limits.EgressBurst)
+}
+
+// deleteBandwidthLimits deletes the ifb of the endpoint if there is one, the qdiscs of the host interface
+// go away with the interface. Namespace: the one of hostIfName.
+func deleteBandwidthLimits(nl netlink.NetlinkInterface, hostIfName string) error {
+	return nl.DeleteLink(bandwidthIfbName(hostIfName))
+}
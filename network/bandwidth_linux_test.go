@@ -0,0 +1,95 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/stretchr/testify/require"
+)
+
+// tcRecorder records the links, qdiscs, classes and filters programmed through it.
+type tcRecorder struct {
+	*netlink.MockNetlink
+	links   []netlink.Link
+	qdiscs  []netlink.Qdisc
+	classes []*netlink.HtbClass
+	filters []*netlink.RedirectFilter
+}
+
+func newTCRecorder() *tcRecorder {
+	return &tcRecorder{MockNetlink: netlink.NewMockNetlink(false, "")}
+}
+
+func (r *tcRecorder) AddLink(link netlink.Link) error {
+	r.links = append(r.links, link)
+	return nil
+}
+
+func (r *tcRecorder) ReplaceQdisc(qdisc netlink.Qdisc) error {
+	r.qdiscs = append(r.qdiscs, qdisc)
+	return nil
+}
+
+func (r *tcRecorder) ReplaceClass(class *netlink.HtbClass) error {
+	r.classes = append(r.classes, class)
+	return nil
+}
+
+func (r *tcRecorder) ReplaceFilter(filter *netlink.RedirectFilter) error {
+	r.filters = append(r.filters, filter)
+	return nil
+}
+
+func TestSetBandwidthLimits(t *testing.T) {
+	// the failing netlink fails the test if anything is programmed
+	failing := netlink.NewMockNetlink(true, "")
+	require.NoError(t, setBandwidthLimits(failing, "azv1", BandwidthLimits{}))
+	require.Error(t, setBandwidthLimits(failing, "azv1", BandwidthLimits{IngressRate: 1000000}))
+
+	// traffic received by the endpoint is shaped by the host interface
+	nl := newTCRecorder()
+	require.NoError(t, setBandwidthLimits(nl, "azv1", BandwidthLimits{IngressRate: 1000000}))
+	require.Empty(t, nl.links)
+	require.Equal(t, []netlink.Qdisc{&netlink.HtbQdisc{
+		QdiscInfo:    netlink.QdiscInfo{LinkName: "azv1", Handle: netlink.MakeHandle(1, 0), Parent: netlink.TC_H_ROOT},
+		DefaultClass: 1,
+	}}, nl.qdiscs)
+	require.Equal(t, []*netlink.HtbClass{{
+		LinkName: "azv1",
+		Handle:   netlink.MakeHandle(1, 1),
+		Parent:   netlink.MakeHandle(1, 0),
+		Rate:     1000000,
+		Ceil:     1000000,
+		Burst:    minBandwidthBurst,
+	}}, nl.classes)
+
+	// traffic sent by the endpoint is redirected to an ifb on the host and shaped there
+	nl = newTCRecorder()
+	require.NoError(t, setBandwidthLimits(nl, "azv1", BandwidthLimits{EgressRate: 80000000, EgressBurst: 8 * 1024 * 1024}))
+	require.Len(t, nl.links, 1)
+	require.Equal(t, netlink.LINK_TYPE_IFB, nl.links[0].Info().Type)
+	require.Equal(t, "ifbazv1", nl.links[0].Info().Name)
+	require.Equal(t, []netlink.Qdisc{
+		&netlink.IngressQdisc{QdiscInfo: netlink.QdiscInfo{LinkName: "azv1", Handle: netlink.MakeHandle(0xffff, 0), Parent: netlink.TC_H_INGRESS}},
+		&netlink.HtbQdisc{
+			QdiscInfo:    netlink.QdiscInfo{LinkName: "ifbazv1", Handle: netlink.MakeHandle(1, 0), Parent: netlink.TC_H_ROOT},
+			DefaultClass: 1,
+		},
+	}, nl.qdiscs)
+	require.Equal(t, []*netlink.RedirectFilter{{
+		LinkName:       "azv1",
+		Parent:         netlink.MakeHandle(0xffff, 0),
+		Priority:       1,
+		TargetLinkName: "ifbazv1",
+	}}, nl.filters)
+	require.Len(t, nl.classes, 1)
+	require.Equal(t, "ifbazv1", nl.classes[0].LinkName)
+	require.Equal(t, uint64(80000000), nl.classes[0].Rate)
+	require.Equal(t, uint32(1024*1024), nl.classes[0].Burst, "burst is converted to bytes")
+}
+
+func TestBandwidthIfbName(t *testing.T) {
+	require.Equal(t, "ifbazv1", bandwidthIfbName("azv1"))
+	require.Equal(t, "ifbc0ffee012345", bandwidthIfbName("azvc0ffee012345"))
+	require.LessOrEqual(t, len(bandwidthIfbName("azvc0ffee0123456789")), maxIfNameLen)
+}
@@ -104,7 +104,7 @@ func (client *LinuxBridgeEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 				MacAddress: client.containerMac,
 			}
 
-			if err := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT); err != nil {
+			if err := addNeighbor(client.netlink, linkInfo, netlink.NUD_PERMANENT); err != nil {
 				log.Printf("Failed setting arp in vm: %v", err)
 			}
 		}
@@ -118,7 +118,7 @@ func (client *LinuxBridgeEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 		return err
 	}
 
-	return nil
+	return setBandwidthLimits(client.netlink, client.hostVethName, epInfo.BandwidthLimits)
 }
 
 func (client *LinuxBridgeEndpointClient) DeleteEndpointRules(ep *endpoint) {
@@ -174,7 +174,8 @@ func (client *LinuxBridgeEndpointClient) MoveEndpointsToContainerNS(epInfo *Endp
 	// Move the container interface to container's network namespace.
 	log.Printf("[net] Setting link %v netns %v.", client.containerVethName, epInfo.NetNsPath)
 	if err := client.netlink.SetLinkNetNs(client.containerVethName, nsID); err != nil {
-		return newErrorLinuxBridgeClient(err.Error())
+		return newErrorLinuxBridgeClient(
+			networkutils.NewError("set link netns", client.containerVethName, networkutils.ObjectNamespace, err))
 	}
 
 	return nil
@@ -191,7 +192,7 @@ func (client *LinuxBridgeEndpointClient) SetupContainerInterfaces(epInfo *Endpoi
 }
 
 func (client *LinuxBridgeEndpointClient) ConfigureContainerInterfacesAndRoutes(epInfo *EndpointInfo) error {
-	if err := client.nuc.AssignIPToInterface(client.containerVethName, epInfo.IPAddresses); err != nil {
+	if err := assignIPAddresses(client.nuc, client.containerVethName, epInfo); err != nil {
 		return err
 	}
 
@@ -203,11 +204,7 @@ func (client *LinuxBridgeEndpointClient) ConfigureContainerInterfacesAndRoutes(e
 		return err
 	}
 
-	if err := client.setIPV6NeighEntry(epInfo); err != nil {
-		return err
-	}
-
-	return nil
+	return client.setIPV6NeighEntry(epInfo)
 }
 
 func (client *LinuxBridgeEndpointClient) DeleteEndpoints(ep *endpoint) error {
@@ -218,7 +215,7 @@ func (client *LinuxBridgeEndpointClient) DeleteEndpoints(ep *endpoint) error {
 		return err
 	}
 
-	return nil
+	return deleteBandwidthLimits(client.netlink, ep.HostIfName)
 }
 
 func addRuleToRouteViaHost(epInfo *EndpointInfo) error {
@@ -306,7 +303,7 @@ func (client *LinuxBridgeEndpointClient) setIPV6NeighEntry(epInfo *EndpointInfo)
 			IPAddr:     hostGwIp,
 			MacAddress: hardwareAddr,
 		}
-		if err := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT); err != nil {
+		if err := addNeighbor(client.netlink, linkInfo, netlink.NUD_PERMANENT); err != nil {
 			log.Printf("Failed setting neigh entry in container: %v", err)
 			return err
 		}
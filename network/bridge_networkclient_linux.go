@@ -18,8 +18,8 @@ const (
 
 var errorLinuxBridgeClient = errors.New("LinuxBridgeClient Error")
 
-func newErrorLinuxBridgeClient(errStr string) error {
-	return fmt.Errorf("%w : %s", errorLinuxBridgeClient, errStr)
+func newErrorLinuxBridgeClient(err error) error {
+	return fmt.Errorf("%v : %w", errorLinuxBridgeClient, err)
 }
 
 type LinuxBridgeClient struct {
@@ -150,7 +150,9 @@ func (client *LinuxBridgeClient) AddL2Rules(extIf *externalInterface) error {
 func (client *LinuxBridgeClient) DeleteL2Rules(extIf *externalInterface) {
 	ebtables.SetVepaMode(client.bridgeName, commonInterfacePrefix, virtualMacAddress, ebtables.Delete)
 	ebtables.SetDnatForArpReplies(extIf.Name, ebtables.Delete)
-	ebtables.SetArpReply(extIf.IPAddresses[0].IP, extIf.MacAddress, ebtables.Delete)
+	if len(extIf.IPAddresses) > 0 {
+		ebtables.SetArpReply(extIf.IPAddresses[0].IP, extIf.MacAddress, ebtables.Delete)
+	}
 	ebtables.SetSnatForInterface(extIf.Name, extIf.MacAddress, ebtables.Delete)
 	if client.nwInfo.IPV6Mode != "" {
 		if len(extIf.IPAddresses) > 1 {
@@ -166,7 +168,7 @@ func (client *LinuxBridgeClient) DeleteL2Rules(extIf *externalInterface) {
 func (client *LinuxBridgeClient) SetBridgeMasterToHostInterface() error {
 	err := client.netlink.SetLinkMaster(client.hostInterfaceName, client.bridgeName)
 	if err != nil {
-		return newErrorLinuxBridgeClient(err.Error())
+		return newErrorLinuxBridgeClient(err)
 	}
 	return nil
 }
@@ -174,7 +176,7 @@ func (client *LinuxBridgeClient) SetBridgeMasterToHostInterface() error {
 func (client *LinuxBridgeClient) SetHairpinOnHostInterface(enable bool) error {
 	err := client.netlink.SetLinkHairpin(client.hostInterfaceName, enable)
 	if err != nil {
-		return newErrorLinuxBridgeClient(err.Error())
+		return newErrorLinuxBridgeClient(err)
 	}
 	return nil
 }
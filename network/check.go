@@ -0,0 +1,68 @@
+package network
+
+import "github.com/Azure/azure-container-networking/log"
+
+// CheckEndpoint verifies that an endpoint still matches its record in the state store: its interfaces exist
+// and the container interface has the recorded addresses and routes. Divergences are reported with an error
+// for which IsEndpointDivergedError returns true, other errors mean the check could not be done.
+func (nm *networkManager) CheckEndpoint(networkID, endpointID, netNsPath, ifName string) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return err
+	}
+
+	ep, err := nw.getEndpoint(endpointID)
+	if err != nil {
+		return err
+	}
+
+	return nm.checkEndpointImpl(nw, ep, netNsPath, ifName)
+}
+
+// RepairEndpoint brings an endpoint whose interfaces still exist back in line with its record in the state store,
+// and returns the changes made, or those it would make in a dry run. An endpoint whose namespace or interfaces are
+// gone can't be repaired in place, for which IsEndpointNotRepairableError returns true.
+func (nm *networkManager) RepairEndpoint(networkID, endpointID string, dryRun bool) ([]string, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	ep, err := nw.getEndpoint(endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	return nm.repairEndpointImpl(nw, ep, dryRun)
+}
+
+// reconcileEndpoints removes the endpoints whose interfaces are gone from the state, after the store was restored
+// from a backup which may predate their deletion. It must be called with the store locked.
+func (nm *networkManager) reconcileEndpoints() error {
+	removed := 0
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for epID, ep := range nw.Endpoints {
+				if nm.endpointExistsImpl(nw, ep) {
+					continue
+				}
+
+				log.Printf("[net] Removing endpoint %s restored from a backup, its interfaces are gone", epID)
+				delete(nw.Endpoints, epID)
+				removed++
+			}
+		}
+	}
+
+	if removed == 0 {
+		return nil
+	}
+
+	return nm.store.Write(storeKey, nm)
+}
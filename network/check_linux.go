@@ -0,0 +1,253 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/telemetry"
+	"golang.org/x/sys/unix"
+)
+
+// checkEndpointImpl verifies the host interface of the endpoint and, from inside the container
+// namespace, the container interface.
+func (nm *networkManager) checkEndpointImpl(nw *network, ep *endpoint, netNsPath, ifName string) error {
+	if ep.NetworkNameSpace != "" && ep.NetworkNameSpace != netNsPath {
+		return fmt.Errorf("%w: endpoint %s is in netns %s, not %s", errEndpointDiverged, ep.Id, ep.NetworkNameSpace, netNsPath)
+	}
+
+	// The host side of transparent vlan endpoints is in the vnet namespace.
+	if nw.Mode != opModeTransparentVlan && ep.HostIfName != "" {
+		if _, err := nm.netio.GetNetworkInterfaceByName(ep.HostIfName); err != nil {
+			return fmt.Errorf("%w: host interface %s: %v", errEndpointDiverged, ep.HostIfName, err)
+		}
+	}
+
+	stop := nm.phases.Start(telemetry.PhaseNetns)
+	ns, err := OpenNamespace(netNsPath)
+	if err != nil {
+		stop()
+		return fmt.Errorf("%w: %v", errEndpointDiverged, err)
+	}
+	defer ns.Close()
+
+	err = ns.Enter()
+	stop()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		defer nm.phases.Start(telemetry.PhaseNetns)()
+		if err := ns.Exit(); err != nil {
+			log.Printf("[net] Failed to exit netns, err:%v.", err)
+		}
+	}()
+
+	// Transparent endpoints reach their gateway through a static neighbor entry for the host veth, and vlan trunk
+	// endpoints through a static neighbor entry for the Azure host.
+	staticGateway := nw.Mode == opModeTransparent || nw.Mode == opModeTransparentVlan || nw.Mode == opModeVlanTrunk
+
+	return checkContainerInterface(nm.netlink, nm.netio, ep, ifName, staticGateway)
+}
+
+// checkContainerInterface verifies that the container interface has the addresses and routes of the endpoint,
+// and the neighbor entries of the gateways if they are static. It must be called in the container namespace.
+func checkContainerInterface(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface, ep *endpoint, ifName string, staticGateway bool) error {
+	iface, err := netioshim.GetNetworkInterfaceByName(ifName)
+	if err != nil {
+		return fmt.Errorf("%w: container interface %s: %v", errEndpointDiverged, ifName, err)
+	}
+
+	addrs, err := nl.GetIPAddresses(ifName)
+	if err != nil {
+		return err
+	}
+
+	for _, ipAddr := range ep.IPAddresses {
+		if !hasAddress(addrs, ipAddr) {
+			return fmt.Errorf("%w: address %v is not assigned to %s", errEndpointDiverged, ipAddr.String(), ifName)
+		}
+	}
+
+	var gateways []net.IP
+	for _, routeInfo := range ep.Routes {
+		dst := routeInfo.Dst
+		family := unix.AF_INET6
+		if dst.IP.To4() != nil {
+			family = unix.AF_INET
+		}
+
+		routes, err := nl.GetIPRoute(&netlink.Route{Family: family, Dst: &dst, LinkIndex: iface.Index})
+		if err != nil {
+			return err
+		}
+
+		if len(routes) == 0 {
+			return fmt.Errorf("%w: route to %v is missing on %s", errEndpointDiverged, dst.String(), ifName)
+		}
+
+		for _, route := range routes {
+			if route.Gw != nil {
+				gateways = append(gateways, route.Gw)
+			}
+		}
+	}
+
+	if !staticGateway || len(gateways) == 0 {
+		return nil
+	}
+
+	neighbors, err := nl.ListNeighbors(ifName)
+	if err != nil {
+		return err
+	}
+
+	for _, gw := range gateways {
+		if !hasNeighbor(neighbors, gw) {
+			return fmt.Errorf("%w: neighbor entry of gateway %v is missing on %s", errEndpointDiverged, gw, ifName)
+		}
+	}
+
+	return nil
+}
+
+// hasAddress returns true if the address with the same prefix length is in the list.
+func hasAddress(addrs []*netlink.Address, ipAddr net.IPNet) bool {
+	ones, bits := ipAddr.Mask.Size()
+	for _, addr := range addrs {
+		if addr.IPNet == nil || !addr.IPNet.IP.Equal(ipAddr.IP) {
+			continue
+		}
+
+		if addrOnes, addrBits := addr.IPNet.Mask.Size(); addrOnes == ones && addrBits == bits {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasNeighbor returns true if the list has a resolved entry for the IP address.
+func hasNeighbor(neighbors []*netlink.Neighbor, ip net.IP) bool {
+	for _, neigh := range neighbors {
+		if !neigh.IP.Equal(ip) || len(neigh.HardwareAddr) == 0 {
+			continue
+		}
+
+		if neigh.State&(netlink.NUD_FAILED|netlink.NUD_INCOMPLETE) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// repairEndpointImpl adds the addresses and routes of the endpoint which are missing on the container interface.
+// The neighbor entries of static gateways are left to the endpoint clients, which set them up on ADD.
+func (nm *networkManager) repairEndpointImpl(nw *network, ep *endpoint, dryRun bool) ([]string, error) {
+	if ep.NetworkNameSpace == "" {
+		return nil, fmt.Errorf("%w: endpoint %s has no netns", errEndpointNotRepairable, ep.Id)
+	}
+
+	if nw.Mode != opModeTransparentVlan && ep.HostIfName != "" {
+		if _, err := nm.netio.GetNetworkInterfaceByName(ep.HostIfName); err != nil {
+			return nil, fmt.Errorf("%w: host interface %s: %v", errEndpointNotRepairable, ep.HostIfName, err)
+		}
+	}
+
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errEndpointNotRepairable, err)
+	}
+	defer ns.Close()
+
+	if err = ns.Enter(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := ns.Exit(); err != nil {
+			log.Printf("[net] Failed to exit netns, err:%v.", err)
+		}
+	}()
+
+	return repairContainerInterface(nm.netlink, nm.netio, ep, ep.IfName, dryRun)
+}
+
+// repairContainerInterface adds the addresses and routes of the endpoint which are missing on the container
+// interface, and returns them. It must be called in the container namespace.
+func repairContainerInterface(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface, ep *endpoint, ifName string, dryRun bool) ([]string, error) {
+	iface, err := netioshim.GetNetworkInterfaceByName(ifName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: container interface %s: %v", errEndpointNotRepairable, ifName, err)
+	}
+
+	addrs, err := nl.GetIPAddresses(ifName)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []string
+	for i := range ep.IPAddresses {
+		ipAddr := ep.IPAddresses[i]
+		if hasAddress(addrs, ipAddr) {
+			continue
+		}
+
+		actions = append(actions, fmt.Sprintf("add address %v to %s", ipAddr.String(), ifName))
+		if dryRun {
+			continue
+		}
+
+		if err := nl.AddIPAddress(ifName, ipAddr.IP, &ipAddr); err != nil {
+			return actions, fmt.Errorf("failed to add address %v to %s: %w", ipAddr.String(), ifName, err)
+		}
+	}
+
+	var missingRoutes []RouteInfo
+	for _, routeInfo := range ep.Routes {
+		dst := routeInfo.Dst
+		family := unix.AF_INET6
+		if dst.IP.To4() != nil {
+			family = unix.AF_INET
+		}
+
+		routes, err := nl.GetIPRoute(&netlink.Route{Family: family, Dst: &dst, LinkIndex: iface.Index})
+		if err != nil {
+			return actions, err
+		}
+
+		if len(routes) == 0 {
+			actions = append(actions, fmt.Sprintf("add route to %v on %s", dst.String(), ifName))
+			missingRoutes = append(missingRoutes, routeInfo)
+		}
+	}
+
+	if dryRun || len(missingRoutes) == 0 {
+		return actions, nil
+	}
+
+	return actions, addRoutes(nl, netioshim, ifName, missingRoutes)
+}
+
+// endpointExistsImpl returns false if the namespace or the host interface of the endpoint is gone.
+func (nm *networkManager) endpointExistsImpl(nw *network, ep *endpoint) bool {
+	if ep.NetworkNameSpace != "" {
+		if _, err := os.Stat(ep.NetworkNameSpace); os.IsNotExist(err) {
+			return false
+		}
+	}
+
+	// The host side of transparent vlan endpoints is in the vnet namespace.
+	if nw.Mode != opModeTransparentVlan && ep.HostIfName != "" {
+		if _, err := nm.netio.GetNetworkInterfaceByName(ep.HostIfName); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,251 @@
+package network
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/stretchr/testify/require"
+)
+
+// checkNetlink reports the addresses, routes and neighbors of a container interface.
+type checkNetlink struct {
+	*netlink.MockNetlink
+	addrs     []*netlink.Address
+	routes    []*netlink.Route
+	neighbors []*netlink.Neighbor
+}
+
+func (nl *checkNetlink) GetIPAddresses(string) ([]*netlink.Address, error) {
+	return nl.addrs, nil
+}
+
+func (nl *checkNetlink) GetIPRoute(filter *netlink.Route) ([]*netlink.Route, error) {
+	var routes []*netlink.Route
+	for _, route := range nl.routes {
+		if route.Dst.String() == filter.Dst.String() {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+func (nl *checkNetlink) ListNeighbors(string) ([]*netlink.Neighbor, error) {
+	return nl.neighbors, nil
+}
+
+func TestCheckContainerInterface(t *testing.T) {
+	_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+	ipAddr := net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}
+	gw := net.ParseIP("169.254.1.1")
+	gwMac, _ := net.ParseMAC("12:34:56:78:9a:bc")
+
+	ep := &endpoint{
+		Id:          "ep1",
+		IPAddresses: []net.IPNet{ipAddr},
+		Routes:      []RouteInfo{{Dst: *defaultDst, Gw: net.ParseIP("10.0.0.1")}},
+	}
+
+	newNetlink := func() *checkNetlink {
+		return &checkNetlink{
+			MockNetlink: netlink.NewMockNetlink(false, ""),
+			addrs:       []*netlink.Address{{IPNet: &ipAddr}},
+			routes:      []*netlink.Route{{Dst: defaultDst, Gw: gw}},
+			neighbors:   []*netlink.Neighbor{{IP: gw, HardwareAddr: gwMac, State: netlink.NUD_PERMANENT}},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		modify        func(nl *checkNetlink)
+		netio         netio.NetIOInterface
+		staticGateway bool
+		wantDiverged  bool
+	}{
+		{
+			name:          "matching endpoint",
+			modify:        func(*checkNetlink) {},
+			staticGateway: true,
+		},
+		{
+			name:         "missing interface",
+			modify:       func(*checkNetlink) {},
+			netio:        netio.NewMockNetIO(true, 1),
+			wantDiverged: true,
+		},
+		{
+			name:         "missing address",
+			modify:       func(nl *checkNetlink) { nl.addrs = nil },
+			wantDiverged: true,
+		},
+		{
+			name: "address with another prefix length",
+			modify: func(nl *checkNetlink) {
+				nl.addrs = []*netlink.Address{{IPNet: &net.IPNet{IP: ipAddr.IP, Mask: net.CIDRMask(32, 32)}}}
+			},
+			wantDiverged: true,
+		},
+		{
+			name:         "missing route",
+			modify:       func(nl *checkNetlink) { nl.routes = nil },
+			wantDiverged: true,
+		},
+		{
+			name:          "missing static gateway neighbor",
+			modify:        func(nl *checkNetlink) { nl.neighbors = nil },
+			staticGateway: true,
+			wantDiverged:  true,
+		},
+		{
+			name:          "failed static gateway neighbor",
+			modify:        func(nl *checkNetlink) { nl.neighbors[0].State = netlink.NUD_FAILED },
+			staticGateway: true,
+			wantDiverged:  true,
+		},
+		{
+			name:   "learned gateway neighbor is not checked",
+			modify: func(nl *checkNetlink) { nl.neighbors = nil },
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nl := newNetlink()
+			tt.modify(nl)
+			if tt.netio == nil {
+				tt.netio = netio.NewMockNetIO(false, 0)
+			}
+
+			err := checkContainerInterface(nl, tt.netio, ep, "eth0", tt.staticGateway)
+			if tt.wantDiverged {
+				require.Error(t, err)
+				require.True(t, IsEndpointDivergedError(err), "unexpected error %v", err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// repairNetlink records the addresses and routes added to a container interface.
+type repairNetlink struct {
+	checkNetlink
+	addedAddrs  []string
+	addedRoutes []string
+}
+
+func (nl *repairNetlink) AddIPAddress(_ string, _ net.IP, ipNet *net.IPNet) error {
+	nl.addedAddrs = append(nl.addedAddrs, ipNet.String())
+	return nil
+}
+
+func (nl *repairNetlink) AddIPRoute(route *netlink.Route) error {
+	nl.addedRoutes = append(nl.addedRoutes, route.Dst.String())
+	return nil
+}
+
+func TestRepairContainerInterface(t *testing.T) {
+	_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+	_, podDst, _ := net.ParseCIDR("10.0.0.0/24")
+	ipAddr := net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}
+	ipAddr2 := net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)}
+
+	ep := &endpoint{
+		Id:          "ep1",
+		IPAddresses: []net.IPNet{ipAddr, ipAddr2},
+		Routes:      []RouteInfo{{Dst: *defaultDst, Gw: net.ParseIP("10.0.0.1")}, {Dst: *podDst}},
+	}
+
+	newNetlink := func() *repairNetlink {
+		return &repairNetlink{
+			checkNetlink: checkNetlink{
+				MockNetlink: netlink.NewMockNetlink(false, ""),
+				addrs:       []*netlink.Address{{IPNet: &ipAddr}},
+				routes:      []*netlink.Route{{Dst: podDst}},
+			},
+		}
+	}
+	wantActions := []string{"add address 10.0.0.5/24 to eth0", "add route to 0.0.0.0/0 on eth0"}
+
+	t.Run("dry run", func(t *testing.T) {
+		nl := newNetlink()
+		actions, err := repairContainerInterface(nl, netio.NewMockNetIO(false, 0), ep, "eth0", true)
+		require.NoError(t, err)
+		require.Equal(t, wantActions, actions)
+		require.Empty(t, nl.addedAddrs)
+		require.Empty(t, nl.addedRoutes)
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		nl := newNetlink()
+		actions, err := repairContainerInterface(nl, netio.NewMockNetIO(false, 0), ep, "eth0", false)
+		require.NoError(t, err)
+		require.Equal(t, wantActions, actions)
+		require.Equal(t, []string{"10.0.0.5/24"}, nl.addedAddrs)
+		require.Equal(t, []string{"0.0.0.0/0"}, nl.addedRoutes)
+	})
+
+	t.Run("matching endpoint", func(t *testing.T) {
+		nl := newNetlink()
+		nl.addrs = append(nl.addrs, &netlink.Address{IPNet: &ipAddr2})
+		nl.routes = append(nl.routes, &netlink.Route{Dst: defaultDst})
+		actions, err := repairContainerInterface(nl, netio.NewMockNetIO(false, 0), ep, "eth0", false)
+		require.NoError(t, err)
+		require.Empty(t, actions)
+	})
+
+	t.Run("missing interface", func(t *testing.T) {
+		_, err := repairContainerInterface(newNetlink(), netio.NewMockNetIO(true, 1), ep, "eth0", false)
+		require.True(t, IsEndpointNotRepairableError(err), "unexpected error %v", err)
+	})
+}
+
+func TestRestoreReconcilesEndpointsOfBackup(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "azure-vnet.json")
+	netNs := filepath.Join(dir, "netns")
+	require.NoError(t, os.WriteFile(netNs, nil, 0o600))
+
+	newStore := func() store.KeyValueStore {
+		kvs, err := store.NewJsonFileStoreWithBackups(fileName, processlock.NewMockFileLock(false), store.DefaultBackupCount)
+		require.NoError(t, err)
+		return kvs
+	}
+
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{
+			"eth0": {
+				Name: "eth0",
+				Networks: map[string]*network{
+					"nw": {
+						Id: "nw",
+						Endpoints: map[string]*endpoint{
+							"ep1": {Id: "ep1", NetworkNameSpace: netNs},
+							"ep2": {Id: "ep2", NetworkNameSpace: filepath.Join(dir, "deleted")},
+						},
+					},
+				},
+			},
+		},
+		store: newStore(),
+	}
+	require.NoError(t, nm.save())
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"Network":`), 0o600))
+
+	// The endpoint whose netns is gone is removed from the restored state.
+	nm = &networkManager{ExternalInterfaces: map[string]*externalInterface{}, store: newStore(), netio: netio.NewMockNetIO(false, 0)}
+	require.NoError(t, nm.restoreState(false))
+	endpoints := nm.ExternalInterfaces["eth0"].Networks["nw"].Endpoints
+	require.Contains(t, endpoints, "ep1")
+	require.NotContains(t, endpoints, "ep2")
+
+	nm = &networkManager{ExternalInterfaces: map[string]*externalInterface{}, store: newStore()}
+	require.NoError(t, nm.restoreState(false))
+	require.NotContains(t, nm.ExternalInterfaces["eth0"].Networks["nw"].Endpoints, "ep2")
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// checkEndpointImpl verifies that the HNS endpoint exists, is attached to the namespace and has the addresses of the endpoint.
+func (nm *networkManager) checkEndpointImpl(_ *network, ep *endpoint, netNsPath, _ string) error {
+	if ep.NetNs != "" && ep.NetNs != netNsPath {
+		return fmt.Errorf("%w: endpoint %s is in netns %s, not %s", errEndpointDiverged, ep.Id, ep.NetNs, netNsPath)
+	}
+
+	if useHnsV2, err := UseHnsV2(ep.NetNs); useHnsV2 {
+		if err != nil {
+			return err
+		}
+
+		return checkEndpointHnsV2(ep)
+	}
+
+	return checkEndpointHnsV1(ep)
+}
+
+// checkEndpointHnsV1 verifies the endpoint using HNS v1.
+func checkEndpointHnsV1(ep *endpoint) error {
+	hnsEndpoint, err := Hnsv1.GetHNSEndpointByID(ep.HnsId)
+	if err != nil {
+		return fmt.Errorf("%w: hns endpoint %s: %v", errEndpointDiverged, ep.HnsId, err)
+	}
+
+	for _, ipAddr := range ep.IPAddresses {
+		if !ipAddr.IP.Equal(hnsEndpoint.IPAddress) && !ipAddr.IP.Equal(hnsEndpoint.IPv6Address) {
+			return fmt.Errorf("%w: address %v is not assigned to hns endpoint %s", errEndpointDiverged, ipAddr.IP, ep.HnsId)
+		}
+	}
+
+	return nil
+}
+
+// checkEndpointHnsV2 verifies the endpoint using HNS v2.
+func checkEndpointHnsV2(ep *endpoint) error {
+	hcnEndpoint, err := Hnsv2.GetEndpointByID(ep.HnsId)
+	if err != nil {
+		if _, endpointNotFound := err.(hcn.EndpointNotFoundError); endpointNotFound {
+			return fmt.Errorf("%w: hcn endpoint %s: %v", errEndpointDiverged, ep.HnsId, err)
+		}
+
+		return fmt.Errorf("Failed to get hcn endpoint with id: %s due to err: %w", ep.HnsId, err)
+	}
+
+	namespace, err := Hnsv2.GetNamespaceByID(ep.NetNs)
+	if err != nil {
+		return fmt.Errorf("%w: hcn namespace %s: %v", errEndpointDiverged, ep.NetNs, err)
+	}
+
+	if hcnEndpoint.HostComputeNamespace != namespace.Id {
+		return fmt.Errorf("%w: hcn endpoint %s is not attached to namespace %s", errEndpointDiverged, ep.HnsId, namespace.Id)
+	}
+
+	for _, ipAddr := range ep.IPAddresses {
+		found := false
+		for _, ipConfig := range hcnEndpoint.IpConfigurations {
+			if ipAddr.IP.Equal(net.ParseIP(ipConfig.IpAddress)) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%w: address %v is not assigned to hcn endpoint %s", errEndpointDiverged, ipAddr.IP, ep.HnsId)
+		}
+	}
+
+	return checkOutboundNATExceptions(ep, hcnEndpoint)
+}
+
+// repairEndpointImpl adds the missing outbound NAT exceptions back to the HNS endpoint. It can't repair other
+// divergences in place, so it only reports them.
+func (nm *networkManager) repairEndpointImpl(nw *network, ep *endpoint, dryRun bool) ([]string, error) {
+	if err := nm.checkEndpointImpl(nw, ep, ep.NetNs, ep.IfName); err != nil {
+		if isOutboundNATExceptionsMissingError(err) {
+			return repairOutboundNATExceptions(ep, dryRun)
+		}
+		if IsEndpointDivergedError(err) {
+			return nil, fmt.Errorf("%w: %v", errEndpointNotRepairable, err)
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// endpointExistsImpl returns false if the HNS endpoint is gone.
+func (nm *networkManager) endpointExistsImpl(_ *network, ep *endpoint) bool {
+	if useHnsV2, err := UseHnsV2(ep.NetNs); useHnsV2 && err == nil {
+		_, err = Hnsv2.GetEndpointByID(ep.HnsId)
+		_, endpointNotFound := err.(hcn.EndpointNotFoundError)
+		return !endpointNotFound
+	}
+
+	// hcsshim bubbles up a generic error when the endpoint is not found.
+	_, err := Hnsv1.GetHNSEndpointByID(ep.HnsId)
+	return err == nil || !strings.Contains(strings.ToLower(err.Error()), "not found")
+}
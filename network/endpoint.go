@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/Azure/azure-container-networking/network/policy"
 	"github.com/Azure/azure-container-networking/platform"
+	"go.uber.org/zap"
 )
 
 const (
@@ -35,6 +37,8 @@ type endpoint struct {
 	Gateways                 []net.IP
 	DNS                      DNSInfo
 	Routes                   []RouteInfo
+	SecondaryInterfaces      []InterfaceInfo
+	PortMappings             []PortMapping
 	VlanID                   int
 	EnableSnatOnHost         bool
 	EnableInfraVnet          bool
@@ -48,6 +52,8 @@ type endpoint struct {
 	PODNameSpace             string `json:",omitempty"`
 	InfraVnetAddressSpace    string `json:",omitempty"`
 	NetNs                    string `json:",omitempty"`
+	// OutboundNATExceptions are the exceptions CNI manages on the OutBoundNAT policy of the HNS endpoint.
+	OutboundNATExceptions []string `json:",omitempty"`
 }
 
 // EndpointInfo contains read-only information about an endpoint.
@@ -82,6 +88,45 @@ type EndpointInfo struct {
 	VnetCidrs                string
 	ServiceCidrs             string
 	NATInfo                  []policy.NATInfo
+	BandwidthLimits          BandwidthLimits
+	SecondaryInterfaces      []InterfaceInfo
+	PortMappings             []PortMapping
+	HostIfName               string
+	HNSEndpointID            string
+	// OutboundNATExceptions are added to the exceptions of the OutBoundNAT policy of the HNS endpoint, and kept on it
+	// by UpdateOutboundNATExceptions and RepairEndpoint.
+	OutboundNATExceptions []string
+	// DADTimeout enables the duplicate address detection of the IP addresses on Linux, see
+	// networkutils.WithDuplicateAddressDetection.
+	DADTimeout time.Duration
+}
+
+// PortMapping maps a port of the host to a port of the endpoint, as requested by the hostPort of a container.
+// An empty host IP maps the port on all addresses of the host.
+type PortMapping struct {
+	HostPort      int
+	ContainerPort int
+	Protocol      string
+	HostIP        string `json:",omitempty"`
+}
+
+// InterfaceInfo describes an interface of an endpoint in addition to its primary interface, such as a
+// delegated NIC in the container, which is reported in the CNI result next to the primary interface.
+type InterfaceInfo struct {
+	Name        string
+	MacAddress  net.HardwareAddr
+	IPAddresses []net.IPNet
+	Gateways    []net.IP
+	Routes      []RouteInfo
+}
+
+// BandwidthLimits are the rates the traffic of an endpoint is shaped to, zero rates are not limited.
+// Rates are in bits per second, bursts in bits.
+type BandwidthLimits struct {
+	IngressRate  uint64
+	IngressBurst uint64
+	EgressRate   uint64
+	EgressBurst  uint64
 }
 
 // RouteInfo contains information about an IP route.
@@ -93,7 +138,15 @@ type RouteInfo struct {
 	DevName  string
 	Scope    int
 	Priority int
-	Table    int
+	// Table is the routing table of the route, such as the table of a VRF the link is bound to. Zero is the main table.
+	Table int
+	// OnLink makes the gateway reachable on the link even if it isn't in a subnet of the link, e.g. when the
+	// gateway is on another NIC of the VM.
+	OnLink bool
+	// MTU is the path MTU of the route, zero for the MTU of the link. LockMTU keeps path MTU discovery from
+	// lowering it.
+	MTU     int
+	LockMTU bool
 }
 
 type apipaClient interface {
@@ -112,9 +165,11 @@ func (nw *network) newEndpoint(cli apipaClient, nl netlink.NetlinkInterface, plc
 	var ep *endpoint
 	var err error
 
+	logger := log.With(log.Component("net"), log.Operation("CreateEndpoint"), log.Network(nw.Id),
+		log.ContainerID(epInfo.ContainerID), zap.String("endpoint", epInfo.Id))
 	defer func() {
 		if err != nil {
-			log.Printf("[net] Failed to create endpoint %v, err:%v.", epInfo.Id, err)
+			logger.Error("Failed to create endpoint", zap.Error(err))
 		}
 	}()
 
@@ -124,8 +179,10 @@ func (nw *network) newEndpoint(cli apipaClient, nl netlink.NetlinkInterface, plc
 		return nil, err
 	}
 
+	ep.SecondaryInterfaces = epInfo.SecondaryInterfaces
 	nw.Endpoints[epInfo.Id] = ep
-	log.Printf("[net] Created endpoint %+v.", ep)
+	logger.Info("Created endpoint", zap.String("ifName", ep.IfName), zap.String("hostIfName", ep.HostIfName),
+		zap.Any("ipAddresses", ep.IPAddresses))
 
 	return ep, nil
 }
@@ -134,17 +191,18 @@ func (nw *network) newEndpoint(cli apipaClient, nl netlink.NetlinkInterface, plc
 func (nw *network) deleteEndpoint(nl netlink.NetlinkInterface, plc platform.ExecClient, endpointID string) error {
 	var err error
 
-	log.Printf("[net] Deleting endpoint %v from network %v.", endpointID, nw.Id)
+	logger := log.With(log.Component("net"), log.Operation("DeleteEndpoint"), log.Network(nw.Id), zap.String("endpoint", endpointID))
+	logger.Info("Deleting endpoint")
 	defer func() {
 		if err != nil {
-			log.Printf("[net] Failed to delete endpoint %v, err:%v.", endpointID, err)
+			logger.Error("Failed to delete endpoint", zap.Error(err))
 		}
 	}()
 
 	// Look up the endpoint.
 	ep, err := nw.getEndpoint(endpointID)
 	if err != nil {
-		log.Printf("[net] Endpoint %v not found. Not Returning error", endpointID)
+		logger.Info("Endpoint not found. Not Returning error")
 		return nil
 	}
 
@@ -157,7 +215,7 @@ func (nw *network) deleteEndpoint(nl netlink.NetlinkInterface, plc platform.Exec
 	// Remove the endpoint object.
 	delete(nw.Endpoints, endpointID)
 
-	log.Printf("[net] Deleted endpoint %+v.", ep)
+	logger.Info("Deleted endpoint", log.ContainerID(ep.ContainerID))
 
 	return nil
 }
@@ -232,12 +290,20 @@ func (ep *endpoint) getInfo() *EndpointInfo {
 		PODName:                  ep.PODName,
 		PODNameSpace:             ep.PODNameSpace,
 		NetworkContainerID:       ep.NetworkContainerID,
+		HostIfName:               ep.HostIfName,
+		HNSEndpointID:            ep.HnsId,
 	}
 
 	info.Routes = append(info.Routes, ep.Routes...)
 
 	info.Gateways = append(info.Gateways, ep.Gateways...)
 
+	info.SecondaryInterfaces = append(info.SecondaryInterfaces, ep.SecondaryInterfaces...)
+
+	info.PortMappings = append(info.PortMappings, ep.PortMappings...)
+
+	info.OutboundNATExceptions = append(info.OutboundNATExceptions, ep.OutboundNATExceptions...)
+
 	// Call the platform implementation.
 	ep.getInfoImpl(info)
 
@@ -10,12 +10,14 @@ import (
 	"net"
 	"strings"
 
+	"github.com/Azure/azure-container-networking/conntrack"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/Azure/azure-container-networking/network/networkutils"
 	"github.com/Azure/azure-container-networking/ovsctl"
 	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
 )
 
 const (
@@ -75,20 +77,17 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 		}
 	}
 
-	if _, ok := epInfo.Data[OptVethName]; ok {
-		key := epInfo.Data[OptVethName].(string)
-		log.Printf("Generate veth name based on the key provided %v", key)
-		vethname := generateVethName(key)
-		hostIfName = fmt.Sprintf("%s%s", hostVEthInterfacePrefix, vethname)
-		contIfName = fmt.Sprintf("%s%s2", hostVEthInterfacePrefix, vethname)
-	} else {
-		// Create a veth pair.
-		log.Printf("Generate veth name based on endpoint id")
-		hostIfName = fmt.Sprintf("%s%s", hostVEthInterfacePrefix, epInfo.Id[:7])
-		contIfName = fmt.Sprintf("%s%s-2", hostVEthInterfacePrefix, epInfo.Id[:7])
-	}
-
-	if vlanid != 0 {
+	hostIfName, contIfName = vethNames(epInfo)
+
+	if nw.Mode == opModeBridgeVlan {
+		log.Printf("Bridge vlan client")
+		epClient = NewLinuxBridgeEndpointClient(nw.vlanBridgeInterface(), hostIfName, contIfName, nw.Mode, nl, plc)
+	} else if nw.Mode == opModeVlanTrunk {
+		log.Printf("Vlan trunk client")
+		// The endpoint has no host interface, its VLAN sub-interface is moved into the container namespace.
+		hostIfName = ""
+		epClient = NewVlanTrunkEndpointClient(nw.extIf, contIfName, vlanid, nl, plc)
+	} else if vlanid != 0 {
 		if nw.Mode == opModeTransparentVlan {
 			log.Printf("Transparent vlan client")
 			if _, ok := epInfo.Data[SnatBridgeIPKey]; ok {
@@ -137,18 +136,23 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 				EnableMultitenancy:       epInfo.EnableMultiTenancy,
 				AllowInboundFromHostToNC: epInfo.AllowInboundFromHostToNC,
 				AllowInboundFromNCToHost: epInfo.AllowInboundFromNCToHost,
+				NetworkNameSpace:         epInfo.NetNsPath,
 			}
 
 			if containerIf != nil {
 				endpt.MacAddress = containerIf.HardwareAddr
 				epClient.DeleteEndpointRules(endpt)
+				deletePortMappings(epInfo.IPAddresses, epInfo.PortMappings)
 			}
 
 			epClient.DeleteEndpoints(endpt)
 		}
 	}()
 
-	if err = epClient.AddEndpoints(epInfo); err != nil {
+	stop := nw.phases.Start(telemetry.PhaseEndpointClient)
+	err = epClient.AddEndpoints(epInfo)
+	stop()
+	if err != nil {
 		return nil, err
 	}
 
@@ -158,7 +162,12 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 	}
 
 	// Setup rules for IP addresses on the container interface.
-	if err = epClient.AddEndpointRules(epInfo); err != nil {
+	stop = nw.phases.Start(telemetry.PhaseIptables)
+	if err = epClient.AddEndpointRules(epInfo); err == nil {
+		err = addPortMappings(epInfo.IPAddresses, epInfo.PortMappings)
+	}
+	stop()
+	if err != nil {
 		return nil, err
 	}
 
@@ -166,25 +175,34 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 	if epInfo.NetNsPath != "" {
 		// Open the network namespace.
 		log.Printf("[net] Opening netns %v.", epInfo.NetNsPath)
+		stop = nw.phases.Start(telemetry.PhaseNetns)
 		ns, err = OpenNamespace(epInfo.NetNsPath)
+		stop()
 		if err != nil {
 			return nil, err
 		}
 		defer ns.Close()
 
-		if err := epClient.MoveEndpointsToContainerNS(epInfo, ns.GetFd()); err != nil {
+		stop = nw.phases.Start(telemetry.PhaseEndpointClient)
+		err = epClient.MoveEndpointsToContainerNS(epInfo, ns.GetFd())
+		stop()
+		if err != nil {
 			return nil, err
 		}
 
 		// Enter the container network namespace.
 		log.Printf("[net] Entering netns %v.", epInfo.NetNsPath)
-		if err = ns.Enter(); err != nil {
+		stop = nw.phases.Start(telemetry.PhaseNetns)
+		err = ns.Enter()
+		stop()
+		if err != nil {
 			return nil, err
 		}
 
 		// Return to host network namespace.
 		defer func() {
 			log.Printf("[net] Exiting netns %v.", epInfo.NetNsPath)
+			defer nw.phases.Start(telemetry.PhaseNetns)()
 			if err := ns.Exit(); err != nil {
 				log.Printf("[net] Failed to exit netns, err:%v.", err)
 			}
@@ -200,14 +218,17 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 		}
 	}
 
+	stop = nw.phases.Start(telemetry.PhaseEndpointClient)
 	// If a name for the container interface is specified...
 	if epInfo.IfName != "" {
-		if err = epClient.SetupContainerInterfaces(epInfo); err != nil {
-			return nil, err
-		}
+		err = epClient.SetupContainerInterfaces(epInfo)
 	}
 
-	if err = epClient.ConfigureContainerInterfacesAndRoutes(epInfo); err != nil {
+	if err == nil {
+		err = epClient.ConfigureContainerInterfacesAndRoutes(epInfo)
+	}
+	stop()
+	if err != nil {
 		return nil, err
 	}
 
@@ -235,9 +256,44 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 	}
 
 	ep.Routes = append(ep.Routes, epInfo.Routes...)
+	ep.PortMappings = append(ep.PortMappings, epInfo.PortMappings...)
 	return ep, nil
 }
 
+// vethNames returns the names of the host and container interfaces of the veth pair of the endpoint.
+func vethNames(epInfo *EndpointInfo) (hostIfName, contIfName string) {
+	if key, ok := epInfo.Data[OptVethName].(string); ok {
+		log.Printf("Generate veth name based on the key provided %v", key)
+		vethname := generateVethName(key)
+		return fmt.Sprintf("%s%s", hostVEthInterfacePrefix, vethname), fmt.Sprintf("%s%s2", hostVEthInterfacePrefix, vethname)
+	}
+
+	// Create a veth pair.
+	log.Printf("Generate veth name based on endpoint id")
+	return fmt.Sprintf("%s%s", hostVEthInterfacePrefix, epInfo.Id[:7]), fmt.Sprintf("%s%s-2", hostVEthInterfacePrefix, epInfo.Id[:7])
+}
+
+// cleanupEndpointImpl deletes the host interface of an endpoint which is not in the state, which is left behind
+// by an ADD that did not complete. Routes to the endpoint are removed with the interface.
+func (nm *networkManager) cleanupEndpointImpl(epInfo *EndpointInfo) error {
+	if _, ok := epInfo.Data[OptVethName]; !ok && len(epInfo.Id) < 7 {
+		return nil
+	}
+
+	hostIfName, _ := vethNames(epInfo)
+	if _, err := nm.netio.GetNetworkInterfaceByName(hostIfName); err != nil {
+		log.Printf("[net] Skipping cleanup of host interface %s: %v", hostIfName, err)
+		return nil
+	}
+
+	log.Printf("[net] Deleting host interface %s of endpoint %s.", hostIfName, epInfo.Id)
+	if err := nm.netlink.DeleteLink(hostIfName); err != nil {
+		return fmt.Errorf("failed to delete host interface %s: %w", hostIfName, err)
+	}
+
+	return nil
+}
+
 // deleteEndpointImpl deletes an existing endpoint from the network.
 func (nw *network) deleteEndpointImpl(nl netlink.NetlinkInterface, plc platform.ExecClient, ep *endpoint) error {
 	var epClient EndpointClient
@@ -245,7 +301,11 @@ func (nw *network) deleteEndpointImpl(nl netlink.NetlinkInterface, plc platform.
 	// Delete the veth pair by deleting one of the peer interfaces.
 	// Deleting the host interface is more convenient since it does not require
 	// entering the container netns and hence works both for CNI and CNM.
-	if ep.VlanID != 0 {
+	if nw.Mode == opModeBridgeVlan {
+		epClient = NewLinuxBridgeEndpointClient(nw.vlanBridgeInterface(), ep.HostIfName, "", nw.Mode, nl, plc)
+	} else if nw.Mode == opModeVlanTrunk {
+		epClient = NewVlanTrunkEndpointClient(nw.extIf, ep.IfName, ep.VlanID, nl, plc)
+	} else if ep.VlanID != 0 {
 		epInfo := ep.getInfo()
 		if nw.Mode == opModeTransparentVlan {
 			log.Printf("Transparent vlan client")
@@ -260,12 +320,30 @@ func (nw *network) deleteEndpointImpl(nl netlink.NetlinkInterface, plc platform.
 		epClient = NewTransparentEndpointClient(nw.extIf, ep.HostIfName, "", nw.Mode, nl, plc)
 	}
 
+	stop := nw.phases.Start(telemetry.PhaseIptables)
 	epClient.DeleteEndpointRules(ep)
+	deletePortMappings(ep.IPAddresses, ep.PortMappings)
+	stop()
+
+	flushEndpointConntrack(nw.conntrack, ep)
+
+	stop = nw.phases.Start(telemetry.PhaseEndpointClient)
 	epClient.DeleteEndpoints(ep)
+	stop()
 
 	return nil
 }
 
+// flushEndpointConntrack removes the conntrack entries of the endpoint addresses so that
+// established flows don't keep using state which was set up for the deleted endpoint.
+func flushEndpointConntrack(ct conntrackClient, ep *endpoint) {
+	for _, ipAddr := range ep.IPAddresses {
+		if _, err := ct.Flush(conntrack.Filter{IP: ipAddr.IP}); err != nil {
+			log.Errorf("[net] Failed to flush conntrack entries for ip %v: %v", ipAddr.IP, err)
+		}
+	}
+}
+
 // getInfoImpl returns information about the endpoint.
 func (ep *endpoint) getInfoImpl(epInfo *EndpointInfo) {
 }
@@ -293,29 +371,74 @@ func addRoutes(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface, inte
 			family = netlink.GetIPAddressFamily(route.Dst.IP)
 		}
 
-		nlRoute := &netlink.Route{
-			Family:    family,
-			Dst:       &route.Dst,
-			Gw:        route.Gw,
-			LinkIndex: ifIndex,
-			Priority:  route.Priority,
-			Protocol:  route.Protocol,
-			Scope:     route.Scope,
-			Table:     route.Table,
-		}
+		nlRoute := newNetlinkRoute(&route, family, ifIndex)
+		nlRoute.MTU = route.MTU
+		nlRoute.LockMTU = route.LockMTU
 
-		if err := nl.AddIPRoute(nlRoute); err != nil {
-			if !strings.Contains(strings.ToLower(err.Error()), "file exists") {
+		done := networkutils.TimeOperation(networkutils.OpAddRoute)
+		err := networkutils.NewError("add route "+route.Dst.String(), interfaceName, networkutils.ObjectRoute, nl.AddIPRoute(nlRoute))
+		done(err)
+		if err != nil {
+			if !networkutils.IsAlreadyExists(err) {
 				return err
-			} else {
-				log.Printf("[net] route already exists")
 			}
+			log.Printf("[net] route already exists")
 		}
 	}
 
 	return nil
 }
 
+// newNetlinkRoute returns the netlink route of the route on the link. The preferred source address and the
+// metric select the route to delete when several routes to the destination differ only by them.
+func newNetlinkRoute(route *RouteInfo, family, ifIndex int) *netlink.Route {
+	nlRoute := &netlink.Route{
+		Family:    family,
+		Dst:       &route.Dst,
+		Src:       route.Src,
+		Gw:        route.Gw,
+		LinkIndex: ifIndex,
+		Priority:  route.Priority,
+		Protocol:  route.Protocol,
+		Scope:     route.Scope,
+		Table:     route.Table,
+	}
+
+	if route.OnLink {
+		nlRoute.Flags |= netlink.RTNH_F_ONLINK
+	}
+
+	return nlRoute
+}
+
+// assignIPAddresses assigns the IP addresses of the endpoint to the interface, detecting duplicate addresses first
+// if enabled for the endpoint, and announces them to the neighbors of the interface.
+func assignIPAddresses(nu networkutils.NetworkUtils, ifName string, epInfo *EndpointInfo) error {
+	if epInfo.DADTimeout > 0 {
+		nu = nu.WithDuplicateAddressDetection(epInfo.DADTimeout)
+	}
+
+	if err := nu.AssignIPToInterface(ifName, epInfo.IPAddresses); err != nil {
+		return err
+	}
+
+	// the addresses may have belonged to another pod or node, whose MAC is still in the caches of the neighbors.
+	// The endpoint is reachable once their entries time out anyway, so a failed announcement doesn't fail it.
+	if err := networkutils.AnnounceIPAddresses(ifName, epInfo.IPAddresses); err != nil {
+		log.Printf("[net] Failed to announce the addresses of %s: %v", ifName, err)
+	}
+
+	return nil
+}
+
+// addNeighbor adds the neighbor entry of the link, timed as an ARP programming operation.
+func addNeighbor(nl netlink.NetlinkInterface, linkInfo netlink.LinkInfo, state int) error {
+	done := networkutils.TimeOperation(networkutils.OpProgramARP)
+	err := nl.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, state)
+	done(err)
+	return err
+}
+
 func deleteRoutes(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface, interfaceName string, routes []RouteInfo) error {
 	ifIndex := 0
 
@@ -344,17 +467,8 @@ func deleteRoutes(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface, i
 			family = netlink.GetIPAddressFamily(route.Dst.IP)
 		}
 
-		nlRoute := &netlink.Route{
-			Family:    family,
-			Dst:       &route.Dst,
-			Gw:        route.Gw,
-			LinkIndex: ifIndex,
-			Protocol:  route.Protocol,
-			Scope:     route.Scope,
-		}
-
-		if err := nl.DeleteIPRoute(nlRoute); err != nil {
-			return err
+		if err := nl.DeleteIPRoute(newNetlinkRoute(&route, family, ifIndex)); err != nil {
+			return networkutils.NewError("delete route "+route.Dst.String(), interfaceName, networkutils.ObjectRoute, err)
 		}
 	}
 
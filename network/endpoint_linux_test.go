@@ -0,0 +1,87 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/conntrack"
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupEndpointImpl(t *testing.T) {
+	epInfo := &EndpointInfo{
+		Id:   "container-eth0",
+		Data: map[string]interface{}{OptVethName: "azurecontainereth0"},
+	}
+
+	// the failing netlink fails the test if the host interface is deleted
+	nm := &networkManager{netlink: netlink.NewMockNetlink(true, ""), netio: netio.NewMockNetIO(true, 1)}
+	require.NoError(t, nm.cleanupEndpointImpl(epInfo), "a missing host interface is skipped")
+
+	nm.netio = netio.NewMockNetIO(false, 0)
+	require.Error(t, nm.cleanupEndpointImpl(epInfo))
+
+	nm.netlink = netlink.NewMockNetlink(false, "")
+	require.NoError(t, nm.cleanupEndpointImpl(epInfo))
+
+	require.NoError(t, nm.cleanupEndpointImpl(&EndpointInfo{Id: "short"}), "no interface name can be derived")
+}
+
+func TestDeleteEndpointImplFlushesConntrack(t *testing.T) {
+	ct := conntrack.NewMockConntrack(false)
+	nw := &network{
+		Id:        "nw",
+		Mode:      opModeTransparent,
+		extIf:     &externalInterface{Name: "eth0"},
+		conntrack: ct,
+	}
+	ep := &endpoint{
+		Id:         "container-eth0",
+		HostIfName: "azv0",
+		IPAddresses: []net.IPNet{
+			{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)},
+			{IP: net.ParseIP("fd00::4"), Mask: net.CIDRMask(64, 128)},
+		},
+	}
+
+	require.NoError(t, nw.deleteEndpointImpl(netlink.NewMockNetlink(false, ""), platform.NewMockExecClient(false), ep))
+	require.Len(t, ct.Flushed, 2)
+	require.True(t, ct.Flushed[0].IP.Equal(ep.IPAddresses[0].IP))
+	require.True(t, ct.Flushed[1].IP.Equal(ep.IPAddresses[1].IP))
+
+	// a failing flush doesn't fail the endpoint deletion
+	nw.conntrack = conntrack.NewMockConntrack(true)
+	require.NoError(t, nw.deleteEndpointImpl(netlink.NewMockNetlink(false, ""), platform.NewMockExecClient(false), ep))
+}
+
+func TestSnatFamily(t *testing.T) {
+	v4 := net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}
+	v6 := net.IPNet{IP: net.ParseIP("fd00::4"), Mask: net.CIDRMask(64, 128)}
+
+	require.Equal(t, iptables.FamilyV4, snatFamily(&EndpointInfo{IPAddresses: []net.IPNet{v4}}))
+	require.Equal(t, iptables.FamilyDual, snatFamily(&EndpointInfo{IPAddresses: []net.IPNet{v4, v6}}))
+}
+
+func TestNewNetlinkRoute(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("0.0.0.0/0")
+	route := RouteInfo{
+		Dst:      *dst,
+		Src:      net.ParseIP("10.0.0.4"),
+		Gw:       net.ParseIP("169.254.1.1"),
+		Priority: 100,
+		OnLink:   true,
+	}
+
+	nlRoute := newNetlinkRoute(&route, netlink.GetIPAddressFamily(route.Gw), 7)
+	require.Equal(t, 7, nlRoute.LinkIndex)
+	require.True(t, nlRoute.Src.Equal(route.Src), "the source hint is the preferred source of the route")
+	require.Equal(t, 100, nlRoute.Priority)
+	require.Equal(t, netlink.RTNH_F_ONLINK, nlRoute.Flags)
+
+	route.OnLink = false
+	require.Zero(t, newNetlinkRoute(&route, netlink.GetIPAddressFamily(route.Gw), 7).Flags)
+}
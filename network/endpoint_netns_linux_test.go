@@ -0,0 +1,245 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/nstest"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// netnsTestbed is a host namespace with its eth0 linked to a fabric namespace, and a container namespace.
+type netnsTestbed struct {
+	host, container, fabric *nstest.Namespace
+	extIf                   *externalInterface
+}
+
+func newNetnsTestbed(t *testing.T) *netnsTestbed {
+	tb := &netnsTestbed{
+		host:      nstest.New(t),
+		container: nstest.New(t),
+		fabric:    nstest.New(t),
+	}
+
+	tb.host.AddVethPair(t, "eth0", tb.fabric, "eth0")
+	tb.host.AddAddress(t, "eth0", "10.240.0.4/16")
+	tb.fabric.AddAddress(t, "eth0", "10.240.0.1/16")
+
+	hostIf := tb.host.Interface(t, "eth0")
+	_, subnet, _ := net.ParseCIDR("10.240.0.0/16")
+	tb.extIf = &externalInterface{
+		Name:        "eth0",
+		BridgeName:  "azure0",
+		MacAddress:  hostIf.HardwareAddr,
+		IPAddresses: []*net.IPNet{{IP: net.ParseIP("10.240.0.4"), Mask: subnet.Mask}},
+		Subnets:     []string{subnet.String()},
+	}
+
+	return tb
+}
+
+func TestTransparentEndpointClientNetns(t *testing.T) {
+	tb := newNetnsTestbed(t)
+	client := NewTransparentEndpointClient(tb.extIf, "azv1", "azv1-2", opModeTransparent, netlink.NewNetlink(), platform.NewExecClient())
+
+	podIP := net.IPNet{IP: net.ParseIP("10.240.0.10"), Mask: net.CIDRMask(16, ipv4Bits)}
+	epInfo := &EndpointInfo{
+		Id:          "c0ffee-eth0",
+		IfName:      "eth0",
+		NetNsPath:   tb.container.Path(),
+		IPAddresses: []net.IPNet{podIP},
+	}
+
+	tb.host.Run(t, func() error {
+		if err := client.AddEndpoints(epInfo); err != nil {
+			return err
+		}
+		if err := client.AddEndpointRules(epInfo); err != nil {
+			return err
+		}
+		return client.MoveEndpointsToContainerNS(epInfo, tb.container.Fd())
+	})
+	tb.container.Run(t, func() error {
+		if err := client.SetupContainerInterfaces(epInfo); err != nil {
+			return err
+		}
+		return client.ConfigureContainerInterfacesAndRoutes(epInfo)
+	})
+
+	// the host routes the pod IP to the host veth and answers ARP for the rest of the subnet
+	require.Equal(t, "aa:aa:aa:aa:aa:aa", tb.host.Interface(t, "azv1").HardwareAddr.String())
+	require.False(t, tb.host.HasInterface(t, "azv1-2"), "the container veth is moved")
+	tb.host.RequireRoute(t, "azv1", "10.240.0.10/32", "")
+	tb.host.RequireSysctl(t, "net.ipv4.conf.azv1.proxy_arp", "1")
+
+	// the container routes everything to the virtual gateway resolved to the host veth
+	containerIf := tb.container.Interface(t, "eth0")
+	require.Equal(t, tb.host.Interface(t, "eth0").MTU, containerIf.MTU)
+	tb.container.RequireRoute(t, "eth0", virtualGwIPString, "")
+	tb.container.RequireRoute(t, "eth0", defaultGwCidr, "169.254.1.1")
+	tb.container.RequireNoRoute(t, "eth0", "10.240.0.0/16")
+	tb.container.RequireNeighbor(t, "eth0", "169.254.1.1", "aa:aa:aa:aa:aa:aa")
+
+	ep := &endpoint{Id: epInfo.Id, IfName: "eth0", HostIfName: "azv1", IPAddresses: epInfo.IPAddresses}
+	tb.host.Run(t, func() error {
+		client.DeleteEndpointRules(ep)
+		return client.DeleteEndpoints(ep)
+	})
+	tb.host.RequireNoRoute(t, "azv1", "10.240.0.10/32")
+}
+
+func TestLinuxBridgeEndpointClientNetns(t *testing.T) {
+	tb := newNetnsTestbed(t)
+	nl := netlink.NewNetlink()
+	tb.host.Run(t, func() error {
+		if err := nl.AddLink(&netlink.BridgeLink{
+			LinkInfo: netlink.LinkInfo{Type: netlink.LINK_TYPE_BRIDGE, Name: tb.extIf.BridgeName},
+		}); err != nil {
+			return err
+		}
+		return nl.SetLinkState(tb.extIf.BridgeName, true)
+	})
+
+	client := NewLinuxBridgeEndpointClient(tb.extIf, "azv1", "azv1-2", opModeBridge, nl, platform.NewExecClient())
+
+	podIP := net.IPNet{IP: net.ParseIP("10.240.0.10"), Mask: net.CIDRMask(16, ipv4Bits)}
+	epInfo := &EndpointInfo{
+		Id:          "c0ffee-eth0",
+		IfName:      "eth0",
+		NetNsPath:   tb.container.Path(),
+		IPAddresses: []net.IPNet{podIP},
+		Routes:      []RouteInfo{{Dst: net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, ipv4Bits)}, Gw: net.ParseIP("10.240.0.1")}},
+	}
+
+	tb.host.Run(t, func() error {
+		if err := client.AddEndpoints(epInfo); err != nil {
+			return err
+		}
+		return client.MoveEndpointsToContainerNS(epInfo, tb.container.Fd())
+	})
+	tb.container.Run(t, func() error {
+		if err := client.SetupContainerInterfaces(epInfo); err != nil {
+			return err
+		}
+		return client.ConfigureContainerInterfacesAndRoutes(epInfo)
+	})
+
+	require.True(t, tb.host.HasInterface(t, "azv1"))
+	require.Equal(t, client.containerMac, tb.container.Interface(t, "eth0").HardwareAddr)
+	tb.container.RequireRoute(t, "eth0", "10.240.0.0/16", "")
+	tb.container.RequireRoute(t, "eth0", defaultGwCidr, "10.240.0.1")
+
+	// the rules attach the host veth to the bridge and program ebtables in the host namespace
+	if nstest.HasCommand("ebtables") {
+		tb.host.Run(t, func() error {
+			return client.AddEndpointRules(epInfo)
+		})
+		require.Equal(t, tb.extIf.BridgeName, tb.host.LinkMaster(t, "azv1"))
+	}
+
+	ep := &endpoint{Id: epInfo.Id, IfName: "eth0", HostIfName: "azv1", IPAddresses: epInfo.IPAddresses, MacAddress: client.containerMac}
+	tb.host.Run(t, func() error {
+		return client.DeleteEndpoints(ep)
+	})
+	require.False(t, tb.host.HasInterface(t, "azv1"))
+	require.False(t, tb.container.HasInterface(t, "eth0"), "the container side goes with the veth pair")
+}
+
+func TestVlanTrunkEndpointClientNetns(t *testing.T) {
+	tb := newNetnsTestbed(t)
+	nl := netlink.NewNetlink()
+
+	// the fabric carries VLAN 100 of the trunk to its own sub-interface
+	fabricIf := tb.fabric.Interface(t, "eth0")
+	tb.fabric.Run(t, func() error {
+		err := nl.AddLink(&netlink.VlanLink{
+			LinkInfo: netlink.LinkInfo{Type: netlink.LINK_TYPE_VLAN, Name: "eth0.100", ParentIndex: fabricIf.Index},
+			VlanID:   100,
+		})
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("the kernel does not support VLAN devices")
+		}
+		if err != nil {
+			return err
+		}
+		return nl.SetLinkState("eth0.100", true)
+	})
+	tb.fabric.AddAddress(t, "eth0.100", "10.241.0.2/24")
+
+	client := NewVlanTrunkEndpointClient(tb.extIf, "azv1-2", 100, nl, platform.NewExecClient())
+
+	podIP := net.IPNet{IP: net.ParseIP("10.241.0.10"), Mask: net.CIDRMask(24, ipv4Bits)}
+	epInfo := &EndpointInfo{
+		Id:          "c0ffee-eth0",
+		IfName:      "eth0",
+		NetNsPath:   tb.container.Path(),
+		IPAddresses: []net.IPNet{podIP},
+		Routes:      []RouteInfo{{Dst: net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, ipv4Bits)}, Gw: net.ParseIP("10.241.0.1")}},
+	}
+
+	tb.host.Run(t, func() error {
+		if err := client.AddEndpoints(epInfo); err != nil {
+			return err
+		}
+		return client.MoveEndpointsToContainerNS(epInfo, tb.container.Fd())
+	})
+	tb.container.Run(t, func() error {
+		if err := client.SetupContainerInterfaces(epInfo); err != nil {
+			return err
+		}
+		return client.ConfigureContainerInterfacesAndRoutes(epInfo)
+	})
+
+	// the container has the VLAN sub-interface of the trunk, the host has nothing of the endpoint
+	require.False(t, tb.host.HasInterface(t, "azv1-2"))
+	tb.container.Run(t, func() error {
+		link, err := nl.GetLink("eth0")
+		if err != nil {
+			return err
+		}
+		require.IsType(t, &netlink.VlanLink{}, link)
+		require.Equal(t, uint16(100), link.(*netlink.VlanLink).VlanID)
+		return nil
+	})
+	tb.container.RequireRoute(t, "eth0", defaultGwCidr, "10.241.0.1")
+	tb.container.RequireNeighbor(t, "eth0", "10.241.0.1", azureMac)
+
+	// traffic of the container reaches the fabric on the VLAN
+	tb.container.Run(t, func() error {
+		conn, err := net.Dial("udp", "10.241.0.2:9")
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = conn.Write([]byte("ping"))
+		return err
+	})
+	require.Eventually(t, func() bool {
+		var neighbors []*netlink.Neighbor
+		tb.fabric.Run(t, func() (err error) {
+			neighbors, err = nl.ListNeighbors("eth0.100")
+			return err
+		})
+		for _, neigh := range neighbors {
+			if neigh.IP.Equal(podIP.IP) && neigh.HardwareAddr.String() == client.containerMac.String() {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond, "the fabric learns the pod on the VLAN")
+
+	// the endpoint is deleted from the container namespace, where it is found by its VLAN ID
+	ep := &endpoint{Id: epInfo.Id, IfName: "azv1-2", VlanID: 100, NetworkNameSpace: tb.container.Path()}
+	tb.host.Run(t, func() error {
+		return NewVlanTrunkEndpointClient(tb.extIf, ep.IfName, ep.VlanID, nl, platform.NewExecClient()).DeleteEndpoints(ep)
+	})
+	require.False(t, tb.container.HasInterface(t, "eth0"))
+}
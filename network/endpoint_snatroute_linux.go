@@ -3,6 +3,7 @@ package network
 import (
 	"fmt"
 
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/Azure/azure-container-networking/network/networkutils"
@@ -19,6 +20,18 @@ func GetSnatContIfName(epInfo *EndpointInfo) string {
 	return fmt.Sprintf("%s%s-2", snatVethInterfacePrefix, epInfo.Id[:7])
 }
 
+// snatFamily returns the IP families the snat rules of the endpoint are programmed for, so that IPv6
+// pods get the same protections as IPv4 pods.
+func snatFamily(epInfo *EndpointInfo) iptables.Family {
+	for _, ipAddr := range epInfo.IPAddresses {
+		if ipAddr.IP.To4() == nil {
+			return iptables.FamilyDual
+		}
+	}
+
+	return iptables.FamilyV4
+}
+
 func AddSnatEndpoint(snatClient *snat.Client) error {
 	if err := snatClient.CreateSnatEndpoint(); err != nil {
 		return errors.Wrap(err, "failed to add snat endpoint")
@@ -37,7 +50,7 @@ func AddSnatEndpointRules(snatClient *snat.Client, hostToNC, ncToHost bool, nl n
 		return errors.Wrap(err, "failed to block ip addresses on snat bridge")
 	}
 	nuc := networkutils.NewNetworkUtils(nl, plc)
-	if err := nuc.EnableIPForwarding(snat.SnatBridgeName); err != nil {
+	if err := nuc.EnableIPForwarding(snat.SnatBridgeName, snatClient.Family); err != nil {
 		return errors.Wrap(err, "failed to enable ip forwarding")
 	}
 
@@ -13,6 +13,7 @@ import (
 	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/Azure/azure-container-networking/network/policy"
 	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
 	"github.com/Microsoft/hcsshim"
 	"github.com/Microsoft/hcsshim/hcn"
 )
@@ -64,6 +65,9 @@ func ConstructEndpointID(containerID string, netNsPath string, ifName string) (s
 
 // newEndpointImpl creates a new endpoint in the network.
 func (nw *network) newEndpointImpl(cli apipaClient, _ netlink.NetlinkInterface, _ platform.ExecClient, epInfo *EndpointInfo) (*endpoint, error) {
+	// HNS programs the endpoint and its policies at once.
+	defer nw.phases.Start(telemetry.PhaseEndpointClient)()
+
 	if useHnsV2, err := UseHnsV2(epInfo.NetNsPath); useHnsV2 {
 		if err != nil {
 			return nil, err
@@ -93,6 +97,7 @@ func (nw *network) newEndpointImplHnsV1(epInfo *EndpointInfo) (*endpoint, error)
 		VirtualNetwork: nw.HnsId,
 		DNSSuffix:      epInfo.DNS.Suffix,
 		DNSServerList:  strings.Join(epInfo.DNS.Servers, ","),
+		DNSDomain:      epInfo.DNS.Domain,
 		Policies:       policy.SerializePolicies(policy.EndpointPolicy, epInfo.Policies, epInfo.Data, epInfo.EnableSnatForDns, epInfo.EnableMultiTenancy),
 	}
 
@@ -198,7 +203,8 @@ func (nw *network) configureHcnEndpoint(epInfo *EndpointInfo) (*hcn.HostComputeE
 		Name:               infraEpName,
 		HostComputeNetwork: nw.HnsId,
 		Dns: hcn.Dns{
-			Search:     strings.Split(epInfo.DNS.Suffix, ","),
+			Domain:     epInfo.DNS.Domain,
+			Search:     epInfo.DNS.Searches(),
 			ServerList: epInfo.DNS.Servers,
 			Options:    epInfo.DNS.Options,
 		},
@@ -218,6 +224,21 @@ func (nw *network) configureHcnEndpoint(epInfo *EndpointInfo) (*hcn.HostComputeE
 		return nil, err
 	}
 
+	// The outbound NAT of the network applies unless the endpoint has its own.
+	if !hasHcnEndpointPolicy(hcnEndpoint.Policies, hcn.OutBoundNAT) {
+		natPolicies, err := policy.GetHcnEndpointPolicies(policy.NetworkPolicy, nw.OutboundNATPolicies, epInfo.Data, epInfo.EnableSnatForDns, epInfo.EnableMultiTenancy, nil)
+		if err != nil {
+			log.Printf("[net] Failed to get outbound NAT policies of network %s due to error: %v", nw.Id, err)
+			return nil, err
+		}
+		hcnEndpoint.Policies = append(hcnEndpoint.Policies, natPolicies...)
+	}
+
+	if err := setOutboundNATExceptions(hcnEndpoint, epInfo.OutboundNATExceptions); err != nil {
+		log.Printf("[net] Failed to set outbound NAT exceptions due to error: %v", err)
+		return nil, err
+	}
+
 	for _, route := range epInfo.Routes {
 		hcnRoute := hcn.Route{
 			NextHop:           route.Gw.String(),
@@ -384,6 +405,7 @@ func (nw *network) newEndpointImplHnsV2(cli apipaClient, epInfo *EndpointInfo) (
 		AllowInboundFromNCToHost: epInfo.AllowInboundFromNCToHost,
 		AllowInboundFromHostToNC: epInfo.AllowInboundFromHostToNC,
 		NetworkContainerID:       epInfo.NetworkContainerID,
+		OutboundNATExceptions:    epInfo.OutboundNATExceptions,
 	}
 
 	for _, route := range epInfo.Routes {
@@ -397,6 +419,8 @@ func (nw *network) newEndpointImplHnsV2(cli apipaClient, epInfo *EndpointInfo) (
 
 // deleteEndpointImpl deletes an existing endpoint from the network.
 func (nw *network) deleteEndpointImpl(_ netlink.NetlinkInterface, _ platform.ExecClient, ep *endpoint) error {
+	defer nw.phases.Start(telemetry.PhaseEndpointClient)()
+
 	if useHnsV2, err := UseHnsV2(ep.NetNs); useHnsV2 {
 		if err != nil {
 			return err
@@ -408,6 +432,59 @@ func (nw *network) deleteEndpointImpl(_ netlink.NetlinkInterface, _ platform.Exe
 	return nw.deleteEndpointImplHnsV1(ep)
 }
 
+// cleanupEndpointImpl deletes the HNS endpoint of an endpoint which is not in the state, which is left behind by
+// an ADD that did not complete. HNS endpoints are named after the endpoint ID.
+func (nm *networkManager) cleanupEndpointImpl(epInfo *EndpointInfo) error {
+	if useHnsV2, err := UseHnsV2(epInfo.NetNsPath); useHnsV2 {
+		if err != nil {
+			return err
+		}
+
+		hcnEndpoint, err := Hnsv2.GetEndpointByName(epInfo.Id)
+		if err != nil {
+			if _, endpointNotFound := err.(hcn.EndpointNotFoundError); endpointNotFound {
+				log.Printf("[net] Skipping cleanup of hcn endpoint %s: %v", epInfo.Id, err)
+				return nil
+			}
+			return fmt.Errorf("failed to get hcn endpoint %s: %w", epInfo.Id, err)
+		}
+
+		return nm.deleteStaleHcnEndpoint(hcnEndpoint)
+	}
+
+	hnsEndpoint, err := Hnsv1.GetHNSEndpointByName(epInfo.Id)
+	if err != nil {
+		log.Printf("[net] Skipping cleanup of HNS endpoint %s: %v", epInfo.Id, err)
+		return nil
+	}
+
+	log.Printf("[net] Deleting HNS endpoint %s of endpoint %s.", hnsEndpoint.Id, epInfo.Id)
+	if _, err = Hnsv1.DeleteEndpoint(hnsEndpoint.Id); err != nil && !strings.Contains(strings.ToLower(err.Error()), "not found") {
+		return fmt.Errorf("failed to delete HNS endpoint %s: %w", hnsEndpoint.Id, err)
+	}
+
+	return nil
+}
+
+// deleteStaleHcnEndpoint removes the hcn endpoint from its namespace and deletes it.
+func (nm *networkManager) deleteStaleHcnEndpoint(hcnEndpoint *hcn.HostComputeEndpoint) error {
+	log.Printf("[net] Deleting hcn endpoint %s.", hcnEndpoint.Id)
+	if hcnEndpoint.HostComputeNamespace != "" {
+		if err := Hnsv2.RemoveNamespaceEndpoint(hcnEndpoint.HostComputeNamespace, hcnEndpoint.Id); err != nil {
+			log.Errorf("[net] Failed to remove hcn endpoint %s from namespace %s: %v", hcnEndpoint.Id, hcnEndpoint.HostComputeNamespace, err)
+		}
+	}
+
+	if err := Hnsv2.DeleteEndpoint(hcnEndpoint); err != nil {
+		if _, endpointNotFound := err.(hcn.EndpointNotFoundError); endpointNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to delete hcn endpoint %s: %w", hcnEndpoint.Id, err)
+	}
+
+	return nil
+}
+
 // deleteEndpointImplHnsV1 deletes an existing endpoint from the network using HNS v1.
 func (nw *network) deleteEndpointImplHnsV1(ep *endpoint) error {
 	log.Printf("[net] HNSEndpointRequest DELETE id:%v", ep.HnsId)
@@ -462,6 +539,10 @@ func (nw *network) deleteEndpointImplHnsV2(ep *endpoint) error {
 	}
 
 	if err = Hnsv2.DeleteEndpoint(hcnEndpoint); err != nil {
+		if _, endpointNotFound := err.(hcn.EndpointNotFoundError); endpointNotFound {
+			log.Printf("[net] hcn endpoint %s was deleted concurrently", ep.HnsId)
+			return nil
+		}
 		return fmt.Errorf("Failed to delete hcn endpoint: %s due to error: %v", ep.HnsId, err)
 	}
 
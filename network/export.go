@@ -0,0 +1,240 @@
+package network
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// NodeState is the network state of the node in a single document, as collected for support bundles. It holds
+// the state recorded by the network manager along with the live state of the host interfaces it refers to.
+type NodeState struct {
+	Version            string
+	TimeStamp          time.Time
+	ExportedAt         time.Time
+	ExternalInterfaces []ExternalInterfaceState
+	// IPTables summarizes the iptables tables on Linux.
+	IPTables []iptables.TableSummary `json:",omitempty"`
+	// Errors are the parts of the live state which could not be collected.
+	Errors []string `json:",omitempty"`
+}
+
+// LinkState is the live state of a host interface.
+type LinkState struct {
+	Name       string
+	Exists     bool
+	Up         bool   `json:",omitempty"`
+	MTU        int    `json:",omitempty"`
+	MacAddress string `json:",omitempty"`
+}
+
+// ExternalInterfaceState is the state of an external interface and its networks.
+type ExternalInterfaceState struct {
+	Name        string
+	Subnets     []string
+	MacAddress  string
+	IPAddresses []string
+	IPv4Gateway string `json:",omitempty"`
+	IPv6Gateway string `json:",omitempty"`
+	Removed     bool   `json:",omitempty"`
+	Link        LinkState
+	// Bridge is the bridge the external interface is attached to, if any.
+	Bridge         *LinkState      `json:",omitempty"`
+	VlanInterfaces []vlanInterface `json:",omitempty"`
+	Networks       []NetworkState
+}
+
+// SNATState is the SNAT configuration of a network or endpoint.
+type SNATState struct {
+	EnableSnatOnHost bool
+	SnatBridgeIP     string `json:",omitempty"`
+	LocalIP          string `json:",omitempty"`
+}
+
+// NetworkState is the state of a network and its endpoints.
+type NetworkState struct {
+	Id         string
+	HnsId      string `json:",omitempty"`
+	Mode       string
+	VlanId     int    `json:",omitempty"`
+	VNI        int    `json:",omitempty"`
+	BridgeName string `json:",omitempty"`
+	Subnets    []string
+	DNS        DNSInfo
+	SNAT       SNATState
+	Endpoints  []EndpointState
+}
+
+// EndpointState is the state of an endpoint, including the live state of its host side veth.
+type EndpointState struct {
+	Id           string
+	HnsId        string `json:",omitempty"`
+	ContainerID  string
+	PodName      string `json:",omitempty"`
+	PodNamespace string `json:",omitempty"`
+	NetNs        string `json:",omitempty"`
+	IfName       string
+	MacAddress   string `json:",omitempty"`
+	IPAddresses  []string
+	Gateways     []string    `json:",omitempty"`
+	Routes       []RouteInfo `json:",omitempty"`
+	VlanID       int         `json:",omitempty"`
+	SNAT         SNATState
+	// HostVeth is the host side veth of the endpoint, which Windows endpoints don't have.
+	HostVeth *LinkState `json:",omitempty"`
+}
+
+// ExportState returns the network state of the node. Failures to collect the live state are recorded in the
+// document rather than returned, so that a partial state is still exported.
+func (nm *networkManager) ExportState() (*NodeState, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	state := &NodeState{
+		Version:    nm.Version,
+		TimeStamp:  nm.TimeStamp,
+		ExportedAt: time.Now(),
+	}
+
+	for _, extIf := range nm.ExternalInterfaces {
+		state.ExternalInterfaces = append(state.ExternalInterfaces, nm.exportExternalInterface(extIf))
+	}
+	sort.Slice(state.ExternalInterfaces, func(i, j int) bool {
+		return state.ExternalInterfaces[i].Name < state.ExternalInterfaces[j].Name
+	})
+
+	nm.exportHostStateImpl(state)
+
+	return state, nil
+}
+
+func (nm *networkManager) exportExternalInterface(extIf *externalInterface) ExternalInterfaceState {
+	extIfState := ExternalInterfaceState{
+		Name:        extIf.Name,
+		Subnets:     extIf.Subnets,
+		MacAddress:  extIf.MacAddress.String(),
+		IPv4Gateway: ipString(extIf.IPv4Gateway),
+		IPv6Gateway: ipString(extIf.IPv6Gateway),
+		Removed:     extIf.Removed,
+		Link:        nm.exportLink(extIf.Name),
+	}
+
+	for _, ipAddr := range extIf.IPAddresses {
+		extIfState.IPAddresses = append(extIfState.IPAddresses, ipAddr.String())
+	}
+
+	if extIf.BridgeName != "" {
+		bridge := nm.exportLink(extIf.BridgeName)
+		extIfState.Bridge = &bridge
+	}
+
+	for _, vlanIf := range extIf.VlanInterfaces {
+		extIfState.VlanInterfaces = append(extIfState.VlanInterfaces, *vlanIf)
+	}
+	sort.Slice(extIfState.VlanInterfaces, func(i, j int) bool {
+		return extIfState.VlanInterfaces[i].VlanID < extIfState.VlanInterfaces[j].VlanID
+	})
+
+	for _, nw := range extIf.Networks {
+		extIfState.Networks = append(extIfState.Networks, nm.exportNetwork(nw))
+	}
+	sort.Slice(extIfState.Networks, func(i, j int) bool { return extIfState.Networks[i].Id < extIfState.Networks[j].Id })
+
+	return extIfState
+}
+
+func (nm *networkManager) exportNetwork(nw *network) NetworkState {
+	nwState := NetworkState{
+		Id:         nw.Id,
+		HnsId:      nw.HnsId,
+		Mode:       nw.Mode,
+		VlanId:     nw.VlanId,
+		VNI:        nw.VNI,
+		BridgeName: nw.BridgeName,
+		DNS:        nw.DNS,
+		SNAT: SNATState{
+			EnableSnatOnHost: nw.EnableSnatOnHost,
+			SnatBridgeIP:     nw.SnatBridgeIP,
+		},
+	}
+
+	for i := range nw.Subnets {
+		nwState.Subnets = append(nwState.Subnets, nw.Subnets[i].Prefix.String())
+	}
+
+	for _, ep := range nw.Endpoints {
+		nwState.Endpoints = append(nwState.Endpoints, nm.exportEndpoint(ep))
+	}
+	sort.Slice(nwState.Endpoints, func(i, j int) bool { return nwState.Endpoints[i].Id < nwState.Endpoints[j].Id })
+
+	return nwState
+}
+
+func (nm *networkManager) exportEndpoint(ep *endpoint) EndpointState {
+	epState := EndpointState{
+		Id:           ep.Id,
+		HnsId:        ep.HnsId,
+		ContainerID:  ep.ContainerID,
+		PodName:      ep.PODName,
+		PodNamespace: ep.PODNameSpace,
+		NetNs:        ep.NetNs,
+		IfName:       ep.IfName,
+		Routes:       ep.Routes,
+		VlanID:       ep.VlanID,
+		SNAT: SNATState{
+			EnableSnatOnHost: ep.EnableSnatOnHost,
+			LocalIP:          ep.LocalIP,
+		},
+	}
+
+	if ep.MacAddress != nil {
+		epState.MacAddress = ep.MacAddress.String()
+	}
+
+	for i := range ep.IPAddresses {
+		epState.IPAddresses = append(epState.IPAddresses, ep.IPAddresses[i].String())
+	}
+
+	for _, gw := range ep.Gateways {
+		epState.Gateways = append(epState.Gateways, gw.String())
+	}
+
+	if ep.HostIfName != "" {
+		hostVeth := nm.exportLink(ep.HostIfName)
+		epState.HostVeth = &hostVeth
+	}
+
+	return epState
+}
+
+// exportLink returns the live state of the host interface. An interface which can't be found is reported as
+// missing, which is what a support engineer looks for when an endpoint has no connectivity.
+func (nm *networkManager) exportLink(ifName string) LinkState {
+	link := LinkState{Name: ifName}
+
+	iface, err := nm.netio.GetNetworkInterfaceByName(ifName)
+	if err != nil {
+		log.Printf("[net] Failed to get interface %s for the state export: %v", ifName, err)
+		return link
+	}
+
+	link.Exists = true
+	link.Up = iface.Flags&net.FlagUp != 0
+	link.MTU = iface.MTU
+	if iface.HardwareAddr != nil {
+		link.MacAddress = iface.HardwareAddr.String()
+	}
+
+	return link
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	return ip.String()
+}
@@ -0,0 +1,20 @@
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/iptables"
+)
+
+// exportHostStateImpl adds the summary of the iptables tables which the plugin programs rules in.
+func (nm *networkManager) exportHostStateImpl(state *NodeState) {
+	for _, version := range []string{iptables.V4, iptables.V6} {
+		for _, table := range []string{iptables.Filter, iptables.Nat, iptables.Mangle} {
+			summary, err := iptables.Summarize(version, table)
+			if err != nil {
+				state.Errors = append(state.Errors, err.Error())
+				continue
+			}
+
+			state.IPTables = append(state.IPTables, *summary)
+		}
+	}
+}
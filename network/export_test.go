@@ -0,0 +1,65 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportExternalInterface(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.240.0.0/16")
+	extIf := &externalInterface{
+		Name:        "eth0",
+		Subnets:     []string{"10.240.0.0/16"},
+		BridgeName:  "azure0",
+		IPAddresses: []*net.IPNet{{IP: net.ParseIP("10.240.0.4"), Mask: subnet.Mask}},
+		IPv4Gateway: net.ParseIP("10.240.0.1"),
+		Networks:    make(map[string]*network),
+	}
+	extIf.Networks["azure"] = &network{
+		Id:               "azure",
+		Mode:             opModeBridge,
+		Subnets:          []SubnetInfo{{Prefix: *subnet}},
+		EnableSnatOnHost: true,
+		SnatBridgeIP:     "169.254.0.1/16",
+		Endpoints: map[string]*endpoint{
+			"b": {Id: "b", HnsId: "hns-b", IPAddresses: []net.IPNet{{IP: net.ParseIP("10.240.0.6"), Mask: subnet.Mask}}},
+			"a": {
+				Id:          "a",
+				ContainerID: "container-a",
+				PODName:     "pod-a",
+				HostIfName:  "azv1",
+				IPAddresses: []net.IPNet{{IP: net.ParseIP("10.240.0.5"), Mask: subnet.Mask}},
+				Gateways:    []net.IP{net.ParseIP("10.240.0.1")},
+			},
+		},
+	}
+
+	// the host veth of endpoint a is missing
+	nm := &networkManager{netio: netio.NewMockNetIO(true, 3)}
+	extIfState := nm.exportExternalInterface(extIf)
+
+	require.Equal(t, "eth0", extIfState.Name)
+	require.Equal(t, []string{"10.240.0.4/16"}, extIfState.IPAddresses)
+	require.Equal(t, "10.240.0.1", extIfState.IPv4Gateway)
+	require.True(t, extIfState.Link.Exists)
+	require.NotNil(t, extIfState.Bridge)
+	require.Equal(t, "azure0", extIfState.Bridge.Name)
+	require.Equal(t, 1000, extIfState.Bridge.MTU)
+
+	require.Len(t, extIfState.Networks, 1)
+	nwState := extIfState.Networks[0]
+	require.Equal(t, []string{"10.240.0.0/16"}, nwState.Subnets)
+	require.Equal(t, SNATState{EnableSnatOnHost: true, SnatBridgeIP: "169.254.0.1/16"}, nwState.SNAT)
+
+	require.Len(t, nwState.Endpoints, 2)
+	epState := nwState.Endpoints[0]
+	require.Equal(t, "a", epState.Id)
+	require.Equal(t, "pod-a", epState.PodName)
+	require.Equal(t, []string{"10.240.0.5/16"}, epState.IPAddresses)
+	require.Equal(t, []string{"10.240.0.1"}, epState.Gateways)
+	require.Equal(t, &LinkState{Name: "azv1"}, epState.HostVeth)
+	require.Nil(t, nwState.Endpoints[1].HostVeth)
+}
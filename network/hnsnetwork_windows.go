@@ -0,0 +1,162 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network/policy"
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+var errHcnNetworkInUse = errors.New("hcn network has endpoints attached")
+
+// hcnNetworkDrift returns the settings of the existing hcn network which differ from the desired one. Only the
+// settings which the CNI config sets are compared, HNS adds defaults to the others.
+func hcnNetworkDrift(existing, desired *hcn.HostComputeNetwork) []string {
+	var drift []string
+	if existing.Type != desired.Type {
+		drift = append(drift, fmt.Sprintf("type %s, want %s", existing.Type, desired.Type))
+	}
+
+	if got, want := hcnSubnets(existing), hcnSubnets(desired); !reflect.DeepEqual(got, want) {
+		drift = append(drift, fmt.Sprintf("subnets %v, want %v", got, want))
+	}
+
+	if got, want := sortedCopy(existing.Dns.ServerList), sortedCopy(desired.Dns.ServerList); !reflect.DeepEqual(got, want) {
+		drift = append(drift, fmt.Sprintf("dns servers %v, want %v", got, want))
+	}
+
+	if existing.Dns.Domain != desired.Dns.Domain {
+		drift = append(drift, fmt.Sprintf("dns domain %q, want %q", existing.Dns.Domain, desired.Dns.Domain))
+	}
+
+	return drift
+}
+
+// hcnSubnets returns the prefix, gateway and isolation policies of each subnet of the network, sorted by prefix.
+func hcnSubnets(hcnNetwork *hcn.HostComputeNetwork) []string {
+	subnets := []string{}
+	for _, ipam := range hcnNetwork.Ipams {
+		for _, subnet := range ipam.Subnets {
+			var nextHops []string
+			for _, route := range subnet.Routes {
+				nextHops = append(nextHops, route.NextHop)
+			}
+			var policies []string
+			for _, subnetPolicy := range subnet.Policies {
+				policies = append(policies, subnetIsolation(subnetPolicy))
+			}
+			subnets = append(subnets, fmt.Sprintf("%s via %v %v", subnet.IpAddressPrefix, sortedCopy(nextHops), sortedCopy(policies)))
+		}
+	}
+	sort.Strings(subnets)
+	return subnets
+}
+
+// subnetIsolation returns the VLAN or VSID of a subnet policy, e.g. "VLAN:100", so the policies compare equal
+// however HNS formats them.
+func subnetIsolation(rawPolicy json.RawMessage) string {
+	var subnetPolicy hcn.SubnetPolicy
+	if err := json.Unmarshal(rawPolicy, &subnetPolicy); err != nil {
+		return string(rawPolicy)
+	}
+
+	// the VLAN and VSID settings have the same layout
+	var setting hcn.VlanPolicySetting
+	if err := json.Unmarshal(subnetPolicy.Settings, &setting); err != nil {
+		return string(rawPolicy)
+	}
+
+	return fmt.Sprintf("%s:%d", subnetPolicy.Type, setting.IsolationId)
+}
+
+func sortedCopy(values []string) []string {
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// checkHcnNetworkUnused returns an error wrapping errHcnNetworkInUse if any endpoint is attached to the network,
+// including the ones which other agents created, since deleting the network would disconnect them.
+func checkHcnNetworkUnused(hcnNetwork *hcn.HostComputeNetwork) error {
+	endpoints, err := Hnsv2.ListEndpointsOfNetwork(hcnNetwork.Id)
+	if err != nil {
+		return fmt.Errorf("failed to list endpoints of hcn network %s: %w", hcnNetwork.Name, err)
+	}
+
+	if len(endpoints) > 0 {
+		return fmt.Errorf("%w: %d endpoints on %s", errHcnNetworkInUse, len(endpoints), hcnNetwork.Name)
+	}
+
+	return nil
+}
+
+// reconcileHcnNetwork creates the hcn network if it doesn't exist, and recreates it if its settings drifted from
+// the desired ones and no endpoint is attached. A network in use is kept as is, since recreating it would
+// disconnect its endpoints.
+func (nm *networkManager) reconcileHcnNetwork(desired *hcn.HostComputeNetwork) (*hcn.HostComputeNetwork, error) {
+	existing, err := Hnsv2.GetNetworkByName(desired.Name)
+	if err != nil {
+		// we can't validate if the network already exists, don't continue
+		if !errors.As(err, &hcn.NetworkNotFoundError{}) {
+			return nil, fmt.Errorf("Failed to create hcn network: %s, failed to query for existing network with error: %v", desired.Name, err)
+		}
+
+		return createHcnNetwork(desired)
+	}
+
+	drift := hcnNetworkDrift(existing, desired)
+	if len(drift) == 0 {
+		log.Printf("[net] Network with name %s already exists", desired.Name)
+		return existing, nil
+	}
+
+	if err := checkHcnNetworkUnused(existing); err != nil {
+		log.Printf("[net] Keeping hcn network %s whose settings drifted: %v: %v", desired.Name, drift, err)
+		return existing, nil
+	}
+
+	log.Printf("[net] Recreating hcn network %s whose settings drifted: %v", desired.Name, drift)
+	if err := Hnsv2.DeleteNetwork(existing); err != nil {
+		return nil, fmt.Errorf("Failed to delete drifted hcn network: %s due to error: %v", desired.Name, err)
+	}
+
+	return createHcnNetwork(desired)
+}
+
+func createHcnNetwork(hcnNetwork *hcn.HostComputeNetwork) (*hcn.HostComputeNetwork, error) {
+	log.Printf("[net] Creating hcn network: %+v", hcnNetwork)
+	hnsResponse, err := Hnsv2.CreateNetwork(hcnNetwork)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create hcn network: %s due to error: %v", hcnNetwork.Name, err)
+	}
+
+	log.Printf("[net] Successfully created hcn network with response: %+v", hnsResponse)
+	return hnsResponse, nil
+}
+
+// outboundNATNetworkPolicies returns the OutBoundNAT policies among the network policies. HNSv2 networks have no
+// outbound NAT, so they are applied to the endpoints of the network.
+func outboundNATNetworkPolicies(policies []policy.Policy) []policy.Policy {
+	var natPolicies []policy.Policy
+	for _, p := range policies {
+		if p.Type == policy.NetworkPolicy && policy.GetPolicyType(p) == policy.OutBoundNatPolicy {
+			natPolicies = append(natPolicies, p)
+		}
+	}
+	return natPolicies
+}
+
+func hasHcnEndpointPolicy(policies []hcn.EndpointPolicy, policyType hcn.EndpointPolicyType) bool {
+	for _, p := range policies {
+		if p.Type == policyType {
+			return true
+		}
+	}
+	return false
+}
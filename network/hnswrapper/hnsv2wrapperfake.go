@@ -57,7 +57,11 @@ func (f Hnsv2wrapperFake) CreateNetwork(network *hcn.HostComputeNetwork) (*hcn.H
 }
 
 func (f Hnsv2wrapperFake) DeleteNetwork(network *hcn.HostComputeNetwork) error {
+	f.Lock()
+	defer f.Unlock()
+
 	delayHnsCall(f.Delay)
+	delete(f.Cache.networks, network.Name)
 	return nil
 }
 
@@ -185,7 +189,7 @@ func (f Hnsv2wrapperFake) GetNetworkByName(networkName string) (*hcn.HostCompute
 	if network, ok := f.Cache.networks[networkName]; ok {
 		return network.GetHCNObj(), nil
 	}
-	return nil, hcn.NetworkNotFoundError{}
+	return nil, hcn.NetworkNotFoundError{NetworkName: networkName}
 }
 
 func (f Hnsv2wrapperFake) GetNetworkByID(networkID string) (*hcn.HostComputeNetwork, error) {
@@ -312,7 +316,14 @@ func (f Hnsv2wrapperFake) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint,
 }
 
 func (f Hnsv2wrapperFake) GetEndpointByName(endpointName string) (*hcn.HostComputeEndpoint, error) {
+	f.Lock()
+	defer f.Unlock()
 	delayHnsCall(f.Delay)
+	for _, endpoint := range f.Cache.endpoints {
+		if endpoint.Name == endpointName {
+			return endpoint.GetHCNObj(), nil
+		}
+	}
 	return nil, hcn.EndpointNotFoundError{EndpointName: endpointName}
 }
 
@@ -406,8 +417,11 @@ func (fCache FakeHNSCache) EndpointIP(id string) string {
 }
 
 type FakeHostComputeNetwork struct {
-	ID   string
-	Name string
+	ID    string
+	Name  string
+	Type  hcn.NetworkType
+	Ipams []hcn.Ipam
+	Dns   hcn.Dns
 	// Policies maps SetPolicy ID to SetPolicy object
 	Policies map[string]*hcn.SetPolicySetting
 }
@@ -416,6 +430,9 @@ func NewFakeHostComputeNetwork(network *hcn.HostComputeNetwork) *FakeHostCompute
 	return &FakeHostComputeNetwork{
 		ID:       network.Id,
 		Name:     network.Name,
+		Type:     network.Type,
+		Ipams:    network.Ipams,
+		Dns:      network.Dns,
 		Policies: make(map[string]*hcn.SetPolicySetting),
 	}
 }
@@ -446,6 +463,9 @@ func (fNetwork *FakeHostComputeNetwork) GetHCNObj() *hcn.HostComputeNetwork {
 	return &hcn.HostComputeNetwork{
 		Id:       fNetwork.ID,
 		Name:     fNetwork.Name,
+		Type:     fNetwork.Type,
+		Ipams:    fNetwork.Ipams,
+		Dns:      fNetwork.Dns,
 		Policies: setPolicies,
 	}
 }
@@ -456,6 +476,8 @@ type FakeHostComputeEndpoint struct {
 	HostComputeNetwork string
 	Policies           []*FakeEndpointPolicy
 	IPConfiguration    string
+	// Flags tells the local endpoints from the remote ones
+	Flags hcn.EndpointFlags
 }
 
 func NewFakeHostComputeEndpoint(endpoint *hcn.HostComputeEndpoint) *FakeHostComputeEndpoint {
@@ -468,6 +490,7 @@ func NewFakeHostComputeEndpoint(endpoint *hcn.HostComputeEndpoint) *FakeHostComp
 		Name:               endpoint.Name,
 		HostComputeNetwork: endpoint.HostComputeNetwork,
 		IPConfiguration:    ip,
+		Flags:              endpoint.Flags,
 	}
 }
 
@@ -505,6 +528,7 @@ func (fEndpoint *FakeHostComputeEndpoint) GetHCNObj() *hcn.HostComputeEndpoint {
 			},
 		},
 		Policies: acls,
+		Flags:    fEndpoint.Flags,
 	}
 }
 
@@ -10,17 +10,22 @@ import (
 
 	cnms "github.com/Azure/azure-container-networking/cnms/cnmspackage"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/conntrack"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/vxlan"
+	"github.com/Azure/azure-container-networking/network/wireguard"
 	"github.com/Azure/azure-container-networking/platform"
 	"github.com/Azure/azure-container-networking/store"
+	"github.com/Azure/azure-container-networking/telemetry"
 )
 
 const (
 	// Network store key.
 	storeKey    = "Network"
 	VlanIDKey   = "VlanID"
+	VNIKey      = "VNI"
 	AzureCNS    = "azure-cns"
 	SNATIPKey   = "NCPrimaryIPKey"
 	RoutesKey   = "RoutesKey"
@@ -57,10 +62,18 @@ type networkManager struct {
 	Version            string
 	TimeStamp          time.Time
 	ExternalInterfaces map[string]*externalInterface
-	store              store.KeyValueStore
+	store              platform.KeyValueStore
 	netlink            netlink.NetlinkInterface
 	netio              netio.NetIOInterface
 	plClient           platform.ExecClient
+	// lockStorePerAccess is set if the store is locked only while it is accessed. The state read last is
+	// kept in persisted to merge the changes of this process into the state saved by other processes.
+	lockStorePerAccess bool
+	persisted          map[string]*externalInterface
+	// phases times the phases of the command being run for its latency breakdown.
+	phases *telemetry.PhaseTimer
+	// conntrack flushes the conntrack entries of deleted endpoints.
+	conntrack conntrackClient
 	sync.Mutex
 }
 
@@ -68,8 +81,11 @@ type networkManager struct {
 type NetworkManager interface {
 	Initialize(config *common.PluginConfig, isRehydrationRequired bool) error
 	Uninitialize()
+	Reload() error
+	SetPhaseTimer(phases *telemetry.PhaseTimer)
 
 	AddExternalInterface(ifName string, subnet string) error
+	RefreshExternalInterfaces() error
 
 	CreateNetwork(nwInfo *NetworkInfo) error
 	DeleteNetwork(networkID string) error
@@ -79,13 +95,26 @@ type NetworkManager interface {
 
 	CreateEndpoint(client apipaClient, networkID string, epInfo *EndpointInfo) error
 	DeleteEndpoint(networkID string, endpointID string) error
+	CleanupEndpoint(epInfo *EndpointInfo) error
+	AddEndpointState(nwInfo *NetworkInfo, epInfo *EndpointInfo) error
 	GetEndpointInfo(networkID string, endpointID string) (*EndpointInfo, error)
+	GetEndpointStats(networkID string, endpointID string) (*InterfaceStats, error)
+	CheckEndpoint(networkID string, endpointID string, netNsPath string, ifName string) error
+	RepairEndpoint(networkID string, endpointID string, dryRun bool) ([]string, error)
+	RemoveEndpointState(networkID string, endpointID string) error
+	UpdateOutboundNATExceptions(networkID string, exceptions []string) error
 	GetAllEndpoints(networkID string) (map[string]*EndpointInfo, error)
 	GetEndpointInfoBasedOnPODDetails(networkID string, podName string, podNameSpace string, doExactMatchForPodName bool) (*EndpointInfo, error)
 	AttachEndpoint(networkID string, endpointID string, sandboxKey string) (*endpoint, error)
 	DetachEndpoint(networkID string, endpointID string) error
 	UpdateEndpoint(networkID string, existingEpInfo *EndpointInfo, targetEpInfo *EndpointInfo) error
 	GetNumberOfEndpoints(ifName string, networkID string) int
+	GetWireguardPublicKey(networkID string) (string, error)
+	SyncWireguardPeers(networkID string, peers []wireguard.Peer) error
+	RotateWireguardKey(networkID string, maxAge time.Duration) (bool, error)
+	GetOverlayVtepMAC(networkID string) (net.HardwareAddr, error)
+	SyncOverlayPeers(networkID string, peers []vxlan.Peer) error
+	ExportState() (*NodeState, error)
 	SetupNetworkUsingState(networkMonitor *cnms.NetworkMonitor) error
 }
 
@@ -96,6 +125,7 @@ func NewNetworkManager(nl netlink.NetlinkInterface, plc platform.ExecClient, net
 		netlink:            nl,
 		plClient:           plc,
 		netio:              netioCli,
+		conntrack:          conntrack.New(),
 	}
 
 	return nm, nil
@@ -105,16 +135,36 @@ func NewNetworkManager(nl netlink.NetlinkInterface, plc platform.ExecClient, net
 func (nm *networkManager) Initialize(config *common.PluginConfig, isRehydrationRequired bool) error {
 	nm.Version = config.Version
 	nm.store = config.Store
+	nm.lockStorePerAccess = config.LockStorePerAccess
 
 	// Restore persisted state.
-	err := nm.restore(isRehydrationRequired)
-	return err
+	if err := nm.restore(isRehydrationRequired); err != nil {
+		return err
+	}
+
+	nm.gcOrphanFlowsImpl()
+	return nil
 }
 
 // Uninitialize cleans up network manager.
 func (nm *networkManager) Uninitialize() {
 }
 
+// SetPhaseTimer sets the timer of the phases of the endpoint operations which follow.
+func (nm *networkManager) SetPhaseTimer(phases *telemetry.PhaseTimer) {
+	nm.phases = phases
+}
+
+// Reload reads the state from the persistent store again, other processes may have changed it since it was read.
+func (nm *networkManager) Reload() error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nm.ExternalInterfaces = make(map[string]*externalInterface)
+
+	return nm.restore(false)
+}
+
 // Restore reads network manager state from persistent store.
 func (nm *networkManager) restore(isRehydrationRequired bool) error {
 	// Skip if a store is not provided.
@@ -123,6 +173,32 @@ func (nm *networkManager) restore(isRehydrationRequired bool) error {
 		return nil
 	}
 
+	if !nm.lockStorePerAccess {
+		return nm.restoreState(isRehydrationRequired)
+	}
+
+	if err := nm.store.Lock(store.DefaultLockTimeout); err != nil {
+		log.Printf("[net] Failed to lock store, err:%v\n", err)
+		return err
+	}
+
+	defer func() {
+		if err := nm.store.Unlock(); err != nil {
+			log.Printf("[net] Failed to unlock store, err:%v\n", err)
+		}
+	}()
+
+	if err := nm.restoreState(isRehydrationRequired); err != nil {
+		return err
+	}
+
+	nm.persisted = copyExternalInterfaces(nm.ExternalInterfaces)
+
+	return nil
+}
+
+// restoreState reads the state of the network manager from the store.
+func (nm *networkManager) restoreState(isRehydrationRequired bool) error {
 	rebooted := false
 	// After a reboot, all address resources are implicitly released.
 	// Ignore the persisted state if it is older than the last reboot time.
@@ -177,9 +253,13 @@ func (nm *networkManager) restore(isRehydrationRequired bool) error {
 		}
 	}
 	// Populate pointers.
-	for _, extIf := range nm.ExternalInterfaces {
-		for _, nw := range extIf.Networks {
-			nw.extIf = extIf
+	nm.populatePointers()
+
+	// A store restored from a backup may still record endpoints which were deleted after the backup was taken.
+	if rs, ok := nm.store.(store.RecoverableStore); ok && rs.Recovered() && !rebooted {
+		if err := nm.reconcileEndpoints(); err != nil {
+			log.Printf("[net] Failed to reconcile restored state, err:%v\n", err)
+			return err
 		}
 	}
 
@@ -222,6 +302,26 @@ func (nm *networkManager) save() error {
 		return nil
 	}
 
+	defer nm.phases.Start(telemetry.PhaseStoreSave)()
+
+	if nm.lockStorePerAccess {
+		if err := nm.store.Lock(store.DefaultLockTimeout); err != nil {
+			log.Printf("[net] Failed to lock store, err:%v\n", err)
+			return err
+		}
+
+		defer func() {
+			if err := nm.store.Unlock(); err != nil {
+				log.Printf("[net] Failed to unlock store, err:%v\n", err)
+			}
+		}()
+
+		if err := nm.mergePersistedState(); err != nil {
+			log.Printf("[net] Failed to merge state, err:%v\n", err)
+			return err
+		}
+	}
+
 	// Update time stamp.
 	nm.TimeStamp = time.Now()
 
@@ -231,6 +331,11 @@ func (nm *networkManager) save() error {
 	} else {
 		log.Printf("[net] Save failed, err:%v\n", err)
 	}
+
+	if err == nil && nm.lockStorePerAccess {
+		nm.persisted = copyExternalInterfaces(nm.ExternalInterfaces)
+	}
+
 	return err
 }
 
@@ -258,6 +363,19 @@ func (nm *networkManager) AddExternalInterface(ifName string, subnet string) err
 	return nil
 }
 
+// RefreshExternalInterfaces updates the external interfaces to the NICs attached to the host at runtime, tearing down
+// the networks of the detached ones.
+func (nm *networkManager) RefreshExternalInterfaces() error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	if !nm.refreshExternalInterfaces() {
+		return nil
+	}
+
+	return nm.save()
+}
+
 // CreateNetwork creates a new container network.
 func (nm *networkManager) CreateNetwork(nwInfo *NetworkInfo) error {
 	nm.Lock()
@@ -332,6 +450,13 @@ func (nm *networkManager) CreateEndpoint(cli apipaClient, networkID string, epIn
 		return err
 	}
 
+	if nw.extIf == nil {
+		return errInterfaceNotFound
+	}
+	if nw.extIf.Removed {
+		return &interfaceRemovedError{ifName: nw.extIf.Name}
+	}
+
 	if nw.VlanId != 0 {
 		if epInfo.Data[VlanIDKey] == nil {
 			log.Printf("overriding endpoint vlanid with network vlanid")
@@ -339,6 +464,7 @@ func (nm *networkManager) CreateEndpoint(cli apipaClient, networkID string, epIn
 		}
 	}
 
+	nw.phases = nm.phases
 	_, err = nw.newEndpoint(cli, nm.netlink, nm.plClient, epInfo)
 	if err != nil {
 		return err
@@ -362,11 +488,22 @@ func (nm *networkManager) DeleteEndpoint(networkID, endpointID string) error {
 		return err
 	}
 
+	vlanID := 0
+	if ep, epErr := nw.getEndpoint(endpointID); epErr == nil {
+		vlanID = ep.VlanID
+	}
+
+	nw.phases = nm.phases
+	nw.conntrack = nm.conntrack
 	err = nw.deleteEndpoint(nm.netlink, nm.plClient, endpointID)
 	if err != nil {
 		return err
 	}
 
+	if nw.extIf != nil {
+		nm.releaseVlanInterface(nw.extIf, vlanID)
+	}
+
 	err = nm.save()
 	if err != nil {
 		return err
@@ -375,6 +512,84 @@ func (nm *networkManager) DeleteEndpoint(networkID, endpointID string) error {
 	return nil
 }
 
+// CleanupEndpoint removes what remains of an endpoint which is not in the state, such as the interfaces created by
+// an ADD which did not complete. Resources which are already gone are skipped.
+func (nm *networkManager) CleanupEndpoint(epInfo *EndpointInfo) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	return nm.cleanupEndpointImpl(epInfo)
+}
+
+// RemoveEndpointState removes the record of an endpoint from the state without deleting its interfaces, for
+// endpoints whose deletion fails, e.g. because HNS lost them.
+func (nm *networkManager) RemoveEndpointState(networkID, endpointID string) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return err
+	}
+
+	if _, err = nw.getEndpoint(endpointID); err != nil {
+		return err
+	}
+
+	delete(nw.Endpoints, endpointID)
+	return nm.save()
+}
+
+// AddEndpointState adds an endpoint whose state is kept outside of the network manager, such as by CNS in
+// stateless CNI mode, so that it can be checked and deleted. The network of the endpoint is only known by its
+// mode and master interface, which is all that is needed to delete its endpoints. Nothing is saved.
+func (nm *networkManager) AddEndpointState(nwInfo *NetworkInfo, epInfo *EndpointInfo) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(nwInfo.Id)
+	if err != nil {
+		extIf, ok := nm.ExternalInterfaces[nwInfo.MasterIfName]
+		if !ok {
+			extIf = &externalInterface{
+				Name:       nwInfo.MasterIfName,
+				BridgeName: nwInfo.BridgeName,
+				Networks:   make(map[string]*network),
+			}
+			nm.ExternalInterfaces[extIf.Name] = extIf
+		}
+
+		nw = &network{
+			Id:        nwInfo.Id,
+			Mode:      nwInfo.Mode,
+			Endpoints: make(map[string]*endpoint),
+			extIf:     extIf,
+			NetNs:     nwInfo.NetNs,
+		}
+		extIf.Networks[nw.Id] = nw
+	}
+
+	nw.Endpoints[epInfo.Id] = &endpoint{
+		Id:               epInfo.Id,
+		HnsId:            epInfo.HNSEndpointID,
+		IfName:           epInfo.IfName,
+		HostIfName:       epInfo.HostIfName,
+		MacAddress:       epInfo.MacAddress,
+		IPAddresses:      epInfo.IPAddresses,
+		Gateways:         epInfo.Gateways,
+		Routes:           epInfo.Routes,
+		NetworkNameSpace: epInfo.NetNsPath,
+		ContainerID:      epInfo.ContainerID,
+		PODName:          epInfo.PODName,
+		PODNameSpace:     epInfo.PODNameSpace,
+		NetNs:            epInfo.NetNsPath,
+	}
+
+	log.Printf("[net] Added endpoint %s of network %s from external state.", epInfo.Id, nwInfo.Id)
+
+	return nil
+}
+
 // GetEndpointInfo returns information about the given endpoint.
 func (nm *networkManager) GetEndpointInfo(networkId string, endpointId string) (*EndpointInfo, error) {
 	nm.Lock()
@@ -536,6 +751,132 @@ func (nm *networkManager) GetNumberOfEndpoints(ifName string, networkId string)
 	return 0
 }
 
+// releaseVlanInterface deletes the VLAN sub-interface with the given ID if it was created by the network manager
+// and no network or endpoint on the external interface references the VLAN anymore.
+func (nm *networkManager) releaseVlanInterface(extIf *externalInterface, vlanID int) {
+	if vlanID == 0 || extIf.VlanInterfaces[vlanID] == nil || extIf.getVlanReferenceCount(vlanID) > 0 {
+		return
+	}
+
+	log.Printf("[net] Deleting VLAN %d sub-interface as no endpoints reference it.", vlanID)
+	if err := nm.deleteVlanInterfaceImpl(extIf, vlanID); err != nil {
+		log.Errorf("[net] Failed to delete VLAN %d sub-interface: %v", vlanID, err)
+	}
+}
+
+// getVlanReferenceCount returns the number of bridge-vlan networks and endpoints on the interface using the VLAN.
+func (extIf *externalInterface) getVlanReferenceCount(vlanID int) int {
+	count := 0
+	for _, nw := range extIf.Networks {
+		if nw.Mode == opModeBridgeVlan && nw.VlanId == vlanID {
+			count++
+		}
+
+		for _, ep := range nw.Endpoints {
+			if ep.VlanID == vlanID {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// getWireguardNetwork returns the network with the given ID if it runs in wireguard mode.
+func (nm *networkManager) getWireguardNetwork(networkID string) (*network, error) {
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if nw.Mode != opModeWireguard {
+		return nil, errNetworkModeInvalid
+	}
+
+	return nw, nil
+}
+
+// GetWireguardPublicKey returns the public key other nodes need to add this node as a peer.
+func (nm *networkManager) GetWireguardPublicKey(networkID string) (string, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getWireguardNetwork(networkID)
+	if err != nil {
+		return "", err
+	}
+
+	return nm.getWireguardPublicKeyImpl(nw)
+}
+
+// SyncWireguardPeers programs the peers of the overlay, peers not in the list are removed.
+func (nm *networkManager) SyncWireguardPeers(networkID string, peers []wireguard.Peer) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getWireguardNetwork(networkID)
+	if err != nil {
+		return err
+	}
+
+	return nm.syncWireguardPeersImpl(nw, peers)
+}
+
+// RotateWireguardKey replaces the wireguard key of the node if it is older than maxAge and returns
+// whether it did. The new public key must be published for the other nodes to pick it up.
+func (nm *networkManager) RotateWireguardKey(networkID string, maxAge time.Duration) (bool, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getWireguardNetwork(networkID)
+	if err != nil {
+		return false, err
+	}
+
+	return nm.rotateWireguardKeyImpl(nw, maxAge)
+}
+
+// getOverlayNetwork returns the network with the given ID if it runs in overlay mode.
+func (nm *networkManager) getOverlayNetwork(networkID string) (*network, error) {
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if nw.Mode != opModeOverlay {
+		return nil, errNetworkModeInvalid
+	}
+
+	return nw, nil
+}
+
+// GetOverlayVtepMAC returns the MAC address of the VXLAN interface other nodes need to add this node as a peer.
+func (nm *networkManager) GetOverlayVtepMAC(networkID string) (net.HardwareAddr, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getOverlayNetwork(networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	return nm.getOverlayVtepMACImpl(nw)
+}
+
+// SyncOverlayPeers programs the peers of the VXLAN overlay, e.g. as reported by a node watcher or CNS.
+// Peers not in the list are removed.
+func (nm *networkManager) SyncOverlayPeers(networkID string, peers []vxlan.Peer) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getOverlayNetwork(networkID)
+	if err != nil {
+		return err
+	}
+
+	return nm.syncOverlayPeersImpl(nw, peers)
+}
+
 func (nm *networkManager) SetupNetworkUsingState(networkMonitor *cnms.NetworkMonitor) error {
 	return nm.monitorNetworkState(networkMonitor)
 }
@@ -1,14 +1,28 @@
 package network
 
 import (
+	"net"
+	"time"
+
 	cnms "github.com/Azure/azure-container-networking/cnms/cnmspackage"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/network/vxlan"
+	"github.com/Azure/azure-container-networking/network/wireguard"
+	"github.com/Azure/azure-container-networking/telemetry"
 )
 
+// MockWireguardPublicKey is the public key reported by the mock for the local node.
+const MockWireguardPublicKey = "mock-wireguard-public-key"
+
+// MockOverlayVtepMAC is the VTEP MAC address reported by the mock for the local node.
+var MockOverlayVtepMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
 // MockNetworkManager is a mock structure for Network Manager
 type MockNetworkManager struct {
 	TestNetworkInfoMap  map[string]*NetworkInfo
 	TestEndpointInfoMap map[string]*EndpointInfo
+	TestWireguardPeers  []wireguard.Peer
+	TestOverlayPeers    []vxlan.Peer
 }
 
 // NewMockNetworkmanager returns a new mock
@@ -27,11 +41,24 @@ func (nm *MockNetworkManager) Initialize(config *common.PluginConfig, isRehydrat
 // Uninitialize mock
 func (nm *MockNetworkManager) Uninitialize() {}
 
+// Reload mock
+func (nm *MockNetworkManager) Reload() error {
+	return nil
+}
+
+// SetPhaseTimer mock
+func (nm *MockNetworkManager) SetPhaseTimer(phases *telemetry.PhaseTimer) {}
+
 // AddExternalInterface mock
 func (nm *MockNetworkManager) AddExternalInterface(ifName string, subnet string) error {
 	return nil
 }
 
+// RefreshExternalInterfaces mock
+func (nm *MockNetworkManager) RefreshExternalInterfaces() error {
+	return nil
+}
+
 // CreateNetwork mock
 func (nm *MockNetworkManager) CreateNetwork(nwInfo *NetworkInfo) error {
 	nm.TestNetworkInfoMap[nwInfo.Id] = nwInfo
@@ -67,6 +94,20 @@ func (nm *MockNetworkManager) GetAllEndpoints(networkID string) (map[string]*End
 	return nm.TestEndpointInfoMap, nil
 }
 
+// CleanupEndpoint mock
+func (nm *MockNetworkManager) CleanupEndpoint(epInfo *EndpointInfo) error {
+	return nil
+}
+
+// AddEndpointState mock
+func (nm *MockNetworkManager) AddEndpointState(nwInfo *NetworkInfo, epInfo *EndpointInfo) error {
+	if _, ok := nm.TestNetworkInfoMap[nwInfo.Id]; !ok {
+		nm.TestNetworkInfoMap[nwInfo.Id] = nwInfo
+	}
+	nm.TestEndpointInfoMap[epInfo.Id] = epInfo
+	return nil
+}
+
 // GetEndpointInfo mock
 func (nm *MockNetworkManager) GetEndpointInfo(networkID string, endpointID string) (*EndpointInfo, error) {
 	if info, exists := nm.TestEndpointInfoMap[endpointID]; exists {
@@ -75,6 +116,47 @@ func (nm *MockNetworkManager) GetEndpointInfo(networkID string, endpointID strin
 	return nil, errEndpointNotFound
 }
 
+// GetEndpointStats mock
+func (nm *MockNetworkManager) GetEndpointStats(networkID string, endpointID string) (*InterfaceStats, error) {
+	if _, exists := nm.TestEndpointInfoMap[endpointID]; exists {
+		return &InterfaceStats{}, nil
+	}
+	return nil, errEndpointNotFound
+}
+
+// CheckEndpoint mock
+func (nm *MockNetworkManager) CheckEndpoint(networkID, endpointID, netNsPath, ifName string) error {
+	if _, exists := nm.TestEndpointInfoMap[endpointID]; exists {
+		return nil
+	}
+	return errEndpointNotFound
+}
+
+// RepairEndpoint mock
+func (nm *MockNetworkManager) RepairEndpoint(networkID, endpointID string, dryRun bool) ([]string, error) {
+	if _, exists := nm.TestEndpointInfoMap[endpointID]; exists {
+		return nil, nil
+	}
+	return nil, errEndpointNotFound
+}
+
+// UpdateOutboundNATExceptions mock
+func (nm *MockNetworkManager) UpdateOutboundNATExceptions(networkID string, exceptions []string) error {
+	if _, ok := nm.TestNetworkInfoMap[networkID]; !ok {
+		return errNetworkNotFound
+	}
+	return nil
+}
+
+// RemoveEndpointState mock
+func (nm *MockNetworkManager) RemoveEndpointState(networkID, endpointID string) error {
+	if _, exists := nm.TestEndpointInfoMap[endpointID]; !exists {
+		return errEndpointNotFound
+	}
+	delete(nm.TestEndpointInfoMap, endpointID)
+	return nil
+}
+
 // GetEndpointInfoBasedOnPODDetails mock
 func (nm *MockNetworkManager) GetEndpointInfoBasedOnPODDetails(networkID string, podName string, podNameSpace string, doExactMatchForPodName bool) (*EndpointInfo, error) {
 	return &EndpointInfo{}, nil
@@ -100,6 +182,67 @@ func (nm *MockNetworkManager) GetNumberOfEndpoints(ifName string, networkID stri
 	return 0
 }
 
+// GetWireguardPublicKey mock
+func (nm *MockNetworkManager) GetWireguardPublicKey(networkID string) (string, error) {
+	if _, ok := nm.TestNetworkInfoMap[networkID]; !ok {
+		return "", errNetworkNotFound
+	}
+
+	return MockWireguardPublicKey, nil
+}
+
+// SyncWireguardPeers mock
+func (nm *MockNetworkManager) SyncWireguardPeers(networkID string, peers []wireguard.Peer) error {
+	if _, ok := nm.TestNetworkInfoMap[networkID]; !ok {
+		return errNetworkNotFound
+	}
+
+	nm.TestWireguardPeers = peers
+	return nil
+}
+
+// RotateWireguardKey mock
+func (nm *MockNetworkManager) RotateWireguardKey(networkID string, _ time.Duration) (bool, error) {
+	if _, ok := nm.TestNetworkInfoMap[networkID]; !ok {
+		return false, errNetworkNotFound
+	}
+
+	return false, nil
+}
+
+// GetOverlayVtepMAC mock
+func (nm *MockNetworkManager) GetOverlayVtepMAC(networkID string) (net.HardwareAddr, error) {
+	if _, ok := nm.TestNetworkInfoMap[networkID]; !ok {
+		return nil, errNetworkNotFound
+	}
+
+	return MockOverlayVtepMAC, nil
+}
+
+// SyncOverlayPeers mock
+func (nm *MockNetworkManager) SyncOverlayPeers(networkID string, peers []vxlan.Peer) error {
+	if _, ok := nm.TestNetworkInfoMap[networkID]; !ok {
+		return errNetworkNotFound
+	}
+
+	nm.TestOverlayPeers = peers
+	return nil
+}
+
+// ExportState mock
+func (nm *MockNetworkManager) ExportState() (*NodeState, error) {
+	state := &NodeState{ExternalInterfaces: []ExternalInterfaceState{{Name: "eth0"}}}
+	for _, nwInfo := range nm.TestNetworkInfoMap {
+		nwState := NetworkState{Id: nwInfo.Id, Mode: nwInfo.Mode}
+		for _, epInfo := range nm.TestEndpointInfoMap {
+			nwState.Endpoints = append(nwState.Endpoints, EndpointState{Id: epInfo.Id, ContainerID: epInfo.ContainerID})
+		}
+		state.ExternalInterfaces[0].Networks = append(state.ExternalInterfaces[0].Networks, nwState)
+	}
+
+	return state, nil
+}
+
 // SetupNetworkUsingState mock
 func (nm *MockNetworkManager) SetupNetworkUsingState(networkMonitor *cnms.NetworkMonitor) error {
 	return nil
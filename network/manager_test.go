@@ -226,4 +226,116 @@ var _ = Describe("Test Manager", func() {
 			})
 		})
 	})
+
+	Describe("Test GetEndpointStats", func() {
+		Context("When network not found", func() {
+			It("Should raise errNetworkNotFound", func() {
+				nm := &networkManager{
+					ExternalInterfaces: map[string]*externalInterface{},
+				}
+				stats, err := nm.GetEndpointStats("nwId", "epId")
+				Expect(err).To(Equal(errNetworkNotFound))
+				Expect(stats).To(BeNil())
+			})
+		})
+
+		Context("When endpoint not found", func() {
+			It("Should raise errEndpointNotFound", func() {
+				ifName := "eth0"
+				nwId := "nwId"
+				nm := &networkManager{
+					ExternalInterfaces: map[string]*externalInterface{},
+				}
+				nm.ExternalInterfaces[ifName] = &externalInterface{
+					Networks: map[string]*network{},
+				}
+				nm.ExternalInterfaces[ifName].Networks[nwId] = &network{
+					Endpoints: map[string]*endpoint{},
+				}
+				stats, err := nm.GetEndpointStats(nwId, "epId")
+				Expect(err).To(Equal(errEndpointNotFound))
+				Expect(stats).To(BeNil())
+			})
+		})
+	})
+
+	Describe("Test RemoveEndpointState", func() {
+		Context("When endpoint not found", func() {
+			It("Should raise errEndpointNotFound", func() {
+				nm := &networkManager{
+					ExternalInterfaces: map[string]*externalInterface{
+						"eth0": {Networks: map[string]*network{"nwId": {Endpoints: map[string]*endpoint{}}}},
+					},
+				}
+				err := nm.RemoveEndpointState("nwId", "epId")
+				Expect(err).To(Equal(errEndpointNotFound))
+			})
+		})
+
+		Context("When endpoint found", func() {
+			It("Should remove only the endpoint from the state", func() {
+				nm := &networkManager{
+					ExternalInterfaces: map[string]*externalInterface{
+						"eth0": {
+							Networks: map[string]*network{
+								"nwId": {
+									Endpoints: map[string]*endpoint{
+										"epId":  {Id: "epId"},
+										"epId2": {Id: "epId2"},
+									},
+								},
+							},
+						},
+					},
+				}
+				err := nm.RemoveEndpointState("nwId", "epId")
+				Expect(err).NotTo(HaveOccurred())
+				endpoints := nm.ExternalInterfaces["eth0"].Networks["nwId"].Endpoints
+				Expect(endpoints).NotTo(HaveKey("epId"))
+				Expect(endpoints).To(HaveKey("epId2"))
+			})
+		})
+	})
+
+	Describe("Test VLAN interfaces", func() {
+		Context("When VLAN is referenced by networks and endpoints", func() {
+			It("Should count the references and keep the VLAN sub-interface", func() {
+				ifName := "eth0"
+				nm := &networkManager{
+					ExternalInterfaces: map[string]*externalInterface{},
+				}
+				nm.ExternalInterfaces[ifName] = &externalInterface{
+					Name: ifName,
+					Networks: map[string]*network{
+						"nw1": {
+							Endpoints: map[string]*endpoint{
+								"ep1": {VlanID: 100},
+								"ep2": {VlanID: 200},
+							},
+						},
+						"nw2": {
+							Endpoints: map[string]*endpoint{
+								"ep3": {VlanID: 100},
+							},
+						},
+						"nw3": {
+							Mode:      opModeBridgeVlan,
+							VlanId:    300,
+							Endpoints: map[string]*endpoint{},
+						},
+					},
+					VlanInterfaces: map[int]*vlanInterface{
+						100: {Name: "eth0.100", VlanID: 100},
+					},
+				}
+				extIf := nm.ExternalInterfaces[ifName]
+				Expect(extIf.getVlanReferenceCount(100)).To(Equal(2))
+				Expect(extIf.getVlanReferenceCount(300)).To(Equal(1))
+				Expect(extIf.getVlanReferenceCount(400)).To(Equal(0))
+
+				nm.releaseVlanInterface(extIf, 100)
+				Expect(extIf.VlanInterfaces).To(HaveKey(100))
+			})
+		})
+	})
 })
@@ -9,6 +9,7 @@ import (
 	"runtime"
 
 	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
 
 	"golang.org/x/sys/unix"
 )
@@ -23,7 +24,7 @@ type Namespace struct {
 func OpenNamespace(nsPath string) (*Namespace, error) {
 	fd, err := os.Open(nsPath)
 	if err != nil {
-		return nil, err
+		return nil, networkutils.NewError("open namespace", nsPath, networkutils.ObjectNamespace, err)
 	}
 
 	return &Namespace{file: fd}, nil
@@ -70,15 +71,19 @@ func (ns *Namespace) set() error {
 func (ns *Namespace) Enter() error {
 	var err error
 
+	// Lock before reading the current namespace so the previous namespace is the one of this thread.
+	runtime.LockOSThread()
+
 	ns.prevNs, err = GetCurrentThreadNamespace()
 	if err != nil {
+		runtime.UnlockOSThread()
 		return err
 	}
 
-	runtime.LockOSThread()
-
 	err = ns.set()
 	if err != nil {
+		ns.prevNs.Close()
+		ns.prevNs = nil
 		runtime.UnlockOSThread()
 		return err
 	}
@@ -4,13 +4,18 @@
 package network
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"strings"
 
+	"github.com/Azure/azure-container-networking/conntrack"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/network/policy"
 	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
+	"go.uber.org/zap"
 )
 
 const (
@@ -19,7 +24,17 @@ const (
 	opModeTunnel          = "tunnel"
 	opModeTransparent     = "transparent"
 	opModeTransparentVlan = "transparent-vlan"
-	opModeDefault         = opModeTunnel
+	opModeWireguard       = "wireguard"
+	// opModeBridgeVlan connects the endpoints to a bridge per VLAN whose uplink is the VLAN sub-interface
+	// of the external interface, isolating the tenants of different VLANs from each other and the host.
+	opModeBridgeVlan = "bridge-vlan"
+	// opModeVlanTrunk moves a VLAN sub-interface of the external interface into the container namespace of each
+	// endpoint, the external interface being a trunk which carries the VLANs of the endpoints.
+	opModeVlanTrunk = "vlan-trunk"
+	// opModeOverlay connects the endpoints to an HNS overlay network on windows. On linux the endpoints are
+	// connected to the bridge and traffic to other nodes is encapsulated by a VXLAN interface.
+	opModeOverlay = "overlay"
+	opModeDefault = opModeTunnel
 )
 
 const (
@@ -39,6 +54,20 @@ type externalInterface struct {
 	Routes      []*route
 	IPv4Gateway net.IP
 	IPv6Gateway net.IP
+	// VlanInterfaces are the VLAN sub-interfaces created on this interface, keyed by VLAN ID.
+	VlanInterfaces map[int]*vlanInterface `json:",omitempty"`
+	// Removed is set once the interface is detached from the host. The interface stays in the state until its
+	// networks are torn down, which waits for their endpoints to be deleted.
+	Removed bool `json:",omitempty"`
+}
+
+// vlanInterface is a VLAN sub-interface of an external interface.
+type vlanInterface struct {
+	Name   string
+	VlanID int
+	MTU    int
+	// Created is set if the network manager created the sub-interface, an adopted sub-interface is left in place.
+	Created bool `json:",omitempty"`
 }
 
 // A container network is a set of endpoints allowed to communicate with each other.
@@ -54,6 +83,19 @@ type network struct {
 	EnableSnatOnHost bool
 	NetNs            string
 	SnatBridgeIP     string
+	// BridgeName is the bridge of a bridge-vlan network, other networks use the bridge of the external interface.
+	BridgeName string `json:",omitempty"`
+	// VNI is the VXLAN network identifier of a linux overlay network.
+	VNI int `json:",omitempty"`
+	// OutboundNATPolicies are the OutBoundNAT network policies of an HNSv2 network, which HNS applies per endpoint.
+	OutboundNATPolicies []policy.Policy `json:",omitempty"`
+	phases              *telemetry.PhaseTimer
+	conntrack           conntrackClient
+}
+
+// conntrackClient flushes the conntrack entries selected by a filter.
+type conntrackClient interface {
+	Flush(filter conntrack.Filter) (uint, error)
 }
 
 // NetworkInfo contains read-only information about a container network.
@@ -86,9 +128,24 @@ type SubnetInfo struct {
 
 // DNSInfo contains DNS information for a container network or endpoint.
 type DNSInfo struct {
+	// Suffix are the DNS search suffixes, separated by commas.
 	Suffix  string
 	Servers []string
 	Options []string
+	// Domain is the DNS domain of the endpoint.
+	Domain string `json:",omitempty"`
+}
+
+// Searches returns the DNS search suffixes.
+func (dns *DNSInfo) Searches() []string {
+	var searches []string
+	for _, suffix := range strings.Split(dns.Suffix, ",") {
+		if suffix = strings.TrimSpace(suffix); suffix != "" {
+			searches = append(searches, suffix)
+		}
+	}
+
+	return searches
 }
 
 func (nwInfo *NetworkInfo) PrettyString() string {
@@ -158,15 +215,74 @@ func (nm *networkManager) findExternalInterfaceByName(ifName string) *externalIn
 	return nil
 }
 
+// refreshExternalInterfaces checks the external interfaces against the NICs of the host, which may be attached or
+// detached at runtime. The interfaces which were detached, or replaced by a NIC with another MAC address, are
+// marked as removed and their networks without endpoints are torn down. Returns whether the state changed.
+func (nm *networkManager) refreshExternalInterfaces() bool {
+	changed := false
+	for ifName, extIf := range nm.ExternalInterfaces {
+		if !extIf.Removed {
+			hostIf, err := nm.netio.GetNetworkInterfaceByName(ifName)
+			switch {
+			case errors.Is(err, netio.ErrInterfaceNotFound):
+				log.Printf("[net] External interface %v was removed from the host.", ifName)
+			case err != nil:
+				log.Printf("[net] Failed to refresh external interface %v: %v.", ifName, err)
+				continue
+			case len(extIf.MacAddress) > 0 && hostIf.HardwareAddr.String() != extIf.MacAddress.String():
+				log.Printf("[net] External interface %v was replaced, MAC address %v is now %v.", ifName, extIf.MacAddress, hostIf.HardwareAddr)
+			default:
+				continue
+			}
+			extIf.Removed = true
+			changed = true
+		}
+
+		if nm.teardownRemovedExternalInterface(extIf) {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// teardownRemovedExternalInterface deletes the networks of a removed external interface which have no endpoints,
+// and the interface itself once it has no networks. A NIC attached again under the same name is then added as a
+// new external interface by the next ADD. Returns whether the state changed.
+func (nm *networkManager) teardownRemovedExternalInterface(extIf *externalInterface) bool {
+	changed := false
+	for networkID, nw := range extIf.Networks {
+		if len(nw.Endpoints) > 0 {
+			continue
+		}
+		// the resources of the network may have gone with the interface, so it is removed from the state anyway
+		if err := nm.deleteNetwork(networkID); err != nil {
+			log.Printf("[net] Failed to tear down network %v of removed interface %v: %v.", networkID, extIf.Name, err)
+			delete(extIf.Networks, networkID)
+		}
+		changed = true
+	}
+
+	if len(extIf.Networks) == 0 {
+		_ = nm.deleteExternalInterface(extIf.Name)
+		changed = true
+	} else {
+		log.Printf("[net] Keeping removed interface %v until the endpoints of its %d networks are deleted.", extIf.Name, len(extIf.Networks))
+	}
+
+	return changed
+}
+
 // NewNetwork creates a new container network.
 func (nm *networkManager) newNetwork(nwInfo *NetworkInfo) (*network, error) {
 	var nw *network
 	var err error
 
-	log.Printf("[net] Creating network %s.", nwInfo.PrettyString())
+	logger := log.With(log.Component("net"), log.Operation("CreateNetwork"), log.Network(nwInfo.Id))
+	logger.Info("Creating network", zap.String("info", nwInfo.PrettyString()))
 	defer func() {
 		if err != nil {
-			log.Printf("[net] Failed to create network %v, err:%v.", nwInfo.Id, err)
+			logger.Error("Failed to create network", zap.Error(err))
 		}
 	}()
 
@@ -187,6 +303,10 @@ func (nm *networkManager) newNetwork(nwInfo *NetworkInfo) (*network, error) {
 		err = errSubnetNotFound
 		return nil, err
 	}
+	if extIf.Removed {
+		err = &interfaceRemovedError{ifName: extIf.Name}
+		return nil, err
+	}
 
 	// Make sure this network does not already exist.
 	if extIf.Networks[nwInfo.Id] != nil {
@@ -204,7 +324,7 @@ func (nm *networkManager) newNetwork(nwInfo *NetworkInfo) (*network, error) {
 	nw.Subnets = nwInfo.Subnets
 	extIf.Networks[nwInfo.Id] = nw
 
-	log.Printf("[net] Created network %v on interface %v.", nwInfo.Id, extIf.Name)
+	logger.Info("Created network", zap.String("interface", extIf.Name))
 	return nw, nil
 }
 
@@ -212,10 +332,11 @@ func (nm *networkManager) newNetwork(nwInfo *NetworkInfo) (*network, error) {
 func (nm *networkManager) deleteNetwork(networkID string) error {
 	var err error
 
-	log.Printf("[net] Deleting network %v.", networkID)
+	logger := log.With(log.Component("net"), log.Operation("DeleteNetwork"), log.Network(networkID))
+	logger.Info("Deleting network")
 	defer func() {
 		if err != nil {
-			log.Printf("[net] Failed to delete network %v, err:%v.", networkID, err)
+			logger.Error("Failed to delete network", zap.Error(err))
 		}
 	}()
 
@@ -234,9 +355,13 @@ func (nm *networkManager) deleteNetwork(networkID string) error {
 	// Remove the network object.
 	if nw.extIf != nil {
 		delete(nw.extIf.Networks, networkID)
+
+		if nw.Mode == opModeBridgeVlan {
+			nm.releaseVlanInterface(nw.extIf, nw.VlanId)
+		}
 	}
 
-	log.Printf("[net] Deleted network %+v.", nw)
+	logger.Info("Deleted network", zap.String("mode", nw.Mode))
 	return nil
 }
 
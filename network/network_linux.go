@@ -49,8 +49,8 @@ const (
 
 var errorNetworkManager = errors.New("Network_linux pkg error")
 
-func newErrorNetworkManager(errStr string) error {
-	return fmt.Errorf("%w : %s", errorNetworkManager, errStr)
+func newErrorNetworkManager(err error) error {
+	return fmt.Errorf("%v : %w", errorNetworkManager, err)
 }
 
 // Linux implementation of route.
@@ -60,13 +60,23 @@ type route netlink.Route
 func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInterface) (*network, error) {
 	// Connect the external interface.
 	var (
-		vlanid int
-		ifName string
+		vlanid     int
+		vni        int
+		ifName     string
+		bridgeName string
 	)
 	opt, _ := nwInfo.Options[genericData].(map[string]interface{})
 	log.Printf("opt %+v options %+v", opt, nwInfo.Options)
 
 	switch nwInfo.Mode {
+	case opModeWireguard:
+		// Pods on the node are connected through the bridge, traffic to other nodes goes through the tunnel.
+		if err := nm.newWireguardClient().Setup(); err != nil {
+			return nil, err
+		}
+		fallthrough
+	case opModeOverlay:
+		fallthrough
 	case opModeTunnel:
 		fallthrough
 	case opModeBridge:
@@ -79,6 +89,17 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		if opt != nil && opt[VlanIDKey] != nil {
 			vlanid, _ = strconv.Atoi(opt[VlanIDKey].(string))
 		}
+
+		if nwInfo.Mode == opModeOverlay {
+			// Pods on the node are connected through the bridge, traffic to other nodes is encapsulated over it.
+			var err error
+			if vni, err = overlayVNI(opt); err != nil {
+				return nil, err
+			}
+			if err = nm.newVxlanClient(vni, extIf).Setup(); err != nil {
+				return nil, err
+			}
+		}
 	case opModeTransparent:
 		log.Printf("Transparent mode")
 		ifName = extIf.Name
@@ -91,6 +112,20 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 	case opModeTransparentVlan:
 		log.Printf("Transparent vlan mode")
 		ifName = extIf.Name
+	case opModeVlanTrunk:
+		log.Printf("Vlan trunk mode")
+		ifName = extIf.Name
+	case opModeBridgeVlan:
+		log.Printf("Bridge vlan mode")
+		if opt != nil && opt[VlanIDKey] != nil {
+			vlanid, _ = strconv.Atoi(opt[VlanIDKey].(string))
+		}
+
+		var err error
+		if bridgeName, err = nm.connectVlanBridge(extIf, vlanid); err != nil {
+			return nil, err
+		}
+		ifName = bridgeName
 	default:
 		return nil, errNetworkModeInvalid
 	}
@@ -110,6 +145,8 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		VlanId:           vlanid,
 		DNS:              nwInfo.DNS,
 		EnableSnatOnHost: nwInfo.EnableSnatOnHost,
+		BridgeName:       bridgeName,
+		VNI:              vni,
 	}
 
 	return nw, nil
@@ -138,12 +175,29 @@ func (nm *networkManager) handleCommonOptions(ifName string, nwInfo *NetworkInfo
 func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 	var networkClient NetworkClient
 
+	// The external interface is not connected to the bridge of a bridge-vlan network.
+	if nw.Mode == opModeBridgeVlan {
+		return nm.disconnectVlanBridge(nw)
+	}
+
 	if nw.VlanId != 0 {
 		networkClient = NewOVSClient(nw.extIf.BridgeName, nw.extIf.Name, ovsctl.NewOvsctl(), nm.netlink, nm.plClient)
 	} else {
 		networkClient = NewLinuxBridgeClient(nw.extIf.BridgeName, nw.extIf.Name, NetworkInfo{}, nm.netlink, nm.plClient)
 	}
 
+	if nw.Mode == opModeWireguard {
+		if err := nm.newWireguardClient().Teardown(); err != nil {
+			log.Errorf("[net] Failed to tear down wireguard interface: %v", err)
+		}
+	}
+
+	if nw.Mode == opModeOverlay {
+		if err := nm.newVxlanClient(nw.VNI, nw.extIf).Teardown(); err != nil {
+			log.Errorf("[net] Failed to tear down vxlan interface: %v", err)
+		}
+	}
+
 	// Disconnect the interface if this was the last network using it.
 	if len(nw.extIf.Networks) == 1 {
 		nm.disconnectExternalInterface(nw.extIf, networkClient)
@@ -309,7 +363,7 @@ func (nm *networkManager) applyIPConfig(extIf *externalInterface, targetIf *net.
 		log.Printf("[net] Adding IP address %v to interface %v.", addr, targetIf.Name)
 
 		err := nm.netlink.AddIPAddress(targetIf.Name, addr.IP, addr)
-		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "file exists") {
+		if err != nil && !errors.Is(err, netlink.ErrExists) {
 			log.Printf("[net] Failed to add IP address %v: %v.", addr, err)
 			return err
 		}
@@ -525,7 +579,13 @@ func (nm *networkManager) connectExternalInterface(extIf *externalInterface, nwI
 
 		// unmark packet if set by kube-proxy to skip kube-postrouting rule and processed
 		// by cni snat rule
-		if err = iptables.InsertIptableRule(iptables.V6, iptables.Mangle, iptables.Postrouting, "", "MARK --set-mark 0x0"); err != nil {
+		unmarkRule := &iptables.Rule{
+			Version: iptables.V6,
+			Table:   iptables.Mangle,
+			Chain:   iptables.Postrouting,
+			Target:  iptables.Jump("MARK", "--set-mark", "0x0"),
+		}
+		if err = iptables.EnsureRule(unmarkRule, iptables.Insert); err != nil {
 			log.Errorf("[net] Adding Iptable mangle rule failed:%v", err)
 			return err
 		}
@@ -552,10 +612,10 @@ func (nm *networkManager) disconnectExternalInterface(extIf *externalInterface,
 	extIf.BridgeName = ""
 	log.Printf("Restoring ipconfig with primary interface %v", extIf.Name)
 
-	// Restore IP configuration.
-	hostIf, _ := net.InterfaceByName(extIf.Name)
-	err := nm.applyIPConfig(extIf, hostIf)
-	if err != nil {
+	// Restore IP configuration, unless the interface was detached from the host.
+	if hostIf, err := net.InterfaceByName(extIf.Name); err != nil {
+		log.Printf("[net] Skipping IP configuration of missing interface %v: %v.", extIf.Name, err)
+	} else if err = nm.applyIPConfig(extIf, hostIf); err != nil {
 		log.Printf("[net] Failed to apply IP configuration: %v.", err)
 	}
 
@@ -589,7 +649,7 @@ func (nm *networkManager) addIpv6NatGateway(nwInfo *NetworkInfo) error {
 			nuc := networkutils.NewNetworkUtils(nm.netlink, nm.plClient)
 			err := nuc.AssignIPToInterface(nwInfo.BridgeName, ipAddr)
 			if err != nil {
-				return newErrorNetworkManager(err.Error())
+				return newErrorNetworkManager(err)
 			}
 		}
 	}
@@ -618,7 +678,7 @@ func (*networkManager) addIpv6SnatRule(extIf *externalInterface, nwInfo *Network
 	for _, ipAddr := range extIf.IPAddresses {
 		if ipAddr.IP.To4() == nil {
 			log.Printf("[net] Adding ipv6 snat rule")
-			matchSrcPrefix := fmt.Sprintf("-s %s", ipv6SubnetPrefix.String())
+			matchSrcPrefix := iptables.MatchSource(ipv6SubnetPrefix.String())
 			if err := networkutils.AddSnatRule(matchSrcPrefix, ipAddr.IP); err != nil {
 				return fmt.Errorf("Adding iptable snat rule failed:%w", err)
 			}
@@ -650,7 +710,7 @@ func AddStaticRoute(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface,
 	route := RouteInfo{Dst: *ipNet, Gw: gwIP}
 	routes = append(routes, route)
 	if err := addRoutes(nl, netioshim, interfaceName, routes); err != nil {
-		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "file exists") {
+		if err != nil && !errors.Is(err, netlink.ErrExists) {
 			log.Printf("addroutes failed with error %v", err)
 			return err
 		}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// hostNICs is a netio with the NICs attached to the host, keyed by name
+type hostNICs map[string]net.HardwareAddr
+
+func (h hostNICs) GetNetworkInterfaceByName(name string) (*net.Interface, error) {
+	mac, ok := h[name]
+	if !ok {
+		return nil, errors.Wrapf(netio.ErrInterfaceNotFound, "no interface %s", name)
+	}
+	return &net.Interface{Name: name, HardwareAddr: mac}, nil
+}
+
+func (hostNICs) GetNetworkInterfaceAddrs(*net.Interface) ([]net.Addr, error) {
+	return []net.Addr{}, nil
+}
+
+func TestRefreshExternalInterfaces(t *testing.T) {
+	mac0, _ := net.ParseMAC("00:0d:3a:00:00:00")
+	mac1, _ := net.ParseMAC("00:0d:3a:00:00:01")
+	nics := hostNICs{"eth0": mac0, "eth1": mac1}
+	nm := &networkManager{
+		ExternalInterfaces: make(map[string]*externalInterface),
+		netlink:            netlink.NewMockNetlink(false, ""),
+		plClient:           platform.NewMockExecClient(false),
+		netio:              nics,
+	}
+	for name, mac := range nics {
+		extIf := &externalInterface{Name: name, MacAddress: mac, Networks: make(map[string]*network)}
+		nm.ExternalInterfaces[name] = extIf
+	}
+	eth1 := nm.ExternalInterfaces["eth1"]
+	eth1.Networks["busy"] = &network{
+		Id:        "busy",
+		Mode:      opModeTransparent,
+		extIf:     eth1,
+		Endpoints: map[string]*endpoint{"ep": {Id: "ep"}},
+	}
+	eth1.Networks["idle"] = &network{Id: "idle", Mode: opModeTransparent, extIf: eth1, Endpoints: map[string]*endpoint{}}
+
+	require.False(t, nm.refreshExternalInterfaces(), "nothing changed")
+
+	// eth1 is detached from the VM
+	delete(nics, "eth1")
+	require.True(t, nm.refreshExternalInterfaces())
+	require.True(t, eth1.Removed)
+	require.NotContains(t, eth1.Networks, "idle", "the network without endpoints is torn down")
+	require.Contains(t, eth1.Networks, "busy")
+	require.False(t, nm.ExternalInterfaces["eth0"].Removed)
+
+	// ADDs over the removed interface fail with a typed error
+	err := nm.CreateEndpoint(nil, "busy", &EndpointInfo{Id: "ep2", Data: map[string]interface{}{}})
+	require.True(t, IsInterfaceRemovedError(err), err)
+	require.Contains(t, err.Error(), "eth1")
+	_, err = nm.newNetwork(&NetworkInfo{Id: "new", MasterIfName: "eth1"})
+	require.True(t, IsInterfaceRemovedError(err), err)
+
+	// the interface is kept even if it comes back, until the endpoints are deleted
+	nics["eth1"] = mac1
+	require.False(t, nm.refreshExternalInterfaces())
+	delete(eth1.Networks["busy"].Endpoints, "ep")
+	require.True(t, nm.refreshExternalInterfaces())
+	require.NotContains(t, nm.ExternalInterfaces, "eth1")
+
+	// a NIC with another MAC address replaced eth0
+	nics["eth0"] = mac1
+	require.True(t, nm.refreshExternalInterfaces())
+	require.NotContains(t, nm.ExternalInterfaces, "eth0")
+}
@@ -5,7 +5,6 @@ package network
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -268,27 +267,36 @@ func (nm *networkManager) configureHcnNetwork(nwInfo *NetworkInfo, extIf *extern
 		subnetPolicy []byte
 	)
 
+	// Set network mode.
+	switch nwInfo.Mode {
+	case opModeBridge:
+		hcnNetwork.Type = hcn.L2Bridge
+	case opModeTunnel:
+		hcnNetwork.Type = hcn.L2Tunnel
+	case opModeOverlay:
+		hcnNetwork.Type = hcn.Overlay
+	default:
+		return nil, errNetworkModeInvalid
+	}
+
+	// The VLAN ID of an overlay network is the VSID of its virtual subnet.
 	opt, _ := nwInfo.Options[genericData].(map[string]interface{})
 	if opt != nil && opt[VlanIDKey] != nil {
 		var err error
 		vlanID, _ := strconv.ParseUint(opt[VlanIDKey].(string), baseDecimal, bitSize)
-		subnetPolicy, err = policy.SerializeHcnSubnetVlanPolicy((uint32)(vlanID))
+		if hcnNetwork.Type == hcn.Overlay {
+			subnetPolicy, err = policy.SerializeHcnSubnetVsidPolicy((uint32)(vlanID))
+		} else {
+			subnetPolicy, err = policy.SerializeHcnSubnetVlanPolicy((uint32)(vlanID))
+		}
 		if err != nil {
-			log.Printf("[net] Failed to serialize subnet vlan policy due to error: %v", err)
+			log.Printf("[net] Failed to serialize subnet isolation policy due to error: %v", err)
 			return nil, err
 		}
 
 		vlanid = (int)(vlanID)
-	}
-
-	// Set network mode.
-	switch nwInfo.Mode {
-	case opModeBridge:
-		hcnNetwork.Type = hcn.L2Bridge
-	case opModeTunnel:
-		hcnNetwork.Type = hcn.L2Tunnel
-	default:
-		return nil, errNetworkModeInvalid
+	} else if hcnNetwork.Type == hcn.Overlay {
+		return nil, fmt.Errorf("%w: overlay network %s has no VSID", errNetworkModeInvalid, nwInfo.Id)
 	}
 
 	// Populate subnets.
@@ -323,26 +331,9 @@ func (nm *networkManager) newNetworkImplHnsV2(nwInfo *NetworkInfo, extIf *extern
 		return nil, err
 	}
 
-	// check if network exists, only create the network does not exist
-	hnsResponse, err := Hnsv2.GetNetworkByName(hcnNetwork.Name)
-
+	hnsResponse, err := nm.reconcileHcnNetwork(hcnNetwork)
 	if err != nil {
-		// if network not found, create the HNS network.
-		if errors.As(err, &hcn.NetworkNotFoundError{}) {
-			log.Printf("[net] Creating hcn network: %+v", hcnNetwork)
-			hnsResponse, err = Hnsv2.CreateNetwork(hcnNetwork)
-
-			if err != nil {
-				return nil, fmt.Errorf("Failed to create hcn network: %s due to error: %v", hcnNetwork.Name, err)
-			}
-
-			log.Printf("[net] Successfully created hcn network with response: %+v", hnsResponse)
-		} else {
-			// we can't validate if the network already exists, don't continue
-			return nil, fmt.Errorf("Failed to create hcn network: %s, failed to query for existing network with error: %v", hcnNetwork.Name, err)
-		}
-	} else {
-		log.Printf("[net] Network with name %s already exists", hcnNetwork.Name)
+		return nil, err
 	}
 
 	var vlanid int
@@ -363,6 +354,7 @@ func (nm *networkManager) newNetworkImplHnsV2(nwInfo *NetworkInfo, extIf *extern
 		EnableSnatOnHost: nwInfo.EnableSnatOnHost,
 		NetNs:            nwInfo.NetNs,
 	}
+	nw.OutboundNATPolicies = outboundNATNetworkPolicies(nwInfo.Policies)
 
 	return nw, nil
 }
@@ -408,6 +400,10 @@ func (nm *networkManager) deleteNetworkImplHnsV2(nw *network) error {
 		return fmt.Errorf("Failed to get hcn network with id: %s due to err: %v", nw.HnsId, err)
 	}
 
+	if err = checkHcnNetworkUnused(hcnNetwork); err != nil {
+		return err
+	}
+
 	if err = Hnsv2.DeleteNetwork(hcnNetwork); err != nil {
 		return fmt.Errorf("Failed to delete hcn network: %s due to error: %v", nw.HnsId, err)
 	}
@@ -419,3 +415,7 @@ func (nm *networkManager) deleteNetworkImplHnsV2(nw *network) error {
 
 func getNetworkInfoImpl(nwInfo *NetworkInfo, nw *network) {
 }
+
+// gcOrphanFlowsImpl is a no-op, there are no OVS bridges on windows.
+func (nm *networkManager) gcOrphanFlowsImpl() {
+}
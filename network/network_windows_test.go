@@ -7,7 +7,9 @@
 package network
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 
@@ -228,3 +230,101 @@ func TestDeleteNetworkImplHnsV1WithTimeout(t *testing.T) {
 		t.Fatal("Failed to timeout HNS calls for deleting network")
 	}
 }
+
+func TestNewNetworkImplHnsV2Overlay(t *testing.T) {
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{},
+	}
+
+	Hnsv2 = hnswrapper.NewHnsv2wrapperFake()
+
+	_, subnet, _ := net.ParseCIDR("10.240.0.0/16")
+	nwInfo := &NetworkInfo{
+		Id:           "azure-overlay",
+		MasterIfName: "eth0",
+		Mode:         "overlay",
+		Subnets:      []SubnetInfo{{Prefix: *subnet, Gateway: net.ParseIP("10.240.0.1")}},
+		Options:      map[string]interface{}{genericData: map[string]interface{}{VlanIDKey: "4096"}},
+	}
+
+	extInterface := &externalInterface{
+		Name: "eth0",
+	}
+
+	_, err := nm.newNetworkImplHnsV2(nwInfo, extInterface)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hcnNetwork, _ := Hnsv2.GetNetworkByName("azure-overlay")
+	if hcnNetwork.Type != hcn.Overlay {
+		t.Fatalf("network type is %s, want Overlay", hcnNetwork.Type)
+	}
+
+	if got := hcnSubnets(hcnNetwork); len(got) != 1 || got[0] != "10.240.0.0/16 via [10.240.0.1] [VSID:4096]" {
+		t.Fatalf("unexpected subnets %v", got)
+	}
+
+	// an overlay network needs a VSID
+	nwInfo.Options = nil
+	if _, err := nm.configureHcnNetwork(nwInfo, extInterface); !errors.Is(err, errNetworkModeInvalid) {
+		t.Fatalf("expected errNetworkModeInvalid, got %v", err)
+	}
+}
+
+func TestReconcileHcnNetwork(t *testing.T) {
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{},
+	}
+
+	hnsFake := hnswrapper.NewHnsv2wrapperFake()
+	Hnsv2 = hnsFake
+
+	existing := &hcn.HostComputeNetwork{
+		Id:   "1d1a2a5c-8b4f-4e2e-9d0a-3c4b5d6e7f80",
+		Name: "azure",
+		Type: hcn.L2Bridge,
+		Dns:  hcn.Dns{ServerList: []string{"168.63.129.16"}},
+	}
+	if _, err := Hnsv2.CreateNetwork(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	desired := *existing
+	desired.Id = ""
+	desired.Dns = hcn.Dns{ServerList: []string{"10.0.0.10"}}
+
+	// an endpoint is attached, so the drifted network is kept
+	if _, err := Hnsv2.CreateEndpoint(&hcn.HostComputeEndpoint{Id: "ep1", HostComputeNetwork: existing.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	hcnNetwork, err := nm.reconcileHcnNetwork(&desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hcnNetwork.Id != existing.Id || hcnNetwork.Dns.ServerList[0] != "168.63.129.16" {
+		t.Fatalf("network in use was recreated: %+v", hcnNetwork)
+	}
+
+	if err := nm.deleteNetworkImplHnsV2(&network{Id: "azure", HnsId: existing.Id}); !errors.Is(err, errHcnNetworkInUse) {
+		t.Fatalf("expected errHcnNetworkInUse, got %v", err)
+	}
+
+	// without endpoints, the network is recreated with the desired settings
+	if err := Hnsv2.DeleteEndpoint(&hcn.HostComputeEndpoint{Id: "ep1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	hcnNetwork, err = nm.reconcileHcnNetwork(&desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hcnNetwork.Dns.ServerList[0] != "10.0.0.10" {
+		t.Fatalf("drifted network was not recreated: %+v", hcnNetwork)
+	}
+
+	if drift := hcnNetworkDrift(hcnNetwork, &desired); len(drift) != 0 {
+		t.Fatalf("unexpected drift %v", drift)
+	}
+}
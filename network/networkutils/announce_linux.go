@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+package networkutils
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	icmpv6NeighborAdvertisement = 136
+	// the override flag of a neighbor advertisement replaces the cached link-layer address of the neighbors.
+	naFlagOverride         = 0x20
+	ndOptTargetLinkAddress = 2
+	neighborAdvertLength   = 32
+	ndHopLimit             = 255
+)
+
+// AnnounceIPAddresses announces the IP addresses of the interface to its neighbors, with a gratuitous ARP for IPv4
+// addresses and an unsolicited neighbor advertisement for IPv6 addresses, so that the neighbor caches of the hosts
+// and switches on the link are updated right away, e.g. after an address moved from another node, instead of
+// after their entries time out. The addresses must be assigned to the interface.
+func AnnounceIPAddresses(ifName string, ipAddresses []net.IPNet) (err error) {
+	done := TimeOperation(OpAnnounceAddresses)
+	defer func() { done(err) }()
+
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return NewError("get interface", ifName, ObjectLink, err)
+	}
+
+	for _, ipAddr := range ipAddresses {
+		log.Printf("[net] Announcing IP address %v on link %v.", ipAddr.IP, ifName)
+		if ipAddr.IP.To4() != nil {
+			err = sendGratuitousARP(iface, ipAddr.IP)
+		} else {
+			err = sendUnsolicitedNA(iface, ipAddr.IP)
+		}
+		if err != nil {
+			return NewError("announce ip address "+ipAddr.IP.String(), ifName, ObjectAddress, err)
+		}
+	}
+
+	return nil
+}
+
+// sendGratuitousARP broadcasts an ARP request for the address from the address itself, as announced in RFC 5227.
+func sendGratuitousARP(iface *net.Interface, ip net.IP) error {
+	fd, broadcast, err := openARPSocket(iface)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Sendto(fd, newARPRequest(iface.HardwareAddr, ip, ip), 0, broadcast); err != nil {
+		return fmt.Errorf("failed to send gratuitous ARP: %w", err)
+	}
+
+	return nil
+}
+
+// sendUnsolicitedNA sends a neighbor advertisement for the address to all the nodes of the link, as in RFC 4861.
+func sendUnsolicitedNA(iface *net.Interface, ip net.IP) error {
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_ICMPV6)
+	if err != nil {
+		return fmt.Errorf("failed to open ICMPv6 socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	// neighbor discovery messages are dropped by the receivers if they may have been forwarded.
+	if err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_HOPS, ndHopLimit); err != nil {
+		return fmt.Errorf("failed to set hop limit: %w", err)
+	}
+	if err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_IF, iface.Index); err != nil {
+		return fmt.Errorf("failed to set interface: %w", err)
+	}
+
+	// the kernel computes the checksum of ICMPv6 messages.
+	na := make([]byte, neighborAdvertLength)
+	na[0] = icmpv6NeighborAdvertisement
+	na[4] = naFlagOverride
+	copy(na[8:24], ip.To16())
+	na[24] = ndOptTargetLinkAddress
+	na[25] = 1 // in units of 8 bytes
+	copy(na[26:32], iface.HardwareAddr)
+
+	allNodes := &unix.SockaddrInet6{ZoneId: uint32(iface.Index)}
+	copy(allNodes.Addr[:], net.IPv6linklocalallnodes)
+	if err = unix.Sendto(fd, na, 0, allNodes); err != nil {
+		return fmt.Errorf("failed to send neighbor advertisement: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+//go:build linux
+// +build linux
+
+package networkutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultDADTimeout is how long duplicate address detection waits for another host to answer for an address.
+	DefaultDADTimeout = time.Second
+
+	// ARP probes are sent like RFC 5227 does, spread over the timeout.
+	arpProbeCount   = 3
+	arpPacketLength = 28
+	arpHardwareEth  = 1
+	arpOpRequest    = 1
+
+	ipv6DADPollInterval = 50 * time.Millisecond
+)
+
+// htons converts a short to the network byte order, as the protocol of packet sockets is.
+func htons(v uint16) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return binary.LittleEndian.Uint16(b)
+}
+
+// probeIPv4Address sends ARP probes for the address on the interface and returns an *AddressConflictError if
+// another host answers for it, or probes for it at the same time, before the timeout.
+func probeIPv4Address(ifName string, ip net.IP, timeout time.Duration) error {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to get interface %s: %w", ifName, err)
+	}
+
+	fd, broadcast, err := openARPSocket(iface)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	// the sender address is unspecified as in RFC 5227, so that the caches of the other hosts are not updated with
+	// an address which may be in use.
+	probe := newARPRequest(iface.HardwareAddr, net.IPv4zero, ip)
+	interval := timeout / arpProbeCount
+	buf := make([]byte, 128)
+
+	for i := 0; i < arpProbeCount; i++ {
+		if err = unix.Sendto(fd, probe, 0, broadcast); err != nil {
+			return fmt.Errorf("failed to send ARP probe for %s: %w", ip, err)
+		}
+
+		deadline := time.Now().Add(interval)
+		for remaining := interval; remaining > 0; remaining = time.Until(deadline) {
+			tv := unix.NsecToTimeval(remaining.Nanoseconds())
+			if err = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+				return fmt.Errorf("failed to set ARP socket timeout: %w", err)
+			}
+
+			n, _, recvErr := unix.Recvfrom(fd, buf, 0)
+			if errors.Is(recvErr, unix.EAGAIN) || errors.Is(recvErr, unix.EINTR) {
+				continue
+			}
+			if recvErr != nil {
+				return fmt.Errorf("failed to receive ARP packets: %w", recvErr)
+			}
+
+			if mac := arpConflict(buf[:n], iface.HardwareAddr, ip); mac != nil {
+				return &AddressConflictError{IP: ip, Interface: ifName, HardwareAddr: mac}
+			}
+		}
+	}
+
+	return nil
+}
+
+// openARPSocket opens a packet socket for the ARP packets of the interface and returns it with the broadcast
+// address of the interface.
+func openARPSocket(iface *net.Interface) (int, *unix.SockaddrLinklayer, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return -1, nil, fmt.Errorf("failed to open ARP socket: %w", err)
+	}
+
+	if err = unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: iface.Index}); err != nil {
+		unix.Close(fd)
+		return -1, nil, fmt.Errorf("failed to bind ARP socket to %s: %w", iface.Name, err)
+	}
+
+	broadcast := &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ARP),
+		Ifindex:  iface.Index,
+		Halen:    uint8(len(iface.HardwareAddr)),
+	}
+	copy(broadcast.Addr[:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	return fd, broadcast, nil
+}
+
+// newARPRequest returns an ARP request for the target address from the sender address. The target MAC is zero.
+func newARPRequest(mac net.HardwareAddr, senderIP, targetIP net.IP) []byte {
+	p := make([]byte, arpPacketLength)
+	binary.BigEndian.PutUint16(p[0:2], arpHardwareEth)
+	binary.BigEndian.PutUint16(p[2:4], unix.ETH_P_IP)
+	p[4] = 6
+	p[5] = 4
+	binary.BigEndian.PutUint16(p[6:8], arpOpRequest)
+	copy(p[8:14], mac)
+	copy(p[14:18], senderIP.To4())
+	copy(p[24:28], targetIP.To4())
+	return p
+}
+
+// arpConflict returns the sender MAC of an ARP packet from another host which uses the address, either as its
+// sender address or as the target of its own probe.
+func arpConflict(p []byte, mac net.HardwareAddr, ip net.IP) net.HardwareAddr {
+	if len(p) < arpPacketLength || binary.BigEndian.Uint16(p[2:4]) != unix.ETH_P_IP || p[4] != 6 || p[5] != 4 {
+		return nil
+	}
+
+	sender := net.HardwareAddr(p[8:14])
+	if bytes.Equal(sender, mac) {
+		return nil
+	}
+
+	senderIP, targetIP := net.IP(p[14:18]), net.IP(p[24:28])
+	if senderIP.Equal(ip) || (senderIP.Equal(net.IPv4zero) && targetIP.Equal(ip)) {
+		return append(net.HardwareAddr(nil), sender...)
+	}
+
+	return nil
+}
+
+// waitForIPv6DAD waits for the kernel to complete the duplicate address detection of an address assigned to
+// the interface. An address which failed it is removed and an *AddressConflictError is returned. An address
+// still tentative after the timeout is left to the kernel.
+func (nu NetworkUtils) waitForIPv6DAD(ifName string, ipNet *net.IPNet, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		addresses, err := nu.netlink.GetIPAddresses(ifName)
+		if err != nil {
+			return NewError("get ip addresses", ifName, ObjectAddress, err)
+		}
+
+		tentative := false
+		for _, addr := range addresses {
+			if !addr.IPNet.IP.Equal(ipNet.IP) {
+				continue
+			}
+			if addr.Flags&unix.IFA_F_DADFAILED != 0 {
+				if err := nu.netlink.DeleteIPAddress(ifName, ipNet.IP, ipNet); err != nil {
+					log.Printf("[net] Failed to remove duplicate address %v from link %v: %v.", ipNet.IP, ifName, err)
+				}
+				return &AddressConflictError{IP: ipNet.IP, Interface: ifName}
+			}
+			tentative = addr.Flags&unix.IFA_F_TENTATIVE != 0
+		}
+
+		if !tentative {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			log.Printf("[net] Address %v on link %v is still tentative after %v.", ipNet.IP, ifName, timeout)
+			return nil
+		}
+		time.Sleep(ipv6DADPollInterval)
+	}
+}
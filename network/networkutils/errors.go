@@ -0,0 +1,157 @@
+package networkutils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/Azure/azure-container-networking/netlink"
+)
+
+// Sentinel errors describing why a network operation failed. Callers should
+// match on these with errors.Is instead of inspecting error strings. They are
+// the sentinels of the netlink package, so errors of either package match them.
+var (
+	ErrLinkNotFound   = netlink.ErrNoSuchDevice
+	ErrLinkExists     = netlink.ErrExists
+	ErrAddressExists  = netlink.ErrExists
+	ErrRouteExists    = netlink.ErrExists
+	ErrRouteNotFound  = netlink.ErrNotFound
+	ErrNamespaceGone  = errors.New("network namespace no longer exists")
+	ErrResourceBusy   = netlink.ErrResourceBusy
+	ErrNotPermitted   = netlink.ErrNotPermitted
+	ErrInvalidRequest = netlink.ErrInvalidRequest
+	// ErrAddressConflict is returned when duplicate address detection finds the address in use by another host.
+	ErrAddressConflict = errors.New("address already in use by another host")
+)
+
+// AddressConflictError is returned by duplicate address detection when another host answers for the address
+// being assigned. It matches ErrAddressConflict with errors.Is.
+type AddressConflictError struct {
+	IP        net.IP
+	Interface string
+	// HardwareAddr is the MAC address of the host using the address, if known.
+	HardwareAddr net.HardwareAddr
+}
+
+func (e *AddressConflictError) Error() string {
+	if e.HardwareAddr != nil {
+		return fmt.Sprintf("address %s on %s: %v: in use by %s", e.IP, e.Interface, ErrAddressConflict, e.HardwareAddr)
+	}
+	return fmt.Sprintf("address %s on %s: %v", e.IP, e.Interface, ErrAddressConflict)
+}
+
+// Is reports whether target is ErrAddressConflict.
+func (e *AddressConflictError) Is(target error) bool {
+	return target == ErrAddressConflict
+}
+
+// Object identifies the kind of object an operation acts upon. It is used to
+// resolve errno values which are ambiguous on their own, such as EEXIST.
+type Object int
+
+const (
+	ObjectLink Object = iota
+	ObjectAddress
+	ObjectRoute
+	ObjectNamespace
+)
+
+// Error is a typed network error carrying the failed operation, the object it
+// targeted, a sentinel Kind and the underlying cause.
+type Error struct {
+	Op     string
+	Target string
+	Kind   error
+	Err    error
+}
+
+func (e *Error) Error() string {
+	op := e.Op
+	if e.Target != "" {
+		op = fmt.Sprintf("%s %s", e.Op, e.Target)
+	}
+
+	if e.Kind == nil {
+		return fmt.Sprintf("%s: %v", op, e.Err)
+	}
+	return fmt.Sprintf("%s: %v: %v", op, e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying cause so that errors.Is can still match
+// syscall.Errno values.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel Kind of this error.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// NewError wraps err into an *Error, classifying it into one of the sentinel
+// errors based on the errno and the object the operation acted upon.
+// It returns nil if err is nil and returns err unchanged if it already is an *Error.
+func NewError(op, target string, obj Object, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var netErr *Error
+	if errors.As(err, &netErr) {
+		return err
+	}
+
+	return &Error{
+		Op:     op,
+		Target: target,
+		Kind:   classify(obj, err),
+		Err:    err,
+	}
+}
+
+// classify maps an error to a sentinel error. ENOENT is the only errno whose
+// meaning depends on the object the operation acted upon.
+func classify(obj Object, err error) error {
+	if errors.Is(err, syscall.ENOENT) {
+		switch obj {
+		case ObjectNamespace:
+			return ErrNamespaceGone
+		case ObjectLink:
+			return ErrLinkNotFound
+		}
+	}
+
+	return netlink.Classify(err)
+}
+
+// IsRetriable reports whether an operation which failed with err may succeed
+// if retried later. Errors caused by a missing namespace or an invalid request
+// are permanent, while transient kernel conditions are retriable.
+func IsRetriable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNamespaceGone),
+		errors.Is(err, ErrInvalidRequest),
+		errors.Is(err, ErrNotPermitted):
+		return false
+	case errors.Is(err, ErrResourceBusy):
+		return true
+	}
+
+	return false
+}
+
+// IsAlreadyExists reports whether err indicates that the object being created already exists.
+func IsAlreadyExists(err error) bool {
+	return errors.Is(err, netlink.ErrExists) || errors.Is(err, syscall.EEXIST)
+}
+
+// IsNotFound reports whether err indicates that the object being acted upon does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrLinkNotFound) ||
+		errors.Is(err, ErrRouteNotFound) ||
+		errors.Is(err, ErrNamespaceGone)
+}
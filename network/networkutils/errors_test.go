@@ -0,0 +1,107 @@
+package networkutils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		obj       Object
+		err       error
+		kind      error
+		retriable bool
+	}{
+		{
+			name: "missing link",
+			obj:  ObjectLink,
+			err:  syscall.ENODEV,
+			kind: ErrLinkNotFound,
+		},
+		{
+			name: "address exists",
+			obj:  ObjectAddress,
+			err:  syscall.EEXIST,
+			kind: ErrAddressExists,
+		},
+		{
+			name: "route exists",
+			obj:  ObjectRoute,
+			err:  syscall.EEXIST,
+			kind: ErrRouteExists,
+		},
+		{
+			name: "route not found",
+			obj:  ObjectRoute,
+			err:  syscall.ESRCH,
+			kind: ErrRouteNotFound,
+		},
+		{
+			name: "namespace gone",
+			obj:  ObjectNamespace,
+			err:  &os.PathError{Op: "open", Path: "/var/run/netns/test", Err: syscall.ENOENT},
+			kind: ErrNamespaceGone,
+		},
+		{
+			name: "interface lookup failed",
+			obj:  ObjectLink,
+			err:  fmt.Errorf("GetNetworkInterfaceByName failed: %w", &net.OpError{Op: "route", Net: "ip+net", Err: errors.New("no such network interface")}),
+			kind: ErrLinkNotFound,
+		},
+		{
+			name:      "busy",
+			obj:       ObjectLink,
+			err:       syscall.EBUSY,
+			kind:      ErrResourceBusy,
+			retriable: true,
+		},
+		{
+			name: "invalid",
+			obj:  ObjectRoute,
+			err:  syscall.EINVAL,
+			kind: ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewError("op", "target", tt.obj, tt.err)
+			require.ErrorIs(t, err, tt.kind)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.retriable, IsRetriable(err))
+
+			var netErr *Error
+			require.ErrorAs(t, fmt.Errorf("wrapped: %w", err), &netErr)
+			require.Equal(t, "op", netErr.Op)
+			require.Equal(t, "target", netErr.Target)
+		})
+	}
+}
+
+func TestNewErrorPreservesExisting(t *testing.T) {
+	require.NoError(t, NewError("op", "target", ObjectLink, nil))
+
+	inner := NewError("add route", "eth0", ObjectRoute, syscall.EEXIST)
+	outer := NewError("set link", "eth0", ObjectLink, fmt.Errorf("wrapped: %w", inner))
+	require.ErrorIs(t, outer, ErrRouteExists)
+	require.True(t, IsAlreadyExists(outer))
+	require.False(t, IsNotFound(outer))
+}
+
+func TestUnclassifiedError(t *testing.T) {
+	errTest := errors.New("test error")
+	err := NewError("op", "", ObjectLink, errTest)
+	require.ErrorIs(t, err, errTest)
+	require.False(t, IsRetriable(err))
+	require.False(t, IsAlreadyExists(err))
+	require.False(t, IsNotFound(err))
+	require.Equal(t, "op: test error", err.Error())
+}
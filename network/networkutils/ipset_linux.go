@@ -0,0 +1,143 @@
+//go:build linux
+// +build linux
+
+package networkutils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	ipsetCreateCmd  = "ipset -exist create %s hash:net family %s"
+	ipsetAddCmd     = "ipset -exist add %s %s"
+	ipsetDelCmd     = "ipset -exist del %s %s"
+	ipsetDestroyCmd = "ipset destroy %s"
+	ipsetListCmd    = "ipset list %s -output save"
+	ipsetListAllCmd = "ipset list -name"
+
+	// ipset names are limited to 31 characters, bridge names to 15.
+	allowedHostsIPSetSuffix  = "-allowed-hosts"
+	privateRangesIPSetSuffix = "-private-ranges"
+	// ipv6SetSuffix tells the IPv6 set apart, a set only holds addresses of one family.
+	ipv6SetSuffix = "6"
+
+	ipsetFamilyV4 = "inet"
+	ipsetFamilyV6 = "inet6"
+)
+
+func getAllowedHostsIPSetName(bridgeName, version string) string {
+	return ipsetName(bridgeName+allowedHostsIPSetSuffix, version)
+}
+
+func getPrivateRangesIPSetName(bridgeName, version string) string {
+	return ipsetName(bridgeName+privateRangesIPSetSuffix, version)
+}
+
+func ipsetName(name, version string) string {
+	if version == iptables.V6 {
+		return name + ipv6SetSuffix
+	}
+
+	return name
+}
+
+// ipsetClient programs the hash:net ipsets referenced by the bridge filter rules.
+type ipsetClient struct {
+	plClient platform.ExecClient
+}
+
+func newIPSetClient(plClient platform.ExecClient) ipsetClient {
+	return ipsetClient{
+		plClient: plClient,
+	}
+}
+
+// ensureSet creates the ipset for addresses of the IP version if it does not exist yet.
+func (c ipsetClient) ensureSet(setName, version string) error {
+	family := ipsetFamilyV4
+	if version == iptables.V6 {
+		family = ipsetFamilyV6
+	}
+
+	if _, err := c.plClient.ExecuteCommand(fmt.Sprintf(ipsetCreateCmd, setName, family)); err != nil {
+		log.Printf("[net] Failed to create ipset %s: %v", setName, err)
+		return fmt.Errorf("failed to create ipset %s: %w", setName, err)
+	}
+
+	return nil
+}
+
+// addAddresses adds the addresses to the ipset, addresses which are already members are ignored.
+func (c ipsetClient) addAddresses(setName string, addresses []string) error {
+	for _, address := range addresses {
+		if _, err := c.plClient.ExecuteCommand(fmt.Sprintf(ipsetAddCmd, setName, address)); err != nil {
+			log.Printf("[net] Failed to add %s to ipset %s: %v", address, setName, err)
+			return fmt.Errorf("failed to add %s to ipset %s: %w", address, setName, err)
+		}
+	}
+
+	return nil
+}
+
+// removeAddresses removes the addresses from the ipset, addresses which are not members are ignored.
+func (c ipsetClient) removeAddresses(setName string, addresses []string) error {
+	for _, address := range addresses {
+		if _, err := c.plClient.ExecuteCommand(fmt.Sprintf(ipsetDelCmd, setName, address)); err != nil {
+			log.Printf("[net] Failed to remove %s from ipset %s: %v", address, setName, err)
+			return fmt.Errorf("failed to remove %s from ipset %s: %w", address, setName, err)
+		}
+	}
+
+	return nil
+}
+
+// destroySet deletes the ipset. The set must not be referenced by any rule anymore.
+func (c ipsetClient) destroySet(setName string) error {
+	if _, err := c.plClient.ExecuteCommand(fmt.Sprintf(ipsetDestroyCmd, setName)); err != nil {
+		log.Printf("[net] Failed to destroy ipset %s: %v", setName, err)
+		return fmt.Errorf("failed to destroy ipset %s: %w", setName, err)
+	}
+
+	return nil
+}
+
+// setExists returns true if the ipset exists.
+func (c ipsetClient) setExists(setName string) (bool, error) {
+	out, err := c.plClient.ExecuteCommand(ipsetListAllCmd)
+	if err != nil {
+		log.Printf("[net] Failed to list ipsets: %v", err)
+		return false, fmt.Errorf("failed to list ipsets: %w", err)
+	}
+
+	for _, name := range strings.Fields(out) {
+		if name == setName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// countMembers returns the number of entries of the ipset.
+func (c ipsetClient) countMembers(setName string) (int, error) {
+	out, err := c.plClient.ExecuteCommand(fmt.Sprintf(ipsetListCmd, setName))
+	if err != nil {
+		log.Printf("[net] Failed to list ipset %s: %v", setName, err)
+		return 0, fmt.Errorf("failed to list ipset %s: %w", setName, err)
+	}
+
+	// The save format has a create line followed by one add line per member.
+	members := 0
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "add ") {
+			members++
+		}
+	}
+
+	return members, nil
+}
@@ -0,0 +1,169 @@
+//go:build linux
+// +build linux
+
+package networkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPSetNames(t *testing.T) {
+	require.Equal(t, "azSnatbr-allowed-hosts", getAllowedHostsIPSetName("azSnatbr", iptables.V4))
+	require.Equal(t, "azSnatbr-allowed-hosts6", getAllowedHostsIPSetName("azSnatbr", iptables.V6))
+	require.Equal(t, "azSnatbr-private-ranges6", getPrivateRangesIPSetName("azSnatbr", iptables.V6))
+
+	// ipset names are limited to 31 characters, bridge names to 15
+	require.LessOrEqual(t, len(getPrivateRangesIPSetName("abcdefghijklmno", iptables.V6)), 31)
+}
+
+func TestFilterAddresses(t *testing.T) {
+	addresses := []string{"10.0.0.4", "fd00::4/128", "168.63.129.16/32", "fe80::/10", "invalid"}
+	require.Equal(t, []string{"10.0.0.4", "168.63.129.16/32"}, filterAddresses(addresses, iptables.V4))
+	require.Equal(t, []string{"fd00::4/128", "fe80::/10"}, filterAddresses(addresses, iptables.V6))
+
+	for _, version := range []string{iptables.V4, iptables.V6} {
+		ranges := getPrivateIPSpace(version)
+		require.Equal(t, ranges, filterAddresses(ranges, version))
+	}
+}
+
+// fakeIPSet is an exec client keeping the ipsets in memory. Only the ipset commands are handled.
+type fakeIPSet struct {
+	sets     map[string][]string
+	commands []string
+}
+
+func newFakeIPSet() *fakeIPSet {
+	return &fakeIPSet{sets: make(map[string][]string)}
+}
+
+func (f *fakeIPSet) ExecuteCommand(command string) (string, error) {
+	f.commands = append(f.commands, command)
+
+	var set, member, family string
+	switch {
+	case command == ipsetListAllCmd:
+		var names []string
+		for name := range f.sets {
+			names = append(names, name)
+		}
+		return strings.Join(names, "\n"), nil
+	case matches(command, ipsetCreateCmd, &set, &family):
+		if _, ok := f.sets[set]; !ok {
+			f.sets[set] = []string{}
+		}
+	case matches(command, ipsetAddCmd, &set, &member):
+		if !contains(f.sets[set], member) {
+			f.sets[set] = append(f.sets[set], member)
+		}
+	case matches(command, ipsetDelCmd, &set, &member):
+		var members []string
+		for _, m := range f.sets[set] {
+			if m != member {
+				members = append(members, m)
+			}
+		}
+		f.sets[set] = members
+	case matches(command, ipsetListCmd, &set):
+		members, ok := f.sets[set]
+		if !ok {
+			return "", errors.New("ipset does not exist")
+		}
+		out := "create " + set + " hash:net family inet\n"
+		for _, m := range members {
+			out += "add " + set + " " + m + "\n"
+		}
+		return out, nil
+	case matches(command, ipsetDestroyCmd, &set):
+		delete(f.sets, set)
+	default:
+		return "", errors.New("unexpected command " + command)
+	}
+
+	return "", nil
+}
+
+func (f *fakeIPSet) ExecuteCommandContext(context.Context, string, *platform.ExecOptions) (*platform.ExecResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func matches(command, format string, args ...*string) bool {
+	ptrs := make([]interface{}, len(args))
+	for i := range args {
+		ptrs[i] = args[i]
+	}
+	n, err := fmt.Sscanf(command, format, ptrs...)
+	return err == nil && n == len(args) && fmt.Sprintf(format, deref(args)...) == command
+}
+
+func deref(args []*string) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, a := range args {
+		values[i] = *a
+	}
+	return values
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAllowIPAddressesDeleteKeepsOtherMembers(t *testing.T) {
+	fake := newFakeIPSet()
+	nu := NewNetworkUtils(netlink.NewMockNetlink(false, ""), fake)
+	setName := getAllowedHostsIPSetName("azbr0", iptables.V4)
+	fake.sets[setName] = []string{"10.0.0.4", "10.0.0.5", "168.63.129.16"}
+
+	require.NoError(t, nu.AllowIPAddresses("azbr0", []string{"10.0.0.4", "10.0.0.5"}, iptables.FamilyV4, iptables.Delete))
+	require.Equal(t, []string{"168.63.129.16"}, fake.sets[setName], "only the deleted addresses are removed")
+	require.NotContains(t, fake.commands, fmt.Sprintf(ipsetDestroyCmd, setName), "the set is in use")
+
+	require.NoError(t, nu.AllowIPAddresses("azbr0", []string{"168.63.129.16"}, iptables.FamilyV4, iptables.Delete))
+	require.NotContains(t, fake.sets, setName, "the set is destroyed once it is empty")
+
+	// deleting from a set which does not exist is not an error
+	require.NoError(t, nu.AllowIPAddresses("azbr0", []string{"10.0.0.4"}, iptables.FamilyV4, iptables.Delete))
+}
+
+func TestIPSetClientCountMembers(t *testing.T) {
+	fake := newFakeIPSet()
+	c := newIPSetClient(fake)
+
+	require.NoError(t, c.ensureSet("azbr0-allowed-hosts", iptables.V4))
+	require.NoError(t, c.addAddresses("azbr0-allowed-hosts", []string{"10.0.0.4", "10.0.0.5", "10.0.0.4"}))
+
+	n, err := c.countMembers("azbr0-allowed-hosts")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	exists, err := c.setExists("azbr0-allowed-hosts")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	require.NoError(t, c.removeAddresses("azbr0-allowed-hosts", []string{"10.0.0.4"}))
+	n, err = c.countMembers("azbr0-allowed-hosts")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	require.NoError(t, c.destroySet("azbr0-allowed-hosts"))
+	exists, err = c.setExists("azbr0-allowed-hosts")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	_, err = newIPSetClient(platform.NewMockExecClient(true)).countMembers("azbr0-allowed-hosts")
+	require.Error(t, err)
+}
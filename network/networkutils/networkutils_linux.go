@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
@@ -33,19 +34,16 @@ const (
 	enableIPForwardCmd   = "sysctl -w net.ipv4.ip_forward=1"
 	toggleIPV6Cmd        = "sysctl -w net.ipv6.conf.all.disable_ipv6=%d"
 	enableIPV6ForwardCmd = "sysctl -w net.ipv6.conf.all.forwarding=1"
+	enableIPV6IfCmd      = "sysctl -w net.ipv6.conf.%s.disable_ipv6=0"
 	disableRACmd         = "sysctl -w net.ipv6.conf.%s.accept_ra=0"
 	acceptRAV6File       = "/proc/sys/net/ipv6/conf/%s/accept_ra"
 )
 
-var errorNetworkUtils = errors.New("NetworkUtils Error")
-
-func newErrorNetworkUtils(errStr string) error {
-	return fmt.Errorf("%w : %s", errorNetworkUtils, errStr)
-}
-
 type NetworkUtils struct {
 	netlink  netlink.NetlinkInterface
 	plClient platform.ExecClient
+	// dadTimeout enables the duplicate address detection of the addresses assigned to interfaces.
+	dadTimeout time.Duration
 }
 
 func NewNetworkUtils(nl netlink.NetlinkInterface, plClient platform.ExecClient) NetworkUtils {
@@ -55,7 +53,17 @@ func NewNetworkUtils(nl netlink.NetlinkInterface, plClient platform.ExecClient)
 	}
 }
 
-func (nu NetworkUtils) CreateEndpoint(hostVethName, containerVethName string, macAddress net.HardwareAddr) error {
+// WithDuplicateAddressDetection returns a copy of the NetworkUtils which detects duplicate addresses before
+// assigning them, waiting up to the timeout for another host to answer for them.
+func (nu NetworkUtils) WithDuplicateAddressDetection(timeout time.Duration) NetworkUtils {
+	nu.dadTimeout = timeout
+	return nu
+}
+
+func (nu NetworkUtils) CreateEndpoint(hostVethName, containerVethName string, macAddress net.HardwareAddr) (err error) {
+	done := TimeOperation(OpCreateEndpoint)
+	defer func() { done(err) }()
+
 	log.Printf("[net] Creating veth pair %v %v.", hostVethName, containerVethName)
 
 	link := netlink.VEthLink{
@@ -67,128 +75,275 @@ func (nu NetworkUtils) CreateEndpoint(hostVethName, containerVethName string, ma
 		PeerName: containerVethName,
 	}
 
-	err := nu.netlink.AddLink(&link)
+	err = retryNetlink("create veth pair", hostVethName, func() error { return nu.netlink.AddLink(&link) })
 	if err != nil {
 		log.Printf("[net] Failed to create veth pair, err:%v.", err)
-		return newErrorNetworkUtils(err.Error())
+		return NewError("create veth pair", hostVethName, ObjectLink, err)
 	}
 
 	log.Printf("[net] Setting link %v state up.", hostVethName)
-	err = nu.netlink.SetLinkState(hostVethName, true)
+	err = retryNetlink("set link state", hostVethName, func() error { return nu.netlink.SetLinkState(hostVethName, true) })
 	if err != nil {
-		return newErrorNetworkUtils(err.Error())
+		return NewError("set link state", hostVethName, ObjectLink, err)
 	}
 
 	if err := nu.DisableRAForInterface(hostVethName); err != nil {
-		return newErrorNetworkUtils(err.Error())
+		return NewError("disable ra", hostVethName, ObjectLink, err)
 	}
 
 	return nil
 }
 
-func (nu NetworkUtils) SetupContainerInterface(containerVethName, targetIfName string) error {
+func (nu NetworkUtils) SetupContainerInterface(containerVethName, targetIfName string) (err error) {
+	done := TimeOperation(OpSetupContainerInterface)
+	defer func() { done(err) }()
+
 	// Interface needs to be down before renaming.
 	log.Printf("[net] Setting link %v state down.", containerVethName)
-	if err := nu.netlink.SetLinkState(containerVethName, false); err != nil {
-		return newErrorNetworkUtils(err.Error())
+	if err := retryNetlink("set link state", containerVethName, func() error {
+		return nu.netlink.SetLinkState(containerVethName, false)
+	}); err != nil {
+		return NewError("set link state", containerVethName, ObjectLink, err)
 	}
 
 	// Rename the container interface.
 	log.Printf("[net] Setting link %v name %v.", containerVethName, targetIfName)
 	if err := nu.netlink.SetLinkName(containerVethName, targetIfName); err != nil {
-		return newErrorNetworkUtils(err.Error())
+		return NewError("set link name", containerVethName, ObjectLink, err)
 	}
 
 	if err := nu.DisableRAForInterface(targetIfName); err != nil {
-		return newErrorNetworkUtils(err.Error())
+		return NewError("disable ra", targetIfName, ObjectLink, err)
 	}
 
 	// Bring the interface back up.
 	log.Printf("[net] Setting link %v state up.", targetIfName)
-	err := nu.netlink.SetLinkState(targetIfName, true)
+	err = retryNetlink("set link state", targetIfName, func() error { return nu.netlink.SetLinkState(targetIfName, true) })
 	if err != nil {
-		return newErrorNetworkUtils(err.Error())
+		return NewError("set link state", targetIfName, ObjectLink, err)
 	}
 	return nil
 }
 
-func (nu NetworkUtils) AssignIPToInterface(interfaceName string, ipAddresses []net.IPNet) error {
-	var err error
+func (nu NetworkUtils) AssignIPToInterface(interfaceName string, ipAddresses []net.IPNet) (err error) {
+	done := TimeOperation(OpAssignIPToInterface)
+	defer func() { done(err) }()
+
 	// Assign IP address to container network interface.
 	for i, ipAddr := range ipAddresses {
+		isIPv4 := ipAddr.IP.To4() != nil
+		if nu.dadTimeout > 0 && isIPv4 {
+			log.Printf("[net] Probing IP address %v on link %v.", ipAddr.IP, interfaceName)
+			if err = probeIPv4Address(interfaceName, ipAddr.IP, nu.dadTimeout); err != nil {
+				return NewError("probe ip address "+ipAddr.String(), interfaceName, ObjectAddress, err)
+			}
+		}
+
 		log.Printf("[net] Adding IP address %v to link %v.", ipAddr.String(), interfaceName)
-		err = nu.netlink.AddIPAddress(interfaceName, ipAddr.IP, &ipAddresses[i])
+		err = retryNetlink("add ip address "+ipAddr.String(), interfaceName, func() error {
+			return nu.netlink.AddIPAddress(interfaceName, ipAddr.IP, &ipAddresses[i])
+		})
+		if errors.Is(err, netlink.ErrExists) {
+			log.Printf("[net] IP address %v already exists on link %v.", ipAddr.String(), interfaceName)
+			continue
+		}
 		if err != nil {
-			return newErrorNetworkUtils(err.Error())
+			return NewError("add ip address "+ipAddr.String(), interfaceName, ObjectAddress, err)
+		}
+
+		// the kernel detects duplicate IPv6 addresses itself once they are assigned.
+		if nu.dadTimeout > 0 && !isIPv4 {
+			if err = nu.waitForIPv6DAD(interfaceName, &ipAddresses[i], nu.dadTimeout); err != nil {
+				return NewError("add ip address "+ipAddr.String(), interfaceName, ObjectAddress, err)
+			}
 		}
 	}
 
 	return nil
 }
 
-func addOrDeleteFilterRule(bridgeName, action, ipAddress, chainName, target string) error {
-	var err error
-	option := "i"
-
+// newBridgeFilterRule returns the filter rule matching traffic of the bridge in the chain.
+func newBridgeFilterRule(bridgeName, chainName, version string, match iptables.Match, target string) *iptables.Rule {
+	ifMatch := iptables.MatchInInterface(bridgeName)
 	if chainName == iptables.Output {
-		option = "o"
+		ifMatch = iptables.MatchOutInterface(bridgeName)
 	}
 
-	matchCondition := fmt.Sprintf("-%s %s -d %s", option, bridgeName, ipAddress)
+	return &iptables.Rule{
+		Version: version,
+		Table:   iptables.Filter,
+		Chain:   chainName,
+		Matches: []iptables.Match{ifMatch, match},
+		Target:  iptables.Jump(target),
+	}
+}
 
-	switch action {
-	case iptables.Insert:
-		err = iptables.InsertIptableRule(iptables.V4, iptables.Filter, chainName, matchCondition, target)
-	case iptables.Append:
-		err = iptables.AppendIptableRule(iptables.V4, iptables.Filter, chainName, matchCondition, target)
-	case iptables.Delete:
-		err = iptables.DeleteIptableRule(iptables.V4, iptables.Filter, chainName, matchCondition, target)
+func addOrDeleteFilterRule(rule *iptables.Rule, action string) error {
+	if action == iptables.Delete {
+		return iptables.DeleteRule(rule)
 	}
 
-	return err
+	return iptables.EnsureRule(rule, action)
+}
+
+// removeLegacyFilterRules deletes the per address rules which were programmed on the bridge
+// before the addresses were moved into an ipset. Legacy rules were only programmed for IPv4.
+func removeLegacyFilterRules(bridgeName string, addresses []string, target string) {
+	for _, chainName := range getFilterChains() {
+		for _, address := range filterAddresses(addresses, iptables.V4) {
+			rule := newBridgeFilterRule(bridgeName, chainName, iptables.V4, iptables.MatchDestination(address), target)
+			if !rule.Exists() {
+				continue
+			}
+
+			log.Printf("[net] Removing legacy rule for %s from chain %s", address, chainName)
+			if err := iptables.DeleteRule(rule); err != nil {
+				log.Printf("[net] Failed to remove legacy rule for %s from chain %s: %v", address, chainName, err)
+			}
+		}
+	}
+}
+
+// programIPSetFilterRules adds or deletes a single match-set rule per filter chain for the ipset.
+func programIPSetFilterRules(bridgeName, setName, version, action, target string) error {
+	for _, chainName := range getFilterChains() {
+		rule := newBridgeFilterRule(bridgeName, chainName, version, iptables.MatchSet(setName, "dst"), target)
+		if err := addOrDeleteFilterRule(rule, action); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// filterAddresses returns the addresses of the IP version.
+func filterAddresses(addresses []string, version string) []string {
+	family := iptables.FamilyV4
+	if version == iptables.V6 {
+		family = iptables.FamilyV6
+	}
+
+	var filtered []string
+	for _, address := range addresses {
+		if iptables.FamilyOf(address) == family {
+			filtered = append(filtered, address)
+		}
+	}
+
+	return filtered
 }
 
-func AllowIPAddresses(bridgeName string, skipAddresses []string, action string) error {
-	chains := getFilterChains()
-	target := getFilterchainTarget()
+// AllowIPAddresses allows traffic to the given addresses through the bridge for each IP version of the
+// family. The addresses of a version are kept in the bridge's allowed-hosts ipset of the version which
+// is referenced by one rule per filter chain. Deleting addresses removes them from the set, the rules
+// and the set are only removed once no address is left.
+func (nu NetworkUtils) AllowIPAddresses(bridgeName string, skipAddresses []string, family iptables.Family, action string) error {
+	ipsetClient := newIPSetClient(nu.plClient)
 
 	log.Printf("[net] Addresses to allow %v", skipAddresses)
 
-	for _, address := range skipAddresses {
-		if err := addOrDeleteFilterRule(bridgeName, action, address, chains[0], target[0]); err != nil {
-			return err
+	if action != iptables.Delete {
+		removeLegacyFilterRules(bridgeName, skipAddresses, iptables.Accept)
+	}
+
+	for _, version := range family.Versions() {
+		setName := getAllowedHostsIPSetName(bridgeName, version)
+		addresses := filterAddresses(skipAddresses, version)
+
+		if action == iptables.Delete {
+			if err := deleteAllowedAddresses(ipsetClient, bridgeName, setName, version, addresses); err != nil {
+				return err
+			}
+			continue
 		}
 
-		if err := addOrDeleteFilterRule(bridgeName, action, address, chains[1], target[0]); err != nil {
+		if err := ipsetClient.ensureSet(setName, version); err != nil {
 			return err
 		}
 
-		if err := addOrDeleteFilterRule(bridgeName, action, address, chains[2], target[0]); err != nil {
+		if err := ipsetClient.addAddresses(setName, addresses); err != nil {
 			return err
 		}
 
+		if err := programIPSetFilterRules(bridgeName, setName, version, action, iptables.Accept); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func BlockIPAddresses(bridgeName, action string) error {
-	privateIPAddresses := getPrivateIPSpace()
-	chains := getFilterChains()
-	target := getFilterchainTarget()
+// deleteAllowedAddresses removes the addresses from the allowed-hosts set, and the set along with the
+// rules referencing it once it is empty, since the other members are still allowed by the rules.
+func deleteAllowedAddresses(ipsetClient ipsetClient, bridgeName, setName, version string, addresses []string) error {
+	exists, err := ipsetClient.setExists(setName)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		if err := ipsetClient.removeAddresses(setName, addresses); err != nil {
+			return err
+		}
+
+		members, err := ipsetClient.countMembers(setName)
+		if err != nil {
+			return err
+		}
+
+		if members > 0 {
+			log.Printf("[net] Keeping ipset %s with %d remaining addresses", setName, members)
+			return nil
+		}
+	}
+
+	if err := programIPSetFilterRules(bridgeName, setName, version, iptables.Delete, iptables.Accept); err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	return ipsetClient.destroySet(setName)
+}
+
+// BlockIPAddresses blocks traffic to the private address space through the bridge for each IP version
+// of the family. The ranges of a version are kept in the bridge's private-ranges ipset of the version
+// which is referenced by one rule per filter chain.
+func (nu NetworkUtils) BlockIPAddresses(bridgeName string, family iptables.Family, action string) error {
+	ipsetClient := newIPSetClient(nu.plClient)
+
+	for _, version := range family.Versions() {
+		privateIPAddresses := getPrivateIPSpace(version)
+		setName := getPrivateRangesIPSetName(bridgeName, version)
+
+		log.Printf("[net] Addresses to block %v", privateIPAddresses)
+
+		if action == iptables.Delete {
+			if err := programIPSetFilterRules(bridgeName, setName, version, action, iptables.Drop); err != nil {
+				return err
+			}
 
-	log.Printf("[net] Addresses to block %v", privateIPAddresses)
+			if err := ipsetClient.destroySet(setName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if version == iptables.V4 {
+			removeLegacyFilterRules(bridgeName, privateIPAddresses, iptables.Drop)
+		}
 
-	for _, ipAddress := range privateIPAddresses {
-		if err := addOrDeleteFilterRule(bridgeName, action, ipAddress, chains[0], target[1]); err != nil {
+		if err := ipsetClient.ensureSet(setName, version); err != nil {
 			return err
 		}
 
-		if err := addOrDeleteFilterRule(bridgeName, action, ipAddress, chains[1], target[1]); err != nil {
+		if err := ipsetClient.addAddresses(setName, privateIPAddresses); err != nil {
 			return err
 		}
 
-		if err := addOrDeleteFilterRule(bridgeName, action, ipAddress, chains[2], target[1]); err != nil {
+		if err := programIPSetFilterRules(bridgeName, setName, version, action, iptables.Drop); err != nil {
 			return err
 		}
 	}
@@ -196,19 +351,31 @@ func BlockIPAddresses(bridgeName, action string) error {
 	return nil
 }
 
-// This fucntion enables ip forwarding in VM and allow forwarding packets from the interface
-func (nu NetworkUtils) EnableIPForwarding(ifName string) error {
-	// Enable ip forwading on linux vm.
-	// sysctl -w net.ipv4.ip_forward=1
-	cmd := fmt.Sprint(enableIPForwardCmd)
-	_, err := nu.plClient.ExecuteCommand(cmd)
-	if err != nil {
-		log.Printf("[net] Enable ipforwarding failed with: %v", err)
-		return err
+// This fucntion enables ip forwarding in VM for each IP version of the family and allow forwarding
+// packets from the interface
+func (nu NetworkUtils) EnableIPForwarding(ifName string, family iptables.Family) error {
+	for _, version := range family.Versions() {
+		// Enable ip forwading on linux vm.
+		// sysctl -w net.ipv4.ip_forward=1
+		cmd := enableIPForwardCmd
+		if version == iptables.V6 {
+			cmd = enableIPV6ForwardCmd
+		}
+
+		_, err := nu.plClient.ExecuteCommand(cmd)
+		if err != nil {
+			log.Printf("[net] Enable ipforwarding failed with: %v", err)
+			return err
+		}
 	}
 
 	// Append a rule in forward chain to allow forwarding from bridge
-	if err := iptables.AppendIptableRule(iptables.V4, iptables.Filter, iptables.Forward, "", iptables.Accept); err != nil {
+	rule := &iptables.Rule{
+		Table:  iptables.Filter,
+		Chain:  iptables.Forward,
+		Target: iptables.Jump(iptables.Accept),
+	}
+	if err := iptables.EnsureRuleFamily(family, rule, iptables.Append); err != nil {
 		log.Printf("[net] Appending forward chain rule: allow traffic coming from snatbridge failed with: %v", err)
 		return err
 	}
@@ -239,15 +406,28 @@ func (nu NetworkUtils) UpdateIPV6Setting(disable int) error {
 	return err
 }
 
-// This fucntion adds rule which snat to ip passed filtered by match string.
-func AddSnatRule(match string, ip net.IP) error {
-	version := iptables.V4
-	if ip.To4() == nil {
-		version = iptables.V6
+// AddSnatRule adds a rule which snats the traffic filtered by match to ip, with ip6tables if ip is an
+// IPv6 address. The addresses the match filters must be of the same family as ip.
+func AddSnatRule(match iptables.Match, ip net.IP) error {
+	rule := &iptables.Rule{
+		Table:   iptables.Nat,
+		Chain:   iptables.Postrouting,
+		Matches: []iptables.Match{match},
+		Target:  iptables.Jump(iptables.Snat, "--to", ip.String()),
+	}
+	return iptables.EnsureRuleFamily(iptables.FamilyOf(ip.String()), rule, iptables.Insert)
+}
+
+// EnableIPV6ForInterface enables IPv6 on the interface, which is disabled on interfaces created while
+// IPv6 is disabled by default.
+func (nu NetworkUtils) EnableIPV6ForInterface(ifName string) error {
+	cmd := fmt.Sprintf(enableIPV6IfCmd, ifName)
+	out, err := nu.plClient.ExecuteCommand(cmd)
+	if err != nil {
+		log.Errorf("[net] Enabling ipv6 on %s failed with err: %v out: %v", ifName, err, out)
 	}
 
-	target := fmt.Sprintf("SNAT --to %s", ip.String())
-	return iptables.InsertIptableRule(version, iptables.Nat, iptables.Postrouting, match, target)
+	return err
 }
 
 func (nu NetworkUtils) DisableRAForInterface(ifName string) error {
@@ -267,7 +447,13 @@ func (nu NetworkUtils) DisableRAForInterface(ifName string) error {
 	return err
 }
 
-func getPrivateIPSpace() []string {
+// getPrivateIPSpace returns the private ranges of the IP version. Link local IPv6 addresses are not
+// included since neighbor discovery depends on them.
+func getPrivateIPSpace(version string) []string {
+	if version == iptables.V6 {
+		return []string{"fc00::/7"}
+	}
+
 	privateIPAddresses := []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16"}
 	return privateIPAddresses
 }
@@ -276,8 +462,3 @@ func getFilterChains() []string {
 	chains := []string{"FORWARD", "INPUT", "OUTPUT"}
 	return chains
 }
-
-func getFilterchainTarget() []string {
-	actions := []string{"ACCEPT", "DROP"}
-	return actions
-}
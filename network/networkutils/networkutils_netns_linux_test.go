@@ -0,0 +1,129 @@
+//go:build linux
+// +build linux
+
+package networkutils
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/nstest"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointPlumbingNetns(t *testing.T) {
+	host := nstest.New(t)
+	container := nstest.New(t)
+	nl := netlink.NewNetlink()
+	nu := NewNetworkUtils(nl, platform.NewExecClient())
+	mac, _ := net.ParseMAC("aa:aa:aa:aa:aa:aa")
+
+	host.Run(t, func() error {
+		if err := nu.CreateEndpoint("azv1", "azv1-2", mac); err != nil {
+			return err
+		}
+		return nl.SetLinkNetNs("azv1-2", container.Fd())
+	})
+	require.Equal(t, mac, host.Interface(t, "azv1").HardwareAddr)
+	require.True(t, host.Interface(t, "azv1").Flags&net.FlagUp != 0)
+
+	_, ipNet, _ := net.ParseCIDR("10.240.0.10/16")
+	ipNet.IP = net.ParseIP("10.240.0.10")
+	container.Run(t, func() error {
+		if err := nu.SetupContainerInterface("azv1-2", "eth0"); err != nil {
+			return err
+		}
+		if err := nu.AssignIPToInterface("eth0", []net.IPNet{*ipNet}); err != nil {
+			return err
+		}
+		// assigning an address twice is not an error
+		return nu.AssignIPToInterface("eth0", []net.IPNet{*ipNet})
+	})
+	require.True(t, container.Interface(t, "eth0").Flags&net.FlagUp != 0)
+	container.RequireRoute(t, "eth0", "10.240.0.0/16", "")
+
+	if container.Sysctl(t, "net.ipv6.conf.all.disable_ipv6") == "0" {
+		host.RequireSysctl(t, "net.ipv6.conf.azv1.accept_ra", "0")
+		container.RequireSysctl(t, "net.ipv6.conf.eth0.accept_ra", "0")
+	}
+}
+
+func TestEnableIPForwardingNetns(t *testing.T) {
+	nstest.RequireCommand(t, "iptables")
+	host := nstest.New(t)
+	nu := NewNetworkUtils(netlink.NewNetlink(), platform.NewExecClient())
+
+	host.Run(t, func() error {
+		return nu.EnableIPForwarding("eth0", iptables.FamilyV4)
+	})
+	host.RequireSysctl(t, "net.ipv4.ip_forward", "1")
+	host.RequireIptablesRule(t, iptables.Filter, iptables.Forward, "-j", iptables.Accept)
+}
+
+func TestDuplicateAddressDetectionNetns(t *testing.T) {
+	host := nstest.New(t)
+	container := nstest.New(t)
+	host.AddVethPair(t, "azv1", container, "eth0")
+	host.AddAddress(t, "azv1", "10.241.0.1/24")
+	nu := NewNetworkUtils(netlink.NewNetlink(), platform.NewExecClient()).WithDuplicateAddressDetection(300 * time.Millisecond)
+
+	_, ipNet, _ := net.ParseCIDR("10.241.0.0/24")
+	taken, free := *ipNet, *ipNet
+	taken.IP = net.ParseIP("10.241.0.1")
+	free.IP = net.ParseIP("10.241.0.2")
+
+	container.Run(t, func() error {
+		err := nu.AssignIPToInterface("eth0", []net.IPNet{taken})
+		require.ErrorIs(t, err, ErrAddressConflict)
+
+		var conflictErr *AddressConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		require.Equal(t, host.Interface(t, "azv1").HardwareAddr, conflictErr.HardwareAddr)
+
+		return nu.AssignIPToInterface("eth0", []net.IPNet{free})
+	})
+	container.RequireRoute(t, "eth0", "10.241.0.0/24", "")
+}
+
+func TestAnnounceIPAddressesNetns(t *testing.T) {
+	host := nstest.New(t)
+	container := nstest.New(t)
+	host.AddVethPair(t, "azv1", container, "eth0")
+	host.AddAddress(t, "azv1", "10.241.0.1/24")
+	container.AddAddress(t, "eth0", "10.241.0.2/24")
+
+	// the address belonged to another endpoint, whose MAC the host still has.
+	staleMac, _ := net.ParseMAC("aa:aa:aa:aa:aa:aa")
+	host.Run(t, func() error {
+		return netlink.NewNetlink().SetOrRemoveLinkAddress(netlink.LinkInfo{
+			Name:       "azv1",
+			IPAddr:     net.ParseIP("10.241.0.2"),
+			MacAddress: staleMac,
+		}, netlink.ADD, netlink.NUD_STALE)
+	})
+
+	_, ipNet, _ := net.ParseCIDR("10.241.0.0/24")
+	ipNet.IP = net.ParseIP("10.241.0.2")
+	container.Run(t, func() error {
+		return AnnounceIPAddresses("eth0", []net.IPNet{*ipNet})
+	})
+
+	mac := container.Interface(t, "eth0").HardwareAddr
+	require.Eventually(t, func() bool {
+		var neighbors []*netlink.Neighbor
+		host.Run(t, func() (err error) {
+			neighbors, err = netlink.NewNetlink().ListNeighbors("azv1")
+			return err
+		})
+		for _, neigh := range neighbors {
+			if neigh.IP.Equal(ipNet.IP) && neigh.HardwareAddr.String() == mac.String() {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond, "the host updates its neighbor entry from the gratuitous ARP")
+}
@@ -0,0 +1,84 @@
+package networkutils
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Operations on the endpoints whose latency and result are reported.
+const (
+	OpCreateEndpoint          = "CreateEndpoint"
+	OpSetupContainerInterface = "SetupContainerInterface"
+	OpAssignIPToInterface     = "AssignIPToInterface"
+	OpAddRoute                = "AddRoute"
+	OpProgramARP              = "ProgramARP"
+	OpAnnounceAddresses       = "AnnounceAddresses"
+)
+
+// Results of an operation. A failed operation is reported with the class of its error.
+const (
+	ResultSucceeded       = "Succeeded"
+	ResultAlreadyExists   = "AlreadyExists"
+	ResultNotFound        = "NotFound"
+	ResultNamespaceGone   = "NamespaceGone"
+	ResultResourceBusy    = "ResourceBusy"
+	ResultNotPermitted    = "NotPermitted"
+	ResultInvalidRequest  = "InvalidRequest"
+	ResultAddressConflict = "AddressConflict"
+	ResultOther           = "Other"
+)
+
+// OperationObserver receives the duration and the result of each operation.
+type OperationObserver func(operation string, duration time.Duration, result string)
+
+var (
+	observerMutex sync.RWMutex
+	observer      OperationObserver
+)
+
+// SetOperationObserver sets the observer of the operations, such as the recorder of the operation metrics of a
+// CNI command. Operations aren't observed without one.
+func SetOperationObserver(o OperationObserver) {
+	observerMutex.Lock()
+	defer observerMutex.Unlock()
+	observer = o
+}
+
+// TimeOperation starts timing an operation and returns the function which reports it with its error.
+func TimeOperation(operation string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		observerMutex.RLock()
+		o := observer
+		observerMutex.RUnlock()
+
+		if o != nil {
+			o(operation, time.Since(start), ErrorClass(err))
+		}
+	}
+}
+
+// ErrorClass returns the result of an operation which returned err, which is ResultSucceeded for a nil error.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ResultSucceeded
+	case IsAlreadyExists(err):
+		return ResultAlreadyExists
+	case errors.Is(err, ErrNamespaceGone):
+		return ResultNamespaceGone
+	case IsNotFound(err):
+		return ResultNotFound
+	case errors.Is(err, ErrResourceBusy):
+		return ResultResourceBusy
+	case errors.Is(err, ErrNotPermitted):
+		return ResultNotPermitted
+	case errors.Is(err, ErrInvalidRequest):
+		return ResultInvalidRequest
+	case errors.Is(err, ErrAddressConflict):
+		return ResultAddressConflict
+	}
+
+	return ResultOther
+}
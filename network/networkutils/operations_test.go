@@ -0,0 +1,42 @@
+package networkutils
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorClass(t *testing.T) {
+	require.Equal(t, ResultSucceeded, ErrorClass(nil))
+	require.Equal(t, ResultAlreadyExists, ErrorClass(NewError("add route", "eth0", ObjectRoute, syscall.EEXIST)))
+	require.Equal(t, ResultNotFound, ErrorClass(NewError("set link state", "eth0", ObjectLink, syscall.ENODEV)))
+	require.Equal(t, ResultNamespaceGone, ErrorClass(NewError("enter namespace", "ns", ObjectNamespace, syscall.ENOENT)))
+	require.Equal(t, ResultNotPermitted, ErrorClass(NewError("add address", "eth0", ObjectAddress, syscall.EPERM)))
+	require.Equal(t, ResultAddressConflict, ErrorClass(NewError("add ip address", "eth0", ObjectAddress,
+		&AddressConflictError{IP: net.ParseIP("10.0.0.1"), Interface: "eth0"})))
+	require.Equal(t, ResultOther, ErrorClass(errors.New("boom")))
+}
+
+func TestTimeOperation(t *testing.T) {
+	var operations, results []string
+	SetOperationObserver(func(operation string, duration time.Duration, result string) {
+		require.Positive(t, duration)
+		operations = append(operations, operation)
+		results = append(results, result)
+	})
+	defer SetOperationObserver(nil)
+
+	TimeOperation(OpAddRoute)(nil)
+	TimeOperation(OpProgramARP)(NewError("add neighbor", "eth0", ObjectLink, syscall.EBUSY))
+
+	require.Equal(t, []string{OpAddRoute, OpProgramARP}, operations)
+	require.Equal(t, []string{ResultSucceeded, ResultResourceBusy}, results)
+
+	SetOperationObserver(nil)
+	TimeOperation(OpAddRoute)(nil)
+	require.Len(t, operations, 2, "operations aren't observed without an observer")
+}
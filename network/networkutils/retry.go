@@ -0,0 +1,39 @@
+package networkutils
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/avast/retry-go/v3"
+)
+
+const netlinkRetryAttempts = 5
+
+var (
+	// the delay doubles after each attempt up to the max delay, which bounds the whole retry to about half a second.
+	netlinkRetryDelay    = 20 * time.Millisecond
+	netlinkRetryMaxDelay = 200 * time.Millisecond
+)
+
+// isTransientNetlinkError returns true for the errnos the kernel returns while it is busy, e.g. during a mass pod
+// churn: EBUSY, EAGAIN, EINTR and ENOBUFS.
+func isTransientNetlinkError(err error) bool {
+	return netlink.Classify(err) == netlink.ErrResourceBusy
+}
+
+// retryNetlink calls the netlink request until it succeeds, fails with a non transient error or runs out of
+// attempts, backing off exponentially in between. It returns the last error.
+func retryNetlink(op, target string, request func() error) error {
+	//nolint:wrapcheck // the callers wrap the error of the request
+	return retry.Do(request,
+		retry.Attempts(netlinkRetryAttempts),
+		retry.Delay(netlinkRetryDelay),
+		retry.MaxDelay(netlinkRetryMaxDelay),
+		retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true),
+		retry.RetryIf(isTransientNetlinkError),
+		retry.OnRetry(func(n uint, err error) {
+			log.Printf("[net] Retrying %s of %s after attempt %d failed: %v", op, target, n+1, err)
+		}))
+}
@@ -0,0 +1,66 @@
+package networkutils
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryNetlink(t *testing.T) {
+	delay, maxDelay := netlinkRetryDelay, netlinkRetryMaxDelay
+	netlinkRetryDelay, netlinkRetryMaxDelay = time.Millisecond, time.Millisecond
+	defer func() { netlinkRetryDelay, netlinkRetryMaxDelay = delay, maxDelay }()
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			name:         "succeeds",
+			wantAttempts: 1,
+		},
+		{
+			name:         "retries transient errors",
+			errs:         []error{syscall.EBUSY, syscall.EAGAIN, syscall.EINTR},
+			wantAttempts: 4,
+		},
+		{
+			name:         "doesn't retry other errors",
+			errs:         []error{syscall.EEXIST},
+			wantErr:      syscall.EEXIST,
+			wantAttempts: 1,
+		},
+		{
+			name:         "gives up after the last attempt",
+			errs:         []error{syscall.EBUSY, syscall.EBUSY, syscall.EBUSY, syscall.EBUSY, syscall.EBUSY, syscall.EBUSY},
+			wantErr:      syscall.EBUSY,
+			wantAttempts: netlinkRetryAttempts,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryNetlink("add link", "eth0", func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+
+			require.Equal(t, tt.wantAttempts, attempts)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.True(t, errors.Is(err, tt.wantErr), "unexpected error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+//go:build linux
+// +build linux
+
+// Package nstest runs tests of the Linux endpoint plumbing against the kernel in throwaway network
+// namespaces. The tests need CAP_NET_ADMIN and skip themselves otherwise, run them with
+//
+//	go test -exec sudo ./network/...
+package nstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/netns"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+const namePrefix = "acntest"
+
+var seq uint32
+
+// Namespace is a named network namespace deleted at the end of the test that created it.
+type Namespace struct {
+	Name string
+	fd   int
+	ns   *netns.Netns
+}
+
+// RequireNetAdmin skips the test if namespaces and links can't be created.
+func RequireNetAdmin(t testing.TB) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("requires root, run with go test -exec sudo")
+	}
+}
+
+// RequireCommand skips the test if the command is not installed.
+func RequireCommand(t testing.TB, name string) {
+	t.Helper()
+	if !HasCommand(name) {
+		t.Skipf("requires %s", name)
+	}
+}
+
+// HasCommand returns whether the command is installed.
+func HasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// New creates a network namespace with its loopback interface up. The calling thread is left in its
+// namespace.
+func New(t testing.TB) *Namespace {
+	t.Helper()
+	RequireNetAdmin(t)
+
+	n := &Namespace{
+		Name: fmt.Sprintf("%s-%d-%d", namePrefix, os.Getpid(), atomic.AddUint32(&seq, 1)),
+		ns:   netns.New(),
+	}
+
+	runtime.LockOSThread()
+	orig, err := n.ns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Fatalf("failed to get current namespace: %v", err)
+	}
+	n.fd, err = n.ns.NewNamed(n.Name)
+	// NewNamed moves the thread to the new namespace, move it back before anything else runs on it.
+	if setErr := n.ns.Set(orig); setErr != nil {
+		// leave the thread locked so that it exits with the goroutine
+		t.Fatalf("failed to return to namespace: %v", setErr)
+	}
+	_ = n.ns.Close(orig)
+	runtime.UnlockOSThread()
+	if err != nil {
+		t.Skipf("failed to create namespace %s: %v", n.Name, err)
+	}
+
+	t.Cleanup(func() {
+		_ = n.ns.Close(n.fd)
+		if err := n.ns.DeleteNamed(n.Name); err != nil {
+			t.Logf("failed to delete namespace %s: %v", n.Name, err)
+		}
+	})
+
+	n.Run(t, func() error {
+		return netlink.NewNetlink().SetLinkState("lo", true)
+	})
+
+	return n
+}
+
+// Fd returns the file descriptor of the namespace as taken by SetLinkNetNs.
+func (n *Namespace) Fd() uintptr {
+	return uintptr(n.fd)
+}
+
+// Path returns the path of the namespace as found in EndpointInfo.NetNsPath.
+func (n *Namespace) Path() string {
+	return filepath.Join("/var/run/netns", n.Name)
+}
+
+// Run runs fn with the calling thread in the namespace and fails the test if it returns an error.
+func (n *Namespace) Run(t testing.TB, fn func() error) {
+	t.Helper()
+	require.NoError(t, n.ns.ExecuteInNS(n.fd, fn), "in namespace %s", n.Name)
+}
+
+// AddVethPair creates a veth pair with name in the namespace and peerName in the peer namespace, both up.
+func (n *Namespace) AddVethPair(t testing.TB, name string, peer *Namespace, peerName string) {
+	t.Helper()
+	nl := netlink.NewNetlink()
+	// the peer is created under a temporary name so that both ends can have the same name
+	tmpName := fmt.Sprintf("acnpeer%d", atomic.AddUint32(&seq, 1))
+
+	n.Run(t, func() error {
+		if err := nl.AddLink(&netlink.VEthLink{
+			LinkInfo: netlink.LinkInfo{Type: netlink.LINK_TYPE_VETH, Name: name},
+			PeerName: tmpName,
+		}); err != nil {
+			return err
+		}
+		if err := nl.SetLinkNetNs(tmpName, peer.Fd()); err != nil {
+			return err
+		}
+		return nl.SetLinkState(name, true)
+	})
+	peer.Run(t, func() error {
+		if err := nl.SetLinkName(tmpName, peerName); err != nil {
+			return err
+		}
+		return nl.SetLinkState(peerName, true)
+	})
+}
+
+// AddAddress assigns the address in CIDR notation to the interface.
+func (n *Namespace) AddAddress(t testing.TB, ifName, cidr string) {
+	t.Helper()
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	require.NoError(t, err)
+
+	n.Run(t, func() error {
+		return netlink.NewNetlink().AddIPAddress(ifName, ip, ipNet)
+	})
+}
+
+// Interface returns the interface of the namespace, failing the test if there is none.
+func (n *Namespace) Interface(t testing.TB, ifName string) *net.Interface {
+	t.Helper()
+	var iface *net.Interface
+
+	n.Run(t, func() (err error) {
+		iface, err = net.InterfaceByName(ifName)
+		return err
+	})
+
+	return iface
+}
+
+// HasInterface returns whether the namespace has the interface.
+func (n *Namespace) HasInterface(t testing.TB, ifName string) bool {
+	t.Helper()
+	found := false
+
+	n.Run(t, func() error {
+		_, err := net.InterfaceByName(ifName)
+		found = err == nil
+		return nil
+	})
+
+	return found
+}
+
+// LinkMaster returns the name of the bridge or bond the interface is attached to, empty if none. The
+// test is skipped if iproute2 is not installed.
+func (n *Namespace) LinkMaster(t testing.TB, ifName string) string {
+	t.Helper()
+	RequireCommand(t, "ip")
+	master := ""
+
+	n.Run(t, func() error {
+		out, err := exec.Command("ip", "-o", "link", "show", "dev", ifName).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("ip link show %s: %w: %s", ifName, err, out)
+		}
+
+		fields := strings.Fields(string(out))
+		for i := 0; i < len(fields)-1; i++ {
+			if fields[i] == "master" {
+				master = fields[i+1]
+			}
+		}
+		return nil
+	})
+
+	return master
+}
+
+// Routes returns the routes of the main table through the interface.
+func (n *Namespace) Routes(t testing.TB, ifName string) []*netlink.Route {
+	t.Helper()
+	var routes []*netlink.Route
+
+	n.Run(t, func() error {
+		iface, err := net.InterfaceByName(ifName)
+		if err != nil {
+			return err
+		}
+
+		routes, err = netlink.NewNetlink().GetIPRoute(&netlink.Route{Family: unix.AF_UNSPEC, LinkIndex: iface.Index})
+		return err
+	})
+
+	return routes
+}
+
+// RequireRoute fails the test unless the interface has a route to dst, through gw if it is not empty.
+// The default route is 0.0.0.0/0 or ::/0.
+func (n *Namespace) RequireRoute(t testing.TB, ifName, dst, gw string) {
+	t.Helper()
+	routes := n.Routes(t, ifName)
+	for _, r := range routes {
+		if routeDst(r) == dst && (gw == "" || r.Gw.Equal(net.ParseIP(gw))) {
+			return
+		}
+	}
+
+	t.Fatalf("no route to %s via %q on %s in %s, routes: %s", dst, gw, ifName, n.Name, formatRoutes(routes))
+}
+
+// RequireNoRoute fails the test if the interface has a route to dst.
+func (n *Namespace) RequireNoRoute(t testing.TB, ifName, dst string) {
+	t.Helper()
+	routes := n.Routes(t, ifName)
+	for _, r := range routes {
+		if routeDst(r) == dst {
+			t.Fatalf("unexpected route to %s on %s in %s, routes: %s", dst, ifName, n.Name, formatRoutes(routes))
+		}
+	}
+}
+
+// RequireNeighbor fails the test unless the interface resolves ip to mac.
+func (n *Namespace) RequireNeighbor(t testing.TB, ifName, ip, mac string) {
+	t.Helper()
+	var neighbors []*netlink.Neighbor
+
+	n.Run(t, func() (err error) {
+		neighbors, err = netlink.NewNetlink().ListNeighbors(ifName)
+		return err
+	})
+
+	var found []string
+	for _, neigh := range neighbors {
+		if neigh.IP.Equal(net.ParseIP(ip)) && neigh.HardwareAddr.String() == mac {
+			return
+		}
+		found = append(found, fmt.Sprintf("%v lladdr %v", neigh.IP, neigh.HardwareAddr))
+	}
+
+	t.Fatalf("no neighbor %s lladdr %s on %s in %s, neighbors: [%s]", ip, mac, ifName, n.Name, strings.Join(found, ", "))
+}
+
+// Sysctl returns the value of the sysctl of the namespace, as in net.ipv4.conf.eth0.proxy_arp.
+func (n *Namespace) Sysctl(t testing.TB, key string) string {
+	t.Helper()
+	var value []byte
+
+	n.Run(t, func() (err error) {
+		value, err = os.ReadFile(filepath.Join("/proc/sys", strings.ReplaceAll(key, ".", "/")))
+		return err
+	})
+
+	return string(bytes.TrimSpace(value))
+}
+
+// RequireSysctl fails the test unless the sysctl of the namespace has the value.
+func (n *Namespace) RequireSysctl(t testing.TB, key, value string) {
+	t.Helper()
+	require.Equal(t, value, n.Sysctl(t, key), "sysctl %s in %s", key, n.Name)
+}
+
+// HasIptablesRule returns whether the rule is in the chain of the table, as checked by iptables -C.
+// The test is skipped if iptables is not installed.
+func (n *Namespace) HasIptablesRule(t testing.TB, table, chain string, rulespec ...string) bool {
+	t.Helper()
+	RequireCommand(t, "iptables")
+	found := false
+
+	n.Run(t, func() error {
+		args := append([]string{"-w", "-t", table, "-C", chain}, rulespec...)
+		out, err := exec.Command("iptables", args...).CombinedOutput()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			// the rule or the chain does not exist
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("iptables %s: %w: %s", strings.Join(args, " "), err, out)
+		}
+
+		found = true
+		return nil
+	})
+
+	return found
+}
+
+// RequireIptablesRule fails the test unless the rule is in the chain of the table.
+func (n *Namespace) RequireIptablesRule(t testing.TB, table, chain string, rulespec ...string) {
+	t.Helper()
+	if !n.HasIptablesRule(t, table, chain, rulespec...) {
+		t.Fatalf("no rule %q in %s %s in %s", strings.Join(rulespec, " "), table, chain, n.Name)
+	}
+}
+
+func routeDst(r *netlink.Route) string {
+	if r.Dst != nil {
+		return r.Dst.String()
+	}
+	if r.Family == unix.AF_INET6 {
+		return "::/0"
+	}
+
+	return "0.0.0.0/0"
+}
+
+func formatRoutes(routes []*netlink.Route) string {
+	s := make([]string, 0, len(routes))
+	for _, r := range routes {
+		if r.Gw != nil {
+			s = append(s, fmt.Sprintf("%s via %v", routeDst(r), r.Gw))
+		} else {
+			s = append(s, routeDst(r))
+		}
+	}
+
+	return "[" + strings.Join(s, ", ") + "]"
+}
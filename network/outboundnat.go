@@ -0,0 +1,125 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// UpdateOutboundNATExceptions sets the exceptions CNI manages on the OutBoundNAT policy of the endpoints of the
+// network, e.g. after the CNI config changed. The exceptions which were managed before are removed from the policy
+// and the new ones added, those set by other agents are kept. Endpoints whose managed exceptions are already the
+// desired ones are left as is.
+func (nm *networkManager) UpdateOutboundNATExceptions(networkID string, exceptions []string) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return err
+	}
+
+	var updated, failed int
+	var lastErr error
+	for _, ep := range nw.Endpoints {
+		if equalOutboundNATExceptions(ep.OutboundNATExceptions, exceptions) {
+			continue
+		}
+
+		if err := nm.updateOutboundNATExceptionsImpl(ep, ep.OutboundNATExceptions, exceptions); err != nil {
+			log.Printf("[net] Failed to update the outbound NAT exceptions of endpoint %s: %v", ep.Id, err)
+			failed++
+			lastErr = err
+			continue
+		}
+
+		log.Printf("[net] Updated the outbound NAT exceptions of endpoint %s from %v to %v", ep.Id, ep.OutboundNATExceptions, exceptions)
+		ep.OutboundNATExceptions = exceptions
+		updated++
+	}
+
+	if updated > 0 {
+		if err := nm.save(); err != nil {
+			return err
+		}
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("failed to update the outbound NAT exceptions of %d endpoints: %w", failed, lastErr)
+	}
+
+	return nil
+}
+
+// mergeOutboundNATExceptions returns the exceptions of an OutBoundNAT policy once the managed exceptions changed
+// from previous to desired: the previous ones are removed and the desired ones added, the others are kept.
+func mergeOutboundNATExceptions(existing, previous, desired []string) []string {
+	removed := make(map[string]bool, len(previous))
+	for _, exception := range previous {
+		removed[canonicalOutboundNATException(exception)] = true
+	}
+
+	merged := make([]string, 0, len(existing)+len(desired))
+	seen := make(map[string]bool, len(existing)+len(desired))
+	for _, exception := range existing {
+		key := canonicalOutboundNATException(exception)
+		if removed[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, exception)
+	}
+
+	for _, exception := range desired {
+		key := canonicalOutboundNATException(exception)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, exception)
+	}
+
+	return merged
+}
+
+// missingOutboundNATExceptions returns the desired exceptions which are not in the existing ones, e.g. because
+// another agent overwrote the OutBoundNAT policy.
+func missingOutboundNATExceptions(existing, desired []string) []string {
+	present := make(map[string]bool, len(existing))
+	for _, exception := range existing {
+		present[canonicalOutboundNATException(exception)] = true
+	}
+
+	var missing []string
+	for _, exception := range desired {
+		if !present[canonicalOutboundNATException(exception)] {
+			missing = append(missing, exception)
+		}
+	}
+
+	return missing
+}
+
+// equalOutboundNATExceptions returns whether the exceptions are the same, in any order.
+func equalOutboundNATExceptions(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	return len(missingOutboundNATExceptions(a, b)) == 0 && len(missingOutboundNATExceptions(b, a)) == 0
+}
+
+// canonicalOutboundNATException returns the exception in the form HNS reports it, so that "10.0.0.1/8" and
+// "10.0.0.0/8" compare equal. Exceptions which are neither a prefix nor an address are returned as is.
+func canonicalOutboundNATException(exception string) string {
+	if _, prefix, err := net.ParseCIDR(exception); err == nil {
+		return prefix.String()
+	}
+
+	if ip := net.ParseIP(exception); ip != nil {
+		return ip.String()
+	}
+
+	return exception
+}
@@ -0,0 +1,7 @@
+package network
+
+// updateOutboundNATExceptionsImpl does nothing, the linux endpoints are SNATed by iptables rules which exclude the
+// vnet CIDRs rather than by an OutBoundNAT policy.
+func (nm *networkManager) updateOutboundNATExceptionsImpl(*endpoint, []string, []string) error {
+	return nil
+}
@@ -0,0 +1,29 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeOutboundNATExceptions(t *testing.T) {
+	// the exceptions of other agents are kept, the previous managed ones replaced by the desired ones.
+	existing := []string{"10.0.0.0/8", "168.63.129.16/32", "10.224.0.0/12"}
+	merged := mergeOutboundNATExceptions(existing, []string{"10.224.0.1/12"}, []string{"10.0.0.0/8", "10.240.0.0/16"})
+	require.Equal(t, []string{"10.0.0.0/8", "168.63.129.16/32", "10.240.0.0/16"}, merged)
+
+	// an overwritten policy gets the managed exceptions back.
+	desired := []string{"10.0.0.0/8", "10.240.0.0/16"}
+	overwritten := []string{"168.63.129.16/32"}
+	require.Equal(t, desired, missingOutboundNATExceptions(overwritten, desired))
+	merged = mergeOutboundNATExceptions(overwritten, desired, desired)
+	require.Empty(t, missingOutboundNATExceptions(merged, desired))
+	require.Equal(t, []string{"168.63.129.16/32", "10.0.0.0/8", "10.240.0.0/16"}, merged)
+}
+
+func TestEqualOutboundNATExceptions(t *testing.T) {
+	require.True(t, equalOutboundNATExceptions(nil, []string{}))
+	require.True(t, equalOutboundNATExceptions([]string{"10.0.0.0/8", "fd00::/8"}, []string{"fd00::1/8", "10.1.0.0/8"}))
+	require.False(t, equalOutboundNATExceptions([]string{"10.0.0.0/8"}, []string{"10.0.0.0/16"}))
+	require.False(t, equalOutboundNATExceptions([]string{"10.0.0.0/8"}, nil))
+}
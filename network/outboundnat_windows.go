@@ -0,0 +1,167 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// errOutboundNATExceptionsMissing is a divergence the endpoint is repaired from in place, by adding the exceptions
+// back to its OutBoundNAT policy.
+var errOutboundNATExceptionsMissing = fmt.Errorf("%w: outbound NAT exceptions are missing", errEndpointDiverged)
+
+// outboundNATExceptionPolicy returns the index and the settings of the OutBoundNAT policy which SNATs the traffic of
+// the endpoint, -1 if it has none. The OutBoundNAT policies with destinations, such as the DNS NAT and loopback DSR
+// ones, only apply to their destinations and are skipped.
+func outboundNATExceptionPolicy(policies []hcn.EndpointPolicy) (int, hcn.OutboundNatPolicySetting, error) {
+	for i := range policies {
+		if policies[i].Type != hcn.OutBoundNAT {
+			continue
+		}
+
+		var setting hcn.OutboundNatPolicySetting
+		if len(policies[i].Settings) > 0 {
+			if err := json.Unmarshal(policies[i].Settings, &setting); err != nil {
+				return -1, setting, fmt.Errorf("failed to parse OutBoundNAT policy %s: %w", policies[i].Settings, err)
+			}
+		}
+
+		if len(setting.Destinations) == 0 {
+			return i, setting, nil
+		}
+	}
+
+	return -1, hcn.OutboundNatPolicySetting{}, nil
+}
+
+// setOutboundNATExceptions adds the managed exceptions to the OutBoundNAT policy of the endpoint being created.
+// An endpoint without OutBoundNAT policy isn't SNATed, so none is added to it.
+func setOutboundNATExceptions(hcnEndpoint *hcn.HostComputeEndpoint, exceptions []string) error {
+	if len(exceptions) == 0 {
+		return nil
+	}
+
+	i, setting, err := outboundNATExceptionPolicy(hcnEndpoint.Policies)
+	if err != nil || i < 0 {
+		return err
+	}
+
+	setting.Exceptions = mergeOutboundNATExceptions(setting.Exceptions, nil, exceptions)
+	if hcnEndpoint.Policies[i].Settings, err = json.Marshal(setting); err != nil {
+		return fmt.Errorf("failed to marshal OutBoundNAT policy: %w", err)
+	}
+
+	return nil
+}
+
+// applyOutboundNATExceptions replaces the OutBoundNAT policy of the hcn endpoint with one whose managed exceptions
+// changed from previous to desired. The policy is removed and added again, since updating it would replace the other
+// OutBoundNAT policies of the endpoint as well.
+func applyOutboundNATExceptions(hcnEndpoint *hcn.HostComputeEndpoint, previous, desired []string) error {
+	i, setting, err := outboundNATExceptionPolicy(hcnEndpoint.Policies)
+	if err != nil {
+		return err
+	}
+
+	if i < 0 {
+		log.Printf("[net] Not setting outbound NAT exceptions on hcn endpoint %s, it has no OutBoundNAT policy", hcnEndpoint.Id)
+		return nil
+	}
+
+	existing := setting.Exceptions
+	setting.Exceptions = mergeOutboundNATExceptions(existing, previous, desired)
+	if equalOutboundNATExceptions(existing, setting.Exceptions) {
+		return nil
+	}
+
+	rawSetting, err := json.Marshal(setting)
+	if err != nil {
+		return fmt.Errorf("failed to marshal OutBoundNAT policy: %w", err)
+	}
+
+	removeRequest := hcn.PolicyEndpointRequest{Policies: []hcn.EndpointPolicy{hcnEndpoint.Policies[i]}}
+	if err := Hnsv2.ApplyEndpointPolicy(hcnEndpoint, hcn.RequestTypeRemove, removeRequest); err != nil {
+		return fmt.Errorf("failed to remove OutBoundNAT policy of hcn endpoint %s: %w", hcnEndpoint.Id, err)
+	}
+
+	addRequest := hcn.PolicyEndpointRequest{Policies: []hcn.EndpointPolicy{{Type: hcn.OutBoundNAT, Settings: rawSetting}}}
+	if err := Hnsv2.ApplyEndpointPolicy(hcnEndpoint, hcn.RequestTypeAdd, addRequest); err != nil {
+		// the endpoint is SNATed without exceptions rather than not at all.
+		if restoreErr := Hnsv2.ApplyEndpointPolicy(hcnEndpoint, hcn.RequestTypeAdd, removeRequest); restoreErr != nil {
+			log.Printf("[net] Failed to restore OutBoundNAT policy of hcn endpoint %s: %v", hcnEndpoint.Id, restoreErr)
+		}
+		return fmt.Errorf("failed to add OutBoundNAT policy to hcn endpoint %s: %w", hcnEndpoint.Id, err)
+	}
+
+	log.Printf("[net] Set outbound NAT exceptions of hcn endpoint %s to %v", hcnEndpoint.Id, setting.Exceptions)
+	return nil
+}
+
+// updateOutboundNATExceptionsImpl updates the OutBoundNAT policy of the HNSv2 endpoint. HNSv1 endpoints keep the
+// exceptions they were created with.
+func (nm *networkManager) updateOutboundNATExceptionsImpl(ep *endpoint, previous, desired []string) error {
+	if useHnsV2, err := UseHnsV2(ep.NetNs); !useHnsV2 || err != nil {
+		log.Printf("[net] Not updating outbound NAT exceptions of HNSv1 endpoint %s", ep.Id)
+		return nil
+	}
+
+	hcnEndpoint, err := Hnsv2.GetEndpointByID(ep.HnsId)
+	if err != nil {
+		return fmt.Errorf("failed to get hcn endpoint %s: %w", ep.HnsId, err)
+	}
+
+	return applyOutboundNATExceptions(hcnEndpoint, previous, desired)
+}
+
+// checkOutboundNATExceptions returns an error wrapping errOutboundNATExceptionsMissing if exceptions managed on the
+// OutBoundNAT policy of the hcn endpoint are missing, e.g. because another agent overwrote the policy.
+func checkOutboundNATExceptions(ep *endpoint, hcnEndpoint *hcn.HostComputeEndpoint) error {
+	if len(ep.OutboundNATExceptions) == 0 {
+		return nil
+	}
+
+	i, setting, err := outboundNATExceptionPolicy(hcnEndpoint.Policies)
+	if err != nil || i < 0 {
+		return err
+	}
+
+	if missing := missingOutboundNATExceptions(setting.Exceptions, ep.OutboundNATExceptions); len(missing) > 0 {
+		return fmt.Errorf("%w: %v on hcn endpoint %s", errOutboundNATExceptionsMissing, missing, ep.HnsId)
+	}
+
+	return nil
+}
+
+// repairOutboundNATExceptions adds the missing managed exceptions back to the OutBoundNAT policy of the endpoint.
+func repairOutboundNATExceptions(ep *endpoint, dryRun bool) ([]string, error) {
+	hcnEndpoint, err := Hnsv2.GetEndpointByID(ep.HnsId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hcn endpoint %s: %w", ep.HnsId, err)
+	}
+
+	_, setting, err := outboundNATExceptionPolicy(hcnEndpoint.Policies)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := missingOutboundNATExceptions(setting.Exceptions, ep.OutboundNATExceptions)
+	actions := []string{fmt.Sprintf("add outbound NAT exceptions %v to hcn endpoint %s", sortedCopy(missing), ep.HnsId)}
+	if dryRun {
+		return actions, nil
+	}
+
+	if err := applyOutboundNATExceptions(hcnEndpoint, ep.OutboundNATExceptions, ep.OutboundNATExceptions); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
+
+// isOutboundNATExceptionsMissingError returns true if the only divergence of the endpoint is its outbound NAT
+// exceptions.
+func isOutboundNATExceptionsMissingError(err error) bool {
+	return errors.Is(err, errOutboundNATExceptionsMissing)
+}
@@ -0,0 +1,45 @@
+package network
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/stretchr/testify/require"
+)
+
+func outboundNATPolicy(t *testing.T, setting hcn.OutboundNatPolicySetting) hcn.EndpointPolicy {
+	t.Helper()
+	raw, err := json.Marshal(setting)
+	require.NoError(t, err)
+	return hcn.EndpointPolicy{Type: hcn.OutBoundNAT, Settings: raw}
+}
+
+func TestSetOutboundNATExceptions(t *testing.T) {
+	dnsNAT := outboundNATPolicy(t, hcn.OutboundNatPolicySetting{Destinations: []string{"168.63.129.16"}})
+	hcnEndpoint := &hcn.HostComputeEndpoint{
+		Policies: []hcn.EndpointPolicy{dnsNAT, outboundNATPolicy(t, hcn.OutboundNatPolicySetting{Exceptions: []string{"10.0.0.0/8"}})},
+	}
+
+	require.NoError(t, setOutboundNATExceptions(hcnEndpoint, []string{"10.0.0.0/8", "10.240.0.0/16"}))
+	require.Equal(t, dnsNAT, hcnEndpoint.Policies[0], "the NAT policies of destinations are kept")
+	i, setting, err := outboundNATExceptionPolicy(hcnEndpoint.Policies)
+	require.NoError(t, err)
+	require.Equal(t, 1, i)
+	require.Equal(t, []string{"10.0.0.0/8", "10.240.0.0/16"}, setting.Exceptions)
+
+	ep := &endpoint{HnsId: "hns", OutboundNATExceptions: []string{"10.240.0.0/16"}}
+	require.NoError(t, checkOutboundNATExceptions(ep, hcnEndpoint))
+
+	// another agent overwrote the policy.
+	hcnEndpoint.Policies[1] = outboundNATPolicy(t, hcn.OutboundNatPolicySetting{Exceptions: []string{"10.0.0.0/8"}})
+	err = checkOutboundNATExceptions(ep, hcnEndpoint)
+	require.ErrorIs(t, err, errOutboundNATExceptionsMissing)
+	require.True(t, IsEndpointDivergedError(err))
+
+	// endpoints which aren't SNATed get no OutBoundNAT policy.
+	hcnEndpoint = &hcn.HostComputeEndpoint{Policies: []hcn.EndpointPolicy{dnsNAT}}
+	require.NoError(t, setOutboundNATExceptions(hcnEndpoint, []string{"10.240.0.0/16"}))
+	require.Len(t, hcnEndpoint.Policies, 1)
+	require.NoError(t, checkOutboundNATExceptions(ep, hcnEndpoint))
+}
@@ -0,0 +1,38 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/Azure/azure-container-networking/network/vxlan"
+)
+
+// newVxlanClient returns the client of the VXLAN interface of an overlay network. The packets are encapsulated
+// over the bridge, which holds the IP address of the external interface once it is connected.
+func (nm *networkManager) newVxlanClient(vni int, extIf *externalInterface) *vxlan.Client {
+	return vxlan.NewClient(vni, extIf.BridgeName, nm.netlink, nm.netio)
+}
+
+// overlayVNI returns the VNI of the VNI option of an overlay network, or the default VNI if there is none.
+func overlayVNI(opt map[string]interface{}) (int, error) {
+	value, ok := opt[VNIKey].(string)
+	if !ok || value == "" {
+		return vxlan.DefaultVNI, nil
+	}
+
+	vni, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", vxlan.ErrInvalidVNI, value)
+	}
+
+	return vni, vxlan.ValidateVNI(vni)
+}
+
+func (nm *networkManager) getOverlayVtepMACImpl(nw *network) (net.HardwareAddr, error) {
+	return nm.newVxlanClient(nw.VNI, nw.extIf).HardwareAddr()
+}
+
+func (nm *networkManager) syncOverlayPeersImpl(nw *network, peers []vxlan.Peer) error {
+	return nm.newVxlanClient(nw.VNI, nw.extIf).SyncPeers(peers)
+}
@@ -0,0 +1,16 @@
+package network
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/network/vxlan"
+)
+
+// HNS programs the VXLAN peers of overlay networks on windows.
+func (nm *networkManager) getOverlayVtepMACImpl(*network) (net.HardwareAddr, error) {
+	return nil, vxlan.ErrNotSupported
+}
+
+func (nm *networkManager) syncOverlayPeersImpl(*network, []vxlan.Peer) error {
+	return vxlan.ErrNotSupported
+}
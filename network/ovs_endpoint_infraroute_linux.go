@@ -26,7 +26,7 @@ func AddInfraVnetEndpoint(client *OVSEndpointClient) error {
 
 func AddInfraEndpointRules(client *OVSEndpointClient, infraIP net.IPNet, hostPort string) error {
 	if client.enableInfraVnet {
-		return client.infraVnetClient.CreateInfraVnetRules(client.bridgeName, infraIP, client.hostPrimaryMac, hostPort)
+		return client.infraVnetClient.CreateInfraVnetRules(client.bridgeName, infraIP, client.hostPrimaryMac, hostPort, client.flowCookie)
 	}
 
 	return nil
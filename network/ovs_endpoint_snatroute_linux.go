@@ -32,6 +32,7 @@ func (client *OVSEndpointClient) NewSnatClient(snatBridgeIP, localIP string, epI
 			client.netlink,
 			client.plClient,
 		)
+		client.snatClient.Family = snatFamily(epInfo)
 	}
 }
 
@@ -26,6 +26,7 @@ type OVSEndpointClient struct {
 	snatClient               snat.Client
 	infraVnetClient          ovsinfravnet.OVSInfraVnetClient
 	vlanID                   int
+	flowCookie               uint64
 	enableSnatOnHost         bool
 	enableInfraVnet          bool
 	allowInboundFromHostToNC bool
@@ -60,6 +61,7 @@ func NewOVSEndpointClient(
 		hostPrimaryMac:           nw.extIf.MacAddress.String(),
 		containerVethName:        containerVethName,
 		vlanID:                   vlanid,
+		flowCookie:               ovsctl.FlowCookie(epInfo.Id),
 		enableSnatOnHost:         epInfo.EnableSnatOnHost,
 		enableInfraVnet:          epInfo.EnableInfraVnet,
 		allowInboundFromHostToNC: epInfo.AllowInboundFromHostToNC,
@@ -133,14 +135,14 @@ func (client *OVSEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
 		// This rule also checks if packets coming from right source ip based on the ovs port to prevent ip spoofing.
 		// Otherwise it drops the packet.
 		log.Printf("[ovs] Adding IP SNAT rule for egress traffic on %v.", containerOVSPort)
-		if err := client.ovsctlClient.AddIPSnatRule(client.bridgeName, ipAddr.IP, client.vlanID, containerOVSPort, client.hostPrimaryMac, hostPort); err != nil {
+		if err := client.ovsctlClient.AddIPSnatRule(client.bridgeName, ipAddr.IP, client.vlanID, containerOVSPort, client.hostPrimaryMac, hostPort, client.flowCookie); err != nil {
 			return err
 		}
 
 		// Add IP DNAT rule based on dst ip and vlanid - This rule changes the destination mac to corresponding container mac based on the ip and
 		// forwards the packet to corresponding container hostveth port
 		log.Printf("[ovs] Adding MAC DNAT rule for IP address %v on hostport %v, containerport: %v", ipAddr.IP.String(), hostPort, containerOVSPort)
-		if err := client.ovsctlClient.AddMacDnatRule(client.bridgeName, hostPort, ipAddr.IP, client.containerMac, client.vlanID, containerOVSPort); err != nil {
+		if err := client.ovsctlClient.AddMacDnatRule(client.bridgeName, hostPort, ipAddr.IP, client.containerMac, client.vlanID, containerOVSPort, client.flowCookie); err != nil {
 			return err
 		}
 	}
@@ -149,6 +151,10 @@ func (client *OVSEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
 		return err
 	}
 
+	if err := setBandwidthLimits(client.netlink, client.hostVethName, epInfo.BandwidthLimits); err != nil {
+		return err
+	}
+
 	return client.AddSnatEndpointRules()
 }
 
@@ -185,13 +191,20 @@ func (client *OVSEndpointClient) DeleteEndpointRules(ep *endpoint) {
 
 	client.DeleteSnatEndpointRules()
 	DeleteInfraVnetEndpointRules(client, ep, hostPort)
+
+	// The flows above are deleted by their match, which misses the flows of a port that was renumbered,
+	// so the flows tagged with the endpoint are purged too.
+	log.Printf("[ovs] Deleting flows of endpoint %v with cookie %#x", ep.Id, client.flowCookie)
+	if err := client.ovsctlClient.DeleteFlowsByCookie(client.bridgeName, client.flowCookie); err != nil {
+		log.Printf("[ovs] Deleting flows of endpoint %v failed with error %v", ep.Id, err)
+	}
 }
 
 func (client *OVSEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
 	// Move the container interface to container's network namespace.
 	log.Printf("[ovs] Setting link %v netns %v.", client.containerVethName, epInfo.NetNsPath)
 	if err := client.netlink.SetLinkNetNs(client.containerVethName, nsID); err != nil {
-		return err
+		return networkutils.NewError("set link netns", client.containerVethName, networkutils.ObjectNamespace, err)
 	}
 
 	if err := client.MoveSnatEndpointToContainerNS(epInfo.NetNsPath, nsID); err != nil {
@@ -218,7 +231,7 @@ func (client *OVSEndpointClient) SetupContainerInterfaces(epInfo *EndpointInfo)
 
 func (client *OVSEndpointClient) ConfigureContainerInterfacesAndRoutes(epInfo *EndpointInfo) error {
 	nuc := networkutils.NewNetworkUtils(client.netlink, client.plClient)
-	if err := nuc.AssignIPToInterface(client.containerVethName, epInfo.IPAddresses); err != nil {
+	if err := assignIPAddresses(nuc, client.containerVethName, epInfo); err != nil {
 		return err
 	}
 
@@ -241,6 +254,10 @@ func (client *OVSEndpointClient) DeleteEndpoints(ep *endpoint) error {
 		return err
 	}
 
+	if err := deleteBandwidthLimits(client.netlink, ep.HostIfName); err != nil {
+		return err
+	}
+
 	if err := client.DeleteSnatEndpoint(); err != nil {
 		return err
 	}
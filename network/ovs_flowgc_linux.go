@@ -0,0 +1,89 @@
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/ovsctl"
+)
+
+// orphanFlowGracePeriod is how long a flow must have been programmed before it can be collected. The flows of an
+// endpoint are programmed before the endpoint is saved, so a younger flow may belong to an endpoint which another
+// CNI process is still adding.
+const orphanFlowGracePeriod = 5 * time.Minute
+
+// ovsBridges returns the OVS bridges of the networks in the state.
+func (nm *networkManager) ovsBridges() []string {
+	var bridges []string
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			if nw.VlanId != 0 && nw.Mode != opModeTransparentVlan && nw.Mode != opModeBridgeVlan && extIf.BridgeName != "" {
+				bridges = append(bridges, extIf.BridgeName)
+				break
+			}
+		}
+	}
+	return bridges
+}
+
+// gcOrphanOVSFlows deletes the flows tagged with the cookie of an endpoint which isn't in the state, e.g. when
+// the plugin crashed between programming the flows of an endpoint and saving it, or after deleting the endpoint.
+func (nm *networkManager) gcOrphanOVSFlows(ovs ovsctl.OvsInterface) error {
+	bridges := nm.ovsBridges()
+	if len(bridges) == 0 {
+		return nil
+	}
+
+	liveCookies := make(map[uint64]struct{})
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				liveCookies[ovsctl.FlowCookie(ep.Id)] = struct{}{}
+			}
+		}
+	}
+
+	var errs error
+	for _, bridgeName := range bridges {
+		// the flows shared by the endpoints of the bridge have cookie 0, a mask of 0 lists all flows
+		flows, err := ovs.ListFlowsByCookie(bridgeName, 0, 0)
+		if err != nil {
+			errs = appendOVSFlowGCError(errs, err)
+			continue
+		}
+
+		orphanCookies := make(map[uint64]struct{})
+		for _, flow := range flows {
+			if flow.Cookie == 0 || flow.Duration < orphanFlowGracePeriod {
+				continue
+			}
+			if _, ok := liveCookies[flow.Cookie]; !ok {
+				orphanCookies[flow.Cookie] = struct{}{}
+			}
+		}
+
+		for cookie := range orphanCookies {
+			log.Printf("[ovs] Deleting orphan flows with cookie %#x from bridge %v", cookie, bridgeName)
+			if err := ovs.DeleteFlowsByCookie(bridgeName, cookie); err != nil {
+				errs = appendOVSFlowGCError(errs, err)
+			}
+		}
+	}
+
+	return errs
+}
+
+func appendOVSFlowGCError(errs, err error) error {
+	if errs == nil {
+		return err
+	}
+	return fmt.Errorf("%w, previous err: [%v]", err, errs)
+}
+
+// gcOrphanFlowsImpl deletes the OVS flows which were left behind by endpoints which are gone.
+func (nm *networkManager) gcOrphanFlowsImpl() {
+	if err := nm.gcOrphanOVSFlows(ovsctl.NewOvsctl()); err != nil {
+		log.Printf("[ovs] Failed to delete orphan flows, err:%v.", err)
+	}
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/ovsctl"
+	"github.com/stretchr/testify/require"
+)
+
+// flowTable is an ovsctl with the flows of the bridges, keyed by bridge name.
+type flowTable struct {
+	ovsctl.MockOvsctl
+	flows map[string][]ovsctl.Flow
+}
+
+func (f *flowTable) ListFlowsByCookie(bridgeName string, _, _ uint64) ([]ovsctl.Flow, error) {
+	return f.flows[bridgeName], nil
+}
+
+func (f *flowTable) DeleteFlowsByCookie(bridgeName string, cookie uint64) error {
+	var flows []ovsctl.Flow
+	for _, flow := range f.flows[bridgeName] {
+		if flow.Cookie != cookie {
+			flows = append(flows, flow)
+		}
+	}
+	f.flows[bridgeName] = flows
+	return nil
+}
+
+func TestGCOrphanOVSFlows(t *testing.T) {
+	extIf := &externalInterface{Name: "eth0", BridgeName: "azure0", Networks: make(map[string]*network)}
+	extIf.Networks["nw"] = &network{
+		Id:        "nw",
+		Mode:      opModeBridge,
+		VlanId:    100,
+		extIf:     extIf,
+		Endpoints: map[string]*endpoint{"live": {Id: "live"}},
+	}
+	nm := &networkManager{ExternalInterfaces: map[string]*externalInterface{"eth0": extIf}}
+
+	old := 2 * orphanFlowGracePeriod
+	ovs := &flowTable{
+		MockOvsctl: ovsctl.NewMockOvsctl(false, "", ""),
+		flows: map[string][]ovsctl.Flow{
+			"azure0": {
+				{Cookie: 0, Duration: old, Match: "priority=20,arp,arp_op=1"},
+				{Cookie: ovsctl.FlowCookie("live"), Duration: old, Match: "priority=20,ip,in_port=3"},
+				{Cookie: ovsctl.FlowCookie("gone"), Duration: old, Match: "priority=20,ip,in_port=4"},
+				{Cookie: ovsctl.FlowCookie("gone"), Duration: old, Match: "priority=10,ip,in_port=4"},
+				{Cookie: ovsctl.FlowCookie("adding"), Duration: time.Second, Match: "priority=20,ip,in_port=5"},
+			},
+		},
+	}
+
+	require.NoError(t, nm.gcOrphanOVSFlows(ovs))
+	require.Equal(t, []ovsctl.Flow{
+		{Cookie: 0, Duration: old, Match: "priority=20,arp,arp_op=1"},
+		{Cookie: ovsctl.FlowCookie("live"), Duration: old, Match: "priority=20,ip,in_port=3"},
+		{Cookie: ovsctl.FlowCookie("adding"), Duration: time.Second, Match: "priority=20,ip,in_port=5"},
+	}, ovs.flows["azure0"], "only the old flows of endpoints which aren't in the state are deleted")
+
+	// bridges without OVS networks aren't listed
+	extIf.Networks["nw"].VlanId = 0
+	ovs.flows = nil
+	require.NoError(t, nm.gcOrphanOVSFlows(ovs))
+}
@@ -19,8 +19,8 @@ import (
 
 var errorOVSNetworkClient = errors.New("OVSNetworkClient Error")
 
-func newErrorOVSNetworkClient(errStr string) error {
-	return fmt.Errorf("%w : %s", errorOVSNetworkClient, errStr)
+func newErrorOVSNetworkClient(err error) error {
+	return fmt.Errorf("%v : %w", errorOVSNetworkClient, err)
 }
 
 type OVSNetworkClient struct {
@@ -133,7 +133,7 @@ func (client *OVSNetworkClient) AddL2Rules(extIf *externalInterface) error {
 	log.Printf("[ovs] Adding DNAT rule for ingress ARP traffic on interface %v.", client.hostInterfaceName)
 	err = client.ovsctlClient.AddArpDnatRule(client.bridgeName, ofport, macHex)
 	if err != nil {
-		return newErrorOVSNetworkClient(err.Error())
+		return newErrorOVSNetworkClient(err)
 	}
 
 	return nil
@@ -148,7 +148,7 @@ func (client *OVSNetworkClient) DeleteL2Rules(extIf *externalInterface) {
 func (client *OVSNetworkClient) SetBridgeMasterToHostInterface() error {
 	err := client.ovsctlClient.AddPortOnOVSBridge(client.hostInterfaceName, client.bridgeName, 0)
 	if err != nil {
-		return newErrorOVSNetworkClient(err.Error())
+		return newErrorOVSNetworkClient(err)
 	}
 	return nil
 }
@@ -76,6 +76,7 @@ func (client *OVSInfraVnetClient) CreateInfraVnetRules(
 	infraIP net.IPNet,
 	hostPrimaryMac string,
 	hostPort string,
+	flowCookie uint64,
 ) error {
 	ovs := ovsctl.NewOvsctl()
 
@@ -86,13 +87,13 @@ func (client *OVSInfraVnetClient) CreateInfraVnetRules(
 	}
 
 	// 0 signifies not to add vlan tag to this traffic
-	if err := ovs.AddIPSnatRule(bridgeName, infraIP.IP, 0, infraContainerPort, hostPrimaryMac, hostPort); err != nil {
+	if err := ovs.AddIPSnatRule(bridgeName, infraIP.IP, 0, infraContainerPort, hostPrimaryMac, hostPort, flowCookie); err != nil {
 		log.Printf("[ovs] AddIpSnatRule failed with error %v", err)
 		return err
 	}
 
 	// 0 signifies not to match traffic based on vlan tag
-	if err := ovs.AddMacDnatRule(bridgeName, hostPort, infraIP.IP, client.containerInfraMac, 0, infraContainerPort); err != nil {
+	if err := ovs.AddMacDnatRule(bridgeName, hostPort, infraIP.IP, client.containerInfraMac, 0, infraContainerPort, flowCookie); err != nil {
 		log.Printf("[ovs] AddMacDnatRule failed with error %v", err)
 		return err
 	}
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"fmt"
+	"net"
+)
+
+// ValidatePodRoutes returns an error if a route requested for the pod cannot be programmed on the endpoint with
+// the IP addresses. The destination must be a prefix other than the default route, which comes from IPAM, and
+// must not be requested twice. The gateway must be of the family of the destination and in the subnet of one of
+// the addresses of the endpoint, so that it is reachable from the pod.
+func ValidatePodRoutes(routes []RouteInfo, ipAddresses []net.IPNet) error {
+	seen := make(map[string]struct{}, len(routes))
+	for i := range routes {
+		route := &routes[i]
+		if route.Dst.IP == nil || route.Dst.Mask == nil {
+			return fmt.Errorf("%w: route has no destination", errPodRouteInvalid)
+		}
+
+		dst := route.Dst.String()
+		if ones, _ := route.Dst.Mask.Size(); ones == 0 {
+			return fmt.Errorf("%w: %s replaces the default route", errPodRouteInvalid, dst)
+		}
+
+		if !route.Dst.IP.Equal(route.Dst.IP.Mask(route.Dst.Mask)) {
+			return fmt.Errorf("%w: %s is not a prefix", errPodRouteInvalid, dst)
+		}
+
+		if _, ok := seen[dst]; ok {
+			return fmt.Errorf("%w: %s is requested more than once", errPodRouteInvalid, dst)
+		}
+		seen[dst] = struct{}{}
+
+		if route.Gw == nil {
+			return fmt.Errorf("%w: %s has no gateway", errPodRouteInvalid, dst)
+		}
+
+		if (route.Gw.To4() == nil) != (route.Dst.IP.To4() == nil) {
+			return fmt.Errorf("%w: gateway %s of %s is of another family", errPodRouteInvalid, route.Gw, dst)
+		}
+
+		if !gatewayReachable(route.Gw, ipAddresses) {
+			return fmt.Errorf("%w: gateway %s of %s is not in the subnet of the pod", errPodRouteInvalid, route.Gw, dst)
+		}
+	}
+
+	return nil
+}
+
+// gatewayReachable returns true if the gateway is in the subnet of one of the addresses and is not one of them.
+func gatewayReachable(gw net.IP, ipAddresses []net.IPNet) bool {
+	reachable := false
+	for _, ipAddr := range ipAddresses {
+		if ipAddr.IP.Equal(gw) {
+			return false
+		}
+
+		subnet := net.IPNet{IP: ipAddr.IP.Mask(ipAddr.Mask), Mask: ipAddr.Mask}
+		if subnet.Contains(gw) {
+			reachable = true
+		}
+	}
+
+	return reachable
+}
@@ -0,0 +1,50 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePodRoutes(t *testing.T) {
+	podIP := net.IPNet{IP: net.ParseIP("10.1.0.10").To4(), Mask: net.CIDRMask(24, 32)}
+	podIPv6 := net.IPNet{IP: net.ParseIP("fd00::10"), Mask: net.CIDRMask(64, 128)}
+	addresses := []net.IPNet{podIP, podIPv6}
+
+	route := func(dst, gw string) RouteInfo {
+		_, dstNet, err := net.ParseCIDR(dst)
+		require.NoError(t, err)
+		return RouteInfo{Dst: *dstNet, Gw: net.ParseIP(gw)}
+	}
+
+	require.NoError(t, ValidatePodRoutes(nil, addresses))
+	require.NoError(t, ValidatePodRoutes([]RouteInfo{
+		route("192.168.0.0/16", "10.1.0.1"),
+		route("172.16.0.0/12", "10.1.0.2"),
+		route("fd01::/48", "fd00::1"),
+	}, addresses))
+
+	tests := []struct {
+		name   string
+		routes []RouteInfo
+	}{
+		{name: "default route", routes: []RouteInfo{route("0.0.0.0/0", "10.1.0.1")}},
+		{name: "not a prefix", routes: []RouteInfo{{Dst: net.IPNet{IP: net.ParseIP("192.168.1.1").To4(), Mask: net.CIDRMask(16, 32)}, Gw: net.ParseIP("10.1.0.1")}}},
+		{name: "duplicate", routes: []RouteInfo{route("192.168.0.0/16", "10.1.0.1"), route("192.168.0.0/16", "10.1.0.2")}},
+		{name: "no gateway", routes: []RouteInfo{{Dst: route("192.168.0.0/16", "").Dst}}},
+		{name: "family mismatch", routes: []RouteInfo{route("192.168.0.0/16", "fd00::1")}},
+		{name: "gateway outside the subnet", routes: []RouteInfo{route("192.168.0.0/16", "10.2.0.1")}},
+		{name: "gateway is the pod", routes: []RouteInfo{route("192.168.0.0/16", "10.1.0.10")}},
+		{name: "no destination", routes: []RouteInfo{{Gw: net.ParseIP("10.1.0.1")}}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePodRoutes(tt.routes, addresses)
+			require.Error(t, err)
+			require.True(t, IsPodRouteInvalidError(err), err.Error())
+		})
+	}
+}
@@ -26,6 +26,7 @@ const (
 type KVPairRoutePolicy struct {
 	Type              CNIPolicyType   `json:"Type"`
 	DestinationPrefix json.RawMessage `json:"DestinationPrefix"`
+	NextHop           json.RawMessage `json:"NextHop"`
 	NeedEncap         json.RawMessage `json:"NeedEncap"`
 }
 
@@ -37,6 +38,7 @@ type KVPairOutBoundNAT struct {
 type KVPairRoute struct {
 	Type              CNIPolicyType `json:"Type"`
 	DestinationPrefix string        `json:"DestinationPrefix"`
+	NextHop           string        `json:"NextHop,omitempty"`
 	NeedEncap         bool          `json:"NeedEncap"`
 }
 
@@ -287,6 +289,21 @@ func SerializeHcnSubnetVlanPolicy(vlanID uint32) ([]byte, error) {
 	return vlanSubnetPolicyBytes, nil
 }
 
+// SerializeHcnSubnetVsidPolicy serializes subnet policy for the VSID of an overlay network to json.
+func SerializeHcnSubnetVsidPolicy(vsid uint32) ([]byte, error) {
+	vsidPolicySettingBytes, err := json.Marshal(&hcn.VsidPolicySetting{IsolationId: vsid})
+	if err != nil {
+		return nil, err
+	}
+
+	vsidSubnetPolicy := &hcn.SubnetPolicy{
+		Type:     hcn.VSID,
+		Settings: vsidPolicySettingBytes,
+	}
+
+	return json.Marshal(vsidSubnetPolicy)
+}
+
 // GetHcnNetAdapterPolicy returns network adapter name policy.
 func GetHcnNetAdapterPolicy(networkAdapterName string) (hcn.NetworkPolicy, error) {
 	networkAdapterNamePolicy := hcn.NetworkPolicy{
@@ -359,19 +376,27 @@ func GetHcnRoutePolicy(policy Policy) (hcn.EndpointPolicy, error) {
 	}
 
 	if data.Type == RoutePolicy {
-		var destinationPrefix string
+		var destinationPrefix, nextHop string
 		var needEncap bool
 
 		if err := json.Unmarshal(data.DestinationPrefix, &destinationPrefix); err != nil {
 			return routePolicy, err
 		}
 
+		// The next hop is only set on the routes requested for the pod, other routes go to the VFP gateway.
+		if len(data.NextHop) > 0 {
+			if err := json.Unmarshal(data.NextHop, &nextHop); err != nil {
+				return routePolicy, err
+			}
+		}
+
 		if err := json.Unmarshal(data.NeedEncap, &needEncap); err != nil {
 			return routePolicy, err
 		}
 
 		sdnRoutePolicySetting := &hcn.SDNRoutePolicySetting{
 			DestinationPrefix: destinationPrefix,
+			NextHop:           nextHop,
 			NeedEncap:         needEncap,
 		}
 
@@ -47,4 +47,28 @@ var _ = Describe("Windows Policies", func() {
 			Expect(string(generatedPolicy.Settings)).To(Equal(expected_policy))
 		})
 	})
+
+	Describe("Test GetHcnRoutePolicy", func() {
+		It("Should marshall the policy without a next hop", func() {
+			policy := Policy{
+				Type: EndpointPolicy,
+				Data: []byte(`{"Type": "ROUTE", "DestinationPrefix": "10.0.0.0/8", "NeedEncap": true}`),
+			}
+
+			generatedPolicy, err := GetHcnRoutePolicy(policy)
+			Expect(err).To(BeNil())
+			Expect(string(generatedPolicy.Settings)).To(Equal(`{"DestinationPrefix":"10.0.0.0/8","NeedEncap":true}`))
+		})
+
+		It("Should marshall the next hop of a pod route", func() {
+			policy := Policy{
+				Type: EndpointPolicy,
+				Data: []byte(`{"Type": "ROUTE", "DestinationPrefix": "192.168.0.0/16", "NextHop": "10.1.0.1", "NeedEncap": false}`),
+			}
+
+			generatedPolicy, err := GetHcnRoutePolicy(policy)
+			Expect(err).To(BeNil())
+			Expect(string(generatedPolicy.Settings)).To(Equal(`{"DestinationPrefix":"192.168.0.0/16","NextHop":"10.1.0.1"}`))
+		})
+	})
 })
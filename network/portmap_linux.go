@@ -0,0 +1,145 @@
+package network
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// hostPortChain holds the DNAT rules of the port mappings of all endpoints.
+	hostPortChain = "AZURECNI-HOSTPORT"
+	// hostPortMasqChain holds the rules masquerading the hairpin traffic of pods to their own mapped ports.
+	hostPortMasqChain = "AZURECNI-HOSTPORT-MASQ"
+	// portMapRuleOwner tags the iptables rules programmed for port mappings.
+	portMapRuleOwner = "azure-cni-portmap"
+)
+
+// hostPortRules returns the rules mapping the host ports to the addresses of the endpoint. Mappings with a host IP
+// only apply to endpoint addresses of the same family.
+func hostPortRules(ipAddresses []net.IPNet, mappings []PortMapping) []*iptables.Rule {
+	var rules []*iptables.Rule
+	for _, ipAddr := range ipAddresses {
+		version := iptables.V4
+		if ipAddr.IP.To4() == nil {
+			version = iptables.V6
+		}
+
+		for _, mapping := range mappings {
+			protocol := strings.ToLower(strings.TrimSpace(mapping.Protocol))
+			if protocol == "" {
+				protocol = iptables.TCP
+			}
+
+			if protocol != iptables.TCP && protocol != iptables.UDP && protocol != "sctp" {
+				log.Printf("[net] Ignoring port mapping %+v with unsupported protocol", mapping)
+				continue
+			}
+
+			dnatMatches := []iptables.Match{iptables.MatchDestinationPort(protocol, mapping.HostPort)}
+			if mapping.HostIP != "" {
+				hostIP := net.ParseIP(mapping.HostIP)
+				if hostIP == nil || (hostIP.To4() == nil) != (ipAddr.IP.To4() == nil) {
+					continue
+				}
+
+				if !hostIP.IsUnspecified() {
+					dnatMatches = append([]iptables.Match{iptables.MatchDestination(hostIP.String())}, dnatMatches...)
+				}
+			}
+
+			rules = append(rules,
+				&iptables.Rule{
+					Version: version,
+					Table:   iptables.Nat,
+					Chain:   hostPortChain,
+					Matches: dnatMatches,
+					Target: iptables.Jump("DNAT", "--to-destination",
+						net.JoinHostPort(ipAddr.IP.String(), strconv.Itoa(mapping.ContainerPort))),
+				},
+				&iptables.Rule{
+					Version: version,
+					Table:   iptables.Nat,
+					Chain:   hostPortMasqChain,
+					Matches: []iptables.Match{
+						iptables.MatchSource(ipAddr.IP.String()),
+						iptables.MatchDestination(ipAddr.IP.String()),
+						iptables.MatchDestinationPort(protocol, mapping.ContainerPort),
+					},
+					Target: iptables.Jump(iptables.Masquerade),
+				},
+			)
+		}
+	}
+
+	return rules
+}
+
+// hostPortJumpRules returns the rules sending the traffic to local addresses through the port mapping chains.
+func hostPortJumpRules(version string) []*iptables.Rule {
+	localDst := iptables.Match{Module: "addrtype", Args: []string{"--dst-type", "LOCAL"}}
+	return []*iptables.Rule{
+		{Version: version, Table: iptables.Nat, Chain: iptables.Prerouting, Matches: []iptables.Match{localDst}, Target: iptables.Jump(hostPortChain)},
+		{Version: version, Table: iptables.Nat, Chain: iptables.Output, Matches: []iptables.Match{localDst}, Target: iptables.Jump(hostPortChain)},
+		{Version: version, Table: iptables.Nat, Chain: iptables.Postrouting, Target: iptables.Jump(hostPortMasqChain)},
+	}
+}
+
+// addPortMappings programs the port mappings of the endpoint. It is done in the host namespace, so that the
+// mappings don't depend on the hairpin and SNAT setup of the network mode. The writers create the chains under
+// iptables.RestoreLockPath, so that the ADDs of other pods running in parallel don't flush the mappings.
+func addPortMappings(ipAddresses []net.IPNet, mappings []PortMapping) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	registry := newPortMapRegistry()
+	writers := make(map[string]*iptables.Writer)
+	for _, rule := range hostPortRules(ipAddresses, mappings) {
+		w, ok := writers[rule.Version]
+		if !ok {
+			w = iptables.NewWriter(rule.Version)
+			w.EnsureChain(iptables.Nat, hostPortChain)
+			w.EnsureChain(iptables.Nat, hostPortMasqChain)
+			for _, jump := range hostPortJumpRules(rule.Version) {
+				if err := w.EnsureRule(registry.Tag(jump), iptables.Append); err != nil {
+					return err
+				}
+			}
+			writers[rule.Version] = w
+		}
+
+		if err := w.EnsureRule(registry.Tag(rule), iptables.Append); err != nil {
+			return err
+		}
+	}
+
+	for version, w := range writers {
+		log.Printf("[net] Adding port mappings %+v for %v.", mappings, ipAddresses)
+		if err := w.Flush(); err != nil {
+			return errors.Wrapf(err, "failed to program port mappings for IPv%s", version)
+		}
+	}
+
+	return nil
+}
+
+// deletePortMappings removes the port mappings of the endpoint. The chains and the jumps to them are kept for
+// the other endpoints.
+func deletePortMappings(ipAddresses []net.IPNet, mappings []PortMapping) {
+	registry := newPortMapRegistry()
+	for _, rule := range hostPortRules(ipAddresses, mappings) {
+		if err := registry.DeleteRule(rule); err != nil {
+			log.Errorf("[net] Failed to delete port mapping rule %s: %v", rule, err)
+		}
+	}
+}
+
+// newPortMapRegistry returns the registry tagging the iptables rules of port mappings.
+func newPortMapRegistry() *iptables.Registry {
+	return iptables.NewRegistry(portMapRuleOwner, iptables.ReleaseVersion, hostPortChain)
+}
@@ -0,0 +1,44 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostPortRules(t *testing.T) {
+	ipAddresses := []net.IPNet{
+		{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)},
+		{IP: net.ParseIP("fd00::4"), Mask: net.CIDRMask(64, 128)},
+	}
+	mappings := []PortMapping{
+		{HostPort: 8080, ContainerPort: 80, Protocol: "TCP"},
+		{HostPort: 5353, ContainerPort: 53, Protocol: "udp", HostIP: "192.168.0.10"},
+		{HostPort: 9090, ContainerPort: 90, Protocol: "icmp"},
+	}
+
+	rules := hostPortRules(ipAddresses, mappings)
+
+	var rendered []string
+	for _, rule := range rules {
+		rendered = append(rendered, "v"+rule.Version+" "+rule.String())
+	}
+
+	require.Equal(t, []string{
+		"v4 -t nat -A AZURECNI-HOSTPORT -p tcp --dport 8080 -j DNAT --to-destination 10.0.0.4:80",
+		"v4 -t nat -A AZURECNI-HOSTPORT-MASQ -s 10.0.0.4 -d 10.0.0.4 -p tcp --dport 80 -j MASQUERADE",
+		"v4 -t nat -A AZURECNI-HOSTPORT -d 192.168.0.10 -p udp --dport 5353 -j DNAT --to-destination 10.0.0.4:53",
+		"v4 -t nat -A AZURECNI-HOSTPORT-MASQ -s 10.0.0.4 -d 10.0.0.4 -p udp --dport 53 -j MASQUERADE",
+		"v6 -t nat -A AZURECNI-HOSTPORT -p tcp --dport 8080 -j DNAT --to-destination [fd00::4]:80",
+		"v6 -t nat -A AZURECNI-HOSTPORT-MASQ -s fd00::4 -d fd00::4 -p tcp --dport 80 -j MASQUERADE",
+	}, rendered, "the IPv4 host IP only maps the IPv4 address and unsupported protocols are skipped")
+
+	for _, rule := range append(rules, hostPortJumpRules(iptables.V4)...) {
+		require.Equal(t, iptables.Nat, rule.Table)
+	}
+
+	require.Empty(t, hostPortRules(ipAddresses, nil))
+	require.NoError(t, addPortMappings(ipAddresses, nil), "nothing is programmed without port mappings")
+}
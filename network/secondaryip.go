@@ -0,0 +1,92 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+)
+
+// SecondaryIPConfig is the secondary IP configuration of a delegated NIC, e.g. the customer NIC of a network
+// container in multitenancy. Each secondary IP address is assigned to the NIC, and the traffic sourced from it
+// is routed out of the NIC through a policy rule to its own routing table.
+type SecondaryIPConfig struct {
+	IfName      string
+	IPAddresses []net.IPNet
+	// Gateway is the next hop of the default route in the routing table of the NIC.
+	Gateway net.IP
+	// Table is the routing table of the NIC, which must not be shared with another NIC.
+	Table int
+	// RulePriority is the priority of the policy rules of the secondary IP addresses.
+	RulePriority int
+}
+
+func (cfg *SecondaryIPConfig) validate() error {
+	if cfg.IfName == "" {
+		return fmt.Errorf("%w: no interface", errSecondaryIPInvalid)
+	}
+	if cfg.Gateway == nil {
+		return fmt.Errorf("%w: no gateway for %s", errSecondaryIPInvalid, cfg.IfName)
+	}
+	// the main and local tables are shared with the host interfaces
+	if cfg.Table <= 0 || cfg.Table == 254 || cfg.Table == 255 {
+		return fmt.Errorf("%w: table %d of %s is reserved", errSecondaryIPInvalid, cfg.Table, cfg.IfName)
+	}
+	gatewayIsV4 := cfg.Gateway.To4() != nil
+	for _, ipAddr := range cfg.IPAddresses {
+		if (ipAddr.IP.To4() != nil) != gatewayIsV4 {
+			return fmt.Errorf("%w: address %s and gateway %s of %s are not in the same family",
+				errSecondaryIPInvalid, ipAddr.String(), cfg.Gateway, cfg.IfName)
+		}
+	}
+	return nil
+}
+
+// SecondaryIPProgrammer programs the secondary IP configuration of delegated NICs on the host.
+// Programming is idempotent, so the configuration of every NIC can be reapplied when the agent restarts.
+type SecondaryIPProgrammer struct {
+	netlink netlink.NetlinkInterface
+	netio   netio.NetIOInterface
+}
+
+// NewSecondaryIPProgrammer returns a SecondaryIPProgrammer.
+func NewSecondaryIPProgrammer(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface) *SecondaryIPProgrammer {
+	return &SecondaryIPProgrammer{
+		netlink: nl,
+		netio:   netioshim,
+	}
+}
+
+// Reapply verifies the secondary IP configuration of each NIC, e.g. when the agent restarts, and programs it
+// again if it diverged. It returns the interfaces which were reprogrammed.
+func (p *SecondaryIPProgrammer) Reapply(configs []*SecondaryIPConfig) ([]string, error) {
+	var (
+		reapplied []string
+		errs      error
+	)
+	for _, cfg := range configs {
+		err := p.Verify(cfg)
+		if err == nil {
+			continue
+		}
+		if !IsSecondaryIPDivergedError(err) {
+			errs = appendSecondaryIPError(errs, err)
+			continue
+		}
+
+		if err := p.Apply(cfg); err != nil {
+			errs = appendSecondaryIPError(errs, err)
+			continue
+		}
+		reapplied = append(reapplied, cfg.IfName)
+	}
+	return reapplied, errs
+}
+
+func appendSecondaryIPError(errs, err error) error {
+	if errs == nil {
+		return err
+	}
+	return fmt.Errorf("%w, previous err: [%v]", err, errs)
+}
@@ -0,0 +1,226 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+	"golang.org/x/sys/unix"
+)
+
+// secondaryIPRoute returns the default route of the routing table of the NIC.
+func secondaryIPRoute(cfg *SecondaryIPConfig, linkIndex int) *netlink.Route {
+	family, bits := unix.AF_INET, 32
+	if cfg.Gateway.To4() == nil {
+		family, bits = unix.AF_INET6, 128
+	}
+	return &netlink.Route{
+		Family:    family,
+		Dst:       &net.IPNet{IP: net.IPv6zero[:bits/8], Mask: net.CIDRMask(0, bits)},
+		Gw:        cfg.Gateway,
+		Table:     cfg.Table,
+		LinkIndex: linkIndex,
+	}
+}
+
+// secondaryIPRules returns the policy rules which route the traffic from the secondary IP addresses to the
+// routing table of the NIC.
+func secondaryIPRules(cfg *SecondaryIPConfig) []*netlink.Rule {
+	rules := make([]*netlink.Rule, 0, len(cfg.IPAddresses))
+	for _, ipAddr := range cfg.IPAddresses {
+		family, bits := unix.AF_INET, 32
+		ip := ipAddr.IP.To4()
+		if ip == nil {
+			family, bits, ip = unix.AF_INET6, 128, ipAddr.IP
+		}
+		rules = append(rules, &netlink.Rule{
+			Family:   family,
+			Src:      &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)},
+			Table:    cfg.Table,
+			Priority: cfg.RulePriority,
+		})
+	}
+	return rules
+}
+
+// hasDefaultRoute returns true if one of the routes is the default route via the gateway over the link.
+func hasDefaultRoute(routes []*netlink.Route, route *netlink.Route) bool {
+	for _, r := range routes {
+		// the kernel reports the default route without a destination
+		isDefault := r.Dst == nil
+		if r.Dst != nil {
+			ones, _ := r.Dst.Mask.Size()
+			isDefault = ones == 0
+		}
+		if isDefault && r.Gw.Equal(route.Gw) && r.LinkIndex == route.LinkIndex {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRule(rules []*netlink.Rule, rule *netlink.Rule) bool {
+	for _, r := range rules {
+		if r.Src != nil && r.Src.String() == rule.Src.String() && r.Table == rule.Table && r.Priority == rule.Priority {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply programs the secondary IP addresses of the NIC, the default route of its routing table, and the policy
+// rules of the addresses. What is already programmed is left as is.
+func (p *SecondaryIPProgrammer) Apply(cfg *SecondaryIPConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	iface, err := p.netio.GetNetworkInterfaceByName(cfg.IfName)
+	if err != nil {
+		return fmt.Errorf("failed to find delegated NIC %s: %w", cfg.IfName, err)
+	}
+
+	addrs, err := p.netlink.GetIPAddresses(cfg.IfName)
+	if err != nil {
+		return fmt.Errorf("failed to list addresses of %s: %w", cfg.IfName, err)
+	}
+	var added []net.IPNet
+	for i := range cfg.IPAddresses {
+		ipAddr := cfg.IPAddresses[i]
+		if hasAddress(addrs, ipAddr) {
+			continue
+		}
+		log.Printf("[net] Adding secondary IP address %v to %s.", ipAddr.String(), cfg.IfName)
+		if err := p.netlink.AddIPAddress(cfg.IfName, ipAddr.IP, &ipAddr); err != nil && !errors.Is(err, netlink.ErrExists) {
+			return fmt.Errorf("failed to add secondary IP address %s to %s: %w", ipAddr.String(), cfg.IfName, err)
+		}
+		added = append(added, ipAddr)
+	}
+
+	// a secondary IP address added to the NIC may have moved from another node, whose MAC the neighbors still have.
+	if len(added) > 0 {
+		if err := networkutils.AnnounceIPAddresses(cfg.IfName, added); err != nil {
+			log.Printf("[net] Failed to announce the secondary IP addresses of %s: %v", cfg.IfName, err)
+		}
+	}
+
+	// the route is added after the addresses, since the kernel rejects a gateway which isn't reachable yet
+	route := secondaryIPRoute(cfg, iface.Index)
+	routes, err := p.netlink.GetIPRoutesInTable(route.Family, cfg.Table)
+	if err != nil {
+		return fmt.Errorf("failed to list routes in table %d: %w", cfg.Table, err)
+	}
+	if !hasDefaultRoute(routes, route) {
+		log.Printf("[net] Adding default route via %v dev %s to table %d.", cfg.Gateway, cfg.IfName, cfg.Table)
+		if err := p.netlink.AddIPRoute(route); err != nil && !errors.Is(err, netlink.ErrExists) {
+			return fmt.Errorf("failed to add default route to table %d: %w", cfg.Table, err)
+		}
+	}
+
+	rules, err := p.netlink.GetIPRules(route.Family)
+	if err != nil {
+		return fmt.Errorf("failed to list policy rules: %w", err)
+	}
+	for _, rule := range secondaryIPRules(cfg) {
+		if hasRule(rules, rule) {
+			continue
+		}
+		log.Printf("[net] Adding rule from %v lookup %d priority %d.", rule.Src, rule.Table, rule.Priority)
+		if err := p.netlink.AddIPRule(rule); err != nil && !errors.Is(err, netlink.ErrExists) {
+			return fmt.Errorf("failed to add rule from %v: %w", rule.Src, err)
+		}
+	}
+
+	return nil
+}
+
+// Remove deletes the policy rules, the default route and the secondary IP addresses of the NIC. What is already
+// gone, including the NIC itself, is skipped.
+func (p *SecondaryIPProgrammer) Remove(cfg *SecondaryIPConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	// the rules don't depend on the NIC, so they are removed even if the NIC was detached
+	for _, rule := range secondaryIPRules(cfg) {
+		log.Printf("[net] Deleting rule from %v lookup %d priority %d.", rule.Src, rule.Table, rule.Priority)
+		if err := p.netlink.DeleteIPRule(rule); err != nil && !errors.Is(err, netlink.ErrNotFound) {
+			return fmt.Errorf("failed to delete rule from %v: %w", rule.Src, err)
+		}
+	}
+
+	iface, err := p.netio.GetNetworkInterfaceByName(cfg.IfName)
+	if err != nil {
+		log.Printf("[net] Delegated NIC %s is gone, skipping its routes and addresses: %v.", cfg.IfName, err)
+		return nil
+	}
+
+	route := secondaryIPRoute(cfg, iface.Index)
+	if err := p.netlink.DeleteIPRoute(route); err != nil && !errors.Is(err, netlink.ErrNotFound) {
+		return fmt.Errorf("failed to delete default route from table %d: %w", cfg.Table, err)
+	}
+
+	addrs, err := p.netlink.GetIPAddresses(cfg.IfName)
+	if err != nil {
+		return fmt.Errorf("failed to list addresses of %s: %w", cfg.IfName, err)
+	}
+	for i := range cfg.IPAddresses {
+		ipAddr := cfg.IPAddresses[i]
+		if !hasAddress(addrs, ipAddr) {
+			continue
+		}
+		log.Printf("[net] Deleting secondary IP address %v from %s.", ipAddr.String(), cfg.IfName)
+		if err := p.netlink.DeleteIPAddress(cfg.IfName, ipAddr.IP, &ipAddr); err != nil {
+			return fmt.Errorf("failed to delete secondary IP address %s from %s: %w", ipAddr.String(), cfg.IfName, err)
+		}
+	}
+
+	return nil
+}
+
+// Verify returns an error wrapping errSecondaryIPDiverged if an address, the route or a rule of the secondary IP
+// configuration of the NIC is missing.
+func (p *SecondaryIPProgrammer) Verify(cfg *SecondaryIPConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	iface, err := p.netio.GetNetworkInterfaceByName(cfg.IfName)
+	if err != nil {
+		return fmt.Errorf("failed to find delegated NIC %s: %w", cfg.IfName, err)
+	}
+
+	addrs, err := p.netlink.GetIPAddresses(cfg.IfName)
+	if err != nil {
+		return fmt.Errorf("failed to list addresses of %s: %w", cfg.IfName, err)
+	}
+	for _, ipAddr := range cfg.IPAddresses {
+		if !hasAddress(addrs, ipAddr) {
+			return fmt.Errorf("%w: address %v is not assigned to %s", errSecondaryIPDiverged, ipAddr.String(), cfg.IfName)
+		}
+	}
+
+	route := secondaryIPRoute(cfg, iface.Index)
+	routes, err := p.netlink.GetIPRoutesInTable(route.Family, cfg.Table)
+	if err != nil {
+		return fmt.Errorf("failed to list routes in table %d: %w", cfg.Table, err)
+	}
+	if !hasDefaultRoute(routes, route) {
+		return fmt.Errorf("%w: no default route via %v dev %s in table %d", errSecondaryIPDiverged, cfg.Gateway, cfg.IfName, cfg.Table)
+	}
+
+	rules, err := p.netlink.GetIPRules(route.Family)
+	if err != nil {
+		return fmt.Errorf("failed to list policy rules: %w", err)
+	}
+	for _, rule := range secondaryIPRules(cfg) {
+		if !hasRule(rules, rule) {
+			return fmt.Errorf("%w: no rule from %v lookup %d", errSecondaryIPDiverged, rule.Src, rule.Table)
+		}
+	}
+
+	return nil
+}
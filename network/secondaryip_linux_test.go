@@ -0,0 +1,188 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/stretchr/testify/require"
+)
+
+// secondaryIPNetlink keeps the addresses, routes and rules programmed through it.
+type secondaryIPNetlink struct {
+	*netlink.MockNetlink
+	addrs  []*netlink.Address
+	routes []*netlink.Route
+	rules  []*netlink.Rule
+	calls  int
+}
+
+func (nl *secondaryIPNetlink) AddIPAddress(_ string, ip net.IP, ipNet *net.IPNet) error {
+	nl.calls++
+	nl.addrs = append(nl.addrs, &netlink.Address{IPNet: &net.IPNet{IP: ip, Mask: ipNet.Mask}})
+	return nil
+}
+
+func (nl *secondaryIPNetlink) DeleteIPAddress(_ string, ip net.IP, _ *net.IPNet) error {
+	nl.calls++
+	for i, addr := range nl.addrs {
+		if addr.IPNet.IP.Equal(ip) {
+			nl.addrs = append(nl.addrs[:i], nl.addrs[i+1:]...)
+			return nil
+		}
+	}
+	return netlink.ErrNotFound
+}
+
+func (nl *secondaryIPNetlink) GetIPAddresses(string) ([]*netlink.Address, error) {
+	return nl.addrs, nil
+}
+
+func (nl *secondaryIPNetlink) AddIPRoute(route *netlink.Route) error {
+	nl.calls++
+	// the kernel reports the default route without a destination
+	nl.routes = append(nl.routes, &netlink.Route{Gw: route.Gw, Table: route.Table, LinkIndex: route.LinkIndex})
+	return nil
+}
+
+func (nl *secondaryIPNetlink) DeleteIPRoute(route *netlink.Route) error {
+	nl.calls++
+	for i, r := range nl.routes {
+		if r.Table == route.Table && r.Gw.Equal(route.Gw) {
+			nl.routes = append(nl.routes[:i], nl.routes[i+1:]...)
+			return nil
+		}
+	}
+	return netlink.ErrNotFound
+}
+
+func (nl *secondaryIPNetlink) GetIPRoutesInTable(_, table int) ([]*netlink.Route, error) {
+	var routes []*netlink.Route
+	for _, r := range nl.routes {
+		if r.Table == table {
+			routes = append(routes, r)
+		}
+	}
+	return routes, nil
+}
+
+func (nl *secondaryIPNetlink) AddIPRule(rule *netlink.Rule) error {
+	nl.calls++
+	nl.rules = append(nl.rules, rule)
+	return nil
+}
+
+func (nl *secondaryIPNetlink) DeleteIPRule(rule *netlink.Rule) error {
+	nl.calls++
+	for i, r := range nl.rules {
+		if r.Src.String() == rule.Src.String() && r.Table == rule.Table {
+			nl.rules = append(nl.rules[:i], nl.rules[i+1:]...)
+			return nil
+		}
+	}
+	return netlink.ErrNotFound
+}
+
+func (nl *secondaryIPNetlink) GetIPRules(int) ([]*netlink.Rule, error) {
+	return nl.rules, nil
+}
+
+func TestSecondaryIPProgrammer(t *testing.T) {
+	mac, _ := net.ParseMAC("00:0d:3a:00:00:02")
+	nics := hostNICs{"eth2": mac}
+	nl := &secondaryIPNetlink{MockNetlink: netlink.NewMockNetlink(false, "")}
+	p := NewSecondaryIPProgrammer(nl, nics)
+
+	cfg := &SecondaryIPConfig{
+		IfName: "eth2",
+		IPAddresses: []net.IPNet{
+			{IP: net.ParseIP("10.1.0.5"), Mask: net.CIDRMask(24, 32)},
+			{IP: net.ParseIP("10.1.0.6"), Mask: net.CIDRMask(24, 32)},
+		},
+		Gateway:      net.ParseIP("10.1.0.1"),
+		Table:        100,
+		RulePriority: 1000,
+	}
+
+	require.True(t, IsSecondaryIPDivergedError(p.Verify(cfg)))
+
+	require.NoError(t, p.Apply(cfg))
+	require.Len(t, nl.addrs, 2)
+	require.Len(t, nl.routes, 1)
+	require.Len(t, nl.rules, 2)
+	require.Equal(t, "10.1.0.5/32", nl.rules[0].Src.String())
+	require.NoError(t, p.Verify(cfg))
+
+	// applying again doesn't program anything
+	calls := nl.calls
+	require.NoError(t, p.Apply(cfg))
+	require.Equal(t, calls, nl.calls)
+
+	// a rule is flushed while the agent is down
+	nl.rules = nl.rules[:1]
+	reapplied, err := p.Reapply([]*SecondaryIPConfig{cfg})
+	require.NoError(t, err)
+	require.Equal(t, []string{"eth2"}, reapplied)
+	require.Len(t, nl.rules, 2)
+
+	reapplied, err = p.Reapply([]*SecondaryIPConfig{cfg})
+	require.NoError(t, err)
+	require.Empty(t, reapplied)
+
+	require.NoError(t, p.Remove(cfg))
+	require.Empty(t, nl.addrs)
+	require.Empty(t, nl.routes)
+	require.Empty(t, nl.rules)
+	require.NoError(t, p.Remove(cfg), "removing again is a no-op")
+
+	// the rules are removed even if the NIC was detached
+	require.NoError(t, p.Apply(cfg))
+	delete(nics, "eth2")
+	require.NoError(t, p.Remove(cfg))
+	require.Empty(t, nl.rules)
+}
+
+func TestSecondaryIPConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SecondaryIPConfig
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			cfg: SecondaryIPConfig{
+				IfName:      "eth2",
+				IPAddresses: []net.IPNet{{IP: net.ParseIP("10.1.0.5"), Mask: net.CIDRMask(24, 32)}},
+				Gateway:     net.ParseIP("10.1.0.1"),
+				Table:       100,
+			},
+		},
+		{
+			name:    "main table",
+			cfg:     SecondaryIPConfig{IfName: "eth2", Gateway: net.ParseIP("10.1.0.1"), Table: 254},
+			wantErr: true,
+		},
+		{
+			name: "mixed families",
+			cfg: SecondaryIPConfig{
+				IfName:      "eth2",
+				IPAddresses: []net.IPNet{{IP: net.ParseIP("fd00::5"), Mask: net.CIDRMask(64, 128)}},
+				Gateway:     net.ParseIP("10.1.0.1"),
+				Table:       100,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if tt.wantErr {
+				require.ErrorIs(t, err, errSecondaryIPInvalid)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package network
+
+import "errors"
+
+var errSecondaryIPNotSupported = errors.New("secondary IP programming of delegated NICs is not supported on windows")
+
+func (p *SecondaryIPProgrammer) Apply(*SecondaryIPConfig) error {
+	return errSecondaryIPNotSupported
+}
+
+func (p *SecondaryIPProgrammer) Remove(*SecondaryIPConfig) error {
+	return errSecondaryIPNotSupported
+}
+
+func (p *SecondaryIPProgrammer) Verify(*SecondaryIPConfig) error {
+	return errSecondaryIPNotSupported
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"strings"
 
+	"github.com/Azure/azure-container-networking/conntrack"
 	"github.com/Azure/azure-container-networking/ebtables"
 	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
@@ -25,6 +26,18 @@ const (
 	vlanDropAddRule     = "ebtables -t nat -A PREROUTING -p 802_1Q -j DROP"
 	vlanDropMatch       = "-p 802_1Q -j DROP"
 	l2PreroutingEntries = "ebtables -t nat -L PREROUTING"
+	// cniRuleOwner tags the iptables rules programmed by the snat client.
+	cniRuleOwner = "azure-cni"
+	// cniChainPrefix is the prefix of the iptables chains owned by the snat client.
+	cniChainPrefix = "AZURECNI"
+	// SnatBridgeULAPrefix is the unique local prefix of the IPv6 addresses on the snat bridge, the IPv6
+	// counterpart of the link local IPv4 range of the bridge. The local IPv6 address of a NC embeds its
+	// local IPv4 address in the prefix.
+	SnatBridgeULAPrefix = "fd00:a9fe::/64"
+	// SnatBridgeIPv6 is the IPv6 address of the snat bridge, the IPv6 gateway of the NCs.
+	SnatBridgeIPv6      = "fd00:a9fe::1/64"
+	icmpv6Protocol      = "ipv6-icmp"
+	routerAdvertisement = "router-advertisement"
 )
 
 var errorSnatClient = errors.New("SnatClient Error")
@@ -33,6 +46,10 @@ func newErrorSnatClient(errStr string) error {
 	return fmt.Errorf("%w : %s", errorSnatClient, errStr)
 }
 
+type conntrackClient interface {
+	Flush(filter conntrack.Filter) (uint, error)
+}
+
 type Client struct {
 	hostSnatVethName       string
 	hostPrimaryMac         string
@@ -41,6 +58,12 @@ type Client struct {
 	SnatBridgeIP           string
 	SkipAddressesFromBlock []string
 	netlink                netlink.NetlinkInterface
+	conntrack              conntrackClient
+
+	// Family holds the IP families the bridge is set up for, IPv4 unless the endpoint has IPv6
+	// addresses. IPv6 adds the unique local addresses to the bridge and the NC, and programs the
+	// NAT and filter rules with ip6tables as well.
+	Family iptables.Family
 
 	plClient platform.ExecClient
 }
@@ -62,7 +85,9 @@ func NewSnatClient(hostIfName string,
 		localIP:               localIP,
 		SnatBridgeIP:          snatBridgeIP,
 		hostPrimaryMac:        hostPrimaryMac,
+		Family:                iptables.FamilyV4,
 		netlink:               nl,
+		conntrack:             conntrack.New(),
 
 		plClient: plClient,
 	}
@@ -87,6 +112,19 @@ func (client *Client) CreateSnatEndpoint() error {
 		return err
 	}
 
+	if client.ipv6Enabled() {
+		if err := client.addMasqueradeRule(SnatBridgeULAPrefix); err != nil {
+			log.Printf("Adding ipv6 snat rule failed with error %v", err)
+			return err
+		}
+
+		// The NCs must not autoconfigure addresses or routes from router advertisements of the host.
+		if err := client.addRouterAdvertisementDropRule(); err != nil {
+			log.Printf("Adding router advertisement drop rule failed with error %v", err)
+			return err
+		}
+	}
+
 	// Drop all vlan packets coming via linux bridge.
 	if err := client.addVlanDropRule(); err != nil {
 		log.Printf("Adding vlan drop rule failed with error %v", err)
@@ -109,7 +147,7 @@ func (client *Client) CreateSnatEndpoint() error {
 
 // AllowIPAddressesOnSnatBridge adds iptables rules  that allows only specific Private IPs via linux bridge
 func (client *Client) AllowIPAddressesOnSnatBridge() error {
-	if err := networkutils.AllowIPAddresses(SnatBridgeName, client.SkipAddressesFromBlock, iptables.Insert); err != nil {
+	if err := networkutils.NewNetworkUtils(client.netlink, client.plClient).AllowIPAddresses(SnatBridgeName, client.SkipAddressesFromBlock, client.Family, iptables.Insert); err != nil {
 		log.Printf("AllowIPAddresses failed with error %v", err)
 		return newErrorSnatClient(err.Error())
 	}
@@ -119,7 +157,7 @@ func (client *Client) AllowIPAddressesOnSnatBridge() error {
 
 // BlockIPAddressesOnSnatBridge adds iptables rules  that blocks all private IPs flowing via linux bridge
 func (client *Client) BlockIPAddressesOnSnatBridge() error {
-	if err := networkutils.BlockIPAddresses(SnatBridgeName, iptables.Append); err != nil {
+	if err := networkutils.NewNetworkUtils(client.netlink, client.plClient).BlockIPAddresses(SnatBridgeName, client.Family, iptables.Append); err != nil {
 		log.Printf("AllowIPAddresses failed with error %v", err)
 		return newErrorSnatClient(err.Error())
 	}
@@ -127,9 +165,13 @@ func (client *Client) BlockIPAddressesOnSnatBridge() error {
 	return nil
 }
 
-/**
+/*
+*
+
 	Move container veth inside container network namespace
-**/
+
+*
+*/
 func (client *Client) MoveSnatEndpointToContainerNS(netnsPath string, nsID uintptr) error {
 	log.Printf("[snat] Setting link %v netns %v.", client.containerSnatVethName, netnsPath)
 	err := client.netlink.SetLinkNetNs(client.containerSnatVethName, nsID)
@@ -139,9 +181,13 @@ func (client *Client) MoveSnatEndpointToContainerNS(netnsPath string, nsID uintp
 	return nil
 }
 
-/**
+/*
+*
+
 	Configure Routes and setup name for container veth
-**/
+
+*
+*/
 func (client *Client) SetupSnatContainerInterface() error {
 	epc := networkutils.NewNetworkUtils(client.netlink, client.plClient)
 	if err := epc.SetupContainerInterface(client.containerSnatVethName, azureSnatIfName); err != nil {
@@ -159,185 +205,259 @@ func getNCLocalAndGatewayIP(client *Client) (brIP, contIP net.IP) {
 	return bridgeIP, containerIP
 }
 
-/**
-	This function adds iptables rules that allows only host to NC communication and not the other way
-**/
-func (client *Client) AllowInboundFromHostToNC() error {
-	bridgeIP, containerIP := getNCLocalAndGatewayIP(client)
+// ncLocalAndGatewayIPs are the addresses of the snat bridge and the NC of an IP version.
+type ncLocalAndGatewayIPs struct {
+	version     string
+	bridgeIP    net.IP
+	containerIP net.IP
+}
 
-	// Create CNI Output chain
-	if err := iptables.CreateChain(iptables.V4, iptables.Filter, iptables.CNIOutputChain); err != nil {
-		log.Printf("AllowInboundFromHostToNC: Creating %v failed with error: %v", iptables.CNIOutputChain, err)
-		return newErrorSnatClient(err.Error())
+// getNCLocalAndGatewayIPs returns the addresses of the snat bridge and the NC of each IP version of the family.
+func getNCLocalAndGatewayIPs(client *Client) []ncLocalAndGatewayIPs {
+	bridgeIP, containerIP := getNCLocalAndGatewayIP(client)
+	ips := []ncLocalAndGatewayIPs{{version: iptables.V4, bridgeIP: bridgeIP, containerIP: containerIP}}
+	if client.ipv6Enabled() {
+		bridgeIPv6, _, _ := net.ParseCIDR(SnatBridgeIPv6)
+		ips = append(ips, ncLocalAndGatewayIPs{version: iptables.V6, bridgeIP: bridgeIPv6, containerIP: ncLocalIPv6(containerIP)})
 	}
 
-	// Forward traffic from Ouptut chain to CNI Output chain
-	if err := iptables.InsertIptableRule(iptables.V4, iptables.Filter, iptables.Output, "", iptables.CNIOutputChain); err != nil {
-		log.Printf("AllowInboundFromHostToNC: Inserting forward rule to %v failed with error: %v", iptables.CNIOutputChain, err)
-		return newErrorSnatClient(err.Error())
-	}
+	return ips
+}
 
-	// Allow connection from Host to NC
-	matchCondition := fmt.Sprintf("-s %s -d %s", bridgeIP.String(), containerIP.String())
-	err := iptables.InsertIptableRule(iptables.V4, iptables.Filter, iptables.CNIOutputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		log.Printf("AllowInboundFromHostToNC: Inserting output rule failed: %v", err)
-		return newErrorSnatClient(err.Error())
-	}
+// ncLocalIPv6 returns the local IPv6 address of the NC with the local IPv4 address, which is unique on the
+// snat bridge as the IPv4 address is.
+func ncLocalIPv6(localIP net.IP) net.IP {
+	_, prefix, _ := net.ParseCIDR(SnatBridgeULAPrefix)
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.IP)
+	copy(ip[net.IPv6len-net.IPv4len:], localIP.To4())
+	return ip
+}
 
-	// Create cniinput chain
-	if err := iptables.CreateChain(iptables.V4, iptables.Filter, iptables.CNIInputChain); err != nil {
-		log.Printf("AllowInboundFromHostToNC: Creating %v failed with error: %v", iptables.CNIInputChain, err)
-		return newErrorSnatClient(err.Error())
-	}
+func (client *Client) ipv6Enabled() bool {
+	return client.Family&iptables.FamilyV6 != 0
+}
 
-	// Forward from Input to cniinput chain
-	if err := iptables.InsertIptableRule(iptables.V4, iptables.Filter, iptables.Input, "", iptables.CNIInputChain); err != nil {
-		log.Printf("AllowInboundFromHostToNC: Inserting forward rule to %v failed with error: %v", iptables.CNIInputChain, err)
-		return newErrorSnatClient(err.Error())
-	}
+/*
+*
 
-	// Accept packets from NC only if established connection
-	matchCondition = fmt.Sprintf(" -i %s -m state --state %s,%s", SnatBridgeName, iptables.Established, iptables.Related)
-	err = iptables.InsertIptableRule(iptables.V4, iptables.Filter, iptables.CNIInputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		log.Printf("AllowInboundFromHostToNC: Inserting input rule failed: %v", err)
-		return newErrorSnatClient(err.Error())
-	}
+	This function adds iptables rules that allows only host to NC communication and not the other way
 
+*
+*/
+func (client *Client) AllowInboundFromHostToNC() error {
 	snatContainerVeth, _ := net.InterfaceByName(client.containerSnatVethName)
 
-	// Add static arp entry for localIP to prevent arp going out of VM
-	log.Printf("Adding static arp entry for ip %s mac %s", containerIP, snatContainerVeth.HardwareAddr.String())
-	linkInfo := netlink.LinkInfo{
-		Name:       SnatBridgeName,
-		IPAddr:     containerIP,
-		MacAddress: snatContainerVeth.HardwareAddr,
-	}
+	for _, ips := range getNCLocalAndGatewayIPs(client) {
+		w := iptables.NewWriter(ips.version)
 
-	err = client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT)
-	if err != nil {
-		log.Printf("AllowInboundFromHostToNC: Error adding static arp entry for ip %s mac %s: %v", containerIP, snatContainerVeth.HardwareAddr.String(), err)
-		return newErrorSnatClient(err.Error())
+		// Create CNI Output chain and forward traffic from Output chain to it
+		w.EnsureChain(iptables.Filter, iptables.CNIOutputChain)
+		rules := []*iptables.Rule{
+			newFilterRule(ips.version, iptables.Output, iptables.CNIOutputChain),
+			// Allow connection from Host to NC
+			newFilterRule(ips.version, iptables.CNIOutputChain, iptables.Accept,
+				iptables.MatchSource(ips.bridgeIP.String()), iptables.MatchDestination(ips.containerIP.String())),
+		}
+
+		// Create cniinput chain and forward traffic from Input chain to it
+		w.EnsureChain(iptables.Filter, iptables.CNIInputChain)
+		rules = append(rules,
+			newFilterRule(ips.version, iptables.Input, iptables.CNIInputChain),
+			// Accept packets from NC only if established connection
+			newFilterRule(ips.version, iptables.CNIInputChain, iptables.Accept,
+				iptables.MatchInInterface(SnatBridgeName), iptables.MatchState(iptables.Established, iptables.Related)),
+		)
+
+		if err := client.programRules(w, newRuleRegistry(), ips.version, rules); err != nil {
+			log.Printf("AllowInboundFromHostToNC: Programming iptables rules failed with error: %v", err)
+			return newErrorSnatClient(err.Error())
+		}
+
+		// Add static neighbor entry for localIP to prevent arp and neighbor discovery going out of VM
+		log.Printf("Adding static arp entry for ip %s mac %s", ips.containerIP, snatContainerVeth.HardwareAddr.String())
+		linkInfo := netlink.LinkInfo{
+			Name:       SnatBridgeName,
+			IPAddr:     ips.containerIP,
+			MacAddress: snatContainerVeth.HardwareAddr,
+		}
+
+		err := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT)
+		if err != nil {
+			log.Printf("AllowInboundFromHostToNC: Error adding static arp entry for ip %s mac %s: %v", ips.containerIP, snatContainerVeth.HardwareAddr.String(), err)
+			return newErrorSnatClient(err.Error())
+		}
 	}
 
 	return nil
 }
 
 func (client *Client) DeleteInboundFromHostToNC() error {
-	bridgeIP, containerIP := getNCLocalAndGatewayIP(client)
+	var err error
+	for _, ips := range getNCLocalAndGatewayIPs(client) {
+		// Delete allow connection from Host to NC
+		ruleErr := newRuleRegistry().DeleteRule(newFilterRule(ips.version, iptables.CNIOutputChain, iptables.Accept,
+			iptables.MatchSource(ips.bridgeIP.String()), iptables.MatchDestination(ips.containerIP.String())))
+		if ruleErr != nil {
+			log.Printf("DeleteInboundFromHostToNC: Error removing output rule %v", ruleErr)
+		}
 
-	// Delete allow connection from Host to NC
-	matchCondition := fmt.Sprintf("-s %s -d %s", bridgeIP.String(), containerIP.String())
-	err := iptables.DeleteIptableRule(iptables.V4, iptables.Filter, iptables.CNIOutputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		log.Printf("DeleteInboundFromHostToNC: Error removing output rule %v", err)
-	}
+		// Flush connections which were allowed by the deleted rule
+		client.flushConntrack(conntrack.Filter{SrcIP: ips.bridgeIP, DstIP: ips.containerIP})
 
-	// Remove static arp entry added for container local IP
-	log.Printf("Removing static arp entry for ip %s ", containerIP)
-	linkInfo := netlink.LinkInfo{
-		Name:       SnatBridgeName,
-		IPAddr:     containerIP,
-		MacAddress: nil,
-	}
+		// Remove static neighbor entry added for container local IP
+		log.Printf("Removing static arp entry for ip %s ", ips.containerIP)
+		linkInfo := netlink.LinkInfo{
+			Name:       SnatBridgeName,
+			IPAddr:     ips.containerIP,
+			MacAddress: nil,
+		}
 
-	err = client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.REMOVE, netlink.NUD_INCOMPLETE)
-	if err != nil {
-		log.Printf("AllowInboundFromHostToNC: Error removing static arp entry for ip %s: %v", containerIP, err)
+		if removeErr := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.REMOVE, netlink.NUD_INCOMPLETE); removeErr != nil {
+			log.Printf("AllowInboundFromHostToNC: Error removing static arp entry for ip %s: %v", ips.containerIP, removeErr)
+			// the entries of the other IP versions are still removed, the first failure is returned.
+			if err == nil {
+				err = removeErr
+			}
+		}
 	}
 
 	return err
 }
 
-/**
+/*
+*
+
 	This function adds iptables rules that allows only NC to Host communication and not the other way
-**/
+
+*
+*/
 func (client *Client) AllowInboundFromNCToHost() error {
-	bridgeIP, containerIP := getNCLocalAndGatewayIP(client)
+	snatContainerVeth, _ := net.InterfaceByName(client.containerSnatVethName)
 
-	// Create CNI Input chain
-	if err := iptables.CreateChain(iptables.V4, iptables.Filter, iptables.CNIInputChain); err != nil {
-		log.Printf("AllowInboundFromHostToNC: Creating %v failed with error: %v", iptables.CNIInputChain, err)
-		return err
-	}
+	for _, ips := range getNCLocalAndGatewayIPs(client) {
+		w := iptables.NewWriter(ips.version)
 
-	// Forward traffic from Input to cniinput chain
-	if err := iptables.InsertIptableRule(iptables.V4, iptables.Filter, iptables.Input, "", iptables.CNIInputChain); err != nil {
-		log.Printf("AllowInboundFromHostToNC: Inserting forward rule to %v failed with error: %v", iptables.CNIInputChain, err)
-		return err
-	}
+		// Create CNI Input chain and forward traffic from Input chain to it
+		w.EnsureChain(iptables.Filter, iptables.CNIInputChain)
+		rules := []*iptables.Rule{
+			newFilterRule(ips.version, iptables.Input, iptables.CNIInputChain),
+			// Allow NC to Host connection
+			newFilterRule(ips.version, iptables.CNIInputChain, iptables.Accept,
+				iptables.MatchSource(ips.containerIP.String()), iptables.MatchDestination(ips.bridgeIP.String())),
+		}
 
-	// Allow NC to Host connection
-	matchCondition := fmt.Sprintf("-s %s -d %s", containerIP.String(), bridgeIP.String())
-	err := iptables.InsertIptableRule(iptables.V4, iptables.Filter, iptables.CNIInputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		log.Printf("AllowInboundFromHostToNC: Inserting output rule failed: %v", err)
-		return err
-	}
+		// Create CNI output chain and forward traffic from Output chain to it
+		w.EnsureChain(iptables.Filter, iptables.CNIOutputChain)
+		rules = append(rules,
+			newFilterRule(ips.version, iptables.Output, iptables.CNIOutputChain),
+			// Accept packets from Host only if established connection
+			newFilterRule(ips.version, iptables.CNIOutputChain, iptables.Accept,
+				iptables.MatchOutInterface(SnatBridgeName), iptables.MatchState(iptables.Established, iptables.Related)),
+		)
+
+		if err := client.programRules(w, newRuleRegistry(), ips.version, rules); err != nil {
+			log.Printf("AllowInboundFromNCToHost: Programming iptables rules failed with error: %v", err)
+			return err
+		}
 
-	// Create CNI output chain
-	if err := iptables.CreateChain(iptables.V4, iptables.Filter, iptables.CNIOutputChain); err != nil {
-		log.Printf("AllowInboundFromHostToNC: Creating %v failed with error: %v", iptables.CNIOutputChain, err)
-		return err
-	}
+		// Add static neighbor entry for localIP to prevent arp and neighbor discovery going out of VM
+		log.Printf("Adding static arp entry for ip %s mac %s", ips.containerIP, snatContainerVeth.HardwareAddr.String())
+		linkInfo := netlink.LinkInfo{
+			Name:       SnatBridgeName,
+			IPAddr:     ips.containerIP,
+			MacAddress: snatContainerVeth.HardwareAddr,
+		}
 
-	// Forward traffic from Output to CNI Output chain
-	if err := iptables.InsertIptableRule(iptables.V4, iptables.Filter, iptables.Output, "", iptables.CNIOutputChain); err != nil {
-		log.Printf("AllowInboundFromHostToNC: Inserting forward rule to %v failed with error: %v", iptables.CNIOutputChain, err)
-		return err
+		if err := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT); err != nil {
+			log.Printf("AllowInboundFromNCToHost: Error adding static arp entry for ip %s mac %s: %v", ips.containerIP, snatContainerVeth.HardwareAddr.String(), err)
+			return err
+		}
 	}
 
-	// Accept packets from Host only if established connection
-	matchCondition = fmt.Sprintf(" -o %s -m state --state %s,%s", SnatBridgeName, iptables.Established, iptables.Related)
-	err = iptables.InsertIptableRule(iptables.V4, iptables.Filter, iptables.CNIOutputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		log.Printf("AllowInboundFromHostToNC: Inserting input rule failed: %v", err)
-		return err
-	}
+	return nil
+}
 
-	snatContainerVeth, _ := net.InterfaceByName(client.containerSnatVethName)
+func (client *Client) DeleteInboundFromNCToHost() error {
+	var err error
+	for _, ips := range getNCLocalAndGatewayIPs(client) {
+		// Delete allow NC to Host connection
+		ruleErr := newRuleRegistry().DeleteRule(newFilterRule(ips.version, iptables.CNIInputChain, iptables.Accept,
+			iptables.MatchSource(ips.containerIP.String()), iptables.MatchDestination(ips.bridgeIP.String())))
+		if ruleErr != nil {
+			log.Printf("DeleteInboundFromNCToHost: Error removing output rule %v", ruleErr)
+		}
 
-	// Add static arp entry for localIP to prevent arp going out of VM
-	log.Printf("Adding static arp entry for ip %s mac %s", containerIP, snatContainerVeth.HardwareAddr.String())
-	linkInfo := netlink.LinkInfo{
-		Name:       SnatBridgeName,
-		IPAddr:     containerIP,
-		MacAddress: snatContainerVeth.HardwareAddr,
-	}
+		// Flush connections which were allowed by the deleted rule
+		client.flushConntrack(conntrack.Filter{SrcIP: ips.containerIP, DstIP: ips.bridgeIP})
 
-	err = client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT)
-	if err != nil {
-		log.Printf("AllowInboundFromNCToHost: Error adding static arp entry for ip %s mac %s: %v", containerIP, snatContainerVeth.HardwareAddr.String(), err)
+		// Remove static neighbor entry added for container local IP
+		log.Printf("Removing static arp entry for ip %s ", ips.containerIP)
+		linkInfo := netlink.LinkInfo{
+			Name:       SnatBridgeName,
+			IPAddr:     ips.containerIP,
+			MacAddress: nil,
+		}
+
+		if removeErr := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.REMOVE, netlink.NUD_INCOMPLETE); removeErr != nil {
+			log.Printf("DeleteInboundFromNCToHost: Error removing static arp entry for ip %s: %v", ips.containerIP, removeErr)
+			// the entries of the other IP versions are still removed, the first failure is returned.
+			if err == nil {
+				err = removeErr
+			}
+		}
 	}
 
 	return err
 }
 
-func (client *Client) DeleteInboundFromNCToHost() error {
-	bridgeIP, containerIP := getNCLocalAndGatewayIP(client)
+// programRules inserts the rules in order, after the chains added to the writer, with a single restore.
+// Since every rule is inserted at the head of its chain the last rule ends up first. The rules are tagged
+// with the registry, copies left behind by other releases are removed once the new rules are in place.
+func (client *Client) programRules(w *iptables.Writer, registry *iptables.Registry, version string, rules []*iptables.Rule) error {
+	for _, chain := range []string{iptables.CNIInputChain, iptables.CNIOutputChain} {
+		registry.RegisterChain(version, iptables.Filter, chain)
+	}
 
-	// Delete allow NC to Host connection
-	matchCondition := fmt.Sprintf("-s %s -d %s", containerIP.String(), bridgeIP.String())
-	err := iptables.DeleteIptableRule(iptables.V4, iptables.Filter, iptables.CNIInputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		log.Printf("DeleteInboundFromNCToHost: Error removing output rule %v", err)
+	for _, rule := range rules {
+		if err := w.EnsureRule(registry.Tag(rule), iptables.Insert); err != nil {
+			return err
+		}
 	}
 
-	// Remove static arp entry added for container local IP
-	log.Printf("Removing static arp entry for ip %s ", containerIP)
-	linkInfo := netlink.LinkInfo{
-		Name:       SnatBridgeName,
-		IPAddr:     containerIP,
-		MacAddress: nil,
+	if err := w.Flush(); err != nil {
+		return err
 	}
 
-	err = client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.REMOVE, netlink.NUD_INCOMPLETE)
-	if err != nil {
-		log.Printf("DeleteInboundFromNCToHost: Error removing static arp entry for ip %s: %v", containerIP, err)
+	if err := registry.CleanupOrphans(false); err != nil {
+		log.Printf("Failed to clean up iptables rules of other releases: %v", err)
 	}
 
-	return err
+	return nil
+}
+
+// newRuleRegistry returns the registry tagging the iptables rules of the snat client.
+func newRuleRegistry() *iptables.Registry {
+	return iptables.NewRegistry(cniRuleOwner, iptables.ReleaseVersion, cniChainPrefix)
+}
+
+// newFilterRule returns a rule of the IP version in the filter table jumping from chain to target.
+func newFilterRule(version, chain, target string, matches ...iptables.Match) *iptables.Rule {
+	return &iptables.Rule{
+		Version: version,
+		Table:   iptables.Filter,
+		Chain:   chain,
+		Matches: matches,
+		Target:  iptables.Jump(target),
+	}
+}
+
+// flushConntrack removes conntrack entries matching the filter. Failures are only logged since
+// stale entries expire on their own and must not fail the rule deletion.
+func (client *Client) flushConntrack(filter conntrack.Filter) {
+	if _, err := client.conntrack.Flush(filter); err != nil {
+		log.Printf("Failed to flush conntrack entries matching %+v: %v", filter, err)
+	}
 }
 
 /**
@@ -351,6 +471,49 @@ func (client *Client) ConfigureSnatContainerInterface() error {
 	if err != nil {
 		return newErrorSnatClient(err.Error())
 	}
+
+	if client.ipv6Enabled() {
+		return client.configureSnatContainerInterfaceIPv6(ip)
+	}
+	return nil
+}
+
+// configureSnatContainerInterfaceIPv6 adds the local IPv6 address of the NC to the container veth, and the
+// default IPv6 route via the snat bridge unless the NC has one already. Router advertisements are ignored
+// so that the routes of the NC are only those programmed by CNI.
+func (client *Client) configureSnatContainerInterfaceIPv6(localIP net.IP) error {
+	nuc := networkutils.NewNetworkUtils(client.netlink, client.plClient)
+	if err := nuc.DisableRAForInterface(client.containerSnatVethName); err != nil {
+		return newErrorSnatClient(err.Error())
+	}
+
+	ip := ncLocalIPv6(localIP)
+	_, prefix, _ := net.ParseCIDR(SnatBridgeULAPrefix)
+	log.Printf("[snat] Adding IP address %v to link %v.", ip, client.containerSnatVethName)
+	if err := client.netlink.AddIPAddress(client.containerSnatVethName, ip, &net.IPNet{IP: ip, Mask: prefix.Mask}); err != nil {
+		return newErrorSnatClient(err.Error())
+	}
+
+	iface, err := net.InterfaceByName(client.containerSnatVethName)
+	if err != nil {
+		return newErrorSnatClient(err.Error())
+	}
+
+	gwIP, _, _ := net.ParseCIDR(SnatBridgeIPv6)
+	route := &netlink.Route{
+		Family:    netlink.GetIPAddressFamily(gwIP),
+		Dst:       &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 8*net.IPv6len)},
+		Gw:        gwIP,
+		LinkIndex: iface.Index,
+	}
+	log.Printf("[snat] Adding default IPv6 route via %v to link %v.", gwIP, client.containerSnatVethName)
+	if err := client.netlink.AddIPRoute(route); err != nil {
+		if !errors.Is(err, netlink.ErrExists) {
+			return newErrorSnatClient(err.Error())
+		}
+		log.Printf("[snat] Keeping the default IPv6 route of the NC")
+	}
+
 	return nil
 }
 
@@ -388,9 +551,13 @@ func (client *Client) DropArpForSnatBridgeApipaRange(snatBridgeIP, azSnatVethIfN
 	return err
 }
 
-/**
+/*
+*
+
 	This function creates linux bridge which will be used for outbound connectivity by NCs
-**/
+
+*
+*/
 func (client *Client) createSnatBridge(snatBridgeIP, hostPrimaryMac string) error {
 	_, err := net.InterfaceByName(SnatBridgeName)
 	if err == nil {
@@ -421,13 +588,23 @@ func (client *Client) createSnatBridge(snatBridgeIP, hostPrimaryMac string) erro
 		return err
 	}
 
-	log.Printf("Assigning %v on snat bridge", snatBridgeIP)
+	bridgeIPs := []string{snatBridgeIP}
+	if client.ipv6Enabled() {
+		if err = nuc.EnableIPV6ForInterface(SnatBridgeName); err != nil {
+			return newErrorSnatClient(err.Error())
+		}
+		bridgeIPs = append(bridgeIPs, SnatBridgeIPv6)
+	}
 
-	ip, addr, _ := net.ParseCIDR(snatBridgeIP)
-	err = client.netlink.AddIPAddress(SnatBridgeName, ip, addr)
-	if err != nil && !strings.Contains(strings.ToLower(err.Error()), "file exists") {
-		log.Printf("[net] Failed to add IP address %v: %v.", addr, err)
-		return newErrorSnatClient(err.Error())
+	for _, bridgeIP := range bridgeIPs {
+		log.Printf("Assigning %v on snat bridge", bridgeIP)
+
+		ip, addr, _ := net.ParseCIDR(bridgeIP)
+		err = client.netlink.AddIPAddress(SnatBridgeName, ip, addr)
+		if err != nil && !errors.Is(err, netlink.ErrExists) {
+			log.Printf("[net] Failed to add IP address %v: %v.", addr, err)
+			return newErrorSnatClient(err.Error())
+		}
 	}
 
 	if err = client.netlink.SetLinkState(SnatBridgeName, true); err != nil {
@@ -437,18 +614,51 @@ func (client *Client) createSnatBridge(snatBridgeIP, hostPrimaryMac string) erro
 	return nil
 }
 
-/**
-	This function adds iptable rules that will snat all traffic that has source ip in apipa range and coming via linux bridge
-**/
+/*
+*
+
+	This function adds iptable rules that will snat all traffic that has source ip in apipa range and coming via linux bridge.
+	The traffic from the unique local IPv6 range is masqueraded with ip6tables.
+
+*
+*/
 func (client *Client) addMasqueradeRule(snatBridgeIPWithPrefix string) error {
 	_, ipNet, _ := net.ParseCIDR(snatBridgeIPWithPrefix)
-	matchCondition := fmt.Sprintf("-s %s", ipNet.String())
-	return iptables.InsertIptableRule(iptables.V4, iptables.Nat, iptables.Postrouting, matchCondition, iptables.Masquerade)
+	rule := &iptables.Rule{
+		Table:   iptables.Nat,
+		Chain:   iptables.Postrouting,
+		Matches: []iptables.Match{iptables.MatchSource(ipNet.String())},
+		Target:  iptables.Jump(iptables.Masquerade),
+	}
+	return iptables.EnsureRuleFamily(iptables.FamilyOf(ipNet.String()), rule, iptables.Insert)
 }
 
-/**
+// addRouterAdvertisementDropRule drops the router advertisements the host sends on the snat bridge.
+func (client *Client) addRouterAdvertisementDropRule() error {
+	return iptables.EnsureRule(newRouterAdvertisementDropRule(), iptables.Insert)
+}
+
+func newRouterAdvertisementDropRule() *iptables.Rule {
+	return &iptables.Rule{
+		Version: iptables.V6,
+		Table:   iptables.Filter,
+		Chain:   iptables.Output,
+		Matches: []iptables.Match{
+			iptables.MatchOutInterface(SnatBridgeName),
+			iptables.MatchProtocol(icmpv6Protocol),
+			iptables.MatchRaw("--icmpv6-type " + routerAdvertisement),
+		},
+		Target: iptables.Jump(iptables.Drop),
+	}
+}
+
+/*
+*
+
 	Drop all vlan traffic on linux bridge
-**/
+
+*
+*/
 func (client *Client) addVlanDropRule() error {
 	out, err := client.plClient.ExecuteCommand(l2PreroutingEntries)
 	if err != nil {
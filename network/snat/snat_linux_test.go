@@ -1,10 +1,14 @@
 package snat
 
 import (
+	"net"
 	"os"
 	"testing"
 
+	"github.com/Azure/azure-container-networking/conntrack"
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/stretchr/testify/require"
 )
 
 var anyInterface = "dummy"
@@ -24,6 +28,7 @@ func TestAllowInboundFromHostToNC(t *testing.T) {
 		localIP:               "169.254.0.4/16",
 		containerSnatVethName: anyInterface,
 		netlink:               nl,
+		conntrack:             conntrack.NewMockConntrack(false),
 	}
 
 	if err := nl.AddLink(&netlink.DummyLink{
@@ -71,6 +76,7 @@ func TestAllowInboundFromNCToHost(t *testing.T) {
 		localIP:               "169.254.0.4/16",
 		containerSnatVethName: anyInterface,
 		netlink:               nl,
+		conntrack:             conntrack.NewMockConntrack(false),
 	}
 
 	if err := nl.AddLink(&netlink.DummyLink{
@@ -110,3 +116,102 @@ func TestAllowInboundFromNCToHost(t *testing.T) {
 		t.Errorf("Error removing snat bridge: %v", err)
 	}
 }
+
+func TestDeleteInboundRulesFlushConntrack(t *testing.T) {
+	ct := conntrack.NewMockConntrack(false)
+	client := &Client{
+		SnatBridgeIP:          "169.254.0.1/16",
+		localIP:               "169.254.0.4/16",
+		containerSnatVethName: anyInterface,
+		netlink:               netlink.NewMockNetlink(false, ""),
+		conntrack:             ct,
+	}
+
+	bridgeIP := net.ParseIP("169.254.0.1")
+	containerIP := net.ParseIP("169.254.0.4")
+
+	require.NoError(t, client.DeleteInboundFromHostToNC())
+	require.NoError(t, client.DeleteInboundFromNCToHost())
+	require.Len(t, ct.Flushed, 2)
+	require.True(t, ct.Flushed[0].SrcIP.Equal(bridgeIP) && ct.Flushed[0].DstIP.Equal(containerIP),
+		"the host to NC connections are flushed: %+v", ct.Flushed[0])
+	require.True(t, ct.Flushed[1].SrcIP.Equal(containerIP) && ct.Flushed[1].DstIP.Equal(bridgeIP),
+		"the NC to host connections are flushed: %+v", ct.Flushed[1])
+}
+
+func TestNCLocalAndGatewayIPv6(t *testing.T) {
+	client := &Client{
+		SnatBridgeIP: "169.254.0.1/16",
+		localIP:      "169.254.0.4/16",
+		Family:       iptables.FamilyV4,
+	}
+
+	ips := getNCLocalAndGatewayIPs(client)
+	require.Len(t, ips, 1, "IPv6 is only set up for dual stack endpoints")
+	require.Equal(t, iptables.V4, ips[0].version)
+
+	client.Family = iptables.FamilyDual
+	ips = getNCLocalAndGatewayIPs(client)
+	require.Len(t, ips, 2)
+	require.Equal(t, iptables.V6, ips[1].version)
+	require.Equal(t, "fd00:a9fe::1", ips[1].bridgeIP.String())
+	require.Equal(t, "fd00:a9fe::a9fe:4", ips[1].containerIP.String(), "the NC IPv6 address embeds its IPv4 address")
+
+	_, prefix, _ := net.ParseCIDR(SnatBridgeULAPrefix)
+	require.True(t, prefix.Contains(ips[1].bridgeIP))
+	require.True(t, prefix.Contains(ips[1].containerIP))
+}
+
+func TestDeleteInboundRulesFlushConntrackIPv6(t *testing.T) {
+	ct := conntrack.NewMockConntrack(false)
+	client := &Client{
+		SnatBridgeIP:          "169.254.0.1/16",
+		localIP:               "169.254.0.4/16",
+		containerSnatVethName: anyInterface,
+		netlink:               netlink.NewMockNetlink(false, ""),
+		conntrack:             ct,
+		Family:                iptables.FamilyDual,
+	}
+
+	require.NoError(t, client.DeleteInboundFromHostToNC())
+	require.Len(t, ct.Flushed, 2, "the connections of both IP versions are flushed")
+	require.True(t, ct.Flushed[1].SrcIP.Equal(net.ParseIP("fd00:a9fe::1")) && ct.Flushed[1].DstIP.Equal(net.ParseIP("fd00:a9fe::a9fe:4")),
+		"the IPv6 host to NC connections are flushed: %+v", ct.Flushed[1])
+}
+
+func TestRouterAdvertisementDropRule(t *testing.T) {
+	rule := newRouterAdvertisementDropRule()
+	require.Equal(t, iptables.V6, rule.Version)
+	require.Equal(t, "-o azSnatbr -p ipv6-icmp --icmpv6-type router-advertisement -j DROP", rule.Render())
+}
+
+// neighborFailingNetlink fails to program the neighbor entries of IPv4 addresses.
+type neighborFailingNetlink struct {
+	*netlink.MockNetlink
+	removed []net.IP
+}
+
+func (nl *neighborFailingNetlink) SetOrRemoveLinkAddress(linkInfo netlink.LinkInfo, mode, linkState int) error {
+	if linkInfo.IPAddr.To4() != nil {
+		return netlink.ErrorMockNetlink
+	}
+	nl.removed = append(nl.removed, linkInfo.IPAddr)
+	return nil
+}
+
+func TestDeleteInboundRulesReturnIPv4NeighborError(t *testing.T) {
+	nl := &neighborFailingNetlink{MockNetlink: netlink.NewMockNetlink(false, "")}
+	client := &Client{
+		SnatBridgeIP:          "169.254.0.1/16",
+		localIP:               "169.254.0.4/16",
+		containerSnatVethName: anyInterface,
+		netlink:               nl,
+		conntrack:             conntrack.NewMockConntrack(false),
+		Family:                iptables.FamilyDual,
+	}
+
+	require.ErrorIs(t, client.DeleteInboundFromHostToNC(), netlink.ErrorMockNetlink,
+		"the IPv4 failure isn't hidden by the IPv6 success")
+	require.ErrorIs(t, client.DeleteInboundFromNCToHost(), netlink.ErrorMockNetlink)
+	require.Len(t, nl.removed, 2, "the IPv6 entries are removed despite the IPv4 failure")
+}
@@ -0,0 +1,192 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+// populatePointers links the networks to their external interfaces.
+func (nm *networkManager) populatePointers() {
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			nw.extIf = extIf
+		}
+	}
+}
+
+// mergePersistedState merges the changes made by this process since the state was read into the state
+// saved by other processes in the meantime. The store must be locked.
+func (nm *networkManager) mergePersistedState() error {
+	persisted := &networkManager{ExternalInterfaces: make(map[string]*externalInterface)}
+
+	err := nm.store.Read(storeKey, persisted)
+	if err != nil && err != store.ErrKeyNotFound && err != store.ErrStoreEmpty {
+		return err
+	}
+
+	if persisted.ExternalInterfaces == nil {
+		persisted.ExternalInterfaces = make(map[string]*externalInterface)
+	}
+
+	mergeExternalInterfaces(nm.persisted, nm.ExternalInterfaces, persisted.ExternalInterfaces)
+
+	nm.ExternalInterfaces = persisted.ExternalInterfaces
+	nm.populatePointers()
+
+	return nil
+}
+
+// copyExternalInterfaces returns a deep copy of the external interfaces.
+func copyExternalInterfaces(extIfs map[string]*externalInterface) map[string]*externalInterface {
+	copied := make(map[string]*externalInterface)
+
+	b, err := json.Marshal(extIfs)
+	if err == nil {
+		err = json.Unmarshal(b, &copied)
+	}
+
+	if err != nil {
+		// Without a copy all entries are treated as changed by this process on the next save.
+		log.Printf("[net] Failed to copy state, err:%v", err)
+		return nil
+	}
+
+	return copied
+}
+
+// equalState returns true if both values have the same persisted representation.
+func equalState(a, b interface{}) bool {
+	ab, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ab, bb)
+}
+
+// mergeExternalInterfaces applies the changes from base to current onto persisted. Entries are merged
+// per external interface, network and endpoint, so concurrent changes to different endpoints are kept.
+func mergeExternalInterfaces(base, current, persisted map[string]*externalInterface) {
+	for name, extIf := range current {
+		mergeExternalInterface(base[name], extIf, persisted, name)
+	}
+
+	for name, extIf := range base {
+		if _, ok := current[name]; !ok && equalState(extIf, persisted[name]) {
+			delete(persisted, name)
+		}
+	}
+}
+
+func mergeExternalInterface(base, current *externalInterface, persisted map[string]*externalInterface, name string) {
+	extIf, ok := persisted[name]
+	if !ok {
+		// Keep the deletion by another process unless this process changed the interface.
+		if base == nil || !equalState(base, current) {
+			persisted[name] = current
+		}
+		return
+	}
+
+	if base == nil {
+		base = &externalInterface{}
+	}
+
+	if !equalState(externalInterfaceFields(base), externalInterfaceFields(current)) {
+		networks, vlanInterfaces := extIf.Networks, extIf.VlanInterfaces
+		*extIf = *current
+		extIf.Networks, extIf.VlanInterfaces = networks, vlanInterfaces
+	}
+
+	if extIf.Networks == nil {
+		extIf.Networks = make(map[string]*network)
+	}
+
+	for id, nw := range current.Networks {
+		mergeNetwork(base.Networks[id], nw, extIf.Networks, id)
+	}
+
+	for id, nw := range base.Networks {
+		if _, ok := current.Networks[id]; !ok && equalState(nw, extIf.Networks[id]) {
+			delete(extIf.Networks, id)
+		}
+	}
+
+	if extIf.VlanInterfaces == nil && len(current.VlanInterfaces) > 0 {
+		extIf.VlanInterfaces = make(map[int]*vlanInterface)
+	}
+
+	for id, vlanIf := range current.VlanInterfaces {
+		if !equalState(base.VlanInterfaces[id], vlanIf) {
+			extIf.VlanInterfaces[id] = vlanIf
+		}
+	}
+
+	for id := range base.VlanInterfaces {
+		if _, ok := current.VlanInterfaces[id]; !ok {
+			delete(extIf.VlanInterfaces, id)
+		}
+	}
+}
+
+func mergeNetwork(base, current *network, persisted map[string]*network, id string) {
+	nw, ok := persisted[id]
+	if !ok {
+		if base == nil || !equalState(base, current) {
+			persisted[id] = current
+		}
+		return
+	}
+
+	if base == nil {
+		base = &network{}
+	}
+
+	if !equalState(networkFields(base), networkFields(current)) {
+		endpoints := nw.Endpoints
+		*nw = *current
+		nw.Endpoints = endpoints
+	}
+
+	if nw.Endpoints == nil {
+		nw.Endpoints = make(map[string]*endpoint)
+	}
+
+	for epID, ep := range current.Endpoints {
+		if !equalState(base.Endpoints[epID], ep) {
+			nw.Endpoints[epID] = ep
+		}
+	}
+
+	for epID := range base.Endpoints {
+		if _, ok := current.Endpoints[epID]; !ok {
+			delete(nw.Endpoints, epID)
+		}
+	}
+}
+
+// externalInterfaceFields returns the interface without the entries that are merged separately.
+func externalInterfaceFields(extIf *externalInterface) *externalInterface {
+	fields := *extIf
+	fields.Networks = nil
+	fields.VlanInterfaces = nil
+	return &fields
+}
+
+// networkFields returns the network without the endpoints, which are merged separately.
+func networkFields(nw *network) *network {
+	fields := *nw
+	fields.Endpoints = nil
+	return &fields
+}
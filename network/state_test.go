@@ -0,0 +1,174 @@
+package network
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const testNetworkID = "azure"
+
+// newSharedStoreManager returns a network manager which locks the store in dir only while accessing it, as each
+// CNI process does when endpoints are added in parallel.
+func newSharedStoreManager(tb testing.TB, dir string) *networkManager {
+	tb.Helper()
+
+	lockclient, err := processlock.NewFileLock(filepath.Join(dir, "azure-vnet.json.lock"))
+	if err != nil {
+		tb.Fatalf("failed to create lock: %v", err)
+	}
+
+	kvs, err := store.NewJsonFileStore(filepath.Join(dir, "azure-vnet.json"), lockclient)
+	if err != nil {
+		tb.Fatalf("failed to create store: %v", err)
+	}
+
+	nm := &networkManager{ExternalInterfaces: make(map[string]*externalInterface)}
+	if err := nm.Initialize(&common.PluginConfig{Store: kvs, LockStorePerAccess: true}, false); err != nil {
+		tb.Fatalf("failed to initialize network manager: %v", err)
+	}
+
+	return nm
+}
+
+// addTestEndpoint adds an endpoint to the state of nm and saves it.
+func addTestEndpoint(nm *networkManager, epID string) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	extIf, ok := nm.ExternalInterfaces["eth0"]
+	if !ok {
+		extIf = &externalInterface{Name: "eth0", Networks: make(map[string]*network)}
+		nm.ExternalInterfaces["eth0"] = extIf
+	}
+
+	nw, ok := extIf.Networks[testNetworkID]
+	if !ok {
+		nw = &network{Id: testNetworkID, Endpoints: make(map[string]*endpoint), extIf: extIf}
+		extIf.Networks[testNetworkID] = nw
+	}
+
+	nw.Endpoints[epID] = &endpoint{Id: epID}
+
+	return nm.save()
+}
+
+func TestSaveMergesConcurrentChanges(t *testing.T) {
+	dir := t.TempDir()
+
+	// The network exists before the endpoints are added, as it is created under an exclusive lock.
+	if err := addTestEndpoint(newSharedStoreManager(t, dir), "existing"); err != nil {
+		t.Fatalf("failed to add endpoint: %v", err)
+	}
+
+	managers := make([]*networkManager, 8)
+	for i := range managers {
+		managers[i] = newSharedStoreManager(t, dir)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(managers))
+	for i, nm := range managers {
+		wg.Add(1)
+		go func(i int, nm *networkManager) {
+			defer wg.Done()
+			errs <- addTestEndpoint(nm, fmt.Sprintf("ep%d", i))
+		}(i, nm)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("failed to add endpoint: %v", err)
+		}
+	}
+
+	// Delete one of the endpoints from a manager whose state is outdated.
+	nm := managers[0]
+	nm.Lock()
+	delete(nm.ExternalInterfaces["eth0"].Networks[testNetworkID].Endpoints, "ep0")
+	err := nm.save()
+	nm.Unlock()
+	if err != nil {
+		t.Fatalf("failed to delete endpoint: %v", err)
+	}
+
+	nm = newSharedStoreManager(t, dir)
+	nw := nm.ExternalInterfaces["eth0"].Networks[testNetworkID]
+	if nw.extIf != nm.ExternalInterfaces["eth0"] {
+		t.Errorf("network is not linked to its external interface")
+	}
+
+	if _, ok := nw.Endpoints["ep0"]; ok {
+		t.Errorf("deleted endpoint ep0 was restored")
+	}
+
+	if _, ok := nw.Endpoints["existing"]; !ok {
+		t.Errorf("endpoint existing was lost")
+	}
+
+	for i := 1; i < len(managers); i++ {
+		if _, ok := nw.Endpoints[fmt.Sprintf("ep%d", i)]; !ok {
+			t.Errorf("endpoint ep%d was lost", i)
+		}
+	}
+}
+
+// BenchmarkConcurrentEndpointSave compares adding endpoints in parallel when the store is locked only to save
+// the state with holding the store lock for the whole operation. The sleep stands in for programming the
+// endpoint, which takes far longer than saving the state, and is spent by many ADDs at once.
+func BenchmarkConcurrentEndpointSave(b *testing.B) {
+	const (
+		work        = 20 * time.Millisecond
+		parallelism = 16
+	)
+
+	b.Run("PerEndpoint", func(b *testing.B) {
+		dir := b.TempDir()
+		var n int64
+		b.SetParallelism(parallelism)
+		b.RunParallel(func(pb *testing.PB) {
+			nm := newSharedStoreManager(b, dir)
+			for pb.Next() {
+				time.Sleep(work)
+				if err := addTestEndpoint(nm, fmt.Sprintf("ep%d", atomic.AddInt64(&n, 1))); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+
+	b.Run("GlobalLock", func(b *testing.B) {
+		dir := b.TempDir()
+		var n int64
+		b.SetParallelism(parallelism)
+		b.RunParallel(func(pb *testing.PB) {
+			nm := newSharedStoreManager(b, dir)
+			nm.lockStorePerAccess = false
+			for pb.Next() {
+				if err := nm.store.Lock(store.DefaultLockTimeout); err != nil {
+					b.Error(err)
+					continue
+				}
+				if err := nm.Reload(); err != nil {
+					b.Error(err)
+				}
+				time.Sleep(work)
+				if err := addTestEndpoint(nm, fmt.Sprintf("ep%d", atomic.AddInt64(&n, 1))); err != nil {
+					b.Error(err)
+				}
+				if err := nm.store.Unlock(); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+}
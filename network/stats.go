@@ -0,0 +1,36 @@
+package network
+
+// InterfaceStats contains the traffic counters of a network interface.
+type InterfaceStats struct {
+	RxBytes   uint64
+	TxBytes   uint64
+	RxPackets uint64
+	TxPackets uint64
+	RxDropped uint64
+	TxDropped uint64
+	RxErrors  uint64
+	TxErrors  uint64
+}
+
+// Dropped returns the number of packets dropped in both directions.
+func (s *InterfaceStats) Dropped() uint64 {
+	return s.RxDropped + s.TxDropped
+}
+
+// GetEndpointStats returns the traffic counters of the host side interface of an endpoint.
+func (nm *networkManager) GetEndpointStats(networkID, endpointID string) (*InterfaceStats, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	ep, err := nw.getEndpoint(endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ep.getStatsImpl(nm.netlink)
+}
@@ -0,0 +1,35 @@
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+)
+
+// GetInterfaceStats returns the traffic counters of the interface as reported by netlink.
+func GetInterfaceStats(nl netlink.NetlinkInterface, ifName string) (*InterfaceStats, error) {
+	link, err := nl.GetLink(ifName)
+	if err != nil {
+		return nil, networkutils.NewError("get link", ifName, networkutils.ObjectLink, err)
+	}
+
+	stats := link.Info().Statistics
+	if stats == nil {
+		return &InterfaceStats{}, nil
+	}
+
+	return &InterfaceStats{
+		RxBytes:   stats.RxBytes,
+		TxBytes:   stats.TxBytes,
+		RxPackets: stats.RxPackets,
+		TxPackets: stats.TxPackets,
+		RxDropped: stats.RxDropped,
+		TxDropped: stats.TxDropped,
+		RxErrors:  stats.RxErrors,
+		TxErrors:  stats.TxErrors,
+	}, nil
+}
+
+// getStatsImpl returns the counters of the host veth of the endpoint.
+func (ep *endpoint) getStatsImpl(nl netlink.NetlinkInterface) (*InterfaceStats, error) {
+	return GetInterfaceStats(nl, ep.HostIfName)
+}
@@ -0,0 +1,38 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/stretchr/testify/require"
+)
+
+// statsNetlink returns a link with the given counters.
+type statsNetlink struct {
+	*netlink.MockNetlink
+	stats *netlink.LinkStatistics
+}
+
+func (nl statsNetlink) GetLink(name string) (netlink.Link, error) {
+	return &netlink.LinkInfo{Name: name, Statistics: nl.stats}, nil
+}
+
+func TestGetInterfaceStats(t *testing.T) {
+	nl := statsNetlink{
+		MockNetlink: netlink.NewMockNetlink(false, ""),
+		stats:       &netlink.LinkStatistics{RxBytes: 100, TxBytes: 200, RxDropped: 3, TxDropped: 4, RxErrors: 1},
+	}
+
+	stats, err := GetInterfaceStats(nl, "azv0")
+	require.NoError(t, err)
+	require.Equal(t, &InterfaceStats{RxBytes: 100, TxBytes: 200, RxDropped: 3, TxDropped: 4, RxErrors: 1}, stats)
+	require.Equal(t, uint64(7), stats.Dropped())
+
+	nl.stats = nil
+	stats, err = GetInterfaceStats(nl, "azv0")
+	require.NoError(t, err)
+	require.Equal(t, &InterfaceStats{}, stats, "links without counters report zero")
+
+	_, err = GetInterfaceStats(netlink.NewMockNetlink(true, ""), "azv0")
+	require.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Microsoft/hcsshim"
+	"github.com/pkg/errors"
+)
+
+// GetInterfaceStats returns the traffic counters of the HNS endpoint with the given name, HNS endpoints
+// are the interfaces of the containers on Windows. HNS does not report error counters, those are always zero.
+func GetInterfaceStats(_ netlink.NetlinkInterface, ifName string) (*InterfaceStats, error) {
+	hnsEndpoint, err := hcsshim.GetHNSEndpointByName(ifName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get hns endpoint %s", ifName)
+	}
+
+	stats, err := hcsshim.GetHNSEndpointStats(hnsEndpoint.Id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get stats of hns endpoint %s", ifName)
+	}
+
+	return &InterfaceStats{
+		RxBytes:   stats.BytesReceived,
+		TxBytes:   stats.BytesSent,
+		RxPackets: stats.PacketsReceived,
+		TxPackets: stats.PacketsSent,
+		RxDropped: stats.DroppedPacketsIncoming,
+		TxDropped: stats.DroppedPacketsOutgoing,
+	}, nil
+}
+
+// getStatsImpl returns the counters of the HNS endpoint, which is named after the endpoint.
+func (ep *endpoint) getStatsImpl(nl netlink.NetlinkInterface) (*InterfaceStats, error) {
+	return GetInterfaceStats(nl, ep.Id)
+}
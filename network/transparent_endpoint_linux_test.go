@@ -5,6 +5,7 @@ package network
 
 import (
 	"net"
+	"syscall"
 	"testing"
 
 	"github.com/Azure/azure-container-networking/netio"
@@ -389,3 +390,11 @@ func TestTransConfigureContainerInterfacesAndRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestNewErrorTransparentEndpointClient(t *testing.T) {
+	err := newErrorTransparentEndpointClient(&netlink.Error{Errno: syscall.EEXIST, Link: "azv1"})
+	require.ErrorIs(t, err, errorTransparentEndpointClient)
+	require.ErrorIs(t, err, netlink.ErrExists)
+	require.ErrorIs(t, err, networkutils.ErrLinkExists)
+	require.Contains(t, err.Error(), "TransparentEndpointClient Error : ")
+}
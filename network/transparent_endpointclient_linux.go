@@ -27,8 +27,26 @@ const (
 
 var errorTransparentEndpointClient = errors.New("TransparentEndpointClient Error")
 
-func newErrorTransparentEndpointClient(errStr string) error {
-	return fmt.Errorf("%w : %s", errorTransparentEndpointClient, errStr)
+// transparentEndpointClientError matches both errorTransparentEndpointClient and the error it wraps
+// with errors.Is, which a single fmt.Errorf can't do.
+type transparentEndpointClientError struct {
+	err error
+}
+
+func (e *transparentEndpointClientError) Error() string {
+	return fmt.Sprintf("%v : %v", errorTransparentEndpointClient, e.err)
+}
+
+func (e *transparentEndpointClientError) Unwrap() error {
+	return e.err
+}
+
+func (e *transparentEndpointClientError) Is(target error) bool {
+	return target == errorTransparentEndpointClient
+}
+
+func newErrorTransparentEndpointClient(err error) error {
+	return &transparentEndpointClientError{err: err}
 }
 
 type TransparentEndpointClient struct {
@@ -79,15 +97,16 @@ func (client *TransparentEndpointClient) setArpProxy(ifName string) error {
 func (client *TransparentEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
 	if _, err := client.netioshim.GetNetworkInterfaceByName(client.hostVethName); err == nil {
 		log.Printf("Deleting old host veth %v", client.hostVethName)
-		if err = client.netlink.DeleteLink(client.hostVethName); err != nil {
+		// the old veth is gone if its pod's namespace was deleted meanwhile
+		if err = client.netlink.DeleteLink(client.hostVethName); err != nil && !errors.Is(err, netlink.ErrNoSuchDevice) {
 			log.Printf("[net] Failed to delete old hostveth %v: %v.", client.hostVethName, err)
-			return newErrorTransparentEndpointClient(err.Error())
+			return newErrorTransparentEndpointClient(err)
 		}
 	}
 
 	primaryIf, err := client.netioshim.GetNetworkInterfaceByName(client.hostPrimaryIfName)
 	if err != nil {
-		return newErrorTransparentEndpointClient(err.Error())
+		return newErrorTransparentEndpointClient(err)
 	}
 
 	mac, err := net.ParseMAC(defaultHostVethHwAddr)
@@ -96,7 +115,7 @@ func (client *TransparentEndpointClient) AddEndpoints(epInfo *EndpointInfo) erro
 	}
 
 	if err = client.netUtilsClient.CreateEndpoint(client.hostVethName, client.containerVethName, mac); err != nil {
-		return newErrorTransparentEndpointClient(err.Error())
+		return newErrorTransparentEndpointClient(err)
 	}
 
 	defer func() {
@@ -109,14 +128,14 @@ func (client *TransparentEndpointClient) AddEndpoints(epInfo *EndpointInfo) erro
 
 	containerIf, err := client.netioshim.GetNetworkInterfaceByName(client.containerVethName)
 	if err != nil {
-		return newErrorTransparentEndpointClient(err.Error())
+		return newErrorTransparentEndpointClient(err)
 	}
 
 	client.containerMac = containerIf.HardwareAddr
 
 	hostVethIf, err := client.netioshim.GetNetworkInterfaceByName(client.hostVethName)
 	if err != nil {
-		return newErrorTransparentEndpointClient(err.Error())
+		return newErrorTransparentEndpointClient(err)
 	}
 
 	client.hostVethMac = hostVethIf.HardwareAddr
@@ -153,7 +172,7 @@ func (client *TransparentEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 		routeInfo.Dst = ipNet
 		routeInfoList = append(routeInfoList, routeInfo)
 		if err := addRoutes(client.netlink, client.netioshim, client.hostVethName, routeInfoList); err != nil {
-			return newErrorTransparentEndpointClient(err.Error())
+			return newErrorTransparentEndpointClient(err)
 		}
 	}
 
@@ -163,6 +182,10 @@ func (client *TransparentEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 		return err
 	}
 
+	if err := setBandwidthLimits(client.netlink, client.hostVethName, epInfo.BandwidthLimits); err != nil {
+		return newErrorTransparentEndpointClient(err)
+	}
+
 	return nil
 }
 
@@ -193,7 +216,8 @@ func (client *TransparentEndpointClient) MoveEndpointsToContainerNS(epInfo *Endp
 	// Move the container interface to container's network namespace.
 	log.Printf("[net] Setting link %v netns %v.", client.containerVethName, epInfo.NetNsPath)
 	if err := client.netlink.SetLinkNetNs(client.containerVethName, nsID); err != nil {
-		return newErrorTransparentEndpointClient(err.Error())
+		return newErrorTransparentEndpointClient(
+			networkutils.NewError("set link netns", client.containerVethName, networkutils.ObjectNamespace, err))
 	}
 
 	return nil
@@ -210,8 +234,8 @@ func (client *TransparentEndpointClient) SetupContainerInterfaces(epInfo *Endpoi
 }
 
 func (client *TransparentEndpointClient) ConfigureContainerInterfacesAndRoutes(epInfo *EndpointInfo) error {
-	if err := client.netUtilsClient.AssignIPToInterface(client.containerVethName, epInfo.IPAddresses); err != nil {
-		return newErrorTransparentEndpointClient(err.Error())
+	if err := assignIPAddresses(client.netUtilsClient, client.containerVethName, epInfo); err != nil {
+		return newErrorTransparentEndpointClient(err)
 	}
 
 	// ip route del 10.240.0.0/12 dev eth0 (removing kernel subnet route added by above call)
@@ -223,7 +247,7 @@ func (client *TransparentEndpointClient) ConfigureContainerInterfacesAndRoutes(e
 			Protocol: netlink.RTPROT_KERNEL,
 		}
 		if err := deleteRoutes(client.netlink, client.netioshim, client.containerVethName, []RouteInfo{routeInfo}); err != nil {
-			return newErrorTransparentEndpointClient(err.Error())
+			return newErrorTransparentEndpointClient(err)
 		}
 	}
 
@@ -235,15 +259,18 @@ func (client *TransparentEndpointClient) ConfigureContainerInterfacesAndRoutes(e
 		Scope: netlink.RT_SCOPE_LINK,
 	}
 	if err := addRoutes(client.netlink, client.netioshim, client.containerVethName, []RouteInfo{routeInfo}); err != nil {
-		return newErrorTransparentEndpointClient(err.Error())
+		return newErrorTransparentEndpointClient(err)
 	}
 
-	// ip route add default via 169.254.1.1 dev eth0
+	// ip route add default via 169.254.1.1 dev eth0 onlink
+	// onlink keeps the kernel from rejecting the route when the virtual gateway is in none of the subnets of the
+	// link, as on pods with interfaces on several NICs.
 	_, defaultIPNet, _ := net.ParseCIDR(defaultGwCidr)
 	dstIP := net.IPNet{IP: net.ParseIP(defaultGw), Mask: defaultIPNet.Mask}
 	routeInfo = RouteInfo{
-		Dst: dstIP,
-		Gw:  virtualGwIP,
+		Dst:    dstIP,
+		Gw:     virtualGwIP,
+		OnLink: true,
 	}
 	if err := addRoutes(client.netlink, client.netioshim, client.containerVethName, []RouteInfo{routeInfo}); err != nil {
 		return err
@@ -258,7 +285,7 @@ func (client *TransparentEndpointClient) ConfigureContainerInterfacesAndRoutes(e
 		MacAddress: client.hostVethMac,
 	}
 
-	if err := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PROBE); err != nil {
+	if err := addNeighbor(client.netlink, linkInfo, netlink.NUD_PROBE); err != nil {
 		return fmt.Errorf("Adding arp in container failed: %w", err)
 	}
 
@@ -269,7 +296,9 @@ func (client *TransparentEndpointClient) ConfigureContainerInterfacesAndRoutes(e
 	}
 
 	if epInfo.IPV6Mode != "" {
-		return client.setIPV6NeighEntry()
+		if err := client.setIPV6NeighEntry(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -286,12 +315,13 @@ func (client *TransparentEndpointClient) setupIPV6Routes() error {
 		Scope: netlink.RT_SCOPE_LINK,
 	}
 
-	// ip -6 route add default via fe80::1234:5678:9abc dev eth0
+	// ip -6 route add default via fe80::1234:5678:9abc dev eth0 onlink
 	_, defaultIPNet, _ := net.ParseCIDR(defaultv6Cidr)
 	log.Printf("defaultv6ipnet :%+v", defaultIPNet)
 	defaultRoute := RouteInfo{
-		Dst: *defaultIPNet,
-		Gw:  virtualGwIP,
+		Dst:    *defaultIPNet,
+		Gw:     virtualGwIP,
+		OnLink: true,
 	}
 
 	return addRoutes(client.netlink, client.netioshim, client.containerVethName, []RouteInfo{gwRoute, defaultRoute})
@@ -306,7 +336,7 @@ func (client *TransparentEndpointClient) setIPV6NeighEntry() error {
 		MacAddress: client.hostVethMac,
 	}
 
-	if err := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT); err != nil {
+	if err := addNeighbor(client.netlink, linkInfo, netlink.NUD_PERMANENT); err != nil {
 		log.Printf("Failed setting neigh entry in container: %+v", err)
 		return fmt.Errorf("Failed setting neigh entry in container: %w", err)
 	}
@@ -315,5 +345,9 @@ func (client *TransparentEndpointClient) setIPV6NeighEntry() error {
 }
 
 func (client *TransparentEndpointClient) DeleteEndpoints(ep *endpoint) error {
+	if err := deleteBandwidthLimits(client.netlink, ep.HostIfName); err != nil {
+		return newErrorTransparentEndpointClient(err)
+	}
+
 	return nil
 }
@@ -20,6 +20,7 @@ func (client *TransparentVlanEndpointClient) NewSnatClient(snatBridgeIP, localIP
 			client.netlink,
 			client.plClient,
 		)
+		client.snatClient.Family = snatFamily(epInfo)
 	}
 }
 
@@ -3,6 +3,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/iptables"
@@ -26,13 +27,6 @@ const (
 	DisableRPFilterCmd = "sysctl -w net.ipv4.conf.all.rp_filter=0" // Command to disable the rp filter for tunneling
 )
 
-type netnsClient interface {
-	Get() (fileDescriptor int, err error)
-	GetFromName(name string) (fileDescriptor int, err error)
-	Set(fileDescriptor int) (err error)
-	NewNamed(name string) (fileDescriptor int, err error)
-	DeleteNamed(name string) (err error)
-}
 type TransparentVlanEndpointClient struct {
 	primaryHostIfName string // So like eth0
 	vlanIfName        string // So like eth0.1
@@ -52,7 +46,7 @@ type TransparentVlanEndpointClient struct {
 	allowInboundFromHostToNC bool
 	allowInboundFromNCToHost bool
 	enableSnatForDNS         bool
-	netnsClient              netnsClient
+	netnsClient              netns.Interface
 	netlink                  netlink.NetlinkInterface
 	netioshim                netio.NetIOInterface
 	plClient                 platform.ExecClient
@@ -107,7 +101,7 @@ func (client *TransparentVlanEndpointClient) AddEndpoints(epInfo *EndpointInfo)
 		return errors.Wrap(err, "failed to add snat endpoint")
 	}
 	// VNET Namespace
-	return ExecuteInNS(client.vnetNSName, func() error {
+	return client.executeInVnetNS(func() error {
 		return client.PopulateVnet(epInfo)
 	})
 }
@@ -118,6 +112,7 @@ func (client *TransparentVlanEndpointClient) PopulateVM(epInfo *EndpointInfo) er
 	if err != nil {
 		return errors.Wrap(err, "failed to get vm ns handle")
 	}
+	defer client.closeNS(vmNS)
 
 	log.Printf("[transparent vlan] Checking if NS exists...")
 	vnetNS, existingErr := client.netnsClient.GetFromName(client.vnetNSName)
@@ -130,6 +125,7 @@ func (client *TransparentVlanEndpointClient) PopulateVM(epInfo *EndpointInfo) er
 		if err != nil {
 			return errors.Wrap(err, "failed to create vnet ns")
 		}
+		defer client.closeNS(vnetNS)
 		client.vnetNSFileDescriptor = vnetNS
 		deleteNSIfNotNilErr := client.netnsClient.Set(vmNS)
 		// Any failure will trigger removing the namespace created
@@ -153,17 +149,17 @@ func (client *TransparentVlanEndpointClient) PopulateVM(epInfo *EndpointInfo) er
 		if deleteNSIfNotNilErr != nil {
 			return errors.Wrap(deleteNSIfNotNilErr, "failed to get eth0 interface")
 		}
-		linkAttrs := vishnetlink.NewLinkAttrs()
-		linkAttrs.Name = client.vlanIfName
-		// Set the peer
-		linkAttrs.ParentIndex = eth0.Index
-		link := &vishnetlink.Vlan{
-			LinkAttrs: linkAttrs,
-			VlanId:    client.vlanID,
+		link := &netlink.VlanLink{
+			LinkInfo: netlink.LinkInfo{
+				Type:        netlink.LINK_TYPE_VLAN,
+				Name:        client.vlanIfName,
+				ParentIndex: eth0.Index,
+			},
+			VlanID: uint16(client.vlanID),
 		}
 		log.Printf("[transparent vlan] Attempting to create %s link in VM NS", client.vlanIfName)
 		// Create vlan veth
-		deleteNSIfNotNilErr = vishnetlink.LinkAdd(link)
+		deleteNSIfNotNilErr = client.netlink.AddLink(link)
 		if deleteNSIfNotNilErr != nil {
 			// Any failure to add the link should error (auto delete NS)
 			return errors.Wrap(deleteNSIfNotNilErr, "failed to create vlan vnet link after making new ns")
@@ -188,6 +184,7 @@ func (client *TransparentVlanEndpointClient) PopulateVM(epInfo *EndpointInfo) er
 		}
 	} else {
 		log.Printf("[transparent vlan] Existing NS (%s) detected. Assuming %s exists too", client.vnetNSName, client.vlanIfName)
+		defer client.closeNS(vnetNS)
 	}
 	client.vnetNSFileDescriptor = vnetNS
 
@@ -252,7 +249,7 @@ func (client *TransparentVlanEndpointClient) AddEndpointRules(epInfo *EndpointIn
 		return errors.Wrap(err, "failed to add snat endpoint rules")
 	}
 	log.Printf("[transparent vlan] Adding tunneling rules in vnet namespace")
-	err := ExecuteInNS(client.vnetNSName, func() error {
+	err := client.executeInVnetNS(func() error {
 		return client.AddVnetRules(epInfo)
 	})
 	return err
@@ -261,13 +258,24 @@ func (client *TransparentVlanEndpointClient) AddEndpointRules(epInfo *EndpointIn
 // Add rules related to tunneling the packet outside of the VM, assumes all calls are idempotent. Namespace: vnet
 func (client *TransparentVlanEndpointClient) AddVnetRules(epInfo *EndpointInfo) error {
 	// iptables -t mangle -I PREROUTING -j MARK --set-mark <TUNNELING MARK>
-	markOption := fmt.Sprintf("MARK --set-mark %d", tunnelingMark)
-	if err := iptables.InsertIptableRule(iptables.V4, "mangle", "PREROUTING", "", markOption); err != nil {
+	markRule := &iptables.Rule{
+		Version: iptables.V4,
+		Table:   iptables.Mangle,
+		Chain:   iptables.Prerouting,
+		Target:  iptables.Jump("MARK", "--set-mark", strconv.Itoa(tunnelingMark)),
+	}
+	if err := iptables.EnsureRule(markRule, iptables.Insert); err != nil {
 		return errors.Wrap(err, "unable to insert iptables rule mark all packets not entering on vlan interface")
 	}
 	// iptables -t mangle -I PREROUTING -j ACCEPT -i <VLAN IF>
-	match := fmt.Sprintf("-i %s", client.vlanIfName)
-	if err := iptables.InsertIptableRule(iptables.V4, "mangle", "PREROUTING", match, "ACCEPT"); err != nil {
+	acceptRule := &iptables.Rule{
+		Version: iptables.V4,
+		Table:   iptables.Mangle,
+		Chain:   iptables.Prerouting,
+		Matches: []iptables.Match{iptables.MatchInInterface(client.vlanIfName)},
+		Target:  iptables.Jump(iptables.Accept),
+	}
+	if err := iptables.EnsureRule(acceptRule, iptables.Insert); err != nil {
 		return errors.Wrap(err, "unable to insert iptables rule accept all incoming from vlan interface")
 	}
 	// Packets that are marked should go to the tunneling table
@@ -291,6 +299,10 @@ func (client *TransparentVlanEndpointClient) AddVnetRules(epInfo *EndpointInfo)
 		}
 	}
 
+	if err := setBandwidthLimits(client.netlink, client.vnetVethName, epInfo.BandwidthLimits); err != nil {
+		return errors.Wrap(err, "failed to set bandwidth limits")
+	}
+
 	return nil
 }
 
@@ -300,7 +312,8 @@ func (client *TransparentVlanEndpointClient) DeleteEndpointRules(ep *endpoint) {
 
 func (client *TransparentVlanEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
 	if err := client.netlink.SetLinkNetNs(client.containerVethName, nsID); err != nil {
-		return errors.Wrap(err, "failed to move endpoint to container ns")
+		return errors.Wrap(networkutils.NewError("set link netns", client.containerVethName, networkutils.ObjectNamespace, err),
+			"failed to move endpoint to container ns")
 	}
 	if err := client.MoveSnatEndpointToContainerNS(epInfo.NetNsPath, nsID); err != nil {
 		return errors.Wrap(err, "failed to move snat endpoint to container ns")
@@ -329,7 +342,7 @@ func (client *TransparentVlanEndpointClient) ConfigureContainerInterfacesAndRout
 	}
 
 	// Switch to vnet NS and call ConfigureVnetInterfacesAndRoutes
-	err = ExecuteInNS(client.vnetNSName, func() error {
+	err = client.executeInVnetNS(func() error {
 		return client.ConfigureVnetInterfacesAndRoutesImpl(epInfo)
 	})
 	if err != nil {
@@ -345,7 +358,7 @@ func (client *TransparentVlanEndpointClient) ConfigureContainerInterfacesAndRout
 
 // Called from ConfigureContainerInterfacesAndRoutes, Namespace: Container
 func (client *TransparentVlanEndpointClient) ConfigureContainerInterfacesAndRoutesImpl(epInfo *EndpointInfo) error {
-	if err := client.netUtilsClient.AssignIPToInterface(client.containerVethName, epInfo.IPAddresses); err != nil {
+	if err := assignIPAddresses(client.netUtilsClient, client.containerVethName, epInfo); err != nil {
 		return errors.Wrap(err, "failed to assign ips to container veth interface")
 	}
 	// kernel subnet route auto added by above call must be removed
@@ -468,7 +481,7 @@ func (client *TransparentVlanEndpointClient) AddDefaultArp(interfaceName, destMa
 		MacAddress: hardwareAddr,
 	}
 
-	if err := client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT); err != nil {
+	if err := addNeighbor(client.netlink, linkInfo, netlink.NUD_PERMANENT); err != nil {
 		return fmt.Errorf("adding arp entry failed: %w", err)
 	}
 	return nil
@@ -476,7 +489,7 @@ func (client *TransparentVlanEndpointClient) AddDefaultArp(interfaceName, destMa
 
 func (client *TransparentVlanEndpointClient) DeleteEndpoints(ep *endpoint) error {
 	// Vnet NS
-	err := ExecuteInNS(client.vnetNSName, func() error {
+	err := client.executeInVnetNS(func() error {
 		// Passing in functionality to get number of routes after deletion
 		getNumRoutesLeft := func() (int, error) {
 			routes, err := vishnetlink.RouteList(nil, vishnetlink.FAMILY_V4)
@@ -506,6 +519,10 @@ func (client *TransparentVlanEndpointClient) DeleteEndpointsImpl(ep *endpoint, g
 		return errors.Wrap(err, "failed to remove routes")
 	}
 
+	if err := deleteBandwidthLimits(client.netlink, client.vnetVethName); err != nil {
+		return errors.Wrap(err, "failed to delete bandwidth limits")
+	}
+
 	routesLeft, err := getNumRoutesLeft()
 	if err != nil {
 		return err
@@ -525,42 +542,21 @@ func (client *TransparentVlanEndpointClient) DeleteEndpointsImpl(ep *endpoint, g
 	return nil
 }
 
-// Helper function that allows executing a function in a VM namespace
-// Does not work for process namespaces
-func ExecuteInNS(nsName string, f func() error) error {
-	// Current namespace
-	returnedTo, err := GetCurrentThreadNamespace()
+// executeInVnetNS runs f with the calling goroutine in the vnet namespace.
+func (client *TransparentVlanEndpointClient) executeInVnetNS(f func() error) error {
+	vnetNS, err := client.netnsClient.GetFromName(client.vnetNSName)
 	if err != nil {
-		log.Errorf("[ExecuteInNS] Could not get NS we are in: %v", err)
-	} else {
-		log.Printf("[ExecuteInNS] In NS before switch: %s", returnedTo.file.Name())
+		return errors.Wrapf(err, "failed to open vnet ns %s", client.vnetNSName)
 	}
+	defer client.closeNS(vnetNS)
 
-	// Open the network namespace
-	log.Printf("[ExecuteInNS] Opening ns %v.", fmt.Sprintf("/var/run/netns/%s", nsName))
-	ns, err := OpenNamespace(fmt.Sprintf("/var/run/netns/%s", nsName))
-	if err != nil {
-		return err
-	}
-	defer ns.Close()
-	// Enter the network namespace
-	log.Printf("[ExecuteInNS] Entering ns %s.", ns.file.Name())
-	if err := ns.Enter(); err != nil {
-		return err
-	}
+	log.Printf("[transparent vlan] Entering vnet ns %s.", client.vnetNSName)
+	return client.netnsClient.ExecuteInNS(vnetNS, f) //nolint:wrapcheck // errors of f are returned as is
+}
 
-	// Exit network namespace
-	defer func() {
-		log.Printf("[ExecuteInNS] Exiting ns %s.", ns.file.Name())
-		if err := ns.Exit(); err != nil {
-			log.Errorf("[ExecuteInNS] Could not exit ns, err:%v.", err)
-		}
-		returnedTo, err := GetCurrentThreadNamespace()
-		if err != nil {
-			log.Errorf("[ExecuteInNS] Could not get NS we returned to: %v", err)
-		} else {
-			log.Printf("[ExecuteInNS] Returned to NS: %s", returnedTo.file.Name())
-		}
-	}()
-	return f()
+// closeNS releases a namespace handle.
+func (client *TransparentVlanEndpointClient) closeNS(fileDescriptor int) {
+	if err := client.netnsClient.Close(fileDescriptor); err != nil {
+		log.Errorf("[transparent vlan] Failed to close ns handle %d: %v", fileDescriptor, err)
+	}
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/netns"
 	"github.com/Azure/azure-container-networking/network/networkutils"
 	"github.com/Azure/azure-container-networking/platform"
 	"github.com/pkg/errors"
@@ -49,6 +50,22 @@ func (netns *mockNetns) DeleteNamed(name string) (err error) {
 	return netns.deleteNamed(name)
 }
 
+func (netns *mockNetns) GetFromPath(path string) (fileDescriptor int, err error) {
+	return netns.getFromName(path)
+}
+
+func (netns *mockNetns) GetFromPid(int) (fileDescriptor int, err error) {
+	return netns.get()
+}
+
+func (netns *mockNetns) Close(int) (err error) {
+	return nil
+}
+
+func (netns *mockNetns) ExecuteInNS(_ int, f func() error) (err error) {
+	return f()
+}
+
 func defaultGet() (int, error) {
 	return 1, nil
 }
@@ -107,6 +124,32 @@ func TestTransparentVlanAddEndpoints(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: "failed to create vnet ns: netns failure: " + errNetnsMock.Error(),
 		},
+		{
+			name: "Add endpoints create vnet ns vlan link fail",
+			client: &TransparentVlanEndpointClient{
+				primaryHostIfName: "eth0",
+				vlanIfName:        "eth0.1",
+				vnetVethName:      "A1veth0",
+				containerVethName: "B1veth0",
+				vnetNSName:        "az_ns_1",
+				netnsClient: &mockNetns{
+					get: defaultGet,
+					getFromName: func(name string) (fileDescriptor int, err error) {
+						return 0, newNetnsErrorMock("netns failure")
+					},
+					newNamed:    defaultNewNamed,
+					set:         defaultSet,
+					deleteNamed: defaultDeleteNamed,
+				},
+				netlink:        netlink.NewMockNetlink(true, "netlink fail"),
+				plClient:       platform.NewMockExecClient(false),
+				netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
+				netioshim:      netio.NewMockNetIO(false, 0),
+			},
+			epInfo:     &EndpointInfo{},
+			wantErr:    true,
+			wantErrMsg: "failed to create vlan vnet link after making new ns: " + netlink.ErrorMockNetlink.Error() + " : netlink fail",
+		},
 		{
 			name: "Add endpoints with existing vnet ns",
 			client: &TransparentVlanEndpointClient{
@@ -360,6 +403,71 @@ func TestTransparentVlanAddEndpoints(t *testing.T) {
 	}
 }
 
+func TestTransparentVlanAddEndpointsClosesNetns(t *testing.T) {
+	nl := netlink.NewMockNetlink(false, "")
+	plc := platform.NewMockExecClient(false)
+
+	tests := []struct {
+		name       string
+		existingNS bool
+		netlink    netlink.NetlinkInterface
+		wantErr    bool
+	}{
+		{
+			name: "Add endpoints new vnet ns",
+		},
+		{
+			name:       "Add endpoints existing vnet ns",
+			existingNS: true,
+		},
+		{
+			name:    "Add endpoints new vnet ns vlan link fail",
+			netlink: netlink.NewMockNetlink(true, "netlink fail"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fakeNetns := netns.NewFake()
+			if tt.existingNS {
+				fakeNetns.AddNamed("az_ns_1")
+			}
+			if tt.netlink == nil {
+				tt.netlink = netlink.NewMockNetlink(false, "")
+			}
+			client := &TransparentVlanEndpointClient{
+				primaryHostIfName: "eth0",
+				vlanIfName:        "eth0.1",
+				vnetVethName:      "A1veth0",
+				containerVethName: "B1veth0",
+				vnetNSName:        "az_ns_1",
+				netnsClient:       fakeNetns,
+				netlink:           tt.netlink,
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+				netioshim:         netio.NewMockNetIO(false, 0),
+			}
+
+			err := client.PopulateVM(&EndpointInfo{})
+			if tt.wantErr {
+				require.Error(t, err)
+				require.False(t, fakeNetns.HasNamed("az_ns_1"), "vnet ns should be deleted on failure")
+			} else {
+				require.NoError(t, err)
+				err = client.executeInVnetNS(func() error {
+					require.Equal(t, "az_ns_1", fakeNetns.Current())
+					return nil
+				})
+				require.NoError(t, err)
+			}
+			require.Equal(t, "host", fakeNetns.Current())
+			require.Zero(t, fakeNetns.OpenHandles(), "all ns handles should be closed")
+		})
+	}
+}
+
 func TestTransparentVlanDeleteEndpoints(t *testing.T) {
 	nl := netlink.NewMockNetlink(false, "")
 	plc := platform.NewMockExecClient(false)
@@ -0,0 +1,195 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+)
+
+const (
+	minVlanID = 1
+	maxVlanID = 4094
+
+	// Prefix for the bridge names of bridge-vlan networks, followed by the VLAN ID.
+	vlanBridgePrefix = "azvlan"
+)
+
+// getVlanInterfaceName returns the conventional name of a VLAN sub-interface, e.g. eth0.100.
+func getVlanInterfaceName(ifName string, vlanID int) string {
+	return fmt.Sprintf("%s.%d", ifName, vlanID)
+}
+
+// addVlanInterfaceImpl creates the VLAN sub-interface of the external interface and records it in the
+// interface state. An existing sub-interface is reused so that repeated plugin invocations are idempotent.
+func (nm *networkManager) addVlanInterfaceImpl(extIf *externalInterface, vlanID int) (*vlanInterface, error) {
+	if vlanID < minVlanID || vlanID > maxVlanID {
+		return nil, errVlanIDInvalid
+	}
+
+	name := getVlanInterfaceName(extIf.Name, vlanID)
+	if vlanIf := extIf.VlanInterfaces[vlanID]; vlanIf != nil {
+		if _, err := nm.netio.GetNetworkInterfaceByName(vlanIf.Name); err == nil {
+			log.Printf("[net] VLAN sub-interface %v already exists.", vlanIf.Name)
+			return vlanIf, nil
+		}
+
+		log.Printf("[net] VLAN sub-interface %v is recorded but missing, recreating it.", vlanIf.Name)
+	}
+
+	parentIf, err := nm.netio.GetNetworkInterfaceByName(extIf.Name)
+	if err != nil {
+		return nil, networkutils.NewError("get interface", extIf.Name, networkutils.ObjectLink, err)
+	}
+
+	log.Printf("[net] Creating VLAN sub-interface %v with mtu %d.", name, parentIf.MTU)
+	link := netlink.VlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type:        netlink.LINK_TYPE_VLAN,
+			Name:        name,
+			MTU:         uint(parentIf.MTU),
+			ParentIndex: parentIf.Index,
+		},
+		VlanID: uint16(vlanID),
+	}
+
+	created := true
+	if err = nm.netlink.AddLink(&link); err != nil {
+		err = networkutils.NewError("create vlan interface", name, networkutils.ObjectLink, err)
+		if !networkutils.IsAlreadyExists(err) {
+			return nil, err
+		}
+
+		// Only adopt the interface if it is the VLAN of the parent, not a link that happens to share its name.
+		existing, err := nm.netlink.GetLink(name)
+		if err != nil {
+			return nil, networkutils.NewError("get vlan interface", name, networkutils.ObjectLink, err)
+		}
+
+		if vlan, ok := existing.(*netlink.VlanLink); !ok || vlan.VlanID != uint16(vlanID) || vlan.ParentIndex != parentIf.Index {
+			return nil, errVlanInterfaceMismatch
+		}
+
+		log.Printf("[net] VLAN sub-interface %v already exists, adopting it.", name)
+		created = false
+	}
+
+	if err = nm.netlink.SetLinkState(name, true); err != nil {
+		// Only remove the sub-interface if it was created here, an adopted one was set up by someone else.
+		if created {
+			if delErr := nm.netlink.DeleteLink(name); delErr != nil {
+				log.Errorf("[net] Failed to delete VLAN sub-interface %v: %v", name, delErr)
+			}
+		}
+		return nil, networkutils.NewError("set link state", name, networkutils.ObjectLink, err)
+	}
+
+	vlanIf := &vlanInterface{
+		Name:    name,
+		VlanID:  vlanID,
+		MTU:     parentIf.MTU,
+		Created: created,
+	}
+
+	if extIf.VlanInterfaces == nil {
+		extIf.VlanInterfaces = make(map[int]*vlanInterface)
+	}
+	extIf.VlanInterfaces[vlanID] = vlanIf
+
+	return vlanIf, nil
+}
+
+// deleteVlanInterfaceImpl deletes the VLAN sub-interface and removes it from the interface state.
+// A sub-interface which was adopted rather than created is only removed from the state.
+func (nm *networkManager) deleteVlanInterfaceImpl(extIf *externalInterface, vlanID int) error {
+	vlanIf := extIf.VlanInterfaces[vlanID]
+	if vlanIf == nil {
+		return nil
+	}
+
+	name := vlanIf.Name
+	if !vlanIf.Created {
+		log.Printf("[net] Leaving adopted VLAN sub-interface %v in place.", name)
+		delete(extIf.VlanInterfaces, vlanID)
+		return nil
+	}
+
+	log.Printf("[net] Deleting VLAN sub-interface %v.", name)
+	if err := nm.netlink.DeleteLink(name); err != nil {
+		return networkutils.NewError("delete vlan interface", name, networkutils.ObjectLink, err)
+	}
+
+	delete(extIf.VlanInterfaces, vlanID)
+
+	return nil
+}
+
+// getVlanBridgeName returns the name of the bridge of a bridge-vlan network, e.g. azvlan100.
+func getVlanBridgeName(vlanID int) string {
+	return fmt.Sprintf("%s%d", vlanBridgePrefix, vlanID)
+}
+
+// connectVlanBridge creates the VLAN sub-interface of the external interface and a bridge with the
+// sub-interface as its uplink, and returns the name of the bridge. Existing links are reused so that
+// repeated plugin invocations are idempotent.
+func (nm *networkManager) connectVlanBridge(extIf *externalInterface, vlanID int) (string, error) {
+	vlanIf, err := nm.addVlanInterfaceImpl(extIf, vlanID)
+	if err != nil {
+		return "", err
+	}
+
+	bridgeName := getVlanBridgeName(vlanID)
+	log.Printf("[net] Creating bridge %v for VLAN sub-interface %v.", bridgeName, vlanIf.Name)
+	bridge := netlink.BridgeLink{
+		LinkInfo: netlink.LinkInfo{
+			Type: netlink.LINK_TYPE_BRIDGE,
+			Name: bridgeName,
+			MTU:  uint(vlanIf.MTU),
+		},
+	}
+
+	if err = nm.netlink.AddLink(&bridge); err != nil {
+		err = networkutils.NewError("create bridge", bridgeName, networkutils.ObjectLink, err)
+		if !networkutils.IsAlreadyExists(err) {
+			nm.releaseVlanInterface(extIf, vlanID)
+			return "", err
+		}
+	}
+
+	if err = nm.netlink.SetLinkMaster(vlanIf.Name, bridgeName); err != nil {
+		return "", networkutils.NewError("set link master", vlanIf.Name, networkutils.ObjectLink, err)
+	}
+
+	if err = nm.netlink.SetLinkState(bridgeName, true); err != nil {
+		return "", networkutils.NewError("set link state", bridgeName, networkutils.ObjectLink, err)
+	}
+
+	return bridgeName, nil
+}
+
+// disconnectVlanBridge deletes the bridge of a bridge-vlan network. The VLAN sub-interface is released
+// separately once the network is removed from the state.
+func (nm *networkManager) disconnectVlanBridge(nw *network) error {
+	log.Printf("[net] Deleting bridge %v of VLAN %d.", nw.BridgeName, nw.VlanId)
+	if err := nm.netlink.DeleteLink(nw.BridgeName); err != nil && !networkutils.IsNotFound(err) {
+		return networkutils.NewError("delete bridge", nw.BridgeName, networkutils.ObjectLink, err)
+	}
+
+	return nil
+}
+
+// vlanBridgeInterface returns the view of the external interface used by the endpoint clients of a
+// bridge-vlan network: the VLAN sub-interface connected to the network's bridge.
+func (nw *network) vlanBridgeInterface() *externalInterface {
+	name := getVlanInterfaceName(nw.extIf.Name, nw.VlanId)
+	if vlanIf := nw.extIf.VlanInterfaces[nw.VlanId]; vlanIf != nil {
+		name = vlanIf.Name
+	}
+
+	return &externalInterface{
+		Name:       name,
+		BridgeName: nw.BridgeName,
+		MacAddress: nw.extIf.MacAddress,
+	}
+}
@@ -0,0 +1,130 @@
+package network
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/stretchr/testify/require"
+)
+
+var errSetLinkState = errors.New("set link state failed")
+
+// vlanNetlink keeps the links in memory and fails setting the link state if failSetState is set.
+type vlanNetlink struct {
+	*netlink.MockNetlink
+	links        map[string]netlink.Link
+	masters      map[string]string
+	deleted      []string
+	failSetState bool
+}
+
+func newVlanNetlink() *vlanNetlink {
+	return &vlanNetlink{
+		MockNetlink: netlink.NewMockNetlink(false, ""),
+		links:       make(map[string]netlink.Link),
+		masters:     make(map[string]string),
+	}
+}
+
+func (nl *vlanNetlink) AddLink(link netlink.Link) error {
+	if _, ok := nl.links[link.Info().Name]; ok {
+		return syscall.EEXIST
+	}
+	nl.links[link.Info().Name] = link
+	return nil
+}
+
+func (nl *vlanNetlink) GetLink(name string) (netlink.Link, error) {
+	if link, ok := nl.links[name]; ok {
+		return link, nil
+	}
+	return nil, syscall.ENODEV
+}
+
+func (nl *vlanNetlink) DeleteLink(name string) error {
+	delete(nl.links, name)
+	nl.deleted = append(nl.deleted, name)
+	return nil
+}
+
+func (nl *vlanNetlink) SetLinkMaster(name, master string) error {
+	nl.masters[name] = master
+	return nil
+}
+
+func (nl *vlanNetlink) SetLinkState(string, bool) error {
+	if nl.failSetState {
+		return errSetLinkState
+	}
+	return nil
+}
+
+func TestConnectVlanBridge(t *testing.T) {
+	nl := newVlanNetlink()
+	nm := &networkManager{netlink: nl, netio: netio.NewMockNetIO(false, 0)}
+	extIf := &externalInterface{Name: "eth0", Networks: map[string]*network{}}
+
+	bridgeName, err := nm.connectVlanBridge(extIf, 100)
+	require.NoError(t, err)
+	require.Equal(t, "azvlan100", bridgeName)
+	require.Equal(t, "azvlan100", nl.masters["eth0.100"], "the VLAN sub-interface is the uplink of the bridge")
+	require.Equal(t, &vlanInterface{Name: "eth0.100", VlanID: 100, MTU: 1000, Created: true}, extIf.VlanInterfaces[100])
+
+	// the network keeps the sub-interface while its endpoints come and go
+	nw := &network{Id: "nw", Mode: opModeBridgeVlan, VlanId: 100, BridgeName: bridgeName, extIf: extIf}
+	extIf.Networks[nw.Id] = nw
+	nm.releaseVlanInterface(extIf, 100)
+	require.Empty(t, nl.deleted)
+
+	_, err = nm.connectVlanBridge(extIf, 100)
+	require.NoError(t, err, "connecting again reuses the links")
+
+	ifView := nw.vlanBridgeInterface()
+	require.Equal(t, "eth0.100", ifView.Name)
+	require.Equal(t, "azvlan100", ifView.BridgeName)
+
+	require.NoError(t, nm.deleteNetworkImpl(nw))
+	delete(extIf.Networks, nw.Id)
+	nm.releaseVlanInterface(extIf, 100)
+	require.Equal(t, []string{"azvlan100", "eth0.100"}, nl.deleted)
+	require.Empty(t, extIf.VlanInterfaces)
+}
+
+func TestAdoptedVlanInterfaceIsNotDeleted(t *testing.T) {
+	nl := newVlanNetlink()
+	nl.links["eth0.100"] = &netlink.VlanLink{
+		LinkInfo: netlink.LinkInfo{Type: netlink.LINK_TYPE_VLAN, Name: "eth0.100", ParentIndex: 2},
+		VlanID:   100,
+	}
+	nm := &networkManager{netlink: nl, netio: netio.NewMockNetIO(false, 0)}
+	extIf := &externalInterface{Name: "eth0"}
+
+	nl.failSetState = true
+	_, err := nm.addVlanInterfaceImpl(extIf, 100)
+	require.ErrorIs(t, err, errSetLinkState)
+	require.Empty(t, nl.deleted, "a sub-interface which existed before is not cleaned up")
+
+	nl.failSetState = false
+	vlanIf, err := nm.addVlanInterfaceImpl(extIf, 100)
+	require.NoError(t, err)
+	require.False(t, vlanIf.Created)
+
+	nm.releaseVlanInterface(extIf, 100)
+	require.Empty(t, nl.deleted, "an adopted sub-interface is left in place")
+	require.Empty(t, extIf.VlanInterfaces)
+
+	// a sub-interface which was created here is deleted if it can't be brought up
+	delete(nl.links, "eth0.100")
+	nl.failSetState = true
+	_, err = nm.addVlanInterfaceImpl(extIf, 100)
+	require.ErrorIs(t, err, errSetLinkState)
+	require.Equal(t, []string{"eth0.100"}, nl.deleted)
+
+	// a link of another VLAN sharing the name is not adopted
+	nl.links["eth0.200"] = &netlink.VlanLink{LinkInfo: netlink.LinkInfo{Name: "eth0.200", ParentIndex: 2}, VlanID: 300}
+	_, err = nm.addVlanInterfaceImpl(extIf, 200)
+	require.ErrorIs(t, err, errVlanInterfaceMismatch)
+}
@@ -0,0 +1,213 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/netns"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// VlanTrunkEndpointClient connects an endpoint to the VLAN of a host NIC in trunk mode. The VLAN sub-interface
+// is created on the NIC and moved into the container namespace, so the traffic of the endpoint is tagged by the
+// kernel of the container and doesn't go through the host namespace.
+type VlanTrunkEndpointClient struct {
+	primaryHostIfName string // The trunk NIC, like eth1
+	containerIfName   string // The VLAN sub-interface, until it is renamed in the container namespace
+	containerMac      net.HardwareAddr
+	vlanID            int
+	netnsClient       netns.Interface
+	netlink           netlink.NetlinkInterface
+	netioshim         netio.NetIOInterface
+	plClient          platform.ExecClient
+	netUtilsClient    networkutils.NetworkUtils
+}
+
+func NewVlanTrunkEndpointClient(
+	extIf *externalInterface,
+	containerIfName string,
+	vlanID int,
+	nl netlink.NetlinkInterface,
+	plc platform.ExecClient,
+) *VlanTrunkEndpointClient {
+	return &VlanTrunkEndpointClient{
+		primaryHostIfName: extIf.Name,
+		containerIfName:   containerIfName,
+		vlanID:            vlanID,
+		netnsClient:       netns.New(),
+		netlink:           nl,
+		netioshim:         &netio.NetIO{},
+		plClient:          plc,
+		netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+	}
+}
+
+// AddEndpoints creates the VLAN sub-interface of the endpoint on the trunk NIC, in the host namespace.
+func (client *VlanTrunkEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
+	if client.vlanID < minVlanID || client.vlanID > maxVlanID {
+		return fmt.Errorf("%w: %d", errVlanIDInvalid, client.vlanID)
+	}
+
+	if _, err := client.netioshim.GetNetworkInterfaceByName(client.containerIfName); err == nil {
+		log.Printf("[net] Deleting old VLAN sub-interface %v", client.containerIfName)
+		if err = client.netlink.DeleteLink(client.containerIfName); err != nil && !errors.Is(err, netlink.ErrNoSuchDevice) {
+			return networkutils.NewError("delete link", client.containerIfName, networkutils.ObjectLink, err)
+		}
+	}
+
+	primaryIf, err := client.netioshim.GetNetworkInterfaceByName(client.primaryHostIfName)
+	if err != nil {
+		return networkutils.NewError("get interface", client.primaryHostIfName, networkutils.ObjectLink, err)
+	}
+
+	log.Printf("[net] Creating VLAN sub-interface %v with VLAN ID %d on %v.", client.containerIfName, client.vlanID, client.primaryHostIfName)
+	link := netlink.VlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type:        netlink.LINK_TYPE_VLAN,
+			Name:        client.containerIfName,
+			MTU:         uint(primaryIf.MTU),
+			ParentIndex: primaryIf.Index,
+		},
+		VlanID: uint16(client.vlanID),
+	}
+	if err = client.netlink.AddLink(&link); err != nil {
+		return networkutils.NewError("create vlan interface", client.containerIfName, networkutils.ObjectLink, err)
+	}
+
+	containerIf, err := client.netioshim.GetNetworkInterfaceByName(client.containerIfName)
+	if err != nil {
+		if delErr := client.netlink.DeleteLink(client.containerIfName); delErr != nil {
+			log.Errorf("[net] Failed to delete VLAN sub-interface %v: %v", client.containerIfName, delErr)
+		}
+		return networkutils.NewError("get interface", client.containerIfName, networkutils.ObjectLink, err)
+	}
+
+	client.containerMac = containerIf.HardwareAddr
+
+	return nil
+}
+
+// AddEndpointRules adds nothing, the traffic of the endpoint doesn't go through the host namespace.
+func (client *VlanTrunkEndpointClient) AddEndpointRules(_ *EndpointInfo) error {
+	return nil
+}
+
+func (client *VlanTrunkEndpointClient) DeleteEndpointRules(_ *endpoint) {}
+
+func (client *VlanTrunkEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
+	log.Printf("[net] Setting link %v netns %v.", client.containerIfName, epInfo.NetNsPath)
+	if err := client.netlink.SetLinkNetNs(client.containerIfName, nsID); err != nil {
+		return networkutils.NewError("set link netns", client.containerIfName, networkutils.ObjectNamespace, err)
+	}
+
+	return nil
+}
+
+func (client *VlanTrunkEndpointClient) SetupContainerInterfaces(epInfo *EndpointInfo) error {
+	if err := client.netUtilsClient.SetupContainerInterface(client.containerIfName, epInfo.IfName); err != nil {
+		return err
+	}
+
+	client.containerIfName = epInfo.IfName
+
+	return nil
+}
+
+// ConfigureContainerInterfacesAndRoutes assigns the addresses and routes of the endpoint to the VLAN sub-interface,
+// and adds static neighbor entries for the gateways, which the Azure host answers for with azureMac.
+func (client *VlanTrunkEndpointClient) ConfigureContainerInterfacesAndRoutes(epInfo *EndpointInfo) error {
+	if err := assignIPAddresses(client.netUtilsClient, client.containerIfName, epInfo); err != nil {
+		return err
+	}
+
+	if err := addRoutes(client.netlink, client.netioshim, client.containerIfName, epInfo.Routes); err != nil {
+		return err
+	}
+
+	gatewayMac, _ := net.ParseMAC(azureMac)
+	for _, route := range epInfo.Routes {
+		if route.Gw == nil || route.Gw.IsUnspecified() {
+			continue
+		}
+
+		log.Printf("[net] Adding static neighbor for gateway %v and MAC %v in container namespace.", route.Gw, gatewayMac)
+		linkInfo := netlink.LinkInfo{
+			Name:       client.containerIfName,
+			IPAddr:     route.Gw,
+			MacAddress: gatewayMac,
+		}
+		if err := addNeighbor(client.netlink, linkInfo, netlink.NUD_PERMANENT); err != nil {
+			return fmt.Errorf("adding neighbor of gateway %v failed: %w", route.Gw, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteEndpoints deletes the VLAN sub-interface of the endpoint. It is still in the host namespace if the ADD
+// failed before moving it, otherwise it is found by its VLAN ID in the container namespace. The sub-interface is
+// deleted with the container namespace if the namespace is already gone.
+func (client *VlanTrunkEndpointClient) DeleteEndpoints(ep *endpoint) error {
+	if _, err := client.netioshim.GetNetworkInterfaceByName(client.containerIfName); err == nil {
+		log.Printf("[net] Deleting VLAN sub-interface %v.", client.containerIfName)
+		if err = client.netlink.DeleteLink(client.containerIfName); err != nil && !errors.Is(err, netlink.ErrNoSuchDevice) {
+			return networkutils.NewError("delete link", client.containerIfName, networkutils.ObjectLink, err)
+		}
+		return nil
+	}
+
+	if ep.NetworkNameSpace == "" {
+		return nil
+	}
+
+	nsFd, err := client.netnsClient.GetFromPath(ep.NetworkNameSpace)
+	if err != nil {
+		log.Printf("[net] Skipping deletion of VLAN sub-interface in netns %v: %v", ep.NetworkNameSpace, err)
+		return nil
+	}
+	defer func() {
+		if err := client.netnsClient.Close(nsFd); err != nil {
+			log.Errorf("[net] Failed to close netns handle %d: %v", nsFd, err)
+		}
+	}()
+
+	//nolint:wrapcheck // errors of the function are returned as is
+	return client.netnsClient.ExecuteInNS(nsFd, func() error {
+		name, err := client.findVlanInterface(ep.VlanID)
+		if err != nil || name == "" {
+			return err
+		}
+
+		log.Printf("[net] Deleting VLAN sub-interface %v in netns %v.", name, ep.NetworkNameSpace)
+		if err := client.netlink.DeleteLink(name); err != nil && !errors.Is(err, netlink.ErrNoSuchDevice) {
+			return networkutils.NewError("delete link", name, networkutils.ObjectLink, err)
+		}
+		return nil
+	})
+}
+
+// findVlanInterface returns the name of the VLAN sub-interface with the VLAN ID in the current namespace, or an
+// empty name if there is none.
+func (client *VlanTrunkEndpointClient) findVlanInterface(vlanID int) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to list interfaces: %w", err)
+	}
+
+	for i := range ifaces {
+		link, err := client.netlink.GetLink(ifaces[i].Name)
+		if err != nil {
+			continue
+		}
+		if vlan, ok := link.(*netlink.VlanLink); ok && int(vlan.VlanID) == vlanID {
+			return ifaces[i].Name, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,38 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVlanTrunkEndpointClientAddEndpoints(t *testing.T) {
+	extIf := &externalInterface{Name: "eth1"}
+
+	t.Run("invalid VLAN ID", func(t *testing.T) {
+		nl := newVlanNetlink()
+		client := NewVlanTrunkEndpointClient(extIf, "azv1-2", 0, nl, platform.NewMockExecClient(false))
+		client.netioshim = netio.NewMockNetIO(false, 0)
+
+		require.ErrorIs(t, client.AddEndpoints(&EndpointInfo{}), errVlanIDInvalid)
+		require.Empty(t, nl.links)
+	})
+
+	t.Run("creates the VLAN sub-interface on the trunk", func(t *testing.T) {
+		nl := newVlanNetlink()
+		nl.links["azv1-2"] = &netlink.VEthLink{LinkInfo: netlink.LinkInfo{Name: "azv1-2"}}
+		client := NewVlanTrunkEndpointClient(extIf, "azv1-2", 100, nl, platform.NewMockExecClient(false))
+		client.netioshim = netio.NewMockNetIO(false, 0)
+
+		require.NoError(t, client.AddEndpoints(&EndpointInfo{}))
+		require.Equal(t, []string{"azv1-2"}, nl.deleted, "a leftover interface is replaced")
+		require.Equal(t, &netlink.VlanLink{
+			LinkInfo: netlink.LinkInfo{Type: netlink.LINK_TYPE_VLAN, Name: "azv1-2", MTU: 1000, ParentIndex: 2},
+			VlanID:   100,
+		}, nl.links["azv1-2"])
+		require.Equal(t, "ab:cd:ef:12:34:56", client.containerMac.String())
+	})
+}
@@ -0,0 +1,13 @@
+package network
+
+import "errors"
+
+var errVlanInterfaceNotSupported = errors.New("VLAN sub-interfaces are not supported on windows")
+
+func (nm *networkManager) addVlanInterfaceImpl(*externalInterface, int) (*vlanInterface, error) {
+	return nil, errVlanInterfaceNotSupported
+}
+
+func (nm *networkManager) deleteVlanInterfaceImpl(*externalInterface, int) error {
+	return errVlanInterfaceNotSupported
+}
@@ -0,0 +1,69 @@
+// Package vxlan programs the VXLAN interface which carries pod traffic between nodes in overlay mode.
+package vxlan
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+const (
+	// DefaultVNI is the VXLAN network identifier of an overlay network which has none configured.
+	DefaultVNI = 4096
+	// DefaultPort is the IANA assigned VXLAN UDP port.
+	DefaultPort = 4789
+	// interfacePrefix is followed by the VNI in the name of the VXLAN interface.
+	interfacePrefix = "azvxlan"
+	// maxVNI is the largest VNI, which is 24 bits wide.
+	maxVNI = 1<<24 - 1
+	// encapOverhead is the size of the outer IPv4, UDP and VXLAN headers and the inner ethernet header.
+	encapOverhead = 50
+)
+
+var (
+	// ErrInvalidPeer is returned when a peer has no node IP, no pod CIDR or no VTEP MAC address.
+	ErrInvalidPeer = errors.New("vxlan peer requires a node IP, a pod CIDR and a VTEP MAC address")
+	// ErrInvalidVNI is returned when the VNI does not fit in 24 bits.
+	ErrInvalidVNI = errors.New("vxlan VNI must be between 1 and 16777215")
+	// ErrNotSupported is returned on platforms without VXLAN support.
+	ErrNotSupported = errors.New("vxlan overlay is not supported on this platform")
+)
+
+// InterfaceName returns the name of the VXLAN interface of the VNI.
+func InterfaceName(vni int) string {
+	return fmt.Sprintf("%s%d", interfacePrefix, vni)
+}
+
+// ValidateVNI returns an error if the VNI cannot be used for a VXLAN interface.
+func ValidateVNI(vni int) error {
+	if vni < 1 || vni > maxVNI {
+		return fmt.Errorf("%w: %d", ErrInvalidVNI, vni)
+	}
+
+	return nil
+}
+
+// Peer is a remote node of the overlay.
+type Peer struct {
+	// NodeIP is the underlay address of the node, the destination of the encapsulated packets.
+	NodeIP net.IP
+	// PodCIDR is the pod CIDR hosted on the node. Traffic to it is routed through the VXLAN interface.
+	PodCIDR net.IPNet
+	// VtepMAC is the MAC address of the VXLAN interface of the node.
+	VtepMAC net.HardwareAddr
+}
+
+// Validate returns an error if the peer cannot be programmed.
+func (p *Peer) Validate() error {
+	if p.NodeIP == nil || p.PodCIDR.IP == nil || p.PodCIDR.Mask == nil || len(p.VtepMAC) == 0 {
+		return ErrInvalidPeer
+	}
+
+	return nil
+}
+
+// vtepIP is the next hop of the routes to the pod CIDR of the peer. It is the network address of the pod CIDR,
+// which is never assigned to a pod, and resolves to the VTEP MAC address through a static neighbor entry.
+func (p *Peer) vtepIP() net.IP {
+	return p.PodCIDR.IP.Mask(p.PodCIDR.Mask)
+}
@@ -0,0 +1,277 @@
+package vxlan
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+	"golang.org/x/sys/unix"
+)
+
+var errVNIMismatch = errors.New("vxlan interface exists with a different VNI")
+
+// Client manages the VXLAN interface of an overlay network and the forwarding state of its peers.
+type Client struct {
+	vni          int
+	ifName       string
+	underlayName string
+	netlink      netlink.NetlinkInterface
+	netio        netio.NetIOInterface
+}
+
+// NewClient returns a client of the VXLAN interface of the VNI, which encapsulates traffic over the
+// underlay interface.
+func NewClient(vni int, underlayName string, nl netlink.NetlinkInterface, nio netio.NetIOInterface) *Client {
+	return &Client{
+		vni:          vni,
+		ifName:       InterfaceName(vni),
+		underlayName: underlayName,
+		netlink:      nl,
+		netio:        nio,
+	}
+}
+
+// Setup creates the VXLAN interface on top of the underlay interface. Setup is idempotent.
+func (client *Client) Setup() error {
+	if err := ValidateVNI(client.vni); err != nil {
+		return err
+	}
+
+	underlay, err := client.netio.GetNetworkInterfaceByName(client.underlayName)
+	if err != nil {
+		return networkutils.NewError("get interface", client.underlayName, networkutils.ObjectLink, err)
+	}
+
+	log.Printf("[net] Creating vxlan interface %s with VNI %d over %s.", client.ifName, client.vni, client.underlayName)
+	err = client.netlink.AddLink(&netlink.VxlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type: netlink.LINK_TYPE_VXLAN,
+			Name: client.ifName,
+			MTU:  uint(underlay.MTU - encapOverhead),
+		},
+		VNI:       uint32(client.vni),
+		VtepIndex: underlay.Index,
+		Port:      DefaultPort,
+		// Peers are programmed explicitly, learning would let any host on the underlay inject entries.
+		Learning: false,
+	})
+	if err != nil {
+		err = networkutils.NewError("create vxlan interface", client.ifName, networkutils.ObjectLink, err)
+		if !networkutils.IsAlreadyExists(err) {
+			return err
+		}
+
+		if err = client.checkVNI(); err != nil {
+			return err
+		}
+	}
+
+	if err = client.netlink.SetLinkState(client.ifName, true); err != nil {
+		return networkutils.NewError("set link state", client.ifName, networkutils.ObjectLink, err)
+	}
+
+	return nil
+}
+
+// checkVNI returns an error if the existing interface is not a VXLAN interface of the VNI of the client.
+func (client *Client) checkVNI() error {
+	link, err := client.netlink.GetLink(client.ifName)
+	if err != nil {
+		return networkutils.NewError("get vxlan interface", client.ifName, networkutils.ObjectLink, err)
+	}
+
+	if vxlan, ok := link.(*netlink.VxlanLink); !ok || int(vxlan.VNI) != client.vni {
+		return fmt.Errorf("%w: %s", errVNIMismatch, client.ifName)
+	}
+
+	return nil
+}
+
+// Teardown deletes the VXLAN interface, its routes, neighbor and FDB entries go with it.
+func (client *Client) Teardown() error {
+	log.Printf("[net] Deleting vxlan interface %s.", client.ifName)
+	if err := client.netlink.DeleteLink(client.ifName); err != nil {
+		err = networkutils.NewError("delete vxlan interface", client.ifName, networkutils.ObjectLink, err)
+		if !networkutils.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HardwareAddr returns the MAC address of the VXLAN interface, which other nodes need to add this node as a peer.
+func (client *Client) HardwareAddr() (net.HardwareAddr, error) {
+	iface, err := client.netio.GetNetworkInterfaceByName(client.ifName)
+	if err != nil {
+		return nil, networkutils.NewError("get interface", client.ifName, networkutils.ObjectLink, err)
+	}
+
+	return iface.HardwareAddr, nil
+}
+
+// SyncPeers programs the FDB entries, neighbor entries and routes which send the traffic to the pod CIDRs of
+// the peers through the VXLAN interface. The state of peers which are not in the list anymore is removed.
+func (client *Client) SyncPeers(peers []Peer) error {
+	for i := range peers {
+		if err := peers[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	iface, err := client.netio.GetNetworkInterfaceByName(client.ifName)
+	if err != nil {
+		return networkutils.NewError("get interface", client.ifName, networkutils.ObjectLink, err)
+	}
+
+	if err = client.syncFDB(peers); err != nil {
+		return err
+	}
+
+	if err = client.syncNeighbors(peers); err != nil {
+		return err
+	}
+
+	return client.syncRoutes(iface.Index, peers)
+}
+
+// syncFDB makes the FDB of the VXLAN interface send the frames to the VTEP MAC of each peer to its node IP.
+func (client *Client) syncFDB(peers []Peer) error {
+	desired := make(map[string]net.IP, len(peers))
+	for i := range peers {
+		desired[peers[i].VtepMAC.String()] = peers[i].NodeIP
+	}
+
+	entries, err := client.netlink.ListFDBEntries(client.ifName)
+	if err != nil {
+		return networkutils.NewError("list fdb entries", client.ifName, networkutils.ObjectLink, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IP == nil || entry.HardwareAddr == nil {
+			continue
+		}
+
+		if nodeIP, ok := desired[entry.HardwareAddr.String()]; ok && nodeIP.Equal(entry.IP) {
+			continue
+		}
+
+		log.Printf("[net] Removing stale fdb entry %v dst %v from vxlan interface %s.", entry.HardwareAddr, entry.IP, client.ifName)
+		if err = client.netlink.DeleteFDBEntry(client.ifName, entry.HardwareAddr, entry.IP); err != nil {
+			err = networkutils.NewError("delete fdb entry", entry.HardwareAddr.String(), networkutils.ObjectLink, err)
+			if !networkutils.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	// Adding an entry replaces the existing one, which keeps the sync idempotent.
+	for i := range peers {
+		if err = client.netlink.AddFDBEntry(client.ifName, peers[i].VtepMAC, peers[i].NodeIP); err != nil {
+			return networkutils.NewError("add fdb entry", peers[i].VtepMAC.String(), networkutils.ObjectLink, err)
+		}
+	}
+
+	return nil
+}
+
+// syncNeighbors resolves the VTEP IP of each peer to its VTEP MAC with a permanent neighbor entry.
+func (client *Client) syncNeighbors(peers []Peer) error {
+	desired := make(map[string]struct{}, len(peers))
+	for i := range peers {
+		desired[peers[i].vtepIP().String()] = struct{}{}
+	}
+
+	neighbors, err := client.netlink.ListNeighbors(client.ifName)
+	if err != nil {
+		return networkutils.NewError("list neighbors", client.ifName, networkutils.ObjectLink, err)
+	}
+
+	for _, neigh := range neighbors {
+		if neigh.IP == nil || neigh.State&netlink.NUD_PERMANENT == 0 {
+			continue
+		}
+
+		if _, ok := desired[neigh.IP.String()]; ok {
+			continue
+		}
+
+		log.Printf("[net] Removing stale neighbor %v from vxlan interface %s.", neigh.IP, client.ifName)
+		linkInfo := netlink.LinkInfo{Name: client.ifName, IPAddr: neigh.IP, MacAddress: neigh.HardwareAddr}
+		if err = client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.REMOVE, netlink.NUD_INCOMPLETE); err != nil {
+			return networkutils.NewError("delete neighbor", neigh.IP.String(), networkutils.ObjectLink, err)
+		}
+	}
+
+	for i := range peers {
+		linkInfo := netlink.LinkInfo{Name: client.ifName, IPAddr: peers[i].vtepIP(), MacAddress: peers[i].VtepMAC}
+		if err = client.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.ADD, netlink.NUD_PERMANENT); err != nil {
+			return networkutils.NewError("add neighbor", linkInfo.IPAddr.String(), networkutils.ObjectLink, err)
+		}
+	}
+
+	return nil
+}
+
+// syncRoutes makes the routes through the VXLAN interface match the pod CIDRs of the peers. The routes are
+// onlink via the VTEP IP of the peer since the VTEP IPs are not in a subnet of the interface.
+func (client *Client) syncRoutes(linkIndex int, peers []Peer) error {
+	desired := make(map[string]*Peer, len(peers))
+	for i := range peers {
+		desired[peers[i].PodCIDR.String()] = &peers[i]
+	}
+
+	routes, err := client.netlink.GetIPRoute(&netlink.Route{LinkIndex: linkIndex})
+	if err != nil {
+		return networkutils.NewError("list routes", client.ifName, networkutils.ObjectRoute, err)
+	}
+
+	existing := make(map[string]struct{}, len(routes))
+	for _, r := range routes {
+		if r.Dst == nil {
+			continue
+		}
+
+		if peer, ok := desired[r.Dst.String()]; ok && peer.vtepIP().Equal(r.Gw) {
+			existing[r.Dst.String()] = struct{}{}
+			continue
+		}
+
+		log.Printf("[net] Removing stale route %v via vxlan interface %s.", r.Dst, client.ifName)
+		if err = client.netlink.DeleteIPRoute(r); err != nil {
+			return networkutils.NewError("delete route", r.Dst.String(), networkutils.ObjectRoute, err)
+		}
+	}
+
+	for key, peer := range desired {
+		if _, ok := existing[key]; ok {
+			continue
+		}
+
+		family := unix.AF_INET
+		if peer.PodCIDR.IP.To4() == nil {
+			family = unix.AF_INET6
+		}
+
+		dst := peer.PodCIDR
+		err = client.netlink.AddIPRoute(&netlink.Route{
+			Family:    family,
+			Dst:       &dst,
+			Gw:        peer.vtepIP(),
+			Flags:     unix.RTNH_F_ONLINK,
+			LinkIndex: linkIndex,
+		})
+		if err != nil {
+			err = networkutils.NewError("add route", key, networkutils.ObjectRoute, err)
+			if !networkutils.IsAlreadyExists(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package vxlan
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+const testUnderlay = "eth0"
+
+// recordingNetlink returns the configured FDB entries, neighbors and routes and records the changes.
+type recordingNetlink struct {
+	*netlink.MockNetlink
+	links     []netlink.Link
+	fdb       []*netlink.Neighbor
+	neighbors []*netlink.Neighbor
+	routes    []*netlink.Route
+
+	addedFDB        []string
+	deletedFDB      []string
+	addedNeighbors  []string
+	deletedNeighbor []string
+	addedRoutes     []*netlink.Route
+	deletedRoutes   []*netlink.Route
+}
+
+func newRecordingNetlink() *recordingNetlink {
+	return &recordingNetlink{MockNetlink: netlink.NewMockNetlink(false, "")}
+}
+
+func (nl *recordingNetlink) AddLink(link netlink.Link) error {
+	nl.links = append(nl.links, link)
+	return nil
+}
+
+func (nl *recordingNetlink) ListFDBEntries(string) ([]*netlink.Neighbor, error) {
+	return nl.fdb, nil
+}
+
+func (nl *recordingNetlink) AddFDBEntry(_ string, mac net.HardwareAddr, dst net.IP) error {
+	nl.addedFDB = append(nl.addedFDB, mac.String()+" "+dst.String())
+	return nil
+}
+
+func (nl *recordingNetlink) DeleteFDBEntry(_ string, mac net.HardwareAddr, dst net.IP) error {
+	nl.deletedFDB = append(nl.deletedFDB, mac.String()+" "+dst.String())
+	return nil
+}
+
+func (nl *recordingNetlink) ListNeighbors(string) ([]*netlink.Neighbor, error) {
+	return nl.neighbors, nil
+}
+
+func (nl *recordingNetlink) SetOrRemoveLinkAddress(linkInfo netlink.LinkInfo, mode, _ int) error {
+	entry := linkInfo.IPAddr.String() + " " + linkInfo.MacAddress.String()
+	if mode == netlink.ADD {
+		nl.addedNeighbors = append(nl.addedNeighbors, entry)
+	} else {
+		nl.deletedNeighbor = append(nl.deletedNeighbor, entry)
+	}
+	return nil
+}
+
+func (nl *recordingNetlink) GetIPRoute(*netlink.Route) ([]*netlink.Route, error) {
+	return nl.routes, nil
+}
+
+func (nl *recordingNetlink) AddIPRoute(route *netlink.Route) error {
+	nl.addedRoutes = append(nl.addedRoutes, route)
+	return nil
+}
+
+func (nl *recordingNetlink) DeleteIPRoute(route *netlink.Route) error {
+	nl.deletedRoutes = append(nl.deletedRoutes, route)
+	return nil
+}
+
+func mustParseMAC(t *testing.T, s string) net.HardwareAddr {
+	mac, err := net.ParseMAC(s)
+	require.NoError(t, err)
+	return mac
+}
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return ipNet
+}
+
+func TestSetup(t *testing.T) {
+	nl := newRecordingNetlink()
+	client := NewClient(DefaultVNI, testUnderlay, nl, netio.NewMockNetIO(false, 0))
+
+	require.NoError(t, client.Setup())
+	require.Len(t, nl.links, 1)
+	link, ok := nl.links[0].(*netlink.VxlanLink)
+	require.True(t, ok)
+	require.Equal(t, "azvxlan4096", link.Name)
+	require.Equal(t, uint32(DefaultVNI), link.VNI)
+	require.Equal(t, 2, link.VtepIndex)
+	require.Equal(t, uint(1000-encapOverhead), link.MTU)
+	require.Equal(t, uint16(DefaultPort), link.Port)
+	require.False(t, link.Learning)
+
+	require.ErrorIs(t, NewClient(0, testUnderlay, nl, netio.NewMockNetIO(false, 0)).Setup(), ErrInvalidVNI)
+	require.ErrorIs(t, NewClient(maxVNI+1, testUnderlay, nl, netio.NewMockNetIO(false, 0)).Setup(), ErrInvalidVNI)
+}
+
+func TestSyncPeers(t *testing.T) {
+	peerMAC := mustParseMAC(t, "02:00:00:00:00:01")
+	movedMAC := mustParseMAC(t, "02:00:00:00:00:02")
+	staleMAC := mustParseMAC(t, "02:00:00:00:00:03")
+	peerCIDR := mustParseCIDR(t, "10.244.1.0/24")
+	movedCIDR := mustParseCIDR(t, "10.244.2.0/24")
+	staleCIDR := mustParseCIDR(t, "10.244.3.0/24")
+
+	nl := newRecordingNetlink()
+	nl.fdb = []*netlink.Neighbor{
+		{HardwareAddr: peerMAC, IP: net.ParseIP("10.240.0.5")},
+		// the node of the peer got a new IP
+		{HardwareAddr: movedMAC, IP: net.ParseIP("10.240.0.6")},
+		{HardwareAddr: staleMAC, IP: net.ParseIP("10.240.0.7")},
+	}
+	nl.neighbors = []*netlink.Neighbor{
+		{IP: net.ParseIP("10.244.1.0"), HardwareAddr: peerMAC, State: netlink.NUD_PERMANENT},
+		{IP: net.ParseIP("10.244.3.0"), HardwareAddr: staleMAC, State: netlink.NUD_PERMANENT},
+		// learned entries are left to the kernel
+		{IP: net.ParseIP("10.244.4.1"), State: netlink.NUD_REACHABLE},
+	}
+	nl.routes = []*netlink.Route{
+		{Dst: peerCIDR, Gw: net.ParseIP("10.244.1.0")},
+		{Dst: staleCIDR, Gw: net.ParseIP("10.244.3.0")},
+	}
+
+	client := NewClient(DefaultVNI, testUnderlay, nl, netio.NewMockNetIO(false, 0))
+	peers := []Peer{
+		{NodeIP: net.ParseIP("10.240.0.5"), PodCIDR: *peerCIDR, VtepMAC: peerMAC},
+		{NodeIP: net.ParseIP("10.240.0.8"), PodCIDR: *movedCIDR, VtepMAC: movedMAC},
+	}
+
+	require.NoError(t, client.SyncPeers(peers))
+	require.Equal(t, []string{"02:00:00:00:00:02 10.240.0.6", "02:00:00:00:00:03 10.240.0.7"}, nl.deletedFDB)
+	require.Equal(t, []string{"02:00:00:00:00:01 10.240.0.5", "02:00:00:00:00:02 10.240.0.8"}, nl.addedFDB)
+	require.Equal(t, []string{"10.244.3.0 02:00:00:00:00:03"}, nl.deletedNeighbor)
+	require.Equal(t, []string{"10.244.1.0 02:00:00:00:00:01", "10.244.2.0 02:00:00:00:00:02"}, nl.addedNeighbors)
+
+	require.Len(t, nl.deletedRoutes, 1)
+	require.Equal(t, staleCIDR.String(), nl.deletedRoutes[0].Dst.String())
+	require.Len(t, nl.addedRoutes, 1)
+	require.Equal(t, movedCIDR.String(), nl.addedRoutes[0].Dst.String())
+	require.True(t, net.ParseIP("10.244.2.0").Equal(nl.addedRoutes[0].Gw))
+	require.Equal(t, unix.RTNH_F_ONLINK, nl.addedRoutes[0].Flags)
+	require.Equal(t, 2, nl.addedRoutes[0].LinkIndex)
+
+	require.ErrorIs(t, client.SyncPeers([]Peer{{NodeIP: net.ParseIP("10.240.0.5")}}), ErrInvalidPeer)
+}
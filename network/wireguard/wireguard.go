@@ -0,0 +1,57 @@
+// Package wireguard programs the wireguard interface used to encrypt pod-to-pod traffic between nodes.
+package wireguard
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultInterfaceName is the name of the wireguard interface created on each node.
+	DefaultInterfaceName = "azwg0"
+	// DefaultListenPort is the UDP port the wireguard interface listens on.
+	DefaultListenPort = 51820
+	// DefaultKeyDir is the directory holding the node's private key.
+	DefaultKeyDir = "/var/run/azure-vnet/wireguard"
+	// persistentKeepalive keeps NAT mappings between nodes alive, in seconds.
+	persistentKeepalive = 25
+)
+
+var (
+	// ErrInvalidPeer is returned when a peer has no public key or no allowed IPs.
+	ErrInvalidPeer = errors.New("wireguard peer requires a public key and allowed IPs")
+	// ErrNotSupported is returned on platforms without wireguard support.
+	ErrNotSupported = errors.New("wireguard overlay is not supported on this platform")
+)
+
+// Peer is a remote node of the overlay.
+type Peer struct {
+	// PublicKey is the base64 encoded public key of the node.
+	PublicKey string
+	// Endpoint is the host:port the node's wireguard interface listens on.
+	Endpoint string
+	// AllowedIPs are the pod CIDRs hosted on the node. Traffic to them is routed through the tunnel.
+	AllowedIPs []net.IPNet
+}
+
+// Validate returns an error if the peer cannot be programmed.
+func (p *Peer) Validate() error {
+	if p.PublicKey == "" || len(p.AllowedIPs) == 0 {
+		return ErrInvalidPeer
+	}
+
+	return nil
+}
+
+// allowedIPs returns the allowed IPs of the peer in the format accepted by wg.
+func (p *Peer) allowedIPs() string {
+	cidrs := make([]string, 0, len(p.AllowedIPs))
+	for i := range p.AllowedIPs {
+		cidrs = append(cidrs, p.AllowedIPs[i].String())
+	}
+	sort.Strings(cidrs)
+
+	return strings.Join(cidrs, ",")
+}
@@ -0,0 +1,284 @@
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+	"github.com/Azure/azure-container-networking/platform"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// The private key never appears on a command line since executed commands are logged.
+	genKeyCmd        = "(umask 077 && wg genkey > %s)"
+	pubKeyCmd        = "wg pubkey < %s"
+	setInterfaceCmd  = "wg set %s listen-port %d private-key %s"
+	setPrivateKeyCmd = "wg set %s private-key %s"
+	setPeerCmd       = "wg set %s peer %s persistent-keepalive %d allowed-ips %s"
+	setPeerEndpoint  = " endpoint %s"
+	removePeerCmd    = "wg set %s peer %s remove"
+	showPeersCmd     = "wg show %s peers"
+
+	privateKeyFile = "%s.key"
+)
+
+var errorWireguardClient = errors.New("WireguardClient Error")
+
+func newErrorWireguardClient(err error) error {
+	return fmt.Errorf("%v : %w", errorWireguardClient, err)
+}
+
+// Client manages the wireguard interface of the node, its key and its peers.
+type Client struct {
+	ifName     string
+	listenPort int
+	keyDir     string
+	netlink    netlink.NetlinkInterface
+	netio      netio.NetIOInterface
+	plClient   platform.ExecClient
+}
+
+func NewClient(
+	ifName string,
+	listenPort int,
+	keyDir string,
+	nl netlink.NetlinkInterface,
+	nio netio.NetIOInterface,
+	plClient platform.ExecClient,
+) *Client {
+	return &Client{
+		ifName:     ifName,
+		listenPort: listenPort,
+		keyDir:     keyDir,
+		netlink:    nl,
+		netio:      nio,
+		plClient:   plClient,
+	}
+}
+
+func (client *Client) keyPath() string {
+	return filepath.Join(client.keyDir, fmt.Sprintf(privateKeyFile, client.ifName))
+}
+
+// Setup creates the wireguard interface and configures it with the node's private key, which is
+// generated on first use. Setup is idempotent.
+func (client *Client) Setup() error {
+	if err := os.MkdirAll(client.keyDir, 0o700); err != nil {
+		return newErrorWireguardClient(err)
+	}
+
+	keyPath := client.keyPath()
+	if _, err := os.Stat(keyPath); errors.Is(err, os.ErrNotExist) {
+		log.Printf("[net] Generating wireguard private key %s.", keyPath)
+		if _, err = client.plClient.ExecuteCommand(fmt.Sprintf(genKeyCmd, keyPath)); err != nil {
+			return newErrorWireguardClient(err)
+		}
+	}
+
+	log.Printf("[net] Creating wireguard interface %s.", client.ifName)
+	err := client.netlink.AddLink(&netlink.WireguardLink{
+		LinkInfo: netlink.LinkInfo{
+			Type: netlink.LINK_TYPE_WIREGUARD,
+			Name: client.ifName,
+		},
+	})
+	if err != nil {
+		err = networkutils.NewError("create wireguard interface", client.ifName, networkutils.ObjectLink, err)
+		if !networkutils.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	if _, err = client.plClient.ExecuteCommand(fmt.Sprintf(setInterfaceCmd, client.ifName, client.listenPort, keyPath)); err != nil {
+		return newErrorWireguardClient(err)
+	}
+
+	if err = client.netlink.SetLinkState(client.ifName, true); err != nil {
+		return networkutils.NewError("set link state", client.ifName, networkutils.ObjectLink, err)
+	}
+
+	return nil
+}
+
+// Teardown deletes the wireguard interface, its routes go with it, and the node's private key.
+func (client *Client) Teardown() error {
+	log.Printf("[net] Deleting wireguard interface %s.", client.ifName)
+	if err := client.netlink.DeleteLink(client.ifName); err != nil {
+		err = networkutils.NewError("delete wireguard interface", client.ifName, networkutils.ObjectLink, err)
+		if !networkutils.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if err := os.Remove(client.keyPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return newErrorWireguardClient(err)
+	}
+
+	return nil
+}
+
+// PublicKey returns the public key of the node.
+func (client *Client) PublicKey() (string, error) {
+	out, err := client.plClient.ExecuteCommand(fmt.Sprintf(pubKeyCmd, client.keyPath()))
+	if err != nil {
+		return "", newErrorWireguardClient(err)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// KeyAge returns how long ago the private key of the node was generated.
+func (client *Client) KeyAge() (time.Duration, error) {
+	info, err := os.Stat(client.keyPath())
+	if err != nil {
+		return 0, newErrorWireguardClient(err)
+	}
+
+	return time.Since(info.ModTime()), nil
+}
+
+// RotateKey replaces the private key of the node and returns the new public key. Peers keep using the
+// old public key until the new one is distributed, so traffic is interrupted until then.
+func (client *Client) RotateKey() (string, error) {
+	keyPath := client.keyPath()
+	newKeyPath := keyPath + ".new"
+
+	log.Printf("[net] Rotating wireguard private key of %s.", client.ifName)
+	if _, err := client.plClient.ExecuteCommand(fmt.Sprintf(genKeyCmd, newKeyPath)); err != nil {
+		return "", newErrorWireguardClient(err)
+	}
+
+	if _, err := client.plClient.ExecuteCommand(fmt.Sprintf(setPrivateKeyCmd, client.ifName, newKeyPath)); err != nil {
+		os.Remove(newKeyPath)
+		return "", newErrorWireguardClient(err)
+	}
+
+	if err := os.Rename(newKeyPath, keyPath); err != nil {
+		return "", newErrorWireguardClient(err)
+	}
+
+	return client.PublicKey()
+}
+
+// SyncPeers programs the given peers and routes to their allowed IPs through the wireguard interface.
+// Peers and routes which are not in the list anymore are removed.
+func (client *Client) SyncPeers(peers []Peer) error {
+	current, err := client.listPeers()
+	if err != nil {
+		return err
+	}
+
+	desired := make(map[string]struct{}, len(peers))
+	for i := range peers {
+		peer := &peers[i]
+		if err = peer.Validate(); err != nil {
+			return err
+		}
+
+		desired[peer.PublicKey] = struct{}{}
+		cmd := fmt.Sprintf(setPeerCmd, client.ifName, peer.PublicKey, persistentKeepalive, peer.allowedIPs())
+		if peer.Endpoint != "" {
+			cmd += fmt.Sprintf(setPeerEndpoint, peer.Endpoint)
+		}
+
+		if _, err = client.plClient.ExecuteCommand(cmd); err != nil {
+			return newErrorWireguardClient(err)
+		}
+	}
+
+	for _, key := range current {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+
+		log.Printf("[net] Removing stale wireguard peer %s.", key)
+		if _, err = client.plClient.ExecuteCommand(fmt.Sprintf(removePeerCmd, client.ifName, key)); err != nil {
+			return newErrorWireguardClient(err)
+		}
+	}
+
+	return client.syncRoutes(peers)
+}
+
+// listPeers returns the public keys of the peers configured on the interface.
+func (client *Client) listPeers() ([]string, error) {
+	out, err := client.plClient.ExecuteCommand(fmt.Sprintf(showPeersCmd, client.ifName))
+	if err != nil {
+		return nil, newErrorWireguardClient(err)
+	}
+
+	return strings.Fields(out), nil
+}
+
+// syncRoutes makes the routes through the wireguard interface match the allowed IPs of the peers.
+func (client *Client) syncRoutes(peers []Peer) error {
+	iface, err := client.netio.GetNetworkInterfaceByName(client.ifName)
+	if err != nil {
+		return networkutils.NewError("get interface", client.ifName, networkutils.ObjectLink, err)
+	}
+
+	desired := make(map[string]*net.IPNet)
+	for i := range peers {
+		for j := range peers[i].AllowedIPs {
+			dst := peers[i].AllowedIPs[j]
+			desired[dst.String()] = &dst
+		}
+	}
+
+	routes, err := client.netlink.GetIPRoute(&netlink.Route{LinkIndex: iface.Index})
+	if err != nil {
+		return networkutils.NewError("list routes", client.ifName, networkutils.ObjectRoute, err)
+	}
+
+	existing := make(map[string]struct{}, len(routes))
+	for _, r := range routes {
+		if r.Dst == nil {
+			continue
+		}
+
+		if _, ok := desired[r.Dst.String()]; ok {
+			existing[r.Dst.String()] = struct{}{}
+			continue
+		}
+
+		log.Printf("[net] Removing stale route %v via wireguard interface %s.", r.Dst, client.ifName)
+		if err = client.netlink.DeleteIPRoute(r); err != nil {
+			return networkutils.NewError("delete route", r.Dst.String(), networkutils.ObjectRoute, err)
+		}
+	}
+
+	for key, dst := range desired {
+		if _, ok := existing[key]; ok {
+			continue
+		}
+
+		family := unix.AF_INET
+		if dst.IP.To4() == nil {
+			family = unix.AF_INET6
+		}
+
+		err = client.netlink.AddIPRoute(&netlink.Route{
+			Family:    family,
+			Dst:       dst,
+			Scope:     netlink.RT_SCOPE_LINK,
+			LinkIndex: iface.Index,
+		})
+		if err != nil {
+			err = networkutils.NewError("add route", key, networkutils.ObjectRoute, err)
+			if !networkutils.IsAlreadyExists(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package wireguard
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIfName = "azwgtest"
+	localKey   = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+	peerKey    = "HIgo9xNzJMWLKASShiTqIybxZ0U3wGLiUeJ1PKf8ykw="
+	staleKey   = "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0="
+)
+
+// fakeExecClient records the executed commands and answers wg queries.
+type fakeExecClient struct {
+	commands []string
+	peers    []string
+}
+
+func (f *fakeExecClient) ExecuteCommand(command string) (string, error) {
+	f.commands = append(f.commands, command)
+
+	switch {
+	case strings.HasPrefix(command, "wg show"):
+		return strings.Join(f.peers, "\n"), nil
+	case strings.HasPrefix(command, "wg pubkey"):
+		return localKey + "\n", nil
+	}
+
+	return "", nil
+}
+
+func (f *fakeExecClient) ExecuteCommandContext(_ context.Context, command string, _ *platform.ExecOptions) (*platform.ExecResult, error) {
+	out, err := f.ExecuteCommand(command)
+	return &platform.ExecResult{Stdout: out}, err
+}
+
+func newTestClient(t *testing.T, plClient *fakeExecClient) *Client {
+	return NewClient(testIfName, DefaultListenPort, t.TempDir(), netlink.NewMockNetlink(false, ""), netio.NewMockNetIO(false, 0), plClient)
+}
+
+func TestSetup(t *testing.T) {
+	plClient := &fakeExecClient{}
+	client := newTestClient(t, plClient)
+
+	require.NoError(t, client.Setup())
+	keyPath := filepath.Join(client.keyDir, testIfName+".key")
+	require.Contains(t, plClient.commands, "(umask 077 && wg genkey > "+keyPath+")")
+	require.Contains(t, plClient.commands, "wg set azwgtest listen-port 51820 private-key "+keyPath)
+
+	for _, cmd := range plClient.commands {
+		require.NotContains(t, cmd, "wg set azwgtest private-key", "setup must not replace the key")
+	}
+
+	key, err := client.PublicKey()
+	require.NoError(t, err)
+	require.Equal(t, localKey, key)
+}
+
+func TestSyncPeers(t *testing.T) {
+	plClient := &fakeExecClient{peers: []string{peerKey, staleKey}}
+	client := newTestClient(t, plClient)
+
+	_, podCIDR, _ := net.ParseCIDR("10.244.1.0/24")
+	_, podCIDRv6, _ := net.ParseCIDR("fd00:1::/64")
+	peers := []Peer{
+		{
+			PublicKey:  peerKey,
+			Endpoint:   "10.240.0.5:51820",
+			AllowedIPs: []net.IPNet{*podCIDRv6, *podCIDR},
+		},
+	}
+
+	require.NoError(t, client.SyncPeers(peers))
+	require.Equal(t, []string{
+		"wg show azwgtest peers",
+		"wg set azwgtest peer " + peerKey + " persistent-keepalive 25 allowed-ips 10.244.1.0/24,fd00:1::/64 endpoint 10.240.0.5:51820",
+		"wg set azwgtest peer " + staleKey + " remove",
+	}, plClient.commands)
+
+	require.ErrorIs(t, client.SyncPeers([]Peer{{PublicKey: peerKey}}), ErrInvalidPeer)
+}
+
+func TestRotateKey(t *testing.T) {
+	plClient := &fakeExecClient{}
+	client := newTestClient(t, plClient)
+	keyPath := filepath.Join(client.keyDir, testIfName+".key")
+
+	require.NoError(t, os.WriteFile(keyPath, []byte("key"), 0o600))
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(keyPath, old, old))
+	age, err := client.KeyAge()
+	require.NoError(t, err)
+	require.Greater(t, age, 47*time.Hour)
+
+	// the fake does not run wg genkey, create the new key file in its place
+	require.NoError(t, os.WriteFile(keyPath+".new", []byte("key"), 0o600))
+
+	key, err := client.RotateKey()
+	require.NoError(t, err)
+	require.Equal(t, localKey, key)
+	require.Contains(t, plClient.commands, "wg set azwgtest private-key "+keyPath+".new")
+	require.FileExists(t, keyPath)
+	require.NoFileExists(t, keyPath+".new")
+
+	age, err = client.KeyAge()
+	require.NoError(t, err)
+	require.Less(t, age, time.Hour, "the rotated key must replace the old one")
+
+	require.NoError(t, client.Teardown())
+	require.NoFileExists(t, keyPath)
+}
@@ -0,0 +1,40 @@
+package network
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network/wireguard"
+)
+
+func (nm *networkManager) newWireguardClient() *wireguard.Client {
+	return wireguard.NewClient(wireguard.DefaultInterfaceName, wireguard.DefaultListenPort, wireguard.DefaultKeyDir,
+		nm.netlink, nm.netio, nm.plClient)
+}
+
+func (nm *networkManager) getWireguardPublicKeyImpl(_ *network) (string, error) {
+	return nm.newWireguardClient().PublicKey()
+}
+
+func (nm *networkManager) syncWireguardPeersImpl(_ *network, peers []wireguard.Peer) error {
+	return nm.newWireguardClient().SyncPeers(peers)
+}
+
+func (nm *networkManager) rotateWireguardKeyImpl(_ *network, maxAge time.Duration) (bool, error) {
+	client := nm.newWireguardClient()
+	age, err := client.KeyAge()
+	if err != nil {
+		return false, err
+	}
+
+	if age < maxAge {
+		return false, nil
+	}
+
+	log.Printf("[net] Wireguard key is %v old, rotating it.", age.Round(time.Second))
+	if _, err = client.RotateKey(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
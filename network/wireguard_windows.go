@@ -0,0 +1,19 @@
+package network
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/network/wireguard"
+)
+
+func (nm *networkManager) getWireguardPublicKeyImpl(*network) (string, error) {
+	return "", wireguard.ErrNotSupported
+}
+
+func (nm *networkManager) syncWireguardPeersImpl(*network, []wireguard.Peer) error {
+	return wireguard.ErrNotSupported
+}
+
+func (nm *networkManager) rotateWireguardKeyImpl(*network, time.Duration) (bool, error) {
+	return false, wireguard.ErrNotSupported
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	kubernetesServiceNamespace = "default"
+	kubernetesServiceName      = "kubernetes"
+	anyIPv4CIDR                = "0.0.0.0/0"
+	maxPort                    = 65535
+)
+
+var errInvalidMeshPort = errors.New("service mesh port must be between 1 and 65535")
+
+// controlPlaneAllowlist returns the allowlist of the configured node CIDRs and API server endpoints. With a clientset,
+// it also allows the endpoints of the kubernetes service and the internal IPs of the node, which covers the kubelet's
+// health probes. Discovery failures are logged since the configured entries still apply, but invalid configured
+// entries are an error.
+func controlPlaneAllowlist(cfg npmconfig.ControlPlaneAllowlistConfig, clientset kubernetes.Interface, nodeName string) ([]*policies.AllowlistEntry, error) {
+	allowlist := make([]*policies.AllowlistEntry, 0)
+	for _, cidr := range cfg.NodeCIDRs {
+		entry, err := policies.NewAllowlistEntry(cidr, policies.UnspecifiedProtocol, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node CIDR in control plane allowlist: %w", err)
+		}
+		allowlist = append(allowlist, entry)
+	}
+	for _, endpoint := range cfg.APIServerEndpoints {
+		entry, err := apiServerAllowlistEntry(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API server endpoint in control plane allowlist: %w", err)
+		}
+		allowlist = append(allowlist, entry)
+	}
+
+	if clientset != nil {
+		allowlist = append(allowlist, discoverAPIServerEndpoints(clientset)...)
+		allowlist = append(allowlist, discoverNodeIPs(clientset, nodeName)...)
+	}
+
+	for _, entry := range allowlist {
+		klog.Infof("allowing control plane traffic under all network policies: %+v", entry)
+	}
+	return allowlist, nil
+}
+
+// serviceMeshAllowlist returns the allowlist of the excluded ports of the service meshes, from and to any address.
+func serviceMeshAllowlist(cfg npmconfig.ServiceMeshConfig) ([]*policies.AllowlistEntry, error) {
+	allowlist := make([]*policies.AllowlistEntry, 0, len(cfg.ExcludedPorts))
+	for _, port := range cfg.ExcludedPorts {
+		if port <= 0 || port > maxPort {
+			return nil, fmt.Errorf("%w: %d", errInvalidMeshPort, port)
+		}
+		entry, err := policies.NewAllowlistEntry(anyIPv4CIDR, policies.TCP, port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service mesh port %d: %w", port, err)
+		}
+		klog.Infof("allowing service mesh traffic under all network policies: %+v", entry)
+		allowlist = append(allowlist, entry)
+	}
+	return allowlist, nil
+}
+
+func apiServerAllowlistEntry(endpoint string) (*policies.AllowlistEntry, error) {
+	host, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint %s: %w", endpoint, err)
+	}
+	port, err := strconv.ParseInt(portStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse port of endpoint %s: %w", endpoint, err)
+	}
+	return policies.NewAllowlistEntry(host, policies.TCP, int32(port))
+}
+
+func discoverAPIServerEndpoints(clientset kubernetes.Interface) []*policies.AllowlistEntry {
+	endpoints, err := clientset.CoreV1().Endpoints(kubernetesServiceNamespace).Get(context.TODO(), kubernetesServiceName, metav1.GetOptions{})
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.NpmID, "Error: failed to get the endpoints of the kubernetes service for the control plane allowlist: %s", err.Error())
+		return nil
+	}
+
+	allowlist := make([]*policies.AllowlistEntry, 0)
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			for _, port := range subset.Ports {
+				if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+					continue
+				}
+				entry, err := policies.NewAllowlistEntry(address.IP, policies.TCP, port.Port)
+				if err != nil {
+					metrics.SendErrorLogAndMetric(util.NpmID, "Error: skipping API server endpoint in the control plane allowlist: %s", err.Error())
+					continue
+				}
+				allowlist = append(allowlist, entry)
+			}
+		}
+	}
+	return allowlist
+}
+
+func discoverNodeIPs(clientset kubernetes.Interface, nodeName string) []*policies.AllowlistEntry {
+	node, err := clientset.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.NpmID, "Error: failed to get node %s for the control plane allowlist: %s", nodeName, err.Error())
+		return nil
+	}
+
+	allowlist := make([]*policies.AllowlistEntry, 0)
+	for _, address := range node.Status.Addresses {
+		if address.Type != corev1.NodeInternalIP {
+			continue
+		}
+		entry, err := policies.NewAllowlistEntry(address.Address, policies.UnspecifiedProtocol, 0)
+		if err != nil {
+			metrics.SendErrorLogAndMetric(util.NpmID, "Error: skipping node IP in the control plane allowlist: %s", err.Error())
+			continue
+		}
+		allowlist = append(allowlist, entry)
+	}
+	return allowlist
+}
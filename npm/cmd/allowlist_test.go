@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestControlPlaneAllowlist(t *testing.T) {
+	metrics.InitializeAll()
+	cfg := npmconfig.ControlPlaneAllowlistConfig{
+		NodeCIDRs:          []string{"10.240.0.0/16"},
+		APIServerEndpoints: []string{"20.0.0.1:443"},
+	}
+
+	allowlist, err := controlPlaneAllowlist(cfg, nil, "node1")
+	require.NoError(t, err)
+	require.Equal(t, []*policies.AllowlistEntry{
+		{CIDR: "10.240.0.0/16", Protocol: policies.UnspecifiedProtocol},
+		{CIDR: "20.0.0.1/32", Protocol: policies.TCP, Port: 443},
+	}, allowlist)
+
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "kubernetes"},
+			Subsets: []corev1.EndpointSubset{
+				{
+					Addresses: []corev1.EndpointAddress{{IP: "20.0.0.2"}},
+					Ports:     []corev1.EndpointPort{{Port: 6443, Protocol: corev1.ProtocolTCP}},
+				},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeHostName, Address: "node1"},
+					{Type: corev1.NodeInternalIP, Address: "10.240.0.4"},
+				},
+			},
+		},
+	)
+	allowlist, err = controlPlaneAllowlist(npmconfig.ControlPlaneAllowlistConfig{}, clientset, "node1")
+	require.NoError(t, err)
+	require.Equal(t, []*policies.AllowlistEntry{
+		{CIDR: "20.0.0.2/32", Protocol: policies.TCP, Port: 6443},
+		{CIDR: "10.240.0.4/32", Protocol: policies.UnspecifiedProtocol},
+	}, allowlist)
+
+	// discovery failures aren't fatal
+	allowlist, err = controlPlaneAllowlist(cfg, k8sfake.NewSimpleClientset(), "node1")
+	require.NoError(t, err)
+	require.Len(t, allowlist, 2)
+
+	_, err = controlPlaneAllowlist(npmconfig.ControlPlaneAllowlistConfig{NodeCIDRs: []string{"not-a-cidr"}}, nil, "node1")
+	require.Error(t, err)
+	_, err = controlPlaneAllowlist(npmconfig.ControlPlaneAllowlistConfig{APIServerEndpoints: []string{"20.0.0.1"}}, nil, "node1")
+	require.Error(t, err)
+}
+
+func TestServiceMeshAllowlist(t *testing.T) {
+	allowlist, err := serviceMeshAllowlist(npmconfig.ServiceMeshConfig{Enabled: true, ExcludedPorts: []int32{15008, 4143}})
+	require.NoError(t, err)
+	require.Equal(t, []*policies.AllowlistEntry{
+		{CIDR: "0.0.0.0/0", Protocol: policies.TCP, Port: 15008},
+		{CIDR: "0.0.0.0/0", Protocol: policies.TCP, Port: 4143},
+	}, allowlist)
+
+	allowlist, err = serviceMeshAllowlist(npmconfig.ServiceMeshConfig{})
+	require.NoError(t, err)
+	require.Empty(t, allowlist)
+
+	_, err = serviceMeshAllowlist(npmconfig.ServiceMeshConfig{ExcludedPorts: []int32{0}})
+	require.ErrorIs(t, err, errInvalidMeshPort)
+	_, err = serviceMeshAllowlist(npmconfig.ServiceMeshConfig{ExcludedPorts: []int32{70000}})
+	require.ErrorIs(t, err, errInvalidMeshPort)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/deniedflows"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/klog"
+)
+
+// configureDeniedFlowLog adds the NFLOG rules for the denied traffic to the dataplane config.
+func configureDeniedFlowLog(cfg npmconfig.DeniedFlowLogConfig) {
+	npmV2DataplaneCfg.LogDenied = cfg.Enabled
+	npmV2DataplaneCfg.LogDeniedGroup = cfg.GroupOrDefault()
+	npmV2DataplaneCfg.LogDeniedPerSecond = cfg.PerSecond
+}
+
+// startDeniedFlowLog harvests the denied flows of the dataplane until the stop channel is closed, and returns the log
+// of the latest ones. It returns nil if the log is disabled or the OS has no source of denied flows, in which case
+// NPM runs without it.
+func startDeniedFlowLog(cfg npmconfig.DeniedFlowLogConfig, dp *dataplane.DataPlane, stopChannel <-chan struct{}) *deniedflows.Log {
+	if !cfg.Enabled {
+		return nil
+	}
+	source, err := deniedflows.NewNFLOGSource(cfg.GroupOrDefault())
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.NpmID, "error: failed to start denied flow log: %s", err.Error())
+		return nil
+	}
+
+	var export func(*deniedflows.Event)
+	if cfg.ExportTelemetry {
+		export = func(event *deniedflows.Event) {
+			metrics.SendLog(util.NpmID, event.String(), metrics.DonotPrint)
+		}
+	}
+	log := deniedflows.NewLog(cfg.BufferSizeOrDefault())
+	go deniedflows.NewHarvester(source, log, dp.GuessDenyingPolicies, export).Run(stopChannel)
+	klog.Infof("logging denied flows of NFLOG group %d", cfg.GroupOrDefault())
+	return log
+}
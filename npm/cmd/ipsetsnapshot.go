@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/klog"
+)
+
+const snapshotFilePerm = 0o600
+
+// restoreIPSetSnapshot seeds the dataplane with the snapshot in the file if there is one, and removes the file so
+// that an old snapshot isn't restored again. NPM starts without the snapshot if it can't be restored, the
+// controllers add the sets when they sync.
+func restoreIPSetSnapshot(dp *dataplane.DataPlane, path string) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		if removeErr := os.Remove(path); removeErr != nil {
+			klog.Warningf("failed to remove ipset snapshot %s: %v", path, removeErr)
+		}
+
+		snapshot := &ipsets.Snapshot{}
+		if err = json.Unmarshal(b, snapshot); err == nil {
+			err = dp.RestoreIPSets(snapshot)
+		}
+	}
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.NpmID, "failed to restore ipset snapshot %s: %v", path, err)
+		return
+	}
+	klog.Infof("restored ipset snapshot %s", path)
+}
+
+// saveIPSetSnapshot writes the snapshot of the dataplane's ipsets to the file. The file is replaced at once, so
+// that a restart never finds a partial snapshot.
+func saveIPSetSnapshot(dp *dataplane.DataPlane, path string) error {
+	b, err := json.Marshal(dp.SnapshotIPSets())
+	if err != nil {
+		return fmt.Errorf("failed to marshal ipset snapshot: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, snapshotFilePerm); err != nil {
+		return fmt.Errorf("failed to write ipset snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace ipset snapshot: %w", err)
+	}
+	return nil
+}
+
+// saveIPSetSnapshotOnTermination saves the ipset snapshot when NPM is terminated, and exits.
+func saveIPSetSnapshotOnTermination(dp *dataplane.DataPlane, path string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-signals
+		klog.Infof("received %s, saving ipset snapshot to %s", sig, path)
+		if err := saveIPSetSnapshot(dp, path); err != nil {
+			klog.Errorf("failed to save ipset snapshot: %v", err)
+		}
+		klog.Flush()
+		os.Exit(0)
+	}()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	controllersv2 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v2"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var errEventsFileNotSpecified = errors.New("events file is not specified")
+
+func newSimulateCmd() *cobra.Command {
+	simulateCmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Replays recorded pod, namespace and network policy events against an in-memory dataplane",
+		Long: `Replays a stream of JSON events like {"type": "ADDED", "kind": "Pod", "object": {...}} through the v2 controllers
+against an in-memory dataplane, then prints the sync times, the applied ipsets and the policies.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			eventsF, _ := cmd.Flags().GetString("events-file")
+			if eventsF == "" {
+				return errEventsFileNotSpecified
+			}
+
+			config := &npmconfig.Config{}
+			if err := viper.Unmarshal(config); err != nil {
+				return fmt.Errorf("failed to load config with err %w", err)
+			}
+
+			f, err := os.Open(eventsF)
+			if err != nil {
+				return fmt.Errorf("failed to open events file: %w", err)
+			}
+			defer f.Close()
+
+			metrics.InitializeAll()
+			dp := dataplane.NewInMemoryDataplane(simulatorDataplaneCfg(config.Toggles))
+			simulator := controllersv2.NewSimulator(dp)
+
+			start := time.Now()
+			if err := simulator.ReplayAll(f); err != nil {
+				return fmt.Errorf("failed to replay events: %w", err)
+			}
+			printSimulation(cmd, simulator, dp, time.Since(start))
+			return nil
+		},
+	}
+
+	simulateCmd.Flags().StringP("events-file", "e", "", "Set the path of the recorded events")
+
+	return simulateCmd
+}
+
+func simulatorDataplaneCfg(toggles npmconfig.Toggles) *dataplane.Config {
+	ipsetMode := ipsets.ApplyAllIPSets
+	if toggles.ApplyIPSetsOnNeed {
+		ipsetMode = ipsets.ApplyOnNeed
+	}
+	return &dataplane.Config{
+		IPSetManagerCfg: &ipsets.IPSetManagerCfg{
+			NetworkName: npmV2DataplaneCfg.IPSetManagerCfg.NetworkName,
+			IPSetMode:   ipsetMode,
+		},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{
+			PolicyMode:           policies.IPSetPolicyMode,
+			PlaceAzureChainFirst: toggles.PlaceAzureChainFirst,
+		},
+	}
+}
+
+func printSimulation(cmd *cobra.Command, simulator *controllersv2.Simulator, dp *dataplane.InMemoryDataplane, elapsed time.Duration) {
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Replayed events in %s\n", elapsed)
+	for _, kind := range simulator.Kinds() {
+		stats := simulator.Stats(kind)
+		fmt.Fprintf(out, "  %s: %d events, %d errors, total %s, max %s\n", kind, stats.Events, stats.Errors, stats.Total, stats.Max)
+	}
+
+	sets := dp.IPSets().AppliedSets()
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(out, "IPSets (%d):\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s: [%s]\n", name, strings.Join(sets[name], ", "))
+	}
+
+	policyKeys := dp.GetAllPolicies()
+	fmt.Fprintf(out, "Policies (%d):\n", len(policyKeys))
+	for _, key := range policyKeys {
+		fmt.Fprintf(out, "  %s\n", key)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	simulateEventsFile = "testdata/simulate-events.json"
+
+	simulateCmdString = "simulate"
+	eventsFileFlag    = "-e"
+)
+
+func TestSimulateCmd(t *testing.T) {
+	tests := []*testCases{
+		{
+			name:    "no events file",
+			args:    []string{simulateCmdString},
+			wantErr: true,
+		},
+		{
+			name:    "bad events file",
+			args:    []string{simulateCmdString, eventsFileFlag, nonExistingFile},
+			wantErr: true,
+		},
+	}
+
+	testCommand(t, tests)
+}
+
+func TestSimulateCmdOutput(t *testing.T) {
+	rootCMD := NewRootCmd()
+	b := bytes.NewBufferString("")
+	rootCMD.SetOut(b)
+	rootCMD.SetArgs([]string{simulateCmdString, eventsFileFlag, simulateEventsFile})
+	require.NoError(t, rootCMD.Execute())
+
+	out := b.String()
+	require.Contains(t, out, "Namespace: 1 events, 0 errors")
+	require.Contains(t, out, "Pod: 4 events, 0 errors")
+	require.Contains(t, out, "NetworkPolicy: 1 events, 0 errors")
+	require.Contains(t, out, "  podlabel-app:api: [10.0.0.2]\n  podlabel-app:web: []\n")
+	require.Contains(t, out, "Policies (1):\n  frontend/allow-web\n")
+}
@@ -5,17 +5,22 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"reflect"
 	"time"
 
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm"
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	restserver "github.com/Azure/azure-container-networking/npm/http/server"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/deniedflows"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ratelimiter"
+	"github.com/Azure/azure-container-networking/npm/pkg/eventing"
 	"github.com/Azure/azure-container-networking/npm/pkg/models"
 	"github.com/Azure/azure-container-networking/npm/util"
 	"github.com/spf13/cobra"
@@ -75,10 +80,16 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		klog.Infof("NPM is running on Linux Dataplane")
 	}
 	klog.Infof("starting NPM version %d with image %s", config.NPMVersion(), version)
+	iptables.ReleaseVersion = version
+	iptables.JournalPath = iptables.DefaultJournalPath
+
+	if err := config.NamespaceScope.Validate(); err != nil {
+		return fmt.Errorf("failed to load namespace scope: %w", err)
+	}
 
 	var err error
 
-	err = initLogging()
+	err = initLogging(config)
 	if err != nil {
 		return err
 	}
@@ -121,6 +132,8 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 	k8sServerVersion := k8sServerVersion(clientset)
 
 	var dp dataplane.GenericDataplane
+	var v2Dataplane *dataplane.DataPlane
+	var deniedFlowLog *deniedflows.Log
 	stopChannel := wait.NeverStop
 	if config.Toggles.EnableV2NPM {
 		// update the dataplane config
@@ -130,20 +143,70 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		} else {
 			npmV2DataplaneCfg.IPSetMode = ipsets.ApplyAllIPSets
 		}
+		npmV2DataplaneCfg.UseKernelTimeouts = config.Toggles.UseIPSetKernelTimeouts
+		npmV2DataplaneCfg.RejectDenied = config.Toggles.RejectDeniedTraffic
+		npmV2DataplaneCfg.HoldPoliciesUntilBootupFinishes = config.Toggles.HoldPoliciesUntilSynced
+		npmV2DataplaneCfg.RateLimiterCfg = dataplaneRateLimiterCfg(config.DataplaneRateLimit)
+		npmV2DataplaneCfg.EnforcedDirection, err = policies.ParseEnforcedDirection(config.EnforcedPolicyDirection)
+		if err != nil {
+			return fmt.Errorf("failed to load enforced policy direction: %w", err)
+		}
+		if config.Toggles.EnableControlPlaneAllowlist {
+			npmV2DataplaneCfg.Allowlist, err = controlPlaneAllowlist(config.ControlPlaneAllowlist, clientset, models.GetNodeName())
+			if err != nil {
+				return fmt.Errorf("failed to create control plane allowlist: %w", err)
+			}
+		}
+		npmV2DataplaneCfg.MeshCompatibility = config.ServiceMesh.Enabled
+		meshAllowlist, err := serviceMeshAllowlist(config.ServiceMesh)
+		if err != nil {
+			return fmt.Errorf("failed to create service mesh allowlist: %w", err)
+		}
+		npmV2DataplaneCfg.Allowlist = append(npmV2DataplaneCfg.Allowlist, meshAllowlist...)
+		configureDeniedFlowLog(config.DeniedFlowLog)
 
-		dp, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, stopChannel)
+		v2Dataplane, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, stopChannel)
 		if err != nil {
 			return fmt.Errorf("failed to create dataplane with error %w", err)
 		}
-		dp.RunPeriodicTasks()
+		if config.IPSetSnapshotFile != "" {
+			restoreIPSetSnapshot(v2Dataplane, config.IPSetSnapshotFile)
+			saveIPSetSnapshotOnTermination(v2Dataplane, config.IPSetSnapshotFile)
+		}
+		v2Dataplane.RunPeriodicTasks()
+		deniedFlowLog = startDeniedFlowLog(config.DeniedFlowLog, v2Dataplane, stopChannel)
+		dp = v2Dataplane
 	}
-	npMgr := npm.NewNetworkPolicyManager(config, factory, dp, exec.New(), version, k8sServerVersion)
+	var recorder *eventing.Recorder
+	if config.Toggles.EnableV2NPM && config.Toggles.EnableDataplaneEvents {
+		recorder = eventing.NewRecorder(clientset, models.GetNodeName())
+	}
+	npMgr := npm.NewNetworkPolicyManager(config, factory, dp, exec.New(), version, k8sServerVersion, recorder)
 	err = metrics.CreateTelemetryHandle(config.NPMVersion(), version, npm.GetAIMetadata())
 	if err != nil {
 		klog.Infof("CreateTelemetryHandle failed with error %v. AITelemetry is not initialized.", err)
 	}
 
-	go restserver.NPMRestServerListenAndServe(config, npMgr)
+	if config.OTLP.Enabled() {
+		if err = metrics.CreateOTLPTelemetryHandle(version, config.OTLP); err != nil {
+			klog.Infof("CreateOTLPTelemetryHandle failed with error %v. OTLP telemetry is not initialized.", err)
+		}
+	}
+
+	configWatcher := npmconfig.NewWatcher(viper.ConfigFileUsed(), config)
+	debugEncoders := restserver.DebugEncoders{NPM: npMgr, Config: configWatcher}
+	if v2Dataplane != nil {
+		debugEncoders.IPSetSnapshot = v2Dataplane.IPSetSnapshotEncoder()
+		debugEncoders.MeshCompatibility = v2Dataplane.MeshCompatibilityEncoder()
+		debugEncoders.RuleCounters = v2Dataplane.RuleCountersEncoder()
+	}
+	if deniedFlowLog != nil {
+		debugEncoders.DeniedFlows = deniedFlowLog
+	}
+	go restserver.NPMRestServerListenAndServe(config, debugEncoders)
+	if config.Toggles.EnablePolicyAPI {
+		go restserver.NPMPolicyAPIListenAndServe(config, npMgr)
+	}
 
 	metrics.SendLog(util.NpmID, "starting NPM", metrics.PrintLog)
 	if err = npMgr.Start(config, stopChannel); err != nil {
@@ -151,17 +214,64 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		return fmt.Errorf("failed to start with err: %w", err)
 	}
 
+	if config.Toggles.EnableConfigHotReload {
+		configWatcher.OnReload(func(oldConfig, newConfig npmconfig.Config) error {
+			return reloadConfig(oldConfig, newConfig, npMgr, v2Dataplane)
+		})
+		go configWatcher.Run(stopChannel)
+	}
+
 	select {}
 }
 
-func initLogging() error {
+// reloadConfig applies the changes to the config which are safe at runtime. The dataplane is nil in v1 NPM.
+func reloadConfig(oldConfig, newConfig npmconfig.Config, npMgr *npm.NetworkPolicyManager, dp *dataplane.DataPlane) error {
+	if oldConfig.LogLevel != newConfig.LogLevel {
+		level, err := newConfig.ParseLogLevel()
+		if err != nil {
+			return fmt.Errorf("failed to reload log level: %w", err)
+		}
+		log.SetLevel(level)
+	}
+
+	if oldConfig.DataplaneRateLimit != newConfig.DataplaneRateLimit && dp != nil {
+		dp.SetRateLimit(dataplaneRateLimiterCfg(newConfig.DataplaneRateLimit))
+	}
+
+	if !reflect.DeepEqual(oldConfig.NamespaceScope, newConfig.NamespaceScope) {
+		if err := npMgr.UpdateNamespaceScope(newConfig.NamespaceScope); err != nil {
+			metrics.SendErrorLogAndMetric(util.NpmID, "failed to reload namespace scope: %v", err)
+			return fmt.Errorf("failed to reload namespace scope: %w", err)
+		}
+	}
+	return nil
+}
+
+func dataplaneRateLimiterCfg(cfg npmconfig.DataplaneRateLimitConfig) *ratelimiter.Config {
+	return &ratelimiter.Config{
+		OpsPerSecond: cfg.OpsPerSecond,
+		Burst:        cfg.Burst,
+	}
+}
+
+func initLogging(config npmconfig.Config) error {
+	level, err := config.ParseLogLevel()
+	if err != nil {
+		return fmt.Errorf("failed to load log level: %w", err)
+	}
 	log.SetName("azure-npm")
-	log.SetLevel(log.LevelInfo)
+	log.SetLevel(level)
 	if err := log.SetTargetLogDirectory(log.TargetStdout, ""); err != nil {
 		log.Logf("Failed to configure logging, err:%v.", err)
 		return fmt.Errorf("%w", err)
 	}
 
+	if config.Toggles.EnableJSONLogging {
+		if err := log.SetFormat(log.FormatJSON); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -14,6 +14,7 @@ import (
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/goalstateprocessor"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
 	"github.com/Azure/azure-container-networking/npm/pkg/models"
 	"github.com/Azure/azure-container-networking/npm/pkg/transport"
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -58,7 +59,7 @@ func startDaemon(config npmconfig.Config) error {
 
 	addr := config.Transport.Address + ":" + strconv.Itoa(config.Transport.ServicePort)
 	ctx := context.Background()
-	err := initLogging()
+	err := initLogging(config)
 	if err != nil {
 		klog.Errorf("failed to init logging : %v", err)
 		return err
@@ -66,15 +67,43 @@ func startDaemon(config npmconfig.Config) error {
 
 	var dp dataplane.GenericDataplane
 
-	dp, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, wait.NeverStop)
+	npmV2DataplaneCfg.RateLimiterCfg = dataplaneRateLimiterCfg(config.DataplaneRateLimit)
+	npmV2DataplaneCfg.EnforcedDirection, err = policies.ParseEnforcedDirection(config.EnforcedPolicyDirection)
+	if err != nil {
+		return fmt.Errorf("failed to load enforced policy direction: %w", err)
+	}
+	if config.Toggles.EnableControlPlaneAllowlist {
+		// the daemon has no kubernetes client, so only the configured entries apply
+		npmV2DataplaneCfg.Allowlist, err = controlPlaneAllowlist(config.ControlPlaneAllowlist, nil, models.GetNodeName())
+		if err != nil {
+			return fmt.Errorf("failed to create control plane allowlist: %w", err)
+		}
+	}
+	npmV2DataplaneCfg.RejectDenied = config.Toggles.RejectDeniedTraffic
+	npmV2DataplaneCfg.MeshCompatibility = config.ServiceMesh.Enabled
+	meshAllowlist, err := serviceMeshAllowlist(config.ServiceMesh)
+	if err != nil {
+		return fmt.Errorf("failed to create service mesh allowlist: %w", err)
+	}
+	npmV2DataplaneCfg.Allowlist = append(npmV2DataplaneCfg.Allowlist, meshAllowlist...)
+	configureDeniedFlowLog(config.DeniedFlowLog)
+	v2Dataplane, err := dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, wait.NeverStop)
 	if err != nil {
 		klog.Errorf("failed to create dataplane: %v", err)
 		return fmt.Errorf("failed to create dataplane with error %w", err)
 	}
+	dp = v2Dataplane
 
 	dp.RunPeriodicTasks()
 	// TODO Daemon should implement cache encoder
-	go restserver.NPMRestServerListenAndServe(config, nil)
+	debugEncoders := restserver.DebugEncoders{
+		MeshCompatibility: v2Dataplane.MeshCompatibilityEncoder(),
+		RuleCounters:      v2Dataplane.RuleCountersEncoder(),
+	}
+	if deniedFlowLog := startDeniedFlowLog(config.DeniedFlowLog, v2Dataplane, wait.NeverStop); deniedFlowLog != nil {
+		debugEncoders.DeniedFlows = deniedFlowLog
+	}
+	go restserver.NPMRestServerListenAndServe(config, debugEncoders)
 
 	client, err := transport.NewEventsClient(ctx, pod, node, addr)
 	if err != nil {
@@ -99,6 +128,12 @@ func startDaemon(config npmconfig.Config) error {
 		klog.Infof("CreateTelemetryHandle failed with error %v. AITelemetry is not initialized.", err)
 	}
 
+	if config.OTLP.Enabled() {
+		if err = metrics.CreateOTLPTelemetryHandle(version, config.OTLP); err != nil {
+			klog.Infof("CreateOTLPTelemetryHandle failed with error %v. OTLP telemetry is not initialized.", err)
+		}
+	}
+
 	err = n.Start(config, wait.NeverStop)
 	if err != nil {
 		klog.Errorf("failed to start dataplane : %v", err)
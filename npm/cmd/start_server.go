@@ -54,7 +54,7 @@ func startControlplane(config npmconfig.Config, flags npmconfig.Flags) error {
 
 	var err error
 
-	err = initLogging()
+	err = initLogging(config)
 	if err != nil {
 		klog.Errorf("failed to init logging : %v", err)
 		return err
@@ -118,7 +118,13 @@ func startControlplane(config npmconfig.Config, flags npmconfig.Flags) error {
 		klog.Infof("CreateTelemetryHandle failed with error %v. AITelemetry is not initialized.", err)
 	}
 
-	go restserver.NPMRestServerListenAndServe(config, npMgr)
+	if config.OTLP.Enabled() {
+		if err = metrics.CreateOTLPTelemetryHandle(version, config.OTLP); err != nil {
+			klog.Infof("CreateOTLPTelemetryHandle failed with error %v. OTLP telemetry is not initialized.", err)
+		}
+	}
+
+	go restserver.NPMRestServerListenAndServe(config, restserver.DebugEncoders{NPM: npMgr})
 
 	metrics.SendLog(util.FanOutServerID, "starting fan-out server", metrics.PrintLog)
 
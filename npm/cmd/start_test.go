@@ -4,12 +4,20 @@ import (
 	"testing"
 
 	"github.com/Azure/azure-container-networking/log"
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	"github.com/stretchr/testify/require"
 )
 
 func TestInitLogging(t *testing.T) {
 	expectedLogPath := log.LogPath
-	err := initLogging()
+	err := initLogging(npmconfig.Config{})
 	require.NoError(t, err)
 	require.Equal(t, expectedLogPath, log.GetLogDirectory())
+
+	err = initLogging(npmconfig.Config{Toggles: npmconfig.Toggles{EnableJSONLogging: true}})
+	require.NoError(t, err)
+	require.NoError(t, log.SetFormat(log.FormatText))
+
+	err = initLogging(npmconfig.Config{LogLevel: "loud"})
+	require.Error(t, err)
 }
@@ -1,12 +1,26 @@
 package npmconfig
 
-import "github.com/Azure/azure-container-networking/npm/util"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/telemetry"
+	"k8s.io/apimachinery/pkg/labels"
+)
 
 const (
 	defaultResyncPeriod    = 15
 	defaultListeningPort   = 10091
 	defaultGrpcPort        = 10092
 	defaultGrpcServicePort = 9002
+	defaultPolicyAPIPort   = 10093
+	// DefaultDeniedFlowLogGroup is the NFLOG group of the denied traffic unless configured
+	DefaultDeniedFlowLogGroup = 100
+	// DefaultDeniedFlowLogBufferSize is the number of denied flow events kept unless configured
+	DefaultDeniedFlowLogBufferSize = 1000
 	// ConfigEnvPath is what's used by viper to load config path
 	ConfigEnvPath = "NPM_CONFIG"
 
@@ -27,6 +41,10 @@ var DefaultConfig = Config{
 		ServicePort: defaultGrpcServicePort,
 	},
 
+	PolicyAPI: PolicyAPIConfig{
+		Port: defaultPolicyAPIPort,
+	},
+
 	Toggles: Toggles{
 		EnablePrometheusMetrics: true,
 		EnablePprof:             true,
@@ -34,6 +52,10 @@ var DefaultConfig = Config{
 		EnableV2NPM:             true,
 		PlaceAzureChainFirst:    util.PlaceAzureChainFirst,
 		ApplyIPSetsOnNeed:       false,
+		// allow the control plane traffic unless turned off explicitly
+		EnableControlPlaneAllowlist: true,
+		EnableDataplaneEvents:       true,
+		HoldPoliciesUntilSynced:     true,
 	},
 }
 
@@ -46,6 +68,99 @@ type GrpcServerConfig struct {
 	ServicePort int `json:"ServicePort,omitempty"`
 }
 
+// PolicyAPIConfig configures the API serving the cluster policy state. It only listens on localhost or on a
+// unix socket.
+type PolicyAPIConfig struct {
+	// Port is the port on localhost on which the API listens when SocketPath is empty
+	Port int `json:"Port,omitempty"`
+	// SocketPath is the unix socket on which the API listens instead of the port when set
+	SocketPath string `json:"SocketPath,omitempty"`
+	// TokenFile holds the bearer token which the requests must present when set
+	TokenFile string `json:"TokenFile,omitempty"`
+}
+
+// DataplaneRateLimitConfig limits the writes of the v2 dataplane to ipset, iptables and HNS with a token bucket.
+type DataplaneRateLimitConfig struct {
+	// OpsPerSecond is the sustained rate of writes. The limit is disabled when it's zero.
+	OpsPerSecond float64 `json:"OpsPerSecond,omitempty"`
+	// Burst is the number of writes which may run at once after a quiet period
+	Burst int `json:"Burst,omitempty"`
+}
+
+// NamespaceScopeConfig limits the namespaces whose pods and network policies v2 NPM programs. All namespaces are in
+// scope when it's empty.
+type NamespaceScopeConfig struct {
+	// Include are the only namespaces in scope when set
+	Include []string `json:"Include,omitempty"`
+	// Exclude are the namespaces out of scope, even if they are included or match the label selector
+	Exclude []string `json:"Exclude,omitempty"`
+	// LabelSelector selects the namespaces in scope by their labels when set, e.g. "npm=enabled"
+	LabelSelector string `json:"LabelSelector,omitempty"`
+}
+
+// Validate returns an error if the label selector can't be parsed.
+func (c NamespaceScopeConfig) Validate() error {
+	if c.LabelSelector == "" {
+		return nil
+	}
+	if _, err := labels.Parse(c.LabelSelector); err != nil {
+		return fmt.Errorf("invalid namespace label selector %q: %w", c.LabelSelector, err)
+	}
+	return nil
+}
+
+// ControlPlaneAllowlistConfig is the traffic which v2 NPM allows even when network policies would block it, so that
+// no policy can cut the pods or the node off from the control plane. Besides these, NPM allows the endpoints of the
+// kubernetes service and the addresses of its node, which it discovers at startup.
+type ControlPlaneAllowlistConfig struct {
+	// NodeCIDRs are allowed on all ports, e.g. the subnet of the nodes
+	NodeCIDRs []string `json:"NodeCIDRs,omitempty"`
+	// APIServerEndpoints are the <ip>:<port> endpoints of the API server allowed over TCP, e.g. when NPM runs
+	// without access to the kubernetes service
+	APIServerEndpoints []string `json:"APIServerEndpoints,omitempty"`
+}
+
+// ServiceMeshConfig lets v2 NPM coexist with service meshes such as Istio, including the ambient mode, and Linkerd,
+// which redirect the traffic of the pods with iptables.
+type ServiceMeshConfig struct {
+	// Enabled places the jump to the NPM chains after the jumps to the chains of the meshes in Linux
+	Enabled bool `json:"Enabled,omitempty"`
+	// ExcludedPorts are the TCP ports of the meshes which NPM allows under all network policies, e.g. 15008 for the
+	// HBONE tunnel of Istio ambient
+	ExcludedPorts []int32 `json:"ExcludedPorts,omitempty"`
+}
+
+// DeniedFlowLogConfig logs the traffic which network policies deny in v2 NPM on Linux, with NFLOG rules before the
+// rules which drop or reject it. NPM harvests the logged packets into events which the debug API serves. Windows has
+// no log of the flows blocked by HNS ACLs, so it's not supported there.
+type DeniedFlowLogConfig struct {
+	Enabled bool `json:"Enabled,omitempty"`
+	// Group is the NFLOG group of the rules, DefaultDeniedFlowLogGroup when zero
+	Group uint16 `json:"Group,omitempty"`
+	// PerSecond limits the packets logged per second in each direction when positive
+	PerSecond int `json:"PerSecond,omitempty"`
+	// BufferSize is the number of latest events which the debug API serves, DefaultDeniedFlowLogBufferSize when zero
+	BufferSize int `json:"BufferSize,omitempty"`
+	// ExportTelemetry sends each event to telemetry as well
+	ExportTelemetry bool `json:"ExportTelemetry,omitempty"`
+}
+
+// GroupOrDefault returns the NFLOG group of the rules.
+func (c DeniedFlowLogConfig) GroupOrDefault() uint16 {
+	if c.Group == 0 {
+		return DefaultDeniedFlowLogGroup
+	}
+	return c.Group
+}
+
+// BufferSizeOrDefault returns the number of events which the debug API serves.
+func (c DeniedFlowLogConfig) BufferSizeOrDefault() int {
+	if c.BufferSize <= 0 {
+		return DefaultDeniedFlowLogBufferSize
+	}
+	return c.BufferSize
+}
+
 type Config struct {
 	ResyncPeriodInMinutes int `json:"ResyncPeriodInMinutes,omitempty"`
 
@@ -54,16 +169,80 @@ type Config struct {
 
 	Transport GrpcServerConfig `json:"Transport,omitempty"`
 
+	PolicyAPI PolicyAPIConfig `json:"PolicyAPI,omitempty"`
+
+	DataplaneRateLimit DataplaneRateLimitConfig `json:"DataplaneRateLimit,omitempty"`
+
+	NamespaceScope NamespaceScopeConfig `json:"NamespaceScope,omitempty"`
+
+	ControlPlaneAllowlist ControlPlaneAllowlistConfig `json:"ControlPlaneAllowlist,omitempty"`
+
+	ServiceMesh ServiceMeshConfig `json:"ServiceMesh,omitempty"`
+
+	DeniedFlowLog DeniedFlowLogConfig `json:"DeniedFlowLog,omitempty"`
+
 	Toggles Toggles `json:"Toggles,omitempty"`
+
+	// LogLevel is the chattiness of the NPM log: "error", "warning", "info" (the default) or "debug"
+	LogLevel string `json:"LogLevel,omitempty"`
+
+	// OTLP exports the telemetry to an OpenTelemetry collector as well as AI when its endpoint is set
+	OTLP telemetry.OTLPConfig `json:"OTLP,omitempty"`
+
+	// IPSetSnapshotFile is where v2 NPM saves the snapshot of its ipsets when it's terminated, and restores them from
+	// when it starts, so that the sets are back in the dataplane before the controllers sync. A snapshot served by the
+	// debug API of NPM with another dataplane backend can be put there as well. Disabled when empty.
+	IPSetSnapshotFile string `json:"IPSetSnapshotFile,omitempty"`
+
+	// EnforcedPolicyDirection limits v2 NPM to enforcing network policies on the "Ingress" or the "Egress" traffic of
+	// the pods, which reduces the number of rules. Both directions are enforced when it's "Both" or empty.
+	EnforcedPolicyDirection string `json:"EnforcedPolicyDirection,omitempty"`
 }
 
 type Toggles struct {
-	EnablePrometheusMetrics bool
-	EnablePprof             bool
-	EnableHTTPDebugAPI      bool
-	EnableV2NPM             bool
-	PlaceAzureChainFirst    bool
-	ApplyIPSetsOnNeed       bool
+	EnablePrometheusMetrics     bool
+	EnablePprof                 bool
+	EnableHTTPDebugAPI          bool
+	EnablePolicyAPI             bool
+	EnableV2NPM                 bool
+	PlaceAzureChainFirst        bool
+	ApplyIPSetsOnNeed           bool
+	EnableJSONLogging           bool
+	EnableControlPlaneAllowlist bool
+	// EnableDataplaneEvents reports the dataplane failures of v2 NPM with Events on the network policies and a
+	// condition on the node
+	EnableDataplaneEvents bool
+	// UseIPSetKernelTimeouts creates the hash sets with timeout support in Linux so that the kernel also expires the
+	// ipset members added with a TTL
+	UseIPSetKernelTimeouts bool
+	// EnableConfigHotReload watches the config file, e.g. the mounted ConfigMap, and applies the changes to the
+	// tunables which are safe to change at runtime without restarting NPM
+	EnableConfigHotReload bool
+	// RejectDeniedTraffic makes v2 NPM reject the traffic denied by network policies in Linux, with a TCP reset or an
+	// ICMP admin-prohibited error, instead of dropping it. Windows blocks the traffic either way. Single policies can
+	// reject their denied traffic with the npm.azure.com/reject-denied-traffic annotation.
+	RejectDeniedTraffic bool
+	// HoldPoliciesUntilSynced keeps the network policies out of the v2 dataplane after a restart until the ipsets of
+	// the existing namespaces and pods are filled, so that no legitimate traffic is denied meanwhile
+	HoldPoliciesUntilSynced bool
+}
+
+var errInvalidLogLevel = errors.New("invalid log level")
+
+// ParseLogLevel returns the level of the log package for the config, e.g. log.LevelInfo for "info" or "".
+func (c Config) ParseLogLevel() (int, error) {
+	switch strings.ToLower(c.LogLevel) {
+	case "error":
+		return log.LevelError, nil
+	case "warning":
+		return log.LevelWarning, nil
+	case "", "info":
+		return log.LevelInfo, nil
+	case "debug":
+		return log.LevelDebug, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", errInvalidLogLevel, c.LogLevel)
+	}
 }
 
 type Flags struct {
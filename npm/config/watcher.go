@@ -0,0 +1,192 @@
+package npmconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// DefaultReloadInterval is how often the Watcher checks the config file. The kubelet takes up to a minute to update
+// a mounted ConfigMap anyway.
+const DefaultReloadInterval = 30 * time.Second
+
+// reloadableFields are the fields of the Config which the Watcher applies at runtime. The other fields, such as the
+// ports, the resync period, and the toggles which select the version or backend of NPM, are only safe to change on
+// a restart.
+var reloadableFields = map[string]struct{}{
+	"DataplaneRateLimit": {},
+	"NamespaceScope":     {},
+	"LogLevel":           {},
+}
+
+// ReloadFunc applies the changes to the reloadable fields from the old to the new config at runtime.
+type ReloadFunc func(oldConfig, newConfig Config) error
+
+// EffectiveConfig is the config which NPM runs with, as served by the debug API.
+type EffectiveConfig struct {
+	Config Config `json:"Config"`
+	// PendingRestart are the fields which differ in the config file but only take effect when NPM restarts
+	PendingRestart []string `json:"PendingRestart"`
+}
+
+/*
+Watcher reloads the config file, e.g. the mounted NPM ConfigMap, and applies the changes to the reloadable fields
+without restarting NPM.
+
+The file is polled rather than watched with inotify since the kubelet updates a ConfigMap volume by swapping a
+symlink to a new directory, which a watch on the file itself wouldn't see. A file which is missing or hasn't
+changed since the last reload is ignored.
+
+If a ReloadFunc fails, the effective config is kept and the reload is retried on the next poll, so a ReloadFunc
+must be idempotent.
+*/
+type Watcher struct {
+	sync.RWMutex
+	path     string
+	interval time.Duration
+	// effective is the config which NPM started with and the reloadable fields of the last reload
+	effective      Config
+	pendingRestart []string
+	// lastContent is the content of the file which was last applied
+	lastContent []byte
+	reloadFuncs []ReloadFunc
+}
+
+// NewWatcher returns a Watcher of the config file at the path for NPM which started with the config.
+func NewWatcher(path string, config Config) *Watcher {
+	return &Watcher{
+		path:           path,
+		interval:       DefaultReloadInterval,
+		effective:      config,
+		pendingRestart: make([]string, 0),
+	}
+}
+
+// OnReload calls f with the old and new config whenever a reloadable field changes. It must be called before Run.
+func (w *Watcher) OnReload(f ReloadFunc) {
+	w.Lock()
+	defer w.Unlock()
+	w.reloadFuncs = append(w.reloadFuncs, f)
+}
+
+// Run reloads the config file periodically until the stop channel is closed.
+func (w *Watcher) Run(stopCh <-chan struct{}) {
+	klog.Infof("[ConfigWatcher] reloading config from %s every %v", w.path, w.interval)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := w.Reload(); err != nil {
+				klog.Errorf("[ConfigWatcher] failed to reload config from %s: %v", w.path, err)
+			}
+		}
+	}
+}
+
+// Reload reads the config file and applies the changes to the reloadable fields.
+func (w *Watcher) Reload() error {
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// NPM runs with the default config without a file
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	if w.lastContent != nil && bytes.Equal(content, w.lastContent) {
+		return nil
+	}
+
+	var fileConfig Config
+	if err := json.Unmarshal(content, &fileConfig); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := fileConfig.NamespaceScope.Validate(); err != nil {
+		return err
+	}
+	if _, err := fileConfig.ParseLogLevel(); err != nil {
+		return err
+	}
+
+	oldConfig := w.effective
+	newConfig := oldConfig
+	newConfig.DataplaneRateLimit = fileConfig.DataplaneRateLimit
+	newConfig.NamespaceScope = fileConfig.NamespaceScope
+	newConfig.LogLevel = fileConfig.LogLevel
+
+	if !reflect.DeepEqual(oldConfig, newConfig) {
+		klog.Infof("[ConfigWatcher] applying reloaded config: %+v", newConfig)
+		var errs error
+		for _, f := range w.reloadFuncs {
+			if err := f(oldConfig, newConfig); err != nil {
+				if errs == nil {
+					errs = err
+				} else {
+					errs = fmt.Errorf("%w, previous err: [%v]", err, errs)
+				}
+			}
+		}
+		if errs != nil {
+			return fmt.Errorf("failed to apply reloaded config: %w", errs)
+		}
+	}
+
+	w.effective = newConfig
+	w.pendingRestart = fieldsPendingRestart(newConfig, fileConfig)
+	if len(w.pendingRestart) > 0 {
+		klog.Infof("[ConfigWatcher] changes to %v take effect when NPM restarts", w.pendingRestart)
+	}
+	w.lastContent = content
+	return nil
+}
+
+// fieldsPendingRestart returns the fields which aren't reloadable and differ between the configs
+func fieldsPendingRestart(effective, fileConfig Config) []string {
+	fields := make([]string, 0)
+	effectiveValue := reflect.ValueOf(effective)
+	fileValue := reflect.ValueOf(fileConfig)
+	for i := 0; i < effectiveValue.NumField(); i++ {
+		name := effectiveValue.Type().Field(i).Name
+		if _, ok := reloadableFields[name]; ok {
+			continue
+		}
+		if !reflect.DeepEqual(effectiveValue.Field(i).Interface(), fileValue.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// Effective returns the config which NPM runs with.
+func (w *Watcher) Effective() EffectiveConfig {
+	w.RLock()
+	defer w.RUnlock()
+	pendingRestart := make([]string, len(w.pendingRestart))
+	copy(pendingRestart, w.pendingRestart)
+	return EffectiveConfig{
+		Config:         w.effective,
+		PendingRestart: pendingRestart,
+	}
+}
+
+// MarshalJSON serves the effective config on the debug API.
+func (w *Watcher) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(w.Effective())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal effective config: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,90 @@
+package npmconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errReload = errors.New("failed to reload")
+
+func writeConfig(t *testing.T, path string, config Config) {
+	t.Helper()
+	b, err := json.Marshal(config)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+}
+
+func TestWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azure-npm.json")
+	w := NewWatcher(path, DefaultConfig)
+	reloads := make([]Config, 0)
+	var reloadErr error
+	w.OnReload(func(_, newConfig Config) error {
+		reloads = append(reloads, newConfig)
+		return reloadErr
+	})
+
+	// a missing file keeps the config
+	require.NoError(t, w.Reload())
+	require.Equal(t, DefaultConfig, w.Effective().Config)
+
+	// an unchanged file doesn't reload
+	writeConfig(t, path, DefaultConfig)
+	require.NoError(t, w.Reload())
+	require.Empty(t, reloads)
+	require.Empty(t, w.Effective().PendingRestart)
+
+	fileConfig := DefaultConfig
+	fileConfig.LogLevel = "debug"
+	fileConfig.DataplaneRateLimit = DataplaneRateLimitConfig{OpsPerSecond: 10, Burst: 5}
+	fileConfig.NamespaceScope = NamespaceScopeConfig{Exclude: []string{"kube-system"}}
+	fileConfig.ListeningPort = 8080
+	writeConfig(t, path, fileConfig)
+	require.NoError(t, w.Reload())
+	require.Len(t, reloads, 1)
+	effective := w.Effective()
+	require.Equal(t, "debug", effective.Config.LogLevel)
+	require.Equal(t, fileConfig.DataplaneRateLimit, effective.Config.DataplaneRateLimit)
+	require.Equal(t, fileConfig.NamespaceScope, effective.Config.NamespaceScope)
+	require.Equal(t, DefaultConfig.ListeningPort, effective.Config.ListeningPort, "the port is only changed on restart")
+	require.Equal(t, []string{"ListeningPort"}, effective.PendingRestart)
+
+	// a failed reload keeps the effective config and is retried
+	fileConfig.LogLevel = "error"
+	writeConfig(t, path, fileConfig)
+	reloadErr = errReload
+	require.ErrorIs(t, w.Reload(), errReload)
+	require.Equal(t, "debug", w.Effective().Config.LogLevel)
+	reloadErr = nil
+	require.NoError(t, w.Reload())
+	require.Equal(t, "error", w.Effective().Config.LogLevel)
+	require.Len(t, reloads, 3)
+
+	// invalid configs are rejected
+	fileConfig.LogLevel = "loud"
+	writeConfig(t, path, fileConfig)
+	require.Error(t, w.Reload())
+	fileConfig.LogLevel = ""
+	fileConfig.NamespaceScope.LabelSelector = "npm in ("
+	writeConfig(t, path, fileConfig)
+	require.Error(t, w.Reload())
+	require.Len(t, reloads, 3)
+
+	b, err := json.Marshal(w)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"PendingRestart":["ListeningPort"]`)
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for _, level := range []string{"", "error", "Warning", "info", "DEBUG"} {
+		_, err := Config{LogLevel: level}.ParseLogLevel()
+		require.NoError(t, err, level)
+	}
+	_, err := Config{LogLevel: "trace"}.ParseLogLevel()
+	require.ErrorIs(t, err, errInvalidLogLevel)
+}
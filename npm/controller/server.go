@@ -88,10 +88,17 @@ func NewNetworkPolicyServer(
 		},
 	}
 
+	scopeCfg := config.NamespaceScope
+	namespaceScope, err := controllersv2.NewNamespaceScope(scopeCfg.Include, scopeCfg.Exclude, scopeCfg.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create namespace scope: %w", err)
+	}
+
 	n.NpmNamespaceCacheV2 = &controllersv2.NpmNamespaceCache{NsMap: make(map[string]*common.Namespace)}
-	n.PodControllerV2 = controllersv2.NewPodController(n.PodInformer, dp, n.NpmNamespaceCacheV2)
-	n.NamespaceControllerV2 = controllersv2.NewNamespaceController(n.NsInformer, dp, n.NpmNamespaceCacheV2)
-	n.NetPolControllerV2 = controllersv2.NewNetworkPolicyController(n.NpInformer, dp)
+	// the daemons apply the dataplane, so the controller has no dataplane failures to report
+	n.PodControllerV2 = controllersv2.NewPodController(n.PodInformer, dp, n.NpmNamespaceCacheV2, namespaceScope, nil)
+	n.NamespaceControllerV2 = controllersv2.NewNamespaceController(n.NsInformer, dp, n.NpmNamespaceCacheV2, namespaceScope, nil)
+	n.NetPolControllerV2 = controllersv2.NewNetworkPolicyController(n.NpInformer, dp, namespaceScope, nil)
 
 	return n, nil
 }
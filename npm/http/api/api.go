@@ -1,13 +1,92 @@
 package api
 
+import (
+	"errors"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/protos"
+)
+
 const (
 	DefaultListeningIP = "0.0.0.0"
 	DefaultHttpPort    = "10091"
 	NodeMetricsPath    = "/node-metrics"
 	ClusterMetricsPath = "/cluster-metrics"
 	NPMMgrPath         = "/npm/v1/debug/manager"
+	NPMConfigPath      = "/npm/v1/debug/config"
+	// NPMIPSetSnapshotPath serves the ipset cache of v2 NPM in the format which NPM restores
+	NPMIPSetSnapshotPath = "/npm/v1/debug/ipsets/snapshot"
+	// NPMMeshCompatibilityPath serves the order of the NPM chains relative to the chains of service meshes in Linux
+	NPMMeshCompatibilityPath = "/npm/v1/debug/mesh"
+	// NPMDeniedFlowsPath serves the latest flows which network policies denied when the denied flow log is enabled
+	NPMDeniedFlowsPath = "/npm/v1/debug/denied-flows"
+	// NPMRuleCountersPath serves the packets matched by the iptables rules of each network policy in Linux
+	NPMRuleCountersPath = "/npm/v1/debug/rule-counters"
+
+	// paths of the policy state API
+	IPSetsPath      = "/npm/v1/ipsets"
+	PoliciesPath    = "/npm/v1/policies"
+	PodPoliciesPath = "/npm/v1/pods/{namespace}/{name}/policies"
+	OpenAPIPath     = "/npm/v1/openapi.json"
+)
+
+var (
+	// ErrNotFound is returned by a PolicyState for a pod which isn't in the cache
+	ErrNotFound = errors.New("not found")
+	// ErrNotSupported is returned by a PolicyState of v1 NPM
+	ErrNotSupported = errors.New("not supported by this version of NPM")
 )
 
-type DescribeIPSetRequest struct{}
+// PolicyState is the cluster policy state of an NPM pod which the policy state API serves.
+type PolicyState interface {
+	ListIPSets() (*ListIPSetsResponse, error)
+	ListPolicies() (*ListPoliciesResponse, error)
+	GetPodPolicies(namespace, name string) (*PodPoliciesResponse, error)
+	// StreamIPSets sends the v2 ipsets which match the request one at a time, which the IPSets gRPC service of the
+	// API streams to the client.
+	StreamIPSets(req *protos.ListIPSetsRequest, send func(*protos.IPSet) error) error
+}
+
+type IPSet struct {
+	Name       string `json:"name"`
+	HashedName string `json:"hashedName"`
+	Type       string `json:"type"`
+	// Members are the IPs of a hash set or the names of the member sets of a list
+	Members []string `json:"members"`
+}
+
+type ListIPSetsResponse struct {
+	IPSets []*IPSet `json:"ipsets"`
+}
+
+type Policy struct {
+	Key               string   `json:"key"`
+	PodSelectorIPSets []string `json:"podSelectorIPSets"`
+	RuleIPSets        []string `json:"ruleIPSets"`
+	ACLs              []*ACL   `json:"acls"`
+	// Error is why the policy can't be translated, in which case NPM doesn't program it
+	Error string `json:"error,omitempty"`
+}
+
+type ACL struct {
+	Comment   string `json:"comment"`
+	Direction string `json:"direction"`
+	Target    string `json:"target"`
+	Protocol  string `json:"protocol"`
+	Port      int32  `json:"port,omitempty"`
+	EndPort   int32  `json:"endPort,omitempty"`
+	// SrcSets and DstSets are the names of the sets matched, prefixed with ! when excluded
+	SrcSets []string `json:"srcSets"`
+	DstSets []string `json:"dstSets"`
+}
+
+type ListPoliciesResponse struct {
+	Policies []*Policy `json:"policies"`
+}
 
-type DescribeIPSetResponse struct{}
+type PodPoliciesResponse struct {
+	Pod string `json:"pod"`
+	IP  string `json:"ip"`
+	// Ingress and Egress are the keys of the policies selecting the pod for the direction
+	Ingress []string `json:"ingress"`
+	Egress  []string `json:"egress"`
+}
@@ -2,7 +2,10 @@ package client
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-container-networking/npm/http/api"
@@ -13,6 +16,8 @@ import (
 type NPMHttpClient struct {
 	endpoint string
 	client   *http.Client
+	// token is sent as a bearer token to the policy state API when set
+	token string
 }
 
 func NewNPMHttpClient(endpoint string) *NPMHttpClient {
@@ -24,6 +29,16 @@ func NewNPMHttpClient(endpoint string) *NPMHttpClient {
 	}
 }
 
+// NewPolicyAPIClient returns a client of the policy state API. The client of an API on a unix socket should
+// set the socket in the transport of httpClient and use any host in the endpoint, e.g. http://localhost.
+func NewPolicyAPIClient(endpoint, token string, httpClient *http.Client) *NPMHttpClient {
+	return &NPMHttpClient{
+		endpoint: endpoint,
+		client:   httpClient,
+		token:    token,
+	}
+}
+
 func (n *NPMHttpClient) GetNpmMgr() (*npm.NetworkPolicyManager, error) {
 	url := n.endpoint + api.NPMMgrPath
 	req, err := http.NewRequest(http.MethodGet, url, nil)
@@ -44,3 +59,52 @@ func (n *NPMHttpClient) GetNpmMgr() (*npm.NetworkPolicyManager, error) {
 
 	return &ns, nil
 }
+
+func (n *NPMHttpClient) ListIPSets() (*api.ListIPSetsResponse, error) {
+	resp := &api.ListIPSetsResponse{}
+	if err := n.getPolicyState(api.IPSetsPath, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (n *NPMHttpClient) ListPolicies() (*api.ListPoliciesResponse, error) {
+	resp := &api.ListPoliciesResponse{}
+	if err := n.getPolicyState(api.PoliciesPath, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (n *NPMHttpClient) GetPodPolicies(namespace, name string) (*api.PodPoliciesResponse, error) {
+	path := strings.NewReplacer("{namespace}", namespace, "{name}", name).Replace(api.PodPoliciesPath)
+	resp := &api.PodPoliciesResponse{}
+	if err := n.getPolicyState(path, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (n *NPMHttpClient) getPolicyState(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, n.endpoint+path, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	res, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("failed to get %s with status %d: %s", path, res.StatusCode, strings.TrimSpace(string(b))) //nolint:goerr113 // the status is the error
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
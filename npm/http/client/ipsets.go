@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// StreamIPSets calls handle with each v2 ipset of the policy state API which matches the request, as the API streams
+// them, and stops at the first error of handle. conn is a gRPC connection to the API, which is cleartext since the
+// API only listens on localhost or on a unix socket, e.g. from grpc.Dial("unix:///path/to/socket",
+// grpc.WithTransportCredentials(insecure.NewCredentials())). The token is sent as a bearer token when set.
+func StreamIPSets(ctx context.Context, conn grpc.ClientConnInterface, token string, req *protos.ListIPSetsRequest,
+	handle func(*protos.IPSet) error,
+) error {
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	// stops the stream when handle fails
+	defer cancel()
+
+	stream, err := protos.NewIPSetsClient(conn).List(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to list ipsets: %w", err)
+	}
+	for {
+		set, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to receive ipset: %w", err)
+		}
+		if err := handle(set); err != nil {
+			return err
+		}
+	}
+}
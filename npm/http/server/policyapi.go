@@ -0,0 +1,160 @@
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed" // for the OpenAPI document
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/http/api"
+	"github.com/Azure/azure-container-networking/npm/pkg/protos"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+const bearerPrefix = "Bearer "
+
+// NPMPolicyAPIListenAndServe serves the policy state API on a unix socket or on localhost, never on the node's
+// addresses, since it reveals the pods and policies of the cluster.
+func NPMPolicyAPIListenAndServe(config npmconfig.Config, state api.PolicyState) {
+	if err := policyAPIListenAndServe(config.PolicyAPI, state); err != nil {
+		klog.Errorf("Failed to start NPM policy API with error: %+v", err)
+	}
+}
+
+func policyAPIListenAndServe(cfg npmconfig.PolicyAPIConfig, state api.PolicyState) error {
+	var token string
+	if cfg.TokenFile != "" {
+		b, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	var (
+		listener net.Listener
+		err      error
+	)
+	if cfg.SocketPath != "" {
+		// remove the socket of a previous run
+		if err = os.Remove(cfg.SocketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+		listener, err = net.Listen("unix", cfg.SocketPath)
+	} else {
+		listener, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", cfg.Port))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	srv := &http.Server{
+		// h2c serves the gRPC services of the API over cleartext HTTP/2 next to the JSON endpoints
+		Handler: h2c.NewHandler(NewPolicyAPIHandler(state, token), &http2.Server{}),
+	}
+	klog.Infof("Starting NPM policy API on %s... ", listener.Addr())
+	return srv.Serve(listener) //nolint:wrapcheck // only returned to be logged
+}
+
+// NewPolicyAPIHandler returns the handler of the policy state API, which serves the gRPC requests with the gRPC
+// services of the API. Requests must present the token as a bearer token unless it is empty.
+func NewPolicyAPIHandler(state api.PolicyState, token string) http.Handler {
+	grpcServer := grpc.NewServer()
+	protos.RegisterIPSetsServer(grpcServer, &ipsetsServer{state: state})
+
+	router := mux.NewRouter()
+	router.Handle(api.IPSetsPath, policyStateHandler(func(*http.Request) (interface{}, error) {
+		return state.ListIPSets()
+	})).Methods(http.MethodGet)
+	router.Handle(api.PoliciesPath, policyStateHandler(func(*http.Request) (interface{}, error) {
+		return state.ListPolicies()
+	})).Methods(http.MethodGet)
+	router.Handle(api.PodPoliciesPath, policyStateHandler(func(r *http.Request) (interface{}, error) {
+		vars := mux.Vars(r)
+		return state.GetPodPolicies(vars["namespace"], vars["name"])
+	})).Methods(http.MethodGet)
+	router.HandleFunc(api.OpenAPIPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(openAPIDocument); err != nil {
+			klog.Errorf("failed to write resp: %v", err)
+		}
+	}).Methods(http.MethodGet)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
+	if token == "" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, bearerPrefix) ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, bearerPrefix)), []byte(token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func policyStateHandler(get func(r *http.Request) (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := get(r)
+		if err != nil {
+			switch {
+			case errors.Is(err, api.ErrNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, api.ErrNotSupported):
+				http.Error(w, err.Error(), http.StatusNotImplemented)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		b, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(b); err != nil {
+			klog.Errorf("failed to write resp: %v", err)
+		}
+	})
+}
+
+// ipsetsServer serves the v2 ipsets of the policy state API.
+type ipsetsServer struct {
+	protos.UnimplementedIPSetsServer
+	state api.PolicyState
+}
+
+func (s *ipsetsServer) List(req *protos.ListIPSetsRequest, stream protos.IPSets_ListServer) error {
+	if err := s.state.StreamIPSets(req, stream.Send); err != nil {
+		if errors.Is(err, api.ErrNotSupported) {
+			return status.Error(codes.Unimplemented, err.Error()) //nolint:wrapcheck // gRPC status
+		}
+		return status.Error(codes.Internal, err.Error()) //nolint:wrapcheck // gRPC status
+	}
+	return nil
+}
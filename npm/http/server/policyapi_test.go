@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/http/api"
+	"github.com/Azure/azure-container-networking/npm/pkg/protos"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakePolicyState struct {
+	err error
+}
+
+func (f *fakePolicyState) ListIPSets() (*api.ListIPSetsResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &api.ListIPSetsResponse{IPSets: []*api.IPSet{{Name: "ns-test", Members: []string{"10.0.0.1"}}}}, nil
+}
+
+func (f *fakePolicyState) ListPolicies() (*api.ListPoliciesResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &api.ListPoliciesResponse{Policies: []*api.Policy{{Key: "test/deny"}}}, nil
+}
+
+func (f *fakePolicyState) GetPodPolicies(namespace, name string) (*api.PodPoliciesResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if name != "a" {
+		return nil, fmt.Errorf("pod %s/%s: %w", namespace, name, api.ErrNotFound)
+	}
+	return &api.PodPoliciesResponse{Pod: namespace + "/" + name, Ingress: []string{"test/deny"}}, nil
+}
+
+func (f *fakePolicyState) StreamIPSets(req *protos.ListIPSetsRequest, send func(*protos.IPSet) error) error {
+	if f.err != nil {
+		return f.err
+	}
+	for _, name := range []string{"ns-a", "ns-b"} {
+		set := &protos.IPSet{Name: name, Kind: protos.IPSet_HashSet, MemberCount: 1}
+		if !req.GetOmitMembers() {
+			set.Members = []string{"10.0.0.1"}
+		}
+		if err := send(set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func servePolicyAPI(t *testing.T, handler http.Handler, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPolicyAPIHandler(t *testing.T) {
+	handler := NewPolicyAPIHandler(&fakePolicyState{}, "")
+
+	rr := servePolicyAPI(t, handler, api.IPSetsPath, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	ipsets := &api.ListIPSetsResponse{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), ipsets))
+	require.Equal(t, []string{"10.0.0.1"}, ipsets.IPSets[0].Members)
+
+	rr = servePolicyAPI(t, handler, api.PoliciesPath, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	policies := &api.ListPoliciesResponse{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), policies))
+	require.Equal(t, "test/deny", policies.Policies[0].Key)
+
+	rr = servePolicyAPI(t, handler, "/npm/v1/pods/test/a/policies", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	pod := &api.PodPoliciesResponse{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), pod))
+	require.Equal(t, "test/a", pod.Pod)
+	require.Equal(t, []string{"test/deny"}, pod.Ingress)
+
+	rr = servePolicyAPI(t, handler, "/npm/v1/pods/test/b/policies", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = servePolicyAPI(t, handler, api.OpenAPIPath, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.True(t, json.Valid(rr.Body.Bytes()))
+}
+
+func TestPolicyAPIHandlerErrors(t *testing.T) {
+	handler := NewPolicyAPIHandler(&fakePolicyState{err: api.ErrNotSupported}, "")
+	require.Equal(t, http.StatusNotImplemented, servePolicyAPI(t, handler, api.IPSetsPath, "").Code)
+
+	handler = NewPolicyAPIHandler(&fakePolicyState{err: fmt.Errorf("boom")}, "") //nolint:goerr113 // test
+	require.Equal(t, http.StatusInternalServerError, servePolicyAPI(t, handler, api.PoliciesPath, "").Code)
+}
+
+func TestPolicyAPIHandlerToken(t *testing.T) {
+	handler := NewPolicyAPIHandler(&fakePolicyState{}, "secret")
+	require.Equal(t, http.StatusUnauthorized, servePolicyAPI(t, handler, api.IPSetsPath, "").Code)
+	require.Equal(t, http.StatusUnauthorized, servePolicyAPI(t, handler, api.IPSetsPath, "wrong").Code)
+	require.Equal(t, http.StatusOK, servePolicyAPI(t, handler, api.IPSetsPath, "secret").Code)
+}
+
+// listIPSets lists the ipsets of the handler served over h2c like the policy API serves them.
+func listIPSets(t *testing.T, handler http.Handler, token string, req *protos.ListIPSetsRequest) ([]*protos.IPSet, error) {
+	t.Helper()
+	srv := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer srv.Close()
+	conn, err := grpc.Dial(strings.TrimPrefix(srv.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	stream, err := protos.NewIPSetsClient(conn).List(ctx, req)
+	require.NoError(t, err)
+	var sets []*protos.IPSet
+	for {
+		set, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return sets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+}
+
+func TestPolicyAPIListIPSetsV2(t *testing.T) {
+	sets, err := listIPSets(t, NewPolicyAPIHandler(&fakePolicyState{}, "secret"), "secret", &protos.ListIPSetsRequest{})
+	require.NoError(t, err)
+	require.Len(t, sets, 2)
+	require.Equal(t, "ns-a", sets[0].Name)
+	require.Equal(t, []string{"10.0.0.1"}, sets[0].Members)
+	require.Equal(t, "ns-b", sets[1].Name)
+
+	sets, err = listIPSets(t, NewPolicyAPIHandler(&fakePolicyState{}, ""), "", &protos.ListIPSetsRequest{OmitMembers: true})
+	require.NoError(t, err)
+	require.Len(t, sets, 2)
+	require.Equal(t, uint32(1), sets[0].MemberCount)
+	require.Empty(t, sets[0].Members)
+
+	_, err = listIPSets(t, NewPolicyAPIHandler(&fakePolicyState{}, "secret"), "wrong", &protos.ListIPSetsRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = listIPSets(t, NewPolicyAPIHandler(&fakePolicyState{err: api.ErrNotSupported}, ""), "", &protos.ListIPSetsRequest{})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
@@ -21,7 +21,24 @@ type NPMRestServer struct {
 	router           *mux.Router
 }
 
-func NPMRestServerListenAndServe(config npmconfig.Config, npmEncoder json.Marshaler) {
+// DebugEncoders are the caches which the debug API serves. The API doesn't serve the nil ones.
+type DebugEncoders struct {
+	// NPM is the cache of the NPM manager, served for the ACN CLI
+	NPM json.Marshaler
+	// Config is the effective config
+	Config json.Marshaler
+	// IPSetSnapshot is the snapshot of the ipset cache of v2 NPM
+	IPSetSnapshot json.Marshaler
+	// MeshCompatibility is the order of the NPM chains relative to the chains of service meshes
+	MeshCompatibility json.Marshaler
+	// DeniedFlows are the latest flows denied by network policies
+	DeniedFlows json.Marshaler
+	// RuleCounters are the packets matched by the rules of each network policy
+	RuleCounters json.Marshaler
+}
+
+// NPMRestServerListenAndServe serves the metrics and debug API.
+func NPMRestServerListenAndServe(config npmconfig.Config, encoders DebugEncoders) {
 	rs := NPMRestServer{}
 
 	rs.router = mux.NewRouter()
@@ -33,9 +50,29 @@ func NPMRestServerListenAndServe(config npmconfig.Config, npmEncoder json.Marsha
 	}
 
 	// the nil check is for fan-out npm
-	if config.Toggles.EnableHTTPDebugAPI && npmEncoder != nil {
+	if config.Toggles.EnableHTTPDebugAPI && encoders.NPM != nil {
 		// ACN CLI debug handlers
-		rs.router.Handle(api.NPMMgrPath, rs.npmCacheHandler(npmEncoder)).Methods(http.MethodGet)
+		rs.router.Handle(api.NPMMgrPath, rs.npmCacheHandler(encoders.NPM)).Methods(http.MethodGet)
+	}
+
+	if config.Toggles.EnableHTTPDebugAPI && encoders.Config != nil {
+		rs.router.Handle(api.NPMConfigPath, rs.npmCacheHandler(encoders.Config)).Methods(http.MethodGet)
+	}
+
+	if config.Toggles.EnableHTTPDebugAPI && encoders.IPSetSnapshot != nil {
+		rs.router.Handle(api.NPMIPSetSnapshotPath, rs.npmCacheHandler(encoders.IPSetSnapshot)).Methods(http.MethodGet)
+	}
+
+	if config.Toggles.EnableHTTPDebugAPI && encoders.MeshCompatibility != nil {
+		rs.router.Handle(api.NPMMeshCompatibilityPath, rs.npmCacheHandler(encoders.MeshCompatibility)).Methods(http.MethodGet)
+	}
+
+	if config.Toggles.EnableHTTPDebugAPI && encoders.DeniedFlows != nil {
+		rs.router.Handle(api.NPMDeniedFlowsPath, rs.npmCacheHandler(encoders.DeniedFlows)).Methods(http.MethodGet)
+	}
+
+	if config.Toggles.EnableHTTPDebugAPI && encoders.RuleCounters != nil {
+		rs.router.Handle(api.NPMRuleCountersPath, rs.npmCacheHandler(encoders.RuleCounters)).Methods(http.MethodGet)
 	}
 
 	if config.Toggles.EnablePprof {
@@ -5,11 +5,11 @@ package iptm
 
 import (
 	"bytes"
-	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -19,6 +19,9 @@ import (
 )
 
 const (
+	// npmRuleOwner owns the AZURE-NPM chains in the iptables registry.
+	npmRuleOwner = "azure-npm"
+
 	iptablesErrDoesNotExist     int = 1
 	reconcileChainTimeInMinutes     = 5
 	minLineNumberStringLength   int = 3
@@ -60,6 +63,71 @@ type IptEntry struct {
 	Specs                 []string
 }
 
+// Rule returns the entry in the rule model of the iptables package. NPM entries list the jump before
+// the matches, the rule model always renders the jump last.
+func (entry *IptEntry) Rule() *iptables.Rule {
+	rule := &iptables.Rule{
+		Version: iptables.V4,
+		Table:   util.IptablesFilterTable,
+		Chain:   entry.Chain,
+	}
+
+	inTarget := false
+	for i := 0; i < len(entry.Specs); i++ {
+		spec := entry.Specs[i]
+		switch {
+		case spec == util.IptablesJumpFlag && i+1 < len(entry.Specs):
+			i++
+			rule.Target = iptables.Jump(entry.Specs[i])
+			inTarget = true
+		case spec == util.IptablesModuleFlag && i+1 < len(entry.Specs):
+			i++
+			rule.Matches = append(rule.Matches, iptables.Match{Module: entry.Specs[i]})
+			inTarget = false
+		case inTarget && !isShortOption(spec):
+			rule.Target.Args = append(rule.Target.Args, spec)
+		default:
+			// builtin matches such as -p tcp start a new match, anything else is an option of the current one
+			if last := len(rule.Matches) - 1; last < 0 || (isShortOption(spec) && !isNegated(rule.Matches[last])) {
+				rule.Matches = append(rule.Matches, iptables.Match{})
+			}
+			last := len(rule.Matches) - 1
+			rule.Matches[last].Args = append(rule.Matches[last].Args, spec)
+			inTarget = false
+		}
+	}
+
+	return rule
+}
+
+// args returns the specification of the entry as rendered by the rule model, after the position of
+// an insertion if the entry has one.
+func (entry *IptEntry) args() []string {
+	specs := entry.Specs
+	var position []string
+	if len(specs) > 0 {
+		if _, err := strconv.Atoi(specs[0]); err == nil {
+			position, specs = specs[:1], specs[1:]
+		}
+	}
+
+	rule := (&IptEntry{Chain: entry.Chain, Specs: specs}).Rule()
+	if rule.Target.Name == "" {
+		return entry.Specs
+	}
+
+	return append(position, rule.Args()...)
+}
+
+// isShortOption returns true for options such as -p or -s and for the negation of an option.
+func isShortOption(spec string) bool {
+	return spec == "!" || (len(spec) == 2 && spec[0] == '-' && spec[1] != '-')
+}
+
+func isNegated(m iptables.Match) bool {
+	return m.Module == "" && len(m.Args) > 0 && m.Args[len(m.Args)-1] == "!"
+}
+
 // IptablesManager stores iptables entries.
 type IptablesManager struct {
 	exec                 utilexec.Interface
@@ -95,7 +163,7 @@ func NewIptablesManager(exec utilexec.Interface, io ioshim, placeAzureChainFirst
 func (iptMgr *IptablesManager) InitNpmChains() error {
 	log.Logf("Initializing AZURE-NPM chains.")
 
-	if err := iptMgr.addAllChains(); err != nil {
+	if err := iptMgr.addAllChainsAndRules(); err != nil {
 		return err
 	}
 
@@ -103,7 +171,7 @@ func (iptMgr *IptablesManager) InitNpmChains() error {
 		metrics.SendErrorLogAndMetric(util.IptmID, "Error: failed to add AZURE-NPM chain to FORWARD chain. %s", err.Error())
 	}
 
-	return iptMgr.addAllRulesToChains()
+	return nil
 }
 
 // UninitNpmChains uninitializes Azure NPM chains in iptables.
@@ -195,6 +263,43 @@ func (iptMgr *IptablesManager) Add(entry *IptEntry) error {
 	return nil
 }
 
+// AddAll adds the rules in iptables with a single iptables-restore, in the order Add would add them
+// one by one. Like Add, it does not check whether the rules exist already.
+func (iptMgr *IptablesManager) AddAll(entries []*IptEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	writer := iptMgr.newWriter(func(string, string, string) bool { return true }, func(*iptables.Rule) bool { return false })
+	for _, entry := range entries {
+		log.Logf("Adding iptables entry: %+v.", entry)
+
+		// DROP rules are added at the BOTTOM of the DROPS chains, which end with a RETURN statement
+		action := iptables.Insert
+		if isDropsChain(entry.Chain) {
+			action = iptables.Append
+		}
+
+		if err := writer.EnsureRule(entry.Rule(), action); err != nil {
+			return err
+		}
+	}
+
+	timer := metrics.StartNewTimer()
+	err := writer.Flush()
+	metrics.RecordACLRuleExecTime(timer) // record execution time regardless of failure
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.IptmID, "Error: failed to create iptables rules.")
+		return err
+	}
+
+	for range entries {
+		metrics.IncNumACLRules()
+	}
+
+	return nil
+}
+
 // Delete removes a rule in iptables.
 func (iptMgr *IptablesManager) Delete(entry *IptEntry) error {
 	log.Logf("Deleting iptables entry: %+v", entry)
@@ -334,44 +439,161 @@ func (iptMgr *IptablesManager) reconcileChains(stopCh <-chan struct{}) {
 	}
 }
 
-// addAllRulesToChains checks and adds all the rules in NPM chains
-func (iptMgr *IptablesManager) addAllRulesToChains() error {
-	allDefaultRules := getAllDefaultRules()
-	for _, rule := range allDefaultRules {
+// addAllChainsAndRules creates the NPM chains and adds their default rules which don't exist yet with
+// a single iptables-restore.
+func (iptMgr *IptablesManager) addAllChainsAndRules() error {
+	// a chain which exists must not be written to iptables-restore, it would be flushed
+	currentChains, err := ioutil.AllCurrentAzureChains(iptMgr.exec, util.IptablesDefaultWaitTime)
+	if err != nil {
+		// iptables -N leaves existing chains alone, so fall back to creating the chains one by one
+		metrics.SendErrorLogAndMetric(util.IptmID, "Warning: failed to list current AZURE-NPM chains, creating them one by one. %s", err.Error())
+		currentChains = make(map[string]struct{}, len(IptablesAzureChainList))
+		for _, chain := range IptablesAzureChainList {
+			if err = iptMgr.addChain(chain); err != nil {
+				return err
+			}
+			currentChains[chain] = struct{}{}
+		}
+	}
+
+	writer := iptMgr.newWriter(
+		func(_, _, chainName string) bool {
+			_, ok := currentChains[chainName]
+			return ok
+		},
+		func(rule *iptables.Rule) bool {
+			exists, err := iptMgr.exists(&IptEntry{Chain: rule.Chain, Specs: rule.Args()})
+			if err != nil {
+				metrics.SendErrorLogAndMetric(util.IptmID, "Error: failed to check rule %s. %s", rule, err.Error())
+			}
+			return exists
+		},
+	)
+
+	for _, chain := range IptablesAzureChainList {
+		writer.EnsureChain(util.IptablesFilterTable, chain)
+	}
+
+	for _, rule := range getAllDefaultRules() {
 		entry := &IptEntry{
 			Chain: rule[0],
 			Specs: rule[1:],
 		}
-		exists, err := iptMgr.exists(entry)
-		if err != nil {
+		if err = writer.EnsureRule(entry.Rule(), iptables.Append); err != nil {
 			return err
 		}
+	}
 
-		if !exists {
-			iptMgr.OperationFlag = util.IptablesAppendFlag
-			if _, err = iptMgr.run(entry); err != nil {
-				msg := "Error: failed to add %s to parent chain %s"
-				switch {
-				case len(rule) == 3:
-					// 0th index is parent chain and 2nd is chain to be added
-					msg = fmt.Sprintf(msg, rule[2], rule[0])
-				case len(rule) > 3:
-					// last element is comment
-					msg = fmt.Sprintf(msg, rule[len(rule)-1], rule[0])
-				default:
-					msg = "Error: failed to add main chains with invalid rule length"
-				}
-
-				metrics.SendErrorLogAndMetric(util.IptmID, msg)
-				return err
-			}
-		}
+	if err = writer.Flush(); err != nil {
+		metrics.SendErrorLogAndMetric(util.IptmID, "Error: failed to add NPM chains and their rules. %s", err.Error())
+		return err
+	}
 
+	// chains of other releases, e.g. of NPM v2 before a rollback, would otherwise stay forever
+	for chain := range currentChains {
+		if !isNpmChain(chain) {
+			iptMgr.cleanupStaleChains()
+			break
+		}
 	}
 
 	return nil
 }
 
+func isNpmChain(chain string) bool {
+	for _, c := range IptablesAzureChainList {
+		if c == chain {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanupStaleChains removes the AZURE-NPM chains which are not NPM chains of this release and the rules
+// jumping to them. The rules of the NPM chains are left alone, they are reconciled by the controllers.
+func (iptMgr *IptablesManager) cleanupStaleChains() {
+	registry := iptables.NewRegistry(npmRuleOwner, iptables.ReleaseVersion, util.IptablesAzureChain)
+	registry.UseExec(
+		func(_, tableName string) (string, error) {
+			output, err := iptMgr.exec.Command(util.Iptables,
+				util.IptablesWaitFlag, util.IptablesDefaultWaitTime, util.IptablesTableFlag, tableName, util.IptablesListRulesFlag,
+			).CombinedOutput()
+			return string(output), err
+		},
+		func(_, params string) error {
+			args := append([]string{util.IptablesWaitFlag, util.IptablesDefaultWaitTime}, splitParams(params)...)
+			log.Logf("Executing iptables command %s %v", util.Iptables, args)
+			_, err := iptMgr.exec.Command(util.Iptables, args...).CombinedOutput()
+			return err
+		},
+		func(rule *iptables.Rule) bool {
+			exists, _ := iptMgr.exists(&IptEntry{Chain: rule.Chain, Specs: rule.Args()})
+			return exists
+		},
+	)
+	for _, chain := range IptablesAzureChainList {
+		registry.RegisterChain(iptables.V4, util.IptablesFilterTable, chain)
+	}
+
+	if err := registry.CleanupOrphanChains(); err != nil {
+		metrics.SendErrorLogAndMetric(util.IptmID, "Error: failed to remove stale AZURE-NPM chains. %s", err.Error())
+	}
+}
+
+// splitParams splits iptables parameters as listed by iptables -S, where arguments with spaces such as
+// comments are double quoted.
+func splitParams(params string) []string {
+	var (
+		args    []string
+		current strings.Builder
+		quoted  bool
+		inArg   bool
+	)
+	for _, c := range params {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case c == ' ' && !quoted:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args
+}
+
+// newWriter returns an iptables writer running iptables-restore through the exec interface of the
+// manager, with the iptables binaries NPM runs everywhere else.
+func (iptMgr *IptablesManager) newWriter(chainExists func(version, tableName, chainName string) bool, ruleExists func(*iptables.Rule) bool) *iptables.Writer {
+	writer := iptables.NewWriter(iptables.V4)
+	writer.UseExec(
+		func(_ string, args []string, input []byte) ([]byte, error) {
+			cmd := iptMgr.exec.Command(util.IptablesRestore, args...)
+			cmd.SetStdin(bytes.NewReader(input))
+			output, err := cmd.CombinedOutput()
+			// like run, only a failure of iptables-restore itself is an error
+			if _, failed := err.(utilexec.ExitError); failed {
+				return output, err
+			}
+			return output, nil
+		},
+		chainExists,
+		ruleExists,
+	)
+
+	return writer
+}
+
 // Exists checks if a rule exists in iptables.
 func (iptMgr *IptablesManager) exists(entry *IptEntry) (bool, error) {
 	iptMgr.OperationFlag = util.IptablesCheckFlag
@@ -387,17 +609,6 @@ func (iptMgr *IptablesManager) exists(entry *IptEntry) (bool, error) {
 	return false, err
 }
 
-// AddAllChains adds all NPM chains
-func (iptMgr *IptablesManager) addAllChains() error {
-	// Add all secondary Chains
-	for _, chainToAdd := range IptablesAzureChainList {
-		if err := iptMgr.addChain(chainToAdd); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // AddChain adds a chain to iptables.
 func (iptMgr *IptablesManager) addChain(chain string) error {
 	entry := &IptEntry{
@@ -492,7 +703,7 @@ func (iptMgr *IptablesManager) run(entry *IptEntry) (int, error) {
 		entry.LockWaitTimeInSeconds = util.IptablesDefaultWaitTime
 	}
 
-	cmdArgs := append([]string{util.IptablesWaitFlag, entry.LockWaitTimeInSeconds, iptMgr.OperationFlag, entry.Chain}, entry.Specs...)
+	cmdArgs := append([]string{util.IptablesWaitFlag, entry.LockWaitTimeInSeconds, iptMgr.OperationFlag, entry.Chain}, entry.args()...)
 
 	if iptMgr.OperationFlag != util.IptablesCheckFlag {
 		log.Logf("Executing iptables command %s %v", cmdName, cmdArgs)
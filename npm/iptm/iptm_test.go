@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/metrics/promutil"
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -13,16 +14,35 @@ import (
 
 var (
 	initCalls = []testutils.TestCmd{
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-ACCEPT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-INGRESS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-EGRESS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-INGRESS-PORT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-INGRESS-FROM"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-EGRESS-PORT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-EGRESS-TO"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-INGRESS-DROPS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-EGRESS-DROPS"}},
+		{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "Chain AZURE-NPM"}, ExitCode: 1},
+
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-INGRESS"}}, // broken here
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-EGRESS"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "ACCEPT-on-INGRESS-and-EGRESS-mark-0x3000", "-j", "AZURE-NPM-ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "ACCEPT-on-INGRESS-mark-0x2000", "-j", "AZURE-NPM-ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "ACCEPT-on-EGRESS-mark-0x1000", "-j", "AZURE-NPM-ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "state", "--state", "RELATED,ESTABLISHED", "-m", "comment", "--comment", "ACCEPT-on-connection-state", "-j", "ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-ACCEPT", "-m", "comment", "--comment", "Clear-AZURE-NPM-MARKS", "-j", "MARK", "--set-mark", "0x0"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-ACCEPT", "-m", "comment", "--comment", "ACCEPT-All-packets", "-j", "ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-j", "AZURE-NPM-INGRESS-PORT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-j", "AZURE-NPM-INGRESS-DROPS"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-PORT", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000", "-j", "RETURN"}},
+		// /////////
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-PORT", "-m", "comment", "--comment", "ALL-JUMP-TO-AZURE-NPM-INGRESS-FROM", "-j", "AZURE-NPM-INGRESS-FROM"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "AZURE-NPM-EGRESS-PORT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "AZURE-NPM-EGRESS-DROPS"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-m", "comment", "--comment", "ALL-JUMP-TO-AZURE-NPM-EGRESS-TO", "-j", "AZURE-NPM-EGRESS-TO"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-DROPS", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-DROPS", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-DROPS", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables-restore", "--noflush", "-w", "60"}},
+
 		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
 
 		// NOTE the following grep call stdouts are misleading. The first grep returns 3, and the second one returns "" (i.e. line 0)
@@ -30,78 +50,47 @@ var (
 		{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, Stdout: "3  "}, // THIS IS THE GREP CALL
 		{Cmd: []string{"grep", "KUBE-SERVICES"}, Stdout: "4  "},
 
-		{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 		{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, Stdout: "3  "}, // THIS IS THE GREP CALL
 		{Cmd: []string{"grep", "AZURE-NPM"}, Stdout: "4  "},
-		{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
-		{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "3", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
-
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-INGRESS"}}, // broken here
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-EGRESS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-ACCEPT", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "ACCEPT-on-INGRESS-and-EGRESS-mark-0x3000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-ACCEPT", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "ACCEPT-on-INGRESS-mark-0x2000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-ACCEPT", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "ACCEPT-on-EGRESS-mark-0x1000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT", "-m", "comment", "--comment", "ACCEPT-on-connection-state"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-ACCEPT", "-j", "MARK", "--set-mark", "0x0", "-m", "comment", "--comment", "Clear-AZURE-NPM-MARKS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-ACCEPT", "-j", "ACCEPT", "-m", "comment", "--comment", "ACCEPT-All-packets"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-j", "AZURE-NPM-INGRESS-PORT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-j", "RETURN", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-j", "AZURE-NPM-INGRESS-DROPS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-PORT", "-j", "RETURN", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000"}},
-		// /////////
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-PORT", "-j", "AZURE-NPM-INGRESS-FROM", "-m", "comment", "--comment", "ALL-JUMP-TO-AZURE-NPM-INGRESS-FROM"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "AZURE-NPM-EGRESS-PORT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "RETURN", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "RETURN", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "AZURE-NPM-EGRESS-DROPS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-j", "RETURN", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-j", "RETURN", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-j", "AZURE-NPM-EGRESS-TO", "-m", "comment", "--comment", "ALL-JUMP-TO-AZURE-NPM-EGRESS-TO"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-DROPS", "-j", "RETURN", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-DROPS", "-j", "RETURN", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-DROPS", "-j", "RETURN", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000"}},
+		{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
+		{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "3", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 	}
 
 	initWithJumpToAzureAtTopCalls = []testutils.TestCmd{
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-ACCEPT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-INGRESS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-EGRESS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-INGRESS-PORT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-INGRESS-FROM"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-EGRESS-PORT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-EGRESS-TO"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-INGRESS-DROPS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM-EGRESS-DROPS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
-
-		{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}, ExitCode: 1},
-		{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+		{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "Chain AZURE-NPM"}, ExitCode: 1},
 
 		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-INGRESS"}}, // broken here
 		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-EGRESS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-ACCEPT", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "ACCEPT-on-INGRESS-and-EGRESS-mark-0x3000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-ACCEPT", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "ACCEPT-on-INGRESS-mark-0x2000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-j", "AZURE-NPM-ACCEPT", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "ACCEPT-on-EGRESS-mark-0x1000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT", "-m", "comment", "--comment", "ACCEPT-on-connection-state"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-ACCEPT", "-j", "MARK", "--set-mark", "0x0", "-m", "comment", "--comment", "Clear-AZURE-NPM-MARKS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-ACCEPT", "-j", "ACCEPT", "-m", "comment", "--comment", "ACCEPT-All-packets"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "ACCEPT-on-INGRESS-and-EGRESS-mark-0x3000", "-j", "AZURE-NPM-ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "ACCEPT-on-INGRESS-mark-0x2000", "-j", "AZURE-NPM-ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "ACCEPT-on-EGRESS-mark-0x1000", "-j", "AZURE-NPM-ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM", "-m", "state", "--state", "RELATED,ESTABLISHED", "-m", "comment", "--comment", "ACCEPT-on-connection-state", "-j", "ACCEPT"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-ACCEPT", "-m", "comment", "--comment", "Clear-AZURE-NPM-MARKS", "-j", "MARK", "--set-mark", "0x0"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-ACCEPT", "-m", "comment", "--comment", "ACCEPT-All-packets", "-j", "ACCEPT"}},
 		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-j", "AZURE-NPM-INGRESS-PORT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-j", "RETURN", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000", "-j", "RETURN"}},
 		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS", "-j", "AZURE-NPM-INGRESS-DROPS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-PORT", "-j", "RETURN", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-PORT", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000", "-j", "RETURN"}},
 		// /////////
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-PORT", "-j", "AZURE-NPM-INGRESS-FROM", "-m", "comment", "--comment", "ALL-JUMP-TO-AZURE-NPM-INGRESS-FROM"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-PORT", "-m", "comment", "--comment", "ALL-JUMP-TO-AZURE-NPM-INGRESS-FROM", "-j", "AZURE-NPM-INGRESS-FROM"}},
 		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "AZURE-NPM-EGRESS-PORT"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "RETURN", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "RETURN", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000", "-j", "RETURN"}},
 		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS", "-j", "AZURE-NPM-EGRESS-DROPS"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-j", "RETURN", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-j", "RETURN", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-j", "AZURE-NPM-EGRESS-TO", "-m", "comment", "--comment", "ALL-JUMP-TO-AZURE-NPM-EGRESS-TO"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-DROPS", "-j", "RETURN", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-DROPS", "-j", "RETURN", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000"}},
-		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-DROPS", "-j", "RETURN", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-PORT", "-m", "comment", "--comment", "ALL-JUMP-TO-AZURE-NPM-EGRESS-TO", "-j", "AZURE-NPM-EGRESS-TO"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-INGRESS-DROPS", "-m", "mark", "--mark", "0x2000", "-m", "comment", "--comment", "RETURN-on-INGRESS-mark-0x2000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-DROPS", "-m", "mark", "--mark", "0x3000", "-m", "comment", "--comment", "RETURN-on-EGRESS-and-INGRESS-mark-0x3000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "AZURE-NPM-EGRESS-DROPS", "-m", "mark", "--mark", "0x1000", "-m", "comment", "--comment", "RETURN-on-EGRESS-mark-0x1000", "-j", "RETURN"}},
+		{Cmd: []string{"iptables-restore", "--noflush", "-w", "60"}},
+
+		{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
+
+		{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}, ExitCode: 1},
+		{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 	}
 )
 
@@ -125,7 +114,7 @@ func TestUninitNpmChains(t *testing.T) {
 			name: "no v2 npm chains exist",
 			calls: []testutils.TestCmd{
 				{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}},
-				{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+				{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 				{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
 				{Cmd: []string{"grep", "Chain AZURE-NPM"}, ExitCode: 1},
 				{Cmd: []string{"iptables", "-w", "60", "-F", "AZURE-NPM"}},
@@ -158,7 +147,7 @@ func TestUninitNpmChains(t *testing.T) {
 			name: " v2 exists chian exists",
 			calls: []testutils.TestCmd{
 				{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}},
-				{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+				{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 				{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
 				{Cmd: []string{"grep", "Chain AZURE-NPM"}, Stdout: "Chain AZURE-NPM-INGRESS-ALLOW-MARK (1 references)\n"},
 				{Cmd: []string{"iptables", "-w", "60", "-F", "AZURE-NPM"}},
@@ -229,8 +218,8 @@ func TestCheckAndAddForwardChain(t *testing.T) {
 			args: args{
 				calls: []testutils.TestCmd{
 					{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
-					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}, ExitCode: 1}, // "rule does not exist"
-					{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}, ExitCode: 1}, // "rule does not exist"
+					{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 				},
 				placeAzureChainFirst: util.PlaceAzureChainFirst,
 			},
@@ -242,8 +231,8 @@ func TestCheckAndAddForwardChain(t *testing.T) {
 					{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
 					{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, Stdout: "3  "}, // THIS IS THE GREP CALL STDOUT
 					{Cmd: []string{"grep", "KUBE-SERVICES"}, ExitCode: 1},                                                   // THIS IS THE EXIT CODE FOR CHECK command below ("rule doesn't exist")
-					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
-					{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "4", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
+					{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "4", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 				},
 				placeAzureChainFirst: util.PlaceAzureChainAfterKubeServices,
 			},
@@ -253,7 +242,7 @@ func TestCheckAndAddForwardChain(t *testing.T) {
 			args: args{
 				calls: []testutils.TestCmd{
 					{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
-					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 					{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, Stdout: "1  "}, // THIS IS THE GREP CALL STDOUT
 					{Cmd: []string{"grep", "AZURE-NPM"}},
 				},
@@ -267,7 +256,7 @@ func TestCheckAndAddForwardChain(t *testing.T) {
 					{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
 					{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, Stdout: "3  "}, // THIS IS THE GREP CALL STDOUT
 					{Cmd: []string{"grep", "KUBE-SERVICES"}},
-					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}, Stdout: "4  "}, // THIS IS THE GREP CALL STDOUT
+					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}, Stdout: "4  "}, // THIS IS THE GREP CALL STDOUT
 					{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}},
 					{Cmd: []string{"grep", "AZURE-NPM"}},
 				},
@@ -279,11 +268,11 @@ func TestCheckAndAddForwardChain(t *testing.T) {
 			args: args{
 				calls: []testutils.TestCmd{
 					{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
-					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 					{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, Stdout: "5  "}, // THIS IS THE GREP CALL STDOUT
 					{Cmd: []string{"grep", "AZURE-NPM"}},
-					{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
-					{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+					{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
+					{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 				},
 				placeAzureChainFirst: util.PlaceAzureChainFirst,
 			},
@@ -295,11 +284,11 @@ func TestCheckAndAddForwardChain(t *testing.T) {
 					{Cmd: []string{"iptables", "-w", "60", "-N", "AZURE-NPM"}},
 					{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, Stdout: "3  "}, // THIS IS THE GREP CALL STDOUT
 					{Cmd: []string{"grep", "KUBE-SERVICES"}},
-					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}, Stdout: "2  "}, // THIS IS THE GREP CALL STDOUT
+					{Cmd: []string{"iptables", "-w", "60", "-C", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}, Stdout: "2  "}, // THIS IS THE GREP CALL STDOUT
 					{Cmd: []string{"iptables", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}},
 					{Cmd: []string{"grep", "AZURE-NPM"}},
-					{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
-					{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "3", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+					{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
+					{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "3", "-m", "conntrack", "--ctstate", "NEW", "-j", "AZURE-NPM"}},
 				},
 				placeAzureChainFirst: util.PlaceAzureChainAfterKubeServices,
 			},
@@ -397,6 +386,64 @@ func TestAdd(t *testing.T) {
 	}
 }
 
+func TestAddAll(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"iptables-restore", "--noflush", "-w", "60"}},
+	}
+
+	fexec := testutils.GetFakeExecWithScripts(calls)
+	defer testutils.VerifyCalls(t, fexec, calls)
+	iptMgr := NewIptablesManager(fexec, NewFakeIptOperationShim(), util.PlaceAzureChainAfterKubeServices)
+
+	execCount := resetPrometheusAndGetExecCount(t)
+	defer testPrometheusMetrics(t, 2, execCount+1)
+
+	entries := []*IptEntry{
+		{
+			Chain: util.IptablesAzureIngressPortChain,
+			Specs: []string{util.IptablesJumpFlag, util.IptablesAzureIngressFromChain},
+		},
+		{
+			Chain: util.IptablesAzureIngressDropsChain,
+			Specs: []string{util.IptablesJumpFlag, util.IptablesDrop},
+		},
+	}
+	require.NoError(t, iptMgr.AddAll(entries))
+	require.NoError(t, iptMgr.AddAll(nil))
+}
+
+func TestIptEntryRule(t *testing.T) {
+	entry := &IptEntry{
+		Chain: util.IptablesAzureAcceptChain,
+		Specs: []string{
+			util.IptablesJumpFlag,
+			util.IptablesMark,
+			util.IptablesSetMarkFlag,
+			"0x0",
+			util.IptablesModuleFlag,
+			util.IptablesCommentModuleFlag,
+			util.IptablesCommentFlag,
+			"Clear AZURE-NPM MARKS",
+			"!",
+			"-s",
+			"10.0.0.0/8",
+		},
+	}
+
+	rule := entry.Rule()
+	require.Equal(t, util.IptablesFilterTable, rule.Table)
+	require.Equal(t, iptables.Jump("MARK", "--set-mark", "0x0"), rule.Target)
+	require.Equal(t, []iptables.Match{
+		{Module: "comment", Args: []string{"--comment", "Clear AZURE-NPM MARKS"}},
+		{Args: []string{"!", "-s", "10.0.0.0/8"}},
+	}, rule.Matches)
+	require.Equal(t, []string{"-m", "comment", "--comment", "Clear AZURE-NPM MARKS", "!", "-s", "10.0.0.0/8", "-j", "MARK", "--set-mark", "0x0"}, entry.args())
+
+	// the position of an insertion stays in front
+	entry = &IptEntry{Chain: util.IptablesForwardChain, Specs: []string{"3", util.IptablesJumpFlag, util.IptablesAzureChain}}
+	require.Equal(t, []string{"3", "-j", "AZURE-NPM"}, entry.args())
+}
+
 func resetPrometheusAndGetExecCount(t *testing.T) int {
 	metrics.ResetNumACLRules()
 	execCount, err := metrics.GetACLRuleExecCount()
@@ -530,3 +577,32 @@ func TestMain(m *testing.M) {
 
 	os.Exit(exitCode)
 }
+
+func TestCleanupStaleChains(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-S"}, Stdout: `-N AZURE-NPM
+-N AZURE-NPM-INGRESS
+-N AZURE-NPM-INGRESS-ALLOW-MARK
+-A AZURE-NPM -j AZURE-NPM-INGRESS
+-A AZURE-NPM-INGRESS -m comment --comment "stale jump" -j AZURE-NPM-INGRESS-ALLOW-MARK
+-A AZURE-NPM-INGRESS-ALLOW-MARK -j MARK --set-xmark 0x2000/0xffffffff
+`},
+		{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-D", "AZURE-NPM-INGRESS", "-m", "comment", "--comment", "stale jump", "-j", "AZURE-NPM-INGRESS-ALLOW-MARK"}},
+		{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-F", "AZURE-NPM-INGRESS-ALLOW-MARK"}},
+		{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-X", "AZURE-NPM-INGRESS-ALLOW-MARK"}},
+	}
+
+	fexec := testutils.GetFakeExecWithScripts(calls)
+	defer testutils.VerifyCalls(t, fexec, calls)
+	iptMgr := NewIptablesManager(fexec, NewFakeIptOperationShim(), false)
+
+	iptMgr.cleanupStaleChains()
+}
+
+func TestSplitParams(t *testing.T) {
+	require.Equal(t,
+		[]string{"-t", "filter", "-D", "AZURE-NPM", "-m", "comment", "--comment", "a b", "-j", "DROP"},
+		splitParams(`-t filter -D AZURE-NPM -m comment --comment "a b" -j DROP`),
+	)
+	require.Equal(t, []string{"--comment", ""}, splitParams(`--comment ""`))
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/telemetry"
 	"k8s.io/klog"
 )
 
@@ -54,6 +55,18 @@ func CreateTelemetryHandle(npmVersionNum int, imageVersion, aiMetadata string) e
 	return nil
 }
 
+// CreateOTLPTelemetryHandle exports the telemetry to an OpenTelemetry collector, alongside AI if its handler was created
+func CreateOTLPTelemetryHandle(imageVersion string, config telemetry.OTLPConfig) error {
+	otlp, err := telemetry.NewOTLPTelemetry(util.AzureNpmFlag, imageVersion, config)
+	if err != nil {
+		return err
+	}
+
+	th = telemetry.NewTeeTelemetryHandle(th, otlp)
+	log.Logf("Initialized OTLP handle")
+	return nil
+}
+
 // SendErrorLogAndMetric sends a metric through AI telemetry and sends a log to the Kusto Messages table
 func SendErrorLogAndMetric(operationID int, format string, args ...interface{}) {
 	// Send error metrics
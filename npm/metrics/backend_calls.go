@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	successResult = "success"
+	failureResult = "failure"
+)
+
+// RecordBackendCall records a call of the dataplane to ipset, iptables or HNS (the backend), e.g. an "add" to ipset,
+// with how long it took and whether it failed.
+func RecordBackendCall(backend, operation string, duration time.Duration, failed bool) {
+	result := successResult
+	if failed {
+		result = failureResult
+	}
+	backendCalls.With(prometheus.Labels{backendLabel: backend, backendOperationLabel: operation, resultLabel: result}).Inc()
+	backendCallTime.With(prometheus.Labels{backendLabel: backend, backendOperationLabel: operation}).
+		Observe(float64(duration) / float64(time.Millisecond))
+}
+
+// GetBackendCalls returns the number of calls of the operation to the backend which failed or succeeded.
+// This function is slow.
+func GetBackendCalls(backend, operation string, failed bool) (int, error) {
+	result := successResult
+	if failed {
+		result = failureResult
+	}
+	dtoMetric, err := getDTOMetric(backendCalls.With(prometheus.Labels{backendLabel: backend, backendOperationLabel: operation, resultLabel: result}))
+	if err != nil {
+		return 0, err
+	}
+	return int(dtoMetric.Counter.GetValue()), nil
+}
+
+// GetBackendCallTimeCount returns the number of calls of the operation to the backend whose time was recorded.
+// This function is slow.
+func GetBackendCallTimeCount(backend, operation string) (int, error) {
+	collector, ok := backendCallTime.With(prometheus.Labels{backendLabel: backend, backendOperationLabel: operation}).(prometheus.Collector)
+	if !ok {
+		return 0, errNotCollector
+	}
+	dtoMetric, err := getDTOMetric(collector)
+	if err != nil {
+		return 0, err
+	}
+	return int(dtoMetric.Histogram.GetSampleCount()), nil
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordBackendCall(t *testing.T) {
+	InitializeAll()
+	RecordBackendCall("ipset", "restore", 20*time.Millisecond, false)
+	RecordBackendCall("ipset", "restore", time.Second, true)
+	RecordBackendCall("ipset", "restore", 5*time.Millisecond, false)
+
+	succeeded, err := GetBackendCalls("ipset", "restore", false)
+	require.NoError(t, err)
+	require.Equal(t, 2, succeeded)
+	failed, err := GetBackendCalls("ipset", "restore", true)
+	require.NoError(t, err)
+	require.Equal(t, 1, failed)
+	count, err := GetBackendCallTimeCount("ipset", "restore")
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	count, err = GetBackendCallTimeCount("iptables", "restore")
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
@@ -0,0 +1,18 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// IncDeniedFlows counts a logged packet which network policies denied in the direction ("ingress" or "egress").
+func IncDeniedFlows(direction, protocol string) {
+	deniedFlows.With(prometheus.Labels{directionLabel: direction, protocolLabel: protocol}).Inc()
+}
+
+// GetDeniedFlows returns the number of logged packets denied in the direction with the protocol.
+// This function is slow.
+func GetDeniedFlows(direction, protocol string) (int, error) {
+	dtoMetric, err := getDTOMetric(deniedFlows.With(prometheus.Labels{directionLabel: direction, protocolLabel: protocol}))
+	if err != nil {
+		return 0, err
+	}
+	return int(dtoMetric.Counter.GetValue()), nil
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncDeniedFlows(t *testing.T) {
+	InitializeAll()
+	IncDeniedFlows("ingress", "TCP")
+	IncDeniedFlows("ingress", "TCP")
+	IncDeniedFlows("egress", "UDP")
+
+	val, err := GetDeniedFlows("ingress", "TCP")
+	require.NoError(t, err)
+	require.Equal(t, 2, val)
+	val, err = GetDeniedFlows("egress", "UDP")
+	require.NoError(t, err)
+	require.Equal(t, 1, val)
+	val, err = GetDeniedFlows("egress", "TCP")
+	require.NoError(t, err)
+	require.Zero(t, val)
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// SetPolicyRuleCounters records the packets which the rules of the policy with the "namespace/name" key matched, and
+// how many of its rules matched none.
+func SetPolicyRuleCounters(policyKey string, matchedPackets uint64, unmatchedRules int) {
+	labels := prometheus.Labels{policyLabel: policyKey}
+	policyMatchedPackets.With(labels).Set(float64(matchedPackets))
+	policyUnmatchedRules.With(labels).Set(float64(unmatchedRules))
+}
+
+// ResetPolicyRuleCounters removes the counters of all policies, so that the deleted policies are gone after the
+// counters are recorded again.
+func ResetPolicyRuleCounters() {
+	policyMatchedPackets.Reset()
+	policyUnmatchedRules.Reset()
+}
+
+// GetPolicyRuleCounters returns the matched packets and the unmatched rules of the policy, or zeros if they aren't
+// recorded.
+// This function is slow.
+func GetPolicyRuleCounters(policyKey string) (matchedPackets, unmatchedRules int, err error) {
+	labels := prometheus.Labels{policyLabel: policyKey}
+	matchedPackets, err = getVecValue(policyMatchedPackets, labels)
+	if err != nil {
+		return 0, 0, err
+	}
+	unmatchedRules, err = getVecValue(policyUnmatchedRules, labels)
+	if err != nil {
+		return 0, 0, err
+	}
+	return matchedPackets, unmatchedRules, nil
+}
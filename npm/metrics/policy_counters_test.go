@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyRuleCounters(t *testing.T) {
+	InitializeAll()
+	SetPolicyRuleCounters("x/deny-all", 42, 1)
+
+	packets, unmatched, err := GetPolicyRuleCounters("x/deny-all")
+	require.NoError(t, err)
+	require.Equal(t, 42, packets)
+	require.Equal(t, 1, unmatched)
+
+	ResetPolicyRuleCounters()
+	packets, unmatched, err = GetPolicyRuleCounters("x/deny-all")
+	require.NoError(t, err)
+	require.Zero(t, packets)
+	require.Zero(t, unmatched)
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	ingressDirection = "ingress"
+	egressDirection  = "egress"
+)
+
+// SetEnforcedPolicyDirections records the directions of the traffic on which network policies are enforced.
+func SetEnforcedPolicyDirections(ingress, egress bool) {
+	enforcedDirections.With(prometheus.Labels{directionLabel: ingressDirection}).Set(boolToFloat(ingress))
+	enforcedDirections.With(prometheus.Labels{directionLabel: egressDirection}).Set(boolToFloat(egress))
+}
+
+// GetEnforcedPolicyDirections returns whether network policies are enforced on the ingress and egress traffic.
+// This function is slow.
+func GetEnforcedPolicyDirections() (ingress, egress bool, err error) {
+	ingressVal, err := getVecValue(enforcedDirections, prometheus.Labels{directionLabel: ingressDirection})
+	if err != nil {
+		return false, false, err
+	}
+	egressVal, err := getVecValue(enforcedDirections, prometheus.Labels{directionLabel: egressDirection})
+	if err != nil {
+		return false, false, err
+	}
+	return ingressVal == 1, egressVal == 1, nil
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
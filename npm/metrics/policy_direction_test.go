@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnforcedPolicyDirections(t *testing.T) {
+	InitializeAll()
+	SetEnforcedPolicyDirections(true, false)
+	ingress, egress, err := GetEnforcedPolicyDirections()
+	require.NoError(t, err)
+	require.True(t, ingress)
+	require.False(t, egress)
+
+	SetEnforcedPolicyDirections(true, true)
+	ingress, egress, err = GetEnforcedPolicyDirections()
+	require.NoError(t, err)
+	require.True(t, ingress)
+	require.True(t, egress)
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// PolicyDataplaneSize is what a network policy expands into in the dataplane.
+type PolicyDataplaneSize struct {
+	// Rules is the number of iptables rules, or the number of HNS ACLs on each endpoint in Windows
+	Rules int
+	// IPSets is the number of IPSets, or HNS SetPolicies in Windows, which the policy references
+	IPSets int
+	// ConfigBytes is the size of the iptables-restore file, or of the HNS ACL settings on each endpoint in Windows
+	ConfigBytes int
+}
+
+// SetPolicyDataplaneSize records the dataplane size of the policy with the "namespace/name" key.
+func SetPolicyDataplaneSize(policyKey string, size *PolicyDataplaneSize) {
+	labels := prometheus.Labels{policyLabel: policyKey}
+	policyRules.With(labels).Set(float64(size.Rules))
+	policyIPSets.With(labels).Set(float64(size.IPSets))
+	policyConfigBytes.With(labels).Set(float64(size.ConfigBytes))
+}
+
+// DeletePolicyDataplaneSize removes the dataplane size of the policy, e.g. after the policy is deleted.
+func DeletePolicyDataplaneSize(policyKey string) {
+	labels := prometheus.Labels{policyLabel: policyKey}
+	policyRules.Delete(labels)
+	policyIPSets.Delete(labels)
+	policyConfigBytes.Delete(labels)
+}
+
+// GetPolicyDataplaneSize returns the dataplane size of the policy, or zeros if it isn't recorded.
+// This function is slow.
+func GetPolicyDataplaneSize(policyKey string) (*PolicyDataplaneSize, error) {
+	labels := prometheus.Labels{policyLabel: policyKey}
+	rules, err := getVecValue(policyRules, labels)
+	if err != nil {
+		return nil, err
+	}
+	ipsets, err := getVecValue(policyIPSets, labels)
+	if err != nil {
+		return nil, err
+	}
+	configBytes, err := getVecValue(policyConfigBytes, labels)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyDataplaneSize{Rules: rules, IPSets: ipsets, ConfigBytes: configBytes}, nil
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyDataplaneSize(t *testing.T) {
+	InitializeAll()
+	size := &PolicyDataplaneSize{Rules: 5, IPSets: 3, ConfigBytes: 812}
+	SetPolicyDataplaneSize("x/deny-all", size)
+	SetPolicyDataplaneSize("x/allow-dns", &PolicyDataplaneSize{Rules: 1})
+
+	actual, err := GetPolicyDataplaneSize("x/deny-all")
+	require.NoError(t, err)
+	require.Equal(t, size, actual)
+
+	DeletePolicyDataplaneSize("x/deny-all")
+	actual, err = GetPolicyDataplaneSize("x/deny-all")
+	require.NoError(t, err)
+	require.Equal(t, &PolicyDataplaneSize{}, actual)
+	actual, err = GetPolicyDataplaneSize("x/allow-dns")
+	require.NoError(t, err)
+	require.Equal(t, 1, actual.Rules)
+}
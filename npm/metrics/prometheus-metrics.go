@@ -3,6 +3,7 @@ package metrics
 import (
 	"net/http"
 
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -54,6 +55,57 @@ const (
 	namespaceExecTimeName           = "namespace_exec_time"
 	controllerNamespaceExecTimeHelp = "Execution time in milliseconds for adding/updating/deleting a namespace"
 
+	// rate limiting of the writes to ipset, iptables and HNS
+	dataplaneOperationLabel = "operation"
+
+	throttledOperationsName = "dataplane_throttled_operations"
+	throttledOperationsHelp = "The number of writes to the kernel or HNS which waited for the dataplane rate limiter"
+
+	rateLimitWaitTimeName = "dataplane_rate_limit_wait_time"
+	rateLimitWaitTimeHelp = "Time in milliseconds which writes to the kernel or HNS waited for the dataplane rate limiter"
+
+	// dataplane size of each network policy
+	policyLabel = "policy"
+
+	policyRulesName = "policy_dataplane_rules"
+	policyRulesHelp = "The number of iptables rules (or HNS ACLs on each endpoint in Windows) which a network policy expands into"
+
+	policyIPSetsName = "policy_dataplane_ipsets"
+	policyIPSetsHelp = "The number of IPSets (or HNS SetPolicies in Windows) which a network policy references"
+
+	policyConfigBytesName = "policy_dataplane_config_bytes"
+	policyConfigBytesHelp = "The size in bytes of the iptables-restore file (or HNS ACL settings on each endpoint in Windows) generated for a network policy"
+
+	// directions of the traffic on which network policies are enforced
+	directionLabel = "direction"
+
+	enforcedDirectionsName = "policy_enforced_directions"
+	enforcedDirectionsHelp = "Whether network policies are enforced on the ingress and egress traffic of the pods (1) or not (0)"
+
+	// calls of the dataplane to ipset, iptables and HNS
+	backendLabel          = "backend"
+	backendOperationLabel = "operation"
+	resultLabel           = "result"
+
+	backendCallsName = "dataplane_backend_calls"
+	backendCallsHelp = "The number of calls to ipset, iptables or HNS made by the dataplane, by operation and result"
+
+	backendCallTimeName = "dataplane_backend_call_time"
+	backendCallTimeHelp = "Time in milliseconds of the calls to ipset, iptables or HNS made by the dataplane, by operation"
+
+	// flows denied by network policies and logged by NFLOG
+	protocolLabel = "protocol"
+
+	deniedFlowsName = "denied_flows"
+	deniedFlowsHelp = "The number of logged packets denied by network policies, by direction and protocol"
+
+	// packet counters of the iptables rules of each network policy
+	policyMatchedPacketsName = "policy_matched_packets"
+	policyMatchedPacketsHelp = "The number of packets which the iptables rules of a network policy matched since they were programmed"
+
+	policyUnmatchedRulesName = "policy_unmatched_rules"
+	policyUnmatchedRulesHelp = "The number of iptables rules of a network policy which matched no packets since they were programmed"
+
 	// TODO add health metrics
 
 	quantileMedian float64 = 0.5
@@ -95,6 +147,31 @@ var (
 	controllerNamespaceExecTime *prometheus.SummaryVec
 	controllerExecTimeLabels    = []string{operationLabel, hadErrorLabel}
 
+	throttledOperations      *prometheus.CounterVec
+	rateLimitWaitTime        *prometheus.SummaryVec
+	dataplaneOperationLabels = []string{dataplaneOperationLabel}
+
+	policyRules       *prometheus.GaugeVec
+	policyIPSets      *prometheus.GaugeVec
+	policyConfigBytes *prometheus.GaugeVec
+	policyLabels      = []string{policyLabel}
+
+	policyMatchedPackets *prometheus.GaugeVec
+	policyUnmatchedRules *prometheus.GaugeVec
+
+	enforcedDirections      *prometheus.GaugeVec
+	enforcedDirectionLabels = []string{directionLabel}
+
+	backendCalls          *prometheus.CounterVec
+	backendCallLabels     = []string{backendLabel, backendOperationLabel, resultLabel}
+	backendCallTime       *prometheus.HistogramVec
+	backendCallTimeLabels = []string{backendLabel, backendOperationLabel}
+	// from 1ms to about 16s
+	backendCallTimeBuckets = prometheus.ExponentialBuckets(1, 2, 15)
+
+	deniedFlows       *prometheus.CounterVec
+	deniedFlowsLabels = []string{directionLabel, protocolLabel}
+
 	// TODO add health metrics
 )
 
@@ -134,6 +211,9 @@ func InitializeAll() {
 	} else {
 		initializeDaemonMetrics()
 		initializeControllerMetrics()
+		if err := iptables.RegisterMetrics(nodeRegistry); err != nil {
+			log.Errorf("Error registering iptables metrics: %v", err)
+		}
 		// TODO include dataplane health metrics:
 		// num failures for apply ipsets, updating policies, deleting policies, and running periodic policy tasks, etc.
 		log.Logf("Finished initializing all Prometheus metrics")
@@ -171,6 +251,17 @@ func initializeDaemonMetrics() {
 	// NODE METRICS
 	addACLRuleExecTime = createNodeSummary(addACLRuleExecTimeName, addACLRuleExecTimeHelp)
 	addIPSetExecTime = createNodeSummary(addIPSetExecTimeName, addIPSetExecTimeHelp)
+	throttledOperations = createNodeCounterVec(throttledOperationsName, throttledOperationsHelp, dataplaneOperationLabels)
+	rateLimitWaitTime = createNodeSummaryVec(rateLimitWaitTimeName, "", rateLimitWaitTimeHelp, dataplaneOperationLabels)
+	policyRules = createNodeGaugeVec(policyRulesName, policyRulesHelp, policyLabels)
+	policyIPSets = createNodeGaugeVec(policyIPSetsName, policyIPSetsHelp, policyLabels)
+	policyConfigBytes = createNodeGaugeVec(policyConfigBytesName, policyConfigBytesHelp, policyLabels)
+	enforcedDirections = createNodeGaugeVec(enforcedDirectionsName, enforcedDirectionsHelp, enforcedDirectionLabels)
+	backendCalls = createNodeCounterVec(backendCallsName, backendCallsHelp, backendCallLabels)
+	backendCallTime = createNodeHistogramVec(backendCallTimeName, backendCallTimeHelp, backendCallTimeLabels, backendCallTimeBuckets)
+	deniedFlows = createNodeCounterVec(deniedFlowsName, deniedFlowsHelp, deniedFlowsLabels)
+	policyMatchedPackets = createNodeGaugeVec(policyMatchedPacketsName, policyMatchedPacketsHelp, policyLabels)
+	policyUnmatchedRules = createNodeGaugeVec(policyUnmatchedRulesName, policyUnmatchedRulesHelp, policyLabels)
 }
 
 // initializeControllerMetrics creates metrics modified by the controller
@@ -228,6 +319,46 @@ func createClusterGaugeVec(name, helpMessage string, labels []string) *prometheu
 	return gaugeVec
 }
 
+func createNodeGaugeVec(name, helpMessage string, labels []string) *prometheus.GaugeVec {
+	gaugeVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      helpMessage,
+		},
+		labels,
+	)
+	register(gaugeVec, name, NodeMetrics)
+	return gaugeVec
+}
+
+func createNodeCounterVec(name, helpMessage string, labels []string) *prometheus.CounterVec {
+	counterVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      helpMessage,
+		},
+		labels,
+	)
+	register(counterVec, name, NodeMetrics)
+	return counterVec
+}
+
+func createNodeHistogramVec(name, helpMessage string, labels []string, buckets []float64) *prometheus.HistogramVec {
+	histogramVec := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      helpMessage,
+			Buckets:   buckets,
+		},
+		labels,
+	)
+	register(histogramVec, name, NodeMetrics)
+	return histogramVec
+}
+
 func createNodeSummary(name, helpMessage string) prometheus.Summary {
 	// uses default observation TTL of 10 minutes
 	summary := prometheus.NewSummary(
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RecordRateLimitWait records how long a write of the operation waited for the dataplane rate limiter, and counts
+// it as throttled if it waited at all.
+func RecordRateLimitWait(operation string, wait time.Duration) {
+	labels := prometheus.Labels{dataplaneOperationLabel: operation}
+	rateLimitWaitTime.With(labels).Observe(float64(wait) / float64(time.Millisecond))
+	if wait > 0 {
+		throttledOperations.With(labels).Inc()
+	}
+}
+
+// GetThrottledOperations returns the number of writes of the operation which waited for the rate limiter.
+// This function is slow.
+func GetThrottledOperations(operation string) (int, error) {
+	dtoMetric, err := getDTOMetric(throttledOperations.With(prometheus.Labels{dataplaneOperationLabel: operation}))
+	if err != nil {
+		return 0, err
+	}
+	return int(dtoMetric.Counter.GetValue()), nil
+}
+
+// GetRateLimitWaitCount returns the number of writes of the operation which went through the rate limiter.
+// This function is slow.
+func GetRateLimitWaitCount(operation string) (int, error) {
+	return getCountVecValue(rateLimitWaitTime, prometheus.Labels{dataplaneOperationLabel: operation})
+}
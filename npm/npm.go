@@ -12,6 +12,7 @@ import (
 	controllersv1 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v1"
 	controllersv2 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v2"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/eventing"
 	"github.com/Azure/azure-container-networking/npm/pkg/models"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/version"
@@ -23,6 +24,8 @@ import (
 
 var aiMetadata string //nolint // aiMetadata is set in Makefile
 
+var errNamespaceScopeV1 = errors.New("namespace scope is only supported by v2 NPM")
+
 // NetworkPolicyManager contains informers for pod, namespace and networkpolicy.
 type NetworkPolicyManager struct {
 	config npmconfig.Config
@@ -48,13 +51,15 @@ type NetworkPolicyManager struct {
 	models.AzureConfig
 }
 
-// NewNetworkPolicyManager creates a NetworkPolicyManager
+// NewNetworkPolicyManager creates a NetworkPolicyManager. The recorder reports the dataplane failures of v2 NPM and
+// may be nil.
 func NewNetworkPolicyManager(config npmconfig.Config,
 	informerFactory informers.SharedInformerFactory,
 	dp dataplane.GenericDataplane,
 	exec utilexec.Interface,
 	npmVersion string,
-	k8sServerVersion *version.Info) *NetworkPolicyManager {
+	k8sServerVersion *version.Info,
+	recorder *eventing.Recorder) *NetworkPolicyManager {
 	klog.Infof("API server version: %+v AI metadata %+v", k8sServerVersion, aiMetadata)
 
 	npMgr := &NetworkPolicyManager{
@@ -76,11 +81,17 @@ func NewNetworkPolicyManager(config npmconfig.Config,
 
 	// create v2 NPM specific components.
 	if npMgr.config.Toggles.EnableV2NPM {
+		scopeCfg := config.NamespaceScope
+		namespaceScope, err := controllersv2.NewNamespaceScope(scopeCfg.Include, scopeCfg.Exclude, scopeCfg.LabelSelector)
+		if err != nil {
+			// start validates the config, so this only happens to callers which don't
+			klog.Errorf("failed to create namespace scope, all namespaces are in scope: %v", err)
+		}
 		npMgr.NpmNamespaceCacheV2 = &controllersv2.NpmNamespaceCache{NsMap: make(map[string]*common.Namespace)}
-		npMgr.PodControllerV2 = controllersv2.NewPodController(npMgr.PodInformer, dp, npMgr.NpmNamespaceCacheV2)
-		npMgr.NamespaceControllerV2 = controllersv2.NewNamespaceController(npMgr.NsInformer, dp, npMgr.NpmNamespaceCacheV2)
+		npMgr.PodControllerV2 = controllersv2.NewPodController(npMgr.PodInformer, dp, npMgr.NpmNamespaceCacheV2, namespaceScope, recorder)
+		npMgr.NamespaceControllerV2 = controllersv2.NewNamespaceController(npMgr.NsInformer, dp, npMgr.NpmNamespaceCacheV2, namespaceScope, recorder)
 		// Question(jungukcho): Is config.Toggles.PlaceAzureChainFirst needed for v2?
-		npMgr.NetPolControllerV2 = controllersv2.NewNetworkPolicyController(npMgr.NpInformer, dp)
+		npMgr.NetPolControllerV2 = controllersv2.NewNetworkPolicyController(npMgr.NpInformer, dp, namespaceScope, recorder)
 		return npMgr
 	}
 
@@ -168,6 +179,17 @@ func (npMgr *NetworkPolicyManager) GetAppVersion() string {
 	return npMgr.Version
 }
 
+// UpdateNamespaceScope replaces the scope of the namespaces which v2 NPM programs at runtime.
+func (npMgr *NetworkPolicyManager) UpdateNamespaceScope(scopeCfg npmconfig.NamespaceScopeConfig) error {
+	if !npMgr.config.Toggles.EnableV2NPM {
+		return errNamespaceScopeV1
+	}
+	if err := npMgr.NamespaceControllerV2.UpdateScope(scopeCfg.Include, scopeCfg.Exclude, scopeCfg.LabelSelector); err != nil {
+		return errors.Wrapf(err, "failed to update namespace scope")
+	}
+	return nil
+}
+
 // Start starts shared informers and waits for the shared informer cache to sync.
 func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan struct{}) error {
 	if !config.Toggles.EnableV2NPM {
@@ -198,6 +220,7 @@ func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan
 		go npMgr.PodControllerV2.Run(stopCh)
 		go npMgr.NamespaceControllerV2.Run(stopCh)
 		go npMgr.NetPolControllerV2.Run(stopCh)
+		go npMgr.finishBootup()
 		return nil
 	}
 
@@ -210,6 +233,30 @@ func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan
 	return nil
 }
 
+// bootupFinisher is implemented by the dataplanes which hold the policies after bootup until FinishBootup.
+type bootupFinisher interface {
+	InBootupPhase() bool
+	FinishBootup() error
+}
+
+// finishBootup fills the ipsets of the namespaces and pods in the informer caches, and then lets the dataplane add
+// the policies which it held meanwhile.
+func (npMgr *NetworkPolicyManager) finishBootup() {
+	finisher, ok := npMgr.Dataplane.(bootupFinisher)
+	if !ok || !finisher.InBootupPhase() {
+		return
+	}
+	if err := npMgr.NamespaceControllerV2.InitialSync(); err != nil {
+		klog.Errorf("failed to sync all namespaces before finishing bootup: %v", err)
+	}
+	if err := npMgr.PodControllerV2.InitialSync(); err != nil {
+		klog.Errorf("failed to sync all pods before finishing bootup: %v", err)
+	}
+	if err := finisher.FinishBootup(); err != nil {
+		klog.Errorf("failed to finish bootup: %v", err)
+	}
+}
+
 // GetAIMetadata returns ai metadata number
 func GetAIMetadata() string {
 	return aiMetadata
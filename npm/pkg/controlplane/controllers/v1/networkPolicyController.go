@@ -412,10 +412,8 @@ func (c *NetworkPolicyController) syncAddAndUpdateNetPol(netPolObj *networkingv1
 		return operationKind, fmt.Errorf("[syncAddAndUpdateNetPol] Error: createCidrsRule out due to %w", err)
 	}
 
-	for _, iptEntry := range iptEntries {
-		if err = c.iptMgr.Add(iptEntry); err != nil {
-			return operationKind, fmt.Errorf("[syncAddAndUpdateNetPol] Error: failed to apply iptables rule. Rule: %+v with err: %w", iptEntry, err)
-		}
+	if err = c.iptMgr.AddAll(iptEntries); err != nil {
+		return operationKind, fmt.Errorf("[syncAddAndUpdateNetPol] Error: failed to apply iptables rules with err: %w", err)
 	}
 
 	return operationKind, nil
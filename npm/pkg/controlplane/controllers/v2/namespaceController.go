@@ -13,6 +13,7 @@ import (
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/eventing"
 	"github.com/Azure/azure-container-networking/npm/util"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -26,7 +27,11 @@ import (
 	"k8s.io/klog"
 )
 
-var errWorkqueueFormatting = errors.New("error in formatting")
+var (
+	errWorkqueueFormatting = errors.New("error in formatting")
+	errNoNamespaceScope    = errors.New("namespace controller has no namespace scope")
+	errInitialSync         = errors.New("failed to sync")
+)
 
 // NpmNamespaceCache to store namespace struct in nameSpaceController.go.
 // Since this cache is shared between podController and NamespaceController,
@@ -59,14 +64,22 @@ type NamespaceController struct {
 	nameSpaceLister   corelisters.NamespaceLister
 	workqueue         workqueue.RateLimitingInterface
 	npmNamespaceCache *NpmNamespaceCache
+	namespaceScope    *NamespaceScope
+	// recorder reports the result of applying the dataplane on the node condition
+	recorder *eventing.Recorder
 }
 
-func NewNamespaceController(nameSpaceInformer coreinformer.NamespaceInformer, dp dataplane.GenericDataplane, npmNamespaceCache *NpmNamespaceCache) *NamespaceController {
+// NewNamespaceController returns a NamespaceController which programs the namespaces in scope and keeps the
+// label-based scope up to date. A nil scope has all namespaces in scope. The recorder may be nil.
+func NewNamespaceController(nameSpaceInformer coreinformer.NamespaceInformer, dp dataplane.GenericDataplane, npmNamespaceCache *NpmNamespaceCache,
+	namespaceScope *NamespaceScope, recorder *eventing.Recorder) *NamespaceController {
 	nameSpaceController := &NamespaceController{
 		dp:                dp,
 		nameSpaceLister:   nameSpaceInformer.Lister(),
 		workqueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Namespaces"),
 		npmNamespaceCache: npmNamespaceCache,
+		namespaceScope:    namespaceScope,
+		recorder:          recorder,
 	}
 
 	nameSpaceInformer.Informer().AddEventHandler(
@@ -83,6 +96,51 @@ func (n *NamespaceController) GetCache() map[string]*common.Namespace {
 	return n.npmNamespaceCache.GetCache()
 }
 
+// UpdateScope replaces the namespace scope at runtime, e.g. when the NPM config is reloaded. The namespaces which
+// entered or left the scope are requeued along with their pods and network policies.
+func (nsc *NamespaceController) UpdateScope(include, exclude []string, labelSelector string) error {
+	if nsc.namespaceScope == nil {
+		return errNoNamespaceScope
+	}
+	namespaces, err := nsc.nameSpaceLister.List(k8slabels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	changed, err := nsc.namespaceScope.update(include, exclude, labelSelector, namespaces)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range changed {
+		nsc.workqueue.Add(namespace)
+	}
+	return nil
+}
+
+// InitialSync syncs the namespaces in the informer cache right away instead of waiting for the workqueue, e.g. to
+// fill the ipset lists before the dataplane enforces the policies after bootup. The workqueue retries the namespaces
+// which failed.
+func (nsc *NamespaceController) InitialSync() error {
+	namespaces, err := nsc.nameSpaceLister.List(k8slabels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	numFailed := 0
+	for _, nsObj := range namespaces {
+		key, needSync := nsc.needSync(nsObj, "INITIAL SYNC")
+		if !needSync {
+			continue
+		}
+		if err := nsc.syncNamespace(key); err != nil {
+			klog.Infof("[InitialSync] failed to sync namespace %s: %s", key, err.Error())
+			numFailed++
+		}
+	}
+	if numFailed > 0 {
+		return fmt.Errorf("%w: %d of %d namespaces", errInitialSync, numFailed, len(namespaces))
+	}
+	return nil
+}
+
 // filter this event if we do not need to handle this event
 func (nsc *NamespaceController) needSync(obj interface{}, event string) (string, bool) {
 	needSync := false
@@ -236,6 +294,7 @@ func (nsc *NamespaceController) syncNamespace(nsKey string) error {
 		}
 
 		dperr := nsc.dp.ApplyDataPlane()
+		nsc.recorder.DataplaneApplied(dperr)
 
 		// NOTE: it may seem like Prometheus is considering some ns create events as updates.
 		// This happens when pod create events beat ns create events, so the pod controller will create the ipset
@@ -259,6 +318,7 @@ func (nsc *NamespaceController) syncNamespace(nsKey string) error {
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			klog.Infof("Namespace %s not found, may be it is deleted", nsKey)
+			nsc.namespaceScope.forget(nsKey)
 
 			if _, ok := nsc.npmNamespaceCache.NsMap[nsKey]; ok {
 				// record time to delete namespace if it exists (can't call within cleanDeletedNamespace because this can be called by a pod update)
@@ -285,6 +345,15 @@ func (nsc *NamespaceController) syncNamespace(nsKey string) error {
 		return nsc.cleanDeletedNamespace(nsKey)
 	}
 
+	nsc.namespaceScope.observe(nsObj)
+	if !nsc.namespaceScope.InScope(nsKey) {
+		if _, ok := nsc.npmNamespaceCache.NsMap[nsKey]; ok {
+			// the namespace left the scope
+			operationKind = metrics.DeleteOp
+		}
+		return nsc.cleanDeletedNamespace(nsKey)
+	}
+
 	cachedNsObj, nsExists := nsc.npmNamespaceCache.NsMap[nsKey]
 	if nsExists {
 		if k8slabels.Equals(cachedNsObj.LabelsMap, nsObj.ObjectMeta.Labels) {
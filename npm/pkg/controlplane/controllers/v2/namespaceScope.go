@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+)
+
+// NamespaceScope decides which namespaces NPM programs the namespace, pods and policies of.
+// A nil NamespaceScope has every namespace in scope.
+type NamespaceScope struct {
+	sync.RWMutex
+	include map[string]struct{}
+	exclude map[string]struct{}
+	// selector is nil unless the scope is label-based
+	selector labels.Selector
+	// selected holds the namespaces which matched the selector when the namespace controller last synced them
+	selected map[string]struct{}
+	// onChange is called with a namespace which entered or left the scope
+	onChange []func(namespace string)
+}
+
+// NewNamespaceScope returns the scope of the namespaces which are included (or all if include is empty), aren't
+// excluded, and match the label selector if it isn't empty.
+func NewNamespaceScope(include, exclude []string, labelSelector string) (*NamespaceScope, error) {
+	selector, err := parseNamespaceSelector(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	return &NamespaceScope{
+		include:  namespaceSet(include),
+		exclude:  namespaceSet(exclude),
+		selector: selector,
+		selected: make(map[string]struct{}),
+	}, nil
+}
+
+func parseNamespaceSelector(labelSelector string) (labels.Selector, error) {
+	if labelSelector == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse namespace label selector %q: %w", labelSelector, err)
+	}
+	return selector, nil
+}
+
+func namespaceSet(namespaces []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(namespaces))
+	for _, ns := range namespaces {
+		set[ns] = struct{}{}
+	}
+	return set
+}
+
+// InScope is whether the namespace is in scope. With a label selector, a namespace is out of scope until the
+// namespace controller has synced it.
+func (s *NamespaceScope) InScope(namespace string) bool {
+	if s == nil {
+		return true
+	}
+	s.RLock()
+	defer s.RUnlock()
+	return s.inScope(namespace)
+}
+
+// inScope expects the lock to be held
+func (s *NamespaceScope) inScope(namespace string) bool {
+	if _, ok := s.exclude[namespace]; ok {
+		return false
+	}
+	if len(s.include) > 0 {
+		if _, ok := s.include[namespace]; !ok {
+			return false
+		}
+	}
+	if s.selector == nil {
+		return true
+	}
+	_, ok := s.selected[namespace]
+	return ok
+}
+
+// subscribe calls f with every namespace which enters or leaves the scope at runtime.
+func (s *NamespaceScope) subscribe(f func(namespace string)) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.onChange = append(s.onChange, f)
+}
+
+// observe records whether the namespace matches the label selector, and notifies the subscribers if this moved
+// the namespace in or out of scope.
+func (s *NamespaceScope) observe(nsObj *corev1.Namespace) {
+	if s == nil {
+		return
+	}
+	s.setSelected(nsObj.Name, func(selector labels.Selector) bool {
+		return selector.Matches(labels.Set(nsObj.Labels))
+	})
+}
+
+// forget drops a deleted namespace.
+func (s *NamespaceScope) forget(namespace string) {
+	if s == nil {
+		return
+	}
+	s.setSelected(namespace, func(labels.Selector) bool { return false })
+}
+
+// setSelected records whether the namespace matches the label selector, if the scope is label-based
+func (s *NamespaceScope) setSelected(namespace string, matches func(labels.Selector) bool) {
+	s.Lock()
+	if s.selector == nil {
+		s.Unlock()
+		return
+	}
+	selected := matches(s.selector)
+	_, wasSelected := s.selected[namespace]
+	if selected {
+		s.selected[namespace] = struct{}{}
+	} else {
+		delete(s.selected, namespace)
+	}
+	onChange := s.onChange
+	s.Unlock()
+
+	if wasSelected == selected {
+		return
+	}
+	klog.Infof("[NamespaceScope] namespace %s is now in scope: %t", namespace, selected)
+	for _, f := range onChange {
+		f(namespace)
+	}
+}
+
+// update replaces the included and excluded namespaces and the label selector at runtime, matching the selector
+// against the given namespaces. It notifies the subscribers of the namespaces which entered or left the scope, and
+// returns them so that the namespace controller can requeue them too.
+func (s *NamespaceScope) update(include, exclude []string, labelSelector string, namespaces []*corev1.Namespace) ([]string, error) {
+	selector, err := parseNamespaceSelector(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	wasInScope := make(map[string]bool, len(namespaces))
+	for _, nsObj := range namespaces {
+		wasInScope[nsObj.Name] = s.inScope(nsObj.Name)
+	}
+	s.include = namespaceSet(include)
+	s.exclude = namespaceSet(exclude)
+	s.selector = selector
+	s.selected = make(map[string]struct{})
+	if selector != nil {
+		for _, nsObj := range namespaces {
+			if selector.Matches(labels.Set(nsObj.Labels)) {
+				s.selected[nsObj.Name] = struct{}{}
+			}
+		}
+	}
+	changed := make([]string, 0)
+	for _, nsObj := range namespaces {
+		if s.inScope(nsObj.Name) != wasInScope[nsObj.Name] {
+			changed = append(changed, nsObj.Name)
+		}
+	}
+	onChange := s.onChange
+	s.Unlock()
+
+	for _, namespace := range changed {
+		klog.Infof("[NamespaceScope] namespace %s is now in scope: %t", namespace, !wasInScope[namespace])
+		for _, f := range onChange {
+			f(namespace)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNamespaceScopeInScope(t *testing.T) {
+	var nilScope *NamespaceScope
+	require.True(t, nilScope.InScope("test"))
+
+	scope, err := NewNamespaceScope(nil, nil, "")
+	require.NoError(t, err)
+	require.True(t, scope.InScope("test"))
+
+	scope, err = NewNamespaceScope([]string{"a", "b"}, []string{"b"}, "")
+	require.NoError(t, err)
+	require.True(t, scope.InScope("a"))
+	require.False(t, scope.InScope("b"), "exclude should win over include")
+	require.False(t, scope.InScope("c"))
+
+	scope, err = NewNamespaceScope(nil, []string{"kube-system"}, "npm=enabled")
+	require.NoError(t, err)
+	require.False(t, scope.InScope("a"), "namespace should be out of scope until it is observed")
+	scope.observe(newNameSpace("a", "0", map[string]string{"npm": "enabled"}))
+	scope.observe(newNameSpace("kube-system", "0", map[string]string{"npm": "enabled"}))
+	require.True(t, scope.InScope("a"))
+	require.False(t, scope.InScope("kube-system"))
+	scope.forget("a")
+	require.False(t, scope.InScope("a"))
+
+	_, err = NewNamespaceScope(nil, nil, "npm in (")
+	require.Error(t, err)
+}
+
+func TestNamespaceScopeChange(t *testing.T) {
+	metrics.ReinitializeAll()
+	dp := dataplane.NewInMemoryDataplane(&dataplane.Config{
+		IPSetManagerCfg:  &ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyAllIPSets},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode},
+	})
+	scope, err := NewNamespaceScope(nil, nil, "npm=enabled")
+	require.NoError(t, err)
+
+	factory := kubeinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), noResyncPeriodFunc())
+	nsInformer := factory.Core().V1().Namespaces()
+	podInformer := factory.Core().V1().Pods()
+	npmNamespaceCache := &NpmNamespaceCache{NsMap: make(map[string]*common.Namespace)}
+	nsController := NewNamespaceController(nsInformer, dp, npmNamespaceCache, scope, nil)
+	podController := NewPodController(podInformer, dp, npmNamespaceCache, scope, nil)
+
+	podObj := createPod("a", "test", "0", "10.0.0.1", map[string]string{"app": "a"}, NonHostNetwork, corev1.PodRunning)
+	require.NoError(t, podInformer.Informer().GetIndexer().Add(podObj))
+	require.NoError(t, podController.syncPod("test/a"))
+	require.NotContains(t, podController.podMap, "test/a", "pod should be out of scope until its namespace is synced")
+
+	// the namespace enters the scope and its pods are requeued
+	nsObj := newNameSpace("test", "0", map[string]string{"npm": "enabled"})
+	require.NoError(t, nsInformer.Informer().GetIndexer().Add(nsObj))
+	require.NoError(t, nsController.syncNamespace("test"))
+	require.Contains(t, npmNamespaceCache.NsMap, "test")
+	require.Equal(t, 1, podController.workqueue.Len())
+	require.True(t, podController.processNextWorkItem())
+	require.Contains(t, podController.podMap, "test/a")
+	require.Equal(t, []string{"10.0.0.1"}, dp.IPSets().AppliedSets()["podlabel-app:a"])
+
+	// the namespace leaves the scope and the caches are cleaned up
+	nsObj = newNameSpace("test", "1", map[string]string{"npm": "disabled"})
+	require.NoError(t, nsInformer.Informer().GetIndexer().Update(nsObj))
+	require.NoError(t, nsController.syncNamespace("test"))
+	require.NotContains(t, npmNamespaceCache.NsMap, "test")
+	require.Equal(t, 1, podController.workqueue.Len())
+	require.True(t, podController.processNextWorkItem())
+	require.NotContains(t, podController.podMap, "test/a")
+	require.Empty(t, dp.IPSets().AppliedSets()["podlabel-app:a"])
+}
+
+func TestNamespaceScopeUpdate(t *testing.T) {
+	metrics.ReinitializeAll()
+	dp := dataplane.NewInMemoryDataplane(&dataplane.Config{
+		IPSetManagerCfg:  &ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyAllIPSets},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode},
+	})
+	scope, err := NewNamespaceScope(nil, []string{"test"}, "")
+	require.NoError(t, err)
+
+	factory := kubeinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), noResyncPeriodFunc())
+	nsInformer := factory.Core().V1().Namespaces()
+	podInformer := factory.Core().V1().Pods()
+	npmNamespaceCache := &NpmNamespaceCache{NsMap: make(map[string]*common.Namespace)}
+	nsController := NewNamespaceController(nsInformer, dp, npmNamespaceCache, scope, nil)
+	podController := NewPodController(podInformer, dp, npmNamespaceCache, scope, nil)
+
+	require.NoError(t, nsInformer.Informer().GetIndexer().Add(newNameSpace("test", "0", map[string]string{"npm": "enabled"})))
+	require.NoError(t, nsInformer.Informer().GetIndexer().Add(newNameSpace("other", "0", map[string]string{"npm": "disabled"})))
+	podObj := createPod("a", "test", "0", "10.0.0.1", map[string]string{"app": "a"}, NonHostNetwork, corev1.PodRunning)
+	require.NoError(t, podInformer.Informer().GetIndexer().Add(podObj))
+	require.NoError(t, podController.syncPod("test/a"))
+	require.NotContains(t, podController.podMap, "test/a")
+
+	// the label selector is matched against the existing namespaces right away
+	require.NoError(t, nsController.UpdateScope(nil, nil, "npm=enabled"))
+	require.True(t, scope.InScope("test"))
+	require.False(t, scope.InScope("other"))
+	require.Equal(t, 2, nsController.workqueue.Len(), "both namespaces changed")
+	require.Equal(t, 1, podController.workqueue.Len())
+	require.True(t, podController.processNextWorkItem())
+	require.Contains(t, podController.podMap, "test/a")
+
+	// an unchanged scope requeues nothing
+	require.NoError(t, nsController.UpdateScope(nil, nil, "npm=enabled"))
+	require.Equal(t, 2, nsController.workqueue.Len())
+	require.Equal(t, 0, podController.workqueue.Len())
+
+	require.Error(t, nsController.UpdateScope(nil, nil, "npm in ("))
+	require.True(t, scope.InScope("test"), "a bad selector keeps the scope")
+
+	noScope := NewNamespaceController(nsInformer, dp, npmNamespaceCache, nil, nil)
+	require.ErrorIs(t, noScope.UpdateScope(nil, nil, ""), errNoNamespaceScope)
+}
@@ -12,9 +12,11 @@ import (
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/eventing"
 	"github.com/Azure/azure-container-networking/npm/util"
 	networkingv1 "k8s.io/api/networking/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	networkinginformers "k8s.io/client-go/informers/networking/v1"
@@ -24,6 +26,10 @@ import (
 	"k8s.io/klog"
 )
 
+// failuresBeforeEvent is how many times in a row a policy fails to sync before it gets an Event, which is about 5
+// seconds of retries with the default rate limiter of the workqueue
+const failuresBeforeEvent = 10
+
 var (
 	errNetPolKeyFormat          = errors.New("invalid network policy key format")
 	errNetPolTranslationFailure = errors.New("failed to translate network policy")
@@ -34,7 +40,14 @@ type NetworkPolicyController struct {
 	netPolLister netpollister.NetworkPolicyLister
 	workqueue    workqueue.RateLimitingInterface
 	rawNpSpecMap map[string]*networkingv1.NetworkPolicySpec // Key is <nsname>/<policyname>
-	dp           dataplane.GenericDataplane
+	// rawRejectDeniedMap has the translation.RejectDeniedAnnotation of the policies, which changes the translation
+	// like the spec
+	rawRejectDeniedMap map[string]string
+	dp                 dataplane.GenericDataplane
+	// namespaceScope has the namespaces whose policies are programmed
+	namespaceScope *NamespaceScope
+	// recorder emits Events on the policies which fail to be programmed
+	recorder *eventing.Recorder
 }
 
 func (c *NetworkPolicyController) GetCache() map[string]*networkingv1.NetworkPolicySpec {
@@ -43,13 +56,20 @@ func (c *NetworkPolicyController) GetCache() map[string]*networkingv1.NetworkPol
 	return c.rawNpSpecMap
 }
 
-func NewNetworkPolicyController(npInformer networkinginformers.NetworkPolicyInformer, dp dataplane.GenericDataplane) *NetworkPolicyController {
+// NewNetworkPolicyController returns a NetworkPolicyController which programs the policies of the namespaces in
+// scope. A nil scope has all namespaces in scope. The recorder may be nil.
+func NewNetworkPolicyController(npInformer networkinginformers.NetworkPolicyInformer, dp dataplane.GenericDataplane,
+	namespaceScope *NamespaceScope, recorder *eventing.Recorder) *NetworkPolicyController {
 	netPolController := &NetworkPolicyController{
-		netPolLister: npInformer.Lister(),
-		workqueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "NetworkPolicy"),
-		rawNpSpecMap: make(map[string]*networkingv1.NetworkPolicySpec),
-		dp:           dp,
+		netPolLister:       npInformer.Lister(),
+		workqueue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "NetworkPolicy"),
+		rawNpSpecMap:       make(map[string]*networkingv1.NetworkPolicySpec),
+		rawRejectDeniedMap: make(map[string]string),
+		dp:                 dp,
+		namespaceScope:     namespaceScope,
+		recorder:           recorder,
 	}
+	namespaceScope.subscribe(netPolController.enqueueNamespace)
 
 	npInformer.Informer().AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
@@ -154,6 +174,18 @@ func (c *NetworkPolicyController) Run(stopCh <-chan struct{}) {
 	klog.Info("Shutting down Network Policy workers")
 }
 
+// enqueueNamespace syncs the policies of a namespace which entered or left the scope.
+func (c *NetworkPolicyController) enqueueNamespace(namespace string) {
+	netPols, err := c.netPolLister.NetworkPolicies(namespace).List(labels.Everything())
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.NetpolID, "[enqueueNamespace] Error: failed to list network policies in namespace %s: %v", namespace, err)
+		return
+	}
+	for _, netPol := range netPols {
+		c.addNetworkPolicy(netPol)
+	}
+}
+
 func (c *NetworkPolicyController) runWorker() {
 	for c.processNextWorkItem() {
 	}
@@ -183,6 +215,7 @@ func (c *NetworkPolicyController) processNextWorkItem() bool {
 		if err := c.syncNetPol(key); err != nil {
 			// Put the item back on the workqueue to handle any transient errors.
 			c.workqueue.AddRateLimited(key)
+			c.recordFailure(key, err)
 			return fmt.Errorf("error syncing '%s': %w, requeuing", key, err)
 		}
 		// Finally, if no error occurs we Forget this item so it does not
@@ -200,6 +233,27 @@ func (c *NetworkPolicyController) processNextWorkItem() bool {
 	return true
 }
 
+// recordFailure emits an Event on the policy once it has failed to sync failuresBeforeEvent times in a row, or right
+// away if it can't be translated since retries won't help.
+func (c *NetworkPolicyController) recordFailure(key string, err error) {
+	if c.recorder == nil {
+		return
+	}
+	if !errors.Is(err, errNetPolTranslationFailure) && c.workqueue.NumRequeues(key) < failuresBeforeEvent {
+		return
+	}
+	namespace, name, splitErr := cache.SplitMetaNamespaceKey(key)
+	if splitErr != nil {
+		return
+	}
+	netPolObj, getErr := c.netPolLister.NetworkPolicies(namespace).Get(name)
+	if getErr != nil {
+		// a deleted policy has nowhere to show the Event
+		return
+	}
+	c.recorder.NetworkPolicyFailed(netPolObj, err)
+}
+
 // syncNetPol compares the actual state with the desired, and attempts to converge the two.
 func (c *NetworkPolicyController) syncNetPol(key string) error {
 	// timer for recording execution times
@@ -254,13 +308,25 @@ func (c *NetworkPolicyController) syncNetPol(key string) error {
 		return nil
 	}
 
+	if !c.namespaceScope.InScope(namespace) {
+		if _, ok := c.rawNpSpecMap[key]; ok {
+			// the namespace left the scope
+			operationKind = metrics.DeleteOp
+		}
+		if err = c.cleanUpNetworkPolicy(key); err != nil {
+			return fmt.Errorf("error: %w when network policy is out of scope", err)
+		}
+		return nil
+	}
+
 	cachedNetPolSpecObj, netPolExists := c.rawNpSpecMap[key]
 	if netPolExists {
 		// if network policy does not have different states against lastly applied states stored in cachedNetPolObj,
 		// netPolController does not need to reconcile this update.
 		// In this updateNetworkPolicy event,
 		// newNetPol was updated with states which netPolController does not need to reconcile.
-		if reflect.DeepEqual(cachedNetPolSpecObj, &netPolObj.Spec) {
+		if reflect.DeepEqual(cachedNetPolSpecObj, &netPolObj.Spec) &&
+			c.rawRejectDeniedMap[key] == netPolObj.Annotations[translation.RejectDeniedAnnotation] {
 			return nil
 		}
 	}
@@ -324,6 +390,7 @@ func (c *NetworkPolicyController) syncAddAndUpdateNetPol(netPolObj *networkingv1
 	}
 
 	c.rawNpSpecMap[netpolKey] = &netPolObj.Spec
+	c.rawRejectDeniedMap[netpolKey] = netPolObj.Annotations[translation.RejectDeniedAnnotation]
 	return operationKind, nil
 }
 
@@ -342,6 +409,7 @@ func (c *NetworkPolicyController) cleanUpNetworkPolicy(netPolKey string) error {
 
 	// Success to clean up ipset and iptables operations in kernel and delete the cached network policy from RawNpMap
 	delete(c.rawNpSpecMap, netPolKey)
+	delete(c.rawRejectDeniedMap, netPolKey)
 	metrics.DecNumPolicies()
 	return nil
 }
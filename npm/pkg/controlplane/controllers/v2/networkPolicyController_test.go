@@ -10,6 +10,7 @@ import (
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/metrics/promutil"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	dpmocks "github.com/Azure/azure-container-networking/npm/pkg/dataplane/mocks"
 	gomock "github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -48,7 +49,7 @@ func (f *netPolFixture) newNetPolController(_ chan struct{}, dp dataplane.Generi
 	kubeclient := k8sfake.NewSimpleClientset(f.kubeobjects...)
 	f.kubeInformer = kubeinformers.NewSharedInformerFactory(kubeclient, noResyncPeriodFunc())
 
-	f.netPolController = NewNetworkPolicyController(f.kubeInformer.Networking().V1().NetworkPolicies(), dp)
+	f.netPolController = NewNetworkPolicyController(f.kubeInformer.Networking().V1().NetworkPolicies(), dp, nil, nil)
 
 	for _, netPol := range f.netPolLister {
 		err := f.kubeInformer.Networking().V1().NetworkPolicies().Informer().GetIndexer().Add(netPol)
@@ -425,3 +426,34 @@ func TestLabelUpdateNetworkPolicy(t *testing.T) {
 	}
 	checkNetPolTestResult("TestUpdateNetPol", f, testCases)
 }
+
+func TestNetworkPolicyWithInMemoryDataplane(t *testing.T) {
+	netPolObj := createNetPol()
+	// named ports are not supported in Windows
+	netPolObj.Spec.Egress = nil
+	netPolObj.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+
+	f := newNetPolFixture(t)
+	f.netPolLister = append(f.netPolLister, netPolObj)
+	f.kubeobjects = append(f.kubeobjects, netPolObj)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	dp := dataplane.NewInMemoryDataplane(&dataplane.Config{
+		IPSetManagerCfg: &ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyOnNeed, NetworkName: "azure"},
+	})
+	f.newNetPolController(stopCh, dp)
+
+	addNetPol(f, netPolObj)
+	policyKey := getKey(netPolObj, t)
+	require.Equal(t, []string{policyKey}, dp.GetAllPolicies())
+	policy, ok := dp.Policies().GetPolicy(policyKey)
+	require.True(t, ok)
+	for _, set := range policy.RuleIPSets {
+		require.Contains(t, dp.IPSets().AppliedSets(), set.Metadata.GetPrefixName(), "the sets of the rules are applied")
+	}
+
+	deleteNetPol(t, f, netPolObj, DeletedFinalStateknownObject)
+	require.Empty(t, dp.GetAllPolicies())
+	require.Empty(t, dp.IPSets().AppliedSets(), "no set is referenced anymore")
+}
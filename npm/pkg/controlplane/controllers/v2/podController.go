@@ -13,10 +13,12 @@ import (
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/eventing"
 	"github.com/Azure/azure-container-networking/npm/util"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformer "k8s.io/client-go/informers/core/v1"
@@ -36,6 +38,16 @@ const (
 
 var kubeAllNamespaces = &ipsets.IPSetMetadata{Name: util.KubeAllNamespacesFlag, Type: ipsets.KeyLabelOfNamespace}
 
+// podLabelUpdater is implemented by the dataplanes which can move a pod between the sets of its old and new labels in one call.
+type podLabelUpdater interface {
+	UpdatePodLabels(podMetadata *dataplane.PodMetadata, delta *dataplane.PodLabelDelta) error
+}
+
+// namespaceTeardown is implemented by the dataplanes which can remove the pods of a namespace from all ipsets at once.
+type namespaceTeardown interface {
+	RemoveAllForPodKeyPrefix(podKeyPrefix string) int
+}
+
 type PodController struct {
 	podLister corelisters.PodLister
 	workqueue workqueue.RateLimitingInterface
@@ -43,16 +55,25 @@ type PodController struct {
 	podMap    map[string]*common.NpmPod // Key is <nsname>/<podname>
 	sync.RWMutex
 	npmNamespaceCache *NpmNamespaceCache
+	namespaceScope    *NamespaceScope
+	// recorder reports the result of applying the dataplane on the node condition
+	recorder *eventing.Recorder
 }
 
-func NewPodController(podInformer coreinformer.PodInformer, dp dataplane.GenericDataplane, npmNamespaceCache *NpmNamespaceCache) *PodController {
+// NewPodController returns a PodController which programs the pods of the namespaces in scope. A nil scope has all
+// namespaces in scope. The recorder may be nil.
+func NewPodController(podInformer coreinformer.PodInformer, dp dataplane.GenericDataplane, npmNamespaceCache *NpmNamespaceCache,
+	namespaceScope *NamespaceScope, recorder *eventing.Recorder) *PodController {
 	podController := &PodController{
 		podLister:         podInformer.Lister(),
 		workqueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Pods"),
 		dp:                dp,
 		podMap:            make(map[string]*common.NpmPod),
 		npmNamespaceCache: npmNamespaceCache,
+		namespaceScope:    namespaceScope,
+		recorder:          recorder,
 	}
+	namespaceScope.subscribe(podController.enqueueNamespace)
 
 	podInformer.Informer().AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
@@ -195,6 +216,45 @@ func (c *PodController) Run(stopCh <-chan struct{}) {
 	klog.Info("Shutting down Pod workers")
 }
 
+// InitialSync syncs the pods in the informer cache right away instead of waiting for the workqueue, e.g. to fill the
+// ipsets before the dataplane enforces the policies after bootup. Syncing them again from the workqueue changes
+// nothing, and the workqueue retries the pods which failed.
+func (c *PodController) InitialSync() error {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+	numFailed := 0
+	for _, pod := range pods {
+		key, needSync := c.needSync("INITIAL SYNC", pod)
+		if !needSync {
+			continue
+		}
+		if err := c.syncPod(key); err != nil {
+			klog.Infof("[InitialSync] failed to sync pod %s: %s", key, err.Error())
+			numFailed++
+		}
+	}
+	if numFailed > 0 {
+		return fmt.Errorf("%w: %d of %d pods", errInitialSync, numFailed, len(pods))
+	}
+	return nil
+}
+
+// enqueueNamespace syncs the pods of a namespace which entered or left the scope.
+func (c *PodController) enqueueNamespace(namespace string) {
+	pods, err := c.podLister.Pods(namespace).List(labels.Everything())
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.PodID, "[enqueueNamespace] Error: failed to list pods in namespace %s: %v", namespace, err)
+		return
+	}
+	for _, pod := range pods {
+		if key, needSync := c.needSync("SCOPE", pod); needSync {
+			c.workqueue.Add(key)
+		}
+	}
+}
+
 func (c *PodController) runWorker() {
 	for c.processNextWorkItem() {
 	}
@@ -264,6 +324,7 @@ func (c *PodController) syncPod(key string) error {
 		}
 
 		dperr := c.dp.ApplyDataPlane()
+		c.recorder.DataplaneApplied(dperr)
 
 		// can't record this in another deferred func since deferred funcs are processed in LIFO order
 		metrics.RecordControllerPodExecTime(timer, operationKind, err != nil && dperr != nil)
@@ -289,6 +350,11 @@ func (c *PodController) syncPod(key string) error {
 				operationKind = metrics.DeleteOp
 			}
 
+			if teardown, ok := c.dp.(namespaceTeardown); ok && c.allPodsDeleted(namespace) {
+				c.cleanUpDeletedNamespacePods(teardown, namespace)
+				return nil
+			}
+
 			// cleanUpDeletedPod will check if the pod exists in cache, if it does then proceeds with deletion
 			// if it does not exists, then event will be no-op
 			err = c.cleanUpDeletedPod(key)
@@ -316,6 +382,17 @@ func (c *PodController) syncPod(key string) error {
 		return nil
 	}
 
+	if !c.namespaceScope.InScope(namespace) {
+		if _, ok := c.podMap[key]; ok {
+			// the namespace left the scope
+			operationKind = metrics.DeleteOp
+		}
+		if err = c.cleanUpDeletedPod(key); err != nil {
+			return fmt.Errorf("error: %w when pod is out of scope", err)
+		}
+		return nil
+	}
+
 	cachedNpmPod, npmPodExists := c.podMap[key]
 	if npmPodExists {
 		// if pod does not have different states against lastly applied states stored in cachedNpmPod,
@@ -446,62 +523,18 @@ func (c *PodController) syncAddAndUpdatePod(newPodObj *corev1.Pod) (metrics.Oper
 	}
 
 	// Dealing with #1 pod update event, the IP addresses of cached npmPod and newPodObj are same
-	// If no change in labels, then GetIPSetListCompareLabels will return empty list.
-	// Otherwise it returns list of deleted PodIP from cached pod's labels and list of added PodIp from new pod's labels
-	addToIPSets, deleteFromIPSets := util.GetIPSetListCompareLabels(cachedNpmPod.Labels, newPodObj.Labels)
-
+	// Only the sets of the changed labels are updated. If no label changed, the delta is empty.
+	delta := dataplane.ComputePodLabelDelta(cachedNpmPod.Labels, newPodObj.Labels)
 	newPodMetadata := dataplane.NewPodMetadata(podKey, newPodObj.Status.PodIP, newPodObj.Spec.NodeName)
-	// todo: verify pulling nodename from newpod,
-	// if a pod is getting deleted, we do not have to cleanup policies, so it is okay to pass in wrong nodename
-	cachedPodMetadata := dataplane.NewPodMetadata(podKey, cachedNpmPod.PodIP, newPodMetadata.NodeName)
-	// Delete the pod from its label's ipset.
-	for _, removeIPSetName := range deleteFromIPSets {
-		klog.Infof("Deleting pod %s (ip : %s) from ipset %s", podKey, cachedNpmPod.PodIP, removeIPSetName)
-
-		var toRemoveSet *ipsets.IPSetMetadata
-		if util.IsKeyValueLabelSetName(removeIPSetName) {
-			toRemoveSet = ipsets.NewIPSetMetadata(removeIPSetName, ipsets.KeyValueLabelOfPod)
-		} else {
-			toRemoveSet = ipsets.NewIPSetMetadata(removeIPSetName, ipsets.KeyLabelOfPod)
-		}
-		if err = c.dp.RemoveFromSets([]*ipsets.IPSetMetadata{toRemoveSet}, cachedPodMetadata); err != nil {
-			return metrics.UpdateOp, fmt.Errorf("[syncAddAndUpdatePod] Error: failed to delete pod from label ipset with err: %w", err)
-		}
-		// {IMPORTANT} The order of compared list will be key and then key+val. NPM should only append after both key
-		// key + val ipsets are worked on. 0th index will be key and 1st index will be value of the label
-		removedLabelKey, removedLabelValue := util.GetLabelKVFromSet(removeIPSetName)
-		if removedLabelValue != "" {
-			cachedNpmPod.RemoveLabelsWithKey(removedLabelKey)
+	if !delta.IsEmpty() {
+		klog.Infof("Moving pod %s (ip : %s) out of %d label ipsets and into %d label ipsets",
+			podKey, newPodObj.Status.PodIP, len(delta.SetsToRemove), len(delta.SetsToAdd))
+		if err = c.updatePodLabels(newPodMetadata, delta); err != nil {
+			return metrics.UpdateOp, fmt.Errorf("[syncAddAndUpdatePod] Error: failed to update pod label ipsets with err: %w", err)
 		}
 	}
-
-	// Add the pod to its label's ipset.
-	for _, addIPSetName := range addToIPSets {
-
-		klog.Infof("Creating ipset %s if it doesn't already exist", addIPSetName)
-
-		var toAddSet *ipsets.IPSetMetadata
-		if util.IsKeyValueLabelSetName(addIPSetName) {
-			toAddSet = ipsets.NewIPSetMetadata(addIPSetName, ipsets.KeyValueLabelOfPod)
-		} else {
-			toAddSet = ipsets.NewIPSetMetadata(addIPSetName, ipsets.KeyLabelOfPod)
-		}
-
-		klog.Infof("Adding pod %s (ip : %s) to ipset %s", podKey, newPodObj.Status.PodIP, addIPSetName)
-		if err = c.dp.AddToSets([]*ipsets.IPSetMetadata{toAddSet}, newPodMetadata); err != nil {
-			return metrics.UpdateOp, fmt.Errorf("[syncAddAndUpdatePod] Error: failed to add pod to label ipset with err: %w", err)
-		}
-		// {IMPORTANT} Same as above order is assumed to be key and then key+val. NPM should only append to existing labels
-		// only after both ipsets for a given label's key value pair are added successfully
-		// (TODO) will need to remove this ordering dependency
-		addedLabelKey, addedLabelValue := util.GetLabelKVFromSet(addIPSetName)
-		if addedLabelValue != "" {
-			cachedNpmPod.AppendLabels(map[string]string{addedLabelKey: addedLabelValue}, common.AppendToExistingLabels)
-		}
-	}
-	// This will ensure after all labels are worked on to overwrite. This way will reduce any bugs introduced above
-	// If due to ordering issue the above deleted and added labels are not correct,
-	// this below appendLabels will help ensure correct state in cache for all successful ops.
+	// The cached labels change only after the pod is in the sets of its new labels,
+	// so a retry after an error computes the same delta.
 	cachedNpmPod.AppendLabels(newPodObj.Labels, common.ClearExistingLabels)
 
 	// (TODO): optimize named port addition and deletions.
@@ -528,6 +561,29 @@ func (c *PodController) syncAddAndUpdatePod(newPodObj *corev1.Pod) (metrics.Oper
 	return metrics.UpdateOp, nil
 }
 
+// updatePodLabels moves the pod between the label sets of the delta,
+// one set at a time if the dataplane can't update the pod's labels in one call.
+func (c *PodController) updatePodLabels(podMetadata *dataplane.PodMetadata, delta *dataplane.PodLabelDelta) error {
+	if updater, ok := c.dp.(podLabelUpdater); ok {
+		if err := updater.UpdatePodLabels(podMetadata, delta); err != nil {
+			return fmt.Errorf("failed to update label ipsets: %w", err)
+		}
+		return nil
+	}
+
+	for _, set := range delta.SetsToRemove {
+		if err := c.dp.RemoveFromSets([]*ipsets.IPSetMetadata{set}, podMetadata); err != nil {
+			return fmt.Errorf("failed to delete pod from label ipset %s: %w", set.GetPrefixName(), err)
+		}
+	}
+	for _, set := range delta.SetsToAdd {
+		if err := c.dp.AddToSets([]*ipsets.IPSetMetadata{set}, podMetadata); err != nil {
+			return fmt.Errorf("failed to add pod to label ipset %s: %w", set.GetPrefixName(), err)
+		}
+	}
+	return nil
+}
+
 // cleanUpDeletedPod cleans up all ipset associated with this pod
 func (c *PodController) cleanUpDeletedPod(cachedNpmPodKey string) error {
 	klog.Infof("[cleanUpDeletedPod] deleting Pod with key %s", cachedNpmPodKey)
@@ -572,6 +628,48 @@ func (c *PodController) cleanUpDeletedPod(cachedNpmPodKey string) error {
 	return nil
 }
 
+// allPodsDeleted returns true if no pod of the namespace is left in the informer cache while several are still cached,
+// e.g. after the namespace is deleted. Removing a single pod from its sets is cheaper than scanning all sets.
+func (c *PodController) allPodsDeleted(namespace string) bool {
+	pods, err := c.podLister.Pods(namespace).List(labels.Everything())
+	if err != nil || len(pods) > 0 {
+		return false
+	}
+	numCachedPods := 0
+	for _, npmPod := range c.podMap {
+		if npmPod.Namespace == namespace {
+			numCachedPods++
+		}
+	}
+	return numCachedPods > 1
+}
+
+// cleanUpDeletedNamespacePods removes all the cached pods of the namespace from all their ipsets at once, so that the
+// next ApplyDataPlane removes them in one transaction instead of one pod at a time. If the namespace is gone, its
+// ipset is destroyed in the same transaction. The delete events of the other pods are then no-ops.
+func (c *PodController) cleanUpDeletedNamespacePods(teardown namespaceTeardown, namespace string) {
+	numMembers := teardown.RemoveAllForPodKeyPrefix(namespace + "/")
+	numPods := 0
+	for podKey, npmPod := range c.podMap {
+		if npmPod.Namespace == namespace {
+			delete(c.podMap, podKey)
+			numPods++
+		}
+	}
+	klog.Infof("[cleanUpDeletedNamespacePods] removed %d pods of namespace %s, %d ipset members in total", numPods, namespace, numMembers)
+
+	c.npmNamespaceCache.Lock()
+	_, namespaceExists := c.npmNamespaceCache.NsMap[namespace]
+	c.npmNamespaceCache.Unlock()
+	if namespaceExists {
+		return
+	}
+	// the namespace is no longer in any list, so its empty set can be destroyed unless a policy still refers to it
+	if err := c.dp.DeleteIPSet(ipsets.NewIPSetMetadata(namespace, ipsets.Namespace), util.SoftDelete); err != nil {
+		klog.Infof("[cleanUpDeletedNamespacePods] keeping the ipset of namespace %s: %s", namespace, err.Error())
+	}
+}
+
 // manageNamedPortIpsets helps with adding or deleting Pod namedPort IPsets.
 func (c *PodController) manageNamedPortIpsets(portList []corev1.ContainerPort, podKey,
 	podIP, nodeName string, namedPortOperation NamedPortOperation) error {
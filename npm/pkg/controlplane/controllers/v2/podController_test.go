@@ -14,6 +14,7 @@ import (
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	dpmocks "github.com/Azure/azure-container-networking/npm/pkg/dataplane/mocks"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
 	"github.com/Azure/azure-container-networking/npm/util"
 	gomock "github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -76,7 +77,7 @@ func (f *podFixture) newPodController(_ chan struct{}) {
 	f.kubeInformer = kubeinformers.NewSharedInformerFactory(kubeclient, noResyncPeriodFunc())
 
 	npmNamespaceCache := &NpmNamespaceCache{NsMap: make(map[string]*common.Namespace)}
-	f.podController = NewPodController(f.kubeInformer.Core().V1().Pods(), f.dp, npmNamespaceCache)
+	f.podController = NewPodController(f.kubeInformer.Core().V1().Pods(), f.dp, npmNamespaceCache, nil, nil)
 
 	for _, pod := range f.podLister {
 		err := f.kubeInformer.Core().V1().Pods().Informer().GetIndexer().Add(pod)
@@ -982,3 +983,95 @@ func TestNPMPodNoUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteNamespacePods(t *testing.T) {
+	dp := dataplane.NewInMemoryDataplane(&dataplane.Config{
+		IPSetManagerCfg:  &ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyAllIPSets},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode},
+	})
+	f := newFixture(t, dp)
+	podA1 := createPod("a1", "ns-a", "0", "10.0.0.1", map[string]string{"app": "web"}, NonHostNetwork, corev1.PodRunning)
+	podA2 := createPod("a2", "ns-a", "0", "10.0.0.2", map[string]string{"app": "web"}, NonHostNetwork, corev1.PodRunning)
+	podB1 := createPod("b1", "ns-b", "0", "10.0.0.3", map[string]string{"app": "web"}, NonHostNetwork, corev1.PodRunning)
+	f.podLister = append(f.podLister, podA1, podA2, podB1)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	f.newPodController(stopCh)
+	for _, key := range []string{"ns-a/a1", "ns-a/a2", "ns-b/b1"} {
+		require.NoError(t, f.podController.syncPod(key))
+	}
+
+	// the namespace controller forgets the deleted namespace and removes it from the lists
+	nsASet := ipsets.NewIPSetMetadata("ns-a", ipsets.Namespace)
+	delete(f.podController.npmNamespaceCache.NsMap, "ns-a")
+	require.NoError(t, dp.RemoveFromList(kubeAllNamespaces, []*ipsets.IPSetMetadata{nsASet}))
+
+	indexer := f.kubeInformer.Core().V1().Pods().Informer().GetIndexer()
+	require.NoError(t, indexer.Delete(podA1))
+	require.NoError(t, indexer.Delete(podA2))
+	require.NoError(t, f.podController.syncPod("ns-a/a1"))
+
+	require.NotContains(t, f.podController.podMap, "ns-a/a1")
+	require.NotContains(t, f.podController.podMap, "ns-a/a2", "all the pods of the namespace are removed at once")
+	require.Contains(t, f.podController.podMap, "ns-b/b1")
+	require.Nil(t, dp.GetIPSet(nsASet.GetPrefixName()), "the set of the deleted namespace is destroyed")
+	appSet := dp.GetIPSet(ipsets.NewIPSetMetadata("app:web", ipsets.KeyValueLabelOfPod).GetPrefixName())
+	require.Equal(t, map[string]string{"10.0.0.3": "ns-b/b1"}, appSet.IPPodKey)
+
+	// the delete event of the other pod is a no-op
+	require.NoError(t, f.podController.syncPod("ns-a/a2"))
+	require.Contains(t, f.podController.podMap, "ns-b/b1")
+}
+
+func TestLabelUpdatePodMovesOnlyChangedLabels(t *testing.T) {
+	dp := dataplane.NewInMemoryDataplane(&dataplane.Config{
+		IPSetManagerCfg:  &ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyAllIPSets},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode},
+	})
+	f := newFixture(t, dp)
+	oldPodObj := createPod("a", "ns", "0", "10.0.0.1", map[string]string{"app": "web", "version": "v1"}, NonHostNetwork, corev1.PodRunning)
+	f.podLister = append(f.podLister, oldPodObj)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	f.newPodController(stopCh)
+	require.NoError(t, f.podController.syncPod("ns/a"))
+
+	newPodObj := oldPodObj.DeepCopy()
+	newPodObj.Labels["version"] = "v2"
+	newPodObj.ResourceVersion = "1"
+	require.NoError(t, f.kubeInformer.Core().V1().Pods().Informer().GetIndexer().Update(newPodObj))
+	require.NoError(t, f.podController.syncPod("ns/a"))
+
+	members := func(name string, setType ipsets.SetType) map[string]string {
+		return dp.GetIPSet(ipsets.NewIPSetMetadata(name, setType).GetPrefixName()).IPPodKey
+	}
+	require.Contains(t, members("app:web", ipsets.KeyValueLabelOfPod), "10.0.0.1")
+	require.Contains(t, members("version", ipsets.KeyLabelOfPod), "10.0.0.1")
+	require.Contains(t, members("version:v2", ipsets.KeyValueLabelOfPod), "10.0.0.1")
+	require.Empty(t, members("version:v1", ipsets.KeyValueLabelOfPod))
+	require.Equal(t, newPodObj.Labels, f.podController.podMap["ns/a"].Labels)
+}
+
+func TestPodInitialSync(t *testing.T) {
+	dp := dataplane.NewInMemoryDataplane(&dataplane.Config{
+		IPSetManagerCfg:  &ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyAllIPSets},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode},
+	})
+	f := newFixture(t, dp)
+	f.podLister = append(f.podLister,
+		createPod("a", "ns", "0", "10.0.0.1", map[string]string{"app": "web"}, NonHostNetwork, corev1.PodRunning),
+		createPod("b", "ns", "0", "10.0.0.2", map[string]string{"app": "web"}, NonHostNetwork, corev1.PodRunning),
+		createPod("host", "ns", "0", "10.0.0.3", map[string]string{"app": "web"}, HostNetwork, corev1.PodRunning),
+	)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	f.newPodController(stopCh)
+
+	require.NoError(t, f.podController.InitialSync())
+	require.Contains(t, f.podController.podMap, "ns/a")
+	require.Contains(t, f.podController.podMap, "ns/b")
+	require.NotContains(t, f.podController.podMap, "ns/host", "host network pods aren't synced")
+	appSet := dp.GetIPSet(ipsets.NewIPSetMetadata("app:web", ipsets.KeyValueLabelOfPod).GetPrefixName())
+	require.Equal(t, map[string]string{"10.0.0.1": "ns/a", "10.0.0.2": "ns/b"}, appSet.IPPodKey)
+	require.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, dp.IPSets().AppliedSets()[ipsets.NewIPSetMetadata("ns", ipsets.Namespace).GetPrefixName()])
+}
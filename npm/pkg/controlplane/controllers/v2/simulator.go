@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// kinds of the objects in a SimulatorEvent
+const (
+	PodKind           = "Pod"
+	NamespaceKind     = "Namespace"
+	NetworkPolicyKind = "NetworkPolicy"
+)
+
+var (
+	errUnknownEventKind = errors.New("unknown event kind")
+	errUnknownEventType = errors.New("unknown event type")
+)
+
+// SimulatorEvent is a recorded watch event of a pod, namespace or network policy.
+type SimulatorEvent struct {
+	Type   watch.EventType `json:"type"`
+	Kind   string          `json:"kind"`
+	Object json.RawMessage `json:"object"`
+}
+
+// SimulatorStats are the sync times of the events of one kind.
+type SimulatorStats struct {
+	Events int
+	Errors int
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// Simulator replays recorded events through the v2 controllers one at a time, without informers or workqueues,
+// so that the time spent in the controllers and the dataplane can be measured offline.
+type Simulator struct {
+	podIndexer       cache.Indexer
+	nsIndexer        cache.Indexer
+	netPolIndexer    cache.Indexer
+	podController    *PodController
+	nsController     *NamespaceController
+	netPolController *NetworkPolicyController
+	stats            map[string]*SimulatorStats
+}
+
+func NewSimulator(dp dataplane.GenericDataplane) *Simulator {
+	// the informers are never started, so the clientset is never called
+	factory := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	podInformer := factory.Core().V1().Pods()
+	nsInformer := factory.Core().V1().Namespaces()
+	netPolInformer := factory.Networking().V1().NetworkPolicies()
+
+	npmNamespaceCache := &NpmNamespaceCache{NsMap: make(map[string]*common.Namespace)}
+	return &Simulator{
+		podIndexer:       podInformer.Informer().GetIndexer(),
+		nsIndexer:        nsInformer.Informer().GetIndexer(),
+		netPolIndexer:    netPolInformer.Informer().GetIndexer(),
+		podController:    NewPodController(podInformer, dp, npmNamespaceCache, nil, nil),
+		nsController:     NewNamespaceController(nsInformer, dp, npmNamespaceCache, nil, nil),
+		netPolController: NewNetworkPolicyController(netPolInformer, dp, nil, nil),
+		stats:            make(map[string]*SimulatorStats),
+	}
+}
+
+// ReplayAll replays the stream of JSON events until the end of r. Events which fail to sync are counted and
+// skipped, like the controllers would requeue them. Only a malformed stream stops the replay.
+func (s *Simulator) ReplayAll(r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	for i := 0; ; i++ {
+		event := &SimulatorEvent{}
+		if err := decoder.Decode(event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to decode event %d: %w", i, err)
+		}
+		if err := s.Replay(event); err != nil {
+			if errors.Is(err, errUnknownEventKind) || errors.Is(err, errUnknownEventType) {
+				return fmt.Errorf("failed to replay event %d: %w", i, err)
+			}
+			klog.Errorf("[Simulator] failed to sync event %d: %s", i, err.Error())
+		}
+	}
+}
+
+// Replay updates the lister cache with the event and syncs the object like the controller's worker would.
+func (s *Simulator) Replay(event *SimulatorEvent) error {
+	var (
+		indexer cache.Indexer
+		obj     interface{}
+		syncObj func(key string) error
+	)
+	switch event.Kind {
+	case PodKind:
+		indexer, obj, syncObj = s.podIndexer, &corev1.Pod{}, s.podController.syncPod
+	case NamespaceKind:
+		indexer, obj, syncObj = s.nsIndexer, &corev1.Namespace{}, s.nsController.syncNamespace
+	case NetworkPolicyKind:
+		indexer, obj, syncObj = s.netPolIndexer, &networkingv1.NetworkPolicy{}, s.netPolController.syncNetPol
+	default:
+		return fmt.Errorf("%w: %q", errUnknownEventKind, event.Kind)
+	}
+
+	if err := json.Unmarshal(event.Object, obj); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", event.Kind, err)
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return fmt.Errorf("failed to get key of %s: %w", event.Kind, err)
+	}
+
+	switch event.Type {
+	case watch.Added:
+		err = indexer.Add(obj)
+	case watch.Modified:
+		err = indexer.Update(obj)
+	case watch.Deleted:
+		err = indexer.Delete(obj)
+	default:
+		return fmt.Errorf("%w: %q", errUnknownEventType, event.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update cache with %s %s: %w", event.Kind, key, err)
+	}
+
+	stats, ok := s.stats[event.Kind]
+	if !ok {
+		stats = &SimulatorStats{}
+		s.stats[event.Kind] = stats
+	}
+	start := time.Now()
+	err = syncObj(key)
+	elapsed := time.Since(start)
+
+	stats.Events++
+	stats.Total += elapsed
+	if elapsed > stats.Max {
+		stats.Max = elapsed
+	}
+	if err != nil {
+		stats.Errors++
+		return fmt.Errorf("failed to sync %s %s: %w", event.Kind, key, err)
+	}
+	return nil
+}
+
+// Kinds returns the sorted kinds of the events replayed so far.
+func (s *Simulator) Kinds() []string {
+	kinds := make([]string, 0, len(s.stats))
+	for kind := range s.stats {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Stats returns the sync times of the events of the kind replayed so far.
+func (s *Simulator) Stats(kind string) SimulatorStats {
+	if stats, ok := s.stats[kind]; ok {
+		return *stats
+	}
+	return SimulatorStats{}
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulatorReplayAll(t *testing.T) {
+	metrics.ReinitializeAll()
+	dp := dataplane.NewInMemoryDataplane(&dataplane.Config{
+		IPSetManagerCfg:  &ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyAllIPSets},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode},
+	})
+	simulator := NewSimulator(dp)
+
+	events := `
+{"type": "ADDED", "kind": "Namespace", "object": {"metadata": {"name": "test"}}}
+{"type": "ADDED", "kind": "Pod", "object": {"metadata": {"name": "a", "namespace": "test", "labels": {"app": "a"}}, "status": {"phase": "Running", "podIP": "10.0.0.1"}}}
+{"type": "DELETED", "kind": "Namespace", "object": {"metadata": {"name": "test"}}}
+`
+	require.NoError(t, simulator.ReplayAll(strings.NewReader(events)))
+	require.Equal(t, []string{NamespaceKind, PodKind}, simulator.Kinds())
+	require.Equal(t, 2, simulator.Stats(NamespaceKind).Events)
+	require.Equal(t, 1, simulator.Stats(PodKind).Events)
+	require.Zero(t, simulator.Stats(NetworkPolicyKind).Events)
+	require.Equal(t, []string{"10.0.0.1"}, dp.IPSets().AppliedSets()["podlabel-app:a"])
+
+	require.ErrorIs(t, simulator.ReplayAll(strings.NewReader(`{"type": "ADDED", "kind": "Service", "object": {}}`)), errUnknownEventKind)
+	require.ErrorIs(t, simulator.ReplayAll(strings.NewReader(`{"type": "BOOKMARK", "kind": "Pod", "object": {}}`)), errUnknownEventType)
+	require.Error(t, simulator.ReplayAll(strings.NewReader(`{"type": `)))
+}
@@ -365,7 +365,9 @@ func (gsp *GoalStateProcessor) processIPSetsRemoveEvent(ipsetNames []string, for
 			continue
 		}
 
-		gsp.dp.DeleteIPSet(ipsets.NewIPSetMetadata(cachedIPSet.Name, cachedIPSet.Type), forceDelete)
+		if err := gsp.dp.DeleteIPSet(ipsets.NewIPSetMetadata(cachedIPSet.Name, cachedIPSet.Type), forceDelete); err != nil {
+			klog.Errorf("Error processing %s IPSET remove event %s", ipsetName, err)
+		}
 	}
 }
 
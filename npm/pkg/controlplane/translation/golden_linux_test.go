@@ -0,0 +1,152 @@
+package translation
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	goldenExt = ".rules"
+
+	// limits of the kernel for names and of the comment match
+	maxChainNameLength = 28
+	maxSetNameLength   = 31
+	maxCommentLength   = 256
+)
+
+var (
+	dstPortRegex = regexp.MustCompile(`^(\d+)(?::(\d+))?$`)
+	markRegex    = regexp.MustCompile(`^0x[0-9a-fA-F]+(?:/0x[0-9a-fA-F]+)?$`)
+)
+
+// requireValidRendering fails the test unless every line of the iptables-restore file is one iptables-restore
+// accepts, with chains which are declared or owned by NPM and sets of the policy.
+func requireValidRendering(t *testing.T, npmNetPol *policies.NPMNetworkPolicy, rendered string) {
+	t.Helper()
+	if rendered == "" {
+		return
+	}
+
+	sets := make(map[string]struct{})
+	for _, translatedSets := range [][]*ipsets.TranslatedIPSet{npmNetPol.PodSelectorIPSets, npmNetPol.ChildPodSelectorIPSets, npmNetPol.RuleIPSets} {
+		for _, set := range translatedSets {
+			sets[set.Metadata.GetHashedName()] = struct{}{}
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(rendered, "\n"), "\n")
+	require.Equal(t, "*"+util.IptablesFilterTable, lines[0])
+	require.Equal(t, util.IptablesRestoreCommit, lines[len(lines)-1])
+
+	chains := make(map[string]struct{})
+	for _, line := range lines[1 : len(lines)-1] {
+		tokens := strings.Split(line, " ")
+		for _, token := range tokens {
+			require.NotEmpty(t, token, "empty token in line %q", line)
+		}
+
+		if strings.HasPrefix(line, ":") {
+			chain := strings.TrimPrefix(tokens[0], ":")
+			require.Equal(t, []string{"-", "-"}, tokens[1:], "chain declaration %q", line)
+			require.LessOrEqual(t, len(chain), maxChainNameLength, "chain name %s", chain)
+			chains[chain] = struct{}{}
+			continue
+		}
+
+		require.GreaterOrEqual(t, len(tokens), 2, "line %q", line)
+		chain := tokens[1]
+		if _, ok := chains[chain]; !ok {
+			require.True(t, strings.HasPrefix(chain, util.IptablesAzureChain), "undeclared chain in line %q", line)
+		}
+
+		switch tokens[0] {
+		case util.IptablesFlushFlag:
+			require.Len(t, tokens, 2, "line %q", line)
+		case util.IptablesAppendFlag:
+			requireValidRuleSpecs(t, sets, line, tokens[2:])
+		case util.IptablesInsertionFlag:
+			require.GreaterOrEqual(t, len(tokens), 3, "line %q", line)
+			index, err := strconv.Atoi(tokens[2])
+			require.NoError(t, err, "line %q", line)
+			require.Positive(t, index, "line %q", line)
+			requireValidRuleSpecs(t, sets, line, tokens[3:])
+		default:
+			require.Failf(t, "unexpected command", "line %q", line)
+		}
+	}
+}
+
+func requireValidRuleSpecs(t *testing.T, sets map[string]struct{}, line string, specs []string) {
+	t.Helper()
+	at := func(i int) string {
+		require.Less(t, i, len(specs), "line %q ends early", line)
+		return specs[i]
+	}
+	next := func(i int) string {
+		return at(i + 1)
+	}
+
+	hasProtocol := false
+	hasTarget := false
+	for i := 0; i < len(specs); i++ {
+		switch specs[i] {
+		case util.IptablesProtFlag:
+			require.Contains(t, []string{"tcp", "udp", "sctp"}, strings.ToLower(next(i)), "line %q", line)
+			hasProtocol = true
+			i++
+		case util.IptablesDstPortFlag:
+			require.True(t, hasProtocol, "port without protocol in line %q", line)
+			match := dstPortRegex.FindStringSubmatch(next(i))
+			require.NotNil(t, match, "port %s in line %q", next(i), line)
+			start, _ := strconv.Atoi(match[1])
+			end := start
+			if match[2] != "" {
+				end, _ = strconv.Atoi(match[2])
+			}
+			require.True(t, start >= 1 && start <= end && end <= 65535, "port %s in line %q", next(i), line)
+			i++
+		case util.IptablesModuleFlag:
+			switch next(i) {
+			case util.IptablesSetModuleFlag:
+				i += 2
+				if at(i) == util.IptablesNotFlag {
+					i++
+				}
+				require.Equal(t, util.IptablesMatchSetFlag, at(i), "line %q", line)
+				set := next(i)
+				require.LessOrEqual(t, len(set), maxSetNameLength, "set name %s", set)
+				require.Contains(t, sets, set, "set %s is not in the policy, line %q", set, line)
+				matchString := at(i + 2)
+				require.Contains(t, []string{util.IptablesSrcFlag, util.IptablesDstFlag, util.IptablesNamedPortFlag}, matchString, "line %q", line)
+				i += 2
+			case util.IptablesCommentModuleFlag:
+				i += 2
+				require.Equal(t, util.IptablesCommentFlag, at(i), "line %q", line)
+				comment := next(i)
+				require.LessOrEqual(t, len(comment), maxCommentLength, "comment %s", comment)
+				i++
+			default:
+				require.Failf(t, "unexpected module", "module %s in line %q", next(i), line)
+			}
+		case util.IptablesJumpFlag:
+			target := next(i)
+			hasTarget = true
+			i++
+			if target == util.IptablesMark {
+				require.Equal(t, util.IptablesSetMarkFlag, next(i), "line %q", line)
+				require.Regexp(t, markRegex, next(i+1), "mark in line %q", line)
+				i += 2
+			}
+		default:
+			require.Failf(t, "unexpected token", "token %s in line %q", specs[i], line)
+		}
+	}
+	require.True(t, hasTarget, "no target in line %q", line)
+}
@@ -0,0 +1,94 @@
+package translation
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var update = flag.Bool("update", false, "write the golden files of TestTranslatePolicyGolden for this OS")
+
+var errNotNetworkPolicy = errors.New("object is not a NetworkPolicy")
+
+// corpusDirs hold the real-world policies which are checked against the golden files and seed the fuzzer.
+var corpusDirs = []string{"../../../testpolicies", "testdata/policies"}
+
+func corpusFiles(tb testing.TB) []string {
+	tb.Helper()
+	files := make([]string, 0)
+	for _, dir := range corpusDirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		require.NoError(tb, err)
+		files = append(files, matches...)
+	}
+	require.NotEmpty(tb, files)
+	return files
+}
+
+func decodePolicy(b []byte) (*networkingv1.NetworkPolicy, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(b, nil, nil)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // test helper
+	}
+	npObj, ok := obj.(*networkingv1.NetworkPolicy)
+	if !ok {
+		return nil, errNotNetworkPolicy
+	}
+	return npObj, nil
+}
+
+// renderTranslation returns what the dataplane of this OS programs for the policy, or the error which stops it.
+func renderTranslation(npObj *networkingv1.NetworkPolicy) string {
+	npmNetPol, err := TranslatePolicy(npObj)
+	if err != nil {
+		return "translation error: " + err.Error() + "\n"
+	}
+	rendered, err := policies.RenderPolicy(npmNetPol)
+	if err != nil {
+		return "render error: " + err.Error() + "\n"
+	}
+	return rendered
+}
+
+// TestTranslatePolicyGolden compares the rendered translation of the corpus with the golden files in
+// testdata/golden/<GOOS>. After an intended change of the translation or the dataplane, review the diff of
+//
+//	go test ./npm/pkg/controlplane/translation -run TestTranslatePolicyGolden -update
+func TestTranslatePolicyGolden(t *testing.T) {
+	for _, file := range corpusFiles(t) {
+		file := file
+		name := strings.TrimSuffix(filepath.Base(file), ".yaml")
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(file)
+			require.NoError(t, err)
+			npObj, err := decodePolicy(b)
+			require.NoError(t, err)
+
+			got := renderTranslation(npObj)
+			golden := filepath.Join("testdata", "golden", runtime.GOOS, name+goldenExt)
+			if *update {
+				require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0o755))
+				require.NoError(t, os.WriteFile(golden, []byte(got), 0o644)) //nolint:gosec // test data
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if errors.Is(err, fs.ErrNotExist) {
+				t.Skipf("no golden file %s, write it with -update", golden)
+			}
+			require.NoError(t, err)
+			// the golden files may be checked out with carriage returns on Windows
+			require.Equal(t, strings.ReplaceAll(string(want), "\r\n", "\n"), got)
+		})
+	}
+}
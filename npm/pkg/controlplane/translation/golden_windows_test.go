@@ -0,0 +1,63 @@
+package translation
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/stretchr/testify/require"
+)
+
+const goldenExt = ".json"
+
+// requireValidRendering fails the test unless every ACL setting is one HNS accepts, with addresses which are
+// sets of the policy.
+func requireValidRendering(t *testing.T, npmNetPol *policies.NPMNetworkPolicy, rendered string) {
+	t.Helper()
+	if rendered == "" {
+		return
+	}
+
+	sets := make(map[string]struct{})
+	for _, translatedSets := range [][]*ipsets.TranslatedIPSet{npmNetPol.PodSelectorIPSets, npmNetPol.ChildPodSelectorIPSets, npmNetPol.RuleIPSets} {
+		for _, set := range translatedSets {
+			sets[set.Metadata.GetHashedName()] = struct{}{}
+		}
+	}
+
+	var rules []*policies.NPMACLPolSettings
+	require.NoError(t, json.Unmarshal([]byte(rendered), &rules))
+	require.Len(t, rules, len(npmNetPol.ACLs))
+	for _, rule := range rules {
+		require.Equal(t, npmNetPol.ACLPolicyID, rule.Id)
+		require.Equal(t, hcn.RuleTypeSwitch, rule.RuleType)
+		require.Contains(t, []hcn.ActionType{hcn.ActionTypeAllow, hcn.ActionTypeBlock}, rule.Action)
+		require.Contains(t, []hcn.DirectionType{hcn.DirectionTypeIn, hcn.DirectionTypeOut}, rule.Direction)
+		require.Contains(t, []string{"", "6", "17"}, rule.Protocols)
+		require.NotZero(t, rule.Priority)
+
+		for _, addresses := range []string{rule.LocalAddresses, rule.RemoteAddresses} {
+			if addresses == "" {
+				continue
+			}
+			for _, set := range strings.Split(addresses, ",") {
+				require.Contains(t, sets, set, "set %s is not in the policy", set)
+			}
+		}
+		for _, ports := range []string{rule.LocalPorts, rule.RemotePorts} {
+			if ports == "" {
+				continue
+			}
+			require.NotEmpty(t, rule.Protocols, "ports without protocol")
+			for _, port := range strings.Split(ports, ",") {
+				p, err := strconv.Atoi(port)
+				require.NoError(t, err)
+				require.True(t, p >= 1 && p <= 65535, "port %d", p)
+			}
+		}
+	}
+}
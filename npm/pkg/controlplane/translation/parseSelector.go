@@ -2,6 +2,7 @@ package translation
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
@@ -201,9 +202,9 @@ func parseNSSelector(selector *metav1.LabelSelector) []labelSelector {
 	}
 
 	// #2. MatchLabels
-	for matchKey, matchVal := range selector.MatchLabels {
+	for _, matchKey := range sortedMatchLabelKeys(selector.MatchLabels) {
 		// matchKey + ":" + matchVal (can be empty string) case
-		setName := util.GetIpSetFromLabelKV(matchKey, matchVal)
+		setName := util.GetIpSetFromLabelKV(matchKey, selector.MatchLabels[matchKey])
 		parsedSelectors.addSelector(true, ipsets.KeyValueLabelOfNamespace, setName)
 	}
 
@@ -229,6 +230,17 @@ func parseNSSelector(selector *metav1.LabelSelector) []labelSelector {
 	return parsedSelectors.labelSelectors
 }
 
+// sortedMatchLabelKeys returns the keys of matchLabels in order so that a policy always translates to the same
+// sets and rules, in the same order.
+func sortedMatchLabelKeys(matchLabels map[string]string) []string {
+	keys := make([]string, 0, len(matchLabels))
+	for key := range matchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // parsePodSelector parses podSelector and returns slice of labelSelector object
 // which includes operator, setType, ipset name and its members slice.
 // Members slice exists only if setType is only NestedLabelOfPod.
@@ -236,9 +248,9 @@ func parsePodSelector(policyKey string, selector *metav1.LabelSelector) ([]label
 	parsedSelectors := newParsedSelectors()
 
 	// #1. MatchLabels
-	for matchKey, matchVal := range selector.MatchLabels {
+	for _, matchKey := range sortedMatchLabelKeys(selector.MatchLabels) {
 		// matchKey + ":" + matchVal (can be empty string) case
-		setName := util.GetIpSetFromLabelKV(matchKey, matchVal)
+		setName := util.GetIpSetFromLabelKV(matchKey, selector.MatchLabels[matchKey])
 		parsedSelectors.addSelector(true, ipsets.KeyValueLabelOfPod, setName)
 	}
 
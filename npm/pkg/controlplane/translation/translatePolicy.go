@@ -3,6 +3,7 @@ package translation
 import (
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
@@ -28,8 +29,14 @@ var (
 	ErrUnsupportedExceptCIDR = errors.New("unsupported Except CIDR block translation features used on windows")
 	// ErrUnsupportedSCTP is returned when SCTP protocol is used in windows.
 	ErrUnsupportedSCTP = errors.New("unsupported SCTP protocol used on windows")
+	// ErrInvalidRejectDeniedAnnotation is returned when the RejectDeniedAnnotation isn't a boolean.
+	ErrInvalidRejectDeniedAnnotation = errors.New("invalid " + RejectDeniedAnnotation + " annotation")
 )
 
+// RejectDeniedAnnotation set to "true" on a network policy rejects the traffic which the policy denies instead of
+// dropping it in Linux.
+const RejectDeniedAnnotation = "npm.azure.com/reject-denied-traffic"
+
 type podSelectorResult struct {
 	psSets      []*ipsets.TranslatedIPSet
 	childPSSets []*ipsets.TranslatedIPSet
@@ -556,6 +563,13 @@ func egressPolicy(npmNetPol *policies.NPMNetworkPolicy, netPolName string, egres
 func TranslatePolicy(npObj *networkingv1.NetworkPolicy) (*policies.NPMNetworkPolicy, error) {
 	netPolName := npObj.Name
 	npmNetPol := policies.NewNPMNetworkPolicy(netPolName, npObj.Namespace)
+	if value, ok := npObj.Annotations[RejectDeniedAnnotation]; ok {
+		rejectDenied, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRejectDeniedAnnotation, value)
+		}
+		npmNetPol.RejectDenied = rejectDenied
+	}
 
 	// podSelector in spec.PodSelector is common for ingress and egress.
 	// Process this podSelector first.
@@ -0,0 +1,212 @@
+package translation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var errInvalidPolicy = errors.New("invalid network policy")
+
+// FuzzTranslatePolicy mutates the corpus and checks that translating a policy the API server accepts never
+// panics, and that the dataplane of this OS accepts what it renders when the translation succeeds. Run it with
+//
+//	go test ./npm/pkg/controlplane/translation -run '^$' -fuzz FuzzTranslatePolicy
+func FuzzTranslatePolicy(f *testing.F) {
+	for _, file := range corpusFiles(f) {
+		b, err := os.ReadFile(file)
+		require.NoError(f, err)
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		npObj, err := decodePolicy(b)
+		if err != nil {
+			t.Skip()
+		}
+		if err := validatePolicy(npObj); err != nil {
+			t.Skip(err)
+		}
+
+		npmNetPol, err := TranslatePolicy(npObj)
+		if err != nil {
+			// features which the dataplane doesn't support are rejected
+			return
+		}
+		rendered, err := policies.RenderPolicy(npmNetPol)
+		require.NoError(t, err, "translated policy:\n%s", npmNetPol.PrettyString())
+		requireValidRendering(t, npmNetPol, rendered)
+	})
+}
+
+// validatePolicy returns an error for the policies which the API server rejects, as far as the translation cares.
+func validatePolicy(npObj *networkingv1.NetworkPolicy) error {
+	if errs := validation.IsDNS1123Subdomain(npObj.Name); len(errs) > 0 {
+		return fmt.Errorf("%w: name %q: %s", errInvalidPolicy, npObj.Name, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Label(npObj.Namespace); len(errs) > 0 {
+		return fmt.Errorf("%w: namespace %q: %s", errInvalidPolicy, npObj.Namespace, strings.Join(errs, ", "))
+	}
+	if err := validateLabelSelector(&npObj.Spec.PodSelector); err != nil {
+		return err
+	}
+
+	seenTypes := make(map[networkingv1.PolicyType]struct{})
+	for _, policyType := range npObj.Spec.PolicyTypes {
+		if policyType != networkingv1.PolicyTypeIngress && policyType != networkingv1.PolicyTypeEgress {
+			return fmt.Errorf("%w: policy type %q", errInvalidPolicy, policyType)
+		}
+		if _, ok := seenTypes[policyType]; ok {
+			return fmt.Errorf("%w: duplicate policy type %q", errInvalidPolicy, policyType)
+		}
+		seenTypes[policyType] = struct{}{}
+	}
+
+	for _, rule := range npObj.Spec.Ingress {
+		if err := validateRule(rule.Ports, rule.From); err != nil {
+			return err
+		}
+	}
+	for _, rule := range npObj.Spec.Egress {
+		if err := validateRule(rule.Ports, rule.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRule(ports []networkingv1.NetworkPolicyPort, peers []networkingv1.NetworkPolicyPeer) error {
+	for i := range ports {
+		if err := validatePort(&ports[i]); err != nil {
+			return err
+		}
+	}
+
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			if peer.PodSelector != nil || peer.NamespaceSelector != nil {
+				return fmt.Errorf("%w: ipBlock with a selector", errInvalidPolicy)
+			}
+			if err := validateIPBlock(peer.IPBlock); err != nil {
+				return err
+			}
+			continue
+		}
+		if peer.PodSelector == nil && peer.NamespaceSelector == nil {
+			return fmt.Errorf("%w: empty peer", errInvalidPolicy)
+		}
+		for _, selector := range []*metav1.LabelSelector{peer.PodSelector, peer.NamespaceSelector} {
+			if err := validateLabelSelector(selector); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validatePort(port *networkingv1.NetworkPolicyPort) error {
+	if port.Protocol != nil {
+		switch *port.Protocol {
+		case v1.ProtocolTCP, v1.ProtocolUDP, v1.ProtocolSCTP:
+		default:
+			return fmt.Errorf("%w: protocol %q", errInvalidPolicy, *port.Protocol)
+		}
+	}
+
+	if port.Port == nil {
+		if port.EndPort != nil {
+			return fmt.Errorf("%w: endPort without port", errInvalidPolicy)
+		}
+		return nil
+	}
+	if port.Port.Type == intstr.String {
+		if errs := validation.IsValidPortName(port.Port.StrVal); len(errs) > 0 {
+			return fmt.Errorf("%w: port %q: %s", errInvalidPolicy, port.Port.StrVal, strings.Join(errs, ", "))
+		}
+		if port.EndPort != nil {
+			return fmt.Errorf("%w: endPort with named port", errInvalidPolicy)
+		}
+		return nil
+	}
+	if errs := validation.IsValidPortNum(int(port.Port.IntVal)); len(errs) > 0 {
+		return fmt.Errorf("%w: port %d: %s", errInvalidPolicy, port.Port.IntVal, strings.Join(errs, ", "))
+	}
+	if port.EndPort != nil {
+		if errs := validation.IsValidPortNum(int(*port.EndPort)); len(errs) > 0 || *port.EndPort < port.Port.IntVal {
+			return fmt.Errorf("%w: endPort %d", errInvalidPolicy, *port.EndPort)
+		}
+	}
+	return nil
+}
+
+func validateIPBlock(ipBlock *networkingv1.IPBlock) error {
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		return fmt.Errorf("%w: cidr %q", errInvalidPolicy, ipBlock.CIDR)
+	}
+	cidrOnes, _ := cidr.Mask.Size()
+	for _, except := range ipBlock.Except {
+		exceptIP, exceptCIDR, err := net.ParseCIDR(except)
+		if err != nil {
+			return fmt.Errorf("%w: except %q", errInvalidPolicy, except)
+		}
+		exceptOnes, _ := exceptCIDR.Mask.Size()
+		if !cidr.Contains(exceptIP) || exceptOnes <= cidrOnes {
+			return fmt.Errorf("%w: except %q is not strictly within %q", errInvalidPolicy, except, ipBlock.CIDR)
+		}
+	}
+	return nil
+}
+
+func validateLabelSelector(selector *metav1.LabelSelector) error {
+	if selector == nil {
+		return nil
+	}
+
+	for key, value := range selector.MatchLabels {
+		if err := validateLabel(key, value); err != nil {
+			return err
+		}
+	}
+	for _, req := range selector.MatchExpressions {
+		switch req.Operator {
+		case metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn:
+			if len(req.Values) == 0 {
+				return fmt.Errorf("%w: operator %s without values", errInvalidPolicy, req.Operator)
+			}
+		case metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+			if len(req.Values) > 0 {
+				return fmt.Errorf("%w: operator %s with values", errInvalidPolicy, req.Operator)
+			}
+		default:
+			return fmt.Errorf("%w: operator %q", errInvalidPolicy, req.Operator)
+		}
+		if err := validateLabel(req.Key, req.Values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateLabel(key string, values ...string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return fmt.Errorf("%w: label key %q: %s", errInvalidPolicy, key, strings.Join(errs, ", "))
+	}
+	for _, value := range values {
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("%w: label value %q: %s", errInvalidPolicy, value, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
@@ -2573,3 +2573,24 @@ func TestEgressPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslatePolicyRejectDeniedAnnotation(t *testing.T) {
+	npObj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "x"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	npmNetPol, err := TranslatePolicy(npObj)
+	require.NoError(t, err)
+	require.False(t, npmNetPol.RejectDenied)
+
+	npObj.Annotations = map[string]string{RejectDeniedAnnotation: "true"}
+	npmNetPol, err = TranslatePolicy(npObj)
+	require.NoError(t, err)
+	require.True(t, npmNetPol.RejectDenied)
+
+	npObj.Annotations = map[string]string{RejectDeniedAnnotation: "sometimes"}
+	_, err = TranslatePolicy(npObj)
+	require.ErrorIs(t, err, ErrInvalidRejectDeniedAnnotation)
+}
@@ -0,0 +1,140 @@
+// Package backendmetrics records the calls of the dataplane to ipset, iptables and HNS in the NPM metrics, so that
+// the cost of a controller event in kernel or HNS invocations is visible.
+package backendmetrics
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/util"
+	utilexec "k8s.io/utils/exec"
+)
+
+const (
+	runOperation   = "run"
+	otherOperation = "other"
+)
+
+// operationFlags are the names of the operations of the ipset and iptables commands by their flags.
+var operationFlags = map[string]map[string]string{
+	util.Ipset: {
+		util.IpsetRestoreFlag:  "restore",
+		util.IpsetSaveFlag:     "save",
+		"list":                 "list",
+		util.IpsetTestFlag:     "test",
+		util.IpsetCreationFlag: "create",
+		util.IpsetAppendFlag:   "add",
+		util.IpsetDeletionFlag: "delete",
+		util.IpsetFlushFlag:    "flush",
+		util.IpsetDestroyFlag:  "destroy",
+	},
+	util.Iptables: {
+		util.IptablesChainCreationFlag: "create-chain",
+		util.IptablesInsertionFlag:     "insert",
+		util.IptablesAppendFlag:        "append",
+		util.IptablesDeletionFlag:      "delete",
+		util.IptablesFlushFlag:         "flush",
+		util.IptablesDestroyFlag:       "destroy-chain",
+		util.IptablesCheckFlag:         "check",
+		util.IptablesListFlag:          "list",
+		"--list":                       "list",
+		util.IptablesListRulesFlag:     "list-rules",
+	},
+}
+
+// WrapExec returns an exec which records the commands run for ipset and iptables. Other commands, such as grep, are
+// not recorded.
+func WrapExec(exec utilexec.Interface) utilexec.Interface {
+	return &instrumentedExec{Interface: exec}
+}
+
+type instrumentedExec struct {
+	utilexec.Interface
+}
+
+func (e *instrumentedExec) Command(cmd string, args ...string) utilexec.Cmd {
+	return wrap(e.Interface.Command(cmd, args...), cmd, args)
+}
+
+func (e *instrumentedExec) CommandContext(ctx context.Context, cmd string, args ...string) utilexec.Cmd {
+	return wrap(e.Interface.CommandContext(ctx, cmd, args...), cmd, args)
+}
+
+func wrap(command utilexec.Cmd, cmd string, args []string) utilexec.Cmd {
+	backend := filepath.Base(cmd)
+	switch backend {
+	case util.Ipset, util.Iptables, util.IptablesSave, util.IptablesRestore, util.BashCommand:
+		return &instrumentedCmd{Cmd: command, backend: backend, operation: operation(backend, args)}
+	default:
+		return command
+	}
+}
+
+// operation is the name of the operation of the command, e.g. "add" for "ipset -A". The commands with no flags for
+// their operation, such as iptables-restore, are a "run".
+func operation(backend string, args []string) string {
+	flags, ok := operationFlags[backend]
+	if !ok {
+		return runOperation
+	}
+	for _, arg := range args {
+		if name, ok := flags[arg]; ok {
+			return name
+		}
+	}
+	return otherOperation
+}
+
+// instrumentedCmd records the command when it completes. A started command completes the first time it's waited for.
+type instrumentedCmd struct {
+	utilexec.Cmd
+	backend   string
+	operation string
+	started   time.Time
+}
+
+func (c *instrumentedCmd) record(start time.Time, err error) {
+	metrics.RecordBackendCall(c.backend, c.operation, time.Since(start), err != nil)
+}
+
+func (c *instrumentedCmd) Run() error {
+	start := time.Now()
+	err := c.Cmd.Run()
+	c.record(start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped command
+}
+
+func (c *instrumentedCmd) CombinedOutput() ([]byte, error) {
+	start := time.Now()
+	output, err := c.Cmd.CombinedOutput()
+	c.record(start, err)
+	return output, err //nolint:wrapcheck // same errors as the wrapped command
+}
+
+func (c *instrumentedCmd) Output() ([]byte, error) {
+	start := time.Now()
+	output, err := c.Cmd.Output()
+	c.record(start, err)
+	return output, err //nolint:wrapcheck // same errors as the wrapped command
+}
+
+func (c *instrumentedCmd) Start() error {
+	c.started = time.Now()
+	err := c.Cmd.Start()
+	if err != nil {
+		c.record(c.started, err)
+		c.started = time.Time{}
+	}
+	return err //nolint:wrapcheck // same errors as the wrapped command
+}
+
+func (c *instrumentedCmd) Wait() error {
+	err := c.Cmd.Wait()
+	if !c.started.IsZero() {
+		c.record(c.started, err)
+		c.started = time.Time{}
+	}
+	return err //nolint:wrapcheck // same errors as the wrapped command
+}
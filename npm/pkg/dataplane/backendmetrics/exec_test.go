@@ -0,0 +1,77 @@
+package backendmetrics
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapExec(t *testing.T) {
+	metrics.ReinitializeAll()
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"ipset", "restore"}},
+		{Cmd: []string{"ipset", "restore"}, ExitCode: 1},
+		{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}},
+		{Cmd: []string{"grep", "azure-npm-"}},
+	}
+	exec := WrapExec(testutils.GetFakeExecWithScripts(calls))
+	for _, call := range calls {
+		_, _ = exec.Command(call.Cmd[0], call.Cmd[1:]...).CombinedOutput()
+	}
+
+	requireCalls(t, "ipset", "restore", 1, 1)
+	requireCalls(t, "iptables", "delete", 1, 0)
+	requireCalls(t, "grep", runOperation, 0, 0)
+}
+
+func TestWrapExecStartAndWait(t *testing.T) {
+	metrics.ReinitializeAll()
+	// the fake exec fails all the starts if one fails
+	listCall := testutils.TestCmd{Cmd: []string{"ipset", "list", "--name"}, PipedToCommand: true}
+	list := WrapExec(testutils.GetFakeExecWithScripts([]testutils.TestCmd{listCall})).Command(listCall.Cmd[0], listCall.Cmd[1:]...)
+	require.NoError(t, list.Start())
+	requireCalls(t, "ipset", "list", 0, 0)
+	require.NoError(t, list.Wait())
+	require.NoError(t, list.Wait())
+	requireCalls(t, "ipset", "list", 1, 0)
+
+	failedCall := testutils.TestCmd{Cmd: []string{"iptables", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true, HasStartError: true, ExitCode: 1}
+	failed := WrapExec(testutils.GetFakeExecWithScripts([]testutils.TestCmd{failedCall})).Command(failedCall.Cmd[0], failedCall.Cmd[1:]...)
+	require.Error(t, failed.Start())
+	_ = failed.Wait()
+	requireCalls(t, "iptables", "list", 0, 1)
+}
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		cmd       []string
+		operation string
+	}{
+		{[]string{"ipset", "-X", "azure-npm-123"}, "destroy"},
+		{[]string{"ipset", "list", "--name"}, "list"},
+		{[]string{"ipset", "-exist", "-N", "azure-npm-123", "nethash"}, "create"},
+		{[]string{"iptables", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM"}, "insert"},
+		{[]string{"iptables", "-w", "60", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, "list"},
+		{[]string{"iptables", "-w", "60", "-Z"}, otherOperation},
+		{[]string{"iptables-restore", "-w", "60", "-T", "filter", "--noflush"}, runOperation},
+		{[]string{"bash", "-c", "ipset flush azure-npm-123"}, runOperation},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.operation, operation(tt.cmd[0], tt.cmd[1:]), "command %v", tt.cmd)
+	}
+}
+
+func requireCalls(t *testing.T, backend, operation string, succeeded, failed int) {
+	t.Helper()
+	count, err := metrics.GetBackendCalls(backend, operation, false)
+	require.NoError(t, err)
+	require.Equal(t, succeeded, count, "succeeded calls of %s %s", backend, operation)
+	count, err = metrics.GetBackendCalls(backend, operation, true)
+	require.NoError(t, err)
+	require.Equal(t, failed, count, "failed calls of %s %s", backend, operation)
+	count, err = metrics.GetBackendCallTimeCount(backend, operation)
+	require.NoError(t, err)
+	require.Equal(t, succeeded+failed, count, "timed calls of %s %s", backend, operation)
+}
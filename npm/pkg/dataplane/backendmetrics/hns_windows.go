@@ -0,0 +1,136 @@
+package backendmetrics
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/network/hnswrapper"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+const hnsBackend = "hns"
+
+// WrapHNS returns an HNS wrapper which records the calls to HNS, with the name of the method as the operation.
+func WrapHNS(hns hnswrapper.HnsV2WrapperInterface) hnswrapper.HnsV2WrapperInterface {
+	return &instrumentedHNS{hns: hns}
+}
+
+type instrumentedHNS struct {
+	hns hnswrapper.HnsV2WrapperInterface
+}
+
+func record(operation string, start time.Time, err error) {
+	metrics.RecordBackendCall(hnsBackend, operation, time.Since(start), err != nil)
+}
+
+func (h *instrumentedHNS) CreateEndpoint(endpoint *hcn.HostComputeEndpoint) (*hcn.HostComputeEndpoint, error) {
+	start := time.Now()
+	result, err := h.hns.CreateEndpoint(endpoint)
+	record("CreateEndpoint", start, err)
+	return result, err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) DeleteEndpoint(endpoint *hcn.HostComputeEndpoint) error {
+	start := time.Now()
+	err := h.hns.DeleteEndpoint(endpoint)
+	record("DeleteEndpoint", start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) CreateNetwork(network *hcn.HostComputeNetwork) (*hcn.HostComputeNetwork, error) {
+	start := time.Now()
+	result, err := h.hns.CreateNetwork(network)
+	record("CreateNetwork", start, err)
+	return result, err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) DeleteNetwork(network *hcn.HostComputeNetwork) error {
+	start := time.Now()
+	err := h.hns.DeleteNetwork(network)
+	record("DeleteNetwork", start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) ModifyNetworkSettings(network *hcn.HostComputeNetwork, request *hcn.ModifyNetworkSettingRequest) error {
+	start := time.Now()
+	err := h.hns.ModifyNetworkSettings(network, request)
+	record("ModifyNetworkSettings", start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) AddNetworkPolicy(network *hcn.HostComputeNetwork, networkPolicy hcn.PolicyNetworkRequest) error {
+	start := time.Now()
+	err := h.hns.AddNetworkPolicy(network, networkPolicy)
+	record("AddNetworkPolicy", start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) RemoveNetworkPolicy(network *hcn.HostComputeNetwork, networkPolicy hcn.PolicyNetworkRequest) error {
+	start := time.Now()
+	err := h.hns.RemoveNetworkPolicy(network, networkPolicy)
+	record("RemoveNetworkPolicy", start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) GetNamespaceByID(netNamespacePath string) (*hcn.HostComputeNamespace, error) {
+	start := time.Now()
+	result, err := h.hns.GetNamespaceByID(netNamespacePath)
+	record("GetNamespaceByID", start, err)
+	return result, err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) AddNamespaceEndpoint(namespaceID, endpointID string) error {
+	start := time.Now()
+	err := h.hns.AddNamespaceEndpoint(namespaceID, endpointID)
+	record("AddNamespaceEndpoint", start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) RemoveNamespaceEndpoint(namespaceID, endpointID string) error {
+	start := time.Now()
+	err := h.hns.RemoveNamespaceEndpoint(namespaceID, endpointID)
+	record("RemoveNamespaceEndpoint", start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) GetNetworkByName(networkName string) (*hcn.HostComputeNetwork, error) {
+	start := time.Now()
+	result, err := h.hns.GetNetworkByName(networkName)
+	record("GetNetworkByName", start, err)
+	return result, err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) GetNetworkByID(networkID string) (*hcn.HostComputeNetwork, error) {
+	start := time.Now()
+	result, err := h.hns.GetNetworkByID(networkID)
+	record("GetNetworkByID", start, err)
+	return result, err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) GetEndpointByID(endpointID string) (*hcn.HostComputeEndpoint, error) {
+	start := time.Now()
+	result, err := h.hns.GetEndpointByID(endpointID)
+	record("GetEndpointByID", start, err)
+	return result, err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) ListEndpointsOfNetwork(networkID string) ([]hcn.HostComputeEndpoint, error) {
+	start := time.Now()
+	result, err := h.hns.ListEndpointsOfNetwork(networkID)
+	record("ListEndpointsOfNetwork", start, err)
+	return result, err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, endpointPolicy hcn.PolicyEndpointRequest) error {
+	start := time.Now()
+	err := h.hns.ApplyEndpointPolicy(endpoint, requestType, endpointPolicy)
+	record("ApplyEndpointPolicy", start, err)
+	return err //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *instrumentedHNS) GetEndpointByName(endpointName string) (*hcn.HostComputeEndpoint, error) {
+	start := time.Now()
+	result, err := h.hns.GetEndpointByName(endpointName)
+	record("GetEndpointByName", start, err)
+	return result, err //nolint:wrapcheck // same errors as the wrapped HNS
+}
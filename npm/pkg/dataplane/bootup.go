@@ -0,0 +1,101 @@
+package dataplane
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/klog"
+)
+
+// bootupPhase holds the policies added after bootup until FinishBootup. Until then, the dataplane only has ipsets,
+// which the controllers fill from the informer caches, so no traffic is denied because a policy refers to a set
+// whose members aren't in the dataplane yet.
+type bootupPhase struct {
+	sync.Mutex
+	finished bool
+	// policies are the latest version of each held policy, by policy key
+	policies map[string]*policies.NPMNetworkPolicy
+}
+
+func newBootupPhase(holdPolicies bool) *bootupPhase {
+	return &bootupPhase{
+		finished: !holdPolicies,
+		policies: make(map[string]*policies.NPMNetworkPolicy),
+	}
+}
+
+// hold keeps the policy, replacing a held version of it, and returns true until the bootup phase finishes.
+func (b *bootupPhase) hold(policy *policies.NPMNetworkPolicy) bool {
+	b.Lock()
+	defer b.Unlock()
+	if b.finished {
+		return false
+	}
+	klog.Infof("[DataPlane] holding policy %s until bootup finishes", policy.PolicyKey)
+	b.policies[policy.PolicyKey] = policy
+	return true
+}
+
+// release forgets a held policy and returns true until the bootup phase finishes.
+func (b *bootupPhase) release(policyKey string) bool {
+	b.Lock()
+	defer b.Unlock()
+	if b.finished {
+		return false
+	}
+	delete(b.policies, policyKey)
+	return true
+}
+
+// InBootupPhase returns true while the dataplane holds the policies.
+func (dp *DataPlane) InBootupPhase() bool {
+	dp.bootup.Lock()
+	defer dp.bootup.Unlock()
+	return !dp.bootup.finished
+}
+
+// FinishBootup ends the bootup phase: it applies the ipsets which the controllers filled since bootup and then adds
+// the policies which it held meanwhile. The policies added afterwards go to the dataplane right away. It does nothing
+// when the policies weren't held or the bootup already finished.
+func (dp *DataPlane) FinishBootup() error {
+	// the policies added meanwhile wait for the held ones so that they aren't replaced by an older version
+	dp.bootup.Lock()
+	defer dp.bootup.Unlock()
+	if dp.bootup.finished {
+		return nil
+	}
+	dp.bootup.finished = true
+	heldPolicies := dp.bootup.policies
+	dp.bootup.policies = nil
+
+	if err := dp.ApplyDataPlane(); err != nil {
+		metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "[DataPlane] failed to apply ipsets when finishing bootup. err: [%s]", err.Error())
+	}
+
+	policyKeys := make([]string, 0, len(heldPolicies))
+	for policyKey := range heldPolicies {
+		policyKeys = append(policyKeys, policyKey)
+	}
+	sort.Strings(policyKeys)
+	klog.Infof("[DataPlane] finishing bootup with %d held policies", len(policyKeys))
+
+	var aggregateErr error
+	for _, policyKey := range policyKeys {
+		if err := dp.addPolicy(heldPolicies[policyKey]); err != nil {
+			metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "[DataPlane] failed to add held policy %s. err: [%s]", policyKey, err.Error())
+			if aggregateErr == nil {
+				aggregateErr = fmt.Errorf("failed to add held policy %s: %w", policyKey, err)
+			} else {
+				aggregateErr = fmt.Errorf("failed to add held policy %s: %s. previous err: [%w]", policyKey, err.Error(), aggregateErr)
+			}
+		}
+	}
+	if aggregateErr != nil {
+		return fmt.Errorf("[DataPlane] error while finishing bootup: %w", aggregateErr)
+	}
+	return nil
+}
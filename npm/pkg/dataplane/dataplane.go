@@ -2,6 +2,7 @@ package dataplane
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ratelimiter"
 	"github.com/Azure/azure-container-networking/npm/util"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
 	"k8s.io/klog"
@@ -22,6 +24,11 @@ type PolicyMode string
 type Config struct {
 	*ipsets.IPSetManagerCfg
 	*policies.PolicyManagerCfg
+	// RateLimiterCfg limits the writes to ipset, iptables and HNS when enabled
+	RateLimiterCfg *ratelimiter.Config
+	// HoldPoliciesUntilBootupFinishes keeps the policies out of the dataplane until FinishBootup is called,
+	// so that no policy refers to ipsets which the controllers haven't filled yet after a restart
+	HoldPoliciesUntilBootupFinishes bool
 }
 
 type updatePodCache struct {
@@ -33,19 +40,10 @@ func newUpdatePodCache() *updatePodCache {
 	return &updatePodCache{cache: make(map[string]*updateNPMPod)}
 }
 
-type endpointCache struct {
-	sync.Mutex
-	cache map[string]*npmEndpoint
-}
-
-func newEndpointCache() *endpointCache {
-	return &endpointCache{cache: make(map[string]*npmEndpoint)}
-}
-
 type DataPlane struct {
 	*Config
-	policyMgr *policies.PolicyManager
-	ipsetMgr  *ipsets.IPSetManager
+	policyMgr policies.Manager
+	ipsetMgr  ipsets.Manager
 	networkID string
 	nodeName  string
 	// endpointCache stores all endpoints of the network (including off-node)
@@ -54,23 +52,94 @@ type DataPlane struct {
 	ioShim         *common.IOShim
 	updatePodCache *updatePodCache
 	stopChannel    <-chan struct{}
+	// rateLimiter limits the writes of the ioShim. It's nil for a DataPlane created with NewDataPlaneWithManagers.
+	rateLimiter *ratelimiter.Limiter
+	// bootup holds the policies until FinishBootup when HoldPoliciesUntilBootupFinishes is set
+	bootup *bootupPhase
 }
 
+var _ GenericDataplane = (*DataPlane)(nil)
+
 func NewDataPlane(nodeName string, ioShim *common.IOShim, cfg *Config, stopChannel <-chan struct{}) (*DataPlane, error) {
 	metrics.InitializeAll()
 	if util.IsWindowsDP() {
 		klog.Infof("[DataPlane] enabling AddEmptySetToLists for Windows")
 		cfg.IPSetManagerCfg.AddEmptySetToLists = true
 	}
+	if cfg.RateLimiterCfg.Enabled() {
+		klog.Infof("[DataPlane] limiting writes to %v per second with a burst of %d", cfg.RateLimiterCfg.OpsPerSecond, cfg.RateLimiterCfg.Burst)
+	}
+	if !cfg.EnforcesIngress() || !cfg.EnforcesEgress() {
+		klog.Infof("[DataPlane] only enforcing network policies in direction %s", cfg.EnforcedDirection)
+	}
+	metrics.SetEnforcedPolicyDirections(cfg.EnforcesIngress(), cfg.EnforcesEgress())
+	if cfg.RejectDenied {
+		if util.IsWindowsDP() {
+			klog.Infof("[DataPlane] HNS ACLs can't reject, so denied traffic is blocked")
+		} else {
+			klog.Infof("[DataPlane] rejecting denied traffic instead of dropping it")
+		}
+	}
+	if cfg.HoldPoliciesUntilBootupFinishes {
+		klog.Infof("[DataPlane] holding network policies until the ipsets are synced after bootup")
+	}
+	if cfg.LogDenied {
+		if util.IsWindowsDP() {
+			klog.Infof("[DataPlane] HNS doesn't log the flows blocked by ACLs, so denied traffic isn't logged")
+		} else {
+			klog.Infof("[DataPlane] logging denied traffic to NFLOG group %d", cfg.LogDeniedGroup)
+		}
+	}
+	// the writes always go through the limiter so that SetRateLimit can enable it at runtime.
+	// the time of the calls recorded in the metrics doesn't include the wait for the limiter.
+	limiter := ratelimiter.NewReloadable(cfg.RateLimiterCfg)
+	ioShim = rateLimitedIOShim(instrumentedIOShim(ioShim), limiter)
+	dp, err := NewDataPlaneWithManagers(
+		nodeName,
+		ioShim,
+		cfg,
+		ipsets.NewIPSetManager(cfg.IPSetManagerCfg, ioShim),
+		policies.NewPolicyManager(ioShim, cfg.PolicyManagerCfg),
+		stopChannel,
+	)
+	if err != nil {
+		return nil, err
+	}
+	dp.rateLimiter = limiter
+	return dp, nil
+}
+
+// SetRateLimit changes the limit of the writes to ipset, iptables and HNS at runtime. It does nothing for a
+// DataPlane which wasn't created by NewDataPlane.
+func (dp *DataPlane) SetRateLimit(cfg *ratelimiter.Config) {
+	if cfg.Enabled() {
+		klog.Infof("[DataPlane] limiting writes to %v per second with a burst of %d", cfg.OpsPerSecond, cfg.Burst)
+	} else {
+		klog.Infof("[DataPlane] not limiting writes")
+	}
+	dp.rateLimiter.SetConfig(cfg)
+}
+
+// NewDataPlaneWithManagers returns a DataPlane on the given ipset and policy managers, such as the fakes in
+// tests which shouldn't depend on the OS.
+func NewDataPlaneWithManagers(
+	nodeName string,
+	ioShim *common.IOShim,
+	cfg *Config,
+	ipsetMgr ipsets.Manager,
+	policyMgr policies.Manager,
+	stopChannel <-chan struct{},
+) (*DataPlane, error) {
 	dp := &DataPlane{
 		Config:         cfg,
-		policyMgr:      policies.NewPolicyManager(ioShim, cfg.PolicyManagerCfg),
-		ipsetMgr:       ipsets.NewIPSetManager(cfg.IPSetManagerCfg, ioShim),
+		policyMgr:      policyMgr,
+		ipsetMgr:       ipsetMgr,
 		endpointCache:  newEndpointCache(),
 		nodeName:       nodeName,
 		ioShim:         ioShim,
 		updatePodCache: newUpdatePodCache(),
 		stopChannel:    stopChannel,
+		bootup:         newBootupPhase(cfg.HoldPoliciesUntilBootupFinishes),
 	}
 
 	err := dp.BootupDataplane()
@@ -107,9 +176,36 @@ func (dp *DataPlane) RunPeriodicTasks() {
 				// in Windows, does nothing
 				// in Linux, locks policy manager but can be interrupted
 				dp.policyMgr.Reconcile()
+
+				// in Windows, does nothing
+				dp.recordRuleCounters()
 			}
 		}
 	}()
+
+	go func() {
+		ticker := time.NewTicker(ipsets.MemberTTLTick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-dp.stopChannel:
+				return
+			case now := <-ticker.C:
+				dp.expireIPSetMembers(now)
+			}
+		}
+	}()
+}
+
+// expireIPSetMembers removes the ipset members whose TTL has passed from the dataplane
+func (dp *DataPlane) expireIPSetMembers(now time.Time) {
+	if dp.ipsetMgr.ExpireMembers(now) == 0 {
+		return
+	}
+	if err := dp.ipsetMgr.ApplyIPSets(); err != nil {
+		metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "[DataPlane] failed to apply ipsets after expiring members. err: [%s]", err.Error())
+	}
 }
 
 func (dp *DataPlane) GetIPSet(setName string) *ipsets.IPSet {
@@ -122,9 +218,12 @@ func (dp *DataPlane) CreateIPSets(setMetadata []*ipsets.IPSetMetadata) {
 }
 
 // DeleteSet checks for members and references of the given "set" type ipset
-// if not used then will delete it from cache
-func (dp *DataPlane) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, forceDelete util.DeleteOption) {
-	dp.ipsetMgr.DeleteIPSet(setMetadata.GetPrefixName(), forceDelete)
+// if not used then will delete it from cache, otherwise returns ipsets.ErrSetInUse
+func (dp *DataPlane) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, forceDelete util.DeleteOption) error {
+	if err := dp.ipsetMgr.DeleteIPSet(setMetadata.GetPrefixName(), forceDelete); err != nil {
+		return fmt.Errorf("[DataPlane] error while deleting set: %w", err)
+	}
+	return nil
 }
 
 // AddToSets takes in a list of IPSet names along with IP member
@@ -182,6 +281,32 @@ func (dp *DataPlane) RemoveFromSets(setNames []*ipsets.IPSetMetadata, podMetadat
 	return nil
 }
 
+// RemoveAllForPodKeyPrefix removes the pods whose key starts with the prefix from all sets at once, e.g. the pods of
+// a deleted namespace, and returns how many members it removed. It doesn't update the policies of the pods' endpoints,
+// so it is only for pods which are gone. The pending updates of the pods are dropped and their endpoints lose their
+// pod keys, as if the endpoints were gone.
+func (dp *DataPlane) RemoveAllForPodKeyPrefix(podKeyPrefix string) int {
+	numMembers := dp.ipsetMgr.RemoveAllForPodKeyPrefix(podKeyPrefix)
+	if dp.shouldUpdatePod() {
+		// lock updatePodCache and then the endpoint cache, in the same order as ApplyDataPlane
+		dp.updatePodCache.Lock()
+		defer dp.updatePodCache.Unlock()
+
+		for podKey := range dp.updatePodCache.cache {
+			if strings.HasPrefix(podKey, podKeyPrefix) {
+				delete(dp.updatePodCache.cache, podKey)
+			}
+		}
+
+		dp.endpointCache.Lock()
+		defer dp.endpointCache.Unlock()
+
+		numEndpoints := dp.endpointCache.removePodKeyPrefix(podKeyPrefix, time.Now().Unix())
+		klog.Infof("[DataPlane] removed the pod keys with prefix %s from %d endpoints", podKeyPrefix, numEndpoints)
+	}
+	return numMembers
+}
+
 // AddToLists takes a list name and list of sets which are to be added as members
 // to given list
 func (dp *DataPlane) AddToLists(listName, setNames []*ipsets.IPSetMetadata) error {
@@ -249,16 +374,31 @@ func (dp *DataPlane) ApplyDataPlane() error {
 // AddPolicy takes in a translated NPMNetworkPolicy object and applies on dataplane
 func (dp *DataPlane) AddPolicy(policy *policies.NPMNetworkPolicy) error {
 	klog.Infof("[DataPlane] Add Policy called for %s", policy.PolicyKey)
+	if dp.bootup.hold(policy) {
+		return nil
+	}
+	return dp.addPolicy(policy)
+}
+
+func (dp *DataPlane) addPolicy(policy *policies.NPMNetworkPolicy) error {
+
+	// leave out the ACLs and rule IPSets of the direction which isn't enforced before creating anything
+	filtered := policies.FilterEnforcedDirection(policy, dp.EnforcedDirection)
+	if len(filtered.ACLs) == 0 && len(policy.ACLs) > 0 {
+		klog.Infof("[DataPlane] Policy %s has no rules in the enforced direction %s", policy.PolicyKey, dp.EnforcedDirection)
+		return nil
+	}
+	policy = filtered
 
 	// Create and add references for Selector IPSets first
-	err := dp.createIPSetsAndReferences(policy.AllPodSelectorIPSets(), policy.PolicyKey, ipsets.SelectorType)
+	err := createIPSetsAndReferences(dp.ipsetMgr, policy.AllPodSelectorIPSets(), policy.PolicyKey, ipsets.SelectorType)
 	if err != nil {
 		klog.Infof("[DataPlane] error while adding Selector IPSet references: %s", err.Error())
 		return fmt.Errorf("[DataPlane] error while adding Selector IPSet references: %w", err)
 	}
 
 	// Create and add references for Rule IPSets
-	err = dp.createIPSetsAndReferences(policy.RuleIPSets, policy.PolicyKey, ipsets.NetPolType)
+	err = createIPSetsAndReferences(dp.ipsetMgr, policy.RuleIPSets, policy.PolicyKey, ipsets.NetPolType)
 	if err != nil {
 		klog.Infof("[DataPlane] error while adding Rule IPSet references: %s", err.Error())
 		return fmt.Errorf("[DataPlane] error while adding Rule IPSet references: %w", err)
@@ -285,6 +425,9 @@ func (dp *DataPlane) AddPolicy(policy *policies.NPMNetworkPolicy) error {
 // RemovePolicy takes in network policyKey (namespace/name of network policy) and removes it from dataplane and cache
 func (dp *DataPlane) RemovePolicy(policyKey string) error {
 	klog.Infof("[DataPlane] Remove Policy called for %s", policyKey)
+	if dp.bootup.release(policyKey) {
+		return nil
+	}
 	// because policy Manager will remove from policy from cache
 	// keep a local copy to remove references for ipsets
 	policy, ok := dp.policyMgr.GetPolicy(policyKey)
@@ -298,13 +441,13 @@ func (dp *DataPlane) RemovePolicy(policyKey string) error {
 		return fmt.Errorf("[DataPlane] error while removing policy: %w", err)
 	}
 	// Remove references for Rule IPSets first
-	err = dp.deleteIPSetsAndReferences(policy.RuleIPSets, policy.PolicyKey, ipsets.NetPolType)
+	err = deleteIPSetsAndReferences(dp.ipsetMgr, policy.RuleIPSets, policy.PolicyKey, ipsets.NetPolType)
 	if err != nil {
 		return err
 	}
 
 	// Remove references for Selector IPSets
-	err = dp.deleteIPSetsAndReferences(policy.AllPodSelectorIPSets(), policy.PolicyKey, ipsets.SelectorType)
+	err = deleteIPSetsAndReferences(dp.ipsetMgr, policy.AllPodSelectorIPSets(), policy.PolicyKey, ipsets.SelectorType)
 	if err != nil {
 		return err
 	}
@@ -321,6 +464,9 @@ func (dp *DataPlane) RemovePolicy(policyKey string) error {
 // onto dataplane accordingly
 func (dp *DataPlane) UpdatePolicy(policy *policies.NPMNetworkPolicy) error {
 	klog.Infof("[DataPlane] Update Policy called for %s", policy.PolicyKey)
+	if dp.bootup.hold(policy) {
+		return nil
+	}
 	ok := dp.policyMgr.PolicyExists(policy.PolicyKey)
 	if !ok {
 		klog.Infof("[DataPlane] Policy %s is not found.", policy.PolicyKey)
@@ -352,15 +498,15 @@ func (dp *DataPlane) GetAllPolicies() []string {
 	return nil
 }
 
-func (dp *DataPlane) createIPSetsAndReferences(sets []*ipsets.TranslatedIPSet, netpolName string, referenceType ipsets.ReferenceType) error {
+func createIPSetsAndReferences(ipsetMgr ipsets.Manager, sets []*ipsets.TranslatedIPSet, netpolName string, referenceType ipsets.ReferenceType) error {
 	// Create IPSets first along with reference updates
 	npmErrorString := npmerrors.AddSelectorReference
 	if referenceType == ipsets.NetPolType {
 		npmErrorString = npmerrors.AddNetPolReference
 	}
 	for _, set := range sets {
-		dp.ipsetMgr.CreateIPSets([]*ipsets.IPSetMetadata{set.Metadata})
-		err := dp.ipsetMgr.AddReference(set.Metadata, netpolName, referenceType)
+		ipsetMgr.CreateIPSets([]*ipsets.IPSetMetadata{set.Metadata})
+		err := ipsetMgr.AddReference(set.Metadata, netpolName, referenceType)
 		if err != nil {
 			return npmerrors.Errorf(npmErrorString, false, fmt.Sprintf("[DataPlane] failed to add reference with err: %s", err.Error()))
 		}
@@ -374,7 +520,7 @@ func (dp *DataPlane) createIPSetsAndReferences(sets []*ipsets.TranslatedIPSet, n
 			// ipblock can have either cidr (CIDR in IPBlock) or "cidr + " " (space) + nomatch" (Except in IPBlock)
 			// (TODO) need to revise it for windows
 			for _, ipblock := range set.Members {
-				err := dp.ipsetMgr.AddToSets([]*ipsets.IPSetMetadata{set.Metadata}, ipblock, "")
+				err := ipsetMgr.AddToSets([]*ipsets.IPSetMetadata{set.Metadata}, ipblock, "")
 				if err != nil {
 					return npmerrors.Errorf(npmErrorString, false, fmt.Sprintf("[DataPlane] failed to AddToSet in addIPSetReferences with err: %s", err.Error()))
 				}
@@ -382,7 +528,7 @@ func (dp *DataPlane) createIPSetsAndReferences(sets []*ipsets.TranslatedIPSet, n
 		} else if setType == ipsets.NestedLabelOfPod && len(set.Members) > 0 {
 			// Check if any 2nd level IPSets are generated by Controller with members
 			// Apply members to the list set
-			err := dp.ipsetMgr.AddToLists([]*ipsets.IPSetMetadata{set.Metadata}, ipsets.GetMembersOfTranslatedSets(set.Members))
+			err := ipsetMgr.AddToLists([]*ipsets.IPSetMetadata{set.Metadata}, ipsets.GetMembersOfTranslatedSets(set.Members))
 			if err != nil {
 				return npmerrors.Errorf(npmErrorString, false, fmt.Sprintf("[DataPlane] failed to AddToList in addIPSetReferences with err: %s", err.Error()))
 			}
@@ -392,14 +538,14 @@ func (dp *DataPlane) createIPSetsAndReferences(sets []*ipsets.TranslatedIPSet, n
 	return nil
 }
 
-func (dp *DataPlane) deleteIPSetsAndReferences(sets []*ipsets.TranslatedIPSet, netpolName string, referenceType ipsets.ReferenceType) error {
+func deleteIPSetsAndReferences(ipsetMgr ipsets.Manager, sets []*ipsets.TranslatedIPSet, netpolName string, referenceType ipsets.ReferenceType) error {
 	npmErrorString := npmerrors.DeleteSelectorReference
 	if referenceType == ipsets.NetPolType {
 		npmErrorString = npmerrors.DeleteNetPolReference
 	}
 	for _, set := range sets {
 		// TODO ignore set does not exist error
-		err := dp.ipsetMgr.DeleteReference(set.Metadata.GetPrefixName(), netpolName, referenceType)
+		err := ipsetMgr.DeleteReference(set.Metadata.GetPrefixName(), netpolName, referenceType)
 		if err != nil {
 			return npmerrors.Errorf(npmErrorString, false, fmt.Sprintf("[DataPlane] failed to deleteIPSetReferences with err: %s", err.Error()))
 		}
@@ -415,21 +561,21 @@ func (dp *DataPlane) deleteIPSetsAndReferences(sets []*ipsets.TranslatedIPSet, n
 			// ipblock can have either cidr (CIDR in IPBlock) or "cidr + " " (space) + nomatch" (Except in IPBlock)
 			// (TODO) need to revise it for windows
 			for _, ipblock := range set.Members {
-				err := dp.ipsetMgr.RemoveFromSets([]*ipsets.IPSetMetadata{set.Metadata}, ipblock, "")
+				err := ipsetMgr.RemoveFromSets([]*ipsets.IPSetMetadata{set.Metadata}, ipblock, "")
 				if err != nil {
 					return npmerrors.Errorf(npmErrorString, false, fmt.Sprintf("[DataPlane] failed to RemoveFromSet in deleteIPSetReferences with err: %s", err.Error()))
 				}
 			}
 		} else if set.Metadata.GetSetKind() == ipsets.ListSet && len(set.Members) > 0 {
 			// Delete if any 2nd level IPSets are generated by Controller with members
-			err := dp.ipsetMgr.RemoveFromList(set.Metadata, ipsets.GetMembersOfTranslatedSets(set.Members))
+			err := ipsetMgr.RemoveFromList(set.Metadata, ipsets.GetMembersOfTranslatedSets(set.Members))
 			if err != nil {
 				return npmerrors.Errorf(npmErrorString, false, fmt.Sprintf("[DataPlane] failed to RemoveFromList in deleteIPSetReferences with err: %s", err.Error()))
 			}
 		}
 
-		// Try to delete these IPSets
-		dp.ipsetMgr.DeleteIPSet(set.Metadata.GetPrefixName(), false)
+		// Try to delete these IPSets. Sets still in use by other policies or pods stay in the cache.
+		_ = ipsetMgr.DeleteIPSet(set.Metadata.GetPrefixName(), util.SoftDelete)
 	}
 	return nil
 }
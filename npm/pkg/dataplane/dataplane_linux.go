@@ -1,10 +1,29 @@
 package dataplane
 
 import (
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/backendmetrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ratelimiter"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
 )
 
+// instrumentedIOShim records the calls to ipset and iptables in the metrics.
+func instrumentedIOShim(ioShim *common.IOShim) *common.IOShim {
+	return &common.IOShim{
+		Exec:  backendmetrics.WrapExec(ioShim.Exec),
+		Netns: ioShim.Netns,
+	}
+}
+
+// rateLimitedIOShim returns a copy of the ioshim whose writes to ipset and iptables wait for the limiter.
+func rateLimitedIOShim(ioShim *common.IOShim, limiter *ratelimiter.Limiter) *common.IOShim {
+	return &common.IOShim{
+		Exec:  limiter.WrapExec(ioShim.Exec),
+		Netns: ioShim.Netns,
+	}
+}
+
 func (dp *DataPlane) getEndpointsToApplyPolicy(policy *policies.NPMNetworkPolicy) (map[string]string, error) {
 	// NOOP in Linux
 	return nil, nil
@@ -15,7 +34,7 @@ func (dp *DataPlane) shouldUpdatePod() bool {
 }
 
 func (dp *DataPlane) updatePod(pod *updateNPMPod) error {
-	// NOOP in Linux
+	// NOOP in Linux since the iptables rules match the pods through their ipsets
 	return nil
 }
 
@@ -0,0 +1,127 @@
+package dataplane
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataPlaneWithFakeManagers(t *testing.T) {
+	fakeIPSets := ipsets.NewFakeIPSetManager(dpCfg.IPSetManagerCfg)
+	fakePolicies := policies.NewFakePolicyManager()
+	// no exec calls are expected since the managers don't touch the OS
+	dp, err := NewDataPlaneWithManagers(nodeName, common.NewMockIOShim(nil), dpCfg, fakeIPSets, fakePolicies, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, fakePolicies.BootupCount())
+
+	nsSet := ipsets.NewIPSetMetadata("test", ipsets.Namespace)
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet}, NewPodMetadata("testns/a", "10.0.0.1", nodeName)))
+	require.NoError(t, dp.ApplyDataPlane())
+	require.Equal(t, []string{"10.0.0.1"}, fakeIPSets.AppliedSets()[nsSet.GetPrefixName()])
+
+	policy := testPolicyobj
+	require.NoError(t, dp.AddPolicy(&policy))
+	require.Equal(t, []string{policy.PolicyKey}, fakePolicies.PolicyKeys())
+	require.Contains(t, fakeIPSets.GetIPSet(setPodKey1.Metadata.GetPrefixName()).SelectorReference, policy.PolicyKey)
+
+	require.NoError(t, dp.RemovePolicy(policy.PolicyKey))
+	require.Empty(t, fakePolicies.PolicyKeys())
+}
+
+func TestRestoreIPSetsWithFakeManagers(t *testing.T) {
+	source := ipsets.NewFakeIPSetManager(dpCfg.IPSetManagerCfg)
+	nsSet := ipsets.NewIPSetMetadata("test", ipsets.Namespace)
+	require.NoError(t, source.AddToSets([]*ipsets.IPSetMetadata{nsSet}, "10.0.0.1", "testns/a"))
+
+	fakeIPSets := ipsets.NewFakeIPSetManager(dpCfg.IPSetManagerCfg)
+	dp, err := NewDataPlaneWithManagers(nodeName, common.NewMockIOShim(nil), dpCfg, fakeIPSets, policies.NewFakePolicyManager(), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, dp.RestoreIPSets(source.Snapshot()))
+	require.Equal(t, []string{"10.0.0.1"}, fakeIPSets.AppliedSets()[nsSet.GetPrefixName()], "restored sets are applied")
+
+	snapshot := dp.SnapshotIPSets()
+	require.Len(t, snapshot.Sets, 1)
+	require.Contains(t, snapshot.Sets[0].Expiries, "10.0.0.1", "restored members expire unless the controllers add them again")
+
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet}, NewPodMetadata("testns/a", "10.0.0.1", nodeName)))
+	require.Empty(t, dp.SnapshotIPSets().Sets[0].Expiries)
+}
+
+func TestAddPolicyInEnforcedDirectionWithFakeManagers(t *testing.T) {
+	cfg := &Config{
+		IPSetManagerCfg:  dpCfg.IPSetManagerCfg,
+		PolicyManagerCfg: &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode, EnforcedDirection: policies.Ingress},
+	}
+	fakeIPSets := ipsets.NewFakeIPSetManager(cfg.IPSetManagerCfg)
+	fakePolicies := policies.NewFakePolicyManager()
+	dp, err := NewDataPlaneWithManagers(nodeName, common.NewMockIOShim(nil), cfg, fakeIPSets, fakePolicies, nil)
+	require.NoError(t, err)
+
+	// testPolicyobj only has an egress ACL
+	policy := testPolicyobj
+	require.NoError(t, dp.AddPolicy(&policy))
+	require.Empty(t, fakePolicies.PolicyKeys())
+	require.Nil(t, fakeIPSets.GetIPSet(setPodKey1.Metadata.GetPrefixName()), "no IPSets are created for the egress rules")
+	require.NoError(t, dp.RemovePolicy(policy.PolicyKey))
+
+	policy.ACLs = append(policy.ACLs, policies.NewACLPolicy(policies.Dropped, policies.Ingress))
+	require.NoError(t, dp.AddPolicy(&policy))
+	require.Equal(t, []string{policy.PolicyKey}, fakePolicies.PolicyKeys())
+	added, ok := fakePolicies.GetPolicy(policy.PolicyKey)
+	require.True(t, ok)
+	require.Len(t, added.ACLs, 1)
+	require.Equal(t, policies.Ingress, added.ACLs[0].Direction)
+	require.Empty(t, added.RuleIPSets, "the ingress ACL references no rule IPSets")
+}
+
+func TestGuessDenyingPoliciesWithFakeManagers(t *testing.T) {
+	dp, err := NewDataPlaneWithManagers(nodeName, common.NewMockIOShim(nil), dpCfg, ipsets.NewFakeIPSetManager(dpCfg.IPSetManagerCfg), policies.NewFakePolicyManager(), nil)
+	require.NoError(t, err)
+
+	// testPolicyobj selects the pods in setpodkey1 and only has an egress ACL
+	policy := testPolicyobj
+	require.NoError(t, dp.AddPolicy(&policy))
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{setPodKey1.Metadata}, NewPodMetadata("testns/a", "10.0.0.1", nodeName)))
+
+	require.Equal(t, []string{policy.PolicyKey}, dp.GuessDenyingPolicies("10.0.0.1", false))
+	require.Empty(t, dp.GuessDenyingPolicies("10.0.0.1", true), "the policy has no ingress ACLs")
+	require.Empty(t, dp.GuessDenyingPolicies("10.0.0.2", false), "the policy doesn't select the pod")
+}
+
+func TestHoldPoliciesUntilBootupFinishes(t *testing.T) {
+	cfg := &Config{
+		IPSetManagerCfg:                 dpCfg.IPSetManagerCfg,
+		PolicyManagerCfg:                dpCfg.PolicyManagerCfg,
+		HoldPoliciesUntilBootupFinishes: true,
+	}
+	fakeIPSets := ipsets.NewFakeIPSetManager(cfg.IPSetManagerCfg)
+	fakePolicies := policies.NewFakePolicyManager()
+	dp, err := NewDataPlaneWithManagers(nodeName, common.NewMockIOShim(nil), cfg, fakeIPSets, fakePolicies, nil)
+	require.NoError(t, err)
+	require.True(t, dp.InBootupPhase())
+
+	policy := testPolicyobj
+	require.NoError(t, dp.AddPolicy(&policy))
+	removed := testPolicyobj
+	removed.PolicyKey = "x/removed"
+	require.NoError(t, dp.UpdatePolicy(&removed))
+	require.NoError(t, dp.RemovePolicy(removed.PolicyKey))
+	require.Empty(t, fakePolicies.PolicyKeys(), "policies are held during bootup")
+	require.Nil(t, fakeIPSets.GetIPSet(setPodKey1.Metadata.GetPrefixName()), "the sets of held policies aren't created")
+
+	nsSet := ipsets.NewIPSetMetadata("test", ipsets.Namespace)
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet}, NewPodMetadata("testns/a", "10.0.0.1", nodeName)))
+
+	require.NoError(t, dp.FinishBootup())
+	require.False(t, dp.InBootupPhase())
+	require.Equal(t, []string{"10.0.0.1"}, fakeIPSets.AppliedSets()[nsSet.GetPrefixName()])
+	require.Equal(t, []string{policy.PolicyKey}, fakePolicies.PolicyKeys(), "only the held policies which weren't removed are added")
+
+	require.NoError(t, dp.RemovePolicy(policy.PolicyKey))
+	require.Empty(t, fakePolicies.PolicyKeys(), "policies go to the dataplane right away after bootup")
+	require.NoError(t, dp.FinishBootup(), "finishing bootup again does nothing")
+}
@@ -3,10 +3,12 @@ package dataplane
 import (
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/backendmetrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ratelimiter"
 	"github.com/Azure/azure-container-networking/npm/util"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
 	"github.com/Microsoft/hcsshim/hcn"
@@ -26,6 +28,22 @@ var (
 	errMismanagedPodKey      = errors.New("the pod key was not managed correctly when refreshing pod endpoints")
 )
 
+// instrumentedIOShim records the calls to HNS in the metrics.
+func instrumentedIOShim(ioShim *common.IOShim) *common.IOShim {
+	return &common.IOShim{
+		Exec: backendmetrics.WrapExec(ioShim.Exec),
+		Hns:  backendmetrics.WrapHNS(ioShim.Hns),
+	}
+}
+
+// rateLimitedIOShim returns a copy of the ioshim whose writes to HNS wait for the limiter.
+func rateLimitedIOShim(ioShim *common.IOShim, limiter *ratelimiter.Limiter) *common.IOShim {
+	return &common.IOShim{
+		Exec: limiter.WrapExec(ioShim.Exec),
+		Hns:  limiter.WrapHNS(ioShim.Hns),
+	}
+}
+
 // initializeDataPlane will help gather network and endpoint details
 func (dp *DataPlane) initializeDataPlane() error {
 	klog.Infof("[DataPlane] Initializing dataplane for windows")
@@ -115,7 +133,9 @@ func (dp *DataPlane) shouldUpdatePod() bool {
 // 2. Will check for existing applicable network policies and applies it on endpoint
 func (dp *DataPlane) updatePod(pod *updateNPMPod) error {
 	klog.Infof("[DataPlane] updatePod called for Pod Key %s", pod.PodKey)
-	// Check if pod is part of this node
+	// Check if pod is part of this node.
+	// A pod on another node has no local endpoint to apply policies on. Its IP is still a member of the SetPolicies
+	// since AddToSets and RemoveFromSets manage the sets for every pod in the cluster, so policies on this node match it.
 	if pod.NodeName != dp.nodeName {
 		klog.Infof("[DataPlane] ignoring update pod as expected Node: [%s] got: [%s]", dp.nodeName, pod.NodeName)
 		return nil
@@ -340,74 +360,24 @@ func (dp *DataPlane) refreshPodEndpoints() error {
 		return err
 	}
 
-	// lock the endpoint cache while we reconcile with HNS goal state
-	dp.endpointCache.Lock()
-	defer dp.endpointCache.Unlock()
-
-	currentTime := time.Now().Unix()
-	existingIPs := make(map[string]struct{})
+	npmEndpoints := make([]*npmEndpoint, 0, len(endpoints))
 	for _, endpoint := range endpoints {
 		if len(endpoint.IpConfigurations) == 0 {
 			klog.Infof("Endpoint ID %s has no IPAddreses", endpoint.Id)
 			continue
 		}
-		ip := endpoint.IpConfigurations[0].IpAddress
-		if ip == "" {
+		if endpoint.IpConfigurations[0].IpAddress == "" {
 			klog.Infof("Endpoint ID %s has empty IPAddress field", endpoint.Id)
 			continue
 		}
-
-		existingIPs[ip] = struct{}{}
-
-		oldNPMEP, ok := dp.endpointCache.cache[ip]
-		if !ok {
-			// add the endpoint to the cache if it's not already there
-			npmEP := newNPMEndpoint(endpoint)
-			dp.endpointCache.cache[ip] = npmEP
-			// NOTE: TSGs rely on this log line
-			klog.Infof("updating endpoint cache to include %s: %+v", npmEP.ip, npmEP)
-		} else if oldNPMEP.id != endpoint.Id {
-			// multiple endpoints can have the same IP address, but there should be one endpoint ID per pod
-			// throw away old endpoints that have the same IP as a current endpoint (the old endpoint is getting deleted)
-			// we don't have to worry about cleaning up network policies on endpoints that are getting deleted
-			npmEP := newNPMEndpoint(endpoint)
-			if oldNPMEP.podKey == unspecifiedPodKey {
-				klog.Infof("updating endpoint cache since endpoint changed for IP which never had a pod key. new endpoint: %s, old endpoint: %s, ip: %s", npmEP.id, oldNPMEP.id, npmEP.ip)
-				dp.endpointCache.cache[ip] = npmEP
-			} else {
-				npmEP.stalePodKey = &staleKey{
-					key:       oldNPMEP.podKey,
-					timestamp: currentTime,
-				}
-				dp.endpointCache.cache[ip] = npmEP
-				// NOTE: TSGs rely on this log line
-				klog.Infof("updating endpoint cache for previously cached IP %s: %+v with stalePodKey %+v", npmEP.ip, npmEP, npmEP.stalePodKey)
-			}
-		}
+		npmEndpoints = append(npmEndpoints, newNPMEndpoint(endpoint))
 	}
 
-	// garbage collection for the endpoint cache
-	for ip, ep := range dp.endpointCache.cache {
-		if _, ok := existingIPs[ip]; !ok {
-			if ep.podKey == unspecifiedPodKey {
-				if ep.stalePodKey == nil {
-					klog.Infof("deleting old endpoint which never had a pod key. ID: %s, IP: %s", ep.id, ip)
-					delete(dp.endpointCache.cache, ip)
-				} else if int(currentTime-ep.stalePodKey.timestamp)/60 > minutesToKeepStalePodKey {
-					klog.Infof("deleting old endpoint which had a stale pod key. ID: %s, IP: %s, stalePodKey: %+v", ep.id, ip, ep.stalePodKey)
-					delete(dp.endpointCache.cache, ip)
-				}
-			} else {
-				ep.stalePodKey = &staleKey{
-					key:       ep.podKey,
-					timestamp: currentTime,
-				}
-				ep.podKey = unspecifiedPodKey
-				klog.Infof("marking endpoint stale for at least %d minutes. ID: %s, IP: %s, new stalePodKey: %+v", minutesToKeepStalePodKey, ep.id, ip, ep.stalePodKey)
-			}
-		}
-	}
+	// lock the endpoint cache while we reconcile with HNS goal state
+	dp.endpointCache.Lock()
+	defer dp.endpointCache.Unlock()
 
+	dp.endpointCache.reconcile(npmEndpoints, time.Now().Unix())
 	return nil
 }
 
@@ -423,5 +393,6 @@ func (dp *DataPlane) setNetworkIDByName(networkName string) error {
 }
 
 func isNetworkNotFoundErr(err error) bool {
-	return strings.Contains(err.Error(), fmt.Sprintf("Network name \"%s\" not found", util.AzureNetworkName))
+	var notFound hcn.NetworkNotFoundError
+	return errors.As(err, &notFound)
 }
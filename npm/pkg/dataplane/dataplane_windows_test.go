@@ -9,6 +9,8 @@ import (
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	dptestutils "github.com/Azure/azure-container-networking/npm/pkg/dataplane/testutils"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Microsoft/hcsshim/hcn"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
@@ -111,3 +113,74 @@ func TestAllMultiJobCases(t *testing.T) {
 		})
 	}
 }
+
+func TestGetPodEndpointsIgnoresRemoteEndpoints(t *testing.T) {
+	hns := ipsets.GetHNSFake(t)
+	for _, ep := range []*hcn.HostComputeEndpoint{
+		{Id: "local", HostComputeNetwork: common.FakeHNSNetworkID, IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.1"}}},
+		{Id: "remote", HostComputeNetwork: common.FakeHNSNetworkID, IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.1.1"}}, Flags: hcn.EndpointFlagsRemoteEndpoint},
+	} {
+		_, err := hns.CreateEndpoint(ep)
+		require.NoError(t, err)
+	}
+
+	dp := &DataPlane{ioShim: common.NewMockIOShimWithFakeHNS(hns), networkID: common.FakeHNSNetworkID}
+
+	endpoints, err := dp.getPodEndpoints(refreshLocalEndpoints)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	require.Equal(t, "local", endpoints[0].Id)
+
+	endpoints, err = dp.getPodEndpoints(refreshAllEndpoints)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 2)
+}
+
+func TestSetNetworkIDByName(t *testing.T) {
+	hns := ipsets.GetHNSFake(t)
+	dp := &DataPlane{ioShim: common.NewMockIOShimWithFakeHNS(hns)}
+
+	require.NoError(t, dp.setNetworkIDByName(util.AzureNetworkName))
+	require.Equal(t, common.FakeHNSNetworkID, dp.networkID)
+
+	// a missing network is retried until it is created
+	require.NoError(t, hns.DeleteNetwork(&hcn.HostComputeNetwork{Name: util.AzureNetworkName}))
+	err := dp.setNetworkIDByName(util.AzureNetworkName)
+	require.True(t, isNetworkNotFoundErr(err), "unexpected error %v", err)
+}
+
+func TestRemoveAllForPodKeyPrefix(t *testing.T) {
+	hns := ipsets.GetHNSFake(t)
+	io := common.NewMockIOShimWithFakeHNS(hns)
+	for _, ep := range []*hcn.HostComputeEndpoint{dptestutils.Endpoint("ep1", "10.0.0.1"), dptestutils.Endpoint("ep2", "10.0.0.2")} {
+		_, err := hns.CreateEndpoint(ep)
+		require.NoError(t, err)
+	}
+
+	dp, err := NewDataPlane(thisNode, io, defaultWindowsDPCfg, nil)
+	require.NoError(t, err)
+
+	nsX := ipsets.NewIPSetMetadata("x", ipsets.Namespace)
+	nsY := ipsets.NewIPSetMetadata("y", ipsets.Namespace)
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsX}, NewPodMetadata("x/a", "10.0.0.1", thisNode)))
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsY}, NewPodMetadata("y/b", "10.0.0.2", thisNode)))
+	require.NoError(t, dp.ApplyDataPlane())
+	require.Equal(t, "x/a", dp.endpointCache.cache["10.0.0.1"].podKey)
+
+	// a pending update of a pod in the namespace is dropped with the pod
+	podLabel := ipsets.NewIPSetMetadata("k", ipsets.KeyLabelOfPod)
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{podLabel}, NewPodMetadata("x/a", "10.0.0.1", thisNode)))
+	require.Contains(t, dp.updatePodCache.cache, "x/a")
+
+	require.Equal(t, 2, dp.RemoveAllForPodKeyPrefix("x/"))
+	require.NotContains(t, dp.updatePodCache.cache, "x/a")
+	ep := dp.endpointCache.cache["10.0.0.1"]
+	require.Equal(t, unspecifiedPodKey, ep.podKey)
+	require.True(t, ep.isStalePodKey("x/a"))
+	require.Equal(t, "y/b", dp.endpointCache.cache["10.0.0.2"].podKey)
+
+	// the pod which takes over the IP gets the endpoint
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsY}, NewPodMetadata("y/c", "10.0.0.1", thisNode)))
+	require.NoError(t, dp.ApplyDataPlane())
+	require.Equal(t, "y/c", dp.endpointCache.cache["10.0.0.1"].podKey)
+}
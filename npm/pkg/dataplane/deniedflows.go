@@ -0,0 +1,66 @@
+package dataplane
+
+import (
+	"sort"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+)
+
+// GuessDenyingPolicies returns the keys of the policies which select the pod with the IP and have rules in the
+// direction, sorted. Since the drop marks don't say which policy denied a packet, these are the policies which may
+// have denied the packets of the pod.
+func (dp *DataPlane) GuessDenyingPolicies(podIP string, ingress bool) []string {
+	snapshot := dp.ipsetMgr.Snapshot()
+	prefixNames := make([]string, len(snapshot.Sets))
+	for i := range snapshot.Sets {
+		prefixNames[i] = ipsets.NewIPSetMetadata(snapshot.Sets[i].Name, snapshot.Sets[i].Type).GetPrefixName()
+	}
+
+	// the sets with the pod, and the lists with those sets
+	setsWithPod := make(map[string]struct{})
+	for i := range snapshot.Sets {
+		if _, ok := snapshot.Sets[i].Members[podIP]; ok {
+			setsWithPod[prefixNames[i]] = struct{}{}
+		}
+	}
+	for i := range snapshot.Sets {
+		for j := range snapshot.Sets[i].MemberSets {
+			if _, ok := setsWithPod[snapshot.Sets[i].MemberSets[j].GetPrefixName()]; ok {
+				setsWithPod[prefixNames[i]] = struct{}{}
+				break
+			}
+		}
+	}
+
+	policyKeys := make(map[string]struct{})
+	for i := range snapshot.Sets {
+		if _, ok := setsWithPod[prefixNames[i]]; !ok {
+			continue
+		}
+		for _, policyKey := range snapshot.Sets[i].SelectorReferences {
+			policy, ok := dp.policyMgr.GetPolicy(policyKey)
+			if ok && hasACLsInDirection(policy, ingress) {
+				policyKeys[policyKey] = struct{}{}
+			}
+		}
+	}
+
+	guesses := make([]string, 0, len(policyKeys))
+	for policyKey := range policyKeys {
+		guesses = append(guesses, policyKey)
+	}
+	sort.Strings(guesses)
+	return guesses
+}
+
+func hasACLsInDirection(policy *policies.NPMNetworkPolicy, ingress bool) bool {
+	for _, acl := range policy.ACLs {
+		if acl.Direction == policies.Both ||
+			(ingress && acl.Direction == policies.Ingress) ||
+			(!ingress && acl.Direction == policies.Egress) {
+			return true
+		}
+	}
+	return false
+}
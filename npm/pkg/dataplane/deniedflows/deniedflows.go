@@ -0,0 +1,240 @@
+// Package deniedflows harvests the packets which network policies deny from the NFLOG rules of the dataplane and
+// turns them into structured events for the debug API and telemetry.
+package deniedflows
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"k8s.io/klog"
+)
+
+const (
+	// IngressPrefix and EgressPrefix are the NFLOG prefixes of the packets denied in each direction
+	IngressPrefix = "NPM-DENIED-INGRESS"
+	EgressPrefix  = "NPM-DENIED-EGRESS"
+
+	// CopyRange is how many bytes of each packet NFLOG copies, enough for the IPv4 header with options and the ports
+	CopyRange = 128
+
+	ingress = "ingress"
+	egress  = "egress"
+
+	ipv4Version      = 4
+	minIPv4HeaderLen = 20
+	protocolICMP     = 1
+	protocolTCP      = 6
+	protocolUDP      = 17
+	protocolSCTP     = 132
+)
+
+var (
+	// ErrSourceClosed is returned by a Source which was closed
+	ErrSourceClosed = errors.New("denied flow source closed")
+	// ErrNotSupported is returned when the OS has no source of denied flows
+	ErrNotSupported = errors.New("denied flow logging is not supported on this OS")
+
+	errUnknownPrefix = errors.New("unknown prefix")
+	errNotIPv4       = errors.New("not an IPv4 packet")
+	errTruncated     = errors.New("truncated packet")
+)
+
+// Sample is a packet logged by an NFLOG rule of the dataplane.
+type Sample struct {
+	Time   time.Time
+	Prefix string
+	// Payload is the start of the packet from its IP header
+	Payload []byte
+}
+
+// Source returns the samples of denied packets. Read blocks until there's a sample or the Source is closed.
+type Source interface {
+	Read() (*Sample, error)
+	Close() error
+}
+
+// Event is a packet denied by network policies.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Protocol  string    `json:"protocol"`
+	SrcIP     string    `json:"srcIP"`
+	DstIP     string    `json:"dstIP"`
+	SrcPort   uint16    `json:"srcPort,omitempty"`
+	DstPort   uint16    `json:"dstPort,omitempty"`
+	// Policies are the keys of the policies which likely denied the packet. NFLOG doesn't know which policy set the
+	// drop mark, so they're the policies which select the destination pod for ingress or the source pod for egress.
+	Policies []string `json:"policies"`
+}
+
+func (e *Event) String() string {
+	return fmt.Sprintf("denied %s %s flow %s:%d -> %s:%d, policies %v", e.Direction, e.Protocol, e.SrcIP, e.SrcPort, e.DstIP, e.DstPort, e.Policies)
+}
+
+// ParseSample returns the event of a sample, without the policies.
+func ParseSample(sample *Sample) (*Event, error) {
+	event := &Event{Time: sample.Time}
+	switch sample.Prefix {
+	case IngressPrefix:
+		event.Direction = ingress
+	case EgressPrefix:
+		event.Direction = egress
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnknownPrefix, sample.Prefix)
+	}
+
+	packet := sample.Payload
+	if len(packet) < minIPv4HeaderLen {
+		return nil, errTruncated
+	}
+	if packet[0]>>4 != ipv4Version {
+		return nil, errNotIPv4
+	}
+	headerLen := int(packet[0]&0x0f) * 4
+	if headerLen < minIPv4HeaderLen {
+		return nil, errTruncated
+	}
+	event.SrcIP = net.IP(packet[12:16]).String()
+	event.DstIP = net.IP(packet[16:20]).String()
+
+	protocol := packet[9]
+	switch protocol {
+	case protocolICMP:
+		event.Protocol = "ICMP"
+	case protocolTCP:
+		event.Protocol = "TCP"
+	case protocolUDP:
+		event.Protocol = "UDP"
+	case protocolSCTP:
+		event.Protocol = "SCTP"
+	default:
+		event.Protocol = strconv.Itoa(int(protocol))
+	}
+	if protocol == protocolTCP || protocol == protocolUDP || protocol == protocolSCTP {
+		if len(packet) < headerLen+4 {
+			return nil, errTruncated
+		}
+		event.SrcPort = binary.BigEndian.Uint16(packet[headerLen : headerLen+2])
+		event.DstPort = binary.BigEndian.Uint16(packet[headerLen+2 : headerLen+4])
+	}
+	return event, nil
+}
+
+// Log keeps the latest events.
+type Log struct {
+	sync.Mutex
+	events []Event
+	// next is where the next event goes once the log is full
+	next int
+}
+
+// NewLog returns a Log which keeps the latest capacity events.
+func NewLog(capacity int) *Log {
+	return &Log{events: make([]Event, 0, capacity)}
+}
+
+// Add adds the event, replacing the oldest one when the log is full.
+func (l *Log) Add(event *Event) {
+	l.Lock()
+	defer l.Unlock()
+	if cap(l.events) == 0 {
+		return
+	}
+	if len(l.events) < cap(l.events) {
+		l.events = append(l.events, *event)
+		return
+	}
+	l.events[l.next] = *event
+	l.next = (l.next + 1) % len(l.events)
+}
+
+// Events returns the events from the oldest.
+func (l *Log) Events() []Event {
+	l.Lock()
+	defer l.Unlock()
+	events := make([]Event, 0, len(l.events))
+	events = append(events, l.events[l.next:]...)
+	events = append(events, l.events[:l.next]...)
+	return events
+}
+
+func (l *Log) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Events()) //nolint:wrapcheck // the events are plain data
+}
+
+// PolicyGuesser returns the keys of the policies which select the pod with the IP in the direction.
+type PolicyGuesser func(podIP string, ingress bool) []string
+
+// Harvester turns the samples of a Source into events, which it counts in the metrics, adds to a Log and exports.
+type Harvester struct {
+	source Source
+	log    *Log
+	guess  PolicyGuesser
+	// export is called with each event when it's not nil, e.g. to send the event to telemetry
+	export func(*Event)
+}
+
+// NewHarvester returns a Harvester. The guess and export functions may be nil.
+func NewHarvester(source Source, log *Log, guess PolicyGuesser, export func(*Event)) *Harvester {
+	return &Harvester{
+		source: source,
+		log:    log,
+		guess:  guess,
+		export: export,
+	}
+}
+
+// Run harvests the samples until the stop channel is closed, which closes the Source, or the Source fails.
+func (h *Harvester) Run(stopChannel <-chan struct{}) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stopChannel:
+		case <-done:
+		}
+		if err := h.source.Close(); err != nil {
+			klog.Errorf("[DeniedFlows] failed to close source: %v", err)
+		}
+	}()
+
+	for {
+		sample, err := h.source.Read()
+		if err != nil {
+			if !errors.Is(err, ErrSourceClosed) {
+				klog.Errorf("[DeniedFlows] stopped harvesting denied flows: %v", err)
+			}
+			return
+		}
+		h.harvest(sample)
+	}
+}
+
+func (h *Harvester) harvest(sample *Sample) {
+	event, err := ParseSample(sample)
+	if err != nil {
+		klog.V(2).Infof("[DeniedFlows] skipping sample: %v", err)
+		return
+	}
+	event.Policies = []string{}
+	if h.guess != nil {
+		podIP := event.SrcIP
+		if event.Direction == ingress {
+			podIP = event.DstIP
+		}
+		event.Policies = h.guess(podIP, event.Direction == ingress)
+	}
+
+	metrics.IncDeniedFlows(event.Direction, event.Protocol)
+	h.log.Add(event)
+	if h.export != nil {
+		h.export(event)
+	}
+}
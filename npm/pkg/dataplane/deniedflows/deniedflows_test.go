@@ -0,0 +1,129 @@
+package deniedflows
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// ipv4Packet returns the start of an IPv4 packet with a 20 byte header and the ports of the transport header.
+func ipv4Packet(protocol byte, src, dst string, srcPort, dstPort uint16) []byte {
+	packet := make([]byte, minIPv4HeaderLen+4)
+	packet[0] = ipv4Version<<4 | minIPv4HeaderLen/4
+	packet[9] = protocol
+	copy(packet[12:16], net.ParseIP(src).To4())
+	copy(packet[16:20], net.ParseIP(dst).To4())
+	packet[20], packet[21] = byte(srcPort>>8), byte(srcPort)
+	packet[22], packet[23] = byte(dstPort>>8), byte(dstPort)
+	return packet
+}
+
+func TestParseSample(t *testing.T) {
+	now := time.Now()
+	event, err := ParseSample(&Sample{Time: now, Prefix: IngressPrefix, Payload: ipv4Packet(protocolTCP, "10.0.0.1", "10.0.0.2", 40000, 80)})
+	require.NoError(t, err)
+	require.Equal(t, &Event{
+		Time:      now,
+		Direction: "ingress",
+		Protocol:  "TCP",
+		SrcIP:     "10.0.0.1",
+		DstIP:     "10.0.0.2",
+		SrcPort:   40000,
+		DstPort:   80,
+	}, event)
+
+	event, err = ParseSample(&Sample{Prefix: EgressPrefix, Payload: ipv4Packet(protocolICMP, "10.0.0.2", "10.0.0.1", 0, 0)})
+	require.NoError(t, err)
+	require.Equal(t, "egress", event.Direction)
+	require.Equal(t, "ICMP", event.Protocol)
+	require.Zero(t, event.DstPort, "ICMP has no ports")
+
+	_, err = ParseSample(&Sample{Prefix: "OTHER", Payload: ipv4Packet(protocolTCP, "10.0.0.1", "10.0.0.2", 1, 2)})
+	require.ErrorIs(t, err, errUnknownPrefix)
+	_, err = ParseSample(&Sample{Prefix: IngressPrefix, Payload: ipv4Packet(protocolUDP, "10.0.0.1", "10.0.0.2", 1, 2)[:22]})
+	require.ErrorIs(t, err, errTruncated)
+	ipv6 := ipv4Packet(protocolTCP, "10.0.0.1", "10.0.0.2", 1, 2)
+	ipv6[0] = 6 << 4
+	_, err = ParseSample(&Sample{Prefix: IngressPrefix, Payload: ipv6})
+	require.ErrorIs(t, err, errNotIPv4)
+}
+
+func TestLog(t *testing.T) {
+	log := NewLog(2)
+	require.Empty(t, log.Events())
+	log.Add(&Event{SrcIP: "10.0.0.1"})
+	log.Add(&Event{SrcIP: "10.0.0.2"})
+	log.Add(&Event{SrcIP: "10.0.0.3"})
+	require.Equal(t, []Event{{SrcIP: "10.0.0.2"}, {SrcIP: "10.0.0.3"}}, log.Events(), "the oldest event is replaced")
+
+	b, err := json.Marshal(log)
+	require.NoError(t, err)
+	var events []Event
+	require.NoError(t, json.Unmarshal(b, &events))
+	require.Len(t, events, 2)
+
+	empty := NewLog(0)
+	empty.Add(&Event{})
+	require.Empty(t, empty.Events())
+}
+
+type fakeSource struct {
+	samples chan *Sample
+	closed  chan struct{}
+}
+
+func (s *fakeSource) Read() (*Sample, error) {
+	select {
+	case sample := <-s.samples:
+		return sample, nil
+	case <-s.closed:
+		return nil, ErrSourceClosed
+	}
+}
+
+func (s *fakeSource) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestHarvester(t *testing.T) {
+	metrics.InitializeAll()
+	source := &fakeSource{samples: make(chan *Sample, 3), closed: make(chan struct{})}
+	source.samples <- &Sample{Prefix: IngressPrefix, Payload: ipv4Packet(protocolTCP, "10.0.0.1", "10.0.0.2", 40000, 80)}
+	source.samples <- &Sample{Prefix: "OTHER", Payload: ipv4Packet(protocolTCP, "10.0.0.1", "10.0.0.2", 40000, 80)}
+	source.samples <- &Sample{Prefix: EgressPrefix, Payload: ipv4Packet(protocolUDP, "10.0.0.2", "10.0.0.3", 40000, 53)}
+
+	log := NewLog(10)
+	var guesses []string
+	guess := func(podIP string, ingress bool) []string {
+		if ingress {
+			guesses = append(guesses, "ingress "+podIP)
+			return []string{"ns/deny-ingress"}
+		}
+		guesses = append(guesses, "egress "+podIP)
+		return []string{}
+	}
+	exported := make(chan *Event, 2)
+	stopChannel := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		NewHarvester(source, log, guess, func(event *Event) { exported <- event }).Run(stopChannel)
+		close(done)
+	}()
+
+	first, second := <-exported, <-exported
+	close(stopChannel)
+	<-done
+
+	require.Equal(t, []string{"ns/deny-ingress"}, first.Policies)
+	require.Equal(t, "egress", second.Direction)
+	require.Equal(t, []string{"ingress 10.0.0.2", "egress 10.0.0.2"}, guesses, "the pod is the destination of ingress and the source of egress")
+	require.Len(t, log.Events(), 2, "the sample with an unknown prefix is skipped")
+	val, err := metrics.GetDeniedFlows("ingress", "TCP")
+	require.NoError(t, err)
+	require.Equal(t, 1, val)
+}
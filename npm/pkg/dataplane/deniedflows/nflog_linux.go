@@ -0,0 +1,209 @@
+package deniedflows
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// nfnetlink_log constants from linux/netfilter/nfnetlink_log.h
+const (
+	nfulnlMsgPacket = 0
+	nfulnlMsgConfig = 1
+
+	nfulaCfgCmd  = 1
+	nfulaCfgMode = 2
+
+	nfulnlCfgCmdBind = 1
+
+	nfulnlCopyPacket = 2
+
+	nfulaPayload = 9
+	nfulaPrefix  = 10
+
+	nlaTypeMask    = 0x3fff
+	nlaHeaderLen   = 4
+	nfgenmsgLen    = 4
+	recvBufferSize = 65536
+	// recvTimeout is how often a blocked Read checks whether the source was closed
+	recvTimeout = time.Second
+)
+
+var errNetlinkAck = errors.New("nfnetlink_log rejected the config")
+
+// nflogSource reads the packets of an NFLOG group from nfnetlink_log.
+type nflogSource struct {
+	fd     int
+	group  uint16
+	closed atomic.Bool
+	// readLock is held by Read so that Close doesn't close the socket while Read receives from it
+	readLock sync.Mutex
+	buf      []byte
+	pending  []*Sample
+}
+
+// NewNFLOGSource binds to the NFLOG group of the rules which log the denied packets. Needs CAP_NET_ADMIN.
+func NewNFLOGSource(group uint16) (Source, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netfilter netlink socket: %w", err)
+	}
+	s := &nflogSource{fd: fd, group: group, buf: make([]byte, recvBufferSize)}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind netfilter netlink socket: %w", err)
+	}
+	timeout := unix.NsecToTimeval(recvTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set receive timeout: %w", err)
+	}
+
+	if err := s.configure(nfulaCfgCmd, []byte{nfulnlCfgCmdBind}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind NFLOG group %d: %w", group, err)
+	}
+	// struct nfulnl_msg_config_mode: the copy range, the copy mode and a pad byte
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode, CopyRange)
+	mode[4] = nfulnlCopyPacket
+	if err := s.configure(nfulaCfgMode, mode); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set copy mode of NFLOG group %d: %w", group, err)
+	}
+	return s, nil
+}
+
+// configure sends a config message with one attribute for the group and waits for the ack.
+func (s *nflogSource) configure(attrType uint16, attrValue []byte) error {
+	attrLen := nlaHeaderLen + len(attrValue)
+	msgLen := unix.NLMSG_HDRLEN + nfgenmsgLen + nlaAlign(attrLen)
+	msg := make([]byte, msgLen)
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(msgLen))
+	binary.NativeEndian.PutUint16(msg[4:6], unix.NFNL_SUBSYS_ULOG<<8|nfulnlMsgConfig)
+	binary.NativeEndian.PutUint16(msg[6:8], unix.NLM_F_REQUEST|unix.NLM_F_ACK)
+	// nfgenmsg: the family, the version and the group in network byte order
+	msg[unix.NLMSG_HDRLEN] = unix.AF_UNSPEC
+	msg[unix.NLMSG_HDRLEN+1] = unix.NFNETLINK_V0
+	binary.BigEndian.PutUint16(msg[unix.NLMSG_HDRLEN+2:], s.group)
+	attr := msg[unix.NLMSG_HDRLEN+nfgenmsgLen:]
+	binary.NativeEndian.PutUint16(attr[0:2], uint16(attrLen))
+	binary.NativeEndian.PutUint16(attr[2:4], attrType)
+	copy(attr[nlaHeaderLen:], attrValue)
+
+	if err := unix.Sendto(s.fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("failed to send config: %w", err)
+	}
+	n, _, err := unix.Recvfrom(s.fd, s.buf, 0)
+	if err != nil {
+		return fmt.Errorf("failed to receive ack: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(s.buf[:n])
+	if err != nil {
+		return fmt.Errorf("failed to parse ack: %w", err)
+	}
+	for _, m := range msgs {
+		if m.Header.Type == unix.NLMSG_ERROR && len(m.Data) >= 4 {
+			if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+				return fmt.Errorf("%w: %w", errNetlinkAck, unix.Errno(-errno))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *nflogSource) Read() (*Sample, error) {
+	s.readLock.Lock()
+	defer s.readLock.Unlock()
+	for len(s.pending) == 0 {
+		if s.closed.Load() {
+			return nil, ErrSourceClosed
+		}
+		n, _, err := unix.Recvfrom(s.fd, s.buf, 0)
+		if err != nil {
+			switch {
+			case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+				continue
+			case errors.Is(err, unix.ENOBUFS):
+				// the kernel dropped samples which weren't read in time
+				continue
+			default:
+				return nil, fmt.Errorf("failed to receive NFLOG samples: %w", err)
+			}
+		}
+		s.pending = parseSamples(s.buf[:n], time.Now())
+	}
+	sample := s.pending[0]
+	s.pending = s.pending[1:]
+	return sample, nil
+}
+
+// Close stops Read within the receive timeout and closes the socket, which unbinds the group.
+func (s *nflogSource) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	s.readLock.Lock()
+	defer s.readLock.Unlock()
+	if err := unix.Close(s.fd); err != nil {
+		return fmt.Errorf("failed to close netfilter netlink socket: %w", err)
+	}
+	return nil
+}
+
+// parseSamples returns the packets of the nfnetlink_log messages which have a prefix and a payload.
+func parseSamples(b []byte, now time.Time) []*Sample {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return nil
+	}
+	samples := make([]*Sample, 0, len(msgs))
+	for _, m := range msgs {
+		if m.Header.Type != unix.NFNL_SUBSYS_ULOG<<8|nfulnlMsgPacket || len(m.Data) < nfgenmsgLen {
+			continue
+		}
+		sample := &Sample{Time: now}
+		attrs := m.Data[nfgenmsgLen:]
+		for len(attrs) >= nlaHeaderLen {
+			attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+			attrType := binary.NativeEndian.Uint16(attrs[2:4]) & nlaTypeMask
+			if attrLen < nlaHeaderLen || attrLen > len(attrs) {
+				break
+			}
+			value := attrs[nlaHeaderLen:attrLen]
+			switch attrType {
+			case nfulaPrefix:
+				sample.Prefix = nullTerminated(value)
+			case nfulaPayload:
+				sample.Payload = append([]byte(nil), value...)
+			}
+			if nlaAlign(attrLen) >= len(attrs) {
+				break
+			}
+			attrs = attrs[nlaAlign(attrLen):]
+		}
+		if sample.Prefix != "" && sample.Payload != nil {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+func nullTerminated(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+func nlaAlign(length int) int {
+	return (length + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+}
@@ -0,0 +1,42 @@
+package deniedflows
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// nflogMessage returns an nfnetlink_log packet message with the attributes.
+func nflogMessage(attrs map[uint16][]byte, order ...uint16) []byte {
+	body := make([]byte, nfgenmsgLen)
+	for _, attrType := range order {
+		value := attrs[attrType]
+		attr := make([]byte, nlaAlign(nlaHeaderLen+len(value)))
+		binary.NativeEndian.PutUint16(attr[0:2], uint16(nlaHeaderLen+len(value)))
+		binary.NativeEndian.PutUint16(attr[2:4], attrType)
+		copy(attr[nlaHeaderLen:], value)
+		body = append(body, attr...)
+	}
+	msg := make([]byte, unix.NLMSG_HDRLEN, unix.NLMSG_HDRLEN+len(body))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(unix.NLMSG_HDRLEN+len(body)))
+	binary.NativeEndian.PutUint16(msg[4:6], unix.NFNL_SUBSYS_ULOG<<8|nfulnlMsgPacket)
+	return append(msg, body...)
+}
+
+func TestParseSamples(t *testing.T) {
+	now := time.Now()
+	payload := ipv4Packet(protocolTCP, "10.0.0.1", "10.0.0.2", 40000, 80)
+	b := nflogMessage(map[uint16][]byte{
+		nfulaPrefix:  []byte(IngressPrefix + "\x00"),
+		nfulaPayload: payload,
+	}, nfulaPrefix, nfulaPayload)
+	// a message without a payload is skipped
+	b = append(b, nflogMessage(map[uint16][]byte{nfulaPrefix: []byte(EgressPrefix + "\x00")}, nfulaPrefix)...)
+
+	samples := parseSamples(b, now)
+	require.Equal(t, []*Sample{{Time: now, Prefix: IngressPrefix, Payload: payload}}, samples)
+	require.Empty(t, parseSamples([]byte{1, 2, 3}, now))
+}
@@ -0,0 +1,6 @@
+package deniedflows
+
+// NewNFLOGSource returns ErrNotSupported in Windows, where HNS doesn't expose the flows which its ACLs block.
+func NewNFLOGSource(_ uint16) (Source, error) {
+	return nil, ErrNotSupported
+}
@@ -125,10 +125,15 @@ func (dp *DPShim) createIPSet(set *ipsets.IPSetMetadata) {
 	dp.dirtyCache.modifyAddorUpdateSets(setName)
 }
 
-func (dp *DPShim) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, _ util.DeleteOption) {
+func (dp *DPShim) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, _ util.DeleteOption) error {
 	dp.lock()
 	defer dp.unlock()
+	setName := setMetadata.GetPrefixName()
+	if set, ok := dp.setCache[setName]; ok && set.HasReferences() {
+		return fmt.Errorf("%w: %s has references", ipsets.ErrSetInUse, setName)
+	}
 	dp.deleteIPSet(setMetadata)
+	return nil
 }
 
 func (dp *DPShim) deleteIPSet(setMetadata *ipsets.IPSetMetadata) {
@@ -0,0 +1,124 @@
+package dataplane
+
+import (
+	"strings"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+const (
+	unspecifiedPodKey        = ""
+	minutesToKeepStalePodKey = 10
+)
+
+type endpointCache struct {
+	sync.Mutex
+	cache map[string]*npmEndpoint
+}
+
+func newEndpointCache() *endpointCache {
+	return &endpointCache{cache: make(map[string]*npmEndpoint)}
+}
+
+// npmEndpoint holds info relevant for endpoints in windows.
+// It has no hcn types so that the endpoint cache logic builds and is tested on every OS.
+type npmEndpoint struct {
+	name   string
+	id     string
+	ip     string
+	podKey string
+	// stalePodKey is used to keep track of the previous pod that had this IP
+	stalePodKey *staleKey
+	// Map with Key as Network Policy name to to emulate set
+	// and value as struct{} for minimal memory consumption
+	netPolReference map[string]struct{}
+}
+
+type staleKey struct {
+	key string
+	// timestamp represents the Unix time this struct was created
+	timestamp int64
+}
+
+func (ep *npmEndpoint) isStalePodKey(podKey string) bool {
+	return ep.stalePodKey != nil && ep.stalePodKey.key == podKey
+}
+
+// reconcile updates the cache to the endpoints which HNS reports, which all have an IP.
+// New endpoints are added with an unspecified pod key, an endpoint replacing another one with the same IP keeps the
+// pod key of the old endpoint as stale, and endpoints which are gone are marked stale before they are deleted.
+// The caller must hold the lock of the cache.
+func (c *endpointCache) reconcile(endpoints []*npmEndpoint, currentTime int64) {
+	existingIPs := make(map[string]struct{})
+	for _, npmEP := range endpoints {
+		ip := npmEP.ip
+		existingIPs[ip] = struct{}{}
+
+		oldNPMEP, ok := c.cache[ip]
+		if !ok {
+			// add the endpoint to the cache if it's not already there
+			c.cache[ip] = npmEP
+			// NOTE: TSGs rely on this log line
+			klog.Infof("updating endpoint cache to include %s: %+v", npmEP.ip, npmEP)
+		} else if oldNPMEP.id != npmEP.id {
+			// multiple endpoints can have the same IP address, but there should be one endpoint ID per pod
+			// throw away old endpoints that have the same IP as a current endpoint (the old endpoint is getting deleted)
+			// we don't have to worry about cleaning up network policies on endpoints that are getting deleted
+			if oldNPMEP.podKey == unspecifiedPodKey {
+				klog.Infof("updating endpoint cache since endpoint changed for IP which never had a pod key. new endpoint: %s, old endpoint: %s, ip: %s", npmEP.id, oldNPMEP.id, npmEP.ip)
+				c.cache[ip] = npmEP
+			} else {
+				npmEP.stalePodKey = &staleKey{
+					key:       oldNPMEP.podKey,
+					timestamp: currentTime,
+				}
+				c.cache[ip] = npmEP
+				// NOTE: TSGs rely on this log line
+				klog.Infof("updating endpoint cache for previously cached IP %s: %+v with stalePodKey %+v", npmEP.ip, npmEP, npmEP.stalePodKey)
+			}
+		}
+	}
+
+	// garbage collection for the endpoint cache
+	for ip, ep := range c.cache {
+		if _, ok := existingIPs[ip]; !ok {
+			if ep.podKey == unspecifiedPodKey {
+				if ep.stalePodKey == nil {
+					klog.Infof("deleting old endpoint which never had a pod key. ID: %s, IP: %s", ep.id, ip)
+					delete(c.cache, ip)
+				} else if int(currentTime-ep.stalePodKey.timestamp)/60 > minutesToKeepStalePodKey {
+					klog.Infof("deleting old endpoint which had a stale pod key. ID: %s, IP: %s, stalePodKey: %+v", ep.id, ip, ep.stalePodKey)
+					delete(c.cache, ip)
+				}
+			} else {
+				ep.stalePodKey = &staleKey{
+					key:       ep.podKey,
+					timestamp: currentTime,
+				}
+				ep.podKey = unspecifiedPodKey
+				klog.Infof("marking endpoint stale for at least %d minutes. ID: %s, IP: %s, new stalePodKey: %+v", minutesToKeepStalePodKey, ep.id, ip, ep.stalePodKey)
+			}
+		}
+	}
+}
+
+// removePodKeyPrefix marks the endpoints of the pods whose key starts with the prefix stale, like the endpoints which are
+// gone, so that the cache no longer refers to the pods, and returns how many endpoints it marked.
+// The caller must hold the lock of the cache.
+func (c *endpointCache) removePodKeyPrefix(podKeyPrefix string, currentTime int64) int {
+	numMarked := 0
+	for ip, ep := range c.cache {
+		if ep.podKey == unspecifiedPodKey || !strings.HasPrefix(ep.podKey, podKeyPrefix) {
+			continue
+		}
+		ep.stalePodKey = &staleKey{
+			key:       ep.podKey,
+			timestamp: currentTime,
+		}
+		ep.podKey = unspecifiedPodKey
+		klog.Infof("marking endpoint stale since its pod was removed. ID: %s, IP: %s, new stalePodKey: %+v", ep.id, ip, ep.stalePodKey)
+		numMarked++
+	}
+	return numMarked
+}
@@ -0,0 +1,76 @@
+package dataplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testNPMEndpoint(id, ip string) *npmEndpoint {
+	return &npmEndpoint{
+		name:            id,
+		id:              id,
+		ip:              ip,
+		podKey:          unspecifiedPodKey,
+		netPolReference: make(map[string]struct{}),
+	}
+}
+
+func TestEndpointCacheReconcile(t *testing.T) {
+	c := newEndpointCache()
+	now := int64(1000)
+
+	c.reconcile([]*npmEndpoint{testNPMEndpoint("ep1", "10.0.0.1"), testNPMEndpoint("ep2", "10.0.0.2")}, now)
+	require.Len(t, c.cache, 2)
+	c.cache["10.0.0.1"].podKey = "x/a"
+
+	// the same endpoint keeps its pod key
+	c.reconcile([]*npmEndpoint{testNPMEndpoint("ep1", "10.0.0.1"), testNPMEndpoint("ep2", "10.0.0.2")}, now)
+	require.Equal(t, "x/a", c.cache["10.0.0.1"].podKey)
+
+	// a new endpoint for the IP keeps the pod key of the old one as stale
+	c.reconcile([]*npmEndpoint{testNPMEndpoint("ep3", "10.0.0.1"), testNPMEndpoint("ep2", "10.0.0.2")}, now)
+	ep := c.cache["10.0.0.1"]
+	require.Equal(t, "ep3", ep.id)
+	require.Equal(t, unspecifiedPodKey, ep.podKey)
+	require.True(t, ep.isStalePodKey("x/a"))
+
+	// a new endpoint for an IP which never had a pod key replaces the old one
+	c.reconcile([]*npmEndpoint{testNPMEndpoint("ep3", "10.0.0.1"), testNPMEndpoint("ep4", "10.0.0.2")}, now)
+	require.Equal(t, "ep4", c.cache["10.0.0.2"].id)
+	require.Nil(t, c.cache["10.0.0.2"].stalePodKey)
+}
+
+func TestEndpointCacheReconcileDeletedEndpoints(t *testing.T) {
+	c := newEndpointCache()
+	now := int64(1000)
+
+	c.reconcile([]*npmEndpoint{testNPMEndpoint("ep1", "10.0.0.1"), testNPMEndpoint("ep2", "10.0.0.2")}, now)
+	c.cache["10.0.0.1"].podKey = "x/a"
+
+	// a gone endpoint without a pod key is deleted, one with a pod key is marked stale
+	c.reconcile(nil, now)
+	require.Len(t, c.cache, 1)
+	ep := c.cache["10.0.0.1"]
+	require.Equal(t, unspecifiedPodKey, ep.podKey)
+	require.True(t, ep.isStalePodKey("x/a"))
+
+	// the stale endpoint is kept for minutesToKeepStalePodKey
+	c.reconcile(nil, now+minutesToKeepStalePodKey*60)
+	require.Len(t, c.cache, 1)
+	c.reconcile(nil, now+(minutesToKeepStalePodKey+1)*60)
+	require.Empty(t, c.cache)
+}
+
+func TestEndpointCacheRemovePodKeyPrefix(t *testing.T) {
+	c := newEndpointCache()
+	c.reconcile([]*npmEndpoint{testNPMEndpoint("ep1", "10.0.0.1"), testNPMEndpoint("ep2", "10.0.0.2"), testNPMEndpoint("ep3", "10.0.0.3")}, 1000)
+	c.cache["10.0.0.1"].podKey = "x/a"
+	c.cache["10.0.0.2"].podKey = "xy/b"
+
+	require.Equal(t, 1, c.removePodKeyPrefix("x/", 1000))
+	require.Equal(t, unspecifiedPodKey, c.cache["10.0.0.1"].podKey)
+	require.True(t, c.cache["10.0.0.1"].isStalePodKey("x/a"))
+	require.Equal(t, "xy/b", c.cache["10.0.0.2"].podKey)
+	require.Nil(t, c.cache["10.0.0.3"].stalePodKey)
+}
@@ -0,0 +1,166 @@
+package dataplane
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/klog"
+)
+
+// InMemoryDataplane is a GenericDataplane which keeps the ipsets and policies in memory without programming the OS.
+// It is for the unit tests of the controllers and for simulating NPM. Like in Linux, policies don't track endpoints.
+type InMemoryDataplane struct {
+	ipsetMgr  *ipsets.FakeIPSetManager
+	policyMgr *policies.FakePolicyManager
+}
+
+var _ GenericDataplane = (*InMemoryDataplane)(nil)
+
+func NewInMemoryDataplane(cfg *Config) *InMemoryDataplane {
+	return &InMemoryDataplane{
+		ipsetMgr:  ipsets.NewFakeIPSetManager(cfg.IPSetManagerCfg),
+		policyMgr: policies.NewFakePolicyManager(),
+	}
+}
+
+// IPSets returns the ipset manager to check the ipsets applied by ApplyDataPlane.
+func (dp *InMemoryDataplane) IPSets() *ipsets.FakeIPSetManager {
+	return dp.ipsetMgr
+}
+
+// Policies returns the policy manager to check the policies added.
+func (dp *InMemoryDataplane) Policies() *policies.FakePolicyManager {
+	return dp.policyMgr
+}
+
+func (dp *InMemoryDataplane) BootupDataplane() error {
+	if err := dp.policyMgr.Bootup(nil); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] failed to reset policies: %w", err)
+	}
+	if err := dp.ipsetMgr.ResetIPSets(); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] failed to reset ipsets: %w", err)
+	}
+	return nil
+}
+
+// RunPeriodicTasks does nothing. Reconcile the ipsets and policies from the test instead.
+func (dp *InMemoryDataplane) RunPeriodicTasks() {}
+
+func (dp *InMemoryDataplane) GetAllIPSets() map[string]string {
+	return dp.ipsetMgr.GetAllIPSets()
+}
+
+func (dp *InMemoryDataplane) GetIPSet(setName string) *ipsets.IPSet {
+	return dp.ipsetMgr.GetIPSet(setName)
+}
+
+func (dp *InMemoryDataplane) CreateIPSets(setMetadatas []*ipsets.IPSetMetadata) {
+	dp.ipsetMgr.CreateIPSets(setMetadatas)
+}
+
+func (dp *InMemoryDataplane) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, deleteOption util.DeleteOption) error {
+	if err := dp.ipsetMgr.DeleteIPSet(setMetadata.GetPrefixName(), deleteOption); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while deleting set: %w", err)
+	}
+	return nil
+}
+
+func (dp *InMemoryDataplane) AddToSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *PodMetadata) error {
+	if err := dp.ipsetMgr.AddToSets(setMetadatas, podMetadata.PodIP, podMetadata.PodKey); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while adding to set: %w", err)
+	}
+	return nil
+}
+
+func (dp *InMemoryDataplane) RemoveFromSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *PodMetadata) error {
+	if err := dp.ipsetMgr.RemoveFromSets(setMetadatas, podMetadata.PodIP, podMetadata.PodKey); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while removing from set: %w", err)
+	}
+	return nil
+}
+
+func (dp *InMemoryDataplane) RemoveAllForPodKeyPrefix(podKeyPrefix string) int {
+	return dp.ipsetMgr.RemoveAllForPodKeyPrefix(podKeyPrefix)
+}
+
+func (dp *InMemoryDataplane) UpdatePodLabels(podMetadata *PodMetadata, delta *PodLabelDelta) error {
+	if err := dp.ipsetMgr.RemoveFromSets(delta.SetsToRemove, podMetadata.PodIP, podMetadata.PodKey); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while updating pod labels: %w", err)
+	}
+	if err := dp.ipsetMgr.AddToSets(delta.SetsToAdd, podMetadata.PodIP, podMetadata.PodKey); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while updating pod labels: %w", err)
+	}
+	return nil
+}
+
+func (dp *InMemoryDataplane) AddToLists(listMetadatas, setMetadatas []*ipsets.IPSetMetadata) error {
+	if err := dp.ipsetMgr.AddToLists(listMetadatas, setMetadatas); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while adding to list: %w", err)
+	}
+	return nil
+}
+
+func (dp *InMemoryDataplane) RemoveFromList(listMetadata *ipsets.IPSetMetadata, setMetadatas []*ipsets.IPSetMetadata) error {
+	if err := dp.ipsetMgr.RemoveFromList(listMetadata, setMetadatas); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while removing from list: %w", err)
+	}
+	return nil
+}
+
+func (dp *InMemoryDataplane) ApplyDataPlane() error {
+	if err := dp.ipsetMgr.ApplyIPSets(); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while applying IPSets: %w", err)
+	}
+	return nil
+}
+
+// GetAllPolicies returns the keys of the policies added.
+func (dp *InMemoryDataplane) GetAllPolicies() []string {
+	return dp.policyMgr.PolicyKeys()
+}
+
+func (dp *InMemoryDataplane) AddPolicy(policy *policies.NPMNetworkPolicy) error {
+	klog.Infof("[InMemoryDataplane] Add Policy called for %s", policy.PolicyKey)
+	if err := createIPSetsAndReferences(dp.ipsetMgr, policy.AllPodSelectorIPSets(), policy.PolicyKey, ipsets.SelectorType); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while adding Selector IPSet references: %w", err)
+	}
+	if err := createIPSetsAndReferences(dp.ipsetMgr, policy.RuleIPSets, policy.PolicyKey, ipsets.NetPolType); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while adding Rule IPSet references: %w", err)
+	}
+	if err := dp.ApplyDataPlane(); err != nil {
+		return err
+	}
+	if err := dp.policyMgr.AddPolicy(policy, nil); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while adding policy: %w", err)
+	}
+	return nil
+}
+
+func (dp *InMemoryDataplane) RemovePolicy(policyKey string) error {
+	klog.Infof("[InMemoryDataplane] Remove Policy called for %s", policyKey)
+	policy, ok := dp.policyMgr.GetPolicy(policyKey)
+	if !ok {
+		return nil
+	}
+	if err := dp.policyMgr.RemovePolicy(policyKey); err != nil {
+		return fmt.Errorf("[InMemoryDataplane] error while removing policy: %w", err)
+	}
+	if err := deleteIPSetsAndReferences(dp.ipsetMgr, policy.RuleIPSets, policy.PolicyKey, ipsets.NetPolType); err != nil {
+		return err
+	}
+	if err := deleteIPSetsAndReferences(dp.ipsetMgr, policy.AllPodSelectorIPSets(), policy.PolicyKey, ipsets.SelectorType); err != nil {
+		return err
+	}
+	return dp.ApplyDataPlane()
+}
+
+func (dp *InMemoryDataplane) UpdatePolicy(policy *policies.NPMNetworkPolicy) error {
+	if dp.policyMgr.PolicyExists(policy.PolicyKey) {
+		if err := dp.RemovePolicy(policy.PolicyKey); err != nil {
+			return fmt.Errorf("[InMemoryDataplane] error while updating policy: %w", err)
+		}
+	}
+	return dp.AddPolicy(policy)
+}
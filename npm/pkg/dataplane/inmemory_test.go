@@ -0,0 +1,42 @@
+package dataplane
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryDataplane(t *testing.T) {
+	dp := NewInMemoryDataplane(dpCfg)
+	require.NoError(t, dp.BootupDataplane())
+
+	nsSet := ipsets.NewIPSetMetadata("test", ipsets.Namespace)
+	podMetadata := NewPodMetadata("testns/a", "10.0.0.1", nodeName)
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet}, podMetadata))
+	require.Error(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet}, NewPodMetadata("testns/b", "2001:db8::1", nodeName)))
+	require.NoError(t, dp.AddToLists([]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("all-namespaces", ipsets.KeyLabelOfNamespace)}, []*ipsets.IPSetMetadata{nsSet}))
+	require.NoError(t, dp.ApplyDataPlane())
+	require.Equal(t, []string{"10.0.0.1"}, dp.IPSets().AppliedSets()[nsSet.GetPrefixName()])
+
+	policy := testPolicyobj
+	require.NoError(t, dp.UpdatePolicy(&policy))
+	require.Equal(t, []string{policy.PolicyKey}, dp.GetAllPolicies())
+	require.Contains(t, dp.GetIPSet(setPodKey1.Metadata.GetPrefixName()).SelectorReference, policy.PolicyKey)
+	require.Equal(t, []string{"10.0.0.0/8"}, dp.IPSets().AppliedSets()[policy.RuleIPSets[3].Metadata.GetPrefixName()], "policy sets are applied")
+
+	// the policy is replaced
+	require.NoError(t, dp.UpdatePolicy(&policy))
+	require.Equal(t, []string{policy.PolicyKey}, dp.GetAllPolicies())
+
+	require.NoError(t, dp.RemovePolicy(policy.PolicyKey))
+	require.Empty(t, dp.GetAllPolicies())
+	require.Nil(t, dp.GetIPSet(policy.PodSelectorIPSets[0].Metadata.GetPrefixName()), "unreferenced policy sets are deleted")
+	require.NoError(t, dp.RemovePolicy(policy.PolicyKey), "removing a missing policy is a no-op")
+
+	require.NoError(t, dp.RemoveFromSets([]*ipsets.IPSetMetadata{nsSet}, podMetadata))
+	require.NoError(t, dp.RemoveFromList(ipsets.NewIPSetMetadata("all-namespaces", ipsets.KeyLabelOfNamespace), []*ipsets.IPSetMetadata{nsSet}))
+	require.NoError(t, dp.DeleteIPSet(nsSet, util.SoftDelete))
+	require.Nil(t, dp.GetIPSet(nsSet.GetPrefixName()))
+}
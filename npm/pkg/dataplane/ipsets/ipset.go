@@ -3,10 +3,12 @@ package ipsets
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/npm/util/intern"
 )
 
 type IPSetMetadata struct {
@@ -184,6 +186,8 @@ var (
 	}
 	// ErrIPSetInvalidKind is returned when IPSet kind is invalid
 	ErrIPSetInvalidKind = errors.New("invalid IPSet Kind")
+	// ErrSetInUse is returned when an IPSet can't be deleted because of its members or references
+	ErrSetInUse = errors.New("ipset is in use")
 )
 
 func (x SetType) String() string {
@@ -210,6 +214,8 @@ type IPSet struct {
 	// IpPodKey is used for setMaps to store Ips and ports as keys
 	// and podKey as value
 	IPPodKey map[string]string
+	// memberExpiry holds the expiry time of the members of a hash set which were added with a TTL
+	memberExpiry map[string]time.Time
 	// This is used for listMaps to store child IP Sets
 	MemberIPSets map[string]*IPSet
 	// Using a map to emulate set and value as struct{} for
@@ -253,6 +259,17 @@ func NewIPSet(setMetadata *IPSetMetadata) *IPSet {
 	return set
 }
 
+// internStrings interns the names and members of the set in the pool, so that they stay in the pool after a Rotate.
+func (set *IPSet) internStrings(pool *intern.Pool) {
+	set.Name = pool.String(set.Name)
+	set.unprefixedName = pool.String(set.unprefixedName)
+	set.HashedName = pool.String(set.HashedName)
+	for member, podKey := range set.IPPodKey {
+		// assigning to an existing key replaces the key with the pooled copy as well
+		set.IPPodKey[pool.String(member)] = pool.String(podKey)
+	}
+}
+
 // GetSetMetadata returns set metadata with unprefixed original name and SetType
 func (set *IPSet) GetSetMetadata() *IPSetMetadata {
 	return NewIPSetMetadata(set.unprefixedName, set.Type)
@@ -345,6 +362,39 @@ func (set *IPSet) deleteReference(referenceName string, referenceType ReferenceT
 	}
 }
 
+// IPSetUsage is what keeps an IPSet from being deleted.
+// Members don't keep an IPSet from being force deleted.
+type IPSetUsage struct {
+	// Members is the number of IPs in a hash set or member sets in a list
+	Members            int
+	SelectorReferences int
+	NetPolReferences   int
+	// ListReferences is the number of lists which have the IPSet as a member
+	ListReferences int
+}
+
+func (usage *IPSetUsage) String() string {
+	return fmt.Sprintf("%d members, %d selector references, %d netpol references, %d list references",
+		usage.Members, usage.SelectorReferences, usage.NetPolReferences, usage.ListReferences)
+}
+
+// usage doesn't count the ignorableMember of a list, like canBeDeleted
+func (set *IPSet) usage(ignorableMember *IPSet) *IPSetUsage {
+	members := len(set.IPPodKey)
+	if set.Kind == ListSet {
+		members = len(set.MemberIPSets)
+		if ignorableMember != nil && set.hasMember(ignorableMember.Name) {
+			members--
+		}
+	}
+	return &IPSetUsage{
+		Members:            members,
+		SelectorReferences: len(set.SelectorReference),
+		NetPolReferences:   len(set.NetPolReference),
+		ListReferences:     set.ipsetReferCount,
+	}
+}
+
 func (set *IPSet) shouldBeInKernel() bool {
 	return set.usedByNetPol() || set.referencedInKernel()
 }
@@ -382,6 +432,26 @@ func (set *IPSet) referencedInKernel() bool {
 	return set.kernelReferCount > 0
 }
 
+// expiryOf returns the expiry time of the member if it was added with a TTL
+func (set *IPSet) expiryOf(member string) (time.Time, bool) {
+	expiry, ok := set.memberExpiry[member]
+	return expiry, ok
+}
+
+func (set *IPSet) setExpiry(member string, expiry time.Time) {
+	if set.memberExpiry == nil {
+		set.memberExpiry = make(map[string]time.Time)
+	}
+	set.memberExpiry[member] = expiry
+}
+
+// clearExpiry makes the member permanent and returns whether it had a TTL
+func (set *IPSet) clearExpiry(member string) bool {
+	_, ok := set.memberExpiry[member]
+	delete(set.memberExpiry, member)
+	return ok
+}
+
 // panics if set is not a list set
 func (set *IPSet) hasMember(memberName string) bool {
 	_, isMember := set.MemberIPSets[memberName]
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/util"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+	"github.com/Azure/azure-container-networking/npm/util/intern"
 	"k8s.io/klog"
 )
 
@@ -50,7 +52,11 @@ type IPSetManager struct {
 	emptySet   *IPSet
 	setMap     map[string]*IPSet
 	dirtyCache dirtyCacheInterface
-	ioShim     *common.IOShim
+	// ttlWheel schedules the expiry of the members added with a TTL
+	ttlWheel *timerWheel
+	// pool shares one copy of the IPs, pod keys and names of the sets. Reconcile rotates it.
+	pool   *intern.Pool
+	ioShim *common.IOShim
 	sync.RWMutex
 }
 
@@ -62,14 +68,58 @@ type IPSetManagerCfg struct {
 	// This is necessary for HNS (Windows); otherwise, an allow ACL with a list condition
 	// allows all IPs if the list has no members.
 	AddEmptySetToLists bool
+	// UseKernelTimeouts only affects Linux. If true, hash sets are created with timeout support so that the kernel
+	// also expires the members added with a TTL, even if NPM doesn't apply the expiry in time.
+	UseKernelTimeouts bool
 }
 
+// Manager is the cache of ipsets which the dataplane applies to the kernel in Linux (ipset) or to HNS
+// SetPolicies in Windows. IPSetManager is the OS backed Manager, FakeIPSetManager the in-memory one for tests.
+type Manager interface {
+	Reconcile()
+	ResetIPSets() error
+	CreateIPSets(setMetadatas []*IPSetMetadata)
+	// DeleteIPSet, GetIPSet, and GetIPSetUsage expect the prefixed ipset name
+	DeleteIPSet(name string, deleteOption util.DeleteOption) error
+	GetIPSet(name string) *IPSet
+	GetIPSetUsage(name string) *IPSetUsage
+	AddReference(setMetadata *IPSetMetadata, referenceName string, referenceType ReferenceType) error
+	DeleteReference(setName, referenceName string, referenceType ReferenceType) error
+	AddToSets(addToSets []*IPSetMetadata, ip, podKey string) error
+	// AddToSetWithTTL adds the ip to the hash sets until the TTL passes, unless the ip is a permanent member.
+	// Adding the ip again refreshes the TTL, and AddToSets makes it permanent.
+	AddToSetWithTTL(addToSets []*IPSetMetadata, ip, podKey string, ttl time.Duration) error
+	// ExpireMembers removes the members whose TTL has passed from the cache and returns how many it removed.
+	// The removals reach the dataplane on the next ApplyIPSets.
+	ExpireMembers(now time.Time) int
+	RemoveFromSets(removeFromSets []*IPSetMetadata, ip, podKey string) error
+	// RemoveAllForPodKeyPrefix removes the members whose pod key starts with the prefix from every hash set, e.g. all
+	// the pods of a deleted namespace with the prefix "<namespace>/", and returns how many it removed. The removals
+	// reach the dataplane on the next ApplyIPSets, in one transaction.
+	RemoveAllForPodKeyPrefix(podKeyPrefix string) int
+	AddToLists(listMetadatas, setMetadatas []*IPSetMetadata) error
+	RemoveFromList(listMetadata *IPSetMetadata, setMetadatas []*IPSetMetadata) error
+	ApplyIPSets() error
+	GetAllIPSets() map[string]string
+	// Snapshot returns the sets with their members, pod keys, lists and references.
+	Snapshot() *Snapshot
+	// Restore seeds an empty cache with a snapshot, e.g. after a restart or a change of the dataplane backend.
+	// The restored sets reach the dataplane on the next ApplyIPSets. It returns ErrCacheNotEmpty if there are sets
+	// in the cache already, and must not run concurrently with other updates.
+	Restore(snapshot *Snapshot) error
+	osManager
+}
+
+var _ Manager = (*IPSetManager)(nil)
+
 func NewIPSetManager(iMgrCfg *IPSetManagerCfg, ioShim *common.IOShim) *IPSetManager {
 	return &IPSetManager{
 		iMgrCfg:    iMgrCfg,
 		emptySet:   nil, // will be set if needed in calls to AddToLists
 		setMap:     make(map[string]*IPSet),
 		dirtyCache: newDirtyCache(),
+		ttlWheel:   newTimerWheel(MemberTTLTick, numWheelSlots),
+		pool:       intern.NewPool(),
 		ioShim:     ioShim,
 	}
 }
@@ -90,6 +140,12 @@ func (iMgr *IPSetManager) Reconcile() {
 	if numRemovedSets > 0 {
 		klog.Infof("[IPSetManager] removed %d empty/unreferenced ipsets, updating toDeleteCache to: %+v", numRemovedSets, iMgr.dirtyCache.printDeleteCache())
 	}
+
+	// drop the strings of the removed sets and members from the intern pool and keep the ones of the cache
+	iMgr.pool.Rotate()
+	for _, set := range iMgr.setMap {
+		set.internStrings(iMgr.pool)
+	}
 }
 
 func (iMgr *IPSetManager) ResetIPSets() error {
@@ -101,6 +157,7 @@ func (iMgr *IPSetManager) ResetIPSets() error {
 	iMgr.setMap = make(map[string]*IPSet)
 	iMgr.emptySet = nil
 	iMgr.clearDirtyCache()
+	iMgr.ttlWheel.reset()
 	if err != nil {
 		metrics.SendErrorLogAndMetric(util.IpsmID, "error: failed to reset ipsetmanager: %s", err.Error())
 		return fmt.Errorf("error while resetting ipsetmanager: %w", err)
@@ -125,6 +182,7 @@ func (iMgr *IPSetManager) createAndGetIPSet(setMetadata *IPSetMetadata) *IPSet {
 	}
 
 	set = NewIPSet(setMetadata)
+	set.internStrings(iMgr.pool)
 	iMgr.setMap[prefixedName] = set
 	metrics.IncNumIPSets()
 	if iMgr.iMgrCfg.IPSetMode == ApplyAllIPSets {
@@ -137,6 +195,7 @@ func (iMgr *IPSetManager) createAndGetIPSet(setMetadata *IPSetMetadata) *IPSet {
 		if iMgr.emptySet == nil {
 			// duplicate of code chunk above
 			iMgr.emptySet = NewIPSet(emptySetMetadata)
+			iMgr.emptySet.internStrings(iMgr.pool)
 			iMgr.setMap[emptySetPrefixName] = iMgr.emptySet
 			metrics.IncNumIPSets()
 			iMgr.modifyCacheForKernelCreation(iMgr.emptySet)
@@ -148,15 +207,30 @@ func (iMgr *IPSetManager) createAndGetIPSet(setMetadata *IPSetMetadata) *IPSet {
 	return set
 }
 
-// DeleteIPSet expects the prefixed ipset name
-func (iMgr *IPSetManager) DeleteIPSet(name string, deleteOption util.DeleteOption) {
+// DeleteIPSet expects the prefixed ipset name. Deleting a missing set is a no-op.
+// It returns ErrSetInUse if the set has references, or members unless the set is force deleted.
+func (iMgr *IPSetManager) DeleteIPSet(name string, deleteOption util.DeleteOption) error {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+	set, exists := iMgr.setMap[name]
+	if !exists {
+		return nil
+	}
+	if !iMgr.modifyCacheForCacheDeletion(set, deleteOption) {
+		return setInUseError(set, iMgr.emptySet)
+	}
+	return nil
+}
+
+// GetIPSetUsage expects the prefixed ipset name and returns nil if the set doesn't exist
+func (iMgr *IPSetManager) GetIPSetUsage(name string) *IPSetUsage {
 	iMgr.Lock()
 	defer iMgr.Unlock()
 	set, exists := iMgr.setMap[name]
 	if !exists {
-		return
+		return nil
 	}
-	iMgr.modifyCacheForCacheDeletion(set, deleteOption)
+	return set.usage(iMgr.emptySet)
 }
 
 // GetIPSet needs the prefixed ipset name
@@ -234,6 +308,20 @@ func (iMgr *IPSetManager) DeleteReference(setName, referenceName string, referen
 }
 
 func (iMgr *IPSetManager) AddToSets(addToSets []*IPSetMetadata, ip, podKey string) error {
+	return iMgr.addToSets(addToSets, ip, podKey, 0)
+}
+
+func (iMgr *IPSetManager) AddToSetWithTTL(addToSets []*IPSetMetadata, ip, podKey string, ttl time.Duration) error {
+	if ttl <= 0 {
+		msg := fmt.Sprintf("error: failed to add to sets: invalid ttl %s for ip %s", ttl, ip)
+		metrics.SendErrorLogAndMetric(util.IpsmID, msg)
+		return npmerrors.Errorf(npmerrors.AppendIPSet, true, msg)
+	}
+	return iMgr.addToSets(addToSets, ip, podKey, ttl)
+}
+
+// addToSets adds a permanent member if the ttl is 0
+func (iMgr *IPSetManager) addToSets(addToSets []*IPSetMetadata, ip, podKey string, ttl time.Duration) error {
 	if len(addToSets) == 0 {
 		return nil
 	}
@@ -244,9 +332,14 @@ func (iMgr *IPSetManager) AddToSets(addToSets []*IPSetMetadata, ip, podKey strin
 		return npmerrors.Errorf(npmerrors.AppendIPSet, true, msg)
 	}
 
+	// the members of the sets of a pod share one copy of its IP and key
+	ip = iMgr.pool.String(ip)
+	podKey = iMgr.pool.String(podKey)
+
 	iMgr.Lock()
 	defer iMgr.Unlock()
 
+	now := time.Now()
 	for _, metadata := range addToSets {
 		// 1. check for errors and create a missing set
 		prefixedName := metadata.GetPrefixName()
@@ -264,6 +357,26 @@ func (iMgr *IPSetManager) AddToSets(addToSets []*IPSetMetadata, ip, podKey strin
 			iMgr.modifyCacheForKernelMemberAdd(set, ip)
 			metrics.AddEntryToIPSet(prefixedName)
 		}
+
+		// 3. update the TTL. A permanent member stays permanent.
+		_, hadTTL := set.expiryOf(ip)
+		switch {
+		case ttl == 0:
+			if set.clearExpiry(ip) {
+				// re-add the member so that it doesn't time out in the kernel
+				iMgr.modifyCacheForKernelMemberAdd(set, ip)
+			}
+		case !ok || hadTTL:
+			expiry := now.Add(ttl)
+			set.setExpiry(ip, expiry)
+			iMgr.ttlWheel.schedule(memberKey{setName: prefixedName, member: ip}, now, expiry)
+			if ok {
+				// re-add the member to refresh its timeout in the kernel
+				iMgr.modifyCacheForKernelMemberAdd(set, ip)
+			}
+		default:
+			continue
+		}
 		set.IPPodKey[ip] = podKey
 	}
 	return nil
@@ -313,11 +426,62 @@ func (iMgr *IPSetManager) RemoveFromSets(removeFromSets []*IPSetMetadata, ip, po
 		// update the IP ownership with podkey
 		iMgr.modifyCacheForKernelMemberDelete(set, ip)
 		delete(set.IPPodKey, ip)
+		set.clearExpiry(ip)
 		metrics.RemoveEntryFromIPSet(prefixedName)
 	}
 	return nil
 }
 
+func (iMgr *IPSetManager) RemoveAllForPodKeyPrefix(podKeyPrefix string) int {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+
+	numRemoved := 0
+	for prefixedName, set := range iMgr.setMap {
+		if set.Kind != HashSet {
+			continue
+		}
+		for member, podKey := range set.IPPodKey {
+			if !strings.HasPrefix(podKey, podKeyPrefix) {
+				continue
+			}
+			iMgr.modifyCacheForKernelMemberDelete(set, member)
+			delete(set.IPPodKey, member)
+			set.clearExpiry(member)
+			metrics.RemoveEntryFromIPSet(prefixedName)
+			numRemoved++
+		}
+	}
+	return numRemoved
+}
+
+func (iMgr *IPSetManager) ExpireMembers(now time.Time) int {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+
+	numExpired := 0
+	for _, key := range iMgr.ttlWheel.advance(now) {
+		set, ok := iMgr.setMap[key.setName]
+		if !ok {
+			continue
+		}
+		// the member may have been refreshed, made permanent, or removed since it was scheduled
+		expiry, ok := set.expiryOf(key.member)
+		if !ok || expiry.After(now) {
+			continue
+		}
+		iMgr.modifyCacheForKernelMemberDelete(set, key.member)
+		delete(set.IPPodKey, key.member)
+		set.clearExpiry(key.member)
+		metrics.RemoveEntryFromIPSet(key.setName)
+		numExpired++
+	}
+	if numExpired > 0 {
+		klog.Infof("[IPSetManager] expired %d members with a TTL", numExpired)
+	}
+	return numExpired
+}
+
 func (iMgr *IPSetManager) AddToLists(listMetadatas, setMetadatas []*IPSetMetadata) error {
 	if len(listMetadatas) == 0 || len(setMetadatas) == 0 {
 		return nil
@@ -482,25 +646,48 @@ func (iMgr *IPSetManager) GetAllIPSets() map[string]string {
 	return setMap
 }
 
+func (iMgr *IPSetManager) Snapshot() *Snapshot {
+	iMgr.RLock()
+	defer iMgr.RUnlock()
+	return snapshotSets(iMgr.setMap, iMgr.emptySet, time.Now())
+}
+
+func (iMgr *IPSetManager) Restore(snapshot *Snapshot) error {
+	iMgr.RLock()
+	numSets := len(iMgr.setMap)
+	iMgr.RUnlock()
+	if numSets > 0 {
+		return fmt.Errorf("%w: %d sets", ErrCacheNotEmpty, numSets)
+	}
+
+	if err := restoreSnapshot(iMgr, snapshot, time.Now()); err != nil {
+		metrics.SendErrorLogAndMetric(util.IpsmID, "error: failed to restore ipset snapshot: %s", err.Error())
+		return err
+	}
+	klog.Infof("[IPSetManager] restored %d ipsets from a snapshot taken at %s", len(snapshot.Sets), snapshot.Taken)
+	return nil
+}
+
 func (iMgr *IPSetManager) exists(name string) bool {
 	_, ok := iMgr.setMap[name]
 	return ok
 }
 
+// modifyCacheForCacheDeletion returns whether the set was deleted.
 // the metric for number of ipsets in the kernel will be lower than in reality until the next applyIPSet call
-func (iMgr *IPSetManager) modifyCacheForCacheDeletion(set *IPSet, deleteOption util.DeleteOption) {
+func (iMgr *IPSetManager) modifyCacheForCacheDeletion(set *IPSet, deleteOption util.DeleteOption) bool {
 	if set == iMgr.emptySet {
-		return
+		return false
 	}
 
 	if deleteOption == util.ForceDelete {
 		// If force delete, then check if Set is used by other set or network policy
 		// else delete the set even if it has members
 		if !set.canBeForceDeleted() {
-			return
+			return false
 		}
 	} else if !set.canBeDeleted(iMgr.emptySet) {
-		return
+		return false
 	}
 
 	delete(iMgr.setMap, set.Name)
@@ -509,6 +696,15 @@ func (iMgr *IPSetManager) modifyCacheForCacheDeletion(set *IPSet, deleteOption u
 		iMgr.modifyCacheForKernelRemoval(set)
 	}
 	// if mode is ApplyOnNeed, the set will not be in the kernel (or will be in the delete cache already) since there are no references
+	return true
+}
+
+// setInUseError explains why the set wasn't deleted. The empty set is never deleted.
+func setInUseError(set, emptySet *IPSet) error {
+	if set == emptySet {
+		return fmt.Errorf("%w: %s is needed by lists", ErrSetInUse, set.Name)
+	}
+	return fmt.Errorf("%w: %s has %s", ErrSetInUse, set.Name, set.usage(emptySet))
 }
 
 func (iMgr *IPSetManager) modifyCacheForKernelCreation(set *IPSet) {
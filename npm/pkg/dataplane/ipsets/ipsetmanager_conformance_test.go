@@ -0,0 +1,256 @@
+package ipsets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/util"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// newManagerFunc returns a Manager which expects the exec calls if it is OS backed.
+type newManagerFunc func(t *testing.T, cfg *IPSetManagerCfg, calls []testutils.TestCmd) Manager
+
+// the conformance tests are the behavior the dataplane relies on, which every Manager must have.
+var managerConformanceTests = []struct {
+	name string
+	test func(t *testing.T, newManager newManagerFunc)
+}{
+	{"sets", testManagerSets},
+	{"lists", testManagerLists},
+	{"references", testManagerReferences},
+	{"reconcile and reset", testManagerReconcileAndReset},
+	{"empty set in lists", testManagerEmptySetInLists},
+	{"usage", testManagerUsage},
+	{"member TTL", testManagerMemberTTL},
+	{"snapshot and restore", testManagerSnapshotRestore},
+	{"remove all for pod key prefix", testManagerRemoveAllForPodKeyPrefix},
+}
+
+func runManagerConformanceTests(t *testing.T, newManager newManagerFunc) {
+	for _, tt := range managerConformanceTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newManager)
+		})
+	}
+}
+
+func TestIPSetManagerConformance(t *testing.T) {
+	runManagerConformanceTests(t, func(t *testing.T, cfg *IPSetManagerCfg, calls []testutils.TestCmd) Manager {
+		ioshim := common.NewMockIOShim(calls)
+		t.Cleanup(func() { ioshim.VerifyCalls(t, calls) })
+		return NewIPSetManager(cfg, ioshim)
+	})
+}
+
+func TestFakeIPSetManagerConformance(t *testing.T) {
+	runManagerConformanceTests(t, func(_ *testing.T, cfg *IPSetManagerCfg, _ []testutils.TestCmd) Manager {
+		return NewFakeIPSetManager(cfg)
+	})
+}
+
+func testManagerSets(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, applyAlwaysCfg, GetApplyIPSetsTestCalls([]*IPSetMetadata{TestNSSet.Metadata}, nil))
+
+	m.CreateIPSets([]*IPSetMetadata{TestNSSet.Metadata})
+	set := m.GetIPSet(TestNSSet.PrefixName)
+	require.NotNil(t, set)
+	require.Equal(t, TestNSSet.HashedName, set.HashedName)
+	require.Equal(t, map[string]string{TestNSSet.HashedName: TestNSSet.PrefixName}, m.GetAllIPSets())
+	require.Nil(t, m.GetIPSet(TestKVPodSet.PrefixName))
+
+	// missing sets are created
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestNSSet.Metadata, TestKVPodSet.Metadata}, testPodIP, testPodKey))
+	require.Equal(t, map[string]string{testPodIP: testPodKey}, m.GetIPSet(TestNSSet.PrefixName).IPPodKey)
+	require.Equal(t, map[string]string{testPodIP: testPodKey}, m.GetIPSet(TestKVPodSet.PrefixName).IPPodKey)
+	require.Error(t, m.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "not-an-ip", testPodKey))
+	require.Error(t, m.AddToSets([]*IPSetMetadata{TestKeyNSList.Metadata}, testPodIP, testPodKey), "lists have no IPs")
+
+	// the IP belongs to a new pod, the delete is stale
+	require.NoError(t, m.RemoveFromSets([]*IPSetMetadata{TestNSSet.Metadata}, testPodIP, "other-pod-key"))
+	require.Contains(t, m.GetIPSet(TestNSSet.PrefixName).IPPodKey, testPodIP)
+	require.NoError(t, m.RemoveFromSets([]*IPSetMetadata{TestNSSet.Metadata, TestCIDRSet.Metadata}, testPodIP, testPodKey))
+	require.Empty(t, m.GetIPSet(TestNSSet.PrefixName).IPPodKey)
+	require.Nil(t, m.GetIPSet(TestCIDRSet.PrefixName), "missing sets are not created")
+
+	require.NoError(t, m.ApplyIPSets())
+}
+
+func testManagerLists(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, applyAlwaysCfg, GetApplyIPSetsTestCalls([]*IPSetMetadata{TestKeyNSList.Metadata}, nil))
+
+	require.NoError(t, m.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata}))
+	require.Contains(t, m.GetIPSet(TestKeyNSList.PrefixName).MemberIPSets, TestNSSet.PrefixName)
+	require.NotNil(t, m.GetIPSet(TestNSSet.PrefixName), "missing members are created")
+	require.Error(t, m.AddToLists([]*IPSetMetadata{TestNSSet.Metadata}, []*IPSetMetadata{TestKVPodSet.Metadata}), "hash sets have no members")
+	require.Error(t, m.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestKVNSList.Metadata}), "lists are not nested")
+
+	// members of lists are not deleted
+	require.ErrorIs(t, m.DeleteIPSet(TestNSSet.PrefixName, util.SoftDelete), ErrSetInUse)
+	require.ErrorIs(t, m.DeleteIPSet(TestNSSet.PrefixName, util.ForceDelete), ErrSetInUse)
+	require.NotNil(t, m.GetIPSet(TestNSSet.PrefixName))
+	// lists with members are only force deleted
+	require.ErrorIs(t, m.DeleteIPSet(TestKeyNSList.PrefixName, util.SoftDelete), ErrSetInUse)
+	require.NotNil(t, m.GetIPSet(TestKeyNSList.PrefixName))
+
+	require.NoError(t, m.RemoveFromList(TestKeyNSList.Metadata, []*IPSetMetadata{TestNSSet.Metadata, TestKVPodSet.Metadata}))
+	require.Empty(t, m.GetIPSet(TestKeyNSList.PrefixName).MemberIPSets)
+	require.NoError(t, m.RemoveFromList(TestKVNSList.Metadata, []*IPSetMetadata{TestNSSet.Metadata}), "missing lists are ignored")
+	require.Error(t, m.RemoveFromList(TestNSSet.Metadata, []*IPSetMetadata{TestKVPodSet.Metadata}))
+	require.NoError(t, m.DeleteIPSet(TestNSSet.PrefixName, util.SoftDelete))
+	require.Nil(t, m.GetIPSet(TestNSSet.PrefixName))
+
+	require.NoError(t, m.ApplyIPSets())
+}
+
+func testManagerReferences(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, applyOnNeedCfg, GetApplyIPSetsTestCalls([]*IPSetMetadata{TestCIDRSet.Metadata}, nil))
+
+	require.NoError(t, m.AddReference(TestKVPodSet.Metadata, testNetPolKey, SelectorType))
+	require.Contains(t, m.GetIPSet(TestKVPodSet.PrefixName).SelectorReference, testNetPolKey)
+	require.Error(t, m.AddReference(TestCIDRSet.Metadata, testNetPolKey, SelectorType), "cidr sets don't select pods")
+	require.NoError(t, m.AddReference(TestCIDRSet.Metadata, testNetPolKey, NetPolType))
+	require.Contains(t, m.GetIPSet(TestCIDRSet.PrefixName).NetPolReference, testNetPolKey)
+
+	// sets used by policies are not deleted
+	require.ErrorIs(t, m.DeleteIPSet(TestKVPodSet.PrefixName, util.ForceDelete), ErrSetInUse)
+	require.NotNil(t, m.GetIPSet(TestKVPodSet.PrefixName))
+
+	require.Error(t, m.DeleteReference(TestNSSet.PrefixName, testNetPolKey, SelectorType))
+	require.NoError(t, m.DeleteReference(TestKVPodSet.PrefixName, testNetPolKey, SelectorType))
+	require.Empty(t, m.GetIPSet(TestKVPodSet.PrefixName).SelectorReference)
+	require.NoError(t, m.DeleteIPSet(TestKVPodSet.PrefixName, util.ForceDelete))
+	require.Nil(t, m.GetIPSet(TestKVPodSet.PrefixName))
+	require.NoError(t, m.DeleteIPSet(TestKVPodSet.PrefixName, util.ForceDelete), "missing sets are ignored")
+
+	require.NoError(t, m.ApplyIPSets())
+}
+
+func testManagerReconcileAndReset(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, applyOnNeedCfg, GetResetTestCalls())
+
+	m.CreateIPSets([]*IPSetMetadata{TestNSSet.Metadata})
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestKVPodSet.Metadata}, testPodIP, testPodKey))
+
+	// empty sets which are not referenced are removed
+	m.Reconcile()
+	require.Nil(t, m.GetIPSet(TestNSSet.PrefixName))
+	require.NotNil(t, m.GetIPSet(TestKVPodSet.PrefixName))
+
+	require.NoError(t, m.ResetIPSets())
+	require.Empty(t, m.GetAllIPSets())
+}
+
+func testManagerEmptySetInLists(t *testing.T, newManager newManagerFunc) {
+	cfg := &IPSetManagerCfg{IPSetMode: ApplyOnNeed, NetworkName: "azure", AddEmptySetToLists: true}
+	m := newManager(t, cfg, nil)
+
+	m.CreateIPSets([]*IPSetMetadata{TestKeyNSList.Metadata, TestNestedLabelList.Metadata})
+	require.Contains(t, m.GetIPSet(TestKeyNSList.PrefixName).MemberIPSets, emptySetPrefixName)
+	require.Empty(t, m.GetIPSet(TestNestedLabelList.PrefixName).MemberIPSets, "only namespace lists have the empty set")
+
+	// the empty set stays in the list and is never deleted
+	require.NoError(t, m.RemoveFromList(TestKeyNSList.Metadata, []*IPSetMetadata{emptySetMetadata}))
+	require.Contains(t, m.GetIPSet(TestKeyNSList.PrefixName).MemberIPSets, emptySetPrefixName)
+	require.NoError(t, m.DeleteIPSet(TestKeyNSList.PrefixName, util.SoftDelete))
+	require.ErrorIs(t, m.DeleteIPSet(emptySetPrefixName, util.ForceDelete), ErrSetInUse)
+	require.Nil(t, m.GetIPSet(TestKeyNSList.PrefixName))
+	require.NotNil(t, m.GetIPSet(emptySetPrefixName))
+}
+
+func testManagerUsage(t *testing.T, newManager newManagerFunc) {
+	cfg := &IPSetManagerCfg{IPSetMode: ApplyOnNeed, NetworkName: "azure", AddEmptySetToLists: true}
+	m := newManager(t, cfg, nil)
+
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, testPodIP, testPodKey))
+	require.NoError(t, m.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata, TestKVNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata}))
+	require.NoError(t, m.AddReference(TestNSSet.Metadata, testNetPolKey, SelectorType))
+	require.NoError(t, m.AddReference(TestNSSet.Metadata, testNetPolKey, NetPolType))
+	require.NoError(t, m.AddReference(TestNSSet.Metadata, "other-netpol", NetPolType))
+	require.Equal(t, &IPSetUsage{Members: 1, SelectorReferences: 1, NetPolReferences: 2, ListReferences: 2}, m.GetIPSetUsage(TestNSSet.PrefixName))
+	// the empty set isn't counted as a member
+	require.Equal(t, &IPSetUsage{Members: 1}, m.GetIPSetUsage(TestKeyNSList.PrefixName))
+	require.Nil(t, m.GetIPSetUsage(TestKVPodSet.PrefixName))
+
+	err := m.DeleteIPSet(TestNSSet.PrefixName, util.SoftDelete)
+	require.ErrorIs(t, err, ErrSetInUse)
+	require.Contains(t, err.Error(), "1 members, 1 selector references, 2 netpol references, 2 list references")
+}
+
+func testManagerMemberTTL(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, applyAlwaysCfg, nil)
+	now := time.Now()
+
+	require.NoError(t, m.AddToSetWithTTL([]*IPSetMetadata{TestNSSet.Metadata}, testPodIP, testPodKey, time.Minute))
+	require.Equal(t, map[string]string{testPodIP: testPodKey}, m.GetIPSet(TestNSSet.PrefixName).IPPodKey, "missing sets are created")
+	require.Error(t, m.AddToSetWithTTL([]*IPSetMetadata{TestNSSet.Metadata}, testPodIP, testPodKey, 0))
+	require.Error(t, m.AddToSetWithTTL([]*IPSetMetadata{TestKeyNSList.Metadata}, testPodIP, testPodKey, time.Minute), "lists have no IPs")
+
+	// a permanent member stays permanent
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestKVPodSet.Metadata}, testPodIP, testPodKey))
+	require.NoError(t, m.AddToSetWithTTL([]*IPSetMetadata{TestKVPodSet.Metadata}, testPodIP, testPodKey, time.Second))
+	// AddToSets makes a member permanent
+	require.NoError(t, m.AddToSetWithTTL([]*IPSetMetadata{TestKeyPodSet.Metadata}, testPodIP, testPodKey, time.Second))
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestKeyPodSet.Metadata}, testPodIP, testPodKey))
+	// adding again refreshes the TTL
+	require.NoError(t, m.AddToSetWithTTL([]*IPSetMetadata{TestNamedportSet.Metadata}, "10.0.0.2,tcp:80", testPodKey, time.Second))
+	require.NoError(t, m.AddToSetWithTTL([]*IPSetMetadata{TestNamedportSet.Metadata}, "10.0.0.2,tcp:80", testPodKey, time.Hour))
+	// removed members don't expire
+	require.NoError(t, m.AddToSetWithTTL([]*IPSetMetadata{TestCIDRSet.Metadata}, "10.0.0.0/16", testPodKey, time.Second))
+	require.NoError(t, m.RemoveFromSets([]*IPSetMetadata{TestCIDRSet.Metadata}, "10.0.0.0/16", testPodKey))
+
+	require.Equal(t, 0, m.ExpireMembers(now))
+	require.Equal(t, 1, m.ExpireMembers(now.Add(2*time.Minute)))
+	require.Empty(t, m.GetIPSet(TestNSSet.PrefixName).IPPodKey)
+	require.Contains(t, m.GetIPSet(TestKVPodSet.PrefixName).IPPodKey, testPodIP)
+	require.Contains(t, m.GetIPSet(TestKeyPodSet.PrefixName).IPPodKey, testPodIP)
+	require.Contains(t, m.GetIPSet(TestNamedportSet.PrefixName).IPPodKey, "10.0.0.2,tcp:80")
+
+	require.Equal(t, 1, m.ExpireMembers(now.Add(2*time.Hour)))
+	require.Empty(t, m.GetIPSet(TestNamedportSet.PrefixName).IPPodKey)
+	require.Equal(t, 0, m.ExpireMembers(now.Add(3*time.Hour)))
+}
+
+func testManagerSnapshotRestore(t *testing.T, newManager newManagerFunc) {
+	cfg := &IPSetManagerCfg{IPSetMode: ApplyOnNeed, NetworkName: "azure", AddEmptySetToLists: true}
+	m := newManager(t, cfg, nil)
+
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, testPodIP, testPodKey))
+	require.NoError(t, m.AddToSetWithTTL([]*IPSetMetadata{TestKVPodSet.Metadata}, "10.0.0.2", "other-pod-key", time.Hour))
+	require.NoError(t, m.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata}))
+	require.NoError(t, m.AddReference(TestKVPodSet.Metadata, testNetPolKey, SelectorType))
+	require.NoError(t, m.AddReference(TestKeyNSList.Metadata, testNetPolKey, NetPolType))
+
+	snapshot := m.Snapshot()
+	require.Equal(t, SnapshotVersion, snapshot.Version)
+	require.Len(t, snapshot.Sets, 3, "the empty set isn't in the snapshot")
+
+	restored := newManager(t, cfg, nil)
+	require.NoError(t, restored.Restore(snapshot))
+	for _, set := range []*TestSet{TestNSSet, TestKVPodSet, TestKeyNSList} {
+		require.Equal(t, m.GetIPSetUsage(set.PrefixName), restored.GetIPSetUsage(set.PrefixName), set.PrefixName)
+	}
+	require.Equal(t, map[string]string{testPodIP: testPodKey}, restored.GetIPSet(TestNSSet.PrefixName).IPPodKey, "pod keys are restored")
+	require.Contains(t, restored.GetIPSet(TestKeyNSList.PrefixName).MemberIPSets, emptySetPrefixName)
+	require.Equal(t, 1, restored.ExpireMembers(time.Now().Add(2*time.Hour)), "TTLs are restored")
+
+	require.ErrorIs(t, restored.Restore(snapshot), ErrCacheNotEmpty)
+	require.ErrorIs(t, newManager(t, cfg, nil).Restore(&Snapshot{Version: SnapshotVersion + 1}), ErrSnapshotVersion)
+}
+
+func testManagerRemoveAllForPodKeyPrefix(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, applyAlwaysCfg, nil)
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestNSSet.Metadata, TestKVPodSet.Metadata}, "10.0.0.1", "x/a"))
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestKVPodSet.Metadata}, "10.0.0.2", "x/b"))
+	require.NoError(t, m.AddToSets([]*IPSetMetadata{TestKVPodSet.Metadata}, "10.0.0.3", "xy/c"))
+	require.NoError(t, m.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata}))
+
+	require.Equal(t, 3, m.RemoveAllForPodKeyPrefix("x/"))
+	require.Empty(t, m.GetIPSet(TestNSSet.PrefixName).IPPodKey)
+	require.Equal(t, map[string]string{"10.0.0.3": "xy/c"}, m.GetIPSet(TestKVPodSet.PrefixName).IPPodKey, "the pods of other namespaces stay")
+	require.Contains(t, m.GetIPSet(TestKeyNSList.PrefixName).MemberIPSets, TestNSSet.PrefixName, "lists keep their member sets")
+	require.Zero(t, m.RemoveAllForPodKeyPrefix("x/"))
+}
@@ -3,7 +3,9 @@ package ipsets
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/parse"
@@ -14,6 +16,9 @@ import (
 	utilexec "k8s.io/utils/exec"
 )
 
+// osManager holds the Manager methods only used by the dataplane of the OS.
+type osManager interface{}
+
 const (
 	ipsetFlushAndDestroyString = "ipset flush && ipset destroy"
 
@@ -39,6 +44,9 @@ const (
 	ipsetIPPortHashFlag = "hash:ip,port"
 	ipsetMaxelemName    = "maxelem"
 	ipsetMaxelemNum     = "4294967295"
+	ipsetTimeoutName    = "timeout"
+	// a timeout of 0 makes a member permanent in a set with timeout support
+	ipsetNoTimeout = "0"
 
 	// constants for parsing ipset save
 	createStringWithSpace = "create "
@@ -603,7 +611,8 @@ func (iMgr *IPSetManager) updateDirtyKernelSets(setsToAddOrUpdate map[string]str
 			lineAfterAdd := string(line[len(addStringWithSpace):])
 			spaceSplitLineAfterAdd := strings.Split(lineAfterAdd, space)
 			parent := spaceSplitLineAfterAdd[0]
-			if len(spaceSplitLineAfterAdd) != 2 || parent != hashedName {
+			// members of sets with timeout support are followed by their remaining timeout
+			if len(spaceSplitLineAfterAdd) < 2 || parent != hashedName {
 				metrics.SendErrorLogAndMetric(util.IpsmID, "expected an add line for set %s in ipset save file, but got the following line: %s", hashedName, string(line))
 				// TODO send error snapshot
 				line, readIndex = nextCreateLine(readIndex, saveFile)
@@ -740,6 +749,9 @@ func (iMgr *IPSetManager) createSetForApply(creator *ioutil.FileCreator, set *IP
 	if set.Type == CIDRBlocks {
 		specs = append(specs, ipsetMaxelemName, ipsetMaxelemNum)
 	}
+	if iMgr.usesKernelTimeouts(set) {
+		specs = append(specs, ipsetTimeoutName, ipsetNoTimeout)
+	}
 
 	prefixedName := set.Name // to appease golint complaints about function literal
 	errorHandlers := []*ioutil.LineErrorHandler{
@@ -774,7 +786,12 @@ func (iMgr *IPSetManager) deleteMemberForApply(creator *ioutil.FileCreator, set
 			},
 		},
 	}
-	creator.AddLine(sectionID, errorHandlers, ipsetDeleteFlag, set.HashedName, member) // delete member
+	specs := []string{ipsetDeleteFlag, set.HashedName, member}
+	if iMgr.usesKernelTimeouts(set) {
+		// the kernel may have expired the member already
+		specs = append(specs, ipsetExistFlag)
+	}
+	creator.AddLine(sectionID, errorHandlers, specs...) // delete member
 }
 
 func (iMgr *IPSetManager) addMemberForApply(creator *ioutil.FileCreator, set *IPSet, sectionID, member string) {
@@ -808,7 +825,30 @@ func (iMgr *IPSetManager) addMemberForApply(creator *ioutil.FileCreator, set *IP
 			},
 		}
 	}
-	creator.AddLine(sectionID, errorHandlers, ipsetAddFlag, set.HashedName, member) // add member
+	specs := []string{ipsetAddFlag, set.HashedName, member}
+	if iMgr.usesKernelTimeouts(set) {
+		// --exist updates the timeout of a member which is already in the kernel
+		specs = append(specs, ipsetTimeoutName, kernelTimeout(set, member), ipsetExistFlag)
+	}
+	creator.AddLine(sectionID, errorHandlers, specs...) // add member
+}
+
+func (iMgr *IPSetManager) usesKernelTimeouts(set *IPSet) bool {
+	return iMgr.iMgrCfg.UseKernelTimeouts && set.Kind == HashSet
+}
+
+// kernelTimeout returns the remaining seconds of the member's TTL, rounded up, or 0 if the member is permanent
+func kernelTimeout(set *IPSet, member string) string {
+	expiry, ok := set.expiryOf(member)
+	if !ok {
+		return ipsetNoTimeout
+	}
+	seconds := int64(math.Ceil(time.Until(expiry).Seconds()))
+	if seconds < 1 {
+		// the member expires in the cache on the next ExpireMembers call
+		seconds = 1
+	}
+	return fmt.Sprint(seconds)
 }
 
 func sectionID(prefix, prefixedName string) string {
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/metrics"
@@ -992,6 +993,40 @@ func TestDeleteMembers(t *testing.T) {
 	require.False(t, wasFileAltered, "file should not be altered")
 }
 
+func TestKernelTimeouts(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	iMgr := NewIPSetManager(&IPSetManagerCfg{IPSetMode: ApplyAllIPSets, UseKernelTimeouts: true}, ioshim)
+
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "1.1.1.1", "a"))
+	require.NoError(t, iMgr.AddToSetWithTTL([]*IPSetMetadata{TestNSSet.Metadata}, "2.2.2.2", "b", 30*time.Second))
+	iMgr.CreateIPSets([]*IPSetMetadata{TestCIDRSet.Metadata})
+	require.NoError(t, iMgr.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata}))
+
+	expectedLines := []string{
+		fmt.Sprintf("-N %s --exist nethash timeout 0", TestNSSet.HashedName),
+		fmt.Sprintf("-N %s --exist nethash maxelem 4294967295 timeout 0", TestCIDRSet.HashedName),
+		fmt.Sprintf("-N %s --exist setlist", TestKeyNSList.HashedName),
+		fmt.Sprintf("-A %s 1.1.1.1 timeout 0 --exist", TestNSSet.HashedName),
+		fmt.Sprintf("-A %s 2.2.2.2 timeout 30 --exist", TestNSSet.HashedName),
+		fmt.Sprintf("-A %s %s", TestKeyNSList.HashedName, TestNSSet.HashedName),
+		"",
+	}
+	creator := iMgr.fileCreatorForApply(1)
+	dptestutils.AssertEqualLines(t, testAndSortRestoreFileLines(t, expectedLines), testAndSortRestoreFileString(t, creator.ToString()))
+	iMgr.clearDirtyCache()
+
+	// the kernel may have expired the member already
+	require.Equal(t, 1, iMgr.ExpireMembers(time.Now().Add(time.Minute)))
+	expectedLines = []string{
+		fmt.Sprintf("-N %s --exist nethash timeout 0", TestNSSet.HashedName),
+		fmt.Sprintf("-D %s 2.2.2.2 --exist", TestNSSet.HashedName),
+		"",
+	}
+	creator = iMgr.fileCreatorForApply(1)
+	dptestutils.AssertEqualLines(t, testAndSortRestoreFileLines(t, expectedLines), testAndSortRestoreFileString(t, creator.ToString()))
+}
+
 func TestUpdateWithIdenticalSaveFile(t *testing.T) {
 	calls := []testutils.TestCmd{fakeRestoreSuccessCommand}
 	ioshim := common.NewMockIOShim(calls)
@@ -3,7 +3,10 @@ package ipsets
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"runtime"
 	"testing"
+	"unsafe"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/metrics"
@@ -516,7 +519,7 @@ func TestDeleteIPSet(t *testing.T) {
 			iMgr := NewIPSetManager(tt.args.cfg, ioShim)
 			iMgr.CreateIPSets(tt.args.toCreateMetadatas)
 			require.NoError(t, iMgr.ApplyIPSets())
-			iMgr.DeleteIPSet(tt.args.toDeleteName, util.SoftDelete)
+			require.NoError(t, iMgr.DeleteIPSet(tt.args.toDeleteName, util.SoftDelete))
 			assertExpectedInfo(t, iMgr, &tt.expectedInfo)
 		})
 	}
@@ -581,8 +584,8 @@ func TestDeleteIPSetNotAllowed(t *testing.T) {
 	require.NoError(t, iMgr.AddToLists([]*IPSetMetadata{list}, []*IPSetMetadata{namespaceSet}))
 	require.NoError(t, iMgr.ApplyIPSets())
 
-	iMgr.DeleteIPSet(namespaceSet.GetPrefixName(), util.SoftDelete)
-	iMgr.DeleteIPSet(list.GetPrefixName(), util.SoftDelete)
+	require.ErrorIs(t, iMgr.DeleteIPSet(namespaceSet.GetPrefixName(), util.SoftDelete), ErrSetInUse)
+	require.ErrorIs(t, iMgr.DeleteIPSet(list.GetPrefixName(), util.SoftDelete), ErrSetInUse)
 
 	assertExpectedInfo(t, iMgr, &expectedInfo{
 		mainCache: []setMembers{
@@ -1663,3 +1666,70 @@ func assertExpectedInfo(t *testing.T, iMgr *IPSetManager, info *expectedInfo) {
 		require.Equal(t, expectedNumEntries, numEntries, "numEntries mismatch for set %s", set.Name)
 	}
 }
+
+func TestAddToSetsSharesStrings(t *testing.T) {
+	metrics.InitializeAll()
+	iMgr := NewIPSetManager(applyOnNeedCfg, common.NewMockIOShim(nil))
+	// the strings of two events for the same pod
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{namespaceSet}, fmt.Sprintf("10.0.0.%d", 1), fmt.Sprintf("ns/pod-%d", 1)))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{keyLabelOfPodSet}, fmt.Sprintf("10.0.0.%d", 1), fmt.Sprintf("ns/pod-%d", 1)))
+
+	podKey1 := iMgr.GetIPSet(namespaceSet.GetPrefixName()).IPPodKey["10.0.0.1"]
+	podKey2 := iMgr.GetIPSet(keyLabelOfPodSet.GetPrefixName()).IPPodKey["10.0.0.1"]
+	require.True(t, sameStringData(podKey1, podKey2), "the sets share one copy of the pod key")
+
+	iMgr.Reconcile()
+	keyValueSet := NewIPSetMetadata("test-set4", KeyValueLabelOfPod)
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{keyValueSet}, fmt.Sprintf("10.0.0.%d", 1), fmt.Sprintf("ns/pod-%d", 1)))
+	podKey3 := iMgr.GetIPSet(keyValueSet.GetPrefixName()).IPPodKey["10.0.0.1"]
+	require.True(t, sameStringData(podKey1, podKey3), "the strings of the cache stay shared after a reconcile")
+}
+
+// addSyntheticPods adds pods to the manager like the pod controller, with new strings for each event like the pod
+// objects which the informer decodes: one event creates the pod and a later one flips a label during a rollout.
+func addSyntheticPods(b *testing.B, iMgr *IPSetManager, numPods int) {
+	b.Helper()
+	for i := 0; i < numPods; i++ {
+		namespace := fmt.Sprintf("ns-%d", i%500)
+		ip := func() string { return fmt.Sprintf("10.%d.%d.%d", (i>>16)&255, (i>>8)&255, i&255) }
+		podKey := func() string { return fmt.Sprintf("%s/pod-%d", namespace, i) }
+
+		createdIP, createdPodKey := ip(), podKey()
+		created := []*IPSetMetadata{
+			NewIPSetMetadata(namespace, Namespace),
+			NewIPSetMetadata("app", KeyLabelOfPod),
+			NewIPSetMetadata(fmt.Sprintf("app:app-%d", i%1000), KeyValueLabelOfPod),
+			NewIPSetMetadata("version", KeyLabelOfPod),
+			NewIPSetMetadata("version:v1", KeyValueLabelOfPod),
+		}
+		require.NoError(b, iMgr.AddToSets(created, createdIP, createdPodKey))
+		require.NoError(b, iMgr.AddToSets([]*IPSetMetadata{NewIPSetMetadata("http", NamedPorts)}, createdIP+",8080", createdPodKey))
+
+		updatedIP, updatedPodKey := ip(), podKey()
+		require.NoError(b, iMgr.RemoveFromSets([]*IPSetMetadata{NewIPSetMetadata("version:v1", KeyValueLabelOfPod)}, updatedIP, updatedPodKey))
+		require.NoError(b, iMgr.AddToSets([]*IPSetMetadata{NewIPSetMetadata("version:v2", KeyValueLabelOfPod)}, updatedIP, updatedPodKey))
+	}
+}
+
+// BenchmarkIPSetCacheHeap reports the heap which the ipset cache of 50k pods keeps.
+func BenchmarkIPSetCacheHeap(b *testing.B) {
+	metrics.InitializeAll()
+	var memStats runtime.MemStats
+	for n := 0; n < b.N; n++ {
+		runtime.GC()
+		runtime.ReadMemStats(&memStats)
+		heapBefore := memStats.HeapAlloc
+
+		iMgr := NewIPSetManager(applyOnNeedCfg, common.NewMockIOShim(nil))
+		addSyntheticPods(b, iMgr, 50000)
+
+		runtime.GC()
+		runtime.ReadMemStats(&memStats)
+		b.ReportMetric(float64(memStats.HeapAlloc-heapBefore)/(1<<20), "heap-MiB")
+		runtime.KeepAlive(iMgr)
+	}
+}
+
+func sameStringData(a, b string) bool {
+	return (*reflect.StringHeader)(unsafe.Pointer(&a)).Data == (*reflect.StringHeader)(unsafe.Pointer(&b)).Data
+}
@@ -20,6 +20,13 @@ const (
 	donotResetIPSets                           = false
 )
 
+// osManager holds the Manager methods only used by the dataplane of the OS.
+type osManager interface {
+	DoesIPSatisfySelectorIPSets(ip, podKey string, setList map[string]struct{}) (bool, error)
+	GetIPsFromSelectorIPSets(setList map[string]struct{}) (map[string]string, error)
+	GetSelectorReferencesBySet(setName string) (map[string]struct{}, error)
+}
+
 var errUnsupportedNetwork = errors.New("only 'azure' network is supported")
 
 type networkPolicyBuilder struct {
@@ -29,19 +36,38 @@ type networkPolicyBuilder struct {
 }
 
 func (iMgr *IPSetManager) DoesIPSatisfySelectorIPSets(ip, podKey string, setList map[string]struct{}) (bool, error) {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+	return doesIPSatisfySelectorIPSets(iMgr.setMap, ip, podKey, setList)
+}
+
+// GetIPsFromSelectorIPSets will take in a map of prefixedSetNames and return an intersection of IPs mapped to pod key
+func (iMgr *IPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{}) (map[string]string, error) {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+	return getIPsFromSelectorIPSets(iMgr.setMap, setList)
+}
+
+func (iMgr *IPSetManager) GetSelectorReferencesBySet(setName string) (map[string]struct{}, error) {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+	return getSelectorReferencesBySet(iMgr.setMap, setName)
+}
+
+// the selector helpers below are shared with FakeIPSetManager, which keeps its sets in a map of the same shape.
+
+func doesIPSatisfySelectorIPSets(setMap map[string]*IPSet, ip, podKey string, setList map[string]struct{}) (bool, error) {
 	if len(setList) == 0 {
 		klog.Infof("[ipset manager] unexpectedly encountered empty selector list")
 		return true, nil
 	}
-	iMgr.Lock()
-	defer iMgr.Unlock()
 
-	if err := iMgr.validateSelectorIPSets(setList); err != nil {
+	if err := validateSelectorIPSets(setMap, setList); err != nil {
 		return false, err
 	}
 
 	for setName := range setList {
-		set := iMgr.setMap[setName]
+		set := setMap[setName]
 		if !set.isIPAffiliated(ip, podKey) {
 			return false, nil
 		}
@@ -50,16 +76,13 @@ func (iMgr *IPSetManager) DoesIPSatisfySelectorIPSets(ip, podKey string, setList
 	return true, nil
 }
 
-// GetIPsFromSelectorIPSets will take in a map of prefixedSetNames and return an intersection of IPs mapped to pod key
-func (iMgr *IPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{}) (map[string]string, error) {
+func getIPsFromSelectorIPSets(setMap map[string]*IPSet, setList map[string]struct{}) (map[string]string, error) {
 	ips := make(map[string]string)
 	if len(setList) == 0 {
 		return ips, nil
 	}
-	iMgr.Lock()
-	defer iMgr.Unlock()
 
-	if err := iMgr.validateSelectorIPSets(setList); err != nil {
+	if err := validateSelectorIPSets(setMap, setList); err != nil {
 		return nil, err
 	}
 
@@ -68,7 +91,7 @@ func (iMgr *IPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{})
 	// which is a hash set, and we favor hash sets for firstSet
 	var firstSet *IPSet
 	for setName := range setList {
-		firstSet = iMgr.setMap[setName]
+		firstSet = setMap[setName]
 		if firstSet.Kind == HashSet {
 			// firstSet can be any set, but ideally is a hash set for efficiency (compare the branch for hash sets to the one for lists below)
 			break
@@ -82,7 +105,7 @@ func (iMgr *IPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{})
 				if otherSetName == firstSet.Name {
 					continue
 				}
-				otherSet := iMgr.setMap[otherSetName]
+				otherSet := setMap[otherSetName]
 				if !otherSet.isIPAffiliated(ip, podKey) {
 					isAffiliated = false
 					break
@@ -119,7 +142,7 @@ func (iMgr *IPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{})
 				if otherSetName == firstSet.Name {
 					continue
 				}
-				otherSet := iMgr.setMap[otherSetName]
+				otherSet := setMap[otherSetName]
 				if !otherSet.isIPAffiliated(ip, podKey) {
 					isAffiliated = false
 					break
@@ -134,16 +157,14 @@ func (iMgr *IPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{})
 	return ips, nil
 }
 
-func (iMgr *IPSetManager) GetSelectorReferencesBySet(setName string) (map[string]struct{}, error) {
-	iMgr.Lock()
-	defer iMgr.Unlock()
-	if !iMgr.exists(setName) {
+func getSelectorReferencesBySet(setMap map[string]*IPSet, setName string) (map[string]struct{}, error) {
+	set, ok := setMap[setName]
+	if !ok {
 		return nil, npmerrors.Errorf(
 			npmerrors.GetSelectorReference,
 			false,
 			fmt.Sprintf("[ipset manager] selector ipset %s does not exist", setName))
 	}
-	set := iMgr.setMap[setName]
 	m := make(map[string]struct{}, len(set.SelectorReference))
 	for r := range set.SelectorReference {
 		m[r] = struct{}{}
@@ -151,15 +172,15 @@ func (iMgr *IPSetManager) GetSelectorReferencesBySet(setName string) (map[string
 	return m, nil
 }
 
-func (iMgr *IPSetManager) validateSelectorIPSets(setList map[string]struct{}) error {
+func validateSelectorIPSets(setMap map[string]*IPSet, setList map[string]struct{}) error {
 	for setName := range setList {
-		if !iMgr.exists(setName) {
+		set, ok := setMap[setName]
+		if !ok {
 			return npmerrors.Errorf(
 				npmerrors.GetSelectorReference,
 				false,
 				fmt.Sprintf("[ipset manager] selector ipset %s does not exist", setName))
 		}
-		set := iMgr.setMap[setName]
 		if !set.canSetBeSelectorIPSet() {
 			return npmerrors.Errorf(
 				npmerrors.IPSetIntersection,
@@ -0,0 +1,409 @@
+package ipsets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+)
+
+// FakeIPSetManager is an in-memory Manager for tests which don't need the OS. It keeps the cache of ipsets
+// like IPSetManager, and on ApplyIPSets takes a snapshot of the sets which IPSetManager would have in the
+// kernel, so that tests can check what the dataplane applied. See the conformance tests for the shared behavior.
+type FakeIPSetManager struct {
+	sync.Mutex
+	cfg        *IPSetManagerCfg
+	emptySet   *IPSet
+	setMap     map[string]*IPSet
+	applied    map[string][]string
+	applyCount int
+	applyErr   error
+}
+
+var _ Manager = (*FakeIPSetManager)(nil)
+
+func NewFakeIPSetManager(cfg *IPSetManagerCfg) *FakeIPSetManager {
+	return &FakeIPSetManager{
+		cfg:     cfg,
+		setMap:  make(map[string]*IPSet),
+		applied: make(map[string][]string),
+	}
+}
+
+// AppliedSets returns the members of the sets applied by the last successful ApplyIPSets by prefixed set
+// name. Hash sets have their IPs as members, lists the prefixed names of their member sets. Both are sorted.
+func (f *FakeIPSetManager) AppliedSets() map[string][]string {
+	f.Lock()
+	defer f.Unlock()
+	sets := make(map[string][]string, len(f.applied))
+	for name, members := range f.applied {
+		sets[name] = append(make([]string, 0, len(members)), members...)
+	}
+	return sets
+}
+
+// ApplyCount returns the number of calls to ApplyIPSets, including the failed ones.
+func (f *FakeIPSetManager) ApplyCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.applyCount
+}
+
+// SetApplyError makes ApplyIPSets fail with err until it is called again with nil.
+func (f *FakeIPSetManager) SetApplyError(err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.applyErr = err
+}
+
+func (f *FakeIPSetManager) Reconcile() {
+	f.Lock()
+	defer f.Unlock()
+	for _, set := range f.setMap {
+		f.deleteFromCache(set, util.SoftDelete)
+	}
+}
+
+func (f *FakeIPSetManager) ResetIPSets() error {
+	f.Lock()
+	defer f.Unlock()
+	f.setMap = make(map[string]*IPSet)
+	f.emptySet = nil
+	f.applied = make(map[string][]string)
+	return nil
+}
+
+func (f *FakeIPSetManager) CreateIPSets(setMetadatas []*IPSetMetadata) {
+	f.Lock()
+	defer f.Unlock()
+	for _, setMetadata := range setMetadatas {
+		_ = f.createAndGetIPSet(setMetadata)
+	}
+}
+
+func (f *FakeIPSetManager) createAndGetIPSet(setMetadata *IPSetMetadata) *IPSet {
+	if set, ok := f.setMap[setMetadata.GetPrefixName()]; ok {
+		return set
+	}
+
+	set := NewIPSet(setMetadata)
+	f.setMap[set.Name] = set
+	if f.cfg.AddEmptySetToLists && (set.Type == KeyLabelOfNamespace || set.Type == KeyValueLabelOfNamespace) {
+		if f.emptySet == nil {
+			f.emptySet = NewIPSet(emptySetMetadata)
+			f.setMap[f.emptySet.Name] = f.emptySet
+		}
+		set.MemberIPSets[f.emptySet.Name] = f.emptySet
+		f.emptySet.incIPSetReferCount()
+	}
+	return set
+}
+
+func (f *FakeIPSetManager) DeleteIPSet(name string, deleteOption util.DeleteOption) error {
+	f.Lock()
+	defer f.Unlock()
+	set, ok := f.setMap[name]
+	if !ok {
+		return nil
+	}
+	if !f.deleteFromCache(set, deleteOption) {
+		return setInUseError(set, f.emptySet)
+	}
+	return nil
+}
+
+func (f *FakeIPSetManager) GetIPSetUsage(name string) *IPSetUsage {
+	f.Lock()
+	defer f.Unlock()
+	set, ok := f.setMap[name]
+	if !ok {
+		return nil
+	}
+	return set.usage(f.emptySet)
+}
+
+func (f *FakeIPSetManager) deleteFromCache(set *IPSet, deleteOption util.DeleteOption) bool {
+	if set == f.emptySet {
+		return false
+	}
+	if deleteOption == util.ForceDelete {
+		if !set.canBeForceDeleted() {
+			return false
+		}
+	} else if !set.canBeDeleted(f.emptySet) {
+		return false
+	}
+	delete(f.setMap, set.Name)
+	return true
+}
+
+func (f *FakeIPSetManager) GetIPSet(name string) *IPSet {
+	f.Lock()
+	defer f.Unlock()
+	return f.setMap[name]
+}
+
+func (f *FakeIPSetManager) AddReference(setMetadata *IPSetMetadata, referenceName string, referenceType ReferenceType) error {
+	f.Lock()
+	defer f.Unlock()
+	set := f.createAndGetIPSet(setMetadata)
+	if referenceType == SelectorType && !set.canSetBeSelectorIPSet() {
+		return npmerrors.Errorf(npmerrors.AddSelectorReference, false,
+			fmt.Sprintf("ipset %s is not a selector ipset it is of type %s", set.Name, set.Type.String()))
+	}
+	set.addReference(referenceName, referenceType)
+	return nil
+}
+
+func (f *FakeIPSetManager) DeleteReference(setName, referenceName string, referenceType ReferenceType) error {
+	f.Lock()
+	defer f.Unlock()
+	set, ok := f.setMap[setName]
+	if !ok {
+		npmErrorString := npmerrors.DeleteSelectorReference
+		if referenceType == NetPolType {
+			npmErrorString = npmerrors.DeleteNetPolReference
+		}
+		return npmerrors.Errorf(npmErrorString, false, fmt.Sprintf("ipset %s does not exist", setName))
+	}
+	set.deleteReference(referenceName, referenceType)
+	return nil
+}
+
+func (f *FakeIPSetManager) AddToSets(addToSets []*IPSetMetadata, ip, podKey string) error {
+	return f.addToSets(addToSets, ip, podKey, 0)
+}
+
+func (f *FakeIPSetManager) AddToSetWithTTL(addToSets []*IPSetMetadata, ip, podKey string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return npmerrors.Errorf(npmerrors.AppendIPSet, true, fmt.Sprintf("error: failed to add to sets: invalid ttl %s for ip %s", ttl, ip))
+	}
+	return f.addToSets(addToSets, ip, podKey, ttl)
+}
+
+func (f *FakeIPSetManager) addToSets(addToSets []*IPSetMetadata, ip, podKey string, ttl time.Duration) error {
+	if len(addToSets) == 0 {
+		return nil
+	}
+	if !validateIPSetMemberIP(ip) {
+		return npmerrors.Errorf(npmerrors.AppendIPSet, true, fmt.Sprintf("error: failed to add to sets: invalid ip %s", ip))
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	for _, setMetadata := range addToSets {
+		set := f.createAndGetIPSet(setMetadata)
+		if set.Kind != HashSet {
+			return npmerrors.Errorf(npmerrors.AppendIPSet, false, fmt.Sprintf("ipset %s is not a hash set", set.Name))
+		}
+		_, isMember := set.IPPodKey[ip]
+		_, hadTTL := set.expiryOf(ip)
+		switch {
+		case ttl == 0:
+			set.clearExpiry(ip)
+		case !isMember || hadTTL:
+			set.setExpiry(ip, time.Now().Add(ttl))
+		default:
+			// a permanent member stays permanent
+			continue
+		}
+		set.IPPodKey[ip] = podKey
+	}
+	return nil
+}
+
+func (f *FakeIPSetManager) ExpireMembers(now time.Time) int {
+	f.Lock()
+	defer f.Unlock()
+	numExpired := 0
+	for _, set := range f.setMap {
+		for member, expiry := range set.memberExpiry {
+			if !expiry.After(now) {
+				delete(set.IPPodKey, member)
+				set.clearExpiry(member)
+				numExpired++
+			}
+		}
+	}
+	return numExpired
+}
+
+func (f *FakeIPSetManager) RemoveFromSets(removeFromSets []*IPSetMetadata, ip, podKey string) error {
+	if len(removeFromSets) == 0 {
+		return nil
+	}
+	if !validateIPSetMemberIP(ip) {
+		return npmerrors.Errorf(npmerrors.AppendIPSet, true, fmt.Sprintf("error: failed to add to sets: invalid ip %s", ip))
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	for _, setMetadata := range removeFromSets {
+		set, ok := f.setMap[setMetadata.GetPrefixName()]
+		if !ok {
+			continue
+		}
+		if set.Kind != HashSet {
+			return npmerrors.Errorf(npmerrors.DeleteIPSet, false, fmt.Sprintf("ipset %s is not a hash set", set.Name))
+		}
+		// a delete for another pod key is stale since the IP belongs to a new pod
+		if cachedPodKey, ok := set.IPPodKey[ip]; ok && cachedPodKey == podKey {
+			delete(set.IPPodKey, ip)
+			set.clearExpiry(ip)
+		}
+	}
+	return nil
+}
+
+func (f *FakeIPSetManager) RemoveAllForPodKeyPrefix(podKeyPrefix string) int {
+	f.Lock()
+	defer f.Unlock()
+	numRemoved := 0
+	for _, set := range f.setMap {
+		if set.Kind != HashSet {
+			continue
+		}
+		for member, podKey := range set.IPPodKey {
+			if strings.HasPrefix(podKey, podKeyPrefix) {
+				delete(set.IPPodKey, member)
+				set.clearExpiry(member)
+				numRemoved++
+			}
+		}
+	}
+	return numRemoved
+}
+
+func (f *FakeIPSetManager) AddToLists(listMetadatas, setMetadatas []*IPSetMetadata) error {
+	if len(listMetadatas) == 0 || len(setMetadatas) == 0 {
+		return nil
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	for _, setMetadata := range setMetadatas {
+		if set := f.createAndGetIPSet(setMetadata); set.Kind != HashSet {
+			return npmerrors.Errorf(npmerrors.AppendIPSet, false,
+				fmt.Sprintf("ipset %s is not a hash set and nested list sets are not supported", set.Name))
+		}
+	}
+
+	for _, listMetadata := range listMetadatas {
+		list := f.createAndGetIPSet(listMetadata)
+		if list.Kind != ListSet {
+			return npmerrors.Errorf(npmerrors.AppendIPSet, false, fmt.Sprintf("ipset %s is not a list set", list.Name))
+		}
+		for _, setMetadata := range setMetadatas {
+			memberName := setMetadata.GetPrefixName()
+			if memberName == "" || list.hasMember(memberName) {
+				continue
+			}
+			member := f.setMap[memberName]
+			list.MemberIPSets[memberName] = member
+			member.incIPSetReferCount()
+		}
+	}
+	return nil
+}
+
+func (f *FakeIPSetManager) RemoveFromList(listMetadata *IPSetMetadata, setMetadatas []*IPSetMetadata) error {
+	if len(setMetadatas) == 0 {
+		return nil
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	list, ok := f.setMap[listMetadata.GetPrefixName()]
+	if !ok {
+		return nil
+	}
+	if list.Kind != ListSet {
+		return npmerrors.Errorf(npmerrors.DeleteIPSet, false, fmt.Sprintf("ipset %s is not a list set", list.Name))
+	}
+
+	for _, setMetadata := range setMetadatas {
+		memberName := setMetadata.GetPrefixName()
+		if memberName == "" || (f.cfg.AddEmptySetToLists && memberName == emptySetPrefixName) {
+			continue
+		}
+		member, ok := f.setMap[memberName]
+		if !ok {
+			continue
+		}
+		if member.Kind != HashSet {
+			return npmerrors.Errorf(npmerrors.DeleteIPSet, false,
+				fmt.Sprintf("ipset %s is not a hash set and nested list sets are not supported", memberName))
+		}
+		if list.hasMember(memberName) {
+			delete(list.MemberIPSets, memberName)
+			member.decIPSetReferCount()
+		}
+	}
+	return nil
+}
+
+// ApplyIPSets snapshots the sets which would be in the kernel: every set in ApplyAllIPSets mode, otherwise the
+// sets referenced by policies, the members of those which are lists, and the empty set.
+func (f *FakeIPSetManager) ApplyIPSets() error {
+	f.Lock()
+	defer f.Unlock()
+	f.applyCount++
+	if f.applyErr != nil {
+		return f.applyErr
+	}
+
+	inKernel := make(map[string]*IPSet)
+	for _, set := range f.setMap {
+		if f.cfg.IPSetMode != ApplyAllIPSets && !set.usedByNetPol() && set != f.emptySet {
+			continue
+		}
+		inKernel[set.Name] = set
+		for _, member := range set.MemberIPSets {
+			inKernel[member.Name] = member
+		}
+	}
+
+	f.applied = make(map[string][]string, len(inKernel))
+	for _, set := range inKernel {
+		members := make([]string, 0, len(set.IPPodKey)+len(set.MemberIPSets))
+		for ip := range set.IPPodKey {
+			members = append(members, ip)
+		}
+		for memberName := range set.MemberIPSets {
+			members = append(members, memberName)
+		}
+		sort.Strings(members)
+		f.applied[set.Name] = members
+	}
+	return nil
+}
+
+func (f *FakeIPSetManager) GetAllIPSets() map[string]string {
+	f.Lock()
+	defer f.Unlock()
+	setMap := make(map[string]string, len(f.setMap))
+	for _, set := range f.setMap {
+		setMap[set.HashedName] = set.Name
+	}
+	return setMap
+}
+
+func (f *FakeIPSetManager) Snapshot() *Snapshot {
+	f.Lock()
+	defer f.Unlock()
+	return snapshotSets(f.setMap, f.emptySet, time.Now())
+}
+
+func (f *FakeIPSetManager) Restore(snapshot *Snapshot) error {
+	f.Lock()
+	numSets := len(f.setMap)
+	f.Unlock()
+	if numSets > 0 {
+		return fmt.Errorf("%w: %d sets", ErrCacheNotEmpty, numSets)
+	}
+	return restoreSnapshot(f, snapshot, time.Now())
+}
@@ -0,0 +1,47 @@
+package ipsets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeIPSetManagerAppliedSets(t *testing.T) {
+	f := NewFakeIPSetManager(applyOnNeedCfg)
+
+	require.NoError(t, f.AddToSets([]*IPSetMetadata{TestNSSet.Metadata, TestKVPodSet.Metadata}, testPodIP, testPodKey))
+	require.NoError(t, f.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata}))
+	require.NoError(t, f.AddReference(TestKeyNSList.Metadata, testNetPolKey, NetPolType))
+	require.Empty(t, f.AppliedSets(), "nothing is applied before ApplyIPSets")
+
+	// only the referenced list and its members are applied on need
+	require.NoError(t, f.ApplyIPSets())
+	require.Equal(t, map[string][]string{
+		TestKeyNSList.PrefixName: {TestNSSet.PrefixName},
+		TestNSSet.PrefixName:     {testPodIP},
+	}, f.AppliedSets())
+
+	// the last successful apply is kept
+	errApply := errors.New("apply failed")
+	f.SetApplyError(errApply)
+	require.NoError(t, f.DeleteReference(TestKeyNSList.PrefixName, testNetPolKey, NetPolType))
+	require.ErrorIs(t, f.ApplyIPSets(), errApply)
+	require.Len(t, f.AppliedSets(), 2)
+
+	f.SetApplyError(nil)
+	require.NoError(t, f.ApplyIPSets())
+	require.Empty(t, f.AppliedSets())
+	require.Equal(t, 3, f.ApplyCount())
+}
+
+func TestFakeIPSetManagerApplyAll(t *testing.T) {
+	f := NewFakeIPSetManager(applyAlwaysCfg)
+	f.CreateIPSets([]*IPSetMetadata{TestNSSet.Metadata, TestKeyNSList.Metadata})
+
+	require.NoError(t, f.ApplyIPSets())
+	require.Equal(t, map[string][]string{
+		TestKeyNSList.PrefixName: {},
+		TestNSSet.PrefixName:     {},
+	}, f.AppliedSets())
+}
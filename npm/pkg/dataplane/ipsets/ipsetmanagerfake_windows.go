@@ -0,0 +1,19 @@
+package ipsets
+
+func (f *FakeIPSetManager) DoesIPSatisfySelectorIPSets(ip, podKey string, setList map[string]struct{}) (bool, error) {
+	f.Lock()
+	defer f.Unlock()
+	return doesIPSatisfySelectorIPSets(f.setMap, ip, podKey, setList)
+}
+
+func (f *FakeIPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{}) (map[string]string, error) {
+	f.Lock()
+	defer f.Unlock()
+	return getIPsFromSelectorIPSets(f.setMap, setList)
+}
+
+func (f *FakeIPSetManager) GetSelectorReferencesBySet(setName string) (map[string]struct{}, error) {
+	f.Lock()
+	defer f.Unlock()
+	return getSelectorReferencesBySet(f.setMap, setName)
+}
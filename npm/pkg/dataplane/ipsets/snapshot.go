@@ -0,0 +1,161 @@
+package ipsets
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SnapshotVersion is the version of the Snapshot format which this NPM writes and restores.
+const SnapshotVersion = 1
+
+var (
+	// ErrSnapshotVersion is returned when restoring a snapshot of another format version
+	ErrSnapshotVersion = errors.New("unsupported ipset snapshot version")
+	// ErrCacheNotEmpty is returned when restoring a snapshot into a Manager which already has sets
+	ErrCacheNotEmpty = errors.New("ipset cache is not empty")
+)
+
+// Snapshot is the cache of a Manager in a versioned format, which can be restored into another Manager, e.g. by a
+// restarted NPM or by NPM with another dataplane backend.
+type Snapshot struct {
+	Version int           `json:"version"`
+	Taken   time.Time     `json:"taken"`
+	Sets    []SetSnapshot `json:"sets"`
+}
+
+// SetSnapshot is a set of a Snapshot. The empty set of lists isn't in snapshots, managers which need it add it back.
+type SetSnapshot struct {
+	Name string  `json:"name"`
+	Type SetType `json:"type"`
+	// Members are the IPs of a hash set with the key of the pod which owns them
+	Members map[string]string `json:"members,omitempty"`
+	// Expiries are the expiry times of the members of a hash set which were added with a TTL
+	Expiries map[string]time.Time `json:"expiries,omitempty"`
+	// MemberSets are the member sets of a list
+	MemberSets []IPSetMetadata `json:"memberSets,omitempty"`
+	// SelectorReferences and NetPolReferences are the network policies which reference the set
+	SelectorReferences []string `json:"selectorReferences,omitempty"`
+	NetPolReferences   []string `json:"netPolReferences,omitempty"`
+}
+
+// snapshotSets returns the snapshot of the sets, sorted by name. The caller must hold the lock of the sets.
+func snapshotSets(setMap map[string]*IPSet, emptySet *IPSet, now time.Time) *Snapshot {
+	snapshot := &Snapshot{
+		Version: SnapshotVersion,
+		Taken:   now,
+		Sets:    make([]SetSnapshot, 0, len(setMap)),
+	}
+	for _, set := range setMap {
+		if set == emptySet {
+			continue
+		}
+		setSnapshot := SetSnapshot{
+			Name:               set.unprefixedName,
+			Type:               set.Type,
+			SelectorReferences: sortedKeys(set.SelectorReference),
+			NetPolReferences:   sortedKeys(set.NetPolReference),
+		}
+		if len(set.IPPodKey) > 0 {
+			setSnapshot.Members = make(map[string]string, len(set.IPPodKey))
+			for ip, podKey := range set.IPPodKey {
+				setSnapshot.Members[ip] = podKey
+			}
+		}
+		if len(set.memberExpiry) > 0 {
+			setSnapshot.Expiries = make(map[string]time.Time, len(set.memberExpiry))
+			for ip, expiry := range set.memberExpiry {
+				setSnapshot.Expiries[ip] = expiry
+			}
+		}
+		for _, member := range set.MemberIPSets {
+			if member != emptySet {
+				setSnapshot.MemberSets = append(setSnapshot.MemberSets, *member.GetSetMetadata())
+			}
+		}
+		sort.Slice(setSnapshot.MemberSets, func(i, j int) bool {
+			return setSnapshot.MemberSets[i].GetPrefixName() < setSnapshot.MemberSets[j].GetPrefixName()
+		})
+		snapshot.Sets = append(snapshot.Sets, setSnapshot)
+	}
+	sort.Slice(snapshot.Sets, func(i, j int) bool {
+		return snapshot.Sets[i].prefixName() < snapshot.Sets[j].prefixName()
+	})
+	return snapshot
+}
+
+func (s *SetSnapshot) prefixName() string {
+	return NewIPSetMetadata(s.Name, s.Type).GetPrefixName()
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/*
+restoreSnapshot replays the snapshot on the Manager, so that the sets, members, lists and references go through the
+same bookkeeping as the updates of the controllers: the sets are marked dirty and reach the dataplane on the next
+ApplyIPSets, and later updates of the controllers, such as the removal of a pod's IP, apply to the restored cache.
+Members whose TTL passed before now are skipped.
+*/
+func restoreSnapshot(m Manager, snapshot *Snapshot, now time.Time) error {
+	if snapshot.Version != SnapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, snapshot.Version)
+	}
+
+	// 1. sets and the members of hash sets
+	for i := range snapshot.Sets {
+		setSnapshot := &snapshot.Sets[i]
+		metadata := NewIPSetMetadata(setSnapshot.Name, setSnapshot.Type)
+		m.CreateIPSets([]*IPSetMetadata{metadata})
+		for ip, podKey := range setSnapshot.Members {
+			var err error
+			if expiry, ok := setSnapshot.Expiries[ip]; ok {
+				if !expiry.After(now) {
+					continue
+				}
+				err = m.AddToSetWithTTL([]*IPSetMetadata{metadata}, ip, podKey, expiry.Sub(now))
+			} else {
+				err = m.AddToSets([]*IPSetMetadata{metadata}, ip, podKey)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to restore member %s of set %s: %w", ip, metadata.GetPrefixName(), err)
+			}
+		}
+	}
+
+	// 2. members of lists and references, once all sets exist
+	for i := range snapshot.Sets {
+		setSnapshot := &snapshot.Sets[i]
+		metadata := NewIPSetMetadata(setSnapshot.Name, setSnapshot.Type)
+		if len(setSnapshot.MemberSets) > 0 {
+			members := make([]*IPSetMetadata, len(setSnapshot.MemberSets))
+			for j := range setSnapshot.MemberSets {
+				members[j] = &setSnapshot.MemberSets[j]
+			}
+			if err := m.AddToLists([]*IPSetMetadata{metadata}, members); err != nil {
+				return fmt.Errorf("failed to restore members of list %s: %w", metadata.GetPrefixName(), err)
+			}
+		}
+		for _, policyKey := range setSnapshot.SelectorReferences {
+			if err := m.AddReference(metadata, policyKey, SelectorType); err != nil {
+				return fmt.Errorf("failed to restore selector reference of set %s: %w", metadata.GetPrefixName(), err)
+			}
+		}
+		for _, policyKey := range setSnapshot.NetPolReferences {
+			if err := m.AddReference(metadata, policyKey, NetPolType); err != nil {
+				return fmt.Errorf("failed to restore netpol reference of set %s: %w", metadata.GetPrefixName(), err)
+			}
+		}
+	}
+	return nil
+}
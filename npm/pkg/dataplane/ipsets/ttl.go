@@ -0,0 +1,101 @@
+package ipsets
+
+import "time"
+
+const (
+	// MemberTTLTick is the granularity of member TTLs. The dataplane expires members this often.
+	MemberTTLTick = time.Second
+	// numWheelSlots is the number of ticks in one turn of the timer wheel
+	numWheelSlots = 64
+)
+
+// memberKey identifies a member of a hash set by the prefixed set name and the member (IP or IP-port pair)
+type memberKey struct {
+	setName string
+	member  string
+}
+
+/*
+timerWheel schedules the expiry of set members with a TTL.
+Each slot holds the members due in the ticks which map to the slot, so advancing the wheel only visits the slots
+of the elapsed ticks instead of every member with a TTL. Members due after more than one turn stay in their slot
+until their tick comes around.
+
+The wheel only holds hints: the expiry time in the set is the source of truth. So refreshing or removing a member
+doesn't have to unschedule it, and the caller must check the set before expiring a member returned by advance().
+
+In Linux, the kernel also expires the members if the sets have timeouts (see IPSetManagerCfg.UseKernelTimeouts).
+In Windows, HNS SetPolicies have no timeouts, so the wheel is the only way that members expire.
+*/
+type timerWheel struct {
+	tick  time.Duration
+	slots []map[memberKey]int64
+	// current is the last tick which the wheel advanced to
+	current int64
+	started bool
+}
+
+func newTimerWheel(tick time.Duration, numSlots int) *timerWheel {
+	slots := make([]map[memberKey]int64, numSlots)
+	for i := range slots {
+		slots[i] = make(map[memberKey]int64)
+	}
+	return &timerWheel{tick: tick, slots: slots}
+}
+
+func (w *timerWheel) tickOf(t time.Time) int64 {
+	return t.UnixNano() / int64(w.tick)
+}
+
+func (w *timerWheel) start(now time.Time) {
+	if !w.started {
+		w.current = w.tickOf(now)
+		w.started = true
+	}
+}
+
+// schedule makes the member due at the first tick at or after its expiry
+func (w *timerWheel) schedule(key memberKey, now, expiry time.Time) {
+	w.start(now)
+	due := w.tickOf(expiry)
+	if expiry.UnixNano()%int64(w.tick) != 0 {
+		due++
+	}
+	if due <= w.current {
+		due = w.current + 1
+	}
+	w.slots[due%int64(len(w.slots))][key] = due
+}
+
+// advance moves the wheel to now and returns the members which are due
+func (w *timerWheel) advance(now time.Time) []memberKey {
+	w.start(now)
+	target := w.tickOf(now)
+	numTicks := target - w.current
+	if numTicks > int64(len(w.slots)) {
+		// every slot is visited once per turn
+		numTicks = int64(len(w.slots))
+	}
+
+	var due []memberKey
+	for i := int64(1); i <= numTicks; i++ {
+		slot := w.slots[(w.current+i)%int64(len(w.slots))]
+		for key, dueTick := range slot {
+			if dueTick <= target {
+				due = append(due, key)
+				delete(slot, key)
+			}
+		}
+	}
+	if target > w.current {
+		w.current = target
+	}
+	return due
+}
+
+func (w *timerWheel) reset() {
+	for i := range w.slots {
+		w.slots[i] = make(map[memberKey]int64)
+	}
+	w.started = false
+}
@@ -0,0 +1,32 @@
+package ipsets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimerWheel(t *testing.T) {
+	w := newTimerWheel(time.Second, 4)
+	start := time.Unix(100, 0)
+	a := memberKey{setName: "set", member: "10.0.0.1"}
+	b := memberKey{setName: "set", member: "10.0.0.2"}
+	c := memberKey{setName: "set", member: "10.0.0.3"}
+
+	w.schedule(a, start, start.Add(1500*time.Millisecond))
+	// due after more than one turn
+	w.schedule(b, start, start.Add(10*time.Second))
+	// already due
+	w.schedule(c, start, start.Add(-time.Second))
+
+	require.Equal(t, []memberKey{c}, w.advance(start.Add(time.Second)))
+	require.Equal(t, []memberKey{a}, w.advance(start.Add(2*time.Second)))
+	require.Empty(t, w.advance(start.Add(9*time.Second)))
+	require.Equal(t, []memberKey{b}, w.advance(start.Add(time.Minute)), "a long pause visits every slot")
+	require.Empty(t, w.advance(start.Add(2*time.Minute)))
+
+	w.schedule(a, start.Add(2*time.Minute), start.Add(3*time.Minute))
+	w.reset()
+	require.Empty(t, w.advance(start.Add(time.Hour)))
+}
@@ -0,0 +1,61 @@
+package dataplane
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"k8s.io/klog"
+)
+
+// RestoredMemberTTL is how long the members of a restored snapshot stay in the sets unless the controllers add them
+// again. The controllers add the members of the pods which still exist when they sync, which makes them permanent,
+// so that the members of pods deleted while NPM was down don't stay in the sets.
+const RestoredMemberTTL = 5 * time.Minute
+
+// SnapshotIPSets returns the snapshot of the ipset cache.
+func (dp *DataPlane) SnapshotIPSets() *ipsets.Snapshot {
+	return dp.ipsetMgr.Snapshot()
+}
+
+// RestoreIPSets seeds the empty ipset cache with a snapshot, e.g. taken before a restart or by NPM with another
+// dataplane backend, and applies the sets so that they are in the dataplane before the controllers sync. The
+// permanent members of the snapshot are restored with RestoredMemberTTL.
+func (dp *DataPlane) RestoreIPSets(snapshot *ipsets.Snapshot) error {
+	expiry := time.Now().Add(RestoredMemberTTL)
+	for i := range snapshot.Sets {
+		set := &snapshot.Sets[i]
+		for ip := range set.Members {
+			if _, ok := set.Expiries[ip]; ok {
+				continue
+			}
+			if set.Expiries == nil {
+				set.Expiries = make(map[string]time.Time, len(set.Members))
+			}
+			set.Expiries[ip] = expiry
+		}
+	}
+
+	if err := dp.ipsetMgr.Restore(snapshot); err != nil {
+		return fmt.Errorf("[DataPlane] failed to restore ipsets: %w", err)
+	}
+	if err := dp.ipsetMgr.ApplyIPSets(); err != nil {
+		return fmt.Errorf("[DataPlane] failed to apply restored ipsets: %w", err)
+	}
+	klog.Infof("[DataPlane] restored %d ipsets", len(snapshot.Sets))
+	return nil
+}
+
+// IPSetSnapshotEncoder serves the snapshot of the ipset cache on the debug API.
+func (dp *DataPlane) IPSetSnapshotEncoder() json.Marshaler {
+	return ipsetSnapshotEncoder{dp: dp}
+}
+
+type ipsetSnapshotEncoder struct {
+	dp *DataPlane
+}
+
+func (e ipsetSnapshotEncoder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.dp.SnapshotIPSets()) //nolint:wrapcheck // the snapshot is plain data
+}
@@ -0,0 +1,78 @@
+package dataplane
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// PodLabelDelta is the label sets which a pod leaves and joins when its labels change.
+// The sets of the labels which didn't change are in neither list.
+type PodLabelDelta struct {
+	SetsToRemove []*ipsets.IPSetMetadata
+	SetsToAdd    []*ipsets.IPSetMetadata
+}
+
+// IsEmpty returns true if the pod stays in the same sets.
+func (delta *PodLabelDelta) IsEmpty() bool {
+	return len(delta.SetsToRemove) == 0 && len(delta.SetsToAdd) == 0
+}
+
+// ComputePodLabelDelta returns the label sets which a pod leaves and joins when its labels change from the old ones to
+// the new ones. A removed or added label changes both its key set and its key-value set, while a changed value only
+// moves the pod between key-value sets. Each list is sorted by set name.
+func ComputePodLabelDelta(oldLabels, newLabels map[string]string) *PodLabelDelta {
+	delta := &PodLabelDelta{
+		SetsToRemove: []*ipsets.IPSetMetadata{},
+		SetsToAdd:    []*ipsets.IPSetMetadata{},
+	}
+	for key, oldValue := range oldLabels {
+		newValue, ok := newLabels[key]
+		if ok && newValue == oldValue {
+			continue
+		}
+		if !ok {
+			delta.SetsToRemove = append(delta.SetsToRemove, ipsets.NewIPSetMetadata(key, ipsets.KeyLabelOfPod))
+		}
+		delta.SetsToRemove = append(delta.SetsToRemove,
+			ipsets.NewIPSetMetadata(util.GetIpSetFromLabelKV(key, oldValue), ipsets.KeyValueLabelOfPod))
+	}
+	for key, newValue := range newLabels {
+		oldValue, ok := oldLabels[key]
+		if ok && newValue == oldValue {
+			continue
+		}
+		if !ok {
+			delta.SetsToAdd = append(delta.SetsToAdd, ipsets.NewIPSetMetadata(key, ipsets.KeyLabelOfPod))
+		}
+		delta.SetsToAdd = append(delta.SetsToAdd,
+			ipsets.NewIPSetMetadata(util.GetIpSetFromLabelKV(key, newValue), ipsets.KeyValueLabelOfPod))
+	}
+	sortSetsByName(delta.SetsToRemove)
+	sortSetsByName(delta.SetsToAdd)
+	return delta
+}
+
+func sortSetsByName(sets []*ipsets.IPSetMetadata) {
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].GetPrefixName() < sets[j].GetPrefixName()
+	})
+}
+
+// UpdatePodLabels moves the pod between the sets of the delta, leaving it in the sets of its unchanged labels.
+// Removing and adding are idempotent, so the same delta can be retried after an error.
+func (dp *DataPlane) UpdatePodLabels(podMetadata *PodMetadata, delta *PodLabelDelta) error {
+	if len(delta.SetsToRemove) > 0 {
+		if err := dp.RemoveFromSets(delta.SetsToRemove, podMetadata); err != nil {
+			return fmt.Errorf("[DataPlane] error while updating pod labels: %w", err)
+		}
+	}
+	if len(delta.SetsToAdd) > 0 {
+		if err := dp.AddToSets(delta.SetsToAdd, podMetadata); err != nil {
+			return fmt.Errorf("[DataPlane] error while updating pod labels: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package dataplane
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputePodLabelDelta(t *testing.T) {
+	key := func(name string) *ipsets.IPSetMetadata {
+		return ipsets.NewIPSetMetadata(name, ipsets.KeyLabelOfPod)
+	}
+	keyValue := func(name string) *ipsets.IPSetMetadata {
+		return ipsets.NewIPSetMetadata(name, ipsets.KeyValueLabelOfPod)
+	}
+
+	tests := []struct {
+		name           string
+		oldLabels      map[string]string
+		newLabels      map[string]string
+		expectedRemove []*ipsets.IPSetMetadata
+		expectedAdd    []*ipsets.IPSetMetadata
+	}{
+		{
+			name:           "no change",
+			oldLabels:      map[string]string{"app": "a", "tier": "web"},
+			newLabels:      map[string]string{"app": "a", "tier": "web"},
+			expectedRemove: []*ipsets.IPSetMetadata{},
+			expectedAdd:    []*ipsets.IPSetMetadata{},
+		},
+		{
+			name:           "changed value keeps the key set",
+			oldLabels:      map[string]string{"app": "a", "tier": "web"},
+			newLabels:      map[string]string{"app": "b", "tier": "web"},
+			expectedRemove: []*ipsets.IPSetMetadata{keyValue("app:a")},
+			expectedAdd:    []*ipsets.IPSetMetadata{keyValue("app:b")},
+		},
+		{
+			name:           "removed and added labels",
+			oldLabels:      map[string]string{"app": "a", "canary": "true"},
+			newLabels:      map[string]string{"app": "a", "version": "v2"},
+			expectedRemove: []*ipsets.IPSetMetadata{key("canary"), keyValue("canary:true")},
+			expectedAdd:    []*ipsets.IPSetMetadata{key("version"), keyValue("version:v2")},
+		},
+		{
+			name:           "from no labels",
+			oldLabels:      nil,
+			newLabels:      map[string]string{"tier": "web", "app": "a"},
+			expectedRemove: []*ipsets.IPSetMetadata{},
+			expectedAdd:    []*ipsets.IPSetMetadata{key("app"), keyValue("app:a"), key("tier"), keyValue("tier:web")},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			delta := ComputePodLabelDelta(tt.oldLabels, tt.newLabels)
+			require.Equal(t, tt.expectedRemove, delta.SetsToRemove)
+			require.Equal(t, tt.expectedAdd, delta.SetsToAdd)
+			require.Equal(t, len(tt.expectedRemove)+len(tt.expectedAdd) == 0, delta.IsEmpty())
+		})
+	}
+}
+
+func TestInMemoryDataplaneUpdatePodLabels(t *testing.T) {
+	dp := NewInMemoryDataplane(dpCfg)
+	podMetadata := NewPodMetadata("testns/a", "10.0.0.1", nodeName)
+	oldLabels := map[string]string{"app": "a", "tier": "web"}
+	newLabels := map[string]string{"app": "b", "tier": "web"}
+	require.NoError(t, dp.UpdatePodLabels(podMetadata, ComputePodLabelDelta(nil, oldLabels)))
+
+	delta := ComputePodLabelDelta(oldLabels, newLabels)
+	require.NoError(t, dp.UpdatePodLabels(podMetadata, delta))
+	require.NoError(t, dp.UpdatePodLabels(podMetadata, delta), "the delta can be retried")
+
+	for _, set := range []*ipsets.IPSetMetadata{
+		ipsets.NewIPSetMetadata("app", ipsets.KeyLabelOfPod),
+		ipsets.NewIPSetMetadata("app:b", ipsets.KeyValueLabelOfPod),
+		ipsets.NewIPSetMetadata("tier:web", ipsets.KeyValueLabelOfPod),
+	} {
+		require.Contains(t, dp.GetIPSet(set.GetPrefixName()).IPPodKey, "10.0.0.1", set.GetPrefixName())
+	}
+	require.Empty(t, dp.GetIPSet(ipsets.NewIPSetMetadata("app:a", ipsets.KeyValueLabelOfPod).GetPrefixName()).IPPodKey)
+}
@@ -0,0 +1,36 @@
+package dataplane
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+)
+
+// meshCompatibilityReporter is implemented by the policy managers which can order their chains relative to the
+// chains of service meshes, i.e. the Linux PolicyManager.
+type meshCompatibilityReporter interface {
+	MeshCompatibilityReport() (*policies.MeshCompatibilityReport, error)
+}
+
+// MeshCompatibilityEncoder serves the mesh compatibility report on the debug API. It returns nil if the policy
+// manager has no report, e.g. in Windows.
+func (dp *DataPlane) MeshCompatibilityEncoder() json.Marshaler {
+	reporter, ok := dp.policyMgr.(meshCompatibilityReporter)
+	if !ok {
+		return nil
+	}
+	return meshCompatibilityEncoder{reporter: reporter}
+}
+
+type meshCompatibilityEncoder struct {
+	reporter meshCompatibilityReporter
+}
+
+func (e meshCompatibilityEncoder) MarshalJSON() ([]byte, error) {
+	report, err := e.reporter.MeshCompatibilityReport()
+	if err != nil {
+		return nil, fmt.Errorf("[DataPlane] failed to get mesh compatibility report: %w", err)
+	}
+	return json.Marshal(report) //nolint:wrapcheck // the report is plain data
+}
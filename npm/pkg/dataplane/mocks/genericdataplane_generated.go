@@ -123,9 +123,11 @@ func (mr *MockGenericDataplaneMockRecorder) CreateIPSets(setMetadatas interface{
 }
 
 // DeleteIPSet mocks base method.
-func (m *MockGenericDataplane) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, deleteOption util.DeleteOption) {
+func (m *MockGenericDataplane) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, deleteOption util.DeleteOption) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "DeleteIPSet", setMetadata, deleteOption)
+	ret := m.ctrl.Call(m, "DeleteIPSet", setMetadata, deleteOption)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // DeleteIPSet indicates an expected call of DeleteIPSet.
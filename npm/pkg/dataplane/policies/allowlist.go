@@ -0,0 +1,57 @@
+package policies
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	ErrInvalidAllowlistCIDR     = errors.New("invalid allowlist CIDR")
+	ErrInvalidAllowlistProtocol = errors.New("allowlist protocol must be TCP, UDP, or SCTP")
+	ErrAllowlistPortNoProtocol  = errors.New("allowlist port requires a protocol")
+)
+
+// AllowlistEntry exempts the traffic between pods and a CIDR from all network policies, such as the traffic to the
+// API server or from the kubelet's health probes. The Port is the destination port in both directions.
+// The PolicyManager programs the allowlist ahead of and separately from the network policies.
+type AllowlistEntry struct {
+	CIDR     string
+	Protocol Protocol
+	// Port is only matched when non-zero
+	Port int32
+}
+
+// NewAllowlistEntry returns a validated entry. An IP without a prefix length is treated as a single address.
+func NewAllowlistEntry(cidr string, protocol Protocol, port int32) (*AllowlistEntry, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAllowlistCIDR, cidr)
+		}
+		if ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAllowlistCIDR, cidr)
+	}
+
+	if protocol == "" {
+		protocol = UnspecifiedProtocol
+	}
+	switch protocol {
+	case TCP, UDP, SCTP:
+	case UnspecifiedProtocol:
+		if port != 0 {
+			return nil, fmt.Errorf("%w: %s port %d", ErrAllowlistPortNoProtocol, cidr, port)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAllowlistProtocol, protocol)
+	}
+
+	return &AllowlistEntry{CIDR: cidr, Protocol: protocol, Port: port}, nil
+}
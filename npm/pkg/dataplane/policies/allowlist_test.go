@@ -0,0 +1,30 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAllowlistEntry(t *testing.T) {
+	entry, err := NewAllowlistEntry("10.0.0.4", TCP, 443)
+	require.NoError(t, err)
+	require.Equal(t, &AllowlistEntry{CIDR: "10.0.0.4/32", Protocol: TCP, Port: 443}, entry)
+
+	entry, err = NewAllowlistEntry("fd00::1", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, &AllowlistEntry{CIDR: "fd00::1/128", Protocol: UnspecifiedProtocol}, entry)
+
+	entry, err = NewAllowlistEntry("10.224.0.0/16", UDP, 0)
+	require.NoError(t, err)
+	require.Equal(t, &AllowlistEntry{CIDR: "10.224.0.0/16", Protocol: UDP}, entry)
+
+	_, err = NewAllowlistEntry("10.224.0.0/33", "", 0)
+	require.ErrorIs(t, err, ErrInvalidAllowlistCIDR)
+	_, err = NewAllowlistEntry("apiserver", TCP, 443)
+	require.ErrorIs(t, err, ErrInvalidAllowlistCIDR)
+	_, err = NewAllowlistEntry("10.0.0.4", "ICMP", 0)
+	require.ErrorIs(t, err, ErrInvalidAllowlistProtocol)
+	_, err = NewAllowlistEntry("10.0.0.4", "", 443)
+	require.ErrorIs(t, err, ErrAllowlistPortNoProtocol)
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/deniedflows"
 	"github.com/Azure/azure-container-networking/npm/util"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
 	"github.com/Azure/azure-container-networking/npm/util/ioutil"
@@ -318,6 +319,12 @@ func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *
 	}
 
 	// add AZURE-NPM-INGRESS chain rules
+	if pMgr.LogDenied {
+		creator.AddLine("", nil, pMgr.logDeniedSpecs(util.IptablesAzureIngressChain, util.IptablesAzureIngressDropMarkHex, deniedflows.IngressPrefix, "INGRESS")...)
+	}
+	for _, specs := range rejectSpecs(util.IptablesAzureIngressChain, pMgr.ingressRejectMark(), "INGRESS") {
+		creator.AddLine("", nil, specs...)
+	}
 	ingressDropSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureIngressChain, util.IptablesJumpFlag, util.IptablesDrop}
 	ingressDropSpecs = append(ingressDropSpecs, onMarkSpecs(util.IptablesAzureIngressDropMarkHex)...)
 	ingressDropSpecs = append(ingressDropSpecs, commentSpecs(fmt.Sprintf("DROP-ON-INGRESS-DROP-MARK-%s", util.IptablesAzureIngressDropMarkHex))...)
@@ -331,6 +338,12 @@ func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *
 	creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureIngressAllowMarkChain, util.IptablesJumpFlag, util.IptablesAzureEgressChain)
 
 	// add AZURE-NPM-EGRESS chain rules
+	if pMgr.LogDenied {
+		creator.AddLine("", nil, pMgr.logDeniedSpecs(util.IptablesAzureEgressChain, util.IptablesAzureEgressDropMarkHex, deniedflows.EgressPrefix, "EGRESS")...)
+	}
+	for _, specs := range rejectSpecs(util.IptablesAzureEgressChain, pMgr.egressRejectMark(), "EGRESS") {
+		creator.AddLine("", nil, specs...)
+	}
 	egressDropSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureEgressChain, util.IptablesJumpFlag, util.IptablesDrop}
 	egressDropSpecs = append(egressDropSpecs, onMarkSpecs(util.IptablesAzureEgressDropMarkHex)...)
 	egressDropSpecs = append(egressDropSpecs, commentSpecs(fmt.Sprintf("DROP-ON-EGRESS-DROP-MARK-%s", util.IptablesAzureEgressDropMarkHex))...)
@@ -350,6 +363,7 @@ func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *
 // add/reposition the jump from FORWARD chain to AZURE-NPM chain to be in the correct position based on config:
 // option 1) jump to AZURE-NPM chain should be the first rule
 // option 2) jump to AZURE-NPM chain should be after the jump to KUBE-SERVICES chain
+// in mesh compatibility mode, the jump to AZURE-NPM chain is also placed after the jumps to the chains of service meshes
 func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 	// get the line number for the azure jump
 	azureChainLineNum, err := pMgr.chainLineNumber(util.IptablesAzureChain)
@@ -359,7 +373,7 @@ func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 		return npmerrors.SimpleErrorWrapper(baseErrString, err)
 	}
 
-	if pMgr.PlaceAzureChainFirst == util.PlaceAzureChainFirst && azureChainLineNum == 1 {
+	if pMgr.PlaceAzureChainFirst == util.PlaceAzureChainFirst && azureChainLineNum == 1 && !pMgr.MeshCompatibility {
 		// the azure jump is in the right position, so we're done
 		return nil
 	}
@@ -381,6 +395,20 @@ func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 		}
 	}
 
+	if pMgr.MeshCompatibility {
+		meshChainLineNum, err := pMgr.lastMeshChainLineNumber()
+		if err != nil {
+			baseErrString := "failed to get index of jumps from FORWARD chain to service mesh chains"
+			metrics.SendErrorLogAndMetric(util.IptmID, "error: %s: %s", baseErrString, err.Error())
+			return npmerrors.SimpleErrorWrapper(baseErrString, err)
+		}
+
+		if meshChainLineNum >= targetIndex {
+			// the azure jump should be immediately after the last mesh jump
+			targetIndex = meshChainLineNum + 1
+		}
+	}
+
 	if azureChainLineNum == targetIndex {
 		// the azure jump is in the right position, so we're done
 		return nil
@@ -396,9 +424,9 @@ func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 		}
 
 		if azureChainLineNum < targetIndex {
-			// this means kube jump existed and was below the deleted azure jump, so decrement the target index
-			// this can only occur if PlaceAzureChainFirst == PlaceAfterKube
-			// this logic depends on targetIndex being 1 or kubeChainLineNum + 1
+			// this means the kube jump or a mesh jump existed and was below the deleted azure jump, so decrement the target index
+			// this can only occur if PlaceAzureChainFirst == PlaceAfterKube or in mesh compatibility mode
+			// this logic depends on targetIndex being 1, kubeChainLineNum + 1, or meshChainLineNum + 1
 			targetIndex--
 		}
 	}
@@ -447,6 +475,50 @@ func (pMgr *PolicyManager) chainLineNumber(chain string) (int, error) {
 	return 0, npmerrors.SimpleErrorWrapper(fmt.Sprintf("unable to parse line number. searchResults: [%s]", string(searchResults)), errUnexpectedLineNumberString)
 }
 
+// rejectSpecs returns the rules of a base chain which reject the traffic on the mark: TCP with a reset and the rest
+// with an ICMP admin-prohibited error. They come before the rule which drops on the drop mark.
+func rejectSpecs(chain, mark, direction string) [][]string {
+	comment := commentSpecs(fmt.Sprintf("REJECT-ON-%s-REJECT-MARK-%s", direction, mark))
+	tcpSpecs := []string{util.IptablesAppendFlag, chain, util.IptablesProtFlag, string(TCP), util.IptablesJumpFlag, util.IptablesReject, util.IptablesRejectWithFlag, util.IptablesTCPReset}
+	tcpSpecs = append(tcpSpecs, onMarkSpecs(mark)...)
+	tcpSpecs = append(tcpSpecs, comment...)
+	otherSpecs := []string{util.IptablesAppendFlag, chain, util.IptablesJumpFlag, util.IptablesReject, util.IptablesRejectWithFlag, util.IptablesICMPAdminProhib}
+	otherSpecs = append(otherSpecs, onMarkSpecs(mark)...)
+	otherSpecs = append(otherSpecs, comment...)
+	return [][]string{tcpSpecs, otherSpecs}
+}
+
+// logDeniedSpecs logs the packets with the drop mark, which also matches the packets to reject, before they're
+// rejected or dropped
+func (pMgr *PolicyManager) logDeniedSpecs(chain, mark, prefix, direction string) []string {
+	specs := []string{
+		util.IptablesAppendFlag, chain, util.IptablesJumpFlag, util.IptablesNFLOG,
+		util.IptablesNFLOGGroupFlag, strconv.Itoa(int(pMgr.LogDeniedGroup)),
+		util.IptablesNFLOGPrefixFlag, prefix,
+		util.IptablesNFLOGRangeFlag, strconv.Itoa(deniedflows.CopyRange),
+	}
+	specs = append(specs, onMarkSpecs(mark)...)
+	if pMgr.LogDeniedPerSecond > 0 {
+		specs = append(specs, util.IptablesModuleFlag, util.IptablesLimitModuleFlag, util.IptablesLimitFlag, fmt.Sprintf("%d/second", pMgr.LogDeniedPerSecond))
+	}
+	return append(specs, commentSpecs(fmt.Sprintf("LOG-ON-%s-DROP-MARK-%s", direction, mark))...)
+}
+
+// when all denied traffic is rejected, the drop marks are the reject marks
+func (pMgr *PolicyManager) ingressRejectMark() string {
+	if pMgr.RejectDenied {
+		return util.IptablesAzureIngressDropMarkHex
+	}
+	return util.IptablesAzureIngressRejectMarkHex
+}
+
+func (pMgr *PolicyManager) egressRejectMark() string {
+	if pMgr.RejectDenied {
+		return util.IptablesAzureEgressDropMarkHex
+	}
+	return util.IptablesAzureEgressRejectMarkHex
+}
+
 func onMarkSpecs(mark string) []string {
 	return []string{
 		util.IptablesModuleFlag,
@@ -213,9 +213,13 @@ func TestCreatorForBootup(t *testing.T) {
 				":AZURE-NPM-INGRESS-ALLOW-MARK - -",
 				":AZURE-NPM-EGRESS - -",
 				":AZURE-NPM-ACCEPT - -",
+				"-A AZURE-NPM-INGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500",
+				"-A AZURE-NPM-INGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500",
 				"-A AZURE-NPM-INGRESS -j DROP -m mark --mark 0x400/0x400 -m comment --comment DROP-ON-INGRESS-DROP-MARK-0x400/0x400",
 				"-A AZURE-NPM-INGRESS-ALLOW-MARK -j MARK --set-mark 0x200/0x200 -m comment --comment SET-INGRESS-ALLOW-MARK-0x200/0x200",
 				"-A AZURE-NPM-INGRESS-ALLOW-MARK -j AZURE-NPM-EGRESS",
+				"-A AZURE-NPM-EGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x900/0x900 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x900/0x900",
+				"-A AZURE-NPM-EGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x900/0x900 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x900/0x900",
 				"-A AZURE-NPM-EGRESS -j DROP -m mark --mark 0x800/0x800 -m comment --comment DROP-ON-EGRESS-DROP-MARK-0x800/0x800",
 				"-A AZURE-NPM-EGRESS -j AZURE-NPM-ACCEPT -m mark --mark 0x200/0x200 -m comment --comment ACCEPT-ON-INGRESS-ALLOW-MARK-0x200/0x200",
 				"-A AZURE-NPM-ACCEPT -j ACCEPT",
@@ -245,9 +249,13 @@ func TestCreatorForBootup(t *testing.T) {
 				"-F AZURE-NPM-ACCEPT",
 				"-F AZURE-NPM-INGRESS-123456",
 				"-F AZURE-NPM-EGRESS-123456",
+				"-A AZURE-NPM-INGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500",
+				"-A AZURE-NPM-INGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500",
 				"-A AZURE-NPM-INGRESS -j DROP -m mark --mark 0x400/0x400 -m comment --comment DROP-ON-INGRESS-DROP-MARK-0x400/0x400",
 				"-A AZURE-NPM-INGRESS-ALLOW-MARK -j MARK --set-mark 0x200/0x200 -m comment --comment SET-INGRESS-ALLOW-MARK-0x200/0x200",
 				"-A AZURE-NPM-INGRESS-ALLOW-MARK -j AZURE-NPM-EGRESS",
+				"-A AZURE-NPM-EGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x900/0x900 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x900/0x900",
+				"-A AZURE-NPM-EGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x900/0x900 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x900/0x900",
 				"-A AZURE-NPM-EGRESS -j DROP -m mark --mark 0x800/0x800 -m comment --comment DROP-ON-EGRESS-DROP-MARK-0x800/0x800",
 				"-A AZURE-NPM-EGRESS -j AZURE-NPM-ACCEPT -m mark --mark 0x200/0x200 -m comment --comment ACCEPT-ON-INGRESS-ALLOW-MARK-0x200/0x200",
 				"-A AZURE-NPM-ACCEPT -j ACCEPT",
@@ -274,9 +282,13 @@ func TestCreatorForBootup(t *testing.T) {
 				"-F AZURE-NPM-ACCEPT",
 				"-F AZURE-NPM-INGRESS",
 				"-F AZURE-NPM-INGRESS-ALLOW-MARK",
+				"-A AZURE-NPM-INGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500",
+				"-A AZURE-NPM-INGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500",
 				"-A AZURE-NPM-INGRESS -j DROP -m mark --mark 0x400/0x400 -m comment --comment DROP-ON-INGRESS-DROP-MARK-0x400/0x400",
 				"-A AZURE-NPM-INGRESS-ALLOW-MARK -j MARK --set-mark 0x200/0x200 -m comment --comment SET-INGRESS-ALLOW-MARK-0x200/0x200",
 				"-A AZURE-NPM-INGRESS-ALLOW-MARK -j AZURE-NPM-EGRESS",
+				"-A AZURE-NPM-EGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x900/0x900 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x900/0x900",
+				"-A AZURE-NPM-EGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x900/0x900 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x900/0x900",
 				"-A AZURE-NPM-EGRESS -j DROP -m mark --mark 0x800/0x800 -m comment --comment DROP-ON-EGRESS-DROP-MARK-0x800/0x800",
 				"-A AZURE-NPM-EGRESS -j AZURE-NPM-ACCEPT -m mark --mark 0x200/0x200 -m comment --comment ACCEPT-ON-INGRESS-ALLOW-MARK-0x200/0x200",
 				"-A AZURE-NPM-ACCEPT -j ACCEPT",
@@ -302,9 +314,13 @@ func TestCreatorForBootup(t *testing.T) {
 				"-F AZURE-NPM-EGRESS-DROPS",
 				"-F AZURE-NPM-EGRESS-FROM",
 				"-F AZURE-NPM-EGRESS-PORTS",
+				"-A AZURE-NPM-INGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500",
+				"-A AZURE-NPM-INGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500",
 				"-A AZURE-NPM-INGRESS -j DROP -m mark --mark 0x400/0x400 -m comment --comment DROP-ON-INGRESS-DROP-MARK-0x400/0x400",
 				"-A AZURE-NPM-INGRESS-ALLOW-MARK -j MARK --set-mark 0x200/0x200 -m comment --comment SET-INGRESS-ALLOW-MARK-0x200/0x200",
 				"-A AZURE-NPM-INGRESS-ALLOW-MARK -j AZURE-NPM-EGRESS",
+				"-A AZURE-NPM-EGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x900/0x900 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x900/0x900",
+				"-A AZURE-NPM-EGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x900/0x900 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x900/0x900",
 				"-A AZURE-NPM-EGRESS -j DROP -m mark --mark 0x800/0x800 -m comment --comment DROP-ON-EGRESS-DROP-MARK-0x800/0x800",
 				"-A AZURE-NPM-EGRESS -j AZURE-NPM-ACCEPT -m mark --mark 0x200/0x200 -m comment --comment ACCEPT-ON-INGRESS-ALLOW-MARK-0x200/0x200",
 				"-A AZURE-NPM-ACCEPT -j ACCEPT",
@@ -494,6 +510,46 @@ func TestBootupLinux(t *testing.T) {
 	}
 }
 
+func TestCreatorForBootupWithRejectDenied(t *testing.T) {
+	pMgr := NewPolicyManager(common.NewMockIOShim(nil), &PolicyManagerCfg{PolicyMode: IPSetPolicyMode, RejectDenied: true})
+	actualLines := strings.Split(pMgr.creatorForBootup(stringsToMap(nil)).ToString(), "\n")
+
+	// all the traffic on the drop marks is rejected
+	require.Contains(t, actualLines, "-A AZURE-NPM-INGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x400/0x400 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x400/0x400")
+	require.Contains(t, actualLines, "-A AZURE-NPM-INGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x400/0x400 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x400/0x400")
+	require.Contains(t, actualLines, "-A AZURE-NPM-EGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x800/0x800 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x800/0x800")
+	require.Contains(t, actualLines, "-A AZURE-NPM-EGRESS -j REJECT --reject-with icmp-admin-prohibited -m mark --mark 0x800/0x800 -m comment --comment REJECT-ON-EGRESS-REJECT-MARK-0x800/0x800")
+}
+
+func TestCreatorForBootupWithLogDenied(t *testing.T) {
+	pMgr := NewPolicyManager(common.NewMockIOShim(nil), &PolicyManagerCfg{
+		PolicyMode:         IPSetPolicyMode,
+		LogDenied:          true,
+		LogDeniedGroup:     7,
+		LogDeniedPerSecond: 10,
+	})
+	actualLines := strings.Split(pMgr.creatorForBootup(stringsToMap(nil)).ToString(), "\n")
+
+	// the denied traffic is logged before it's rejected or dropped
+	ingressLog := "-A AZURE-NPM-INGRESS -j NFLOG --nflog-group 7 --nflog-prefix NPM-DENIED-INGRESS --nflog-range 128 -m mark --mark 0x400/0x400 -m limit --limit 10/second -m comment --comment LOG-ON-INGRESS-DROP-MARK-0x400/0x400"
+	egressLog := "-A AZURE-NPM-EGRESS -j NFLOG --nflog-group 7 --nflog-prefix NPM-DENIED-EGRESS --nflog-range 128 -m mark --mark 0x800/0x800 -m limit --limit 10/second -m comment --comment LOG-ON-EGRESS-DROP-MARK-0x800/0x800"
+	require.Contains(t, actualLines, ingressLog)
+	require.Contains(t, actualLines, egressLog)
+	require.Less(t, indexOf(actualLines, ingressLog), indexOf(actualLines, "-A AZURE-NPM-INGRESS -j DROP -m mark --mark 0x400/0x400 -m comment --comment DROP-ON-INGRESS-DROP-MARK-0x400/0x400"))
+	require.Less(t, indexOf(actualLines, egressLog), indexOf(actualLines, "-A AZURE-NPM-EGRESS -j DROP -m mark --mark 0x800/0x800 -m comment --comment DROP-ON-EGRESS-DROP-MARK-0x800/0x800"))
+	require.Less(t, indexOf(actualLines, ingressLog), indexOf(actualLines, "-A AZURE-NPM-INGRESS -p TCP -j REJECT --reject-with tcp-reset -m mark --mark 0x500/0x500 -m comment --comment REJECT-ON-INGRESS-REJECT-MARK-0x500/0x500"))
+	require.Equal(t, numLinuxBaseACLRules+2, pMgr.numLinuxBaseACLRules())
+}
+
+func indexOf(lines []string, line string) int {
+	for i, l := range lines {
+		if l == line {
+			return i
+		}
+	}
+	return -1
+}
+
 func TestPositionAzureChainJumpRule(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -0,0 +1,94 @@
+package policies
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RuleCounter is what an iptables rule of a network policy matched since it was programmed.
+type RuleCounter struct {
+	Chain string `json:"chain"`
+	// RuleHash identifies the rule among the rules of the policy, see RuleProvenance
+	RuleHash string `json:"ruleHash"`
+	// Rule is the rule as saved by iptables-save
+	Rule    string `json:"rule"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// PolicyCounters are the counters of the rules of a network policy, including the jumps to its chains.
+type PolicyCounters struct {
+	PolicyKey string        `json:"policyKey"`
+	Packets   uint64        `json:"packets"`
+	Bytes     uint64        `json:"bytes"`
+	Rules     []RuleCounter `json:"rules"`
+	// UnmatchedRules is the number of rules which matched no packets, e.g. rules for peers which never connect
+	UnmatchedRules int `json:"unmatchedRules"`
+}
+
+// RuleCountersReport are the counters of the rules of all network policies, sorted by policy key.
+type RuleCountersReport struct {
+	Policies []*PolicyCounters `json:"policies"`
+}
+
+// parseRuleCounters returns the counters of the rules with a provenance tag in the output of iptables-save -c,
+// where each rule starts with its [packets:bytes] counters.
+func parseRuleCounters(save string) *RuleCountersReport {
+	policies := make(map[string]*PolicyCounters)
+	for _, line := range strings.Split(save, "\n") {
+		counters, rule, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || !strings.HasPrefix(counters, "[") || !strings.HasSuffix(counters, "]") {
+			continue
+		}
+		packetsString, bytesString, ok := strings.Cut(strings.Trim(counters, "[]"), ":")
+		if !ok {
+			continue
+		}
+		packets, err := strconv.ParseUint(packetsString, 10, 64)
+		if err != nil {
+			continue
+		}
+		bytes, err := strconv.ParseUint(bytesString, 10, 64)
+		if err != nil {
+			continue
+		}
+		provenance, ok := ProvenanceOfRule(rule)
+		if !ok {
+			continue
+		}
+		// the rule starts with -A <chain>
+		fields := strings.Fields(rule)
+		if len(fields) < 2 {
+			continue
+		}
+
+		policyKey := provenance.PolicyKey()
+		policy, ok := policies[policyKey]
+		if !ok {
+			policy = &PolicyCounters{PolicyKey: policyKey, Rules: make([]RuleCounter, 0)}
+			policies[policyKey] = policy
+		}
+		policy.Rules = append(policy.Rules, RuleCounter{
+			Chain:    fields[1],
+			RuleHash: provenance.RuleHash,
+			Rule:     rule,
+			Packets:  packets,
+			Bytes:    bytes,
+		})
+		policy.Packets += packets
+		policy.Bytes += bytes
+		if packets == 0 {
+			policy.UnmatchedRules++
+		}
+	}
+
+	report := &RuleCountersReport{Policies: make([]*PolicyCounters, 0, len(policies))}
+	for _, policy := range policies {
+		report.Policies = append(report.Policies, policy)
+	}
+	sort.Slice(report.Policies, func(i, j int) bool {
+		return report.Policies[i].PolicyKey < report.Policies[j].PolicyKey
+	})
+	return report
+}
@@ -0,0 +1,15 @@
+package policies
+
+import (
+	"github.com/Azure/azure-container-networking/npm/util"
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+)
+
+// RuleCounters returns the packets and bytes which the iptables rules of each network policy matched.
+func (pMgr *PolicyManager) RuleCounters() (*RuleCountersReport, error) {
+	save, err := pMgr.ioShim.Exec.Command(util.IptablesSave, util.IptablesSaveCountersFlag, util.IptablesTableFlag, util.IptablesFilterTable).CombinedOutput()
+	if err != nil {
+		return nil, npmerrors.SimpleErrorWrapper("failed to save iptables with counters", err)
+	}
+	return parseRuleCounters(string(save)), nil
+}
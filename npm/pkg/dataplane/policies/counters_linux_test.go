@@ -0,0 +1,34 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleCounters(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"iptables-save", "-c", "-t", "filter"}, Stdout: saveWithCounters},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, &PolicyManagerCfg{PolicyMode: IPSetPolicyMode})
+
+	report, err := pMgr.RuleCounters()
+	require.NoError(t, err)
+	require.Len(t, report.Policies, 2)
+}
+
+func TestRuleCountersError(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"iptables-save", "-c", "-t", "filter"}, ExitCode: 1},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, &PolicyManagerCfg{PolicyMode: IPSetPolicyMode})
+
+	_, err := pMgr.RuleCounters()
+	require.Error(t, err)
+}
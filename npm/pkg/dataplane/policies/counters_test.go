@@ -0,0 +1,42 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const saveWithCounters = `# Generated by iptables-save
+*filter
+:AZURE-NPM-INGRESS - [0:0]
+[12:960] -A AZURE-NPM-INGRESS -j AZURE-NPM-INGRESS-123 -m set --match-set azure-npm-456 dst -m comment --comment "NPM-POLICY:x/deny-all:111"
+[3:180] -A AZURE-NPM-INGRESS-123 -j MARK --set-xmark 0x4000/0xffffffff -m comment --comment "NPM-POLICY:x/deny-all:222"
+[0:0] -A AZURE-NPM-INGRESS-123 -p TCP --dport 80 -m comment --comment "NPM-POLICY:x/deny-all:333"
+[5:300] -A AZURE-NPM-EGRESS -j AZURE-NPM-EGRESS-789 -m comment --comment "NPM-POLICY:a/allow-dns:444"
+[7:420] -A AZURE-NPM-INGRESS -j DROP -m mark --mark 0x400/0x400 -m comment --comment DROP-ON-INGRESS-DROP-MARK-0x400/0x400
+COMMIT
+`
+
+func TestParseRuleCounters(t *testing.T) {
+	report := parseRuleCounters(saveWithCounters)
+	require.Len(t, report.Policies, 2, "rules without a provenance tag aren't counted")
+
+	allowDNS := report.Policies[0]
+	require.Equal(t, "a/allow-dns", allowDNS.PolicyKey)
+	require.Equal(t, uint64(5), allowDNS.Packets)
+	require.Zero(t, allowDNS.UnmatchedRules)
+
+	denyAll := report.Policies[1]
+	require.Equal(t, "x/deny-all", denyAll.PolicyKey)
+	require.Equal(t, uint64(15), denyAll.Packets)
+	require.Equal(t, uint64(1140), denyAll.Bytes)
+	require.Equal(t, 1, denyAll.UnmatchedRules)
+	require.Len(t, denyAll.Rules, 3)
+	require.Equal(t, RuleCounter{
+		Chain:    "AZURE-NPM-INGRESS-123",
+		RuleHash: "333",
+		Rule:     `-A AZURE-NPM-INGRESS-123 -p TCP --dport 80 -m comment --comment "NPM-POLICY:x/deny-all:333"`,
+	}, denyAll.Rules[2])
+
+	require.Empty(t, parseRuleCounters("").Policies)
+}
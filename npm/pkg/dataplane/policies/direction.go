@@ -0,0 +1,88 @@
+package policies
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+)
+
+var ErrInvalidEnforcedDirection = errors.New("enforced policy direction must be Ingress, Egress, or Both")
+
+// ParseEnforcedDirection returns the Direction for the name of an enforcement mode, "Ingress", "Egress" or "Both".
+// The name is case insensitive and empty means Both.
+func ParseEnforcedDirection(name string) (Direction, error) {
+	switch strings.ToLower(name) {
+	case "", "both":
+		return Both, nil
+	case "ingress":
+		return Ingress, nil
+	case "egress":
+		return Egress, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidEnforcedDirection, name)
+	}
+}
+
+// EnforcesIngress returns true if network policies are enforced on the traffic entering the pods.
+func (cfg *PolicyManagerCfg) EnforcesIngress() bool {
+	return cfg.EnforcedDirection != Egress
+}
+
+// EnforcesEgress returns true if network policies are enforced on the traffic leaving the pods.
+func (cfg *PolicyManagerCfg) EnforcesEgress() bool {
+	return cfg.EnforcedDirection != Ingress
+}
+
+/*
+FilterEnforcedDirection returns the part of the policy in the enforced direction: the ACLs of the other direction and
+the rule IPSets which only they reference are left out, and ACLs in both directions only keep the enforced one.
+The policy is returned as is when both directions are enforced, otherwise the policy isn't modified and a copy is
+returned. The copy has no ACLs if the policy only has rules in the other direction.
+*/
+func FilterEnforcedDirection(policy *NPMNetworkPolicy, enforced Direction) *NPMNetworkPolicy {
+	if enforced == "" || enforced == Both {
+		return policy
+	}
+
+	filtered := *policy
+	filtered.ACLs = make([]*ACLPolicy, 0, len(policy.ACLs))
+	// the names of the rule IPSets which the ACLs in the enforced direction reference
+	referenced := make(map[string]struct{})
+	for _, acl := range policy.ACLs {
+		if acl.Direction != enforced && acl.Direction != Both {
+			continue
+		}
+		if acl.Direction == Both {
+			aclCopy := *acl
+			aclCopy.Direction = enforced
+			acl = &aclCopy
+		}
+		filtered.ACLs = append(filtered.ACLs, acl)
+		for _, setInfo := range acl.SrcList {
+			referenced[setInfo.IPSet.GetPrefixName()] = struct{}{}
+		}
+		for _, setInfo := range acl.DstList {
+			referenced[setInfo.IPSet.GetPrefixName()] = struct{}{}
+		}
+	}
+
+	// the members of the referenced nested label sets are referenced as well
+	for _, set := range policy.RuleIPSets {
+		if _, ok := referenced[set.Metadata.GetPrefixName()]; !ok || set.Metadata.Type != ipsets.NestedLabelOfPod {
+			continue
+		}
+		for _, member := range set.Members {
+			referenced[ipsets.NewIPSetMetadata(member, ipsets.KeyValueLabelOfPod).GetPrefixName()] = struct{}{}
+		}
+	}
+
+	filtered.RuleIPSets = make([]*ipsets.TranslatedIPSet, 0, len(policy.RuleIPSets))
+	for _, set := range policy.RuleIPSets {
+		if _, ok := referenced[set.Metadata.GetPrefixName()]; ok {
+			filtered.RuleIPSets = append(filtered.RuleIPSets, set)
+		}
+	}
+	return &filtered
+}
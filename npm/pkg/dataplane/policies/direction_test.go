@@ -0,0 +1,62 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnforcedDirection(t *testing.T) {
+	for name, expected := range map[string]Direction{"": Both, "Both": Both, "ingress": Ingress, "Egress": Egress} {
+		direction, err := ParseEnforcedDirection(name)
+		require.NoError(t, err, name)
+		require.Equal(t, expected, direction, name)
+	}
+	_, err := ParseEnforcedDirection("IN")
+	require.ErrorIs(t, err, ErrInvalidEnforcedDirection)
+}
+
+func TestFilterEnforcedDirection(t *testing.T) {
+	nsSet := ipsets.NewTranslatedIPSet("ns2", ipsets.Namespace)
+	nestedSet := ipsets.NewTranslatedIPSet("ns1/policy-app:a:b", ipsets.NestedLabelOfPod, "app:a", "app:b")
+	childSets := []*ipsets.TranslatedIPSet{
+		ipsets.NewTranslatedIPSet("app:a", ipsets.KeyValueLabelOfPod),
+		ipsets.NewTranslatedIPSet("app:b", ipsets.KeyValueLabelOfPod),
+	}
+	cidrSet := ipsets.NewTranslatedIPSet("policy-in-ns-ns1-0OUT", ipsets.CIDRBlocks, "10.0.0.0/8")
+
+	ingressACL := NewACLPolicy(Allowed, Ingress)
+	ingressACL.SrcList = []SetInfo{
+		NewSetInfo(nsSet.Metadata.Name, ipsets.Namespace, true, SrcMatch),
+		NewSetInfo(nestedSet.Metadata.Name, ipsets.NestedLabelOfPod, true, SrcMatch),
+	}
+	egressACL := NewACLPolicy(Allowed, Egress)
+	egressACL.DstList = []SetInfo{
+		NewSetInfo(nsSet.Metadata.Name, ipsets.Namespace, true, DstMatch),
+		NewSetInfo(cidrSet.Metadata.Name, ipsets.CIDRBlocks, true, DstMatch),
+	}
+	bothACL := NewACLPolicy(Dropped, Both)
+
+	policy := NewNPMNetworkPolicy("policy", "ns1")
+	policy.RuleIPSets = append([]*ipsets.TranslatedIPSet{nsSet, nestedSet, cidrSet}, childSets...)
+	policy.ACLs = []*ACLPolicy{ingressACL, egressACL, bothACL}
+
+	require.Same(t, policy, FilterEnforcedDirection(policy, Both))
+	require.Same(t, policy, FilterEnforcedDirection(policy, ""))
+
+	ingressOnly := FilterEnforcedDirection(policy, Ingress)
+	require.Len(t, ingressOnly.ACLs, 2)
+	require.Same(t, ingressACL, ingressOnly.ACLs[0])
+	require.Equal(t, Ingress, ingressOnly.ACLs[1].Direction)
+	require.Equal(t, append([]*ipsets.TranslatedIPSet{nsSet, nestedSet}, childSets...), ingressOnly.RuleIPSets)
+
+	egressOnly := FilterEnforcedDirection(policy, Egress)
+	require.Len(t, egressOnly.ACLs, 2)
+	require.Same(t, egressACL, egressOnly.ACLs[0])
+	require.Equal(t, Egress, egressOnly.ACLs[1].Direction)
+	require.Equal(t, []*ipsets.TranslatedIPSet{nsSet, cidrSet}, egressOnly.RuleIPSets)
+
+	require.Len(t, policy.ACLs, 3, "the policy isn't modified")
+	require.Equal(t, Both, bothACL.Direction)
+}
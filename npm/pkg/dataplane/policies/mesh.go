@@ -0,0 +1,86 @@
+package policies
+
+import (
+	"strconv"
+	"strings"
+)
+
+// meshChainPrefixes are the prefixes of the iptables chains of the known service meshes: Istio, including the
+// ambient mode, and Linkerd, whose proxy-init chains are named PROXY_INIT_*.
+var meshChainPrefixes = []string{"ISTIO_", "LINKERD", "PROXY_INIT_"}
+
+// MeshCompatibilityReport is how the NPM chains are ordered relative to the chains of service meshes in Linux.
+type MeshCompatibilityReport struct {
+	// Enabled is whether NPM places its chains after the chains of the meshes
+	Enabled bool `json:"enabled"`
+	// MeshChains are the chains of the meshes by iptables table
+	MeshChains map[string][]string `json:"meshChains"`
+	// ForwardJumps are the jumps of the FORWARD chain to the chains of NPM and the meshes, in order
+	ForwardJumps []ChainJump `json:"forwardJumps"`
+	// Compatible is false when a jump of the FORWARD chain to a chain of a mesh comes after the jump to NPM,
+	// in which case network policies may drop the traffic redirected by the mesh
+	Compatible bool `json:"compatible"`
+}
+
+// ChainJump is a jump of a chain to another chain.
+type ChainJump struct {
+	Line   int    `json:"line"`
+	Target string `json:"target"`
+}
+
+// IsMeshChain returns true if the chain belongs to a known service mesh.
+func IsMeshChain(chain string) bool {
+	for _, prefix := range meshChainPrefixes {
+		if strings.HasPrefix(chain, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChainJumps returns the rules of an "iptables -n -L <chain> --line-numbers" listing with their targets.
+func parseChainJumps(listing string) []ChainJump {
+	jumps := make([]ChainJump, 0)
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		lineNum, err := strconv.Atoi(fields[0])
+		if err != nil {
+			// the headers of the listing
+			continue
+		}
+		jumps = append(jumps, ChainJump{Line: lineNum, Target: fields[1]})
+	}
+	return jumps
+}
+
+// lastMeshJump returns the line number of the last jump to a chain of a mesh, or 0 if there is none.
+func lastMeshJump(jumps []ChainJump) int {
+	last := 0
+	for _, jump := range jumps {
+		if IsMeshChain(jump.Target) && jump.Line > last {
+			last = jump.Line
+		}
+	}
+	return last
+}
+
+// parseMeshChains returns the chains of the meshes by table in an iptables-save output.
+func parseMeshChains(save string) map[string][]string {
+	chains := make(map[string][]string)
+	table := ""
+	for _, line := range strings.Split(save, "\n") {
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimPrefix(line, "*")
+		case strings.HasPrefix(line, ":"):
+			fields := strings.Fields(strings.TrimPrefix(line, ":"))
+			if len(fields) > 0 && IsMeshChain(fields[0]) {
+				chains[table] = append(chains[table], fields[0])
+			}
+		}
+	}
+	return chains
+}
@@ -0,0 +1,56 @@
+package policies
+
+import (
+	"github.com/Azure/azure-container-networking/npm/util"
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+)
+
+// returns 0 if there are no jumps from FORWARD chain to service mesh chains
+func (pMgr *PolicyManager) lastMeshChainLineNumber() (int, error) {
+	jumps, err := pMgr.forwardChainJumps()
+	if err != nil {
+		return 0, err
+	}
+	return lastMeshJump(jumps), nil
+}
+
+func (pMgr *PolicyManager) forwardChainJumps() ([]ChainJump, error) {
+	output, err := pMgr.ioShim.Exec.Command(util.Iptables, listForwardEntriesArgs...).CombinedOutput()
+	if err != nil {
+		return nil, npmerrors.SimpleErrorWrapper("failed to list FORWARD chain", err)
+	}
+	return parseChainJumps(string(output)), nil
+}
+
+// MeshCompatibilityReport returns the chains of service meshes in all tables and whether the jump from FORWARD chain
+// to AZURE-NPM chain comes after the jumps to the chains of the meshes.
+func (pMgr *PolicyManager) MeshCompatibilityReport() (*MeshCompatibilityReport, error) {
+	save, err := pMgr.ioShim.Exec.Command(util.IptablesSave).CombinedOutput()
+	if err != nil {
+		return nil, npmerrors.SimpleErrorWrapper("failed to save iptables", err)
+	}
+	jumps, err := pMgr.forwardChainJumps()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MeshCompatibilityReport{
+		Enabled:      pMgr.MeshCompatibility,
+		MeshChains:   parseMeshChains(string(save)),
+		ForwardJumps: make([]ChainJump, 0),
+		Compatible:   true,
+	}
+	azureChainLineNum := 0
+	for _, jump := range jumps {
+		if jump.Target == util.IptablesAzureChain {
+			azureChainLineNum = jump.Line
+		}
+		if jump.Target == util.IptablesAzureChain || IsMeshChain(jump.Target) {
+			report.ForwardJumps = append(report.ForwardJumps, jump)
+		}
+	}
+	if azureChainLineNum != 0 && lastMeshJump(jumps) > azureChainLineNum {
+		report.Compatible = false
+	}
+	return report, nil
+}
@@ -0,0 +1,128 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/util"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+const forwardListingWithMesh = `Chain FORWARD (policy ACCEPT)
+num  target     prot opt source               destination
+1    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0            ctstate NEW
+2    ISTIO_FWD  all  --  0.0.0.0/0            0.0.0.0/0
+3    KUBE-FORWARD  all  --  0.0.0.0/0            0.0.0.0/0
+`
+
+func TestPositionAzureChainJumpRuleWithMesh(t *testing.T) {
+	tests := []struct {
+		name    string
+		calls   []testutils.TestCmd
+		wantErr bool
+	}{
+		{
+			name: "azure jump above the mesh jump",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{
+					Cmd:    []string{"grep", "AZURE-NPM"},
+					Stdout: "1    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0    ...",
+				},
+				{Cmd: listLineNumbersCommandStrings, Stdout: forwardListingWithMesh},
+				{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+				{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "2", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+			},
+		},
+		{
+			name: "azure jump right after the mesh jump",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{
+					Cmd:    []string{"grep", "AZURE-NPM"},
+					Stdout: "3    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0    ...",
+				},
+				{
+					Cmd:    listLineNumbersCommandStrings,
+					Stdout: "1    ISTIO_FWD  all  --  0.0.0.0/0  0.0.0.0/0\n2    LINKERD-FWD  all  --  0.0.0.0/0  0.0.0.0/0\n3    AZURE-NPM  all  --  0.0.0.0/0  0.0.0.0/0",
+				},
+			},
+		},
+		{
+			name: "no mesh jump and azure jump at top",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{
+					Cmd:    []string{"grep", "AZURE-NPM"},
+					Stdout: "1    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0    ...",
+				},
+				{Cmd: listLineNumbersCommandStrings, Stdout: "1    AZURE-NPM  all  --  0.0.0.0/0  0.0.0.0/0"},
+			},
+		},
+		{
+			name: "error listing the mesh jumps",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{Cmd: []string{"grep", "AZURE-NPM"}, ExitCode: 1},
+				{Cmd: listLineNumbersCommandStrings, ExitCode: 1},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ioshim := common.NewMockIOShim(tt.calls)
+			defer ioshim.VerifyCalls(t, tt.calls)
+			cfg := &PolicyManagerCfg{
+				PolicyMode:           IPSetPolicyMode, // value doesn't matter for Linux
+				PlaceAzureChainFirst: util.PlaceAzureChainFirst,
+				MeshCompatibility:    true,
+			}
+			pMgr := NewPolicyManager(ioshim, cfg)
+			err := pMgr.positionAzureChainJumpRule()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMeshCompatibilityReport(t *testing.T) {
+	save := `*nat
+:PREROUTING ACCEPT [0:0]
+:ISTIO_INBOUND - [0:0]
+:ISTIO_REDIRECT - [0:0]
+COMMIT
+*filter
+:FORWARD ACCEPT [0:0]
+:AZURE-NPM - [0:0]
+:ISTIO_FWD - [0:0]
+COMMIT
+`
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"iptables-save"}, Stdout: save},
+		{Cmd: listLineNumbersCommandStrings, Stdout: forwardListingWithMesh},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, &PolicyManagerCfg{MeshCompatibility: true})
+
+	report, err := pMgr.MeshCompatibilityReport()
+	require.NoError(t, err)
+	require.Equal(t, &MeshCompatibilityReport{
+		Enabled: true,
+		MeshChains: map[string][]string{
+			"nat":    {"ISTIO_INBOUND", "ISTIO_REDIRECT"},
+			"filter": {"ISTIO_FWD"},
+		},
+		ForwardJumps: []ChainJump{
+			{Line: 1, Target: "AZURE-NPM"},
+			{Line: 2, Target: "ISTIO_FWD"},
+		},
+		Compatible: false,
+	}, report)
+}
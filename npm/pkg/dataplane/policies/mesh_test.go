@@ -0,0 +1,33 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsMeshChain(t *testing.T) {
+	require.True(t, IsMeshChain("ISTIO_REDIRECT"))
+	require.True(t, IsMeshChain("LINKERD-FWD"))
+	require.True(t, IsMeshChain("PROXY_INIT_OUTPUT"))
+	require.False(t, IsMeshChain("AZURE-NPM"))
+	require.False(t, IsMeshChain("KUBE-SERVICES"))
+}
+
+func TestParseChainJumps(t *testing.T) {
+	listing := `Chain FORWARD (policy ACCEPT)
+num  target     prot opt source               destination
+1    KUBE-SERVICES  all  --  0.0.0.0/0            0.0.0.0/0
+2    ISTIO_FWD  all  --  0.0.0.0/0            0.0.0.0/0
+
+3    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0
+`
+	jumps := parseChainJumps(listing)
+	require.Equal(t, []ChainJump{
+		{Line: 1, Target: "KUBE-SERVICES"},
+		{Line: 2, Target: "ISTIO_FWD"},
+		{Line: 3, Target: "AZURE-NPM"},
+	}, jumps)
+	require.Equal(t, 2, lastMeshJump(jumps))
+	require.Equal(t, 0, lastMeshJump(parseChainJumps("")))
+}
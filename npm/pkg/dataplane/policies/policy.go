@@ -32,6 +32,9 @@ type NPMNetworkPolicy struct {
 	// podIP is key and endpoint ID as value
 	// Will be populated by dataplane and policy manager
 	PodEndpoints map[string]string
+	// RejectDenied rejects the traffic which the policy denies with a TCP reset or an ICMP admin-prohibited error
+	// instead of dropping it, so that clients fail fast instead of timing out. Windows blocks the traffic either way.
+	RejectDenied bool
 }
 
 func NewNPMNetworkPolicy(netPolName, netPolNamespace string) *NPMNetworkPolicy {
@@ -71,6 +74,17 @@ func (netPol *NPMNetworkPolicy) numACLRulesProducedInKernel() int {
 	return numRules
 }
 
+// numIPSets returns the number of distinct IPSets which the policy references
+func (netPol *NPMNetworkPolicy) numIPSets() int {
+	names := make(map[string]struct{})
+	for _, sets := range [][]*ipsets.TranslatedIPSet{netPol.PodSelectorIPSets, netPol.ChildPodSelectorIPSets, netPol.RuleIPSets} {
+		for _, set := range sets {
+			names[set.Metadata.GetPrefixName()] = struct{}{}
+		}
+	}
+	return len(names)
+}
+
 func (netPol *NPMNetworkPolicy) PrettyString() string {
 	if netPol == nil {
 		klog.Infof("NPMNetworkPolicy is nil when trying to print string")
@@ -237,6 +237,11 @@ func (portRange *Ports) toIPTablesString() string {
 			-IN-ns
 			-namespaceName
 
+	v2 provenance:
+		- every rule of a policy (ACLs and jumps) has a second comment after the one above:
+			NPM-POLICY:namespace/name:ruleHash
+		- see provenance.go
+
 	strings for protocol, ports, selectors:
 		protocol: just "name"
 
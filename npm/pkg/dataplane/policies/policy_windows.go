@@ -11,7 +11,10 @@ import (
 const (
 	blockRulePriotity = 3000
 	allowRulePriotity = 222
+	// allowlistPriority takes precedence over the rules of all network policies
+	allowlistPriority = 100
 	policyIDPrefix    = "azure-acl"
+	allowlistPolicyID = policyIDPrefix + "-allowlist"
 )
 
 var (
@@ -83,10 +86,7 @@ func (acl *ACLPolicy) convertToAclSettings(aclID string) (*NPMACLPolSettings, er
 	policySettings.Action = getHCNAction(acl.Target)
 
 	// TODO need to have better priority handling
-	policySettings.Priority = uint16(allowRulePriotity)
-	if policySettings.Action == hcn.ActionTypeBlock {
-		policySettings.Priority = uint16(blockRulePriotity)
-	}
+	policySettings.Priority = aclPriority(policySettings.Id, policySettings.Action)
 	protoNum, ok := protocolNumMap[acl.Protocol]
 	if !ok {
 		return policySettings, ErrProtocolNotSupported
@@ -140,6 +140,18 @@ func (acl *ACLPolicy) convertToAclSettings(aclID string) (*NPMACLPolSettings, er
 	return policySettings, nil
 }
 
+// aclPriority returns the priority of an NPM ACL with the ID and action.
+// Block rules are evaluated after allow rules, and the allowlist before both.
+func aclPriority(aclID string, action hcn.ActionType) uint16 {
+	if aclID == allowlistPolicyID {
+		return allowlistPriority
+	}
+	if action == hcn.ActionTypeBlock {
+		return blockRulePriotity
+	}
+	return allowRulePriotity
+}
+
 func (acl *ACLPolicy) checkIPSets() bool {
 	for _, set := range acl.SrcList {
 		if set.IPSet.Type == ipsets.NamedPorts {
@@ -162,6 +174,41 @@ func (acl *ACLPolicy) checkIPSets() bool {
 	return true
 }
 
+// allowlistSettings returns the ACLs allowing the traffic to and from the allowlisted CIDR.
+// See convertToAclSettings for how HNS maps the addresses and ports.
+func (entry *AllowlistEntry) allowlistSettings() []*NPMACLPolSettings {
+	port := ""
+	if entry.Port != 0 {
+		port = fmt.Sprint(entry.Port)
+	}
+	protocols := protocolNumMap[entry.Protocol]
+	if protocols == protocolNumMap[UnspecifiedProtocol] {
+		protocols = ""
+	}
+	return []*NPMACLPolSettings{
+		{
+			Id:             allowlistPolicyID,
+			Protocols:      protocols,
+			Action:         hcn.ActionTypeAllow,
+			Direction:      hcn.DirectionTypeIn,
+			LocalAddresses: entry.CIDR,
+			LocalPorts:     port,
+			RuleType:       hcn.RuleTypeSwitch,
+			Priority:       allowlistPriority,
+		},
+		{
+			Id:              allowlistPolicyID,
+			Protocols:       protocols,
+			Action:          hcn.ActionTypeAllow,
+			Direction:       hcn.DirectionTypeOut,
+			RemoteAddresses: entry.CIDR,
+			RemotePorts:     port,
+			RuleType:        hcn.RuleTypeSwitch,
+			Priority:        allowlistPriority,
+		},
+	}
+}
+
 func getAddrListFromSetInfo(setInfoList []SetInfo) string {
 	setInfoStr := ""
 	setInfoLen := len(setInfoList)
@@ -25,7 +25,7 @@ const (
 	// this number is based on the implementation in chain-management_linux.go
 	// it represents the number of rules unrelated to policies
 	// it's technically 3 off when there are no policies since we flush the AZURE-NPM chain then
-	numLinuxBaseACLRules = 11
+	numLinuxBaseACLRules = 15
 )
 
 type PolicyManagerCfg struct {
@@ -33,6 +33,22 @@ type PolicyManagerCfg struct {
 	PolicyMode PolicyManagerMode
 	// PlaceAzureChainFirst only affects Linux
 	PlaceAzureChainFirst bool
+	// Allowlist is the traffic which no network policy can block, such as the traffic to the control plane
+	Allowlist []*AllowlistEntry
+	// EnforcedDirection limits the enforcement of network policies to Ingress or Egress traffic.
+	// Both directions are enforced when it's Both or empty.
+	EnforcedDirection Direction
+	// MeshCompatibility places the jump to the NPM chains after the jumps to the chains of service meshes, so that
+	// network policies don't override the redirection of the meshes. Only affects Linux.
+	MeshCompatibility bool
+	// RejectDenied rejects the traffic denied by all network policies instead of dropping it. Windows blocks the
+	// traffic either way since HNS ACLs can't reject.
+	RejectDenied bool
+	// LogDenied logs the traffic denied by network policies to the NFLOG group LogDeniedGroup, at most
+	// LogDeniedPerSecond packets per second in each direction if it's positive. Only affects Linux.
+	LogDenied          bool
+	LogDeniedGroup     uint16
+	LogDeniedPerSecond int
 }
 
 type PolicyMap struct {
@@ -56,9 +72,25 @@ type PolicyManager struct {
 	ioShim           *common.IOShim
 	staleChains      *staleChains
 	reconcileManager *reconcileManager
+	// allowlistEndpoints are the IDs of the endpoints with the allowlist ACLs. Only used in Windows.
+	allowlistEndpoints map[string]struct{}
 	*PolicyManagerCfg
 }
 
+// Manager applies network policies to iptables in Linux or to the HNS endpoints in Windows. PolicyManager
+// is the OS backed Manager, FakePolicyManager the in-memory one for tests.
+type Manager interface {
+	Bootup(epIDs []string) error
+	Reconcile()
+	PolicyExists(policyKey string) bool
+	GetPolicy(policyKey string) (*NPMNetworkPolicy, bool)
+	AddPolicy(policy *NPMNetworkPolicy, endpointList map[string]string) error
+	RemovePolicy(policyKey string) error
+	RemovePolicyForEndpoints(policyKey string, endpointList map[string]string) error
+}
+
+var _ Manager = (*PolicyManager)(nil)
+
 func NewPolicyManager(ioShim *common.IOShim, cfg *PolicyManagerCfg) *PolicyManager {
 	return &PolicyManager{
 		policyMap: &PolicyMap{
@@ -69,7 +101,8 @@ func NewPolicyManager(ioShim *common.IOShim, cfg *PolicyManagerCfg) *PolicyManag
 		reconcileManager: &reconcileManager{
 			releaseLockSignal: make(chan struct{}, 1),
 		},
-		PolicyManagerCfg: cfg,
+		allowlistEndpoints: make(map[string]struct{}),
+		PolicyManagerCfg:   cfg,
 	}
 }
 
@@ -83,11 +116,19 @@ func (pMgr *PolicyManager) Bootup(epIDs []string) error {
 
 	if !util.IsWindowsDP() {
 		// update Prometheus metrics on success
-		metrics.IncNumACLRulesBy(numLinuxBaseACLRules)
+		metrics.IncNumACLRulesBy(pMgr.numLinuxBaseACLRules())
 	}
 	return nil
 }
 
+// numLinuxBaseACLRules includes the NFLOG rule of each direction when denied traffic is logged
+func (pMgr *PolicyManager) numLinuxBaseACLRules() int {
+	if pMgr.LogDenied {
+		return numLinuxBaseACLRules + 2
+	}
+	return numLinuxBaseACLRules
+}
+
 func (pMgr *PolicyManager) Reconcile() {
 	pMgr.reconcile()
 }
@@ -189,6 +230,7 @@ func (pMgr *PolicyManager) RemovePolicy(policyKey string) error {
 
 	// remove policy from cache
 	delete(pMgr.policyMap.cache, policyKey)
+	metrics.DeletePolicyDataplaneSize(policyKey)
 	return nil
 }
 
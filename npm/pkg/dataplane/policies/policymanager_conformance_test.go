@@ -0,0 +1,98 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// newManagerFunc returns a Manager which expects the exec calls if it is OS backed.
+type newManagerFunc func(t *testing.T, calls []testutils.TestCmd) Manager
+
+// the conformance tests are the behavior the dataplane relies on, which every Manager must have.
+var managerConformanceTests = []struct {
+	name string
+	test func(t *testing.T, newManager newManagerFunc)
+}{
+	{"bootup", testManagerBootup},
+	{"add and remove", testManagerAddAndRemove},
+	{"remove for endpoints", testManagerRemoveForEndpoints},
+	{"policies without acls", testManagerPolicyWithoutACLs},
+	{"invalid policies", testManagerInvalidPolicy},
+}
+
+func runManagerConformanceTests(t *testing.T, newManager newManagerFunc) {
+	for _, tt := range managerConformanceTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newManager)
+		})
+	}
+}
+
+func TestPolicyManagerConformance(t *testing.T) {
+	runManagerConformanceTests(t, func(t *testing.T, calls []testutils.TestCmd) Manager {
+		ioshim := common.NewMockIOShim(calls)
+		t.Cleanup(func() { ioshim.VerifyCalls(t, calls) })
+		return NewPolicyManager(ioshim, ipsetConfig)
+	})
+}
+
+func TestFakePolicyManagerConformance(t *testing.T) {
+	runManagerConformanceTests(t, func(_ *testing.T, _ []testutils.TestCmd) Manager {
+		return NewFakePolicyManager()
+	})
+}
+
+func testManagerBootup(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, GetBootupTestCalls())
+	require.NoError(t, m.Bootup(epIDs))
+}
+
+func testManagerAddAndRemove(t *testing.T, newManager newManagerFunc) {
+	netpol := testNetworkPolicy()
+	m := newManager(t, append(GetAddPolicyTestCalls(netpol), GetRemovePolicyTestCalls(netpol)...))
+
+	require.False(t, m.PolicyExists(netpol.PolicyKey))
+	require.NoError(t, m.AddPolicy(netpol, epList))
+	require.True(t, m.PolicyExists(netpol.PolicyKey))
+	policy, ok := m.GetPolicy(netpol.PolicyKey)
+	require.True(t, ok)
+	require.Same(t, netpol, policy)
+	require.Equal(t, UnspecifiedProtocol, policy.ACLs[0].Protocol, "policies are normalized")
+
+	require.NoError(t, m.RemovePolicy(netpol.PolicyKey))
+	require.False(t, m.PolicyExists(netpol.PolicyKey))
+	_, ok = m.GetPolicy(netpol.PolicyKey)
+	require.False(t, ok)
+	require.NoError(t, m.RemovePolicy(netpol.PolicyKey), "missing policies are ignored")
+}
+
+func testManagerRemoveForEndpoints(t *testing.T, newManager newManagerFunc) {
+	netpol := testNetworkPolicy()
+	m := newManager(t, append(GetAddPolicyTestCalls(netpol), GetRemovePolicyTestCalls(netpol)...))
+
+	require.NoError(t, m.RemovePolicyForEndpoints(netpol.PolicyKey, epList), "missing policies are ignored")
+	require.NoError(t, m.AddPolicy(netpol, epList))
+	require.NoError(t, m.RemovePolicyForEndpoints(netpol.PolicyKey, epList))
+	require.True(t, m.PolicyExists(netpol.PolicyKey), "the policy is kept for other endpoints")
+}
+
+func testManagerPolicyWithoutACLs(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, nil)
+
+	netpol := NewNPMNetworkPolicy("test-netpol", "x")
+	require.NoError(t, m.AddPolicy(netpol, epList))
+	require.False(t, m.PolicyExists(netpol.PolicyKey))
+}
+
+func testManagerInvalidPolicy(t *testing.T, newManager newManagerFunc) {
+	m := newManager(t, nil)
+
+	netpol := testNetworkPolicy()
+	netpol.ACLs[0].Protocol = "invalid"
+	require.Error(t, m.AddPolicy(netpol, epList))
+	require.False(t, m.PolicyExists(netpol.PolicyKey))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/util"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
 	"github.com/Azure/azure-container-networking/npm/util/ioutil"
@@ -41,6 +42,16 @@ Known errors that we should retry on:
     Another app is currently holding the xtables lock. Stopped waiting after 60s.
 */
 
+/*
+Chain layout:
+Each policy has its own chains, AZURE-NPM-INGRESS-<hash> and/or AZURE-NPM-EGRESS-<hash>,
+with a single jump from AZURE-NPM-INGRESS/AZURE-NPM-EGRESS that matches the policy's selector.
+So adding or removing a policy never touches the rules of other policies:
+- add: create the policy chains and append the jumps in one iptables-restore call.
+- remove: delete the jumps, flush the policy chains, then delete the chains in the background (see reconcile).
+bootup() migrates from older layouts by flushing/deleting the deprecated v1 chains and any old v2 policy chains.
+*/
+
 func (pMgr *PolicyManager) addPolicy(networkPolicy *NPMNetworkPolicy, _ map[string]string) error {
 	// 1. Add rules for the network policies and activate NPM (if necessary).
 	chainsToCreate := chainNames([]*NPMNetworkPolicy{networkPolicy})
@@ -59,6 +70,12 @@ func (pMgr *PolicyManager) addPolicy(networkPolicy *NPMNetworkPolicy, _ map[stri
 	for _, chain := range chainsToCreate {
 		pMgr.staleChains.remove(chain)
 	}
+
+	metrics.SetPolicyDataplaneSize(networkPolicy.PolicyKey, &metrics.PolicyDataplaneSize{
+		Rules:       networkPolicy.numACLRulesProducedInKernel(),
+		IPSets:      networkPolicy.numIPSets(),
+		ConfigBytes: len(creator.ToString()),
+	})
 	return nil
 }
 
@@ -178,7 +195,7 @@ func (pMgr *PolicyManager) deleteJumpRule(policy *NPMNetworkPolicy, direction Un
 	// if this actually happens (don't think it should), could use ignoreErrorsAndRunIPTablesCommand instead with: "Bad rule (does a matching rule exist in that chain?)"
 	if err != nil && errCode != doesNotExistErrorCode {
 		errorString := fmt.Sprintf("failed to delete jump from %s chain to %s chain for policy %s with exit code %d", baseChainName, chainName, policy.PolicyKey, errCode)
-		log.Errorf("%s: %w", errorString, err)
+		log.Errorf("%s: %v", errorString, err)
 		return npmerrors.SimpleErrorWrapper(errorString, err)
 	}
 	return nil
@@ -189,7 +206,7 @@ func ingressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
 	specs := []string{util.IptablesJumpFlag, chainName}
 	specs = append(specs, matchSetSpecsForNetworkPolicy(networkPolicy, DstMatch)...)
 	specs = append(specs, commentSpecs(networkPolicy.commentForJumpToIngress())...)
-	return specs
+	return withProvenance(networkPolicy, util.IptablesAzureIngressChain, specs)
 }
 
 func egressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
@@ -197,7 +214,7 @@ func egressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
 	specs := []string{util.IptablesJumpFlag, chainName}
 	specs = append(specs, matchSetSpecsForNetworkPolicy(networkPolicy, SrcMatch)...)
 	specs = append(specs, commentSpecs(networkPolicy.commentForJumpToEgress())...)
-	return specs
+	return withProvenance(networkPolicy, util.IptablesAzureEgressChain, specs)
 }
 
 func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, networkPolicies []*NPMNetworkPolicy) *ioutil.FileCreator {
@@ -206,6 +223,12 @@ func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, n
 	// 1. Activate NPM if necessary
 	if pMgr.isFirstPolicy() {
 		creator.AddLine("", nil, util.IptablesFlushFlag, util.IptablesAzureChain) // flush just in case there are old rules
+		// the allowlist must come before the jumps to the policy chains
+		for _, entry := range pMgr.Allowlist {
+			for _, specs := range allowlistSpecs(entry) {
+				creator.AddLine("", nil, specs...)
+			}
+		}
 		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureChain, util.IptablesJumpFlag, util.IptablesAzureIngressChain)
 		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureChain, util.IptablesJumpFlag, util.IptablesAzureEgressChain)
 		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureChain, util.IptablesJumpFlag, util.IptablesAzureAcceptChain)
@@ -244,6 +267,8 @@ func writeNetworkPolicyRules(creator *ioutil.FileCreator, networkPolicy *NPMNetw
 			chainName = networkPolicy.ingressChainName()
 			if aclPolicy.Target == Allowed {
 				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesAzureIngressAllowMarkChain}
+			} else if networkPolicy.RejectDenied {
+				actionSpecs = setMarkSpecs(util.IptablesAzureIngressRejectMarkHex)
 			} else {
 				actionSpecs = setMarkSpecs(util.IptablesAzureIngressDropMarkHex)
 			}
@@ -251,13 +276,15 @@ func writeNetworkPolicyRules(creator *ioutil.FileCreator, networkPolicy *NPMNetw
 			chainName = networkPolicy.egressChainName()
 			if aclPolicy.Target == Allowed {
 				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesAzureAcceptChain}
+			} else if networkPolicy.RejectDenied {
+				actionSpecs = setMarkSpecs(util.IptablesAzureEgressRejectMarkHex)
 			} else {
 				actionSpecs = setMarkSpecs(util.IptablesAzureEgressDropMarkHex)
 			}
 		}
+		specs := append(actionSpecs, iptablesRuleSpecs(aclPolicy)...)
 		line := []string{"-A", chainName}
-		line = append(line, actionSpecs...)
-		line = append(line, iptablesRuleSpecs(aclPolicy)...)
+		line = append(line, withProvenance(networkPolicy, chainName, specs)...)
 		creator.AddLine("", nil, line...) // TODO add error handler
 	}
 }
@@ -317,6 +344,33 @@ func commentSpecs(comment string) []string {
 	}
 }
 
+// withProvenance tags the specs of a rule in the chain with the policy, see ProvenanceOfRule
+func withProvenance(networkPolicy *NPMNetworkPolicy, chainName string, specs []string) []string {
+	return append(specs, commentSpecs(provenanceComment(networkPolicy.PolicyKey, chainName, specs))...)
+}
+
+// allowlistSpecs returns the rules accepting the new connections to and from the allowlisted CIDR.
+func allowlistSpecs(entry *AllowlistEntry) [][]string {
+	portSpecs := make([]string, 0)
+	comment := fmt.Sprintf("ALLOWLIST-%s", entry.CIDR)
+	if entry.Protocol != UnspecifiedProtocol {
+		portSpecs = append(portSpecs, util.IptablesProtFlag, string(entry.Protocol))
+	}
+	if entry.Port != 0 {
+		portSpecs = append(portSpecs, util.IptablesDstPortFlag, fmt.Sprint(entry.Port))
+		comment = fmt.Sprintf("%s-%s-%d", comment, entry.Protocol, entry.Port)
+	}
+
+	allSpecs := make([][]string, 0, 2)
+	for _, addrFlag := range []string{util.IptablesDFlag, util.IptablesSFlag} {
+		specs := []string{util.IptablesAppendFlag, util.IptablesAzureChain, util.IptablesJumpFlag, util.IptablesAzureAcceptChain, addrFlag, entry.CIDR}
+		specs = append(specs, portSpecs...)
+		specs = append(specs, commentSpecs(comment)...)
+		allSpecs = append(allSpecs, specs)
+	}
+	return allSpecs
+}
+
 func insertSpecs(chainName string, index int, specs []string) []string {
 	indexString := fmt.Sprint(index)
 	insertSpecs := []string{util.IptablesInsertionFlag, chainName, indexString}
@@ -326,3 +380,8 @@ func insertSpecs(chainName string, index int, specs []string) []string {
 func joinWithDash(prefix, item string) string {
 	return fmt.Sprintf("%s-%s", prefix, item)
 }
+
+// GetACLPriorityUtilization returns no endpoints since iptables rules have no priorities.
+func (pMgr *PolicyManager) GetACLPriorityUtilization() (map[string]*ACLPriorityUtilization, error) {
+	return map[string]*ACLPriorityUtilization{}, nil
+}
@@ -173,28 +173,33 @@ var (
 	ingressNetPolChain               = ingressNetPol.ingressChainName()
 	egressNetPolChain                = egressNetPol.egressChainName()
 
-	ingressEgressNetPolIngressJump = fmt.Sprintf(
+	ingressEgressNetPolIngressJump = tagged(bothDirectionsNetPol, util.IptablesAzureIngressChain, fmt.Sprintf(
 		"-j %s -m set --match-set %s dst -m comment --comment %s",
 		bothDirectionsNetPolIngressChain,
 		ipsets.TestKeyPodSet.HashedName,
 		bothDirectionsNetPolIngressJumpComment,
-	)
-	ingressEgressNetPolEgressJump = fmt.Sprintf(
+	))
+	ingressEgressNetPolEgressJump = tagged(bothDirectionsNetPol, util.IptablesAzureEgressChain, fmt.Sprintf(
 		"-j %s -m set --match-set %s src -m comment --comment %s",
 		bothDirectionsNetPolEgressChain,
 		ipsets.TestKeyPodSet.HashedName,
 		bothDirectionsNetPolEgressJumpComment,
-	)
-	ingressNetPolJump = fmt.Sprintf(
+	))
+	ingressNetPolJump = tagged(ingressNetPol, util.IptablesAzureIngressChain, fmt.Sprintf(
 		"-j %s -m set --match-set %s dst -m set --match-set %s dst -m comment --comment %s",
 		ingressNetPolChain,
 		ipsets.TestKeyPodSet.HashedName,
 		ipsets.TestNSSet.HashedName,
 		ingressNetPolJumpComment,
-	)
-	egressNetPolJump = fmt.Sprintf("-j %s -m comment --comment %s", egressNetPolChain, egressNetPolJumpComment)
+	))
+	egressNetPolJump = tagged(egressNetPol, util.IptablesAzureEgressChain, fmt.Sprintf("-j %s -m comment --comment %s", egressNetPolChain, egressNetPolJumpComment))
 )
 
+// tagged appends the provenance tag of the policy to a rule in the chain
+func tagged(networkPolicy *NPMNetworkPolicy, chainName, rule string) string {
+	return fmt.Sprintf("%s -m comment --comment %s", rule, provenanceComment(networkPolicy.PolicyKey, chainName, strings.Fields(rule)))
+}
+
 var allTestNetworkPolicies = []*NPMNetworkPolicy{bothDirectionsNetPol, ingressNetPol, egressNetPol}
 
 func TestChainNames(t *testing.T) {
@@ -240,10 +245,10 @@ func TestCreatorForAddPolicies(t *testing.T) {
 		"-A AZURE-NPM -j AZURE-NPM-EGRESS",
 		"-A AZURE-NPM -j AZURE-NPM-ACCEPT",
 		// policy 1
-		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, ingressDropRule),
-		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, ingressAllowRule),
-		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, egressDropRule),
-		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, egressAllowRule),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolIngressChain, ingressDropRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolIngressChain, ingressAllowRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolEgressChain, egressDropRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolEgressChain, egressAllowRule)),
 		fmt.Sprintf("-I AZURE-NPM-INGRESS 1 %s", ingressEgressNetPolIngressJump),
 		fmt.Sprintf("-I AZURE-NPM-EGRESS 1 %s", ingressEgressNetPolEgressJump),
 		"COMMIT",
@@ -264,17 +269,17 @@ func TestCreatorForAddPolicies(t *testing.T) {
 		fmt.Sprintf(":%s - -", ingressNetPolChain),
 		fmt.Sprintf(":%s - -", egressNetPolChain),
 		// policy 1
-		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, ingressDropRule),
-		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, ingressAllowRule),
-		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, egressDropRule),
-		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, egressAllowRule),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolIngressChain, ingressDropRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolIngressChain, ingressAllowRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolEgressChain, egressDropRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolEgressChain, egressAllowRule)),
 		fmt.Sprintf("-I AZURE-NPM-INGRESS 1 %s", ingressEgressNetPolIngressJump),
 		fmt.Sprintf("-I AZURE-NPM-EGRESS 1 %s", ingressEgressNetPolEgressJump),
 		// policy 2
-		fmt.Sprintf("-A %s %s", ingressNetPolChain, ingressDropRule),
+		fmt.Sprintf("-A %s %s", ingressNetPolChain, tagged(ingressNetPol, ingressNetPolChain, ingressDropRule)),
 		fmt.Sprintf("-I AZURE-NPM-INGRESS 2 %s", ingressNetPolJump),
 		// policy 3
-		fmt.Sprintf("-A %s %s", egressNetPolChain, egressAllowRule),
+		fmt.Sprintf("-A %s %s", egressNetPolChain, tagged(egressNetPol, egressNetPolChain, egressAllowRule)),
 		fmt.Sprintf("-I AZURE-NPM-EGRESS 2 %s", egressNetPolJump),
 		"COMMIT",
 		"",
@@ -282,6 +287,85 @@ func TestCreatorForAddPolicies(t *testing.T) {
 	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
 }
 
+func TestCreatorForAddPoliciesWithAllowlist(t *testing.T) {
+	apiServer, err := NewAllowlistEntry("10.0.0.4", TCP, 443)
+	require.NoError(t, err)
+	nodes, err := NewAllowlistEntry("10.224.0.0/16", "", 0)
+	require.NoError(t, err)
+	cfg := &PolicyManagerCfg{
+		PolicyMode:           IPSetPolicyMode,
+		PlaceAzureChainFirst: util.PlaceAzureChainFirst,
+		Allowlist:            []*AllowlistEntry{apiServer, nodes},
+	}
+	pMgr := NewPolicyManager(common.NewMockIOShim(nil), cfg)
+
+	policies := []*NPMNetworkPolicy{allTestNetworkPolicies[0]}
+	creator := pMgr.creatorForNewNetworkPolicies(chainNames(policies), policies)
+	actualLines := strings.Split(creator.ToString(), "\n")
+	expectedLines := []string{
+		"*filter",
+		fmt.Sprintf(":%s - -", bothDirectionsNetPolIngressChain),
+		fmt.Sprintf(":%s - -", bothDirectionsNetPolEgressChain),
+		"-F AZURE-NPM",
+		// allowlist ahead of the activation rules
+		"-A AZURE-NPM -j AZURE-NPM-ACCEPT -d 10.0.0.4/32 -p TCP --dport 443 -m comment --comment ALLOWLIST-10.0.0.4/32-TCP-443",
+		"-A AZURE-NPM -j AZURE-NPM-ACCEPT -s 10.0.0.4/32 -p TCP --dport 443 -m comment --comment ALLOWLIST-10.0.0.4/32-TCP-443",
+		"-A AZURE-NPM -j AZURE-NPM-ACCEPT -d 10.224.0.0/16 -m comment --comment ALLOWLIST-10.224.0.0/16",
+		"-A AZURE-NPM -j AZURE-NPM-ACCEPT -s 10.224.0.0/16 -m comment --comment ALLOWLIST-10.224.0.0/16",
+		"-A AZURE-NPM -j AZURE-NPM-INGRESS",
+		"-A AZURE-NPM -j AZURE-NPM-EGRESS",
+		"-A AZURE-NPM -j AZURE-NPM-ACCEPT",
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolIngressChain, ingressDropRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolIngressChain, ingressAllowRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolEgressChain, egressDropRule)),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, tagged(bothDirectionsNetPol, bothDirectionsNetPolEgressChain, egressAllowRule)),
+		fmt.Sprintf("-I AZURE-NPM-INGRESS 1 %s", ingressEgressNetPolIngressJump),
+		fmt.Sprintf("-I AZURE-NPM-EGRESS 1 %s", ingressEgressNetPolEgressJump),
+		"COMMIT",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestCreatorForAddPoliciesWithRejectDenied(t *testing.T) {
+	pMgr := NewPolicyManager(common.NewMockIOShim(nil), ipsetConfig)
+	rejectingNetPol := *bothDirectionsNetPol
+	rejectingNetPol.RejectDenied = true
+	policies := []*NPMNetworkPolicy{&rejectingNetPol}
+	creator := pMgr.creatorForNewNetworkPolicies(chainNames(policies), policies)
+	actualLines := strings.Split(creator.ToString(), "\n")
+
+	// the denied traffic is marked for rejection, the allowed traffic is unchanged
+	ingressRejectRule := strings.Replace(ingressDropRule, util.IptablesAzureIngressDropMarkHex, util.IptablesAzureIngressRejectMarkHex, 1)
+	egressRejectRule := strings.Replace(egressDropRule, util.IptablesAzureEgressDropMarkHex, util.IptablesAzureEgressRejectMarkHex, 1)
+	require.Contains(t, actualLines, fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, tagged(&rejectingNetPol, bothDirectionsNetPolIngressChain, ingressRejectRule)))
+	require.Contains(t, actualLines, fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, tagged(&rejectingNetPol, bothDirectionsNetPolIngressChain, ingressAllowRule)))
+	require.Contains(t, actualLines, fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, tagged(&rejectingNetPol, bothDirectionsNetPolEgressChain, egressRejectRule)))
+	require.Contains(t, actualLines, fmt.Sprintf("-A %s %s", bothDirectionsNetPolEgressChain, tagged(&rejectingNetPol, bothDirectionsNetPolEgressChain, egressAllowRule)))
+}
+
+func TestCreatorForAddPoliciesProvenance(t *testing.T) {
+	pMgr := NewPolicyManager(common.NewMockIOShim(nil), ipsetConfig)
+	creator := pMgr.creatorForNewNetworkPolicies(chainNames(allTestNetworkPolicies), allTestNetworkPolicies)
+
+	// every rule of a policy can be attributed to it, unlike the activation rules
+	numTagged := make(map[string]int)
+	for _, line := range strings.Split(creator.ToString(), "\n") {
+		provenance, ok := ProvenanceOfRule(line)
+		if !strings.HasPrefix(line, "-A AZURE-NPM-") && !strings.HasPrefix(line, "-I AZURE-NPM-") {
+			require.False(t, ok, line)
+			continue
+		}
+		require.True(t, ok, line)
+		numTagged[provenance.PolicyKey()]++
+	}
+	require.Equal(t, map[string]int{
+		bothDirectionsNetPol.PolicyKey: 6,
+		ingressNetPol.PolicyKey:        2,
+		egressNetPol.PolicyKey:         2,
+	}, numTagged)
+}
+
 func TestCreatorForRemovePolicies(t *testing.T) {
 	calls := []testutils.TestCmd{fakeIPTablesRestoreCommand}
 	ioshim := common.NewMockIOShim(calls)
@@ -110,7 +110,7 @@ func TestBootup(t *testing.T) {
 
 	require.NoError(t, pMgr.Bootup(epIDs))
 
-	expectedNumACLs := 11
+	expectedNumACLs := 15
 	if util.IsWindowsDP() {
 		expectedNumACLs = 0
 	}
@@ -135,6 +135,16 @@ func TestAddPolicy(t *testing.T) {
 		numTestNetPolACLRulesProducedInKernel *= numEndpoints
 	}
 	promVals{numTestNetPolACLRulesProducedInKernel, 1}.testPrometheusMetrics(t)
+
+	size, err := metrics.GetPolicyDataplaneSize(testNetPol.PolicyKey)
+	require.NoError(t, err)
+	expectedRules := 3
+	if util.IsWindowsDP() {
+		expectedRules = len(testNetPol.ACLs)
+	}
+	require.Equal(t, expectedRules, size.Rules)
+	require.Equal(t, 2, size.IPSets)
+	require.Positive(t, size.ConfigBytes)
 }
 
 func TestAddEmptyPolicy(t *testing.T) {
@@ -191,6 +201,10 @@ func TestRemovePolicy(t *testing.T) {
 	_, ok := pMgr.GetPolicy(testNetPol.PolicyKey)
 	require.False(t, ok)
 	promVals{0, 1}.testPrometheusMetrics(t)
+
+	size, err := metrics.GetPolicyDataplaneSize(testNetPol.PolicyKey)
+	require.NoError(t, err)
+	require.Equal(t, &metrics.PolicyDataplaneSize{}, size)
 }
 
 // see policymanager_linux.go for testing when an error occurs
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/npm/metrics"
@@ -33,6 +34,9 @@ func newStaleChains() *staleChains {
 }
 
 func (pMgr *PolicyManager) bootup(epIDs []string) error {
+	// resetting the ACLs removes the allowlist too
+	pMgr.allowlistEndpoints = make(map[string]struct{})
+
 	var aggregateErr error
 	for _, epID := range epIDs {
 		err := pMgr.removePolicyByEndpointID("", epID, 0, resetAllACLs)
@@ -52,8 +56,95 @@ func (pMgr *PolicyManager) bootup(epIDs []string) error {
 	return nil
 }
 
+// reconcile compacts the priorities of the NPM ACLs on the endpoints.
+// It only runs during quiet periods: if policies are being added or removed, it waits for the next reconcile.
 func (pMgr *PolicyManager) reconcile() {
-	// not implemented
+	if !pMgr.policyMap.TryLock() {
+		klog.Infof("[PolicyManagerWindows] skipping ACL priority compaction since policies are being updated")
+		return
+	}
+	defer pMgr.policyMap.Unlock()
+
+	var aggregateErr error
+	for epID := range pMgr.endpointsWithACLs() {
+		if err := pMgr.compactACLPriorities(epID); err != nil {
+			if aggregateErr == nil {
+				aggregateErr = fmt.Errorf("failed to compact ACL priorities on %s ID Endpoint with err: %w", epID, err)
+			} else {
+				aggregateErr = fmt.Errorf("failed to compact ACL priorities on %s ID Endpoint with err: %s. previous err: [%w]", epID, err.Error(), aggregateErr)
+			}
+		}
+	}
+	if aggregateErr != nil {
+		metrics.SendErrorLogAndMetric(util.IptmID, "error: [PolicyManagerWindows] %s", aggregateErr.Error())
+	}
+}
+
+// GetACLPriorityUtilization returns the priority utilization of the NPM ACLs for each endpoint with policies.
+func (pMgr *PolicyManager) GetACLPriorityUtilization() (map[string]*ACLPriorityUtilization, error) {
+	pMgr.policyMap.RLock()
+	defer pMgr.policyMap.RUnlock()
+
+	utilization := make(map[string]*ACLPriorityUtilization)
+	for epID := range pMgr.endpointsWithACLs() {
+		epObj, err := pMgr.ioShim.Hns.GetEndpointByID(epID)
+		if err != nil {
+			if isNotFoundErr(err) || strings.Contains(err.Error(), "endpoint was not found") {
+				continue
+			}
+			return nil, fmt.Errorf("[PolicyManagerWindows] failed to get the endpoint. endpoint: %s, err: %w", epID, err)
+		}
+		epBuilder, err := splitEndpointPolicies(epObj.Policies)
+		if err != nil {
+			return nil, fmt.Errorf("[PolicyManagerWindows] couldn't split endpoint policies. endpoint: %s, err: %w", epID, err)
+		}
+		utilization[epID] = epBuilder.priorityUtilization()
+	}
+	return utilization, nil
+}
+
+// endpointsWithACLs returns the IDs of the endpoints which NPM has applied ACLs to.
+// The caller must hold the policyMap lock.
+func (pMgr *PolicyManager) endpointsWithACLs() map[string]struct{} {
+	epIDs := make(map[string]struct{}, len(pMgr.allowlistEndpoints))
+	for epID := range pMgr.allowlistEndpoints {
+		epIDs[epID] = struct{}{}
+	}
+	for _, policy := range pMgr.policyMap.cache {
+		for _, epID := range policy.PodEndpoints {
+			epIDs[epID] = struct{}{}
+		}
+	}
+	return epIDs
+}
+
+// compactACLPriorities renumbers the NPM ACLs on the endpoint if their priorities or order have drifted.
+func (pMgr *PolicyManager) compactACLPriorities(epID string) error {
+	epObj, err := pMgr.ioShim.Hns.GetEndpointByID(epID)
+	if err != nil {
+		if isNotFoundErr(err) || strings.Contains(err.Error(), "endpoint was not found") {
+			return nil
+		}
+		return fmt.Errorf("failed to get the endpoint: %w", err)
+	}
+
+	epBuilder, err := splitEndpointPolicies(epObj.Policies)
+	if err != nil {
+		return fmt.Errorf("couldn't split endpoint policies: %w", err)
+	}
+	if !epBuilder.compactNPMACLs() {
+		return nil
+	}
+
+	klog.Infof("[PolicyManagerWindows] compacting ACL priorities on %s ID Endpoint", epID)
+	epPolicies, err := epBuilder.getHCNPolicyRequest()
+	if err != nil {
+		return fmt.Errorf("unable to get HCN policy request: %w", err)
+	}
+	if err := pMgr.ioShim.Hns.ApplyEndpointPolicy(epObj, hcn.RequestTypeUpdate, epPolicies); err != nil {
+		return fmt.Errorf("unable to apply compacted ACLs: %w", err)
+	}
+	return nil
 }
 
 // addPolicy will add the policy for each specified endpoint if the policy doesn't exist on the endpoint yet,
@@ -65,6 +156,10 @@ func (pMgr *PolicyManager) addPolicy(policy *NPMNetworkPolicy, endpointList map[
 		return nil
 	}
 	klog.Infof("[PolicyManagerWindows] adding policy %s on %+v", policy.PolicyKey, endpointList)
+	if policy.RejectDenied {
+		// HNS ACLs can only allow or block, so blocking is the closest to rejecting
+		klog.Infof("[PolicyManagerWindows] blocking instead of rejecting the traffic denied by policy %s", policy.PolicyKey)
+	}
 
 	// 1. remove stale endpoints from policy.PodEndpoints and skip adding to endpoints that already have the policy
 	if policy.PodEndpoints == nil {
@@ -105,10 +200,20 @@ func (pMgr *PolicyManager) addPolicy(policy *NPMNetworkPolicy, endpointList map[
 	if err != nil {
 		return err
 	}
+	// the allowlist is added along with the first policy on an endpoint so that no policy can block it
+	epPolicyRequestWithAllowlist, err := getEPPolicyReqFromACLSettings(append(rulesToAdd, pMgr.allowlistSettings()...))
+	if err != nil {
+		return err
+	}
 
 	var aggregateErr error
 	for epIP, epID := range endpointList {
-		err = pMgr.applyPoliciesToEndpointID(epID, epPolicyRequest)
+		request := epPolicyRequest
+		_, hasAllowlist := pMgr.allowlistEndpoints[epID]
+		if !hasAllowlist {
+			request = epPolicyRequestWithAllowlist
+		}
+		err = pMgr.applyPoliciesToEndpointID(epID, request)
 		if err != nil {
 			klog.Errorf("failed to add policy to kernel. policy %s, endpoint: %s, err: %s", policy.PolicyKey, epID, err.Error())
 			// Do not return if one endpoint fails, try all endpoints.
@@ -122,11 +227,24 @@ func (pMgr *PolicyManager) addPolicy(policy *NPMNetworkPolicy, endpointList map[
 		}
 		// Now update policy cache to reflect new endpoint
 		policy.PodEndpoints[epIP] = epID
+		if len(pMgr.Allowlist) > 0 {
+			pMgr.allowlistEndpoints[epID] = struct{}{}
+		}
 	}
 
 	if aggregateErr != nil {
 		return fmt.Errorf("[PolicyManagerWindows] %w", aggregateErr)
 	}
+
+	configBytes := 0
+	for _, epPolicy := range epPolicyRequest.Policies {
+		configBytes += len(epPolicy.Settings)
+	}
+	metrics.SetPolicyDataplaneSize(policy.PolicyKey, &metrics.PolicyDataplaneSize{
+		Rules:       len(rulesToAdd),
+		IPSets:      policy.numIPSets(),
+		ConfigBytes: configBytes,
+	})
 	return nil
 }
 
@@ -179,6 +297,7 @@ func (pMgr *PolicyManager) removePolicyByEndpointID(ruleID, epID string, noOfRul
 		// IsNotFound check is being skipped at times. So adding a redundant check here.
 		if isNotFoundErr(err) || strings.Contains(err.Error(), "endpoint was not found") {
 			klog.Infof("[PolicyManagerWindows] ignoring remove policy since the endpoint wasn't found. the corresponding pod might be deleted. policy: %s, endpoint: %s, err: %s", ruleID, epID, err.Error())
+			delete(pMgr.allowlistEndpoints, epID)
 			return nil
 		}
 		return fmt.Errorf("[PolicyManagerWindows] failed to remove policy while getting the endpoint. policy: %s, endpoint: %s, err: %w", ruleID, epID, err)
@@ -242,6 +361,14 @@ func (pMgr *PolicyManager) applyPoliciesToEndpointID(epID string, policies hcn.P
 	return nil
 }
 
+func (pMgr *PolicyManager) allowlistSettings() []*NPMACLPolSettings {
+	settings := make([]*NPMACLPolSettings, 0, 2*len(pMgr.Allowlist))
+	for _, entry := range pMgr.Allowlist {
+		settings = append(settings, entry.allowlistSettings()...)
+	}
+	return settings
+}
+
 // getEPPolicyReqFromACLSettings converts given ACLSettings into PolicyEndpointRequest
 func getEPPolicyReqFromACLSettings(settings []*NPMACLPolSettings) (hcn.PolicyEndpointRequest, error) {
 	policyToAdd := hcn.PolicyEndpointRequest{
@@ -368,6 +495,50 @@ func (epBuilder *endpointPolicyBuilder) resetAllNPMAclPolicies() bool {
 	return aclFound
 }
 
+// compactNPMACLs gives each NPM ACL the priority of its kind (see aclPriority) and orders the ACLs by priority.
+// The sort is stable so that ACLs with the same priority keep their order. Returns whether any ACL changed.
+func (epBuilder *endpointPolicyBuilder) compactNPMACLs() bool {
+	changed := false
+	for _, acl := range epBuilder.aclPolicies {
+		if !strings.HasPrefix(acl.Id, policyIDPrefix) {
+			continue
+		}
+		if priority := aclPriority(acl.Id, acl.Action); acl.Priority != priority {
+			acl.Priority = priority
+			changed = true
+		}
+	}
+
+	byPriority := func(i, j int) bool {
+		return epBuilder.aclPolicies[i].Priority < epBuilder.aclPolicies[j].Priority
+	}
+	if !sort.SliceIsSorted(epBuilder.aclPolicies, byPriority) {
+		sort.SliceStable(epBuilder.aclPolicies, byPriority)
+		changed = true
+	}
+	return changed
+}
+
+func (epBuilder *endpointPolicyBuilder) priorityUtilization() *ACLPriorityUtilization {
+	utilization := &ACLPriorityUtilization{}
+	priorities := make(map[uint16]struct{})
+	for _, acl := range epBuilder.aclPolicies {
+		if !strings.HasPrefix(acl.Id, policyIDPrefix) {
+			continue
+		}
+		utilization.NumACLs++
+		priorities[acl.Priority] = struct{}{}
+		if acl.Priority > utilization.MaxPriority {
+			utilization.MaxPriority = acl.Priority
+		}
+		if acl.Priority != aclPriority(acl.Id, acl.Action) {
+			utilization.NumToCompact++
+		}
+	}
+	utilization.NumPriorities = len(priorities)
+	return utilization
+}
+
 func (epBuilder *endpointPolicyBuilder) removeACLPolicyAtIndex(indexes map[int]struct{}) {
 	if len(indexes) == 0 {
 		return
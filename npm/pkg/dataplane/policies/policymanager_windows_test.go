@@ -248,3 +248,101 @@ func getPortStr(start, end int32) string {
 
 	return portStr
 }
+
+func TestAddPoliciesWithAllowlist(t *testing.T) {
+	pMgr, hns := getPMgr(t)
+	apiServer, err := NewAllowlistEntry("10.0.0.4", TCP, 443)
+	require.NoError(t, err)
+	pMgr.PolicyManagerCfg = &PolicyManagerCfg{
+		PolicyMode: IPSetPolicyMode,
+		Allowlist:  []*AllowlistEntry{apiServer},
+	}
+	expectedAllowlistACLs := []*hnswrapper.FakeEndpointPolicy{
+		{
+			ID:             allowlistPolicyID,
+			Protocols:      "6",
+			Direction:      "In",
+			Action:         "Allow",
+			LocalAddresses: "10.0.0.4/32",
+			LocalPorts:     "443",
+			Priority:       allowlistPriority,
+		},
+		{
+			ID:              allowlistPolicyID,
+			Protocols:       "6",
+			Direction:       "Out",
+			Action:          "Allow",
+			RemoteAddresses: "10.0.0.4/32",
+			RemotePorts:     "443",
+			Priority:        allowlistPriority,
+		},
+	}
+
+	// the allowlist is added once per endpoint and stays after the policies are removed
+	require.NoError(t, pMgr.AddPolicy(TestNetworkPolicies[0], endpointIDListCopy()))
+	require.NoError(t, pMgr.AddPolicy(TestNetworkPolicies[1], endpointIDListCopy()))
+	require.NoError(t, pMgr.RemovePolicy(TestNetworkPolicies[0].PolicyKey))
+
+	aclPolicies, err := hns.Cache.ACLPolicies(endPointIDList, allowlistPolicyID)
+	require.NoError(t, err)
+	for _, id := range endPointIDList {
+		verifyFakeHNSCacheACLs(t, expectedAllowlistACLs, aclPolicies[id])
+	}
+
+	// bootup resets the allowlist
+	require.NoError(t, pMgr.Bootup([]string{"test1", "test2"}))
+	require.Empty(t, pMgr.allowlistEndpoints)
+	aclPolicies, err = hns.Cache.ACLPolicies(endPointIDList, allowlistPolicyID)
+	require.NoError(t, err)
+	for _, id := range endPointIDList {
+		require.Empty(t, aclPolicies[id])
+	}
+}
+
+func TestCompactACLPriorities(t *testing.T) {
+	pMgr, hns := getPMgr(t)
+	require.NoError(t, pMgr.AddPolicy(TestNetworkPolicies[0], endpointIDListCopy()))
+
+	utilization, err := pMgr.GetACLPriorityUtilization()
+	require.NoError(t, err)
+	require.Len(t, utilization, len(endPointIDList))
+	for _, id := range endPointIDList {
+		require.Equal(t, &ACLPriorityUtilization{
+			NumACLs:       len(expectedACLs),
+			NumPriorities: 2,
+			MaxPriority:   blockRulePriotity,
+		}, utilization[id])
+	}
+
+	// drift the priorities of an endpoint, e.g. from an older NPM
+	for _, acl := range hns.Cache.GetAllACLs()["test1"] {
+		acl.Priority += 1000
+	}
+	utilization, err = pMgr.GetACLPriorityUtilization()
+	require.NoError(t, err)
+	require.Equal(t, len(expectedACLs), utilization["test1"].NumToCompact)
+	require.Equal(t, uint16(blockRulePriotity+1000), utilization["test1"].MaxPriority)
+	require.Zero(t, utilization["test2"].NumToCompact)
+
+	pMgr.Reconcile()
+	utilization, err = pMgr.GetACLPriorityUtilization()
+	require.NoError(t, err)
+	require.Zero(t, utilization["test1"].NumToCompact)
+
+	aclPolicies, err := hns.Cache.ACLPolicies(endPointIDList, TestNetworkPolicies[0].ACLPolicyID)
+	require.NoError(t, err)
+	acls := aclPolicies["test1"]
+	verifyFakeHNSCacheACLs(t, expectedACLs, acls)
+	for i := 1; i < len(acls); i++ {
+		require.LessOrEqual(t, acls[i-1].Priority, acls[i].Priority, "ACLs should be ordered by priority")
+	}
+
+	// compaction waits while policies are being updated
+	pMgr.policyMap.Lock()
+	hns.Cache.GetAllACLs()["test2"][0].Priority = 1
+	pMgr.Reconcile()
+	pMgr.policyMap.Unlock()
+	utilization, err = pMgr.GetACLPriorityUtilization()
+	require.NoError(t, err)
+	require.Equal(t, 1, utilization["test2"].NumToCompact)
+}
@@ -0,0 +1,162 @@
+package policies
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+)
+
+// FakePolicyManager is an in-memory Manager for tests which don't need the OS. It keeps the policies like
+// PolicyManager, and the endpoints each policy was applied to. See the conformance tests for the shared behavior.
+type FakePolicyManager struct {
+	sync.Mutex
+	policies       map[string]*NPMNetworkPolicy
+	endpoints      map[string]map[string]string
+	bootupCount    int
+	reconcileCount int
+	err            error
+}
+
+var _ Manager = (*FakePolicyManager)(nil)
+
+func NewFakePolicyManager() *FakePolicyManager {
+	return &FakePolicyManager{
+		policies:  make(map[string]*NPMNetworkPolicy),
+		endpoints: make(map[string]map[string]string),
+	}
+}
+
+// PolicyKeys returns the sorted keys of the policies.
+func (f *FakePolicyManager) PolicyKeys() []string {
+	f.Lock()
+	defer f.Unlock()
+	keys := make([]string, 0, len(f.policies))
+	for key := range f.policies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Endpoints returns the endpoints the policy is applied to, by IP. It is empty for policies added without
+// endpoints, as they are in Linux.
+func (f *FakePolicyManager) Endpoints(policyKey string) map[string]string {
+	f.Lock()
+	defer f.Unlock()
+	endpoints := make(map[string]string, len(f.endpoints[policyKey]))
+	for ip, id := range f.endpoints[policyKey] {
+		endpoints[ip] = id
+	}
+	return endpoints
+}
+
+// BootupCount returns the number of calls to Bootup.
+func (f *FakePolicyManager) BootupCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.bootupCount
+}
+
+// ReconcileCount returns the number of calls to Reconcile.
+func (f *FakePolicyManager) ReconcileCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.reconcileCount
+}
+
+// SetError makes Bootup and the changes of policies fail with err, without changing anything, until it is
+// called again with nil.
+func (f *FakePolicyManager) SetError(err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.err = err
+}
+
+func (f *FakePolicyManager) Bootup(_ []string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.bootupCount++
+	if f.err != nil {
+		return npmerrors.ErrorWrapper(npmerrors.BootupPolicyMgr, false, "failed to bootup policy manager", f.err)
+	}
+	return nil
+}
+
+func (f *FakePolicyManager) Reconcile() {
+	f.Lock()
+	defer f.Unlock()
+	f.reconcileCount++
+}
+
+func (f *FakePolicyManager) PolicyExists(policyKey string) bool {
+	f.Lock()
+	defer f.Unlock()
+	_, ok := f.policies[policyKey]
+	return ok
+}
+
+func (f *FakePolicyManager) GetPolicy(policyKey string) (*NPMNetworkPolicy, bool) {
+	f.Lock()
+	defer f.Unlock()
+	policy, ok := f.policies[policyKey]
+	return policy, ok
+}
+
+func (f *FakePolicyManager) AddPolicy(policy *NPMNetworkPolicy, endpointList map[string]string) error {
+	if len(policy.ACLs) == 0 {
+		return nil
+	}
+
+	NormalizePolicy(policy)
+	if err := ValidatePolicy(policy); err != nil {
+		return npmerrors.Errorf(npmerrors.AddPolicy, false, fmt.Sprintf("failed to validate policy: %s", err.Error()))
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return npmerrors.Errorf(npmerrors.AddPolicy, false, fmt.Sprintf("failed to add policy: %s", f.err.Error()))
+	}
+
+	f.policies[policy.PolicyKey] = policy
+	if f.endpoints[policy.PolicyKey] == nil {
+		f.endpoints[policy.PolicyKey] = make(map[string]string)
+	}
+	for ip, id := range endpointList {
+		f.endpoints[policy.PolicyKey][ip] = id
+	}
+	return nil
+}
+
+func (f *FakePolicyManager) RemovePolicy(policyKey string) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.policies[policyKey]; !ok {
+		return nil
+	}
+	if f.err != nil {
+		return npmerrors.Errorf(npmerrors.RemovePolicy, false, fmt.Sprintf("failed to remove policy: %s", f.err.Error()))
+	}
+
+	delete(f.policies, policyKey)
+	delete(f.endpoints, policyKey)
+	return nil
+}
+
+func (f *FakePolicyManager) RemovePolicyForEndpoints(policyKey string, endpointList map[string]string) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.policies[policyKey]; !ok {
+		return nil
+	}
+	if f.err != nil {
+		return npmerrors.Errorf(npmerrors.RemovePolicy, false, fmt.Sprintf("failed to remove policy. endpoints: [%+v]. err: [%s]", endpointList, f.err.Error()))
+	}
+
+	for ip := range endpointList {
+		delete(f.endpoints[policyKey], ip)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package policies
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakePolicyManager(t *testing.T) {
+	f := NewFakePolicyManager()
+	require.NoError(t, f.Bootup(epIDs))
+	f.Reconcile()
+	require.Equal(t, 1, f.BootupCount())
+	require.Equal(t, 1, f.ReconcileCount())
+
+	netpol := testNetworkPolicy()
+	require.NoError(t, f.AddPolicy(netpol, map[string]string{"10.0.0.1": "test123"}))
+	require.NoError(t, f.AddPolicy(netpol, map[string]string{"10.0.0.2": "test456"}))
+	require.Equal(t, []string{netpol.PolicyKey}, f.PolicyKeys())
+	require.Equal(t, epList, f.Endpoints(netpol.PolicyKey))
+
+	require.NoError(t, f.RemovePolicyForEndpoints(netpol.PolicyKey, map[string]string{"10.0.0.1": "test123"}))
+	require.Equal(t, map[string]string{"10.0.0.2": "test456"}, f.Endpoints(netpol.PolicyKey))
+
+	// nothing changes while failing
+	errFake := errors.New("fake error")
+	f.SetError(errFake)
+	require.ErrorContains(t, f.Bootup(nil), errFake.Error())
+	require.Error(t, f.AddPolicy(testNetworkPolicy(), epList))
+	require.Error(t, f.RemovePolicy(netpol.PolicyKey))
+	require.True(t, f.PolicyExists(netpol.PolicyKey))
+
+	f.SetError(nil)
+	require.NoError(t, f.RemovePolicy(netpol.PolicyKey))
+	require.Empty(t, f.PolicyKeys())
+	require.Empty(t, f.Endpoints(netpol.PolicyKey))
+}
@@ -0,0 +1,23 @@
+package policies
+
+import "math"
+
+// maxACLPriority is the highest priority an HNS ACL can have
+const maxACLPriority = math.MaxUint16
+
+// ACLPriorityUtilization describes the priorities of the NPM ACLs on an HNS endpoint. Only used in Windows.
+type ACLPriorityUtilization struct {
+	// NumACLs is the number of NPM ACLs on the endpoint
+	NumACLs int
+	// NumPriorities is the number of distinct priorities among the NPM ACLs
+	NumPriorities int
+	// MaxPriority is the highest priority among the NPM ACLs
+	MaxPriority uint16
+	// NumToCompact is the number of NPM ACLs which compaction would renumber
+	NumToCompact int
+}
+
+// Utilization returns the fraction of the ACL priority range in use on the endpoint.
+func (u *ACLPriorityUtilization) Utilization() float64 {
+	return float64(u.MaxPriority) / float64(maxACLPriority)
+}
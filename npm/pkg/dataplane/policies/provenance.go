@@ -0,0 +1,68 @@
+package policies
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// provenancePrefix starts the provenance tag which every iptables rule of a network policy has as a comment:
+// NPM-POLICY:<namespace>/<name>:<rule hash>
+const provenancePrefix = "NPM-POLICY:"
+
+var ErrInvalidProvenance = errors.New("invalid provenance tag")
+
+// RuleProvenance is the NetworkPolicy which an iptables rule was programmed for.
+type RuleProvenance struct {
+	Namespace string
+	Name      string
+	// RuleHash identifies the rule among the rules of the policy. It doesn't depend on the position of the rule.
+	RuleHash string
+}
+
+// PolicyKey returns the "namespace/name" key of the policy, like NPMNetworkPolicy.PolicyKey.
+func (p *RuleProvenance) PolicyKey() string {
+	return fmt.Sprintf("%s/%s", p.Namespace, p.Name)
+}
+
+// provenanceComment returns the provenance tag of a rule in the chain with the specs, which shouldn't include the tag
+func provenanceComment(policyKey, chainName string, specs []string) string {
+	ruleHash := util.Hash(chainName + " " + strings.Join(specs, " "))
+	return fmt.Sprintf("%s%s:%s", provenancePrefix, policyKey, ruleHash)
+}
+
+// ParseProvenanceComment parses the provenance tag of a rule, e.g. "NPM-POLICY:x/deny-all:2166136261".
+func ParseProvenanceComment(comment string) (*RuleProvenance, error) {
+	comment = strings.Trim(comment, `"`)
+	if !strings.HasPrefix(comment, provenancePrefix) {
+		return nil, fmt.Errorf("%w: missing prefix %s in %s", ErrInvalidProvenance, provenancePrefix, comment)
+	}
+	// namespaces, names, and hashes can't contain a colon
+	parts := strings.Split(strings.TrimPrefix(comment, provenancePrefix), ":")
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProvenance, comment)
+	}
+	namespace, name, ok := strings.Cut(parts[0], "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("%w: bad policy key in %s", ErrInvalidProvenance, comment)
+	}
+	return &RuleProvenance{Namespace: namespace, Name: name, RuleHash: parts[1]}, nil
+}
+
+// ProvenanceOfRule finds and parses the provenance tag of a rule as listed by iptables -S or iptables-save.
+// It returns false for rules without a tag, e.g. the ones which NPM didn't program for a policy.
+func ProvenanceOfRule(rule string) (*RuleProvenance, bool) {
+	fields := strings.Fields(rule)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] != util.IptablesCommentFlag {
+			continue
+		}
+		provenance, err := ParseProvenanceComment(fields[i+1])
+		if err == nil {
+			return provenance, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,50 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenanceComment(t *testing.T) {
+	comment := provenanceComment("x/deny-all", "AZURE-NPM-INGRESS", []string{"-j", "AZURE-NPM-INGRESS-123"})
+	require.Regexp(t, `^NPM-POLICY:x/deny-all:\d+$`, comment)
+	require.NotEqual(t, comment, provenanceComment("x/deny-all", "AZURE-NPM-EGRESS", []string{"-j", "AZURE-NPM-INGRESS-123"}))
+
+	provenance, err := ParseProvenanceComment(comment)
+	require.NoError(t, err)
+	require.Equal(t, "x", provenance.Namespace)
+	require.Equal(t, "deny-all", provenance.Name)
+	require.Equal(t, "x/deny-all", provenance.PolicyKey())
+}
+
+func TestParseProvenanceComment(t *testing.T) {
+	provenance, err := ParseProvenanceComment(`"NPM-POLICY:kube-system/allow.dns:2166136261"`)
+	require.NoError(t, err)
+	require.Equal(t, &RuleProvenance{Namespace: "kube-system", Name: "allow.dns", RuleHash: "2166136261"}, provenance)
+
+	for _, comment := range []string{
+		"ALLOW-ALL",
+		"NPM-POLICY:",
+		"NPM-POLICY:x/deny-all",
+		"NPM-POLICY:x/deny-all:",
+		"NPM-POLICY:deny-all:123",
+		"NPM-POLICY:/deny-all:123",
+		"NPM-POLICY:x/deny-all:1:2",
+	} {
+		_, err := ParseProvenanceComment(comment)
+		require.ErrorIs(t, err, ErrInvalidProvenance, comment)
+	}
+}
+
+func TestProvenanceOfRule(t *testing.T) {
+	rule := `-A AZURE-NPM-INGRESS-123 -j MARK --set-mark 0x4000/0x4000 -m comment --comment DROP-ALL -m comment --comment "NPM-POLICY:x/deny-all:42"`
+	provenance, ok := ProvenanceOfRule(rule)
+	require.True(t, ok)
+	require.Equal(t, &RuleProvenance{Namespace: "x", Name: "deny-all", RuleHash: "42"}, provenance)
+
+	_, ok = ProvenanceOfRule("-A AZURE-NPM -j AZURE-NPM-INGRESS")
+	require.False(t, ok)
+	_, ok = ProvenanceOfRule("-A AZURE-NPM -j AZURE-NPM-ACCEPT -m comment --comment ALLOWLIST-10.0.0.4/32")
+	require.False(t, ok)
+}
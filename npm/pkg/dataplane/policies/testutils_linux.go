@@ -3,6 +3,7 @@ package policies
 import (
 	"strings"
 
+	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/util"
 	testutils "github.com/Azure/azure-container-networking/test/utils"
 )
@@ -65,6 +66,24 @@ func GetBootupTestCalls() []testutils.TestCmd {
 	}
 }
 
+// RenderPolicy returns the iptables-restore file which adds the policy when no other policy is applied.
+// The policy is normalized and validated as in AddPolicy, and a policy without ACLs renders to nothing.
+func RenderPolicy(policy *NPMNetworkPolicy) (string, error) {
+	if len(policy.ACLs) == 0 {
+		return "", nil
+	}
+
+	NormalizePolicy(policy)
+	if err := ValidatePolicy(policy); err != nil {
+		return "", err
+	}
+
+	pMgr := NewPolicyManager(common.NewMockIOShim(nil), &PolicyManagerCfg{PolicyMode: IPSetPolicyMode})
+	networkPolicies := []*NPMNetworkPolicy{policy}
+	creator := pMgr.creatorForNewNetworkPolicies(chainNames(networkPolicies), networkPolicies)
+	return creator.ToString(), nil
+}
+
 func getFakeDeleteJumpCommand(chainName, jumpRule string) testutils.TestCmd {
 	args := []string{"iptables", "-w", "60", "-D", chainName}
 	args = append(args, strings.Split(jumpRule, " ")...)
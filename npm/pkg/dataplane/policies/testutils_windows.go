@@ -1,6 +1,11 @@
 package policies
 
-import testutils "github.com/Azure/azure-container-networking/test/utils"
+import (
+	"encoding/json"
+	"fmt"
+
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+)
 
 func GetAddPolicyTestCalls(_ *NPMNetworkPolicy) []testutils.TestCmd {
 	return []testutils.TestCmd{}
@@ -13,3 +18,27 @@ func GetRemovePolicyTestCalls(_ *NPMNetworkPolicy) []testutils.TestCmd {
 func GetBootupTestCalls() []testutils.TestCmd {
 	return []testutils.TestCmd{}
 }
+
+// RenderPolicy returns the ACL settings which are added to each endpoint selected by the policy, as indented JSON.
+// The policy is normalized and validated as in AddPolicy, and a policy without ACLs renders to nothing.
+func RenderPolicy(policy *NPMNetworkPolicy) (string, error) {
+	if len(policy.ACLs) == 0 {
+		return "", nil
+	}
+
+	NormalizePolicy(policy)
+	if err := ValidatePolicy(policy); err != nil {
+		return "", err
+	}
+
+	rules, err := getSettingsFromACL(policy)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert ACLs: %w", err)
+	}
+
+	b, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal ACL settings: %w", err)
+	}
+	return string(b) + "\n", nil
+}
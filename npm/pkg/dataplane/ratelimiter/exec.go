@@ -0,0 +1,92 @@
+package ratelimiter
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	utilexec "k8s.io/utils/exec"
+)
+
+// WrapExec returns an exec which waits for the limiter before running a command which writes to ipset or iptables.
+func (l *Limiter) WrapExec(exec utilexec.Interface) utilexec.Interface {
+	if l == nil {
+		return exec
+	}
+	return &limitedExec{Interface: exec, limiter: l}
+}
+
+type limitedExec struct {
+	utilexec.Interface
+	limiter *Limiter
+}
+
+func (e *limitedExec) Command(cmd string, args ...string) utilexec.Cmd {
+	return e.wrap(e.Interface.Command(cmd, args...), cmd, args)
+}
+
+func (e *limitedExec) CommandContext(ctx context.Context, cmd string, args ...string) utilexec.Cmd {
+	return e.wrap(e.Interface.CommandContext(ctx, cmd, args...), cmd, args)
+}
+
+func (e *limitedExec) wrap(command utilexec.Cmd, cmd string, args []string) utilexec.Cmd {
+	operation := filepath.Base(cmd)
+	if !isWrite(operation, args) {
+		return command
+	}
+	return &limitedCmd{Cmd: command, limiter: e.limiter, operation: operation}
+}
+
+// isWrite is whether the command may change ipsets or iptables. Listing, saving and checking don't.
+func isWrite(operation string, args []string) bool {
+	switch operation {
+	case util.IptablesRestore, util.BashCommand:
+		return true
+	case util.Iptables:
+		return hasAnyArg(args, util.IptablesAppendFlag, util.IptablesInsertionFlag, util.IptablesDeletionFlag,
+			util.IptablesChainCreationFlag, util.IptablesFlushFlag, util.IptablesDestroyFlag)
+	case util.Ipset:
+		return hasAnyArg(args, util.IpsetRestoreFlag, util.IpsetCreationFlag, util.IpsetAppendFlag, util.IpsetDeletionFlag,
+			util.IpsetFlushFlag, util.IpsetDestroyFlag)
+	default:
+		return false
+	}
+}
+
+func hasAnyArg(args []string, flags ...string) bool {
+	for _, arg := range args {
+		for _, flag := range flags {
+			if arg == flag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// limitedCmd waits for the limiter when it's run. Wait doesn't wait again after Start.
+type limitedCmd struct {
+	utilexec.Cmd
+	limiter   *Limiter
+	operation string
+}
+
+func (c *limitedCmd) Run() error {
+	c.limiter.Wait(c.operation)
+	return c.Cmd.Run() //nolint:wrapcheck // same errors as the wrapped command
+}
+
+func (c *limitedCmd) CombinedOutput() ([]byte, error) {
+	c.limiter.Wait(c.operation)
+	return c.Cmd.CombinedOutput() //nolint:wrapcheck // same errors as the wrapped command
+}
+
+func (c *limitedCmd) Output() ([]byte, error) {
+	c.limiter.Wait(c.operation)
+	return c.Cmd.Output() //nolint:wrapcheck // same errors as the wrapped command
+}
+
+func (c *limitedCmd) Start() error {
+	c.limiter.Wait(c.operation)
+	return c.Cmd.Start() //nolint:wrapcheck // same errors as the wrapped command
+}
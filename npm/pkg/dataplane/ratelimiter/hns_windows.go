@@ -0,0 +1,71 @@
+package ratelimiter
+
+import (
+	"github.com/Azure/azure-container-networking/network/hnswrapper"
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+const hnsOperation = "hns"
+
+// WrapHNS returns an HNS wrapper which waits for the limiter before the calls which change networks or endpoints.
+func (l *Limiter) WrapHNS(hns hnswrapper.HnsV2WrapperInterface) hnswrapper.HnsV2WrapperInterface {
+	if l == nil {
+		return hns
+	}
+	return &limitedHNS{HnsV2WrapperInterface: hns, limiter: l}
+}
+
+type limitedHNS struct {
+	hnswrapper.HnsV2WrapperInterface
+	limiter *Limiter
+}
+
+func (h *limitedHNS) CreateEndpoint(endpoint *hcn.HostComputeEndpoint) (*hcn.HostComputeEndpoint, error) {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.CreateEndpoint(endpoint) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) DeleteEndpoint(endpoint *hcn.HostComputeEndpoint) error {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.DeleteEndpoint(endpoint) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) CreateNetwork(network *hcn.HostComputeNetwork) (*hcn.HostComputeNetwork, error) {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.CreateNetwork(network) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) DeleteNetwork(network *hcn.HostComputeNetwork) error {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.DeleteNetwork(network) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) ModifyNetworkSettings(network *hcn.HostComputeNetwork, request *hcn.ModifyNetworkSettingRequest) error {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.ModifyNetworkSettings(network, request) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) AddNetworkPolicy(network *hcn.HostComputeNetwork, networkPolicy hcn.PolicyNetworkRequest) error {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.AddNetworkPolicy(network, networkPolicy) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) RemoveNetworkPolicy(network *hcn.HostComputeNetwork, networkPolicy hcn.PolicyNetworkRequest) error {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.RemoveNetworkPolicy(network, networkPolicy) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) AddNamespaceEndpoint(namespaceID, endpointID string) error {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.AddNamespaceEndpoint(namespaceID, endpointID) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) RemoveNamespaceEndpoint(namespaceID, endpointID string) error {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.RemoveNamespaceEndpoint(namespaceID, endpointID) //nolint:wrapcheck // same errors as the wrapped HNS
+}
+
+func (h *limitedHNS) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, endpointPolicy hcn.PolicyEndpointRequest) error {
+	h.limiter.Wait(hnsOperation)
+	return h.HnsV2WrapperInterface.ApplyEndpointPolicy(endpoint, requestType, endpointPolicy) //nolint:wrapcheck // same errors as the wrapped HNS
+}
@@ -0,0 +1,83 @@
+// Package ratelimiter throttles the writes of the dataplane to ipset, iptables and HNS with a token bucket, so that
+// a storm of policy or label changes can't starve the node's CPU or hold the xtables lock for long.
+package ratelimiter
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"golang.org/x/time/rate"
+)
+
+type Config struct {
+	// OpsPerSecond is the sustained rate of writes. The limiter is disabled when it isn't positive.
+	OpsPerSecond float64
+	// Burst is the number of writes which may run at once after a quiet period. It is at least 1.
+	Burst int
+}
+
+// Enabled is whether writes are limited with this config.
+func (cfg *Config) Enabled() bool {
+	return cfg != nil && cfg.OpsPerSecond > 0
+}
+
+type Limiter struct {
+	limiter *rate.Limiter
+	// sleep is replaced in tests
+	sleep func(time.Duration)
+}
+
+// New returns a Limiter for the config, or nil if it's disabled. A nil Limiter doesn't limit.
+func New(cfg *Config) *Limiter {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &Limiter{
+		limiter: rate.NewLimiter(rate.Limit(cfg.OpsPerSecond), cfg.burst()),
+		sleep:   time.Sleep,
+	}
+}
+
+// NewReloadable returns a Limiter for the config which SetConfig can enable, disable or retune at runtime.
+// Unlike New, it isn't nil when the config is disabled.
+func NewReloadable(cfg *Config) *Limiter {
+	l := &Limiter{
+		limiter: rate.NewLimiter(rate.Inf, 1),
+		sleep:   time.Sleep,
+	}
+	l.SetConfig(cfg)
+	return l
+}
+
+// SetConfig changes the rate and burst of the limiter. A disabled config stops limiting until it's enabled again.
+func (l *Limiter) SetConfig(cfg *Config) {
+	if l == nil {
+		return
+	}
+	if !cfg.Enabled() {
+		l.limiter.SetLimit(rate.Inf)
+		return
+	}
+	l.limiter.SetBurst(cfg.burst())
+	l.limiter.SetLimit(rate.Limit(cfg.OpsPerSecond))
+}
+
+func (cfg *Config) burst() int {
+	if cfg.Burst < 1 {
+		return 1
+	}
+	return cfg.Burst
+}
+
+// Wait blocks until a write of the operation may run, and records the wait in the metrics.
+func (l *Limiter) Wait(operation string) {
+	if l == nil || l.limiter.Limit() == rate.Inf {
+		return
+	}
+	// the burst is at least 1, so the reservation of a single token is always OK
+	delay := l.limiter.Reserve().Delay()
+	if delay > 0 {
+		l.sleep(delay)
+	}
+	metrics.RecordRateLimitWait(operation, delay)
+}
@@ -0,0 +1,134 @@
+package ratelimiter
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/util"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(t *testing.T, cfg *Config) (*Limiter, *[]time.Duration) {
+	t.Helper()
+	metrics.ReinitializeAll()
+	l := New(cfg)
+	require.NotNil(t, l)
+	sleeps := make([]time.Duration, 0)
+	l.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+	return l, &sleeps
+}
+
+func TestDisabled(t *testing.T) {
+	require.Nil(t, New(nil))
+	require.Nil(t, New(&Config{Burst: 10}))
+
+	// a nil limiter doesn't limit
+	var l *Limiter
+	l.Wait("ipset")
+	exec := testutils.GetFakeExecWithScripts(nil)
+	require.Equal(t, exec, l.WrapExec(exec))
+}
+
+func TestWait(t *testing.T) {
+	l, sleeps := newTestLimiter(t, &Config{OpsPerSecond: 1, Burst: 2})
+
+	// the burst doesn't wait
+	l.Wait(util.Ipset)
+	l.Wait(util.Ipset)
+	require.Empty(t, *sleeps)
+
+	l.Wait(util.IptablesRestore)
+	require.Len(t, *sleeps, 1)
+	require.InDelta(t, time.Second, (*sleeps)[0], float64(100*time.Millisecond))
+
+	throttled, err := metrics.GetThrottledOperations(util.Ipset)
+	require.NoError(t, err)
+	require.Equal(t, 0, throttled)
+	throttled, err = metrics.GetThrottledOperations(util.IptablesRestore)
+	require.NoError(t, err)
+	require.Equal(t, 1, throttled)
+	waits, err := metrics.GetRateLimitWaitCount(util.Ipset)
+	require.NoError(t, err)
+	require.Equal(t, 2, waits)
+}
+
+func TestWrapExec(t *testing.T) {
+	// the burst is rounded up to 1
+	l, sleeps := newTestLimiter(t, &Config{OpsPerSecond: 1})
+
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"ipset", "list", "--name"}},
+		{Cmd: []string{"ipset", "restore"}},
+		{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}},
+		{Cmd: []string{"iptables-save", "-t", "filter"}},
+	}
+	exec := l.WrapExec(testutils.GetFakeExecWithScripts(calls))
+	for _, call := range calls {
+		_, err := exec.Command(call.Cmd[0], call.Cmd[1:]...).CombinedOutput()
+		require.NoError(t, err)
+	}
+
+	// only the second write waits
+	require.Len(t, *sleeps, 1)
+	throttled, err := metrics.GetThrottledOperations(util.Iptables)
+	require.NoError(t, err)
+	require.Equal(t, 1, throttled)
+}
+
+func TestIsWrite(t *testing.T) {
+	tests := []struct {
+		cmd   []string
+		write bool
+	}{
+		{[]string{"ipset", "restore"}, true},
+		{[]string{"ipset", "-X", "azure-npm-123"}, true},
+		{[]string{"ipset", "list", "--name"}, false},
+		{[]string{"ipset", "save"}, false},
+		{[]string{"/usr/sbin/iptables-restore", "-w", "60", "-T", "filter", "--noflush"}, true},
+		{[]string{"iptables", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM"}, true},
+		{[]string{"iptables", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM"}, false},
+		{[]string{"iptables", "-w", "60", "-t", "filter", "-n", "--list", "FORWARD", "--line-numbers"}, false},
+		{[]string{"iptables-save"}, false},
+		{[]string{"bash", "-c", "ipset flush azure-npm-123"}, true},
+		{[]string{"grep", "azure-npm-"}, false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.write, isWrite(path.Base(tt.cmd[0]), tt.cmd[1:]), "command %v", tt.cmd)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	metrics.ReinitializeAll()
+	l := NewReloadable(nil)
+	require.NotNil(t, l)
+	sleeps := make([]time.Duration, 0)
+	l.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+
+	// disabled until the config is enabled
+	l.Wait(util.Ipset)
+	l.Wait(util.Ipset)
+	require.Empty(t, sleeps)
+	waits, err := metrics.GetRateLimitWaitCount(util.Ipset)
+	require.NoError(t, err)
+	require.Equal(t, 0, waits)
+
+	l.SetConfig(&Config{OpsPerSecond: 1})
+	l.Wait(util.Ipset)
+	l.Wait(util.Ipset)
+	require.Len(t, sleeps, 1)
+
+	l.SetConfig(&Config{})
+	l.Wait(util.Ipset)
+	require.Len(t, sleeps, 1)
+
+	// a nil limiter ignores the config
+	var nilLimiter *Limiter
+	nilLimiter.SetConfig(&Config{OpsPerSecond: 1})
+}
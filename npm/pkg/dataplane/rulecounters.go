@@ -0,0 +1,55 @@
+package dataplane
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// ruleCounterReporter is implemented by the policy managers which can count the packets matched by the rules of each
+// policy, i.e. the Linux PolicyManager. HNS doesn't expose counters for its ACLs.
+type ruleCounterReporter interface {
+	RuleCounters() (*policies.RuleCountersReport, error)
+}
+
+// RuleCountersEncoder serves the counters of the rules of each policy on the debug API. It returns nil if the policy
+// manager has no counters, e.g. in Windows.
+func (dp *DataPlane) RuleCountersEncoder() json.Marshaler {
+	reporter, ok := dp.policyMgr.(ruleCounterReporter)
+	if !ok {
+		return nil
+	}
+	return ruleCountersEncoder{reporter: reporter}
+}
+
+type ruleCountersEncoder struct {
+	reporter ruleCounterReporter
+}
+
+func (e ruleCountersEncoder) MarshalJSON() ([]byte, error) {
+	report, err := e.reporter.RuleCounters()
+	if err != nil {
+		return nil, fmt.Errorf("[DataPlane] failed to get rule counters: %w", err)
+	}
+	return json.Marshal(report) //nolint:wrapcheck // the report is plain data
+}
+
+// recordRuleCounters records the counters of the rules of each policy in the metrics, if the policy manager has them.
+func (dp *DataPlane) recordRuleCounters() {
+	reporter, ok := dp.policyMgr.(ruleCounterReporter)
+	if !ok {
+		return
+	}
+	report, err := reporter.RuleCounters()
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "[DataPlane] failed to get rule counters. err: [%s]", err.Error())
+		return
+	}
+	metrics.ResetPolicyRuleCounters()
+	for _, policy := range report.Policies {
+		metrics.SetPolicyRuleCounters(policy.PolicyKey, policy.Packets, policy.UnmatchedRules)
+	}
+}
@@ -14,7 +14,7 @@ type GenericDataplane interface {
 	GetAllIPSets() map[string]string
 	GetIPSet(setName string) *ipsets.IPSet
 	CreateIPSets(setMetadatas []*ipsets.IPSetMetadata)
-	DeleteIPSet(setMetadata *ipsets.IPSetMetadata, deleteOption util.DeleteOption)
+	DeleteIPSet(setMetadata *ipsets.IPSetMetadata, deleteOption util.DeleteOption) error
 	AddToSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *PodMetadata) error
 	RemoveFromSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *PodMetadata) error
 	AddToLists(listMetadatas []*ipsets.IPSetMetadata, setMetadatas []*ipsets.IPSetMetadata) error
@@ -27,9 +27,10 @@ type GenericDataplane interface {
 	UpdatePolicy(policies *policies.NPMNetworkPolicy) error
 }
 
-// UpdateNPMPod pod controller will populate and send this datastructure to dataplane
-// to update the dataplane with the latest pod information
-// this helps in calculating if any update needs to have policies applied or removed
+// updateNPMPod is the pod update which AddToSets and RemoveFromSets accumulate and ApplyDataPlane passes to updatePod
+// on every OS: the pod's key (namespace and name), IP and node, and the sets which its labels added it to or removed
+// it from since the last apply. It helps in calculating if any update needs to have policies applied or removed.
+// updatePod ignores pods on other nodes on every OS, since only local pods have endpoints to apply policies on.
 type updateNPMPod struct {
 	*PodMetadata
 	IPSetsToAdd    []string
@@ -57,6 +58,12 @@ func (p *PodMetadata) Namespace() string {
 	return strings.Split(p.PodKey, "/")[0]
 }
 
+// Name returns the name of the pod in its "namespace/name" key.
+func (p *PodMetadata) Name() string {
+	_, name, _ := strings.Cut(p.PodKey, "/")
+	return name
+}
+
 func newUpdateNPMPod(podMetadata *PodMetadata) *updateNPMPod {
 	return &updateNPMPod{
 		PodMetadata:    podMetadata,
@@ -1,4 +0,0 @@
-package dataplane
-
-// npmEndpoint holds info relevant for endpoints in windows
-type npmEndpoint struct{}
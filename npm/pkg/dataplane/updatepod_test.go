@@ -0,0 +1,55 @@
+package dataplane
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodMetadata(t *testing.T) {
+	pod := NewPodMetadata("x/a", "10.0.0.1", nodeName)
+	require.Equal(t, "x", pod.Namespace())
+	require.Equal(t, "a", pod.Name())
+	require.Equal(t, "a", newUpdateNPMPod(pod).Name())
+}
+
+// updatePod must behave the same on every OS for pods without a local endpoint: it succeeds and changes nothing.
+func TestUpdatePodContract(t *testing.T) {
+	policy := testPolicyobj
+	tests := []struct {
+		name string
+		pod  *PodMetadata
+	}{
+		{
+			name: "pod on another node",
+			pod:  NewPodMetadata("x/a", "10.0.0.1", "othernode"),
+		},
+		{
+			name: "local pod without an endpoint",
+			pod:  NewPodMetadata("x/b", "10.0.0.2", nodeName),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fakePolicies := policies.NewFakePolicyManager()
+			dp := &DataPlane{
+				Config:         dpCfg,
+				policyMgr:      fakePolicies,
+				ipsetMgr:       ipsets.NewFakeIPSetManager(dpCfg.IPSetManagerCfg),
+				endpointCache:  newEndpointCache(),
+				nodeName:       nodeName,
+				updatePodCache: newUpdatePodCache(),
+			}
+			require.NoError(t, fakePolicies.AddPolicy(&policy, nil))
+
+			update := newUpdateNPMPod(tt.pod)
+			update.updateIPSetsToAdd([]*ipsets.IPSetMetadata{setPodKey1.Metadata})
+			require.NoError(t, dp.updatePod(update))
+			require.Empty(t, dp.endpointCache.cache)
+			require.Empty(t, fakePolicies.Endpoints(policy.PolicyKey))
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Package eventing surfaces the dataplane failures of v2 NPM on the Kubernetes objects: an Event on a NetworkPolicy
+// which NPM can't program and a condition on the node while NPM can't apply the dataplane, so that users see them in
+// kubectl describe instead of only in the NPM logs.
+package eventing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+)
+
+const (
+	component = "azure-npm"
+
+	// ReasonNetworkPolicyFailed is the reason of the Event on a NetworkPolicy which NPM failed to program
+	ReasonNetworkPolicyFailed = "FailedToProgramNetworkPolicy"
+
+	// NodeConditionDataplaneFailing is True while NPM fails to apply the dataplane on the node
+	NodeConditionDataplaneFailing corev1.NodeConditionType = "NetworkPolicyDataplaneFailing"
+	reasonApplyFailed                                      = "ApplyDataplaneFailed"
+	reasonApplySucceeded                                   = "ApplyDataplaneSucceeded"
+
+	// defaultInterval is the least time between two Events of an object for the same reason, and between failed
+	// updates of the node condition
+	defaultInterval = 5 * time.Minute
+	// maxMessageLength keeps long dataplane errors from bloating the Events and the node
+	maxMessageLength = 1024
+)
+
+// Recorder emits the Events and updates the node condition. A nil Recorder does nothing.
+type Recorder struct {
+	sync.Mutex
+	recorder  record.EventRecorder
+	clientset kubernetes.Interface
+	nodeName  string
+	interval  time.Duration
+	// lastEvent is when each object last got an Event for a reason
+	lastEvent map[string]time.Time
+	// nodeFailing is the status of the node condition after the last successful update, nil before the first one
+	nodeFailing *bool
+	// nextNodeUpdate is when the node condition may be updated again after a failed update
+	nextNodeUpdate time.Time
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewRecorder returns a Recorder which sends the Events to the API server as the NPM on the node.
+func NewRecorder(clientset kubernetes.Interface, nodeName string) *Recorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component, Host: nodeName})
+	return newRecorder(recorder, clientset, nodeName)
+}
+
+func newRecorder(recorder record.EventRecorder, clientset kubernetes.Interface, nodeName string) *Recorder {
+	return &Recorder{
+		recorder:  recorder,
+		clientset: clientset,
+		nodeName:  nodeName,
+		interval:  defaultInterval,
+		lastEvent: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// NetworkPolicyFailed emits a Warning Event on the NetworkPolicy, unless it got one in the last interval.
+func (r *Recorder) NetworkPolicyFailed(netPol *networkingv1.NetworkPolicy, err error) {
+	if r == nil {
+		return
+	}
+	key := fmt.Sprintf("%s/%s/%s", netPol.Namespace, netPol.Name, ReasonNetworkPolicyFailed)
+	if !r.allow(key) {
+		return
+	}
+	r.recorder.Eventf(netPol, corev1.EventTypeWarning, ReasonNetworkPolicyFailed,
+		"NPM on node %s failed to program the network policy: %s", r.nodeName, truncate(err.Error()))
+}
+
+// DataplaneApplied updates the node condition with the result of applying the dataplane. The node is only updated
+// when the status changes, and a failed update is retried after the interval.
+func (r *Recorder) DataplaneApplied(applyErr error) {
+	if r == nil {
+		return
+	}
+	failing := applyErr != nil
+
+	r.Lock()
+	defer r.Unlock()
+	if r.nodeFailing != nil && *r.nodeFailing == failing {
+		return
+	}
+	if r.now().Before(r.nextNodeUpdate) {
+		return
+	}
+
+	if err := r.patchNodeCondition(failing, applyErr); err != nil {
+		metrics.SendErrorLogAndMetric(util.NpmID, "Error: failed to update the %s condition of node %s: %s", NodeConditionDataplaneFailing, r.nodeName, err.Error())
+		r.nextNodeUpdate = r.now().Add(r.interval)
+		return
+	}
+	klog.Infof("[Recorder] set the %s condition of node %s to %t", NodeConditionDataplaneFailing, r.nodeName, failing)
+	r.nodeFailing = &failing
+}
+
+func (r *Recorder) patchNodeCondition(failing bool, applyErr error) error {
+	condition := corev1.NodeCondition{
+		Type:               NodeConditionDataplaneFailing,
+		Status:             corev1.ConditionFalse,
+		Reason:             reasonApplySucceeded,
+		Message:            "NPM applied the dataplane",
+		LastHeartbeatTime:  metav1.NewTime(r.now()),
+		LastTransitionTime: metav1.NewTime(r.now()),
+	}
+	if failing {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = reasonApplyFailed
+		condition.Message = "NPM failed to apply the dataplane: " + truncate(applyErr.Error())
+	}
+
+	// the conditions of a node are merged by type
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.NodeCondition{condition},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal node condition: %w", err)
+	}
+	if _, err := r.clientset.CoreV1().Nodes().PatchStatus(context.TODO(), r.nodeName, patch); err != nil {
+		return fmt.Errorf("failed to patch node status: %w", err)
+	}
+	return nil
+}
+
+// allow is whether the key didn't get an Event in the last interval, and records the Event if so
+func (r *Recorder) allow(key string) bool {
+	r.Lock()
+	defer r.Unlock()
+	now := r.now()
+	if last, ok := r.lastEvent[key]; ok && now.Sub(last) < r.interval {
+		return false
+	}
+	r.lastEvent[key] = now
+	// drop the expired keys so that deleted objects don't pile up
+	for k, last := range r.lastEvent {
+		if now.Sub(last) >= r.interval {
+			delete(r.lastEvent, k)
+		}
+	}
+	return true
+}
+
+func truncate(message string) string {
+	if len(message) <= maxMessageLength {
+		return message
+	}
+	return message[:maxMessageLength] + "..."
+}
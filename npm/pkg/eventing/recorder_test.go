@@ -0,0 +1,103 @@
+package eventing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+var errApply = errors.New("failed to restore iptables")
+
+func newTestRecorder(t *testing.T, objects ...runtime.Object) (*Recorder, *record.FakeRecorder, *k8sfake.Clientset, *time.Time) {
+	t.Helper()
+	metrics.InitializeAll()
+	fakeRecorder := record.NewFakeRecorder(10)
+	clientset := k8sfake.NewSimpleClientset(objects...)
+	r := newRecorder(fakeRecorder, clientset, "node1")
+	now := time.Unix(0, 0)
+	r.now = func() time.Time { return now }
+	return r, fakeRecorder, clientset, &now
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	r.NetworkPolicyFailed(&networkingv1.NetworkPolicy{}, errApply)
+	r.DataplaneApplied(errApply)
+}
+
+func TestNetworkPolicyFailed(t *testing.T) {
+	r, fakeRecorder, _, now := newTestRecorder(t)
+	netPol := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "deny-all"}}
+	otherNetPol := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "allow-dns"}}
+
+	r.NetworkPolicyFailed(netPol, errApply)
+	require.Equal(t, "Warning FailedToProgramNetworkPolicy NPM on node node1 failed to program the network policy: failed to restore iptables", <-fakeRecorder.Events)
+
+	// rate limited per policy
+	r.NetworkPolicyFailed(netPol, errApply)
+	r.NetworkPolicyFailed(otherNetPol, errApply)
+	require.Len(t, fakeRecorder.Events, 1)
+	<-fakeRecorder.Events
+
+	*now = now.Add(defaultInterval)
+	r.NetworkPolicyFailed(netPol, errApply)
+	require.Len(t, fakeRecorder.Events, 1)
+}
+
+func TestDataplaneApplied(t *testing.T) {
+	r, _, clientset, now := newTestRecorder(t, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+	condition := func() *corev1.NodeCondition {
+		node, err := clientset.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+		require.NoError(t, err)
+		for i := range node.Status.Conditions {
+			if node.Status.Conditions[i].Type == NodeConditionDataplaneFailing {
+				return &node.Status.Conditions[i]
+			}
+		}
+		return nil
+	}
+	numPatches := func() int {
+		n := 0
+		for _, action := range clientset.Actions() {
+			if action.GetVerb() == "patch" {
+				n++
+			}
+		}
+		return n
+	}
+
+	r.DataplaneApplied(nil)
+	require.Equal(t, corev1.ConditionFalse, condition().Status)
+	r.DataplaneApplied(nil)
+	require.Equal(t, 1, numPatches(), "the node is only updated when the status changes")
+
+	r.DataplaneApplied(errApply)
+	require.Equal(t, corev1.ConditionTrue, condition().Status)
+	require.Equal(t, "NPM failed to apply the dataplane: failed to restore iptables", condition().Message)
+	require.Equal(t, 2, numPatches())
+
+	// failed updates are retried after the interval
+	clientset.PrependReactor("patch", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errApply
+	})
+	r.DataplaneApplied(nil)
+	r.DataplaneApplied(nil)
+	require.Equal(t, 3, numPatches())
+	require.Equal(t, corev1.ConditionTrue, condition().Status)
+
+	clientset.ReactionChain = clientset.ReactionChain[1:]
+	*now = now.Add(defaultInterval)
+	r.DataplaneApplied(nil)
+	require.Equal(t, corev1.ConditionFalse, condition().Status)
+}
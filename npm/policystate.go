@@ -0,0 +1,258 @@
+package npm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-container-networking/npm/http/api"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/pkg/protos"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var _ api.PolicyState = (*NetworkPolicyManager)(nil)
+
+// ListIPSets returns the ipsets of the dataplane with their members, sorted by name.
+func (npMgr *NetworkPolicyManager) ListIPSets() (*api.ListIPSetsResponse, error) {
+	if !npMgr.config.Toggles.EnableV2NPM {
+		return nil, api.ErrNotSupported
+	}
+
+	resp := &api.ListIPSetsResponse{IPSets: make([]*api.IPSet, 0)}
+	for _, name := range npMgr.Dataplane.GetAllIPSets() {
+		set := npMgr.Dataplane.GetIPSet(name)
+		if set == nil {
+			// deleted since listing the sets
+			continue
+		}
+		resp.IPSets = append(resp.IPSets, &api.IPSet{
+			Name:       set.Name,
+			HashedName: set.HashedName,
+			Type:       set.Type.String(),
+			Members:    ipsetMembers(set),
+		})
+	}
+	sort.Slice(resp.IPSets, func(i, j int) bool {
+		return resp.IPSets[i].Name < resp.IPSets[j].Name
+	})
+	return resp, nil
+}
+
+func ipsetMembers(set *ipsets.IPSet) []string {
+	members := make([]string, 0, len(set.IPPodKey)+len(set.MemberIPSets))
+	for ip := range set.IPPodKey {
+		members = append(members, ip)
+	}
+	for memberName := range set.MemberIPSets {
+		members = append(members, memberName)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// StreamIPSets sends the v2 ipsets of the dataplane which match the request one at a time, sorted by name, so that
+// large inventories are never held in a single response.
+func (npMgr *NetworkPolicyManager) StreamIPSets(req *protos.ListIPSetsRequest, send func(*protos.IPSet) error) error {
+	if !npMgr.config.Toggles.EnableV2NPM {
+		return api.ErrNotSupported
+	}
+
+	allSets := npMgr.Dataplane.GetAllIPSets()
+	names := make([]string, 0, len(allSets))
+	for _, name := range allSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		set := npMgr.Dataplane.GetIPSet(name)
+		if set == nil {
+			// deleted since listing the sets
+			continue
+		}
+		kind := ipsetKindV2(set.Kind)
+		if (req.GetKind() != protos.IPSet_UnknownKind && kind != req.GetKind()) ||
+			(req.GetType() != "" && set.Type.String() != req.GetType()) {
+			continue
+		}
+
+		setV2 := &protos.IPSet{
+			Name:               set.Name,
+			HashedName:         set.HashedName,
+			Kind:               kind,
+			Type:               set.Type.String(),
+			MemberCount:        uint32(len(set.IPPodKey) + len(set.MemberIPSets)),
+			SelectorReferences: sortedReferences(set.SelectorReference),
+			NetPolReferences:   sortedReferences(set.NetPolReference),
+		}
+		if !req.GetOmitMembers() {
+			setV2.Members = ipsetMembers(set)
+			if len(set.IPPodKey) > 0 {
+				setV2.PodKeys = make(map[string]string, len(set.IPPodKey))
+				for ip, podKey := range set.IPPodKey {
+					setV2.PodKeys[ip] = podKey
+				}
+			}
+		}
+		if err := send(setV2); err != nil {
+			return fmt.Errorf("failed to send ipset %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func ipsetKindV2(kind ipsets.SetKind) protos.IPSet_Kind {
+	switch kind {
+	case ipsets.HashSet:
+		return protos.IPSet_HashSet
+	case ipsets.ListSet:
+		return protos.IPSet_ListSet
+	default:
+		return protos.IPSet_UnknownKind
+	}
+}
+
+func sortedReferences(references map[string]struct{}) []string {
+	keys := make([]string, 0, len(references))
+	for key := range references {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ListPolicies returns the translation of the network policies in the informer cache, sorted by key.
+func (npMgr *NetworkPolicyManager) ListPolicies() (*api.ListPoliciesResponse, error) {
+	if !npMgr.config.Toggles.EnableV2NPM {
+		return nil, api.ErrNotSupported
+	}
+
+	netPols, err := npMgr.NpInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network policies: %w", err)
+	}
+
+	resp := &api.ListPoliciesResponse{Policies: make([]*api.Policy, 0, len(netPols))}
+	for _, netPol := range netPols {
+		resp.Policies = append(resp.Policies, translatePolicyState(netPol))
+	}
+	sort.Slice(resp.Policies, func(i, j int) bool {
+		return resp.Policies[i].Key < resp.Policies[j].Key
+	})
+	return resp, nil
+}
+
+func translatePolicyState(netPol *networkingv1.NetworkPolicy) *api.Policy {
+	policy := &api.Policy{Key: netPol.Namespace + "/" + netPol.Name}
+	npmNetPol, err := translation.TranslatePolicy(netPol)
+	if err != nil {
+		policy.Error = err.Error()
+		return policy
+	}
+
+	policy.PodSelectorIPSets = translatedSetNames(npmNetPol.AllPodSelectorIPSets())
+	policy.RuleIPSets = translatedSetNames(npmNetPol.RuleIPSets)
+	policy.ACLs = make([]*api.ACL, 0, len(npmNetPol.ACLs))
+	for _, acl := range npmNetPol.ACLs {
+		policy.ACLs = append(policy.ACLs, &api.ACL{
+			Comment:   acl.Comment,
+			Direction: string(acl.Direction),
+			Target:    string(acl.Target),
+			Protocol:  string(acl.Protocol),
+			Port:      acl.DstPorts.Port,
+			EndPort:   acl.DstPorts.EndPort,
+			SrcSets:   setInfoNames(acl.SrcList),
+			DstSets:   setInfoNames(acl.DstList),
+		})
+	}
+	return policy
+}
+
+func setInfoNames(setInfos []policies.SetInfo) []string {
+	names := make([]string, 0, len(setInfos))
+	for _, setInfo := range setInfos {
+		name := setInfo.IPSet.GetPrefixName()
+		if !setInfo.Included {
+			name = "!" + name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func translatedSetNames(sets []*ipsets.TranslatedIPSet) []string {
+	names := make([]string, 0, len(sets))
+	for _, set := range sets {
+		names = append(names, set.Metadata.GetPrefixName())
+	}
+	return names
+}
+
+// GetPodPolicies returns the keys of the network policies which select the pod, by direction.
+func (npMgr *NetworkPolicyManager) GetPodPolicies(namespace, name string) (*api.PodPoliciesResponse, error) {
+	if !npMgr.config.Toggles.EnableV2NPM {
+		return nil, api.ErrNotSupported
+	}
+
+	pod, err := npMgr.PodInformer.Lister().Pods(namespace).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("pod %s/%s: %w", namespace, name, api.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get pod %s/%s: %w", namespace, name, err)
+	}
+
+	netPols, err := npMgr.NpInformer.Lister().NetworkPolicies(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network policies in namespace %s: %w", namespace, err)
+	}
+
+	resp := &api.PodPoliciesResponse{
+		Pod:     namespace + "/" + name,
+		IP:      pod.Status.PodIP,
+		Ingress: make([]string, 0),
+		Egress:  make([]string, 0),
+	}
+	podLabels := labels.Set(pod.Labels)
+	for _, netPol := range netPols {
+		selector, err := metav1.LabelSelectorAsSelector(&netPol.Spec.PodSelector)
+		if err != nil || !selector.Matches(podLabels) {
+			continue
+		}
+
+		npmNetPol, err := translation.TranslatePolicy(netPol)
+		if err != nil {
+			// NPM doesn't program the policy
+			continue
+		}
+		ingress, egress := aclDirections(npmNetPol.ACLs)
+		if ingress {
+			resp.Ingress = append(resp.Ingress, npmNetPol.PolicyKey)
+		}
+		if egress {
+			resp.Egress = append(resp.Egress, npmNetPol.PolicyKey)
+		}
+	}
+	sort.Strings(resp.Ingress)
+	sort.Strings(resp.Egress)
+	return resp, nil
+}
+
+func aclDirections(acls []*policies.ACLPolicy) (ingress, egress bool) {
+	for _, acl := range acls {
+		switch acl.Direction {
+		case policies.Ingress:
+			ingress = true
+		case policies.Egress:
+			egress = true
+		case policies.Both:
+			ingress, egress = true, true
+		}
+	}
+	return ingress, egress
+}
@@ -0,0 +1,124 @@
+package npm
+
+import (
+	"errors"
+	"testing"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/http/api"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/pkg/models"
+	"github.com/Azure/azure-container-networking/npm/pkg/protos"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPolicyState(t *testing.T) {
+	factory := kubeinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	dp := dataplane.NewInMemoryDataplane(&dataplane.Config{
+		IPSetManagerCfg:  &ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyAllIPSets},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode},
+	})
+	npMgr := &NetworkPolicyManager{
+		config: npmconfig.Config{Toggles: npmconfig.Toggles{EnableV2NPM: true}},
+		Informers: models.Informers{
+			PodInformer: factory.Core().V1().Pods(),
+			NpInformer:  factory.Networking().V1().NetworkPolicies(),
+		},
+		Dataplane: dp,
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "test", Labels: map[string]string{"app": "a"}},
+		Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+	}
+	require.NoError(t, npMgr.PodInformer.Informer().GetIndexer().Add(pod))
+	for _, netPol := range []*networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "deny-ingress", Namespace: "test"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "deny-egress-b", Namespace: "test"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "b"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			},
+		},
+	} {
+		require.NoError(t, npMgr.NpInformer.Informer().GetIndexer().Add(netPol))
+	}
+
+	nsSet := ipsets.NewIPSetMetadata("test", ipsets.Namespace)
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet}, dataplane.NewPodMetadata("test/a", "10.0.0.1", "")))
+
+	ipsetsResp, err := npMgr.ListIPSets()
+	require.NoError(t, err)
+	require.Len(t, ipsetsResp.IPSets, 1)
+	require.Equal(t, &api.IPSet{
+		Name:       nsSet.GetPrefixName(),
+		HashedName: nsSet.GetHashedName(),
+		Type:       ipsets.Namespace.String(),
+		Members:    []string{"10.0.0.1"},
+	}, ipsetsResp.IPSets[0])
+
+	var setsV2 []*protos.IPSet
+	collect := func(set *protos.IPSet) error {
+		setsV2 = append(setsV2, set)
+		return nil
+	}
+	require.NoError(t, npMgr.StreamIPSets(&protos.ListIPSetsRequest{}, collect))
+	require.Len(t, setsV2, 1)
+	require.Equal(t, nsSet.GetPrefixName(), setsV2[0].Name)
+	require.Equal(t, protos.IPSet_HashSet, setsV2[0].Kind)
+	require.Equal(t, ipsets.Namespace.String(), setsV2[0].Type)
+	require.Equal(t, uint32(1), setsV2[0].MemberCount)
+	require.Equal(t, []string{"10.0.0.1"}, setsV2[0].Members)
+	require.Equal(t, map[string]string{"10.0.0.1": "test/a"}, setsV2[0].PodKeys)
+
+	setsV2 = nil
+	require.NoError(t, npMgr.StreamIPSets(&protos.ListIPSetsRequest{OmitMembers: true}, collect))
+	require.Len(t, setsV2, 1)
+	require.Equal(t, uint32(1), setsV2[0].MemberCount)
+	require.Empty(t, setsV2[0].Members)
+	require.Empty(t, setsV2[0].PodKeys)
+
+	setsV2 = nil
+	require.NoError(t, npMgr.StreamIPSets(&protos.ListIPSetsRequest{Kind: protos.IPSet_ListSet}, collect))
+	require.NoError(t, npMgr.StreamIPSets(&protos.ListIPSetsRequest{Type: ipsets.KeyLabelOfPod.String()}, collect))
+	require.Empty(t, setsV2)
+
+	policiesResp, err := npMgr.ListPolicies()
+	require.NoError(t, err)
+	require.Len(t, policiesResp.Policies, 2)
+	require.Equal(t, "test/deny-egress-b", policiesResp.Policies[0].Key)
+	require.Equal(t, "test/deny-ingress", policiesResp.Policies[1].Key)
+	require.Empty(t, policiesResp.Policies[1].Error)
+	require.Equal(t, []string{nsSet.GetPrefixName()}, policiesResp.Policies[1].PodSelectorIPSets)
+	require.NotEmpty(t, policiesResp.Policies[1].ACLs)
+
+	podResp, err := npMgr.GetPodPolicies("test", "a")
+	require.NoError(t, err)
+	require.Equal(t, &api.PodPoliciesResponse{
+		Pod:     "test/a",
+		IP:      "10.0.0.1",
+		Ingress: []string{"test/deny-ingress"},
+		Egress:  []string{},
+	}, podResp)
+
+	_, err = npMgr.GetPodPolicies("test", "b")
+	require.True(t, errors.Is(err, api.ErrNotFound))
+
+	npMgr.config.Toggles.EnableV2NPM = false
+	_, err = npMgr.ListIPSets()
+	require.True(t, errors.Is(err, api.ErrNotSupported))
+	require.True(t, errors.Is(npMgr.StreamIPSets(&protos.ListIPSetsRequest{}, collect), api.ErrNotSupported))
+}
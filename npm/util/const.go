@@ -27,6 +27,7 @@ const (
 	Iptables                   string = "iptables"
 	Ip6tables                  string = "ip6tables" //nolint (avoid warning to capitalize this p)
 	IptablesSave               string = "iptables-save"
+	IptablesSaveCountersFlag   string = "-c"
 	IptablesRestore            string = "iptables-restore"
 	IptablesRestoreNoFlushFlag string = "--noflush"
 	IptablesRestoreTableFlag   string = "-T"
@@ -68,6 +69,15 @@ const (
 	IptablesStateFlag          string = "--state"
 	IptablesCtstateModuleFlag  string = "conntrack" // state module is obsolete: https://unix.stackexchange.com/questions/108169/what-is-the-difference-between-m-conntrack-ctstate-and-m-state-state
 	IptablesCtstateFlag        string = "--ctstate"
+	IptablesRejectWithFlag     string = "--reject-with"
+	IptablesTCPReset           string = "tcp-reset"
+	IptablesICMPAdminProhib    string = "icmp-admin-prohibited"
+	IptablesNFLOG              string = "NFLOG"
+	IptablesNFLOGGroupFlag     string = "--nflog-group"
+	IptablesNFLOGPrefixFlag    string = "--nflog-prefix"
+	IptablesNFLOGRangeFlag     string = "--nflog-range"
+	IptablesLimitModuleFlag    string = "limit"
+	IptablesLimitFlag          string = "--limit"
 	IptablesMultiportFlag      string = "multiport"
 	IptablesRelatedState       string = "RELATED"
 	IptablesEstablishedState   string = "ESTABLISHED"
@@ -79,6 +89,7 @@ const (
 
 	IptablesTableFlag       string = "-t"
 	IptablesListFlag        string = "-L"
+	IptablesListRulesFlag   string = "-S"
 	IptablesNumericFlag     string = "-n"
 	IptablesLineNumbersFlag string = "--line-numbers"
 
@@ -124,10 +135,13 @@ const (
 	// marks in NPM v2
 	// NPM uses the 3rd word of the 32-bit mark for the purpose of
 	// identifying the traffic direction and decision making.
-	// NPM uses 9th, 10th and 11th bit for marking
+	// NPM uses 8th, 9th, 10th and 11th bit for marking
 	IptablesAzureIngressAllowMarkHex string = "0x200/0x200"
 	IptablesAzureIngressDropMarkHex  string = "0x400/0x400"
 	IptablesAzureEgressDropMarkHex   string = "0x800/0x800"
+	// the 8th bit is set along with the drop mark of either direction to reject instead of drop
+	IptablesAzureIngressRejectMarkHex string = "0x500/0x500"
+	IptablesAzureEgressRejectMarkHex  string = "0x900/0x900"
 
 	// marks in NPM v1
 	IptablesAzureIngressMarkHex string = "0x2000"
@@ -0,0 +1,66 @@
+// Package intern deduplicates the strings which NPM keeps many copies of, like the IPs, pod keys and ipset names in
+// the ipset cache. Each cache owns its Pool, since only the cache knows which strings it still keeps when it rotates
+// the Pool.
+package intern
+
+import (
+	"strings"
+	"sync"
+)
+
+// Pool hands out one shared copy of equal strings.
+type Pool struct {
+	sync.Mutex
+	strings map[string]string
+	// previous are the strings of the pool before the last Rotate, which stay in the pool if interned again
+	previous map[string]string
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		strings:  make(map[string]string),
+		previous: make(map[string]string),
+	}
+}
+
+// String returns the pool's copy of the string, adding a copy if the pool has none. The copy doesn't keep a larger
+// string alive which the string may be a part of.
+func (p *Pool) String(s string) string {
+	if s == "" {
+		return s
+	}
+	p.Lock()
+	defer p.Unlock()
+	if pooled, ok := p.strings[s]; ok {
+		return pooled
+	}
+	pooled, ok := p.previous[s]
+	if !ok {
+		pooled = strings.Clone(s)
+	}
+	p.strings[pooled] = pooled
+	return pooled
+}
+
+// Rotate drops the strings which weren't interned since the previous Rotate, so that the pool doesn't grow with the
+// strings which the caches forgot. The copies handed out stay valid. Caches intern the strings which they keep again
+// after a Rotate so that they stay shared.
+func (p *Pool) Rotate() {
+	p.Lock()
+	defer p.Unlock()
+	p.previous = p.strings
+	p.strings = make(map[string]string, len(p.previous))
+}
+
+// Len returns the number of strings in the pool.
+func (p *Pool) Len() int {
+	p.Lock()
+	defer p.Unlock()
+	n := len(p.strings)
+	for s := range p.previous {
+		if _, ok := p.strings[s]; !ok {
+			n++
+		}
+	}
+	return n
+}
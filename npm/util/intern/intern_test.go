@@ -0,0 +1,45 @@
+package intern
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sameCopy(a, b string) bool {
+	return (*reflect.StringHeader)(unsafe.Pointer(&a)).Data == (*reflect.StringHeader)(unsafe.Pointer(&b)).Data
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool()
+	a := p.String(strings.Repeat("10.0.0.1", 2))
+	b := p.String(strings.Repeat("10.0.0.1", 2))
+	require.Equal(t, a, b)
+	require.True(t, sameCopy(a, b), "equal strings share one copy")
+	require.Equal(t, "", p.String(""))
+	require.Equal(t, 1, p.Len())
+
+	// a string interned again after a Rotate keeps its copy
+	p.Rotate()
+	require.Equal(t, 1, p.Len())
+	require.True(t, sameCopy(a, p.String(a)))
+	other := p.String("ns/pod")
+
+	// a string which isn't interned again leaves the pool on the next Rotate
+	p.Rotate()
+	p.String(other)
+	p.Rotate()
+	require.Equal(t, 1, p.Len())
+	require.False(t, sameCopy(a, p.String(strings.Repeat("10.0.0.1", 2))))
+}
+
+func TestPoolCopiesSubstrings(t *testing.T) {
+	p := NewPool()
+	line := "10.0.0.1 ns/pod"
+	ip := p.String(line[:8])
+	require.Equal(t, "10.0.0.1", ip)
+	require.False(t, sameCopy(line, ip), "the pool doesn't keep the whole line alive")
+}
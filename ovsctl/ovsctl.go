@@ -6,8 +6,11 @@ package ovsctl
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
@@ -39,30 +42,57 @@ type OvsInterface interface {
 	GetOVSPortNumber(interfaceName string) (string, error)
 	AddVMIpAcceptRule(bridgeName string, primaryIP string, mac string) error
 	AddArpSnatRule(bridgeName string, mac string, macHex string, ofport string) error
-	AddIPSnatRule(bridgeName string, ip net.IP, vlanID int, port string, mac string, outport string) error
+	AddIPSnatRule(bridgeName string, ip net.IP, vlanID int, port string, mac string, outport string, cookie uint64) error
 	AddArpDnatRule(bridgeName string, port string, mac string) error
 	AddFakeArpReply(bridgeName string, ip net.IP) error
 	AddArpReplyRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, mode string) error
-	AddMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, containerPort string) error
+	AddMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, containerPort string, cookie uint64) error
 	DeleteArpReplyRule(bridgeName string, port string, ip net.IP, vlanid int)
 	DeleteIPSnatRule(bridgeName string, port string)
 	DeleteMacDnatRule(bridgeName string, port string, ip net.IP, vlanid int)
 	DeletePortFromOVS(bridgeName string, interfaceName string) error
+	ListFlowsByCookie(bridgeName string, cookie uint64, cookieMask uint64) ([]Flow, error)
+	DeleteFlowsByCookie(bridgeName string, cookie uint64) error
 }
 
+// Flow is an OpenFlow flow as dumped by ovs-ofctl.
+type Flow struct {
+	Cookie   uint64
+	Table    int
+	Duration time.Duration
+	// Match is the match of the flow with its priority, e.g. "priority=20,ip,in_port=3,nw_src=10.0.0.4".
+	Match string
+}
+
+// FlowCookie returns the cookie which tags the flows of the endpoint, so they can be listed and deleted
+// without knowing their match. Cookie 0 is left to the flows which are shared by the endpoints of the bridge.
+func FlowCookie(endpointID string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(endpointID))
+	cookie := h.Sum64()
+	if cookie == 0 {
+		cookie = 1
+	}
+	return cookie
+}
+
+// Ovsctl configures the bridges and ports through ovsdb-server and programs the flows with ovs-ofctl.
 type Ovsctl struct {
 	execcli platform.ExecClient
+	ovsdb   *ovsdbClient
 }
 
 func NewOvsctl() Ovsctl {
-	return Ovsctl{execcli: platform.NewExecClient()}
+	return Ovsctl{
+		execcli: platform.NewExecClient(),
+		ovsdb:   newOVSDBClient(ovsdbSocket),
+	}
 }
 
 func (o Ovsctl) CreateOVSBridge(bridgeName string) error {
 	log.Printf("[ovs] Creating OVS Bridge %v", bridgeName)
 
-	ovsCreateCmd := fmt.Sprintf("ovs-vsctl add-br %s", bridgeName)
-	_, err := o.execcli.ExecuteCommand(ovsCreateCmd)
+	err := o.ovsdb.createBridge(bridgeName)
 	if err != nil {
 		log.Printf("[ovs] Error while creating OVS bridge %v", err)
 		return newErrorOvsctl(err.Error())
@@ -74,8 +104,7 @@ func (o Ovsctl) CreateOVSBridge(bridgeName string) error {
 func (o Ovsctl) DeleteOVSBridge(bridgeName string) error {
 	log.Printf("[ovs] Deleting OVS Bridge %v", bridgeName)
 
-	ovsCreateCmd := fmt.Sprintf("ovs-vsctl del-br %s", bridgeName)
-	_, err := o.execcli.ExecuteCommand(ovsCreateCmd)
+	err := o.ovsdb.deleteBridge(bridgeName)
 	if err != nil {
 		log.Printf("[ovs] Error while deleting OVS bridge %v", err)
 		return newErrorOvsctl(err.Error())
@@ -85,8 +114,7 @@ func (o Ovsctl) DeleteOVSBridge(bridgeName string) error {
 }
 
 func (o Ovsctl) AddPortOnOVSBridge(hostIfName, bridgeName string, vlanID int) error {
-	cmd := fmt.Sprintf("ovs-vsctl add-port %s %s", bridgeName, hostIfName)
-	_, err := o.execcli.ExecuteCommand(cmd)
+	err := o.ovsdb.addPort(bridgeName, hostIfName)
 	if err != nil {
 		log.Printf("[ovs] Error while setting OVS as master to primary interface %v", err)
 		return newErrorOvsctl(err.Error())
@@ -96,14 +124,13 @@ func (o Ovsctl) AddPortOnOVSBridge(hostIfName, bridgeName string, vlanID int) er
 }
 
 func (o Ovsctl) GetOVSPortNumber(interfaceName string) (string, error) {
-	cmd := fmt.Sprintf("ovs-vsctl get Interface %s ofport", interfaceName)
-	ofport, err := o.execcli.ExecuteCommand(cmd)
+	ofport, err := o.ovsdb.ofport(interfaceName)
 	if err != nil {
 		log.Printf("[ovs] Get ofport failed with error %v", err)
 		return "", newErrorOvsctl(err.Error())
 	}
 
-	return strings.Trim(ofport, "\n"), nil
+	return strconv.Itoa(ofport), nil
 }
 
 func (o Ovsctl) AddVMIpAcceptRule(bridgeName, primaryIP, mac string) error {
@@ -130,13 +157,14 @@ func (o Ovsctl) AddArpSnatRule(bridgeName, mac, macHex, ofport string) error {
 }
 
 // IP SNAT Rule - Change src mac to VM Mac for packets coming from container host veth port.
-func (o Ovsctl) AddIPSnatRule(bridgeName string, ip net.IP, vlanID int, port, mac, outport string) error {
+func (o Ovsctl) AddIPSnatRule(bridgeName string, ip net.IP, vlanID int, port, mac, outport string, cookie uint64) error {
 	var cmd string
 	if outport == "" {
 		outport = "normal"
 	}
 
-	commonPrefix := fmt.Sprintf("ovs-ofctl add-flow %v priority=%d,ip,nw_src=%s,in_port=%s,vlan_tci=0,actions=mod_dl_src:%s", bridgeName, high, ip.String(), port, mac)
+	commonPrefix := fmt.Sprintf("ovs-ofctl add-flow %v cookie=%#x,priority=%d,ip,nw_src=%s,in_port=%s,vlan_tci=0,actions=mod_dl_src:%s",
+		bridgeName, cookie, high, ip.String(), port, mac)
 
 	// This rule also checks if packets coming from right source ip based on the ovs port to prevent ip spoofing.
 	// Otherwise it drops the packet.
@@ -153,8 +181,8 @@ func (o Ovsctl) AddIPSnatRule(bridgeName string, ip net.IP, vlanID int, port, ma
 	}
 
 	// Drop other packets which doesn't satisfy above condition
-	cmd = fmt.Sprintf("ovs-ofctl add-flow %v priority=%d,ip,in_port=%s,actions=drop",
-		bridgeName, low, port)
+	cmd = fmt.Sprintf("ovs-ofctl add-flow %v cookie=%#x,priority=%d,ip,in_port=%s,actions=drop",
+		bridgeName, cookie, low, port)
 	_, err = o.execcli.ExecuteCommand(cmd)
 	if err != nil {
 		log.Printf("[ovs] Dropping vlantag packet rule failed with error %v", err)
@@ -227,12 +255,12 @@ func (o Ovsctl) AddArpReplyRule(bridgeName, port string, ip net.IP, mac string,
 }
 
 // Add MAC DNAT rule based on dst ip and vlanid
-func (o Ovsctl) AddMacDnatRule(bridgeName, port string, ip net.IP, mac string, vlanid int, containerPort string) error {
+func (o Ovsctl) AddMacDnatRule(bridgeName, port string, ip net.IP, mac string, vlanid int, containerPort string, cookie uint64) error {
 	var cmd string
 	// This rule changes the destination mac to speciifed mac based on the ip and vlanid.
 	// and forwards the packet to corresponding container hostveth port
 
-	commonPrefix := fmt.Sprintf("ovs-ofctl add-flow %s ip,nw_dst=%s,in_port=%s", bridgeName, ip.String(), port)
+	commonPrefix := fmt.Sprintf("ovs-ofctl add-flow %s cookie=%#x,ip,nw_dst=%s,in_port=%s", bridgeName, cookie, ip.String(), port)
 	if vlanid != 0 {
 		cmd = fmt.Sprintf("%s,dl_vlan=%v,actions=mod_dl_dst:%s,strip_vlan,%s", commonPrefix, vlanid, mac, containerPort)
 	} else {
@@ -291,8 +319,7 @@ func (o Ovsctl) DeleteMacDnatRule(bridgeName, port string, ip net.IP, vlanid int
 
 func (o Ovsctl) DeletePortFromOVS(bridgeName, interfaceName string) error {
 	// Disconnect external interface from its bridge.
-	cmd := fmt.Sprintf("ovs-vsctl del-port %s %s", bridgeName, interfaceName)
-	_, err := o.execcli.ExecuteCommand(cmd)
+	err := o.ovsdb.deletePort(bridgeName, interfaceName)
 	if err != nil {
 		log.Printf("[ovs] Failed to disconnect interface %v from bridge, err:%v.", interfaceName, err)
 		return newErrorOvsctl(err.Error())
@@ -300,3 +327,68 @@ func (o Ovsctl) DeletePortFromOVS(bridgeName, interfaceName string) error {
 
 	return nil
 }
+
+// ListFlowsByCookie lists the flows of the bridge whose cookie matches the cookie in the bits of the mask.
+// A mask of 0 lists all the flows.
+func (o Ovsctl) ListFlowsByCookie(bridgeName string, cookie, cookieMask uint64) ([]Flow, error) {
+	cmd := fmt.Sprintf("ovs-ofctl dump-flows %s", bridgeName)
+	if cookieMask != 0 {
+		cmd = fmt.Sprintf("%s cookie=%#x/%#x", cmd, cookie, cookieMask)
+	}
+	out, err := o.execcli.ExecuteCommand(cmd)
+	if err != nil {
+		log.Printf("[ovs] Dumping flows of bridge %v failed with error %v", bridgeName, err)
+		return nil, newErrorOvsctl(err.Error())
+	}
+
+	return parseFlows(out)
+}
+
+// parseFlows parses the output of ovs-ofctl dump-flows, e.g.
+// " cookie=0x5, duration=3.123s, table=0, n_packets=0, n_bytes=0, idle_age=3, priority=20,ip,in_port=3 actions=drop"
+func parseFlows(out string) ([]Flow, error) {
+	var flows []Flow
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		// skip the header, e.g. "NXST_FLOW reply (xid=0x4):"
+		if !strings.HasPrefix(line, "cookie=") {
+			continue
+		}
+
+		fields, _, _ := strings.Cut(line, " actions=")
+		var flow Flow
+		for _, field := range strings.Split(fields, ", ") {
+			key, value, _ := strings.Cut(field, "=")
+			var err error
+			switch key {
+			case "cookie":
+				flow.Cookie, err = strconv.ParseUint(value, 0, 64)
+			case "duration":
+				flow.Duration, err = time.ParseDuration(value)
+			case "table":
+				flow.Table, err = strconv.Atoi(value)
+			case "n_packets", "n_bytes", "idle_age", "hard_age", "idle_timeout", "hard_timeout", "reset_counts":
+			default:
+				flow.Match = field
+			}
+			if err != nil {
+				return nil, newErrorOvsctl(fmt.Sprintf("failed to parse %s of flow %s: %v", key, line, err))
+			}
+		}
+		flows = append(flows, flow)
+	}
+
+	return flows, nil
+}
+
+// DeleteFlowsByCookie deletes the flows of the bridge which are tagged with the cookie.
+func (o Ovsctl) DeleteFlowsByCookie(bridgeName string, cookie uint64) error {
+	cmd := fmt.Sprintf("ovs-ofctl del-flows %s cookie=%#x/-1", bridgeName, cookie)
+	_, err := o.execcli.ExecuteCommand(cmd)
+	if err != nil {
+		log.Printf("[ovs] Deleting flows with cookie %#x failed with error %v", cookie, err)
+		return newErrorOvsctl(err.Error())
+	}
+
+	return nil
+}
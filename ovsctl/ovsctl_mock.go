@@ -57,7 +57,7 @@ func (m MockOvsctl) AddArpSnatRule(bridgeName string, mac string, macHex string,
 	return nil
 }
 
-func (m MockOvsctl) AddIPSnatRule(bridgeName string, ip net.IP, vlanID int, port string, mac string, outport string) error {
+func (m MockOvsctl) AddIPSnatRule(bridgeName string, ip net.IP, vlanID int, port string, mac string, outport string, cookie uint64) error {
 	if m.returnError {
 		return newErrorOvsctl(m.errorStr)
 	}
@@ -85,7 +85,7 @@ func (m MockOvsctl) AddArpReplyRule(bridgeName string, port string, ip net.IP, m
 	return nil
 }
 
-func (m MockOvsctl) AddMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, containerPort string) error {
+func (m MockOvsctl) AddMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, containerPort string, cookie uint64) error {
 	if m.returnError {
 		return newErrorOvsctl(m.errorStr)
 	}
@@ -104,3 +104,17 @@ func (m MockOvsctl) DeletePortFromOVS(bridgeName string, interfaceName string) e
 	}
 	return nil
 }
+
+func (m MockOvsctl) ListFlowsByCookie(bridgeName string, cookie uint64, cookieMask uint64) ([]Flow, error) {
+	if m.returnError {
+		return nil, newErrorOvsctl(m.errorStr)
+	}
+	return []Flow{}, nil
+}
+
+func (m MockOvsctl) DeleteFlowsByCookie(bridgeName string, cookie uint64) error {
+	if m.returnError {
+		return newErrorOvsctl(m.errorStr)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package ovsctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlows(t *testing.T) {
+	out := `NXST_FLOW reply (xid=0x4):
+ cookie=0x0, duration=612.5s, table=0, n_packets=3, n_bytes=126, idle_age=12, priority=20,arp,arp_op=1 actions=load:0x2->NXM_OF_ARP_OP[],IN_PORT
+ cookie=0x1f2e, duration=3.25s, table=0, n_packets=0, n_bytes=0, idle_age=3, priority=10,ip,in_port=3 actions=drop
+`
+	flows, err := parseFlows(out)
+	require.NoError(t, err)
+	require.Equal(t, []Flow{
+		{Cookie: 0, Table: 0, Duration: 612500 * time.Millisecond, Match: "priority=20,arp,arp_op=1"},
+		{Cookie: 0x1f2e, Table: 0, Duration: 3250 * time.Millisecond, Match: "priority=10,ip,in_port=3"},
+	}, flows)
+
+	_, err = parseFlows(" cookie=0xzz, duration=1s, table=0, priority=10,ip actions=drop")
+	require.Error(t, err)
+}
+
+func TestFlowCookie(t *testing.T) {
+	require.Equal(t, FlowCookie("ep1"), FlowCookie("ep1"))
+	require.NotEqual(t, FlowCookie("ep1"), FlowCookie("ep2"))
+	require.NotZero(t, FlowCookie(""))
+}
@@ -0,0 +1,278 @@
+package ovsctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// ovsdbSocket is the unix socket which ovsdb-server listens on
+	ovsdbSocket   = "/var/run/openvswitch/db.sock"
+	ovsdbDatabase = "Open_vSwitch"
+	// ovsdbTimeout bounds a transaction with ovsdb-server
+	ovsdbTimeout = 10 * time.Second
+	// reconfigureTimeout bounds the wait for ovs-vswitchd to apply a transaction, like ovs-vsctl --timeout
+	reconfigureTimeout = 10 * time.Second
+	// reconfigurePollInterval is how often the progress of ovs-vswitchd is checked
+	reconfigurePollInterval = 50 * time.Millisecond
+)
+
+var (
+	errOVSDBTransaction   = errors.New("ovsdb transaction failed")
+	errRowNotFound        = errors.New("ovsdb row not found")
+	errOFPortNotAssigned  = errors.New("ofport is not assigned")
+	errReconfigureTimeout = errors.New("timed out waiting for ovs-vswitchd to reconfigure")
+)
+
+// ovsdbOperation is an operation of an OVSDB transaction, see RFC 7047 section 5.2. It is a map since the
+// members which an operation allows differ, e.g. an insert has no where clause but a select of all rows has an
+// empty one.
+type ovsdbOperation map[string]interface{}
+
+func selectOp(table string, where []interface{}, columns ...string) ovsdbOperation {
+	return ovsdbOperation{"op": "select", "table": table, "where": where, "columns": columns}
+}
+
+func insertOp(table string, row map[string]interface{}, uuidName string) ovsdbOperation {
+	return ovsdbOperation{"op": "insert", "table": table, "row": row, "uuid-name": uuidName}
+}
+
+func mutateOp(table string, where []interface{}, mutations ...[]interface{}) ovsdbOperation {
+	return ovsdbOperation{"op": "mutate", "table": table, "where": where, "mutations": mutations}
+}
+
+// allRows is the where clause which matches every row, e.g. the single row of the Open_vSwitch table
+var allRows = []interface{}{}
+
+// ovsdbResult is the result of an operation of an OVSDB transaction.
+type ovsdbResult struct {
+	Rows    []map[string]json.RawMessage `json:"rows"`
+	Count   int                          `json:"count"`
+	Error   string                       `json:"error"`
+	Details string                       `json:"details"`
+}
+
+type ovsdbRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     interface{}   `json:"id"`
+}
+
+type ovsdbResponse struct {
+	// Method is set on the requests of the server, e.g. echo
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  interface{}     `json:"error"`
+	ID     interface{}     `json:"id"`
+}
+
+// condition returns the where clause which matches the rows whose column equals the value.
+func condition(column string, value interface{}) []interface{} {
+	return []interface{}{[]interface{}{column, "==", value}}
+}
+
+func namedUUID(name string) []interface{} {
+	return []interface{}{"named-uuid", name}
+}
+
+// ovsdbClient is a JSON-RPC client of ovsdb-server. It opens a connection per transaction, which is cheaper than
+// a process per ovs-vsctl command and suits the short-lived plugin.
+type ovsdbClient struct {
+	dial func() (net.Conn, error)
+	id   int
+}
+
+func newOVSDBClient(socket string) *ovsdbClient {
+	return &ovsdbClient{
+		dial: func() (net.Conn, error) {
+			return net.DialTimeout("unix", socket, ovsdbTimeout)
+		},
+	}
+}
+
+// transact runs the operations in one transaction, which either applies all of them or none.
+func (c *ovsdbClient) transact(ops ...ovsdbOperation) ([]ovsdbResult, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ovsdb-server: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(ovsdbTimeout)); err != nil {
+		return nil, fmt.Errorf("failed to set deadline on ovsdb connection: %w", err)
+	}
+
+	c.id++
+	params := []interface{}{ovsdbDatabase}
+	for _, op := range ops {
+		params = append(params, op)
+	}
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(ovsdbRequest{Method: "transact", Params: params, ID: c.id}); err != nil {
+		return nil, fmt.Errorf("failed to send ovsdb transaction: %w", err)
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var resp ovsdbResponse
+		if err := dec.Decode(&resp); err != nil {
+			return nil, fmt.Errorf("failed to read ovsdb response: %w", err)
+		}
+
+		// the server checks the liveness of the connection with echo requests
+		if resp.Method == "echo" {
+			if err := enc.Encode(map[string]interface{}{"result": resp.Params, "error": nil, "id": resp.ID}); err != nil {
+				return nil, fmt.Errorf("failed to reply to ovsdb echo: %w", err)
+			}
+			continue
+		}
+
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%w: %v", errOVSDBTransaction, resp.Error)
+		}
+
+		var results []ovsdbResult
+		if err := json.Unmarshal(resp.Result, &results); err != nil {
+			return nil, fmt.Errorf("failed to parse ovsdb results: %w", err)
+		}
+
+		// an operation which failed aborts the transaction, the result after the operations reports a failed commit
+		for i, result := range results {
+			if result.Error != "" {
+				op := "commit"
+				if i < len(ops) {
+					op = fmt.Sprintf("%v %v", ops[i]["op"], ops[i]["table"])
+				}
+				return nil, fmt.Errorf("%w: %s: %s: %s", errOVSDBTransaction, op, result.Error, result.Details)
+			}
+		}
+
+		return results, nil
+	}
+}
+
+// selectUUID returns the UUID of the row of the table with the name.
+func (c *ovsdbClient) selectUUID(table, name string) (string, error) {
+	results, err := c.transact(selectOp(table, condition("name", name), "_uuid"))
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 || len(results[0].Rows) == 0 {
+		return "", fmt.Errorf("%w: %s %s", errRowNotFound, table, name)
+	}
+
+	var uuid []string
+	if err := json.Unmarshal(results[0].Rows[0]["_uuid"], &uuid); err != nil || len(uuid) != 2 {
+		return "", fmt.Errorf("%w: bad uuid of %s %s: %v", errOVSDBTransaction, table, name, err)
+	}
+	return uuid[1], nil
+}
+
+// transactAndWait runs the operations in one transaction with ovsdb-server and waits for ovs-vswitchd to apply
+// them, like ovs-vsctl does, so e.g. the ofport of a new interface is assigned when it returns.
+func (c *ovsdbClient) transactAndWait(ops ...ovsdbOperation) error {
+	ops = append(ops,
+		mutateOp("Open_vSwitch", allRows, []interface{}{"next_cfg", "+=", 1}),
+		selectOp("Open_vSwitch", allRows, "next_cfg"))
+	results, err := c.transact(ops...)
+	if err != nil {
+		return err
+	}
+
+	nextCfg, err := openVSwitchColumn(results[len(ops)-1], "next_cfg")
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(reconfigureTimeout)
+	for {
+		results, err := c.transact(selectOp("Open_vSwitch", allRows, "cur_cfg"))
+		if err != nil {
+			return err
+		}
+		curCfg, err := openVSwitchColumn(results[0], "cur_cfg")
+		if err != nil {
+			return err
+		}
+		if curCfg >= nextCfg {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: cur_cfg %d, next_cfg %d", errReconfigureTimeout, curCfg, nextCfg)
+		}
+		time.Sleep(reconfigurePollInterval)
+	}
+}
+
+// openVSwitchColumn returns an integer column of the single row of the Open_vSwitch table
+func openVSwitchColumn(result ovsdbResult, column string) (int, error) {
+	if len(result.Rows) != 1 {
+		return 0, fmt.Errorf("%w: %d rows in Open_vSwitch table", errOVSDBTransaction, len(result.Rows))
+	}
+	var value int
+	if err := json.Unmarshal(result.Rows[0][column], &value); err != nil {
+		return 0, fmt.Errorf("%w: bad %s: %v", errOVSDBTransaction, column, err)
+	}
+	return value, nil
+}
+
+// createBridge adds the bridge with its internal port, like ovs-vsctl add-br.
+func (c *ovsdbClient) createBridge(bridgeName string) error {
+	return c.transactAndWait(
+		insertOp("Interface", map[string]interface{}{"name": bridgeName, "type": "internal"}, "iface"),
+		insertOp("Port", map[string]interface{}{"name": bridgeName, "interfaces": namedUUID("iface")}, "port"),
+		insertOp("Bridge", map[string]interface{}{"name": bridgeName, "ports": namedUUID("port")}, "bridge"),
+		mutateOp("Open_vSwitch", allRows, []interface{}{"bridges", "insert", namedUUID("bridge")}))
+}
+
+// deleteBridge removes the bridge, like ovs-vsctl del-br. Its ports and interfaces are garbage collected.
+func (c *ovsdbClient) deleteBridge(bridgeName string) error {
+	uuid, err := c.selectUUID("Bridge", bridgeName)
+	if err != nil {
+		return err
+	}
+
+	return c.transactAndWait(
+		mutateOp("Open_vSwitch", allRows, []interface{}{"bridges", "delete", []interface{}{"uuid", uuid}}))
+}
+
+// addPort adds the interface to the bridge, like ovs-vsctl add-port.
+func (c *ovsdbClient) addPort(bridgeName, ifName string) error {
+	return c.transactAndWait(
+		insertOp("Interface", map[string]interface{}{"name": ifName}, "iface"),
+		insertOp("Port", map[string]interface{}{"name": ifName, "interfaces": namedUUID("iface")}, "port"),
+		mutateOp("Bridge", condition("name", bridgeName), []interface{}{"ports", "insert", namedUUID("port")}))
+}
+
+// deletePort removes the interface from the bridge, like ovs-vsctl del-port.
+func (c *ovsdbClient) deletePort(bridgeName, ifName string) error {
+	uuid, err := c.selectUUID("Port", ifName)
+	if err != nil {
+		return err
+	}
+
+	return c.transactAndWait(
+		mutateOp("Bridge", condition("name", bridgeName), []interface{}{"ports", "delete", []interface{}{"uuid", uuid}}))
+}
+
+// ofport returns the OpenFlow port number of the interface, like ovs-vsctl get Interface ofport.
+func (c *ovsdbClient) ofport(ifName string) (int, error) {
+	results, err := c.transact(selectOp("Interface", condition("name", ifName), "ofport"))
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 || len(results[0].Rows) == 0 {
+		return 0, fmt.Errorf("%w: Interface %s", errRowNotFound, ifName)
+	}
+
+	// an unassigned ofport is the empty set ["set",[]]
+	var ofport int
+	if err := json.Unmarshal(results[0].Rows[0]["ofport"], &ofport); err != nil {
+		return 0, fmt.Errorf("%w: %s", errOFPortNotAssigned, ifName)
+	}
+	return ofport, nil
+}
@@ -0,0 +1,134 @@
+package ovsctl
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOVSDB answers each transaction with the results of the handler and records the operations.
+type fakeOVSDB struct {
+	transactions [][]map[string]interface{}
+	handler      func(ops []map[string]interface{}) interface{}
+	// echo makes the server check the liveness of the connection before answering
+	echo bool
+}
+
+func (f *fakeOVSDB) client(t *testing.T) *ovsdbClient {
+	return &ovsdbClient{
+		dial: func() (net.Conn, error) {
+			client, server := net.Pipe()
+			go f.serve(t, server)
+			return client, nil
+		},
+	}
+}
+
+func (f *fakeOVSDB) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+		ID     int               `json:"id"`
+	}
+	if err := dec.Decode(&req); err != nil {
+		return
+	}
+	require.Equal(t, "transact", req.Method)
+
+	var ops []map[string]interface{}
+	for _, param := range req.Params[1:] {
+		var op map[string]interface{}
+		require.NoError(t, json.Unmarshal(param, &op))
+		ops = append(ops, op)
+	}
+	f.transactions = append(f.transactions, ops)
+
+	if f.echo {
+		_ = enc.Encode(map[string]interface{}{"method": "echo", "params": []string{}, "id": "echo"})
+		var reply map[string]interface{}
+		if err := dec.Decode(&reply); err != nil {
+			return
+		}
+		require.Equal(t, "echo", reply["id"])
+	}
+	_ = enc.Encode(map[string]interface{}{"result": f.handler(ops), "error": nil, "id": req.ID})
+}
+
+// cfgHandler answers like ovsdb-server and an ovs-vswitchd which has applied the next config.
+func cfgHandler(ops []map[string]interface{}) interface{} {
+	results := make([]interface{}, 0, len(ops))
+	for _, op := range ops {
+		switch op["op"] {
+		case "select":
+			columns := op["columns"].([]interface{})
+			results = append(results, map[string]interface{}{"rows": []interface{}{map[string]interface{}{columns[0].(string): 7}}})
+		case "insert":
+			results = append(results, map[string]interface{}{"uuid": []string{"uuid", "0cb0ff6e-2b41-4f4e-9b3c-1d3c7f1a2b3c"}})
+		default:
+			results = append(results, map[string]interface{}{"count": 1})
+		}
+	}
+	return results
+}
+
+func TestOVSDBAddPort(t *testing.T) {
+	f := &fakeOVSDB{handler: cfgHandler, echo: true}
+	require.NoError(t, f.client(t).addPort("azure0", "azv1234"))
+
+	// the port is added in one transaction, then the progress of ovs-vswitchd is checked
+	require.Len(t, f.transactions, 2)
+	ops := f.transactions[0]
+	require.Len(t, ops, 5)
+	require.Equal(t, "Interface", ops[0]["table"])
+	require.Equal(t, "Port", ops[1]["table"])
+	require.Equal(t, "Bridge", ops[2]["table"])
+	require.Equal(t, []interface{}{[]interface{}{"name", "==", "azure0"}}, ops[2]["where"])
+	require.Equal(t, "Open_vSwitch", ops[3]["table"])
+	require.Equal(t, []interface{}{}, ops[3]["where"], "the mutation of the next config matches all rows")
+	require.NotContains(t, ops[0], "where", "an insert has no where clause")
+}
+
+func TestOVSDBOFPort(t *testing.T) {
+	ofport := interface{}(3)
+	f := &fakeOVSDB{handler: func([]map[string]interface{}) interface{} {
+		return []interface{}{map[string]interface{}{"rows": []interface{}{map[string]interface{}{"ofport": ofport}}}}
+	}}
+	client := f.client(t)
+
+	port, err := client.ofport("azv1234")
+	require.NoError(t, err)
+	require.Equal(t, 3, port)
+
+	ofport = []interface{}{"set", []interface{}{}}
+	_, err = client.ofport("azv1234")
+	require.ErrorIs(t, err, errOFPortNotAssigned)
+}
+
+func TestOVSDBTransactionError(t *testing.T) {
+	f := &fakeOVSDB{handler: func([]map[string]interface{}) interface{} {
+		return []interface{}{
+			map[string]interface{}{"uuid": []string{"uuid", "0cb0ff6e-2b41-4f4e-9b3c-1d3c7f1a2b3c"}},
+			map[string]interface{}{"error": "constraint violation", "details": "Transaction causes multiple rows in \"Port\" table to have identical values"},
+		}
+	}}
+
+	err := f.client(t).addPort("azure0", "azv1234")
+	require.ErrorIs(t, err, errOVSDBTransaction)
+	require.Contains(t, err.Error(), "insert Port")
+}
+
+func TestOVSDBDeleteMissingBridge(t *testing.T) {
+	f := &fakeOVSDB{handler: func([]map[string]interface{}) interface{} {
+		return []interface{}{map[string]interface{}{"rows": []interface{}{}}}
+	}}
+
+	err := f.client(t).deleteBridge("azure0")
+	require.ErrorIs(t, err, errRowNotFound)
+	require.Len(t, f.transactions, 1)
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// defaultMaxStreamSize is the number of bytes of each output stream of a command logged by default.
+const defaultMaxStreamSize = 4096
+
+// ExecuteCommand runs a shell command bounded by the client timeout and returns its stdout.
+func (p *execClient) ExecuteCommand(command string) (string, error) {
+	result, err := p.ExecuteCommandContext(context.Background(), command, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Stdout, nil
+}
+
+// ExecuteCommandContext runs a shell command until it exits or the context is done. Contexts without
+// a deadline are bounded by the client timeout. The output captured so far is returned with the error
+// if the command fails, the error matches the context error if the command was killed.
+func (p *execClient) ExecuteCommandContext(ctx context.Context, command string, opts *ExecOptions) (*ExecResult, error) {
+	log.Printf("[Azure-Utils] %s", command)
+
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
+
+	if _, ok := ctx.Deadline(); !ok && p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	stdout := newOutputWriter("stdout", opts)
+	stderr := newOutputWriter("stderr", opts)
+
+	cmd := newShellCommand(command)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Start()
+	if err == nil {
+		// Kill the command and its children when the context is done, children that inherited the
+		// output pipes would otherwise block Wait until they exit.
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				killCommand(cmd)
+			case <-done:
+			}
+		}()
+
+		err = cmd.Wait()
+		close(done)
+	}
+	stdout.flush()
+	stderr.flush()
+
+	result := &ExecResult{
+		Stdout: stdout.buf.String(),
+		Stderr: stderr.buf.String(),
+	}
+
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return result, fmt.Errorf("%w:%s", err, result.Stderr)
+	}
+
+	return result, nil
+}
+
+// outputWriter captures an output stream of a command and optionally logs it line by line.
+type outputWriter struct {
+	name      string
+	stream    bool
+	remaining int
+	buf       bytes.Buffer
+	line      []byte
+}
+
+func newOutputWriter(name string, opts *ExecOptions) *outputWriter {
+	w := &outputWriter{
+		name:      name,
+		stream:    opts.StreamOutput,
+		remaining: opts.MaxStreamSize,
+	}
+
+	if w.remaining <= 0 {
+		w.remaining = defaultMaxStreamSize
+	}
+
+	return w
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	if w.stream && w.remaining > 0 {
+		logged := p
+		if len(logged) > w.remaining {
+			logged = logged[:w.remaining]
+		}
+		w.remaining -= len(logged)
+
+		w.line = append(w.line, logged...)
+		for {
+			i := bytes.IndexByte(w.line, '\n')
+			if i < 0 {
+				break
+			}
+			log.Printf("[Azure-Utils] %s: %s", w.name, w.line[:i])
+			w.line = w.line[i+1:]
+		}
+
+		if w.remaining == 0 {
+			w.flush()
+			log.Printf("[Azure-Utils] %s: output truncated", w.name)
+		}
+	}
+
+	return len(p), nil
+}
+
+// flush logs the last line of the stream if it is not terminated by a newline.
+func (w *outputWriter) flush() {
+	if len(w.line) > 0 {
+		log.Printf("[Azure-Utils] %s: %s", w.name, w.line)
+		w.line = nil
+	}
+}
@@ -1,6 +1,9 @@
 package platform
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 type mockExecClient struct {
 	returnError bool
@@ -22,3 +25,11 @@ func (e *mockExecClient) ExecuteCommand(string) (string, error) {
 
 	return "", nil
 }
+
+func (e *mockExecClient) ExecuteCommandContext(context.Context, string, *ExecOptions) (*ExecResult, error) {
+	if e.returnError {
+		return &ExecResult{}, ErrMockExec
+	}
+
+	return &ExecResult{}, nil
+}
@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"context"
 	"time"
 )
 
@@ -12,9 +13,26 @@ type execClient struct {
 	Timeout time.Duration
 }
 
+// ExecOptions control how ExecuteCommandContext runs a command.
+type ExecOptions struct {
+	// StreamOutput logs the stdout and stderr lines of the command as they are written, so the
+	// progress of long running commands is visible before they exit.
+	StreamOutput bool
+	// MaxStreamSize caps the bytes of each output stream written to the log, defaultMaxStreamSize
+	// if zero. The captured output is not capped.
+	MaxStreamSize int
+}
+
+// ExecResult is the captured output of a command.
+type ExecResult struct {
+	Stdout string
+	Stderr string
+}
+
 //nolint:revive // ExecClient make sense
 type ExecClient interface {
 	ExecuteCommand(command string) (string, error)
+	ExecuteCommandContext(ctx context.Context, command string, opts *ExecOptions) (*ExecResult, error)
 }
 
 func NewExecClient() ExecClient {
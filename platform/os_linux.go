@@ -4,12 +4,12 @@
 package platform
 
 import (
-	"bytes"
-	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -62,7 +62,8 @@ func GetProcessSupport() error {
 // GetLastRebootTime returns the last time the system rebooted.
 func GetLastRebootTime() (time.Time, error) {
 	// Query last reboot time.
-	out, err := exec.Command("uptime", "-s").Output()
+	p := NewExecClient()
+	out, err := p.ExecuteCommand("uptime -s")
 	if err != nil {
 		log.Printf("Failed to query uptime, err:%v", err)
 		return time.Time{}.UTC(), err
@@ -79,26 +80,16 @@ func GetLastRebootTime() (time.Time, error) {
 	return rebootTime.UTC(), nil
 }
 
-func (p *execClient) ExecuteCommand(command string) (string, error) {
-	log.Printf("[Azure-Utils] %s", command)
-
-	var stderr bytes.Buffer
-	var out bytes.Buffer
-
-	// Create a new context and add a timeout to it
-	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
-	defer cancel() // The cancel should be deferred so resources are cleaned up
-
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-	cmd.Stderr = &stderr
-	cmd.Stdout = &out
-
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("%s:%s", err.Error(), stderr.String())
-	}
+// newShellCommand returns the command running a shell command line in its own process group.
+func newShellCommand(command string) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
 
-	return out.String(), nil
+// killCommand kills the process group of a command started by newShellCommand.
+func killCommand(cmd *exec.Cmd) {
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
 }
 
 func SetOutboundSNAT(subnet string) error {
@@ -150,6 +141,17 @@ func GetOSDetails() (map[string]string, error) {
 	return osInfoArr, nil
 }
 
+// IsProcessAlive reports whether a process with the pid is running.
+func IsProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// Signal 0 only checks whether the process exists and may be signalled.
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
 func GetProcessNameByID(pidstr string) (string, error) {
 	p := NewExecClient()
 	pidstr = strings.Trim(pidstr, "\n")
@@ -1,6 +1,9 @@
 package platform
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -27,3 +30,44 @@ func TestExecuteCommandNoTimeout(t *testing.T) {
 		t.Errorf("TestExecuteCommandNoTimeout failed with error %v", err)
 	}
 }
+
+// Command is killed when the context deadline passes, the error matches the context error
+func TestExecuteCommandContextDeadline(t *testing.T) {
+	client := NewExecClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := client.ExecuteCommandContext(ctx, "sleep 3", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TestExecuteCommandContextDeadline expected deadline exceeded error, got %v", err)
+	}
+}
+
+// Stdout and stderr are captured separately and returned with the error of a failed command
+func TestExecuteCommandContextOutput(t *testing.T) {
+	client := NewExecClient()
+
+	result, err := client.ExecuteCommandContext(context.Background(), "echo out; echo err >&2; exit 1", nil)
+	if err == nil {
+		t.Fatalf("TestExecuteCommandContextOutput should have returned exit error")
+	}
+
+	if result.Stdout != "out\n" || result.Stderr != "err\n" {
+		t.Errorf("TestExecuteCommandContextOutput unexpected output %+v", result)
+	}
+}
+
+// Streaming the output to the log is capped but does not truncate the captured output
+func TestExecuteCommandContextStreamOutput(t *testing.T) {
+	client := NewExecClient()
+
+	result, err := client.ExecuteCommandContext(context.Background(), "seq 1 1000", &ExecOptions{StreamOutput: true, MaxStreamSize: 16})
+	if err != nil {
+		t.Fatalf("TestExecuteCommandContextStreamOutput failed with error %v", err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(result.Stdout), "\n"); len(lines) != 1000 {
+		t.Errorf("TestExecuteCommandContextStreamOutput captured %d lines", len(lines))
+	}
+}
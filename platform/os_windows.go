@@ -5,6 +5,7 @@ package platform
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -96,21 +97,14 @@ func GetLastRebootTime() (time.Time, error) {
 	return rebootTime.UTC(), nil
 }
 
-func (p *execClient) ExecuteCommand(command string) (string, error) {
-	log.Printf("[Azure-Utils] %s", command)
-
-	var stderr bytes.Buffer
-	var out bytes.Buffer
-	cmd := exec.Command("cmd", "/c", command)
-	cmd.Stderr = &stderr
-	cmd.Stdout = &out
-
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("%s:%s", err.Error(), stderr.String())
-	}
+// newShellCommand returns the command running a cmd command line.
+func newShellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/c", command)
+}
 
-	return out.String(), nil
+// killCommand kills the process of a command started by newShellCommand.
+func killCommand(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
 }
 
 func SetOutboundSNAT(subnet string) error {
@@ -122,9 +116,8 @@ func SetOutboundSNAT(subnet string) error {
 func ClearNetworkConfiguration() (bool, error) {
 	jsonStore := CNIRuntimePath + "azure-vnet.json"
 	log.Printf("Deleting the json store %s", jsonStore)
-	cmd := exec.Command("cmd", "/c", "del", jsonStore)
-
-	if err := cmd.Run(); err != nil {
+	p := NewExecClient()
+	if _, err := p.ExecuteCommand("del " + jsonStore); err != nil {
 		log.Printf("Error deleting the json store %s", jsonStore)
 		return true, err
 	}
@@ -193,6 +186,30 @@ func GetOSDetails() (map[string]string, error) {
 	return nil, nil
 }
 
+// stillActive is the exit code of processes which have not exited.
+const stillActive = 259
+
+// IsProcessAlive reports whether a process with the pid is running.
+func IsProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// The process exists if it can not be opened for lack of access rights.
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck // nothing to do on failure
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+
+	return code == stillActive
+}
+
 func GetProcessNameByID(pidstr string) (string, error) {
 	pidstr = strings.Trim(pidstr, "\r\n")
 	cmd := fmt.Sprintf("Get-Process -Id %s|Format-List", pidstr)
@@ -0,0 +1,17 @@
+package platform
+
+import "time"
+
+// KeyValueStore represents a persistent store of (key,value) pairs. It is shared by the state of the
+// network and ipam managers. The file-based implementation is store.NewJsonFileStore, which locks the
+// store with a timeout, breaks locks of exited processes and writes the file atomically with fsync.
+type KeyValueStore interface {
+	Exists() bool
+	Read(key string, value interface{}) error
+	Write(key string, value interface{}) error
+	Flush() error
+	Lock(timeout time.Duration) error
+	Unlock() error
+	GetModificationTime() (time.Time, error)
+	Remove()
+}
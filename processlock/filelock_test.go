@@ -2,9 +2,11 @@ package processlock
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -110,3 +112,106 @@ func TestReleaseFileLockError(t *testing.T) {
 		})
 	}
 }
+
+func TestLockTimeout(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "timeout.lock")
+	owner := &fileLock{filePath: lockFile}
+	require.NoError(t, owner.Lock())
+
+	// the owner is this process, so the lock is not broken
+	waiter := &fileLock{filePath: lockFile}
+	err := waiter.LockTimeout(50 * time.Millisecond)
+	require.ErrorIs(t, err, ErrLockTimeout)
+
+	// the abandoned attempt releases the lock once it acquires it
+	require.NoError(t, owner.Unlock())
+	require.NoError(t, waiter.LockTimeout(time.Second))
+	require.NoError(t, waiter.Unlock())
+}
+
+func TestLockTimeoutBreaksStaleLock(t *testing.T) {
+	// the pid of a process which exited
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	exitedPid := cmd.Process.Pid
+
+	// the lock is held by an orphan which inherited it from the exited process
+	lockFile := filepath.Join(t.TempDir(), "stale.lock")
+	orphan := &fileLock{filePath: lockFile}
+	require.NoError(t, orphan.Lock())
+	require.NoError(t, os.WriteFile(lockFile, []byte(strconv.Itoa(exitedPid)), 0o600))
+
+	waiter := &fileLock{filePath: lockFile}
+	require.NoError(t, waiter.LockTimeout(50*time.Millisecond))
+
+	b, err := os.ReadFile(lockFile)
+	require.NoError(t, err)
+	require.Equal(t, strconv.Itoa(os.Getpid()), string(b))
+
+	require.NoError(t, waiter.Unlock())
+	require.NoError(t, orphan.Unlock())
+}
+
+func TestLockTimeoutBreaksStaleLockOnce(t *testing.T) {
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	exitedPid := cmd.Process.Pid
+
+	lockFile := filepath.Join(t.TempDir(), "stale.lock")
+	orphan := &fileLock{filePath: lockFile}
+	require.NoError(t, orphan.Lock())
+	require.NoError(t, os.WriteFile(lockFile, []byte(strconv.Itoa(exitedPid)), 0o600))
+
+	// waiters timing out together break the stale lock once, only one of them acquires it
+	errs := make(chan error, 2)
+	waiters := []*fileLock{{filePath: lockFile}, {filePath: lockFile}}
+	for _, w := range waiters {
+		w := w
+		go func() { errs <- w.LockTimeout(100 * time.Millisecond) }()
+	}
+
+	acquired := 0
+	for range waiters {
+		if err := <-errs; err == nil {
+			acquired++
+		} else {
+			require.ErrorIs(t, err, ErrLockTimeout)
+		}
+	}
+	require.Equal(t, 1, acquired)
+
+	for _, w := range waiters {
+		_ = w.Unlock()
+	}
+	require.NoError(t, orphan.Unlock())
+}
+
+func TestRWFileLock(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "rw.lock")
+	newLock := func() RWInterface {
+		l, err := NewRWFileLock(lockFile)
+		require.NoError(t, err)
+		return l
+	}
+
+	// readers share the lock
+	reader1, reader2 := newLock(), newLock()
+	require.NoError(t, reader1.RLockTimeout(time.Second))
+	require.NoError(t, reader2.RLockTimeout(time.Second))
+
+	// a writer waits for the readers
+	writer := newLock()
+	require.ErrorIs(t, writer.LockTimeout(50*time.Millisecond), ErrLockTimeout)
+
+	require.NoError(t, reader1.Unlock())
+	require.NoError(t, reader2.Unlock())
+	require.NoError(t, writer.LockTimeout(time.Second))
+
+	// and readers wait for the writer
+	require.ErrorIs(t, reader1.RLockTimeout(50*time.Millisecond), ErrLockTimeout)
+	require.NoError(t, writer.Unlock())
+	require.NoError(t, reader1.RLockTimeout(time.Second))
+	require.NoError(t, reader1.Unlock())
+
+	require.ErrorIs(t, reader1.Unlock(), ErrInvalidFile)
+}
@@ -1,6 +1,8 @@
 package processlock
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 )
 
@@ -25,6 +27,18 @@ func (l *mockFileLock) Lock() error {
 	return nil
 }
 
+func (l *mockFileLock) LockTimeout(timeout time.Duration) error {
+	if l.fail {
+		return ErrMockFileLock
+	}
+
+	if timeout <= 0 {
+		return ErrLockTimeout
+	}
+
+	return nil
+}
+
 func (l *mockFileLock) Unlock() error {
 	if l.fail {
 		return ErrMockFileLock
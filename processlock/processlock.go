@@ -5,8 +5,13 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-container-networking/internal/lockedfile"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
 	"github.com/pkg/errors"
 )
 
@@ -14,16 +19,28 @@ import (
 var (
 	ErrEmptyFilePath = errors.New("empty file path")
 	ErrInvalidFile   = errors.New("invalid File pointer")
+	ErrLockTimeout   = errors.New("timed out acquiring lock")
+)
+
+const (
+	// breakLockExtension is added to the lock path for the lock serializing the takeover of stale locks.
+	breakLockExtension = ".break"
+	// staleLockExtension is added to the lock path of a stale lock file while it is removed.
+	staleLockExtension = ".stale"
 )
 
 //nolint:revive // this naming makes sense
 type Interface interface {
 	Lock() error
+	// LockTimeout acquires the lock, giving up after the timeout. A lock whose owner is no longer
+	// running is broken and acquired again.
+	LockTimeout(timeout time.Duration) error
 	Unlock() error
 }
 
 type fileLock struct {
 	filePath string
+	mu       sync.Mutex
 	file     *lockedfile.File
 }
 
@@ -44,22 +61,162 @@ func NewFileLock(fileAbsPath string) (Interface, error) {
 }
 
 func (l *fileLock) Lock() error {
-	var err error
-
-	l.file, err = lockedfile.Create(l.filePath)
+	f, err := l.acquire(nil)
 	if err != nil {
-		return errors.Wrap(err, "lockedfile create error in lock")
+		return err
+	}
+
+	l.setFile(f)
+	return nil
+}
+
+type acquireResult struct {
+	file *lockedfile.File
+	err  error
+}
+
+func (l *fileLock) LockTimeout(timeout time.Duration) error {
+	broken := false
+	for {
+		result := make(chan acquireResult, 1)
+		abandoned := make(chan struct{})
+		go func() {
+			f, err := l.acquire(abandoned)
+			result <- acquireResult{file: f, err: err}
+		}()
+
+		select {
+		case r := <-result:
+			if r.err != nil {
+				return r.err
+			}
+			l.setFile(r.file)
+			return nil
+		case <-time.After(timeout):
+		}
+
+		// The abandoned attempt keeps waiting, release the lock if it is acquired after all.
+		close(abandoned)
+		go func() {
+			if r := <-result; r.err == nil {
+				_ = r.file.Close()
+			}
+		}()
+
+		pid, err := l.owner()
+		if err != nil {
+			return errors.Wrapf(ErrLockTimeout, "%s: %v", l.filePath, err)
+		}
+
+		if broken || platform.IsProcessAlive(pid) {
+			return errors.Wrapf(ErrLockTimeout, "%s held by pid %d", l.filePath, pid)
+		}
+
+		// The owner exited without the lock being released, which happens when the locked file was
+		// inherited by a process that outlived it. Replace the lock file so that it can be acquired.
+		if err := l.breakStale(pid); err != nil {
+			return err
+		}
+		broken = true
 	}
+}
 
-	_, err = l.file.WriteString(strconv.Itoa(os.Getpid()))
+// breakStale moves the lock file of the exited process pid out of the way. Waiters timing out together
+// take turns under a second lock and check the owner again, so a lock file created and acquired by the
+// waiter which broke the lock first is not removed by the next one.
+func (l *fileLock) breakStale(pid int) error {
+	unlock, err := lockedfile.MutexAt(l.filePath + breakLockExtension).Lock()
 	if err != nil {
-		return errors.Wrap(err, "write to lockfile failed")
+		return errors.Wrap(err, "failed to lock stale lock takeover")
+	}
+	defer unlock()
+
+	if current, err := l.owner(); err != nil || current != pid {
+		// already broken and acquired again, or being acquired
+		return nil
+	}
+
+	log.Printf("Breaking lock %s of exited process %d", l.filePath, pid)
+	stale := l.filePath + staleLockExtension
+	if err := os.Rename(l.filePath, stale); err != nil {
+		return errors.Wrap(err, "failed to move stale lock file")
+	}
+
+	if err := os.Remove(stale); err != nil {
+		return errors.Wrap(err, "failed to remove stale lock file")
 	}
 
 	return nil
 }
 
+// acquire locks the lock file and records the pid of the process in it. It stops retrying if the
+// lock file is replaced after the attempt is abandoned.
+func (l *fileLock) acquire(abandoned <-chan struct{}) (*lockedfile.File, error) {
+	for {
+		f, err := lockedfile.Create(l.filePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "lockedfile create error in lock")
+		}
+
+		// A lock on a lock file which was removed while waiting for it does not exclude anyone.
+		if l.isCurrent(f) {
+			if _, err = f.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+				_ = f.Close()
+				return nil, errors.Wrap(err, "write to lockfile failed")
+			}
+			return f, nil
+		}
+
+		_ = f.Close()
+
+		select {
+		case <-abandoned:
+			return nil, ErrLockTimeout
+		default:
+		}
+	}
+}
+
+// isCurrent reports whether the file is the lock file at the lock path.
+func (l *fileLock) isCurrent(f *lockedfile.File) bool {
+	locked, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	current, err := os.Stat(l.filePath)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(locked, current)
+}
+
+// owner returns the pid recorded in the lock file by the process which acquired it last.
+func (l *fileLock) owner() (int, error) {
+	b, err := os.ReadFile(l.filePath)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read lock file")
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to parse lock owner")
+	}
+
+	return pid, nil
+}
+
+func (l *fileLock) setFile(f *lockedfile.File) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.file = f
+}
+
 func (l *fileLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if l.file == nil {
 		return ErrInvalidFile
 	}
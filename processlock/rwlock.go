@@ -0,0 +1,106 @@
+package processlock
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/internal/lockedfile"
+	"github.com/pkg/errors"
+)
+
+// RWInterface is a lock which is held by one process exclusively or shared by many processes.
+type RWInterface interface {
+	// LockTimeout acquires the lock exclusively, giving up after the timeout.
+	LockTimeout(timeout time.Duration) error
+	// RLockTimeout acquires the lock shared, giving up after the timeout.
+	RLockTimeout(timeout time.Duration) error
+	Unlock() error
+}
+
+// rwFileLock is a reader/writer lock on a file. Unlike fileLock it does not record an owner, so a lock
+// held by an exited process is not broken; the lock file is never removed and the operating system
+// releases the lock when the process exits.
+type rwFileLock struct {
+	filePath string
+	mu       sync.Mutex
+	file     *lockedfile.File
+}
+
+func NewRWFileLock(fileAbsPath string) (RWInterface, error) {
+	if fileAbsPath == "" {
+		return nil, ErrEmptyFilePath
+	}
+
+	//nolint:gomnd //0o664 - permission to create directory in octal
+	err := os.MkdirAll(filepath.Dir(fileAbsPath), os.FileMode(0o664))
+	if err != nil {
+		return nil, errors.Wrap(err, "mkdir lock dir returned error")
+	}
+
+	return &rwFileLock{
+		filePath: fileAbsPath,
+	}, nil
+}
+
+func (l *rwFileLock) LockTimeout(timeout time.Duration) error {
+	return l.lock(os.O_RDWR|os.O_CREATE, timeout)
+}
+
+func (l *rwFileLock) RLockTimeout(timeout time.Duration) error {
+	return l.lock(os.O_RDONLY|os.O_CREATE, timeout)
+}
+
+// lock opens the lock file, which is write locked if flag allows writes and read locked otherwise.
+func (l *rwFileLock) lock(flag int, timeout time.Duration) error {
+	result := make(chan acquireResult, 1)
+	go func() {
+		//nolint:gomnd //0o664 - permission of the lock file in octal
+		f, err := lockedfile.OpenFile(l.filePath, flag, 0o664)
+		result <- acquireResult{file: f, err: err}
+	}()
+
+	select {
+	case r := <-result:
+		if r.err != nil {
+			return errors.Wrap(r.err, "lockedfile open error in lock")
+		}
+		l.setFile(r.file)
+		return nil
+	case <-time.After(timeout):
+	}
+
+	// The attempt keeps waiting, release the lock if it is acquired after all.
+	go func() {
+		if r := <-result; r.err == nil {
+			_ = r.file.Close()
+		}
+	}()
+
+	return errors.Wrapf(ErrLockTimeout, "%s", l.filePath)
+}
+
+func (l *rwFileLock) setFile(f *lockedfile.File) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.file = f
+}
+
+func (l *rwFileLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return ErrInvalidFile
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	if err != nil && !errors.Is(err, fs.ErrClosed) {
+		return errors.Wrap(err, "file close error in unlock")
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/pkg/errors"
+)
+
+const (
+	// BackupExtension - Extension added to the file name for backups, after the number of the backup.
+	BackupExtension = ".bak"
+
+	// CorruptExtension - Extension added to the file name for a corrupt file restored from a backup.
+	CorruptExtension = ".corrupt"
+
+	// DefaultBackupCount - number of backups kept of state files.
+	DefaultBackupCount = 3
+
+	// backupHeader starts the first line of a backup, followed by the checksum of the rest of the backup.
+	backupHeader = "# sha256 "
+)
+
+var errBackupChecksum = errors.New("backup checksum mismatch")
+
+// RecoverableStore is a KeyValueStore which restores its latest valid backup when it finds its file corrupt.
+type RecoverableStore interface {
+	KeyValueStore
+	// Recovered returns true if the store was restored from a backup when it was last read. The backup may
+	// predate changes made outside of the store, which should then be reconciled.
+	Recovered() bool
+}
+
+// NewJsonFileStoreWithBackups creates a jsonFileStore which keeps checksummed backups of its file, the latest
+// first in <fileName>.1.bak, and restores the latest valid one when the file is corrupt or empty.
+//
+//nolint:revive // ignoring name change
+func NewJsonFileStoreWithBackups(fileName string, lockclient processlock.Interface, backupCount int) (KeyValueStore, error) {
+	kvs, err := NewJsonFileStore(fileName, lockclient)
+	if err != nil {
+		return kvs, err
+	}
+
+	kvs.(*jsonFileStore).backupCount = backupCount
+	return kvs, nil
+}
+
+// Recovered returns true if the store was restored from a backup when it was last read.
+func (kvs *jsonFileStore) Recovered() bool {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return kvs.recovered
+}
+
+// backupName returns the name of the nth latest backup.
+func (kvs *jsonFileStore) backupName(n int) string {
+	return fmt.Sprintf("%s.%d%s", kvs.fileName, n, BackupExtension)
+}
+
+// backup rotates the backups and saves the contents of the file as the latest one.
+func (kvs *jsonFileStore) backup(buf []byte) error {
+	sum := sha256.Sum256(buf)
+	checksum := hex.EncodeToString(sum[:])
+	if checksum == kvs.backupChecksum {
+		return nil
+	}
+
+	for n := kvs.backupCount - 1; n >= 1; n-- {
+		if err := platform.ReplaceFile(kvs.backupName(n), kvs.backupName(n+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to rotate backup %s", kvs.backupName(n))
+		}
+	}
+
+	content := make([]byte, 0, len(backupHeader)+len(checksum)+1+len(buf))
+	content = append(content, backupHeader+checksum+"\n"...)
+	content = append(content, buf...)
+	if err := writeFile(kvs.backupName(1), content); err != nil {
+		return err
+	}
+
+	kvs.backupChecksum = checksum
+	return nil
+}
+
+// readBackup returns the contents of the file saved in the backup, after verifying its checksum.
+func readBackup(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	header, buf, found := bytes.Cut(b, []byte("\n"))
+	if !found || !bytes.HasPrefix(header, []byte(backupHeader)) {
+		return nil, errors.Wrap(errBackupChecksum, "missing checksum")
+	}
+
+	sum := sha256.Sum256(buf)
+	if string(header[len(backupHeader):]) != hex.EncodeToString(sum[:]) {
+		return nil, errBackupChecksum
+	}
+
+	return buf, nil
+}
+
+// recover restores the file from its latest valid backup, after moving the corrupt file aside for troubleshooting.
+// It returns loadErr if no backup is valid.
+func (kvs *jsonFileStore) recover(loadErr error) (map[string]*json.RawMessage, error) {
+	log.Errorf("Failed to load file %s, restoring it from a backup: %v", kvs.fileName, loadErr)
+
+	for n := 1; n <= kvs.backupCount; n++ {
+		name := kvs.backupName(n)
+		buf, err := readBackup(name)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Errorf("Skipping backup %s: %v", name, err)
+			}
+			continue
+		}
+
+		data, err := decodeStore(buf)
+		if err != nil {
+			log.Errorf("Skipping backup %s: %v", name, err)
+			continue
+		}
+
+		if err := platform.ReplaceFile(kvs.fileName, kvs.fileName+CorruptExtension); err != nil {
+			log.Errorf("Failed to keep corrupt file %s: %v", kvs.fileName, err)
+		}
+
+		if err := writeFile(kvs.fileName, buf); err != nil {
+			return nil, errors.Wrapf(err, "failed to restore backup %s", name)
+		}
+
+		log.Printf("Restored file %s from backup %s", kvs.fileName, name)
+		kvs.recovered = true
+		return data, nil
+	}
+
+	return nil, errors.Wrap(loadErr, "no valid backup")
+}
+
+// removeBackups removes the backups of the file.
+func (kvs *jsonFileStore) removeBackups() {
+	for n := 1; n <= kvs.backupCount; n++ {
+		if err := os.Remove(kvs.backupName(n)); err != nil && !os.IsNotExist(err) {
+			log.Errorf("could not remove backup %s. Error: %v", kvs.backupName(n), err)
+		}
+	}
+	kvs.backupChecksum = ""
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/stretchr/testify/require"
+)
+
+func newBackupStore(t *testing.T, fileName string) *jsonFileStore {
+	t.Helper()
+	kvs, err := NewJsonFileStoreWithBackups(fileName, processlock.NewMockFileLock(false), 2)
+	require.NoError(t, err)
+	return kvs.(*jsonFileStore)
+}
+
+// Tests that each save is backed up and the backups are rotated.
+func TestBackupsAreRotated(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.json")
+	kvs := newBackupStore(t, fileName)
+
+	for i, value := range []testType1{{"a", 1}, {"a", 1}, {"b", 2}, {"c", 3}} {
+		require.NoError(t, kvs.Write(testKey1, &value), "write %d", i)
+	}
+
+	// The unchanged second write is not backed up, so the latest backups are c and b.
+	for n, want := range []testType1{{"c", 3}, {"b", 2}} {
+		buf, err := readBackup(kvs.backupName(n + 1))
+		require.NoError(t, err)
+		data, err := decodeStore(buf)
+		require.NoError(t, err)
+		var value testType1
+		require.NoError(t, json.Unmarshal(*data[testKey1], &value))
+		require.Equal(t, want, value)
+	}
+	_, err := os.Stat(kvs.backupName(3))
+	require.True(t, os.IsNotExist(err))
+
+	kvs.Remove()
+	for n := 1; n <= 2; n++ {
+		_, err := os.Stat(kvs.backupName(n))
+		require.True(t, os.IsNotExist(err))
+	}
+}
+
+// Tests that a corrupt or empty file is restored from the latest valid backup.
+func TestCorruptFileIsRestoredFromBackup(t *testing.T) {
+	for name, contents := range map[string]string{"corrupt": `{"key1":{"Fie`, "empty": ""} {
+		t.Run(name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "test.json")
+			kvs := newBackupStore(t, fileName)
+			require.NoError(t, kvs.Write(testKey1, &testType1{"old", 1}))
+			require.NoError(t, kvs.Write(testKey1, &testType1{"new", 2}))
+
+			// The latest backup fails its checksum, so the one before it is restored.
+			b, err := os.ReadFile(kvs.backupName(1))
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(kvs.backupName(1), append(b, ' '), 0o600))
+			require.NoError(t, os.WriteFile(fileName, []byte(contents), 0o600))
+
+			kvs = newBackupStore(t, fileName)
+			var value testType1
+			require.NoError(t, kvs.Read(testKey1, &value))
+			require.Equal(t, testType1{"old", 1}, value)
+			require.True(t, kvs.Recovered())
+
+			corrupt, err := os.ReadFile(fileName + CorruptExtension)
+			require.NoError(t, err)
+			require.Equal(t, contents, string(corrupt))
+
+			// The file itself was restored.
+			kvs = newBackupStore(t, fileName)
+			require.NoError(t, kvs.Read(testKey1, &value))
+			require.Equal(t, testType1{"old", 1}, value)
+			require.False(t, kvs.Recovered())
+		})
+	}
+}
+
+// Tests that a corrupt file without a valid backup fails to read.
+func TestCorruptFileWithoutBackup(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.json")
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"key1":`), 0o600))
+
+	var value testType1
+	kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.ErrorIs(t, kvs.Read(testKey1, &value), ErrStoreCorrupt)
+
+	err = newBackupStore(t, fileName).Read(testKey1, &value)
+	require.ErrorIs(t, err, ErrStoreCorrupt)
+	_, statErr := os.Stat(fileName)
+	require.NoError(t, statErr)
+
+	// Without backups an empty file is still only empty.
+	require.NoError(t, os.WriteFile(fileName, nil, 0o600))
+	kvs, err = NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.ErrorIs(t, kvs.Read(testKey1, &value), ErrStoreEmpty)
+}
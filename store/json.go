@@ -32,6 +32,11 @@ type jsonFileStore struct {
 	data        map[string]*json.RawMessage
 	inSync      bool
 	processLock processlock.Interface
+	// backupCount is the number of backups kept of the file, none if zero.
+	backupCount int
+	// backupChecksum is the checksum of the latest backup, which is not written again for an unchanged store.
+	backupChecksum string
+	recovered      bool
 	sync.Mutex
 }
 
@@ -64,40 +69,65 @@ func (kvs *jsonFileStore) Read(key string, value interface{}) error {
 
 	// Read contents from file if memory is not in sync.
 	if !kvs.inSync {
-		// Open and parse the file if it exists.
-		file, err := os.Open(kvs.fileName)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return ErrKeyNotFound
-			}
+		if err := kvs.load(); err != nil {
 			return err
 		}
-		defer file.Close()
+	}
 
-		b, err := io.ReadAll(file)
-		if err != nil {
-			return err
-		}
+	raw, ok := kvs.data[key]
+	if !ok {
+		return ErrKeyNotFound
+	}
 
-		if len(b) == 0 {
-			log.Printf("Unable to read file %s, was empty", kvs.fileName)
-			return ErrStoreEmpty
-		}
+	return json.Unmarshal(*raw, value)
+}
 
-		// Decode to raw JSON messages.
-		if err := json.Unmarshal(b, &kvs.data); err != nil {
-			return err
+// load reads the file into memory. A corrupt file is restored from the latest valid backup if the store has any.
+func (kvs *jsonFileStore) load() error {
+	// Open and parse the file if it exists.
+	file, err := os.Open(kvs.fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrKeyNotFound
 		}
+		return err
+	}
+	defer file.Close()
 
-		kvs.inSync = true
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return err
 	}
 
-	raw, ok := kvs.data[key]
-	if !ok {
-		return ErrKeyNotFound
+	kvs.recovered = false
+	data, err := decodeStore(b)
+	if errors.Is(err, ErrStoreEmpty) {
+		log.Printf("Unable to read file %s, was empty", kvs.fileName)
+	}
+	if err != nil && kvs.backupCount > 0 {
+		data, err = kvs.recover(err)
+	}
+	if err != nil {
+		return err
 	}
 
-	return json.Unmarshal(*raw, value)
+	kvs.data = data
+	kvs.inSync = true
+	return nil
+}
+
+// decodeStore decodes the contents of the file to raw JSON messages.
+func decodeStore(b []byte) (map[string]*json.RawMessage, error) {
+	if len(b) == 0 {
+		return nil, ErrStoreEmpty
+	}
+
+	data := make(map[string]*json.RawMessage)
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStoreCorrupt, err)
+	}
+
+	return data, nil
 }
 
 // Write saves the given key value pair to persistent store.
@@ -131,7 +161,23 @@ func (kvs *jsonFileStore) flush() error {
 		return err
 	}
 
-	dir, file := filepath.Split(kvs.fileName)
+	if err := writeFile(kvs.fileName, buf); err != nil {
+		return err
+	}
+
+	if kvs.backupCount > 0 {
+		// The file was saved, so a failed backup only loses a recovery point.
+		if err := kvs.backup(buf); err != nil {
+			log.Errorf("Failed to back up file %s: %v", kvs.fileName, err)
+		}
+	}
+
+	return nil
+}
+
+// writeFile atomically replaces the file with the contents.
+func writeFile(fileName string, buf []byte) (err error) {
+	dir, file := filepath.Split(fileName)
 	if dir == "" {
 		dir = "."
 	}
@@ -156,45 +202,47 @@ func (kvs *jsonFileStore) flush() error {
 		return fmt.Errorf("Temp file write failed with: %v", err)
 	}
 
+	// Make sure the contents are on disk before the rename makes them visible.
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("temp file sync failed with: %v", err)
+	}
+
 	if err = f.Close(); err != nil {
 		return fmt.Errorf("temp file close failed with: %v", err)
 	}
 
 	// atomic replace
-	if err = platform.ReplaceFile(tmpFileName, kvs.fileName); err != nil {
+	if err = platform.ReplaceFile(tmpFileName, fileName); err != nil {
 		return fmt.Errorf("rename temp file to state file failed:%v", err)
 	}
 
-	return nil
-}
+	// Persist the rename. Directories can not be synced on all platforms, so this is best effort.
+	if d, dirErr := os.Open(dir); dirErr == nil {
+		_ = d.Sync()
+		d.Close()
+	}
 
-func (kvs *jsonFileStore) lockUtil(status chan error) {
-	err := kvs.processLock.Lock()
-	status <- err
+	return nil
 }
 
-// Lock locks the store for exclusive access.
+// Lock locks the store for exclusive access and reads it again on the next Read. A lock left behind
+// by a process which exited is broken.
 func (kvs *jsonFileStore) Lock(timeout time.Duration) error {
 	kvs.Mutex.Lock()
 	defer kvs.Mutex.Unlock()
 
-	afterTime := time.After(timeout)
-	status := make(chan error)
-
 	log.Printf("Acquiring process lock")
-	go kvs.lockUtil(status)
-
-	var err error
-	select {
-	case <-afterTime:
-		return ErrTimeoutLockingStore
-	case err = <-status:
-	}
-
-	if err != nil {
+	if err := kvs.processLock.LockTimeout(timeout); err != nil {
+		if errors.Is(err, processlock.ErrLockTimeout) {
+			log.Printf("Failed to acquire process lock: %v", err)
+			return ErrTimeoutLockingStore
+		}
 		return errors.Wrap(err, "processLock acquire error")
 	}
 
+	// Other processes may have written the file since it was read.
+	kvs.inSync = false
+
 	log.Printf("Acquired process lock")
 	return nil
 }
@@ -232,5 +280,7 @@ func (kvs *jsonFileStore) Remove() {
 	if err := os.Remove(kvs.fileName); err != nil {
 		log.Errorf("could not remove file %s. Error: %v", kvs.fileName, err)
 	}
+	// The backups would bring the removed store back if the next file was corrupt.
+	kvs.removeBackups()
 	kvs.Mutex.Unlock()
 }
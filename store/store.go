@@ -5,20 +5,12 @@ package store
 
 import (
 	"fmt"
-	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
 )
 
 // KeyValueStore represents a persistent store of (key,value) pairs.
-type KeyValueStore interface {
-	Exists() bool
-	Read(key string, value interface{}) error
-	Write(key string, value interface{}) error
-	Flush() error
-	Lock(timeout time.Duration) error
-	Unlock() error
-	GetModificationTime() (time.Time, error)
-	Remove()
-}
+type KeyValueStore = platform.KeyValueStore
 
 var (
 	// Errors returned by KeyValueStore methods.
@@ -26,6 +18,7 @@ var (
 	ErrStoreLocked                    = fmt.Errorf("store is already locked")
 	ErrStoreNotLocked                 = fmt.Errorf("store is not locked")
 	ErrStoreEmpty                     = fmt.Errorf("store is empty")
+	ErrStoreCorrupt                   = fmt.Errorf("store is corrupt")
 	ErrTimeoutLockingStore            = fmt.Errorf("timed out locking store")
 	ErrNonBlockingLockIsAlreadyLocked = fmt.Errorf("attempted to perform non-blocking lock on an already locked store")
 )
@@ -27,13 +27,21 @@ func CreateAITelemetryHandle(aiConfig aitelemetry.AIConfig, disableAll, disableM
 		return fmt.Errorf("Telmetry disabled")
 	}
 
+	gDisableMetric = disableMetric
+	gDisableTrace = disableTrace
+
 	th, err = aitelemetry.NewAITelemetry("", aiMetadata, aiConfig)
+	return err
+}
+
+// CreateOTLPTelemetryHandle exports the telemetry to an OpenTelemetry collector, alongside AI if its handle was created.
+func CreateOTLPTelemetryHandle(appName, appVersion string, config OTLPConfig) error {
+	otlp, err := NewOTLPTelemetry(appName, appVersion, config)
 	if err != nil {
 		return err
 	}
 
-	gDisableMetric = disableMetric
-	gDisableTrace = disableTrace
+	th = NewTeeTelemetryHandle(th, otlp)
 	return nil
 }
 
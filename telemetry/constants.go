@@ -5,10 +5,15 @@ package telemetry
 const (
 
 	// Metric Names
-	CNIAddTimeMetricStr    = "CNIAddTimeMs"
-	CNIDelTimeMetricStr    = "CNIDelTimeMs"
-	CNIUpdateTimeMetricStr = "CNIUpdateTimeMs"
-	CNILockTimeoutStr      = "CNILockTimeoutError"
+	CNIAddTimeMetricStr      = "CNIAddTimeMs"
+	CNIDelTimeMetricStr      = "CNIDelTimeMs"
+	CNIUpdateTimeMetricStr   = "CNIUpdateTimeMs"
+	CNILockTimeoutStr        = "CNILockTimeoutError"
+	CNIEndpointDroppedStr    = "CNIEndpointDroppedPackets"
+	CNIPhaseTimeMetricStr    = "CNIPhaseTimeMs"
+	CNIPhaseSummaryMetricStr = "CNIPhaseSummaryMs"
+	CNIOperationMetricStr    = "CNIOperationTimeMs"
+	IPAMPoolUsageMetricStr   = "IPAMPoolUsagePercent"
 
 	// Dimension Names
 	ContextStr        = "Context"
@@ -20,6 +25,20 @@ const (
 	CNIModeStr        = "CNIMode"
 	CNINetworkModeStr = "CNINetworkMode"
 	OSTypeStr         = "OSType"
+	EndpointIDStr     = "EndpointID"
+	RxDroppedStr      = "RxDropped"
+	TxDroppedStr      = "TxDropped"
+	RxErrorsStr       = "RxErrors"
+	TxErrorsStr       = "TxErrors"
+	PhaseStr          = "Phase"
+	CountStr          = "Count"
+	MaxStr            = "Max"
+	SubnetStr         = "Subnet"
+	TotalStr          = "Total"
+	AllocatedStr      = "Allocated"
+	ReservedStr       = "Reserved"
+	NetOperationStr   = "NetOperation"
+	ResultStr         = "Result"
 
 	// Values
 	SucceededStr     = "Succeeded"
@@ -0,0 +1,135 @@
+// Copyright Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// OperationRecorder records the outcome of each operation on the links, addresses, routes and neighbors of the
+// endpoints during a CNI command. A nil recorder records nothing.
+type OperationRecorder struct {
+	mutex   sync.Mutex
+	results []operationResult
+}
+
+type operationResult struct {
+	operation string
+	result    string
+	duration  time.Duration
+}
+
+// NewOperationRecorder returns a recorder without any recorded operation.
+func NewOperationRecorder() *OperationRecorder {
+	return &OperationRecorder{}
+}
+
+// Observe records an operation which took the duration. The result is SucceededStr or the class of the error.
+func (r *OperationRecorder) Observe(operation string, duration time.Duration, result string) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.results = append(r.results, operationResult{operation: operation, result: result, duration: duration})
+}
+
+// Metrics returns a metric of each recorded operation of the CNI command, in the order they were recorded.
+func (r *OperationRecorder) Metrics(command, version string) []AIMetric {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	metrics := make([]AIMetric, 0, len(r.results))
+	for _, res := range r.results {
+		metrics = append(metrics, AIMetric{
+			Metric: aitelemetry.Metric{
+				Name:       CNIOperationMetricStr,
+				Value:      float64(res.duration.Microseconds()) / float64(time.Millisecond/time.Microsecond),
+				AppVersion: version,
+				CustomDimensions: map[string]string{
+					OperationTypeStr: command,
+					NetOperationStr:  res.operation,
+					ResultStr:        res.result,
+				},
+			},
+		})
+	}
+
+	return metrics
+}
+
+var (
+	operationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "cni",
+			Name:      "network_operations_total",
+			Help:      "Number of operations on the links, addresses, routes and neighbors of endpoints by result.",
+		},
+		[]string{"command", "operation", "result"},
+	)
+	operationLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "cni",
+			Name:      "network_operation_duration_seconds",
+			Help:      "Latency of the operations on the links, addresses, routes and neighbors of endpoints.",
+			//nolint:gomnd // 100us to ~3.3s
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+		},
+		[]string{"command", "operation", "result"},
+	)
+)
+
+// RegisterMetrics registers the operation metrics of the CNI commands with the registerer of the telemetry service.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{operationsTotal, operationLatency} {
+		if err := registerer.Register(c); err != nil {
+			return errors.Wrap(err, "failed to register operation metrics")
+		}
+	}
+
+	return nil
+}
+
+// observeOperationMetric adds an operation metric sent by a CNI command to the Prometheus metrics. It returns
+// false for other metrics.
+func observeOperationMetric(aiMetric AIMetric) bool {
+	if aiMetric.Metric.Name != CNIOperationMetricStr {
+		return false
+	}
+
+	labels := prometheus.Labels{
+		"command":   aiMetric.Metric.CustomDimensions[OperationTypeStr],
+		"operation": aiMetric.Metric.CustomDimensions[NetOperationStr],
+		"result":    aiMetric.Metric.CustomDimensions[ResultStr],
+	}
+	operationsTotal.With(labels).Inc()
+	operationLatency.With(labels).Observe(aiMetric.Metric.Value / float64(time.Second/time.Millisecond))
+
+	return true
+}
+
+// ServeMetrics serves the metrics of the registry on the address until the server fails.
+func ServeMetrics(address string, gatherer prometheus.Gatherer) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{ErrorHandling: promhttp.HTTPErrorOnError}))
+
+	//nolint:gosec // the address is local and the metrics are not sensitive
+	if err := http.ListenAndServe(address, mux); err != nil {
+		log.Logf("[Telemetry] Metrics server on %s stopped: %v", address, err)
+	}
+}
@@ -0,0 +1,63 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationRecorder(t *testing.T) {
+	var nilRecorder *OperationRecorder
+	nilRecorder.Observe("AddRoute", time.Millisecond, SucceededStr)
+	require.Empty(t, nilRecorder.Metrics("ADD", "v1"))
+
+	recorder := NewOperationRecorder()
+	recorder.Observe("CreateEndpoint", 1500*time.Microsecond, SucceededStr)
+	recorder.Observe("AddRoute", 2*time.Millisecond, "AlreadyExists")
+
+	metrics := recorder.Metrics("ADD", "v1")
+	require.Len(t, metrics, 2)
+	require.Equal(t, CNIOperationMetricStr, metrics[0].Metric.Name)
+	require.InDelta(t, 1.5, metrics[0].Metric.Value, 0.001)
+	require.Equal(t, map[string]string{
+		OperationTypeStr: "ADD",
+		NetOperationStr:  "AddRoute",
+		ResultStr:        "AlreadyExists",
+	}, metrics[1].Metric.CustomDimensions)
+}
+
+func TestObserveOperationMetric(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetrics(registry))
+	require.Error(t, RegisterMetrics(registry), "registering twice must fail")
+
+	recorder := NewOperationRecorder()
+	recorder.Observe("AssignIPToInterface", 3*time.Millisecond, SucceededStr)
+	recorder.Observe("AssignIPToInterface", 5*time.Millisecond, "NotPermitted")
+	for _, metric := range recorder.Metrics("ADD", "v1") {
+		require.True(t, observeOperationMetric(metric))
+	}
+	require.False(t, observeOperationMetric(AIMetric{}), "other metrics are left to AI")
+
+	counter := &dto.Metric{}
+	require.NoError(t, operationsTotal.WithLabelValues("ADD", "AssignIPToInterface", "NotPermitted").Write(counter))
+	require.InDelta(t, 1, counter.GetCounter().GetValue(), 0)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	found := false
+	for _, family := range families {
+		if family.GetName() != "cni_network_operation_duration_seconds" {
+			continue
+		}
+		found = true
+		require.Len(t, family.GetMetric(), 2, "one histogram per result")
+		for _, metric := range family.GetMetric() {
+			require.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+		}
+	}
+	require.True(t, found)
+}
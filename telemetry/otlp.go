@@ -0,0 +1,602 @@
+// Copyright 2022 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	otlpLogsPath      = "/v1/logs"
+	otlpMetricsPath   = "/v1/metrics"
+	otlpLogsSignal    = "logs"
+	otlpMetricsSignal = "metrics"
+	otlpSpoolSuffix   = ".json"
+
+	defaultOTLPBatchSize           = 512
+	defaultOTLPBatchIntervalInSecs = 10
+	defaultOTLPTimeoutInSecs       = 10
+	defaultOTLPSpoolMaxSizeInBytes = 10 * 1024 * 1024
+
+	// SeverityNumber of the OTLP log data model.
+	otlpSeverityInfo = 9
+)
+
+var errOTLPEndpoint = errors.New("invalid OTLP endpoint")
+
+// OTLPConfig - configuration of the exporter sending the telemetry to an OpenTelemetry collector over OTLP/HTTP.
+type OTLPConfig struct {
+	// Endpoint is the base URL of the collector, e.g. http://localhost:4318. The exporter is disabled when empty.
+	Endpoint string
+	// Headers are added to every export request, e.g. to authenticate to the collector.
+	Headers map[string]string
+	// SamplingRatio is the fraction of the logs and events that are exported. Metrics are always exported.
+	// Values outside of (0, 1] export everything.
+	SamplingRatio float64
+	// BatchSize is the number of records queued before they are exported.
+	BatchSize int
+	// BatchIntervalInSecs is the maximum delay before the queued records are exported.
+	BatchIntervalInSecs int
+	// TimeoutInSecs is the timeout of an export request.
+	TimeoutInSecs int
+	// SpoolDirectory keeps the batches which couldn't be exported until the collector is reachable again.
+	// Batches are dropped when empty.
+	SpoolDirectory string
+	// SpoolMaxSizeInBytes caps the size of the spool, the oldest batches are dropped first.
+	SpoolMaxSizeInBytes int64
+}
+
+// Enabled returns true if a collector is configured.
+func (c *OTLPConfig) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+func (c *OTLPConfig) setDefaults() {
+	if c.SamplingRatio <= 0 || c.SamplingRatio > 1 {
+		c.SamplingRatio = 1
+	}
+
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultOTLPBatchSize
+	}
+
+	if c.BatchIntervalInSecs <= 0 {
+		c.BatchIntervalInSecs = defaultOTLPBatchIntervalInSecs
+	}
+
+	if c.TimeoutInSecs <= 0 {
+		c.TimeoutInSecs = defaultOTLPTimeoutInSecs
+	}
+
+	if c.SpoolMaxSizeInBytes <= 0 {
+		c.SpoolMaxSizeInBytes = defaultOTLPSpoolMaxSizeInBytes
+	}
+}
+
+// JSON encoding of the OTLP protobuf messages, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber,omitempty"`
+	SeverityText   string         `json:"severityText,omitempty"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpNumberDataPoint struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	AsDouble     float64        `json:"asDouble"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name  string    `json:"name"`
+	Gauge otlpGauge `json:"gauge"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+// otlpTelemetryHandle batches the telemetry and exports it to an OpenTelemetry collector.
+type otlpTelemetryHandle struct {
+	config   OTLPConfig
+	endpoint string
+	client   *http.Client
+	resource otlpResource
+	scope    otlpScope
+	sample   func() bool
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	logs    []otlpLogRecord
+	metrics []otlpMetric
+
+	// serializes the exports and the spool.
+	exportMutex sync.Mutex
+
+	flush     chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewOTLPTelemetry creates a telemetry handle exporting the logs, events and metrics of the app
+// to the OpenTelemetry collector of the config.
+func NewOTLPTelemetry(appName, appVersion string, config OTLPConfig) (aitelemetry.TelemetryHandle, error) {
+	h, err := newOTLPTelemetryHandle(appName, appVersion, config)
+	if err != nil {
+		return nil, err
+	}
+
+	go h.run()
+	return h, nil
+}
+
+func newOTLPTelemetryHandle(appName, appVersion string, config OTLPConfig) (*otlpTelemetryHandle, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Wrapf(errOTLPEndpoint, "%q", config.Endpoint)
+	}
+
+	config.setDefaults()
+
+	if config.SpoolDirectory != "" {
+		if err := os.MkdirAll(config.SpoolDirectory, 0o755); err != nil { //nolint:gomnd // same permissions as the other ACN directories
+			return nil, errors.Wrap(err, "failed to create OTLP spool directory")
+		}
+	}
+
+	hostName, _ := os.Hostname()
+	ratio := config.SamplingRatio
+
+	return &otlpTelemetryHandle{
+		config:   config,
+		endpoint: strings.TrimSuffix(config.Endpoint, "/"),
+		client:   &http.Client{Timeout: time.Duration(config.TimeoutInSecs) * time.Second},
+		resource: otlpResource{
+			Attributes: []otlpKeyValue{
+				otlpAttribute("service.name", appName),
+				otlpAttribute("service.version", appVersion),
+				otlpAttribute("host.name", hostName),
+			},
+		},
+		scope: otlpScope{Name: appName, Version: appVersion},
+		sample: func() bool {
+			return ratio >= 1 || rand.Float64() < ratio //nolint:gosec // sampling doesn't need a secure source
+		},
+		now:   time.Now,
+		flush: make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+func otlpAttribute(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+// otlpAttributes converts the dimensions to attributes, sorted by key so the payloads are stable.
+func otlpAttributes(dimensions map[string]string, extra ...otlpKeyValue) []otlpKeyValue {
+	attributes := make([]otlpKeyValue, 0, len(dimensions)+len(extra))
+	attributes = append(attributes, extra...)
+
+	keys := make([]string, 0, len(dimensions))
+	for k := range dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		attributes = append(attributes, otlpAttribute(k, dimensions[k]))
+	}
+
+	return attributes
+}
+
+func (h *otlpTelemetryHandle) timestamp() string {
+	return strconv.FormatInt(h.now().UnixNano(), 10)
+}
+
+// TrackLog exports the report as a log record.
+func (h *otlpTelemetryHandle) TrackLog(report aitelemetry.Report) {
+	if !h.sample() {
+		return
+	}
+
+	var extra []otlpKeyValue
+	if report.Context != "" {
+		extra = append(extra, otlpAttribute("Context", report.Context))
+	}
+
+	h.queueLog(otlpLogRecord{
+		TimeUnixNano:   h.timestamp(),
+		SeverityNumber: otlpSeverityInfo,
+		SeverityText:   "INFO",
+		Body:           otlpAnyValue{StringValue: report.Message},
+		Attributes:     otlpAttributes(report.CustomDimensions, extra...),
+	})
+}
+
+// TrackEvent exports the event as a log record named after the event.
+func (h *otlpTelemetryHandle) TrackEvent(event aitelemetry.Event) {
+	if !h.sample() {
+		return
+	}
+
+	extra := []otlpKeyValue{otlpAttribute("event.name", event.EventName)}
+	if event.ResourceID != "" {
+		extra = append(extra, otlpAttribute("ResourceID", event.ResourceID))
+	}
+
+	h.queueLog(otlpLogRecord{
+		TimeUnixNano:   h.timestamp(),
+		SeverityNumber: otlpSeverityInfo,
+		SeverityText:   "INFO",
+		Body:           otlpAnyValue{StringValue: event.EventName},
+		Attributes:     otlpAttributes(event.Properties, extra...),
+	})
+}
+
+// TrackMetric exports the metric as a gauge.
+func (h *otlpTelemetryHandle) TrackMetric(metric aitelemetry.Metric) {
+	h.mutex.Lock()
+	h.metrics = append(h.metrics, otlpMetric{
+		Name: metric.Name,
+		Gauge: otlpGauge{
+			DataPoints: []otlpNumberDataPoint{
+				{
+					TimeUnixNano: h.timestamp(),
+					AsDouble:     metric.Value,
+					Attributes:   otlpAttributes(metric.CustomDimensions),
+				},
+			},
+		},
+	})
+	full := len(h.logs)+len(h.metrics) >= h.config.BatchSize
+	h.mutex.Unlock()
+
+	if full {
+		h.requestFlush()
+	}
+}
+
+func (h *otlpTelemetryHandle) queueLog(record otlpLogRecord) {
+	h.mutex.Lock()
+	h.logs = append(h.logs, record)
+	full := len(h.logs)+len(h.metrics) >= h.config.BatchSize
+	h.mutex.Unlock()
+
+	if full {
+		h.requestFlush()
+	}
+}
+
+func (h *otlpTelemetryHandle) requestFlush() {
+	select {
+	case h.flush <- struct{}{}:
+	default:
+	}
+}
+
+func (h *otlpTelemetryHandle) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(time.Duration(h.config.BatchIntervalInSecs) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.Flush()
+		case <-h.flush:
+			h.Flush()
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// Flush exports the queued records. Batches which can't be exported are spooled.
+func (h *otlpTelemetryHandle) Flush() {
+	h.mutex.Lock()
+	logs, metrics := h.logs, h.metrics
+	h.logs, h.metrics = nil, nil
+	h.mutex.Unlock()
+
+	h.exportMutex.Lock()
+	defer h.exportMutex.Unlock()
+
+	if len(logs) > 0 {
+		h.exportOrSpool(otlpLogsSignal, otlpLogsRequest{
+			ResourceLogs: []otlpResourceLogs{
+				{
+					Resource:  h.resource,
+					ScopeLogs: []otlpScopeLogs{{Scope: h.scope, LogRecords: logs}},
+				},
+			},
+		})
+	}
+
+	if len(metrics) > 0 {
+		h.exportOrSpool(otlpMetricsSignal, otlpMetricsRequest{
+			ResourceMetrics: []otlpResourceMetrics{
+				{
+					Resource:     h.resource,
+					ScopeMetrics: []otlpScopeMetrics{{Scope: h.scope, Metrics: metrics}},
+				},
+			},
+		})
+	}
+}
+
+// Close stops the exporter and exports the queued records, waiting at most timeout seconds.
+func (h *otlpTelemetryHandle) Close(timeout int) {
+	h.closeOnce.Do(func() {
+		close(h.stop)
+	})
+
+	flushed := make(chan struct{})
+	go func() {
+		<-h.done
+		h.Flush()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Duration(timeout) * time.Second):
+		log.Printf("[Telemetry] Timed out flushing the OTLP exporter")
+	}
+}
+
+func (h *otlpTelemetryHandle) exportOrSpool(signal string, request interface{}) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		log.Printf("[Telemetry] Failed to marshal OTLP %s: %v", signal, err)
+		return
+	}
+
+	if err := h.export(signal, payload); err != nil {
+		log.Printf("[Telemetry] Failed to export OTLP %s: %v", signal, err)
+		h.spool(signal, payload)
+		return
+	}
+
+	// the collector is reachable, send what was spooled while it wasn't.
+	h.replaySpool()
+}
+
+func (h *otlpTelemetryHandle) export(signal string, payload []byte) error {
+	path := otlpLogsPath
+	if signal == otlpMetricsSignal {
+		path = otlpMetricsPath
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.config.TimeoutInSecs)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("Content-Type", ContentType)
+	for k, v := range h.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to post to collector")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("collector replied with http status code %d", resp.StatusCode) //nolint:goerr113 // status is only logged
+	}
+
+	return nil
+}
+
+// spool writes the payload to the spool directory, named so the oldest batches sort first.
+func (h *otlpTelemetryHandle) spool(signal string, payload []byte) {
+	if h.config.SpoolDirectory == "" {
+		return
+	}
+
+	name := fmt.Sprintf("%020d-%s%s", h.now().UnixNano(), signal, otlpSpoolSuffix)
+	if err := os.WriteFile(filepath.Join(h.config.SpoolDirectory, name), payload, 0o600); err != nil { //nolint:gomnd // owner only
+		log.Printf("[Telemetry] Failed to spool OTLP %s: %v", signal, err)
+		return
+	}
+
+	h.trimSpool()
+}
+
+// spooled returns the spooled batches, oldest first.
+func (h *otlpTelemetryHandle) spooled() []os.DirEntry {
+	entries, err := os.ReadDir(h.config.SpoolDirectory)
+	if err != nil {
+		log.Printf("[Telemetry] Failed to read OTLP spool: %v", err)
+		return nil
+	}
+
+	spooled := entries[:0]
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), otlpSpoolSuffix) {
+			spooled = append(spooled, e)
+		}
+	}
+
+	return spooled
+}
+
+// trimSpool drops the oldest batches until the spool fits its maximum size.
+func (h *otlpTelemetryHandle) trimSpool() {
+	spooled := h.spooled()
+
+	var size int64
+	sizes := make([]int64, len(spooled))
+	for i, e := range spooled {
+		if info, err := e.Info(); err == nil {
+			sizes[i] = info.Size()
+			size += sizes[i]
+		}
+	}
+
+	for i := 0; i < len(spooled) && size > h.config.SpoolMaxSizeInBytes; i++ {
+		if err := os.Remove(filepath.Join(h.config.SpoolDirectory, spooled[i].Name())); err != nil {
+			log.Printf("[Telemetry] Failed to remove spooled OTLP batch %s: %v", spooled[i].Name(), err)
+			continue
+		}
+		size -= sizes[i]
+	}
+}
+
+// replaySpool exports the spooled batches oldest first, stopping at the first failure.
+func (h *otlpTelemetryHandle) replaySpool() {
+	if h.config.SpoolDirectory == "" {
+		return
+	}
+
+	for _, e := range h.spooled() {
+		file := filepath.Join(h.config.SpoolDirectory, e.Name())
+		payload, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("[Telemetry] Failed to read spooled OTLP batch %s: %v", e.Name(), err)
+			continue
+		}
+
+		signal := otlpLogsSignal
+		if strings.HasSuffix(e.Name(), "-"+otlpMetricsSignal+otlpSpoolSuffix) {
+			signal = otlpMetricsSignal
+		}
+
+		if err := h.export(signal, payload); err != nil {
+			log.Printf("[Telemetry] Failed to export spooled OTLP batch %s: %v", e.Name(), err)
+			return
+		}
+
+		if err := os.Remove(file); err != nil {
+			log.Printf("[Telemetry] Failed to remove spooled OTLP batch %s: %v", e.Name(), err)
+		}
+	}
+}
+
+// teeTelemetryHandle sends the telemetry to several handles.
+type teeTelemetryHandle []aitelemetry.TelemetryHandle
+
+// NewTeeTelemetryHandle returns a handle sending the telemetry to all the non nil handles.
+func NewTeeTelemetryHandle(handles ...aitelemetry.TelemetryHandle) aitelemetry.TelemetryHandle {
+	var tee teeTelemetryHandle
+	for _, h := range handles {
+		if h != nil {
+			tee = append(tee, h)
+		}
+	}
+
+	switch len(tee) {
+	case 0:
+		return nil
+	case 1:
+		return tee[0]
+	default:
+		return tee
+	}
+}
+
+func (t teeTelemetryHandle) TrackLog(report aitelemetry.Report) {
+	for _, h := range t {
+		h.TrackLog(report)
+	}
+}
+
+func (t teeTelemetryHandle) TrackMetric(metric aitelemetry.Metric) {
+	for _, h := range t {
+		h.TrackMetric(metric)
+	}
+}
+
+func (t teeTelemetryHandle) TrackEvent(event aitelemetry.Event) {
+	for _, h := range t {
+		h.TrackEvent(event)
+	}
+}
+
+func (t teeTelemetryHandle) Close(timeout int) {
+	for _, h := range t {
+		h.Close(timeout)
+	}
+}
+
+func (t teeTelemetryHandle) Flush() {
+	for _, h := range t {
+		h.Flush()
+	}
+}
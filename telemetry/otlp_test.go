@@ -0,0 +1,199 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/stretchr/testify/require"
+)
+
+type otlpCollector struct {
+	sync.Mutex
+	available bool
+	headers   []http.Header
+	logs      []otlpLogsRequest
+	metrics   []otlpMetricsRequest
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.available {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	c.headers = append(c.headers, r.Header)
+	switch r.URL.Path {
+	case otlpLogsPath:
+		var req otlpLogsRequest
+		_ = json.Unmarshal(body, &req)
+		c.logs = append(c.logs, req)
+	case otlpMetricsPath:
+		var req otlpMetricsRequest
+		_ = json.Unmarshal(body, &req)
+		c.metrics = append(c.metrics, req)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestOTLPHandle(t *testing.T, config OTLPConfig) (*otlpTelemetryHandle, *otlpCollector) {
+	collector := &otlpCollector{available: true}
+	server := httptest.NewServer(collector)
+	t.Cleanup(server.Close)
+
+	config.Endpoint = server.URL + "/"
+	h, err := newOTLPTelemetryHandle("azure-cns", "v1.4.35", config)
+	require.NoError(t, err)
+
+	return h, collector
+}
+
+func TestOTLPInvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"localhost:4318", "unix:///var/run/otel.sock", "http://"} {
+		_, err := NewOTLPTelemetry("azure-cns", "v1.4.35", OTLPConfig{Endpoint: endpoint})
+		require.ErrorIs(t, err, errOTLPEndpoint, endpoint)
+	}
+}
+
+func TestOTLPExport(t *testing.T) {
+	h, collector := newTestOTLPHandle(t, OTLPConfig{Headers: map[string]string{"Authorization": "Bearer token"}})
+
+	h.TrackLog(aitelemetry.Report{Message: "ADD succeeded", Context: "pod", CustomDimensions: map[string]string{OperationTypeStr: "ADD"}})
+	h.TrackEvent(aitelemetry.Event{EventName: "Snapshot", Properties: map[string]string{"IPs": "10"}})
+	h.TrackMetric(aitelemetry.Metric{Name: CNIAddTimeMetricStr, Value: 42, CustomDimensions: map[string]string{StatusStr: SucceededStr}})
+	h.Flush()
+
+	require.Len(t, collector.logs, 1)
+	require.Len(t, collector.metrics, 1)
+	for _, header := range collector.headers {
+		require.Equal(t, "Bearer token", header.Get("Authorization"))
+		require.Equal(t, ContentType, header.Get("Content-Type"))
+	}
+
+	resourceLogs := collector.logs[0].ResourceLogs[0]
+	require.Contains(t, resourceLogs.Resource.Attributes, otlpAttribute("service.name", "azure-cns"))
+	require.Contains(t, resourceLogs.Resource.Attributes, otlpAttribute("service.version", "v1.4.35"))
+
+	records := resourceLogs.ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+	require.Equal(t, "ADD succeeded", records[0].Body.StringValue)
+	require.Equal(t, []otlpKeyValue{otlpAttribute("Context", "pod"), otlpAttribute(OperationTypeStr, "ADD")}, records[0].Attributes)
+	require.Equal(t, "Snapshot", records[1].Body.StringValue)
+	require.Equal(t, []otlpKeyValue{otlpAttribute("event.name", "Snapshot"), otlpAttribute("IPs", "10")}, records[1].Attributes)
+
+	metrics := collector.metrics[0].ResourceMetrics[0].ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 1)
+	require.Equal(t, CNIAddTimeMetricStr, metrics[0].Name)
+	require.Equal(t, float64(42), metrics[0].Gauge.DataPoints[0].AsDouble)
+	require.Equal(t, []otlpKeyValue{otlpAttribute(StatusStr, SucceededStr)}, metrics[0].Gauge.DataPoints[0].Attributes)
+
+	// nothing is queued anymore.
+	h.Flush()
+	require.Len(t, collector.logs, 1)
+	require.Len(t, collector.metrics, 1)
+}
+
+func TestOTLPSampling(t *testing.T) {
+	h, collector := newTestOTLPHandle(t, OTLPConfig{})
+	h.sample = func() bool { return false }
+
+	h.TrackLog(aitelemetry.Report{Message: "ADD succeeded"})
+	h.TrackEvent(aitelemetry.Event{EventName: "Snapshot"})
+	h.TrackMetric(aitelemetry.Metric{Name: CNIAddTimeMetricStr, Value: 42})
+	h.Flush()
+
+	require.Empty(t, collector.logs, "logs and events are sampled")
+	require.Len(t, collector.metrics, 1, "metrics are not sampled")
+}
+
+func TestOTLPBatchSize(t *testing.T) {
+	h, _ := newTestOTLPHandle(t, OTLPConfig{BatchSize: 2})
+
+	h.TrackMetric(aitelemetry.Metric{Name: CNIAddTimeMetricStr})
+	require.Empty(t, h.flush)
+	h.TrackLog(aitelemetry.Report{Message: "ADD succeeded"})
+	require.Len(t, h.flush, 1, "a full batch requests a flush")
+}
+
+func TestOTLPSpool(t *testing.T) {
+	dir := t.TempDir()
+	h, collector := newTestOTLPHandle(t, OTLPConfig{SpoolDirectory: dir})
+	collector.available = false
+
+	h.TrackLog(aitelemetry.Report{Message: "first"})
+	h.TrackMetric(aitelemetry.Metric{Name: CNIAddTimeMetricStr, Value: 1})
+	h.Flush()
+	h.TrackLog(aitelemetry.Report{Message: "second"})
+	h.Flush()
+
+	spooled, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, spooled, 3)
+
+	// the spool is replayed oldest first once the collector is reachable.
+	collector.available = true
+	h.TrackLog(aitelemetry.Report{Message: "third"})
+	h.Flush()
+
+	require.Len(t, collector.logs, 3)
+	require.Len(t, collector.metrics, 1)
+	for i, msg := range []string{"third", "first", "second"} {
+		require.Equal(t, msg, collector.logs[i].ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body.StringValue)
+	}
+
+	spooled, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, spooled)
+}
+
+func TestOTLPSpoolMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	h, collector := newTestOTLPHandle(t, OTLPConfig{SpoolDirectory: dir, SpoolMaxSizeInBytes: 1})
+	collector.available = false
+
+	h.TrackLog(aitelemetry.Report{Message: "dropped"})
+	h.Flush()
+
+	spooled, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, spooled, "batches above the max size are dropped")
+}
+
+type countingTelemetryHandle struct {
+	logs, metrics, events, flushes, closes int
+}
+
+func (c *countingTelemetryHandle) TrackLog(aitelemetry.Report)    { c.logs++ }
+func (c *countingTelemetryHandle) TrackMetric(aitelemetry.Metric) { c.metrics++ }
+func (c *countingTelemetryHandle) TrackEvent(aitelemetry.Event)   { c.events++ }
+func (c *countingTelemetryHandle) Close(int)                      { c.closes++ }
+func (c *countingTelemetryHandle) Flush()                         { c.flushes++ }
+
+func TestTeeTelemetryHandle(t *testing.T) {
+	require.Nil(t, NewTeeTelemetryHandle(nil, nil))
+
+	a := &countingTelemetryHandle{}
+	require.Same(t, a, NewTeeTelemetryHandle(nil, a))
+
+	b := &countingTelemetryHandle{}
+	tee := NewTeeTelemetryHandle(a, nil, b)
+	tee.TrackLog(aitelemetry.Report{})
+	tee.TrackMetric(aitelemetry.Metric{})
+	tee.TrackEvent(aitelemetry.Event{})
+	tee.Flush()
+	tee.Close(0)
+
+	for _, h := range []*countingTelemetryHandle{a, b} {
+		require.Equal(t, countingTelemetryHandle{logs: 1, metrics: 1, events: 1, flushes: 1, closes: 1}, *h)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+)
+
+// Phases of a CNI command reported in the latency breakdown.
+const (
+	PhaseIPAM           = "IPAM"
+	PhaseNetns          = "Netns"
+	PhaseEndpointClient = "EndpointClient"
+	PhaseIptables       = "Iptables"
+	PhaseStoreSave      = "StoreSave"
+)
+
+// phaseSummaryInterval is how often the telemetry service reports the summary of the phase latencies.
+const phaseSummaryInterval = 5 * time.Minute
+
+// PhaseTimer accumulates the time spent in each phase of a CNI command. A nil timer records nothing, so code
+// shared with callers that don't report a breakdown can time its phases unconditionally.
+type PhaseTimer struct {
+	mutex     sync.Mutex
+	durations map[string]time.Duration
+}
+
+// NewPhaseTimer returns a timer without any recorded phase.
+func NewPhaseTimer() *PhaseTimer {
+	return &PhaseTimer{durations: make(map[string]time.Duration)}
+}
+
+// Start starts timing a phase and returns the function which stops it. Phases entered several times in a
+// command, such as saving the state, are added up.
+func (t *PhaseTimer) Start(phase string) func() {
+	if t == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		t.mutex.Lock()
+		t.durations[phase] += time.Since(start)
+		t.mutex.Unlock()
+	}
+}
+
+// Durations returns the time spent in each recorded phase.
+func (t *PhaseTimer) Durations() map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	if t == nil {
+		return durations
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for phase, d := range t.durations {
+		durations[phase] = d
+	}
+
+	return durations
+}
+
+// Metrics returns a metric of the time spent in each recorded phase of the operation, in a stable order.
+func (t *PhaseTimer) Metrics(operation, version string) []AIMetric {
+	durations := t.Durations()
+
+	phases := make([]string, 0, len(durations))
+	for phase := range durations {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+
+	metrics := make([]AIMetric, 0, len(phases))
+	for _, phase := range phases {
+		metrics = append(metrics, AIMetric{
+			Metric: aitelemetry.Metric{
+				Name:       CNIPhaseTimeMetricStr,
+				Value:      float64(durations[phase].Milliseconds()),
+				AppVersion: version,
+				CustomDimensions: map[string]string{
+					OperationTypeStr: operation,
+					PhaseStr:         phase,
+				},
+			},
+		})
+	}
+
+	return metrics
+}
+
+type phaseKey struct {
+	version   string
+	operation string
+	phase     string
+}
+
+type phaseStats struct {
+	count int
+	total float64
+	max   float64
+}
+
+// phaseSummary aggregates the phase metrics sent by the CNI commands between two summary reports, so that the
+// phase which regressed can be found without going through the metrics of every command.
+type phaseSummary struct {
+	stats map[phaseKey]*phaseStats
+}
+
+func newPhaseSummary() *phaseSummary {
+	return &phaseSummary{stats: make(map[phaseKey]*phaseStats)}
+}
+
+// add records a phase metric. Other metrics are ignored.
+func (s *phaseSummary) add(aiMetric AIMetric) {
+	if aiMetric.Metric.Name != CNIPhaseTimeMetricStr {
+		return
+	}
+
+	key := phaseKey{
+		version:   aiMetric.Metric.AppVersion,
+		operation: aiMetric.Metric.CustomDimensions[OperationTypeStr],
+		phase:     aiMetric.Metric.CustomDimensions[PhaseStr],
+	}
+
+	stats, ok := s.stats[key]
+	if !ok {
+		stats = &phaseStats{}
+		s.stats[key] = stats
+	}
+
+	stats.count++
+	stats.total += aiMetric.Metric.Value
+	if aiMetric.Metric.Value > stats.max {
+		stats.max = aiMetric.Metric.Value
+	}
+}
+
+// flush returns the average time of each phase since the last flush, with the number of commands and the
+// maximum time as dimensions, and resets the summary.
+func (s *phaseSummary) flush() []AIMetric {
+	keys := make([]phaseKey, 0, len(s.stats))
+	for key := range s.stats {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		if keys[i].phase != keys[j].phase {
+			return keys[i].phase < keys[j].phase
+		}
+		return keys[i].version < keys[j].version
+	})
+
+	metrics := make([]AIMetric, 0, len(keys))
+	for _, key := range keys {
+		stats := s.stats[key]
+		metrics = append(metrics, AIMetric{
+			Metric: aitelemetry.Metric{
+				Name:       CNIPhaseSummaryMetricStr,
+				Value:      stats.total / float64(stats.count),
+				AppVersion: key.version,
+				CustomDimensions: map[string]string{
+					OperationTypeStr: key.operation,
+					PhaseStr:         key.phase,
+					CountStr:         strconv.Itoa(stats.count),
+					MaxStr:           strconv.FormatFloat(stats.max, 'f', -1, 64),
+				},
+			},
+		})
+	}
+
+	s.stats = make(map[phaseKey]*phaseStats)
+
+	return metrics
+}
@@ -0,0 +1,71 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhaseTimer(t *testing.T) {
+	var nilTimer *PhaseTimer
+	nilTimer.Start(PhaseIPAM)()
+	require.Empty(t, nilTimer.Durations())
+	require.Empty(t, nilTimer.Metrics("ADD", "v1"))
+
+	timer := NewPhaseTimer()
+	stop := timer.Start(PhaseStoreSave)
+	time.Sleep(2 * time.Millisecond)
+	stop()
+	stop = timer.Start(PhaseStoreSave)
+	time.Sleep(2 * time.Millisecond)
+	stop()
+	timer.Start(PhaseIPAM)()
+
+	durations := timer.Durations()
+	require.GreaterOrEqual(t, durations[PhaseStoreSave], 4*time.Millisecond, "the time of repeated phases is added up")
+	require.Contains(t, durations, PhaseIPAM)
+
+	metrics := timer.Metrics("ADD", "v1")
+	require.Len(t, metrics, 2)
+	require.Equal(t, PhaseIPAM, metrics[0].Metric.CustomDimensions[PhaseStr])
+	require.Equal(t, PhaseStoreSave, metrics[1].Metric.CustomDimensions[PhaseStr])
+	for _, metric := range metrics {
+		require.Equal(t, CNIPhaseTimeMetricStr, metric.Metric.Name)
+		require.Equal(t, "ADD", metric.Metric.CustomDimensions[OperationTypeStr])
+		require.Equal(t, "v1", metric.Metric.AppVersion)
+	}
+}
+
+func TestPhaseSummary(t *testing.T) {
+	summary := newPhaseSummary()
+
+	phaseMetric := func(operation, phase string, value float64) AIMetric {
+		timer := NewPhaseTimer()
+		timer.Start(phase)()
+		metric := timer.Metrics(operation, "v1")[0]
+		metric.Metric.Value = value
+		return metric
+	}
+
+	summary.add(phaseMetric("ADD", PhaseIPAM, 10))
+	summary.add(phaseMetric("ADD", PhaseIPAM, 30))
+	summary.add(phaseMetric("DEL", PhaseIPAM, 5))
+	summary.add(phaseMetric("ADD", PhaseIptables, 100))
+	summary.add(AIMetric{})
+
+	metrics := summary.flush()
+	require.Len(t, metrics, 3)
+
+	require.Equal(t, CNIPhaseSummaryMetricStr, metrics[0].Metric.Name)
+	require.Equal(t, "ADD", metrics[0].Metric.CustomDimensions[OperationTypeStr])
+	require.Equal(t, PhaseIPAM, metrics[0].Metric.CustomDimensions[PhaseStr])
+	require.Equal(t, float64(20), metrics[0].Metric.Value)
+	require.Equal(t, "2", metrics[0].Metric.CustomDimensions[CountStr])
+	require.Equal(t, "30", metrics[0].Metric.CustomDimensions[MaxStr])
+
+	require.Equal(t, PhaseIptables, metrics[1].Metric.CustomDimensions[PhaseStr])
+	require.Equal(t, "DEL", metrics[2].Metric.CustomDimensions[OperationTypeStr])
+
+	require.Empty(t, summary.flush(), "the summary is reset after it is reported")
+}
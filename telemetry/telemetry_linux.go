@@ -5,7 +5,6 @@ package telemetry
 
 import (
 	"fmt"
-	"os/exec"
 	"runtime"
 	"strings"
 	"syscall"
@@ -96,15 +95,15 @@ func (report *CNIReport) GetOSDetails() {
 		return
 	}
 
-	out, err := exec.Command("uname", "-r").Output()
+	p := platform.NewExecClient()
+	out, err := p.ExecuteCommand("uname -r")
 	if err != nil {
 		report.OSDetails = OSInfo{OSType: runtime.GOOS}
 		report.OSDetails.ErrorMessage = "uname -r failed with " + err.Error()
 		return
 	}
 
-	kernelVersion := string(out)
-	kernelVersion = strings.TrimSuffix(kernelVersion, "\n")
+	kernelVersion := strings.TrimSuffix(out, "\n")
 
 	report.OSDetails = OSInfo{
 		OSType:         runtime.GOOS,
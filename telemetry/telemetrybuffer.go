@@ -34,6 +34,9 @@ type TelemetryConfig struct {
 	BatchSizeInBytes              int
 	GetEnvRetryCount              int
 	GetEnvRetryWaitTimeInSecs     int
+	OTLP                          OTLPConfig
+	// MetricsAddress is the address the Prometheus metrics are served on, they aren't served if it is empty.
+	MetricsAddress string
 }
 
 // FdName - file descriptor name
@@ -173,12 +176,28 @@ func (tb *TelemetryBuffer) Connect() error {
 func (tb *TelemetryBuffer) PushData(ctx context.Context) {
 	defer tb.Close()
 
+	summary := newPhaseSummary()
+	ticker := time.NewTicker(phaseSummaryInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case report := <-tb.data:
+			// The operation metrics are only exported to Prometheus, a command sends too many of them for AI.
+			if aiMetric, ok := report.(AIMetric); ok && observeOperationMetric(aiMetric) {
+				continue
+			}
+
 			tb.mutex.Lock()
 			push(report)
+			if aiMetric, ok := report.(AIMetric); ok {
+				summary.add(aiMetric)
+			}
 			tb.mutex.Unlock()
+		case <-ticker.C:
+			for _, aiMetric := range summary.flush() {
+				SendAIMetric(aiMetric)
+			}
 		case <-tb.cancel:
 			log.Logf("[Telemetry] server cancel event")
 			return
@@ -26,8 +26,9 @@ const (
 	FlagVersion           = "version"
 
 	// CNI Log Flags
-	FlagFollow      = "follow"
-	FlagLogFilePath = "log-file"
+	FlagFollow          = "follow"
+	FlagLogFilePath     = "log-file"
+	FlagJournalFilePath = "journal-file"
 
 	// tenancy flags
 	Singletenancy = "singletenancy"
@@ -67,6 +68,7 @@ const (
 	DefaultBinDirLinux      = "/opt/cni/bin/"
 	DefaultConflistDirLinux = "/etc/cni/net.d/"
 	DefaultLogFile          = "/var/log/azure-vnet.log"
+	DefaultJournalFile      = "/var/log/azure-vnet-iptables.journal"
 	Transparent             = "transparent"
 	Bridge                  = "bridge"
 	Azure0                  = "azure0"
@@ -101,6 +103,7 @@ var (
 		FlagConflistDirectory:          DefaultConflistDirLinux,
 		FlagVersion:                    Packaged,
 		FlagLogFilePath:                DefaultLogFile,
+		FlagJournalFilePath:            DefaultJournalFile,
 		FlagCNSUrl:                     DefaultCNSUrl,
 		FlagEnableExactMatchForPodName: DefaultEnableExactMatchForPodName,
 		EnvCNILogFile:                  EnvCNILogFile,
@@ -5,7 +5,9 @@ package cni
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/Azure/azure-container-networking/iptables"
 	c "github.com/Azure/azure-container-networking/tools/acncli/api"
 	"github.com/nxadm/tail"
 	"github.com/spf13/cobra"
@@ -20,6 +22,7 @@ func LogsCmd() *cobra.Command {
 		Long:  "The logs command is used to fetch and/or watch the logs of an ACN component",
 	}
 	cmd.AddCommand(LogsCNICmd())
+	cmd.AddCommand(LogsIptablesCmd())
 	return cmd
 }
 
@@ -51,3 +54,31 @@ func LogsCNICmd() *cobra.Command {
 
 	return cmd
 }
+
+// LogsIptablesCmd dumps the journal of the iptables changes made by ACN components
+func LogsIptablesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "iptables",
+		Short: "Dumps the journal of iptables rule changes",
+		Long:  "The iptables command prints every iptables rule change recorded by ACN components, oldest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := iptables.ReadJournal(viper.GetString(c.FlagJournalFilePath))
+			if err != nil {
+				return err
+			}
+
+			for i := range entries {
+				e := &entries[i]
+				fmt.Printf("%s %s %s: %s\n", e.Timestamp.Format(time.RFC3339Nano), e.Caller, e.Command, e.Result)
+				if e.Input != "" {
+					fmt.Println(e.Input)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().String(c.FlagJournalFilePath, c.Defaults[c.FlagJournalFilePath], "Path of the iptables journal file")
+
+	return cmd
+}
@@ -0,0 +1,137 @@
+// Package collector gathers the state, config, logs and dataplane snapshots of the ACN components on a node into a
+// single redacted tarball for support cases.
+package collector
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+const (
+	// DefaultSourceTimeout bounds each source, so that e.g. a hung HNS call doesn't block the whole bundle.
+	DefaultSourceTimeout = 30 * time.Second
+	manifestName         = "manifest.json"
+	entryPerm            = 0o644
+)
+
+// Source adds entries to a bundle.
+type Source interface {
+	// Collect adds the entries of the source to the bundle. An error is recorded in the manifest and the bundle
+	// goes on with the next source.
+	Collect(ctx context.Context, b *Bundle) error
+	// String describes the source in the manifest.
+	String() string
+}
+
+// Manifest describes the content of a bundle.
+type Manifest struct {
+	Hostname    string
+	CollectedAt time.Time
+	Entries     []string
+	// Errors are the sources which failed, a support bundle is still useful without them.
+	Errors []string `json:",omitempty"`
+}
+
+// Bundle is a tarball being collected.
+type Bundle struct {
+	tw       *tar.Writer
+	redactor *Redactor
+	now      time.Time
+	manifest Manifest
+}
+
+// Add adds an entry with the redacted content to the bundle.
+func (b *Bundle) Add(name string, content []byte) error {
+	return b.add(name, b.redactor.Redact(content))
+}
+
+func (b *Bundle) add(name string, content []byte) error {
+	hdr := &tar.Header{
+		Name:    path.Clean(name),
+		Mode:    entryPerm,
+		Size:    int64(len(content)),
+		ModTime: b.now,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write header of %s: %w", name, err)
+	}
+	if _, err := b.tw.Write(content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	b.manifest.Entries = append(b.manifest.Entries, hdr.Name)
+	return nil
+}
+
+// Collector collects the sources into a bundle.
+type Collector struct {
+	Sources  []Source
+	Redactor *Redactor
+	// Timeout bounds each source.
+	Timeout time.Duration
+}
+
+// New returns a collector of the sources which redacts the default secrets.
+func New(sources []Source) *Collector {
+	return &Collector{
+		Sources:  sources,
+		Redactor: NewRedactor(),
+		Timeout:  DefaultSourceTimeout,
+	}
+}
+
+// Collect writes the gzipped tarball of the sources to w. The manifest is added last so that it lists the
+// entries and the failed sources.
+func (c *Collector) Collect(ctx context.Context, w io.Writer) (*Manifest, error) {
+	gw := gzip.NewWriter(w)
+	b := &Bundle{
+		tw:       tar.NewWriter(gw),
+		redactor: c.Redactor,
+		now:      time.Now(),
+	}
+	b.manifest.CollectedAt = b.now
+	b.manifest.Hostname, _ = os.Hostname()
+
+	for _, source := range c.Sources {
+		if err := c.collect(ctx, b, source); err != nil {
+			b.manifest.Errors = append(b.manifest.Errors, fmt.Sprintf("%s: %v", source, err))
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("collection canceled: %w", ctx.Err())
+		}
+	}
+
+	manifest, err := json.MarshalIndent(&b.manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := b.add(manifestName, manifest); err != nil {
+		return nil, err
+	}
+
+	if err := b.tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tarball: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip stream: %w", err)
+	}
+
+	return &b.manifest, nil
+}
+
+func (c *Collector) collect(ctx context.Context, b *Bundle, source Source) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	return source.Collect(ctx, b)
+}
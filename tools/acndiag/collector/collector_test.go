@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readBundle returns the entries of the tarball by name.
+func readBundle(t *testing.T, r io.Reader) map[string]string {
+	gr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+
+	entries := make(map[string]string)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries[hdr.Name] = string(content)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "azure-vnet.json")
+	require.NoError(t, os.WriteFile(state, []byte(`{"Network": {"Token": "abc"}}`), 0o600))
+
+	oldLog := filepath.Join(dir, "azure-vnet.log.1")
+	require.NoError(t, os.WriteFile(oldLog, []byte("old"), 0o600))
+	require.NoError(t, os.Chtimes(oldLog, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)))
+	newLog := filepath.Join(dir, "azure-vnet.log")
+	require.NoError(t, os.WriteFile(newLog, []byte("line 1\nline 2\n"), 0o600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/debug/ipaddresses" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"IPConfigurationStatus": []}`))
+	}))
+	defer server.Close()
+
+	c := New([]Source{
+		&FileSource{Pattern: state},
+		&FileSource{Pattern: filepath.Join(dir, "azure-vnet.log*"), MaxAge: 24 * time.Hour, MaxSize: 7},
+		&FileSource{Pattern: filepath.Join(dir, "azure-cns*.json"), Optional: true},
+		&FileSource{Pattern: filepath.Join(dir, "missing.json")},
+		&HTTPSource{Name: "cns-ipaddresses", URL: server.URL + "/debug/ipaddresses"},
+		&HTTPSource{Name: "npm-manager", URL: server.URL + "/npm/v1/debug/manager"},
+	})
+
+	var buf bytes.Buffer
+	manifest, err := c.Collect(context.Background(), &buf)
+	require.NoError(t, err)
+	require.Len(t, manifest.Errors, 2)
+	require.True(t, strings.HasPrefix(manifest.Errors[0], "files "))
+	require.Contains(t, manifest.Errors[1], "404")
+
+	entries := readBundle(t, &buf)
+	require.Len(t, entries, 4)
+	require.Equal(t, `{"Network": {"Token": "REDACTED"}}`, entries[path.Join("files", entryPath(state))])
+	require.Equal(t, "line 2\n", entries[path.Join("files", entryPath(newLog))], "only the tail of the log is kept")
+	require.Equal(t, `{"IPConfigurationStatus": []}`, entries["http/cns-ipaddresses.json"])
+
+	var bundled Manifest
+	require.NoError(t, json.Unmarshal([]byte(entries[manifestName]), &bundled))
+	require.Equal(t, manifest.Entries[:3], bundled.Entries)
+	require.Equal(t, manifest.Errors, bundled.Errors)
+}
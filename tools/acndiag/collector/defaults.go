@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	npmapi "github.com/Azure/azure-container-networking/npm/http/api"
+)
+
+const (
+	// DefaultLogMaxAge skips the logs which haven't been written to in the last day.
+	DefaultLogMaxAge = 24 * time.Hour
+	// DefaultLogMaxSize keeps the last 20MB of each log.
+	DefaultLogMaxSize = 20 << 20
+	DefaultCNSURL     = "http://localhost:10090"
+	DefaultNPMURL     = "http://localhost:10091"
+)
+
+// Options configure the default sources.
+type Options struct {
+	LogMaxAge  time.Duration
+	LogMaxSize int64
+	CNSURL     string
+	NPMURL     string
+}
+
+// DefaultSources returns the sources of a support bundle of the node: the CNI and CNS state files, the debug APIs
+// of CNS and NPM, the dataplane snapshots and the recent logs.
+func DefaultSources(opts Options) []Source {
+	sources := platformSources(opts)
+
+	// CNS and NPM don't run on every node, the errors of their APIs in the manifest are expected there.
+	sources = append(sources,
+		&HTTPSource{Name: "cns-ipaddresses", URL: opts.CNSURL + cns.PathDebugIPAddresses},
+		&HTTPSource{Name: "cns-podcontext", URL: opts.CNSURL + cns.PathDebugPodContext},
+		&HTTPSource{Name: "cns-restdata", URL: opts.CNSURL + cns.PathDebugRestData},
+		&HTTPSource{Name: "cns-podipassignments", URL: opts.CNSURL + cns.PathDebugPodIPAssignments},
+		&HTTPSource{Name: "npm-manager", URL: opts.NPMURL + npmapi.NPMMgrPath},
+		&HTTPSource{Name: "npm-config", URL: opts.NPMURL + npmapi.NPMConfigPath},
+		&HTTPSource{Name: "npm-ipsets", URL: opts.NPMURL + npmapi.IPSetsPath},
+		&HTTPSource{Name: "npm-policies", URL: opts.NPMURL + npmapi.PoliciesPath},
+	)
+
+	return sources
+}
+
+// logSource returns the source of the logs matching the pattern.
+func logSource(pattern string, opts Options) *FileSource {
+	return &FileSource{Pattern: pattern, MaxAge: opts.LogMaxAge, MaxSize: opts.LogMaxSize, Optional: true}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// cnsEndpointStatePath is where CNS keeps the endpoint state when it manages the state of the endpoints.
+const cnsEndpointStatePath = "/var/run/azure-cns/"
+
+func platformSources(opts Options) []Source {
+	return []Source{
+		// state
+		&FileSource{Pattern: platform.CNIRuntimePath + "azure-vnet*.json"},
+		&FileSource{Pattern: platform.CNMRuntimePath + "*.json", Optional: true},
+		&FileSource{Pattern: cnsEndpointStatePath + "*.json", Optional: true},
+		&FileSource{Pattern: filepath.Join(platform.K8SNetConfigPath, "*"), Optional: true},
+		&FileSource{Pattern: telemetry.CNITelemetryFile, Optional: true},
+		&CommandSource{Name: "azure-vnet-state", Args: []string{platform.CNIBinaryPath, "state", "dump"}},
+
+		// dataplane
+		&CommandSource{Name: "ip-addr", Args: []string{"ip", "-d", "addr", "show"}},
+		&CommandSource{Name: "ip-link", Args: []string{"ip", "-d", "-s", "link", "show"}},
+		&CommandSource{Name: "ip-route", Args: []string{"ip", "route", "show", "table", "all"}},
+		&CommandSource{Name: "ip6-route", Args: []string{"ip", "-6", "route", "show", "table", "all"}},
+		&CommandSource{Name: "ip-rule", Args: []string{"ip", "rule", "show"}},
+		&CommandSource{Name: "ip-neigh", Args: []string{"ip", "neigh", "show"}},
+		&CommandSource{Name: "iptables", Args: []string{"iptables-save", "-c"}},
+		&CommandSource{Name: "ip6tables", Args: []string{"ip6tables-save", "-c"}},
+		&CommandSource{Name: "ipset", Args: []string{"ipset", "save"}},
+		&CommandSource{Name: "ebtables", Args: []string{"ebtables", "-t", "nat", "-L", "--Lc"}},
+		&CommandSource{Name: "conntrack", Args: []string{"conntrack", "-S"}},
+
+		// logs
+		logSource(filepath.Join(log.LogPath, "azure-vnet*.log*"), opts),
+		logSource(filepath.Join(log.LogPath, "azure-vnet-iptables.journal"), opts),
+		logSource(filepath.Join(log.LogPath, "azure-cns*.log*"), opts),
+		logSource(filepath.Join(log.LogPath, "azure-npm*.log*"), opts),
+	}
+}
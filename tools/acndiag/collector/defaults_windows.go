@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// kubeDir is where the CNI state, logs and telemetry live on Windows nodes.
+const kubeDir = "C:\\k"
+
+// hnsCommand returns the command which dumps the HNS objects of the cmdlet as JSON.
+func hnsCommand(name, cmdlet string) *CommandSource {
+	return &CommandSource{
+		Name: name,
+		Args: []string{"powershell.exe", "-NoProfile", "-Command", cmdlet + " | ConvertTo-Json -Depth 20"},
+	}
+}
+
+func platformSources(opts Options) []Source {
+	return []Source{
+		// state
+		&FileSource{Pattern: filepath.Join(kubeDir, "azure-vnet*.json")},
+		&FileSource{Pattern: filepath.Join(kubeDir, "azure-cns*.json"), Optional: true},
+		&FileSource{Pattern: filepath.Join(platform.K8SNetConfigPath, "*"), Optional: true},
+		&FileSource{Pattern: filepath.Join(kubeDir, "AzureCNITelemetry.json"), Optional: true},
+		&CommandSource{Name: "azure-vnet-state", Args: []string{platform.CNIBinaryPath, "state", "dump"}},
+
+		// dataplane
+		hnsCommand("hns-networks", "Get-HnsNetwork"),
+		hnsCommand("hns-endpoints", "Get-HnsEndpoint"),
+		hnsCommand("hns-policies", "Get-HnsPolicyList"),
+		&CommandSource{Name: "ipconfig", Args: []string{"ipconfig", "/allcompartments", "/all"}},
+		&CommandSource{Name: "route", Args: []string{"route", "print"}},
+		&CommandSource{Name: "vfp-ports", Args: []string{"vfpctrl.exe", "/list-vmswitch-port"}},
+
+		// logs
+		logSource(filepath.Join(kubeDir, "azure-vnet*.log*"), opts),
+		logSource(filepath.Join(kubeDir, "azure-cns*.log*"), opts),
+		logSource(filepath.Join(kubeDir, "azure-npm*.log*"), opts),
+	}
+}
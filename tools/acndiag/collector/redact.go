@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"regexp"
+)
+
+// Redacted replaces the secrets in the bundle.
+const Redacted = "REDACTED"
+
+// secretNames are the parts of the names of fields and parameters which hold secrets.
+const secretNames = `password|passwd|secret|token|privatekey|private_key|accesskey|access_key|apikey|api_key|credential|connectionstring`
+
+// Redactor removes secrets from the content added to a bundle.
+type Redactor struct {
+	rules []redactRule
+}
+
+type redactRule struct {
+	re *regexp.Regexp
+	// replacement keeps the name of the secret, which is useful to know, and drops its value
+	replacement string
+}
+
+// NewRedactor returns a Redactor for the secrets which show up in the state, config and logs of the components:
+// JSON fields and key=value parameters whose name suggests a secret, authorization headers, SAS signatures and
+// PEM private keys. Extra patterns are redacted entirely.
+func NewRedactor(extra ...*regexp.Regexp) *Redactor {
+	r := &Redactor{
+		rules: []redactRule{
+			{
+				re:          regexp.MustCompile(`(?i)("[^"]*(?:` + secretNames + `)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`),
+				replacement: `${1}"` + Redacted + `"`,
+			},
+			{
+				re:          regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*["']?(?:bearer|basic)\s+)[^\s"']+`),
+				replacement: `${1}` + Redacted,
+			},
+			{
+				re:          regexp.MustCompile(`(?i)(\b[\w.-]*(?:` + secretNames + `)[\w.-]*\s*[=:]\s*)[^\s"',;&]+`),
+				replacement: `${1}` + Redacted,
+			},
+			{
+				re:          regexp.MustCompile(`(?i)([?&]sig=)[^&\s"']+`),
+				replacement: `${1}` + Redacted,
+			},
+			{
+				re:          regexp.MustCompile(`-----BEGIN ([A-Z ]*)PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+				replacement: `-----BEGIN ${1}PRIVATE KEY-----` + Redacted + `-----END ${1}PRIVATE KEY-----`,
+			},
+		},
+	}
+
+	for _, re := range extra {
+		r.rules = append(r.rules, redactRule{re: re, replacement: Redacted})
+	}
+
+	return r
+}
+
+// Redact returns the content with the secrets replaced.
+func (r *Redactor) Redact(content []byte) []byte {
+	for _, rule := range r.rules {
+		content = rule.re.ReplaceAll(content, []byte(rule.replacement))
+	}
+
+	return content
+}
@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var errNoFiles = errors.New("no files match")
+
+// FileSource collects the files matching a glob pattern, such as state files, configs and logs.
+type FileSource struct {
+	Pattern string
+	// MaxAge skips files which weren't modified recently, e.g. rotated logs. Zero collects every file.
+	MaxAge time.Duration
+	// MaxSize keeps the tail of larger files, which is the part of a log that matters. Zero keeps the whole file.
+	MaxSize int64
+	// Optional doesn't record an error when no file matches, for files which only exist in some setups.
+	Optional bool
+}
+
+func (s *FileSource) String() string {
+	return "files " + s.Pattern
+}
+
+// Collect adds the matching files under files/ with their path on the node.
+func (s *FileSource) Collect(_ context.Context, b *Bundle) error {
+	matches, err := filepath.Glob(s.Pattern)
+	if err != nil {
+		return fmt.Errorf("bad pattern: %w", err)
+	}
+
+	collected := 0
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if s.MaxAge > 0 && b.now.Sub(info.ModTime()) > s.MaxAge {
+			continue
+		}
+
+		content, err := readTail(match, s.MaxSize)
+		if err != nil {
+			return err
+		}
+		if err := b.Add(path.Join("files", entryPath(match)), content); err != nil {
+			return err
+		}
+		collected++
+	}
+
+	if collected == 0 && !s.Optional {
+		return errNoFiles
+	}
+
+	return nil
+}
+
+// readTail reads the last maxSize bytes of the file, or the whole file if maxSize is zero.
+func readTail(name string, maxSize int64) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if maxSize > 0 {
+		if info, err := f.Stat(); err == nil && info.Size() > maxSize {
+			if _, err := f.Seek(-maxSize, io.SeekEnd); err != nil {
+				return nil, fmt.Errorf("failed to seek %s: %w", name, err)
+			}
+		}
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	return content, nil
+}
+
+// entryPath returns the path of the entry of a file on the node, without the volume and leading separator.
+func entryPath(name string) string {
+	name = strings.TrimPrefix(name, filepath.VolumeName(name))
+	return strings.TrimPrefix(filepath.ToSlash(name), "/")
+}
+
+// CommandSource collects the output of a command, such as a dataplane snapshot.
+type CommandSource struct {
+	// Name is the name of the entry under commands/.
+	Name string
+	Args []string
+}
+
+func (s *CommandSource) String() string {
+	return "command " + strings.Join(s.Args, " ")
+}
+
+// Collect adds the output of the command. The output of a failed command is added as well, it often tells why.
+func (s *CommandSource) Collect(ctx context.Context, b *Bundle) error {
+	//nolint:gosec // the commands are fixed by the collector
+	out, cmdErr := exec.CommandContext(ctx, s.Args[0], s.Args[1:]...).CombinedOutput()
+	if len(out) > 0 || cmdErr == nil {
+		if err := b.Add(path.Join("commands", s.Name+".txt"), out); err != nil {
+			return err
+		}
+	}
+
+	if cmdErr != nil {
+		return fmt.Errorf("command failed: %w", cmdErr)
+	}
+
+	return nil
+}
+
+// HTTPSource collects the response of a debug API, such as the state of CNS or the caches of NPM.
+type HTTPSource struct {
+	// Name is the name of the entry under http/.
+	Name string
+	URL  string
+}
+
+func (s *HTTPSource) String() string {
+	return "GET " + s.URL
+}
+
+// Collect adds the body of a successful response.
+func (s *HTTPSource) Collect(ctx context.Context, b *Bundle) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return b.Add(path.Join("http", s.Name+".json"), body)
+}
@@ -0,0 +1,78 @@
+// acn-diag collects the state, config, dataplane snapshots and recent logs of the ACN components on a node into a
+// single redacted tarball for support cases.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Azure/azure-container-networking/tools/acndiag/collector"
+	"github.com/spf13/cobra"
+)
+
+var version = ""
+
+func collectCmd() *cobra.Command {
+	opts := collector.Options{}
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "collect",
+		Short: "Collects a support bundle of the node",
+		Long: "The collect command gathers the CNI and CNS state, the NPM and CNS debug APIs, the iptables, ipset or HNS " +
+			"snapshots, the recent logs and the telemetry buffer into a tarball. Secrets are redacted.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if output == "" {
+				hostname, _ := os.Hostname()
+				output = fmt.Sprintf("acn-diag-%s-%s.tar.gz", hostname, time.Now().UTC().Format("20060102T150405Z"))
+			}
+
+			f, err := os.OpenFile(output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+			if err != nil {
+				return fmt.Errorf("failed to create bundle: %w", err)
+			}
+			defer f.Close()
+
+			ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer cancel()
+
+			manifest, err := collector.New(collector.DefaultSources(opts)).Collect(ctx, f)
+			if err != nil {
+				os.Remove(output)
+				return err
+			}
+
+			fmt.Printf("collected %d entries into %s\n", len(manifest.Entries), output)
+			for _, sourceErr := range manifest.Errors {
+				fmt.Printf("skipped %s\n", sourceErr)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Path of the bundle, acn-diag-<host>-<time>.tar.gz by default")
+	cmd.Flags().DurationVar(&opts.LogMaxAge, "log-max-age", collector.DefaultLogMaxAge, "Skip logs which were not written to for longer, 0 collects every log")
+	cmd.Flags().Int64Var(&opts.LogMaxSize, "log-max-size", collector.DefaultLogMaxSize, "Keep the last bytes of larger logs, 0 keeps whole logs")
+	cmd.Flags().StringVar(&opts.CNSURL, "cns-url", collector.DefaultCNSURL, "URL of the CNS API")
+	cmd.Flags().StringVar(&opts.NPMURL, "npm-url", collector.DefaultNPMURL, "URL of the NPM debug API")
+
+	return cmd
+}
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:          "acn-diag",
+		Short:        "Collects diagnostics of the Azure Container Networking components",
+		SilenceUsage: true,
+		Version:      version,
+	}
+	rootCmd.AddCommand(collectCmd())
+
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
+		os.Exit(1)
+	}
+}